@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	backupServices "github.com/rachel-lawrie/verus_app_backend/internal/backup/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/kmsprovider"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+const usage = `Takes and restores encrypted client snapshots in the backup bucket.
+
+  backup -env prod snapshot -client <client_id>   Snapshot one client
+  backup -env prod snapshot -all                  Snapshot every client
+  backup -env prod restore -manifest <key>        Restore a snapshot from its manifest
+  backup -env prod restore -manifest <key> -dry-run
+                                                  Decrypt and check a snapshot without writing it
+`
+
+func main() {
+	env := flag.String("env", "dev", "environment profile to connect with")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.LoadConfig(*env)
+	settings := config.LoadSettings(*env)
+	if settings.Backups.Bucket == "" {
+		log.Fatalf("backups.bucket is not set for %s", *env)
+	}
+	if err := mongoretry.CheckURI(cfg.Database.AtlasConnectionURI); err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	if err := common.ConnectDatabase(cfg.Database); err != nil {
+		log.Fatalf("Could not connect to database: %v", err)
+	}
+
+	service := backupServices.GetBackupServiceImpl()
+	uploader, err := utils.NewS3Uploader(settings.Backups.Bucket, cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey)
+	if err != nil {
+		log.Fatalf("Could not initialize backup uploader: %v", err)
+	}
+	service.Uploader = uploader
+	service.KMSUploader, err = kmsprovider.New(cfg, settings.KMS, nil)
+	if err != nil {
+		log.Fatalf("Could not initialize KMS uploader: %v", err)
+	}
+
+	ctx := context.Background()
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "snapshot":
+		flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+		clientID := flags.String("client", "", "client to snapshot")
+		all := flags.Bool("all", false, "snapshot every client")
+		flags.Parse(args)
+		if *all {
+			if err := service.SnapshotAll(ctx); err != nil {
+				log.Fatalf("Some snapshots failed: %v", err)
+			}
+			log.Printf("Snapshotted every client")
+			return
+		}
+		if *clientID == "" {
+			log.Fatalf("snapshot needs -client or -all")
+		}
+		backup, err := service.Snapshot(ctx, *clientID, "")
+		if err != nil {
+			log.Fatalf("Snapshot failed: %v", err)
+		}
+		printJSON(backup)
+
+	case "restore":
+		flags := flag.NewFlagSet("restore", flag.ExitOnError)
+		manifestKey := flags.String("manifest", "", "key of the snapshot's manifest in the backup bucket")
+		dryRun := flags.Bool("dry-run", false, "decrypt and check the snapshot without writing it")
+		flags.Parse(args)
+		if *manifestKey == "" {
+			log.Fatalf("restore needs -manifest")
+		}
+		result, err := service.Restore(ctx, *manifestKey, *dryRun)
+		printJSON(result)
+		if err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func printJSON(value interface{}) {
+	out, _ := json.MarshalIndent(value, "", "  ")
+	fmt.Println(string(out))
+}
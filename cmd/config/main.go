@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Prints the effective configuration for an environment profile, after the
+// base profile and any inherited profiles have been merged. Secrets are redacted.
+func main() {
+	env := flag.String("env", "dev", "environment profile to print")
+	check := flag.Bool("check", false, "validate the configuration as the server does at startup, and exit 1 if it is invalid")
+	flag.Parse()
+
+	settings, ok, err := config.EffectiveConfig(*env)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if !ok {
+		names, _ := config.ProfileNames()
+		sort.Strings(names)
+		log.Fatalf("No profile %q in config/config.yaml (available: %s)", *env, strings.Join(names, ", "))
+	}
+
+	if *check {
+		if err := config.Validate(*env, config.LoadConfig(*env), config.LoadSettings(*env)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stdout, "Config for %s is valid\n", *env)
+		return
+	}
+
+	out, err := yaml.Marshal(config.Redact(settings))
+	if err != nil {
+		log.Fatalf("Error encoding config: %v", err)
+	}
+	fmt.Fprintf(os.Stdout, "# Effective config for %s\n%s", *env, out)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/accesslog"
 	"github.com/rachel-lawrie/verus_app_backend/internal/app/controller"
-	"github.com/rachel-lawrie/verus_backend_core/common"
 	"github.com/rachel-lawrie/verus_backend_core/errors"
 	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
 	"go.uber.org/zap"
@@ -10,6 +12,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rachel-lawrie/verus_app_backend/app"
 	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/crashreport"
+	"github.com/rachel-lawrie/verus_app_backend/internal/diagnostics"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoclient"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoindex"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/startup"
+	"github.com/rachel-lawrie/verus_app_backend/internal/trustedproxy"
 )
 
 const ENV = "dev"
@@ -25,12 +35,29 @@ func main() {
 		zap.String("app", "verus"),
 		zap.String("env", ENV),
 	)
+	// Follow the log level set through the diagnostics port
+	logger = diagnostics.Leveled(logger)
 
 	// Load the configuration for the dev environment
 	cfg := config.LoadConfig(ENV)
+	settings := config.LoadSettings(ENV)
+	if err := config.Validate(ENV, cfg, settings); err != nil {
+		logger.Fatal("Critical error occurred",
+			zap.Error(err),
+			zap.String("action", "validating configuration"),
+		)
+	}
 
-	logger.Debug("Running application with configuration",
-		zap.Any("config", cfg),
+	// Profiles and the runtime log level, on a port only reachable from this host
+	if err := diagnostics.Start(settings.Diagnostics.Addr, settings.Diagnostics.LogLevel, diagnostics.Sampling(settings.Diagnostics.LogSampling)); err != nil {
+		logger.Fatal("Critical error occurred",
+			zap.Error(err),
+			zap.String("action", "starting diagnostics"),
+		)
+	}
+
+	logger.Info("Running application with configuration",
+		zap.Any("config", config.Summary(ENV, cfg, settings)),
 	)
 
 	// Log the start of the dev server
@@ -39,31 +66,71 @@ func main() {
 	)
 
 	// Connect to the database
-	if err := common.ConnectDatabase(cfg.Database); err != nil {
+	if err := mongoretry.CheckURI(cfg.Database.AtlasConnectionURI); err != nil {
+		logger.Fatal("Invalid database configuration", zap.Error(err))
+	}
+	if err := mongoclient.Connect(cfg.Database, settings.Mongo); err != nil {
 		// Log the fatal error with context and exit the application
 		logger.Fatal("Critical error occurred",
 			zap.Error(err), // Log the error
 			zap.String("action", "connecting to database"), // Log the action
 		)
 	}
+	// Check every dependency before binding the port, so a broken deployment fails here
+	if err := startup.Verify(context.Background(), startup.Checks(cfg, settings), startup.PolicyFrom(settings.Startup)); err != nil {
+		logger.Fatal("Critical error occurred",
+			zap.Error(err),
+			zap.Strings("dependencies", startup.Names(err)),
+			zap.String("action", "checking dependencies"),
+		)
+	}
+	if err := mongoindex.Ensure(context.Background()); err != nil {
+		logger.Fatal("Critical error occurred",
+			zap.Error(err),
+			zap.String("action", "creating database indexes"),
+		)
+	}
+	if err := mongoschema.Ensure(context.Background()); err != nil {
+		logger.Fatal("Critical error occurred",
+			zap.Error(err),
+			zap.String("action", "installing schema validators"),
+		)
+	}
 
 	// Set Gin to release mode
 	gin.SetMode(gin.ReleaseMode)
 
-	r := gin.Default()
-
-	// Use recovery middleware
-	r.Use(gin.Recovery())
+	// gin's console log, leaving out query strings. Panics are left to crashreport.Recovery.
+	r := gin.New()
+	r.Use(accesslog.Console())
+	// Only the load balancers in front of the service may say where a request came from
+	if err := trustedproxy.Configure(r, settings.Proxies); err != nil {
+		logger.Fatal("Critical error occurred",
+			zap.Error(err),
+			zap.String("action", "configuring trusted proxies"),
+		)
+	}
 
 	// Add global error handler middleware
 	r.Use(errors.ErrorHandler())
 
-	// Add Zap logger middleware
-	r.Use(zaplogger.ZapLogger(logger))
+	// One structured entry per request, with the client, sizes and upstream timings
+	r.Use(accesslog.Middleware(logger))
+
+	// Answer panics with a correlation ID and report them with the request's context
+	reporter, err := crashreport.New(settings.ErrorReporting, ENV)
+	if err != nil {
+		logger.Fatal("Critical error occurred",
+			zap.Error(err),
+			zap.String("action", "configuring error reporting"),
+		)
+	}
+	r.Use(crashreport.Recovery(reporter, logger))
 
 	appController := controller.New(controller.Params{
-		Router: r,
-		Config: &cfg,
+		Router:   r,
+		Config:   &cfg,
+		Settings: &settings,
 	})
 	appController.InitializeRoutes()
 
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// result is the outcome of one upload
+type result struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// Uploads documents to a running stack at a fixed rate and reports the latency
+// distribution, exiting non-zero when the p99 or the error rate is over budget. Each
+// run creates its own applicant, so point it at a local or sandbox stack and a sandbox
+// API key.
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "base URL of the API")
+	apiKey := flag.String("api-key", os.Getenv("LOADTEST_API_KEY"), "client API key (default $LOADTEST_API_KEY)")
+	rate := flag.Int("rate", 10, "uploads started per second")
+	duration := flag.Duration("duration", 30*time.Second, "how long to keep starting uploads")
+	workers := flag.Int("workers", 50, "most uploads in flight at once")
+	size := flag.Int("size", 1<<20, "size of each uploaded file in bytes")
+	documentType := flag.String("document-type", "passport", "document type of each upload")
+	country := flag.String("country", "GB", "issuing country of each upload")
+	level := flag.String("level", "basic", "verification level of the test applicant")
+	p99Budget := flag.Duration("p99-budget", 2*time.Second, "highest acceptable p99 upload latency")
+	maxErrorRate := flag.Float64("max-error-rate", 0.01, "highest acceptable share of failed uploads")
+	flag.Parse()
+
+	if *apiKey == "" {
+		log.Fatalf("An API key is required, pass -api-key or set LOADTEST_API_KEY")
+	}
+	if *rate <= 0 || *workers <= 0 || *size <= 0 {
+		log.Fatalf("rate, workers and size must be positive")
+	}
+	apiURL := strings.TrimRight(*baseURL, "/") + "/api/v2"
+	client := &http.Client{Timeout: time.Minute}
+
+	applicantID, err := createApplicant(client, apiURL, *apiKey, *level)
+	if err != nil {
+		log.Fatalf("Error creating the test applicant: %v", err)
+	}
+	body, contentType, err := uploadBody(*size, *documentType, *country)
+	if err != nil {
+		log.Fatalf("Error building the upload: %v", err)
+	}
+	uploadURL := apiURL + "/applicants/" + applicantID + "/documents"
+	log.Printf("Uploading %d byte documents for applicant %s at %d/s for %s", *size, applicantID, *rate, *duration)
+
+	var (
+		results  []result
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		inFlight = make(chan struct{}, *workers)
+		dropped  int
+	)
+	ticker := time.NewTicker(time.Second / time.Duration(*rate))
+	defer ticker.Stop()
+	deadline := time.Now().Add(*duration)
+	for time.Now().Before(deadline) {
+		<-ticker.C
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			// Every worker is busy, so the stack is not keeping up with the rate
+			dropped++
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			r := upload(client, uploadURL, *apiKey, body, contentType)
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if !report(results, dropped, *p99Budget, *maxErrorRate) {
+		os.Exit(1)
+	}
+}
+
+// createApplicant creates the applicant the uploads are made for
+func createApplicant(client *http.Client, apiURL, apiKey, level string) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"first_name":  "Load",
+		"middle_name": "",
+		"last_name":   "Test",
+		"email":       "loadtest@example.com",
+		"phone":       "+440000000000",
+		"dob":         "1990-01-01",
+		"level":       level,
+		"address":     map[string]string{"Line1": "1 Test Street", "City": "London", "PostalCode": "N1 1AA", "Country": "GB"},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, apiURL+"/applicants", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d: %s", resp.StatusCode, respBody)
+	}
+	var created struct {
+		ApplicantID string `json:"applicant_id"`
+	}
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return "", err
+	}
+	return created.ApplicantID, nil
+}
+
+// uploadBody builds the form every upload sends, a random file that sniffs as a PNG
+func uploadBody(size int, documentType, country string) ([]byte, string, error) {
+	content := make([]byte, size)
+	if _, err := rand.Read(content); err != nil {
+		return nil, "", err
+	}
+	copy(content, "\x89PNG\r\n\x1a\n")
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("document_type", documentType)
+	writer.WriteField("country", country)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="document"; filename="loadtest.png"`)
+	header.Set("Content-Type", "image/png")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+// upload sends one document and times it until the whole response is read
+func upload(client *http.Client, uploadURL, apiKey string, body []byte, contentType string) result {
+	req, err := http.NewRequest(http.MethodPost, uploadURL, bytes.NewReader(body))
+	if err != nil {
+		return result{err: err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-API-Key", apiKey)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{latency: time.Since(start), err: err}
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return result{status: resp.StatusCode, latency: time.Since(start), err: err}
+}
+
+// report prints the status counts and latency percentiles, and whether the run was
+// within budget
+func report(results []result, dropped int, p99Budget time.Duration, maxErrorRate float64) bool {
+	if len(results) == 0 {
+		log.Printf("No uploads completed, %d dropped", dropped)
+		return false
+	}
+
+	statuses := map[string]int{}
+	latencies := make([]time.Duration, 0, len(results))
+	failed := dropped
+	var total time.Duration
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			statuses["error"]++
+			failed++
+		default:
+			// Large files are accepted with 202 while their scan is pending
+			statuses[fmt.Sprint(r.status)]++
+			if r.status != http.StatusOK && r.status != http.StatusAccepted {
+				failed++
+			}
+		}
+		latencies = append(latencies, r.latency)
+		total += r.latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	fmt.Printf("Requests   %d sent, %d dropped\n", len(results), dropped)
+	codes := make([]string, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Printf("Status     %s: %d\n", code, statuses[code])
+	}
+	fmt.Printf("Latencies  min %s, mean %s, p50 %s, p90 %s, p95 %s, p99 %s, max %s\n",
+		latencies[0], total/time.Duration(len(latencies)), percentile(0.50), percentile(0.90),
+		percentile(0.95), percentile(0.99), latencies[len(latencies)-1])
+
+	ok := true
+	if p99 := percentile(0.99); p99 > p99Budget {
+		fmt.Printf("FAIL: p99 of %s is over the %s budget\n", p99, p99Budget)
+		ok = false
+	}
+	if rate := float64(failed) / float64(len(results)+dropped); rate > maxErrorRate {
+		fmt.Printf("FAIL: %.2f%% of uploads failed, more than %.2f%%\n", rate*100, maxErrorRate*100)
+		ok = false
+	}
+	return ok
+}
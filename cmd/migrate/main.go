@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/migration"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoindex"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+)
+
+// Moves documents embedded in applicant records into the documents collection, and
+// rewrites applicant statuses stored as strings as the ints the core package stores.
+// Run it once the release that reads the documents collection is deployed, and
+// again afterwards to pick up anything written by instances on the old release.
+func main() {
+	env := flag.String("env", "dev", "environment profile to connect with")
+	flag.Parse()
+
+	cfg := config.LoadConfig(*env)
+	if err := mongoretry.CheckURI(cfg.Database.AtlasConnectionURI); err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	if err := common.ConnectDatabase(cfg.Database); err != nil {
+		log.Fatalf("Could not connect to database: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := mongoindex.Ensure(ctx); err != nil {
+		log.Fatalf("Could not create database indexes: %v", err)
+	}
+
+	result, err := migration.MoveDocuments(ctx,
+		common.GetCollection(constants.CollectionApplicants),
+		common.GetCollection(localConstants.CollectionDocuments),
+	)
+	log.Printf("Moved %d documents from %d applicants, %d applicants left for another run", result.Documents, result.Applicants, result.Skipped)
+	if err != nil {
+		log.Fatalf("Document migration failed: %v", err)
+	}
+
+	statuses, err := migration.ApplicantStatuses(ctx, common.GetCollection(constants.CollectionApplicants))
+	log.Printf("Rewrote %d applicant statuses stored as strings", statuses)
+	if err != nil {
+		log.Fatalf("Status migration failed: %v", err)
+	}
+
+	// Installed after the migrations, so documents moved out of older applicants are
+	// inserted before the documents validator applies to them
+	if err := mongoschema.Ensure(ctx); err != nil {
+		log.Fatalf("Could not install schema validators: %v", err)
+	}
+}
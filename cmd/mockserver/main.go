@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/contract"
+)
+
+// Serves the examples in the API description in place of the API, for testing client
+// SDKs without a running backend or database. Send Prefer: code=404 to get a documented
+// error response instead of the success example.
+func main() {
+	specPath := flag.String("spec", contract.DefaultPath, "API description to serve")
+	addr := flag.String("addr", ":4010", "address to listen on")
+	flag.Parse()
+
+	spec, err := contract.Load(*specPath)
+	if err != nil {
+		log.Fatalf("Error loading API description: %v", err)
+	}
+	log.Printf("Mocking %d operations under %s on %s", len(spec.Routes()), spec.BasePath(), *addr)
+	log.Fatal(http.ListenAndServe(*addr, spec.Mock()))
+}
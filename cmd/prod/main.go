@@ -1,28 +1,85 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/rachel-lawrie/verus_app_backend/app"
 	"github.com/rachel-lawrie/verus_app_backend/internal/app/controller"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/accesslog"
 	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/crashreport"
+	"github.com/rachel-lawrie/verus_app_backend/internal/diagnostics"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoclient"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoindex"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/startup"
+	"github.com/rachel-lawrie/verus_app_backend/internal/trustedproxy"
+	"go.uber.org/zap"
 )
 
 func main() {
 	cfg := config.LoadConfig("prod") // or "dev"
+	settings := config.LoadSettings("prod")
+	if err := config.Validate("prod", cfg, settings); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
 	log.Printf("Starting server on port %s...\n", cfg.Server.Port)
 
-	r := gin.Default()
+	// Profiles and the runtime log level, on a port only reachable from this host
+	if err := diagnostics.Start(settings.Diagnostics.Addr, settings.Diagnostics.LogLevel, diagnostics.Sampling(settings.Diagnostics.LogSampling)); err != nil {
+		log.Fatalf("Could not start diagnostics: %v", err)
+	}
+
+	// Connect to the database
+	if err := mongoretry.CheckURI(cfg.Database.AtlasConnectionURI); err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	if err := mongoclient.Connect(cfg.Database, settings.Mongo); err != nil {
+		log.Fatalf("Could not connect to database: %v", err)
+	}
+	// Check every dependency before binding the port, so a broken deployment fails here
+	if err := startup.Verify(context.Background(), startup.Checks(cfg, settings), startup.PolicyFrom(settings.Startup)); err != nil {
+		log.Fatalf("Dependencies unavailable %v: %v", startup.Names(err), err)
+	}
+	if err := mongoindex.Ensure(context.Background()); err != nil {
+		log.Fatalf("Could not create database indexes: %v", err)
+	}
+	if err := mongoschema.Ensure(context.Background()); err != nil {
+		log.Fatalf("Could not install schema validators: %v", err)
+	}
+
+	// gin's console log, leaving out query strings. Panics are left to crashreport.Recovery.
+	r := gin.New()
+	r.Use(accesslog.Console())
+	// Only the load balancers in front of the service may say where a request came from
+	if err := trustedproxy.Configure(r, settings.Proxies); err != nil {
+		log.Fatalf("Could not configure trusted proxies: %v", err)
+	}
+
+	// One structured entry per request, with the client, sizes and upstream timings
+	logger := diagnostics.Logger().With(zap.String("app", "verus"), zap.String("env", "prod"))
+	r.Use(accesslog.Middleware(logger))
+
+	// Answer panics with a correlation ID and report them with the request's context
+	reporter, err := crashreport.New(settings.ErrorReporting, "prod")
+	if err != nil {
+		log.Fatalf("Could not configure error reporting: %v", err)
+	}
+	r.Use(crashreport.Recovery(reporter, logger))
 
 	// Start the server using the configured port
-	log.Printf("Running application with configuration: %+v\n", cfg)
+	log.Printf("Running application with configuration: %v\n", config.Summary("prod", cfg, settings))
 
 	appController := controller.New(controller.Params{
-		Router: r,
-		Config: &cfg,
+		Router:   r,
+		Config:   &cfg,
+		Settings: &settings,
 	})
+	appController.InitializeRoutes()
 
 	serviceApp := app.Build(app.Params{
 		Router:     r,
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/kmsprovider"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/seed"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Fills a development database with synthetic applicants and documents for one client
+func main() {
+	env := flag.String("env", "dev", "environment profile to connect with; prod is refused")
+	clientID := flag.String("client", "", "client to seed applicants for")
+	count := flag.Int("count", 25, "number of applicants to create")
+	randomSeed := flag.Int64("seed", 0, "random seed; the same seed makes the same records (default: the current time)")
+	reset := flag.Bool("reset", false, "remove the client's earlier seeded applicants and documents first")
+	endpoint := flag.String("endpoint", "http://localhost:9000", "MinIO endpoint to store document files in")
+	bucket := flag.String("bucket", "", "bucket to store document files in (default: aws.bucket_name)")
+	accessKey := flag.String("access-key", "minioadmin", "MinIO access key")
+	secretKey := flag.String("secret-key", "minioadmin", "MinIO secret key")
+	flag.Parse()
+
+	if *env == "prod" {
+		log.Fatalf("Refusing to seed the prod environment")
+	}
+	if *clientID == "" {
+		log.Fatalf("-client is required")
+	}
+	if *randomSeed == 0 {
+		*randomSeed = time.Now().UnixNano()
+	}
+
+	cfg := config.LoadConfig(*env)
+	if *bucket == "" {
+		*bucket = cfg.AWS.BucketName
+	}
+	if err := mongoretry.CheckURI(cfg.Database.AtlasConnectionURI); err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	if err := common.ConnectDatabase(cfg.Database); err != nil {
+		log.Fatalf("Could not connect to database: %v", err)
+	}
+
+	ctx := context.Background()
+	err := common.GetCollection(localConstants.CollectionClients).FindOne(ctx, bson.M{"client_id": *clientID}).Err()
+	if err == mongo.ErrNoDocuments {
+		log.Fatalf("Client %s does not exist; create it before seeding", *clientID)
+	}
+	if err != nil {
+		log.Fatalf("Could not look up client: %v", err)
+	}
+
+	applicants := common.GetCollection(constants.CollectionApplicants)
+	documents := common.GetCollection(localConstants.CollectionDocuments)
+	if *reset {
+		removedDocuments, err := documents.DeleteMany(ctx, seed.DocumentFilter(*clientID))
+		if err != nil {
+			log.Fatalf("Could not remove seeded documents: %v", err)
+		}
+		removedApplicants, err := applicants.DeleteMany(ctx, seed.ApplicantFilter(*clientID))
+		if err != nil {
+			log.Fatalf("Could not remove seeded applicants: %v", err)
+		}
+		log.Printf("Removed %d seeded applicants and %d documents", removedApplicants.DeletedCount, removedDocuments.DeletedCount)
+	}
+
+	store, err := seed.NewMinIOStore(ctx, *endpoint, cfg.AWS.Region, *bucket, *accessKey, *secretKey)
+	if err != nil {
+		log.Fatalf("Could not connect to MinIO: %v", err)
+	}
+	kmsUploader, err := kmsprovider.New(cfg, config.LoadSettings(*env).KMS, clientconfig.NewStore())
+	if err != nil {
+		log.Fatalf("Could not initialize KMS uploader: %v", err)
+	}
+
+	seeder := &seed.Seeder{
+		Applicants:           applicants,
+		Documents:            documents,
+		ApplicantsCollection: constants.CollectionApplicants,
+		DocumentsCollection:  localConstants.CollectionDocuments,
+		Files:                store,
+		KMSUploader:          kmsUploader,
+		Rand:                 rand.New(rand.NewSource(*randomSeed)),
+		Now:                  timestamp.Now(),
+	}
+	result, err := seeder.Run(ctx, *clientID, *count)
+	if err != nil {
+		log.Fatalf("Seeding stopped after %d applicants: %v", result.Applicants, err)
+	}
+
+	log.Printf("Seeded %d applicants and %d documents for %s (seed %d)", result.Applicants, result.Documents, *clientID, *randomSeed)
+	statuses := make([]string, 0, len(result.Statuses))
+	for status := range result.Statuses {
+		statuses = append(statuses, string(status))
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		log.Printf("  %-24s %d", status, result.Statuses[localModels.ApplicantStatus(status)])
+	}
+}
@@ -0,0 +1,214 @@
+// Package accesslog writes one structured entry per request, with the fields per-tenant
+// dashboards in Loki or Elasticsearch group and filter by: the client, the applicant or
+// document the route names, request and response sizes, whether a cache answered, and
+// the time spent waiting on each upstream dependency.
+//
+// Upstream time is added by the wrappers in opsmetrics, which see every S3 and KMS call
+// made with a request's context. MongoDB time is not broken out, as the driver is set
+// up by the core module without a command monitor; it is part of the remaining latency.
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ginKey holds the request's record among the gin context's keys
+const ginKey = "access_log"
+
+// contextKey holds the request's record in its context.Context
+type contextKey struct{}
+
+// record gathers what handlers and services report while a request is handled
+type record struct {
+	mu       sync.Mutex
+	cache    string
+	upstream map[string]*upstream
+}
+
+// upstream is the time spent on calls to one dependency
+type upstream struct {
+	calls int
+	total time.Duration
+}
+
+// Middleware logs each request once it has been handled
+func Middleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		rec := &record{}
+		c.Set(ginKey, rec)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, rec))
+		declared := c.Request.ContentLength
+		body := &countingReader{ReadCloser: c.Request.Body}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = body
+		}
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", c.Writer.Status()),
+			zap.Float64("latency_ms", millis(time.Since(started))),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int64("request_bytes", max(body.n, declared, 0)), // Declared when the handler did not read the body
+			zap.Int("response_bytes", max(c.Writer.Size(), 0)),
+		}
+		if clientID := c.GetString("client_id"); clientID != "" {
+			fields = append(fields, zap.String("client_id", clientID))
+		}
+		if adminID := c.GetString("admin_id"); adminID != "" {
+			fields = append(fields, zap.String("admin_id", adminID))
+		}
+		fields = append(fields, resourceIDs(c, route)...)
+		fields = append(fields, rec.fields()...)
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			logger.Error("Request handled", fields...)
+		case status >= http.StatusBadRequest:
+			logger.Warn("Request handled", fields...)
+		default:
+			logger.Info("Request handled", fields...)
+		}
+	}
+}
+
+// Console returns gin's console request logger without query strings, which can carry
+// tokens and cursors that must not end up in logs
+func Console() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{Formatter: func(p gin.LogFormatterParams) string {
+		path, _, _ := strings.Cut(p.Path, "?")
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"), p.StatusCode, p.Latency, p.ClientIP, p.Method, path, p.ErrorMessage)
+	}})
+}
+
+// resourceIDs names the applicant and document in the route's path parameters
+func resourceIDs(c *gin.Context, route string) []zap.Field {
+	var fields []zap.Field
+	if strings.Contains(route, "/applicants/:id") {
+		fields = append(fields, zap.String("applicant_id", c.Param("id")))
+	}
+	if documentID := c.Param("docId"); documentID != "" {
+		fields = append(fields, zap.String("document_id", documentID))
+	} else if strings.Contains(route, "/documents/:id") {
+		fields = append(fields, zap.String("document_id", c.Param("id")))
+	}
+	return fields
+}
+
+// NoteCache records whether a cache answered the request. A miss noted after a hit, e.g.
+// by a second lookup, is kept, as the request still waited on the source.
+func NoteCache(ctx context.Context, hit bool) {
+	rec := from(ctx)
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if !hit {
+		rec.cache = "miss"
+	} else if rec.cache == "" {
+		rec.cache = "hit"
+	}
+}
+
+// ObserveUpstream adds a call to a dependency, e.g. "s3" or "kms", that took d to the
+// entry of the request ctx belongs to. Calls made outside a request are ignored.
+func ObserveUpstream(ctx context.Context, dependency string, d time.Duration) {
+	rec := from(ctx)
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.upstream == nil {
+		rec.upstream = map[string]*upstream{}
+	}
+	u := rec.upstream[dependency]
+	if u == nil {
+		u = &upstream{}
+		rec.upstream[dependency] = u
+	}
+	u.calls++
+	u.total += d
+}
+
+// from finds the request's record in its context, or in a gin context or one derived
+// from it, which answer string keys from the gin context's keys
+func from(ctx context.Context) *record {
+	if ctx == nil {
+		return nil
+	}
+	if rec, ok := ctx.Value(contextKey{}).(*record); ok {
+		return rec
+	}
+	rec, _ := ctx.Value(ginKey).(*record)
+	return rec
+}
+
+// fields reports the cache outcome and upstream time, as upstream.<dependency>_ms and
+// upstream.<dependency>_calls
+func (r *record) fields() []zap.Field {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var fields []zap.Field
+	if r.cache != "" {
+		fields = append(fields, zap.String("cache", r.cache))
+	}
+	if len(r.upstream) == 0 {
+		return fields
+	}
+	dependencies := make([]string, 0, len(r.upstream))
+	for dependency := range r.upstream {
+		dependencies = append(dependencies, dependency)
+	}
+	sort.Strings(dependencies)
+	var total time.Duration
+	var upstreamFields []zap.Field
+	for _, dependency := range dependencies {
+		u := r.upstream[dependency]
+		total += u.total
+		upstreamFields = append(upstreamFields,
+			zap.Float64(dependency+"_ms", millis(u.total)),
+			zap.Int(dependency+"_calls", u.calls),
+		)
+	}
+	return append(fields, zap.Float64("upstream_ms", millis(total)), zap.Dict("upstream", upstreamFields...))
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// countingReader counts the bytes of the request body the handler read
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,95 @@
+package accesslog
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.InfoLevel)
+	router := gin.New()
+	router.Use(Middleware(zap.New(core)))
+	router.Use(func(c *gin.Context) { c.Set("client_id", "client1") })
+	router.PUT("/applicants/:id/documents/:docId", func(c *gin.Context) {
+		io.ReadAll(c.Request.Body)
+		ObserveUpstream(c.Request.Context(), "s3", 4*time.Millisecond)
+		ctx, cancel := context.WithTimeout(c, time.Second)
+		defer cancel()
+		ObserveUpstream(ctx, "s3", 6*time.Millisecond)
+		ObserveUpstream(c, "kms", 2*time.Millisecond)
+		NoteCache(c.Request.Context(), true)
+		c.String(http.StatusOK, "stored")
+	})
+	router.GET("/stats", func(c *gin.Context) {
+		NoteCache(c.Request.Context(), true)
+		NoteCache(c.Request.Context(), false)
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/applicants/applicant1/documents/doc1", strings.NewReader("0123456789"))
+	router.ServeHTTP(w, req)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	entries := logs.All()
+	require.Len(t, entries, 3)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "Request handled", entries[0].Message)
+	assert.Equal(t, "/applicants/:id/documents/:docId", fields["route"])
+	assert.Equal(t, int64(http.StatusOK), fields["status"])
+	assert.Equal(t, "client1", fields["client_id"])
+	assert.Equal(t, "applicant1", fields["applicant_id"])
+	assert.Equal(t, "doc1", fields["document_id"])
+	assert.Equal(t, int64(10), fields["request_bytes"])
+	assert.Equal(t, int64(6), fields["response_bytes"])
+	assert.Equal(t, "hit", fields["cache"])
+	assert.Equal(t, 12.0, fields["upstream_ms"])
+	assert.Equal(t, map[string]interface{}{"s3_ms": 10.0, "s3_calls": int64(2), "kms_ms": 2.0, "kms_calls": int64(1)}, fields["upstream"])
+
+	fields = entries[1].ContextMap()
+	assert.Equal(t, "miss", fields["cache"])
+	assert.NotContains(t, fields, "upstream")
+	assert.NotContains(t, fields, "applicant_id")
+
+	assert.Equal(t, zapcore.WarnLevel, entries[2].Level)
+	assert.Equal(t, "unmatched", entries[2].ContextMap()["route"])
+}
+
+func TestObserveOutsideRequest(t *testing.T) {
+	// Background work has no entry to add to
+	ObserveUpstream(context.Background(), "s3", time.Millisecond)
+	NoteCache(context.Background(), true)
+}
+
+func TestConsoleLeavesOutQueryStrings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out strings.Builder
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = &out
+	defer func() { gin.DefaultWriter = defaultWriter }()
+
+	router := gin.New()
+	router.Use(Console())
+	router.GET("/hosted/session/events", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hosted/session/events?session=vs_secret", nil))
+
+	assert.Contains(t, out.String(), `"/hosted/session/events"`)
+	assert.NotContains(t, out.String(), "vs_secret")
+}
@@ -0,0 +1,35 @@
+// Package apiversion tells shared handlers which version of the API a request was
+// routed through, so a handler can serve both /api/v1 and /api/v2 and differ only
+// where the versions do.
+//
+//	if apiversion.FromContext(c) >= apiversion.V2 { applicantID = c.Param("id") }
+package apiversion
+
+import "github.com/gin-gonic/gin"
+
+// Version is a major version of the API
+type Version int
+
+const (
+	V1 Version = 1
+	V2 Version = 2
+)
+
+const contextKey = "api_version"
+
+// Middleware marks requests in a router group as belonging to a version
+func Middleware(version Version) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextKey, version)
+		c.Next()
+	}
+}
+
+// FromContext returns the version a request was routed through. Requests outside a
+// versioned group are treated as V1.
+func FromContext(c *gin.Context) Version {
+	if value, ok := c.Get(contextKey); ok {
+		return value.(Version)
+	}
+	return V1
+}
@@ -0,0 +1,27 @@
+package apiversion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var seen []Version
+	handler := func(c *gin.Context) { seen = append(seen, FromContext(c)) }
+	router.GET("/unversioned", handler)
+	router.Group("/v1", Middleware(V1)).GET("/thing", handler)
+	router.Group("/v2", Middleware(V2)).GET("/thing", handler)
+
+	for _, path := range []string{"/unversioned", "/v1/thing", "/v2/thing"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, []Version{V1, V1, V2}, seen)
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
 	"github.com/rachel-lawrie/verus_backend_core/models"
 )
 
@@ -13,19 +15,32 @@ var (
 )
 
 type Params struct {
-	Router *gin.Engine
-	Config *models.Config
+	Router       *gin.Engine
+	Config       *models.Config
+	Settings     *config.Settings
+	Dependencies Dependencies
+}
+
+// Dependencies replaces the AWS clients the routes would otherwise build from the
+// config, e.g. with local stand-ins in integration tests. Nil fields are built as usual.
+type Dependencies struct {
+	Uploader    interfaces.Uploader
+	KMSUploader interfaces.KMSUploader
 }
 
 type controller struct {
-	router *gin.Engine
-	cfg    *models.Config
+	router   *gin.Engine
+	cfg      *models.Config
+	settings *config.Settings
+	deps     Dependencies
 }
 
 func New(p Params) Controller {
 	ctrl := &controller{
-		router: p.Router,
-		cfg:    p.Config,
+		router:   p.Router,
+		cfg:      p.Config,
+		settings: p.Settings,
+		deps:     p.Dependencies,
 	}
 	return ctrl
 }
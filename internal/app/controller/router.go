@@ -1,22 +1,109 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
 	applicationControllers "github.com/rachel-lawrie/verus_app_backend/internal/applicant/controllers"
 	applicantServices "github.com/rachel-lawrie/verus_app_backend/internal/applicant/services"
+	attachmentControllers "github.com/rachel-lawrie/verus_app_backend/internal/attachment/controllers"
+	attachmentServices "github.com/rachel-lawrie/verus_app_backend/internal/attachment/services"
+	auditControllers "github.com/rachel-lawrie/verus_app_backend/internal/audit/controllers"
+	auditServices "github.com/rachel-lawrie/verus_app_backend/internal/audit/services"
 	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/authguard"
+	"github.com/rachel-lawrie/verus_app_backend/internal/awsreplay"
+	backupControllers "github.com/rachel-lawrie/verus_app_backend/internal/backup/controllers"
+	backupServices "github.com/rachel-lawrie/verus_app_backend/internal/backup/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/changelog"
+	changelogControllers "github.com/rachel-lawrie/verus_app_backend/internal/changelog/controllers"
+	clientControllers "github.com/rachel-lawrie/verus_app_backend/internal/client/controllers"
+	clientServices "github.com/rachel-lawrie/verus_app_backend/internal/client/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/compression"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	decisionControllers "github.com/rachel-lawrie/verus_app_backend/internal/decision/controllers"
+	decisionServices "github.com/rachel-lawrie/verus_app_backend/internal/decision/services"
 	documentControllers "github.com/rachel-lawrie/verus_app_backend/internal/document/controllers"
 	documentServices "github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/egress"
+	encryptionKeyControllers "github.com/rachel-lawrie/verus_app_backend/internal/encryptionkey/controllers"
+	encryptionKeyServices "github.com/rachel-lawrie/verus_app_backend/internal/encryptionkey/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/faults"
+	"github.com/rachel-lawrie/verus_app_backend/internal/geoip"
+	"github.com/rachel-lawrie/verus_app_backend/internal/i18n"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	jobControllers "github.com/rachel-lawrie/verus_app_backend/internal/jobs/controllers"
+	jobServices "github.com/rachel-lawrie/verus_app_backend/internal/jobs/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/jsonlimit"
+	"github.com/rachel-lawrie/verus_app_backend/internal/keyring"
+	"github.com/rachel-lawrie/verus_app_backend/internal/kmsprovider"
+	"github.com/rachel-lawrie/verus_app_backend/internal/levels"
+	levelControllers "github.com/rachel-lawrie/verus_app_backend/internal/levels/controllers"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	noteControllers "github.com/rachel-lawrie/verus_app_backend/internal/note/controllers"
+	noteServices "github.com/rachel-lawrie/verus_app_backend/internal/note/services"
+	operationsControllers "github.com/rachel-lawrie/verus_app_backend/internal/operations/controllers"
+	operationsServices "github.com/rachel-lawrie/verus_app_backend/internal/operations/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/opsmetrics"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pii"
+	"github.com/rachel-lawrie/verus_app_backend/internal/preview"
+	"github.com/rachel-lawrie/verus_app_backend/internal/quarantine"
+	"github.com/rachel-lawrie/verus_app_backend/internal/redisclient"
+	rekeyControllers "github.com/rachel-lawrie/verus_app_backend/internal/rekey/controllers"
+	rekeyServices "github.com/rachel-lawrie/verus_app_backend/internal/rekey/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/replicareads"
+	reportControllers "github.com/rachel-lawrie/verus_app_backend/internal/report/controllers"
+	reportServices "github.com/rachel-lawrie/verus_app_backend/internal/report/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/requestmeta"
+	reviewControllers "github.com/rachel-lawrie/verus_app_backend/internal/review/controllers"
+	reviewServices "github.com/rachel-lawrie/verus_app_backend/internal/review/services"
+	riskServices "github.com/rachel-lawrie/verus_app_backend/internal/risk/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/s3upload"
+	sessionControllers "github.com/rachel-lawrie/verus_app_backend/internal/session/controllers"
+	sessionEvents "github.com/rachel-lawrie/verus_app_backend/internal/session/events"
+	sessionServices "github.com/rachel-lawrie/verus_app_backend/internal/session/services"
+	signingControllers "github.com/rachel-lawrie/verus_app_backend/internal/signing/controllers"
+	signingServices "github.com/rachel-lawrie/verus_app_backend/internal/signing/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/slo"
+	"github.com/rachel-lawrie/verus_app_backend/internal/startup"
+	statsControllers "github.com/rachel-lawrie/verus_app_backend/internal/stats/controllers"
+	statsServices "github.com/rachel-lawrie/verus_app_backend/internal/stats/services"
+	sumsubControllers "github.com/rachel-lawrie/verus_app_backend/internal/sumsub/controllers"
+	sumsubServices "github.com/rachel-lawrie/verus_app_backend/internal/sumsub/services"
+	tokenControllers "github.com/rachel-lawrie/verus_app_backend/internal/token/controllers"
+	tokenServices "github.com/rachel-lawrie/verus_app_backend/internal/token/services"
+	usageControllers "github.com/rachel-lawrie/verus_app_backend/internal/usage/controllers"
+	usageServices "github.com/rachel-lawrie/verus_app_backend/internal/usage/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/vendor"
+	webhookControllers "github.com/rachel-lawrie/verus_app_backend/internal/webhook/controllers"
+	webhookServices "github.com/rachel-lawrie/verus_app_backend/internal/webhook/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/worker"
 	"github.com/rachel-lawrie/verus_backend_core/auth"
 	"github.com/rachel-lawrie/verus_backend_core/common"
 	"github.com/rachel-lawrie/verus_backend_core/models"
 	"github.com/rachel-lawrie/verus_backend_core/utils"
 	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
 func (c *controller) InitializeRoutes() {
 	r := c.router
+	r.Use(changelog.DeprecationHeaders(changelog.DeprecatedRoutes))
+	r.Use(requestmeta.Capture())
+	r.Use(opsmetrics.Middleware())
+	r.Use(replicareads.Middleware())
+
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "The server is up and working :-)",
@@ -28,54 +115,499 @@ func (c *controller) InitializeRoutes() {
 
 	r.Static("/docs", "./docs")
 
-	ApiRouting(r, c.cfg)
+	r.GET("/changelog", changelogControllers.GetChangelog)
+
+	// The addresses webhooks and provider calls leave from, for clients to allowlist
+	r.GET(egress.Path, egress.Handler)
+
+	ApiRouting(r, c.cfg, c.settings, c.deps)
 }
 
-func ApiRouting(r *gin.Engine, cfg *models.Config) {
+func ApiRouting(r *gin.Engine, cfg *models.Config, settings *config.Settings, deps Dependencies) {
 
 	logger := zaplogger.GetLogger()
+	if settings == nil {
+		settings = &config.Settings{}
+	}
+
+	mongoretry.SetPolicy(mongoretry.Policy{
+		MaxAttempts: settings.Mongo.WriteAttempts,
+		BaseDelay:   settings.Mongo.RetryBaseDelay,
+		MaxDelay:    settings.Mongo.RetryMaxDelay,
+		RetryAfter:  settings.Mongo.RetryAfter,
+	})
+
+	// Send webhooks and provider calls through the egress proxy when configured
+	if err := egress.Configure(settings.Egress); err != nil {
+		logger.Fatal("Invalid egress settings", zap.Error(err))
+	}
+
+	// Let the configured list endpoints and exports read from secondaries
+	if err := replicareads.Configure(settings.Mongo.SecondaryReads); err != nil {
+		logger.Fatal("Invalid secondary read settings", zap.Error(err))
+	}
+
+	// Record or replay AWS calls when configured, e.g. for offline CI runs
+	replayMode, err := awsreplay.ParseMode(settings.AWSReplay.Mode)
+	if err != nil {
+		logger.Fatal("Invalid AWS replay settings", zap.Error(err))
+	}
+	cassette, err := awsreplay.Open(replayMode, settings.AWSReplay.Cassette)
+	if err != nil {
+		logger.Fatal("Failed to open AWS replay cassette", zap.Error(err))
+	}
+
+	// Per-client settings and feature flags
+	clientconfig.SetDefaults(settings.Features)
+	clientStore := clientconfig.NewStore()
+
+	kmsUploader := deps.KMSUploader
+	var keys *keyring.Keyring
+	if kmsUploader == nil && replayMode != awsreplay.ModeReplay {
+		kmsUploader, err = kmsprovider.New(*cfg, settings.KMS, clientStore)
+		if err != nil {
+			logger.Fatal("Failed to initialize KMS uploader",
+				zap.Error(err),
+			)
+		}
+		// With AWS KMS, clients with their own key get data keys made under it
+		keys, _ = kmsUploader.(*keyring.Keyring)
+	}
+	kmsUploader = opsmetrics.KMSUploader(cassette.KMSUploader(kmsUploader))
+
+	// Initialize S3 uploader. Files are sealed into pooled buffers and large ones are sent
+	// as multipart uploads.
+	uploader := deps.Uploader
+	if uploader == nil && replayMode != awsreplay.ModeReplay {
+		s3Client, err := startup.NewS3Client(context.Background(), *cfg)
+		if err != nil {
+			logger.Fatal("Failed to initialize S3 uploader",
+				zap.Error(err),
+			)
+		}
+		uploader = s3upload.New(s3Client, cfg.AWS.BucketName)
+	}
+	uploader = opsmetrics.Uploader(cassette.Uploader(uploader))
+
+	// Keep new uploads in quarantine until their record is saved, and write the documents
+	// bucket's lifecycle rules. Neither is possible when AWS calls are replayed.
+	var quarantineStore *quarantine.Store
+	var directUploads *documentServices.DirectUploads
+	if replayMode != awsreplay.ModeReplay && (settings.Uploads.Quarantine.Enabled || settings.Uploads.Lifecycle.Manage) {
+		rules, err := quarantine.Rules(settings.Uploads.Quarantine, settings.Uploads.Lifecycle)
+		if err != nil {
+			logger.Fatal("Invalid upload lifecycle settings", zap.Error(err))
+		}
+		s3Client, err := startup.NewS3Client(context.Background(), *cfg)
+		if err != nil {
+			logger.Fatal("Failed to initialize S3 client", zap.Error(err))
+		}
+		quarantineStore = quarantine.New(settings.Uploads.Quarantine, cfg.AWS.BucketName, s3Client)
+		if settings.Uploads.Lifecycle.Manage {
+			if err := quarantine.ApplyLifecycle(context.Background(), s3Client, cfg.AWS.BucketName, rules); err != nil {
+				logger.Error("Failed to apply lifecycle rules to the documents bucket", zap.Error(err))
+			}
+		} else if quarantineStore != nil {
+			logger.Warn("Upload quarantine is on without managed lifecycle rules; quarantined files only expire if the bucket has its own rule")
+		}
+		// Files uploaded through presigned URLs wait in quarantine until they are checked
+		if quarantineStore != nil {
+			directUploads = documentServices.NewDirectUploads(settings.Uploads.Direct, cfg.AWS.BucketName, cfg.AWS.Region, cfg.AWS.KeyID, quarantineStore, s3.NewPresignClient(s3Client), s3Client)
+		}
+	}
+	if directUploads == nil && settings.Uploads.Direct.SigningSecret != "" {
+		logger.Warn("Direct uploads need uploads.quarantine.enabled and are disabled")
+	}
+
+	// Sandbox clients that opt in get delayed and failed requests and dropped webhooks
+	injector, err := faults.New(settings.FaultInjection, settings.Env, clientStore)
+	if err != nil {
+		logger.Fatal("Invalid fault injection settings", zap.Error(err))
+	}
+	injectFaults := injector.Middleware()
+
+	// Initialize webhook service, delivering queued events in the background
+	webhookService := webhookServices.GetWebhookServiceImpl()
+	webhookService.ClientConfig = clientStore
+	if injector != nil {
+		webhookService.Faults = injector
+	}
+	if settings.Webhooks.Timeout > 0 {
+		webhookService.HTTPClient = egress.Client(settings.Webhooks.Timeout)
+	}
+	if settings.Webhooks.MaxAttempts > 0 {
+		webhookService.MaxAttempts = settings.Webhooks.MaxAttempts
+	}
+	webhookService.FailureAlert = webhookServices.FailureAlertPolicy{
+		Rate:      settings.Webhooks.FailureAlertRate,
+		Window:    settings.Webhooks.FailureAlertWindow,
+		MinEvents: int64(settings.Webhooks.FailureAlertMinEvents),
+	}
+	if settings.Webhooks.DeliveryInterval > 0 {
+		go worker.Every(context.Background(), "webhook_delivery", settings.Webhooks.DeliveryInterval, webhookService.DeliverPending)
+	}
+
+	// Background work run on cron schedules, each run by one replica at a time
+	scheduler := jobServices.NewScheduler(settings.Jobs)
+
+	// Meter billable events, exporting them to the billing system when one is configured
+	usageService := usageServices.GetUsageServiceImpl()
+	usageService.BillingURL = settings.Metering.BillingURL
+	if usageService.BillingURL != "" {
+		registerJob(scheduler, "usage_export", usageService.ExportPending)
+	}
+
+	// Initialize applicant service
+	applicantService := applicantServices.GetApplicantServiceImpl()
+	var locator geoip.Locator
+	if settings.GeoIP.Database != "" {
+		table, err := geoip.LoadCSV(settings.GeoIP.Database)
+		if err != nil {
+			logger.Fatal("Failed to load GeoIP database", zap.Error(err))
+		}
+		locator = table
+		applicantService.Geolocator = locator
+	}
+	applicantService.Usage = &usageService
+	applicantService.KMSUploader = kmsUploader
+	applicantService.RestoreGracePeriod = settings.Deletion.RestoreGracePeriod
+
+	// Replicas share state through Redis when it is configured
+	var redisClient *redis.Client
+	if settings.Sessions.RedisURL != "" {
+		redisClient, err = redisclient.New(settings.Sessions.RedisURL)
+		if err != nil {
+			logger.Fatal("Invalid Redis settings", zap.Error(err))
+		}
+	}
+
+	// Hosted pages follow their session's progress as it happens, including the upload steps
+	// recorded below. With Redis, events reach pages connected to any replica.
+	eventBus := sessionEvents.NewBus()
+	if redisClient != nil {
+		eventBus = sessionEvents.NewRedisBus(redisClient, settings.Sessions.EventsChannel)
+		go worker.Every(context.Background(), "session_events", sessionEvents.ReconnectInterval, eventBus.Listen)
+	}
+
+	// Staff actions and downloads of document files are kept in a hash-chained audit log
+	auditService := auditServices.GetAuditServiceImpl()
+	documentService := documentServices.GetDocumentServiceImpl()
+	documentService.Uploader = uploader
+	documentService.KMSUploader = kmsUploader
+	riskService := riskServices.GetRiskServiceImpl()
+	documentService.RiskService = &riskService
+	documentService.StagingDir = settings.Uploads.StagingDir
+	documentService.Usage = &usageService
+	documentService.Clients = clientStore
+	var uploadJobs localInterfaces.UploadJobStore = documentServices.NewMongoUploadJobStore()
+	if redisClient != nil {
+		uploadJobs = documentServices.NewRedisUploadJobStore(redisClient)
+	}
+	documentService.Jobs = sessionEvents.NewProgressStore(uploadJobs, eventBus)
+	documentService.Quarantine = quarantineStore
+	documentService.Direct = directUploads
+	documentService.Limits = documentServices.NewUploadLimits(settings.Uploads.Limits)
+	documentService.Previews = settings.Previews
+	documentService.ImageQuality = settings.Uploads.Quality
+	documentService.RestoreGracePeriod = settings.Deletion.RestoreGracePeriod
+	documentService.Cache = documentServices.NewDocumentCache(time.Duration(cfg.Database.CacheExpirationMins)*time.Minute, time.Duration(cfg.Database.CacheCleanupIntervalMins)*time.Minute)
+	vendorHealth := vendor.NewMonitor(settings.Vendors)
+	if settings.Vendors.Default != "" || len(settings.Vendors.Providers) > 0 {
+		registry, err := vendor.NewRegistry(settings.Vendors)
+		if err != nil {
+			logger.Fatal("Invalid vendor selection settings", zap.Error(err))
+		}
+		// Clients with fallbacks move new submissions off vendors whose health checks fail
+		if vendorHealth != nil {
+			registry.Health = vendorHealth
+			go worker.Every(context.Background(), "vendor_health", vendorHealth.Interval, vendorHealth.Check)
+		}
+		documentService.Vendors = registry
+	}
 
-	kmsUploader, err := utils.NewKMSUploader(cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, cfg.AWS.KeyID)
+	// The verification levels applicants can be created at, narrowed to the countries of each client's vendor
+	levelCatalog, err := levels.New(*settings)
 	if err != nil {
-		logger.Fatal("Failed to initialize KMS uploader",
-			zap.Error(err),
-		)
+		logger.Fatal("Invalid verification level settings", zap.Error(err))
+	}
+	if registry, ok := documentService.Vendors.(*vendor.Registry); ok {
+		levelCatalog.Vendors = registry
+	}
+	levels.SetCatalog(levelCatalog)
+
+	// Error budgets of the SLOs, fed by the request and provider figures of this instance
+	sloTracker, err := slo.NewTracker(settings.SLOs)
+	if err != nil {
+		logger.Fatal("Invalid SLO settings", zap.Error(err))
+	}
+	if sloTracker != nil {
+		opsmetrics.Requests.Subscribe(sloTracker.Observe)
+		opsmetrics.Providers.Subscribe(sloTracker.Observe)
+		go worker.Every(context.Background(), "slo_check", sloTracker.Interval, sloTracker.Check)
+	}
+
+	// Retry uploads that did not reach S3, or tell the client to re-upload
+	reconciler := documentServices.NewUploadReconciler(uploader, kmsUploader, &webhookService, settings.Uploads.ReconcileGracePeriod, settings.Uploads.MaxAttempts)
+	reconciler.Jobs = documentService.Jobs
+	reconciler.Applicants = &applicantService
+	reconciler.Quarantine = quarantineStore
+	registerJob(scheduler, "upload_reconciliation", reconciler.Reconcile)
+
+	// Scan directly uploaded files as soon as they are completed, and remove those never completed
+	if directUploads != nil {
+		directUploads.Webhooks = &webhookService
+		directUploads.Jobs = documentService.Jobs
+		directUploads.Applicants = &applicantService
+		directUploads.Trigger = func(ctx context.Context) {
+			if _, err := scheduler.Trigger(ctx, "direct_upload_scan", "upload"); err != nil && !errors.Is(err, jobServices.ErrJobRunning) {
+				logger.Warn("Failed to start scan of direct upload", zap.Error(err))
+			}
+		}
+		registerJob(scheduler, "direct_upload_scan", directUploads.Scan)
+		registerJob(scheduler, "direct_upload_cleanup", directUploads.Cleanup)
+	}
+
+	// Re-encrypt applicants' data under the current KMS key when their client asks. The data
+	// keys of files are rewritten in S3, which is not possible when AWS calls are replayed.
+	rekeyService := rekeyServices.GetRekeyServiceImpl()
+	rekeyService.KMSUploader = kmsUploader
+	rekeyService.Bucket = cfg.AWS.BucketName
+
+	// Move old documents to archival storage, and restore them when their client asks.
+	// Restores stay available after afterDays is set back to zero, for documents already archived.
+	if replayMode != awsreplay.ModeReplay {
+		s3Client, err := startup.NewS3Client(context.Background(), *cfg)
+		if err != nil {
+			logger.Fatal("Failed to initialize S3 client", zap.Error(err))
+		}
+		coldStorage, err := documentServices.NewColdStorage(settings.ColdStorage, cfg.AWS.BucketName, s3Client, &webhookService)
+		if err != nil {
+			logger.Fatal("Invalid cold storage settings", zap.Error(err))
+		}
+		rekeyService.Files = s3Client
+		coldStorage.Applicants = &applicantService
+		documentService.ColdStorage = coldStorage
+		if coldStorage.AfterDays > 0 {
+			registerJob(scheduler, "document_cold_storage", coldStorage.Archive)
+		}
+		registerJob(scheduler, "document_restore_check", coldStorage.CheckRestores)
+	}
+
+	// Clients bring their own KMS keys, checked with a test encrypt and decrypt, and their
+	// applicants are moved to a key in the background once it is activated
+	encryptionKeyService := encryptionKeyServices.GetEncryptionKeyServiceImpl()
+	if keys != nil {
+		encryptionKeyService.Checker = keys
+	}
+	encryptionKeyService.Rekey = &rekeyService
+	encryptionKeyService.Trigger = func(ctx context.Context) {
+		if _, err := scheduler.Trigger(ctx, "encryption_key_migration", "activation"); err != nil && !errors.Is(err, jobServices.ErrJobRunning) {
+			logger.Warn("Failed to start migrating to client encryption key", zap.Error(err))
+		}
+	}
+	registerJob(scheduler, "encryption_key_migration", encryptionKeyService.MigratePending)
+
+	// Pull applicants' review state back from Sumsub when a Sumsub app is configured
+	sumsubSyncService := sumsubServices.GetSumsubSyncServiceImpl()
+	if settings.Sumsub.AppToken != "" {
+		sumsubSyncService.Client = sumsubServices.NewSumsubClient(settings.Sumsub)
+		sumsubSyncService.Webhooks = &webhookService
+		sumsubSyncService.Applicants = &applicantService
+		if settings.Sumsub.StaleAfter > 0 {
+			sumsubSyncService.StaleAfter = settings.Sumsub.StaleAfter
+		}
+		if settings.Sumsub.BatchSize > 0 {
+			sumsubSyncService.BatchSize = settings.Sumsub.BatchSize
+		}
+		registerJob(scheduler, "sumsub_sync", sumsubSyncService.SyncStale)
+	}
+
+	// Encrypted client snapshots for disaster recovery, written to a bucket of their own
+	backupService := backupServices.GetBackupServiceImpl()
+	if settings.Backups.Bucket != "" && replayMode != awsreplay.ModeReplay {
+		backupService.Uploader, err = utils.NewS3Uploader(settings.Backups.Bucket, cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey)
+		if err != nil {
+			logger.Fatal("Failed to initialize backup uploader", zap.Error(err))
+		}
+		backupService.KMSUploader = kmsUploader
+		registerJob(scheduler, "client_backup", backupService.SnapshotAll)
+	}
+
+	// Verification reports too large to draw during the request are generated in the background
+	reportService := reportServices.GetReportServiceImpl()
+	reportService.Settings = settings.Reports
+	reportService.Documents = &documentService
+	reportService.Trigger = func(ctx context.Context) {
+		if _, err := scheduler.Trigger(ctx, "applicant_report", "request"); err != nil && !errors.Is(err, jobServices.ErrJobRunning) {
+			logger.Warn("Failed to start generating applicant report", zap.Error(err))
+		}
+	}
+	registerJob(scheduler, "applicant_report", reportService.GeneratePending)
+
+	// Compliance reports cover whole periods of a client's records, so they are always
+	// generated in the background and the client is told when each is ready
+	complianceService := reportServices.GetComplianceServiceImpl()
+	complianceService.Settings = settings.Reports.Compliance
+	complianceService.BatchSize = settings.Reports.BatchSize
+	complianceService.Webhooks = &webhookService
+	complianceService.Trigger = func(ctx context.Context) {
+		if _, err := scheduler.Trigger(ctx, "compliance_report", "request"); err != nil && !errors.Is(err, jobServices.ErrJobRunning) {
+			logger.Warn("Failed to start generating compliance report", zap.Error(err))
+		}
+	}
+	registerJob(scheduler, "compliance_report", complianceService.GeneratePending)
+
+	// Without schedules, jobs only run when triggered from the admin API
+	if len(settings.Jobs.Schedules) > 0 {
+		go worker.Every(context.Background(), "job_scheduler", scheduler.PollInterval, scheduler.RunDue)
 	}
 
+	// Supporting files such as correspondence, kept apart from verification documents
+	attachmentService := attachmentServices.GetAttachmentServiceImpl()
+	attachmentService.Uploader = uploader
+	attachmentService.KMSUploader = kmsUploader
+
+	noteService := noteServices.GetNoteServiceImpl()
+
+	// Aggregate counts only, so no applicant can be identified from them
+	statsService := statsServices.GetStatsServiceImpl()
+
+	clientService := clientServices.GetClientServiceImpl()
+	clientService.Webhooks = &webhookService
+
+	secrets := common.GetCollection(localConstants.CollectionClientSecrets)
+
+	// Clients needing to verify response integrity have each response body signed
+	signingService := signingServices.GetSigningServiceImpl()
+	signed := signingControllers.SignResponses(&signingService)
+	// Clients whose security reviews require it sign their requests, each accepted once
+	var nonces signingServices.NonceStore = signingServices.NewMongoNonceStore()
+	if redisClient != nil {
+		nonces = signingServices.NewRedisNonceStore(redisClient)
+	}
+	verified := signingControllers.VerifyRequests(&signingService, nonces, settings.RequestSigning.MaxClockSkew, settings.RequestSigning.MaxBodyBytes)
+
+	// Dashboard sessions use short-lived JWTs issued here, accepted wherever JWTs are
+	tokenService := tokenServices.GetTokenServiceImpl()
+	tokenService.SigningSecret = []byte(settings.Tokens.SigningSecret)
+	if settings.Tokens.AccessTTL > 0 {
+		tokenService.AccessTTL = settings.Tokens.AccessTTL
+	}
+	if settings.Tokens.RefreshTTL > 0 {
+		tokenService.RefreshTTL = settings.Tokens.RefreshTTL
+	}
+	if redisClient != nil {
+		tokenService.Revocations = tokenServices.NewRedisRevocationList(redisClient)
+	}
+	issuesTokens := settings.Tokens.SigningSecret != ""
+	combinedAuth := auth.CombinedAuthMiddleware(secrets)
+	if issuesTokens {
+		combinedAuth = tokenControllers.Authenticate(&tokenService, combinedAuth)
+	}
+
+	// List endpoints page with signed cursors, which a client cannot edit to reach other lists
+	if settings.Pagination.CursorSecret != "" {
+		pagination.SetSecret([]byte(settings.Pagination.CursorSecret))
+	} else {
+		logger.Warn("No pagination.cursorSecret set; cursors only work on the instance that issued them")
+	}
+
+	// Applicants can upload their own documents on a hosted page, through signed links
+	// their client creates for them
+	sessionService := sessionServices.GetSessionServiceImpl()
+	sessionService.SigningSecret = []byte(settings.Sessions.SigningSecret)
+	sessionService.HostedURL = settings.Sessions.HostedURL
+	sessionService.Events = eventBus
+	if settings.Sessions.TTL > 0 {
+		sessionService.TTL = settings.Sessions.TTL
+	}
+	if settings.Sessions.MaxTTL > 0 {
+		sessionService.MaxTTL = settings.Sessions.MaxTTL
+	}
+	hostsSessions := settings.Sessions.SigningSecret != ""
+
+	// Support staff may call client routes as a client to reproduce its issues, giving a
+	// reason that is audited with every such request
+	admins := common.GetCollection(localConstants.CollectionAdminUsers)
+	asClient := func(auth gin.HandlerFunc) gin.HandlerFunc {
+		return middleware.Impersonate(admins, secrets, auth)
+	}
+	impersonations := auditControllers.RecordImpersonations(&auditService)
+
+	// Large tenants' list responses run to megabytes, so they are gzipped for clients that accept it
+	gzipped := compression.Responses(settings.Compression.MinResponseBytes)
+
+	// Applicant bodies are small, so oversized, deeply nested or sprawling ones are refused
+	// before they are decoded
+	limitedJSON := jsonlimit.Middleware(jsonlimit.Limits{
+		MaxBytes:  settings.Payloads.MaxBytes,
+		MaxDepth:  settings.Payloads.MaxDepth,
+		MaxFields: settings.Payloads.MaxFields,
+	})
+
+	// Repeated authentication failures are slowed down and then refused, and clients are
+	// alerted when one of their keys is used from a new country or at an unusual rate
+	guard := authguard.New(settings.AuthGuard, locator, authguard.NewMongoKeyCountries(), &webhookService)
+
+	// Error messages are translated into the language asked for with Accept-Language. Groups
+	// signing their responses translate again after signing is set up, so the signature
+	// covers the translated body.
+	localized := i18n.Middleware()
+
 	vehicles := r.Group("/api")
+	vehicles.Use(localized)
+	vehicles.Use(guard.Middleware())
+	vehicles.Use(compression.Requests(settings.Compression.MaxRequestBytes))
+	// IDs in paths that cannot name the record they stand for, such as a document ID where
+	// an applicant's belongs, are refused before any lookup
+	vehicles.Use(ids.Middleware())
 	v1 := vehicles.Group("/v1")
+	// Field-level encryption of personal data, sharing decrypted data keys within a request
+	v1.Use(pii.Middleware(kmsUploader))
+	v1.Use(apiversion.Middleware(apiversion.V1))
+
+	if issuesTokens {
+		tokens := v1.Group("/auth")
+		tokens.POST("/token", func(c *gin.Context) {
+			tokenControllers.IssueToken(c, &tokenService)
+		})
+		tokens.POST("/revoke", func(c *gin.Context) {
+			tokenControllers.RevokeToken(c, &tokenService)
+		})
+	}
 
 	// Group for routes that require API key authentication
 	protected := v1.Group("/protected")
-	protected.Use(middleware.APIKeyAuthMiddleware(common.GetCollection("client_secrets_table")))
+	protected.Use(changelog.Deprecate(changelog.V1Deprecation))
+	protected.Use(asClient(middleware.APIKeyAuthMiddleware(secrets)))
+	protected.Use(impersonations)
+	protected.Use(clientconfig.Middleware(clientStore))
+	protected.Use(clientconfig.RequireAllowedIP())
+	protected.Use(injectFaults)
+	protected.Use(verified)
+	protected.Use(signed)
+	protected.Use(localized)
 	{
-
-		// Initialize applicant service
-		applicantService := applicantServices.GetApplicantServiceImpl()
-		protected.POST("/applicants", func(c *gin.Context) {
+		protected.POST("/applicants", limitedJSON, func(c *gin.Context) {
 			applicationControllers.CreateApplicant(c, &applicantService, kmsUploader)
 		})
 
-		protected.PUT("/applicants/:id", func(c *gin.Context) {
+		protected.PUT("/applicants/:id", limitedJSON, func(c *gin.Context) {
 			applicationControllers.UpdateApplicant(c, &applicantService)
 		})
 
-		// Initialize S3 uploader
-		uploader, err := utils.NewS3Uploader(cfg.AWS.BucketName, cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey)
-		if err != nil {
-			logger.Fatal("Failed to initialize S3 uploader",
-				zap.Error(err),
-			)
-		}
-
-		documentService := documentServices.GetDocumentServiceImpl()
-		documentService.Uploader = uploader
-		documentService.KMSUploader = kmsUploader
-
 		protected.POST("/documents", func(c *gin.Context) {
 			documentControllers.CreateDocument(c, &documentService)
 		})
 
+		protected.POST("/documents/presign-upload", func(c *gin.Context) {
+			documentControllers.PresignUpload(c, &documentService)
+		})
+
+		protected.POST("/documents/complete", func(c *gin.Context) {
+			documentControllers.CompleteUpload(c, &documentService)
+		})
+
 		protected.GET("/documents/:id", func(c *gin.Context) {
 			documentControllers.GetDocument(c, &documentService)
 		})
@@ -87,21 +619,632 @@ func ApiRouting(r *gin.Engine, cfg *models.Config) {
 		protected.PUT("/documents/:id", func(c *gin.Context) {
 			documentControllers.UpdateDocument(c, &documentService)
 		})
+
+		protected.POST("/documents/:id/replace", func(c *gin.Context) {
+			documentControllers.ReplaceDocument(c, &documentService)
+		})
+
+		protected.GET("/documents/:id/versions", func(c *gin.Context) {
+			documentControllers.GetDocumentVersions(c, &documentService)
+		})
+
+		protected.POST("/documents/:id/restore", func(c *gin.Context) {
+			documentControllers.RestoreDocument(c, &documentService)
+		})
+
+		protected.GET("/documents/:id/restore", func(c *gin.Context) {
+			documentControllers.GetDocumentRestore(c, &documentService)
+		})
+
+		protected.GET("/documents/jobs/:job_id", func(c *gin.Context) {
+			documentControllers.GetUploadJob(c, &documentService)
+		})
+
+		protected.POST("/applicants/:id/attachments", func(c *gin.Context) {
+			attachmentControllers.AddAttachment(c, &attachmentService)
+		})
+
+		protected.GET("/applicants/:id/attachments", gzipped, func(c *gin.Context) {
+			attachmentControllers.ListAttachments(c, &attachmentService)
+		})
+
+		protected.GET("/applicants/:id/attachments/:attachmentId", func(c *gin.Context) {
+			attachmentControllers.DownloadAttachment(c, &attachmentService)
+		})
+
+		protected.POST("/applicants/:id/notes", func(c *gin.Context) {
+			noteControllers.AddNote(c, &noteService)
+		})
+
+		protected.GET("/applicants/:id/notes", gzipped, func(c *gin.Context) {
+			noteControllers.ListNotes(c, &noteService)
+		})
+
+		protected.GET("/webhook-endpoint", func(c *gin.Context) {
+			webhookControllers.GetWebhookEndpoint(c, &webhookService)
+		})
+
+		protected.PUT("/webhook-endpoint", func(c *gin.Context) {
+			webhookControllers.SetWebhookEndpoint(c, &webhookService)
+		})
 	}
 
 	// Group for routes that require JWT or API key authentication
 	protected2 := v1.Group("/protected2")
-	protected2.Use(auth.CombinedAuthMiddleware(common.GetCollection("client_secrets_table")))
+	protected2.Use(changelog.Deprecate(changelog.V1Deprecation))
+	protected2.Use(asClient(combinedAuth))
+	protected2.Use(impersonations)
+	protected2.Use(clientconfig.Middleware(clientStore))
+	protected2.Use(clientconfig.RequireAllowedIP())
+	protected2.Use(injectFaults)
+	protected2.Use(verified)
+	protected2.Use(signed)
+	protected2.Use(localized)
 	{
-		// Initialize applicant service
-		applicantService := applicantServices.GetApplicantServiceImpl()
-
-		protected2.GET("/applicants", func(c *gin.Context) {
+		protected2.GET("/applicants", gzipped, func(c *gin.Context) {
 			applicationControllers.GetAllApplicants(c, &applicantService)
 		})
 
 		protected2.GET("/applicants/:id", func(c *gin.Context) {
 			applicationControllers.GetApplicant(c, &applicantService)
 		})
+
+		protected2.GET("/stats", func(c *gin.Context) {
+			statsControllers.GetStats(c, &statsService)
+		})
+
+		protected2.GET("/levels", func(c *gin.Context) {
+			levelControllers.ListLevels(c, levelCatalog)
+		})
+
+		protected2.GET("/webhooks/failures", func(c *gin.Context) {
+			webhookControllers.ListWebhookFailures(c, &webhookService)
+		})
+
+		protected2.POST("/webhooks/failures/:id/redeliver", func(c *gin.Context) {
+			webhookControllers.RedeliverWebhook(c, &webhookService)
+		})
+
+		protected2.POST("/reports", func(c *gin.Context) {
+			reportControllers.RequestComplianceReport(c, &complianceService)
+		})
+
+		protected2.GET("/reports/:id", func(c *gin.Context) {
+			reportControllers.GetComplianceReport(c, &complianceService)
+		})
+	}
+
+	// Group for trying an integration without creating real applicants
+	sandbox := v1.Group("/sandbox")
+	sandbox.Use(changelog.Deprecate(changelog.V1Deprecation))
+	sandbox.Use(asClient(combinedAuth))
+	sandbox.Use(impersonations)
+	sandbox.Use(clientconfig.Middleware(clientStore))
+	sandbox.Use(clientconfig.RequireAllowedIP())
+	sandbox.Use(injectFaults)
+	sandbox.Use(verified)
+	sandbox.Use(signed)
+	sandbox.Use(localized)
+	{
+		sandbox.POST("/webhooks/test", func(c *gin.Context) {
+			webhookControllers.TestWebhook(c, &webhookService)
+		})
+	}
+
+	// Version 2 nests resources under the applicant they belong to, with authentication
+	// chosen per route rather than by path prefix. Handlers are shared with v1.
+	v2 := vehicles.Group("/v2")
+	v2.Use(pii.Middleware(kmsUploader))
+	v2.Use(apiversion.Middleware(apiversion.V2))
+
+	if issuesTokens {
+		tokens := v2.Group("/auth")
+		tokens.POST("/token", func(c *gin.Context) {
+			tokenControllers.IssueToken(c, &tokenService)
+		})
+		tokens.POST("/revoke", func(c *gin.Context) {
+			tokenControllers.RevokeToken(c, &tokenService)
+		})
+	}
+
+	// Routes that change data require an API key
+	keyed := v2.Group("")
+	keyed.Use(asClient(middleware.APIKeyAuthMiddleware(secrets)))
+	keyed.Use(impersonations)
+	keyed.Use(clientconfig.Middleware(clientStore))
+	keyed.Use(clientconfig.RequireAllowedIP())
+	keyed.Use(injectFaults)
+	keyed.Use(verified)
+	keyed.Use(signed)
+	keyed.Use(localized)
+	{
+		keyed.POST("/applicants", limitedJSON, func(c *gin.Context) {
+			applicationControllers.CreateApplicant(c, &applicantService, kmsUploader)
+		})
+
+		keyed.PUT("/applicants/:id", limitedJSON, func(c *gin.Context) {
+			applicationControllers.UpdateApplicant(c, &applicantService)
+		})
+
+		keyed.PATCH("/applicants/:id", limitedJSON, func(c *gin.Context) {
+			applicationControllers.PatchApplicant(c, &applicantService)
+		})
+
+		keyed.PATCH("/applicants/:id/annotations", limitedJSON, func(c *gin.Context) {
+			applicationControllers.UpdateAnnotations(c, &applicantService)
+		})
+
+		keyed.POST("/applicants/:id/restore", auditControllers.RecordClientActions(&auditService), func(c *gin.Context) {
+			applicationControllers.RestoreApplicant(c, &applicantService)
+		})
+
+		keyed.POST("/applicants/from-document", func(c *gin.Context) {
+			documentControllers.CreateApplicantFromDocument(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/confirm", func(c *gin.Context) {
+			documentControllers.ConfirmApplicant(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/documents", func(c *gin.Context) {
+			documentControllers.CreateDocument(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/documents/presign-upload", func(c *gin.Context) {
+			documentControllers.PresignUpload(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/documents/complete", func(c *gin.Context) {
+			documentControllers.CompleteUpload(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/documents/archive", auditControllers.RecordClientDownloads(&auditService), func(c *gin.Context) {
+			documentControllers.ArchiveDocuments(c, &documentService)
+		})
+
+		keyed.GET("/applicants/:id/documents/:docId", func(c *gin.Context) {
+			documentControllers.GetDocument(c, &documentService)
+		})
+
+		keyed.PUT("/applicants/:id/documents/:docId", func(c *gin.Context) {
+			documentControllers.UpdateDocument(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/documents/:docId/replace", func(c *gin.Context) {
+			documentControllers.ReplaceDocument(c, &documentService)
+		})
+
+		keyed.GET("/applicants/:id/documents/:docId/versions", func(c *gin.Context) {
+			documentControllers.GetDocumentVersions(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/documents/:docId/restore", func(c *gin.Context) {
+			documentControllers.RestoreDocument(c, &documentService)
+		})
+
+		keyed.GET("/applicants/:id/documents/:docId/restore", func(c *gin.Context) {
+			documentControllers.GetDocumentRestore(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/documents/:docId/undelete", auditControllers.RecordClientActions(&auditService), func(c *gin.Context) {
+			documentControllers.UndeleteDocument(c, &documentService)
+		})
+
+		keyed.POST("/applicants/:id/attachments", func(c *gin.Context) {
+			attachmentControllers.AddAttachment(c, &attachmentService)
+		})
+
+		keyed.GET("/applicants/:id/attachments", gzipped, func(c *gin.Context) {
+			attachmentControllers.ListAttachments(c, &attachmentService)
+		})
+
+		keyed.GET("/applicants/:id/attachments/:attachmentId", func(c *gin.Context) {
+			attachmentControllers.DownloadAttachment(c, &attachmentService)
+		})
+
+		keyed.POST("/applicants/:id/notes", func(c *gin.Context) {
+			noteControllers.AddNote(c, &noteService)
+		})
+
+		keyed.GET("/applicants/:id/notes", gzipped, func(c *gin.Context) {
+			noteControllers.ListNotes(c, &noteService)
+		})
+
+		keyed.POST("/applicants/:id/sync", func(c *gin.Context) {
+			sumsubControllers.SyncApplicant(c, &sumsubSyncService)
+		})
+
+		keyed.POST("/applicants/:id/rekey", func(c *gin.Context) {
+			rekeyControllers.RekeyApplicant(c, &rekeyService)
+		})
+
+		if hostsSessions {
+			keyed.POST("/applicants/:id/sessions", func(c *gin.Context) {
+				sessionControllers.CreateSession(c, &sessionService)
+			})
+		}
+
+		keyed.GET("/webhook-endpoint", func(c *gin.Context) {
+			webhookControllers.GetWebhookEndpoint(c, &webhookService)
+		})
+
+		keyed.PUT("/webhook-endpoint", func(c *gin.Context) {
+			webhookControllers.SetWebhookEndpoint(c, &webhookService)
+		})
+
+		keyed.POST("/webhook-endpoint/rotate-secret", func(c *gin.Context) {
+			webhookControllers.RotateWebhookSecret(c, &webhookService)
+		})
+
+		keyed.POST("/webhooks/verify", func(c *gin.Context) {
+			webhookControllers.VerifyWebhookSignature(c, &webhookService)
+		})
+
+		keyed.POST("/webhooks/failures/:id/redeliver", func(c *gin.Context) {
+			webhookControllers.RedeliverWebhook(c, &webhookService)
+		})
+
+		keyed.POST("/sandbox/webhooks/test", func(c *gin.Context) {
+			webhookControllers.TestWebhook(c, &webhookService)
+		})
+
+		keyed.POST("/reports", func(c *gin.Context) {
+			reportControllers.RequestComplianceReport(c, &complianceService)
+		})
+
+		keyed.POST("/encryption-keys", func(c *gin.Context) {
+			encryptionKeyControllers.RegisterEncryptionKey(c, &encryptionKeyService)
+		})
+
+		keyed.POST("/encryption-keys/:id/validate", func(c *gin.Context) {
+			encryptionKeyControllers.ValidateEncryptionKey(c, &encryptionKeyService)
+		})
+
+		keyed.POST("/encryption-keys/:id/activate", func(c *gin.Context) {
+			encryptionKeyControllers.ActivateEncryptionKey(c, &encryptionKeyService)
+		})
+
+		keyed.GET("/ip-allowlist", clientControllers.GetOwnIPAllowlist)
+
+		keyed.PUT("/ip-allowlist", func(c *gin.Context) {
+			clientControllers.SetOwnIPAllowlist(c, &clientService)
+		})
+	}
+
+	// Reviewer UIs on other origins read previews, asking first in a preflight request
+	// that carries no credentials
+	previewCORS := preview.CORS(settings.Previews.AllowedOrigins)
+	v2.OPTIONS("/applicants/:id/documents/:docId/preview", previewCORS)
+
+	// Read-only routes also accept a JWT, e.g. from the client dashboard
+	readable := v2.Group("")
+	readable.Use(asClient(combinedAuth))
+	readable.Use(impersonations)
+	readable.Use(clientconfig.Middleware(clientStore))
+	readable.Use(clientconfig.RequireAllowedIP())
+	readable.Use(injectFaults)
+	readable.Use(verified)
+	readable.Use(signed)
+	readable.Use(localized)
+	{
+		readable.GET("/applicants", gzipped, func(c *gin.Context) {
+			applicationControllers.GetAllApplicants(c, &applicantService)
+		})
+
+		readable.GET("/applicants/deleted", func(c *gin.Context) {
+			applicationControllers.ListDeletedApplicants(c, &applicantService)
+		})
+
+		readable.GET("/applicants/:id", func(c *gin.Context) {
+			applicationControllers.GetApplicant(c, &applicantService)
+		})
+
+		readable.GET("/applicants/:id/sessions/:sessionId", func(c *gin.Context) {
+			sessionControllers.GetSession(c, &sessionService)
+		})
+
+		readable.GET("/stats", func(c *gin.Context) {
+			statsControllers.GetStats(c, &statsService)
+		})
+
+		readable.GET("/labels", i18n.ListLabels)
+
+		readable.GET("/levels", func(c *gin.Context) {
+			levelControllers.ListLevels(c, levelCatalog)
+		})
+
+		readable.GET("/documents/jobs/:job_id", func(c *gin.Context) {
+			documentControllers.GetUploadJob(c, &documentService)
+		})
+
+		readable.GET("/applicants/:id/documents/:docId/preview", previewCORS, auditControllers.RecordClientDownloads(&auditService), func(c *gin.Context) {
+			documentControllers.PreviewDocument(c, &documentService, settings.Previews.MaxAge)
+		})
+
+		readable.GET("/applicants/:id/report.pdf", auditControllers.RecordClientDownloads(&auditService), func(c *gin.Context) {
+			reportControllers.GetApplicantReport(c, &reportService)
+		})
+
+		readable.GET("/reports/:id", func(c *gin.Context) {
+			reportControllers.GetComplianceReport(c, &complianceService)
+		})
+
+		readable.GET("/encryption-keys", func(c *gin.Context) {
+			encryptionKeyControllers.ListEncryptionKeys(c, &encryptionKeyService)
+		})
+
+		readable.GET("/encryption-keys/:id", func(c *gin.Context) {
+			encryptionKeyControllers.GetEncryptionKey(c, &encryptionKeyService)
+		})
+
+		readable.GET("/webhooks/failures", func(c *gin.Context) {
+			webhookControllers.ListWebhookFailures(c, &webhookService)
+		})
+	}
+
+	// Routes for the hosted page, authenticated by a session token instead of the client's
+	// credentials. The applicant's IP is not the client's, so the client's allowlist does not apply.
+	if hostsSessions {
+		hosted := v2.Group("/hosted/session")
+		hosted.Use(sessionControllers.Authenticate(&sessionService))
+		hosted.Use(clientconfig.Middleware(clientStore))
+		hosted.Use(localized)
+		{
+			hosted.GET("", func(c *gin.Context) {
+				sessionControllers.GetHostedSession(c, &sessionService)
+			})
+
+			hosted.POST("/documents", func(c *gin.Context) {
+				sessionControllers.UploadHostedDocument(c, &sessionService, &documentService)
+			})
+
+			hosted.POST("/complete", func(c *gin.Context) {
+				sessionControllers.CompleteHostedSession(c, &sessionService)
+			})
+
+			hosted.GET("/handoff.png", func(c *gin.Context) {
+				sessionControllers.GetHandoffCode(c, &sessionService)
+			})
+
+			hosted.GET("/events", func(c *gin.Context) {
+				sessionControllers.WatchHostedSession(c, &sessionService)
+			})
+		}
+	}
+
+	// Group for internal staff routes that require an admin key
+	admin := v1.Group("/admin")
+	admin.Use(middleware.AdminAuthMiddleware(admins))
+	admin.Use(auditControllers.RecordAdminActions(&auditService))
+	{
+		reviewService := reviewServices.GetReviewServiceImpl()
+		decisionService := decisionServices.GetDecisionServiceImpl()
+		decisionService.Settings = settings.Decisions
+		decisionService.Usage = &usageService
+		reviewers := admin.Group("/review-queue")
+		reviewers.Use(middleware.RequireAdminRole(middleware.RoleReviewer, middleware.RoleAdmin))
+
+		reviewers.GET("", gzipped, func(c *gin.Context) {
+			reviewControllers.GetReviewQueue(c, &reviewService)
+		})
+
+		reviewers.POST("/:id/claim", func(c *gin.Context) {
+			reviewControllers.ClaimApplicant(c, &reviewService)
+		})
+
+		reviewers.POST("/:id/release", func(c *gin.Context) {
+			reviewControllers.ReleaseApplicant(c, &reviewService)
+		})
+
+		reviewers.POST("/:id/assign", middleware.RequireAdminRole(middleware.RoleAdmin), func(c *gin.Context) {
+			reviewControllers.AssignApplicant(c, &reviewService)
+		})
+
+		reviewers.POST("/:id/approve", func(c *gin.Context) {
+			decisionControllers.ProposeDecision(c, &decisionService, localModels.DecisionApprove)
+		})
+
+		reviewers.POST("/:id/reject", func(c *gin.Context) {
+			decisionControllers.ProposeDecision(c, &decisionService, localModels.DecisionReject)
+		})
+
+		// Decisions awaiting a second reviewer under dual control
+		decisions := admin.Group("/decisions")
+		decisions.Use(middleware.RequireAdminRole(middleware.RoleReviewer, middleware.RoleAdmin))
+
+		decisions.POST("/:decisionId/confirm", func(c *gin.Context) {
+			decisionControllers.ConfirmDecision(c, &decisionService)
+		})
+
+		decisions.POST("/:decisionId/decline", func(c *gin.Context) {
+			decisionControllers.DeclineDecision(c, &decisionService)
+		})
+
+		// Previous files of replaced documents
+		admin.GET("/applicants/:id/documents/:docId/versions/:version", middleware.RequireAdminRole(middleware.RoleReviewer, middleware.RoleAdmin), func(c *gin.Context) {
+			documentControllers.DownloadDocumentVersion(c, &documentService)
+		})
+
+		// Process counters, including MongoDB write failures split into transient and persistent
+		admin.GET("/metrics", middleware.RequireAdminRole(middleware.RoleAdmin), gin.WrapH(expvar.Handler()))
+
+		// Audit log of staff actions for a time range, with a proof that nothing was omitted
+		admin.GET("/audit-log/export", middleware.RequireAdminRole(middleware.RoleAdmin), gzipped, func(c *gin.Context) {
+			auditControllers.ExportAuditLog(c, &auditService)
+		})
+
+		// Every download of a document's files, with who made it and why, for data protection reports
+		admin.GET("/documents/:id/access-log", middleware.RequireAdminRole(middleware.RoleAdmin), func(c *gin.Context) {
+			auditControllers.GetDocumentAccessLog(c, &auditService)
+		})
+
+		// Deleted applicants of every client, with how long each can still be restored
+		admin.GET("/applicants/deleted", middleware.RequireAdminRole(middleware.RoleAdmin), func(c *gin.Context) {
+			applicationControllers.AdminListDeletedApplicants(c, &applicantService)
+		})
+
+		admin.GET("/applicants/:id/decisions", middleware.RequireAdminRole(middleware.RoleReviewer, middleware.RoleAdmin), func(c *gin.Context) {
+			decisionControllers.GetApplicantDecisions(c, &decisionService)
+		})
+
+		attachments := admin.Group("/applicants/:id/attachments")
+		attachments.Use(middleware.RequireAdminRole(middleware.RoleReviewer, middleware.RoleAdmin))
+
+		attachments.GET("", gzipped, func(c *gin.Context) {
+			attachmentControllers.AdminListAttachments(c, &attachmentService)
+		})
+
+		attachments.POST("", func(c *gin.Context) {
+			attachmentControllers.AdminAddAttachment(c, &attachmentService)
+		})
+
+		attachments.GET("/:attachmentId", func(c *gin.Context) {
+			attachmentControllers.AdminDownloadAttachment(c, &attachmentService)
+		})
+
+		attachments.DELETE("/:attachmentId", middleware.RequireAdminRole(middleware.RoleAdmin), func(c *gin.Context) {
+			attachmentControllers.AdminDeleteAttachment(c, &attachmentService)
+		})
+
+		// Client onboarding and settings
+		clients := admin.Group("/clients")
+		clients.Use(middleware.RequireAdminRole(middleware.RoleAdmin))
+
+		clients.POST("", func(c *gin.Context) {
+			clientControllers.RegisterClient(c, &clientService)
+		})
+
+		clients.GET("/:clientId", func(c *gin.Context) {
+			clientControllers.GetClient(c, &clientService)
+		})
+
+		clients.PUT("/:clientId", func(c *gin.Context) {
+			clientControllers.UpdateClient(c, &clientService)
+		})
+
+		clients.GET("/:clientId/usage", func(c *gin.Context) {
+			usageControllers.GetClientUsage(c, &usageService)
+		})
+
+		clients.POST("/:clientId/dashboard-users", func(c *gin.Context) {
+			tokenControllers.CreateDashboardUser(c, &tokenService)
+		})
+
+		clients.POST("/:clientId/dashboard-users/:userId/disable", func(c *gin.Context) {
+			tokenControllers.DisableDashboardUser(c, &tokenService)
+		})
+
+		clients.DELETE("/:clientId/api-keys/:keyId", func(c *gin.Context) {
+			tokenControllers.RevokeAPIKey(c, &tokenService)
+		})
+
+		clients.POST("/:clientId/signing-keys", func(c *gin.Context) {
+			signingControllers.RotateSigningKey(c, &signingService)
+		})
+
+		clients.GET("/:clientId/signing-keys", func(c *gin.Context) {
+			signingControllers.ListSigningKeys(c, &signingService)
+		})
+
+		clients.PUT("/:clientId/ip-allowlist", func(c *gin.Context) {
+			clientControllers.SetIPAllowlist(c, &clientService)
+		})
+
+		// Lets a client that locked itself out back in while its allowlist is corrected
+		clients.PUT("/:clientId/ip-allowlist/bypass", func(c *gin.Context) {
+			clientControllers.SetIPAllowlistBypass(c, &clientService)
+		})
+
+		// Figures for the internal operations dashboard. Latency and error rates cover this instance only.
+		operationsService := operationsServices.GetOperationsServiceImpl()
+		ops := admin.Group("/ops")
+		ops.Use(middleware.RequireAdminRole(middleware.RoleAdmin))
+
+		ops.GET("/volumes", func(c *gin.Context) {
+			operationsControllers.GetClientVolumes(c, &operationsService)
+		})
+
+		ops.GET("/webhooks", func(c *gin.Context) {
+			operationsControllers.GetWebhookDeliveries(c, &operationsService)
+		})
+
+		ops.GET("/queues", func(c *gin.Context) {
+			operationsControllers.GetQueueDepth(c, &operationsService)
+		})
+
+		ops.GET("/providers", operationsControllers.GetProviderLatency)
+
+		ops.GET("/vendors", func(c *gin.Context) {
+			operationsControllers.GetVendorHealth(c, vendorHealth)
+		})
+
+		ops.GET("/errors", operationsControllers.GetErrorRates)
+
+		ops.GET("/slos", func(c *gin.Context) {
+			operationsControllers.GetSLOs(c, sloTracker)
+		})
+
+		// Runtime profiles of this instance. go tool pprof cannot send the admin key, so
+		// fetch a profile with curl and open the file.
+		ops.GET("/pprof/*profile", operationsControllers.GetProfile)
+
+		// Log level of this instance, e.g. debug for 30 minutes while chasing a problem
+		ops.GET("/log-level", operationsControllers.GetLogLevel)
+
+		ops.PUT("/log-level", operationsControllers.SetLogLevel)
+
+		// Scheduled jobs, which can be run now or paused on every replica
+		jobs := admin.Group("/jobs")
+		jobs.Use(middleware.RequireAdminRole(middleware.RoleAdmin))
+
+		jobs.GET("", func(c *gin.Context) {
+			jobControllers.ListJobs(c, scheduler)
+		})
+
+		jobs.GET("/:name/runs", func(c *gin.Context) {
+			jobControllers.ListJobRuns(c, scheduler)
+		})
+
+		jobs.POST("/:name/run", func(c *gin.Context) {
+			jobControllers.TriggerJob(c, scheduler)
+		})
+
+		jobs.POST("/:name/pause", func(c *gin.Context) {
+			jobControllers.PauseJob(c, scheduler)
+		})
+
+		jobs.POST("/:name/resume", func(c *gin.Context) {
+			jobControllers.ResumeJob(c, scheduler)
+		})
+
+		// Client snapshots, also taken nightly by the client_backup job
+		backups := admin.Group("/backups")
+		backups.Use(middleware.RequireAdminRole(middleware.RoleAdmin))
+
+		backups.GET("", func(c *gin.Context) {
+			backupControllers.ListBackups(c, &backupService)
+		})
+
+		backups.POST("", func(c *gin.Context) {
+			backupControllers.TakeSnapshot(c, &backupService)
+		})
+
+		notes := admin.Group("/applicants/:id/notes")
+		notes.Use(middleware.RequireAdminRole(middleware.RoleReviewer, middleware.RoleAdmin))
+
+		notes.GET("", gzipped, func(c *gin.Context) {
+			noteControllers.AdminListNotes(c, &noteService)
+		})
+
+		notes.POST("", func(c *gin.Context) {
+			noteControllers.AdminAddNote(c, &noteService)
+		})
+	}
+}
+
+// registerJob adds a job to the scheduler, stopping the server if its configured schedule is invalid
+func registerJob(scheduler *jobServices.Scheduler, name string, run func(ctx context.Context) error) {
+	if err := scheduler.Register(name, run); err != nil {
+		zaplogger.GetLogger().Fatal("Invalid job settings", zap.Error(err))
 	}
 }
@@ -0,0 +1,173 @@
+// Package apperr defines the kinds of error services return for conditions a client can
+// act on, and answers them with the same status and body on every route. A service
+// declares its errors with one of the constructors, such as
+//
+//	var ErrSessionNotFound = apperr.NotFound("session_not_found", "session not found")
+//
+// and wraps them with fmt.Errorf and %w to add detail. Its handler passes whatever it gets
+// back to Respond instead of matching each error to a status itself, and services never
+// write responses of their own.
+package apperr
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrBadRequest is the kind of error returned for a request that cannot be carried out
+	// as it was made, such as one naming an unknown option
+	ErrBadRequest = errors.New("bad request")
+	// ErrForbidden is the kind of error returned when the caller may not act on a record
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is the kind of error returned for a record the client has no access to
+	// or that does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is the kind of error returned when a record is not in a state that allows
+	// the request, or was changed by another request meanwhile
+	ErrConflict = errors.New("conflict")
+	// ErrGone is the kind of error returned for a record that can no longer be acted on,
+	// such as one deleted too long ago to be restored
+	ErrGone = errors.New("gone")
+	// ErrUnprocessable is the kind of error returned for content that was received but
+	// cannot be used, such as a document that cannot be read
+	ErrUnprocessable = errors.New("unprocessable")
+	// ErrProviderUnavailable is the kind of error returned when a verification or storage
+	// provider could not be reached or refused a call
+	ErrProviderUnavailable = errors.New("provider unavailable")
+	// ErrQuotaExceeded is the kind of error returned when a client has used up an allowance
+	// and must wait before trying again
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrNotImplemented is the kind of error returned for a feature this deployment does not offer
+	ErrNotImplemented = errors.New("not implemented")
+	// ErrUnavailable is the kind of error returned for a feature that is not configured
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// ErrApplicantNotFound is returned by every service when the client has no applicant with
+// the requested ID. Applicants that do not exist and those of other clients are not told
+// apart, so clients cannot probe which IDs belong to others.
+var ErrApplicantNotFound = NotFound("applicant_not_found", "applicant not found")
+
+// Error is a domain error of one of the kinds above. errors.Is matches it to its kind as
+// well as to itself.
+type Error struct {
+	Kind    error
+	Code    string // Machine-readable code clients are sent, such as applicant_not_found
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is the error's kind
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// BadRequest returns an error of kind ErrBadRequest
+func BadRequest(code, message string) error {
+	return &Error{Kind: ErrBadRequest, Code: code, Message: message}
+}
+
+// Forbidden returns an error of kind ErrForbidden
+func Forbidden(code, message string) error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+// NotFound returns an error of kind ErrNotFound
+func NotFound(code, message string) error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+// Conflict returns an error of kind ErrConflict
+func Conflict(code, message string) error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+// Gone returns an error of kind ErrGone
+func Gone(code, message string) error {
+	return &Error{Kind: ErrGone, Code: code, Message: message}
+}
+
+// Unprocessable returns an error of kind ErrUnprocessable
+func Unprocessable(code, message string) error {
+	return &Error{Kind: ErrUnprocessable, Code: code, Message: message}
+}
+
+// ProviderUnavailable returns an error of kind ErrProviderUnavailable. Clients are sent
+// its message alone, as whatever it is wrapped with describes the provider's answer.
+func ProviderUnavailable(code, message string) error {
+	return &Error{Kind: ErrProviderUnavailable, Code: code, Message: message}
+}
+
+// QuotaExceeded returns an error of kind ErrQuotaExceeded
+func QuotaExceeded(code, message string) error {
+	return &Error{Kind: ErrQuotaExceeded, Code: code, Message: message}
+}
+
+// NotImplemented returns an error of kind ErrNotImplemented
+func NotImplemented(code, message string) error {
+	return &Error{Kind: ErrNotImplemented, Code: code, Message: message}
+}
+
+// Unavailable returns an error of kind ErrUnavailable
+func Unavailable(code, message string) error {
+	return &Error{Kind: ErrUnavailable, Code: code, Message: message}
+}
+
+// withDetails is an error sent to clients with more members in its response body, such
+// as the checks a document failed
+type withDetails struct {
+	err     error
+	details map[string]interface{}
+}
+
+// WithDetails returns err, which keeps its kind and code, to be answered with details
+// added to the response body
+func WithDetails(err error, details map[string]interface{}) error {
+	return &withDetails{err: err, details: details}
+}
+
+func (e *withDetails) Error() string {
+	return e.err.Error()
+}
+
+func (e *withDetails) Unwrap() error {
+	return e.err
+}
+
+// ErrValidation is returned for input that is well formed but cannot be accepted. Err is
+// what clients are told, and may wrap a more specific error such as
+// models.ErrInvalidApplicant; Fields says what is wrong with each field at fault.
+type ErrValidation struct {
+	Err    error
+	Fields map[string]string
+}
+
+// Invalid returns an ErrValidation reporting err for the fields
+func Invalid(err error, fields map[string]string) error {
+	return &ErrValidation{Err: err, Fields: fields}
+}
+
+func (e *ErrValidation) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, name+" "+e.Fields[name])
+	}
+	return "invalid request: " + strings.Join(problems, ", ")
+}
+
+func (e *ErrValidation) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,127 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errApplicantNotFound = NotFound("applicant_not_found", "applicant not found")
+	errVendorDown        = ProviderUnavailable("vendor_unavailable", "vendor is unavailable")
+	errTooManyUploads    = QuotaExceeded("too_many_uploads", "too many uploads in progress")
+	errInvalidApplicant  = errors.New("invalid applicant")
+)
+
+func TestKinds(t *testing.T) {
+	wrapped := fmt.Errorf("%w: app1", errApplicantNotFound)
+	assert.ErrorIs(t, wrapped, errApplicantNotFound)
+	assert.ErrorIs(t, wrapped, ErrNotFound)
+	assert.NotErrorIs(t, wrapped, ErrQuotaExceeded)
+	assert.Equal(t, "applicant not found: app1", wrapped.Error())
+
+	invalid := Invalid(fmt.Errorf("%w: email required", errInvalidApplicant), map[string]string{"email": "required"})
+	assert.ErrorIs(t, invalid, errInvalidApplicant)
+	assert.Equal(t, "invalid applicant: email required", invalid.Error())
+	assert.Equal(t, "invalid request: dob must be a date, email required",
+		Invalid(nil, map[string]string{"email": "required", "dob": "must be a date"}).Error())
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		err                error
+		expectedStatusCode int
+	}{
+		{BadRequest("invalid_report_period", "invalid report period"), http.StatusBadRequest},
+		{Forbidden("not_assigned", "applicant is not assigned to this reviewer"), http.StatusForbidden},
+		{errApplicantNotFound, http.StatusNotFound},
+		{Conflict("upload_in_progress", "document upload is still in progress"), http.StatusConflict},
+		{Gone("restore_period_over", "the grace period for restoring the record has passed"), http.StatusGone},
+		{Unprocessable("document_unreadable", "applicant details could not be read from the document"), http.StatusUnprocessableEntity},
+		{errVendorDown, http.StatusBadGateway},
+		{errTooManyUploads, http.StatusTooManyRequests},
+		{NotImplemented("direct_uploads_disabled", "direct uploads are not enabled"), http.StatusNotImplemented},
+		{Unavailable("backups_disabled", "backups are not configured"), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			status, ok := Status(fmt.Errorf("%w: detail", tt.err))
+			assert.True(t, ok)
+			assert.Equal(t, tt.expectedStatusCode, status)
+		})
+	}
+
+	_, ok := Status(errors.New("connection reset"))
+	assert.False(t, ok)
+}
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		name               string
+		err                error
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "Not found",
+			err:                fmt.Errorf("%w: app1", errApplicantNotFound),
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error":"applicant not found: app1","code":"applicant_not_found"}`,
+		},
+		{
+			name:               "Validation",
+			err:                Invalid(fmt.Errorf("%w: email required", errInvalidApplicant), map[string]string{"email": "required"}),
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody:       `{"error":"invalid applicant: email required","code":"validation_failed","fields":{"email":"required"}}`,
+		},
+		{
+			name:               "Provider unavailable hides the provider's answer",
+			err:                fmt.Errorf("%w: responded with status 500: internal token expired", errVendorDown),
+			expectedStatusCode: http.StatusBadGateway,
+			expectedBody:       `{"error":"vendor is unavailable","code":"vendor_unavailable"}`,
+		},
+		{
+			name:               "Conflict",
+			err:                fmt.Errorf("%w: doc1", Conflict("document_archived", "document is archived; restore it before downloading")),
+			expectedStatusCode: http.StatusConflict,
+			expectedBody:       `{"error":"document is archived; restore it before downloading: doc1","code":"document_archived"}`,
+		},
+		{
+			name:               "Details",
+			err:                WithDetails(Unprocessable("upload_checks_failed", "document failed upload checks"), map[string]interface{}{"checks": []string{"file_size"}}),
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody:       `{"error":"document failed upload checks","code":"upload_checks_failed","checks":["file_size"]}`,
+		},
+		{
+			name:               "Quota exceeded",
+			err:                errTooManyUploads,
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedBody:       `{"error":"too many uploads in progress","code":"too_many_uploads"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			assert.True(t, Respond(c, tt.err))
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+
+	t.Run("Other errors are left to the caller", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		assert.False(t, Respond(c, errors.New("connection reset")))
+		assert.False(t, c.Writer.Written())
+	})
+}
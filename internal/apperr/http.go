@@ -0,0 +1,81 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// ValidationFailed is the code of responses to an ErrValidation
+const ValidationFailed = "validation_failed"
+
+// statuses are the HTTP statuses of the kinds of error
+var statuses = map[error]int{
+	ErrBadRequest:          http.StatusBadRequest,
+	ErrForbidden:           http.StatusForbidden,
+	ErrNotFound:            http.StatusNotFound,
+	ErrConflict:            http.StatusConflict,
+	ErrGone:                http.StatusGone,
+	ErrUnprocessable:       http.StatusUnprocessableEntity,
+	ErrProviderUnavailable: http.StatusBadGateway,
+	ErrQuotaExceeded:       http.StatusTooManyRequests,
+	ErrNotImplemented:      http.StatusNotImplemented,
+	ErrUnavailable:         http.StatusServiceUnavailable,
+}
+
+// Status returns the HTTP status of a domain error, and false for any other error
+func Status(err error) (int, bool) {
+	var invalid *ErrValidation
+	if errors.As(err, &invalid) {
+		return http.StatusUnprocessableEntity, true
+	}
+	var domain *Error
+	if errors.As(err, &domain) {
+		status, ok := statuses[domain.Kind]
+		return status, ok
+	}
+	return 0, false
+}
+
+// Respond answers a domain error with its status, message, code and any details it was
+// given, and reports whether it did. Bad requests get 400, forbidden actions 403, not found
+// errors 404, conflicts 409, records that are gone 410, validation errors 422 with the
+// fields at fault, unprocessable content 422, provider errors 502, exceeded quotas 429,
+// features not offered 501 and features not configured 503. Any other error is left to
+// the caller.
+func Respond(c *gin.Context, err error) bool {
+	status, ok := Status(err)
+	if !ok {
+		return false
+	}
+
+	var invalid *ErrValidation
+	if errors.As(err, &invalid) {
+		body := gin.H{"error": err.Error(), "code": ValidationFailed}
+		if len(invalid.Fields) > 0 {
+			body["fields"] = invalid.Fields
+		}
+		c.JSON(status, body)
+		return true
+	}
+	var domain *Error
+	errors.As(err, &domain)
+	if domain.Kind == ErrProviderUnavailable {
+		// The provider's answer is for our logs, not the client
+		zaplogger.GetLogger().Error("Provider unavailable", zap.Error(err), zap.String("route", c.FullPath()))
+		c.JSON(status, gin.H{"error": domain.Message, "code": domain.Code})
+		return true
+	}
+	body := gin.H{"error": err.Error(), "code": domain.Code}
+	var detailed *withDetails
+	if errors.As(err, &detailed) {
+		for name, value := range detailed.details {
+			body[name] = value
+		}
+	}
+	c.JSON(status, body)
+	return true
+}
@@ -2,14 +2,30 @@ package controllers
 
 import (
 	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"time"
+	"net/netip"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/consent"
+	"github.com/rachel-lawrie/verus_app_backend/internal/diagnostics"
+	"github.com/rachel-lawrie/verus_app_backend/internal/dto"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
 	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/jsonpatch"
+	"github.com/rachel-lawrie/verus_app_backend/internal/levels"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/requestmeta"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
 	"github.com/rachel-lawrie/verus_backend_core/models"
 	"github.com/rachel-lawrie/verus_backend_core/models_sumsub"
 	"github.com/rachel-lawrie/verus_backend_core/utils"
@@ -17,11 +33,16 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	defaultDeletedLimit = 50
+	maxDeletedLimit     = 200
+)
+
 // createApplicantObject creates a new applicant object with provided name, dob, address, email, phone and auto-generates fields like applicant id and timestamps.
 
 func createApplicantObject(firstName, middleName, lastName, email, phone, level string, encryptedData models.EncryptedData) models.Applicant {
 	return models.Applicant{
-		ApplicantID:       uuid.New().String(),       // Generate a unique ID for the applicant
+		ApplicantID:       ids.Applicant.New(),       // Generate a unique ID for the applicant
 		FirstName:         firstName,                 // Set the provided name
 		MiddleName:        middleName,                // Set the provided middle name
 		LastName:          lastName,                  // Set the provided last name
@@ -29,8 +50,8 @@ func createApplicantObject(firstName, middleName, lastName, email, phone, level
 		Phone:             phone,                     // Phone can be set later if required
 		ClientID:          "placeholder",             // Associate with a client ID if available
 		EncryptedData:     encryptedData,             // Encrypted DOB and address
-		CreatedAt:         time.Now(),                // Set the current time as creation time
-		UpdatedAt:         time.Now(),                // Set the current time as the last update time
+		CreatedAt:         timestamp.Now(),           // Set the current time as creation time
+		UpdatedAt:         timestamp.Now(),           // Set the current time as the last update time
 		Deleted:           false,                     // Set the applicant as active (not deleted)
 		DeletedAt:         nil,                       // No deletion timestamp initially
 		DeletedBy:         nil,                       // No deletion information initially
@@ -44,14 +65,18 @@ func CreateApplicant(c *gin.Context, service interfaces.ApplicantService, kmsUpl
 	logger := zaplogger.GetLogger()
 	// Define the input struct for the applicant
 	var input struct {
-		FirstName  string            `json:"first_name" binding:"required"`
-		MiddleName string            `json:"middle_name" binding:"required"`
-		LastName   string            `json:"last_name" binding:"required"`
-		Email      string            `json:"email" binding:"required"` // Applicant's email address
-		Phone      string            `json:"phone" binding:"required"` // Applicant's phone number
-		Address    models.RawAddress `json:"address" binding:"required"`
-		DOB        string            `json:"dob" binding:"required"`   // Applicant's date of birth
-		Level      string            `json:"level" binding:"required"` // Verification level
+		FirstName  string                  `json:"first_name" binding:"required"`
+		MiddleName string                  `json:"middle_name" binding:"required"`
+		LastName   string                  `json:"last_name" binding:"required"`
+		Email      string                  `json:"email" binding:"required"` // Applicant's email address
+		Phone      string                  `json:"phone" binding:"required"` // Applicant's phone number
+		Address    models.RawAddress       `json:"address" binding:"required"`
+		DOB        string                  `json:"dob" binding:"required"`   // Applicant's date of birth
+		Level      string                  `json:"level" binding:"required"` // Verification level
+		Device     *localModels.DeviceInfo `json:"device"`                   // End user's device, as seen by the client
+		Consent    *localModels.Consent    `json:"consent"`                  // Applicant's agreement to be verified
+		Tags       []string                `json:"tags"`                     // Client's own tags for the applicant
+		Metadata   map[string]string       `json:"metadata"`                 // Client's own key/value metadata
 	}
 
 	// Set content type to application/json
@@ -64,7 +89,8 @@ func CreateApplicant(c *gin.Context, service interfaces.ApplicantService, kmsUpl
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
 		return
 	}
-	logger.Debug("Raw request body: ", zap.String("body", string(bodyBytes)))
+	// Sampled, as this is logged for every request while debug logs are on
+	diagnostics.Logger().Debug("Raw request body: ", zap.String("body", string(bodyBytes)))
 
 	// Set the body back
 	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
@@ -74,6 +100,43 @@ func CreateApplicant(c *gin.Context, service interfaces.ApplicantService, kmsUpl
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Device != nil && input.Device.IP != "" {
+		if _, err := netip.ParseAddr(input.Device.IP); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "device.ip must be a valid IP address"})
+			return
+		}
+	}
+	annotations, err := localModels.NewAnnotations(input.Tags, input.Metadata)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Applicants can only be created at the levels in the catalog, and store its spelling
+	input.Level, err = levels.Validate("level", input.Level)
+	if apperr.Respond(c, err) {
+		return
+	}
+
+	// Clients may only verify applicants at the levels they were onboarded for
+	client, err := clientconfig.FromContext(c)
+	if err != nil {
+		log.Printf("CreateApplicant: Error loading client settings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create applicant"})
+		return
+	}
+	if !clientconfig.AllowsLevel(client, input.Level) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "level is not enabled for this client", "allowed_levels": client.AllowedVerificationLevels})
+		return
+	}
+	consentRecord, err := consent.Record(client, input.Consent, timestamp.Now())
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Generate a DEK using KMSUploader
 	plaintextKey, encryptedKey, err := kmsUploader.GenerateDataKey(c.Request.Context())
@@ -114,13 +177,25 @@ func CreateApplicant(c *gin.Context, service interfaces.ApplicantService, kmsUpl
 		EncryptedKey: encryptedKey,
 	}
 
-	applicant := createApplicantObject(input.FirstName, input.MiddleName, input.LastName, input.Email, input.Phone, input.Level, encryptedData)
+	applicant := localModels.ApplicantRecord{
+		Applicant: createApplicantObject(input.FirstName, input.MiddleName, input.LastName, input.Email, input.Phone, input.Level, encryptedData),
+		DeviceMetadata: &localModels.DeviceMetadata{
+			Submitted:  input.Device,
+			Captured:   requestmeta.FromContext(c),
+			CapturedAt: timestamp.Now(),
+		},
+		Consent:     consentRecord,
+		Annotations: annotations,
+	}
 
 	// Log the full applicant object before insertion
 	log.Printf("CreateApplicant: Applicant object to be inserted: %+v", applicant)
 
 	// Call the upload service to handle the file upload
-	applicant, err = service.CreateApplicant(c, &applicant)
+	applicant, err = service.CreateApplicant(c, &applicant, input.Address.Country)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
 	if err != nil {
 		log.Printf("CreateApplicant: Error creating applicant: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create applicant"})
@@ -130,9 +205,46 @@ func CreateApplicant(c *gin.Context, service interfaces.ApplicantService, kmsUpl
 	c.JSON(http.StatusOK, gin.H{"message": "Applicant created successfully", "applicant_id": applicant.ApplicantID})
 }
 
-// GetAllApplicants is the handler function for retrieving all applicants
+// sparseSelection reads the ?fields= and ?include= query parameters. ok is false when
+// neither is given, in which case whole applicants are returned.
+func sparseSelection(c *gin.Context) (selection localModels.ApplicantSelection, ok bool, err error) {
+	fields, hasFields := c.GetQuery("fields")
+	include, hasInclude := c.GetQuery("include")
+	if !hasFields && !hasInclude {
+		return localModels.ApplicantSelection{}, false, nil
+	}
+	selection, err = localModels.ParseApplicantSelection(fields, include)
+	return selection, true, err
+}
+
+// GetAllApplicants is the handler function for retrieving all applicants, narrowed to
+// those with every ?tag= and ?metadata.<key>= given
 func GetAllApplicants(c *gin.Context, service interfaces.ApplicantService) {
-	applicants, err := service.GetAllApplicants(c)
+	selection, sparse, err := sparseSelection(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_fields": localModels.ApplicantFields})
+		return
+	}
+	filter, err := localModels.ParseApplicantFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if sparse {
+		applicants, err := service.SelectApplicants(c, filter, selection)
+		if err != nil {
+			log.Printf("GetAllApplicants: Error retrieving applicants: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve applicants"})
+			return
+		}
+		for i := range applicants {
+			applicants[i] = dto.SelectionResponse(apiversion.FromContext(c), applicants[i])
+		}
+		c.JSON(http.StatusOK, applicants)
+		return
+	}
+
+	applicants, err := service.GetAllApplicants(c, filter)
 	if err != nil {
 		log.Printf("GetAllApplicants: Error retrieving applicants: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve applicants"})
@@ -140,7 +252,7 @@ func GetAllApplicants(c *gin.Context, service interfaces.ApplicantService) {
 	}
 
 	// Respond with the list of applicants
-	c.JSON(http.StatusOK, applicants)
+	c.JSON(http.StatusOK, dto.ApplicantsResponse(apiversion.FromContext(c), applicants))
 }
 
 // GetDocument is the handler function for retrieving document metadata by ID
@@ -149,15 +261,36 @@ func GetApplicant(c *gin.Context, service interfaces.ApplicantService) {
 	appliantID := c.Param("id")
 	log.Printf("GetApplicant: Applicant ID: %v", appliantID)
 
-	applicant, err := service.GetApplicant(c, appliantID)
+	selection, sparse, err := sparseSelection(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_fields": localModels.ApplicantFields})
+		return
+	}
+	if sparse {
+		applicant, err := service.SelectApplicant(c, appliantID, selection)
+		if apperr.Respond(c, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("GetApplicant: Error retrieving applicant: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve applicant"})
+			return
+		}
+		c.JSON(http.StatusOK, dto.SelectionResponse(apiversion.FromContext(c), applicant))
+		return
+	}
 
+	applicant, err := service.GetApplicant(c, appliantID)
+	if apperr.Respond(c, err) || mongoretry.RespondUnavailable(c, err) {
+		return
+	}
 	if err != nil {
-		// Return a JSON response with an error message if document not found
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		log.Printf("GetApplicant: Error retrieving applicant: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve applicant"})
 		return
 	}
 	// Respond with the document metadata
-	c.JSON(http.StatusOK, applicant)
+	c.JSON(http.StatusOK, dto.ApplicantResponse(apiversion.FromContext(c), applicant))
 }
 
 // UpdateDocument is the handler function for updating the status of a document
@@ -173,12 +306,136 @@ func UpdateApplicant(c *gin.Context, service interfaces.ApplicantService) {
 	}
 
 	doc, err := service.UpdateApplicant(c, appliantID, updates)
+	if apperr.Respond(c, err) || mongoretry.RespondUnavailable(c, err) {
+		return
+	}
 	if err != nil {
-		// Return a JSON response with an error message if document not found
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		log.Printf("UpdateApplicant: Error updating applicant: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update applicant"})
 		return
 	}
 
 	// Respond with the updated document metadata
-	c.JSON(http.StatusOK, doc)
+	c.JSON(http.StatusOK, dto.ApplicantResponse(apiversion.FromContext(c), doc))
+}
+
+// PatchApplicant is the handler function for changing an applicant's fields with a JSON
+// Merge Patch or, sent as application/json-patch+json, a JSON Patch
+func PatchApplicant(c *gin.Context, service interfaces.ApplicantService) {
+	applicantID := c.Param("id")
+
+	var patch localModels.ApplicantPatch
+	var err error
+	switch c.ContentType() {
+	case localModels.JSONPatchContentType:
+		err = json.NewDecoder(c.Request.Body).Decode(&patch.Operations)
+		if err == nil && patch.Operations == nil {
+			patch.Operations = []jsonpatch.Operation{}
+		}
+	case localModels.MergePatchContentType, binding.MIMEJSON:
+		err = json.NewDecoder(c.Request.Body).Decode(&patch.Merge)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/merge-patch+json or application/json-patch+json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	applicant, err := service.PatchApplicant(c, applicantID, patch)
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		log.Printf("PatchApplicant: Error patching applicant: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update applicant"})
+	default:
+		c.JSON(http.StatusOK, dto.ApplicantResponse(apiversion.FromContext(c), applicant))
+	}
+}
+
+// UpdateAnnotations is the handler function for changing an applicant's tags and metadata
+// with a JSON merge patch
+func UpdateAnnotations(c *gin.Context, service interfaces.ApplicantService) {
+	applicantID := c.Param("id")
+
+	var patch localModels.AnnotationsPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotations, err := service.UpdateAnnotations(c, applicantID, patch)
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		log.Printf("UpdateAnnotations: Error updating annotations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update annotations"})
+	default:
+		c.JSON(http.StatusOK, annotations)
+	}
+}
+
+// RestoreApplicant is the handler function for un-deleting an applicant within its grace period
+func RestoreApplicant(c *gin.Context, service interfaces.ApplicantService) {
+	applicantID := c.Param("id")
+
+	applicant, err := service.RestoreApplicant(c, applicantID)
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		log.Printf("RestoreApplicant: Error restoring applicant: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not restore applicant"})
+	default:
+		c.JSON(http.StatusOK, dto.ApplicantResponse(apiversion.FromContext(c), applicant))
+	}
+}
+
+// ListDeletedApplicants is the handler function for listing the client's deleted applicants,
+// most recently deleted first, with how long each can still be restored
+func ListDeletedApplicants(c *gin.Context, service interfaces.ApplicantService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, ok := pagination.Parse(c, pagination.Scope(clientID, "deleted_applicants"), defaultDeletedLimit, maxDeletedLimit)
+	if !ok {
+		return
+	}
+	listDeletedApplicants(c, service, tenant.Of(clientID), page)
+}
+
+// AdminListDeletedApplicants is the handler function for listing every client's deleted
+// applicants, or those of the client_id query parameter
+func AdminListDeletedApplicants(c *gin.Context, service interfaces.ApplicantService) {
+	scope := tenant.AllClients()
+	clientID := c.Query("client_id")
+	if clientID != "" {
+		scope = tenant.Of(clientID)
+	}
+
+	page, ok := pagination.Parse(c, pagination.Scope("admin", clientID, "deleted_applicants"), defaultDeletedLimit, maxDeletedLimit)
+	if !ok {
+		return
+	}
+	listDeletedApplicants(c, service, scope, page)
+}
+
+func listDeletedApplicants(c *gin.Context, service interfaces.ApplicantService, scope tenant.Filter, page pagination.Page) {
+	applicants, err := service.ListDeletedApplicants(c.Request.Context(), scope, page)
+	switch {
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		log.Printf("ListDeletedApplicants: Error fetching deleted applicants: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve deleted applicants"})
+	default:
+		pagination.Respond(c, page, applicants, func(applicant localModels.DeletedApplicant) pagination.Cursor {
+			return pagination.Cursor{At: applicant.DeletedAt, ID: applicant.ApplicantID}
+		})
+	}
 }
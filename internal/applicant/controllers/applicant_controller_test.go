@@ -0,0 +1,456 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/levels"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/softdelete"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupApplicantRouter(mockService *localMocks.MockApplicantService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/applicants", func(c *gin.Context) {
+		GetAllApplicants(c, mockService)
+	})
+	router.GET("/applicants/:id", func(c *gin.Context) {
+		GetApplicant(c, mockService)
+	})
+	router.POST("/applicants/:id/restore", func(c *gin.Context) {
+		RestoreApplicant(c, mockService)
+	})
+	router.GET("/applicants/deleted", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+		ListDeletedApplicants(c, mockService)
+	})
+	router.GET("/admin/applicants/deleted", func(c *gin.Context) {
+		AdminListDeletedApplicants(c, mockService)
+	})
+	return router
+}
+
+func TestGetAllApplicantsSparse(t *testing.T) {
+	tests := []struct {
+		name               string
+		query              string
+		expectedSelection  *localModels.ApplicantSelection // nil when whole applicants are fetched
+		expectedStatusCode int
+	}{
+		{
+			name:               "No selection returns whole applicants",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Fields are projected with the applicant ID",
+			query:              "?fields=first_name,status",
+			expectedSelection:  &localModels.ApplicantSelection{Fields: []string{"applicant_id", "first_name", "status"}},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Documents are included on request",
+			query:              "?fields=status&include=documents",
+			expectedSelection:  &localModels.ApplicantSelection{Fields: []string{"applicant_id", "status"}, Documents: true},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Include alone keeps every field",
+			query:              "?include=documents",
+			expectedSelection:  &localModels.ApplicantSelection{Fields: localModels.ApplicantFields, Documents: true},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Encrypted data cannot be selected",
+			query:              "?fields=encrypted_data",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Unknown include",
+			query:              "?include=notes",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockApplicantService)
+			mockService.On("GetAllApplicants", mock.Anything, mock.Anything).Return([]localModels.ApplicantRecord{}, nil)
+			mockService.On("SelectApplicants", mock.Anything, mock.Anything, mock.Anything).Return([]map[string]interface{}{{"applicant_id": "app1"}}, nil)
+			router := setupApplicantRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/applicants"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			switch {
+			case tt.expectedSelection != nil:
+				mockService.AssertCalled(t, "SelectApplicants", mock.Anything, mock.Anything, *tt.expectedSelection)
+				mockService.AssertNotCalled(t, "GetAllApplicants", mock.Anything, mock.Anything)
+			case tt.expectedStatusCode == http.StatusOK:
+				mockService.AssertNotCalled(t, "SelectApplicants", mock.Anything, mock.Anything, mock.Anything)
+			default:
+				assert.Contains(t, w.Body.String(), "allowed_fields")
+				mockService.AssertNotCalled(t, "SelectApplicants", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGetAllApplicantsFiltered(t *testing.T) {
+	tests := []struct {
+		name               string
+		query              string
+		expectedFilter     localModels.ApplicantFilter
+		expectedStatusCode int
+	}{
+		{
+			name:               "No filter",
+			expectedFilter:     localModels.ApplicantFilter{Metadata: map[string]string{}},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Tags and metadata",
+			query:              "?tag=vip&tag=eu-launch&metadata.region=eu&fields=status",
+			expectedFilter:     localModels.ApplicantFilter{Tags: []string{"vip", "eu-launch"}, Metadata: map[string]string{"region": "eu"}},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Operator in a metadata key",
+			query:              "?metadata.$ne=x",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Repeated metadata key",
+			query:              "?metadata.region=eu&metadata.region=us",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Invalid tag",
+			query:              "?tag=%24where",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockApplicantService)
+			mockService.On("GetAllApplicants", mock.Anything, mock.Anything).Return([]localModels.ApplicantRecord{}, nil)
+			mockService.On("SelectApplicants", mock.Anything, mock.Anything, mock.Anything).Return([]map[string]interface{}{}, nil)
+			router := setupApplicantRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/applicants"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode != http.StatusOK {
+				mockService.AssertNotCalled(t, "GetAllApplicants", mock.Anything, mock.Anything)
+				mockService.AssertNotCalled(t, "SelectApplicants", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			if len(tt.expectedFilter.Tags) > 0 {
+				mockService.AssertCalled(t, "SelectApplicants", mock.Anything, tt.expectedFilter, mock.Anything)
+			} else {
+				mockService.AssertCalled(t, "GetAllApplicants", mock.Anything, tt.expectedFilter)
+			}
+		})
+	}
+}
+
+func TestGetApplicantSparse(t *testing.T) {
+	selection := localModels.ApplicantSelection{Fields: []string{"applicant_id", "first_name"}}
+
+	tests := []struct {
+		name               string
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Selected fields only", expectedStatusCode: http.StatusOK},
+		{name: "Applicant not found", serviceErr: apperr.ErrApplicantNotFound, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockApplicantService)
+			mockService.On("SelectApplicant", mock.Anything, "app1", selection).
+				Return(map[string]interface{}{"applicant_id": "app1", "first_name": "Ada"}, tt.serviceErr)
+			router := setupApplicantRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/applicants/app1?fields=first_name", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.JSONEq(t, `{"applicant_id":"app1","first_name":"Ada"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetApplicant(t *testing.T) {
+	tests := []struct {
+		name               string
+		serviceErr         error
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{name: "Found", expectedStatusCode: http.StatusOK},
+		{name: "Applicant not found", serviceErr: apperr.ErrApplicantNotFound, expectedStatusCode: http.StatusNotFound,
+			expectedBody: `{"error":"applicant not found","code":"applicant_not_found"}`},
+		// Only a missing applicant is reported as not found
+		{name: "Lookup fails", serviceErr: errors.New("connection reset"), expectedStatusCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Could not retrieve applicant"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockApplicantService)
+			mockService.On("GetApplicant", mock.Anything, "app1").
+				Return(localModels.ApplicantRecord{Applicant: models.Applicant{ApplicantID: "app1"}}, tt.serviceErr)
+			router := setupApplicantRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/applicants/app1", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), `"applicant_id":"app1"`)
+			}
+		})
+	}
+}
+
+func TestRestoreApplicant(t *testing.T) {
+	tests := []struct {
+		name               string
+		serviceErr         error
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{name: "Restored", expectedStatusCode: http.StatusOK},
+		{name: "Applicant not found", serviceErr: apperr.ErrApplicantNotFound, expectedStatusCode: http.StatusNotFound},
+		{name: "Not deleted", serviceErr: softdelete.ErrNotDeleted, expectedStatusCode: http.StatusConflict, expectedCode: "not_deleted"},
+		{name: "Grace period over", serviceErr: softdelete.ErrGracePeriodOver, expectedStatusCode: http.StatusGone, expectedCode: "restore_period_over"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockApplicantService)
+			mockService.On("RestoreApplicant", mock.Anything, "app1").
+				Return(localModels.ApplicantRecord{Applicant: models.Applicant{ApplicantID: "app1"}}, tt.serviceErr)
+			router := setupApplicantRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/applicants/app1/restore", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tt.expectedCode+`"`)
+			}
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"applicant_id":"app1"`)
+			}
+		})
+	}
+}
+
+func TestListDeletedApplicants(t *testing.T) {
+	deletedAt := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	deleted := []localModels.DeletedApplicant{{
+		ApplicantID:      "app1",
+		ClientID:         "client1",
+		DeletedAt:        deletedAt,
+		RestorableUntil:  deletedAt.Add(30 * 24 * time.Hour),
+		SecondsRemaining: 3600,
+		Restorable:       true,
+	}}
+	tests := []struct {
+		name          string
+		path          string
+		expectedScope tenant.Filter
+	}{
+		{name: "Client sees its own", path: "/applicants/deleted", expectedScope: tenant.Of("client1")},
+		{name: "Admin sees every client", path: "/admin/applicants/deleted", expectedScope: tenant.AllClients()},
+		{name: "Admin narrows to a client", path: "/admin/applicants/deleted?client_id=client2", expectedScope: tenant.Of("client2")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockApplicantService)
+			mockService.On("ListDeletedApplicants", mock.Anything, tt.expectedScope, mock.Anything).Return(deleted, nil)
+			router := setupApplicantRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), `"restorable_until":"2026-10-31T09:00:00Z"`)
+			assert.Contains(t, w.Body.String(), `"seconds_remaining":3600`)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListDeletedApplicantsInvalidCursor(t *testing.T) {
+	mockService := new(localMocks.MockApplicantService)
+	router := setupApplicantRouter(mockService)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/applicants/deleted?cursor=forged", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "ListDeletedApplicants", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// requiringConsent loads every client with consent required
+type requiringConsent struct{}
+
+func (requiringConsent) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	return localModels.Client{ClientID: clientID, Settings: localModels.ClientSettings{RequireConsent: true}}, nil
+}
+
+// fakeKMS hands out a fixed data key
+type fakeKMS struct{}
+
+func (fakeKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	return bytes.Repeat([]byte{7}, 32), []byte("encrypted-key"), nil
+}
+
+func (fakeKMS) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return plaintext, nil
+}
+
+func (fakeKMS) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	return encrypted, nil
+}
+
+func TestCreateApplicantConsent(t *testing.T) {
+	const applicant = `"first_name":"Ada","middle_name":"B","last_name":"Lovelace","email":"ada@example.com","phone":"+441234567890",
+		"address":{"Line1":"1 Main St","City":"London","PostalCode":"N1","Country":"GB"},"dob":"1815-12-10","level":"basic"`
+
+	tests := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{"Consent given", `{` + applicant + `,"consent":{"text_version":"2026-09","given_at":"2026-10-16T09:00:00+02:00","ip":"203.0.113.7","channel":"mobile"}}`, http.StatusOK, ""},
+		{"Consent missing", `{` + applicant + `}`, http.StatusBadRequest, "consent_required"},
+		{"Consent without channel", `{` + applicant + `,"consent":{"text_version":"2026-09","given_at":"2026-10-16T09:00:00Z","ip":"203.0.113.7"}}`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockApplicantService)
+			mockService.On("CreateApplicant", mock.Anything, mock.MatchedBy(func(record *localModels.ApplicantRecord) bool {
+				return record.Consent != nil &&
+					record.Consent.Channel == localModels.ConsentMobile &&
+					record.Consent.GivenAt.Equal(time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)) &&
+					!record.Consent.RecordedAt.IsZero()
+			}), "GB").Return(localModels.ApplicantRecord{}, nil)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/applicants", func(c *gin.Context) {
+				c.Set("client_id", "client1")
+			}, clientconfig.Middleware(requiringConsent{}), func(c *gin.Context) {
+				CreateApplicant(c, mockService, fakeKMS{})
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/applicants", bytes.NewBufferString(tt.body))
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), tt.expectedCode)
+			}
+			if tt.expectedStatusCode != http.StatusOK {
+				mockService.AssertNotCalled(t, "CreateApplicant", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestCreateApplicantUnknownLevel(t *testing.T) {
+	catalog, err := levels.New(config.Settings{Levels: map[string]config.LevelSettings{"basic-kyc-level": {}}})
+	require.NoError(t, err)
+	levels.SetCatalog(catalog)
+	t.Cleanup(func() { levels.SetCatalog(&levels.Catalog{}) })
+
+	mockService := new(localMocks.MockApplicantService)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/applicants", func(c *gin.Context) {
+		CreateApplicant(c, mockService, fakeKMS{})
+	})
+
+	w := httptest.NewRecorder()
+	body := `{"first_name":"Ada","middle_name":"B","last_name":"Lovelace","email":"ada@example.com","phone":"+441234567890",
+		"address":{"Line1":"1 Main St","City":"London","PostalCode":"N1","Country":"GB"},"dob":"1815-12-10","level":"gold"}`
+	req, _ := http.NewRequest(http.MethodPost, "/applicants", bytes.NewBufferString(body))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"unknown verification level: \"gold\"","code":"validation_failed","fields":{"level":"must be one of basic-kyc-level"}}`, w.Body.String())
+	mockService.AssertNotCalled(t, "CreateApplicant", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// onboardedForBasic loads every client onboarded for the basic-kyc-level only
+type onboardedForBasic struct{}
+
+func (onboardedForBasic) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	return localModels.Client{ClientID: clientID, AllowedVerificationLevels: []string{"basic-kyc-level"}}, nil
+}
+
+func TestCreateApplicantStoresCatalogLevel(t *testing.T) {
+	catalog, err := levels.New(config.Settings{Levels: map[string]config.LevelSettings{"basic-kyc-level": {}}})
+	require.NoError(t, err)
+	levels.SetCatalog(catalog)
+	t.Cleanup(func() { levels.SetCatalog(&levels.Catalog{}) })
+
+	mockService := new(localMocks.MockApplicantService)
+	mockService.On("CreateApplicant", mock.Anything, mock.MatchedBy(func(record *localModels.ApplicantRecord) bool {
+		return record.VerificationLevel == "basic-kyc-level"
+	}), "GB").Return(localModels.ApplicantRecord{}, nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/applicants", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	}, clientconfig.Middleware(onboardedForBasic{}), func(c *gin.Context) {
+		CreateApplicant(c, mockService, fakeKMS{})
+	})
+
+	// The level is matched without regard to case, then checked and stored as the catalog spells it
+	w := httptest.NewRecorder()
+	body := `{"first_name":"Ada","middle_name":"B","last_name":"Lovelace","email":"ada@example.com","phone":"+441234567890",
+		"address":{"Line1":"1 Main St","City":"London","PostalCode":"N1","Country":"GB"},"dob":"1815-12-10","level":"Basic-KYC-Level"}`
+	req, _ := http.NewRequest(http.MethodPost, "/applicants", bytes.NewBufferString(body))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestListFilter(t *testing.T) {
+	query, err := listFilter("client1", localModels.ApplicantFilter{}).BSON()
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"client_id": "client1", "deleted": false}, query)
+
+	filter := localModels.ApplicantFilter{Tags: []string{"vip", "eu"}, Metadata: map[string]string{"region": "eu"}}
+	query, err = listFilter("client1", filter).BSON()
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"client_id":                   "client1",
+		"deleted":                     false,
+		"annotations.tags":            bson.M{"$all": []string{"vip", "eu"}},
+		"annotations.metadata.region": "eu",
+	}, query)
+
+	// A list without a client is refused rather than run across clients
+	_, err = listFilter("", filter).BSON()
+	assert.ErrorIs(t, err, tenant.ErrUnscoped)
+}
+
+func TestAnnotationsPatch(t *testing.T) {
+	eu, crm := "eu", "crm-42"
+	current := &localModels.Annotations{Tags: []string{"vip"}, Metadata: map[string]string{"region": "us", "crm_id": "crm-1"}}
+
+	// Metadata is merged key by key, and tags are kept unless given
+	annotations, err := localModels.AnnotationsPatch{Metadata: map[string]*string{"region": &eu, "crm_id": nil}}.Apply(current)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vip"}, annotations.Tags)
+	assert.Equal(t, map[string]string{"region": "eu"}, annotations.Metadata)
+
+	tags := []string{"vip", "vip", "onboarding:2025"}
+	annotations, err = localModels.AnnotationsPatch{Tags: &tags, Metadata: map[string]*string{"crm_id": &crm}}.Apply(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vip", "onboarding:2025"}, annotations.Tags, "repeated tags are dropped")
+	assert.Equal(t, map[string]string{"crm_id": "crm-42"}, annotations.Metadata)
+
+	// Removing everything leaves empty annotations rather than none
+	empty := []string{}
+	annotations, err = localModels.AnnotationsPatch{Tags: &empty, Metadata: map[string]*string{"region": nil, "crm_id": nil}}.Apply(current)
+	require.NoError(t, err)
+	assert.Equal(t, &localModels.Annotations{Tags: []string{}, Metadata: map[string]string{}}, annotations)
+}
+
+func TestAnnotationsBounds(t *testing.T) {
+	long := strings.Repeat("a", localModels.MaxMetadataValueLength)
+	tooMany := map[string]string{}
+	for i := 0; i <= localModels.MaxMetadataKeys; i++ {
+		tooMany["key_"+strings.Repeat("x", i%30)+string(rune('a'+i%26))] = "v"
+	}
+	tooBig := map[string]string{}
+	for i := 0; i < 20; i++ {
+		tooBig["key_"+string(rune('a'+i))] = long
+	}
+
+	tests := []struct {
+		name     string
+		tags     []string
+		metadata map[string]string
+	}{
+		{"Tag with spaces", []string{"big spender"}, nil},
+		{"Tag too long", []string{strings.Repeat("t", localModels.MaxTagLength+1)}, nil},
+		{"Key with a dot", nil, map[string]string{"a.b": "c"}},
+		{"Key with an operator", nil, map[string]string{"$where": "c"}},
+		{"Value too long", nil, map[string]string{"note": long + "a"}},
+		{"Too many keys", nil, tooMany},
+		{"Too many bytes", nil, tooBig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := localModels.NewAnnotations(tt.tags, tt.metadata)
+			assert.ErrorIs(t, err, localModels.ErrInvalidAnnotations)
+		})
+	}
+
+	annotations, err := localModels.NewAnnotations(nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, annotations, "applicants without annotations store none")
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/jsonpatch"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	current := localModels.EditableApplicant{
+		FirstName: "Ada", MiddleName: "B", LastName: "Lovelace",
+		Email: "ada@example.com", Phone: "+441234567890", VerificationLevel: "basic",
+	}
+	operations := func(s string) []jsonpatch.Operation {
+		var ops []jsonpatch.Operation
+		require.NoError(t, json.Unmarshal([]byte(s), &ops))
+		return ops
+	}
+
+	tests := []struct {
+		name     string
+		patch    localModels.ApplicantPatch
+		expected func(a *localModels.EditableApplicant)
+		err      error
+	}{
+		{
+			name:     "Merge patch sets and removes fields",
+			patch:    localModels.ApplicantPatch{Merge: map[string]interface{}{"last_name": "King", "middle_name": nil}},
+			expected: func(a *localModels.EditableApplicant) { a.LastName, a.MiddleName = "King", "" },
+		},
+		{
+			name: "Merge patch sets an address",
+			patch: localModels.ApplicantPatch{Merge: map[string]interface{}{
+				"address": map[string]interface{}{"Line1": "1 Main St", "City": "London", "Country": "GB"},
+			}},
+			expected: func(a *localModels.EditableApplicant) {
+				a.Address = &models.RawAddress{Line1: "1 Main St", City: "London", Country: "GB"}
+			},
+		},
+		{
+			name:     "JSON Patch with a passing test",
+			patch:    localModels.ApplicantPatch{Operations: operations(`[{"op":"test","path":"/last_name","value":"Lovelace"},{"op":"replace","path":"/last_name","value":"King"}]`)},
+			expected: func(a *localModels.EditableApplicant) { a.LastName = "King" },
+		},
+		{
+			name:  "JSON Patch with a failing test",
+			patch: localModels.ApplicantPatch{Operations: operations(`[{"op":"test","path":"/last_name","value":"Byron"}]`)},
+			err:   jsonpatch.ErrTestFailed,
+		},
+		{
+			name:  "Consent cannot be patched",
+			patch: localModels.ApplicantPatch{Merge: map[string]interface{}{"consent": map[string]interface{}{"channel": "web"}}},
+			err:   ErrImmutableField,
+		},
+		{
+			name:  "Status cannot be patched",
+			patch: localModels.ApplicantPatch{Operations: operations(`[{"op":"add","path":"/status","value":"approved"}]`)},
+			err:   ErrImmutableField,
+		},
+		{
+			name:  "Wrong type",
+			patch: localModels.ApplicantPatch{Merge: map[string]interface{}{"email": 5.0}},
+			err:   localModels.ErrInvalidApplicant,
+		},
+		{
+			name:  "Replacing the whole document",
+			patch: localModels.ApplicantPatch{Operations: operations(`[{"op":"replace","path":"","value":["a"]}]`)},
+			err:   jsonpatch.ErrInvalidPatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched, err := applyPatch(current, tt.patch)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			expected := current
+			tt.expected(&expected)
+			assert.Equal(t, expected, patched)
+		})
+	}
+}
+
+func TestEditableApplicantValidate(t *testing.T) {
+	valid := localModels.EditableApplicant{
+		FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Phone: "+441234567890", VerificationLevel: "basic",
+		DOB: "1815-12-10", Address: &models.RawAddress{Line1: "1 Main St", City: "London", Country: "GB"},
+	}
+	assert.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name    string
+		change  func(a *localModels.EditableApplicant)
+		message string
+	}{
+		{"Removed email", func(a *localModels.EditableApplicant) { a.Email = "" }, "email required"},
+		{"Partial address", func(a *localModels.EditableApplicant) { a.Address = &models.RawAddress{City: "Paris"} }, "address.Country, address.Line1 required"},
+		{"Unknown country", func(a *localModels.EditableApplicant) { a.Address.Country = "ZZ" }, "ISO 3166"},
+		{"Malformed date of birth", func(a *localModels.EditableApplicant) { a.DOB = "10/12/1815" }, "dob must be a date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applicant := valid
+			address := *valid.Address
+			applicant.Address = &address
+			tt.change(&applicant)
+			err := applicant.Validate()
+			assert.ErrorIs(t, err, localModels.ErrInvalidApplicant)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+}
@@ -1,24 +1,71 @@
 package services
 
 import (
-	"net/http"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"fmt"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/diagnostics"
+	"github.com/rachel-lawrie/verus_app_backend/internal/geoip"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/jsonpatch"
+	"github.com/rachel-lawrie/verus_app_backend/internal/levels"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongotx"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/replicareads"
+	"github.com/rachel-lawrie/verus_app_backend/internal/softdelete"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
 	"github.com/rachel-lawrie/verus_backend_core/common"
 	"github.com/rachel-lawrie/verus_backend_core/constants"
 	"github.com/rachel-lawrie/verus_backend_core/models"
 	"github.com/rachel-lawrie/verus_backend_core/utils"
 	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	zap "go.uber.org/zap"
 )
 
+// ErrImmutableField is returned when an update tries to change a field that is fixed once
+// the applicant is created, such as its consent record, or that has a route of its own
+var ErrImmutableField = apperr.BadRequest("immutable_field", "field cannot be changed")
+
+// ErrAnnotationsConflict is returned when an applicant's annotations keep changing while
+// a patch is applied to them
+var ErrAnnotationsConflict = apperr.Conflict("annotations_changed", "annotations were changed by another request, try again")
+
+// immutableFields cannot be changed by a general update: consent is kept as it was when the
+// applicant was created, and annotations are checked by UpdateAnnotations
+var immutableFields = []string{"consent", "annotations"}
+
+// ErrApplicantChanged is returned when an applicant keeps changing while a patch is applied to it
+var ErrApplicantChanged = apperr.Conflict("applicant_changed", "applicant was changed by another request, try again")
+
+// patchAttempts is how many times a patch is applied before giving up on an applicant
+// that keeps changing
+const patchAttempts = 3
+
 type ApplicantServiceImpl struct {
-	CollectionName string
+	CollectionName         string
+	DocumentCollectionName string                        // Documents are stored apart from applicants and attached when read
+	Geolocator             geoip.Locator                 // Resolves applicant IPs to countries; nil leaves them undetermined
+	Usage                  localInterfaces.UsageRecorder // Meters created applicants for billing; nil records nothing
+	KMSUploader            localInterfaces.KMSUploader   // Opens the data keys of applicants whose date of birth or address is patched
+	RestoreGracePeriod     time.Duration                 // How long deleted applicants can be restored; softdelete.DefaultGracePeriod when zero
 }
 
 var (
@@ -29,15 +76,16 @@ var (
 func GetApplicantServiceImpl() ApplicantServiceImpl {
 	once.Do(func() {
 		instance = ApplicantServiceImpl{
-			CollectionName: constants.CollectionApplicants,
+			CollectionName:         constants.CollectionApplicants,
+			DocumentCollectionName: localConstants.CollectionDocuments,
 		}
 	})
 	return instance
 }
 
-func (s *ApplicantServiceImpl) CreateApplicant(c *gin.Context, applicant *models.Applicant) (models.Applicant, error) {
+func (s *ApplicantServiceImpl) CreateApplicant(c *gin.Context, applicant *localModels.ApplicantRecord, addressCountry string) (localModels.ApplicantRecord, error) {
 	logger := zaplogger.GetLogger()
-	collection := common.GetCollection(s.CollectionName)
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
 
 	// Get the client ID from the context
 	clientIDStr, err := utils.GetClientIDFromContext(c)
@@ -46,21 +94,69 @@ func (s *ApplicantServiceImpl) CreateApplicant(c *gin.Context, applicant *models
 	}
 
 	applicant.ClientID = clientIDStr
-	_, err = collection.InsertOne(c.Request.Context(), applicant)
+
+	// Flag applicants whose IP is located outside their declared address country
+	if applicant.DeviceMetadata != nil {
+		result := checkIPCountry(s.Geolocator, applicant.DeviceMetadata, addressCountry)
+		applicant.DeviceMetadata.IPCountry = result
+		if result.Status == localModels.CountryMismatch {
+			applicant.RiskSignals = append(applicant.RiskSignals, ipCountryMismatchSignal(result))
+			logger.Info("IP country mismatch at applicant creation",
+				zap.String("applicantID", applicant.ApplicantID),
+				zap.String("ipCountry", result.Country),
+				zap.String("addressCountry", result.AddressCountry),
+			)
+		}
+	}
+
+	if err := mongoschema.ValidateInsert(s.CollectionName, applicant); err != nil {
+		logger.Error("Applicant does not match the collection schema", zap.Error(err))
+		return *applicant, err
+	}
+
+	// The applicant and its usage are saved together where transactions are available
+	err = mongotx.Run(c.Request.Context(), "create_applicant", func(ctx context.Context) error {
+		// Keyed on the applicant ID so a retried insert cannot create the applicant twice
+		key := tenant.Of(applicant.ClientID).With("applicant_id", applicant.ApplicantID)
+		insert := func(ctx context.Context) error { return collection.InsertOnce(ctx, key, *applicant) }
+		if err := mongoretry.Write(ctx, "create_applicant", insert); err != nil {
+			return err
+		}
+		if s.Usage == nil {
+			return nil
+		}
+		err := s.Usage.Record(ctx, applicant.ClientID, localModels.UsageApplicantCreated, applicant.ApplicantID)
+		if err != nil && !mongotx.InTransaction(ctx) {
+			// The applicant is saved already, so billing problems are logged rather than failing the request
+			logger.Error("Error recording usage", zap.Error(err), zap.String("applicantID", applicant.ApplicantID))
+			return nil
+		}
+		return err
+	})
+	if errors.Is(err, mongoretry.ErrUnavailable) {
+		return *applicant, err
+	}
 	if err != nil {
 		logger.Error("Error inserting applicant into MongoDB", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create applicant"})
 		return *applicant, err
 	}
 	return *applicant, nil
 }
 
-func (s *ApplicantServiceImpl) GetAllApplicants(c *gin.Context) ([]models.Applicant, error) {
-	logger := zaplogger.GetLogger()
-
-	var applicants []models.Applicant
+// listFilter matches the client's applicants with all of the filter's tags and metadata values
+func listFilter(clientID string, filter localModels.ApplicantFilter) tenant.Filter {
+	query := tenant.Of(clientID).With("deleted", false)
+	if len(filter.Tags) > 0 {
+		query = query.With("annotations.tags", bson.M{"$all": filter.Tags})
+	}
+	for key, value := range filter.Metadata {
+		query = query.With("annotations.metadata."+key, value)
+	}
+	return query
+}
 
-	collection := common.GetCollection(s.CollectionName)
+func (s *ApplicantServiceImpl) GetAllApplicants(c *gin.Context, filter localModels.ApplicantFilter) ([]localModels.ApplicantRecord, error) {
+	logger := zaplogger.GetLogger()
 
 	// Get the client ID from the context
 	clientIDStr, err := utils.GetClientIDFromContext(c)
@@ -68,36 +164,29 @@ func (s *ApplicantServiceImpl) GetAllApplicants(c *gin.Context) ([]models.Applic
 		return nil, err
 	}
 
-	cursor, err := collection.Find(c.Request.Context(), bson.M{"client_id": clientIDStr, "deleted": false})
+	collection := tenant.Guard(replicareads.Collection(c.Request.Context(), common.GetCollection(s.CollectionName)))
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "applicant_id", Value: 1}})
+	cursor, err := collection.Find(c.Request.Context(), listFilter(clientIDStr, filter), opts)
 	if err != nil {
 		logger.Error("Error fetching applicants from MongoDB", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch applicants"})
 		return nil, err
 	}
-	defer cursor.Close(c.Request.Context())
-
-	for cursor.Next(c.Request.Context()) {
-		var applicant models.Applicant
-		if err := cursor.Decode(&applicant); err != nil {
-			logger.Error("Error decoding applicant from MongoDB", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding applicant"})
-			return nil, err
-		}
-		applicants = append(applicants, applicant)
+	applicants := []localModels.ApplicantRecord{}
+	if err := cursor.All(c.Request.Context(), &applicants); err != nil {
+		logger.Error("Error decoding applicants from MongoDB", zap.Error(err))
+		return nil, err
 	}
 
-	if err := cursor.Err(); err != nil {
-		logger.Error("Cursor error", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cursor error"})
+	if err := s.attachDocuments(c.Request.Context(), clientIDStr, applicants); err != nil {
+		logger.Error("Error fetching documents from MongoDB", zap.Error(err))
 		return nil, err
 	}
 
-	// c.JSON(http.StatusOK, applicants)
 	return applicants, nil
 }
 
-func (s *ApplicantServiceImpl) GetApplicant(c *gin.Context, applicantID string) (models.Applicant, error) {
-	var applicant models.Applicant
+func (s *ApplicantServiceImpl) GetApplicant(c *gin.Context, applicantID string) (localModels.ApplicantRecord, error) {
+	var applicant localModels.ApplicantRecord
 	logger := zaplogger.GetLogger()
 
 	// Get the client ID from the context
@@ -106,34 +195,161 @@ func (s *ApplicantServiceImpl) GetApplicant(c *gin.Context, applicantID string)
 		return applicant, err
 	}
 
-	// Generate the filter
-	filter := bson.M{"client_id": clientIDStr, "applicant_id": applicantID, "deleted": false}
-
-	// Fetch the applicant directly from the database
-	collection := common.GetCollection(s.CollectionName)
-	if collection == nil {
-		err := fmt.Errorf("failed to get MongoDB collection: %s", s.CollectionName)
-		logger.Error("Database collection not found", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch applicant"})
+	applicant, err = s.findApplicant(c.Request.Context(), clientIDStr, applicantID)
+	if errors.Is(err, apperr.ErrApplicantNotFound) {
 		return applicant, err
 	}
-
-	// Perform the database query
-	err = collection.FindOne(c.Request.Context(), filter).Decode(&applicant)
 	if err != nil {
 		logger.Error("Error fetching applicant from MongoDB", zap.Error(err), zap.String("applicantID", applicantID))
-		c.JSON(http.StatusNotFound, gin.H{"error": "Applicant not found"})
 		return applicant, err
 	}
 
+	applicants := []localModels.ApplicantRecord{applicant}
+	if err := s.attachDocuments(c.Request.Context(), clientIDStr, applicants); err != nil {
+		logger.Error("Error fetching documents from MongoDB", zap.Error(err), zap.String("applicantID", applicantID))
+		return applicant, err
+	}
+	applicant = applicants[0]
+
 	// Add a debug log to inspect the fetched applicant record
-	logger.Debug("Raw Applicant Record from Database", zap.Any("rawApplicant", applicant))
+	diagnostics.Logger().Debug("Raw Applicant Record from Database", zap.Any("rawApplicant", applicant))
 	return applicant, nil
 }
 
-func (s *ApplicantServiceImpl) UpdateApplicant(c *gin.Context, applicantID string, updates map[string]interface{}) (models.Applicant, error) {
+// findApplicant reads one of the client's applicants that is not deleted, without its
+// documents, or gives apperr.ErrApplicantNotFound
+func (s *ApplicantServiceImpl) findApplicant(ctx context.Context, clientID, applicantID string) (localModels.ApplicantRecord, error) {
+	filter := tenant.Of(clientID).With("applicant_id", applicantID).With("deleted", false)
+	var applicant localModels.ApplicantRecord
+	err := tenant.Guard(common.GetCollection(s.CollectionName)).FindOne(ctx, filter).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return localModels.ApplicantRecord{}, apperr.ErrApplicantNotFound
+	}
+	return applicant, err
+}
+
+// attachDocuments fills in the documents of each of the client's applicants from the
+// documents collection, oldest first
+func (s *ApplicantServiceImpl) attachDocuments(ctx context.Context, clientID string, applicants []localModels.ApplicantRecord) error {
+	if len(applicants) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(applicants))
+	for _, applicant := range applicants {
+		ids = append(ids, applicant.ApplicantID)
+	}
+	documents, err := s.documentsOf(ctx, tenant.Of(clientID), ids)
+	if err != nil {
+		return err
+	}
+	for i, applicant := range applicants {
+		applicants[i].Documents = documents[applicant.ApplicantID]
+	}
+	return nil
+}
+
+// documentsOf fetches the scope's documents of the given applicants, oldest first. Every
+// applicant has an entry, empty if it has no documents.
+func (s *ApplicantServiceImpl) documentsOf(ctx context.Context, scope tenant.Filter, applicantIDs []string) (map[string][]models.Document, error) {
+	documents := make(map[string][]models.Document, len(applicantIDs))
+	for _, id := range applicantIDs {
+		documents[id] = []models.Document{}
+	}
+
+	filter := scope.With("applicant_id", bson.M{"$in": applicantIDs}).With("deleted", false)
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := tenant.Guard(common.GetCollection(s.DocumentCollectionName)).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc models.Document
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if _, ok := documents[doc.ApplicantID]; ok {
+			documents[doc.ApplicantID] = append(documents[doc.ApplicantID], doc)
+		}
+	}
+	return documents, cursor.Err()
+}
+
+// SelectApplicants lists the client's applicants with only the selected fields, read with a
+// projection so unselected fields never leave the database
+func (s *ApplicantServiceImpl) SelectApplicants(c *gin.Context, filter localModels.ApplicantFilter, selection localModels.ApplicantSelection) ([]map[string]interface{}, error) {
+	clientIDStr, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+	return s.selectApplicants(c.Request.Context(), listFilter(clientIDStr, filter), selection)
+}
+
+// SelectApplicant returns one of the client's applicants with only the selected fields
+func (s *ApplicantServiceImpl) SelectApplicant(c *gin.Context, applicantID string, selection localModels.ApplicantSelection) (map[string]interface{}, error) {
+	clientIDStr, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+	filter := tenant.Of(clientIDStr).With("applicant_id", applicantID).With("deleted", false)
+	applicants, err := s.selectApplicants(c.Request.Context(), filter, selection)
+	if err != nil {
+		return nil, err
+	}
+	if len(applicants) == 0 {
+		return nil, apperr.ErrApplicantNotFound
+	}
+	return applicants[0], nil
+}
+
+func (s *ApplicantServiceImpl) selectApplicants(ctx context.Context, filter tenant.Filter, selection localModels.ApplicantSelection) ([]map[string]interface{}, error) {
+	projection := bson.M{"_id": 0, "applicant_id": 1}
+	for _, field := range selection.Fields {
+		projection[field] = 1
+	}
+	cursor, err := tenant.Guard(common.GetCollection(s.CollectionName)).Find(ctx, filter, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch applicants: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	applicants := []map[string]interface{}{}
+	if err := cursor.All(ctx, &applicants); err != nil {
+		return nil, fmt.Errorf("failed to decode applicants: %w", err)
+	}
+	for _, applicant := range applicants {
+		timestamp.FromBSON(applicant)
+	}
+	if !selection.Documents || len(applicants) == 0 {
+		return applicants, nil
+	}
+
+	ids := make([]string, 0, len(applicants))
+	for _, applicant := range applicants {
+		id, _ := applicant["applicant_id"].(string)
+		ids = append(ids, id)
+	}
+	documents, err := s.documentsOf(ctx, tenant.Of(filter.ClientID()), ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch documents: %w", err)
+	}
+	for i, applicant := range applicants {
+		applicant[localModels.IncludeDocuments] = documents[ids[i]]
+	}
+	return applicants, nil
+}
+
+func (s *ApplicantServiceImpl) UpdateApplicant(c *gin.Context, applicantID string, updates map[string]interface{}) (localModels.ApplicantRecord, error) {
 	logger := zaplogger.GetLogger()
-	var applicant models.Applicant
+	var applicant localModels.ApplicantRecord
+	for field := range updates {
+		for _, fixed := range immutableFields {
+			if field == fixed || strings.HasPrefix(field, fixed+".") {
+				return applicant, fmt.Errorf("%w: %s", ErrImmutableField, field)
+			}
+		}
+	}
 
 	// Get the client ID from the context
 	clientIDStr, err := utils.GetClientIDFromContext(c)
@@ -141,11 +357,10 @@ func (s *ApplicantServiceImpl) UpdateApplicant(c *gin.Context, applicantID strin
 		return applicant, err
 	}
 
-	filter, cacheKey, err := GenerateFilterAndCacheKey(applicantID, clientIDStr, s.CollectionName)
+	filter, cacheKey, err := GenerateFilterAndCacheKey(tenant.Of(clientIDStr), applicantID, s.CollectionName)
 
 	if err != nil {
 		logger.Error("Error generating filter and cache key", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update applicant"})
 		return applicant, err
 	}
 
@@ -154,15 +369,17 @@ func (s *ApplicantServiceImpl) UpdateApplicant(c *gin.Context, applicantID strin
 	for field, value := range updates {
 		updateDoc[field] = value
 	}
-	updateDoc["updated_at"] = time.Now() // Always update the updated_at field
+	updateDoc["updated_at"] = timestamp.Now() // Always update the updated_at field
 
 	update := bson.M{"$set": updateDoc}
+	if err := mongoschema.ValidateUpdate(s.CollectionName, update); err != nil {
+		return applicant, err
+	}
 
 	// Invalidate the cache after a successful update
 	err = common.InvalidateCache(c, s.CollectionName, cacheKey, filter, update, nil)
 	if err != nil {
 		logger.Error("Error invalidating cache", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error invalidating cache"})
 		return applicant, err
 	}
 
@@ -170,19 +387,367 @@ func (s *ApplicantServiceImpl) UpdateApplicant(c *gin.Context, applicantID strin
 	result, err := s.GetApplicant(c, applicantID)
 	if err != nil {
 		logger.Error("Error retrieving updated document", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve updated document"})
 		return applicant, err
 	}
 	return result, err
 }
 
-// GenerateFilterAndCacheKey generates the filter and cache key for a document
-func GenerateFilterAndCacheKey(applicantID, clientID, collectionName string) (bson.M, string, error) {
+// PatchApplicant applies a JSON Merge Patch or JSON Patch to the editable fields of one of
+// the client's applicants, checks the result is still a valid applicant and returns it.
+// Like UpdateAnnotations, the patch is written only if the applicant is unchanged since it
+// was read, so JSON Patch test operations hold for what is written.
+func (s *ApplicantServiceImpl) PatchApplicant(c *gin.Context, applicantID string, patch localModels.ApplicantPatch) (localModels.ApplicantRecord, error) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		return localModels.ApplicantRecord{}, err
+	}
+	client, err := clientconfig.FromContext(c)
+	if err != nil {
+		return localModels.ApplicantRecord{}, err
+	}
+	ctx := c.Request.Context()
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	filter := tenant.Of(clientID).With("applicant_id", applicantID).With("deleted", false)
+
+	for attempt := 0; attempt < patchAttempts; attempt++ {
+		var current localModels.ApplicantRecord
+		err := collection.FindOne(ctx, filter).Decode(&current)
+		if err == mongo.ErrNoDocuments {
+			return localModels.ApplicantRecord{}, apperr.ErrApplicantNotFound
+		}
+		if err != nil {
+			return localModels.ApplicantRecord{}, fmt.Errorf("failed to look up applicant: %w", err)
+		}
+
+		editable := editableOf(current)
+		patched, err := applyPatch(editable, patch)
+		if err != nil {
+			return localModels.ApplicantRecord{}, err
+		}
+		if err := patched.Validate(); err != nil {
+			return localModels.ApplicantRecord{}, err
+		}
+		if patched.VerificationLevel != editable.VerificationLevel {
+			if patched.VerificationLevel, err = levels.Validate("verification_level", patched.VerificationLevel); err != nil {
+				return localModels.ApplicantRecord{}, err
+			}
+		}
+		if patched.VerificationLevel != editable.VerificationLevel && !clientconfig.AllowsLevel(client, patched.VerificationLevel) {
+			return localModels.ApplicantRecord{}, apperr.Invalid(
+				fmt.Errorf("%w: level is not enabled for this client", localModels.ErrInvalidApplicant),
+				map[string]string{"verification_level": "is not enabled for this client"},
+			)
+		}
+
+		changes, err := s.patchChanges(ctx, current, editable, patched)
+		if err != nil {
+			return localModels.ApplicantRecord{}, err
+		}
+		if len(changes) == 0 {
+			return current, nil
+		}
+		changes["updated_at"] = timestamp.Now()
+
+		unchanged := filter.With("updated_at", current.UpdatedAt)
+		update := bson.M{"$set": changes}
+		if err := mongoschema.ValidateUpdate(s.CollectionName, update); err != nil {
+			return localModels.ApplicantRecord{}, err
+		}
+		var matched int64
+		err = mongoretry.Write(ctx, "patch_applicant", func(ctx context.Context) error {
+			result, err := collection.UpdateOne(ctx, unchanged, update)
+			if err != nil {
+				return err
+			}
+			matched = result.MatchedCount
+			return nil
+		})
+		if err != nil {
+			return localModels.ApplicantRecord{}, err
+		}
+		if matched == 1 {
+			return s.GetApplicant(c, applicantID)
+		}
+	}
+	return localModels.ApplicantRecord{}, ErrApplicantChanged
+}
+
+// editableOf returns the fields of an applicant a patch applies to
+func editableOf(applicant localModels.ApplicantRecord) localModels.EditableApplicant {
+	return localModels.EditableApplicant{
+		FirstName:         applicant.FirstName,
+		MiddleName:        applicant.MiddleName,
+		LastName:          applicant.LastName,
+		Email:             applicant.Email,
+		Phone:             applicant.Phone,
+		VerificationLevel: applicant.VerificationLevel,
+	}
+}
+
+// editableFields are the members of an EditableApplicant, which are all a patch may leave
+var editableFields = []string{"first_name", "middle_name", "last_name", "email", "phone", "verification_level", "dob", "address"}
+
+// applyPatch applies a patch to an applicant's editable fields. Patches that add fields
+// other than these get ErrImmutableField, like general updates of them.
+func applyPatch(current localModels.EditableApplicant, patch localModels.ApplicantPatch) (localModels.EditableApplicant, error) {
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return localModels.EditableApplicant{}, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return localModels.EditableApplicant{}, err
+	}
+
+	var result interface{}
+	if patch.Operations != nil {
+		result, err = jsonpatch.Apply(doc, patch.Operations)
+		if err != nil {
+			return localModels.EditableApplicant{}, err
+		}
+	} else {
+		result = jsonpatch.MergePatch(doc, patch.Merge)
+	}
+
+	fields, ok := result.(map[string]interface{})
+	if !ok {
+		return localModels.EditableApplicant{}, fmt.Errorf("%w: the applicant must remain an object", jsonpatch.ErrInvalidPatch)
+	}
+	for field := range fields {
+		if !slices.Contains(editableFields, field) {
+			return localModels.EditableApplicant{}, fmt.Errorf("%w: %s", ErrImmutableField, field)
+		}
+	}
+	raw, err = json.Marshal(fields)
+	if err != nil {
+		return localModels.EditableApplicant{}, err
+	}
+	var patched localModels.EditableApplicant
+	if err := json.Unmarshal(raw, &patched); err != nil {
+		return localModels.EditableApplicant{}, apperr.Invalid(fmt.Errorf("%w: %v", localModels.ErrInvalidApplicant, err), nil)
+	}
+	return patched, nil
+}
+
+// patchChanges returns the stored fields to set for an applicant's patched fields,
+// encrypting a new date of birth or address with the applicant's own data key
+func (s *ApplicantServiceImpl) patchChanges(ctx context.Context, applicant localModels.ApplicantRecord, current, patched localModels.EditableApplicant) (bson.M, error) {
+	changes := bson.M{}
+	for field, values := range map[string][2]string{
+		"first_name":         {current.FirstName, patched.FirstName},
+		"middle_name":        {current.MiddleName, patched.MiddleName},
+		"last_name":          {current.LastName, patched.LastName},
+		"email":              {current.Email, patched.Email},
+		"phone":              {current.Phone, patched.Phone},
+		"verification_level": {current.VerificationLevel, patched.VerificationLevel},
+	} {
+		if values[0] != values[1] {
+			changes[field] = values[1]
+		}
+	}
+	if patched.DOB == "" && patched.Address == nil {
+		return changes, nil
+	}
+
+	if s.KMSUploader == nil {
+		return nil, errors.New("no KMS configured to encrypt the date of birth and address")
+	}
+	key, err := s.KMSUploader.DecryptData(ctx, applicant.EncryptedData.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt applicant data key: %w", err)
+	}
+	if patched.DOB != "" {
+		encrypted, err := utils.EncryptField(patched.DOB, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt date of birth: %w", err)
+		}
+		changes["encrypted_data.dob"] = encrypted
+	}
+	if patched.Address != nil {
+		encrypted, err := utils.EncryptAddress(*patched.Address, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt address: %w", err)
+		}
+		changes["encrypted_data.address"] = encrypted
+	}
+	return changes, nil
+}
+
+// UpdateAnnotations applies a patch to the tags and metadata of one of the client's
+// applicants and returns what the applicant now has. The patch is applied to the
+// annotations as read, and written only if the applicant is unchanged since.
+func (s *ApplicantServiceImpl) UpdateAnnotations(c *gin.Context, applicantID string, patch localModels.AnnotationsPatch) (localModels.Annotations, error) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		return localModels.Annotations{}, err
+	}
+	ctx := c.Request.Context()
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	filter := tenant.Of(clientID).With("applicant_id", applicantID).With("deleted", false)
+
+	for attempt := 0; attempt < patchAttempts; attempt++ {
+		var current struct {
+			Annotations *localModels.Annotations `bson:"annotations"`
+			UpdatedAt   time.Time                `bson:"updated_at"`
+		}
+		opts := options.FindOne().SetProjection(bson.M{"annotations": 1, "updated_at": 1})
+		err := collection.FindOne(ctx, filter, opts).Decode(&current)
+		if err == mongo.ErrNoDocuments {
+			return localModels.Annotations{}, apperr.ErrApplicantNotFound
+		}
+		if err != nil {
+			return localModels.Annotations{}, fmt.Errorf("failed to look up applicant: %w", err)
+		}
+
+		annotations, err := patch.Apply(current.Annotations)
+		if err != nil {
+			return localModels.Annotations{}, err
+		}
+
+		unchanged := filter.With("updated_at", current.UpdatedAt)
+		update := bson.M{"$set": bson.M{"annotations": annotations, "updated_at": timestamp.Now()}}
+		if err := mongoschema.ValidateUpdate(s.CollectionName, update); err != nil {
+			return localModels.Annotations{}, err
+		}
+		var matched int64
+		err = mongoretry.Write(ctx, "update_annotations", func(ctx context.Context) error {
+			result, err := collection.UpdateOne(ctx, unchanged, update)
+			if err != nil {
+				return err
+			}
+			matched = result.MatchedCount
+			return nil
+		})
+		if err != nil {
+			return localModels.Annotations{}, err
+		}
+		if matched == 1 {
+			return *annotations, nil
+		}
+	}
+	return localModels.Annotations{}, ErrAnnotationsConflict
+}
+
+// RestoreApplicant un-deletes one of the client's applicants that was deleted within the
+// grace period, and returns it. Its documents keep their own deleted state, so documents
+// deleted before or with it are restored on their own.
+func (s *ApplicantServiceImpl) RestoreApplicant(c *gin.Context, applicantID string) (localModels.ApplicantRecord, error) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		return localModels.ApplicantRecord{}, err
+	}
+	ctx := c.Request.Context()
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	filter := tenant.Of(clientID).With("applicant_id", applicantID)
+
+	var state softdelete.State
+	opts := options.FindOne().SetProjection(softdelete.Projection)
+	err = collection.FindOne(ctx, filter, opts).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		return localModels.ApplicantRecord{}, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to look up applicant: %w", err)
+	}
+	if err := state.Check(s.RestoreGracePeriod, timestamp.Now()); err != nil {
+		return localModels.ApplicantRecord{}, err
+	}
+
+	update := softdelete.Restore(timestamp.Now())
+	if err := mongoschema.ValidateUpdate(s.CollectionName, update); err != nil {
+		return localModels.ApplicantRecord{}, err
+	}
+	// Only the deletion that was checked is undone, so a second restore changes nothing
+	stillDeleted := filter.With("deleted", true).With("deleted_at", state.DeletedAt)
+	var matched int64
+	err = mongoretry.Write(ctx, "restore_applicant", func(ctx context.Context) error {
+		result, err := collection.UpdateOne(ctx, stillDeleted, update)
+		if err != nil {
+			return err
+		}
+		matched = result.MatchedCount
+		return nil
+	})
+	if err != nil {
+		return localModels.ApplicantRecord{}, err
+	}
+	if matched == 0 {
+		return localModels.ApplicantRecord{}, softdelete.ErrNotDeleted
+	}
+
+	applicant, err := s.findApplicant(ctx, clientID, applicantID)
+	if err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to read restored applicant: %w", err)
+	}
+	applicants := []localModels.ApplicantRecord{applicant}
+	if err := s.attachDocuments(ctx, clientID, applicants); err != nil {
+		return localModels.ApplicantRecord{}, err
+	}
+	return applicants[0], nil
+}
+
+// deletedApplicantProjection reads what a deleted applicant is listed with
+var deletedApplicantProjection = bson.M{
+	"applicant_id": 1, "client_id": 1, "first_name": 1, "last_name": 1,
+	"verification_level": 1, "created_at": 1, "deleted_at": 1, "deleted_by": 1,
+}
+
+// ListDeletedApplicants lists the scope's deleted applicants, most recently deleted first,
+// with when each stops being restorable. Applicants deleted without a deleted_at cannot
+// be restored or placed in the list's order, so they are left out.
+func (s *ApplicantServiceImpl) ListDeletedApplicants(ctx context.Context, scope tenant.Filter, page pagination.Page) ([]localModels.DeletedApplicant, error) {
+	filter := scope.With("deleted", true).With("deleted_at", bson.M{"$type": "date"})
+	for field, condition := range page.Filter(bson.M{}, "deleted_at", "applicant_id") {
+		filter = filter.With(field, condition)
+	}
+	opts := page.Options("deleted_at", "applicant_id").SetProjection(deletedApplicantProjection)
+	cursor, err := tenant.Guard(common.GetCollection(s.CollectionName)).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deleted applicants: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	applicants := []localModels.DeletedApplicant{}
+	if err := cursor.All(ctx, &applicants); err != nil {
+		return nil, fmt.Errorf("failed to decode deleted applicants: %w", err)
+	}
+	now := timestamp.Now()
+	for i := range applicants {
+		applicant := &applicants[i]
+		applicant.CreatedAt, applicant.DeletedAt = timestamp.UTC(applicant.CreatedAt), timestamp.UTC(applicant.DeletedAt)
+		applicant.RestorableUntil = softdelete.RestorableUntil(applicant.DeletedAt, s.RestoreGracePeriod)
+		if remaining := applicant.RestorableUntil.Sub(now); remaining > 0 {
+			applicant.Restorable = true
+			applicant.SecondsRemaining = int64(remaining.Seconds())
+		}
+	}
+	return applicants, nil
+}
+
+// Annotations returns the tags and metadata of a client's applicant, or nil when it has none
+func (s *ApplicantServiceImpl) Annotations(ctx context.Context, clientID, applicantID string) (*localModels.Annotations, error) {
+	var applicant struct {
+		Annotations *localModels.Annotations `bson:"annotations"`
+	}
+	filter := tenant.Of(clientID).With("applicant_id", applicantID)
+	opts := options.FindOne().SetProjection(bson.M{"annotations": 1})
+	err := tenant.Guard(common.GetCollection(s.CollectionName)).FindOne(ctx, filter, opts).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return nil, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up applicant annotations: %w", err)
+	}
+	return applicant.Annotations, nil
+}
+
+// GenerateFilterAndCacheKey generates the filter and cache key for one of the scope's
+// applicants. It fails with tenant.ErrUnscoped if the scope names no client.
+func GenerateFilterAndCacheKey(scope tenant.Filter, applicantID, collectionName string) (bson.M, string, error) {
 	logger := zaplogger.GetLogger()
-	filter := bson.M{
-		"applicant_id": applicantID,
-		"client_id":    clientID,
-		"deleted":      false,
+	filter, err := scope.With("applicant_id", applicantID).With("deleted", false).BSON()
+	if err != nil {
+		return nil, "", err
 	}
 	cacheKey, err := common.GenerateCacheKey(collectionName, filter)
 	if err != nil {
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/country"
+	"github.com/rachel-lawrie/verus_app_backend/internal/geoip"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+)
+
+// checkIPCountry geolocates the applicant's IP and compares it with the declared address
+// country. The IP submitted by the client is preferred, as the captured one belongs to
+// whoever called the API.
+func checkIPCountry(locator geoip.Locator, meta *localModels.DeviceMetadata, addressCountry string) *localModels.IPCountry {
+	result := &localModels.IPCountry{Source: "captured", IP: meta.Captured.IP, Status: localModels.CountryUndetermined}
+	if meta.Submitted != nil && meta.Submitted.IP != "" {
+		result.Source = "submitted"
+		result.IP = meta.Submitted.IP
+	}
+	if code, ok := country.Normalize(addressCountry); ok {
+		result.AddressCountry = code
+	}
+
+	ip, err := netip.ParseAddr(result.IP)
+	switch {
+	case err != nil:
+		result.Reason = &localModels.CheckReason{Code: "ip_invalid", Message: "IP address could not be parsed"}
+		return result
+	case !geoip.IsPublic(ip):
+		result.Reason = &localModels.CheckReason{Code: "ip_not_public", Message: "IP address is private or reserved"}
+		return result
+	case locator == nil:
+		result.Reason = &localModels.CheckReason{Code: "geoip_unavailable", Message: "no GeoIP database is configured"}
+		return result
+	}
+
+	code, ok := locator.Country(ip)
+	if !ok {
+		result.Reason = &localModels.CheckReason{Code: "ip_unknown", Message: "IP address is not in the GeoIP database"}
+		return result
+	}
+	result.Country = code
+
+	if result.AddressCountry == "" {
+		result.Reason = &localModels.CheckReason{Code: "address_country_unknown", Message: "declared address country is not recognised"}
+		return result
+	}
+	if result.Country != result.AddressCountry {
+		result.Status = localModels.CountryMismatch
+		result.Reason = &localModels.CheckReason{
+			Code:    "ip_country_mismatch",
+			Message: fmt.Sprintf("IP is located in %s but the declared address is in %s", result.Country, result.AddressCountry),
+		}
+		return result
+	}
+	result.Status = localModels.CountryMatch
+	return result
+}
+
+// ipCountryMismatchSignal raises a risk signal for an IP located outside the address country
+func ipCountryMismatchSignal(result *localModels.IPCountry) localModels.RiskSignal {
+	return localModels.RiskSignal{
+		Code:      "ip_country_mismatch",
+		Severity:  localModels.RiskMedium,
+		Source:    "applicant_creation",
+		Detail:    result.Reason.Message,
+		CreatedAt: timestamp.Now(),
+	}
+}
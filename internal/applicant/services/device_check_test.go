@@ -0,0 +1,51 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/geoip"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIPCountry(t *testing.T) {
+	locator, err := geoip.ParseCSV(strings.NewReader("203.0.113.0/24,GB\n198.51.100.0/24,FR\n"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		locator        geoip.Locator
+		submitted      *localModels.DeviceInfo
+		capturedIP     string
+		addressCountry string
+		expectedStatus localModels.CountryCheckStatus
+		expectedSource string
+		expectedReason string
+	}{
+		{"Captured IP matches", locator, nil, "203.0.113.5", "GB", localModels.CountryMatch, "captured", ""},
+		{"Submitted IP preferred", locator, &localModels.DeviceInfo{IP: "198.51.100.7"}, "203.0.113.5", "GBR", localModels.CountryMismatch, "submitted", "ip_country_mismatch"},
+		{"Private IP", locator, nil, "10.0.0.1", "GB", localModels.CountryUndetermined, "captured", "ip_not_public"},
+		{"Unknown IP", locator, nil, "192.0.2.1", "GB", localModels.CountryUndetermined, "captured", "ip_unknown"},
+		{"No database", nil, nil, "203.0.113.5", "GB", localModels.CountryUndetermined, "captured", "geoip_unavailable"},
+		{"Unknown address country", locator, nil, "203.0.113.5", "Narnia", localModels.CountryUndetermined, "captured", "address_country_unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := &localModels.DeviceMetadata{
+				Submitted: tt.submitted,
+				Captured:  localModels.DeviceInfo{IP: tt.capturedIP},
+			}
+			result := checkIPCountry(tt.locator, meta, tt.addressCountry)
+
+			assert.Equal(t, tt.expectedStatus, result.Status)
+			assert.Equal(t, tt.expectedSource, result.Source)
+			if tt.expectedReason == "" {
+				assert.Nil(t, result.Reason)
+			} else {
+				assert.Equal(t, tt.expectedReason, result.Reason.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+// respondAttachmentError maps attachment service errors to HTTP responses
+func respondAttachmentError(c *gin.Context, err error) {
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not process attachment"})
+	}
+}
+
+// addAttachment reads the multipart form and stores the attachment
+func addAttachment(c *gin.Context, service interfaces.AttachmentService, scope tenant.Filter, attachment localModels.Attachment) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	attachment.ApplicantID = c.Param("id")
+	attachment.Description = c.PostForm("description")
+
+	result, err := service.AddAttachment(c, scope, attachment, file, header)
+	if err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// streamAttachment writes the attachment content to the response
+func streamAttachment(c *gin.Context, service interfaces.AttachmentService, scope tenant.Filter) {
+	attachment, body, err := service.OpenAttachment(c, c.Param("id"), c.Param("attachmentId"), scope)
+	if err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+	defer body.Close()
+
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(attachment.FileName))
+	c.DataFromReader(http.StatusOK, attachment.FileSize, attachment.MimeType, body, nil)
+}
+
+// AdminAddAttachment is the handler function for a reviewer attaching a file to an applicant
+func AdminAddAttachment(c *gin.Context, service interfaces.AttachmentService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reviewer attachments stay internal unless explicitly shared
+	visibility := localModels.AttachmentInternal
+	switch c.PostForm("visibility") {
+	case "", string(localModels.AttachmentInternal):
+	case string(localModels.AttachmentShared):
+		visibility = localModels.AttachmentShared
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be internal or shared"})
+		return
+	}
+
+	addAttachment(c, service, tenant.AllClients(), localModels.Attachment{
+		Visibility:   visibility,
+		UploadedBy:   adminID,
+		UploaderType: localModels.AttachmentUploaderAdmin,
+	})
+}
+
+// AdminListAttachments is the handler function for listing every attachment on an applicant
+func AdminListAttachments(c *gin.Context, service interfaces.AttachmentService) {
+	attachments, err := service.ListAttachments(c, c.Param("id"), tenant.AllClients())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve attachments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": attachments, "count": len(attachments)})
+}
+
+// AdminDownloadAttachment is the handler function for a reviewer downloading an attachment
+func AdminDownloadAttachment(c *gin.Context, service interfaces.AttachmentService) {
+	streamAttachment(c, service, tenant.AllClients())
+}
+
+// AdminDeleteAttachment is the handler function for an admin removing an attachment
+func AdminDeleteAttachment(c *gin.Context, service interfaces.AttachmentService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := service.DeleteAttachment(c, c.Param("id"), c.Param("attachmentId"), adminID); err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AddAttachment is the handler function for a client attaching a file to one of its applicants.
+// Client attachments are always shared so the client can see what it uploaded.
+func AddAttachment(c *gin.Context, service interfaces.AttachmentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	addAttachment(c, service, tenant.Of(clientID), localModels.Attachment{
+		ClientID:     clientID,
+		Visibility:   localModels.AttachmentShared,
+		UploadedBy:   clientID,
+		UploaderType: localModels.AttachmentUploaderClient,
+	})
+}
+
+// ListAttachments is the handler function for a client listing the shared attachments on an applicant
+func ListAttachments(c *gin.Context, service interfaces.AttachmentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	attachments, err := service.ListAttachments(c, c.Param("id"), tenant.Of(clientID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve attachments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": attachments, "count": len(attachments)})
+}
+
+// DownloadAttachment is the handler function for a client downloading a shared attachment
+func DownloadAttachment(c *gin.Context, service interfaces.AttachmentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	streamAttachment(c, service, tenant.Of(clientID))
+}
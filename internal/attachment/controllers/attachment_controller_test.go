@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/attachment/services"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupAttachmentRouter registers the admin and client attachment routes behind fake logins
+func setupAttachmentRouter(mockService *localMocks.MockAttachmentService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	admin := router.Group("/admin", func(c *gin.Context) {
+		c.Set("admin_id", "reviewer1")
+		c.Set("admin_role", "reviewer")
+	})
+	admin.POST("/applicants/:id/attachments", func(c *gin.Context) {
+		AdminAddAttachment(c, mockService)
+	})
+	admin.DELETE("/applicants/:id/attachments/:attachmentId", func(c *gin.Context) {
+		AdminDeleteAttachment(c, mockService)
+	})
+
+	client := router.Group("/protected", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	})
+	client.POST("/applicants/:id/attachments", func(c *gin.Context) {
+		AddAttachment(c, mockService)
+	})
+	client.GET("/applicants/:id/attachments", func(c *gin.Context) {
+		ListAttachments(c, mockService)
+	})
+	client.GET("/applicants/:id/attachments/:attachmentId", func(c *gin.Context) {
+		DownloadAttachment(c, mockService)
+	})
+	return router
+}
+
+// multipartRequest builds an upload request with a small PDF and the given form fields
+func multipartRequest(t *testing.T, path string, fields map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "letter.pdf")
+	assert.NoError(t, err)
+	_, _ = part.Write([]byte("%PDF-1.4\n"))
+	for k, v := range fields {
+		_ = writer.WriteField(k, v)
+	}
+	assert.NoError(t, writer.Close())
+
+	req, _ := http.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestAdminAddAttachment(t *testing.T) {
+	tests := []struct {
+		name               string
+		visibility         string
+		serviceErr         error
+		expectedVisibility localModels.AttachmentVisibility
+		expectedStatusCode int
+	}{
+		{"Defaults to internal", "", nil, localModels.AttachmentInternal, http.StatusCreated},
+		{"Shared", "shared", nil, localModels.AttachmentShared, http.StatusCreated},
+		{"Invalid visibility", "public", nil, "", http.StatusBadRequest},
+		{"Policy violation", "", fmt.Errorf("%w: too many", services.ErrPolicy), localModels.AttachmentInternal, http.StatusUnprocessableEntity},
+		{"Unknown applicant", "", apperr.ErrApplicantNotFound, localModels.AttachmentInternal, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockAttachmentService)
+			router := setupAttachmentRouter(mockService)
+			mockService.On("AddAttachment", mock.Anything, tenant.AllClients(), mock.MatchedBy(func(a localModels.Attachment) bool {
+				return a.ApplicantID == "app1" && a.Visibility == tt.expectedVisibility &&
+					a.UploadedBy == "reviewer1" && a.UploaderType == localModels.AttachmentUploaderAdmin && a.ClientID == ""
+			}), mock.Anything, mock.Anything).Return(localModels.Attachment{AttachmentID: "att1"}, tt.serviceErr)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, multipartRequest(t, "/admin/applicants/app1/attachments", map[string]string{"visibility": tt.visibility}))
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusBadRequest {
+				mockService.AssertNotCalled(t, "AddAttachment", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestClientAttachmentsAreSharedAndScoped(t *testing.T) {
+	mockService := new(localMocks.MockAttachmentService)
+	router := setupAttachmentRouter(mockService)
+	mockService.On("AddAttachment", mock.Anything, tenant.Of("client1"), mock.MatchedBy(func(a localModels.Attachment) bool {
+		return a.ClientID == "client1" && a.Visibility == localModels.AttachmentShared &&
+			a.UploaderType == localModels.AttachmentUploaderClient
+	}), mock.Anything, mock.Anything).Return(localModels.Attachment{AttachmentID: "att1"}, nil)
+	mockService.On("ListAttachments", mock.Anything, "app1", tenant.Of("client1")).Return([]localModels.Attachment{}, nil)
+
+	// A client cannot make its own upload internal
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, multipartRequest(t, "/protected/applicants/app1/attachments", map[string]string{"visibility": "internal"}))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/protected/applicants/app1/attachments", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	mockService := new(localMocks.MockAttachmentService)
+	router := setupAttachmentRouter(mockService)
+	attachment := localModels.Attachment{AttachmentID: "att1", FileName: "letter.pdf", MimeType: "application/pdf", FileSize: 9}
+	mockService.On("OpenAttachment", mock.Anything, "app1", "att1", tenant.Of("client1")).
+		Return(attachment, io.NopCloser(strings.NewReader("%PDF-1.4\n")), nil)
+	mockService.On("OpenAttachment", mock.Anything, "app1", "internal1", tenant.Of("client1")).
+		Return(localModels.Attachment{}, nil, services.ErrAttachmentNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/protected/applicants/app1/attachments/att1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `"letter.pdf"`)
+	assert.Equal(t, "%PDF-1.4\n", w.Body.String())
+
+	// Internal attachments look the same as missing ones to clients
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/protected/applicants/app1/attachments/internal1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminDeleteAttachment(t *testing.T) {
+	mockService := new(localMocks.MockAttachmentService)
+	router := setupAttachmentRouter(mockService)
+	mockService.On("DeleteAttachment", mock.Anything, "app1", "att1", "reviewer1").Return(nil)
+	mockService.On("DeleteAttachment", mock.Anything, "app1", "gone", "reviewer1").Return(services.ErrAttachmentNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/admin/applicants/app1/attachments/att1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/applicants/app1/attachments/gone", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
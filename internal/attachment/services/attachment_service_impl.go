@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/replicareads"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+var (
+	// ErrAttachmentNotFound is returned when the attachment does not exist or is not visible to the caller
+	ErrAttachmentNotFound = apperr.NotFound("attachment_not_found", "attachment not found")
+)
+
+// AttachmentServiceImpl stores supporting files on applicants, separate from verification documents
+type AttachmentServiceImpl struct {
+	Uploader                interfaces.Uploader
+	KMSUploader             interfaces.KMSUploader
+	CollectionName          string
+	ApplicantCollectionName string
+}
+
+var (
+	instance AttachmentServiceImpl
+	once     sync.Once
+)
+
+func GetAttachmentServiceImpl() AttachmentServiceImpl {
+	once.Do(func() {
+		instance = AttachmentServiceImpl{
+			CollectionName:          localConstants.CollectionAttachments,
+			ApplicantCollectionName: constants.CollectionApplicants,
+		}
+	})
+	return instance
+}
+
+// visibilityFilter limits a query to the scope's attachments the caller may see. Reviewers,
+// who query across clients, see every attachment.
+func visibilityFilter(scope tenant.Filter, applicantID string) tenant.Filter {
+	filter := scope.With("applicant_id", applicantID).With("deleted", false)
+	if scope.ClientID() != "" {
+		filter = filter.With("visibility", localModels.AttachmentShared)
+	}
+	return filter
+}
+
+// AddAttachment checks a file against the attachment policy, stores it in S3 and records it
+// on one of the scope's applicants
+func (s *AttachmentServiceImpl) AddAttachment(c *gin.Context, scope tenant.Filter, attachment localModels.Attachment, file multipart.File, header *multipart.FileHeader) (localModels.Attachment, error) {
+	logger := zaplogger.GetLogger()
+	ctx := c.Request.Context()
+
+	// Clients may only attach files to their own applicants; reviewers may attach to any
+	applicantFilter := scope.With("applicant_id", attachment.ApplicantID).With("deleted", false)
+	var applicant struct {
+		ClientID string `bson:"client_id"`
+	}
+	err := tenant.Guard(common.GetCollection(s.ApplicantCollectionName)).FindOne(ctx, applicantFilter).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return localModels.Attachment{}, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return localModels.Attachment{}, fmt.Errorf("failed to look up applicant: %v", err)
+	}
+	attachment.ClientID = applicant.ClientID
+
+	owner := tenant.Of(applicant.ClientID)
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	count, err := collection.CountDocuments(ctx, owner.With("applicant_id", attachment.ApplicantID).With("deleted", false))
+	if err != nil {
+		return localModels.Attachment{}, fmt.Errorf("failed to count attachments: %v", err)
+	}
+	if count >= maxAttachmentsPerApplicant {
+		return localModels.Attachment{}, fmt.Errorf("%w: applicant already has %d attachments", ErrPolicy, maxAttachmentsPerApplicant)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return localModels.Attachment{}, fmt.Errorf("unable to read file: %v", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return localModels.Attachment{}, fmt.Errorf("unable to rewind file: %v", err)
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if err := checkAttachmentPolicy(head[:n], header.Size, mimeType); err != nil {
+		return localModels.Attachment{}, err
+	}
+
+	attachment.AttachmentID = ids.New()
+	attachment.FileName = cleanFileName(header.Filename)
+	attachment.MimeType = mimeType
+	attachment.FileSize = header.Size
+	attachment.CreatedAt = timestamp.Now()
+	attachment.Deleted = false
+
+	objectName := "attachments/" + attachment.ApplicantID + "/" + attachment.AttachmentID + attachmentExtension(mimeType)
+	fileURL, err := s.Uploader.UploadFile(ctx, file, objectName, mimeType, s.KMSUploader)
+	if err != nil {
+		return localModels.Attachment{}, fmt.Errorf("error uploading attachment to S3: %v", err)
+	}
+	attachment.FileURL = fileURL
+
+	key := owner.With("attachment_id", attachment.AttachmentID)
+	err = mongoretry.Write(ctx, "add_attachment", func(ctx context.Context) error {
+		return collection.InsertOnce(ctx, key, attachment)
+	})
+	if err != nil {
+		logger.Error("Error inserting attachment into MongoDB", zap.Error(err), zap.String("applicantID", attachment.ApplicantID))
+		return localModels.Attachment{}, err
+	}
+
+	logger.Info("Attachment added",
+		zap.String("attachmentID", attachment.AttachmentID),
+		zap.String("applicantID", attachment.ApplicantID),
+		zap.String("uploaderType", attachment.UploaderType),
+		zap.String("visibility", string(attachment.Visibility)),
+	)
+	return attachment, nil
+}
+
+// ListAttachments lists the attachments on one of the scope's applicants visible to the
+// caller, newest first
+func (s *AttachmentServiceImpl) ListAttachments(c *gin.Context, applicantID string, scope tenant.Filter) ([]localModels.Attachment, error) {
+	collection := tenant.Guard(replicareads.Collection(c.Request.Context(), common.GetCollection(s.CollectionName)))
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := collection.Find(c.Request.Context(), visibilityFilter(scope, applicantID), opts)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error fetching attachments from MongoDB", zap.Error(err), zap.String("applicantID", applicantID))
+		return nil, err
+	}
+	defer cursor.Close(c.Request.Context())
+
+	attachments := []localModels.Attachment{}
+	if err := cursor.All(c.Request.Context(), &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// OpenAttachment returns one of the scope's attachments visible to the caller together
+// with its content
+func (s *AttachmentServiceImpl) OpenAttachment(c *gin.Context, applicantID, attachmentID string, scope tenant.Filter) (localModels.Attachment, io.ReadCloser, error) {
+	filter := visibilityFilter(scope, applicantID).With("attachment_id", attachmentID)
+
+	var attachment localModels.Attachment
+	err := tenant.Guard(common.GetCollection(s.CollectionName)).FindOne(c.Request.Context(), filter).Decode(&attachment)
+	if err == mongo.ErrNoDocuments {
+		return attachment, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return attachment, nil, fmt.Errorf("failed to look up attachment: %v", err)
+	}
+
+	parsed, err := url.Parse(attachment.FileURL)
+	if err != nil {
+		return attachment, nil, fmt.Errorf("invalid attachment URL: %v", err)
+	}
+	output, err := s.Uploader.DownloadFile(c.Request.Context(), strings.TrimPrefix(parsed.Path, "/"))
+	if err != nil {
+		return attachment, nil, fmt.Errorf("failed to download attachment from S3: %v", err)
+	}
+	return attachment, output.Body, nil
+}
+
+// DeleteAttachment soft deletes an attachment. Only admins delete attachments, so any
+// client's may be deleted.
+func (s *AttachmentServiceImpl) DeleteAttachment(c *gin.Context, applicantID, attachmentID, deletedBy string) error {
+	filter := visibilityFilter(tenant.AllClients(), applicantID).With("attachment_id", attachmentID)
+	now := timestamp.Now()
+	update := bson.M{"$set": bson.M{"deleted": true, "deleted_at": now, "deleted_by": deletedBy}}
+
+	result, err := tenant.Guard(common.GetCollection(s.CollectionName)).UpdateOne(c.Request.Context(), filter, update)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error deleting attachment", zap.Error(err), zap.String("attachmentID", attachmentID))
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
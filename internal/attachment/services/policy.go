@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	maxAttachmentBytes         = 15 << 20 // Largest supporting file accepted
+	maxAttachmentsPerApplicant = 20
+)
+
+// Types accepted as attachments, with the extension they are stored under.
+// Text based types are checked loosely since content sniffing cannot tell them apart.
+var attachmentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpeg",
+	"image/png":       ".png",
+	"text/plain":      ".txt",
+	"message/rfc822":  ".eml",
+}
+
+// ErrPolicy wraps every attachment policy violation so controllers can report it as a client error
+var ErrPolicy = apperr.Unprocessable("attachment_rejected", "attachment rejected")
+
+// checkAttachmentPolicy validates an attachment's size and type against the first bytes of its content
+func checkAttachmentPolicy(head []byte, size int64, declaredMIME string) error {
+	if size <= 0 {
+		return fmt.Errorf("%w: file is empty", ErrPolicy)
+	}
+	if size > maxAttachmentBytes {
+		return fmt.Errorf("%w: file is larger than %d MB", ErrPolicy, maxAttachmentBytes>>20)
+	}
+
+	declared, _, err := mime.ParseMediaType(declaredMIME)
+	if err != nil {
+		return fmt.Errorf("%w: invalid content type", ErrPolicy)
+	}
+	if _, ok := attachmentTypes[declared]; !ok {
+		return fmt.Errorf("%w: content type %s is not accepted", ErrPolicy, declared)
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if strings.HasPrefix(declared, "text/") || declared == "message/rfc822" {
+		if sniffed != "text/plain" {
+			return fmt.Errorf("%w: content does not look like text", ErrPolicy)
+		}
+		return nil
+	}
+	if sniffed != declared {
+		return fmt.Errorf("%w: content is %s but was declared as %s", ErrPolicy, sniffed, declared)
+	}
+	return nil
+}
+
+// attachmentExtension returns the extension an attachment is stored under
+func attachmentExtension(mimeType string) string {
+	declared, _, _ := mime.ParseMediaType(mimeType)
+	return attachmentTypes[declared]
+}
+
+// cleanFileName keeps only the base name of an uploaded file
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAttachmentPolicy(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	email := []byte("From: support@example.com\r\nSubject: Your application\r\n\r\nHello")
+
+	tests := []struct {
+		name      string
+		head      []byte
+		size      int64
+		mimeType  string
+		expectErr bool
+	}{
+		{"PDF", pdf, 1024, "application/pdf", false},
+		{"PNG screenshot", png, 2048, "image/png", false},
+		{"Email", email, 512, "message/rfc822", false},
+		{"Text with charset", email, 512, "text/plain; charset=utf-8", false},
+		{"Empty", nil, 0, "application/pdf", true},
+		{"Too large", pdf, maxAttachmentBytes + 1, "application/pdf", true},
+		{"Type not accepted", pdf, 1024, "application/zip", true},
+		{"PDF declared as PNG", pdf, 1024, "image/png", true},
+		{"Binary declared as text", png, 1024, "text/plain", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAttachmentPolicy(tt.head, tt.size, tt.mimeType)
+			if tt.expectErr {
+				assert.True(t, errors.Is(err, ErrPolicy), "expected a policy error, got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCleanFileName(t *testing.T) {
+	assert.Equal(t, "letter.pdf", cleanFileName("letter.pdf"))
+	assert.Equal(t, "passwd", cleanFileName("../../etc/passwd"))
+	assert.Equal(t, "shot.png", cleanFileName(`C:\Users\me\shot.png`))
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/audit/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// recordTimeout bounds how long a request waits for its audit event to be written
+const recordTimeout = 5 * time.Second
+
+// documentAccessKey holds the document access a download handler noted for the audit log
+const documentAccessKey = "audit_document_access"
+
+// documentAccess is which documents' files a request handed out, and why
+type documentAccess struct {
+	documentIDs []string
+	reason      string
+}
+
+// NoteDocumentAccess attaches the documents whose files a request hands out, and the reason
+// given for needing them, to the request's audit event
+func NoteDocumentAccess(c *gin.Context, reason string, documentIDs ...string) {
+	c.Set(documentAccessKey, documentAccess{documentIDs: documentIDs, reason: reason})
+}
+
+// RecordAdminActions appends every authenticated staff request to the audit log once it
+// has been handled. It must run after AdminAuthMiddleware so the actor is known.
+func RecordAdminActions(service interfaces.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		adminID, err := middleware.GetAdminIDFromContext(c)
+		if err != nil {
+			return
+		}
+		record(c, service, adminID, c.GetString("admin_role"))
+	}
+}
+
+// RecordImpersonations appends every request support staff made as a client to the audit
+// log once it has been handled, with the admin as the actor. It must run after Impersonate.
+func RecordImpersonations(service interfaces.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		impersonation, ok := middleware.GetImpersonationFromContext(c)
+		if !ok {
+			return
+		}
+		record(c, service, impersonation.AdminID, impersonation.Role)
+	}
+}
+
+// RecordClientDownloads appends client requests that hand out document files to the audit
+// log once they have been handled. Requests refused before the handler noted an access, such
+// as those without a reason, are left out, as are impersonated requests, which
+// RecordImpersonations records. It must run after APIKeyAuthMiddleware.
+func RecordClientDownloads(service interfaces.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if _, ok := c.Get(documentAccessKey); !ok {
+			return
+		}
+		if _, ok := middleware.GetImpersonationFromContext(c); ok {
+			return
+		}
+		clientID, err := utils.GetClientIDFromContext(c)
+		if err != nil {
+			return
+		}
+		record(c, service, clientID, "client")
+	}
+}
+
+// RecordClientActions appends every request a client makes to the audit log once it has
+// been handled, for routes whose every use should be accountable, such as restoring deleted
+// records. Impersonated requests are left to RecordImpersonations. It must run after
+// APIKeyAuthMiddleware.
+func RecordClientActions(service interfaces.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if _, ok := middleware.GetImpersonationFromContext(c); ok {
+			return
+		}
+		clientID, err := utils.GetClientIDFromContext(c)
+		if err != nil {
+			return
+		}
+		record(c, service, clientID, "client")
+	}
+}
+
+// record appends a handled request to the audit log, logging rather than failing when it cannot
+func record(c *gin.Context, service interfaces.AuditService, actorID, actorRole string) {
+	event := localModels.AuditEvent{
+		ActorID:   actorID,
+		ActorRole: actorRole,
+		Method:    c.Request.Method,
+		Route:     c.FullPath(),
+		Path:      c.Request.URL.Path,
+		Status:    c.Writer.Status(),
+		IP:        c.ClientIP(),
+	}
+	if value, ok := c.Get(documentAccessKey); ok {
+		access := value.(documentAccess)
+		event.DocumentIDs = access.documentIDs
+		event.Reason = access.reason
+	}
+	// An impersonated request is justified by why support acted as the client
+	if impersonation, ok := middleware.GetImpersonationFromContext(c); ok {
+		event.OnBehalfOf = impersonation.ClientID
+		event.Reason = impersonation.Reason
+	}
+
+	// The request context may already be cancelled once the response is written
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	if _, err := service.Record(ctx, event); err != nil {
+		zaplogger.GetLogger().Error("Failed to record action in audit log",
+			zap.Error(err),
+			zap.String("actorID", actorID),
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+		)
+	}
+}
+
+// ExportAuditLog is the handler function for exporting the audit log for a time range,
+// with a proof auditors can use to check nothing was left out
+func ExportAuditLog(c *gin.Context, service interfaces.AuditService) {
+	from, err := timestamp.Parse(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 time"})
+		return
+	}
+	to, err := timestamp.Parse(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 time"})
+		return
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	export, err := service.Export(c.Request.Context(), from, to)
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case errors.Is(err, services.ErrIncomplete):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Audit log failed verification"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not export audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// GetDocumentAccessLog is the handler function for listing every download of a document's
+// files, with who made it and the reason they gave, for data protection reports
+func GetDocumentAccessLog(c *gin.Context, service interfaces.AuditService) {
+	accessLog, err := service.DocumentAccessLog(c.Request.Context(), c.Param("id"))
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve document access log"})
+		return
+	}
+	c.JSON(http.StatusOK, accessLog)
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/audit/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupAuditRouter registers the audit routes, and a stand-in download, behind a fake admin login and the audit middleware
+func setupAuditRouter(mockService *localMocks.MockAuditService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	admin := router.Group("/admin", func(c *gin.Context) {
+		c.Set("admin_id", "admin1")
+		c.Set("admin_role", "admin")
+	}, RecordAdminActions(mockService))
+	admin.GET("/audit-log/export", func(c *gin.Context) {
+		ExportAuditLog(c, mockService)
+	})
+	admin.GET("/documents/:id/access-log", func(c *gin.Context) {
+		GetDocumentAccessLog(c, mockService)
+	})
+	admin.GET("/documents/:id/file", func(c *gin.Context) {
+		NoteDocumentAccess(c, localModels.AccessReasonSupport, c.Param("id"))
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestExportAuditLog(t *testing.T) {
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		query              string
+		callsService       bool
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{"Exports the range", "?from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z", true, nil, http.StatusOK},
+		{"Missing to", "?from=2026-09-01T00:00:00Z", false, nil, http.StatusBadRequest},
+		{"Range backwards", "?from=2026-10-01T00:00:00Z&to=2026-09-01T00:00:00Z", false, nil, http.StatusBadRequest},
+		{"Range too large", "?from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z", true, services.ErrExportTooLarge, http.StatusBadRequest},
+		{"Chain fails verification", "?from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z", true, services.ErrIncomplete, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockAuditService)
+			if tt.callsService {
+				mockService.On("Export", mock.Anything, from, to).Return(localModels.AuditExport{}, tt.serviceErr)
+			}
+			mockService.On("Record", mock.Anything, mock.Anything).Return(localModels.AuditEvent{}, nil)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/admin/audit-log/export"+tt.query, nil)
+			setupAuditRouter(mockService).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRecordAdminActions(t *testing.T) {
+	mockService := new(localMocks.MockAuditService)
+	mockService.On("Export", mock.Anything, mock.Anything, mock.Anything).Return(localModels.AuditExport{}, nil)
+	mockService.On("Record", mock.Anything, mock.MatchedBy(func(event localModels.AuditEvent) bool {
+		return event.ActorID == "admin1" &&
+			event.ActorRole == "admin" &&
+			event.Method == http.MethodGet &&
+			event.Route == "/admin/audit-log/export" &&
+			event.Status == http.StatusOK
+	})).Return(localModels.AuditEvent{}, nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/audit-log/export?from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z", nil)
+	setupAuditRouter(mockService).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRecordAdminActionsNotesDocumentAccess(t *testing.T) {
+	mockService := new(localMocks.MockAuditService)
+	mockService.On("Record", mock.Anything, mock.MatchedBy(func(event localModels.AuditEvent) bool {
+		return event.ActorID == "admin1" &&
+			event.Reason == localModels.AccessReasonSupport &&
+			assert.ObjectsAreEqual([]string{"doc1"}, event.DocumentIDs)
+	})).Return(localModels.AuditEvent{}, nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/documents/doc1/file", nil)
+	setupAuditRouter(mockService).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRecordClientDownloads(t *testing.T) {
+	mockService := new(localMocks.MockAuditService)
+	mockService.On("Record", mock.Anything, mock.MatchedBy(func(event localModels.AuditEvent) bool {
+		return event.ActorID == "client1" &&
+			event.ActorRole == "client" &&
+			event.Reason == localModels.AccessReasonVerification &&
+			assert.ObjectsAreEqual([]string{"doc1", "doc2"}, event.DocumentIDs)
+	})).Return(localModels.AuditEvent{}, nil).Once()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	client := router.Group("", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	}, RecordClientDownloads(mockService))
+	client.GET("/download", func(c *gin.Context) {
+		NoteDocumentAccess(c, localModels.AccessReasonVerification, "doc1", "doc2")
+		c.Status(http.StatusOK)
+	})
+	// Requests that hand out no files are left out of the log
+	client.GET("/refused", func(c *gin.Context) {
+		c.Status(http.StatusBadRequest)
+	})
+
+	for _, path := range []string{"/download", "/refused"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestRecordClientActions(t *testing.T) {
+	mockService := new(localMocks.MockAuditService)
+	mockService.On("Record", mock.Anything, mock.MatchedBy(func(event localModels.AuditEvent) bool {
+		return event.ActorID == "client1" &&
+			event.ActorRole == "client" &&
+			event.Route == "/applicants/:id/restore" &&
+			event.Path == "/applicants/app1/restore" &&
+			event.Status == http.StatusGone
+	})).Return(localModels.AuditEvent{}, nil).Once()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.POST("/applicants/:id/restore", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+		if c.Query("as") == "support" {
+			c.Set("impersonation", middleware.Impersonation{AdminID: "admin1", Role: middleware.RoleSupport, ClientID: "client1"})
+		}
+	}, RecordClientActions(mockService), func(c *gin.Context) {
+		c.Status(http.StatusGone)
+	})
+
+	// Refused requests are recorded too; impersonated ones are left to RecordImpersonations
+	for _, path := range []string{"/applicants/app1/restore", "/applicants/app1/restore?as=support"} {
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestRecordImpersonations(t *testing.T) {
+	mockService := new(localMocks.MockAuditService)
+	mockService.On("Record", mock.Anything, mock.MatchedBy(func(event localModels.AuditEvent) bool {
+		return event.ActorID == "admin1" &&
+			event.ActorRole == middleware.RoleSupport &&
+			event.OnBehalfOf == "client1" &&
+			event.Reason == "ticket 4411" &&
+			assert.ObjectsAreEqual([]string{"doc1"}, event.DocumentIDs)
+	})).Return(localModels.AuditEvent{}, nil).Once()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/download", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+		if c.Query("as") == "support" {
+			c.Set("impersonation", middleware.Impersonation{AdminID: "admin1", Role: middleware.RoleSupport, ClientID: "client1", Reason: "ticket 4411"})
+		}
+	}, RecordImpersonations(mockService), RecordClientDownloads(mockService), func(c *gin.Context) {
+		NoteDocumentAccess(c, localModels.AccessReasonVerification, "doc1")
+		c.Status(http.StatusOK)
+	})
+
+	// The impersonated download is recorded once, as the admin's
+	req, _ := http.NewRequest(http.MethodGet, "/download?as=support", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	mockService.AssertExpectations(t)
+
+	// The client's own download is not an impersonation
+	mockService.On("Record", mock.Anything, mock.MatchedBy(func(event localModels.AuditEvent) bool {
+		return event.ActorID == "client1" && event.OnBehalfOf == ""
+	})).Return(localModels.AuditEvent{}, nil).Once()
+	req, _ = http.NewRequest(http.MethodGet, "/download", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDocumentAccessLog(t *testing.T) {
+	accessLog := localModels.DocumentAccessLog{
+		DocumentID: "doc1",
+		Accesses:   []localModels.AuditEvent{{Seq: 4, ActorID: "client1", ActorRole: "client", DocumentIDs: []string{"doc1"}, Reason: "audit"}},
+	}
+
+	tests := []struct {
+		name               string
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{"Lists downloads", nil, http.StatusOK},
+		{"Too many downloads", services.ErrAccessLogTooLarge, http.StatusBadRequest},
+		{"Database down", errors.New("db down"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockAuditService)
+			mockService.On("DocumentAccessLog", mock.Anything, "doc1").Return(accessLog, tt.serviceErr)
+			mockService.On("Record", mock.Anything, mock.Anything).Return(localModels.AuditEvent{}, nil)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/admin/documents/doc1/access-log", nil)
+			setupAuditRouter(mockService).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.serviceErr == nil {
+				assert.Contains(t, w.Body.String(), `"reason":"audit"`)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+const (
+	// MaxExportEvents bounds a single export; longer ranges must be split
+	MaxExportEvents = 100000
+
+	// MaxAccessLogEvents bounds the downloads returned for one document
+	MaxAccessLogEvents = 10000
+
+	// appendAttempts bounds how often an append is retried after losing a race for the next sequence number
+	appendAttempts = 10
+
+	// exportSettle keeps exports clear of appends that may still be in flight, so an
+	// event cannot land inside a range after it was exported
+	exportSettle = 30 * time.Second
+)
+
+var (
+	// ErrContention is returned when an event could not be appended because other appends kept winning
+	ErrContention = errors.New("audit log is busy, event not recorded")
+
+	// ErrExportTooLarge is returned when a range holds more than MaxExportEvents events
+	ErrExportTooLarge = apperr.BadRequest("export_too_large", "time range holds too many audit events, export a shorter range")
+
+	// ErrAccessLogTooLarge is returned when a document has been downloaded more than MaxAccessLogEvents times
+	ErrAccessLogTooLarge = apperr.BadRequest("access_log_too_large", "document has too many recorded downloads, export the audit log instead")
+)
+
+// AuditServiceImpl keeps a hash-chained log of staff actions and document downloads
+type AuditServiceImpl struct {
+	CollectionName string
+}
+
+var (
+	instance AuditServiceImpl
+	once     sync.Once
+)
+
+func GetAuditServiceImpl() AuditServiceImpl {
+	once.Do(func() {
+		instance = AuditServiceImpl{
+			CollectionName: localConstants.CollectionAuditLog,
+		}
+	})
+	return instance
+}
+
+// Record appends an event to the chain. Sequence, time and hashes are assigned here.
+// Sequence numbers are unique in the collection, so concurrent appends cannot fork
+// the chain: the loser re-reads the head and tries again.
+func (s *AuditServiceImpl) Record(ctx context.Context, event localModels.AuditEvent) (localModels.AuditEvent, error) {
+	collection := common.GetCollection(s.CollectionName)
+	event.EventID = ids.New()
+
+	for attempt := 0; attempt < appendAttempts; attempt++ {
+		head, err := s.head(ctx, collection)
+		if err != nil {
+			return localModels.AuditEvent{}, err
+		}
+
+		event.Seq = head.Seq + 1
+		event.PrevHash = head.Hash
+		// Times never go backwards along the chain, which keeps each time range a contiguous run of events
+		event.At = timestamp.Now()
+		if event.At.Before(head.At) {
+			event.At = head.At
+		}
+		event.Hash = HashEvent(event)
+
+		err = mongoretry.Write(ctx, "audit_append", func(ctx context.Context) error {
+			_, err := collection.InsertOne(ctx, event)
+			return err
+		})
+		if err == nil {
+			return event, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			zaplogger.GetLogger().Error("Error appending audit event", zap.Error(err), zap.Int64("seq", event.Seq))
+			return localModels.AuditEvent{}, err
+		}
+
+		// A retried insert may have been applied the first time round
+		var existing localModels.AuditEvent
+		if err := collection.FindOne(ctx, bson.M{"seq": event.Seq}).Decode(&existing); err == nil && existing.EventID == event.EventID {
+			return existing, nil
+		}
+	}
+	return localModels.AuditEvent{}, ErrContention
+}
+
+// head returns the latest event, or a zero event carrying GenesisHash when the chain is empty
+func (s *AuditServiceImpl) head(ctx context.Context, collection common.CollectionInterface) (localModels.AuditEvent, error) {
+	var head localModels.AuditEvent
+	err := collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&head)
+	if err == mongo.ErrNoDocuments {
+		return localModels.AuditEvent{Hash: GenesisHash}, nil
+	}
+	if err != nil {
+		return localModels.AuditEvent{}, fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+	return head, nil
+}
+
+// Export returns the events in [from, to) with a proof that none were left out. The
+// end of the range is capped shortly before now so appends still in flight cannot
+// land inside it. The export is verified before it is returned, so a tampered chain
+// is reported rather than handed to an auditor.
+func (s *AuditServiceImpl) Export(ctx context.Context, from, to time.Time) (localModels.AuditExport, error) {
+	collection := common.GetCollection(s.CollectionName)
+	now := timestamp.Now()
+	if settled := now.Add(-exportSettle); to.After(settled) {
+		to = settled
+	}
+	from, to = timestamp.UTC(from), timestamp.UTC(to)
+
+	// Read the head first: it can only move forward, so it is at or after the end of the range
+	head, err := s.head(ctx, collection)
+	if err != nil {
+		return localModels.AuditExport{}, err
+	}
+
+	proof := localModels.AuditProof{
+		Algorithm:   Algorithm,
+		From:        from,
+		To:          to,
+		StartHash:   GenesisHash,
+		HeadSeq:     head.Seq,
+		HeadHash:    head.Hash,
+		GeneratedAt: now,
+	}
+
+	before, err := s.boundary(ctx, collection, bson.M{"at": bson.M{"$lt": from}, "seq": bson.M{"$lte": head.Seq}}, -1)
+	if err != nil {
+		return localModels.AuditExport{}, err
+	}
+	if before != nil {
+		proof.Before = before
+		proof.StartHash = before.Hash
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(MaxExportEvents + 1)
+	cursor, err := collection.Find(ctx, bson.M{"at": bson.M{"$gte": from, "$lt": to}, "seq": bson.M{"$lte": head.Seq}}, opts)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error fetching audit events from MongoDB", zap.Error(err))
+		return localModels.AuditExport{}, err
+	}
+	defer cursor.Close(ctx)
+	events := []localModels.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return localModels.AuditExport{}, err
+	}
+	if len(events) > MaxExportEvents {
+		return localModels.AuditExport{}, ErrExportTooLarge
+	}
+
+	proof.Count = len(events)
+	proof.EndHash = proof.StartHash
+	if len(events) > 0 {
+		proof.FirstSeq = events[0].Seq
+		proof.LastSeq = events[len(events)-1].Seq
+		proof.EndHash = events[len(events)-1].Hash
+	}
+
+	after, err := s.boundary(ctx, collection, bson.M{"at": bson.M{"$gte": to}, "seq": bson.M{"$lte": head.Seq}}, 1)
+	if err != nil {
+		return localModels.AuditExport{}, err
+	}
+	proof.After = after
+
+	export := localModels.AuditExport{Events: events, Proof: proof}
+	if err := VerifyExport(export); err != nil {
+		zaplogger.GetLogger().Error("Audit chain failed verification", zap.Error(err), zap.Time("from", from), zap.Time("to", to))
+		return localModels.AuditExport{}, err
+	}
+	return export, nil
+}
+
+// boundary returns the first event matching filter in the given seq order, or nil if there is none
+func (s *AuditServiceImpl) boundary(ctx context.Context, collection common.CollectionInterface, filter bson.M, order int) (*localModels.AuditEvent, error) {
+	var event localModels.AuditEvent
+	err := collection.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "seq", Value: order}})).Decode(&event)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit range boundary: %w", err)
+	}
+	return &event, nil
+}
+
+// DocumentAccessLog returns every recorded download of a document's files, oldest first.
+// Archive downloads are included for each document they held.
+func (s *AuditServiceImpl) DocumentAccessLog(ctx context.Context, documentID string) (localModels.DocumentAccessLog, error) {
+	collection := common.GetCollection(s.CollectionName)
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(MaxAccessLogEvents + 1)
+	cursor, err := collection.Find(ctx, bson.M{"document_ids": documentID}, opts)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error fetching document accesses from MongoDB", zap.Error(err), zap.String("documentID", documentID))
+		return localModels.DocumentAccessLog{}, err
+	}
+	defer cursor.Close(ctx)
+	accesses := []localModels.AuditEvent{}
+	if err := cursor.All(ctx, &accesses); err != nil {
+		return localModels.DocumentAccessLog{}, err
+	}
+	if len(accesses) > MaxAccessLogEvents {
+		return localModels.DocumentAccessLog{}, ErrAccessLogTooLarge
+	}
+	return localModels.DocumentAccessLog{DocumentID: documentID, Accesses: accesses}, nil
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+)
+
+// Algorithm names how event hashes are computed, so auditors can recompute them
+const Algorithm = "sha256-chain-v1"
+
+// GenesisHash is the previous hash of the first event in the chain
+var GenesisHash = strings.Repeat("0", 64)
+
+// ErrIncomplete is returned by VerifyExport when an export does not prove it holds every event in its range
+var ErrIncomplete = errors.New("audit export is incomplete or altered")
+
+// HashEvent returns the hash of an event chained to the hash of the event before it.
+// The hash covers every field except Hash itself; times are hashed in UTC to the
+// millisecond, which is what MongoDB stores. Download and impersonation fields are left out when empty,
+// so events recorded before they existed keep their hashes.
+func HashEvent(event localModels.AuditEvent) string {
+	content, _ := json.Marshal(struct {
+		Seq         int64    `json:"seq"`
+		EventID     string   `json:"event_id"`
+		At          string   `json:"at"`
+		ActorID     string   `json:"actor_id"`
+		ActorRole   string   `json:"actor_role"`
+		Method      string   `json:"method"`
+		Route       string   `json:"route"`
+		Path        string   `json:"path"`
+		Status      int      `json:"status"`
+		IP          string   `json:"ip"`
+		DocumentIDs []string `json:"document_ids,omitempty"`
+		Reason      string   `json:"reason,omitempty"`
+		OnBehalfOf  string   `json:"on_behalf_of,omitempty"`
+		PrevHash    string   `json:"prev_hash"`
+	}{
+		Seq:         event.Seq,
+		EventID:     event.EventID,
+		At:          timestamp.Format(event.At),
+		ActorID:     event.ActorID,
+		ActorRole:   event.ActorRole,
+		Method:      event.Method,
+		Route:       event.Route,
+		Path:        event.Path,
+		Status:      event.Status,
+		IP:          event.IP,
+		DocumentIDs: event.DocumentIDs,
+		Reason:      event.Reason,
+		OnBehalfOf:  event.OnBehalfOf,
+		PrevHash:    event.PrevHash,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyExport checks an export against its proof: every event hashes correctly, the
+// events chain from StartHash to EndHash without gaps, and the boundary events lie
+// outside the range. It returns an error wrapping ErrIncomplete describing the first problem.
+func VerifyExport(export localModels.AuditExport) error {
+	proof := export.Proof
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrIncomplete, fmt.Sprintf(format, args...))
+	}
+
+	if proof.Algorithm != Algorithm {
+		return fail("unknown algorithm %q", proof.Algorithm)
+	}
+	if proof.Count != len(export.Events) {
+		return fail("proof counts %d events but export holds %d", proof.Count, len(export.Events))
+	}
+
+	// The start of the range is anchored by the event before it, or the start of the chain
+	prevHash, prevSeq := GenesisHash, int64(0)
+	if proof.Before != nil {
+		if HashEvent(*proof.Before) != proof.Before.Hash {
+			return fail("event before the range does not match its hash")
+		}
+		if !proof.Before.At.Before(proof.From) {
+			return fail("event before the range is inside it")
+		}
+		prevHash, prevSeq = proof.Before.Hash, proof.Before.Seq
+	}
+	if proof.StartHash != prevHash {
+		return fail("start hash does not match the event before the range")
+	}
+
+	for _, event := range export.Events {
+		if event.Seq != prevSeq+1 {
+			return fail("expected event %d, found %d", prevSeq+1, event.Seq)
+		}
+		if event.PrevHash != prevHash {
+			return fail("event %d does not chain to the event before it", event.Seq)
+		}
+		if HashEvent(event) != event.Hash {
+			return fail("event %d does not match its hash", event.Seq)
+		}
+		if event.At.Before(proof.From) || !event.At.Before(proof.To) {
+			return fail("event %d is outside the range", event.Seq)
+		}
+		prevHash, prevSeq = event.Hash, event.Seq
+	}
+	if proof.EndHash != prevHash {
+		return fail("end hash does not match the last event in the range")
+	}
+	if len(export.Events) > 0 && (proof.FirstSeq != export.Events[0].Seq || proof.LastSeq != prevSeq) {
+		return fail("sequence bounds do not match the events")
+	}
+
+	// The end of the range is anchored by the event after it, or the chain head
+	if proof.After != nil {
+		if HashEvent(*proof.After) != proof.After.Hash {
+			return fail("event after the range does not match its hash")
+		}
+		if proof.After.Seq != prevSeq+1 || proof.After.PrevHash != prevHash {
+			return fail("event after the range does not follow the last event in it")
+		}
+		if proof.After.At.Before(proof.To) {
+			return fail("event after the range is inside it")
+		}
+	} else if proof.HeadHash != prevHash || proof.HeadSeq != prevSeq {
+		return fail("range ends before the chain head but no following event is given")
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var chainStart = time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+// buildChain returns n chained events one minute apart
+func buildChain(n int) []localModels.AuditEvent {
+	events := make([]localModels.AuditEvent, n)
+	prevHash := GenesisHash
+	for i := range events {
+		events[i] = localModels.AuditEvent{
+			Seq:      int64(i + 1),
+			EventID:  "event" + strconv.Itoa(i+1),
+			At:       chainStart.Add(time.Duration(i) * time.Minute),
+			ActorID:  "admin1",
+			Method:   "GET",
+			Route:    "/api/v1/admin/applicants/:id/decisions",
+			Path:     "/api/v1/admin/applicants/app1/decisions",
+			Status:   200,
+			PrevHash: prevHash,
+		}
+		events[i].Hash = HashEvent(events[i])
+		prevHash = events[i].Hash
+	}
+	return events
+}
+
+// exportOf builds the export Export would return for events[first:last] of chain
+func exportOf(chain []localModels.AuditEvent, first, last int, from, to time.Time) localModels.AuditExport {
+	head := chain[len(chain)-1]
+	proof := localModels.AuditProof{
+		Algorithm: Algorithm,
+		From:      from,
+		To:        to,
+		Count:     last - first,
+		StartHash: GenesisHash,
+		HeadSeq:   head.Seq,
+		HeadHash:  head.Hash,
+	}
+	if first > 0 {
+		before := chain[first-1]
+		proof.Before = &before
+		proof.StartHash = before.Hash
+	}
+	proof.EndHash = proof.StartHash
+	if last > first {
+		proof.FirstSeq = chain[first].Seq
+		proof.LastSeq = chain[last-1].Seq
+		proof.EndHash = chain[last-1].Hash
+	}
+	if last < len(chain) {
+		after := chain[last]
+		proof.After = &after
+	}
+	events := append([]localModels.AuditEvent{}, chain[first:last]...)
+	return localModels.AuditExport{Events: events, Proof: proof}
+}
+
+func TestVerifyExportAcceptsCompleteExports(t *testing.T) {
+	chain := buildChain(6)
+	minute := func(n int) time.Time { return chainStart.Add(time.Duration(n) * time.Minute) }
+
+	assert.NoError(t, VerifyExport(exportOf(chain, 2, 4, minute(2), minute(4))), "middle of the chain")
+	assert.NoError(t, VerifyExport(exportOf(chain, 0, 3, minute(-5), minute(3))), "start of the chain")
+	assert.NoError(t, VerifyExport(exportOf(chain, 4, 6, minute(4), minute(60))), "up to the head")
+	assert.NoError(t, VerifyExport(exportOf(chain, 3, 3, minute(2).Add(time.Second), minute(3))), "empty range")
+}
+
+func TestVerifyExportDetectsOmissions(t *testing.T) {
+	chain := buildChain(6)
+	minute := func(n int) time.Time { return chainStart.Add(time.Duration(n) * time.Minute) }
+
+	tests := []struct {
+		name   string
+		modify func(export *localModels.AuditExport)
+	}{
+		{"Event dropped from the middle", func(export *localModels.AuditExport) {
+			export.Events = append(export.Events[:1], export.Events[2:]...)
+			export.Proof.Count--
+		}},
+		{"Last event dropped", func(export *localModels.AuditExport) {
+			export.Events = export.Events[:len(export.Events)-1]
+			export.Proof.Count--
+			export.Proof.EndHash = export.Events[len(export.Events)-1].Hash
+			export.Proof.LastSeq--
+		}},
+		{"Event edited", func(export *localModels.AuditExport) {
+			export.Events[1].ActorID = "someone_else"
+		}},
+		{"Event edited and rehashed", func(export *localModels.AuditExport) {
+			export.Events[1].ActorID = "someone_else"
+			export.Events[1].Hash = HashEvent(export.Events[1])
+		}},
+		{"Count does not match", func(export *localModels.AuditExport) {
+			export.Proof.Count++
+		}},
+		{"Following event withheld", func(export *localModels.AuditExport) {
+			export.Proof.After = nil
+		}},
+		{"Range start moved past the previous event", func(export *localModels.AuditExport) {
+			export.Proof.From = minute(0)
+		}},
+		{"Range end moved past the following event", func(export *localModels.AuditExport) {
+			export.Proof.To = minute(5)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export := exportOf(chain, 1, 4, minute(1), minute(4))
+			tt.modify(&export)
+			err := VerifyExport(export)
+			assert.True(t, errors.Is(err, ErrIncomplete), "got %v", err)
+		})
+	}
+}
+
+func TestHashEventDownloadFields(t *testing.T) {
+	// Events without download fields hash as they did before those fields existed
+	event := buildChain(1)[0]
+	assert.Equal(t, "9a62798fa4a20a92750cbf56774d4bbe6112f66e48fe814a5b86c604f61011e9", event.Hash)
+
+	event.DocumentIDs = []string{"doc1"}
+	event.Reason = localModels.AccessReasonAudit
+	withReason := HashEvent(event)
+	assert.NotEqual(t, event.Hash, withReason)
+
+	event.Reason = localModels.AccessReasonSupport
+	withSupport := HashEvent(event)
+	assert.NotEqual(t, withReason, withSupport)
+
+	event.OnBehalfOf = "client1"
+	assert.NotEqual(t, withSupport, HashEvent(event))
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+// Admin roles recognised by the admin endpoints
+const (
+	RoleReviewer = "reviewer"
+	RoleAdmin    = "admin"
+	RoleSupport  = "support" // May act as a client to reproduce issues it reports
+)
+
+// adminIdentity is the staff member an admin key belongs to
+type adminIdentity struct {
+	AdminID string `bson:"admin_id"`
+	Role    string `bson:"role"`
+}
+
+// findAdmin looks up the active staff member an admin key belongs to
+func findAdmin(ctx context.Context, collection common.CollectionInterface, adminKey string) (adminIdentity, error) {
+	// Hash the provided admin key and look up the staff member it belongs to
+	hashedKey := utils.HashAPIKey(adminKey)
+	var admin adminIdentity
+	err := collection.FindOne(ctx, map[string]interface{}{
+		"key_hash":   hashedKey,
+		"revoked":    false,
+		"deleted_at": nil,
+	}).Decode(&admin)
+	return admin, err
+}
+
+// AdminAuthMiddleware authenticates internal staff using an admin API key
+func AdminAuthMiddleware(collection common.CollectionInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminKey := c.GetHeader("X-Admin-Key")
+		if adminKey == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin key is missing"})
+			c.Abort()
+			return
+		}
+
+		admin, err := findAdmin(context.Background(), collection, adminKey)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or inactive admin key"})
+			c.Abort()
+			return
+		}
+
+		c.Set("admin_id", admin.AdminID)
+		c.Set("admin_role", admin.Role)
+		c.Next()
+	}
+}
+
+// RequireAdminRole rejects admin requests whose role is not in the allowed list
+func RequireAdminRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("admin_role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient admin role"})
+		c.Abort()
+	}
+}
+
+// GetAdminIDFromContext returns the authenticated admin ID set by AdminAuthMiddleware
+func GetAdminIDFromContext(c *gin.Context) (string, error) {
+	adminID := c.GetString("admin_id")
+	if adminID == "" {
+		return "", fmt.Errorf("admin ID not found in context")
+	}
+	return adminID, nil
+}
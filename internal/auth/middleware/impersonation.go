@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// Headers support staff send, with their admin key, to call the client API as a client
+const (
+	ImpersonateHeader         = "X-Impersonate-Client"
+	ImpersonationReasonHeader = "X-Impersonation-Reason"
+)
+
+const impersonationKey = "impersonation"
+
+// Impersonation is a support admin acting as a client
+type Impersonation struct {
+	AdminID  string // Who is really making the request
+	Role     string
+	ClientID string // Whose data the request sees and changes
+	Reason   string // Why support needs to act as the client
+}
+
+// Impersonate lets support staff call client routes as one of the clients, to reproduce
+// an issue it reports. Requests naming a client in X-Impersonate-Client must carry the
+// admin key of a support admin and a reason; the client becomes the effective client_id
+// and both identities are logged once the request is handled. Every other request is
+// handed to fallback, such as APIKeyAuthMiddleware.
+func Impersonate(admins, secrets common.CollectionInterface, fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID := c.GetHeader(ImpersonateHeader)
+		if clientID == "" {
+			fallback(c)
+			return
+		}
+
+		adminKey := c.GetHeader("X-Admin-Key")
+		if adminKey == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Admin key is missing"})
+			return
+		}
+		admin, err := findAdmin(c.Request.Context(), admins, adminKey)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or inactive admin key"})
+			return
+		}
+		if admin.Role != RoleSupport {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient admin role"})
+			return
+		}
+		reason := c.GetHeader(ImpersonationReasonHeader)
+		if reason == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "impersonation reason is required"})
+			return
+		}
+		found, err := hasActiveKey(c.Request.Context(), secrets, clientID)
+		if err != nil {
+			zaplogger.GetLogger().Error("Error looking up impersonated client", zap.Error(err), zap.String("clientID", clientID))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not look up client"})
+			return
+		}
+		if !found {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "client not found"})
+			return
+		}
+
+		impersonation := Impersonation{AdminID: admin.AdminID, Role: admin.Role, ClientID: clientID, Reason: reason}
+		c.Set("client_id", clientID)
+		c.Set(impersonationKey, impersonation)
+		c.Next()
+
+		zaplogger.GetLogger().Info("Impersonated request",
+			zap.String("adminID", impersonation.AdminID),
+			zap.String("clientID", impersonation.ClientID),
+			zap.String("reason", impersonation.Reason),
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", c.Writer.Status()),
+		)
+	}
+}
+
+// GetImpersonationFromContext returns the support admin acting as the client, if the
+// request is impersonated
+func GetImpersonationFromContext(c *gin.Context) (Impersonation, bool) {
+	if value, ok := c.Get(impersonationKey); ok {
+		return value.(Impersonation), true
+	}
+	return Impersonation{}, false
+}
+
+// hasActiveKey reports whether a client has an API key that is neither revoked nor
+// deleted, so only clients that could make the request themselves are impersonated
+func hasActiveKey(ctx context.Context, secrets common.CollectionInterface, clientID string) (bool, error) {
+	var secret struct {
+		ClientID string `bson:"client_id"`
+	}
+	err := secrets.FindOne(ctx, map[string]interface{}{
+		"client_id":  clientID,
+		"revoked":    false,
+		"deleted_at": nil,
+	}).Decode(&secret)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
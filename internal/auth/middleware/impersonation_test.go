@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeCollection answers every FindOne with the same document, or with no document when it has none
+type fakeCollection struct {
+	common.CollectionInterface
+	found interface{}
+}
+
+func (f *fakeCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	if f.found == nil {
+		return mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(f.found, nil, nil)
+}
+
+func TestImpersonate(t *testing.T) {
+	support := &fakeCollection{found: bson.M{"admin_id": "admin1", "role": RoleSupport}}
+	reviewer := &fakeCollection{found: bson.M{"admin_id": "admin2", "role": RoleReviewer}}
+	client := &fakeCollection{found: bson.M{"client_id": "client1"}}
+	noClient := &fakeCollection{}
+
+	tests := []struct {
+		name       string
+		admins     *fakeCollection
+		secrets    *fakeCollection
+		headers    map[string]string
+		wantStatus int
+		wantClient string
+	}{
+		{
+			name: "Client request", admins: support, secrets: client,
+			wantStatus: http.StatusOK, wantClient: "from-api-key",
+		},
+		{
+			name: "Support acting as a client", admins: support, secrets: client,
+			headers:    map[string]string{"X-Admin-Key": "key", ImpersonateHeader: "client1", ImpersonationReasonHeader: "ticket 4411"},
+			wantStatus: http.StatusOK, wantClient: "client1",
+		},
+		{
+			name: "Without an admin key", admins: support, secrets: client,
+			headers:    map[string]string{ImpersonateHeader: "client1", ImpersonationReasonHeader: "ticket 4411"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Reviewer", admins: reviewer, secrets: client,
+			headers:    map[string]string{"X-Admin-Key": "key", ImpersonateHeader: "client1", ImpersonationReasonHeader: "ticket 4411"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Without a reason", admins: support, secrets: client,
+			headers:    map[string]string{"X-Admin-Key": "key", ImpersonateHeader: "client1"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Unknown client", admins: support, secrets: noClient,
+			headers:    map[string]string{"X-Admin-Key": "key", ImpersonateHeader: "client9", ImpersonationReasonHeader: "ticket 4411"},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := func(c *gin.Context) {
+				c.Set("client_id", "from-api-key")
+				c.Next()
+			}
+			var seenClient string
+			var seenImpersonation Impersonation
+			router := gin.New()
+			router.GET("/applicants", Impersonate(tt.admins, tt.secrets, fallback), func(c *gin.Context) {
+				seenClient = c.GetString("client_id")
+				seenImpersonation, _ = GetImpersonationFromContext(c)
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/applicants", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantClient, seenClient)
+			if tt.wantClient == "client1" {
+				assert.Equal(t, Impersonation{AdminID: "admin1", Role: RoleSupport, ClientID: "client1", Reason: "ticket 4411"}, seenImpersonation)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package authguard
+
+import (
+	"context"
+	"fmt"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoKeyCountries keeps the countries a key was used from on its client secret record
+type MongoKeyCountries struct {
+	CollectionName string
+}
+
+// NewMongoKeyCountries stores countries in the client secrets collection
+func NewMongoKeyCountries() *MongoKeyCountries {
+	return &MongoKeyCountries{CollectionName: localConstants.CollectionClientSecrets}
+}
+
+func (m *MongoKeyCountries) Add(ctx context.Context, keyHash, country string) ([]string, error) {
+	var before struct {
+		SeenCountries []string `bson:"seen_countries"`
+	}
+	err := common.GetCollection(m.CollectionName).FindOneAndUpdate(ctx,
+		bson.M{"client_secret_hash": keyHash},
+		bson.M{"$addToSet": bson.M{"seen_countries": country}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before).SetProjection(bson.M{"seen_countries": 1}),
+	).Decode(&before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record country of API key: %w", err)
+	}
+	return before.SeenCountries, nil
+}
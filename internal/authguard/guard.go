@@ -0,0 +1,203 @@
+// Package authguard slows down and then refuses callers that keep failing to
+// authenticate, and raises security alerts when an API key is used from a country
+// it has not been used from before or at an unusual rate. Failure counts and rates
+// are kept per instance, as with opsmetrics.
+package authguard
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/geoip"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+)
+
+const (
+	// baseDelay is the delay once a source reaches DelayAfter failures, doubled with each further failure
+	baseDelay = 250 * time.Millisecond
+	// keyPrefixLength is how much of an API key identifies it in failure counts and alerts,
+	// enough to tell keys apart without revealing them
+	keyPrefixLength = 12
+	// maxSources bounds the sources remembered before expired ones are pruned
+	maxSources = 10000
+)
+
+// Guard tracks authentication failures by IP and API key prefix, and watches how
+// successfully authenticated keys are used
+type Guard struct {
+	settings config.AuthGuardSettings
+	now      func() time.Time
+	sleep    func(c *gin.Context, d time.Duration)
+
+	mu      sync.Mutex
+	sources map[string]*source
+
+	misuse *misuseDetector
+}
+
+// source is an IP or key prefix and its recent failures
+type source struct {
+	failures    int
+	firstFailed time.Time
+	bannedUntil time.Time
+}
+
+// New creates a Guard. A nil locator disables new-country alerts, and nil countries
+// or alerts disable both kinds of alert.
+func New(settings config.AuthGuardSettings, locator geoip.Locator, countries KeyCountries, alerts interfaces.WebhookService) *Guard {
+	g := &Guard{
+		settings: settings,
+		now:      time.Now,
+		sleep:    wait,
+		sources:  make(map[string]*source),
+	}
+	if alerts != nil {
+		g.misuse = newMisuseDetector(settings, locator, countries, alerts)
+	}
+	return g
+}
+
+// Middleware refuses banned callers with 429, delays callers with repeated failures,
+// and records the outcome of authentication once the request has been handled
+func (g *Guard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := sourceKeys(c)
+		if until, banned := g.banned(keys); banned {
+			retryAfter := math.Ceil(until.Sub(g.now()).Seconds())
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed authentication attempts; try again later"})
+			return
+		}
+		if d := g.delay(keys); d > 0 {
+			g.sleep(c, d)
+		}
+
+		c.Next()
+
+		switch c.Writer.Status() {
+		case http.StatusUnauthorized:
+			g.fail(keys)
+		default:
+			if clientID := c.GetString("client_id"); clientID != "" {
+				g.succeed(keys)
+				if apiKey := c.GetHeader("X-API-Key"); apiKey != "" && g.misuse != nil {
+					g.misuse.observe(c, clientID, apiKey)
+				}
+			}
+		}
+	}
+}
+
+// sourceKeys identifies the caller by IP and, when one was sent, by API key prefix
+func sourceKeys(c *gin.Context) []string {
+	keys := []string{"ip:" + c.ClientIP()}
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		keys = append(keys, "key:"+keyPrefix(apiKey))
+	}
+	return keys
+}
+
+func keyPrefix(apiKey string) string {
+	if len(apiKey) > keyPrefixLength {
+		return apiKey[:keyPrefixLength]
+	}
+	return apiKey
+}
+
+// banned reports whether any of the sources is banned, and until when
+func (g *Guard) banned(keys []string) (time.Time, bool) {
+	now := g.now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var until time.Time
+	for _, key := range keys {
+		if s := g.sources[key]; s != nil && s.bannedUntil.After(now) && s.bannedUntil.After(until) {
+			until = s.bannedUntil
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// delay is how long to hold back a request from the sources, by the most failures among them
+func (g *Guard) delay(keys []string) time.Duration {
+	if g.settings.DelayAfter <= 0 {
+		return 0
+	}
+	now := g.now()
+	g.mu.Lock()
+	failures := 0
+	for _, key := range keys {
+		if s := g.sources[key]; s != nil && now.Sub(s.firstFailed) < g.settings.FailureWindow && s.failures > failures {
+			failures = s.failures
+		}
+	}
+	g.mu.Unlock()
+
+	if failures < g.settings.DelayAfter {
+		return 0
+	}
+	d := baseDelay
+	for i := g.settings.DelayAfter; i < failures && (g.settings.MaxDelay <= 0 || d < g.settings.MaxDelay); i++ {
+		d *= 2
+	}
+	if g.settings.MaxDelay > 0 && d > g.settings.MaxDelay {
+		d = g.settings.MaxDelay
+	}
+	return d
+}
+
+// fail counts a failure against each source, banning those that reach BanAfter
+func (g *Guard) fail(keys []string) {
+	now := g.now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.sources) >= maxSources {
+		g.prune(now)
+	}
+	for _, key := range keys {
+		s := g.sources[key]
+		if s == nil || now.Sub(s.firstFailed) >= g.settings.FailureWindow {
+			s = &source{firstFailed: now}
+			g.sources[key] = s
+		}
+		s.failures++
+		if g.settings.BanAfter > 0 && s.failures >= g.settings.BanAfter {
+			s.bannedUntil = now.Add(g.settings.BanDuration)
+		}
+	}
+}
+
+// succeed forgets the failures of sources that went on to authenticate
+func (g *Guard) succeed(keys []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range keys {
+		if s := g.sources[key]; s != nil && s.bannedUntil.IsZero() {
+			delete(g.sources, key)
+		}
+	}
+}
+
+// prune forgets sources whose failures and ban have expired
+func (g *Guard) prune(now time.Time) {
+	for key, s := range g.sources {
+		if now.Sub(s.firstFailed) >= g.settings.FailureWindow && !s.bannedUntil.After(now) {
+			delete(g.sources, key)
+		}
+	}
+}
+
+// wait holds back a request, giving up early if the caller goes away
+func wait(c *gin.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-c.Request.Context().Done():
+	}
+}
@@ -0,0 +1,147 @@
+package authguard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/geoip"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testSettings = config.AuthGuardSettings{
+	FailureWindow: 15 * time.Minute,
+	DelayAfter:    2,
+	MaxDelay:      time.Second,
+	BanAfter:      4,
+	BanDuration:   10 * time.Minute,
+	RateFloor:     5,
+	RateFactor:    10,
+}
+
+// fakeCountries keeps key countries in memory
+type fakeCountries map[string][]string
+
+func (f fakeCountries) Add(ctx context.Context, keyHash, country string) ([]string, error) {
+	before := f[keyHash]
+	f[keyHash] = append(append([]string(nil), before...), country)
+	return before, nil
+}
+
+// setupRouter authenticates requests carrying the API key "vk_good"
+func setupRouter(guard *Guard) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(guard.Middleware())
+	router.GET("/", func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "vk_good" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or inactive API key"})
+			return
+		}
+		c.Set("client_id", "client-1")
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func get(router *gin.Engine, apiKey, ip string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", apiKey)
+	req.RemoteAddr = ip + ":4321"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGuardDelaysThenBansRepeatedFailures(t *testing.T) {
+	guard := New(testSettings, nil, nil, nil)
+	now := time.Now()
+	guard.now = func() time.Time { return now }
+	var delays []time.Duration
+	guard.sleep = func(c *gin.Context, d time.Duration) { delays = append(delays, d) }
+	router := setupRouter(guard)
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusUnauthorized, get(router, "vk_bad", "8.8.8.8").Code)
+	}
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 500 * time.Millisecond}, delays)
+
+	// Both the IP and the key prefix are banned, even with a correct key
+	w := get(router, "vk_good", "8.8.8.8")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "600", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, get(router, "vk_bad", "1.1.1.1").Code)
+	assert.Equal(t, http.StatusOK, get(router, "vk_good", "1.1.1.1").Code)
+
+	now = now.Add(testSettings.BanDuration)
+	assert.Equal(t, http.StatusOK, get(router, "vk_good", "8.8.8.8").Code)
+}
+
+func TestGuardForgetsFailuresAfterSuccess(t *testing.T) {
+	guard := New(testSettings, nil, nil, nil)
+	delayed := false
+	guard.sleep = func(c *gin.Context, d time.Duration) { delayed = true }
+	router := setupRouter(guard)
+
+	// Distinct keys, so only the IP's failures accumulate
+	get(router, "vk_bad_00001", "8.8.8.8")
+	get(router, "vk_bad_00002", "8.8.8.8")
+	assert.Equal(t, http.StatusOK, get(router, "vk_good", "8.8.8.8").Code)
+	assert.True(t, delayed)
+
+	delayed = false
+	get(router, "vk_bad_00003", "8.8.8.8")
+	get(router, "vk_bad_00004", "8.8.8.8")
+	assert.False(t, delayed)
+}
+
+func TestGuardAlertsOnNewCountry(t *testing.T) {
+	locator, err := geoip.ParseCSV(strings.NewReader("8.8.8.0/24,USA\n1.1.1.0/24,AUS\n"))
+	assert.NoError(t, err)
+	webhooks := new(mocks.MockWebhookService)
+	webhooks.On("Emit", mock.Anything, "client-1", localModels.WebhookSecurityAlert, mock.MatchedBy(func(alert localModels.SecurityAlertData) bool {
+		return alert.Kind == localModels.SecurityAlertNewCountry && alert.Country == "AUS" &&
+			assert.ObjectsAreEqual([]string{"USA"}, alert.KnownCountries) && alert.KeyPrefix == "vk_good"
+	})).Return(nil).Once()
+	router := setupRouter(New(testSettings, locator, fakeCountries{}, webhooks))
+
+	get(router, "vk_good", "8.8.8.8")
+	get(router, "vk_good", "8.8.8.8")
+	get(router, "vk_good", "1.1.1.1")
+	get(router, "vk_good", "1.1.1.1")
+
+	webhooks.AssertExpectations(t)
+}
+
+func TestGuardAlertsOnRateAnomaly(t *testing.T) {
+	webhooks := new(mocks.MockWebhookService)
+	webhooks.On("Emit", mock.Anything, "client-1", localModels.WebhookSecurityAlert, mock.MatchedBy(func(alert localModels.SecurityAlertData) bool {
+		return alert.Kind == localModels.SecurityAlertRateAnomaly && alert.RequestsPerMinute == 6
+	})).Return(nil).Once()
+	settings := testSettings
+	settings.RateFactor = 3
+	guard := New(settings, nil, nil, webhooks)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	guard.misuse.now = func() time.Time { return now }
+	router := setupRouter(guard)
+
+	// A steady request a minute sets a baseline of one, so the sixth request in a
+	// minute passes both the floor and three times the baseline
+	for i := 0; i < 59; i++ {
+		get(router, "vk_good", "8.8.8.8")
+		now = now.Add(time.Minute)
+	}
+	for i := 0; i < 10; i++ {
+		get(router, "vk_good", "8.8.8.8")
+	}
+
+	webhooks.AssertExpectations(t)
+}
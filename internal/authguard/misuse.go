@@ -0,0 +1,179 @@
+package authguard
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/geoip"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+const (
+	// rateMinutes is how many minutes of requests are kept per key, the last being the current one
+	rateMinutes = 60
+	// alertInterval is the least time between two alerts of the same kind about a key
+	alertInterval = time.Hour
+)
+
+// KeyCountries remembers the countries each API key has been used from
+type KeyCountries interface {
+	// Add records that the key with the given hash was used from a country, returning
+	// the countries it had been used from before
+	Add(ctx context.Context, keyHash, country string) ([]string, error)
+}
+
+// misuseDetector watches how each authenticated key is used
+type misuseDetector struct {
+	settings  config.AuthGuardSettings
+	locator   geoip.Locator
+	countries KeyCountries
+	alerts    interfaces.WebhookService
+	now       func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*keyUsage
+}
+
+// keyUsage is a key's requests per minute over the last hour, and what it is known to have done
+type keyUsage struct {
+	minutes   [rateMinutes]minuteCount
+	countries map[string]bool // Countries already recorded, to spare a lookup per request
+	alerted   map[string]time.Time
+}
+
+type minuteCount struct {
+	minute int64
+	count  int64
+}
+
+func newMisuseDetector(settings config.AuthGuardSettings, locator geoip.Locator, countries KeyCountries, alerts interfaces.WebhookService) *misuseDetector {
+	return &misuseDetector{
+		settings:  settings,
+		locator:   locator,
+		countries: countries,
+		alerts:    alerts,
+		now:       time.Now,
+		keys:      make(map[string]*keyUsage),
+	}
+}
+
+// observe records a request authenticated with an API key, alerting the client if
+// it looks like the key is being misused
+func (d *misuseDetector) observe(c *gin.Context, clientID, apiKey string) {
+	keyHash := utils.HashAPIKey(apiKey)
+	now := d.now()
+	alert := localModels.SecurityAlertData{KeyPrefix: keyPrefix(apiKey), IP: c.ClientIP(), At: now.UTC()}
+
+	if perMinute, baseline, anomalous := d.countRequest(keyHash, now); anomalous {
+		alert.Kind = localModels.SecurityAlertRateAnomaly
+		alert.RequestsPerMinute = perMinute
+		alert.BaselinePerMinute = baseline
+		d.raise(c.Request.Context(), clientID, alert)
+	}
+
+	if d.locator == nil || d.countries == nil {
+		return
+	}
+	ip, err := netip.ParseAddr(alert.IP)
+	if err != nil || !geoip.IsPublic(ip) {
+		return
+	}
+	country, ok := d.locator.Country(ip)
+	if !ok || d.knownCountry(keyHash, country) {
+		return
+	}
+	known, err := d.countries.Add(c.Request.Context(), keyHash, country)
+	if err != nil {
+		zaplogger.GetLogger().Warn("Failed to record API key country", zap.Error(err))
+		return
+	}
+	d.rememberCountries(keyHash, append(known, country))
+	// The first country a key is used from is its home rather than a surprise
+	if len(known) > 0 && !slices.Contains(known, country) {
+		alert.Kind = localModels.SecurityAlertNewCountry
+		alert.Country = country
+		alert.KnownCountries = known
+		d.raise(c.Request.Context(), clientID, alert)
+	}
+}
+
+// countRequest counts a request against the current minute, reporting whether the
+// minute's count is anomalous against the average of the rest of the hour
+func (d *misuseDetector) countRequest(keyHash string, now time.Time) (int64, float64, bool) {
+	minute := now.Unix() / 60
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	usage := d.usage(keyHash)
+	slot := &usage.minutes[minute%rateMinutes]
+	if slot.minute != minute {
+		*slot = minuteCount{minute: minute}
+	}
+	slot.count++
+
+	var total int64
+	for _, m := range usage.minutes {
+		if m.minute != minute && m.minute > minute-rateMinutes {
+			total += m.count
+		}
+	}
+	baseline := float64(total) / (rateMinutes - 1)
+	if slot.count <= d.settings.RateFloor || d.settings.RateFactor <= 0 || float64(slot.count) <= baseline*d.settings.RateFactor {
+		return slot.count, baseline, false
+	}
+	if last, ok := usage.alerted[localModels.SecurityAlertRateAnomaly]; ok && now.Sub(last) < alertInterval {
+		return slot.count, baseline, false
+	}
+	usage.alerted[localModels.SecurityAlertRateAnomaly] = now
+	return slot.count, baseline, true
+}
+
+func (d *misuseDetector) knownCountry(keyHash, country string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.usage(keyHash).countries[country]
+}
+
+func (d *misuseDetector) rememberCountries(keyHash string, countries []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	usage := d.usage(keyHash)
+	for _, country := range countries {
+		usage.countries[country] = true
+	}
+}
+
+// usage returns the key's usage, creating it if needed. d.mu must be held.
+func (d *misuseDetector) usage(keyHash string) *keyUsage {
+	usage := d.keys[keyHash]
+	if usage == nil {
+		if len(d.keys) >= maxSources {
+			d.keys = make(map[string]*keyUsage)
+		}
+		usage = &keyUsage{countries: make(map[string]bool), alerted: make(map[string]time.Time)}
+		d.keys[keyHash] = usage
+	}
+	return usage
+}
+
+// raise logs a security alert and sends it to the client's webhook endpoint
+func (d *misuseDetector) raise(ctx context.Context, clientID string, alert localModels.SecurityAlertData) {
+	logger := zaplogger.GetLogger()
+	logger.Warn("API key misuse suspected",
+		zap.String("client_id", clientID),
+		zap.String("kind", alert.Kind),
+		zap.String("key_prefix", alert.KeyPrefix),
+		zap.String("ip", alert.IP))
+	if err := d.alerts.Emit(ctx, clientID, localModels.WebhookSecurityAlert, alert); err != nil {
+		logger.Error("Failed to emit security alert", zap.Error(err))
+	}
+}
@@ -0,0 +1,131 @@
+package awsreplay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAWS stands in for the real S3 and KMS uploaders while recording
+type fakeAWS struct {
+	calls int
+}
+
+func (f *fakeAWS) UploadFile(ctx context.Context, file multipart.File, fileName string, mimeType string, kmsUploader interfaces.KMSUploader) (string, error) {
+	f.calls++
+	if fileName == "broken.pdf" {
+		return "", errors.New("access denied")
+	}
+	return "https://bucket.s3.amazonaws.com/" + fileName, nil
+}
+
+func (f *fakeAWS) DownloadFile(ctx context.Context, objectKey string) (*s3.GetObjectOutput, error) {
+	f.calls++
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("file body")), ContentType: aws.String("application/pdf")}, nil
+}
+
+func (f *fakeAWS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	f.calls++
+	return []byte("plaintext-key"), []byte("encrypted-key"), nil
+}
+
+func (f *fakeAWS) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	f.calls++
+	return append([]byte("enc:"), plaintext...), nil
+}
+
+func (f *fakeAWS) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	f.calls++
+	return encrypted[4:], nil
+}
+
+// tempFile creates a multipart.File-compatible file with the given content
+func tempFile(t *testing.T, content string) *os.File {
+	path := filepath.Join(t.TempDir(), "upload")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	// Record against the fake AWS backend
+	recorder, err := Open(ModeRecord, path)
+	assert.NoError(t, err)
+	fake := &fakeAWS{}
+	uploader := recorder.Uploader(fake)
+	kms := recorder.KMSUploader(fake)
+
+	url, err := uploader.UploadFile(ctx, tempFile(t, "passport"), "doc1.pdf", "application/pdf", kms)
+	assert.NoError(t, err)
+	_, err = uploader.UploadFile(ctx, tempFile(t, "passport"), "broken.pdf", "application/pdf", kms)
+	assert.EqualError(t, err, "access denied")
+	ciphertext, err := kms.EncryptData(ctx, []byte("1990-01-01"))
+	assert.NoError(t, err)
+	_, _, err = kms.GenerateDataKey(ctx)
+	assert.NoError(t, err)
+	_, err = uploader.DownloadFile(ctx, "doc1.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, fake.calls)
+
+	// Replay without any backend, in a different order
+	player, err := Open(ModeReplay, path)
+	assert.NoError(t, err)
+	uploader = player.Uploader(nil)
+	kms = player.KMSUploader(nil)
+
+	plaintext, encrypted, err := kms.GenerateDataKey(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "plaintext-key", string(plaintext))
+	assert.Equal(t, "encrypted-key", string(encrypted))
+
+	replayedURL, err := uploader.UploadFile(ctx, tempFile(t, "passport"), "doc1.pdf", "application/pdf", kms)
+	assert.NoError(t, err)
+	assert.Equal(t, url, replayedURL)
+
+	_, err = uploader.UploadFile(ctx, tempFile(t, "passport"), "broken.pdf", "application/pdf", kms)
+	assert.EqualError(t, err, "access denied", "errors are replayed too")
+
+	replayedCiphertext, err := kms.EncryptData(ctx, []byte("1990-01-01"))
+	assert.NoError(t, err)
+	assert.Equal(t, ciphertext, replayedCiphertext)
+
+	out, err := uploader.DownloadFile(ctx, "doc1.pdf")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(out.Body)
+	assert.Equal(t, "file body", string(body))
+	assert.Equal(t, "application/pdf", aws.ToString(out.ContentType))
+
+	// Different content, or a call made more often than recorded, has no match
+	_, err = uploader.UploadFile(ctx, tempFile(t, "other"), "doc1.pdf", "application/pdf", kms)
+	assert.ErrorIs(t, err, ErrNoInteraction)
+	_, _, err = kms.GenerateDataKey(ctx)
+	assert.ErrorIs(t, err, ErrNoInteraction)
+}
+
+func TestOpen(t *testing.T) {
+	_, err := Open(ModeReplay, filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	_, err = Open(ModeRecord, "")
+	assert.Error(t, err)
+
+	cassette, err := Open(ModeOff, "")
+	assert.NoError(t, err)
+	fake := &fakeAWS{}
+	assert.Same(t, fake, cassette.Uploader(fake), "off mode must not wrap")
+}
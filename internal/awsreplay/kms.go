@@ -0,0 +1,65 @@
+package awsreplay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+)
+
+const serviceKMS = "kms"
+
+// kmsUploader records or replays calls to a KMSUploader
+type kmsUploader struct {
+	inner    interfaces.KMSUploader
+	cassette *Cassette
+}
+
+// KMSUploader wraps inner so its calls go through the cassette. inner may be nil in replay mode.
+func (c *Cassette) KMSUploader(inner interfaces.KMSUploader) interfaces.KMSUploader {
+	if c.mode == ModeOff {
+		return inner
+	}
+	return &kmsUploader{inner: inner, cassette: c}
+}
+
+func (k *kmsUploader) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	output, err := k.cassette.call(serviceKMS, "GenerateDataKey", "", func() ([][]byte, error) {
+		plaintext, encrypted, err := k.inner.GenerateDataKey(ctx)
+		return [][]byte{plaintext, encrypted}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(output) != 2 {
+		return nil, nil, fmt.Errorf("malformed GenerateDataKey recording")
+	}
+	return output[0], output[1], nil
+}
+
+func (k *kmsUploader) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return k.single("EncryptData", plaintext, func() ([]byte, error) {
+		return k.inner.EncryptData(ctx, plaintext)
+	})
+}
+
+func (k *kmsUploader) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	return k.single("DecryptData", encrypted, func() ([]byte, error) {
+		return k.inner.DecryptData(ctx, encrypted)
+	})
+}
+
+// single handles operations that take and return one byte slice
+func (k *kmsUploader) single(operation string, input []byte, do func() ([]byte, error)) ([]byte, error) {
+	output, err := k.cassette.call(serviceKMS, operation, hashKey(input), func() ([][]byte, error) {
+		out, err := do()
+		return [][]byte{out}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output) != 1 {
+		return nil, fmt.Errorf("malformed %s recording", operation)
+	}
+	return output[0], nil
+}
@@ -0,0 +1,80 @@
+package awsreplay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+)
+
+const serviceS3 = "s3"
+
+// uploader records or replays calls to an S3 Uploader
+type uploader struct {
+	inner    interfaces.Uploader
+	cassette *Cassette
+}
+
+// Uploader wraps inner so its calls go through the cassette. inner may be nil in replay mode.
+func (c *Cassette) Uploader(inner interfaces.Uploader) interfaces.Uploader {
+	if c.mode == ModeOff {
+		return inner
+	}
+	return &uploader{inner: inner, cassette: c}
+}
+
+// UploadFile is matched on the file name, MIME type and file content
+func (u *uploader) UploadFile(ctx context.Context, file multipart.File, fileName string, mimeType string, kmsUploader interfaces.KMSUploader) (string, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file for recording: %v", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %v", err)
+	}
+
+	key := hashKey([]byte(fileName), []byte(mimeType), content)
+	output, err := u.cassette.call(serviceS3, "UploadFile", key, func() ([][]byte, error) {
+		url, err := u.inner.UploadFile(ctx, file, fileName, mimeType, kmsUploader)
+		return [][]byte{[]byte(url)}, err
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(output) != 1 {
+		return "", fmt.Errorf("malformed UploadFile recording")
+	}
+	return string(output[0]), nil
+}
+
+// DownloadFile is matched on the object key. The body and content type are recorded.
+func (u *uploader) DownloadFile(ctx context.Context, objectKey string) (*s3.GetObjectOutput, error) {
+	output, err := u.cassette.call(serviceS3, "DownloadFile", objectKey, func() ([][]byte, error) {
+		out, err := u.inner.DownloadFile(ctx, objectKey)
+		if err != nil {
+			return nil, err
+		}
+		defer out.Body.Close()
+		body, err := io.ReadAll(out.Body)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{body, []byte(aws.ToString(out.ContentType))}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output) != 2 {
+		return nil, fmt.Errorf("malformed DownloadFile recording")
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(output[0])),
+		ContentType:   aws.String(string(output[1])),
+		ContentLength: aws.Int64(int64(len(output[0]))),
+	}, nil
+}
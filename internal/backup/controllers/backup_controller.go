@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBackupLimit = 20
+	maxBackupLimit     = 200
+)
+
+type snapshotRequest struct {
+	ClientID string `json:"client_id" binding:"required"`
+}
+
+// TakeSnapshot is the handler function for snapshotting a client to the backup bucket now
+func TakeSnapshot(c *gin.Context, service interfaces.BackupService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var requestBody snapshotRequest
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "client_id is required"})
+		return
+	}
+
+	backup, err := service.Snapshot(c.Request.Context(), requestBody.ClientID, adminID)
+	switch {
+	case mongoretry.RespondUnavailable(c, err):
+	case apperr.Respond(c, err):
+	case err != nil:
+		zaplogger.GetLogger().Error("Error taking client snapshot", zap.Error(err), zap.String("clientID", requestBody.ClientID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not take snapshot"})
+	default:
+		c.JSON(http.StatusCreated, backup)
+	}
+}
+
+// ListBackups is the handler function for a client's snapshots, newest first
+func ListBackups(c *gin.Context, service interfaces.BackupService) {
+	clientID := c.Query("client_id")
+	if clientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "client_id is required"})
+		return
+	}
+	limit := int64(defaultBackupLimit)
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.ParseInt(limitParam, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxBackupLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxBackupLimit)})
+			return
+		}
+		limit = parsed
+	}
+
+	backups, err := service.ListBackups(c.Request.Context(), clientID, limit)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error listing backups", zap.Error(err), zap.String("clientID", clientID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve backups"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": backups, "count": len(backups)})
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/backup/services"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupBackupRouter(mockService *localMocks.MockBackupService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("admin_id", "admin1")
+	})
+	router.GET("/backups", func(c *gin.Context) {
+		ListBackups(c, mockService)
+	})
+	router.POST("/backups", func(c *gin.Context) {
+		TakeSnapshot(c, mockService)
+	})
+	return router
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        string
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Snapshot taken", requestBody: `{"client_id": "client1"}`, expectedStatusCode: http.StatusCreated},
+		{name: "Missing client", requestBody: `{}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Unknown client", requestBody: `{"client_id": "client1"}`, serviceErr: services.ErrClientNotFound, expectedStatusCode: http.StatusNotFound},
+		{name: "No backup bucket", requestBody: `{"client_id": "client1"}`, serviceErr: services.ErrBackupsDisabled, expectedStatusCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockBackupService)
+			mockService.On("Snapshot", mock.Anything, "client1", "admin1").
+				Return(localModels.Backup{BackupID: "backup1", ClientID: "client1"}, tt.serviceErr)
+			router := setupBackupRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/backups", strings.NewReader(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusCreated {
+				assert.Contains(t, w.Body.String(), `"backup_id":"backup1"`)
+			}
+		})
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	tests := []struct {
+		name               string
+		query              string
+		limit              int64
+		expectedStatusCode int
+	}{
+		{name: "Default limit", query: "?client_id=client1", limit: 20, expectedStatusCode: http.StatusOK},
+		{name: "Custom limit", query: "?client_id=client1&limit=5", limit: 5, expectedStatusCode: http.StatusOK},
+		{name: "Missing client", query: "", expectedStatusCode: http.StatusBadRequest},
+		{name: "Limit too high", query: "?client_id=client1&limit=500", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockBackupService)
+			mockService.On("ListBackups", mock.Anything, "client1", tt.limit).
+				Return([]localModels.Backup{{BackupID: "backup1", ClientID: "client1"}}, nil)
+			router := setupBackupRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/backups"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"count":1`)
+				mockService.AssertExpectations(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrClientNotFound is returned when snapshotting a client that does not exist
+	ErrClientNotFound = apperr.NotFound("client_not_found", "client not found")
+	// ErrBackupsDisabled is returned when no backup bucket is configured
+	ErrBackupsDisabled = apperr.Unavailable("backups_disabled", "backups are not configured")
+)
+
+// Collections dumped for each client. Each is filtered on its client_id field.
+var Collections = []string{
+	localConstants.CollectionClients,
+	constants.CollectionApplicants,
+	localConstants.CollectionDocuments,
+	localConstants.CollectionAttachments,
+	localConstants.CollectionNotes,
+	localConstants.CollectionDecisions,
+	localConstants.CollectionVerificationSessions,
+	localConstants.CollectionWebhookEndpoints,
+	localConstants.CollectionWebhookEvents,
+	localConstants.CollectionUsageEvents,
+}
+
+// BackupServiceImpl writes encrypted, client-scoped snapshots to the backup bucket and
+// restores them. Uploader must write to the backup bucket, not the documents bucket.
+type BackupServiceImpl struct {
+	CollectionName string
+	Collections    []string
+	Uploader       interfaces.Uploader
+	KMSUploader    interfaces.KMSUploader
+}
+
+var (
+	instance BackupServiceImpl
+	once     sync.Once
+)
+
+func GetBackupServiceImpl() BackupServiceImpl {
+	once.Do(func() {
+		instance = BackupServiceImpl{
+			CollectionName: localConstants.CollectionBackups,
+			Collections:    Collections,
+		}
+	})
+	return instance
+}
+
+// Snapshot dumps a client's records, seals the dump with a fresh KMS data key and writes
+// it and its manifest to the backup bucket. The records are read one collection after
+// another, so a write made while the snapshot runs may or may not be in it.
+func (s *BackupServiceImpl) Snapshot(ctx context.Context, clientID, takenBy string) (localModels.Backup, error) {
+	if s.Uploader == nil {
+		return localModels.Backup{}, ErrBackupsDisabled
+	}
+	err := common.GetCollection(localConstants.CollectionClients).FindOne(ctx, bson.M{"client_id": clientID}).Err()
+	if err == mongo.ErrNoDocuments {
+		return localModels.Backup{}, ErrClientNotFound
+	}
+	if err != nil {
+		return localModels.Backup{}, fmt.Errorf("failed to look up client: %w", err)
+	}
+
+	backup := localModels.Backup{
+		BackupID:    ids.New(),
+		ClientID:    clientID,
+		TakenAt:     timestamp.Now(),
+		TakenBy:     takenBy,
+		Collections: map[string]int{},
+	}
+	dump := newDump()
+	for _, name := range s.Collections {
+		count, err := dump.addCollection(ctx, name, common.GetCollection(name), bson.M{"client_id": clientID})
+		if err != nil {
+			return localModels.Backup{}, err
+		}
+		backup.Collections[name] = count
+	}
+	archive, err := dump.close()
+	if err != nil {
+		return localModels.Backup{}, err
+	}
+
+	plaintextKey, encryptedKey, err := s.KMSUploader.GenerateDataKey(ctx)
+	if err != nil {
+		return localModels.Backup{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	sealed, err := seal(plaintextKey, archive)
+	if err != nil {
+		return localModels.Backup{}, err
+	}
+	checksum := sha256.Sum256(sealed)
+
+	prefix := "backups/" + clientID + "/" + backup.TakenAt.UTC().Format("20060102T150405Z") + "-" + backup.BackupID
+	backup.ArchiveKey, err = s.upload(ctx, prefix+"/dump.zip.enc", "application/octet-stream", sealed)
+	if err != nil {
+		return localModels.Backup{}, err
+	}
+	manifest := localModels.BackupManifest{
+		BackupID:      backup.BackupID,
+		ClientID:      clientID,
+		TakenAt:       backup.TakenAt,
+		ArchiveKey:    backup.ArchiveKey,
+		ArchiveSHA256: hex.EncodeToString(checksum[:]),
+		DataKey:       encryptedKey,
+		Collections:   backup.Collections,
+		Objects:       dump.objects,
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return localModels.Backup{}, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	backup.ManifestKey, err = s.upload(ctx, prefix+"/manifest.json", "application/json", manifestJSON)
+	if err != nil {
+		return localModels.Backup{}, err
+	}
+	backup.Objects = len(manifest.Objects)
+
+	if err := mongoretry.InsertOnce(ctx, common.GetCollection(s.CollectionName), "record_backup", bson.M{"backup_id": backup.BackupID}, backup); err != nil {
+		return localModels.Backup{}, fmt.Errorf("snapshot written to %s but not recorded: %w", backup.ManifestKey, err)
+	}
+	zaplogger.GetLogger().Info("Client snapshot taken",
+		zap.String("clientID", clientID),
+		zap.String("backupID", backup.BackupID),
+		zap.String("manifest", backup.ManifestKey),
+		zap.Int("objects", backup.Objects),
+	)
+	return backup, nil
+}
+
+// SnapshotAll snapshots every client, carrying on past clients that fail. It is run by
+// the client_backup job.
+func (s *BackupServiceImpl) SnapshotAll(ctx context.Context) error {
+	cursor, err := common.GetCollection(localConstants.CollectionClients).Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"client_id": 1}).SetSort(bson.D{{Key: "client_id", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("failed to list clients: %w", err)
+	}
+	var clients []struct {
+		ClientID string `bson:"client_id"`
+	}
+	if err := cursor.All(ctx, &clients); err != nil {
+		return fmt.Errorf("failed to decode clients: %w", err)
+	}
+
+	var errs []error
+	for _, client := range clients {
+		if _, err := s.Snapshot(ctx, client.ClientID, ""); err != nil {
+			zaplogger.GetLogger().Error("Error taking client snapshot", zap.Error(err), zap.String("clientID", client.ClientID))
+			errs = append(errs, fmt.Errorf("client %s: %w", client.ClientID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListBackups lists a client's snapshots, newest first
+func (s *BackupServiceImpl) ListBackups(ctx context.Context, clientID string, limit int64) ([]localModels.Backup, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "taken_at", Value: -1}}).SetLimit(limit)
+	cursor, err := common.GetCollection(s.CollectionName).Find(ctx, bson.M{"client_id": clientID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	backups := []localModels.Backup{}
+	if err := cursor.All(ctx, &backups); err != nil {
+		return nil, fmt.Errorf("failed to decode backups: %w", err)
+	}
+	return backups, nil
+}
+
+// upload writes content to the backup bucket and returns its object key
+func (s *BackupServiceImpl) upload(ctx context.Context, key, mimeType string, content []byte) (string, error) {
+	location, err := s.Uploader.UploadFile(ctx, memoryFile{bytes.NewReader(content)}, key, mimeType, s.KMSUploader)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return objectKey(location), nil
+}
+
+// objectKey returns the key of an object from the URL the uploader gives for it
+func objectKey(location string) string {
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Path == "" {
+		return location
+	}
+	return strings.TrimPrefix(parsed.Path, "/")
+}
+
+// memoryFile lets content held in memory be uploaded as a multipart file
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error { return nil }
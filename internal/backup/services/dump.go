@@ -0,0 +1,199 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrCorruptBackup is returned for an archive that fails its checksum, cannot be
+// decrypted or does not hold a valid dump
+var ErrCorruptBackup = errors.New("backup archive is corrupt")
+
+// Collections whose records point at files in the documents bucket, and the ID field of each
+var fileCollections = map[string]string{
+	localConstants.CollectionDocuments:   "document_id",
+	localConstants.CollectionAttachments: "attachment_id",
+}
+
+// dump is a zip archive holding one <collection>.bson file per collection, each a
+// sequence of BSON documents as mongodump writes them, so a decrypted archive can also
+// be loaded with mongorestore
+type dump struct {
+	buffer  bytes.Buffer
+	archive *zip.Writer
+	objects []localModels.BackupObject
+}
+
+func newDump() *dump {
+	d := &dump{}
+	d.archive = zip.NewWriter(&d.buffer)
+	return d
+}
+
+// addCollection writes the records of a collection matching filter, and notes the files they point at
+func (d *dump) addCollection(ctx context.Context, name string, collection common.CollectionInterface, filter bson.M) (int, error) {
+	file, err := d.archive.Create(name + ".bson")
+	if err != nil {
+		return 0, fmt.Errorf("failed to add %s to the archive: %w", name, err)
+	}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		if _, err := file.Write(cursor.Current); err != nil {
+			return count, fmt.Errorf("failed to write %s to the archive: %w", name, err)
+		}
+		if idField, ok := fileCollections[name]; ok {
+			d.objects = append(d.objects, filesOf(name, idField, cursor.Current)...)
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return count, nil
+}
+
+func (d *dump) close() ([]byte, error) {
+	if err := d.archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish the archive: %w", err)
+	}
+	return d.buffer.Bytes(), nil
+}
+
+// filesOf lists the S3 objects a record points at: its file and those of the versions it replaced
+func filesOf(collection, idField string, record bson.Raw) []localModels.BackupObject {
+	id, _ := record.Lookup(idField).StringValueOK()
+	var objects []localModels.BackupObject
+	add := func(value bson.RawValue) {
+		if location, ok := value.StringValueOK(); ok && location != "" {
+			objects = append(objects, localModels.BackupObject{Collection: collection, RecordID: id, Key: objectKey(location)})
+		}
+	}
+	add(record.Lookup("file_url"))
+	if versions, ok := record.Lookup("versions").ArrayOK(); ok {
+		values, _ := versions.Values()
+		for _, version := range values {
+			if document, ok := version.DocumentOK(); ok {
+				add(document.Lookup("file_url"))
+			}
+		}
+	}
+	return objects
+}
+
+// readDump returns the records of each collection in an archive
+func readDump(archive []byte) (map[string][]bson.Raw, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptBackup, err)
+	}
+	collections := map[string][]bson.Raw{}
+	for _, file := range reader.File {
+		name := strings.TrimSuffix(path.Base(file.Name), ".bson")
+		content, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCorruptBackup, file.Name, err)
+		}
+		records, err := readRecords(content)
+		content.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCorruptBackup, file.Name, err)
+		}
+		collections[name] = records
+	}
+	return collections, nil
+}
+
+// readRecords splits a sequence of BSON documents, each led by its length
+func readRecords(r io.Reader) ([]bson.Raw, error) {
+	records := []bson.Raw{}
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		size := int32(binary.LittleEndian.Uint32(length[:]))
+		if size < 5 {
+			return nil, fmt.Errorf("invalid document length %d", size)
+		}
+		record := make([]byte, size)
+		copy(record, length[:])
+		if _, err := io.ReadFull(r, record[4:]); err != nil {
+			return nil, err
+		}
+		if err := bson.Raw(record).Validate(); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// seal encrypts an archive with AES-GCM as nonce || ciphertext
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrCorruptBackup
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptBackup, err)
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// clientOf returns the client a dumped record belongs to
+func clientOf(record bson.Raw) string {
+	value := record.Lookup("client_id")
+	if value.Type != bsontype.String {
+		return ""
+	}
+	return value.StringValue()
+}
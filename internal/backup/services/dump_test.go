@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeCollection serves Find from fixed documents and records updates
+type fakeCollection struct {
+	common.CollectionInterface
+	found   []interface{}
+	filters []interface{}
+	updates []interface{}
+}
+
+func (f *fakeCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	return mongo.NewCursorFromDocuments(f.found, nil, nil)
+}
+
+func (f *fakeCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	f.filters = append(f.filters, filter)
+	f.updates = append(f.updates, update)
+	return &mongo.UpdateResult{UpsertedCount: 1}, nil
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	documents := &fakeCollection{found: []interface{}{
+		bson.D{{Key: "_id", Value: 1}, {Key: "document_id", Value: "doc1"}, {Key: "client_id", Value: "client1"},
+			{Key: "file_url", Value: "https://bucket.s3.amazonaws.com/documents/doc1-v2.jpg"},
+			{Key: "versions", Value: bson.A{bson.D{{Key: "file_url", Value: "https://bucket.s3.amazonaws.com/documents/doc1.jpg"}}}}},
+		bson.D{{Key: "_id", Value: 2}, {Key: "document_id", Value: "doc2"}, {Key: "client_id", Value: "client1"}},
+	}}
+	notes := &fakeCollection{}
+
+	dump := newDump()
+	count, err := dump.addCollection(context.Background(), localConstants.CollectionDocuments, documents, bson.M{"client_id": "client1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = dump.addCollection(context.Background(), localConstants.CollectionNotes, notes, bson.M{"client_id": "client1"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	archive, err := dump.close()
+	require.NoError(t, err)
+
+	// Files of the current and replaced versions are listed for the manifest
+	assert.Equal(t, []localModels.BackupObject{
+		{Collection: localConstants.CollectionDocuments, RecordID: "doc1", Key: "documents/doc1-v2.jpg"},
+		{Collection: localConstants.CollectionDocuments, RecordID: "doc1", Key: "documents/doc1.jpg"},
+	}, dump.objects)
+
+	collections, err := readDump(archive)
+	require.NoError(t, err)
+	assert.Len(t, collections[localConstants.CollectionDocuments], 2)
+	assert.Empty(t, collections[localConstants.CollectionNotes])
+	assert.Equal(t, "doc2", collections[localConstants.CollectionDocuments][1].Lookup("document_id").StringValue())
+}
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	sealed, err := seal(key, []byte("archive"))
+	require.NoError(t, err)
+
+	opened, err := open(key, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("archive"), opened)
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = open(key, sealed)
+	assert.ErrorIs(t, err, ErrCorruptBackup)
+}
+
+func TestCheckRecords(t *testing.T) {
+	record := func(clientID string) bson.Raw {
+		raw, _ := bson.Marshal(bson.D{{Key: "_id", Value: 1}, {Key: "client_id", Value: clientID}})
+		return raw
+	}
+	manifest := localModels.BackupManifest{ClientID: "client1", Collections: map[string]int{localConstants.CollectionNotes: 1}}
+
+	assert.NoError(t, checkRecords(localConstants.CollectionNotes, []bson.Raw{record("client1")}, manifest, Collections))
+	assert.ErrorIs(t, checkRecords(localConstants.CollectionNotes, []bson.Raw{record("client2")}, manifest, Collections), ErrCorruptBackup)
+	assert.ErrorIs(t, checkRecords(localConstants.CollectionNotes, []bson.Raw{record("client1"), record("client1")}, manifest, Collections), ErrCorruptBackup)
+	assert.ErrorIs(t, checkRecords(localConstants.CollectionClientSecrets, []bson.Raw{record("client1")}, manifest, Collections), ErrCorruptBackup)
+}
+
+func TestRestoreCollection(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{{Key: "_id", Value: "abc"}, {Key: "note_id", Value: "note1"}, {Key: "client_id", Value: "client1"}})
+	require.NoError(t, err)
+	notes := &fakeCollection{}
+
+	written, err := restoreCollection(context.Background(), notes, localConstants.CollectionNotes, []bson.Raw{raw})
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+	assert.Equal(t, bson.M{"_id": "abc"}, notes.filters[0])
+	assert.Equal(t, bson.M{"$set": bson.D{{Key: "note_id", Value: "note1"}, {Key: "client_id", Value: "client1"}}}, notes.updates[0])
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// Restore writes the records of the snapshot whose manifest is at manifestKey back to
+// their collections. Each record is written over the one with its _id, or inserted if it
+// is gone; records created since the snapshot are left alone. A dry run decrypts and
+// checks the archive without writing anything.
+//
+// Files are not copied: the manifest lists the objects in the documents bucket that the
+// records point at, to be recovered from that bucket's versions if they were lost too.
+func (s *BackupServiceImpl) Restore(ctx context.Context, manifestKey string, dryRun bool) (localModels.BackupRestore, error) {
+	if s.Uploader == nil {
+		return localModels.BackupRestore{}, ErrBackupsDisabled
+	}
+	var manifest localModels.BackupManifest
+	content, err := s.download(ctx, manifestKey)
+	if err != nil {
+		return localModels.BackupRestore{}, err
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return localModels.BackupRestore{}, fmt.Errorf("failed to decode manifest %s: %w", manifestKey, err)
+	}
+
+	sealed, err := s.download(ctx, manifest.ArchiveKey)
+	if err != nil {
+		return localModels.BackupRestore{}, err
+	}
+	checksum := sha256.Sum256(sealed)
+	if hex.EncodeToString(checksum[:]) != manifest.ArchiveSHA256 {
+		return localModels.BackupRestore{}, fmt.Errorf("%w: checksum does not match the manifest", ErrCorruptBackup)
+	}
+	key, err := s.KMSUploader.DecryptData(ctx, manifest.DataKey)
+	if err != nil {
+		return localModels.BackupRestore{}, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	archive, err := open(key, sealed)
+	if err != nil {
+		return localModels.BackupRestore{}, err
+	}
+	collections, err := readDump(archive)
+	if err != nil {
+		return localModels.BackupRestore{}, err
+	}
+
+	result := localModels.BackupRestore{BackupID: manifest.BackupID, ClientID: manifest.ClientID, DryRun: dryRun, Collections: map[string]int{}}
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checkRecords(name, collections[name], manifest, s.Collections); err != nil {
+			return result, err
+		}
+	}
+	for _, name := range names {
+		if dryRun {
+			result.Collections[name] = len(collections[name])
+			continue
+		}
+		written, err := restoreCollection(ctx, common.GetCollection(name), name, collections[name])
+		result.Collections[name] = written
+		if err != nil {
+			return result, err
+		}
+	}
+
+	zaplogger.GetLogger().Info("Client snapshot restored",
+		zap.String("clientID", manifest.ClientID),
+		zap.String("backupID", manifest.BackupID),
+		zap.Bool("dryRun", dryRun),
+	)
+	return result, nil
+}
+
+// checkRecords refuses an archive whose records would land outside the snapshot's client
+// or the collections backups cover, before anything is written
+func checkRecords(name string, records []bson.Raw, manifest localModels.BackupManifest, allowed []string) error {
+	if !slices.Contains(allowed, name) {
+		return fmt.Errorf("%w: unexpected collection %s", ErrCorruptBackup, name)
+	}
+	if len(records) != manifest.Collections[name] {
+		return fmt.Errorf("%w: %s holds %d records, the manifest lists %d", ErrCorruptBackup, name, len(records), manifest.Collections[name])
+	}
+	for _, record := range records {
+		if clientOf(record) != manifest.ClientID {
+			return fmt.Errorf("%w: %s holds a record of another client", ErrCorruptBackup, name)
+		}
+		if _, err := record.LookupErr("_id"); err != nil {
+			return fmt.Errorf("%w: %s holds a record without an _id", ErrCorruptBackup, name)
+		}
+	}
+	return nil
+}
+
+// restoreCollection writes records back by _id and returns how many were written
+func restoreCollection(ctx context.Context, collection common.CollectionInterface, name string, records []bson.Raw) (int, error) {
+	for i, record := range records {
+		var fields bson.D
+		if err := bson.Unmarshal(record, &fields); err != nil {
+			return i, fmt.Errorf("%w: %s: %v", ErrCorruptBackup, name, err)
+		}
+		var id interface{}
+		set := make(bson.D, 0, len(fields))
+		for _, field := range fields {
+			if field.Key == "_id" {
+				id = field.Value
+				continue
+			}
+			set = append(set, field)
+		}
+
+		err := mongoretry.Write(ctx, "restore_"+name, func(ctx context.Context) error {
+			_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set}, options.Update().SetUpsert(true))
+			return err
+		})
+		if err != nil {
+			return i, fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+	return len(records), nil
+}
+
+// download reads an object of the backup bucket
+func (s *BackupServiceImpl) download(ctx context.Context, key string) ([]byte, error) {
+	output, err := s.Uploader.DownloadFile(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer output.Body.Close()
+	content, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,292 @@
+package changelog
+
+import "time"
+
+// Entry describes one client-visible change to the API. Date is nil, served as null,
+// until the version ships.
+type Entry struct {
+	Version           string     `json:"version"`
+	Date              *time.Time `json:"date"`
+	Breaking          bool       `json:"breaking"`
+	Summary           string     `json:"summary"`
+	AffectedEndpoints []string   `json:"affected_endpoints"`
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func shipped(s string) *time.Time {
+	t := date(s)
+	return &t
+}
+
+// Entries is the API changelog, newest first, with the versions not yet shipped at the top.
+// Add an entry whenever a change affects client integrations, and date it when it ships.
+var Entries = []Entry{
+	{
+		Version:  "2.15.0",
+		Breaking: true,
+		Summary:  "GET /api/v2/levels describes the verification levels a client can create applicants at: the documents and checks each requires, the countries it serves, how long a decision may take and its price, narrowed with ?country= and ?currency=. Creating an applicant, or patching its verification_level, at a level that is not listed gets 422 with code validation_failed instead of being accepted. Levels are matched without regard to case and stored as the catalog spells them.",
+		AffectedEndpoints: []string{
+			"GET /api/v2/levels",
+			"GET /api/v1/protected2/levels",
+			"POST /api/v2/applicants",
+			"POST /api/v2/applicants/from-document",
+			"PATCH /api/v2/applicants/:id",
+			"POST /api/v1/protected/applicants",
+		},
+	},
+	{
+		Version:  "2.14.0",
+		Breaking: false,
+		Summary:  "Where enabled, PNG and JPEG photos of identity documents and selfies are checked for resolution, sharpness, glare and framing as they are uploaded. A photo that fails gets 422 at once, and each failed check carries a code naming what to fix: low_resolution, blurry_image, glare or cropped_document.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants/:id/documents",
+			"POST /api/v2/applicants/:id/documents/:docId/replace",
+			"POST /api/v2/applicants/from-document",
+			"POST /api/v1/protected/documents",
+			"POST /api/v2/hosted/session/documents",
+		},
+	},
+	{
+		Version:  "2.13.0",
+		Breaking: false,
+		Summary:  "When Sumsub asks an applicant to resubmit, each document it asked for again gets a resubmission with guidance on what to fix: blurry_image, cropped_document, expired_document or name_mismatch. A new applicant.resubmission_required webhook lists those documents with their guidance. Replacing a document clears its resubmission.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants/:id/sync",
+			"GET /api/v2/applicants/:id/documents",
+			"GET /api/v2/applicants/:id/documents/:docId",
+			"POST /api/v2/sandbox/webhooks/test",
+		},
+	},
+	{
+		Version:           "2.12.0",
+		Breaking:          false,
+		Summary:           "An unexpected failure on any route gets 500 with code internal_error and a correlation_id, also sent in the X-Correlation-ID header. Quote it when asking about the request, so it can be found in our logs and error reports.",
+		AffectedEndpoints: []string{},
+	},
+	{
+		Version:  "2.11.0",
+		Breaking: false,
+		Summary:  "Errors for missing applicants, documents, document versions, upload jobs, reports, attachments, sessions and encryption keys carry a code, such as applicant_not_found, on every route. A patch that would leave an applicant invalid gets 422 with code validation_failed and what is wrong with each field as fields. When Sumsub cannot be reached, sync answers 502 with code sumsub_unavailable. Failures to read or update an applicant other than it being missing get 500 instead of 404. Every other error a client can act on carries a code as well, such as upload_in_progress, replace_conflict or session_closed. A PUT that would write an applicant the collection schema refuses gets 422 with code invalid_write, as a PATCH does, instead of 400. Restoring an archived document while cold storage is not configured gets 503 with code cold_storage_disabled instead of 500.",
+		AffectedEndpoints: []string{
+			"GET /api/v2/applicants/:id",
+			"PUT /api/v2/applicants/:id",
+			"PATCH /api/v2/applicants/:id",
+			"POST /api/v2/applicants/:id/sync",
+			"GET /api/v1/protected/applicants/:id",
+			"PUT /api/v1/protected/applicants/:id",
+		},
+	},
+	{
+		Version:  "2.10.0",
+		Breaking: false,
+		Summary:  "New applicants, documents, clients and webhook events get IDs that name their type: app_, doc_, cli_ or whk_ followed by 32 hex digits. Existing records keep their UUIDs, which are still accepted. An ID in a path that names another type of record, or is neither form, gets 400 with code invalid_id instead of 404, as does an applicant_id of the wrong type in a v1 presigned upload request.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants",
+			"POST /api/v2/applicants/:id/documents",
+			"POST /api/v2/applicants/:id/documents/presign-upload",
+			"POST /api/v1/protected/applicants",
+			"POST /api/v1/protected/documents",
+			"POST /api/v1/protected/documents/presign-upload",
+			"POST /api/v1/admin/clients",
+			"POST /api/v2/webhooks/failures/:id/redeliver",
+		},
+	},
+	{
+		Version:  "2.9.0",
+		Breaking: true,
+		Summary:  "Breaking: uploads for another client's applicant get the same 404 as uploads for an applicant that does not exist, instead of 403 with code applicant_not_owned, so clients cannot tell which IDs belong to others. Every route that takes an applicant, document, upload job or webhook event ID answers one held by another client exactly as it answers one nobody holds; saving a document with POST /api/v1/protected/downloads/:id that does not exist gets 404 with code document_not_found instead of 500. IDs of new applicants, documents and other records are UUIDv7s, ordered by creation time; existing records keep their IDs, and IDs should still be treated as opaque strings.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants/:id/documents",
+			"POST /api/v2/applicants/:id/documents/presign-upload",
+			"POST /api/v2/applicants/:id/documents/complete",
+			"POST /api/v1/protected/documents",
+			"POST /api/v1/protected/documents/presign-upload",
+			"POST /api/v1/protected/documents/complete",
+			"POST /api/v1/protected/downloads/:id",
+		},
+	},
+	{
+		Version:  "2.8.0",
+		Breaking: false,
+		Summary:  "Applicant creation and update bodies larger than 64KB once inflated get 413 with code payload_too_large, and those nested more than 8 levels deep or with more than 500 fields get 400 with code payload_too_deep or payload_too_many_fields. The errors give the limit exceeded as limit.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants",
+			"PUT /api/v2/applicants/:id",
+			"PATCH /api/v2/applicants/:id",
+			"PATCH /api/v2/applicants/:id/annotations",
+			"POST /api/v1/protected/applicants",
+			"PUT /api/v1/protected/applicants/:id",
+		},
+	},
+	{
+		Version:  "2.7.0",
+		Breaking: false,
+		Summary:  "POST /api/v2/applicants/{id}/restore un-deletes an applicant, and POST /api/v2/applicants/{id}/documents/{docId}/undelete a document, deleted within the grace period, 30 days by default. Records that are not deleted get 409 with code not_deleted, and records deleted longer ago than the grace period get 410 with code restore_period_over. Restores are recorded in the audit log. GET /api/v2/applicants/deleted lists the client's deleted applicants, most recently deleted first, with restorable_until and seconds_remaining before they can no longer be restored.",
+		AffectedEndpoints: []string{
+			"GET /api/v2/applicants/deleted",
+			"POST /api/v2/applicants/:id/restore",
+			"POST /api/v2/applicants/:id/documents/:docId/undelete",
+		},
+	},
+	{
+		Version:  "2.6.0",
+		Breaking: false,
+		Summary:  "GET /api/v2/applicants/{id}/report.pdf returns a PDF verification report of an applicant's details, document thumbnails, provider results, screening outcomes and decision trail; reports of applicants with many documents are generated in the background, answering 202 with a Location to fetch them at by report_id. POST /api/v2/reports queues a CSV or JSON compliance report of the verifications performed, their outcomes, decision times against a target and the erasures executed over a date range, sends a report.completed webhook once it is generated and serves it from GET /api/v2/reports/{id}. POST /api/v2/applicants/{id}/rekey re-encrypts an applicant's personal data under a new data key from the current KMS key, and the data keys of its documents' files under that key, listing files in archival storage as skipped. Clients can register their own KMS key with POST /api/v2/encryption-keys, check the service can use it with POST /api/v2/encryption-keys/{id}/validate and switch to it with POST /api/v2/encryption-keys/{id}/activate, after which their existing applicants are moved to the key in the background and GET /api/v2/encryption-keys/{id} reports the progress.",
+		AffectedEndpoints: []string{
+			"GET /api/v2/applicants/:id/report.pdf",
+			"POST /api/v2/reports",
+			"GET /api/v2/reports/:id",
+			"POST /api/v1/protected2/reports",
+			"GET /api/v1/protected2/reports/:id",
+			"POST /api/v2/applicants/:id/rekey",
+			"POST /api/v2/encryption-keys",
+			"GET /api/v2/encryption-keys",
+			"GET /api/v2/encryption-keys/:id",
+			"POST /api/v2/encryption-keys/:id/validate",
+			"POST /api/v2/encryption-keys/:id/activate",
+		},
+	},
+	{
+		Version:  "2.5.0",
+		Breaking: false,
+		Summary:  "Documents older than coldStorage.afterDays can be moved to Glacier or Deep Archive; their files must then be restored with POST /api/v2/applicants/:id/documents/:docId/restore, which is followed with GET on the same path and a document.restored webhook. Large files can be uploaded straight to S3 with the presigned URL from POST /api/v2/applicants/{id}/documents/presign-upload, then checked and registered with POST /api/v2/applicants/{id}/documents/complete. Completed direct uploads are scanned for malware and their checksum verified before they are stored, and files never completed are removed. Uploads that would take an applicant past its document or storage limit get 409 with code applicant_document_limit or applicant_storage_limit, and clients with too many uploads in progress get 429 with code too_many_uploads and Retry-After. GET /api/v2/applicants/{id}/documents/{docId}/preview returns a downscaled JPEG of a photo or of a scanned PDF's first page, watermarked like downloads or on request, with ETag and Cache-Control headers, and can be read by pages from the configured origins.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants/:id/documents",
+			"POST /api/v2/applicants/:id/documents/:docId/restore",
+			"GET /api/v2/applicants/:id/documents/:docId/restore",
+			"POST /api/v2/applicants/:id/documents/presign-upload",
+			"POST /api/v2/applicants/:id/documents/complete",
+			"GET /api/v2/applicants/:id/documents/:docId/preview",
+		},
+	},
+	{
+		Version:  "2.4.0",
+		Breaking: false,
+		Summary:  "Applicants can be created with the client's own tags and key/value metadata, changed with PATCH /api/v2/applicants/{id}/annotations as a merge patch, returned under annotations, echoed in document.upload_failed webhooks and matched by GET /api/v2/applicants?tag=&metadata.<key>=. PATCH /api/v2/applicants/{id} changes an applicant with a JSON Merge Patch, or a JSON Patch sent as application/json-patch+json, and answers 422 when the result would not be a valid applicant; PUT /api/v2/applicants/{id} is deprecated. In the sandbox, clients can opt in to fault injection, which delays some requests, answers some with 500, 502, 503 or 504 and code injected_fault, and drops some webhook deliveries so they are retried.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants",
+			"GET /api/v2/applicants",
+			"GET /api/v2/applicants/:id",
+			"PUT /api/v2/applicants/:id",
+			"PATCH /api/v2/applicants/:id",
+			"PATCH /api/v2/applicants/:id/annotations",
+		},
+	},
+	{
+		Version:  "2.3.0",
+		Breaking: false,
+		Summary:  "POST /api/v2/applicants/from-document creates a provisional applicant from the name and date of birth read from an uploaded document's MRZ, which the client confirms or corrects with POST /api/v2/applicants/{id}/confirm; applicants carry an intake record of the document and the fields corrected. POST /api/v2/applicants/{id}/sessions returns a signed, expiring link to a hosted page where the applicant uploads their own documents, whose progress GET /api/v2/applicants/{id}/sessions/{sessionId} reports. The hosted page can show a QR code from GET /api/v2/hosted/session/handoff.png to carry a session on to the applicant's phone; sessions record the channel they were completed through as completed_via, and applicants as capture_channel. The hosted page can follow its session over a WebSocket at GET /api/v2/hosted/session/events, offering the verus.session subprotocol followed by its session token, which sends document_received and verification_progress events as they happen on any instance.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants/from-document",
+			"POST /api/v2/applicants/:id/confirm",
+			"POST /api/v2/applicants/:id/sessions",
+			"GET /api/v2/applicants/:id/sessions/:sessionId",
+			"GET /api/v2/hosted/session/handoff.png",
+			"GET /api/v2/hosted/session/events",
+		},
+	},
+	{
+		Version:  "2.2.0",
+		Breaking: false,
+		Summary:  "Each client has its own webhook signing secret, rotated with POST /api/v2/webhook-endpoint/rotate-secret; the previous secret keeps signing alongside it for a grace period, deliveries carry X-Verus-Timestamp, and POST /api/v2/webhooks/verify checks a delivery's signature. Webhook events that run out of delivery attempts are kept, listed with GET /api/v2/webhooks/failures a page at a time with next_cursor, and queued again with POST /api/v2/webhooks/failures/{id}/redeliver. Uploads return a job_id whose progress GET /api/v2/documents/jobs/{job_id} reports from any instance for a day. POST /api/v2/applicants/{id}/sync pulls an applicant's review status, document inspections and extracted data from Sumsub and returns what changed and where Sumsub disagrees with our record; applicants awaiting a decision are also synced in the background. POST /api/v2/sandbox/webhooks/test sends a signed sample event of a chosen type to the client's webhook endpoint and returns its status code, latency and the start of its response.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/webhook-endpoint/rotate-secret",
+			"POST /api/v2/webhooks/verify",
+			"GET /api/v2/webhooks/failures",
+			"POST /api/v2/webhooks/failures/:id/redeliver",
+			"GET /api/v1/protected2/webhooks/failures",
+			"POST /api/v1/protected2/webhooks/failures/:id/redeliver",
+			"POST /api/v2/applicants/:id/documents",
+			"GET /api/v2/documents/jobs/:job_id",
+			"POST /api/v2/applicants/:id/sync",
+			"POST /api/v2/sandbox/webhooks/test",
+		},
+	},
+	{
+		Version:  "2.1.0",
+		Breaking: false,
+		Summary:  "Clients can have responses signed with an X-Verus-Response-Signature header. POST /auth/token exchanges an API key or dashboard credentials for a short-lived JWT and a single-use refresh token. Repeated authentication failures from an IP or API key are answered with 429 and Retry-After, and security.alert webhooks report keys used from a new country or at an unusual rate. Clients can restrict the addresses their requests come from with PUT /api/v2/ip-allowlist; other addresses get 403 with code ip_not_allowed. Clients can require their API key requests to be signed with X-Timestamp, X-Nonce and X-Signature headers; stale, forged and replayed requests get 401. Error messages and upload check details are returned in the language asked for with Accept-Language, in English or Spanish, and GET /api/v2/labels lists document type and reason code labels in that language.",
+		AffectedEndpoints: []string{
+			"POST /auth/token",
+			"GET /api/v2/ip-allowlist",
+			"PUT /api/v2/ip-allowlist",
+			"GET /api/v2/labels",
+			"/api/v1/protected/*",
+			"/api/v1/protected2/*",
+		},
+	},
+	{
+		Version:  "2.0.0",
+		Date:     shipped("2026-10-17"),
+		Breaking: true,
+		Summary:  "Added /api/v2, which nests documents under their applicant and chooses authentication per route. Every /api/v1 client route is deprecated and returns Deprecation and Sunset headers; v1 keeps working until its sunset. List responses are gzipped for clients sending Accept-Encoding: gzip, and request bodies may be sent with Content-Encoding: gzip. Applicant reads accept ?fields= and ?include=documents to return only the fields asked for. POST /api/v2/applicants/{id}/documents/archive downloads all of an applicant's documents as a ZIP with a manifest. Clients can have downloaded documents watermarked with their name, the download's purpose and its time. Breaking: document archive downloads and staff downloads of document versions must give a ?reason= of verification, audit or support, which is recorded in the audit log; downloads without one get 400. Breaking: applicants can be created with a consent record of the consent text version, time, IP and channel, which applicant reads return and updates cannot change; clients that require consent get 400 with code consent_required without one, and uploads for applicants without consent fail the consent check. Breaking: every time the API returns is in UTC, to the millisecond, including applicants read with ?fields=. Breaking: v2 applicant and document responses no longer include storage fields: encrypted_data, client_id, file_url, sumsub_applicant and the deleted markers; v1 responses drop encrypted_data and client_id. Breaking: GET /api/v2/applicants/{id}/notes returns one page of notes with next_cursor while more follow, passed back as ?cursor=; cursors are signed and bound to their list, and others get 400 with code invalid_cursor. Uploads for another client's applicant get 403 with code applicant_not_owned, and uploads for an applicant that does not exist get 404, before the file is stored.",
+		AffectedEndpoints: []string{
+			"POST /api/v2/applicants",
+			"GET /api/v2/applicants",
+			"GET /api/v2/applicants/:id",
+			"PUT /api/v2/applicants/:id",
+			"POST /api/v2/applicants/:id/documents",
+			"GET /api/v2/applicants/:id/documents/:docId",
+			"PUT /api/v2/applicants/:id/documents/:docId",
+			"POST /api/v2/applicants/:id/documents/:docId/replace",
+			"GET /api/v2/applicants/:id/documents/:docId/versions",
+			"POST /api/v2/applicants/:id/documents/archive",
+			"GET /api/v1/admin/applicants/:id/documents/:docId/versions/:version",
+			"GET /api/v2/applicants/:id/notes",
+			"GET /api/v2/stats",
+			"GET /api/v1/protected2/stats",
+			"/api/v1/protected/*",
+			"/api/v1/protected2/*",
+		},
+	},
+	{
+		Version:  "1.3.0",
+		Date:     shipped("2026-10-16"),
+		Breaking: false,
+		Summary:  "Added the changelog endpoint. Deprecated endpoints now return Deprecation, Sunset and Link headers.",
+		AffectedEndpoints: []string{
+			"GET /changelog",
+			"POST /api/v1/protected/downloads/:id",
+		},
+	},
+	{
+		Version:  "1.2.0",
+		Date:     shipped("2026-10-14"),
+		Breaking: true,
+		Summary:  "Document uploads validate the country form field as an ISO 3166 code and accept an optional mrz field; mismatches between the two flag the document.",
+		AffectedEndpoints: []string{
+			"POST /api/v1/protected/documents",
+		},
+	},
+	{
+		Version:  "1.1.0",
+		Date:     shipped("2026-10-12"),
+		Breaking: true,
+		Summary:  "Document upload responses include checks and processing_status. Uploads failing synchronous checks return 422; uploads with deferred checks return 202.",
+		AffectedEndpoints: []string{
+			"POST /api/v1/protected/documents",
+		},
+	},
+	{
+		Version:  "1.0.0",
+		Date:     shipped("2025-01-15"),
+		Breaking: false,
+		Summary:  "Initial release of the applicant and document API.",
+		AffectedEndpoints: []string{
+			"POST /api/v1/protected/applicants",
+			"PUT /api/v1/protected/applicants/:id",
+			"GET /api/v1/protected2/applicants",
+			"GET /api/v1/protected2/applicants/:id",
+			"POST /api/v1/protected/documents",
+			"GET /api/v1/protected/documents/:id",
+			"PUT /api/v1/protected/documents/:id",
+		},
+	},
+}
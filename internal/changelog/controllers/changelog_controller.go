@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/changelog"
+)
+
+// GetChangelog is the handler function for listing API changes and deprecations
+func GetChangelog(c *gin.Context) {
+	deprecations := make([]gin.H, 0, len(changelog.DeprecatedRoutes))
+	for route, d := range changelog.DeprecatedRoutes {
+		deprecations = append(deprecations, gin.H{
+			"endpoint": route,
+			"since":    d.Since,
+			"sunset":   d.Sunset,
+			"link":     d.Link,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":      changelog.Entries,
+		"deprecations": deprecations,
+	})
+}
@@ -0,0 +1,69 @@
+package changelog
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecation describes when a route was deprecated and when it will be removed
+type Deprecation struct {
+	Since  time.Time `json:"since"`
+	Sunset time.Time `json:"sunset"`
+	Link   string    `json:"link,omitempty"` // Migration guide or replacement endpoint
+}
+
+// DeprecatedRoutes is the route registry of deprecated endpoints, keyed by "METHOD /route/pattern"
+var DeprecatedRoutes = map[string]Deprecation{
+	"POST /api/v1/protected/downloads/:id": {
+		Since:  date("2026-10-16"),
+		Sunset: date("2027-04-30"),
+		Link:   "/changelog",
+	},
+	// Replaced by PATCH, which validates the applicant it leaves
+	"PUT /api/v2/applicants/:id": {
+		Since:  date("2026-10-17"),
+		Sunset: date("2027-10-29"),
+		Link:   "/changelog",
+	},
+}
+
+// V1Deprecation applies to every /api/v1 route now that /api/v2 replaces them. Routes
+// in DeprecatedRoutes keep their own, earlier, sunset.
+var V1Deprecation = Deprecation{
+	Since:  date("2026-10-17"),
+	Sunset: date("2027-10-29"),
+	Link:   "/changelog",
+}
+
+// DeprecationHeaders attaches Deprecation (RFC 9745), Sunset (RFC 8594) and Link
+// headers to responses from routes listed in DeprecatedRoutes
+func DeprecationHeaders(routes map[string]Deprecation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d, ok := routes[c.Request.Method+" "+c.FullPath()]; ok {
+			setHeaders(c, d)
+		}
+		c.Next()
+	}
+}
+
+// Deprecate attaches the deprecation headers to every route in a group, unless
+// DeprecationHeaders has already set them for the route
+func Deprecate(d Deprecation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Writer.Header().Get("Deprecation") == "" {
+			setHeaders(c, d)
+		}
+		c.Next()
+	}
+}
+
+func setHeaders(c *gin.Context, d Deprecation) {
+	c.Header("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+	c.Header("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	if d.Link != "" {
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", d.Link))
+	}
+}
@@ -0,0 +1,81 @@
+package changelog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecationHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(DeprecationHeaders(map[string]Deprecation{
+		"GET /old/:id": {Since: date("2025-01-01"), Sunset: date("2025-06-30"), Link: "/changelog"},
+	}))
+	router.GET("/old/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/new/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/old/123", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "@1735689600", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Mon, 30 Jun 2025 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</changelog>; rel="deprecation"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/new/123", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+}
+
+func TestDeprecateGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(DeprecationHeaders(map[string]Deprecation{
+		"GET /v1/old": {Since: date("2025-01-01"), Sunset: date("2025-06-30")},
+	}))
+	v1 := router.Group("/v1", Deprecate(Deprecation{Since: date("2025-03-01"), Sunset: date("2026-03-01"), Link: "/changelog"}))
+	v1.GET("/old", func(c *gin.Context) { c.Status(http.StatusOK) })
+	v1.GET("/current", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v1/current", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "Sun, 01 Mar 2026 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</changelog>; rel="deprecation"`, w.Header().Get("Link"))
+
+	// The route's own, earlier sunset wins over the group's
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/v1/old", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "Mon, 30 Jun 2025 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Empty(t, w.Header().Get("Link"))
+}
+
+func TestEntriesNewestFirst(t *testing.T) {
+	for i := 1; i < len(Entries); i++ {
+		previous, current := Entries[i-1].Date, Entries[i].Date
+		if current == nil {
+			assert.Nil(t, previous, "unshipped entry %s is below a shipped one", Entries[i].Version)
+			continue
+		}
+		assert.True(t, previous == nil || previous.After(*current), "entry %s is out of order", Entries[i].Version)
+	}
+}
+
+func TestEntriesNotDatedAhead(t *testing.T) {
+	for _, entry := range Entries {
+		if entry.Date != nil {
+			assert.False(t, entry.Date.After(time.Now()), "entry %s is dated in the future", entry.Version)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/client/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/keyring"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	webhookServices "github.com/rachel-lawrie/verus_app_backend/internal/webhook/services"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+type clientRequest struct {
+	Name                      string                     `json:"name" binding:"required"`
+	ContactName               string                     `json:"contact_name"`
+	ContactEmail              string                     `json:"contact_email" binding:"required,email"`
+	AllowedVerificationLevels []string                   `json:"allowed_verification_levels" binding:"required"`
+	WebhookURL                string                     `json:"webhook_url"`
+	Settings                  localModels.ClientSettings `json:"settings"`
+	Features                  map[string]bool            `json:"features"`
+}
+
+// bindClient validates the request body and converts it to a client, responding with 400 if it is invalid
+func bindClient(c *gin.Context) (localModels.Client, bool) {
+	var requestBody clientRequest
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name, a valid contact_email and allowed_verification_levels are required"})
+		return localModels.Client{}, false
+	}
+
+	client := localModels.Client{
+		Name:         strings.TrimSpace(requestBody.Name),
+		ContactName:  strings.TrimSpace(requestBody.ContactName),
+		ContactEmail: strings.TrimSpace(requestBody.ContactEmail),
+		WebhookURL:   requestBody.WebhookURL,
+	}
+	if client.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be blank"})
+		return localModels.Client{}, false
+	}
+
+	seen := make(map[string]bool)
+	for _, level := range requestBody.AllowedVerificationLevels {
+		level = strings.TrimSpace(level)
+		if level != "" && !seen[level] {
+			seen[level] = true
+			client.AllowedVerificationLevels = append(client.AllowedVerificationLevels, level)
+		}
+	}
+	if len(client.AllowedVerificationLevels) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "allowed_verification_levels must list at least one level"})
+		return localModels.Client{}, false
+	}
+
+	if client.WebhookURL != "" && !webhookServices.ValidEndpointURL(client.WebhookURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_url must be an absolute http(s) URL"})
+		return localModels.Client{}, false
+	}
+
+	if msg := validateSettings(&requestBody.Settings); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return localModels.Client{}, false
+	}
+	client.Settings = requestBody.Settings
+
+	for flag := range requestBody.Features {
+		if !clientconfig.Known(flag) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown feature flag: " + flag})
+			return localModels.Client{}, false
+		}
+	}
+	if len(requestBody.Features) > 0 {
+		client.Features = requestBody.Features
+	}
+	return client, true
+}
+
+// maxWatermarkLabel leaves room on the mark's first line for the download's purpose
+const maxWatermarkLabel = 30
+
+// validateSettings checks a client's settings and normalises its lists, returning a message if they are invalid
+func validateSettings(settings *localModels.ClientSettings) string {
+	if settings.MaxUploadBytes < 0 {
+		return "settings.max_upload_bytes must not be negative"
+	}
+	if settings.Webhooks.MaxAttempts < 0 {
+		return "settings.webhooks.max_attempts must not be negative"
+	}
+	settings.Watermark.Label = strings.TrimSpace(settings.Watermark.Label)
+	if len(settings.Watermark.Label) > maxWatermarkLabel {
+		return fmt.Sprintf("settings.watermark.label must be at most %d characters", maxWatermarkLabel)
+	}
+	switch settings.Sandbox.Outcome {
+	case "", localModels.SandboxOutcomeApprove, localModels.SandboxOutcomeReject, localModels.SandboxOutcomeReview:
+	default:
+		return "settings.sandbox.outcome must be approve, reject or review"
+	}
+	settings.KMSKeyID = strings.TrimSpace(settings.KMSKeyID)
+	if settings.KMSKeyID != "" && !keyring.ValidKeyARN(settings.KMSKeyID) {
+		return "settings.kms_key_id must be the ARN of a KMS key or alias"
+	}
+
+	var documentTypes []string
+	for _, documentType := range settings.AllowedDocumentTypes {
+		if documentType = strings.TrimSpace(documentType); documentType != "" {
+			documentTypes = append(documentTypes, documentType)
+		}
+	}
+	settings.AllowedDocumentTypes = documentTypes
+	return ""
+}
+
+// RegisterClient is the handler function for onboarding a new client. The response
+// holds the client's first API key, which cannot be retrieved again.
+func RegisterClient(c *gin.Context, service interfaces.ClientService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	client, ok := bindClient(c)
+	if !ok {
+		return
+	}
+
+	registration, err := service.RegisterClient(c.Request.Context(), client, adminID)
+	if errors.Is(err, services.ErrWebhookNotSaved) {
+		// The client and key exist, so hand the key over and let the webhook be set again
+		c.JSON(http.StatusCreated, gin.H{
+			"client":  registration.Client,
+			"key_id":  registration.KeyID,
+			"api_key": registration.APIKey,
+			"warning": "The webhook URL could not be saved; set it again with PUT",
+		})
+		return
+	}
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not register client"})
+		return
+	}
+	c.JSON(http.StatusCreated, registration)
+}
+
+// GetClient is the handler function for reading a client's settings
+func GetClient(c *gin.Context, service interfaces.ClientService) {
+	client, err := service.GetClient(c.Request.Context(), c.Param("clientId"))
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve client"})
+		return
+	}
+	c.JSON(http.StatusOK, client)
+}
+
+// UpdateClient is the handler function for replacing a client's settings
+func UpdateClient(c *gin.Context, service interfaces.ClientService) {
+	client, ok := bindClient(c)
+	if !ok {
+		return
+	}
+	client.ClientID = c.Param("clientId")
+
+	updated, err := service.UpdateClient(c.Request.Context(), client)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update client"})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// maxAllowlistEntries bounds a client's IP allowlist
+const maxAllowlistEntries = 100
+
+type ipAllowlistRequest struct {
+	CIDRs *[]string `json:"cidrs"`
+}
+
+// bindCIDRs validates the networks in the request body, responding with 400 if they are invalid
+func bindCIDRs(c *gin.Context) ([]string, bool) {
+	var requestBody ipAllowlistRequest
+	if err := c.ShouldBindJSON(&requestBody); err != nil || requestBody.CIDRs == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cidrs is required; send an empty list to allow any address"})
+		return nil, false
+	}
+	if len(*requestBody.CIDRs) > maxAllowlistEntries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cidrs must not list more than %d networks", maxAllowlistEntries)})
+		return nil, false
+	}
+	cidrs, err := clientconfig.ParseCIDRs(*requestBody.CIDRs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cidrs: " + err.Error()})
+		return nil, false
+	}
+	return cidrs, true
+}
+
+// respondAllowlist sends an updated IP allowlist, or the error that prevented the update
+func respondAllowlist(c *gin.Context, allowlist localModels.IPAllowlist, err error) {
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update IP allowlist"})
+		return
+	}
+	c.JSON(http.StatusOK, allowlist)
+}
+
+// SetIPAllowlist is the handler function for an admin replacing a client's IP allowlist
+func SetIPAllowlist(c *gin.Context, service interfaces.ClientService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	cidrs, ok := bindCIDRs(c)
+	if !ok {
+		return
+	}
+	allowlist, err := service.SetIPAllowlist(c.Request.Context(), c.Param("clientId"), cidrs, adminID)
+	respondAllowlist(c, allowlist, err)
+}
+
+// SetIPAllowlistBypass is the handler function for an admin letting a client's requests
+// through from any address, e.g. after the client locked itself out, or ending that
+func SetIPAllowlistBypass(c *gin.Context, service interfaces.ClientService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var requestBody struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil || requestBody.Enabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+		return
+	}
+	allowlist, err := service.SetIPAllowlistBypass(c.Request.Context(), c.Param("clientId"), *requestBody.Enabled, adminID)
+	respondAllowlist(c, allowlist, err)
+}
+
+// GetOwnIPAllowlist is the handler function for a client reading its own IP allowlist
+func GetOwnIPAllowlist(c *gin.Context) {
+	client, err := clientconfig.FromContext(c)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Could not load client settings"})
+		return
+	}
+	c.JSON(http.StatusOK, client.IPAllowlist)
+}
+
+// SetOwnIPAllowlist is the handler function for a client replacing its own IP allowlist.
+// The list must include the address the request comes from, so a client cannot lock
+// itself out by mistake.
+func SetOwnIPAllowlist(c *gin.Context, service interfaces.ClientService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	cidrs, ok := bindCIDRs(c)
+	if !ok {
+		return
+	}
+	proposed := localModels.Client{IPAllowlist: localModels.IPAllowlist{CIDRs: cidrs}}
+	if !clientconfig.AllowsIP(proposed, c.ClientIP()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cidrs must include the address this request comes from: " + c.ClientIP()})
+		return
+	}
+	allowlist, err := service.SetIPAllowlist(c.Request.Context(), clientID, cidrs, clientID)
+	respondAllowlist(c, allowlist, err)
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/client/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupClientRouter registers the client management routes behind a fake admin login
+func setupClientRouter(mockService *localMocks.MockClientService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	admin := router.Group("/admin", func(c *gin.Context) {
+		c.Set("admin_id", "admin1")
+		c.Set("admin_role", "admin")
+	})
+	admin.POST("/clients", func(c *gin.Context) {
+		RegisterClient(c, mockService)
+	})
+	admin.GET("/clients/:clientId", func(c *gin.Context) {
+		GetClient(c, mockService)
+	})
+	admin.PUT("/clients/:clientId", func(c *gin.Context) {
+		UpdateClient(c, mockService)
+	})
+	admin.PUT("/clients/:clientId/ip-allowlist", func(c *gin.Context) {
+		SetIPAllowlist(c, mockService)
+	})
+	admin.PUT("/clients/:clientId/ip-allowlist/bypass", func(c *gin.Context) {
+		SetIPAllowlistBypass(c, mockService)
+	})
+
+	own := router.Group("/own", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	})
+	own.PUT("/ip-allowlist", func(c *gin.Context) {
+		SetOwnIPAllowlist(c, mockService)
+	})
+	return router
+}
+
+func TestRegisterClient(t *testing.T) {
+	acme := localModels.Client{
+		Name:                      "Acme",
+		ContactName:               "Jo Smith",
+		ContactEmail:              "jo@acme.test",
+		AllowedVerificationLevels: []string{"basic", "enhanced"},
+		WebhookURL:                "https://acme.test/hooks",
+	}
+
+	tests := []struct {
+		name               string
+		requestBody        string
+		serviceErr         error
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "Registers client and returns key",
+			requestBody:        `{"name": " Acme ", "contact_name": "Jo Smith", "contact_email": "jo@acme.test", "allowed_verification_levels": ["basic", "enhanced", "basic", " "], "webhook_url": "https://acme.test/hooks"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `"api_key":"vk_test"`,
+		},
+		{
+			name:               "Webhook not saved still returns key",
+			requestBody:        `{"name": "Acme", "contact_name": "Jo Smith", "contact_email": "jo@acme.test", "allowed_verification_levels": ["basic", "enhanced"], "webhook_url": "https://acme.test/hooks"}`,
+			serviceErr:         services.ErrWebhookNotSaved,
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `"warning"`,
+		},
+		{
+			name:               "Service failure",
+			requestBody:        `{"name": "Acme", "contact_name": "Jo Smith", "contact_email": "jo@acme.test", "allowed_verification_levels": ["basic", "enhanced"], "webhook_url": "https://acme.test/hooks"}`,
+			serviceErr:         errors.New("boom"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "Invalid email",
+			requestBody:        `{"name": "Acme", "contact_email": "not-an-email", "allowed_verification_levels": ["basic"]}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "No verification levels",
+			requestBody:        `{"name": "Acme", "contact_email": "jo@acme.test", "allowed_verification_levels": [" "]}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Unknown feature flag",
+			requestBody:        `{"name": "Acme", "contact_email": "jo@acme.test", "allowed_verification_levels": ["basic"], "features": {"no_such_flag": true}}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Invalid sandbox outcome",
+			requestBody:        `{"name": "Acme", "contact_email": "jo@acme.test", "allowed_verification_levels": ["basic"], "settings": {"sandbox": {"outcome": "maybe"}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Watermark label too long",
+			requestBody:        `{"name": "Acme", "contact_email": "jo@acme.test", "allowed_verification_levels": ["basic"], "settings": {"watermark": {"enabled": true, "label": "Acme Consolidated Holdings International"}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "KMS key that is not an ARN",
+			requestBody:        `{"name": "Acme", "contact_email": "jo@acme.test", "allowed_verification_levels": ["basic"], "settings": {"kms_key_id": "alias/acme"}}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Invalid webhook URL",
+			requestBody:        `{"name": "Acme", "contact_email": "jo@acme.test", "allowed_verification_levels": ["basic"], "webhook_url": "ftp://acme.test"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockClientService)
+			router := setupClientRouter(mockService)
+			mockService.On("RegisterClient", mock.Anything, acme, "admin1").
+				Return(localModels.ClientRegistration{Client: acme, KeyID: "key1", APIKey: "vk_test"}, tt.serviceErr)
+
+			req, _ := http.NewRequest(http.MethodPost, "/admin/clients", strings.NewReader(tt.requestBody))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			if tt.expectedStatusCode == http.StatusBadRequest {
+				mockService.AssertNotCalled(t, "RegisterClient", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				mockService.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestGetClient(t *testing.T) {
+	mockService := new(localMocks.MockClientService)
+	router := setupClientRouter(mockService)
+	mockService.On("GetClient", mock.Anything, "client1").Return(localModels.Client{ClientID: "client1", Name: "Acme"}, nil)
+	mockService.On("GetClient", mock.Anything, "missing").Return(localModels.Client{}, services.ErrClientNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/clients/client1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Acme"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/admin/clients/missing", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateClient(t *testing.T) {
+	clientconfig.SetDefaults(map[string]bool{"new_flow": false})
+	defer clientconfig.SetDefaults(nil)
+
+	mockService := new(localMocks.MockClientService)
+	router := setupClientRouter(mockService)
+	expected := localModels.Client{
+		ClientID:                  "client1",
+		Name:                      "Acme",
+		ContactEmail:              "ops@acme.test",
+		AllowedVerificationLevels: []string{"basic"},
+		Settings: localModels.ClientSettings{
+			MaxUploadBytes:       2 << 20,
+			AllowedDocumentTypes: []string{"passport"},
+			Webhooks:             localModels.ClientWebhookRetryPolicy{MaxAttempts: 3},
+			Watermark:            localModels.DownloadWatermark{Enabled: true, Label: "Acme"},
+			KMSKeyID:             "arn:aws:kms:us-east-1:444455556666:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+		},
+		Features: map[string]bool{"new_flow": true},
+	}
+	mockService.On("UpdateClient", mock.Anything, expected).Return(expected, nil)
+	missing := expected
+	missing.ClientID = "missing"
+	mockService.On("UpdateClient", mock.Anything, missing).Return(localModels.Client{}, services.ErrClientNotFound)
+
+	body := `{"name": "Acme", "contact_email": "ops@acme.test", "allowed_verification_levels": ["basic"],
+		"settings": {"max_upload_bytes": 2097152, "allowed_document_types": [" passport "], "webhooks": {"max_attempts": 3},
+			"watermark": {"enabled": true, "label": " Acme "},
+			"kms_key_id": " arn:aws:kms:us-east-1:444455556666:key/1234abcd-12ab-34cd-56ef-1234567890ab "},
+		"features": {"new_flow": true}}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/admin/clients/client1", strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, "/admin/clients/missing", strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestSetIPAllowlist(t *testing.T) {
+	mockService := new(localMocks.MockClientService)
+	router := setupClientRouter(mockService)
+	cidrs := []string{"203.0.113.0/24", "198.51.100.1/32"}
+	mockService.On("SetIPAllowlist", mock.Anything, "client1", cidrs, "admin1").Return(localModels.IPAllowlist{CIDRs: cidrs}, nil)
+	mockService.On("SetIPAllowlist", mock.Anything, "missing", cidrs, "admin1").Return(localModels.IPAllowlist{}, services.ErrClientNotFound)
+
+	tests := []struct {
+		name               string
+		clientID           string
+		requestBody        string
+		expectedStatusCode int
+	}{
+		{"Replaces allowlist", "client1", `{"cidrs": ["203.0.113.4/24", "198.51.100.1"]}`, http.StatusOK},
+		{"Unknown client", "missing", `{"cidrs": ["203.0.113.0/24", "198.51.100.1"]}`, http.StatusNotFound},
+		{"Missing cidrs", "client1", `{}`, http.StatusBadRequest},
+		{"Invalid network", "client1", `{"cidrs": ["203.0.113.0/40"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/admin/clients/"+tt.clientID+"/ip-allowlist", strings.NewReader(tt.requestBody))
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+		})
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestSetIPAllowlistBypass(t *testing.T) {
+	mockService := new(localMocks.MockClientService)
+	router := setupClientRouter(mockService)
+	mockService.On("SetIPAllowlistBypass", mock.Anything, "client1", true, "admin1").Return(localModels.IPAllowlist{Bypass: true}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/admin/clients/client1/ip-allowlist/bypass", strings.NewReader(`{"enabled": true}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"bypass":true`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, "/admin/clients/client1/ip-allowlist/bypass", strings.NewReader(`{}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestSetOwnIPAllowlistKeepsCallerIn(t *testing.T) {
+	mockService := new(localMocks.MockClientService)
+	router := setupClientRouter(mockService)
+	cidrs := []string{"203.0.113.0/24"}
+	mockService.On("SetIPAllowlist", mock.Anything, "client1", cidrs, "client1").Return(localModels.IPAllowlist{CIDRs: cidrs}, nil).Once()
+
+	put := func(body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/own/ip-allowlist", strings.NewReader(body))
+		req.RemoteAddr = "203.0.113.7:443"
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, put(`{"cidrs": ["198.51.100.0/24"]}`))
+	assert.Equal(t, http.StatusOK, put(`{"cidrs": ["203.0.113.0/24"]}`))
+	mockService.AssertExpectations(t)
+}
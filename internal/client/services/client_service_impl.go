@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	webhookServices "github.com/rachel-lawrie/verus_app_backend/internal/webhook/services"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+var (
+	// ErrClientNotFound is returned when no client has the given ID
+	ErrClientNotFound = apperr.NotFound("client_not_found", "client not found")
+	// ErrWebhookNotSaved is returned with a registration whose client and API key were
+	// created but whose webhook endpoint could not be saved
+	ErrWebhookNotSaved = errors.New("webhook endpoint was not saved")
+)
+
+// ClientServiceImpl registers API clients and manages their settings
+type ClientServiceImpl struct {
+	CollectionName       string
+	SecretCollectionName string
+	Webhooks             localInterfaces.WebhookService
+}
+
+var (
+	instance ClientServiceImpl
+	once     sync.Once
+)
+
+func GetClientServiceImpl() ClientServiceImpl {
+	once.Do(func() {
+		instance = ClientServiceImpl{
+			CollectionName:       localConstants.CollectionClients,
+			SecretCollectionName: localConstants.CollectionClientSecrets,
+		}
+	})
+	return instance
+}
+
+// RegisterClient creates a client and its first API key, and registers its webhook
+// URL if one is given. The key is only ever returned here.
+func (s *ClientServiceImpl) RegisterClient(ctx context.Context, client localModels.Client, adminID string) (localModels.ClientRegistration, error) {
+	logger := zaplogger.GetLogger()
+	now := timestamp.Now()
+	client.ClientID = ids.Client.New()
+	client.CreatedBy = adminID
+	client.CreatedAt = now
+	client.UpdatedAt = now
+
+	collection := common.GetCollection(s.CollectionName)
+	if err := mongoretry.InsertOnce(ctx, collection, "register_client", bson.M{"client_id": client.ClientID}, client); err != nil {
+		logger.Error("Error inserting client into MongoDB", zap.Error(err))
+		return localModels.ClientRegistration{}, err
+	}
+
+	apiKey, err := newAPIKey()
+	if err != nil {
+		return localModels.ClientRegistration{}, err
+	}
+	secret := localModels.ClientSecret{
+		KeyID:            ids.New(),
+		ClientID:         client.ClientID,
+		ClientSecretHash: utils.HashAPIKey(apiKey),
+		CreatedBy:        adminID,
+		CreatedAt:        now,
+	}
+	secrets := common.GetCollection(s.SecretCollectionName)
+	if err := mongoretry.InsertOnce(ctx, secrets, "provision_api_key", bson.M{"key_id": secret.KeyID}, secret); err != nil {
+		logger.Error("Error inserting client API key into MongoDB", zap.Error(err), zap.String("clientID", client.ClientID))
+		return localModels.ClientRegistration{}, err
+	}
+
+	registration := localModels.ClientRegistration{Client: client, KeyID: secret.KeyID, APIKey: apiKey}
+	if client.WebhookURL != "" {
+		endpoint, err := s.Webhooks.SetEndpoint(ctx, client.ClientID, client.WebhookURL)
+		if err != nil {
+			// The API key cannot be shown again, so the registration is still returned
+			registration.Client.WebhookURL = ""
+			return registration, fmt.Errorf("%w: %v", ErrWebhookNotSaved, err)
+		}
+		registration.WebhookSecret = endpoint.Secret
+	}
+	return registration, nil
+}
+
+// GetClient returns a client with its webhook URL and the state of its webhook signing secrets
+func (s *ClientServiceImpl) GetClient(ctx context.Context, clientID string) (localModels.Client, error) {
+	var client localModels.Client
+	err := common.GetCollection(s.CollectionName).FindOne(ctx, bson.M{"client_id": clientID}).Decode(&client)
+	if err == mongo.ErrNoDocuments {
+		return client, ErrClientNotFound
+	}
+	if err != nil {
+		return client, fmt.Errorf("failed to look up client: %w", err)
+	}
+
+	endpoint, err := s.Webhooks.GetEndpoint(ctx, clientID)
+	if err != nil && !errors.Is(err, webhookServices.ErrNoEndpoint) {
+		return client, fmt.Errorf("failed to look up webhook endpoint: %w", err)
+	}
+	client.WebhookURL = endpoint.URL
+	client.WebhookSigning = endpoint.Signing
+	return client, nil
+}
+
+// UpdateClient replaces a client's name, contact, allowed verification levels, settings
+// and feature flags, and changes its webhook URL if one is given
+func (s *ClientServiceImpl) UpdateClient(ctx context.Context, client localModels.Client) (localModels.Client, error) {
+	update := bson.M{"$set": bson.M{
+		"name":                        client.Name,
+		"contact_name":                client.ContactName,
+		"contact_email":               client.ContactEmail,
+		"allowed_verification_levels": client.AllowedVerificationLevels,
+		"settings":                    client.Settings,
+		"features":                    client.Features,
+		"updated_at":                  timestamp.Now(),
+	}}
+
+	var matched int64
+	err := mongoretry.Write(ctx, "update_client", func(ctx context.Context) error {
+		result, err := common.GetCollection(s.CollectionName).UpdateOne(ctx, bson.M{"client_id": client.ClientID}, update)
+		if err == nil {
+			matched = result.MatchedCount
+		}
+		return err
+	})
+	if err != nil {
+		zaplogger.GetLogger().Error("Error updating client", zap.Error(err), zap.String("clientID", client.ClientID))
+		return localModels.Client{}, err
+	}
+	if matched == 0 {
+		return localModels.Client{}, ErrClientNotFound
+	}
+
+	if client.WebhookURL != "" {
+		if _, err := s.Webhooks.SetEndpoint(ctx, client.ClientID, client.WebhookURL); err != nil {
+			return localModels.Client{}, fmt.Errorf("%w: %v", ErrWebhookNotSaved, err)
+		}
+	}
+	return s.GetClient(ctx, client.ClientID)
+}
+
+// SetIPAllowlist replaces the networks the client's requests may come from. An empty
+// list allows any address.
+func (s *ClientServiceImpl) SetIPAllowlist(ctx context.Context, clientID string, cidrs []string, updatedBy string) (localModels.IPAllowlist, error) {
+	now := timestamp.Now()
+	return s.updateIPAllowlist(ctx, clientID, "set_ip_allowlist", bson.M{
+		"ip_allowlist.cidrs":      cidrs,
+		"ip_allowlist.updated_by": updatedBy,
+		"ip_allowlist.updated_at": now,
+	})
+}
+
+// SetIPAllowlistBypass switches the emergency bypass of the client's IP allowlist on or off
+func (s *ClientServiceImpl) SetIPAllowlistBypass(ctx context.Context, clientID string, bypass bool, adminID string) (localModels.IPAllowlist, error) {
+	now := timestamp.Now()
+	return s.updateIPAllowlist(ctx, clientID, "set_ip_allowlist_bypass", bson.M{
+		"ip_allowlist.bypass":        bypass,
+		"ip_allowlist.bypass_set_by": adminID,
+		"ip_allowlist.bypass_set_at": now,
+	})
+}
+
+func (s *ClientServiceImpl) updateIPAllowlist(ctx context.Context, clientID, operation string, set bson.M) (localModels.IPAllowlist, error) {
+	var client localModels.Client
+	found := true
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"ip_allowlist": 1})
+	err := mongoretry.Write(ctx, operation, func(ctx context.Context) error {
+		err := common.GetCollection(s.CollectionName).FindOneAndUpdate(ctx, bson.M{"client_id": clientID}, bson.M{"$set": set}, opts).Decode(&client)
+		if err == mongo.ErrNoDocuments {
+			found = false
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		zaplogger.GetLogger().Error("Error updating client IP allowlist", zap.Error(err), zap.String("clientID", clientID))
+		return localModels.IPAllowlist{}, err
+	}
+	if !found {
+		return localModels.IPAllowlist{}, ErrClientNotFound
+	}
+	return client.IPAllowlist, nil
+}
+
+// newAPIKey generates a random client API key
+func newAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %v", err)
+	}
+	return "vk_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,251 @@
+// Package clientconfig reads the settings and feature flags of the client making a
+// request. The client's record is loaded at most once per request.
+//
+// Feature flags let a capability be rolled out client by client: a flag is off
+// unless it is switched on by default in the service settings or for the client.
+//
+//	if clientconfig.Enabled(client, "document_first_flow") { ... }
+package clientconfig
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	contextKey = "client_config"
+	loaderKey  = "client_config_loader"
+)
+
+// Loader reads a client's record
+type Loader interface {
+	Load(ctx context.Context, clientID string) (localModels.Client, error)
+}
+
+// Store loads client records from the clients collection
+type Store struct {
+	CollectionName string
+}
+
+// NewStore creates a Store reading the clients collection
+func NewStore() *Store {
+	return &Store{CollectionName: localConstants.CollectionClients}
+}
+
+// Load returns the client's record. Clients created before onboarding went through the
+// API may have no record, and get the service defaults.
+func (s *Store) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	client := localModels.Client{ClientID: clientID}
+	opts := options.FindOne().SetProjection(bson.M{
+		"client_id":                   1,
+		"allowed_verification_levels": 1,
+		"settings":                    1,
+		"features":                    1,
+		"ip_allowlist":                1,
+	})
+	err := common.GetCollection(s.CollectionName).FindOne(ctx, bson.M{"client_id": clientID}, opts).Decode(&client)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return client, fmt.Errorf("failed to load client settings: %w", err)
+	}
+	return client, nil
+}
+
+// Middleware makes the client's record available to handlers through FromContext, and
+// tags the request's context with the client so services called with it can tell who
+// the request is for
+func Middleware(loader Loader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(loaderKey, loader)
+		if clientID, err := utils.GetClientIDFromContext(c); err == nil {
+			c.Request = c.Request.WithContext(WithClientID(c.Request.Context(), clientID))
+		}
+		c.Next()
+	}
+}
+
+// clientIDKey tags a context with the client work is done for
+type clientIDKey struct{}
+
+// WithClientID tags a context with the client work is done for, for work done outside a
+// request such as background jobs
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, clientID)
+}
+
+// ClientID returns the client a context is tagged with, or the authenticated client when
+// the context is a request's gin context
+func ClientID(ctx context.Context) (string, bool) {
+	if c, ok := ctx.(*gin.Context); ok {
+		if clientID, err := utils.GetClientIDFromContext(c); err == nil {
+			return clientID, true
+		}
+		if c.Request == nil {
+			return "", false
+		}
+		ctx = c.Request.Context()
+	}
+	clientID, ok := ctx.Value(clientIDKey{}).(string)
+	return clientID, ok && clientID != ""
+}
+
+// FromContext returns the record of the authenticated client, loading it on first use in
+// the request. Without Middleware the client gets the service defaults.
+func FromContext(c *gin.Context) (localModels.Client, error) {
+	if value, ok := c.Get(contextKey); ok {
+		return value.(localModels.Client), nil
+	}
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		return localModels.Client{}, err
+	}
+	client := localModels.Client{ClientID: clientID}
+	if value, ok := c.Get(loaderKey); ok {
+		if client, err = value.(Loader).Load(c.Request.Context(), clientID); err != nil {
+			return client, err
+		}
+	}
+	c.Set(contextKey, client)
+	return client, nil
+}
+
+// IPNotAllowed is the error code of a request refused by the client's IP allowlist
+const IPNotAllowed = "ip_not_allowed"
+
+// RequireAllowedIP refuses requests with 403 when they come from an address outside the
+// authenticated client's IP allowlist. It must follow authentication and Middleware. The
+// allowlist guards the client's own keys, so support staff acting as the client are let through.
+func RequireAllowedIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := middleware.GetImpersonationFromContext(c); ok {
+			c.Next()
+			return
+		}
+		client, err := FromContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not load client settings"})
+			return
+		}
+		if !AllowsIP(client, c.ClientIP()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Requests from this IP address are not allowed for this client",
+				"code":  IPNotAllowed,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AllowsIP reports whether the client accepts requests from an address. Clients without
+// an allowlist, or whose allowlist an admin has bypassed, accept any address.
+func AllowsIP(client localModels.Client, ip string) bool {
+	allowlist := client.IPAllowlist
+	if len(allowlist.CIDRs) == 0 || allowlist.Bypass {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, cidr := range allowlist.CIDRs {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDRs checks a list of networks, accepting single addresses, and returns it
+// deduplicated in canonical form
+func ParseCIDRs(list []string) ([]string, error) {
+	cidrs := []string{}
+	seen := make(map[string]bool)
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		var prefix netip.Prefix
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		} else if prefix, err = netip.ParsePrefix(entry); err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+		}
+		cidr := prefix.Masked().String()
+		if !seen[cidr] {
+			seen[cidr] = true
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs, nil
+}
+
+var (
+	mu       sync.RWMutex
+	defaults = map[string]bool{}
+)
+
+// SetDefaults declares the known feature flags and whether each is on for clients that do not set it
+func SetDefaults(flags map[string]bool) {
+	known := make(map[string]bool, len(flags))
+	for name, enabled := range flags {
+		known[name] = enabled
+	}
+	mu.Lock()
+	defaults = known
+	mu.Unlock()
+}
+
+// Known reports whether a feature flag has been declared
+func Known(flag string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := defaults[flag]
+	return ok
+}
+
+// Enabled reports whether a feature flag is on for the client
+func Enabled(client localModels.Client, flag string) bool {
+	if enabled, ok := client.Features[flag]; ok {
+		return enabled
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaults[flag]
+}
+
+// AllowsLevel reports whether the client may verify applicants at the given level. Clients without
+// a list of levels may use any.
+func AllowsLevel(client localModels.Client, level string) bool {
+	return allows(client.AllowedVerificationLevels, level)
+}
+
+// AllowsDocumentType reports whether the client may upload documents of the given type
+func AllowsDocumentType(client localModels.Client, documentType string) bool {
+	return allows(client.Settings.AllowedDocumentTypes, documentType)
+}
+
+func allows(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, allowed := range list {
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package clientconfig
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingLoader returns a fixed client and counts how often it is asked
+type countingLoader struct {
+	client localModels.Client
+	calls  int
+}
+
+func (l *countingLoader) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	l.calls++
+	client := l.client
+	client.ClientID = clientID
+	return client, nil
+}
+
+func newContext(clientID string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	if clientID != "" {
+		c.Set("client_id", clientID)
+	}
+	return c
+}
+
+func TestFromContextLoadsOncePerRequest(t *testing.T) {
+	loader := &countingLoader{client: localModels.Client{AllowedVerificationLevels: []string{"basic"}}}
+	c := newContext("client1")
+	Middleware(loader)(c)
+
+	first, err := FromContext(c)
+	assert.NoError(t, err)
+	second, err := FromContext(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, loader.calls)
+	assert.Equal(t, "client1", first.ClientID)
+	assert.Equal(t, first, second)
+}
+
+func TestFromContextWithoutMiddlewareUsesDefaults(t *testing.T) {
+	client, err := FromContext(newContext("client1"))
+	assert.NoError(t, err)
+	assert.Equal(t, localModels.Client{ClientID: "client1"}, client)
+
+	_, err = FromContext(newContext(""))
+	assert.Error(t, err)
+}
+
+func TestClientID(t *testing.T) {
+	c := newContext("client1")
+	Middleware(&countingLoader{})(c)
+
+	clientID, ok := ClientID(c)
+	assert.True(t, ok)
+	assert.Equal(t, "client1", clientID)
+	clientID, ok = ClientID(c.Request.Context())
+	assert.True(t, ok, "the request's context is tagged with the client")
+	assert.Equal(t, "client1", clientID)
+
+	clientID, ok = ClientID(WithClientID(context.Background(), "client2"))
+	assert.True(t, ok)
+	assert.Equal(t, "client2", clientID)
+	_, ok = ClientID(context.Background())
+	assert.False(t, ok)
+}
+
+func TestEnabled(t *testing.T) {
+	SetDefaults(map[string]bool{"new_flow": false, "fast_checks": true})
+	defer SetDefaults(nil)
+
+	pilot := localModels.Client{Features: map[string]bool{"new_flow": true, "fast_checks": false}}
+	other := localModels.Client{}
+
+	assert.True(t, Enabled(pilot, "new_flow"))
+	assert.False(t, Enabled(pilot, "fast_checks"), "a client can opt out of a flag that is on by default")
+	assert.False(t, Enabled(other, "new_flow"))
+	assert.True(t, Enabled(other, "fast_checks"))
+	assert.False(t, Enabled(other, "undeclared"))
+
+	assert.True(t, Known("new_flow"))
+	assert.False(t, Known("undeclared"))
+}
+
+func TestAllows(t *testing.T) {
+	client := localModels.Client{
+		AllowedVerificationLevels: []string{"basic"},
+		Settings:                  localModels.ClientSettings{AllowedDocumentTypes: []string{"passport"}},
+	}
+	assert.True(t, AllowsLevel(client, "basic"))
+	assert.False(t, AllowsLevel(client, "enhanced"))
+	assert.True(t, AllowsDocumentType(client, "passport"))
+	assert.False(t, AllowsDocumentType(client, "utility_bill"))
+
+	assert.True(t, AllowsLevel(localModels.Client{}, "enhanced"), "clients without a list may use any level")
+}
+
+func TestAllowsIP(t *testing.T) {
+	client := localModels.Client{IPAllowlist: localModels.IPAllowlist{CIDRs: []string{"203.0.113.0/24", "2001:db8::/32"}}}
+	assert.True(t, AllowsIP(client, "203.0.113.7"))
+	assert.True(t, AllowsIP(client, "::ffff:203.0.113.7"))
+	assert.True(t, AllowsIP(client, "2001:db8::1"))
+	assert.False(t, AllowsIP(client, "198.51.100.1"))
+	assert.False(t, AllowsIP(client, "not-an-ip"))
+
+	client.IPAllowlist.Bypass = true
+	assert.True(t, AllowsIP(client, "198.51.100.1"), "a bypassed allowlist lets any address through")
+	assert.True(t, AllowsIP(localModels.Client{}, "198.51.100.1"), "clients without a list accept any address")
+}
+
+func TestParseCIDRs(t *testing.T) {
+	cidrs, err := ParseCIDRs([]string{" 203.0.113.9/24 ", "198.51.100.1", "203.0.113.0/24", "2001:db8::1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"203.0.113.0/24", "198.51.100.1/32", "2001:db8::1/128"}, cidrs)
+
+	_, err = ParseCIDRs([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestRequireAllowedIP(t *testing.T) {
+	loader := &countingLoader{client: localModels.Client{IPAllowlist: localModels.IPAllowlist{CIDRs: []string{"203.0.113.0/24"}}}}
+
+	for ip, allowed := range map[string]bool{"203.0.113.7": true, "198.51.100.1": false} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.RemoteAddr = ip + ":443"
+		c.Set("client_id", "client1")
+		Middleware(loader)(c)
+		RequireAllowedIP()(c)
+
+		assert.Equal(t, !allowed, c.IsAborted(), ip)
+		if !allowed {
+			assert.Equal(t, 403, w.Code)
+			assert.Contains(t, w.Body.String(), IPNotAllowed)
+		}
+	}
+
+	// Support staff acting as the client are not held to its allowlist
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.RemoteAddr = "198.51.100.1:443"
+	c.Set("client_id", "client1")
+	c.Set("impersonation", middleware.Impersonation{AdminID: "admin1", ClientID: "client1"})
+	Middleware(loader)(c)
+	RequireAllowedIP()(c)
+	assert.False(t, c.IsAborted())
+}
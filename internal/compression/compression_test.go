@@ -0,0 +1,143 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/", handlers...)
+	return router
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(s))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestResponsesCompressesLargeBodies(t *testing.T) {
+	large := strings.Repeat("a", 2048)
+	router := setupRouter(Responses(1024), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"items": large})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Less(t, w.Body.Len(), 1024)
+
+	gz, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"items":"`+large+`"}`, string(body))
+}
+
+func TestResponsesLeavesSmallBodies(t *testing.T) {
+	router := setupRouter(Responses(1024), func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, `{"error":"not found"}`, w.Body.String())
+}
+
+func TestResponsesWithoutAcceptEncoding(t *testing.T) {
+	large := strings.Repeat("a", 2048)
+	router := setupRouter(Responses(1024), func(c *gin.Context) {
+		c.String(http.StatusOK, large)
+	})
+
+	for _, accept := range []string{"", "identity", "gzip;q=0"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		router.ServeHTTP(w, req)
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"), accept)
+		assert.Equal(t, large, w.Body.String(), accept)
+	}
+}
+
+func TestRequestsInflatesGzipBodies(t *testing.T) {
+	var received string
+	router := setupRouter(Requests(1024), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		received = string(body)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(gzipped(t, `{"first_name":"Ada"}`)))
+	req.Header.Set("Content-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"first_name":"Ada"}`, received)
+
+	// Plain bodies pass through untouched
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader("plain"))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "plain", received)
+}
+
+func TestRequestsSafeguards(t *testing.T) {
+	router := setupRouter(Requests(1024), func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name               string
+		encoding           string
+		body               []byte
+		expectedStatusCode int
+	}{
+		{name: "Inflates beyond the limit", encoding: "gzip", body: gzipped(t, strings.Repeat("a", 4096)), expectedStatusCode: http.StatusBadRequest},
+		{name: "Not gzip", encoding: "gzip", body: []byte("plain"), expectedStatusCode: http.StatusBadRequest},
+		{name: "Unsupported encoding", encoding: "br", body: []byte("plain"), expectedStatusCode: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+		})
+	}
+}
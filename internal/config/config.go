@@ -13,18 +13,36 @@ import (
 	"github.com/spf13/viper"
 )
 
-func LoadConfig(env string) models.Config {
-	// Create separate Viper instances for YAML and env
+// readYAML loads the YAML config for the given environment, preferring its
+// profile in config/config.yaml over a standalone config/<env>.yaml
+func readYAML(env string) *viper.Viper {
 	yamlV := viper.New()
-	envV := viper.New()
+	settings, ok, err := EffectiveConfig(env)
+	if err != nil {
+		log.Panicf("Error reading YAML config: %v", err)
+	}
+	if ok {
+		if err := yamlV.MergeConfigMap(settings); err != nil {
+			log.Panicf("Error reading YAML config: %v", err)
+		}
+		return yamlV
+	}
 
-	// Load YAML config first
 	yamlV.SetConfigName(env)
 	yamlV.SetConfigType("yaml")
-	yamlV.AddConfigPath("config/")
+	yamlV.AddConfigPath(configDir)
 	if err := yamlV.ReadInConfig(); err != nil {
 		log.Panicf("Error reading YAML config: %v", err)
 	}
+	return yamlV
+}
+
+func LoadConfig(env string) models.Config {
+	// Create separate Viper instances for YAML and env
+	envV := viper.New()
+
+	// Load YAML config first
+	yamlV := readYAML(env)
 
 	var config models.Config
 	if err := yamlV.Unmarshal(&config); err != nil {
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// profilesFile is the single config file holding a base profile and per-environment
+// overlays. Environments without a profile fall back to config/<env>.yaml.
+const profilesFile = "config"
+
+const configDir = "config/"
+
+// ProfileNames lists the environment profiles defined in the profiles file
+func ProfileNames() ([]string, error) {
+	v, err := readProfilesFile()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range v.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// EffectiveConfig returns the configuration for an environment after the base profile and any
+// profiles it extends have been deep-merged. ok is false if the environment has no profile.
+func EffectiveConfig(env string) (settings map[string]interface{}, ok bool, err error) {
+	v, err := readProfilesFile()
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	profiles := v.GetStringMap("profiles")
+	if _, found := profiles[strings.ToLower(env)]; !found {
+		return nil, false, nil
+	}
+
+	merged := map[string]interface{}{}
+	mergeMaps(merged, v.GetStringMap("base"))
+	chain, err := profileChain(profiles, strings.ToLower(env))
+	if err != nil {
+		return nil, false, err
+	}
+	for _, name := range chain {
+		overlay, _ := profiles[name].(map[string]interface{})
+		mergeMaps(merged, overlay)
+	}
+	delete(merged, "extends")
+	return merged, true, nil
+}
+
+// readProfilesFile reads config/config.yaml, returning an os.IsNotExist error if it is absent
+func readProfilesFile() (*viper.Viper, error) {
+	path := filepath.Join(configDir, profilesFile+".yaml")
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return v, nil
+}
+
+// profileChain resolves "extends" so that the most general profile comes first
+func profileChain(profiles map[string]interface{}, env string) ([]string, error) {
+	var chain []string
+	seen := map[string]bool{}
+	for name := env; name != ""; {
+		if seen[name] {
+			return nil, fmt.Errorf("profile %q extends itself", name)
+		}
+		seen[name] = true
+		profile, found := profiles[name].(map[string]interface{})
+		if !found {
+			return nil, fmt.Errorf("profile %q is not defined", name)
+		}
+		chain = append([]string{name}, chain...)
+		parent, _ := profile["extends"].(string)
+		name = strings.ToLower(parent)
+	}
+	return chain, nil
+}
+
+// mergeMaps deep-merges src into dst. Nested maps are merged key by key;
+// any other value in src, including lists, replaces the one in dst.
+func mergeMaps(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if srcMap, ok := value.(map[string]interface{}); ok {
+			dstMap, ok := dst[key].(map[string]interface{})
+			if !ok {
+				dstMap = map[string]interface{}{}
+				dst[key] = dstMap
+			}
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testProfiles = `
+base:
+  server:
+    port: "8080"
+  database:
+    name: base_db
+    cacheExpirationMins: 10
+  aws:
+    region: us-east-1
+  decisions:
+    dualControlLevels: [enhanced-kyc]
+profiles:
+  dev:
+    database:
+      host: localhost
+      name: dev_db
+  staging:
+    extends: dev
+    aws:
+      region: eu-west-1
+    decisions:
+      dualControlLevels: []
+  loop:
+    extends: loop
+`
+
+func writeTestProfiles(t *testing.T) {
+	os.Mkdir("config", 0755)
+	t.Cleanup(func() { os.RemoveAll("config") })
+	os.WriteFile("config/config.yaml", []byte(testProfiles), 0644)
+}
+
+func TestLoadConfigFromProfile(t *testing.T) {
+	writeTestProfiles(t)
+
+	config := LoadConfig("dev")
+
+	assert.Equal(t, "8080", config.Server.Port)
+	assert.Equal(t, "localhost", config.Database.Host)
+	assert.Equal(t, "dev_db", config.Database.Name)
+	assert.Equal(t, "us-east-1", config.AWS.Region)
+}
+
+func TestEffectiveConfigInheritance(t *testing.T) {
+	writeTestProfiles(t)
+
+	settings, ok, err := EffectiveConfig("staging")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	database := settings["database"].(map[string]interface{})
+	assert.Equal(t, "dev_db", database["name"], "inherited from dev")
+	assert.Equal(t, 10, database["cacheexpirationmins"], "inherited from base")
+	assert.Equal(t, "eu-west-1", settings["aws"].(map[string]interface{})["region"])
+	assert.Empty(t, settings["decisions"].(map[string]interface{})["dualcontrollevels"], "lists replace rather than merge")
+	assert.NotContains(t, settings, "extends")
+
+	assert.Empty(t, LoadSettings("staging").Decisions.DualControlLevels)
+}
+
+func TestEffectiveConfigMissingProfile(t *testing.T) {
+	writeTestProfiles(t)
+
+	_, ok, err := EffectiveConfig("prod")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = EffectiveConfig("loop")
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// Redacted replaces the value of a secret that is set
+const Redacted = "[redacted]"
+
+// secretKeys are the parts of setting names that hold credentials or signing keys
+var secretKeys = []string{"secret", "password", "token", "dsn"}
+
+// isSecret reports whether a setting, by its name, holds a credential
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range secretKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of settings, as from EffectiveConfig, with every secret that is set
+// replaced by Redacted and the passwords of connection URLs removed. Empty secrets are kept,
+// so the output still shows which are missing.
+func Redact(settings map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		redacted[key] = redactValue(key, value)
+	}
+	return redacted
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return Redact(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactValue(key, item)
+		}
+		return items
+	case string:
+		if v != "" && isSecret(key) {
+			return Redacted
+		}
+		return RedactURL(v)
+	default:
+		return value
+	}
+}
+
+// RedactURL removes the password from a URL with credentials, such as a MongoDB or Redis
+// connection string. Other strings are returned as they are.
+func RedactURL(value string) string {
+	if !strings.Contains(value, "://") || !strings.Contains(value, "@") {
+		return value
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.User == nil {
+		return value
+	}
+	if _, ok := parsed.User.Password(); ok {
+		parsed.User = url.UserPassword(parsed.User.Username(), "xxxxx")
+	}
+	return parsed.String()
+}
+
+// Summary describes the configuration a server starts with, for its startup log, without
+// any secret. Secrets are only reported as set or not.
+func Summary(env string, cfg models.Config, settings Settings) map[string]string {
+	database := fmt.Sprintf("%s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.Name)
+	if cfg.Database.UseAtlas {
+		database = RedactURL(cfg.Database.AtlasConnectionURI) + " (" + cfg.Database.Name + ")"
+	}
+	kms := "aws " + cfg.AWS.KeyID
+	if strings.EqualFold(strings.TrimSpace(settings.KMS.Provider), "vault") {
+		kms = "vault " + settings.KMS.Vault.Address + " key " + settings.KMS.Vault.KeyName
+	}
+	return map[string]string{
+		"env":                          env,
+		"server.port":                  cfg.Server.Port,
+		"database":                     database,
+		"aws.region":                   cfg.AWS.Region,
+		"aws.bucketName":               cfg.AWS.BucketName,
+		"aws.credentials":              isSet(cfg.AWS.AccessKeyID != "" && cfg.AWS.SecretAccessKey != ""),
+		"kms":                          kms,
+		"awsReplay.mode":               settings.AWSReplay.Mode,
+		"egress.proxyURL":              RedactURL(settings.Egress.ProxyURL),
+		"proxies.trusted":              strings.Join(settings.Proxies.Trusted, ","),
+		"sessions.redisURL":            RedactURL(settings.Sessions.RedisURL),
+		"tokens.signingSecret":         isSet(settings.Tokens.SigningSecret != ""),
+		"sessions.signingSecret":       isSet(settings.Sessions.SigningSecret != ""),
+		"pagination.cursorSecret":      isSet(settings.Pagination.CursorSecret != ""),
+		"uploads.direct.signingSecret": isSet(settings.Uploads.Direct.SigningSecret != ""),
+		"sumsub.appToken":              isSet(settings.Sumsub.AppToken != ""),
+		"kms.vault.token":              isSet(settings.KMS.Vault.Token != ""),
+		"errorReporting.provider":      settings.ErrorReporting.Provider,
+		"faultInjection.enabled":       fmt.Sprint(settings.FaultInjection.Enabled),
+	}
+}
+
+func isSet(set bool) string {
+	if set {
+		return "set"
+	}
+	return "not set"
+}
@@ -0,0 +1,636 @@
+package config
+
+import (
+	"log"
+	"time"
+)
+
+// Settings holds configuration owned by this service that is not part of the
+// shared models.Config. It is read from the same per-environment YAML file.
+type Settings struct {
+	// Env is the environment the settings were loaded for, e.g. "sandbox"
+	Env         string              `mapstructure:"-"`
+	Decisions   DecisionSettings    `mapstructure:"decisions"`
+	Uploads     UploadSettings      `mapstructure:"uploads"`
+	Webhooks    WebhookSettings     `mapstructure:"webhooks"`
+	AWSReplay   AWSReplaySettings   `mapstructure:"awsReplay"`
+	GeoIP       GeoIPSettings       `mapstructure:"geoip"`
+	Mongo       MongoSettings       `mapstructure:"mongo"`
+	Vendors     VendorSettings      `mapstructure:"vendorSelection"`
+	Startup     StartupSettings     `mapstructure:"startup"`
+	Metering    MeteringSettings    `mapstructure:"metering"`
+	Sumsub      SumsubSettings      `mapstructure:"sumsub"`
+	Compression CompressionSettings `mapstructure:"compression"`
+	Tokens      TokenSettings       `mapstructure:"tokens"`
+	AuthGuard   AuthGuardSettings   `mapstructure:"authGuard"`
+	// Sessions configures the signed links that let applicants upload their documents on a hosted page
+	Sessions SessionSettings `mapstructure:"sessions"`
+	// RequestSigning configures how signed requests are checked for clients that require them
+	RequestSigning RequestSigningSettings `mapstructure:"requestSigning"`
+	// Diagnostics configures the localhost-only port for profiles and the runtime log level
+	Diagnostics DiagnosticsSettings `mapstructure:"diagnostics"`
+	// Jobs schedules background work such as upload reconciliation, run by one replica at a time
+	Jobs JobSettings `mapstructure:"jobs"`
+	// Backups configures the encrypted client snapshots taken by the client_backup job
+	Backups BackupSettings `mapstructure:"backups"`
+	// Features declares the feature flags clients can be given, and whether each is on by default
+	Features map[string]bool `mapstructure:"features"`
+	// SLOs declares service level objectives and how their error budgets are watched
+	SLOs SLOSettings `mapstructure:"slos"`
+	// FaultInjection fails some requests and webhooks of sandbox clients that opt in
+	FaultInjection FaultInjectionSettings `mapstructure:"faultInjection"`
+	// Pagination configures the cursor tokens list endpoints page with
+	Pagination PaginationSettings `mapstructure:"pagination"`
+	// ColdStorage moves old documents to archival storage and restores them on request
+	ColdStorage ColdStorageSettings `mapstructure:"coldStorage"`
+	// Previews configures the downscaled images of documents that reviewer UIs show
+	Previews PreviewSettings `mapstructure:"previews"`
+	// Reports configures the PDF verification reports of applicants
+	Reports ReportSettings `mapstructure:"reports"`
+	// Egress routes outbound calls through a proxy and lists the addresses they leave from
+	Egress EgressSettings `mapstructure:"egress"`
+	// KMS chooses the key service data keys are made with: AWS KMS or a HashiCorp Vault
+	KMS KMSSettings `mapstructure:"kms"`
+	// Proxies says which load balancers in front of the service may report a request's address
+	Proxies ProxySettings `mapstructure:"proxies"`
+	// Deletion configures how long deleted applicants and documents can be restored
+	Deletion DeletionSettings `mapstructure:"deletion"`
+	// Payloads bounds the JSON bodies of applicant creation and updates
+	Payloads PayloadSettings `mapstructure:"payloads"`
+	// ErrorReporting sends panics recovered while handling a request to Sentry or Rollbar
+	ErrorReporting ErrorReportingSettings `mapstructure:"errorReporting"`
+	// Levels describes the verification levels applicants can be created at, by name. Names
+	// are matched without regard to case. Applicants may be created at any level when empty.
+	Levels map[string]LevelSettings `mapstructure:"levels"`
+}
+
+// LevelSettings describes a verification level to the clients that verify applicants at it
+type LevelSettings struct {
+	// Description says what the level is for
+	Description string `mapstructure:"description"`
+	// DocumentTypes are the documents applicants submit at the level, such as passport or selfie
+	DocumentTypes []string `mapstructure:"documentTypes"`
+	// Checks are what is verified at the level, such as document_authenticity or face_match
+	Checks []string `mapstructure:"checks"`
+	// Countries are the ISO 3166-1 countries of the applicants the level serves. Empty means
+	// every country the client's vendor for the level supports.
+	Countries []string `mapstructure:"countries"`
+	// SLA is how long a decision may take from the applicant's creation. Defaults to
+	// reports.compliance.decisionTarget when zero.
+	SLA time.Duration `mapstructure:"sla"`
+	// Prices are the fee for each applicant verified at the level, as a decimal such as
+	// "1.50", by ISO 4217 currency code
+	Prices map[string]string `mapstructure:"prices"`
+}
+
+// DecisionSettings configures manual verification decisions
+type DecisionSettings struct {
+	// DualControlLevels lists the verification levels whose decisions need a second reviewer to confirm
+	DualControlLevels []string `mapstructure:"dualControlLevels"`
+	// DualControlOnHighRisk requires a second reviewer whenever the applicant has a high severity risk signal
+	DualControlOnHighRisk bool `mapstructure:"dualControlOnHighRisk"`
+}
+
+// UploadSettings configures document storage and the upload_reconciliation job
+type UploadSettings struct {
+	// StagingDir keeps a local copy of each upload until it is in S3. Retries are not possible when empty.
+	StagingDir string `mapstructure:"stagingDir"`
+	// ReconcileGracePeriod leaves recent uploads alone so in-flight requests are not raced
+	ReconcileGracePeriod time.Duration `mapstructure:"reconcileGracePeriod"`
+	// MaxAttempts is the number of S3 upload attempts before a document is marked failed
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// Quarantine keeps new files under a restricted prefix until their record is saved
+	Quarantine QuarantineSettings `mapstructure:"quarantine"`
+	// Lifecycle configures the rules written to the documents bucket at startup
+	Lifecycle LifecycleSettings `mapstructure:"lifecycle"`
+	// Direct lets clients upload large files straight to S3 through presigned URLs
+	Direct DirectUploadSettings `mapstructure:"direct"`
+	// Limits bounds the documents each applicant may have and the uploads each client may run at once
+	Limits UploadLimitSettings `mapstructure:"limits"`
+	// Quality refuses photos of identity documents and selfies no provider could read
+	Quality ImageQualitySettings `mapstructure:"quality"`
+}
+
+// ColdStorageSettings configures the document_cold_storage and document_restore_check jobs
+type ColdStorageSettings struct {
+	// AfterDays moves documents to StorageClass once they are this old. Documents are not archived when zero.
+	AfterDays int `mapstructure:"afterDays"`
+	// StorageClass is GLACIER or DEEP_ARCHIVE. Defaults to GLACIER when empty.
+	StorageClass string `mapstructure:"storageClass"`
+	// RestoreDays is how long a restored copy can be downloaded for. Defaults to 7 when zero.
+	RestoreDays int32 `mapstructure:"restoreDays"`
+	// RestoreTier is Expedited, Standard or Bulk, trading cost for how soon a restore completes. Defaults to Standard when empty.
+	RestoreTier string `mapstructure:"restoreTier"`
+	// BatchSize is the most documents each run archives. Defaults to 100 when zero.
+	BatchSize int64 `mapstructure:"batchSize"`
+}
+
+// PreviewSettings configures document previews and the web pages that may read them
+type PreviewSettings struct {
+	// MaxDimension is the longest side of a preview in pixels. Defaults to 800 when zero.
+	MaxDimension int `mapstructure:"maxDimension"`
+	// MaxAge is how long browsers may keep a preview before asking again. Defaults to 5 minutes when zero.
+	MaxAge time.Duration `mapstructure:"maxAge"`
+	// MaxFileBytes bounds the files previews are drawn from. Defaults to 20 MiB when zero.
+	MaxFileBytes int64 `mapstructure:"maxFileBytes"`
+	// AllowedOrigins are the origins whose pages may read previews, such as https://review.example.com. "*" allows any.
+	AllowedOrigins []string `mapstructure:"allowedOrigins"`
+}
+
+// ReportSettings configures applicant verification reports and the applicant_report job
+type ReportSettings struct {
+	// InlineMaxDocuments is the most documents an applicant may have for its report to be generated
+	// during the request. Larger reports are generated by the applicant_report job. Defaults to 10 when zero.
+	InlineMaxDocuments int `mapstructure:"inlineMaxDocuments"`
+	// MaxThumbnails is the most documents a report shows thumbnails of. Defaults to 50 when zero.
+	MaxThumbnails int `mapstructure:"maxThumbnails"`
+	// Retention is how long a report generated in the background can be fetched. Defaults to 24 hours when zero.
+	Retention time.Duration `mapstructure:"retention"`
+	// BatchSize is the most reports each run of the job generates. Defaults to 20 when zero.
+	BatchSize int64 `mapstructure:"batchSize"`
+	// Compliance configures clients' compliance reports and the compliance_report job
+	Compliance ComplianceReportSettings `mapstructure:"compliance"`
+}
+
+// ComplianceReportSettings configures the compliance reports clients file with their regulator
+type ComplianceReportSettings struct {
+	// MaxRange is the longest period a report may cover. Defaults to 366 days when zero.
+	MaxRange time.Duration `mapstructure:"maxRange"`
+	// DecisionTarget is the time from creation to decision reports measure applicants against.
+	// Defaults to 24 hours when zero.
+	DecisionTarget time.Duration `mapstructure:"decisionTarget"`
+	// MaxErasures is the most erasures a report lists one by one. Defaults to 50000 when zero.
+	MaxErasures int64 `mapstructure:"maxErasures"`
+	// Retention is how long a report can be downloaded once generated. Defaults to 7 days when zero.
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// EgressSettings configures how webhooks and provider API calls leave the service
+type EgressSettings struct {
+	// ProxyURL is the HTTP(S) proxy or NAT gateway outbound calls are sent through, e.g.
+	// http://egress-proxy:3128. HTTPS_PROXY, HTTP_PROXY and NO_PROXY are followed when empty.
+	ProxyURL string `mapstructure:"proxyURL"`
+	// NoProxy lists the hosts called directly rather than through ProxyURL, in the form of NO_PROXY
+	NoProxy string `mapstructure:"noProxy"`
+	// IPRanges are the static addresses or CIDR ranges outbound calls leave from, published at
+	// /.well-known/egress-ips for clients to allowlist
+	IPRanges []string `mapstructure:"ipRanges"`
+}
+
+// ProxySettings decides the address a request is taken to come from, which IP allowlists,
+// IP bans and audit entries use. X-Forwarded-For is only believed from a trusted proxy; any
+// other request is taken to come from the address of its connection.
+type ProxySettings struct {
+	// Trusted lists the addresses or CIDR ranges of the load balancers in front of the service
+	Trusted []string `mapstructure:"trusted"`
+	// Platform is a header the hosting platform sets to the client's address, such as
+	// CF-Connecting-IP, read before X-Forwarded-For. It is sent on by anyone, so only set it
+	// when the service cannot be reached except through the platform.
+	Platform string `mapstructure:"platform"`
+}
+
+// DeletionSettings configures restoring soft-deleted applicants and documents
+type DeletionSettings struct {
+	// RestoreGracePeriod is how long after deletion a client may restore an applicant or document. Defaults to 30 days when zero.
+	RestoreGracePeriod time.Duration `mapstructure:"restoreGracePeriod"`
+}
+
+// PayloadSettings bounds the JSON bodies of applicant creation and updates
+type PayloadSettings struct {
+	// MaxBytes is the largest body, once inflated. Defaults to 64KB when zero.
+	MaxBytes int64 `mapstructure:"maxBytes"`
+	// MaxDepth is how deeply objects and arrays may be nested. Defaults to 8 when zero.
+	MaxDepth int `mapstructure:"maxDepth"`
+	// MaxFields is how many object members and array elements a body may have. Defaults to 500 when zero.
+	MaxFields int `mapstructure:"maxFields"`
+}
+
+// ErrorReportingSettings chooses where panics recovered while handling a request are
+// reported. They are logged whichever is chosen.
+type ErrorReportingSettings struct {
+	// Provider is "sentry", "rollbar", or empty to only log panics
+	Provider string `mapstructure:"provider"`
+	// DSN is the Sentry project's DSN. SENTRY_DSN is used when empty.
+	DSN string `mapstructure:"dsn"`
+	// AccessToken is a Rollbar project token with the post_server_item scope. ROLLBAR_ACCESS_TOKEN is used when empty.
+	AccessToken string `mapstructure:"accessToken"`
+	// Environment tags each report. Defaults to the environment the settings were loaded for.
+	Environment string `mapstructure:"environment"`
+	// Timeout bounds each report. Defaults to 5 seconds when zero.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// KMSSettings chooses the key service that makes and opens the data keys of applicants and files
+type KMSSettings struct {
+	// Provider is "aws" for AWS KMS with the key in AWS_KEY_ID, or "vault" for the transit
+	// engine of a HashiCorp Vault. Defaults to "aws" when empty.
+	Provider string        `mapstructure:"provider"`
+	Vault    VaultSettings `mapstructure:"vault"`
+}
+
+// VaultSettings locates the Vault transit key used when the KMS provider is "vault"
+type VaultSettings struct {
+	// Address is the Vault server, e.g. https://vault.internal:8200
+	Address string `mapstructure:"address"`
+	// Token authenticates to Vault. VAULT_TOKEN is used when empty.
+	Token string `mapstructure:"token"`
+	// Namespace is the Vault Enterprise namespace the transit engine is in, if any
+	Namespace string `mapstructure:"namespace"`
+	// Mount is the path the transit engine is mounted at. Defaults to "transit" when empty.
+	Mount string `mapstructure:"mount"`
+	// KeyName is the transit key data keys are made under
+	KeyName string `mapstructure:"keyName"`
+	// Timeout bounds each call to Vault. Defaults to 10 seconds when zero.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// QuarantineSettings configures where uploads wait before they are moved to their permanent key
+type QuarantineSettings struct {
+	// Enabled uploads files under Prefix first. Files go straight to their permanent key when false.
+	Enabled bool `mapstructure:"enabled"`
+	// Prefix is the part of the documents bucket quarantined files are kept in. Defaults to "quarantine/" when empty.
+	Prefix string `mapstructure:"prefix"`
+}
+
+// DirectUploadSettings configures presigned uploads, which need the upload quarantine to be enabled
+type DirectUploadSettings struct {
+	// SigningSecret signs the upload tokens handed out with presigned URLs. Every instance must
+	// share it. Direct uploads are disabled when empty.
+	SigningSecret string `mapstructure:"signingSecret"`
+	// URLTTL is how long a presigned URL can be used. Defaults to 15 minutes when zero.
+	URLTTL time.Duration `mapstructure:"urlTTL"`
+	// MaxBytes is the largest file that can be uploaded directly. Defaults to 100 MB when zero.
+	MaxBytes int64 `mapstructure:"maxBytes"`
+}
+
+// UploadLimitSettings configures the limits on uploads that keep one client or applicant
+// from taking more than its share
+type UploadLimitSettings struct {
+	// MaxConcurrentPerClient is how many uploads each client may have in progress on an
+	// instance at once. Unlimited when zero.
+	MaxConcurrentPerClient int `mapstructure:"maxConcurrentPerClient"`
+	// Default limits the documents of applicants at levels without their own limit
+	Default ApplicantDocumentLimit `mapstructure:"default"`
+	// Levels limits the documents of applicants at each verification level. Level names are
+	// matched without regard to case.
+	Levels map[string]ApplicantDocumentLimit `mapstructure:"levels"`
+}
+
+// ImageQualitySettings configures the checks that refuse blurry, glared, low resolution or
+// cropped photos as they are uploaded
+type ImageQualitySettings struct {
+	// Enabled runs the checks on PNG and JPEG uploads of identity documents and selfies
+	Enabled bool `mapstructure:"enabled"`
+	// MinShortSide is the shortest side, in pixels, a photo may have. Defaults to 600 when zero.
+	MinShortSide int `mapstructure:"minShortSide"`
+	// MinSharpness is the lowest variance of the Laplacian of a photo scaled to 1000 pixels
+	// along its longer side. Defaults to 50 when zero.
+	MinSharpness float64 `mapstructure:"minSharpness"`
+	// MaxGlare is the largest share of a photo, from 0 to 1, that may be blown out to white.
+	// Defaults to 0.1 when zero.
+	MaxGlare float64 `mapstructure:"maxGlare"`
+}
+
+// ApplicantDocumentLimit bounds the documents an applicant may have. Zero fields are unlimited.
+type ApplicantDocumentLimit struct {
+	// MaxDocuments is how many documents an applicant may have, not counting deleted ones
+	MaxDocuments int `mapstructure:"maxDocuments"`
+	// MaxBytes is the total size of an applicant's current document files
+	MaxBytes int64 `mapstructure:"maxBytes"`
+}
+
+// LifecycleSettings configures the documents bucket's lifecycle rules
+type LifecycleSettings struct {
+	// Manage writes the rules below to the bucket at startup. Rules the service did not write are kept.
+	Manage bool `mapstructure:"manage"`
+	// QuarantineExpireDays removes quarantined files that were never moved. Defaults to 7 when zero.
+	QuarantineExpireDays int32 `mapstructure:"quarantineExpireDays"`
+	// ArchiveAfterDays moves documents to ArchiveStorageClass once they are this old. Documents stay where they are when zero.
+	ArchiveAfterDays int32 `mapstructure:"archiveAfterDays"`
+	// ArchiveStorageClass defaults to GLACIER_IR, which can still be downloaded from without a restore
+	ArchiveStorageClass string `mapstructure:"archiveStorageClass"`
+}
+
+// WebhookSettings configures delivery of client webhook events
+type WebhookSettings struct {
+	// DeliveryInterval is how often pending events are delivered. Delivery is disabled when zero.
+	DeliveryInterval time.Duration `mapstructure:"deliveryInterval"`
+	// MaxAttempts is the number of delivery attempts before an event is given up on
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// Timeout bounds each delivery request
+	Timeout time.Duration `mapstructure:"timeout"`
+	// FailureAlertRate is the share of a client's events in FailureAlertWindow that may be
+	// given up on before an alert is raised. Alerts are off when zero.
+	FailureAlertRate      float64       `mapstructure:"failureAlertRate"`
+	FailureAlertWindow    time.Duration `mapstructure:"failureAlertWindow"`
+	FailureAlertMinEvents int           `mapstructure:"failureAlertMinEvents"` // Fewer events in the window never raise an alert
+}
+
+// JobSettings configures the scheduled jobs framework
+type JobSettings struct {
+	// Schedules maps a job name to a cron expression, evaluated in UTC. Jobs without a
+	// schedule only run when triggered from the admin API.
+	Schedules map[string]string `mapstructure:"schedules"`
+	// PollInterval is how often each replica looks for due jobs. Defaults to 15 seconds when zero.
+	PollInterval time.Duration `mapstructure:"pollInterval"`
+	// LockTTL is how long a job's lock outlives a replica that stops renewing it. Defaults to 5 minutes when zero.
+	LockTTL time.Duration `mapstructure:"lockTTL"`
+	// RunRetention is how long run history is kept. Defaults to 30 days when zero.
+	RunRetention time.Duration `mapstructure:"runRetention"`
+}
+
+// BackupSettings configures encrypted, client-scoped snapshots for disaster recovery
+type BackupSettings struct {
+	// Bucket is the S3 bucket snapshots are written to, apart from the documents bucket. Backups are disabled when empty.
+	Bucket string `mapstructure:"bucket"`
+}
+
+// MeteringSettings configures export of billable usage to an external billing system
+type MeteringSettings struct {
+	// BillingURL receives batches of usage events from the usage_export job. Usage is only stored locally when empty.
+	BillingURL string `mapstructure:"billingURL"`
+}
+
+// SumsubSettings configures pulling applicant state back from Sumsub
+type SumsubSettings struct {
+	// BaseURL is Sumsub's API. Defaults to https://api.sumsub.com when empty.
+	BaseURL string `mapstructure:"baseURL"`
+	// AppToken and SecretKey authenticate API calls. Syncing is disabled when AppToken is empty.
+	AppToken  string `mapstructure:"appToken"`
+	SecretKey string `mapstructure:"secretKey"`
+	// StaleAfter is how long the sumsub_sync job leaves an applicant before syncing it again. Defaults to 1 hour when zero.
+	StaleAfter time.Duration `mapstructure:"staleAfter"`
+	// BatchSize bounds how many applicants one run of the sumsub_sync job syncs. Defaults to 100 when zero.
+	BatchSize int `mapstructure:"batchSize"`
+}
+
+// CompressionSettings configures gzip compression of requests and responses
+type CompressionSettings struct {
+	// MinResponseBytes is the smallest list response that is gzipped. Defaults to 1KB when zero.
+	MinResponseBytes int `mapstructure:"minResponseBytes"`
+	// MaxRequestBytes bounds a gzip request body once inflated. Defaults to 32MB when zero.
+	MaxRequestBytes int64 `mapstructure:"maxRequestBytes"`
+}
+
+// TokenSettings configures the JWTs issued for dashboard sessions
+type TokenSettings struct {
+	// SigningSecret signs access tokens. The token endpoint is disabled when empty.
+	SigningSecret string `mapstructure:"signingSecret"`
+	// AccessTTL is how long an access token is valid. Defaults to 15 minutes when zero.
+	AccessTTL time.Duration `mapstructure:"accessTTL"`
+	// RefreshTTL is how long the refresh tokens of a sign-in are valid, counted from the
+	// sign-in rather than each refresh. Defaults to 30 days when zero.
+	RefreshTTL time.Duration `mapstructure:"refreshTTL"`
+}
+
+// SessionSettings configures hosted verification sessions
+type SessionSettings struct {
+	// SigningSecret signs session tokens. Sessions cannot be created when empty.
+	SigningSecret string `mapstructure:"signingSecret"`
+	// HostedURL is the hosted page that session links open, given the token as its "session" query parameter
+	HostedURL string `mapstructure:"hostedURL"`
+	// TTL is how long a session lasts when the client does not say. Defaults to 1 hour when zero.
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxTTL is the longest a client may ask for. Defaults to 7 days when zero.
+	MaxTTL time.Duration `mapstructure:"maxTTL"`
+	// RedisURL is the Redis server, as redis://[user:password@]host:port, that replicas share state through.
+	// Hosted pages receive session events that happen on other replicas through it, and revoked access tokens,
+	// the nonces of signed requests and upload progress are kept in it. Without it pages only receive events of
+	// their own replica, and the rest is kept in MongoDB.
+	RedisURL string `mapstructure:"redisURL"`
+	// EventsChannel is the Redis channel session events are published on. Defaults to verus:session_events when empty.
+	EventsChannel string `mapstructure:"eventsChannel"`
+}
+
+// RequestSigningSettings configures the checks on signed requests
+type RequestSigningSettings struct {
+	// MaxClockSkew is how far a request's timestamp may be from the server's clock. Defaults to 5 minutes when zero.
+	MaxClockSkew time.Duration `mapstructure:"maxClockSkew"`
+	// MaxBodyBytes is the largest body a signed request may have, as it is held in memory
+	// while the signature is checked. Defaults to 20 MiB when zero.
+	MaxBodyBytes int64 `mapstructure:"maxBodyBytes"`
+}
+
+// AuthGuardSettings configures how failed authentication is throttled and how API key misuse is detected
+type AuthGuardSettings struct {
+	// FailureWindow is how long failed attempts from an IP or key prefix are remembered
+	FailureWindow time.Duration `mapstructure:"failureWindow"`
+	// DelayAfter is the number of failures after which responses are delayed, doubling with each failure
+	DelayAfter int `mapstructure:"delayAfter"`
+	// MaxDelay caps the delay
+	MaxDelay time.Duration `mapstructure:"maxDelay"`
+	// BanAfter is the number of failures after which the source is refused for BanDuration
+	BanAfter    int           `mapstructure:"banAfter"`
+	BanDuration time.Duration `mapstructure:"banDuration"`
+	// RateFloor is the requests per minute a key may always make without raising an alert
+	RateFloor int64 `mapstructure:"rateFloor"`
+	// RateFactor raises an alert when a key's requests in a minute exceed this multiple of its hourly average
+	RateFactor float64 `mapstructure:"rateFactor"`
+}
+
+// AWSReplaySettings records S3 and KMS calls to a cassette, or replays them without contacting AWS
+type AWSReplaySettings struct {
+	// Mode is "record", "replay", or empty to call AWS directly
+	Mode string `mapstructure:"mode"`
+	// Cassette is the path of the recording file
+	Cassette string `mapstructure:"cassette"`
+}
+
+// GeoIPSettings configures IP geolocation of applicants
+type GeoIPSettings struct {
+	// Database is a CSV file of "cidr,country" ranges. Geolocation is skipped when empty.
+	Database string `mapstructure:"database"`
+}
+
+// MongoSettings bounds how writes are retried while the database is unavailable, e.g. during a primary election
+type MongoSettings struct {
+	// WriteAttempts is the number of attempts per write, including the first
+	WriteAttempts int `mapstructure:"writeAttempts"`
+	// RetryBaseDelay is the delay before the first retry, doubled for each one after up to RetryMaxDelay
+	RetryBaseDelay time.Duration `mapstructure:"retryBaseDelay"`
+	RetryMaxDelay  time.Duration `mapstructure:"retryMaxDelay"`
+	// RetryAfter is sent to clients with the 503 returned once retries are exhausted
+	RetryAfter time.Duration `mapstructure:"retryAfter"`
+	// Pool sizes the connection pool kept to each server. The driver's defaults apply to zero values.
+	Pool MongoPoolSettings `mapstructure:"pool"`
+	// ConnectTimeout bounds opening a connection, and ServerSelectionTimeout finding a server
+	// an operation can run on, such as the primary during an election
+	ConnectTimeout         time.Duration `mapstructure:"connectTimeout"`
+	ServerSelectionTimeout time.Duration `mapstructure:"serverSelectionTimeout"`
+	// ReadPreference is where reads go: primary, primaryPreferred, secondary,
+	// secondaryPreferred or nearest. Defaults to primary when empty.
+	ReadPreference string `mapstructure:"readPreference"`
+	// SlowQueryThreshold logs commands that take longer, with their collection and filter
+	// without its values. Slow queries are not logged when zero.
+	SlowQueryThreshold time.Duration `mapstructure:"slowQueryThreshold"`
+	// Transactions is auto, required or off. Writes that belong together, such as an applicant
+	// and its usage, are made in a transaction when the deployment is a replica set or sharded
+	// cluster, and one after the other otherwise. required refuses to start without them, and
+	// off never uses them. Defaults to auto.
+	Transactions string `mapstructure:"transactions"`
+	// SecondaryReads lets list endpoints and exports read from secondaries
+	SecondaryReads SecondaryReadSettings `mapstructure:"secondaryReads"`
+}
+
+// SecondaryReadSettings sends the reads of some list endpoints, and of compliance exports, to
+// secondaries so bulk reads do not load the primary. Writes always go to the primary, and so
+// do the reads of a client that wrote within MaxStaleness, so they see their own writes.
+type SecondaryReadSettings struct {
+	// Routes are the endpoints that may read from secondaries, as method and route, e.g.
+	// "GET /api/v2/applicants"
+	Routes []string `mapstructure:"routes"`
+	// Mode is secondary or secondaryPreferred, which falls back to the primary when no
+	// secondary is available. Defaults to secondaryPreferred.
+	Mode string `mapstructure:"mode"`
+	// MaxStaleness is how far behind the primary a secondary may be to be read from. The
+	// driver requires at least 90s. Defaults to 90s.
+	MaxStaleness time.Duration `mapstructure:"maxStaleness"`
+	// Exports reads compliance report exports from secondaries
+	Exports bool `mapstructure:"exports"`
+}
+
+// MongoPoolSettings sizes the connection pool the driver keeps to each server
+type MongoPoolSettings struct {
+	// MaxSize is the most connections open at once; operations wait for one beyond it
+	MaxSize uint64 `mapstructure:"maxSize"`
+	// MinSize is the number of connections kept open while idle
+	MinSize uint64 `mapstructure:"minSize"`
+	// MaxConnecting is the most connections being opened at once
+	MaxConnecting uint64 `mapstructure:"maxConnecting"`
+	// MaxIdleTime closes connections unused for longer
+	MaxIdleTime time.Duration `mapstructure:"maxIdleTime"`
+}
+
+// VendorSettings selects the identity verification vendor used for each client
+type VendorSettings struct {
+	// Default is the vendor for clients without a rule. Vendor selection is disabled when empty.
+	Default string `mapstructure:"default"`
+	// Providers lists the available vendors and what they can verify
+	Providers map[string]ProviderSettings `mapstructure:"providers"`
+	// Clients pins clients, optionally per verification level, to a vendor
+	Clients []VendorRule `mapstructure:"clients"`
+	// Health configures the checks that tell when a vendor is down or slow
+	Health VendorHealthSettings `mapstructure:"health"`
+}
+
+// ProviderSettings describes what a vendor can verify. Empty lists mean no restriction.
+type ProviderSettings struct {
+	DocumentTypes []string `mapstructure:"documentTypes"`
+	Countries     []string `mapstructure:"countries"`
+	// HealthURL is polled to tell whether the vendor is up. A vendor without one is always taken to be up.
+	HealthURL string `mapstructure:"healthURL"`
+}
+
+// VendorRule assigns a vendor to a client. A rule with a level only applies to that verification level.
+type VendorRule struct {
+	ClientID string `mapstructure:"clientId"`
+	Level    string `mapstructure:"level"`
+	Vendor   string `mapstructure:"vendor"`
+	// Fallbacks receive new submissions, in order, while the vendors before them are down or slow.
+	// A client without fallbacks stays with its vendor whatever its health.
+	Fallbacks []string `mapstructure:"fallbacks"`
+	// Countries replaces Vendor and Fallbacks for documents issued by the countries it lists
+	Countries []CountryRoute `mapstructure:"countries"`
+}
+
+// CountryRoute lists the vendors, in order of preference, for documents issued by some countries
+type CountryRoute struct {
+	Countries []string `mapstructure:"countries"`
+	Vendors   []string `mapstructure:"vendors"`
+}
+
+// VendorHealthSettings configures how vendors' health URLs are checked
+type VendorHealthSettings struct {
+	// Interval between checks of each vendor. Defaults to 30 seconds when zero.
+	Interval time.Duration `mapstructure:"interval"`
+	// Timeout bounds each check. Defaults to 5 seconds when zero.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// SlowAfter is the response time past which a check counts as failed. Defaults to 2 seconds when zero.
+	SlowAfter time.Duration `mapstructure:"slowAfter"`
+	// DownAfter is how many checks in a row must fail before new submissions avoid the vendor. Defaults to 3 when zero.
+	DownAfter int `mapstructure:"downAfter"`
+}
+
+// DiagnosticsSettings configures the port that serves profiles, expvar counters, goroutine stacks and the log level
+type DiagnosticsSettings struct {
+	// Addr is a loopback host and port to serve on. Diagnostics are not served when empty.
+	Addr string `mapstructure:"addr"`
+	// LogLevel is the log level at boot, which can be changed at runtime. Defaults to info when empty.
+	LogLevel string `mapstructure:"logLevel"`
+	// LogSampling bounds how often a debug entry with the same message is written
+	LogSampling LogSamplingSettings `mapstructure:"logSampling"`
+}
+
+// LogSamplingSettings samples debug entries per message: the first First in each Tick,
+// then every Thereafter-th. Sampling is off when Tick is zero.
+type LogSamplingSettings struct {
+	Tick       time.Duration `mapstructure:"tick"`
+	First      int           `mapstructure:"first"`
+	Thereafter int           `mapstructure:"thereafter"`
+}
+
+// StartupSettings bounds how long the server waits at boot for its dependencies before giving up
+type StartupSettings struct {
+	// Attempts is the number of times each dependency is checked, including the first
+	Attempts int `mapstructure:"attempts"`
+	// RetryDelay is the delay before the first retry, doubled for each one after
+	RetryDelay time.Duration `mapstructure:"retryDelay"`
+	// Timeout bounds each check
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// LoadSettings loads the service settings for the given environment
+func LoadSettings(env string) Settings {
+	var settings Settings
+	if err := readYAML(env).Unmarshal(&settings); err != nil {
+		log.Panicf("Unable to decode YAML into settings: %v", err)
+	}
+	settings.Env = env
+	return settings
+}
+
+// SLOSettings declares service level objectives, tracked from the request and provider
+// figures of each instance
+type SLOSettings struct {
+	// CheckInterval is how often burn rates are checked. SLOs are not tracked when zero.
+	CheckInterval time.Duration `mapstructure:"checkInterval"`
+	// MinEvents is the fewest events in a window that may raise an alert, so a quiet
+	// instance does not alert on its first failure
+	MinEvents int `mapstructure:"minEvents"`
+	// Objectives lists the SLOs
+	Objectives []ObjectiveSettings `mapstructure:"objectives"`
+}
+
+// ObjectiveSettings is one SLO: the share of events in its series that must be good
+type ObjectiveSettings struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	// Series names the events, as request routes ("POST /api/v2/applicants/:id/documents")
+	// or provider calls ("webhook.deliver"). Requests answered with a 4xx are not counted.
+	Series []string `mapstructure:"series"`
+	// Target is the share of events that must be good, e.g. 0.99
+	Target float64 `mapstructure:"target"`
+	// Latency makes events slower than it bad. When zero, only failed events are bad.
+	Latency time.Duration `mapstructure:"latency"`
+}
+
+// FaultInjectionSettings injects failures into the requests and webhook deliveries of
+// sandbox clients that opt in with settings.sandbox.inject_faults, so they can check how
+// their integration retries. It is refused outside the sandbox environment.
+type FaultInjectionSettings struct {
+	Enabled bool `mapstructure:"enabled"`
+	// LatencyRate is the share of requests delayed by up to MaxLatency
+	LatencyRate float64       `mapstructure:"latencyRate"`
+	MaxLatency  time.Duration `mapstructure:"maxLatency"`
+	// ErrorRate is the share of requests answered with a 500, 502, 503 or 504 instead of being handled
+	ErrorRate float64 `mapstructure:"errorRate"`
+	// WebhookDropRate is the share of webhook deliveries not sent, and retried as if the endpoint had failed
+	WebhookDropRate float64 `mapstructure:"webhookDropRate"`
+}
+
+// PaginationSettings configures the cursor tokens of list endpoints
+type PaginationSettings struct {
+	// CursorSecret signs cursors. Every instance must share it for cursors to work across
+	// them; each instance signs with a random key of its own when empty.
+	CursorSecret string `mapstructure:"cursorSecret"`
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSettings(t *testing.T) {
+	configContent := `
+server:
+  port: "8080"
+decisions:
+  dualControlLevels:
+    - enhanced-kyc
+    - business
+  dualControlOnHighRisk: true
+`
+	configDir := "config"
+	os.Mkdir(configDir, 0755)
+	defer os.RemoveAll(configDir)
+	os.WriteFile(configDir+"/settings-test.yaml", []byte(configContent), 0644)
+
+	settings := LoadSettings("settings-test")
+
+	assert.Equal(t, []string{"enhanced-kyc", "business"}, settings.Decisions.DualControlLevels)
+	assert.True(t, settings.Decisions.DualControlOnHighRisk)
+}
+
+func TestLoadJobSettings(t *testing.T) {
+	configContent := `
+jobs:
+  schedules:
+    upload_reconciliation: "* * * * *"
+    usage_export: "*/5 * * * *"
+  lockTTL: 2m
+`
+	configDir := "config"
+	os.Mkdir(configDir, 0755)
+	defer os.RemoveAll(configDir)
+	os.WriteFile(configDir+"/jobs-test.yaml", []byte(configContent), 0644)
+
+	settings := LoadSettings("jobs-test")
+
+	assert.Equal(t, map[string]string{
+		"upload_reconciliation": "* * * * *",
+		"usage_export":          "*/5 * * * *",
+	}, settings.Jobs.Schedules)
+	assert.Equal(t, 2*time.Minute, settings.Jobs.LockTTL)
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+var (
+	// awsRegion matches region names such as eu-west-1, us-gov-east-1 and cn-north-1
+	awsRegion = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-\d+$`)
+	// bucketName matches the names S3 allows for general purpose buckets
+	bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	// kmsKey matches a KMS key or alias by ARN, a key ID, or an alias name
+	kmsKey = regexp.MustCompile(`^(arn:aws[a-z-]*:kms:[a-z0-9-]+:\d{12}:(key/[A-Za-z0-9-]+|alias/[A-Za-z0-9/_-]+)|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|mrk-[0-9a-f]{32}|alias/[A-Za-z0-9/_-]+)$`)
+)
+
+// ValidationError lists every setting that stops the service from starting, so a
+// deployment can be fixed in one go
+type ValidationError struct {
+	Env      string
+	Problems []string // Each names the setting, e.g. "aws.region: ..."
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration for %s: %s", e.Env, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the configuration of an environment before anything connects with it.
+// Settings needed by every server are required, and those with a fixed format are checked,
+// so a bad deployment fails at boot rather than on the first request that needs them. S3
+// and KMS settings are not required when AWS calls are replayed from a cassette.
+func Validate(env string, cfg models.Config, settings Settings) error {
+	v := &validation{}
+
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		v.add("server.port", "must be a port number, not %q", cfg.Server.Port)
+	}
+
+	if cfg.Database.UseAtlas {
+		uri := cfg.Database.AtlasConnectionURI
+		switch {
+		case uri == "":
+			v.add("database.atlasConnectionURI", "is required when database.useAtlas is true")
+		case !strings.HasPrefix(uri, "mongodb://") && !strings.HasPrefix(uri, "mongodb+srv://"):
+			v.add("database.atlasConnectionURI", "must start with mongodb:// or mongodb+srv://")
+		default:
+			if err := mongoretry.CheckURI(uri); err != nil {
+				v.add("database.atlasConnectionURI", "%v", err)
+			}
+		}
+	} else {
+		v.require("database.host", cfg.Database.Host)
+		if cfg.Database.Port < 1 || cfg.Database.Port > 65535 {
+			v.add("database.port", "must be a port number, not %d", cfg.Database.Port)
+		}
+	}
+	v.require("database.name", cfg.Database.Name)
+
+	switch settings.Mongo.ReadPreference {
+	case "", "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest":
+	default:
+		v.add("mongo.readPreference", "must be primary, primaryPreferred, secondary, secondaryPreferred or nearest, not %q", settings.Mongo.ReadPreference)
+	}
+	switch settings.Mongo.Transactions {
+	case "", "auto", "required", "off":
+	default:
+		v.add("mongo.transactions", "must be auto, required or off, not %q", settings.Mongo.Transactions)
+	}
+	switch settings.Mongo.SecondaryReads.Mode {
+	case "", "secondary", "secondaryPreferred":
+	default:
+		v.add("mongo.secondaryReads.mode", "must be secondary or secondaryPreferred, not %q", settings.Mongo.SecondaryReads.Mode)
+	}
+	if staleness := settings.Mongo.SecondaryReads.MaxStaleness; staleness != 0 && staleness < 90*time.Second {
+		v.add("mongo.secondaryReads.maxStaleness", "must be at least 90s, not %s", staleness)
+	}
+
+	if v.require("aws.region", cfg.AWS.Region) && !awsRegion.MatchString(cfg.AWS.Region) {
+		v.add("aws.region", "must be an AWS region such as us-east-1, not %q", cfg.AWS.Region)
+	}
+
+	switch settings.AWSReplay.Mode {
+	case "", "off", "record":
+	case "replay":
+		if env == "prod" {
+			v.add("awsReplay.mode", "cannot be replay in prod")
+		}
+	default:
+		v.add("awsReplay.mode", "must be record, replay or empty, not %q", settings.AWSReplay.Mode)
+	}
+	if settings.AWSReplay.Mode != "replay" {
+		if v.require("aws.bucketName", cfg.AWS.BucketName) && !bucketName.MatchString(cfg.AWS.BucketName) {
+			v.add("aws.bucketName", "must be an S3 bucket name, not %q", cfg.AWS.BucketName)
+		}
+		v.kms(cfg, settings.KMS)
+	}
+	if settings.Backups.Bucket != "" && !bucketName.MatchString(settings.Backups.Bucket) {
+		v.add("backups.bucket", "must be an S3 bucket name, not %q", settings.Backups.Bucket)
+	}
+
+	for _, proxy := range settings.Proxies.Trusted {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				v.add("proxies.trusted", "must list IP addresses or CIDR ranges, not %q", proxy)
+			}
+		}
+	}
+
+	if glare := settings.Uploads.Quality.MaxGlare; glare < 0 || glare > 1 {
+		v.add("uploads.quality.maxGlare", "must be between 0 and 1, not %v", glare)
+	}
+
+	v.errorReporting(settings.ErrorReporting)
+
+	if settings.FaultInjection.Enabled && env != "sandbox" {
+		v.add("faultInjection.enabled", "can only be true in the sandbox environment")
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Env: env, Problems: v.problems}
+	}
+	return nil
+}
+
+// kms checks the settings of the configured KMS provider
+func (v *validation) kms(cfg models.Config, settings KMSSettings) {
+	switch strings.ToLower(strings.TrimSpace(settings.Provider)) {
+	case "", "aws":
+		if v.require("aws.keyID", cfg.AWS.KeyID) && !kmsKey.MatchString(cfg.AWS.KeyID) {
+			v.add("aws.keyID", "must be a KMS key ID, alias or ARN, not %q", cfg.AWS.KeyID)
+		}
+	case "vault":
+		if v.require("kms.vault.address", settings.Vault.Address) {
+			if address, err := url.Parse(settings.Vault.Address); err != nil || (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+				v.add("kms.vault.address", "must be an http or https URL, not %q", settings.Vault.Address)
+			}
+		}
+		v.require("kms.vault.keyName", settings.Vault.KeyName)
+		if settings.Vault.Token == "" && os.Getenv("VAULT_TOKEN") == "" {
+			v.add("kms.vault.token", "is required, or VAULT_TOKEN")
+		}
+	default:
+		v.add("kms.provider", "must be aws or vault, not %q", settings.Provider)
+	}
+}
+
+// errorReporting checks the settings of the configured error reporting provider
+func (v *validation) errorReporting(settings ErrorReportingSettings) {
+	switch settings.Provider {
+	case "":
+	case "sentry":
+		dsn := settings.DSN
+		if dsn == "" {
+			dsn = os.Getenv("SENTRY_DSN")
+		}
+		if dsn == "" {
+			v.add("errorReporting.dsn", "is required when errorReporting.provider is sentry, or SENTRY_DSN")
+		} else if parsed, err := url.Parse(dsn); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.User.Username() == "" || strings.Trim(parsed.Path, "/") == "" {
+			v.add("errorReporting.dsn", "must be a Sentry DSN such as https://<key>@o0.ingest.sentry.io/<project>")
+		}
+	case "rollbar":
+		if settings.AccessToken == "" && os.Getenv("ROLLBAR_ACCESS_TOKEN") == "" {
+			v.add("errorReporting.accessToken", "is required when errorReporting.provider is rollbar, or ROLLBAR_ACCESS_TOKEN")
+		}
+	default:
+		v.add("errorReporting.provider", "must be sentry, rollbar or empty, not %q", settings.Provider)
+	}
+}
+
+// validation collects the problems found in a configuration
+type validation struct {
+	problems []string
+}
+
+func (v *validation) add(field, format string, args ...any) {
+	v.problems = append(v.problems, field+": "+fmt.Sprintf(format, args...))
+}
+
+// require adds a problem if value is empty and reports whether it was set
+func (v *validation) require(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+// Package consent checks the consent records clients submit for their applicants. A
+// record is taken once, when the applicant is created, and kept unchanged as evidence
+// of what the applicant agreed to; clients can require one before any of an applicant's
+// documents are submitted to a verification vendor.
+package consent
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+const (
+	maxTextVersionLen = 64
+
+	// clockSkew is how far in the future a consent time may be, allowing for the client's clock
+	clockSkew = 5 * time.Minute
+)
+
+// ErrRequired is returned when a client that requires consent creates an applicant without it
+var ErrRequired = apperr.BadRequest("consent_required", "consent is required for this client")
+
+// Validate checks a submitted consent record
+func Validate(consent localModels.Consent, now time.Time) error {
+	switch {
+	case consent.TextVersion == "":
+		return errors.New("consent.text_version is required")
+	case len(consent.TextVersion) > maxTextVersionLen:
+		return fmt.Errorf("consent.text_version must be at most %d characters", maxTextVersionLen)
+	case consent.GivenAt.IsZero():
+		return errors.New("consent.given_at is required")
+	case consent.GivenAt.After(now.Add(clockSkew)):
+		return errors.New("consent.given_at is in the future")
+	case !slices.Contains(localModels.ConsentChannels, consent.Channel):
+		return fmt.Errorf("consent.channel must be one of %v", localModels.ConsentChannels)
+	}
+	if _, err := netip.ParseAddr(consent.IP); err != nil {
+		return errors.New("consent.ip must be a valid IP address")
+	}
+	return nil
+}
+
+// Record checks the consent submitted with a new applicant against the client's settings
+// and returns it ready to store, or nil when none was submitted and none is required
+func Record(client localModels.Client, submitted *localModels.Consent, now time.Time) (*localModels.Consent, error) {
+	if submitted == nil {
+		if client.Settings.RequireConsent {
+			return nil, ErrRequired
+		}
+		return nil, nil
+	}
+	if err := Validate(*submitted, now); err != nil {
+		return nil, err
+	}
+	record := *submitted
+	record.GivenAt = record.GivenAt.UTC()
+	record.RecordedAt = now.UTC()
+	return &record, nil
+}
+
+// Check is the upload check, run for clients that require consent, that keeps documents
+// of applicants without a consent record from being submitted for verification
+func Check(consent *localModels.Consent) localModels.UploadCheck {
+	if consent == nil {
+		return localModels.UploadCheck{
+			Name:   "consent",
+			Status: localModels.UploadCheckFailed,
+			Detail: "applicant has no consent record, so documents cannot be submitted for verification",
+		}
+	}
+	return localModels.UploadCheck{Name: "consent", Status: localModels.UploadCheckPassed, Detail: "text version " + consent.TextVersion}
+}
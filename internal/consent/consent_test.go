@@ -0,0 +1,86 @@
+package consent
+
+import (
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+
+func validConsent() localModels.Consent {
+	return localModels.Consent{
+		TextVersion: "2026-09",
+		GivenAt:     now.Add(-time.Minute),
+		IP:          "203.0.113.7",
+		Channel:     localModels.ConsentWeb,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*localModels.Consent)
+		wantErr string
+	}{
+		{"Valid", func(*localModels.Consent) {}, ""},
+		{"Slightly ahead of our clock", func(c *localModels.Consent) { c.GivenAt = now.Add(time.Minute) }, ""},
+		{"IPv6", func(c *localModels.Consent) { c.IP = "2001:db8::1" }, ""},
+		{"Missing text version", func(c *localModels.Consent) { c.TextVersion = "" }, "consent.text_version is required"},
+		{"Long text version", func(c *localModels.Consent) { c.TextVersion = string(make([]byte, 65)) }, "consent.text_version must be at most 64 characters"},
+		{"Missing time", func(c *localModels.Consent) { c.GivenAt = time.Time{} }, "consent.given_at is required"},
+		{"Future time", func(c *localModels.Consent) { c.GivenAt = now.Add(time.Hour) }, "consent.given_at is in the future"},
+		{"Unknown channel", func(c *localModels.Consent) { c.Channel = "fax" }, "consent.channel must be one of [web mobile in_person phone]"},
+		{"Bad IP", func(c *localModels.Consent) { c.IP = "not-an-ip" }, "consent.ip must be a valid IP address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consent := validConsent()
+			tt.change(&consent)
+			err := Validate(consent, now)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	requiring := localModels.Client{Settings: localModels.ClientSettings{RequireConsent: true}}
+
+	record, err := Record(localModels.Client{}, nil, now)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	_, err = Record(requiring, nil, now)
+	assert.ErrorIs(t, err, ErrRequired)
+
+	submitted := validConsent()
+	submitted.GivenAt = submitted.GivenAt.In(time.FixedZone("CEST", 2*60*60))
+	submitted.RecordedAt = now.Add(-24 * time.Hour)
+	record, err = Record(requiring, &submitted, now)
+	require.NoError(t, err)
+	assert.Equal(t, now, record.RecordedAt, "the recording time is the service's own")
+	assert.Equal(t, time.UTC, record.GivenAt.Location())
+	assert.True(t, record.GivenAt.Equal(submitted.GivenAt))
+
+	submitted.Channel = ""
+	_, err = Record(localModels.Client{}, &submitted, now)
+	assert.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	consent := validConsent()
+	check := Check(&consent)
+	assert.Equal(t, localModels.UploadCheckPassed, check.Status)
+	assert.Equal(t, "text version 2026-09", check.Detail)
+
+	check = Check(nil)
+	assert.Equal(t, "consent", check.Name)
+	assert.Equal(t, localModels.UploadCheckFailed, check.Status)
+}
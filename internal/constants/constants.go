@@ -0,0 +1,32 @@
+package constants
+
+// Collection names owned by this service. Collections shared with other
+// services (e.g. applicants) are defined in verus_backend_core/constants.
+const (
+	CollectionAdminUsers           = "admin_users"
+	CollectionApplicantReports     = "applicant_reports"
+	CollectionAttachments          = "attachments"
+	CollectionAuditLog             = "audit_log"
+	CollectionBackups              = "backups"
+	CollectionClients              = "clients"
+	CollectionClientSecrets        = "client_secrets_table"
+	CollectionComplianceReports    = "compliance_reports"
+	CollectionDashboardUsers       = "dashboard_users"
+	CollectionDecisions            = "decisions"
+	CollectionDocuments            = "documents"
+	CollectionEncryptionKeys       = "encryption_keys"
+	CollectionJobRuns              = "job_runs"
+	CollectionJobs                 = "jobs"
+	CollectionNotes                = "notes"
+	CollectionRefreshTokens        = "refresh_tokens"
+	CollectionRequestNonces        = "request_nonces"
+	CollectionRevokedTokens        = "revoked_tokens"
+	CollectionSigningKeys          = "response_signing_keys"
+	CollectionSumsubSyncs          = "sumsub_syncs"
+	CollectionUploadJobs           = "upload_jobs"
+	CollectionUsageEvents          = "usage_events"
+	CollectionVerificationSessions = "verification_sessions"
+	CollectionWebhookDeadLetters   = "webhook_dead_letters"
+	CollectionWebhookEndpoints     = "webhook_endpoints"
+	CollectionWebhookEvents        = "webhook_events"
+)
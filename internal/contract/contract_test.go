@@ -0,0 +1,1572 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	applicantControllers "github.com/rachel-lawrie/verus_app_backend/internal/applicant/controllers"
+	applicantServices "github.com/rachel-lawrie/verus_app_backend/internal/applicant/services"
+	attachmentControllers "github.com/rachel-lawrie/verus_app_backend/internal/attachment/controllers"
+	attachmentServices "github.com/rachel-lawrie/verus_app_backend/internal/attachment/services"
+	clientControllers "github.com/rachel-lawrie/verus_app_backend/internal/client/controllers"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	documentControllers "github.com/rachel-lawrie/verus_app_backend/internal/document/controllers"
+	documentServices "github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	encryptionKeyControllers "github.com/rachel-lawrie/verus_app_backend/internal/encryptionkey/controllers"
+	encryptionKeyServices "github.com/rachel-lawrie/verus_app_backend/internal/encryptionkey/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/i18n"
+	"github.com/rachel-lawrie/verus_app_backend/internal/jsonlimit"
+	"github.com/rachel-lawrie/verus_app_backend/internal/jsonpatch"
+	"github.com/rachel-lawrie/verus_app_backend/internal/levels"
+	levelControllers "github.com/rachel-lawrie/verus_app_backend/internal/levels/controllers"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	noteControllers "github.com/rachel-lawrie/verus_app_backend/internal/note/controllers"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/preview"
+	rekeyControllers "github.com/rachel-lawrie/verus_app_backend/internal/rekey/controllers"
+	rekeyServices "github.com/rachel-lawrie/verus_app_backend/internal/rekey/services"
+	reportControllers "github.com/rachel-lawrie/verus_app_backend/internal/report/controllers"
+	reportServices "github.com/rachel-lawrie/verus_app_backend/internal/report/services"
+	sessionControllers "github.com/rachel-lawrie/verus_app_backend/internal/session/controllers"
+	sessionServices "github.com/rachel-lawrie/verus_app_backend/internal/session/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/softdelete"
+	statsControllers "github.com/rachel-lawrie/verus_app_backend/internal/stats/controllers"
+	sumsubControllers "github.com/rachel-lawrie/verus_app_backend/internal/sumsub/controllers"
+	sumsubServices "github.com/rachel-lawrie/verus_app_backend/internal/sumsub/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	tokenControllers "github.com/rachel-lawrie/verus_app_backend/internal/token/controllers"
+	tokenServices "github.com/rachel-lawrie/verus_app_backend/internal/token/services"
+	webhookControllers "github.com/rachel-lawrie/verus_app_backend/internal/webhook/controllers"
+	webhookServices "github.com/rachel-lawrie/verus_app_backend/internal/webhook/services"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	coreMocks "github.com/rachel-lawrie/verus_backend_core/mocks"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const specPath = "../../" + DefaultPath
+
+// handlerMocks are the services behind the routes under test
+type handlerMocks struct {
+	applicants  *localMocks.MockApplicantService
+	documents   *localMocks.MockDocumentService
+	attachments *localMocks.MockAttachmentService
+	notes       *localMocks.MockNoteService
+	stats       *localMocks.MockStatsService
+	webhooks    *localMocks.MockWebhookService
+	clients     *localMocks.MockClientService
+	tokens      *localMocks.MockTokenService
+	sumsub      *localMocks.MockSumsubSyncService
+	sessions    *localMocks.MockSessionService
+	reports     *localMocks.MockReportService
+	compliance  *localMocks.MockComplianceReportService
+	rekey       *localMocks.MockRekeyService
+	keys        *localMocks.MockEncryptionKeyService
+}
+
+func newHandlerMocks() *handlerMocks {
+	return &handlerMocks{
+		applicants:  new(localMocks.MockApplicantService),
+		documents:   new(localMocks.MockDocumentService),
+		attachments: new(localMocks.MockAttachmentService),
+		notes:       new(localMocks.MockNoteService),
+		stats:       new(localMocks.MockStatsService),
+		webhooks:    new(localMocks.MockWebhookService),
+		clients:     new(localMocks.MockClientService),
+		tokens:      new(localMocks.MockTokenService),
+		sumsub:      new(localMocks.MockSumsubSyncService),
+		sessions:    new(localMocks.MockSessionService),
+		reports:     new(localMocks.MockReportService),
+		compliance:  new(localMocks.MockComplianceReportService),
+		rekey:       new(localMocks.MockRekeyService),
+		keys:        new(localMocks.MockEncryptionKeyService),
+	}
+}
+
+// fixedLoader returns the same record for every client
+type fixedLoader struct {
+	client localModels.Client
+}
+
+func (l fixedLoader) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	client := l.client
+	client.ClientID = clientID
+	return client, nil
+}
+
+// levelCatalog describes one level, served everywhere
+func levelCatalog() *levels.Catalog {
+	catalog, err := levels.New(config.Settings{Levels: map[string]config.LevelSettings{
+		"basic-kyc-level": {
+			Description:   "Identity document and selfie",
+			DocumentTypes: []string{"passport", "selfie"},
+			Checks:        []string{"document_authenticity", "face_match"},
+			Prices:        map[string]string{"eur": "1.50"},
+		},
+	}})
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// fakeKMS hands out a fixed data key
+type fakeKMS struct{}
+
+func (fakeKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	return bytes.Repeat([]byte{7}, 32), []byte("encrypted-key"), nil
+}
+
+func (fakeKMS) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return plaintext, nil
+}
+
+func (fakeKMS) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	return encrypted, nil
+}
+
+// setupContractRouter mounts the v2 handlers as the router does, with authentication
+// replaced by a fixed client
+func setupContractRouter(m *handlerMocks) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	// The document service is mocked, so handlers are given a collection that is never used
+	documentControllers.DocumentCollection = func() common.CollectionInterface { return new(coreMocks.MockCollection) }
+	router := gin.New()
+	v2 := router.Group("/api/v2")
+	v2.Use(apiversion.Middleware(apiversion.V2))
+
+	v2.POST("/auth/token", func(c *gin.Context) { tokenControllers.IssueToken(c, m.tokens) })
+	v2.POST("/auth/revoke", func(c *gin.Context) { tokenControllers.RevokeToken(c, m.tokens) })
+
+	client := v2.Group("")
+	client.Use(func(c *gin.Context) { c.Set("client_id", "client1") })
+	client.Use(clientconfig.Middleware(fixedLoader{}))
+	limited := jsonlimit.Middleware(jsonlimit.Limits{})
+	client.POST("/applicants", limited, func(c *gin.Context) { applicantControllers.CreateApplicant(c, m.applicants, fakeKMS{}) })
+	client.GET("/applicants", func(c *gin.Context) { applicantControllers.GetAllApplicants(c, m.applicants) })
+	client.GET("/applicants/deleted", func(c *gin.Context) { applicantControllers.ListDeletedApplicants(c, m.applicants) })
+	client.GET("/applicants/:id", func(c *gin.Context) { applicantControllers.GetApplicant(c, m.applicants) })
+	client.PUT("/applicants/:id", limited, func(c *gin.Context) { applicantControllers.UpdateApplicant(c, m.applicants) })
+	client.PATCH("/applicants/:id", limited, func(c *gin.Context) { applicantControllers.PatchApplicant(c, m.applicants) })
+	client.PATCH("/applicants/:id/annotations", limited, func(c *gin.Context) { applicantControllers.UpdateAnnotations(c, m.applicants) })
+	client.POST("/applicants/:id/restore", func(c *gin.Context) { applicantControllers.RestoreApplicant(c, m.applicants) })
+	client.POST("/applicants/from-document", func(c *gin.Context) { documentControllers.CreateApplicantFromDocument(c, m.documents) })
+	client.POST("/applicants/:id/confirm", func(c *gin.Context) { documentControllers.ConfirmApplicant(c, m.documents) })
+	client.POST("/applicants/:id/documents", func(c *gin.Context) { documentControllers.CreateDocument(c, m.documents) })
+	client.POST("/applicants/:id/documents/archive", func(c *gin.Context) { documentControllers.ArchiveDocuments(c, m.documents) })
+	client.POST("/applicants/:id/documents/presign-upload", func(c *gin.Context) { documentControllers.PresignUpload(c, m.documents) })
+	client.POST("/applicants/:id/documents/complete", func(c *gin.Context) { documentControllers.CompleteUpload(c, m.documents) })
+	client.GET("/applicants/:id/documents/:docId", func(c *gin.Context) { documentControllers.GetDocument(c, m.documents) })
+	client.PUT("/applicants/:id/documents/:docId", func(c *gin.Context) { documentControllers.UpdateDocument(c, m.documents) })
+	client.POST("/applicants/:id/documents/:docId/replace", func(c *gin.Context) { documentControllers.ReplaceDocument(c, m.documents) })
+	client.GET("/applicants/:id/documents/:docId/versions", func(c *gin.Context) { documentControllers.GetDocumentVersions(c, m.documents) })
+	client.POST("/applicants/:id/documents/:docId/restore", func(c *gin.Context) { documentControllers.RestoreDocument(c, m.documents) })
+	client.GET("/applicants/:id/documents/:docId/restore", func(c *gin.Context) { documentControllers.GetDocumentRestore(c, m.documents) })
+	client.POST("/applicants/:id/documents/:docId/undelete", func(c *gin.Context) { documentControllers.UndeleteDocument(c, m.documents) })
+	client.GET("/applicants/:id/documents/:docId/preview", func(c *gin.Context) { documentControllers.PreviewDocument(c, m.documents, 0) })
+	client.GET("/applicants/:id/report.pdf", func(c *gin.Context) { reportControllers.GetApplicantReport(c, m.reports) })
+	client.POST("/reports", func(c *gin.Context) { reportControllers.RequestComplianceReport(c, m.compliance) })
+	client.GET("/reports/:id", func(c *gin.Context) { reportControllers.GetComplianceReport(c, m.compliance) })
+	client.POST("/encryption-keys", func(c *gin.Context) { encryptionKeyControllers.RegisterEncryptionKey(c, m.keys) })
+	client.GET("/encryption-keys", func(c *gin.Context) { encryptionKeyControllers.ListEncryptionKeys(c, m.keys) })
+	client.GET("/encryption-keys/:id", func(c *gin.Context) { encryptionKeyControllers.GetEncryptionKey(c, m.keys) })
+	client.POST("/encryption-keys/:id/validate", func(c *gin.Context) { encryptionKeyControllers.ValidateEncryptionKey(c, m.keys) })
+	client.POST("/encryption-keys/:id/activate", func(c *gin.Context) { encryptionKeyControllers.ActivateEncryptionKey(c, m.keys) })
+	client.GET("/documents/jobs/:job_id", func(c *gin.Context) { documentControllers.GetUploadJob(c, m.documents) })
+	client.POST("/applicants/:id/attachments", func(c *gin.Context) { attachmentControllers.AddAttachment(c, m.attachments) })
+	client.GET("/applicants/:id/attachments", func(c *gin.Context) { attachmentControllers.ListAttachments(c, m.attachments) })
+	client.GET("/applicants/:id/attachments/:attachmentId", func(c *gin.Context) { attachmentControllers.DownloadAttachment(c, m.attachments) })
+	client.POST("/applicants/:id/notes", func(c *gin.Context) { noteControllers.AddNote(c, m.notes) })
+	client.GET("/applicants/:id/notes", func(c *gin.Context) { noteControllers.ListNotes(c, m.notes) })
+	client.POST("/applicants/:id/sync", func(c *gin.Context) { sumsubControllers.SyncApplicant(c, m.sumsub) })
+	client.POST("/applicants/:id/rekey", func(c *gin.Context) { rekeyControllers.RekeyApplicant(c, m.rekey) })
+	client.POST("/applicants/:id/sessions", func(c *gin.Context) { sessionControllers.CreateSession(c, m.sessions) })
+	client.GET("/applicants/:id/sessions/:sessionId", func(c *gin.Context) { sessionControllers.GetSession(c, m.sessions) })
+	client.GET("/stats", func(c *gin.Context) { statsControllers.GetStats(c, m.stats) })
+	client.GET("/labels", i18n.ListLabels)
+	client.GET("/levels", func(c *gin.Context) { levelControllers.ListLevels(c, levelCatalog()) })
+	client.GET("/webhook-endpoint", func(c *gin.Context) { webhookControllers.GetWebhookEndpoint(c, m.webhooks) })
+	client.PUT("/webhook-endpoint", func(c *gin.Context) { webhookControllers.SetWebhookEndpoint(c, m.webhooks) })
+	client.POST("/webhook-endpoint/rotate-secret", func(c *gin.Context) { webhookControllers.RotateWebhookSecret(c, m.webhooks) })
+	client.POST("/webhooks/verify", func(c *gin.Context) { webhookControllers.VerifyWebhookSignature(c, m.webhooks) })
+	client.GET("/webhooks/failures", func(c *gin.Context) { webhookControllers.ListWebhookFailures(c, m.webhooks) })
+	client.POST("/webhooks/failures/:id/redeliver", func(c *gin.Context) { webhookControllers.RedeliverWebhook(c, m.webhooks) })
+	client.POST("/sandbox/webhooks/test", func(c *gin.Context) { webhookControllers.TestWebhook(c, m.webhooks) })
+	client.GET("/ip-allowlist", clientControllers.GetOwnIPAllowlist)
+	client.PUT("/ip-allowlist", func(c *gin.Context) { clientControllers.SetOwnIPAllowlist(c, m.clients) })
+
+	hosted := v2.Group("/hosted/session")
+	hosted.Use(sessionControllers.Authenticate(m.sessions))
+	hosted.Use(clientconfig.Middleware(fixedLoader{}))
+	hosted.GET("", func(c *gin.Context) { sessionControllers.GetHostedSession(c, m.sessions) })
+	hosted.POST("/documents", func(c *gin.Context) { sessionControllers.UploadHostedDocument(c, m.sessions, m.documents) })
+	hosted.POST("/complete", func(c *gin.Context) { sessionControllers.CompleteHostedSession(c, m.sessions) })
+	hosted.GET("/handoff.png", func(c *gin.Context) { sessionControllers.GetHandoffCode(c, m.sessions) })
+	hosted.GET("/events", func(c *gin.Context) { sessionControllers.WatchHostedSession(c, m.sessions) })
+	return router
+}
+
+// multipartBody builds an upload form with a file and the given fields
+func multipartBody(t *testing.T, fields map[string]string) (string, string) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	part, err := writer.CreateFormFile("file", "passport.png")
+	require.NoError(t, err)
+	part.Write([]byte("\x89PNG\r\n\x1a\n"))
+	require.NoError(t, writer.Close())
+	return body.String(), writer.FormDataContentType()
+}
+
+// firstPage matches a request for the first page of a list
+func firstPage(limit int64) interface{} {
+	return mock.MatchedBy(func(page pagination.Page) bool { return page.Limit == limit && page.After == nil })
+}
+
+func TestHandlersMatchContract(t *testing.T) {
+	spec, err := Load(specPath)
+	require.NoError(t, err)
+
+	now := time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC)
+	applicant := localModels.ApplicantRecord{
+		Applicant: models.Applicant{ApplicantID: "app1", FirstName: "Ada", LastName: "Lovelace", CreatedAt: now, UpdatedAt: now},
+		Consent:   &localModels.Consent{TextVersion: "2025-01", GivenAt: now, IP: "203.0.113.7", Channel: localModels.ConsentWeb, RecordedAt: now},
+	}
+	document := models.Document{DocumentID: "doc1", ApplicantID: "app1", DocumentType: models.DocumentPassport, Status: models.DocumentVerified, CreatedAt: now, UpdatedAt: now}
+	upload := localModels.UploadResult{
+		DocumentRecord:   localModels.DocumentRecord{Document: document, Version: 2},
+		Checks:           []localModels.UploadCheck{{Name: "file_type", Status: localModels.UploadCheckPassed}},
+		ProcessingStatus: localModels.ProcessingScanPending,
+	}
+	attachment := localModels.Attachment{
+		AttachmentID: "att1", ApplicantID: "app1", ClientID: "client1", FileName: "bill.pdf", MimeType: "application/pdf",
+		FileSize: 4, Visibility: localModels.AttachmentShared, UploadedBy: "client1", UploaderType: localModels.AttachmentUploaderClient, CreatedAt: now,
+	}
+	note := localModels.Note{NoteID: "note1", ApplicantID: "app1", ClientID: "client1", Body: "Called the applicant", Visibility: localModels.NoteShared, AuthorID: "client1", AuthorType: localModels.NoteAuthorClient, CreatedAt: now}
+	endpoint := localModels.WebhookEndpoint{ClientID: "client1", URL: "https://client.example.com/hooks", Secret: "whsec_1", CreatedAt: now, UpdatedAt: now}
+	deletedBy := "client1"
+	deletedApplicant := localModels.DeletedApplicant{
+		ApplicantID: "app1", ClientID: "client1", FirstName: "Ada", LastName: "Lovelace", VerificationLevel: "basic",
+		CreatedAt: now, DeletedAt: now, DeletedBy: &deletedBy, RestorableUntil: now.Add(30 * 24 * time.Hour), SecondsRemaining: 86400, Restorable: true,
+	}
+	deadLetter := localModels.WebhookDeadLetter{
+		EventID: "evt1", ClientID: "client1", Type: localModels.WebhookSecurityAlert, Data: map[string]interface{}{"kind": "api_key_new_country"},
+		Attempts: 8, LastError: "webhook endpoint responded with status 500", CreatedAt: now, FailedAt: now,
+	}
+	uploadForm, uploadType := multipartBody(t, map[string]string{"document_type": "passport"})
+	attachmentForm, attachmentType := multipartBody(t, map[string]string{"description": "Proof of address"})
+	intakeForm, intakeType := multipartBody(t, map[string]string{
+		"document_type": "passport", "country": "SE", "level": "basic",
+		"mrz": "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204159ZE184226B<<<<<10",
+	})
+	noLevelForm, noLevelType := multipartBody(t, map[string]string{"document_type": "passport", "country": "SE"})
+	extracted := localModels.ExtractedIdentity{Source: "mrz", FirstName: "ANNA", MiddleName: "MARIA", LastName: "ERIKSSON", DOB: "1974-08-12", Nationality: "UTO", IssuingCountry: "UTO"}
+	intake := localModels.DocumentIntake{
+		ApplicantID: "app1",
+		Intake:      localModels.ApplicantIntake{State: localModels.IntakeProvisional, DocumentID: "doc1"},
+		Extracted:   extracted,
+		Upload:      upload,
+	}
+	session := localModels.VerificationSession{
+		SessionID: "session1", ClientID: "client1", ApplicantID: "app1", Status: localModels.SessionOpened,
+		DocumentIDs: []string{}, CreatedAt: now, ExpiresAt: now.Add(time.Hour), OpenedAt: &now,
+	}
+	submitted := session
+	submitted.Status = localModels.SessionSubmitted
+	submitted.DocumentIDs = []string{"doc1"}
+	submitted.SubmittedAt = &now
+	authenticated := func(m *handlerMocks, session localModels.VerificationSession) {
+		m.sessions.On("Authenticate", mock.Anything, "vs_session1").Return(session, localModels.ChannelLink, nil)
+	}
+	hostedForm, hostedType := multipartBody(t, map[string]string{"document_type": "passport", "country": "GB"})
+	confirmed := applicant
+	confirmed.Intake = &localModels.ApplicantIntake{State: localModels.IntakeConfirmed, DocumentID: "doc1", Corrected: []string{"last_name"}, ConfirmedAt: &now}
+	keyARN := "arn:aws:kms:eu-west-1:444455556666:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	encryptionKey := localModels.EncryptionKey{EncryptionKeyID: "key1", ClientID: "client1", KeyARN: keyARN, Status: localModels.EncryptionKeyRegistered, RegisteredAt: now}
+	migratingKey := encryptionKey
+	migratingKey.EncryptionKeyID, migratingKey.Status = "key2", localModels.EncryptionKeyMigrating
+	migratingKey.ValidatedAt, migratingKey.ActivatedAt, migratingKey.CutoverAt = &now, &now, &now
+	migratingKey.Migration = &localModels.KeyMigration{Total: 1200, Migrated: 800, FilesSkipped: 3, UpdatedAt: now}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string // Template the request falls under
+		url         string
+		body        string
+		contentType string
+		token       string // Sent as a Bearer token
+		setup       func(m *handlerMocks)
+		wantStatus  int
+	}{
+		{
+			name: "Issue token", method: http.MethodPost, path: "/auth/token", url: "/auth/token",
+			body: `{"grant_type":"api_key","api_key":"key1"}`,
+			setup: func(m *handlerMocks) {
+				m.tokens.On("IssueForAPIKey", mock.Anything, "key1").Return(localModels.TokenPair{AccessToken: "at", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "rt"}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Issue token without grant type", method: http.MethodPost, path: "/auth/token", url: "/auth/token",
+			body: `{}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Issue token with bad credentials", method: http.MethodPost, path: "/auth/token", url: "/auth/token",
+			body: `{"grant_type":"api_key","api_key":"bad"}`,
+			setup: func(m *handlerMocks) {
+				m.tokens.On("IssueForAPIKey", mock.Anything, "bad").Return(localModels.TokenPair{}, tokenServices.ErrInvalidCredentials)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Revoke token", method: http.MethodPost, path: "/auth/revoke", url: "/auth/revoke",
+			body:       `{"token":"rt"}`,
+			setup:      func(m *handlerMocks) { m.tokens.On("Revoke", mock.Anything, "rt").Return(nil) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Revoke without token", method: http.MethodPost, path: "/auth/revoke", url: "/auth/revoke",
+			body: `{}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Create applicant", method: http.MethodPost, path: "/applicants", url: "/applicants",
+			body: `{"first_name":"Ada","middle_name":"B","last_name":"Lovelace","email":"ada@example.com","phone":"+441234567890",
+				"address":{"Line1":"1 Main St","City":"London","PostalCode":"N1","Country":"GB"},"dob":"1815-12-10","level":"basic"}`,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("CreateApplicant", mock.Anything, mock.Anything, "GB").Return(applicant, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Create applicant with consent", method: http.MethodPost, path: "/applicants", url: "/applicants",
+			body: `{"first_name":"Ada","middle_name":"B","last_name":"Lovelace","email":"ada@example.com","phone":"+441234567890",
+				"address":{"Line1":"1 Main St","City":"London","PostalCode":"N1","Country":"GB"},"dob":"1815-12-10","level":"basic",
+				"consent":{"text_version":"2025-01","given_at":"2025-01-15T09:29:00Z","ip":"203.0.113.7","channel":"web"}}`,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("CreateApplicant", mock.Anything, mock.MatchedBy(func(record *localModels.ApplicantRecord) bool {
+					return record.Consent != nil && record.Consent.TextVersion == "2025-01"
+				}), "GB").Return(applicant, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Create applicant with incomplete consent", method: http.MethodPost, path: "/applicants", url: "/applicants",
+			body: `{"first_name":"Ada","middle_name":"B","last_name":"Lovelace","email":"ada@example.com","phone":"+441234567890",
+				"address":{"Line1":"1 Main St","City":"London","PostalCode":"N1","Country":"GB"},"dob":"1815-12-10","level":"basic",
+				"consent":{"text_version":"2025-01"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Create applicant without fields", method: http.MethodPost, path: "/applicants", url: "/applicants",
+			body: `{"first_name":"Ada"}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Create applicant with too large a body", method: http.MethodPost, path: "/applicants", url: "/applicants",
+			body: `{"first_name":"` + strings.Repeat("a", 70<<10) + `"}`, wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Create applicant with too deep a body", method: http.MethodPost, path: "/applicants", url: "/applicants",
+			body: strings.Repeat(`{"a":`, 10) + "1" + strings.Repeat("}", 10), wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Update applicant with too large a body", method: http.MethodPut, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `{"last_name":"` + strings.Repeat("a", 70<<10) + `"}`, wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Patch applicant with too large a body", method: http.MethodPatch, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `{"last_name":"` + strings.Repeat("a", 70<<10) + `"}`, contentType: localModels.MergePatchContentType, wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Patch annotations with too large a body", method: http.MethodPatch, path: "/applicants/{id}/annotations", url: "/applicants/app1/annotations",
+			body: `{"tags":["` + strings.Repeat("a", 70<<10) + `"]}`, wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "List applicants", method: http.MethodGet, path: "/applicants", url: "/applicants",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("GetAllApplicants", mock.Anything, mock.Anything).Return([]localModels.ApplicantRecord{applicant}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List applicants by tag and metadata", method: http.MethodGet, path: "/applicants", url: "/applicants?tag=vip&metadata.region=eu",
+			setup: func(m *handlerMocks) {
+				filter := localModels.ApplicantFilter{Tags: []string{"vip"}, Metadata: map[string]string{"region": "eu"}}
+				m.applicants.On("GetAllApplicants", mock.Anything, filter).Return([]localModels.ApplicantRecord{applicant}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List applicants by an invalid metadata key", method: http.MethodGet, path: "/applicants", url: "/applicants?metadata.$where=1",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "List applicants with selected fields", method: http.MethodGet, path: "/applicants", url: "/applicants?fields=first_name",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("SelectApplicants", mock.Anything, mock.Anything, mock.Anything).Return([]map[string]interface{}{{"applicant_id": "app1", "first_name": "Ada"}}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List applicants with an unknown field", method: http.MethodGet, path: "/applicants", url: "/applicants?fields=encrypted_data",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Get applicant", method: http.MethodGet, path: "/applicants/{id}", url: "/applicants/app1",
+			setup:      func(m *handlerMocks) { m.applicants.On("GetApplicant", mock.Anything, "app1").Return(applicant, nil) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get missing applicant", method: http.MethodGet, path: "/applicants/{id}", url: "/applicants/nope",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("GetApplicant", mock.Anything, "nope").Return(localModels.ApplicantRecord{}, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Update applicant", method: http.MethodPut, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `{"last_name":"King"}`,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("UpdateApplicant", mock.Anything, "app1", mock.Anything).Return(applicant, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Update applicant consent", method: http.MethodPut, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `{"consent.text_version":"2026-01"}`,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("UpdateApplicant", mock.Anything, "app1", mock.Anything).Return(localModels.ApplicantRecord{}, applicantServices.ErrImmutableField)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Update applicant with invalid JSON", method: http.MethodPut, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `{`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Merge patch applicant", method: http.MethodPatch, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `{"last_name":"King","address":{"Line1":"1 Main St","City":"London","Country":"GB"}}`, contentType: localModels.MergePatchContentType,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("PatchApplicant", mock.Anything, "app1", mock.MatchedBy(func(patch localModels.ApplicantPatch) bool {
+					return patch.Merge["last_name"] == "King" && patch.Operations == nil
+				})).Return(applicant, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "JSON Patch applicant", method: http.MethodPatch, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `[{"op":"replace","path":"/last_name","value":"King"}]`, contentType: localModels.JSONPatchContentType,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("PatchApplicant", mock.Anything, "app1", mock.MatchedBy(func(patch localModels.ApplicantPatch) bool {
+					return len(patch.Operations) == 1 && patch.Operations[0].Path == "/last_name"
+				})).Return(applicant, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Patch applicant consent", method: http.MethodPatch, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `{"consent":null}`, contentType: localModels.MergePatchContentType,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("PatchApplicant", mock.Anything, "app1", mock.Anything).Return(localModels.ApplicantRecord{}, fmt.Errorf("%w: consent", applicantServices.ErrImmutableField))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Patch missing applicant", method: http.MethodPatch, path: "/applicants/{id}", url: "/applicants/nope",
+			body: `{"last_name":"King"}`, contentType: localModels.MergePatchContentType,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("PatchApplicant", mock.Anything, "nope", mock.Anything).Return(localModels.ApplicantRecord{}, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Patch applicant with a failed test", method: http.MethodPatch, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `[{"op":"test","path":"/last_name","value":"Byron"}]`, contentType: localModels.JSONPatchContentType,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("PatchApplicant", mock.Anything, "app1", mock.Anything).Return(localModels.ApplicantRecord{}, fmt.Errorf("operation 0 (test /last_name): %w", jsonpatch.ErrTestFailed))
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Patch applicant as form data", method: http.MethodPatch, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `last_name=King`, contentType: "application/x-www-form-urlencoded", wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "Patch applicant leaving it incomplete", method: http.MethodPatch, path: "/applicants/{id}", url: "/applicants/app1",
+			body: `{"email":null}`, contentType: localModels.MergePatchContentType,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("PatchApplicant", mock.Anything, "app1", mock.Anything).Return(localModels.ApplicantRecord{}, apperr.Invalid(fmt.Errorf("%w: email required", localModels.ErrInvalidApplicant), map[string]string{"email": "required"}))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Update applicant annotations", method: http.MethodPatch, path: "/applicants/{id}/annotations", url: "/applicants/app1/annotations",
+			body: `{"tags":["vip"],"metadata":{"region":"eu","crm_id":null}}`,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("UpdateAnnotations", mock.Anything, "app1", mock.Anything).
+					Return(localModels.Annotations{Tags: []string{"vip"}, Metadata: map[string]string{"region": "eu"}}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Update applicant annotations with an invalid key", method: http.MethodPatch, path: "/applicants/{id}/annotations", url: "/applicants/app1/annotations",
+			body: `{"metadata":{"a.b":"c"}}`,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("UpdateAnnotations", mock.Anything, "app1", mock.Anything).
+					Return(localModels.Annotations{}, fmt.Errorf("%w: metadata key %q is invalid", localModels.ErrInvalidAnnotations, "a.b"))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Update annotations of a missing applicant", method: http.MethodPatch, path: "/applicants/{id}/annotations", url: "/applicants/nope/annotations",
+			body: `{"tags":[]}`,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("UpdateAnnotations", mock.Anything, "nope", mock.Anything).Return(localModels.Annotations{}, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Update annotations changed concurrently", method: http.MethodPatch, path: "/applicants/{id}/annotations", url: "/applicants/app1/annotations",
+			body: `{"tags":["vip"]}`,
+			setup: func(m *handlerMocks) {
+				m.applicants.On("UpdateAnnotations", mock.Anything, "app1", mock.Anything).Return(localModels.Annotations{}, applicantServices.ErrAnnotationsConflict)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Create applicant from document", method: http.MethodPost, path: "/applicants/from-document", url: "/applicants/from-document",
+			body: intakeForm, contentType: intakeType,
+			setup: func(m *handlerMocks) {
+				m.documents.On("CreateApplicantFromDocument", mock.Anything, "basic", mock.Anything, mock.Anything).Return(intake, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Create applicant from unreadable document", method: http.MethodPost, path: "/applicants/from-document", url: "/applicants/from-document",
+			body: intakeForm, contentType: intakeType,
+			setup: func(m *handlerMocks) {
+				m.documents.On("CreateApplicantFromDocument", mock.Anything, "basic", mock.Anything, mock.Anything).
+					Return(localModels.DocumentIntake{}, fmt.Errorf("%w: unrecognised MRZ layout", documentServices.ErrUnreadableDocument))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Create applicant from document without level", method: http.MethodPost, path: "/applicants/from-document", url: "/applicants/from-document",
+			body: noLevelForm, contentType: noLevelType, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Confirm applicant", method: http.MethodPost, path: "/applicants/{id}/confirm", url: "/applicants/app1/confirm",
+			body: `{"last_name":"Eriksson Berg","email":"anna@example.com","phone":"+46701234567","address":{"Line1":"1 Storgatan","City":"Stockholm","Country":"SE"}}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("ConfirmApplicant", mock.Anything, "client1", "app1", mock.Anything).Return(confirmed, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Confirm confirmed applicant", method: http.MethodPost, path: "/applicants/{id}/confirm", url: "/applicants/app2/confirm",
+			body: `{"email":"anna@example.com","phone":"+46701234567","address":{"Country":"SE"}}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("ConfirmApplicant", mock.Anything, "client1", "app2", mock.Anything).Return(localModels.ApplicantRecord{}, documentServices.ErrNotProvisional)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Confirm applicant with a bad date of birth", method: http.MethodPost, path: "/applicants/{id}/confirm", url: "/applicants/app1/confirm",
+			body: `{"dob":"12/08/1974","email":"anna@example.com","phone":"+46701234567","address":{"Country":"SE"}}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Upload document", method: http.MethodPost, path: "/applicants/{id}/documents", url: "/applicants/app1/documents",
+			body: uploadForm, contentType: uploadType, wantStatus: http.StatusOK,
+		},
+		{
+			name: "Presign document upload", method: http.MethodPost, path: "/applicants/{id}/documents/presign-upload", url: "/applicants/app1/documents/presign-upload",
+			body: `{"document_type":"passport","country":"GB","mime_type":"application/pdf","file_size":52428800,"checksum_sha256":"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}`,
+			setup: func(m *handlerMocks) {
+				upload := localModels.DirectUpload{
+					DocumentID: "doc1", UploadToken: "vu_token", Method: http.MethodPut, URL: "https://documents.s3.eu-west-2.amazonaws.com/quarantine/doc1.pdf",
+					Headers: map[string]string{"Content-Type": "application/pdf"}, ExpiresAt: now,
+				}
+				m.documents.On("PresignUpload", mock.Anything, "client1", mock.MatchedBy(func(r localModels.DirectUploadRequest) bool { return r.ApplicantID == "app1" })).Return(upload, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Presign oversized document upload", method: http.MethodPost, path: "/applicants/{id}/documents/presign-upload", url: "/applicants/app2/documents/presign-upload",
+			body: `{"document_type":"passport","country":"GB","mime_type":"application/pdf","file_size":999999999999,"checksum_sha256":"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("PresignUpload", mock.Anything, "client1", mock.MatchedBy(func(r localModels.DirectUploadRequest) bool { return r.ApplicantID == "app2" })).
+					Return(localModels.DirectUpload{}, fmt.Errorf("%w: file_size must be between 1 and 104857600 bytes", documentServices.ErrInvalidDirectUpload))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Presign upload without direct uploads", method: http.MethodPost, path: "/applicants/{id}/documents/presign-upload", url: "/applicants/app3/documents/presign-upload",
+			body: `{"document_type":"passport","country":"GB","mime_type":"application/pdf","file_size":1024,"checksum_sha256":"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("PresignUpload", mock.Anything, "client1", mock.MatchedBy(func(r localModels.DirectUploadRequest) bool { return r.ApplicantID == "app3" })).
+					Return(localModels.DirectUpload{}, documentServices.ErrDirectUploadsOff)
+			},
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name: "Presign upload for an applicant at its limit", method: http.MethodPost, path: "/applicants/{id}/documents/presign-upload", url: "/applicants/app4/documents/presign-upload",
+			body: `{"document_type":"passport","country":"GB","mime_type":"application/pdf","file_size":1024,"checksum_sha256":"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("PresignUpload", mock.Anything, "client1", mock.MatchedBy(func(r localModels.DirectUploadRequest) bool { return r.ApplicantID == "app4" })).
+					Return(localModels.DirectUpload{}, fmt.Errorf("%w: applicants at level basic-kyc-level may have 10 documents and this one has 10", documentServices.ErrApplicantDocumentLimit))
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Complete document upload", method: http.MethodPost, path: "/applicants/{id}/documents/complete", url: "/applicants/app1/documents/complete",
+			body: `{"upload_token":"vu_token"}`,
+			setup: func(m *handlerMocks) {
+				result := localModels.UploadResult{
+					DocumentRecord:   localModels.DocumentRecord{Document: document},
+					Checks:           []localModels.UploadCheck{{Name: "quick_scan", Status: localModels.UploadCheckSkipped, Detail: "file deferred to asynchronous scan"}},
+					ProcessingStatus: localModels.ProcessingScanPending,
+				}
+				m.documents.On("CompleteUpload", mock.Anything, "client1", "app1", localModels.DirectUploadCompletion{UploadToken: "vu_token"}, mock.Anything).Return(result, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Complete upload with a bad token", method: http.MethodPost, path: "/applicants/{id}/documents/complete", url: "/applicants/app1/documents/complete",
+			body: `{"upload_token":"vu_edited"}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("CompleteUpload", mock.Anything, "client1", "app1", localModels.DirectUploadCompletion{UploadToken: "vu_edited"}, mock.Anything).
+					Return(localModels.UploadResult{}, documentServices.ErrInvalidUploadToken)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Complete upload before the file is uploaded", method: http.MethodPost, path: "/applicants/{id}/documents/complete", url: "/applicants/app1/documents/complete",
+			body: `{"upload_token":"vu_early"}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("CompleteUpload", mock.Anything, "client1", "app1", localModels.DirectUploadCompletion{UploadToken: "vu_early"}, mock.Anything).
+					Return(localModels.UploadResult{}, documentServices.ErrDirectUploadMissing)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Complete rejected upload", method: http.MethodPost, path: "/applicants/{id}/documents/complete", url: "/applicants/app1/documents/complete",
+			body: `{"upload_token":"vu_rejected"}`,
+			setup: func(m *handlerMocks) {
+				rejected := localModels.UploadResult{
+					Checks:           []localModels.UploadCheck{{Name: "checksum", Status: localModels.UploadCheckFailed, Detail: "file does not match the declared checksum"}},
+					ProcessingStatus: localModels.ProcessingRejected,
+				}
+				checksFailed := apperr.WithDetails(documentServices.ErrUploadChecksFailed, map[string]interface{}{"checks": rejected.Checks, "processing_status": rejected.ProcessingStatus})
+				m.documents.On("CompleteUpload", mock.Anything, "client1", "app1", localModels.DirectUploadCompletion{UploadToken: "vu_rejected"}, mock.Anything).Return(rejected, checksFailed)
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Archive documents", method: http.MethodPost, path: "/applicants/{id}/documents/archive", url: "/applicants/app1/documents/archive?reason=verification",
+			setup: func(m *handlerMocks) {
+				records := []localModels.DocumentRecord{{Document: document}}
+				m.documents.On("ListArchiveDocuments", mock.Anything, "client1", "app1", mock.Anything).Return(records, nil)
+				m.documents.On("WriteDocumentArchive", mock.Anything, records, mock.Anything, mock.Anything).Return("PK\x05\x06", nil, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Archive documents without a reason", method: http.MethodPost, path: "/applicants/{id}/documents/archive", url: "/applicants/app1/documents/archive",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Archive documents of a missing applicant", method: http.MethodPost, path: "/applicants/{id}/documents/archive", url: "/applicants/nope/documents/archive?reason=verification",
+			setup: func(m *handlerMocks) {
+				m.documents.On("ListArchiveDocuments", mock.Anything, "client1", "nope", mock.Anything).Return(nil, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Get document", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}", url: "/applicants/app1/documents/doc1",
+			setup: func(m *handlerMocks) {
+				m.documents.On("GetDocument", mock.Anything, "app1", "doc1", mock.Anything).Return(document, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get missing document", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}", url: "/applicants/app1/documents/nope",
+			setup: func(m *handlerMocks) {
+				m.documents.On("GetDocument", mock.Anything, "app1", "nope", mock.Anything).Return(models.Document{}, documentServices.ErrDocumentNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Update document", method: http.MethodPut, path: "/applicants/{id}/documents/{docId}", url: "/applicants/app1/documents/doc1",
+			body: `{"status":"` + models.DocumentVerified.String() + `"}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("UpdateDocument", mock.Anything, "app1", "doc1", models.DocumentVerified).Return(document, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Update document with an invalid status", method: http.MethodPut, path: "/applicants/{id}/documents/{docId}", url: "/applicants/app1/documents/doc1",
+			body: `{"status":"shredded"}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Update document while the database is unavailable", method: http.MethodPut, path: "/applicants/{id}/documents/{docId}", url: "/applicants/app1/documents/doc2",
+			body: `{"status":"` + models.DocumentVerified.String() + `"}`,
+			setup: func(m *handlerMocks) {
+				m.documents.On("UpdateDocument", mock.Anything, "app1", "doc2", models.DocumentVerified).Return(models.Document{}, mongoretry.ErrUnavailable)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "Replace document", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/replace", url: "/applicants/app1/documents/doc1/replace",
+			body: uploadForm, contentType: uploadType,
+			setup: func(m *handlerMocks) {
+				m.documents.On("ReplaceDocument", mock.Anything, "client1", "doc1", mock.Anything).Return(upload, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Replace document during an upload", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/replace", url: "/applicants/app1/documents/doc2/replace",
+			body: uploadForm, contentType: uploadType,
+			setup: func(m *handlerMocks) {
+				m.documents.On("ReplaceDocument", mock.Anything, "client1", "doc2", mock.Anything).Return(localModels.UploadResult{}, documentServices.ErrUploadInProgress)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Replace document with a rejected file", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/replace", url: "/applicants/app1/documents/doc3/replace",
+			body: uploadForm, contentType: uploadType,
+			setup: func(m *handlerMocks) {
+				rejected := localModels.UploadResult{
+					Checks:           []localModels.UploadCheck{{Name: "file_type", Status: localModels.UploadCheckFailed, Detail: "not an image"}},
+					ProcessingStatus: localModels.ProcessingRejected,
+				}
+				checksFailed := apperr.WithDetails(documentServices.ErrUploadChecksFailed, map[string]interface{}{"checks": rejected.Checks, "processing_status": rejected.ProcessingStatus})
+				m.documents.On("ReplaceDocument", mock.Anything, "client1", "doc3", mock.Anything).Return(rejected, checksFailed)
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Replace document while the client has too many uploads", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/replace", url: "/applicants/app1/documents/doc4/replace",
+			body: uploadForm, contentType: uploadType,
+			setup: func(m *handlerMocks) {
+				m.documents.On("ReplaceDocument", mock.Anything, "client1", "doc4", mock.Anything).
+					Return(localModels.UploadResult{}, fmt.Errorf("%w: the client may have 4 at once; retry once one finishes", documentServices.ErrTooManyUploads))
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name: "List document versions", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/versions", url: "/applicants/app1/documents/doc1/versions",
+			setup: func(m *handlerMocks) {
+				history := localModels.DocumentHistory{DocumentID: "doc1", CurrentVersion: 2, Versions: []localModels.DocumentVersion{
+					{Version: 1, FileSize: 8, Status: models.DocumentUploaded, UploadedAt: now, ReplacedAt: now, ReplacedBy: "client1"},
+				}}
+				m.documents.On("GetDocumentVersions", mock.Anything, "client1", "app1", "doc1", mock.Anything).Return(history, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Restore document", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/restore", url: "/applicants/app1/documents/doc1/restore",
+			setup: func(m *handlerMocks) {
+				state := localModels.ColdStorage{State: localModels.ColdStorageRestoring, StorageClass: "GLACIER", ArchivedAt: now, RestoreRequestedAt: &now}
+				m.documents.On("RestoreDocument", mock.Anything, "client1", "app1", "doc1", mock.Anything).Return(state, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Restore restored document", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/restore", url: "/applicants/app1/documents/doc2/restore",
+			setup: func(m *handlerMocks) {
+				until := now.Add(7 * 24 * time.Hour)
+				state := localModels.ColdStorage{State: localModels.ColdStorageRestored, StorageClass: "GLACIER", ArchivedAt: now, RestoreRequestedAt: &now, RestoredUntil: &until}
+				m.documents.On("RestoreDocument", mock.Anything, "client1", "app1", "doc2", mock.Anything).Return(state, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Restore document that is not archived", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/restore", url: "/applicants/app1/documents/doc3/restore",
+			setup: func(m *handlerMocks) {
+				m.documents.On("RestoreDocument", mock.Anything, "client1", "app1", "doc3", mock.Anything).Return(localModels.ColdStorage{}, documentServices.ErrDocumentNotArchived)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Restore missing document", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/restore", url: "/applicants/app1/documents/doc9/restore",
+			setup: func(m *handlerMocks) {
+				m.documents.On("RestoreDocument", mock.Anything, "client1", "app1", "doc9", mock.Anything).Return(localModels.ColdStorage{}, documentServices.ErrDocumentNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Get document restore", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/restore", url: "/applicants/app1/documents/doc1/restore",
+			setup: func(m *handlerMocks) {
+				state := localModels.ColdStorage{State: localModels.ColdStorageArchived, StorageClass: "GLACIER", ArchivedAt: now}
+				m.documents.On("GetDocumentRestore", mock.Anything, "client1", "app1", "doc1", mock.Anything).Return(state, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get restore of document that is not archived", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/restore", url: "/applicants/app1/documents/doc3/restore",
+			setup: func(m *handlerMocks) {
+				m.documents.On("GetDocumentRestore", mock.Anything, "client1", "app1", "doc3", mock.Anything).Return(localModels.ColdStorage{}, documentServices.ErrDocumentNotArchived)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Get restore of missing document", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/restore", url: "/applicants/app1/documents/doc9/restore",
+			setup: func(m *handlerMocks) {
+				m.documents.On("GetDocumentRestore", mock.Anything, "client1", "app1", "doc9", mock.Anything).Return(localModels.ColdStorage{}, documentServices.ErrDocumentNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Undelete document", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/undelete", url: "/applicants/app1/documents/doc1/undelete",
+			setup: func(m *handlerMocks) {
+				m.documents.On("UndeleteDocument", mock.Anything, "client1", "app1", "doc1", mock.Anything).Return(document, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Undelete document of a deleted applicant", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/undelete", url: "/applicants/app2/documents/doc1/undelete",
+			setup: func(m *handlerMocks) {
+				m.documents.On("UndeleteDocument", mock.Anything, "client1", "app2", "doc1", mock.Anything).Return(models.Document{}, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Undelete document that is not deleted", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/undelete", url: "/applicants/app1/documents/doc2/undelete",
+			setup: func(m *handlerMocks) {
+				m.documents.On("UndeleteDocument", mock.Anything, "client1", "app1", "doc2", mock.Anything).Return(models.Document{}, softdelete.ErrNotDeleted)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Undelete document after the grace period", method: http.MethodPost, path: "/applicants/{id}/documents/{docId}/undelete", url: "/applicants/app1/documents/doc3/undelete",
+			setup: func(m *handlerMocks) {
+				m.documents.On("UndeleteDocument", mock.Anything, "client1", "app1", "doc3", mock.Anything).Return(models.Document{}, softdelete.ErrGracePeriodOver)
+			},
+			wantStatus: http.StatusGone,
+		},
+		{
+			name: "Preview document", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/preview", url: "/applicants/app1/documents/doc1/preview?reason=verification&watermark=true",
+			setup: func(m *handlerMocks) {
+				m.documents.On("PreviewDocument", mock.Anything, "client1", "app1", "doc1", mock.Anything, mock.Anything).Return(localModels.DocumentPreview{ETag: `W/"abc"`, Content: []byte("\xff\xd8\xff")}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Preview document without a reason", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/preview", url: "/applicants/app1/documents/doc1/preview",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Preview document the browser has", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/preview", url: "/applicants/app1/documents/doc2/preview?reason=verification",
+			setup: func(m *handlerMocks) {
+				m.documents.On("PreviewDocument", mock.Anything, "client1", "app1", "doc2", mock.Anything, mock.Anything).Return(localModels.DocumentPreview{ETag: `W/"abc"`}, nil)
+			},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name: "Preview archived document", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/preview", url: "/applicants/app1/documents/doc3/preview?reason=verification",
+			setup: func(m *handlerMocks) {
+				m.documents.On("PreviewDocument", mock.Anything, "client1", "app1", "doc3", mock.Anything, mock.Anything).Return(localModels.DocumentPreview{}, documentServices.ErrDocumentArchived)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Preview missing document", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/preview", url: "/applicants/app1/documents/doc9/preview?reason=verification",
+			setup: func(m *handlerMocks) {
+				m.documents.On("PreviewDocument", mock.Anything, "client1", "app1", "doc9", mock.Anything, mock.Anything).Return(localModels.DocumentPreview{}, documentServices.ErrDocumentNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Preview text PDF", method: http.MethodGet, path: "/applicants/{id}/documents/{docId}/preview", url: "/applicants/app1/documents/doc4/preview?reason=verification",
+			setup: func(m *handlerMocks) {
+				m.documents.On("PreviewDocument", mock.Anything, "client1", "app1", "doc4", mock.Anything, mock.Anything).Return(localModels.DocumentPreview{}, fmt.Errorf("%w: first page has no image", preview.ErrUnsupported))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Get applicant report", method: http.MethodGet, path: "/applicants/{id}/report.pdf", url: "/applicants/app1/report.pdf?reason=audit",
+			setup: func(m *handlerMocks) {
+				m.reports.On("RequestReport", mock.Anything, "client1", "app1", reportServices.PurposeReport).
+					Return(localModels.ApplicantReport{ReportID: "report1", ApplicantID: "app1", Status: localModels.ReportReady, Content: []byte("%PDF-1.4")}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Queue applicant report", method: http.MethodGet, path: "/applicants/{id}/report.pdf", url: "/applicants/app2/report.pdf?reason=audit",
+			setup: func(m *handlerMocks) {
+				m.reports.On("RequestReport", mock.Anything, "client1", "app2", reportServices.PurposeReport).
+					Return(localModels.ApplicantReport{ReportID: "report2", ApplicantID: "app2", Status: localModels.ReportPending, RequestedAt: now, ExpiresAt: now}, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Get applicant report without a reason", method: http.MethodGet, path: "/applicants/{id}/report.pdf", url: "/applicants/app1/report.pdf",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Get report of missing applicant", method: http.MethodGet, path: "/applicants/{id}/report.pdf", url: "/applicants/app9/report.pdf?reason=audit",
+			setup: func(m *handlerMocks) {
+				m.reports.On("RequestReport", mock.Anything, "client1", "app9", reportServices.PurposeReport).Return(localModels.ApplicantReport{}, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Get failed applicant report", method: http.MethodGet, path: "/applicants/{id}/report.pdf", url: "/applicants/app2/report.pdf?reason=audit&report_id=report3",
+			setup: func(m *handlerMocks) {
+				m.reports.On("GetReport", mock.Anything, "client1", "app2", "report3").
+					Return(localModels.ApplicantReport{ReportID: "report3", ApplicantID: "app2", Status: localModels.ReportFailed, Error: "report could not be generated"}, nil)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "Request compliance report", method: http.MethodPost, path: "/reports", url: "/reports",
+			body: `{"format":"csv","from":"2025-01-01","to":"2025-03-31"}`,
+			setup: func(m *handlerMocks) {
+				from, to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+				m.compliance.On("RequestComplianceReport", mock.Anything, "client1", "csv", from, to).
+					Return(localModels.ComplianceReport{ReportID: "report1", Format: "csv", From: from, To: to, Status: localModels.ReportPending, RequestedAt: now, ExpiresAt: now}, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Request compliance report for too long a period", method: http.MethodPost, path: "/reports", url: "/reports",
+			body: `{"from":"2020-01-01","to":"2025-12-31"}`,
+			setup: func(m *handlerMocks) {
+				m.compliance.On("RequestComplianceReport", mock.Anything, "client1", "json", mock.Anything, mock.Anything).
+					Return(localModels.ComplianceReport{}, fmt.Errorf("%w: reports may cover at most 366 days", reportServices.ErrInvalidPeriod))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Download compliance report", method: http.MethodGet, path: "/reports/{id}", url: "/reports/report1",
+			setup: func(m *handlerMocks) {
+				m.compliance.On("GetComplianceReport", mock.Anything, "client1", "report1").
+					Return(localModels.ComplianceReport{ReportID: "report1", Format: "csv", Status: localModels.ReportReady, Content: []byte("section,metric,subject,value\n")}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get pending compliance report", method: http.MethodGet, path: "/reports/{id}", url: "/reports/report2",
+			setup: func(m *handlerMocks) {
+				m.compliance.On("GetComplianceReport", mock.Anything, "client1", "report2").
+					Return(localModels.ComplianceReport{ReportID: "report2", Format: "json", Status: localModels.ReportPending, RequestedAt: now, ExpiresAt: now}, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Get missing compliance report", method: http.MethodGet, path: "/reports/{id}", url: "/reports/report9",
+			setup: func(m *handlerMocks) {
+				m.compliance.On("GetComplianceReport", mock.Anything, "client1", "report9").Return(localModels.ComplianceReport{}, reportServices.ErrReportNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Get failed compliance report", method: http.MethodGet, path: "/reports/{id}", url: "/reports/report3",
+			setup: func(m *handlerMocks) {
+				m.compliance.On("GetComplianceReport", mock.Anything, "client1", "report3").
+					Return(localModels.ComplianceReport{ReportID: "report3", Status: localModels.ReportFailed, Error: "report could not be generated"}, nil)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "Register encryption key", method: http.MethodPost, path: "/encryption-keys", url: "/encryption-keys",
+			body: `{"key_arn":"` + keyARN + `"}`,
+			setup: func(m *handlerMocks) {
+				m.keys.On("RegisterKey", mock.Anything, "client1", keyARN).Return(encryptionKey, nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "Register encryption key that is not an ARN", method: http.MethodPost, path: "/encryption-keys", url: "/encryption-keys",
+			body: `{"key_arn":"alias/acme"}`,
+			setup: func(m *handlerMocks) {
+				m.keys.On("RegisterKey", mock.Anything, "client1", "alias/acme").Return(localModels.EncryptionKey{}, encryptionKeyServices.ErrInvalidKeyARN)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "List encryption keys", method: http.MethodGet, path: "/encryption-keys", url: "/encryption-keys",
+			setup: func(m *handlerMocks) {
+				m.keys.On("ListKeys", mock.Anything, "client1").Return([]localModels.EncryptionKey{migratingKey, encryptionKey}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get migrating encryption key", method: http.MethodGet, path: "/encryption-keys/{id}", url: "/encryption-keys/key2",
+			setup: func(m *handlerMocks) {
+				m.keys.On("GetKey", mock.Anything, "client1", "key2").Return(migratingKey, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get missing encryption key", method: http.MethodGet, path: "/encryption-keys/{id}", url: "/encryption-keys/key9",
+			setup: func(m *handlerMocks) {
+				m.keys.On("GetKey", mock.Anything, "client1", "key9").Return(localModels.EncryptionKey{}, encryptionKeyServices.ErrKeyNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Validate encryption key", method: http.MethodPost, path: "/encryption-keys/{id}/validate", url: "/encryption-keys/key1/validate",
+			setup: func(m *handlerMocks) {
+				validated := encryptionKey
+				validated.Status, validated.ValidatedAt = localModels.EncryptionKeyValidated, &now
+				m.keys.On("ValidateKey", mock.Anything, "client1", "key1").Return(validated, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Validate encryption key the service cannot use", method: http.MethodPost, path: "/encryption-keys/{id}/validate", url: "/encryption-keys/key1/validate",
+			setup: func(m *handlerMocks) {
+				invalid := encryptionKey
+				invalid.Status, invalid.ValidatedAt, invalid.ValidationError = localModels.EncryptionKeyInvalid, &now, "generating a data key failed: AccessDeniedException"
+				m.keys.On("ValidateKey", mock.Anything, "client1", "key1").Return(invalid, nil)
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Validate activated encryption key", method: http.MethodPost, path: "/encryption-keys/{id}/validate", url: "/encryption-keys/key2/validate",
+			setup: func(m *handlerMocks) {
+				m.keys.On("ValidateKey", mock.Anything, "client1", "key2").Return(localModels.EncryptionKey{}, encryptionKeyServices.ErrKeyInUse)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Activate encryption key", method: http.MethodPost, path: "/encryption-keys/{id}/activate", url: "/encryption-keys/key2/activate",
+			setup: func(m *handlerMocks) {
+				m.keys.On("ActivateKey", mock.Anything, "client1", "key2").Return(migratingKey, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Activate encryption key before validating it", method: http.MethodPost, path: "/encryption-keys/{id}/activate", url: "/encryption-keys/key1/activate",
+			setup: func(m *handlerMocks) {
+				m.keys.On("ActivateKey", mock.Anything, "client1", "key1").Return(localModels.EncryptionKey{}, encryptionKeyServices.ErrKeyNotValidated)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Get upload job", method: http.MethodGet, path: "/documents/jobs/{job_id}", url: "/documents/jobs/job1",
+			setup: func(m *handlerMocks) {
+				job := localModels.UploadJob{
+					JobID: "job1", ApplicantID: "app1", DocumentID: "doc1", Stage: localModels.UploadJobStoring,
+					ProcessingStatus: localModels.ProcessingAccepted, CreatedAt: now, UpdatedAt: now,
+				}
+				m.documents.On("GetUploadJob", mock.Anything, "client1", "job1").Return(job, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get missing upload job", method: http.MethodGet, path: "/documents/jobs/{job_id}", url: "/documents/jobs/job9",
+			setup: func(m *handlerMocks) {
+				m.documents.On("GetUploadJob", mock.Anything, "client1", "job9").Return(localModels.UploadJob{}, documentServices.ErrUploadJobNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Add attachment", method: http.MethodPost, path: "/applicants/{id}/attachments", url: "/applicants/app1/attachments",
+			body: attachmentForm, contentType: attachmentType,
+			setup: func(m *handlerMocks) {
+				m.attachments.On("AddAttachment", mock.Anything, tenant.Of("client1"), mock.Anything, mock.Anything, mock.Anything).Return(attachment, nil).Once()
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "Add attachment without a file", method: http.MethodPost, path: "/applicants/{id}/attachments", url: "/applicants/app1/attachments",
+			body: `{}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Add attachment breaking the policy", method: http.MethodPost, path: "/applicants/{id}/attachments", url: "/applicants/app1/attachments",
+			body: attachmentForm, contentType: attachmentType,
+			setup: func(m *handlerMocks) {
+				m.attachments.On("AddAttachment", mock.Anything, tenant.Of("client1"), mock.Anything, mock.Anything, mock.Anything).Return(localModels.Attachment{}, attachmentServices.ErrPolicy)
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "List attachments", method: http.MethodGet, path: "/applicants/{id}/attachments", url: "/applicants/app1/attachments",
+			setup: func(m *handlerMocks) {
+				m.attachments.On("ListAttachments", mock.Anything, "app1", tenant.Of("client1")).Return([]localModels.Attachment{attachment}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Download attachment", method: http.MethodGet, path: "/applicants/{id}/attachments/{attachmentId}", url: "/applicants/app1/attachments/att1",
+			setup: func(m *handlerMocks) {
+				m.attachments.On("OpenAttachment", mock.Anything, "app1", "att1", tenant.Of("client1")).Return(attachment, io.NopCloser(strings.NewReader("%PDF")), nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Download missing attachment", method: http.MethodGet, path: "/applicants/{id}/attachments/{attachmentId}", url: "/applicants/app1/attachments/nope",
+			setup: func(m *handlerMocks) {
+				m.attachments.On("OpenAttachment", mock.Anything, "app1", "nope", tenant.Of("client1")).Return(localModels.Attachment{}, nil, attachmentServices.ErrAttachmentNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Add note", method: http.MethodPost, path: "/applicants/{id}/notes", url: "/applicants/app1/notes",
+			body: `{"body":"Called the applicant"}`,
+			setup: func(m *handlerMocks) {
+				m.notes.On("AddNote", mock.Anything, tenant.Of("client1"), mock.Anything).Return(note, nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "Add empty note", method: http.MethodPost, path: "/applicants/{id}/notes", url: "/applicants/app1/notes",
+			body: `{"body":"  "}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "List notes", method: http.MethodGet, path: "/applicants/{id}/notes", url: "/applicants/app1/notes",
+			setup: func(m *handlerMocks) {
+				m.notes.On("ListNotes", mock.Anything, "app1", tenant.Of("client1"), firstPage(50)).Return([]localModels.Note{note}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List notes with a bad limit", method: http.MethodGet, path: "/applicants/{id}/notes", url: "/applicants/app1/notes?limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "List notes with an edited cursor", method: http.MethodGet, path: "/applicants/{id}/notes", url: "/applicants/app1/notes?cursor=eyJpIjoibjEifQ.AAAA",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Sync applicant", method: http.MethodPost, path: "/applicants/{id}/sync", url: "/applicants/app1/sync",
+			setup: func(m *handlerMocks) {
+				sync := localModels.SumsubSync{
+					SyncID: "sync1", ApplicantID: "app1", Trigger: localModels.SumsubSyncRequested, SumsubID: "sumsub1",
+					ReviewStatus: localModels.SumsubReviewCompleted, ReviewAnswer: localModels.SumsubAnswerGreen,
+					Inspections: []localModels.SumsubInspection{{DocSet: "IDENTITY", DocType: "PASSPORT", Answer: localModels.SumsubAnswerGreen}},
+					Changes:     []localModels.SyncChange{{Field: "status", From: "in_review", To: "approved"}},
+					Conflicts: []localModels.SyncConflict{{
+						Field: "last_name", Local: "Smith", Sumsub: "Smyth", Resolution: localModels.SyncKeptLocal, Reason: "extracted from the applicant's documents",
+					}},
+					SyncedAt: now,
+				}
+				m.sumsub.On("SyncApplicant", mock.Anything, "client1", "app1", localModels.SumsubSyncRequested).Return(sync, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Sync applicant unknown to Sumsub", method: http.MethodPost, path: "/applicants/{id}/sync", url: "/applicants/app2/sync",
+			setup: func(m *handlerMocks) {
+				m.sumsub.On("SyncApplicant", mock.Anything, "client1", "app2", localModels.SumsubSyncRequested).
+					Return(localModels.SumsubSync{}, sumsubServices.ErrSumsubApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Sync applicant that changed meanwhile", method: http.MethodPost, path: "/applicants/{id}/sync", url: "/applicants/app3/sync",
+			setup: func(m *handlerMocks) {
+				m.sumsub.On("SyncApplicant", mock.Anything, "client1", "app3", localModels.SumsubSyncRequested).
+					Return(localModels.SumsubSync{}, sumsubServices.ErrSyncConflict)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "List deleted applicants", method: http.MethodGet, path: "/applicants/deleted", url: "/applicants/deleted?limit=10",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("ListDeletedApplicants", mock.Anything, tenant.Of("client1"), firstPage(10)).
+					Return([]localModels.DeletedApplicant{deletedApplicant}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List deleted applicants with an invalid cursor", method: http.MethodGet, path: "/applicants/deleted", url: "/applicants/deleted?cursor=forged",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "List deleted applicants while the database is unavailable", method: http.MethodGet, path: "/applicants/deleted", url: "/applicants/deleted",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("ListDeletedApplicants", mock.Anything, tenant.Of("client1"), firstPage(50)).
+					Return([]localModels.DeletedApplicant(nil), mongoretry.ErrUnavailable)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "Restore applicant", method: http.MethodPost, path: "/applicants/{id}/restore", url: "/applicants/app1/restore",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("RestoreApplicant", mock.Anything, "app1").Return(applicant, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Restore missing applicant", method: http.MethodPost, path: "/applicants/{id}/restore", url: "/applicants/nope/restore",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("RestoreApplicant", mock.Anything, "nope").Return(localModels.ApplicantRecord{}, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Restore applicant that is not deleted", method: http.MethodPost, path: "/applicants/{id}/restore", url: "/applicants/app2/restore",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("RestoreApplicant", mock.Anything, "app2").Return(localModels.ApplicantRecord{}, softdelete.ErrNotDeleted)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Restore applicant after the grace period", method: http.MethodPost, path: "/applicants/{id}/restore", url: "/applicants/app3/restore",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("RestoreApplicant", mock.Anything, "app3").Return(localModels.ApplicantRecord{}, softdelete.ErrGracePeriodOver)
+			},
+			wantStatus: http.StatusGone,
+		},
+		{
+			name: "Restore applicant while the database is unavailable", method: http.MethodPost, path: "/applicants/{id}/restore", url: "/applicants/app4/restore",
+			setup: func(m *handlerMocks) {
+				m.applicants.On("RestoreApplicant", mock.Anything, "app4").Return(localModels.ApplicantRecord{}, mongoretry.ErrUnavailable)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "Rekey applicant", method: http.MethodPost, path: "/applicants/{id}/rekey", url: "/applicants/app1/rekey",
+			setup: func(m *handlerMocks) {
+				m.rekey.On("Rekey", mock.Anything, "client1", "app1").Return(localModels.Rekey{
+					ApplicantID: "app1", RekeyedAt: now, PII: true, FilesRekeyed: 2,
+					Skipped: []localModels.SkippedFile{{DocumentID: "doc1", Version: 1, Reason: "the file is in archival storage; restore it and rekey again"}},
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Rekey missing applicant", method: http.MethodPost, path: "/applicants/{id}/rekey", url: "/applicants/app2/rekey",
+			setup: func(m *handlerMocks) {
+				m.rekey.On("Rekey", mock.Anything, "client1", "app2").Return(localModels.Rekey{}, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Rekey applicant that kept changing", method: http.MethodPost, path: "/applicants/{id}/rekey", url: "/applicants/app3/rekey",
+			setup: func(m *handlerMocks) {
+				m.rekey.On("Rekey", mock.Anything, "client1", "app3").Return(localModels.Rekey{}, rekeyServices.ErrApplicantChanged)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Create session", method: http.MethodPost, path: "/applicants/{id}/sessions", url: "/applicants/app1/sessions",
+			body: `{"ttl_seconds":600}`,
+			setup: func(m *handlerMocks) {
+				link := localModels.SessionLink{VerificationSession: session, Token: "vs_session1", URL: "https://verify.example.com/start?session=vs_session1"}
+				link.Status = localModels.SessionCreated
+				link.OpenedAt = nil
+				m.sessions.On("CreateSession", mock.Anything, "client1", "app1", 10*time.Minute).Return(link, nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "Create session for too long", method: http.MethodPost, path: "/applicants/{id}/sessions", url: "/applicants/app1/sessions",
+			body: `{"ttl_seconds":99999999}`,
+			setup: func(m *handlerMocks) {
+				m.sessions.On("CreateSession", mock.Anything, "client1", "app1", 99999999*time.Second).
+					Return(localModels.SessionLink{}, fmt.Errorf("%w: ttl_seconds must be between 60 and 604800", sessionServices.ErrInvalidTTL))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Create session for unknown applicant", method: http.MethodPost, path: "/applicants/{id}/sessions", url: "/applicants/app2/sessions",
+			setup: func(m *handlerMocks) {
+				m.sessions.On("CreateSession", mock.Anything, "client1", "app2", time.Duration(0)).Return(localModels.SessionLink{}, apperr.ErrApplicantNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Get session", method: http.MethodGet, path: "/applicants/{id}/sessions/{sessionId}", url: "/applicants/app1/sessions/session1",
+			setup: func(m *handlerMocks) {
+				m.sessions.On("GetSession", mock.Anything, "client1", "app1", "session1").Return(submitted, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get unknown session", method: http.MethodGet, path: "/applicants/{id}/sessions/{sessionId}", url: "/applicants/app1/sessions/session2",
+			setup: func(m *handlerMocks) {
+				m.sessions.On("GetSession", mock.Anything, "client1", "app1", "session2").Return(localModels.VerificationSession{}, sessionServices.ErrSessionNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Open hosted session", method: http.MethodGet, path: "/hosted/session", url: "/hosted/session", token: "vs_session1",
+			setup: func(m *handlerMocks) {
+				authenticated(m, session)
+				m.sessions.On("OpenSession", mock.Anything, session).Return(session, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Open hosted session with an expired token", method: http.MethodGet, path: "/hosted/session", url: "/hosted/session", token: "vs_expired",
+			setup: func(m *handlerMocks) {
+				m.sessions.On("Authenticate", mock.Anything, "vs_expired").Return(localModels.VerificationSession{}, localModels.SessionChannel(""), sessionServices.ErrInvalidToken)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Upload hosted document", method: http.MethodPost, path: "/hosted/session/documents", url: "/hosted/session/documents", token: "vs_session1",
+			body: hostedForm, contentType: hostedType,
+			setup: func(m *handlerMocks) {
+				authenticated(m, session)
+				m.sessions.On("RecordSubmission", mock.Anything, session, mock.Anything).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Complete hosted session", method: http.MethodPost, path: "/hosted/session/complete", url: "/hosted/session/complete", token: "vs_session1",
+			setup: func(m *handlerMocks) {
+				completed := submitted
+				completed.Status = localModels.SessionCompleted
+				completed.CompletedAt = &now
+				completed.CompletedVia = localModels.ChannelLink
+				authenticated(m, submitted)
+				m.sessions.On("CompleteSession", mock.Anything, submitted, localModels.ChannelLink).Return(completed, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Complete hosted session without documents", method: http.MethodPost, path: "/hosted/session/complete", url: "/hosted/session/complete", token: "vs_session1",
+			setup: func(m *handlerMocks) {
+				authenticated(m, session)
+				m.sessions.On("CompleteSession", mock.Anything, session, localModels.ChannelLink).Return(localModels.VerificationSession{}, sessionServices.ErrNothingSubmitted)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Get handoff QR code", method: http.MethodGet, path: "/hosted/session/handoff.png", url: "/hosted/session/handoff.png?size=320", token: "vs_session1",
+			setup: func(m *handlerMocks) {
+				authenticated(m, session)
+				m.sessions.On("HandoffCode", mock.Anything, session, 320).Return([]byte("\x89PNG\r\n\x1a\n"), nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get handoff QR code of a completed session", method: http.MethodGet, path: "/hosted/session/handoff.png", url: "/hosted/session/handoff.png", token: "vs_session1",
+			setup: func(m *handlerMocks) {
+				authenticated(m, session)
+				m.sessions.On("HandoffCode", mock.Anything, session, 256).Return([]byte(nil), sessionServices.ErrSessionClosed)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			// The upgrade itself needs a connection the recorder cannot hand over
+			name: "Watch hosted session without a WebSocket upgrade", method: http.MethodGet, path: "/hosted/session/events", url: "/hosted/session/events", token: "vs_session1",
+			setup: func(m *handlerMocks) {
+				authenticated(m, session)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Get stats", method: http.MethodGet, path: "/stats", url: "/stats",
+			setup: func(m *handlerMocks) {
+				stats := localModels.ApplicantStats{
+					ApplicantsByStatus: map[string]int64{"approved": 2}, VerifiedApplicants: 2, AverageTimeToVerificationSeconds: 12.5,
+					RejectionReasons: map[string]int64{}, DocumentTypes: map[string]int64{"passport": 2}, GeneratedAt: now,
+				}
+				m.stats.On("GetClientStats", mock.Anything, "client1").Return(stats, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get labels", method: http.MethodGet, path: "/labels", url: "/labels",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List levels", method: http.MethodGet, path: "/levels", url: "/levels?country=DE&currency=EUR",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List levels in an unknown country", method: http.MethodGet, path: "/levels", url: "/levels?country=Atlantis",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Get webhook endpoint", method: http.MethodGet, path: "/webhook-endpoint", url: "/webhook-endpoint",
+			setup: func(m *handlerMocks) {
+				registered := endpoint
+				registered.Secret = ""
+				registered.Signing = &localModels.WebhookSigning{SecretHint: "whsec_1a2b3c..."}
+				m.webhooks.On("GetEndpoint", mock.Anything, "client1").Return(registered, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Get missing webhook endpoint", method: http.MethodGet, path: "/webhook-endpoint", url: "/webhook-endpoint",
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("GetEndpoint", mock.Anything, "client1").Return(localModels.WebhookEndpoint{}, webhookServices.ErrNoEndpoint)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Set webhook endpoint", method: http.MethodPut, path: "/webhook-endpoint", url: "/webhook-endpoint",
+			body: `{"url":"https://client.example.com/hooks"}`,
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("SetEndpoint", mock.Anything, "client1", endpoint.URL).Return(endpoint, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Set webhook endpoint to a relative URL", method: http.MethodPut, path: "/webhook-endpoint", url: "/webhook-endpoint",
+			body: `{"url":"/hooks"}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rotate webhook secret", method: http.MethodPost, path: "/webhook-endpoint/rotate-secret", url: "/webhook-endpoint/rotate-secret",
+			body: `{"grace_period_hours":48}`,
+			setup: func(m *handlerMocks) {
+				rotated := endpoint
+				retires := now.Add(48 * time.Hour)
+				rotated.Secret, rotated.SecretRotatedAt, rotated.PreviousSecretRetiresAt = "whsec_2", &now, &retires
+				m.webhooks.On("RotateSecret", mock.Anything, "client1", 48*time.Hour).Return(rotated, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Rotate webhook secret with too long a grace period", method: http.MethodPost, path: "/webhook-endpoint/rotate-secret", url: "/webhook-endpoint/rotate-secret",
+			body: `{"grace_period_hours":1000}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rotate webhook secret without an endpoint", method: http.MethodPost, path: "/webhook-endpoint/rotate-secret", url: "/webhook-endpoint/rotate-secret",
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("RotateSecret", mock.Anything, "client1", webhookServices.DefaultRotationGrace).Return(localModels.WebhookEndpoint{}, webhookServices.ErrNoEndpoint)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Verify webhook signature", method: http.MethodPost, path: "/webhooks/verify", url: "/webhooks/verify",
+			body: `{"payload":"{\"type\":\"security.alert\"}","signature":"t=1700000000,v1=ab"}`,
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("VerifySignature", mock.Anything, "client1", "t=1700000000,v1=ab", []byte(`{"type":"security.alert"}`)).
+					Return(localModels.WebhookVerification{Reason: webhookServices.ErrSignatureMismatch.Error()}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List webhook failures", method: http.MethodGet, path: "/webhooks/failures", url: "/webhooks/failures?limit=10",
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("ListDeadLetters", mock.Anything, "client1", firstPage(10)).Return([]localModels.WebhookDeadLetter{deadLetter}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "List webhook failures with too large a limit", method: http.MethodGet, path: "/webhooks/failures", url: "/webhooks/failures?limit=1000",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Redeliver webhook", method: http.MethodPost, path: "/webhooks/failures/{id}/redeliver", url: "/webhooks/failures/evt1/redeliver",
+			setup: func(m *handlerMocks) {
+				redelivered := deadLetter
+				redelivered.Redeliveries, redelivered.RedeliveredAt, redelivered.RedeliveredBy = 1, &now, "client1"
+				m.webhooks.On("Redeliver", mock.Anything, "client1", "evt1", "client1").Return(redelivered, nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "Redeliver webhook already being redelivered", method: http.MethodPost, path: "/webhooks/failures/{id}/redeliver", url: "/webhooks/failures/evt1/redeliver",
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("Redeliver", mock.Anything, "client1", "evt1", "client1").Return(localModels.WebhookDeadLetter{}, webhookServices.ErrRedeliveryInProgress)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Redeliver missing webhook", method: http.MethodPost, path: "/webhooks/failures/{id}/redeliver", url: "/webhooks/failures/evt9/redeliver",
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("Redeliver", mock.Anything, "client1", "evt9", "client1").Return(localModels.WebhookDeadLetter{}, webhookServices.ErrDeadLetterNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Verify webhook signature without a payload", method: http.MethodPost, path: "/webhooks/verify", url: "/webhooks/verify",
+			body: `{"signature":"t=1700000000,v1=ab"}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Test webhook", method: http.MethodPost, path: "/sandbox/webhooks/test", url: "/sandbox/webhooks/test",
+			body: `{"type":"document.upload_failed"}`,
+			setup: func(m *handlerMocks) {
+				result := localModels.WebhookTestResult{
+					EventID: "test_evt1", Type: localModels.WebhookDocumentUploadFailed, URL: "https://client.example.com/webhooks",
+					Delivered: true, StatusCode: http.StatusOK, LatencyMS: 42, ResponseExcerpt: "ok",
+				}
+				m.webhooks.On("SendTestEvent", mock.Anything, "client1", localModels.WebhookDocumentUploadFailed).Return(result, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Test webhook of an unknown type", method: http.MethodPost, path: "/sandbox/webhooks/test", url: "/sandbox/webhooks/test",
+			body: `{"type":"applicant.created"}`,
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("SendTestEvent", mock.Anything, "client1", "applicant.created").
+					Return(localModels.WebhookTestResult{}, fmt.Errorf("%w: type must be one of document.restored, document.upload_failed, report.completed, security.alert", webhookServices.ErrUnknownEventType))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Test webhook without an endpoint", method: http.MethodPost, path: "/sandbox/webhooks/test", url: "/sandbox/webhooks/test",
+			body: `{"type":"security.alert"}`,
+			setup: func(m *handlerMocks) {
+				m.webhooks.On("SendTestEvent", mock.Anything, "client1", localModels.WebhookSecurityAlert).Return(localModels.WebhookTestResult{}, webhookServices.ErrNoEndpoint)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Get IP allowlist", method: http.MethodGet, path: "/ip-allowlist", url: "/ip-allowlist",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Set IP allowlist", method: http.MethodPut, path: "/ip-allowlist", url: "/ip-allowlist",
+			body: `{"cidrs":["192.0.2.0/24"]}`,
+			setup: func(m *handlerMocks) {
+				allowlist := localModels.IPAllowlist{CIDRs: []string{"192.0.2.0/24"}, UpdatedBy: "client1", UpdatedAt: &now}
+				m.clients.On("SetIPAllowlist", mock.Anything, "client1", []string{"192.0.2.0/24"}, "client1").Return(allowlist, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Set IP allowlist locking the caller out", method: http.MethodPut, path: "/ip-allowlist", url: "/ip-allowlist",
+			body: `{"cidrs":["203.0.113.0/24"]}`, wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	exercised := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			router := setupContractRouter(m)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, spec.BasePath()+tt.url, strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", contentType)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			assert.NoError(t, spec.Validate(tt.method, tt.path, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes()), w.Body.String())
+		})
+		exercised[tt.method+" "+tt.path] = true
+	}
+
+	// Every documented operation must be checked against a handler
+	for _, route := range spec.Routes() {
+		assert.True(t, exercised[route.Method+" "+route.Path], "%s %s has no contract test", route.Method, route.Path)
+	}
+}
@@ -0,0 +1,130 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mock serves the description's examples in place of the API, in the manner of a Prism
+// mock server. A request gets the example of the operation's first 2xx response, or of
+// the status named in a Prefer: code=<status> header. Requests to operations that need
+// credentials but send neither an API key nor a bearer token get the documented 401.
+// Request bodies are not checked.
+func (s *Spec) Mock() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := strings.CutPrefix(r.URL.Path, s.BasePath())
+		if !ok {
+			writeMockError(w, http.StatusNotFound, "no such route")
+			return
+		}
+		template, ok := s.Match(path)
+		if !ok {
+			writeMockError(w, http.StatusNotFound, "no such route")
+			return
+		}
+		operation, ok := s.Operation(r.Method, template)
+		if !ok {
+			writeMockError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		status := successStatus(operation)
+		if len(operation.Security) > 0 && !hasCredentials(r) {
+			status = http.StatusUnauthorized
+		} else if preferred, ok := preferredStatus(r); ok {
+			status = preferred
+		}
+		response, err := s.Response(operation, status)
+		if err != nil {
+			writeMockError(w, http.StatusBadRequest, fmt.Sprintf("%s %s: %v", r.Method, template, err))
+			return
+		}
+		writeExample(w, status, response)
+	})
+}
+
+// successStatus is the lowest 2xx status documented for an operation
+func successStatus(operation *Operation) int {
+	var codes []int
+	for code := range operation.Responses {
+		if n, err := strconv.Atoi(code); err == nil && n >= 200 && n < 300 {
+			codes = append(codes, n)
+		}
+	}
+	if len(codes) == 0 {
+		return http.StatusOK
+	}
+	sort.Ints(codes)
+	return codes[0]
+}
+
+// preferredStatus reads the status a caller asked for with Prefer: code=<status>
+func preferredStatus(r *http.Request) (int, bool) {
+	for _, preference := range strings.Split(r.Header.Get("Prefer"), ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(preference), "code="); ok {
+			status, err := strconv.Atoi(value)
+			return status, err == nil
+		}
+	}
+	return 0, false
+}
+
+// hasCredentials reports whether a request carries an API key or a bearer token. The
+// mock accepts any value.
+func hasCredentials(r *http.Request) bool {
+	if r.Header.Get("X-API-Key") != "" {
+		return true
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	return strings.EqualFold(scheme, "Bearer") && token != ""
+}
+
+// writeExample writes a response's example, preferring JSON where several media types
+// are documented. Media type ranges are served as application/octet-stream.
+func writeExample(w http.ResponseWriter, status int, response *Response) {
+	if len(response.Content) == 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	mediaTypes := make([]string, 0, len(response.Content))
+	for mediaType := range response.Content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Slice(mediaTypes, func(i, j int) bool {
+		if isJSON(mediaTypes[i]) != isJSON(mediaTypes[j]) {
+			return isJSON(mediaTypes[i])
+		}
+		return mediaTypes[i] < mediaTypes[j]
+	})
+	mediaType, example := mediaTypes[0], response.Content[mediaTypes[0]].Example
+
+	if strings.Contains(mediaType, "*") {
+		mediaType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", mediaType)
+	if !isJSON(mediaType) {
+		w.WriteHeader(status)
+		if example != nil {
+			fmt.Fprint(w, example)
+		}
+		return
+	}
+	body, err := json.Marshal(example)
+	if err != nil {
+		writeMockError(w, http.StatusInternalServerError, "example cannot be encoded as JSON")
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func writeMockError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
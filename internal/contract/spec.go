@@ -0,0 +1,368 @@
+// Package contract reads the OpenAPI description of the client API in docs/openapi.yaml,
+// checks responses against it and serves its examples as a mock of the API, so client
+// SDKs can be tested without a running backend.
+//
+// Only the subset of OpenAPI the description uses is understood: schema types, the
+// date-time format, nullable, enum, required, properties, additionalProperties, items,
+// allOf and local $refs. Length and range limits are documentation only.
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is where the description lives, relative to the repository root
+const DefaultPath = "docs/openapi.yaml"
+
+// Spec is a parsed OpenAPI description
+type Spec struct {
+	Servers []struct {
+		URL string `yaml:"url"`
+	} `yaml:"servers"`
+	Paths      map[string]map[string]*Operation `yaml:"paths"` // Path template, then lower case method
+	Components struct {
+		Schemas   map[string]*Schema   `yaml:"schemas"`
+		Responses map[string]*Response `yaml:"responses"`
+	} `yaml:"components"`
+}
+
+// Operation is one method on a path
+type Operation struct {
+	OperationID string                `yaml:"operationId"`
+	Security    []map[string][]string `yaml:"security"` // Empty when the operation needs no credentials
+	Responses   map[string]*Response  `yaml:"responses"`
+}
+
+// Response is a documented response, keyed by status code in its operation
+type Response struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content"` // By media type, which may be a range such as */*
+}
+
+// MediaType is the body of a response in one media type
+type MediaType struct {
+	Schema  *Schema     `yaml:"schema"`
+	Example interface{} `yaml:"example"`
+}
+
+// Schema describes a JSON value
+type Schema struct {
+	Ref                  string             `yaml:"$ref"`
+	Type                 string             `yaml:"type"` // Empty allows any type
+	Format               string             `yaml:"format"`
+	Nullable             bool               `yaml:"nullable"`
+	Enum                 []interface{}      `yaml:"enum"`
+	Required             []string           `yaml:"required"`
+	Properties           map[string]*Schema `yaml:"properties"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties"` // Unlisted properties are allowed either way
+	Items                *Schema            `yaml:"items"`
+	AllOf                []*Schema          `yaml:"allOf"`
+}
+
+// Route is an operation and where it is found
+type Route struct {
+	Method string // Upper case
+	Path   string // Template relative to the base path, e.g. /applicants/{id}
+	*Operation
+}
+
+// Load reads and parses the description at path
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API description: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses a description
+func Parse(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse API description: %w", err)
+	}
+	if len(spec.Paths) == 0 {
+		return nil, errors.New("API description has no paths")
+	}
+	return &spec, nil
+}
+
+// BasePath is the prefix of every path in the description, e.g. /api/v2
+func (s *Spec) BasePath() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(s.Servers[0].URL, "/")
+}
+
+// Routes lists every operation, ordered by path then method
+func (s *Spec) Routes() []Route {
+	var routes []Route
+	for path, operations := range s.Paths {
+		for method, operation := range operations {
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: path, Operation: operation})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Operation returns the operation for a method on a path template
+func (s *Spec) Operation(method, path string) (*Operation, bool) {
+	operation, ok := s.Paths[path][strings.ToLower(method)]
+	return operation, ok
+}
+
+// Match finds the path template a request path relative to the base path falls under.
+// Where several match, the one with the fewest parameters wins.
+func (s *Spec) Match(path string) (string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	best, bestParams := "", -1
+	for template := range s.Paths {
+		params, ok := matchTemplate(template, segments)
+		if !ok {
+			continue
+		}
+		if bestParams < 0 || params < bestParams || (params == bestParams && template < best) {
+			best, bestParams = template, params
+		}
+	}
+	return best, bestParams >= 0
+}
+
+// matchTemplate reports whether path segments fit a template, and how many parameters that took
+func matchTemplate(template string, segments []string) (int, bool) {
+	parts := strings.Split(strings.Trim(template, "/"), "/")
+	if len(parts) != len(segments) {
+		return 0, false
+	}
+	params := 0
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			if segments[i] == "" {
+				return 0, false
+			}
+			params++
+		case part != segments[i]:
+			return 0, false
+		}
+	}
+	return params, true
+}
+
+// Response returns the documented response of an operation for a status code, following $refs
+func (s *Spec) Response(operation *Operation, status int) (*Response, error) {
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		if response, ok = operation.Responses["default"]; !ok {
+			return nil, fmt.Errorf("status %d is not documented", status)
+		}
+	}
+	if response.Ref == "" {
+		return response, nil
+	}
+	name, ok := strings.CutPrefix(response.Ref, "#/components/responses/")
+	resolved := s.Components.Responses[name]
+	if !ok || resolved == nil {
+		return nil, fmt.Errorf("unknown response %s", response.Ref)
+	}
+	return resolved, nil
+}
+
+// Validate checks a response to a method on a path template against the description
+func (s *Spec) Validate(method, path string, status int, contentType string, body []byte) error {
+	operation, ok := s.Operation(method, path)
+	if !ok {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+	response, err := s.Response(operation, status)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if err := s.validateBody(response, contentType, body); err != nil {
+		return fmt.Errorf("%s %s %d: %w", method, path, status, err)
+	}
+	return nil
+}
+
+func (s *Spec) validateBody(response *Response, contentType string, body []byte) error {
+	if len(response.Content) == 0 {
+		if len(body) > 0 {
+			return errors.New("response has a body but none is documented")
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q", contentType)
+	}
+	documented := documentedMediaType(response, mediaType)
+	if documented == nil {
+		return fmt.Errorf("content type %s is not documented", mediaType)
+	}
+	if documented.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("body is not valid JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("body holds more than one JSON value")
+	}
+	return s.validate(documented.Schema, value, "$")
+}
+
+// documentedMediaType finds the documented body a media type falls under, allowing ranges
+func documentedMediaType(response *Response, mediaType string) *MediaType {
+	if documented, ok := response.Content[mediaType]; ok {
+		return documented
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	if documented, ok := response.Content[major+"/*"]; ok {
+		return documented
+	}
+	return response.Content["*/*"]
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// schema follows a $ref to the schema it names
+func (s *Spec) schema(schema *Schema) (*Schema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+	name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+	resolved := s.Components.Schemas[name]
+	if !ok || resolved == nil {
+		return nil, fmt.Errorf("unknown schema %s", schema.Ref)
+	}
+	return s.schema(resolved)
+}
+
+// validate checks a decoded JSON value against a schema. at locates the value in the
+// body for error messages, e.g. $.items[0].created_at
+func (s *Spec) validate(schema *Schema, value interface{}, at string) error {
+	schema, err := s.schema(schema)
+	if err != nil {
+		return err
+	}
+	for _, part := range schema.AllOf {
+		if err := s.validate(part, value, at); err != nil {
+			return err
+		}
+	}
+	if value == nil {
+		if schema.Nullable || (schema.Type == "" && len(schema.AllOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s is null", at)
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		return fmt.Errorf("%s is %v, not one of %v", at, value, schema.Enum)
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(at, "an object", value)
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s.%s is required", at, name)
+			}
+		}
+		for name, property := range object {
+			propertySchema := schema.Properties[name]
+			if propertySchema == nil {
+				propertySchema = schema.AdditionalProperties
+			}
+			if propertySchema == nil {
+				continue
+			}
+			if err := s.validate(propertySchema, property, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return typeError(at, "an array", value)
+		}
+		if schema.Items == nil {
+			return nil
+		}
+		for i, item := range array {
+			if err := s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return typeError(at, "a string", value)
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s is not an RFC 3339 date-time: %q", at, str)
+			}
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok || strings.ContainsAny(number.String(), ".eE") {
+			return typeError(at, "an integer", value)
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return typeError(at, "a number", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(at, "a boolean", value)
+		}
+	default:
+		return fmt.Errorf("%s has unsupported schema type %q", at, schema.Type)
+	}
+	return nil
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeError(at, want string, value interface{}) error {
+	return fmt.Errorf("%s should be %s, got %T", at, want, value)
+}
@@ -0,0 +1,158 @@
+package contract
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExamplesMatchSchemas(t *testing.T) {
+	spec, err := Load(specPath)
+	require.NoError(t, err)
+
+	for _, route := range spec.Routes() {
+		for code := range route.Responses {
+			status, err := strconv.Atoi(code)
+			require.NoError(t, err, "%s %s documents status %q", route.Method, route.Path, code)
+			response, err := spec.Response(route.Operation, status)
+			require.NoError(t, err)
+
+			for mediaType, content := range response.Content {
+				if !isJSON(mediaType) {
+					continue
+				}
+				require.NotNil(t, content.Example, "%s %s %d has no example", route.Method, route.Path, status)
+				body, err := json.Marshal(content.Example)
+				require.NoError(t, err)
+				assert.NoError(t, spec.Validate(route.Method, route.Path, status, mediaType, body))
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	spec, err := Load(specPath)
+	require.NoError(t, err)
+
+	stats := `{"applicants_by_status":{"approved":1},"verified_applicants":1,"rejection_reasons":{},"document_types":{},"generated_at":"2025-01-15T12:00:00Z"}`
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{name: "Valid body", method: http.MethodGet, path: "/stats", status: http.StatusOK, body: stats},
+		{name: "Unlisted properties are allowed", method: http.MethodGet, path: "/stats", status: http.StatusOK, body: strings.Replace(stats, "{", `{"extra":true,`, 1)},
+		{name: "Missing required property", method: http.MethodGet, path: "/stats", status: http.StatusOK, body: `{"verified_applicants":1}`, wantErr: "$.applicants_by_status is required"},
+		{name: "Wrong type", method: http.MethodGet, path: "/stats", status: http.StatusOK, body: strings.Replace(stats, `"verified_applicants":1`, `"verified_applicants":"1"`, 1), wantErr: "$.verified_applicants should be an integer"},
+		{name: "Fraction for an integer", method: http.MethodGet, path: "/stats", status: http.StatusOK, body: strings.Replace(stats, `"approved":1`, `"approved":1.5`, 1), wantErr: "$.applicants_by_status.approved should be an integer"},
+		{name: "Bad date-time", method: http.MethodGet, path: "/stats", status: http.StatusOK, body: strings.Replace(stats, "2025-01-15T12:00:00Z", "yesterday", 1), wantErr: "not an RFC 3339 date-time"},
+		{name: "Value outside enum", method: http.MethodGet, path: "/applicants/{id}", status: http.StatusOK, body: `{"applicant_id":"a","status":"lost"}`, wantErr: "$.status is lost"},
+		{name: "Null where not nullable", method: http.MethodGet, path: "/applicants/{id}", status: http.StatusOK, body: `{"applicant_id":null}`, wantErr: "$.applicant_id is null"},
+		{name: "Array items are checked", method: http.MethodGet, path: "/applicants", status: http.StatusOK, body: `[{"applicant_id":"a"},{}]`, wantErr: "$[1].applicant_id is required"},
+		{name: "allOf parts are all checked", method: http.MethodPost, path: "/applicants/{id}/documents", status: http.StatusOK, body: `{"document_id":"d","applicant_id":"a","document_type":0,"status":0}`, wantErr: "$.checks is required"},
+		{name: "Undocumented status", method: http.MethodGet, path: "/stats", status: http.StatusTeapot, body: `{}`, wantErr: "status 418 is not documented"},
+		{name: "Undocumented operation", method: http.MethodDelete, path: "/stats", status: http.StatusOK, body: `{}`, wantErr: "DELETE /stats is not documented"},
+		{name: "Undocumented content type", method: http.MethodGet, path: "/stats", status: http.StatusOK, contentType: "text/plain", body: stats, wantErr: "content type text/plain is not documented"},
+		{name: "Media type range", method: http.MethodGet, path: "/applicants/{id}/attachments/{attachmentId}", status: http.StatusOK, contentType: "application/pdf", body: "%PDF"},
+		{name: "Trailing data", method: http.MethodGet, path: "/stats", status: http.StatusOK, body: stats + stats, wantErr: "more than one JSON value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json; charset=utf-8"
+			}
+			err := spec.Validate(tt.method, tt.path, tt.status, contentType, []byte(tt.body))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	spec, err := Load(specPath)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path     string
+		want     string
+		wantNone bool
+	}{
+		{path: "/applicants", want: "/applicants"},
+		{path: "/applicants/app1", want: "/applicants/{id}"},
+		{path: "/applicants/app1/documents/doc1/versions", want: "/applicants/{id}/documents/{docId}/versions"},
+		{path: "/applicants//documents", wantNone: true},
+		{path: "/unknown", wantNone: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := spec.Match(tt.path)
+			assert.Equal(t, !tt.wantNone, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMock(t *testing.T) {
+	spec, err := Load(specPath)
+	require.NoError(t, err)
+	server := httptest.NewServer(spec.Mock())
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		headers    map[string]string
+		wantStatus int
+		template   string // Set to check the body against the description
+	}{
+		{name: "Success example", method: http.MethodGet, path: "/api/v2/stats", headers: map[string]string{"X-API-Key": "key"}, wantStatus: http.StatusOK, template: "/stats"},
+		{name: "Bearer token", method: http.MethodGet, path: "/api/v2/applicants/app1", headers: map[string]string{"Authorization": "Bearer token"}, wantStatus: http.StatusOK, template: "/applicants/{id}"},
+		{name: "Lowest success status", method: http.MethodPost, path: "/api/v2/applicants/app1/notes", headers: map[string]string{"X-API-Key": "key"}, wantStatus: http.StatusCreated, template: "/applicants/{id}/notes"},
+		{name: "Missing credentials", method: http.MethodGet, path: "/api/v2/stats", wantStatus: http.StatusUnauthorized, template: "/stats"},
+		{name: "Open operation", method: http.MethodPost, path: "/api/v2/auth/token", wantStatus: http.StatusOK, template: "/auth/token"},
+		{name: "Preferred status", method: http.MethodGet, path: "/api/v2/applicants/app1/documents/doc1", headers: map[string]string{"X-API-Key": "key", "Prefer": "code=404"}, wantStatus: http.StatusNotFound, template: "/applicants/{id}/documents/{docId}"},
+		{name: "Undocumented preferred status", method: http.MethodGet, path: "/api/v2/stats", headers: map[string]string{"X-API-Key": "key", "Prefer": "code=418"}, wantStatus: http.StatusBadRequest},
+		{name: "Unknown path", method: http.MethodGet, path: "/api/v2/unknown", wantStatus: http.StatusNotFound},
+		{name: "Outside the base path", method: http.MethodGet, path: "/api/v1/stats", wantStatus: http.StatusNotFound},
+		{name: "Undocumented method", method: http.MethodDelete, path: "/api/v2/stats", wantStatus: http.StatusMethodNotAllowed},
+		{name: "Binary response", method: http.MethodGet, path: "/api/v2/applicants/app1/attachments/att1", headers: map[string]string{"X-API-Key": "key"}, wantStatus: http.StatusOK, template: "/applicants/{id}/attachments/{attachmentId}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, nil)
+			require.NoError(t, err)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode, string(body))
+			if tt.template != "" {
+				assert.NoError(t, spec.Validate(tt.method, tt.template, resp.StatusCode, resp.Header.Get("Content-Type"), body))
+			}
+		})
+	}
+}
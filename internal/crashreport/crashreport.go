@@ -0,0 +1,78 @@
+// Package crashreport recovers panics raised while handling a request, answers them with
+// the standard 500 response and a correlation ID, and reports them with their stack and
+// request context to the error reporting service in settings.errorReporting: Sentry or
+// Rollbar. Without one, panics are only logged.
+//
+// Clients quote the correlation ID, which is also the ID of the report and appears in the
+// log entry, when they ask about a failed request.
+package crashreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/egress"
+)
+
+// defaultTimeout bounds each report when the settings give no timeout
+const defaultTimeout = 5 * time.Second
+
+// ErrInvalidDSN is returned when a Sentry DSN does not name a key and a project
+var ErrInvalidDSN = errors.New("sentry DSN must be of the form https://<key>@<host>/<project>")
+
+// Event is a recovered panic and the request it interrupted
+type Event struct {
+	CorrelationID string
+	Panic         string // The recovered value
+	Stack         string
+	Method        string
+	Route         string // The route's pattern, such as /api/v2/applicants/:id, or "unmatched"
+	Path          string
+	ClientID      string // Empty when the panic came before the client was authenticated
+	Time          time.Time
+}
+
+// Reporter sends events to an error reporting service
+type Reporter interface {
+	Report(ctx context.Context, event Event) error
+}
+
+// New returns the reporter settings choose for the environment env, or nil when they
+// choose none. Reports are tagged with env unless the settings name another environment.
+func New(settings config.ErrorReportingSettings, env string) (Reporter, error) {
+	environment := settings.Environment
+	if environment == "" {
+		environment = env
+	}
+	timeout := settings.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	switch settings.Provider {
+	case "":
+		return nil, nil
+	case "sentry":
+		dsn := settings.DSN
+		if dsn == "" {
+			dsn = os.Getenv("SENTRY_DSN")
+		}
+		reporter, err := newSentry(dsn, environment, egress.Client(timeout))
+		if err != nil {
+			return nil, err
+		}
+		return reporter, nil
+	case "rollbar":
+		token := settings.AccessToken
+		if token == "" {
+			token = os.Getenv("ROLLBAR_ACCESS_TOKEN")
+		}
+		return &rollbar{endpoint: rollbarEndpoint, token: token, environment: environment, client: egress.Client(timeout)}, nil
+	default:
+		return nil, fmt.Errorf("unknown error reporting provider %q", settings.Provider)
+	}
+}
@@ -0,0 +1,162 @@
+package crashreport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// events collects what the middleware reports
+type events chan Event
+
+func (e events) Report(_ context.Context, event Event) error {
+	e <- event
+	return nil
+}
+
+func TestRecovery(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	reported := make(events, 1)
+	router := gin.New()
+	router.Use(Recovery(reported, zap.New(core)))
+	router.Use(func(c *gin.Context) { c.Set("client_id", "client1") })
+	router.GET("/applicants/:id", func(c *gin.Context) { panic("nil map") })
+	router.GET("/aborted", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/applicants/app1", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	correlationID := w.Header().Get(Header)
+	require.NotEmpty(t, correlationID)
+	assert.Equal(t, map[string]string{"error": "Internal server error", "code": "internal_error", "correlation_id": correlationID}, body)
+
+	select {
+	case event := <-reported:
+		assert.Equal(t, correlationID, event.CorrelationID)
+		assert.Equal(t, "nil map", event.Panic)
+		assert.Contains(t, event.Stack, "crashreport_test.go")
+		assert.Equal(t, http.MethodGet, event.Method)
+		assert.Equal(t, "/applicants/:id", event.Route)
+		assert.Equal(t, "/applicants/app1", event.Path)
+		assert.Equal(t, "client1", event.ClientID)
+	case <-time.After(time.Second):
+		t.Fatal("the panic was not reported")
+	}
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "Recovered from panic", logs.All()[0].Message)
+	assert.Equal(t, correlationID, logs.All()[0].ContextMap()["correlation_id"])
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/aborted", nil))
+	})
+}
+
+func TestRecoveryLogsFailedReports(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	router := gin.New()
+	router.Use(Recovery(failing{}, zap.New(core)))
+	router.GET("/", func(c *gin.Context) { panic("boom") })
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Eventually(t, func() bool { return logs.FilterMessage("Could not report panic").Len() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+type failing struct{}
+
+func (failing) Report(context.Context, Event) error { return errors.New("connection refused") }
+
+var event = Event{
+	CorrelationID: "0192a3b4-c5d6-7e8f-9a0b-1c2d3e4f5a6b",
+	Panic:         "nil map",
+	Stack:         "goroutine 1 [running]:",
+	Method:        http.MethodPost,
+	Route:         "/api/v2/applicants/:id",
+	Path:          "/api/v2/applicants/app_1",
+	ClientID:      "client1",
+	Time:          time.Date(2026, 10, 29, 12, 0, 0, 0, time.UTC),
+}
+
+// capture answers every request with status and keeps the last one's headers and body
+func capture(t *testing.T, status int) (*httptest.Server, *http.Header, *map[string]interface{}) {
+	headers, body := &http.Header{}, &map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*headers = r.Header.Clone()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(body))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, headers, body
+}
+
+func TestSentry(t *testing.T) {
+	server, headers, body := capture(t, http.StatusOK)
+	dsn := strings.Replace(server.URL, "http://", "http://abc123@", 1) + "/42"
+	reporter, err := New(config.ErrorReportingSettings{Provider: "sentry", DSN: dsn}, "sandbox")
+	require.NoError(t, err)
+
+	require.NoError(t, reporter.Report(context.Background(), event))
+	assert.Equal(t, server.URL+"/api/42/store/", reporter.(*sentry).endpoint)
+	assert.Contains(t, headers.Get("X-Sentry-Auth"), "sentry_key=abc123")
+	assert.Equal(t, "0192a3b4c5d67e8f9a0b1c2d3e4f5a6b", (*body)["event_id"])
+	assert.Equal(t, "sandbox", (*body)["environment"])
+	assert.Equal(t, map[string]interface{}{
+		"client_id":      "client1",
+		"route":          "/api/v2/applicants/:id",
+		"correlation_id": event.CorrelationID,
+	}, (*body)["tags"])
+	assert.Equal(t, map[string]interface{}{"stack": event.Stack}, (*body)["extra"])
+
+	_, err = New(config.ErrorReportingSettings{Provider: "sentry", DSN: "https://o0.ingest.sentry.io/42"}, "prod")
+	assert.ErrorIs(t, err, ErrInvalidDSN)
+	prefixed, err := newSentry("https://abc123@example.com/sentry/42", "prod", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/sentry/api/42/store/", prefixed.endpoint)
+}
+
+func TestRollbar(t *testing.T) {
+	server, headers, body := capture(t, http.StatusOK)
+	reporter := &rollbar{endpoint: server.URL, token: "token", environment: "staging", client: http.DefaultClient}
+
+	require.NoError(t, reporter.Report(context.Background(), event))
+	assert.Equal(t, "token", headers.Get("X-Rollbar-Access-Token"))
+	data := (*body)["data"].(map[string]interface{})
+	assert.Equal(t, event.CorrelationID, data["uuid"])
+	assert.Equal(t, "staging", data["environment"])
+	assert.Equal(t, "/api/v2/applicants/:id", data["context"])
+	assert.Equal(t, "client1", data["custom"].(map[string]interface{})["client_id"])
+
+	rejecting, _, _ := capture(t, http.StatusUnauthorized)
+	reporter.endpoint = rejecting.URL
+	assert.EqualError(t, reporter.Report(context.Background(), event), "rollbar responded with status 401")
+}
+
+func TestNew(t *testing.T) {
+	reporter, err := New(config.ErrorReportingSettings{}, "prod")
+	assert.NoError(t, err)
+	assert.Nil(t, reporter)
+
+	reporter, err = New(config.ErrorReportingSettings{Provider: "rollbar", AccessToken: "token", Environment: "prod-eu"}, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "prod-eu", reporter.(*rollbar).environment)
+	assert.Equal(t, rollbarEndpoint, reporter.(*rollbar).endpoint)
+
+	_, err = New(config.ErrorReportingSettings{Provider: "bugsnag"}, "prod")
+	assert.Error(t, err)
+}
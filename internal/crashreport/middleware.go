@@ -0,0 +1,82 @@
+package crashreport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	"go.uber.org/zap"
+)
+
+// Header carries the correlation ID of a request that failed with a panic
+const Header = "X-Correlation-ID"
+
+// Recovery answers a request whose handler panicked with 500, code internal_error and a
+// correlation ID, logs the panic with its stack, and reports it to reporter unless it is
+// nil. Reports are sent in the background so the client is not kept waiting for them.
+//
+// It must be mounted after accesslog.Middleware, so the failed request is still logged.
+func Recovery(reporter Reporter, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// Raised to abort the response on purpose; net/http handles it quietly
+				panic(recovered)
+			}
+
+			event := Event{
+				CorrelationID: ids.New(),
+				Panic:         fmt.Sprint(recovered),
+				Stack:         string(debug.Stack()),
+				Method:        c.Request.Method,
+				Route:         c.FullPath(),
+				Path:          c.Request.URL.Path,
+				ClientID:      c.GetString("client_id"),
+				Time:          time.Now(),
+			}
+			if event.Route == "" {
+				event.Route = "unmatched"
+			}
+
+			c.Header(Header, event.CorrelationID)
+			if c.Writer.Written() {
+				// Part of the response is out; it cannot be replaced
+				c.Abort()
+			} else {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error":          "Internal server error",
+					"code":           "internal_error",
+					"correlation_id": event.CorrelationID,
+				})
+			}
+
+			logger.Error("Recovered from panic",
+				zap.String("panic", event.Panic),
+				zap.String("stack", event.Stack),
+				zap.String("correlation_id", event.CorrelationID),
+				zap.String("method", event.Method),
+				zap.String("route", event.Route),
+				zap.String("client_id", event.ClientID),
+			)
+			if reporter != nil {
+				go report(reporter, event, logger)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// report sends an event, logging it if the service could not be reached
+func report(reporter Reporter, event Event, logger *zap.Logger) {
+	if err := reporter.Report(context.Background(), event); err != nil {
+		logger.Warn("Could not report panic", zap.Error(err), zap.String("correlation_id", event.CorrelationID))
+	}
+}
@@ -0,0 +1,53 @@
+package crashreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// rollbarEndpoint is Rollbar's item API
+const rollbarEndpoint = "https://api.rollbar.com/api/1/item/"
+
+// rollbar sends events to a Rollbar project through its item API
+type rollbar struct {
+	endpoint    string
+	token       string
+	environment string
+	client      *http.Client
+}
+
+func (r *rollbar) Report(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{
+			"uuid":        event.CorrelationID,
+			"timestamp":   event.Time.Unix(),
+			"level":       "critical",
+			"platform":    "go",
+			"language":    "go",
+			"environment": r.environment,
+			"context":     event.Route,
+			"body": map[string]interface{}{
+				"message": map[string]string{"body": "panic: " + event.Panic + "\n\n" + event.Stack},
+			},
+			"request": map[string]string{"method": event.Method, "url": event.Path},
+			"custom": map[string]string{
+				"client_id":      event.ClientID,
+				"route":          event.Route,
+				"correlation_id": event.CorrelationID,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Rollbar-Access-Token", r.token)
+	return send(r.client, req, "rollbar")
+}
@@ -0,0 +1,89 @@
+package crashreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// sentry sends events to a Sentry project through its store endpoint
+type sentry struct {
+	endpoint    string
+	key         string
+	environment string
+	client      *http.Client
+}
+
+// newSentry returns a reporter for the project a DSN names
+func newSentry(dsn, environment string, client *http.Client) (*sentry, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.User.Username() == "" {
+		return nil, ErrInvalidDSN
+	}
+	path := strings.Trim(parsed.Path, "/")
+	if path == "" {
+		return nil, ErrInvalidDSN
+	}
+	// A DSN may put the project under a path prefix, as in https://key@host/sentry/42
+	prefix, project := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		prefix, project = path[:i+1], path[i+1:]
+	}
+	return &sentry{
+		endpoint:    fmt.Sprintf("%s://%s/%sapi/%s/store/", parsed.Scheme, parsed.Host, prefix, project),
+		key:         parsed.User.Username(),
+		environment: environment,
+		client:      client,
+	}, nil
+}
+
+func (s *sentry) Report(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]interface{}{
+		// Sentry event IDs are UUIDs without hyphens, so the report is found by correlation ID
+		"event_id":    strings.ReplaceAll(event.CorrelationID, "-", ""),
+		"timestamp":   event.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"level":       "fatal",
+		"platform":    "go",
+		"logger":      "crashreport",
+		"environment": s.environment,
+		"transaction": event.Route,
+		"exception": map[string]interface{}{
+			"values": []map[string]string{{"type": "panic", "value": event.Panic}},
+		},
+		"tags": map[string]string{
+			"client_id":      event.ClientID,
+			"route":          event.Route,
+			"correlation_id": event.CorrelationID,
+		},
+		"request": map[string]string{"method": event.Method, "url": event.Path},
+		"extra":   map[string]string{"stack": event.Stack},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=verus-crashreport/1.0, sentry_key="+s.key)
+	return send(s.client, req, "sentry")
+}
+
+// send makes a report's request and fails unless the service accepted it
+func send(client *http.Client, req *http.Request, service string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", service, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s responded with status %d", service, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "* * * * *"},
+		{expr: "*/15 8-18 * * 1-5"},
+		{expr: "0,30 0 1 1,7 0"},
+		{expr: "5/20 * * * 7"},
+		{expr: "@daily"},
+		{expr: "* * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "10-5 * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+		{expr: "@sometimes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expr, schedule.String())
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, 1, 15, 9, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "* * * * *", want: time.Date(2025, 1, 15, 9, 8, 0, 0, time.UTC)},
+		{expr: "*/15 * * * *", want: time.Date(2025, 1, 15, 9, 15, 0, 0, time.UTC)},
+		{expr: "5/20 * * * *", want: time.Date(2025, 1, 15, 9, 25, 0, 0, time.UTC)},
+		{expr: "0 3 * * *", want: time.Date(2025, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{expr: "@hourly", want: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{expr: "0 0 * * 0", want: time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 * * 7", want: time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{expr: "30 8 1 * *", want: time.Date(2025, 2, 1, 8, 30, 0, 0, time.UTC)},
+		{expr: "0 0 1 6 *", want: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// A restricted day of month and day of week are alternatives: the 20th or a Friday
+		{expr: "0 0 20 * 5", want: time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 31 2 *", want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(from))
+		})
+	}
+}
+
+func TestNextConvertsToUTC(t *testing.T) {
+	schedule, err := Parse("0 12 * * *")
+	require.NoError(t, err)
+
+	from := time.Date(2025, 1, 15, 11, 0, 0, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC), schedule.Next(from))
+}
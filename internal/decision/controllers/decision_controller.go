@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+)
+
+// respondDecisionError maps decision service errors to HTTP responses
+func respondDecisionError(c *gin.Context, err error) {
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if !apperr.Respond(c, err) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not record decision"})
+	}
+}
+
+// ProposeDecision is the handler function for a reviewer approving or rejecting an applicant
+func ProposeDecision(c *gin.Context, service interfaces.DecisionService, decision localModels.ReviewDecision) {
+	reviewerID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestBody struct {
+		ReasonCode string `json:"reason_code"`
+		Comment    string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if err := localModels.ValidateReasonCode(decision, requestBody.ReasonCode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":              err.Error(),
+			"valid_reason_codes": localModels.ReasonCodes(decision),
+		})
+		return
+	}
+
+	record, err := service.ProposeDecision(c, c.Param("id"), reviewerID, decision, requestBody.ReasonCode, requestBody.Comment)
+	if err != nil {
+		respondDecisionError(c, err)
+		return
+	}
+
+	// A decision waiting on a second reviewer has not changed the applicant yet
+	if record.Status == localModels.DecisionPendingConfirmation {
+		c.JSON(http.StatusAccepted, record)
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// ConfirmDecision is the handler function for a second reviewer confirming a pending decision
+func ConfirmDecision(c *gin.Context, service interfaces.DecisionService) {
+	resolveDecision(c, service.ConfirmDecision)
+}
+
+// DeclineDecision is the handler function for a second reviewer declining a pending decision
+func DeclineDecision(c *gin.Context, service interfaces.DecisionService) {
+	resolveDecision(c, service.DeclineDecision)
+}
+
+// resolveDecision handles confirm and decline, which share their request shape
+func resolveDecision(c *gin.Context, resolve func(c *gin.Context, decisionID, reviewerID, comment string) (localModels.Decision, error)) {
+	reviewerID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestBody struct {
+		Comment string `json:"comment"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+			return
+		}
+	}
+
+	record, err := resolve(c, c.Param("decisionId"), reviewerID, requestBody.Comment)
+	if err != nil {
+		respondDecisionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// GetApplicantDecisions is the handler function for listing an applicant's decision history
+func GetApplicantDecisions(c *gin.Context, service interfaces.DecisionService) {
+	decisions, err := service.GetApplicantDecisions(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve decisions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"decisions": decisions, "count": len(decisions)})
+}
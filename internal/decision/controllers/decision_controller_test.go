@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/decision/services"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupDecisionRouter registers the decision routes behind a fake admin login
+func setupDecisionRouter(mockService *localMocks.MockDecisionService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("admin_id", "reviewer1")
+		c.Set("admin_role", "reviewer")
+	})
+	router.POST("/review-queue/:id/reject", func(c *gin.Context) {
+		ProposeDecision(c, mockService, localModels.DecisionReject)
+	})
+	router.POST("/decisions/:decisionId/confirm", func(c *gin.Context) {
+		ConfirmDecision(c, mockService)
+	})
+	return router
+}
+
+func TestRejectApplicantRequiresReasonCode(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        string
+		expectedStatusCode int
+	}{
+		{"Valid reason", `{"reason_code": "document_expired", "comment": "Passport expired in 2020"}`, http.StatusOK},
+		{"Missing reason", `{"comment": "no reason"}`, http.StatusBadRequest},
+		{"Approve-only reason", `{"reason_code": "checks_passed"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockDecisionService)
+			router := setupDecisionRouter(mockService)
+			mockService.On("ProposeDecision", mock.Anything, "app1", "reviewer1", localModels.DecisionReject, mock.Anything, mock.Anything).
+				Return(localModels.Decision{ApplicantID: "app1", Status: localModels.DecisionApplied}, nil)
+
+			req, _ := http.NewRequest(http.MethodPost, "/review-queue/app1/reject", strings.NewReader(tt.requestBody))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if tt.expectedStatusCode == http.StatusBadRequest {
+				assert.NotEmpty(t, response["valid_reason_codes"])
+			}
+		})
+	}
+}
+
+func TestProposeDecisionAwaitingConfirmation(t *testing.T) {
+	mockService := new(localMocks.MockDecisionService)
+	router := setupDecisionRouter(mockService)
+	mockService.On("ProposeDecision", mock.Anything, "app1", "reviewer1", localModels.DecisionReject, "fraud_suspected", "").
+		Return(localModels.Decision{
+			DecisionID:          "dec1",
+			ApplicantID:         "app1",
+			Status:              localModels.DecisionPendingConfirmation,
+			RequiresDualControl: true,
+		}, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "/review-queue/app1/reject", strings.NewReader(`{"reason_code": "fraud_suspected"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestConfirmDecision(t *testing.T) {
+	tests := []struct {
+		name               string
+		mockError          error
+		expectedStatusCode int
+	}{
+		{"Success", nil, http.StatusOK},
+		{"Proposer confirming own decision", services.ErrSameReviewer, http.StatusForbidden},
+		{"Already resolved", services.ErrDecisionNotPending, http.StatusConflict},
+		{"Unknown decision", services.ErrDecisionNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockDecisionService)
+			router := setupDecisionRouter(mockService)
+			mockService.On("ConfirmDecision", mock.Anything, "dec1", "reviewer1", "").
+				Return(localModels.Decision{DecisionID: "dec1", Status: localModels.DecisionApplied}, tt.mockError)
+
+			req, _ := http.NewRequest(http.MethodPost, "/decisions/dec1/confirm", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
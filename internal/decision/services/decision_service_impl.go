@@ -0,0 +1,383 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongotx"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+var (
+	// ErrNotInQueue is returned when the applicant does not exist or is not awaiting review
+	ErrNotInQueue = apperr.NotFound("not_in_review_queue", "applicant not found in review queue")
+	// ErrNotAssigned is returned when the proposing reviewer is not the applicant's assignee
+	ErrNotAssigned = apperr.Forbidden("not_assigned", "applicant is not assigned to this reviewer")
+	// ErrDecisionPending is returned when the applicant already has a decision awaiting confirmation
+	ErrDecisionPending = apperr.Conflict("decision_pending", "applicant already has a decision awaiting confirmation")
+	// ErrDecisionNotFound is returned when the decision does not exist
+	ErrDecisionNotFound = apperr.NotFound("decision_not_found", "decision not found")
+	// ErrDecisionNotPending is returned when confirming or declining a decision that is no longer pending
+	ErrDecisionNotPending = apperr.Conflict("decision_not_pending", "decision is not awaiting confirmation")
+	// ErrSameReviewer is returned when the proposing reviewer tries to confirm or decline their own decision
+	ErrSameReviewer = apperr.Forbidden("same_reviewer", "decision must be confirmed by a different reviewer")
+)
+
+// DecisionServiceImpl records manual verification decisions and enforces dual control
+type DecisionServiceImpl struct {
+	CollectionName          string
+	ApplicantCollectionName string
+	Settings                config.DecisionSettings
+	Usage                   localInterfaces.UsageRecorder // Meters completed verifications for billing; nil records nothing
+}
+
+var (
+	instance DecisionServiceImpl
+	once     sync.Once
+)
+
+func GetDecisionServiceImpl() DecisionServiceImpl {
+	once.Do(func() {
+		instance = DecisionServiceImpl{
+			CollectionName:          localConstants.CollectionDecisions,
+			ApplicantCollectionName: constants.CollectionApplicants,
+		}
+	})
+	return instance
+}
+
+// decisionApplicant is the part of the applicant record a decision needs
+type decisionApplicant struct {
+	ApplicantID       string                   `bson:"applicant_id"`
+	ClientID          string                   `bson:"client_id"`
+	VerificationLevel string                   `bson:"verification_level"`
+	RiskSignals       []localModels.RiskSignal `bson:"risk_signals"`
+}
+
+// queueFilter matches the scope's applicants currently awaiting review. Reviewers decide
+// for every client, so applicants are looked up with tenant.AllClients and the decision
+// is then kept to the applicant's client.
+func queueFilter(scope tenant.Filter, applicantID string) tenant.Filter {
+	return scope.
+		With("applicant_id", applicantID).
+		With("deleted", false).
+		With("status", bson.M{"$in": localModels.ReviewQueueStatuses})
+}
+
+// dualControlReason explains why a decision needs a second reviewer, or returns
+// an empty string if the proposing reviewer's decision can be applied directly
+func dualControlReason(settings config.DecisionSettings, level string, signals []localModels.RiskSignal) string {
+	for _, l := range settings.DualControlLevels {
+		if l == level {
+			return "verification_level:" + level
+		}
+	}
+	if settings.DualControlOnHighRisk {
+		for _, signal := range signals {
+			if signal.Severity == localModels.RiskHigh {
+				return "high_risk_signal:" + signal.Code
+			}
+		}
+	}
+	return ""
+}
+
+// ProposeDecision records a decision by the reviewer the applicant is assigned to. Decisions
+// that do not need dual control are applied to the applicant straight away.
+func (s *DecisionServiceImpl) ProposeDecision(c *gin.Context, applicantID, reviewerID string, decision localModels.ReviewDecision, reasonCode, comment string) (localModels.Decision, error) {
+	logger := zaplogger.GetLogger()
+	if err := localModels.ValidateReasonCode(decision, reasonCode); err != nil {
+		return localModels.Decision{}, err
+	}
+
+	applicants := tenant.Guard(common.GetCollection(s.ApplicantCollectionName))
+	filter := queueFilter(tenant.AllClients(), applicantID).With("review.assigned_to", reviewerID)
+	var applicant decisionApplicant
+	err := applicants.FindOne(c.Request.Context(), filter).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return localModels.Decision{}, s.explainMiss(c, applicantID)
+	}
+	if err != nil {
+		logger.Error("Error fetching applicant for decision", zap.Error(err), zap.String("applicantID", applicantID))
+		return localModels.Decision{}, err
+	}
+
+	owner := tenant.Of(applicant.ClientID)
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	err = collection.FindOne(c.Request.Context(), owner.
+		With("applicant_id", applicantID).
+		With("status", localModels.DecisionPendingConfirmation),
+	).Err()
+	if err == nil {
+		return localModels.Decision{}, ErrDecisionPending
+	}
+	if err != mongo.ErrNoDocuments {
+		logger.Error("Error checking for pending decisions", zap.Error(err), zap.String("applicantID", applicantID))
+		return localModels.Decision{}, err
+	}
+
+	now := timestamp.Now()
+	record := localModels.Decision{
+		DecisionID:        ids.New(),
+		ApplicantID:       applicantID,
+		ClientID:          applicant.ClientID,
+		VerificationLevel: applicant.VerificationLevel,
+		Decision:          decision,
+		ReasonCode:        reasonCode,
+		Comment:           comment,
+		Status:            localModels.DecisionPendingConfirmation,
+		ProposedBy:        reviewerID,
+		ProposedAt:        now,
+		AuditTrail: []localModels.DecisionAuditEntry{
+			{Action: localModels.DecisionActionProposed, Actor: reviewerID, At: now, Comment: comment},
+		},
+	}
+	record.DualControlReason = dualControlReason(s.Settings, applicant.VerificationLevel, applicant.RiskSignals)
+	record.RequiresDualControl = record.DualControlReason != ""
+
+	if !record.RequiresDualControl {
+		record.Status = localModels.DecisionApplied
+		record.AuditTrail = append(record.AuditTrail, localModels.DecisionAuditEntry{
+			Action: localModels.DecisionActionApplied, Actor: reviewerID, At: now,
+		})
+	}
+
+	// The decision is saved before it is applied, and the two are written together where
+	// transactions are available, so an applicant is never decided without its record
+	key := owner.With("decision_id", record.DecisionID)
+	err = mongotx.Run(c.Request.Context(), "propose_decision", func(ctx context.Context) error {
+		err := mongoretry.Write(ctx, "save_decision", func(ctx context.Context) error {
+			return collection.InsertOnce(ctx, key, record)
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			// Another proposal for the applicant was left pending meanwhile
+			return ErrDecisionPending
+		}
+		if err != nil {
+			logger.Error("Error saving decision", zap.Error(err), zap.String("applicantID", applicantID))
+			return err
+		}
+		if record.RequiresDualControl {
+			return nil
+		}
+		err = s.applyDecision(ctx, record, reviewerID, now)
+		if err != nil && !mongotx.InTransaction(ctx) {
+			// Without a transaction to roll back, remove the decision that was not applied
+			if _, deleteErr := collection.DeleteOne(ctx, key); deleteErr != nil {
+				logger.Error("Error removing decision after failed apply", zap.Error(deleteErr), zap.String("decisionID", record.DecisionID))
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return localModels.Decision{}, err
+	}
+
+	logger.Info("Review decision proposed",
+		zap.String("decisionID", record.DecisionID),
+		zap.String("applicantID", applicantID),
+		zap.String("reviewerID", reviewerID),
+		zap.String("decision", string(decision)),
+		zap.String("reasonCode", reasonCode),
+		zap.Bool("requiresDualControl", record.RequiresDualControl),
+	)
+	return record, nil
+}
+
+// ConfirmDecision applies a pending decision on behalf of a second reviewer
+func (s *DecisionServiceImpl) ConfirmDecision(c *gin.Context, decisionID, reviewerID, comment string) (localModels.Decision, error) {
+	logger := zaplogger.GetLogger()
+	// MongoDB stores milliseconds; truncate so the audit entries can be matched if the apply fails
+	now := timestamp.Now()
+
+	// Move the decision out of pending first so that two confirmations cannot both apply it
+	record, err := s.transition(c, decisionID, reviewerID, bson.M{
+		"$set": bson.M{
+			"status":       localModels.DecisionApplied,
+			"confirmed_by": reviewerID,
+			"confirmed_at": now,
+		},
+		"$push": bson.M{"audit_trail": bson.M{"$each": []localModels.DecisionAuditEntry{
+			{Action: localModels.DecisionActionConfirmed, Actor: reviewerID, At: now, Comment: comment},
+			{Action: localModels.DecisionActionApplied, Actor: reviewerID, At: now},
+		}}},
+	})
+	if err != nil {
+		return record, err
+	}
+
+	if err := s.applyDecision(c.Request.Context(), record, reviewerID, now); err != nil {
+		// Put the decision back so it can be confirmed again or declined
+		collection := tenant.Guard(common.GetCollection(s.CollectionName))
+		_, revertErr := collection.UpdateOne(c.Request.Context(), tenant.Of(record.ClientID).With("decision_id", decisionID), bson.M{
+			"$set":   bson.M{"status": localModels.DecisionPendingConfirmation},
+			"$unset": bson.M{"confirmed_by": "", "confirmed_at": ""},
+			"$pull":  bson.M{"audit_trail": bson.M{"actor": reviewerID, "at": now}},
+		})
+		if revertErr != nil {
+			logger.Error("Error reverting decision after failed apply", zap.Error(revertErr), zap.String("decisionID", decisionID))
+		}
+		return localModels.Decision{}, err
+	}
+
+	logger.Info("Review decision confirmed",
+		zap.String("decisionID", decisionID),
+		zap.String("applicantID", record.ApplicantID),
+		zap.String("proposedBy", record.ProposedBy),
+		zap.String("confirmedBy", reviewerID),
+	)
+	return record, nil
+}
+
+// DeclineDecision discards a pending decision, leaving the applicant in the review queue
+func (s *DecisionServiceImpl) DeclineDecision(c *gin.Context, decisionID, reviewerID, comment string) (localModels.Decision, error) {
+	record, err := s.transition(c, decisionID, reviewerID, bson.M{
+		"$set": bson.M{"status": localModels.DecisionDeclined},
+		"$push": bson.M{"audit_trail": localModels.DecisionAuditEntry{
+			Action: localModels.DecisionActionDeclined, Actor: reviewerID, At: timestamp.Now(), Comment: comment,
+		}},
+	})
+	if err != nil {
+		return record, err
+	}
+
+	zaplogger.GetLogger().Info("Review decision declined",
+		zap.String("decisionID", decisionID),
+		zap.String("applicantID", record.ApplicantID),
+		zap.String("declinedBy", reviewerID),
+	)
+	return record, nil
+}
+
+// GetApplicantDecisions lists the decisions recorded for an applicant of any client, newest first
+func (s *DecisionServiceImpl) GetApplicantDecisions(c *gin.Context, applicantID string) ([]localModels.Decision, error) {
+	logger := zaplogger.GetLogger()
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+
+	opts := options.Find().SetSort(bson.D{{Key: "proposed_at", Value: -1}})
+	cursor, err := collection.Find(c.Request.Context(), tenant.AllClients().With("applicant_id", applicantID), opts)
+	if err != nil {
+		logger.Error("Error fetching decisions from MongoDB", zap.Error(err), zap.String("applicantID", applicantID))
+		return nil, err
+	}
+	defer cursor.Close(c.Request.Context())
+
+	decisions := []localModels.Decision{}
+	if err := cursor.All(c.Request.Context(), &decisions); err != nil {
+		logger.Error("Error decoding decisions", zap.Error(err))
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// transition updates a pending decision of any client on behalf of a reviewer other than
+// the proposer
+func (s *DecisionServiceImpl) transition(c *gin.Context, decisionID, reviewerID string, update bson.M) (localModels.Decision, error) {
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	filter := tenant.AllClients().
+		With("decision_id", decisionID).
+		With("status", localModels.DecisionPendingConfirmation).
+		With("proposed_by", bson.M{"$ne": reviewerID})
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var record localModels.Decision
+	err := collection.FindOneAndUpdate(c.Request.Context(), filter, update, opts).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return record, s.explainDecisionMiss(c, decisionID, reviewerID)
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error("Error updating decision", zap.Error(err), zap.String("decisionID", decisionID))
+		return record, err
+	}
+	return record, nil
+}
+
+// applyDecision moves the applicant to the decided status and records the decision on its review
+func (s *DecisionServiceImpl) applyDecision(ctx context.Context, record localModels.Decision, decidedBy string, now time.Time) error {
+	applicants := tenant.Guard(common.GetCollection(s.ApplicantCollectionName))
+	update := bson.M{"$set": bson.M{
+		"status":             record.Decision.Status(),
+		"review.decided_by":  decidedBy,
+		"review.decided_at":  now,
+		"review.reason_code": record.ReasonCode,
+		"review.comment":     record.Comment,
+		"review.decision_id": record.DecisionID,
+		"updated_at":         now,
+	}}
+	if err := mongoschema.ValidateUpdate(s.ApplicantCollectionName, update); err != nil {
+		return err
+	}
+	result, err := applicants.UpdateOne(ctx, queueFilter(tenant.Of(record.ClientID), record.ApplicantID), update)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error applying decision to applicant", zap.Error(err), zap.String("applicantID", record.ApplicantID))
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotInQueue
+	}
+
+	// Billing problems are logged rather than undoing the decision, unless it is applied in
+	// a transaction, which the failed write has already aborted
+	if s.Usage != nil {
+		err := s.Usage.Record(ctx, record.ClientID, localModels.UsageVerificationCompleted, record.DecisionID)
+		if err != nil && mongotx.InTransaction(ctx) {
+			return err
+		}
+		if err != nil {
+			zaplogger.GetLogger().Error("Error recording usage", zap.Error(err), zap.String("applicantID", record.ApplicantID))
+		}
+	}
+	return nil
+}
+
+// explainMiss works out why the applicant lookup for a proposal matched nothing
+func (s *DecisionServiceImpl) explainMiss(c *gin.Context, applicantID string) error {
+	applicants := tenant.Guard(common.GetCollection(s.ApplicantCollectionName))
+	err := applicants.FindOne(c.Request.Context(), queueFilter(tenant.AllClients(), applicantID)).Err()
+	if err == mongo.ErrNoDocuments {
+		return ErrNotInQueue
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up applicant: %v", err)
+	}
+	return ErrNotAssigned
+}
+
+// explainDecisionMiss works out why a decision transition matched nothing
+func (s *DecisionServiceImpl) explainDecisionMiss(c *gin.Context, decisionID, reviewerID string) error {
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	var record localModels.Decision
+	err := collection.FindOne(c.Request.Context(), tenant.AllClients().With("decision_id", decisionID)).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return ErrDecisionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up decision: %v", err)
+	}
+	if record.Status != localModels.DecisionPendingConfirmation {
+		return ErrDecisionNotPending
+	}
+	if record.ProposedBy == reviewerID {
+		return ErrSameReviewer
+	}
+	return ErrDecisionNotPending
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDualControlReason(t *testing.T) {
+	settings := config.DecisionSettings{
+		DualControlLevels:     []string{"enhanced-kyc"},
+		DualControlOnHighRisk: true,
+	}
+	highRisk := []localModels.RiskSignal{{Code: "country_mismatch", Severity: localModels.RiskHigh}}
+	lowRisk := []localModels.RiskSignal{{Code: "poor_quality", Severity: localModels.RiskLow}}
+
+	tests := []struct {
+		name     string
+		settings config.DecisionSettings
+		level    string
+		signals  []localModels.RiskSignal
+		expected string
+	}{
+		{"Configured level", settings, "enhanced-kyc", nil, "verification_level:enhanced-kyc"},
+		{"High risk signal", settings, "basic-kyc", highRisk, "high_risk_signal:country_mismatch"},
+		{"Low risk signal", settings, "basic-kyc", lowRisk, ""},
+		{"High risk not enforced", config.DecisionSettings{}, "basic-kyc", highRisk, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, dualControlReason(tt.settings, tt.level, tt.signals))
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Package diagnostics serves runtime profiles, expvar counters, goroutine stacks
+// and the log level on a port of their own, bound to a loopback address so they
+// are only reachable from the host, e.g. over an SSH tunnel or kubectl
+// port-forward. The API's admin routes serve profiles too, but need the admin key
+// and go through the whole middleware chain.
+//
+// The log level set here applies to loggers from Logger and Leveled. It can be
+// lowered below the level the application logger was built with, so debug logs
+// of the upload path can be switched on in production while a leak is chased and
+// off again afterwards. Debug entries are sampled per message, see Sampling.
+package diagnostics
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	loggerOnce sync.Once
+	logger     *zap.Logger
+
+	revertMu sync.Mutex
+	revert   *time.Timer
+	revertAt time.Time
+)
+
+// SetLevel sets the runtime log level by name, e.g. "debug" or "warn"
+func SetLevel(name string) error {
+	return SetLevelFor(name, 0)
+}
+
+// SetLevelFor sets the runtime log level by name and, when d is positive, puts the
+// previous level back after d, so debug logs left on do not run up the log bill. Any
+// later change cancels the pending revert.
+func SetLevelFor(name string, d time.Duration) error {
+	var parsed zapcore.Level
+	if err := parsed.UnmarshalText([]byte(name)); err != nil {
+		return err
+	}
+	revertMu.Lock()
+	defer revertMu.Unlock()
+	cancelRevert()
+	previous := level.Level()
+	level.SetLevel(parsed)
+	if d > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(d, func() {
+			revertMu.Lock()
+			defer revertMu.Unlock()
+			if revert != timer {
+				return
+			}
+			revert, revertAt = nil, time.Time{}
+			level.SetLevel(previous)
+			zaplogger.GetLogger().Warn("Log level reverted", zap.Stringer("from", parsed), zap.Stringer("to", previous))
+		})
+		revert, revertAt = timer, time.Now().Add(d)
+	}
+	return nil
+}
+
+// RevertsAt returns when the runtime log level goes back to its previous value, or the
+// zero time when it stays as it is
+func RevertsAt() time.Time {
+	revertMu.Lock()
+	defer revertMu.Unlock()
+	return revertAt
+}
+
+// cancelRevert stops a pending revert. revertMu must be held.
+func cancelRevert() {
+	if revert != nil {
+		revert.Stop()
+		revert, revertAt = nil, time.Time{}
+	}
+}
+
+// Level returns the runtime log level
+func Level() zapcore.Level {
+	return level.Level()
+}
+
+// Logger returns the application logger, filtered by the runtime log level
+func Logger() *zap.Logger {
+	loggerOnce.Do(func() {
+		logger = Leveled(zaplogger.GetLogger())
+	})
+	return logger
+}
+
+// Leveled returns a logger that writes the entries enabled by the runtime log level,
+// in place of the level base was built with
+func Leveled(base *zap.Logger) *zap.Logger {
+	return base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return leveledCore{Core: core}
+	}))
+}
+
+// leveledCore checks entries against the runtime level and writes them to the wrapped
+// core without asking it, as its own level would hide debug entries
+type leveledCore struct {
+	zapcore.Core
+}
+
+func (c leveledCore) Enabled(l zapcore.Level) bool {
+	return level.Enabled(l)
+}
+
+func (c leveledCore) With(fields []zapcore.Field) zapcore.Core {
+	return leveledCore{Core: c.Core.With(fields)}
+}
+
+func (c leveledCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level == zapcore.DebugLevel && level.Enabled(entry.Level) && !sampler.allow(entry.Message, entry.Time) {
+		return checked
+	}
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Handler serves the diagnostics endpoints:
+//
+//	/debug/pprof/     runtime profiles, as served by net/http/pprof
+//	/debug/vars       expvar counters
+//	/debug/goroutines stacks of every goroutine
+//	/debug/loglevel   the runtime log level; PUT {"level":"debug"} to change it
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", dumpGoroutines)
+	mux.HandleFunc("/debug/loglevel", serveLevel)
+	return mux
+}
+
+// dumpGoroutines writes the stack of every goroutine, in the format of an unrecovered panic
+func dumpGoroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := runtimepprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// serveLevel reports the runtime log level, and logs who changed it
+func serveLevel(w http.ResponseWriter, r *http.Request) {
+	revertMu.Lock()
+	defer revertMu.Unlock()
+	previous := level.Level()
+	level.ServeHTTP(w, r)
+	if current := level.Level(); current != previous {
+		cancelRevert()
+		zaplogger.GetLogger().Warn("Log level changed",
+			zap.Stringer("from", previous),
+			zap.Stringer("to", current),
+			zap.String("remoteAddr", r.RemoteAddr),
+		)
+	}
+}
+
+// CheckAddr returns an error unless addr is a host and port on a loopback interface
+func CheckAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid diagnostics address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("diagnostics address %q is not a loopback address", addr)
+	}
+	return nil
+}
+
+// Start sets the log level and sampling at boot and, when addr is set, serves the
+// diagnostics endpoints on it in the background. addr must be a loopback address.
+func Start(addr, logLevel string, sampling Sampling) error {
+	if logLevel != "" {
+		if err := SetLevel(logLevel); err != nil {
+			return fmt.Errorf("invalid log level %q: %w", logLevel, err)
+		}
+	}
+	SetSampling(sampling)
+	if addr == "" {
+		return nil
+	}
+	if err := CheckAddr(addr); err != nil {
+		return err
+	}
+	// Listen here so a port already in use fails the boot rather than being logged
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen for diagnostics: %w", err)
+	}
+	server := &http.Server{
+		Handler:           Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := server.Serve(listener)
+		zaplogger.GetLogger().Error("Diagnostics server stopped", zap.Error(err))
+	}()
+	return nil
+}
@@ -0,0 +1,145 @@
+package diagnostics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{addr: "localhost:6060"},
+		{addr: "127.0.0.1:6060"},
+		{addr: "[::1]:6060"},
+		{addr: ":6060", wantErr: true},
+		{addr: "0.0.0.0:6060", wantErr: true},
+		{addr: "10.0.0.5:6060", wantErr: true},
+		{addr: "example.com:6060", wantErr: true},
+		{addr: "localhost", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := CheckAddr(tt.addr)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	defer SetLevel("info")
+
+	assert.Error(t, Start("", "loud", Sampling{}))
+	assert.Error(t, Start("0.0.0.0:0", "", Sampling{}))
+	require.NoError(t, Start("", "warn", Sampling{}))
+	assert.Equal(t, zapcore.WarnLevel, Level())
+}
+
+func TestLeveled(t *testing.T) {
+	defer SetLevel("info")
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := Leveled(zap.New(core)).With(zap.String("worker", "test"))
+
+	logger.Debug("hidden")
+	logger.Info("shown")
+	require.NoError(t, SetLevel("debug"))
+	logger.Debug("shown below the base level")
+	require.NoError(t, SetLevel("error"))
+	logger.Warn("hidden")
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+		assert.Equal(t, "test", entry.ContextMap()["worker"])
+	}
+	assert.Equal(t, []string{"shown", "shown below the base level"}, messages)
+}
+
+func TestHandler(t *testing.T) {
+	defer SetLevel("info")
+	handler := Handler()
+
+	tests := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{name: "Profile index", method: http.MethodGet, path: "/debug/pprof/", expectedStatusCode: http.StatusOK, expectedBody: "goroutine"},
+		{name: "Named profile", method: http.MethodGet, path: "/debug/pprof/heap?debug=1", expectedStatusCode: http.StatusOK, expectedBody: "heap profile"},
+		{name: "Expvar", method: http.MethodGet, path: "/debug/vars", expectedStatusCode: http.StatusOK, expectedBody: `"memstats"`},
+		{name: "Goroutine stacks", method: http.MethodGet, path: "/debug/goroutines", expectedStatusCode: http.StatusOK, expectedBody: "TestHandler"},
+		{name: "Log level", method: http.MethodGet, path: "/debug/loglevel", expectedStatusCode: http.StatusOK, expectedBody: `{"level":"info"}`},
+		{name: "Change log level", method: http.MethodPut, path: "/debug/loglevel", body: `{"level":"debug"}`, expectedStatusCode: http.StatusOK, expectedBody: `{"level":"debug"}`},
+		{name: "Unknown log level", method: http.MethodPut, path: "/debug/loglevel", body: `{"level":"loud"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Unknown path", method: http.MethodGet, path: "/debug/unknown", expectedStatusCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+	assert.Equal(t, zapcore.DebugLevel, Level())
+}
+
+func TestSetLevelFor(t *testing.T) {
+	defer SetLevel("info")
+	require.NoError(t, SetLevel("info"))
+
+	require.NoError(t, SetLevelFor("debug", 20*time.Millisecond))
+	assert.Equal(t, zapcore.DebugLevel, Level())
+	assert.False(t, RevertsAt().IsZero())
+	assert.Eventually(t, func() bool { return Level() == zapcore.InfoLevel }, time.Second, 5*time.Millisecond)
+	assert.True(t, RevertsAt().IsZero())
+
+	// A later change cancels the revert
+	require.NoError(t, SetLevelFor("debug", 20*time.Millisecond))
+	require.NoError(t, SetLevel("warn"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, zapcore.WarnLevel, Level())
+
+	assert.Error(t, SetLevelFor("loud", time.Minute))
+}
+
+func TestSampling(t *testing.T) {
+	defer SetLevel("info")
+	defer SetSampling(Sampling{})
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := Leveled(zap.New(core))
+	require.NoError(t, SetLevel("debug"))
+	SetSampling(Sampling{Tick: time.Hour, First: 2, Thereafter: 5})
+
+	for i := 0; i < 12; i++ {
+		logger.Debug("raw body")
+		logger.Info("request")
+	}
+	logger.Debug("other")
+
+	counts := map[string]int{}
+	for _, entry := range logs.All() {
+		counts[entry.Message]++
+	}
+	// The first 2 raw bodies, then the 7th and 12th
+	assert.Equal(t, map[string]int{"raw body": 4, "request": 12, "other": 1}, counts)
+}
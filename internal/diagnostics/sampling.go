@@ -0,0 +1,60 @@
+package diagnostics
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Sampling bounds how many debug entries with the same message are written: the first
+// First in each Tick, then every Thereafter-th, so logs such as raw request bodies stay
+// affordable once debug logs are on. A zero Tick writes every entry.
+type Sampling struct {
+	Tick       time.Duration
+	First      int
+	Thereafter int
+}
+
+// sampledOut counts the debug entries dropped by sampling, by message
+var sampledOut = expvar.NewMap("log_sampled_out")
+
+var sampler = &debugSampler{}
+
+// SetSampling replaces how debug entries are sampled
+func SetSampling(s Sampling) {
+	sampler.mu.Lock()
+	defer sampler.mu.Unlock()
+	sampler.config = s
+	sampler.counts = nil
+}
+
+// debugSampler counts entries per message within the current tick
+type debugSampler struct {
+	mu     sync.Mutex
+	config Sampling
+	start  time.Time
+	counts map[string]int
+}
+
+// allow reports whether an entry with the message is written
+func (s *debugSampler) allow(message string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.Tick <= 0 {
+		return true
+	}
+	if s.counts == nil || now.Sub(s.start) >= s.config.Tick {
+		s.start = now
+		s.counts = map[string]int{}
+	}
+	s.counts[message]++
+	n := s.counts[message]
+	if n <= s.config.First {
+		return true
+	}
+	if s.config.Thereafter > 0 && (n-s.config.First)%s.config.Thereafter == 0 {
+		return true
+	}
+	sampledOut.Add(message, 1)
+	return false
+}
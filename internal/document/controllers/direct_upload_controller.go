@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/dto"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+// PresignUpload is the handler function for asking to upload a large file straight to S3.
+// The file is checked and its document registered once the upload is completed.
+func PresignUpload(c *gin.Context, service interfaces.DocumentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var request localModels.DirectUploadRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// v2 names the applicant in the path, whose ID is checked with the route's; v1 in the body
+	switch applicantID := c.Param("id"); {
+	case applicantID != "":
+		request.ApplicantID = applicantID
+	case request.ApplicantID == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "applicant_id is required"})
+		return
+	case ids.Respond(c, ids.Applicant, request.ApplicantID):
+		return
+	}
+
+	upload, err := service.PresignUpload(c, clientID, request)
+	if mongoretry.RespondUnavailable(c, err) || RespondUploadLimit(c, err) || apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("PresignUpload: Error presigning upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not presign upload"})
+		return
+	}
+	c.JSON(http.StatusOK, upload)
+}
+
+// CompleteUpload is the handler function for registering a file uploaded through a presigned
+// URL. It answers as an upload of the file through the API would have, with 202 until
+// the file is scanned.
+func CompleteUpload(c *gin.Context, service interfaces.DocumentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var completion localModels.DirectUploadCompletion
+	if err := c.ShouldBindJSON(&completion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection := DocumentCollection()
+	result, err := service.CompleteUpload(c, clientID, c.Param("id"), completion, collection)
+	if mongoretry.RespondUnavailable(c, err) || RespondUploadLimit(c, err) || apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The quick scan of a directly uploaded file is always deferred
+	c.JSON(http.StatusAccepted, dto.UploadResponse(apiversion.FromContext(c), result))
+}
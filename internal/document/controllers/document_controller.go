@@ -1,68 +1,413 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"path"
+	"strconv"
 
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	auditControllers "github.com/rachel-lawrie/verus_app_backend/internal/audit/controllers"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/dto"
 	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
 	"github.com/rachel-lawrie/verus_backend_core/common"
 	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
 
 	"github.com/gin-gonic/gin"
 )
 
+// DocumentCollection returns the collection handlers give the document service. Tests that
+// mock the service replace it, so handlers run without a database connection.
+var DocumentCollection = func() common.CollectionInterface {
+	return common.GetCollection(localConstants.CollectionDocuments)
+}
+
+// documentPath returns the applicant and document named in the path. v2 routes nest
+// documents under their applicant; v1 routes name only the document, so ok is false and
+// the applicant comes from the body or query.
+func documentPath(c *gin.Context) (applicantID, docID string, ok bool) {
+	if apiversion.FromContext(c) < apiversion.V2 {
+		return "", c.Param("id"), false
+	}
+	return c.Param("id"), c.Param("docId"), true
+}
+
+// applicantFromPath gives the service the applicant named in a v2 upload's path as the
+// applicant_id form field v1 clients send. The form is read once here and reused by the
+// service; a form that cannot be parsed is left for the service to report.
+func applicantFromPath(c *gin.Context) {
+	if apiversion.FromContext(c) < apiversion.V2 {
+		return
+	}
+	if err := services.ParseUploadForm(c); err == nil {
+		c.Request.Form.Set("applicant_id", c.Param("id"))
+	}
+}
+
+// uploadRetryAfter is the Retry-After, in seconds, of an upload refused while the client has too many in progress
+const uploadRetryAfter = "5"
+
+// RespondUploadLimit answers an upload refused by the upload limits, and reports whether it
+// did. A client with too many uploads in progress is told when to try again.
+func RespondUploadLimit(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrTooManyUploads):
+		c.Header("Retry-After", uploadRetryAfter)
+	case !errors.Is(err, services.ErrApplicantDocumentLimit) && !errors.Is(err, services.ErrApplicantStorageLimit):
+		return false
+	}
+	return apperr.Respond(c, err)
+}
+
 // CreateDocument handles the document upload and responds with metadata
 func CreateDocument(c *gin.Context, service interfaces.DocumentService) {
 
 	// Set content type to application/json
 	c.Header("Content-Type", "application/json")
-	collection := common.GetCollection("applicants")
+	collection := DocumentCollection()
+	applicantFromPath(c)
 
 	// Call the upload service to handle the file upload
-	doc, err := service.UploadDocument(c, collection)
+	result, err := service.UploadDocument(c, collection)
+	if mongoretry.RespondUnavailable(c, err) || RespondUploadLimit(c, err) || apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Deferred checks or a pending storage retry mean the pipeline is still running
+	if result.ProcessingStatus == localModels.ProcessingScanPending || result.ProcessingStatus == localModels.ProcessingStoragePending {
+		c.JSON(http.StatusAccepted, dto.UploadResponse(apiversion.FromContext(c), result))
+		return
+	}
+
+	// Respond with document metadata and check results as JSON
+	c.JSON(http.StatusOK, dto.UploadResponse(apiversion.FromContext(c), result))
+}
+
+// ReplaceDocument is the handler function for uploading a new version of an existing document
+func ReplaceDocument(c *gin.Context, service interfaces.DocumentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
 	if err != nil {
-		// Return a JSON response with an error message
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, docID, _ := documentPath(c)
+	applicantFromPath(c)
+	collection := DocumentCollection()
+	result, err := service.ReplaceDocument(c, clientID, docID, collection)
+	if mongoretry.RespondUnavailable(c, err) || RespondUploadLimit(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Respond with document metadata as JSON
-	c.JSON(http.StatusOK, doc)
+	if result.ProcessingStatus == localModels.ProcessingScanPending || result.ProcessingStatus == localModels.ProcessingStoragePending {
+		c.JSON(http.StatusAccepted, dto.UploadResponse(apiversion.FromContext(c), result))
+		return
+	}
+	c.JSON(http.StatusOK, dto.UploadResponse(apiversion.FromContext(c), result))
+}
+
+// GetDocumentVersions is the handler function for listing the versions a document was replaced from
+func GetDocumentVersions(c *gin.Context, service interfaces.DocumentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	applicantID, docID, ok := documentPath(c)
+	if !ok {
+		applicantID = c.Query("applicant_id")
+	}
+	if applicantID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "applicant_id is required"})
+		return
+	}
+
+	collection := DocumentCollection()
+	history, err := service.GetDocumentVersions(c, clientID, applicantID, docID, collection)
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve document versions"})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// RestoreDocument is the handler function for restoring an archived document's file. It
+// answers 202 while the restore is under way and 200 once the file can be downloaded; the
+// client is sent a document.restored webhook when the restore completes.
+func RestoreDocument(c *gin.Context, service interfaces.DocumentService) {
+	documentRestore(c, service.RestoreDocument, "Could not restore document")
+}
+
+// UndeleteDocument is the handler function for un-deleting a document within its grace period
+func UndeleteDocument(c *gin.Context, service interfaces.DocumentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	applicantID, docID, _ := documentPath(c)
+
+	doc, err := service.UndeleteDocument(c, clientID, applicantID, docID, DocumentCollection())
+	if mongoretry.RespondUnavailable(c, err) || RespondUploadLimit(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+	case err != nil:
+		zaplogger.GetLogger().Error("Error restoring deleted document", zap.Error(err), zap.String("documentID", docID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not restore document"})
+	default:
+		c.JSON(http.StatusOK, dto.DocumentResponse(apiversion.FromContext(c), doc))
+	}
+}
+
+// GetDocumentRestore is the handler function for following the restore of an archived document
+func GetDocumentRestore(c *gin.Context, service interfaces.DocumentService) {
+	documentRestore(c, service.GetDocumentRestore, "Could not retrieve document restore")
+}
+
+// documentRestore runs a cold storage call for the document in the path and answers with its state
+func documentRestore(c *gin.Context, call func(*gin.Context, string, string, string, common.CollectionInterface) (localModels.ColdStorage, error), failure string) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	applicantID, docID, ok := documentPath(c)
+	if !ok {
+		applicantID = c.Query("applicant_id")
+	}
+	if applicantID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "applicant_id is required"})
+		return
+	}
+
+	collection := DocumentCollection()
+	state, err := call(c, clientID, applicantID, docID, collection)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return
+	}
+	if c.Request.Method == http.MethodPost && state.State == localModels.ColdStorageRestoring {
+		c.JSON(http.StatusAccepted, state)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// GetUploadJob is the handler function for following an upload's progress. Progress is
+// shared between replicas, so it can be read from any of them.
+func GetUploadJob(c *gin.Context, service interfaces.DocumentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := service.GetUploadJob(c.Request.Context(), clientID, c.Param("job_id"))
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error("Error retrieving upload job", zap.Error(err), zap.String("jobID", c.Param("job_id")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve upload job"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadDocumentVersion is the handler function for staff downloading any version of a document
+func DownloadDocumentVersion(c *gin.Context, service interfaces.DocumentService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a positive number"})
+		return
+	}
+
+	reason, err := services.DownloadReason(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	purpose, err := services.DownloadPurpose(c, services.PurposeReview)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	applicantID, docID := c.Param("id"), c.Param("docId")
+	auditControllers.NoteDocumentAccess(c, reason, docID)
+	collection := DocumentCollection()
+	download := localModels.DocumentDownload{Viewer: adminID, Purpose: purpose}
+	selected, body, err := service.OpenDocumentVersion(c, applicantID, docID, version, download, collection)
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve document version"})
+		return
+	}
+	defer body.Close()
+
+	// Historical files are evidence, so keep a record of who looked at them
+	zaplogger.GetLogger().Info("Document version downloaded",
+		zap.String("adminID", adminID),
+		zap.String("applicantID", applicantID),
+		zap.String("documentID", docID),
+		zap.Int("version", version),
+		zap.String("reason", reason),
+		zap.String("purpose", purpose),
+	)
+
+	fileName := docID + "_v" + strconv.Itoa(version) + path.Ext(selected.FileURL)
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(fileName))
+	c.DataFromReader(http.StatusOK, selected.FileSize, selected.ContentType(), body, nil)
+}
+
+// ArchiveDocuments is the handler function for downloading every document of an applicant as a ZIP.
+// The archive is streamed as the files arrive from S3, so a failure part way through can only
+// be reported by cutting the response short, which leaves a ZIP that does not open.
+func ArchiveDocuments(c *gin.Context, service interfaces.DocumentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	applicantID := c.Param("id")
+	reason, err := services.DownloadReason(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	purpose, err := services.DownloadPurpose(c, services.PurposeArchive)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection := DocumentCollection()
+	documents, err := service.ListArchiveDocuments(c, clientID, applicantID, collection)
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve documents"})
+		return
+	}
+	// Until the archive is complete, any of its documents may have been handed out
+	documentIDs := make([]string, 0, len(documents))
+	for _, doc := range documents {
+		documentIDs = append(documentIDs, doc.DocumentID)
+	}
+	auditControllers.NoteDocumentAccess(c, reason, documentIDs...)
+
+	fileName := applicantID + "_documents.zip"
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(fileName))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	download := localModels.DocumentDownload{Viewer: clientID, Purpose: purpose}
+	entries, err := service.WriteDocumentArchive(c.Request.Context(), documents, download, c.Writer)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error writing document archive", zap.Error(err), zap.String("applicantID", applicantID))
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve documents"})
+		}
+		c.Abort()
+		return
+	}
+
+	// Archives are pulled for audits, so keep a record of who took the full set
+	var included []string
+	for _, entry := range entries {
+		if entry.File != "" {
+			included = append(included, entry.DocumentID)
+		}
+	}
+	auditControllers.NoteDocumentAccess(c, reason, included...)
+	zaplogger.GetLogger().Info("Document archive downloaded",
+		zap.String("clientID", clientID),
+		zap.String("applicantID", applicantID),
+		zap.Int("documents", len(entries)),
+		zap.Int("included", len(included)),
+		zap.String("reason", reason),
+		zap.String("purpose", purpose),
+	)
 }
 
 // GetDocument is the handler function for retrieving document metadata by ID
 func GetDocument(c *gin.Context, service interfaces.DocumentService) {
 	// Get the document ID from the URL parameter
-	docID := c.Param("id")
+	applicantID, docID, ok := documentPath(c)
 
-	// Get the status from the JSON request body
-	var requestBody struct {
-		ApplicantID string `json:"applicant_id"`
-	}
+	// v1 clients send the applicant ID in the JSON request body
+	if !ok {
+		var requestBody struct {
+			ApplicantID string `json:"applicant_id"`
+		}
 
-	// Bind the request body to the struct
-	if err := c.ShouldBindJSON(&requestBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
-		return
+		// Bind the request body to the struct
+		if err := c.ShouldBindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+			return
+		}
+		applicantID = requestBody.ApplicantID
 	}
 
-	collection := common.GetCollection("applicants")
+	collection := DocumentCollection()
 
 	// Call the service to retrieve the document metadata
-	doc, err := service.GetDocument(c, requestBody.ApplicantID, docID, collection)
-	if err != nil {
-		// Return a JSON response with an error message if document not found
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	doc, err := service.GetDocument(c, applicantID, docID, collection)
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve document"})
 		return
 	}
 
 	// Respond with the document metadata
-	c.JSON(http.StatusOK, doc)
+	c.JSON(http.StatusOK, dto.DocumentResponse(apiversion.FromContext(c), doc))
 }
 
 // UpdateDocument is the handler function for updating the status of a document
 func UpdateDocument(c *gin.Context, service interfaces.DocumentService) {
 	// Get the document ID from the URL parameter
-	docID := c.Param("id")
+	applicantID, docID, ok := documentPath(c)
 
 	// Get the status from the JSON request body
 	var requestBody struct {
@@ -75,6 +420,9 @@ func UpdateDocument(c *gin.Context, service interfaces.DocumentService) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
 		return
 	}
+	if !ok {
+		applicantID = requestBody.ApplicantID
+	}
 
 	// Call the service to update the document status
 	status, err := models.ParseDocumentStatus(requestBody.Status)
@@ -82,15 +430,21 @@ func UpdateDocument(c *gin.Context, service interfaces.DocumentService) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
 		return
 	}
-	doc, err := service.UpdateDocument(c, requestBody.ApplicantID, docID, status)
-	if err != nil {
-		// Return a JSON response with an error message if document not found
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	doc, err := service.UpdateDocument(c, applicantID, docID, status)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		zaplogger.GetLogger().Error("Error updating document", zap.Error(err), zap.String("documentID", docID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update document"})
 		return
 	}
 
 	// Respond with the updated document metadata
-	c.JSON(http.StatusOK, doc)
+	c.JSON(http.StatusOK, dto.DocumentResponse(apiversion.FromContext(c), doc))
 }
 
 // SaveDocument is the handler function for saving a document locally for testing from S3 bucket
@@ -116,10 +470,13 @@ func SaveDocument(c *gin.Context, service interfaces.DocumentService) {
 	}
 
 	// Step 3: Get the MongoDB collection
-	collection := common.GetCollection("applicants")
+	collection := DocumentCollection()
 
 	// Step 4: Call the service to save the file locally
 	filePath, err := service.DownloadDocument(c, docID, requestBody.ApplicantID, collection)
+	if apperr.Respond(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
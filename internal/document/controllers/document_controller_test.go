@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
@@ -16,13 +17,27 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	auditControllers "github.com/rachel-lawrie/verus_app_backend/internal/audit/controllers"
+	"github.com/rachel-lawrie/verus_app_backend/internal/document/services"
 	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/preview"
+	"github.com/rachel-lawrie/verus_app_backend/internal/softdelete"
+	"github.com/rachel-lawrie/verus_backend_core/common"
 	"github.com/rachel-lawrie/verus_backend_core/mocks"
 	"github.com/rachel-lawrie/verus_backend_core/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+func TestMain(m *testing.M) {
+	// Handlers get their collection from the mocked service in these tests
+	DocumentCollection = func() common.CollectionInterface { return new(mocks.MockCollection) }
+	os.Exit(m.Run())
+}
+
 // TestCreateDocument tests the CreateDocument function
 func TestCreateDocument(t *testing.T) {
 	now := time.Now()
@@ -136,6 +151,54 @@ func TestCreateDocument(t *testing.T) {
 	}
 }
 
+// refusedUpload fails every upload with the same error
+type refusedUpload struct {
+	*localMocks.MockDocumentService
+	err error
+}
+
+func (s refusedUpload) UploadDocument(c *gin.Context, collection common.CollectionInterface) (localModels.UploadResult, error) {
+	return localModels.UploadResult{}, s.err
+}
+
+// TestCreateDocumentForUnknownOrForeignApplicant tests that uploads are refused before
+// storage when the applicant is missing or belongs to another client
+func TestCreateDocumentForUnknownOrForeignApplicant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"Unknown applicant", apperr.ErrApplicantNotFound, http.StatusNotFound, "applicant_not_found"},
+		{"Too many uploads", fmt.Errorf("%w: the client may have 2 at once", services.ErrTooManyUploads), http.StatusTooManyRequests, "too_many_uploads"},
+		{"Applicant document limit", fmt.Errorf("%w: applicants at level basic may have 3 documents", services.ErrApplicantDocumentLimit), http.StatusConflict, "applicant_document_limit"},
+		{"Applicant storage limit", services.ErrApplicantStorageLimit, http.StatusConflict, "applicant_storage_limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/documents", nil)
+
+			CreateDocument(c, refusedUpload{new(localMocks.MockDocumentService), tt.err})
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if tt.wantStatus == http.StatusNotFound {
+				assert.Equal(t, map[string]interface{}{"error": apperr.ErrApplicantNotFound.Error(), "code": "applicant_not_found"}, response)
+			} else {
+				assert.Equal(t, tt.err.Error(), response["error"])
+			}
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, response["code"])
+			}
+		})
+	}
+}
+
 // TestGetDocument tests the GetDocument function
 func TestGetDocument(t *testing.T) {
 	// Set up Gin in test mode
@@ -181,10 +244,36 @@ func TestGetDocument(t *testing.T) {
 			applicantID:        "applicant123",
 			requestBody:        `{"applicant_id": "applicant123"}`,
 			mockReturn:         models.Document{},
-			mockError:          errors.New("document not found"),
+			mockError:          services.ErrDocumentNotFound,
 			expectedStatusCode: http.StatusNotFound,
 			expectedResponse: map[string]interface{}{
 				"error": "document not found",
+				"code":  "document_not_found",
+			},
+		},
+		{
+			name:               "ApplicantNotFound",
+			docID:              "123",
+			applicantID:        "missing",
+			requestBody:        `{"applicant_id": "missing"}`,
+			mockReturn:         models.Document{},
+			mockError:          apperr.ErrApplicantNotFound,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse: map[string]interface{}{
+				"error": "applicant not found",
+				"code":  "applicant_not_found",
+			},
+		},
+		{
+			name:               "LookupFails",
+			docID:              "456",
+			applicantID:        "applicant123",
+			requestBody:        `{"applicant_id": "applicant123"}`,
+			mockReturn:         models.Document{},
+			mockError:          errors.New("connection reset"),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedResponse: map[string]interface{}{
+				"error": "Could not retrieve document",
 			},
 		},
 	}
@@ -218,6 +307,28 @@ func TestGetDocument(t *testing.T) {
 	}
 }
 
+// TestGetDocumentV2 tests that v2 routes take the applicant from the path rather than the body
+func TestGetDocumentV2(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(localMocks.MockDocumentService)
+	mockService.On("GetDocument", mock.Anything, "applicant123", "123", mock.Anything).
+		Return(models.Document{DocumentID: "123", ApplicantID: "applicant123"}, nil)
+
+	router := gin.Default()
+	v2 := router.Group("/v2", apiversion.Middleware(apiversion.V2))
+	v2.GET("/applicants/:id/documents/:docId", func(c *gin.Context) {
+		GetDocument(c, mockService)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v2/applicants/applicant123/documents/123", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"document_id":"123"`)
+	mockService.AssertExpectations(t)
+}
+
 // removeFields removes specified fields from a map.
 func removeFields(m map[string]interface{}, fieldsToIgnore []string) {
 	for _, field := range fieldsToIgnore {
@@ -282,10 +393,36 @@ func TestUpdateDocument(t *testing.T) {
 			applicantID:        "applicant123",
 			requestBody:        `{"applicant_id": "applicant123", "status": "` + models.DocumentVerified.String() + `"}`,
 			mockReturn:         models.Document{},
-			mockError:          errors.New("document not found"),
+			mockError:          services.ErrDocumentNotFound,
 			expectedStatusCode: http.StatusNotFound,
 			expectedResponse: map[string]interface{}{
-				"error": "document not found",
+				"error": services.ErrDocumentNotFound.Error(),
+				"code":  "document_not_found",
+			},
+		},
+		{
+			name:               "ApplicantNotFound",
+			docID:              "456",
+			applicantID:        "missing",
+			requestBody:        `{"applicant_id": "missing", "status": "` + models.DocumentVerified.String() + `"}`,
+			mockReturn:         models.Document{},
+			mockError:          apperr.ErrApplicantNotFound,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse: map[string]interface{}{
+				"error": apperr.ErrApplicantNotFound.Error(),
+				"code":  "applicant_not_found",
+			},
+		},
+		{
+			name:               "UpdateFails",
+			docID:              "789",
+			applicantID:        "applicant123",
+			requestBody:        `{"applicant_id": "applicant123", "status": "` + models.DocumentVerified.String() + `"}`,
+			mockReturn:         models.Document{},
+			mockError:          errors.New("could not update document: connection reset"),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedResponse: map[string]interface{}{
+				"error": "Could not update document",
 			},
 		},
 		{
@@ -345,3 +482,384 @@ func assertDocumentResponse(w *httptest.ResponseRecorder, t *testing.T) {
 
 	assert.Equal(t, expectedMap, actualMap)
 }
+
+// TestReplaceDocument tests how ReplaceDocument reports the outcome of a replacement
+func TestReplaceDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name               string
+		result             localModels.UploadResult
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{"Replaced", localModels.UploadResult{ProcessingStatus: localModels.ProcessingAccepted}, nil, http.StatusOK},
+		{"Waiting for storage", localModels.UploadResult{ProcessingStatus: localModels.ProcessingStoragePending}, nil, http.StatusAccepted},
+		{"Rejected by checks", localModels.UploadResult{ProcessingStatus: localModels.ProcessingRejected}, services.ErrUploadChecksFailed, http.StatusUnprocessableEntity},
+		{"Unknown document", localModels.UploadResult{}, services.ErrDocumentNotFound, http.StatusNotFound},
+		{"Previous upload still pending", localModels.UploadResult{}, services.ErrUploadInProgress, http.StatusConflict},
+		{"Concurrent replacement", localModels.UploadResult{}, services.ErrReplaceConflict, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockDocumentService)
+			mockService.On("ReplaceDocument", mock.Anything, "client1", "doc1", mock.Anything).Return(tt.result, tt.serviceErr)
+
+			router := gin.Default()
+			router.POST("/documents/:id/replace", func(c *gin.Context) {
+				c.Set("client_id", "client1")
+				ReplaceDocument(c, mockService)
+			})
+
+			req, _ := http.NewRequest(http.MethodPost, "/documents/doc1/replace", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUndeleteDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name               string
+		serviceErr         error
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{"Restored", nil, http.StatusOK, ""},
+		{"Deleted applicant", apperr.ErrApplicantNotFound, http.StatusNotFound, "applicant_not_found"},
+		{"Unknown document", services.ErrDocumentNotFound, http.StatusNotFound, "document_not_found"},
+		{"Not deleted", softdelete.ErrNotDeleted, http.StatusConflict, "not_deleted"},
+		{"Grace period over", softdelete.ErrGracePeriodOver, http.StatusGone, "restore_period_over"},
+		{"Applicant at its limit", services.ErrApplicantDocumentLimit, http.StatusConflict, "applicant_document_limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockDocumentService)
+			mockService.On("UndeleteDocument", mock.Anything, "client1", "app1", "doc1", mock.Anything).
+				Return(models.Document{DocumentID: "doc1", ApplicantID: "app1"}, tt.serviceErr)
+
+			router := gin.Default()
+			v2 := router.Group("/v2", apiversion.Middleware(apiversion.V2))
+			v2.POST("/applicants/:id/documents/:docId/undelete", func(c *gin.Context) {
+				c.Set("client_id", "client1")
+				UndeleteDocument(c, mockService)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/v2/applicants/app1/documents/doc1/undelete", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tt.expectedCode+`"`)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// TestGetDocumentVersions tests listing the versions of a client's document
+func TestGetDocumentVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(localMocks.MockDocumentService)
+	history := localModels.DocumentHistory{
+		DocumentID:     "doc1",
+		CurrentVersion: 2,
+		Versions:       []localModels.DocumentVersion{{Version: 1, FileURL: "https://bucket/doc1.pdf", ReplacedBy: "client1"}},
+	}
+	mockService.On("GetDocumentVersions", mock.Anything, "client1", "app1", "doc1", mock.Anything).Return(history, nil)
+	mockService.On("GetDocumentVersions", mock.Anything, "client1", "app1", "other", mock.Anything).Return(localModels.DocumentHistory{}, services.ErrDocumentNotFound)
+
+	router := gin.Default()
+	router.GET("/documents/:id/versions", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+		GetDocumentVersions(c, mockService)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/documents/doc1/versions?applicant_id=app1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"current_version":2`)
+	assert.NotContains(t, w.Body.String(), "https://bucket", "storage URLs must not be exposed")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/documents/other/versions?applicant_id=app1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/documents/doc1/versions", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetDocumentVersionsV2(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(localMocks.MockDocumentService)
+	mockService.On("GetDocumentVersions", mock.Anything, "client1", "app1", "doc1", mock.Anything).
+		Return(localModels.DocumentHistory{DocumentID: "doc1", CurrentVersion: 2}, nil)
+
+	router := gin.Default()
+	v2 := router.Group("/v2", apiversion.Middleware(apiversion.V2))
+	v2.GET("/applicants/:id/documents/:docId/versions", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+		GetDocumentVersions(c, mockService)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v2/applicants/app1/documents/doc1/versions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetUploadJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(localMocks.MockDocumentService)
+	job := localModels.UploadJob{JobID: "job1", ClientID: "client1", DocumentID: "doc1", Stage: localModels.UploadJobStoring}
+	mockService.On("GetUploadJob", mock.Anything, "client1", "job1").Return(job, nil)
+	mockService.On("GetUploadJob", mock.Anything, "client1", "job9").Return(localModels.UploadJob{}, services.ErrUploadJobNotFound)
+
+	router := gin.Default()
+	router.GET("/documents/:id", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+	router.GET("/documents/jobs/:job_id", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+		GetUploadJob(c, mockService)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/documents/jobs/job1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"stage":"storing"`)
+	assert.NotContains(t, w.Body.String(), "client1")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/documents/jobs/job9", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestApplicantFromPath tests that v2 uploads see the applicant in the path as the applicant_id form field
+func TestApplicantFromPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var seen []string
+	handler := func(c *gin.Context) {
+		applicantFromPath(c)
+		seen = append(seen, c.Request.FormValue("applicant_id"))
+	}
+	router := gin.Default()
+	router.POST("/documents", handler)
+	router.Group("/v2", apiversion.Middleware(apiversion.V2)).POST("/applicants/:id/documents", handler)
+
+	for _, path := range []string{"/documents", "/v2/applicants/from-path/documents"} {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("applicant_id", "from-form")
+		writer.Close()
+		req, _ := http.NewRequest(http.MethodPost, path, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, []string{"from-form", "from-path"}, seen)
+}
+
+// TestDownloadDocumentVersion tests staff downloading a replaced file
+func TestDownloadDocumentVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(localMocks.MockDocumentService)
+	version := localModels.DocumentVersion{Version: 1, FileURL: "https://bucket/doc1.pdf", FileSize: 9}
+	download := localModels.DocumentDownload{Viewer: "reviewer1", Purpose: "review"}
+	mockService.On("OpenDocumentVersion", mock.Anything, "app1", "doc1", 1, download, mock.Anything).
+		Return(version, io.NopCloser(strings.NewReader("%PDF-1.4\n")), nil)
+	mockService.On("OpenDocumentVersion", mock.Anything, "app1", "doc1", 5, download, mock.Anything).
+		Return(localModels.DocumentVersion{}, nil, services.ErrVersionNotFound)
+
+	router := gin.Default()
+	router.GET("/applicants/:id/documents/:docId/versions/:version", func(c *gin.Context) {
+		c.Set("admin_id", "reviewer1")
+		DownloadDocumentVersion(c, mockService)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/applicants/app1/documents/doc1/versions/1?reason=verification", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `"doc1_v1.pdf"`)
+	assert.Equal(t, "%PDF-1.4\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/applicants/app1/documents/doc1/versions/5?reason=verification", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/applicants/app1/documents/doc1/versions/latest?reason=verification", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/applicants/app1/documents/doc1/versions/1?reason=verification&purpose=line%0Abreak", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Every download must say why it is needed
+	for _, query := range []string{"", "?reason=curiosity"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/applicants/app1/documents/doc1/versions/1"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "reason must be one of verification, audit, support")
+	}
+	mockService.AssertNumberOfCalls(t, "OpenDocumentVersion", 2)
+}
+
+// TestArchiveDocuments tests downloading an applicant's documents as a ZIP
+func TestArchiveDocuments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(localMocks.MockDocumentService)
+	records := []localModels.DocumentRecord{{Document: models.Document{DocumentID: "doc1"}}}
+	entries := []localModels.DocumentArchiveEntry{{DocumentID: "doc1", File: "documents/doc1.pdf"}}
+	mockService.On("ListArchiveDocuments", mock.Anything, "client1", "app1", mock.Anything).Return(records, nil)
+	download := localModels.DocumentDownload{Viewer: "client1", Purpose: "audit"}
+	mockService.On("WriteDocumentArchive", mock.Anything, records, download, mock.Anything).Return("PK\x05\x06", entries, nil)
+	mockService.On("ListArchiveDocuments", mock.Anything, "client1", "nope", mock.Anything).Return(nil, apperr.ErrApplicantNotFound)
+	mockService.On("ListArchiveDocuments", mock.Anything, "client1", "broken", mock.Anything).Return(nil, errors.New("db down"))
+
+	// Downloads are recorded in the audit log with the reason given and the documents handed out
+	mockAudit := new(localMocks.MockAuditService)
+	mockAudit.On("Record", mock.Anything, mock.MatchedBy(func(event localModels.AuditEvent) bool {
+		return event.ActorID == "client1" && event.ActorRole == "client" && event.Reason == localModels.AccessReasonAudit &&
+			assert.ObjectsAreEqual([]string{"doc1"}, event.DocumentIDs) && event.Status == http.StatusOK
+	})).Return(localModels.AuditEvent{}, nil).Once()
+	mockAudit.On("Record", mock.Anything, mock.MatchedBy(func(event localModels.AuditEvent) bool {
+		return assert.ObjectsAreEqual([]string{"doc2"}, event.DocumentIDs) && event.Status == http.StatusInternalServerError
+	})).Return(localModels.AuditEvent{}, nil).Once()
+
+	router := gin.Default()
+	router.POST("/applicants/:id/documents/archive", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	}, auditControllers.RecordClientDownloads(mockAudit), func(c *gin.Context) {
+		ArchiveDocuments(c, mockService)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/applicants/app1/documents/archive?reason=audit&purpose=audit", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `"app1_documents.zip"`)
+	assert.Equal(t, "PK\x05\x06", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/applicants/nope/documents/archive?reason=audit", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "applicant_not_found")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/applicants/broken/documents/archive?reason=audit", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	// Settings that fail to load are reported before any of the archive is sent
+	failing := []localModels.DocumentRecord{{Document: models.Document{DocumentID: "doc2"}}}
+	mockService.On("ListArchiveDocuments", mock.Anything, "client1", "app2", mock.Anything).Return(failing, nil)
+	mockService.On("WriteDocumentArchive", mock.Anything, failing, mock.Anything, mock.Anything).Return(nil, nil, errors.New("db down"))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/applicants/app2/documents/archive?reason=audit", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+
+	// Requests refused for want of a reason hand nothing out and are not recorded
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/applicants/app1/documents/archive", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockAudit.AssertExpectations(t)
+}
+
+// TestPreviewDocument tests previews of documents and their cache headers
+func TestPreviewDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(localMocks.MockDocumentService)
+	download := localModels.DocumentDownload{Viewer: "client1", Purpose: "preview"}
+	rendered := localModels.DocumentPreview{ETag: `W/"abc"`, Content: []byte("\xff\xd8jpeg")}
+	mockService.On("PreviewDocument", mock.Anything, "client1", "app1", "doc1", localModels.PreviewRequest{Download: download}, mock.Anything).
+		Return(rendered, nil)
+	mockService.On("PreviewDocument", mock.Anything, "client1", "app1", "doc1", localModels.PreviewRequest{Download: download, Watermark: true, IfNoneMatch: `W/"abc"`}, mock.Anything).
+		Return(localModels.DocumentPreview{ETag: `W/"abc"`}, nil)
+	mockService.On("PreviewDocument", mock.Anything, "client1", "app1", "doc2", mock.Anything, mock.Anything).
+		Return(localModels.DocumentPreview{}, fmt.Errorf("%w: text/plain files", preview.ErrUnsupported))
+	mockService.On("PreviewDocument", mock.Anything, "client1", "app1", "doc3", mock.Anything, mock.Anything).
+		Return(localModels.DocumentPreview{}, services.ErrDocumentArchived)
+
+	router := gin.Default()
+	router.GET("/applicants/:id/documents/:docId/preview", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+		PreviewDocument(c, mockService, time.Minute)
+	})
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/applicants/app1/documents/doc1/preview?reason=verification", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, `W/"abc"`, w.Header().Get("ETag"))
+	assert.Equal(t, "\xff\xd8jpeg", w.Body.String())
+
+	// A preview the browser already has is not sent again
+	w = serve("/applicants/app1/documents/doc1/preview?reason=verification&watermark=true", http.Header{"If-None-Match": {`W/"abc"`}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = serve("/applicants/app1/documents/doc2/preview?reason=verification", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "preview_unavailable")
+
+	w = serve("/applicants/app1/documents/doc3/preview?reason=verification", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "document_archived")
+
+	for _, query := range []string{"", "?reason=verification&watermark=maybe"} {
+		w = serve("/applicants/app1/documents/doc1/preview"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+	mockService.AssertNumberOfCalls(t, "PreviewDocument", 4)
+}
+
+func TestPresignUploadChecksApplicantID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(localMocks.MockDocumentService)
+	router := gin.New()
+	router.POST("/documents/presign-upload", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+		PresignUpload(c, mockService)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/documents/presign-upload", strings.NewReader(`{"applicant_id":"doc_0192b3c4d5e67f808192a3b4c5d6e7f8","document_type":"passport","country":"GB","mime_type":"application/pdf","file_size":1024,"checksum_sha256":"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_id"`)
+	mockService.AssertNotCalled(t, "PresignUpload", mock.Anything, mock.Anything, mock.Anything)
+}
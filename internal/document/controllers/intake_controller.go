@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/consent"
+	"github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/dto"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/levels"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+// CreateApplicantFromDocument is the handler function for starting an applicant with its
+// identity document rather than its details. The form is that of a document upload, with
+// the verification level and, for clients that require it, the consent record as JSON.
+// The applicant is provisional until its details are confirmed with ConfirmApplicant.
+func CreateApplicantFromDocument(c *gin.Context, service interfaces.DocumentService) {
+	if err := services.ParseUploadForm(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	level := c.Request.FormValue("level")
+	if level == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "level is required"})
+		return
+	}
+
+	level, err := levels.Validate("level", level)
+	if apperr.Respond(c, err) {
+		return
+	}
+
+	// Clients may only verify applicants at the levels they were onboarded for
+	client, err := clientconfig.FromContext(c)
+	if err != nil {
+		log.Printf("CreateApplicantFromDocument: Error loading client settings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create applicant"})
+		return
+	}
+	if !clientconfig.AllowsLevel(client, level) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "level is not enabled for this client", "allowed_levels": client.AllowedVerificationLevels})
+		return
+	}
+	var submitted *localModels.Consent
+	if raw := c.Request.FormValue("consent"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &submitted); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "consent must be a JSON object"})
+			return
+		}
+	}
+	consentRecord, err := consent.Record(client, submitted, timestamp.Now())
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection := DocumentCollection()
+	intake, err := service.CreateApplicantFromDocument(c, level, consentRecord, collection)
+	if mongoretry.RespondUnavailable(c, err) || RespondUploadLimit(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if intake.Upload.ProcessingStatus == localModels.ProcessingScanPending || intake.Upload.ProcessingStatus == localModels.ProcessingStoragePending {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, gin.H{
+		"applicant_id": intake.ApplicantID,
+		"intake":       intake.Intake,
+		"extracted":    intake.Extracted,
+		"document":     dto.UploadResponse(apiversion.FromContext(c), intake.Upload),
+	})
+}
+
+// ConfirmApplicant is the handler function for confirming the details of an applicant
+// created from a document, correcting any that were misread and adding the contact
+// details and address
+func ConfirmApplicant(c *gin.Context, service interfaces.DocumentService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var confirmation localModels.ApplicantConfirmation
+	if err := c.ShouldBindJSON(&confirmation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, name := range []*string{confirmation.FirstName, confirmation.LastName} {
+		if name != nil && strings.TrimSpace(*name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "first_name and last_name cannot be empty"})
+			return
+		}
+	}
+	if confirmation.DOB != nil {
+		if _, err := time.Parse("2006-01-02", strings.TrimSpace(*confirmation.DOB)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dob must be a date as YYYY-MM-DD"})
+			return
+		}
+	}
+
+	applicant, err := service.ConfirmApplicant(c, clientID, c.Param("id"), confirmation)
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		log.Printf("ConfirmApplicant: Error confirming applicant: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not confirm applicant"})
+	default:
+		c.JSON(http.StatusOK, dto.ApplicantResponse(apiversion.FromContext(c), applicant))
+	}
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	auditControllers "github.com/rachel-lawrie/verus_app_backend/internal/audit/controllers"
+	"github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/preview"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+// PreviewDocument is the handler function for a downscaled image of a document, which
+// browsers may keep for maxAge. Like downloads, previews must give a reason.
+func PreviewDocument(c *gin.Context, service interfaces.DocumentService, maxAge time.Duration) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	reason, err := services.DownloadReason(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	purpose, err := services.DownloadPurpose(c, services.PurposePreview)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stamped := false
+	if value := c.Query("watermark"); value != "" {
+		if stamped, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "watermark must be true or false"})
+			return
+		}
+	}
+	if maxAge <= 0 {
+		maxAge = preview.DefaultMaxAge
+	}
+
+	applicantID, docID := c.Param("id"), c.Param("docId")
+	auditControllers.NoteDocumentAccess(c, reason, docID)
+	collection := DocumentCollection()
+	request := localModels.PreviewRequest{
+		Download:    localModels.DocumentDownload{Viewer: clientID, Purpose: purpose},
+		Watermark:   stamped,
+		IfNoneMatch: c.GetHeader("If-None-Match"),
+	}
+	result, err := service.PreviewDocument(c, clientID, applicantID, docID, request, collection)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		log.Printf("PreviewDocument: Error drawing preview: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not preview document"})
+		return
+	}
+
+	// Previews show personal data, so only the browser that asked may keep them
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	c.Header("ETag", result.ETag)
+	if result.Content == nil {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Header("Content-Disposition", "inline")
+	c.Data(http.StatusOK, preview.ContentType, result.Content)
+}
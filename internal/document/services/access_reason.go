@@ -0,0 +1,23 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// ErrInvalidReason is returned for a download that gives no reason, or one that is not allowed
+var ErrInvalidReason = errors.New("reason must be one of " + strings.Join(localModels.AccessReasons, ", "))
+
+// DownloadReason reads the reason query parameter every download of document files must give
+func DownloadReason(c *gin.Context) (string, error) {
+	reason := c.Query("reason")
+	for _, allowed := range localModels.AccessReasons {
+		if reason == allowed {
+			return reason, nil
+		}
+	}
+	return "", ErrInvalidReason
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongotx"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mrz"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pii"
+	"github.com/rachel-lawrie/verus_app_backend/internal/requestmeta"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUnreadableDocument is returned when the details of a new applicant cannot be read
+// from the document uploaded to create it
+var ErrUnreadableDocument = apperr.Unprocessable("document_unreadable", "applicant details could not be read from the document")
+
+// ErrNotProvisional is returned when confirming an applicant that was not created from a
+// document or has been confirmed already
+var ErrNotProvisional = apperr.Conflict("applicant_not_provisional", "applicant is not awaiting confirmation")
+
+// CreateApplicantFromDocument creates an applicant from the identity document in the
+// upload form, before the client has sent any of the applicant's details. The name, date
+// of birth and nationality are read from the MRZ sent with the document. The applicant is
+// provisional until the client confirms its details with ConfirmApplicant, and the
+// document is saved as its first. An upload refused by its checks creates no applicant.
+func (s *DocumentServiceImpl) CreateApplicantFromDocument(c *gin.Context, level string, consent *localModels.Consent, collection common.CollectionInterface) (localModels.DocumentIntake, error) {
+	r := c.Request
+	file, fileHeader, mimeType, ext, err := readDocumentFile(c)
+	if err != nil {
+		return localModels.DocumentIntake{}, err
+	}
+	defer file.Close()
+
+	documentType := r.FormValue("document_type")
+	if documentType == "" {
+		return localModels.DocumentIntake{}, requiredField("document_type")
+	}
+	country := r.FormValue("country")
+	if country == "" {
+		return localModels.DocumentIntake{}, requiredField("country")
+	}
+	extracted, err := extractIdentity(r.FormValue("mrz"))
+	if err != nil {
+		return localModels.DocumentIntake{}, err
+	}
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		return localModels.DocumentIntake{}, err
+	}
+	end, err := s.Limits.begin(clientID)
+	if err != nil {
+		return localModels.DocumentIntake{}, err
+	}
+	defer end()
+	// The applicant is new, so only the file itself counts against its level's limit
+	if s.Limits != nil {
+		if err := checkApplicantLimit(s.Limits.forLevel(level), level, applicantUsage{}, 1, fileHeader.Size); err != nil {
+			return localModels.DocumentIntake{}, err
+		}
+	}
+
+	applicantID := ids.Applicant.New()
+	doc := createDocumentObject(applicantID, documentType, country)
+	doc.FileSize = fileHeader.Size
+	fileName := doc.DocumentID + ext
+	record := localModels.DocumentRecord{Document: doc}
+	record.ClientID = clientID
+	tracker := s.trackUpload(r.Context(), record)
+
+	applicant := documentApplicant{ClientID: clientID, VerificationLevel: level, Consent: consent}
+	result, err := s.checkUpload(c, file, fileHeader.Size, mimeType, country, applicant, &record)
+	if err != nil {
+		tracker.fail(r.Context(), result.ProcessingStatus, err)
+		return localModels.DocumentIntake{Upload: result}, err
+	}
+
+	stored, err := s.newProvisionalApplicant(c, clientID, applicantID, level, consent, record.DocumentID, extracted)
+	if err == nil {
+		err = mongoschema.ValidateInsert(s.ApplicantCollectionName, stored)
+	}
+	if err != nil {
+		tracker.fail(r.Context(), result.ProcessingStatus, err)
+		return localModels.DocumentIntake{}, fmt.Errorf("could not create applicant: %w", err)
+	}
+
+	// The record is saved with a placeholder URL before the file goes to S3, as saveUpload does
+	s.stageUpload(&record, file, fileName, mimeType)
+	record.Upload.JobID = tracker.jobID()
+
+	// The applicant, its first document, what the document records on it and the usage of
+	// both are saved together where transactions are available, before the file is stored
+	err = mongotx.Run(r.Context(), "create_applicant_from_document", func(ctx context.Context) error {
+		applicants := tenant.Guard(common.GetCollection(s.ApplicantCollectionName))
+		err := mongoretry.Write(ctx, "create_applicant", func(ctx context.Context) error {
+			return applicants.InsertOnce(ctx, tenant.Of(clientID).With("applicant_id", applicantID), stored)
+		})
+		if err != nil {
+			return fmt.Errorf("could not create applicant: %w", err)
+		}
+		if err := saveDocumentRecord(ctx, applicantID, record, collection); err != nil {
+			return fmt.Errorf("could not create document: %w", err)
+		}
+		if err := s.recordOutcome(ctx, applicantID, record); err != nil {
+			return err
+		}
+		if s.Usage == nil {
+			return nil
+		}
+		err = s.Usage.Record(ctx, clientID, localModels.UsageApplicantCreated, applicantID)
+		if err != nil && !mongotx.InTransaction(ctx) {
+			// The applicant is saved already, so billing problems are logged rather than failing the request
+			log.Printf("Error recording usage for applicant %s: %v", applicantID, err)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		// Without a transaction the applicant may have been saved before the failure
+		if !mongotx.Enabled() {
+			s.removeProvisionalApplicant(r.Context(), clientID, applicantID)
+		}
+		removeStaged(record.Upload.StagedPath)
+		tracker.fail(r.Context(), result.ProcessingStatus, err)
+		return localModels.DocumentIntake{}, err
+	}
+
+	s.finishUpload(c, collection, applicantID, &record, file, &result, tracker)
+
+	intake := *stored.Intake
+	intake.Extracted = extracted
+	return localModels.DocumentIntake{ApplicantID: applicantID, Intake: intake, Extracted: extracted, Upload: result}, nil
+}
+
+// extractIdentity reads a new applicant's details from a document's machine readable zone
+func extractIdentity(mrzText string) (localModels.ExtractedIdentity, error) {
+	if strings.TrimSpace(mrzText) == "" {
+		return localModels.ExtractedIdentity{}, fmt.Errorf("%w: mrz is required", ErrUnreadableDocument)
+	}
+	parsed, err := mrz.Parse(mrzText)
+	if err != nil {
+		return localModels.ExtractedIdentity{}, fmt.Errorf("%w: %v", ErrUnreadableDocument, err)
+	}
+	if parsed.Surname == "" || parsed.BirthDate == nil {
+		return localModels.ExtractedIdentity{}, fmt.Errorf("%w: MRZ holds no surname or date of birth", ErrUnreadableDocument)
+	}
+	firstName, middleName, _ := strings.Cut(parsed.GivenNames, " ")
+	return localModels.ExtractedIdentity{
+		Source:         "mrz",
+		FirstName:      firstName,
+		MiddleName:     middleName,
+		LastName:       parsed.Surname,
+		DOB:            parsed.BirthDate.Format("2006-01-02"),
+		Nationality:    parsed.Nationality,
+		IssuingCountry: parsed.IssuingCountry,
+	}, nil
+}
+
+// newProvisionalApplicant builds the record of an applicant created from a document. The
+// date of birth is encrypted like that of any applicant, and the intake keeps what was
+// read sealed with its own data key.
+func (s *DocumentServiceImpl) newProvisionalApplicant(c *gin.Context, clientID, applicantID, level string, consent *localModels.Consent, documentID string, extracted localModels.ExtractedIdentity) (localModels.ApplicantRecord, error) {
+	ctx := c.Request.Context()
+	plaintextKey, encryptedKey, err := s.KMSUploader.GenerateDataKey(ctx)
+	if err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to generate data key: %v", err)
+	}
+	encryptedDOB, err := utils.EncryptField(extracted.DOB, plaintextKey)
+	if err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to encrypt date of birth: %v", err)
+	}
+
+	intake := localModels.ApplicantIntake{State: localModels.IntakeProvisional, DocumentID: documentID, Extracted: extracted}
+	if err := pii.FromContext(c, s.KMSUploader).Encrypt(ctx, &intake); err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to seal extracted details: %w", err)
+	}
+
+	now := timestamp.Now()
+	return localModels.ApplicantRecord{
+		Applicant: models.Applicant{
+			ApplicantID:       applicantID,
+			FirstName:         extracted.FirstName,
+			MiddleName:        extracted.MiddleName,
+			LastName:          extracted.LastName,
+			ClientID:          clientID,
+			EncryptedData:     models.EncryptedData{DOB: encryptedDOB, EncryptedKey: encryptedKey},
+			CreatedAt:         now,
+			UpdatedAt:         now,
+			Documents:         []models.Document{},
+			VerificationLevel: level,
+		},
+		DeviceMetadata: &localModels.DeviceMetadata{Captured: requestmeta.FromContext(c), CapturedAt: now},
+		Consent:        consent,
+		Intake:         &intake,
+	}, nil
+}
+
+// removeProvisionalApplicant deletes an applicant whose document could not be saved without
+// a transaction, so the client can start again without an empty applicant left behind
+func (s *DocumentServiceImpl) removeProvisionalApplicant(ctx context.Context, clientID, applicantID string) {
+	err := mongoretry.Write(ctx, "remove_provisional_applicant", func(ctx context.Context) error {
+		_, err := tenant.Guard(common.GetCollection(s.ApplicantCollectionName)).DeleteOne(ctx,
+			tenant.Of(clientID).With("applicant_id", applicantID).With("intake.state", localModels.IntakeProvisional))
+		return err
+	})
+	if err != nil {
+		log.Printf("Error removing provisional applicant %s: %v", applicantID, err)
+	}
+}
+
+// ConfirmApplicant confirms the details of a client's provisional applicant, taking any
+// corrected names and date of birth in place of those read from its document, and adds
+// the contact details and address. The fields corrected are recorded on the intake.
+func (s *DocumentServiceImpl) ConfirmApplicant(c *gin.Context, clientID, applicantID string, confirmation localModels.ApplicantConfirmation) (localModels.ApplicantRecord, error) {
+	ctx := c.Request.Context()
+	applicants := tenant.Guard(common.GetCollection(s.ApplicantCollectionName))
+	scope := tenant.Of(clientID).With("applicant_id", applicantID).With("deleted", false)
+	var applicant localModels.ApplicantRecord
+	err := applicants.FindOne(ctx, scope).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return localModels.ApplicantRecord{}, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to look up applicant: %v", err)
+	}
+	if applicant.Intake == nil || applicant.Intake.State != localModels.IntakeProvisional {
+		return localModels.ApplicantRecord{}, ErrNotProvisional
+	}
+
+	intake := *applicant.Intake
+	if err := pii.FromContext(c, s.KMSUploader).Decrypt(ctx, &intake); err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to open extracted details: %w", err)
+	}
+	identity, corrected := confirmIdentity(intake.Extracted, confirmation)
+
+	plaintextKey, encryptedKey, err := s.KMSUploader.GenerateDataKey(ctx)
+	if err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to generate data key: %v", err)
+	}
+	encryptedDOB, err := utils.EncryptField(identity.DOB, plaintextKey)
+	if err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to encrypt date of birth: %v", err)
+	}
+	encryptedAddress, err := utils.EncryptAddress(confirmation.Address, plaintextKey)
+	if err != nil {
+		return localModels.ApplicantRecord{}, fmt.Errorf("failed to encrypt address: %v", err)
+	}
+
+	now := timestamp.Now()
+	set := bson.M{
+		"first_name":          identity.FirstName,
+		"middle_name":         identity.MiddleName,
+		"last_name":           identity.LastName,
+		"email":               confirmation.Email,
+		"phone":               confirmation.Phone,
+		"encrypted_data":      models.EncryptedData{DOB: encryptedDOB, Address: encryptedAddress, EncryptedKey: encryptedKey},
+		"intake.state":        localModels.IntakeConfirmed,
+		"intake.confirmed_at": now,
+		"updated_at":          now,
+	}
+	if len(corrected) > 0 {
+		set["intake.corrected"] = corrected
+	}
+
+	// Only a provisional applicant is confirmed, so of two confirmations at once one fails
+	filter := scope.With("intake.state", localModels.IntakeProvisional)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var confirmed localModels.ApplicantRecord
+	err = mongoretry.Write(ctx, "confirm_applicant", func(ctx context.Context) error {
+		return applicants.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&confirmed)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return localModels.ApplicantRecord{}, ErrNotProvisional
+	}
+	if err != nil {
+		return localModels.ApplicantRecord{}, err
+	}
+	return confirmed, nil
+}
+
+// confirmIdentity applies a confirmation to what was read from a document, returning the
+// confirmed details and the fields that were corrected. Differences only in case or
+// surrounding space, such as "ANNA" confirmed as "Anna", are not corrections.
+func confirmIdentity(extracted localModels.ExtractedIdentity, confirmation localModels.ApplicantConfirmation) (localModels.ExtractedIdentity, []string) {
+	identity := extracted
+	var corrected []string
+	fields := []struct {
+		name  string
+		value *string
+		given *string
+	}{
+		{"first_name", &identity.FirstName, confirmation.FirstName},
+		{"middle_name", &identity.MiddleName, confirmation.MiddleName},
+		{"last_name", &identity.LastName, confirmation.LastName},
+		{"dob", &identity.DOB, confirmation.DOB},
+	}
+	for _, field := range fields {
+		if field.given == nil {
+			continue
+		}
+		given := strings.TrimSpace(*field.given)
+		if !strings.EqualFold(given, *field.value) {
+			corrected = append(corrected, field.name)
+		}
+		*field.value = given
+	}
+	return identity, corrected
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractIdentity(t *testing.T) {
+	extracted, err := extractIdentity("P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204159ZE184226B<<<<<10")
+	require.NoError(t, err)
+	assert.Equal(t, localModels.ExtractedIdentity{
+		Source:         "mrz",
+		FirstName:      "ANNA",
+		MiddleName:     "MARIA",
+		LastName:       "ERIKSSON",
+		DOB:            "1974-08-12",
+		Nationality:    "UTO",
+		IssuingCountry: "UTO",
+	}, extracted)
+
+	for _, text := range []string{"", "hello world"} {
+		_, err := extractIdentity(text)
+		assert.ErrorIs(t, err, ErrUnreadableDocument)
+	}
+}
+
+func TestConfirmIdentity(t *testing.T) {
+	extracted := localModels.ExtractedIdentity{Source: "mrz", FirstName: "ANNA", MiddleName: "MARIA", LastName: "ERIKSSON", DOB: "1974-08-12"}
+	text := func(s string) *string { return &s }
+
+	identity, corrected := confirmIdentity(extracted, localModels.ApplicantConfirmation{})
+	assert.Equal(t, extracted, identity)
+	assert.Empty(t, corrected)
+
+	identity, corrected = confirmIdentity(extracted, localModels.ApplicantConfirmation{
+		FirstName:  text("Anna"),
+		MiddleName: text(""),
+		LastName:   text(" Eriksson Berg "),
+		DOB:        text("1974-08-21"),
+	})
+	assert.Equal(t, "Anna", identity.FirstName)
+	assert.Equal(t, "", identity.MiddleName)
+	assert.Equal(t, "Eriksson Berg", identity.LastName)
+	assert.Equal(t, "1974-08-21", identity.DOB)
+	assert.Equal(t, []string{"middle_name", "last_name", "dob"}, corrected)
+}
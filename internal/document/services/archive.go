@@ -0,0 +1,195 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_app_backend/internal/watermark"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// archiveDownloads is how many files of an archive are fetched from S3 at once. Each is
+// held in memory until its turn to be written, so this also bounds the memory an archive uses.
+const archiveDownloads = 4
+
+// ArchiveManifest is the name of the file in each archive describing its documents
+const ArchiveManifest = "manifest.json"
+
+// ListArchiveDocuments returns the documents of a client's applicant, oldest first
+func (s *DocumentServiceImpl) ListArchiveDocuments(c *gin.Context, clientID, applicantID string, collection common.CollectionInterface) ([]localModels.DocumentRecord, error) {
+	scope := tenant.Of(clientID)
+	if _, err := s.findApplicant(c.Request.Context(), scope, applicantID); err != nil {
+		return nil, err
+	}
+
+	filter := scope.With("applicant_id", applicantID).With("deleted", false)
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := tenant.Guard(collection).Find(c.Request.Context(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up documents: %v", err)
+	}
+	defer cursor.Close(c.Request.Context())
+
+	documents := []localModels.DocumentRecord{}
+	if err := cursor.All(c.Request.Context(), &documents); err != nil {
+		return nil, fmt.Errorf("failed to decode documents: %v", err)
+	}
+	return documents, nil
+}
+
+// archiveFile is a document's file fetched for an archive
+type archiveFile struct {
+	content     *bytes.Buffer
+	watermarked bool
+	err         error
+}
+
+// WriteDocumentArchive writes the current files of documents to w as a ZIP, followed by
+// a manifest describing every document. Files are fetched from S3 several at a time but
+// written in order, so the archive streams out as the downloads complete, and are
+// watermarked for the download if their client asks for it. Files that are not stored or
+// cannot be downloaded are left out and noted in the manifest. Nothing is written when the
+// client's settings cannot be loaded; after that an error is only returned once writing to
+// w fails, by which time part of the archive may be sent.
+func (s *DocumentServiceImpl) WriteDocumentArchive(ctx context.Context, documents []localModels.DocumentRecord, download localModels.DocumentDownload, w io.Writer) ([]localModels.DocumentArchiveEntry, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// An archive holds one applicant's documents, so they share a client
+	var mark *watermark.Mark
+	if len(documents) > 0 {
+		var err error
+		if mark, err = s.downloadWatermark(ctx, documents[0].ClientID, download); err != nil {
+			return nil, fmt.Errorf("failed to load client settings: %v", err)
+		}
+	}
+
+	entries := make([]localModels.DocumentArchiveEntry, len(documents))
+	files := make([]chan archiveFile, len(documents))
+	for i, doc := range documents {
+		entries[i] = localModels.DocumentArchiveEntry{
+			DocumentID:   doc.DocumentID,
+			DocumentType: doc.DocumentType,
+			Country:      doc.Country,
+			Status:       doc.Status,
+			Version:      doc.CurrentVersion(),
+			FileSize:     doc.FileSize,
+			CreatedAt:    doc.CreatedAt,
+		}
+		switch {
+		case doc.Upload != nil && doc.Upload.State == localModels.UploadFailed:
+			entries[i].Missing = "upload failed"
+		case doc.FileURL == "" || doc.FileURL == localModels.PlaceholderFileURL:
+			entries[i].Missing = "upload pending"
+		case !doc.ColdStorage.Downloadable(timestamp.Now()):
+			entries[i].Missing = "in cold storage"
+		default:
+			files[i] = make(chan archiveFile, 1)
+		}
+	}
+
+	// Start downloads as slots free up; a slot is released once its file is written
+	slots := make(chan struct{}, archiveDownloads)
+	go func() {
+		for i, doc := range documents {
+			if files[i] == nil {
+				continue
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(doc localModels.DocumentRecord, result chan<- archiveFile) {
+				content, err := s.fetchArchiveFile(ctx, doc.FileURL)
+				file := archiveFile{content: content, err: err}
+				if err == nil && mark != nil {
+					if stamped, ok := stampFile(doc.DocumentID, doc.FileURL, content.Bytes(), mark); ok {
+						putBuffer(content)
+						file.content, file.watermarked = bytes.NewBuffer(stamped), true
+					}
+				}
+				result <- file
+			}(doc, files[i])
+		}
+	}()
+
+	archive := zip.NewWriter(w)
+	for i, doc := range documents {
+		if files[i] == nil {
+			continue
+		}
+		file := <-files[i]
+		err := func() error {
+			defer func() { <-slots }()
+			if file.err != nil {
+				zaplogger.GetLogger().Warn("Error downloading document for archive", zap.Error(file.err), zap.String("documentID", doc.DocumentID))
+				entries[i].Missing = "download failed"
+				return nil
+			}
+			defer putBuffer(file.content)
+			name := "documents/" + doc.DocumentID + path.Ext(doc.FileURL)
+			// Documents are already compressed images and PDFs, so they are stored as they are
+			part, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: doc.UpdatedAt})
+			if err != nil {
+				return err
+			}
+			if _, err := file.content.WriteTo(part); err != nil {
+				return err
+			}
+			entries[i].File = name
+			entries[i].Watermarked = file.watermarked
+			return nil
+		}()
+		if err != nil {
+			return entries, fmt.Errorf("failed to write archive: %v", err)
+		}
+	}
+
+	manifest, err := archive.Create(ArchiveManifest)
+	if err != nil {
+		return entries, fmt.Errorf("failed to write archive: %v", err)
+	}
+	encoder := json.NewEncoder(manifest)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return entries, fmt.Errorf("failed to write archive: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		return entries, fmt.Errorf("failed to write archive: %v", err)
+	}
+	return entries, nil
+}
+
+// fetchArchiveFile downloads a stored file into a pooled buffer
+func (s *DocumentServiceImpl) fetchArchiveFile(ctx context.Context, fileURL string) (*bytes.Buffer, error) {
+	objectKey, err := getObjectKeyFromURL(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	output, err := s.Uploader.DownloadFile(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	buf := getBuffer()
+	if _, err := buf.ReadFrom(output.Body); err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
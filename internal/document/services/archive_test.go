@@ -0,0 +1,183 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storedFiles stands in for S3, serving files by object key and tracking how many
+// downloads are in flight at once
+type storedFiles struct {
+	files    map[string]string
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (s *storedFiles) UploadFile(ctx context.Context, file multipart.File, fileName string, mimeType string, kmsUploader interfaces.KMSUploader) (string, error) {
+	return "", fmt.Errorf("not supported")
+}
+
+func (s *storedFiles) DownloadFile(ctx context.Context, objectKey string) (*s3.GetObjectOutput, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	content, ok := s.files[objectKey]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchKey: %s", objectKey)
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(content))}, nil
+}
+
+func archivedDocument(id, fileURL string) localModels.DocumentRecord {
+	return localModels.DocumentRecord{Document: coreModels.Document{DocumentID: id, FileURL: fileURL}}
+}
+
+func TestWriteDocumentArchive(t *testing.T) {
+	files := &storedFiles{files: map[string]string{}}
+	documents := []localModels.DocumentRecord{}
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("doc%d.png", i)
+		files.files[key] = "content of " + key
+		documents = append(documents, archivedDocument(fmt.Sprintf("doc%d", i), "https://bucket.s3.amazonaws.com/"+key))
+	}
+	pending := archivedDocument("pending", localModels.PlaceholderFileURL)
+	failed := archivedDocument("failed", localModels.PlaceholderFileURL)
+	failed.Upload = &localModels.StorageUpload{State: localModels.UploadFailed}
+	gone := archivedDocument("gone", "https://bucket.s3.amazonaws.com/gone.pdf")
+	documents = append(documents[:3], append([]localModels.DocumentRecord{pending, failed, gone}, documents[3:]...)...)
+
+	service := &DocumentServiceImpl{Uploader: files}
+	var out bytes.Buffer
+	entries, err := service.WriteDocumentArchive(context.Background(), documents, localModels.DocumentDownload{}, &out)
+	require.NoError(t, err)
+	require.Len(t, entries, len(documents))
+	assert.LessOrEqual(t, files.peak.Load(), int32(archiveDownloads))
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	var names []string
+	contents := map[string]string{}
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+		r, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		contents[file.Name] = string(content)
+	}
+
+	// Files keep the order of the documents, with the manifest last
+	want := []string{}
+	for i := 0; i < 10; i++ {
+		want = append(want, fmt.Sprintf("documents/doc%d.png", i))
+	}
+	assert.Equal(t, append(want, ArchiveManifest), names)
+	assert.Equal(t, "content of doc4.png", contents["documents/doc4.png"])
+
+	var manifest []localModels.DocumentArchiveEntry
+	require.NoError(t, json.Unmarshal([]byte(contents[ArchiveManifest]), &manifest))
+	assert.Equal(t, entries, manifest)
+	missing := map[string]string{}
+	for _, entry := range manifest {
+		if entry.Missing != "" {
+			missing[entry.DocumentID] = entry.Missing
+			assert.Empty(t, entry.File)
+		}
+	}
+	assert.Equal(t, map[string]string{"pending": "upload pending", "failed": "upload failed", "gone": "download failed"}, missing)
+	assert.Equal(t, "documents/doc0.png", manifest[0].File)
+	assert.Equal(t, 1, manifest[0].Version)
+}
+
+// failingWriter accepts limit bytes and then fails
+type failingWriter struct {
+	limit int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		return 0, fmt.Errorf("connection reset")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteDocumentArchiveWriteError(t *testing.T) {
+	files := &storedFiles{files: map[string]string{}}
+	documents := []localModels.DocumentRecord{}
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("doc%d.pdf", i)
+		files.files[key] = strings.Repeat("x", 64<<10)
+		documents = append(documents, archivedDocument(fmt.Sprintf("doc%d", i), "https://bucket.s3.amazonaws.com/"+key))
+	}
+
+	service := &DocumentServiceImpl{Uploader: files}
+	_, err := service.WriteDocumentArchive(context.Background(), documents, localModels.DocumentDownload{}, &failingWriter{limit: 100 << 10})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write archive")
+}
+
+func TestWriteDocumentArchiveWatermark(t *testing.T) {
+	var img bytes.Buffer
+	require.NoError(t, png.Encode(&img, image.NewGray(image.Rect(0, 0, 400, 300))))
+	files := &storedFiles{files: map[string]string{"doc1.png": img.String(), "doc2.pdf": "%PDF-1.4 encrypted"}}
+	documents := []localModels.DocumentRecord{
+		archivedDocument("doc1", "https://bucket.s3.amazonaws.com/doc1.png"),
+		archivedDocument("doc2", "https://bucket.s3.amazonaws.com/doc2.pdf"),
+	}
+	for i := range documents {
+		documents[i].ClientID = "client1"
+	}
+	clients := clientLoader{"client1": {ClientID: "client1", Settings: localModels.ClientSettings{Watermark: localModels.DownloadWatermark{Enabled: true}}}}
+
+	service := &DocumentServiceImpl{Uploader: files, Clients: clients}
+	var out bytes.Buffer
+	entries, err := service.WriteDocumentArchive(context.Background(), documents, localModels.DocumentDownload{Viewer: "client1", Purpose: "audit"}, &out)
+	require.NoError(t, err)
+	assert.True(t, entries[0].Watermarked)
+	// A file that cannot be stamped is still included
+	assert.False(t, entries[1].Watermarked)
+	assert.Equal(t, "documents/doc2.pdf", entries[1].File)
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	r, err := archive.File[0].Open()
+	require.NoError(t, err)
+	stamped, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.NotEqual(t, img.Bytes(), stamped)
+	_, err = png.Decode(bytes.NewReader(stamped))
+	assert.NoError(t, err)
+
+	// Nothing is written when the client's settings cannot be loaded
+	out.Reset()
+	service.Clients = clientLoader(nil)
+	_, err = service.WriteDocumentArchive(context.Background(), documents, localModels.DocumentDownload{}, &out)
+	assert.Error(t, err)
+	assert.Zero(t, out.Len())
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// clientDocuments holds one document of client1, which was deleted when deleted is set,
+// and answers only filters that match it
+type clientDocuments struct {
+	filters []bson.M
+	deleted bool
+}
+
+var client1Document = bson.D{
+	{Key: "document_id", Value: "doc1"},
+	{Key: "applicant_id", Value: "app1"},
+	{Key: "client_id", Value: "client1"},
+	{Key: "file_url", Value: "https://bucket.s3.us-east-1.amazonaws.com/app1/doc1.png"},
+}
+
+func (d *clientDocuments) matches(filter interface{}) bool {
+	f, _ := filter.(bson.M)
+	d.filters = append(d.filters, f)
+	if d.deleted && f["deleted"] == false {
+		return false
+	}
+	return f["client_id"] == "client1" && f["document_id"] == "doc1" && f["applicant_id"] == "app1"
+}
+
+func (d *clientDocuments) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return &mongo.InsertOneResult{}, nil
+}
+
+func (d *clientDocuments) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	if !d.matches(filter) {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(client1Document, nil, nil)
+}
+
+func (d *clientDocuments) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	if !d.matches(filter) {
+		return mongo.NewCursorFromDocuments(nil, nil, nil)
+	}
+	return mongo.NewCursorFromDocuments([]interface{}{client1Document}, nil, nil)
+}
+
+func (d *clientDocuments) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	if !d.matches(filter) {
+		return &mongo.UpdateResult{}, nil
+	}
+	return &mongo.UpdateResult{MatchedCount: 1}, nil
+}
+
+func requestAs(clientID string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if clientID != "" {
+		c.Set("client_id", clientID)
+	}
+	return c
+}
+
+func TestOtherClientsCannotReachADocument(t *testing.T) {
+	service := GetDocumentServiceImpl()
+	svc := &service
+
+	for _, clientID := range []string{"client2", ""} {
+		documents := &clientDocuments{}
+		_, err := svc.DownloadDocument(requestAs(clientID), "doc1", "app1", documents)
+		if clientID == "" {
+			assert.ErrorIs(t, err, tenant.ErrUnscoped)
+		} else {
+			assert.ErrorIs(t, err, ErrDocumentNotFound, "answered like a document that does not exist")
+		}
+
+		_, err = svc.GetDocumentVersions(requestAs(clientID), clientID, "app1", "doc1", documents)
+		if clientID == "" {
+			assert.ErrorIs(t, err, tenant.ErrUnscoped)
+		} else {
+			assert.ErrorIs(t, err, ErrDocumentNotFound)
+		}
+
+		_, _, err = GenerateFilterAndCacheKey(tenant.FromContext(requestAs(clientID)), "app1", "doc1", "documents")
+		if clientID == "" {
+			assert.ErrorIs(t, err, tenant.ErrUnscoped)
+		}
+
+		require.NoError(t, markStored(context.Background(), documents, tenant.Of("client2"), "app1", "doc1", "https://example.com/doc1"))
+		for _, filter := range documents.filters {
+			assert.Equal(t, "client2", filter["client_id"], "every query names the requesting client")
+		}
+	}
+}
+
+func TestOwnerReachesItsDocument(t *testing.T) {
+	documents := &clientDocuments{}
+	service := GetDocumentServiceImpl()
+	history, err := service.GetDocumentVersions(requestAs("client1"), "client1", "app1", "doc1", documents)
+	require.NoError(t, err)
+	assert.Equal(t, "doc1", history.DocumentID)
+
+	filter, _, err := GenerateFilterAndCacheKey(tenant.FromContext(requestAs("client1")), "app1", "doc1", "documents")
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"client_id": "client1", "applicant_id": "app1", "document_id": "doc1", "deleted": false}, filter)
+}
+
+func TestDeletedDocumentsCannotBeDownloaded(t *testing.T) {
+	documents := &clientDocuments{deleted: true}
+	service := GetDocumentServiceImpl()
+	_, err := service.DownloadDocument(requestAs("client1"), "doc1", "app1", documents)
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	require.Len(t, documents.filters, 1)
+	assert.Equal(t, false, documents.filters[0]["deleted"])
+}
+
+func TestUploadsNeedTheApplicantsOwner(t *testing.T) {
+	// Without an authenticated client the applicant is never looked up
+	service := GetDocumentServiceImpl()
+	_, err := service.ownedApplicant(context.Background(), "", "app1")
+	assert.ErrorIs(t, err, tenant.ErrUnscoped)
+}
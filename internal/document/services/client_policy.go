@@ -0,0 +1,39 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// clientPolicyCheck applies the uploading client's own limits on file size and document type
+func clientPolicyCheck(client localModels.Client, size int64, documentType string) localModels.UploadCheck {
+	check := localModels.UploadCheck{Name: "client_policy", Status: localModels.UploadCheckPassed}
+	switch {
+	case client.Settings.MaxUploadBytes > 0 && size > client.Settings.MaxUploadBytes:
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = fmt.Sprintf("file exceeds the client limit of %d bytes", client.Settings.MaxUploadBytes)
+	case !clientconfig.AllowsDocumentType(client, documentType):
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = fmt.Sprintf("document type %s is not enabled for the client", documentType)
+	}
+	return check
+}
+
+// applyClientPolicy adds the client policy check to an upload's results
+func applyClientPolicy(c *gin.Context, record *localModels.DocumentRecord, result *localModels.UploadResult) error {
+	client, err := clientconfig.FromContext(c)
+	if err != nil {
+		return fmt.Errorf("could not load client settings: %w", err)
+	}
+
+	result.Checks = append(result.Checks, clientPolicyCheck(client, record.FileSize, record.DocumentType.String()))
+	result.ProcessingStatus = localModels.OverallStatus(result.Checks)
+	result.DocumentRecord = *record
+	if result.ProcessingStatus == localModels.ProcessingRejected {
+		return checksFailed(*result)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientPolicyCheck(t *testing.T) {
+	client := localModels.Client{Settings: localModels.ClientSettings{
+		MaxUploadBytes:       1000,
+		AllowedDocumentTypes: []string{"passport"},
+	}}
+
+	assert.Equal(t, localModels.UploadCheckPassed, clientPolicyCheck(client, 1000, "passport").Status)
+
+	tooLarge := clientPolicyCheck(client, 1001, "passport")
+	assert.Equal(t, localModels.UploadCheckFailed, tooLarge.Status)
+	assert.Contains(t, tooLarge.Detail, "1000 bytes")
+
+	wrongType := clientPolicyCheck(client, 10, "utility_bill")
+	assert.Equal(t, localModels.UploadCheckFailed, wrongType.Status)
+
+	// Clients without settings keep the service defaults
+	assert.Equal(t, localModels.UploadCheckPassed, clientPolicyCheck(localModels.Client{}, 5<<20, "utility_bill").Status)
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+var (
+	// ErrDocumentArchived is returned when the file of an archived document is downloaded before it is restored
+	ErrDocumentArchived = apperr.Conflict("document_archived", "document is archived; restore it before downloading")
+	// ErrDocumentNotArchived is returned when a restore is asked for a document that is not archived
+	ErrDocumentNotArchived = apperr.Conflict("document_not_archived", "document is not archived")
+	// ErrColdStorageOff is returned when an archived document is restored while cold storage is not configured
+	ErrColdStorageOff = apperr.Unavailable("cold_storage_disabled", "cold storage is not configured")
+)
+
+const (
+	defaultColdStorageClass     = types.StorageClassGlacier
+	defaultRestoreDays          = 7
+	defaultRestoreTier          = types.TierStandard
+	defaultColdStorageBatchSize = 100
+)
+
+// ColdStorageClient is the part of the S3 client cold storage uses
+type ColdStorageClient interface {
+	CopyObject(ctx context.Context, input *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	RestoreObject(ctx context.Context, input *s3.RestoreObjectInput, opts ...func(*s3.Options)) (*s3.RestoreObjectOutput, error)
+	HeadObject(ctx context.Context, input *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// ColdStorage moves the files of documents that have not changed for a while to an
+// archival storage class, restores them when a client asks, and tells the client with a
+// document.restored webhook once a restored copy can be downloaded
+type ColdStorage struct {
+	Client         ColdStorageClient
+	Bucket         string
+	Webhooks       localInterfaces.WebhookService
+	Applicants     localInterfaces.AnnotationReader // Echoes applicants' tags and metadata in webhook events; nil leaves them out
+	CollectionName string
+	AfterDays      int // Documents are not archived when zero
+	StorageClass   types.StorageClass
+	RestoreDays    int32
+	RestoreTier    types.Tier
+	BatchSize      int64
+}
+
+// NewColdStorage creates cold storage for the documents bucket, applying defaults for unset
+// settings. It fails on a storage class or restore tier S3 would refuse.
+func NewColdStorage(settings config.ColdStorageSettings, bucket string, client ColdStorageClient, webhooks localInterfaces.WebhookService) (*ColdStorage, error) {
+	cs := &ColdStorage{
+		Client:         client,
+		Bucket:         bucket,
+		Webhooks:       webhooks,
+		CollectionName: localConstants.CollectionDocuments,
+		AfterDays:      settings.AfterDays,
+		StorageClass:   types.StorageClass(settings.StorageClass),
+		RestoreDays:    settings.RestoreDays,
+		RestoreTier:    types.Tier(settings.RestoreTier),
+		BatchSize:      settings.BatchSize,
+	}
+	if cs.StorageClass == "" {
+		cs.StorageClass = defaultColdStorageClass
+	}
+	if cs.RestoreDays <= 0 {
+		cs.RestoreDays = defaultRestoreDays
+	}
+	if cs.RestoreTier == "" {
+		cs.RestoreTier = defaultRestoreTier
+	}
+	if cs.BatchSize <= 0 {
+		cs.BatchSize = defaultColdStorageBatchSize
+	}
+
+	switch cs.StorageClass {
+	case types.StorageClassGlacier, types.StorageClassDeepArchive:
+	default:
+		return nil, fmt.Errorf("coldStorage.storageClass must be GLACIER or DEEP_ARCHIVE, not %q", cs.StorageClass)
+	}
+	switch cs.RestoreTier {
+	case types.TierStandard, types.TierBulk:
+	case types.TierExpedited:
+		if cs.StorageClass == types.StorageClassDeepArchive {
+			return nil, errors.New("coldStorage.restoreTier Expedited is not available for DEEP_ARCHIVE")
+		}
+	default:
+		return nil, fmt.Errorf("coldStorage.restoreTier must be Expedited, Standard or Bulk, not %q", cs.RestoreTier)
+	}
+	return cs, nil
+}
+
+// Archive makes one pass over documents that have not changed for AfterDays, moving
+// their current files to the archival storage class
+func (cs *ColdStorage) Archive(ctx context.Context) error {
+	if cs.AfterDays <= 0 {
+		return nil
+	}
+	collection := common.GetCollection(cs.CollectionName)
+	filter := bson.M{
+		"updated_at":   bson.M{"$lte": timestamp.Now().AddDate(0, 0, -cs.AfterDays)},
+		"deleted":      false,
+		"file_url":     bson.M{"$ne": localModels.PlaceholderFileURL},
+		"cold_storage": bson.M{"$exists": false},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: 1}}).SetLimit(cs.BatchSize)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("failed to find documents to archive: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	archived := 0
+	for cursor.Next(ctx) {
+		var doc localModels.DocumentRecord
+		if err := cursor.Decode(&doc); err != nil {
+			zaplogger.GetLogger().Error("Error decoding document to archive", zap.Error(err))
+			continue
+		}
+		if err := cs.archiveDocument(ctx, collection, doc); err != nil {
+			zaplogger.GetLogger().Error("Error archiving document", zap.Error(err), zap.String("documentID", doc.DocumentID))
+			continue
+		}
+		archived++
+	}
+	zaplogger.GetLogger().Info("Documents moved to cold storage", zap.Int("documents", archived), zap.String("storageClass", string(cs.StorageClass)))
+	return cursor.Err()
+}
+
+// archiveDocument copies a document's file onto itself in the archival storage class and
+// records it as archived, unless the file was replaced in the meantime
+func (cs *ColdStorage) archiveDocument(ctx context.Context, collection common.CollectionInterface, doc localModels.DocumentRecord) error {
+	key, err := getObjectKeyFromURL(doc.FileURL)
+	if err != nil {
+		return err
+	}
+	source := &url.URL{Path: cs.Bucket + "/" + key}
+	_, err = cs.Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(cs.Bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(source.EscapedPath()),
+		StorageClass:      cs.StorageClass,
+		MetadataDirective: types.MetadataDirectiveCopy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", key, cs.StorageClass, err)
+	}
+
+	state := localModels.ColdStorage{State: localModels.ColdStorageArchived, StorageClass: string(cs.StorageClass), ArchivedAt: timestamp.Now()}
+	filter := documentFilter(tenant.Of(doc.ClientID), doc.ApplicantID, doc.DocumentID).With("file_url", doc.FileURL)
+	return cs.setState(ctx, collection, filter, "archive_document", state)
+}
+
+// Restore starts restoring an archived document's file. Asking again while a restore is
+// under way, or while the restored copy is still there, changes nothing.
+func (cs *ColdStorage) Restore(ctx context.Context, collection common.CollectionInterface, doc localModels.DocumentRecord) (localModels.ColdStorage, error) {
+	if doc.ColdStorage == nil {
+		return localModels.ColdStorage{}, ErrDocumentNotArchived
+	}
+	state := *doc.ColdStorage
+	now := timestamp.Now()
+	if state.State == localModels.ColdStorageRestoring || state.Downloadable(now) {
+		return state, nil
+	}
+	if cs == nil {
+		return state, ErrColdStorageOff
+	}
+
+	key, err := getObjectKeyFromURL(doc.FileURL)
+	if err != nil {
+		return state, err
+	}
+	_, err = cs.Client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket: aws.String(cs.Bucket),
+		Key:    aws.String(key),
+		RestoreRequest: &types.RestoreRequest{
+			Days:                 aws.Int32(cs.RestoreDays),
+			GlacierJobParameters: &types.GlacierJobParameters{Tier: cs.RestoreTier},
+		},
+	})
+	if err != nil && !hasErrorCode(err, "RestoreAlreadyInProgress") {
+		return state, fmt.Errorf("failed to restore %s: %w", key, err)
+	}
+
+	state.State, state.RestoreRequestedAt, state.RestoredUntil = localModels.ColdStorageRestoring, &now, nil
+	filter := documentFilter(tenant.Of(doc.ClientID), doc.ApplicantID, doc.DocumentID).With("file_url", doc.FileURL)
+	if err := cs.setState(ctx, collection, filter, "restore_document", state); err != nil {
+		return localModels.ColdStorage{}, err
+	}
+	return state, nil
+}
+
+// CheckRestores makes one pass over documents being restored, recording those whose
+// restored copy can be downloaded, and returns documents whose copy has gone to archived
+func (cs *ColdStorage) CheckRestores(ctx context.Context) error {
+	collection := common.GetCollection(cs.CollectionName)
+
+	expired := bson.M{
+		"cold_storage.state":          localModels.ColdStorageRestored,
+		"cold_storage.restored_until": bson.M{"$lte": timestamp.Now()},
+	}
+	if _, err := collection.UpdateMany(ctx, expired, archivedAgain()); err != nil {
+		return fmt.Errorf("failed to expire restored documents: %v", err)
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"cold_storage.state": localModels.ColdStorageRestoring, "deleted": false})
+	if err != nil {
+		return fmt.Errorf("failed to find documents being restored: %v", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var doc localModels.DocumentRecord
+		if err := cursor.Decode(&doc); err != nil {
+			zaplogger.GetLogger().Error("Error decoding document being restored", zap.Error(err))
+			continue
+		}
+		if err := cs.checkRestore(ctx, collection, doc); err != nil {
+			zaplogger.GetLogger().Error("Error checking document restore", zap.Error(err), zap.String("documentID", doc.DocumentID))
+		}
+	}
+	return cursor.Err()
+}
+
+// checkRestore reads how a document's restore is going from S3, and tells the client once
+// the restored copy can be downloaded
+func (cs *ColdStorage) checkRestore(ctx context.Context, collection common.CollectionInterface, doc localModels.DocumentRecord) error {
+	key, err := getObjectKeyFromURL(doc.FileURL)
+	if err != nil {
+		return err
+	}
+	head, err := cs.Client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(cs.Bucket), Key: aws.String(key)})
+	if err != nil {
+		return fmt.Errorf("failed to read restore state of %s: %w", key, err)
+	}
+	filter := documentFilter(tenant.Of(doc.ClientID), doc.ApplicantID, doc.DocumentID).With("file_url", doc.FileURL)
+
+	ongoing, expiry, err := parseRestore(aws.ToString(head.Restore))
+	switch {
+	case err != nil:
+		// S3 knows of no restore, e.g. one asked for and expired between two checks
+		return cs.update(ctx, collection, filter, "restore_lost", archivedAgain())
+	case ongoing:
+		return nil
+	}
+
+	state := *doc.ColdStorage
+	state.State, state.RestoredUntil = localModels.ColdStorageRestored, &expiry
+	if err := cs.setState(ctx, collection, filter, "document_restored", state); err != nil {
+		return err
+	}
+	if cs.Webhooks == nil {
+		return nil
+	}
+	data := localModels.DocumentRestoredData{
+		ApplicantID:   doc.ApplicantID,
+		DocumentID:    doc.DocumentID,
+		DocumentType:  doc.DocumentType.String(),
+		RestoredUntil: expiry,
+		Annotations:   applicantAnnotations(ctx, cs.Applicants, doc.ClientID, doc.ApplicantID),
+	}
+	if err := cs.Webhooks.Emit(ctx, doc.ClientID, localModels.WebhookDocumentRestored, data); err != nil {
+		zaplogger.GetLogger().Error("Error emitting document restored webhook", zap.Error(err), zap.String("documentID", doc.DocumentID))
+	}
+	return nil
+}
+
+// archivedAgain returns a restored document to plain archived
+func archivedAgain() bson.M {
+	return bson.M{
+		"$set":   bson.M{"cold_storage.state": localModels.ColdStorageArchived},
+		"$unset": bson.M{"cold_storage.restore_requested_at": "", "cold_storage.restored_until": ""},
+	}
+}
+
+func (cs *ColdStorage) setState(ctx context.Context, collection common.CollectionInterface, filter tenant.Filter, operation string, state localModels.ColdStorage) error {
+	return cs.update(ctx, collection, filter, operation, bson.M{"$set": bson.M{"cold_storage": state}})
+}
+
+func (cs *ColdStorage) update(ctx context.Context, collection common.CollectionInterface, filter tenant.Filter, operation string, update bson.M) error {
+	if err := mongoschema.ValidateUpdate(localConstants.CollectionDocuments, update); err != nil {
+		return err
+	}
+	return mongoretry.Write(ctx, operation, func(ctx context.Context) error {
+		_, err := tenant.Guard(collection).UpdateOne(ctx, filter, update)
+		return err
+	})
+}
+
+// restoreHeader matches the x-amz-restore header, e.g.
+// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+var restoreHeader = regexp.MustCompile(`ongoing-request="(true|false)"(?:,\s*expiry-date="([^"]+)")?`)
+
+// parseRestore reads whether a restore is still under way, and when a finished one expires
+func parseRestore(header string) (ongoing bool, expiry time.Time, err error) {
+	match := restoreHeader.FindStringSubmatch(header)
+	if match == nil {
+		return false, time.Time{}, fmt.Errorf("no restore in %q", header)
+	}
+	if match[1] == "true" {
+		return true, time.Time{}, nil
+	}
+	expiry, err = http.ParseTime(match[2])
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid restore expiry in %q: %w", header, err)
+	}
+	return false, expiry.UTC(), nil
+}
+
+// hasErrorCode reports whether an AWS call failed with the given error code
+func hasErrorCode(err error, code string) bool {
+	var apiErr interface{ ErrorCode() string }
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+// RestoreDocument starts restoring the file of one of a client's archived documents
+func (s *DocumentServiceImpl) RestoreDocument(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.ColdStorage, error) {
+	doc, err := findDocumentRecord(c, collection, tenant.Of(clientID), applicantID, docID)
+	if err != nil {
+		return localModels.ColdStorage{}, err
+	}
+	return s.ColdStorage.Restore(c.Request.Context(), collection, doc)
+}
+
+// GetDocumentRestore returns the archival state of one of a client's archived documents
+func (s *DocumentServiceImpl) GetDocumentRestore(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.ColdStorage, error) {
+	doc, err := findDocumentRecord(c, collection, tenant.Of(clientID), applicantID, docID)
+	if err != nil {
+		return localModels.ColdStorage{}, err
+	}
+	if doc.ColdStorage == nil {
+		return localModels.ColdStorage{}, ErrDocumentNotArchived
+	}
+	return *doc.ColdStorage, nil
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// glacierBucket stands in for the S3 calls that archive and restore files
+type glacierBucket struct {
+	copies   []*s3.CopyObjectInput
+	restores []*s3.RestoreObjectInput
+	restore  string // x-amz-restore header returned by HeadObject
+}
+
+func (b *glacierBucket) CopyObject(ctx context.Context, input *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	b.copies = append(b.copies, input)
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (b *glacierBucket) RestoreObject(ctx context.Context, input *s3.RestoreObjectInput, opts ...func(*s3.Options)) (*s3.RestoreObjectOutput, error) {
+	b.restores = append(b.restores, input)
+	return &s3.RestoreObjectOutput{}, nil
+}
+
+func (b *glacierBucket) HeadObject(ctx context.Context, input *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	output := &s3.HeadObjectOutput{}
+	if b.restore != "" {
+		output.Restore = aws.String(b.restore)
+	}
+	return output, nil
+}
+
+func coldDocument(state localModels.ColdStorageState) localModels.DocumentRecord {
+	return localModels.DocumentRecord{
+		Document: models.Document{
+			DocumentID:   "doc1",
+			ApplicantID:  "applicant1",
+			DocumentType: models.DocumentPassport,
+			FileURL:      "https://bucket.s3.amazonaws.com/doc1.png",
+		},
+		ClientID:    "client1",
+		ColdStorage: &localModels.ColdStorage{State: state, StorageClass: "GLACIER", ArchivedAt: time.Now().AddDate(0, -1, 0)},
+	}
+}
+
+func TestNewColdStorage(t *testing.T) {
+	cs, err := NewColdStorage(config.ColdStorageSettings{}, "bucket", &glacierBucket{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, types.StorageClassGlacier, cs.StorageClass)
+	assert.Equal(t, int32(7), cs.RestoreDays)
+	assert.Equal(t, types.TierStandard, cs.RestoreTier)
+
+	_, err = NewColdStorage(config.ColdStorageSettings{StorageClass: "STANDARD_IA"}, "bucket", &glacierBucket{}, nil)
+	assert.Error(t, err)
+	_, err = NewColdStorage(config.ColdStorageSettings{StorageClass: "DEEP_ARCHIVE", RestoreTier: "Expedited"}, "bucket", &glacierBucket{}, nil)
+	assert.Error(t, err)
+}
+
+func TestArchiveDocumentMovesTheFileInPlace(t *testing.T) {
+	bucket := &glacierBucket{}
+	cs, err := NewColdStorage(config.ColdStorageSettings{AfterDays: 365, StorageClass: "DEEP_ARCHIVE"}, "bucket", bucket, nil)
+	require.NoError(t, err)
+	collection, sets := recordedUpdates()
+
+	doc := coldDocument("")
+	doc.ColdStorage = nil
+	require.NoError(t, cs.archiveDocument(context.Background(), collection, doc))
+
+	require.Len(t, bucket.copies, 1)
+	assert.Equal(t, "doc1.png", aws.ToString(bucket.copies[0].Key))
+	assert.Equal(t, "bucket/doc1.png", aws.ToString(bucket.copies[0].CopySource))
+	assert.Equal(t, types.StorageClassDeepArchive, bucket.copies[0].StorageClass)
+	require.Len(t, *sets, 1)
+	state := (*sets)[0]["cold_storage"].(localModels.ColdStorage)
+	assert.Equal(t, localModels.ColdStorageArchived, state.State)
+	assert.Equal(t, "DEEP_ARCHIVE", state.StorageClass)
+}
+
+func TestRestoreStartsOnce(t *testing.T) {
+	bucket := &glacierBucket{}
+	cs, err := NewColdStorage(config.ColdStorageSettings{RestoreDays: 3, RestoreTier: "Bulk"}, "bucket", bucket, nil)
+	require.NoError(t, err)
+	collection, sets := recordedUpdates()
+
+	state, err := cs.Restore(context.Background(), collection, coldDocument(localModels.ColdStorageArchived))
+	require.NoError(t, err)
+	assert.Equal(t, localModels.ColdStorageRestoring, state.State)
+	assert.NotNil(t, state.RestoreRequestedAt)
+	require.Len(t, bucket.restores, 1)
+	assert.Equal(t, int32(3), aws.ToInt32(bucket.restores[0].RestoreRequest.Days))
+	assert.Equal(t, types.TierBulk, bucket.restores[0].RestoreRequest.GlacierJobParameters.Tier)
+	assert.Len(t, *sets, 1)
+
+	// Asking again while the restore is under way changes nothing
+	_, err = cs.Restore(context.Background(), collection, coldDocument(localModels.ColdStorageRestoring))
+	require.NoError(t, err)
+	assert.Len(t, bucket.restores, 1)
+	assert.Len(t, *sets, 1)
+}
+
+func TestRestoreOfDocumentNotArchived(t *testing.T) {
+	cs, err := NewColdStorage(config.ColdStorageSettings{}, "bucket", &glacierBucket{}, nil)
+	require.NoError(t, err)
+	doc := coldDocument("")
+	doc.ColdStorage = nil
+
+	_, err = cs.Restore(context.Background(), nil, doc)
+	assert.ErrorIs(t, err, ErrDocumentNotArchived)
+
+	// Without cold storage, archived documents cannot be restored
+	var off *ColdStorage
+	_, err = off.Restore(context.Background(), nil, coldDocument(localModels.ColdStorageArchived))
+	assert.ErrorIs(t, err, ErrColdStorageOff)
+}
+
+func TestCheckRestoreTellsTheClient(t *testing.T) {
+	bucket := &glacierBucket{restore: `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`}
+	webhooks := new(localMocks.MockWebhookService)
+	cs, err := NewColdStorage(config.ColdStorageSettings{}, "bucket", bucket, webhooks)
+	require.NoError(t, err)
+	collection, sets := recordedUpdates()
+
+	expiry := time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)
+	webhooks.On("Emit", mock.Anything, "client1", localModels.WebhookDocumentRestored, mock.MatchedBy(func(data localModels.DocumentRestoredData) bool {
+		return data.DocumentID == "doc1" && data.ApplicantID == "applicant1" && data.RestoredUntil.Equal(expiry)
+	})).Return(nil)
+
+	require.NoError(t, cs.checkRestore(context.Background(), collection, coldDocument(localModels.ColdStorageRestoring)))
+	require.Len(t, *sets, 1)
+	state := (*sets)[0]["cold_storage"].(localModels.ColdStorage)
+	assert.Equal(t, localModels.ColdStorageRestored, state.State)
+	assert.True(t, state.RestoredUntil.Equal(expiry))
+	webhooks.AssertExpectations(t)
+}
+
+func TestCheckRestoreStillUnderWay(t *testing.T) {
+	bucket := &glacierBucket{restore: `ongoing-request="true"`}
+	webhooks := new(localMocks.MockWebhookService)
+	cs, err := NewColdStorage(config.ColdStorageSettings{}, "bucket", bucket, webhooks)
+	require.NoError(t, err)
+	collection, sets := recordedUpdates()
+
+	require.NoError(t, cs.checkRestore(context.Background(), collection, coldDocument(localModels.ColdStorageRestoring)))
+	assert.Empty(t, *sets)
+	webhooks.AssertNotCalled(t, "Emit", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestColdStorageDownloadable(t *testing.T) {
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+	var never *localModels.ColdStorage
+	assert.True(t, never.Downloadable(now))
+	assert.False(t, (&localModels.ColdStorage{State: localModels.ColdStorageArchived}).Downloadable(now))
+	assert.False(t, (&localModels.ColdStorage{State: localModels.ColdStorageRestoring}).Downloadable(now))
+	assert.True(t, (&localModels.ColdStorage{State: localModels.ColdStorageRestored, RestoredUntil: &later}).Downloadable(now))
+	assert.False(t, (&localModels.ColdStorage{State: localModels.ColdStorageRestored, RestoredUntil: &earlier}).Downloadable(now))
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"mime/multipart"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	mocks "github.com/rachel-lawrie/verus_backend_core/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// The document service keeps no state between requests, so concurrent uploads must not
+// share anything but the collection. Run with -race to check:
+//
+//	go test -race -run Concurrent ./internal/document/services/
+func TestConcurrentUploadsShareNoState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const uploads = 16
+
+	stagingDir := t.TempDir()
+	service := &DocumentServiceImpl{Uploader: sealingUploader{}, KMSUploader: benchmarkKMS{}, StagingDir: stagingDir}
+	collection := new(mocks.MockCollection)
+	collection.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	body, contentType := uploadFormBody(t, pngFile(t, 64<<10))
+
+	contexts := make([]*gin.Context, uploads)
+	files := make([]multipart.File, uploads)
+	headers := make([]*multipart.FileHeader, uploads)
+	for i := range contexts {
+		contexts[i], files[i], headers[i] = parsedUpload(t, body, contentType)
+		defer files[i].Close()
+	}
+
+	var wg sync.WaitGroup
+	records := make([]localModels.DocumentRecord, uploads)
+	errs := make([]error, uploads)
+	stored := make([]bool, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// The steps UploadDocument takes once the applicant is found
+			c, file, header := contexts[i], files[i], headers[i]
+			record := localModels.DocumentRecord{Document: createDocumentObject("applicant1", "passport", "GB"), ClientID: "client1"}
+			if _, errs[i] = checkDocumentFile(file, header.Size, "image/png", "GB", "", &record); errs[i] != nil {
+				return
+			}
+			service.stageUpload(&record, file, record.DocumentID+".png", "image/png")
+			if errs[i] = saveDocumentRecord(c.Request.Context(), "applicant1", record, collection); errs[i] != nil {
+				return
+			}
+			stored[i] = service.storeUpload(c, collection, "applicant1", &record, file)
+			records[i] = record
+		}(i)
+	}
+	wg.Wait()
+
+	ids := make(map[string]bool)
+	for i := range records {
+		assert.NoError(t, errs[i])
+		assert.True(t, stored[i])
+		assert.Equal(t, localModels.UploadStored, records[i].Upload.State)
+		ids[records[i].DocumentID] = true
+	}
+	assert.Len(t, ids, uploads, "every upload gets its own document")
+	// One insert and one update to the stored file URL per upload
+	collection.AssertNumberOfCalls(t, "UpdateOne", 2*uploads)
+
+	staged, err := os.ReadDir(stagingDir)
+	assert.NoError(t, err)
+	assert.Empty(t, staged, "staged copies are removed once stored")
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/consent"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// applyConsentCheck refuses documents of applicants without a consent record when the
+// client requires one, before a vendor is chosen to verify them
+func applyConsentCheck(c *gin.Context, applicant documentApplicant, record *localModels.DocumentRecord, result *localModels.UploadResult) error {
+	client, err := clientconfig.FromContext(c)
+	if err != nil {
+		return fmt.Errorf("could not load client settings: %w", err)
+	}
+	if !client.Settings.RequireConsent {
+		return nil
+	}
+
+	result.Checks = append(result.Checks, consent.Check(applicant.Consent))
+	result.ProcessingStatus = localModels.OverallStatus(result.Checks)
+	result.DocumentRecord = *record
+	if result.ProcessingStatus == localModels.ProcessingRejected {
+		return checksFailed(*result)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyConsentCheck(t *testing.T) {
+	clients := clientLoader{"strict": {ClientID: "strict", Settings: localModels.ClientSettings{RequireConsent: true}}}
+	consent := &localModels.Consent{TextVersion: "2026-09", Channel: localModels.ConsentWeb}
+
+	tests := []struct {
+		name       string
+		clientID   string
+		consent    *localModels.Consent
+		wantChecks int
+		wantErr    bool
+	}{
+		{"Consent not required", "relaxed", nil, 0, false},
+		{"Consent given", "strict", consent, 1, false},
+		{"Consent missing", "strict", nil, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/documents", nil)
+			c.Set("client_id", tt.clientID)
+			clientconfig.Middleware(clients)(c)
+
+			var result localModels.UploadResult
+			record := localModels.DocumentRecord{}
+			err := applyConsentCheck(c, documentApplicant{ClientID: tt.clientID, Consent: tt.consent}, &record, &result)
+			assert.Len(t, result.Checks, tt.wantChecks)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, localModels.ProcessingRejected, result.ProcessingStatus)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
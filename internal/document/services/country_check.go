@@ -0,0 +1,70 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/country"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mrz"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+)
+
+// checkCountry compares the country claimed at upload with the issuing country
+// read from the document's machine readable zone, when one was supplied
+func checkCountry(claimed, mrzText string) (localModels.UploadCheck, *localModels.CountryCheck) {
+	check := localModels.UploadCheck{Name: "country", Status: localModels.UploadCheckPassed}
+	result := &localModels.CountryCheck{Claimed: claimed, Status: localModels.CountryUndetermined}
+
+	claimedCode, ok := country.Normalize(claimed)
+	if !ok {
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = fmt.Sprintf("unrecognised country code: %s", claimed)
+		return check, nil
+	}
+	result.Claimed = claimedCode
+
+	if mrzText == "" {
+		check.Detail = "no MRZ supplied; issuing country not verified"
+		return check, result
+	}
+
+	parsed, err := mrz.Parse(mrzText)
+	if err != nil {
+		check.Status = localModels.UploadCheckFlagged
+		check.Detail = "issuing country could not be read from MRZ"
+		result.Source = "mrz"
+		result.Reason = &localModels.CheckReason{Code: "mrz_unreadable", Message: err.Error()}
+		return check, result
+	}
+
+	detected, ok := country.Normalize(parsed.IssuingCountry)
+	result.Source = "mrz"
+	result.Detected = parsed.IssuingCountry
+	if !ok {
+		check.Status = localModels.UploadCheckFlagged
+		check.Detail = "MRZ issuing country is not a recognised code"
+		result.Reason = &localModels.CheckReason{Code: "mrz_country_unknown", Message: fmt.Sprintf("unrecognised MRZ issuing state %q", parsed.IssuingCountry)}
+		return check, result
+	}
+	result.Detected = detected
+
+	if detected != claimedCode {
+		check.Status = localModels.UploadCheckFlagged
+		check.Detail = fmt.Sprintf("claimed %s but document was issued by %s", claimedCode, detected)
+		result.Status = localModels.CountryMismatch
+		result.Reason = &localModels.CheckReason{Code: "country_mismatch", Message: check.Detail}
+		return check, result
+	}
+
+	result.Status = localModels.CountryMatch
+	return check, result
+}
+
+// countryMismatchFlag builds the document flag raised for a country mismatch
+func countryMismatchFlag(result *localModels.CountryCheck) localModels.DocumentFlag {
+	return localModels.DocumentFlag{
+		Code:     result.Reason.Code,
+		Message:  result.Reason.Message,
+		RaisedAt: timestamp.Now(),
+	}
+}
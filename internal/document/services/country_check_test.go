@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCountry(t *testing.T) {
+	// ICAO specimen passport re-issued by Sweden so the check digits stay valid
+	swedishMRZ := "P<SWEERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204159ZE184226B<<<<<10"
+
+	tests := []struct {
+		name        string
+		claimed     string
+		mrz         string
+		checkStatus localModels.UploadCheckStatus
+		wantResult  *localModels.CountryCheckStatus
+	}{
+		{"Unknown claimed country", "Narnia", "", localModels.UploadCheckFailed, nil},
+		{"No MRZ", "SE", "", localModels.UploadCheckPassed, statusPtr(localModels.CountryUndetermined)},
+		{"Matching alpha-2 claim", "SE", swedishMRZ, localModels.UploadCheckPassed, statusPtr(localModels.CountryMatch)},
+		{"Matching alpha-3 claim", "swe", swedishMRZ, localModels.UploadCheckPassed, statusPtr(localModels.CountryMatch)},
+		{"Mismatch", "US", swedishMRZ, localModels.UploadCheckFlagged, statusPtr(localModels.CountryMismatch)},
+		{"Unreadable MRZ", "SE", "not an mrz", localModels.UploadCheckFlagged, statusPtr(localModels.CountryUndetermined)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, result := checkCountry(tt.claimed, tt.mrz)
+			assert.Equal(t, tt.checkStatus, check.Status)
+			if tt.wantResult == nil {
+				assert.Nil(t, result)
+				return
+			}
+			assert.Equal(t, *tt.wantResult, result.Status)
+			if result.Status == localModels.CountryMismatch {
+				assert.Equal(t, "country_mismatch", result.Reason.Code)
+				assert.Equal(t, "SWE", result.Detected)
+				assert.Equal(t, "USA", result.Claimed)
+			}
+		})
+	}
+}
+
+func statusPtr(s localModels.CountryCheckStatus) *localModels.CountryCheckStatus {
+	return &s
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/quarantine"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+var (
+	// ErrDirectUploadsOff is returned when a presigned upload is asked for while direct uploads are not configured
+	ErrDirectUploadsOff = apperr.NotImplemented("direct_uploads_disabled", "direct uploads are not enabled")
+	// ErrInvalidDirectUpload is returned when a presigned upload is asked for a file that would be refused
+	ErrInvalidDirectUpload = apperr.BadRequest("invalid_direct_upload", "invalid direct upload")
+	// ErrInvalidUploadToken is returned for an upload token that was edited, expired or issued to another client
+	ErrInvalidUploadToken = apperr.BadRequest("invalid_upload_token", "upload token is invalid or has expired")
+	// ErrDirectUploadMissing is returned when an upload is completed before its file reached S3
+	ErrDirectUploadMissing = apperr.Conflict("file_not_uploaded", "file has not been uploaded")
+	// ErrUploadCompleted is returned when an upload is completed a second time
+	ErrUploadCompleted = apperr.Conflict("upload_completed", "upload was already completed")
+)
+
+const (
+	defaultDirectUploadTTL      = 15 * time.Minute
+	defaultDirectUploadMaxBytes = 100 << 20
+	// uploadTokenPrefix marks upload tokens, so they are not mistaken for session tokens or cursors
+	uploadTokenPrefix = "vu_"
+	// completeGrace is how long after its URL expires an upload can still be completed, for a
+	// PUT that started just before the URL expired
+	completeGrace = time.Hour
+	// sniffBytes is how much of an uploaded file is read back to check its content type
+	sniffBytes = 512
+)
+
+// UploadPresigner is the part of the S3 presign client direct uploads use
+type UploadPresigner interface {
+	PresignPutObject(ctx context.Context, input *s3.PutObjectInput, opts ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// DirectUploadClient is the part of the S3 client that checks, scans and cleans up directly uploaded files
+type DirectUploadClient interface {
+	HeadObject(ctx context.Context, input *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, input *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// DirectUploads hands out presigned S3 uploads for files too large to send through the
+// API, and checks each file once it is uploaded. What the client asked to upload is
+// carried in a signed upload token rather than stored, so nothing is saved until the
+// upload is completed. Files are uploaded under the quarantine prefix and only leave it
+// once they pass a malware scan; Cleanup removes those that are never completed.
+type DirectUploads struct {
+	Presigner      UploadPresigner
+	Client         DirectUploadClient
+	Quarantine     *quarantine.Store
+	Webhooks       localInterfaces.WebhookService   // Tells clients of files that fail their scan
+	Jobs           localInterfaces.UploadJobStore   // Completes the progress of scanned uploads; nil tracks nothing
+	Applicants     localInterfaces.AnnotationReader // Echoes applicants' tags and metadata in webhook events; nil leaves them out
+	Trigger        func(ctx context.Context)        // Starts a scan of newly completed uploads; nil leaves them to the next scheduled scan
+	CollectionName string
+	Bucket         string
+	Region         string
+	KMSKeyID       string // S3 encrypts uploaded files with this key; the bucket's default encryption applies when empty
+	TTL            time.Duration
+	MaxBytes       int64
+	secret         []byte
+}
+
+// NewDirectUploads creates direct uploads to the documents bucket through its quarantine,
+// or returns nil when no signing secret is configured
+func NewDirectUploads(settings config.DirectUploadSettings, bucket, region, kmsKeyID string, store *quarantine.Store, presigner UploadPresigner, client DirectUploadClient) *DirectUploads {
+	if settings.SigningSecret == "" {
+		return nil
+	}
+	d := &DirectUploads{
+		Presigner:      presigner,
+		Client:         client,
+		Quarantine:     store,
+		CollectionName: localConstants.CollectionDocuments,
+		Bucket:         bucket,
+		Region:         region,
+		KMSKeyID:       kmsKeyID,
+		TTL:            settings.URLTTL,
+		MaxBytes:       settings.MaxBytes,
+		secret:         []byte(settings.SigningSecret),
+	}
+	if d.TTL <= 0 {
+		d.TTL = defaultDirectUploadTTL
+	}
+	if d.MaxBytes <= 0 {
+		d.MaxBytes = defaultDirectUploadMaxBytes
+	}
+	return d
+}
+
+// directUpload is what a client asked to upload, carried in the upload token
+type directUpload struct {
+	ClientID     string `json:"c"`
+	ApplicantID  string `json:"a"`
+	DocumentID   string `json:"d"`
+	DocumentType string `json:"t"`
+	Country      string `json:"n"`
+	MimeType     string `json:"m"`
+	Size         int64  `json:"s"`
+	Checksum     string `json:"h"`
+	FileName     string `json:"f"`
+	ExpiresAt    int64  `json:"e"` // Unix time after which the upload can no longer be completed
+}
+
+// PresignUpload checks that a file could be uploaded for one of the client's applicants,
+// and returns a presigned URL to upload it straight to S3 with
+func (s *DocumentServiceImpl) PresignUpload(c *gin.Context, clientID string, request localModels.DirectUploadRequest) (localModels.DirectUpload, error) {
+	if s.Direct == nil || s.Quarantine == nil {
+		return localModels.DirectUpload{}, ErrDirectUploadsOff
+	}
+	ext, ok := mimeTypeToExtension[request.MimeType]
+	if !ok {
+		return localModels.DirectUpload{}, fmt.Errorf("%w: mime_type must be application/pdf, image/jpeg or image/png", ErrInvalidDirectUpload)
+	}
+	documentType, err := models.ParseDocumentType(request.DocumentType)
+	if err != nil {
+		return localModels.DirectUpload{}, fmt.Errorf("%w: unknown document_type %s", ErrInvalidDirectUpload, request.DocumentType)
+	}
+	if request.FileSize <= 0 || request.FileSize > s.Direct.MaxBytes {
+		return localModels.DirectUpload{}, fmt.Errorf("%w: file_size must be between 1 and %d bytes", ErrInvalidDirectUpload, s.Direct.MaxBytes)
+	}
+	if digest, err := base64.StdEncoding.DecodeString(request.ChecksumSHA256); err != nil || len(digest) != sha256.Size {
+		return localModels.DirectUpload{}, fmt.Errorf("%w: checksum_sha256 must be the base64 SHA-256 digest of the file", ErrInvalidDirectUpload)
+	}
+
+	applicant, err := s.ownedApplicant(c.Request.Context(), clientID, request.ApplicantID)
+	if err != nil {
+		return localModels.DirectUpload{}, err
+	}
+	// Checked again on completion, as other uploads may complete first
+	if err := s.checkApplicantLimits(c.Request.Context(), common.GetCollection(s.CollectionName), applicant, request.ApplicantID, 1, request.FileSize); err != nil {
+		return localModels.DirectUpload{}, err
+	}
+	// Refuse files the client's policy would reject before they are uploaded
+	client, err := clientconfig.FromContext(c)
+	if err != nil {
+		return localModels.DirectUpload{}, fmt.Errorf("could not load client settings: %w", err)
+	}
+	if check := clientPolicyCheck(client, request.FileSize, documentType.String()); check.Status == localModels.UploadCheckFailed {
+		return localModels.DirectUpload{}, fmt.Errorf("%w: %s", ErrInvalidDirectUpload, check.Detail)
+	}
+
+	documentID := ids.Document.New()
+	upload := directUpload{
+		ClientID:     clientID,
+		ApplicantID:  request.ApplicantID,
+		DocumentID:   documentID,
+		DocumentType: request.DocumentType,
+		Country:      request.Country,
+		MimeType:     request.MimeType,
+		Size:         request.FileSize,
+		Checksum:     request.ChecksumSHA256,
+		FileName:     documentID + ext,
+	}
+	return s.Direct.presign(c.Request.Context(), s.Quarantine.Key(upload.FileName), upload)
+}
+
+// presign signs a PUT of the file to key that S3 only accepts with the declared type, size and checksum
+func (d *DirectUploads) presign(ctx context.Context, key string, upload directUpload) (localModels.DirectUpload, error) {
+	input := &s3.PutObjectInput{
+		Bucket:         aws.String(d.Bucket),
+		Key:            aws.String(key),
+		ContentType:    aws.String(upload.MimeType),
+		ContentLength:  aws.Int64(upload.Size),
+		ChecksumSHA256: aws.String(upload.Checksum),
+	}
+	if d.KMSKeyID != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(d.KMSKeyID)
+	}
+	expiresAt := timestamp.Now().Add(d.TTL)
+	presigned, err := d.Presigner.PresignPutObject(ctx, input, s3.WithPresignExpires(d.TTL))
+	if err != nil {
+		return localModels.DirectUpload{}, fmt.Errorf("failed to presign upload: %w", err)
+	}
+
+	headers := make(map[string]string, len(presigned.SignedHeader))
+	for name, values := range presigned.SignedHeader {
+		// Clients set the host from the URL
+		if strings.EqualFold(name, "Host") {
+			continue
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+	upload.ExpiresAt = expiresAt.Add(completeGrace).Unix()
+	return localModels.DirectUpload{
+		DocumentID:  upload.DocumentID,
+		UploadToken: d.sign(upload),
+		Method:      presigned.Method,
+		URL:         presigned.URL,
+		Headers:     headers,
+		ExpiresAt:   expiresAt.UTC(),
+	}, nil
+}
+
+// CompleteUpload checks a file uploaded through a presigned URL and registers its document,
+// as a form upload of the file would have. A rejected file is removed from quarantine; an
+// accepted one stays there until its scan passes.
+func (s *DocumentServiceImpl) CompleteUpload(c *gin.Context, clientID, applicantID string, completion localModels.DirectUploadCompletion, collection common.CollectionInterface) (localModels.UploadResult, error) {
+	if s.Direct == nil || s.Quarantine == nil {
+		return localModels.UploadResult{}, ErrDirectUploadsOff
+	}
+	ctx := c.Request.Context()
+	upload, err := s.Direct.parse(completion.UploadToken, timestamp.Now())
+	if err != nil || upload.ClientID != clientID || (applicantID != "" && applicantID != upload.ApplicantID) {
+		return localModels.UploadResult{}, ErrInvalidUploadToken
+	}
+	if _, err := findDocumentRecord(c, collection, tenant.Of(clientID), upload.ApplicantID, upload.DocumentID); err == nil {
+		return localModels.UploadResult{}, ErrUploadCompleted
+	} else if !errors.Is(err, ErrDocumentNotFound) {
+		return localModels.UploadResult{}, err
+	}
+	// The applicant may have been deleted since the URL was handed out
+	applicant, err := s.ownedApplicant(ctx, clientID, upload.ApplicantID)
+	if err != nil {
+		return localModels.UploadResult{}, err
+	}
+	// The file is left in quarantine, so the upload can be completed once the applicant has room
+	if err := s.checkApplicantLimits(ctx, collection, applicant, upload.ApplicantID, 1, upload.Size); err != nil {
+		return localModels.UploadResult{}, err
+	}
+
+	doc := createDocumentObject(upload.ApplicantID, upload.DocumentType, upload.Country)
+	doc.DocumentID = upload.DocumentID
+	doc.FileSize = upload.Size
+	record := localModels.DocumentRecord{Document: doc, ClientID: clientID}
+	tracker := s.trackUpload(ctx, record)
+
+	key := s.Quarantine.Key(upload.FileName)
+	fileURL := s.Direct.fileURL(key)
+	checks, err := s.Direct.checkObject(ctx, key, upload)
+	if err != nil {
+		tracker.fail(ctx, "", err)
+		return localModels.UploadResult{}, err
+	}
+	result, err := checkedResult(checks, upload.Country, completion.MRZ, &record)
+	if err == nil {
+		err = s.checkSubmission(c, applicant, &record, &result)
+	}
+	if err != nil {
+		tracker.fail(ctx, result.ProcessingStatus, err)
+		if result.ProcessingStatus == localModels.ProcessingRejected {
+			if err := s.Quarantine.Release(ctx, fileURL); err != nil {
+				log.Printf("Error removing rejected direct upload of document %s: %v", record.DocumentID, err)
+			}
+		}
+		return result, err
+	}
+
+	// The record is saved pointing at the quarantined file, which the scan moves to its
+	// permanent key once it passes
+	record.FileURL = localModels.PlaceholderFileURL
+	record.Upload = &localModels.StorageUpload{
+		State:         localModels.UploadScanning,
+		FileName:      upload.FileName,
+		MimeType:      upload.MimeType,
+		QuarantineURL: fileURL,
+		JobID:         tracker.jobID(),
+		Checksum:      upload.Checksum,
+	}
+	if err := saveDocumentRecord(ctx, upload.ApplicantID, record, collection); err != nil {
+		tracker.fail(ctx, result.ProcessingStatus, err)
+		return localModels.UploadResult{}, fmt.Errorf("could not create document: %w", err)
+	}
+	tracker.advance(ctx, localModels.UploadJobChecking, result.ProcessingStatus)
+	s.Direct.triggerScan(ctx)
+
+	result.DocumentRecord = record
+	result.JobID = tracker.jobID()
+
+	// Not in a transaction, so failures are logged rather than returned
+	_ = s.recordOutcome(ctx, upload.ApplicantID, record)
+	return result, nil
+}
+
+// checkObject checks an uploaded file against what the client declared, reading back only
+// its first bytes. The quick scan is left to Scan, which reads the whole file.
+func (d *DirectUploads) checkObject(ctx context.Context, key string, upload directUpload) ([]localModels.UploadCheck, error) {
+	head, err := d.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(d.Bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if hasErrorCode(err, "NotFound") || hasErrorCode(err, "NoSuchKey") {
+		return nil, ErrDirectUploadMissing
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	checks := []localModels.UploadCheck{
+		checkUploadedSize(aws.ToInt64(head.ContentLength), upload.Size, d.MaxBytes),
+		checkChecksum(aws.ToString(head.ChecksumSHA256), upload.Checksum),
+	}
+
+	object, err := d.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(d.Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", sniffBytes-1)),
+	})
+	if err != nil {
+		return checks, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	defer object.Body.Close()
+	first, err := io.ReadAll(io.LimitReader(object.Body, sniffBytes))
+	if err != nil {
+		return checks, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	return append(checks, checkMIME(first, upload.MimeType), localModels.UploadCheck{
+		Name:   "quick_scan",
+		Status: localModels.UploadCheckSkipped,
+		Detail: "file deferred to asynchronous scan",
+	}), nil
+}
+
+// checkUploadedSize verifies the uploaded file has the declared size and is within the limit
+func checkUploadedSize(size, declared, limit int64) localModels.UploadCheck {
+	check := localModels.UploadCheck{Name: "size", Status: localModels.UploadCheckPassed}
+	switch {
+	case size <= 0:
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = "file is empty"
+	case size != declared:
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = fmt.Sprintf("uploaded %d bytes but declared %d", size, declared)
+	case size > limit:
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = fmt.Sprintf("file exceeds %d bytes", limit)
+	}
+	return check
+}
+
+// checkChecksum verifies S3 stored the file under the declared SHA-256 digest, which it
+// checked the uploaded bytes against
+func checkChecksum(stored, declared string) localModels.UploadCheck {
+	check := localModels.UploadCheck{Name: "checksum", Status: localModels.UploadCheckPassed}
+	if stored != declared {
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = "file does not match the declared checksum"
+	}
+	return check
+}
+
+// fileURL is the URL of an object in the documents bucket, in the form the uploader returns
+func (d *DirectUploads) fileURL(key string) string {
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", d.Bucket, d.Region, key)
+}
+
+// sign returns an upload token carrying the upload, signed so none of it can be changed
+func (d *DirectUploads) sign(upload directUpload) string {
+	payload, _ := json.Marshal(upload)
+	unsigned := base64.RawURLEncoding.EncodeToString(payload)
+	return uploadTokenPrefix + unsigned + "." + base64.RawURLEncoding.EncodeToString(d.mac(unsigned))
+}
+
+// parse checks an upload token's signature and expiry and returns its upload
+func (d *DirectUploads) parse(token string, now time.Time) (directUpload, error) {
+	unsigned, signed, ok := strings.Cut(strings.TrimPrefix(token, uploadTokenPrefix), ".")
+	if !strings.HasPrefix(token, uploadTokenPrefix) || !ok {
+		return directUpload{}, ErrInvalidUploadToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(signed)
+	if err != nil || !hmac.Equal(signature, d.mac(unsigned)) {
+		return directUpload{}, ErrInvalidUploadToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(unsigned)
+	if err != nil {
+		return directUpload{}, ErrInvalidUploadToken
+	}
+	var upload directUpload
+	if err := json.Unmarshal(payload, &upload); err != nil || now.Unix() >= upload.ExpiresAt {
+		return directUpload{}, ErrInvalidUploadToken
+	}
+	return upload, nil
+}
+
+func (d *DirectUploads) mac(s string) []byte {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	zap "go.uber.org/zap"
+)
+
+// scanWindow is how much of a file the scan holds at a time
+const scanWindow = 1 << 20
+
+// triggerScan starts a scan of newly completed uploads. A scan already under way may have
+// missed them, in which case the next scheduled one picks them up.
+func (d *DirectUploads) triggerScan(ctx context.Context) {
+	if d.Trigger != nil {
+		d.Trigger(context.WithoutCancel(ctx))
+	}
+}
+
+// Scan runs the malware scan and checksum of every completed direct upload still in
+// quarantine, moving the files that pass to their permanent key and rejecting the others
+func (d *DirectUploads) Scan(ctx context.Context) error {
+	collection := common.GetCollection(d.CollectionName)
+
+	cursor, err := collection.Find(ctx, bson.M{"upload.state": localModels.UploadScanning, "deleted": false})
+	if err != nil {
+		return fmt.Errorf("failed to find uploads waiting for a scan: %v", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var doc localModels.DocumentRecord
+		if err := cursor.Decode(&doc); err != nil {
+			zaplogger.GetLogger().Error("Error decoding document waiting for a scan", zap.Error(err))
+			continue
+		}
+		if err := d.scanDocument(ctx, collection, doc); err != nil {
+			zaplogger.GetLogger().Error("Error scanning direct upload", zap.Error(err), zap.String("documentID", doc.DocumentID))
+		}
+	}
+	return cursor.Err()
+}
+
+// scanDocument reads one quarantined file through the quick scan and its SHA-256. An error
+// leaves the document to be scanned again by the next run.
+func (d *DirectUploads) scanDocument(ctx context.Context, collection common.CollectionInterface, doc localModels.DocumentRecord) error {
+	key, err := getObjectKeyFromURL(doc.Upload.QuarantineURL)
+	if err != nil {
+		return err
+	}
+	object, err := d.Client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(d.Bucket), Key: aws.String(key)})
+	if hasErrorCode(err, "NoSuchKey") {
+		d.reject(ctx, collection, doc, "the uploaded file expired from quarantine before it was scanned")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	check, checksum, err := scanStream(object.Body, doc.Upload.MimeType)
+	object.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", key, err)
+	}
+
+	switch {
+	case check.Status == localModels.UploadCheckFailed:
+		d.reject(ctx, collection, doc, "file failed its malware scan: "+check.Detail)
+		return nil
+	case checksum != doc.Upload.Checksum:
+		d.reject(ctx, collection, doc, "file does not match the declared checksum")
+		return nil
+	}
+
+	if _, err := commitUpload(ctx, d.Quarantine, collection, tenant.Of(doc.ClientID), doc.ApplicantID, doc.DocumentID, doc.Upload.QuarantineURL); err != nil {
+		// The document is now quarantined, so the UploadReconciler finishes moving it
+		return fmt.Errorf("failed to move scanned file out of quarantine: %w", err)
+	}
+	completeUploadJob(ctx, d.Jobs, doc, localModels.UploadJobCompleted, "")
+	return nil
+}
+
+// reject removes a file that failed its scan from quarantine and asks the client to upload it again
+func (d *DirectUploads) reject(ctx context.Context, collection common.CollectionInterface, doc localModels.DocumentRecord, reason string) {
+	if err := d.Quarantine.Release(ctx, doc.Upload.QuarantineURL); err != nil {
+		zaplogger.GetLogger().Error("Error removing rejected direct upload", zap.Error(err), zap.String("documentID", doc.DocumentID))
+	}
+	failUpload(ctx, collection, d.Jobs, d.Webhooks, d.Applicants, doc.ApplicantID, doc.ClientID, doc, reason)
+}
+
+// scanStream runs the quick scan over a file of any size a window at a time, and returns
+// the file's base64 SHA-256. Each window starts with the end of the last one, so a marker
+// split between reads is still found.
+func scanStream(r io.Reader, declaredMIME string) (localModels.UploadCheck, string, error) {
+	overlap := len(eicarSignature)
+	for _, pattern := range suspiciousPatterns {
+		overlap = max(overlap, len(pattern))
+	}
+	overlap--
+
+	digest := sha256.New()
+	window := make([]byte, 0, scanWindow+overlap)
+	check := localModels.UploadCheck{Name: "quick_scan", Status: localModels.UploadCheckPassed}
+	for {
+		n, err := io.ReadFull(r, window[len(window):cap(window)])
+		if n > 0 {
+			digest.Write(window[len(window) : len(window)+n])
+			window = window[:len(window)+n]
+			if check = quickScan(window, declaredMIME); check.Status == localModels.UploadCheckFailed {
+				return check, "", nil
+			}
+			if len(window) > overlap {
+				window = window[:copy(window, window[len(window)-overlap:])]
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return check, "", err
+		}
+	}
+	return check, base64.StdEncoding.EncodeToString(digest.Sum(nil)), nil
+}
+
+// Cleanup removes files uploaded through presigned URLs that were never completed, once
+// their upload token has expired. Quarantined files of saved documents are left alone.
+func (d *DirectUploads) Cleanup(ctx context.Context) error {
+	collection := common.GetCollection(d.CollectionName)
+	completed := func(ctx context.Context, documentID string) (bool, error) {
+		err := collection.FindOne(ctx, bson.M{"document_id": documentID}).Err()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	cutoff := timestamp.Now().Add(-(d.TTL + completeGrace))
+	paginator := s3.NewListObjectsV2Paginator(d.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(d.Bucket),
+		Prefix: aws.String(d.Quarantine.Key("")),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list quarantined files: %w", err)
+		}
+		if err := d.removeAbandoned(ctx, page.Contents, cutoff, completed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeAbandoned removes the objects uploaded before cutoff whose document was never saved
+func (d *DirectUploads) removeAbandoned(ctx context.Context, objects []types.Object, cutoff time.Time, completed func(ctx context.Context, documentID string) (bool, error)) error {
+	for _, object := range objects {
+		if aws.ToTime(object.LastModified).After(cutoff) {
+			continue
+		}
+		key := aws.ToString(object.Key)
+		documentID := strings.TrimSuffix(path.Base(key), path.Ext(key))
+		saved, err := completed(ctx, documentID)
+		if err != nil {
+			return fmt.Errorf("failed to look up document %s: %w", documentID, err)
+		}
+		if saved {
+			continue
+		}
+		if err := d.Quarantine.Release(ctx, d.fileURL(key)); err != nil {
+			return err
+		}
+		zaplogger.GetLogger().Info("Removed direct upload that was never completed", zap.String("key", key))
+	}
+	return nil
+}
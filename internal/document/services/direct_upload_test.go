@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/quarantine"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadBucket stands in for the S3 calls that presign and check direct uploads
+type uploadBucket struct {
+	presigned []*s3.PutObjectInput
+	content   []byte // nil when nothing was uploaded
+	checksum  string // checksum S3 stored the upload under
+}
+
+func (b *uploadBucket) PresignPutObject(ctx context.Context, input *s3.PutObjectInput, opts ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	b.presigned = append(b.presigned, input)
+	return &v4.PresignedHTTPRequest{
+		URL:    "https://bucket.s3.eu-west-2.amazonaws.com/" + aws.ToString(input.Key) + "?X-Amz-Signature=abc",
+		Method: http.MethodPut,
+		SignedHeader: http.Header{
+			"Host":                  []string{"bucket.s3.eu-west-2.amazonaws.com"},
+			"Content-Type":          []string{aws.ToString(input.ContentType)},
+			"X-Amz-Checksum-Sha256": []string{aws.ToString(input.ChecksumSHA256)},
+		},
+	}, nil
+}
+
+func (b *uploadBucket) HeadObject(ctx context.Context, input *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if b.content == nil {
+		return nil, apiError("NotFound")
+	}
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(b.content))), ChecksumSHA256: aws.String(b.checksum)}, nil
+}
+
+// apiError is an error S3 answers with
+type apiError string
+
+func (e apiError) Error() string     { return string(e) }
+func (e apiError) ErrorCode() string { return string(e) }
+
+func (b *uploadBucket) GetObject(ctx context.Context, input *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if b.content == nil {
+		return nil, apiError("NoSuchKey")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(b.content))}, nil
+}
+
+func (b *uploadBucket) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return &s3.ListObjectsV2Output{}, nil
+}
+
+func uploadedPDF() ([]byte, string) {
+	content := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("0"), 1024)...)
+	digest := sha256.Sum256(content)
+	return content, base64.StdEncoding.EncodeToString(digest[:])
+}
+
+func TestNewDirectUploads(t *testing.T) {
+	assert.Nil(t, NewDirectUploads(config.DirectUploadSettings{}, "bucket", "eu-west-2", "", nil, nil, nil))
+
+	d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "", nil, nil, nil)
+	require.NotNil(t, d)
+	assert.Equal(t, defaultDirectUploadTTL, d.TTL)
+	assert.Equal(t, int64(defaultDirectUploadMaxBytes), d.MaxBytes)
+}
+
+func TestUploadTokenRoundTrip(t *testing.T) {
+	d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "", nil, nil, nil)
+	now := time.Now()
+	token := d.sign(directUpload{ClientID: "client1", DocumentID: "doc1", ExpiresAt: now.Add(time.Hour).Unix()})
+
+	upload, err := d.parse(token, now)
+	require.NoError(t, err)
+	assert.Equal(t, "client1", upload.ClientID)
+	assert.Equal(t, "doc1", upload.DocumentID)
+
+	_, err = d.parse(token, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidUploadToken, "expired")
+	_, err = d.parse(token[:len(token)-2]+"AA", now)
+	assert.ErrorIs(t, err, ErrInvalidUploadToken, "edited")
+	other := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "other"}, "bucket", "eu-west-2", "", nil, nil, nil)
+	_, err = other.parse(token, now)
+	assert.ErrorIs(t, err, ErrInvalidUploadToken, "signed with another secret")
+	_, err = d.parse("not-a-token", now)
+	assert.ErrorIs(t, err, ErrInvalidUploadToken)
+}
+
+func TestPresignRequiresTheDeclaredFile(t *testing.T) {
+	bucket := &uploadBucket{}
+	d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "key1", nil, bucket, bucket)
+	_, checksum := uploadedPDF()
+
+	upload, err := d.presign(context.Background(), "quarantine/doc1.pdf", directUpload{
+		ClientID: "client1", DocumentID: "doc1", MimeType: "application/pdf", Size: 1033, Checksum: checksum, FileName: "doc1.pdf",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, bucket.presigned, 1)
+	input := bucket.presigned[0]
+	assert.Equal(t, "quarantine/doc1.pdf", aws.ToString(input.Key))
+	assert.Equal(t, int64(1033), aws.ToInt64(input.ContentLength))
+	assert.Equal(t, checksum, aws.ToString(input.ChecksumSHA256))
+	assert.Equal(t, "key1", aws.ToString(input.SSEKMSKeyId))
+
+	assert.Equal(t, "doc1", upload.DocumentID)
+	assert.Equal(t, http.MethodPut, upload.Method)
+	assert.NotContains(t, upload.Headers, "Host")
+	assert.Equal(t, checksum, upload.Headers["X-Amz-Checksum-Sha256"])
+	parsed, err := d.parse(upload.UploadToken, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "doc1.pdf", parsed.FileName)
+	assert.Equal(t, upload.ExpiresAt.Add(completeGrace).Unix(), parsed.ExpiresAt)
+}
+
+func TestCheckObject(t *testing.T) {
+	content, checksum := uploadedPDF()
+	upload := directUpload{MimeType: "application/pdf", Size: int64(len(content)), Checksum: checksum}
+	statuses := func(checks []localModels.UploadCheck) map[string]localModels.UploadCheckStatus {
+		byName := map[string]localModels.UploadCheckStatus{}
+		for _, check := range checks {
+			byName[check.Name] = check.Status
+		}
+		return byName
+	}
+
+	t.Run("matches what was declared", func(t *testing.T) {
+		bucket := &uploadBucket{content: content, checksum: checksum}
+		d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "", nil, bucket, bucket)
+		checks, err := d.checkObject(context.Background(), "quarantine/doc1.pdf", upload)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]localModels.UploadCheckStatus{
+			"size": localModels.UploadCheckPassed, "checksum": localModels.UploadCheckPassed,
+			"mime_sniff": localModels.UploadCheckPassed, "quick_scan": localModels.UploadCheckSkipped,
+		}, statuses(checks))
+	})
+
+	t.Run("another file was uploaded", func(t *testing.T) {
+		other := []byte("\x89PNG\r\n\x1a\n")
+		bucket := &uploadBucket{content: other, checksum: "b3RoZXI="}
+		d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "", nil, bucket, bucket)
+		checks, err := d.checkObject(context.Background(), "quarantine/doc1.pdf", upload)
+		require.NoError(t, err)
+		byName := statuses(checks)
+		assert.Equal(t, localModels.UploadCheckFailed, byName["size"])
+		assert.Equal(t, localModels.UploadCheckFailed, byName["checksum"])
+		assert.Equal(t, localModels.UploadCheckFailed, byName["mime_sniff"])
+	})
+
+	t.Run("nothing was uploaded", func(t *testing.T) {
+		bucket := &uploadBucket{}
+		d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "", nil, bucket, bucket)
+		_, err := d.checkObject(context.Background(), "quarantine/doc1.pdf", upload)
+		assert.ErrorIs(t, err, ErrDirectUploadMissing)
+	})
+}
+
+func scannedDocument(checksum string) localModels.DocumentRecord {
+	return localModels.DocumentRecord{
+		Document: models.Document{DocumentID: "doc1", ApplicantID: "applicant1", DocumentType: models.DocumentPassport, FileURL: localModels.PlaceholderFileURL},
+		ClientID: "client1",
+		Upload: &localModels.StorageUpload{
+			State:         localModels.UploadScanning,
+			FileName:      "doc1.pdf",
+			MimeType:      "application/pdf",
+			QuarantineURL: "https://bucket.s3.eu-west-2.amazonaws.com/quarantine/doc1.pdf",
+			Checksum:      checksum,
+		},
+	}
+}
+
+func TestScanStreamFindsMarkersAcrossWindows(t *testing.T) {
+	content, checksum := uploadedPDF()
+	check, digest, err := scanStream(bytes.NewReader(content), "application/pdf")
+	require.NoError(t, err)
+	assert.Equal(t, localModels.UploadCheckPassed, check.Status)
+	assert.Equal(t, checksum, digest)
+
+	// A marker straddling the end of the first window
+	infected := append(bytes.Repeat([]byte("0"), scanWindow-10), eicarSignature...)
+	check, _, err = scanStream(bytes.NewReader(infected), "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, localModels.UploadCheckFailed, check.Status)
+	assert.Equal(t, "file matches antivirus test signature", check.Detail)
+}
+
+func TestScanDocumentMovesCleanFiles(t *testing.T) {
+	content, checksum := uploadedPDF()
+	files := &uploadBucket{content: content}
+	bucket := &quarantineBucket{}
+	store := quarantine.New(config.QuarantineSettings{Enabled: true}, "bucket", bucket)
+	d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "", store, files, files)
+	collection, sets := recordedUpdates()
+
+	require.NoError(t, d.scanDocument(context.Background(), collection, scannedDocument(checksum)))
+	assert.Equal(t, []string{"doc1.pdf"}, bucket.copied)
+	assert.Equal(t, []string{"quarantine/doc1.pdf"}, bucket.removed)
+	require.Len(t, *sets, 2)
+	assert.Equal(t, localModels.UploadStored, (*sets)[1]["upload.state"])
+}
+
+func TestScanDocumentRejectsFiles(t *testing.T) {
+	content, checksum := uploadedPDF()
+	infected := append([]byte("%PDF-1.7\n/OpenAction"), content...)
+	for name, tc := range map[string]struct {
+		content  []byte
+		checksum string
+		reason   string
+	}{
+		"infected": {content: infected, checksum: checksum, reason: "file failed its malware scan: pdf contains auto-run action"},
+		"changed":  {content: content, checksum: "b3RoZXI=", reason: "file does not match the declared checksum"},
+		"expired":  {content: nil, checksum: checksum, reason: "the uploaded file expired from quarantine before it was scanned"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			files := &uploadBucket{content: tc.content}
+			bucket := &quarantineBucket{}
+			store := quarantine.New(config.QuarantineSettings{Enabled: true}, "bucket", bucket)
+			d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "", store, files, files)
+			webhooks := new(localMocks.MockWebhookService)
+			webhooks.On("Emit", mock.Anything, "client1", localModels.WebhookDocumentUploadFailed, mock.MatchedBy(func(data localModels.DocumentUploadFailedData) bool {
+				return data.DocumentID == "doc1" && data.Reason == tc.reason && data.Action == "reupload"
+			})).Return(nil)
+			d.Webhooks = webhooks
+			collection, sets := recordedUpdates()
+
+			require.NoError(t, d.scanDocument(context.Background(), collection, scannedDocument(tc.checksum)))
+			assert.Empty(t, bucket.copied)
+			assert.Equal(t, []string{"quarantine/doc1.pdf"}, bucket.removed)
+			require.Len(t, *sets, 1)
+			assert.Equal(t, localModels.UploadFailed, (*sets)[0]["upload.state"])
+			webhooks.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRemoveAbandonedUploads(t *testing.T) {
+	bucket := &quarantineBucket{}
+	store := quarantine.New(config.QuarantineSettings{Enabled: true}, "bucket", bucket)
+	d := NewDirectUploads(config.DirectUploadSettings{SigningSecret: "secret"}, "bucket", "eu-west-2", "", store, nil, nil)
+	cutoff := time.Now().Add(-time.Hour)
+	old, recent := cutoff.Add(-time.Minute), cutoff.Add(time.Minute)
+	objects := []types.Object{
+		{Key: aws.String("quarantine/abandoned.pdf"), LastModified: &old},
+		{Key: aws.String("quarantine/saved.png"), LastModified: &old},
+		{Key: aws.String("quarantine/uploading.pdf"), LastModified: &recent},
+	}
+	completed := func(ctx context.Context, documentID string) (bool, error) {
+		return documentID == "saved", nil
+	}
+
+	require.NoError(t, d.removeAbandoned(context.Background(), objects, cutoff, completed))
+	assert.Equal(t, []string{"quarantine/abandoned.pdf"}, bucket.removed)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// DocumentCache keeps documents read by GetDocument, keyed by GenerateFilterAndCacheKey.
+// Entries are dropped as soon as a write to the document is confirmed, without writing to
+// the database again. As with the shared cache, entries are per replica.
+type DocumentCache struct {
+	store *cache.Cache
+}
+
+// NewDocumentCache creates a cache whose entries expire after ttl, or returns nil when ttl is
+// not positive, which leaves reads uncached
+func NewDocumentCache(ttl, cleanup time.Duration) *DocumentCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &DocumentCache{store: cache.New(ttl, cleanup)}
+}
+
+func (d *DocumentCache) get(key string) (models.Document, bool) {
+	if d == nil {
+		return models.Document{}, false
+	}
+	cached, found := d.store.Get(key)
+	if !found {
+		return models.Document{}, false
+	}
+	doc, ok := cached.(models.Document)
+	return doc, ok
+}
+
+func (d *DocumentCache) set(key string, doc models.Document) {
+	if d != nil {
+		d.store.SetDefault(key, doc)
+	}
+}
+
+func (d *DocumentCache) evict(key string) {
+	if d != nil {
+		d.store.Delete(key)
+	}
+}
@@ -1,31 +1,76 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime"
-	"net/http"
+	"mime/multipart"
 	"net/url"
 	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/diagnostics"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongotx"
+	"github.com/rachel-lawrie/verus_app_backend/internal/quarantine"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_app_backend/internal/vendor"
 	"github.com/rachel-lawrie/verus_backend_core/common"
 	"github.com/rachel-lawrie/verus_backend_core/constants"
 	"github.com/rachel-lawrie/verus_backend_core/interfaces"
 	models "github.com/rachel-lawrie/verus_backend_core/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
 )
 
-// DocumentServiceImpl is the concrete implementation of the DocumentService interface
+// DocumentServiceImpl is the concrete implementation of the DocumentService interface.
+//
+// It holds only dependencies set at startup and keeps no state between requests, so
+// any number of requests, on this replica or others, may use it at once. They are kept
+// apart by MongoDB rather than by locks here:
+//   - each upload saves a new document under a fresh ID, inserted at most once
+//   - a replacement only applies to the version it read, failing with ErrReplaceConflict otherwise,
+//     and is saved in one transaction with the risk signals, provider and usage it records
+//   - status updates are last-writer-wins
+//   - the UploadReconciler leaves uploads younger than its grace period to the request handling them
+//
+// Cached reads are per replica: after an update, other replicas may serve the previous
+// document until their cache entry expires.
 type DocumentServiceImpl struct {
-	Uploader       interfaces.Uploader
-	KMSUploader    interfaces.KMSUploader
-	CollectionName string
+	Uploader                interfaces.Uploader
+	KMSUploader             interfaces.KMSUploader
+	RiskService             localInterfaces.RiskService
+	CollectionName          string
+	ApplicantCollectionName string
+	StagingDir              string                         // Local copies of uploads are kept here until they are in S3
+	Vendors                 vendor.Selector                // Picks the verification vendor for each document; nil skips vendor selection
+	Usage                   localInterfaces.UsageRecorder  // Meters processed documents for billing; nil records nothing
+	Clients                 clientconfig.Loader            // Reads client settings for watermarking downloads; nil leaves downloads unmarked
+	Jobs                    localInterfaces.UploadJobStore // Records each upload's progress; nil tracks nothing
+	Quarantine              *quarantine.Store              // Holds new files until their record is saved; nil stores them directly
+	ColdStorage             *ColdStorage                   // Restores archived documents; nil refuses restores
+	Direct                  *DirectUploads                 // Presigns uploads straight to S3; nil, or no Quarantine, refuses them
+	Limits                  *UploadLimits                  // Bounds documents per applicant and uploads in progress per client; nil limits nothing
+	Previews                config.PreviewSettings         // Sizes document previews; zero values use the defaults
+	ImageQuality            config.ImageQualitySettings    // Refuses unreadable photos of identity documents and selfies unless disabled
+	Cache                   *DocumentCache                 // Keeps documents read by GetDocument; nil reads them from the database every time
+	RestoreGracePeriod      time.Duration                  // How long deleted documents can be restored; softdelete.DefaultGracePeriod when zero
 }
 
 var (
@@ -36,15 +81,46 @@ var (
 func GetDocumentServiceImpl() DocumentServiceImpl {
 	once.Do(func() {
 		instance = DocumentServiceImpl{
-			CollectionName: constants.CollectionApplicants,
+			CollectionName:          localConstants.CollectionDocuments,
+			ApplicantCollectionName: constants.CollectionApplicants,
 		}
 	})
 	return instance
 }
 
-// A simple in-memory store for demo purposes (use a database in production)
-var documents = make(map[string]models.Document)
-var mu sync.Mutex // Mutex to ensure thread-safety for map access
+// ErrUploadChecksFailed is returned for an uploaded document that failed one of its checks
+var ErrUploadChecksFailed = apperr.Unprocessable("upload_checks_failed", "document failed upload checks")
+
+// documentApplicant is the part of the applicant record that documents depend on
+type documentApplicant struct {
+	ClientID          string               `bson:"client_id"`
+	VerificationLevel string               `bson:"verification_level"`
+	Consent           *localModels.Consent `bson:"consent"`
+}
+
+// findApplicant looks up the applicant a document belongs to
+func (s *DocumentServiceImpl) findApplicant(ctx context.Context, scope tenant.Filter, applicantID string) (documentApplicant, error) {
+	var applicant documentApplicant
+	opts := options.FindOne().SetProjection(bson.M{"client_id": 1, "verification_level": 1, "consent": 1})
+	filter := scope.With("applicant_id", applicantID).With("deleted", false)
+	err := tenant.Guard(common.GetCollection(s.ApplicantCollectionName)).FindOne(ctx, filter, opts).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return documentApplicant{}, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return documentApplicant{}, fmt.Errorf("failed to look up applicant: %v", err)
+	}
+	return applicant, nil
+}
+
+// ownedApplicant looks up the applicant an upload is for among the uploading client's, so
+// that nothing is stored or billed for an applicant the client can't see
+func (s *DocumentServiceImpl) ownedApplicant(ctx context.Context, clientID, applicantID string) (documentApplicant, error) {
+	if clientID == "" {
+		return documentApplicant{}, tenant.ErrUnscoped
+	}
+	return s.findApplicant(ctx, tenant.Of(clientID), applicantID)
+}
 
 var mimeTypeToExtension = map[string]string{
 	"application/pdf": ".pdf",
@@ -61,186 +137,351 @@ func GetFileExtension(mimeType string) (string, error) {
 	// Use mime.ExtensionsByType for unknown MIME types
 	exts, err := mime.ExtensionsByType(mimeType)
 	if err != nil || len(exts) == 0 {
-		return "", fmt.Errorf("unsupported MIME type: %s", mimeType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedMimeType, mimeType)
 	}
 	return exts[0], nil
 }
 
 // UploadDocument handles the file upload and saves the document
-func (s *DocumentServiceImpl) UploadDocument(c *gin.Context, collection common.CollectionInterface) (models.Document, error) {
+func (s *DocumentServiceImpl) UploadDocument(c *gin.Context, collection common.CollectionInterface) (localModels.UploadResult, error) {
 	r := c.Request
-	// Parse the form data (including file)
-	err := r.ParseMultipartForm(10 << 20) // 10MB max file size
+	file, fileHeader, mimeType, ext, err := readDocumentFile(c)
 	if err != nil {
-		return models.Document{}, fmt.Errorf("unable to parse form data: %v", err)
-	}
-
-	// Get the file from the request
-	file, fileHeader, err := r.FormFile("document")
-	if err != nil {
-		return models.Document{}, fmt.Errorf("unable to retrieve the file: %v", err)
+		return localModels.UploadResult{}, err
 	}
 	defer file.Close()
 
-	// Get MIME type of the uploaded file
-	mimeType := fileHeader.Header.Get("Content-Type")
-	if mimeType == "" {
-		return models.Document{}, fmt.Errorf("unable to determine MIME type")
-	}
-
-	// Check for known MIME types and return an error if unsupported
-	if _, ok := mimeTypeToExtension[mimeType]; !ok {
-		return models.Document{}, fmt.Errorf("unsupported MIME type: %s", mimeType)
-	}
-
-	// Determine the file extension based on MIME type
-	ext, err := GetFileExtension(mimeType)
-	if err != nil {
-		return models.Document{}, fmt.Errorf("unsupported file extension type: %v", mimeType)
-	}
-
 	applicantID := r.FormValue("applicant_id")
 	if applicantID == "" {
-		return models.Document{}, fmt.Errorf("applicant_id is required")
+		return localModels.UploadResult{}, requiredField("applicant_id")
 	}
 	documentType := r.FormValue("document_type")
 	if documentType == "" {
-		return models.Document{}, fmt.Errorf("document_type is required")
+		return localModels.UploadResult{}, requiredField("document_type")
 	}
 	country := r.FormValue("country")
 	if country == "" {
-		return models.Document{}, fmt.Errorf("country is required")
+		return localModels.UploadResult{}, requiredField("country")
 	}
 
 	// Create document metadata
 	doc := createDocumentObject(applicantID, documentType, country)
+	doc.FileSize = fileHeader.Size
 	fileName := doc.DocumentID + ext
+	record := localModels.DocumentRecord{Document: doc}
+
+	// The applicant is checked before anything is staged, stored or metered
+	applicant, err := s.ownedApplicant(r.Context(), c.GetString("client_id"), applicantID)
+	if err != nil {
+		return localModels.UploadResult{}, err
+	}
+	record.ClientID = applicant.ClientID
+	end, err := s.Limits.begin(applicant.ClientID)
+	if err != nil {
+		return localModels.UploadResult{}, err
+	}
+	defer end()
+	if err := s.checkApplicantLimits(r.Context(), collection, applicant, applicantID, 1, fileHeader.Size); err != nil {
+		return localModels.UploadResult{}, err
+	}
+	tracker := s.trackUpload(r.Context(), record)
 
-	// Upload file to S3
-	fileURL, err := s.Uploader.UploadFile(c, file, fileName, mimeType, s.KMSUploader)
+	result, err := s.checkUpload(c, file, fileHeader.Size, mimeType, country, applicant, &record)
 	if err != nil {
-		return models.Document{}, fmt.Errorf("error uploading file to S3: %v", err)
+		tracker.fail(r.Context(), result.ProcessingStatus, err)
+		return result, err
 	}
-	doc.FileURL = fileURL
 
-	mu.Lock()
-	CreateDocument(c, applicantID, doc, collection)
-	mu.Unlock()
+	if err := s.saveUpload(c, collection, applicantID, &record, file, fileName, mimeType, &result, tracker); err != nil {
+		return localModels.UploadResult{}, err
+	}
 
-	// Return document metadata along with success
-	return doc, nil
+	// Return document metadata along with the check results
+	return result, nil
 }
 
-// createApplicantObject creates a new applicant object with provided name, dob, address, email, phone and auto-generates fields like applicant id and timestamps.
-func createDocumentObject(applicantID, documentType, country string) models.Document {
-	now := time.Now()
-	document_type, _ := models.ParseDocumentType(documentType)
-	return models.Document{
-		DocumentID:   uuid.New().String(), // Generate a unique ID for the document
-		ApplicantID:  applicantID,         // Set the Applicant ID
-		DocumentType: document_type,       // Set the document type
-		Country:      country,             // Set the country
-		FileURL:      "placeholder",       // Set placeholder URL then update after saving the file
-		FileSize:     0,
-		Status:       models.DocumentUploaded,
-		CreatedAt:    now,
-		UpdatedAt:    now,
-		Deleted:      false,
-		DeletedAt:    nil,
-		DeletedBy:    nil,
+// saveUpload saves a checked upload's record, moves its file to S3 and records what
+// follows from it for the applicant
+func (s *DocumentServiceImpl) saveUpload(c *gin.Context, collection common.CollectionInterface, applicantID string, record *localModels.DocumentRecord, file multipart.File, fileName, mimeType string, result *localModels.UploadResult, tracker *uploadTracker) error {
+	// Save the record with a placeholder URL before the file goes to S3. If the upload
+	// fails the placeholder is picked up by the UploadReconciler, which retries from
+	// the staged copy or asks the client to re-upload.
+	s.stageUpload(record, file, fileName, mimeType)
+	record.Upload.JobID = tracker.jobID()
+
+	if err := saveDocumentRecord(c.Request.Context(), applicantID, *record, collection); err != nil {
+		removeStaged(record.Upload.StagedPath)
+		tracker.fail(c.Request.Context(), result.ProcessingStatus, err)
+		return fmt.Errorf("could not create document: %w", err)
 	}
-}
 
-func CreateDocument(c *gin.Context, applicantID string, document models.Document, collection common.CollectionInterface) {
+	s.finishUpload(c, collection, applicantID, record, file, result, tracker)
 
-	// Log the full document object before insertion
-	log.Printf("CreateDocument: Document object to be inserted: %+v", document)
+	// Not in a transaction, so failures are logged rather than returned
+	_ = s.recordOutcome(c.Request.Context(), applicantID, *record)
+	return nil
+}
 
-	filter := bson.M{"applicant_id": applicantID, "deleted": false}
-	update := bson.M{
-		"$push": bson.M{
-			"documents": document, // Add the new document
-		},
+// checkUpload runs every check on an upload before anything is stored, returning an error
+// together with the results if the upload is refused
+func (s *DocumentServiceImpl) checkUpload(c *gin.Context, file multipart.File, size int64, mimeType, country string, applicant documentApplicant, record *localModels.DocumentRecord) (localModels.UploadResult, error) {
+	result, err := checkDocumentFile(file, size, mimeType, country, c.Request.FormValue("mrz"), record)
+	if err != nil {
+		return result, err
+	}
+	if err := s.applyImageQuality(file, mimeType, record, &result); err != nil {
+		return result, err
+	}
+	if err := s.checkSubmission(c, applicant, record, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// checkSubmission runs the checks that depend on the applicant and client rather than on the file
+func (s *DocumentServiceImpl) checkSubmission(c *gin.Context, applicant documentApplicant, record *localModels.DocumentRecord, result *localModels.UploadResult) error {
+	if err := applyConsentCheck(c, applicant, record, result); err != nil {
+		return err
 	}
+	if err := s.applyVendorCheck(applicant, record, result); err != nil {
+		return err
+	}
+	return applyClientPolicy(c, record, result)
+}
+
+// finishUpload moves a saved upload's file to S3 and completes the result. An upload left
+// for the UploadReconciler stays at the storing stage until the reconciler finishes it.
+func (s *DocumentServiceImpl) finishUpload(c *gin.Context, collection common.CollectionInterface, applicantID string, record *localModels.DocumentRecord, file multipart.File, result *localModels.UploadResult, tracker *uploadTracker) {
+	tracker.advance(c.Request.Context(), localModels.UploadJobStoring, result.ProcessingStatus)
+	if s.storeUpload(c, collection, applicantID, record, file) {
+		tracker.advance(c.Request.Context(), localModels.UploadJobCompleted, result.ProcessingStatus)
+	} else {
+		result.ProcessingStatus = localModels.ProcessingStoragePending
+	}
+	result.DocumentRecord = *record
+	result.JobID = tracker.jobID()
+}
 
-	_, err := collection.UpdateOne(c.Request.Context(), filter, update)
+// checkDocumentFile runs the synchronous checks on a file and records the country check on the record.
+// It returns an error together with the results if the file is rejected.
+func checkDocumentFile(file multipart.File, size int64, mimeType, country, mrz string, record *localModels.DocumentRecord) (localModels.UploadResult, error) {
+	// Run the synchronous checks before anything is stored
+	checks, err := runUploadChecks(file, size, mimeType)
 	if err != nil {
-		log.Printf("Error inserting document into MongoDB: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create document"})
-		return
+		return localModels.UploadResult{}, err
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Document created successfully", "document_id": document.DocumentID})
+	return checkedResult(checks, country, mrz, record)
 }
 
-func (s *DocumentServiceImpl) GetDocument(c *gin.Context, applicantID string, docID string, collection common.CollectionInterface) (models.Document, error) {
+// checkedResult adds the country check to the checks run on a file and derives the result.
+// It returns an error together with the results if the file is rejected.
+func checkedResult(checks []localModels.UploadCheck, country, mrz string, record *localModels.DocumentRecord) (localModels.UploadResult, error) {
+	// Cross-check the claimed country against the MRZ captured by the client, if any
+	countryCheck, countryResult := checkCountry(country, mrz)
+	checks = append(checks, countryCheck)
+	record.CountryCheck = countryResult
+	if countryResult != nil && countryResult.Status == localModels.CountryMismatch {
+		record.Flags = append(record.Flags, countryMismatchFlag(countryResult))
+	}
 
-	collectionName := constants.CollectionApplicants
-	log.Println("Using MongoDB collection:", collectionName)
+	result := localModels.UploadResult{
+		DocumentRecord:   *record,
+		Checks:           checks,
+		ProcessingStatus: localModels.OverallStatus(checks),
+	}
+	if result.ProcessingStatus == localModels.ProcessingRejected {
+		return result, checksFailed(result)
+	}
+	return result, nil
+}
+
+// checksFailed returns the error for an upload that failed its checks, which reports the
+// checks back to the client
+func checksFailed(result localModels.UploadResult) error {
+	return apperr.WithDetails(ErrUploadChecksFailed, map[string]interface{}{
+		"checks":            result.Checks,
+		"processing_status": result.ProcessingStatus,
+	})
+}
+
+// stageUpload marks the record as waiting for S3 and keeps a local copy of the file if staging is configured
+func (s *DocumentServiceImpl) stageUpload(record *localModels.DocumentRecord, file multipart.File, fileName, mimeType string) {
+	record.FileURL = localModels.PlaceholderFileURL
+	record.Upload = &localModels.StorageUpload{
+		State:    localModels.UploadPending,
+		FileName: fileName,
+		MimeType: mimeType,
+	}
+	if s.StagingDir != "" {
+		stagedPath, err := stageFile(s.StagingDir, fileName, file)
+		if err != nil {
+			log.Printf("Error staging document %s, upload cannot be retried: %v", record.DocumentID, err)
+		}
+		record.Upload.StagedPath = stagedPath
+	}
+}
 
-	filter, cacheKey, err := GenerateFilterAndCacheKey(applicantID, docID, collectionName)
+// storeUpload uploads a saved record's file to S3 and points the record at it.
+// It returns false if the file was left for the UploadReconciler.
+func (s *DocumentServiceImpl) storeUpload(c *gin.Context, collection common.CollectionInterface, applicantID string, record *localModels.DocumentRecord, file multipart.File) bool {
+	started := time.Now()
+	fileURL, err := s.Uploader.UploadFile(c, file, s.Quarantine.Key(record.Upload.FileName), record.Upload.MimeType, s.KMSUploader)
 	if err != nil {
-		log.Printf("Error generating filter and cache key: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating filter and cache key"})
-		return models.Document{}, err
+		log.Printf("Error uploading document %s to S3, leaving it for reconciliation: %v", record.DocumentID, err)
+		return false
 	}
+	uploaded := timestamp.Now()
+	fileURL, err = commitUpload(c.Request.Context(), s.Quarantine, collection, tenant.Of(record.ClientID), applicantID, record.DocumentID, fileURL)
+	if err != nil {
+		log.Printf("Error saving file URL for document %s, leaving it for reconciliation: %v", record.DocumentID, err)
+		return false
+	}
+	diagnostics.Logger().Debug("Document stored",
+		zap.String("documentID", record.DocumentID),
+		zap.Duration("upload", uploaded.Sub(started)),
+		zap.Duration("save", time.Since(uploaded)),
+		zap.Int("goroutines", runtime.NumGoroutine()),
+	)
+	removeStaged(record.Upload.StagedPath)
+	record.FileURL = fileURL
+	record.Upload.State = localModels.UploadStored
+	record.Upload.StagedPath = ""
+	return true
+}
 
-	projection := bson.M{
-		"documents": bson.M{
-			"$elemMatch": bson.M{"document_id": docID},
-		},
+// recordOutcome records a saved document version on its applicant: the risk signals of its
+// flags, the provider chosen for it and its usage. In a transaction the first failure is
+// returned so the transaction is aborted. Otherwise failures are logged rather than failing
+// the upload, as the document is saved already.
+func (s *DocumentServiceImpl) recordOutcome(ctx context.Context, applicantID string, record localModels.DocumentRecord) error {
+	if err := s.recordFlagSignals(ctx, applicantID, record); err != nil {
+		return err
 	}
+	if err := s.recordProvider(ctx, applicantID, record); err != nil {
+		return err
+	}
+	return s.recordUsage(ctx, record)
+}
 
-	var result struct {
-		Documents []models.Document `bson:"documents"`
+// recordFlagSignals feeds a document's flags to the risk assessment of the applicant
+func (s *DocumentServiceImpl) recordFlagSignals(ctx context.Context, applicantID string, record localModels.DocumentRecord) error {
+	if s.RiskService == nil {
+		return nil
+	}
+	for _, flag := range record.Flags {
+		signal := localModels.RiskSignal{
+			Code:       flag.Code,
+			Severity:   localModels.RiskMedium,
+			Source:     "document_upload",
+			DocumentID: record.DocumentID,
+			Detail:     flag.Message,
+		}
+		if err := s.RiskService.RecordSignal(ctx, applicantID, signal); err != nil {
+			if mongotx.InTransaction(ctx) {
+				return fmt.Errorf("failed to record risk signal for document %s: %w", record.DocumentID, err)
+			}
+			log.Printf("Error recording risk signal for document %s: %v", record.DocumentID, err)
+		}
 	}
+	return nil
+}
 
-	if err != nil {
-		return models.Document{}, err
+// recordUsage meters a processed document version for the applicant's client. Billing
+// problems are logged rather than failing the upload, unless in a transaction.
+func (s *DocumentServiceImpl) recordUsage(ctx context.Context, record localModels.DocumentRecord) error {
+	if s.Usage == nil {
+		return nil
 	}
-	err = common.CacheWrapper(c, collectionName, cacheKey, filter, projection, &result)
+	subjectID := fmt.Sprintf("%s:v%d", record.DocumentID, record.CurrentVersion())
+	if err := s.Usage.Record(ctx, record.ClientID, localModels.UsageDocumentProcessed, subjectID); err != nil {
+		if mongotx.InTransaction(ctx) {
+			return err
+		}
+		log.Printf("Error recording usage for document %s: %v", record.DocumentID, err)
+	}
+	return nil
+}
+
+// createApplicantObject creates a new applicant object with provided name, dob, address, email, phone and auto-generates fields like applicant id and timestamps.
+func createDocumentObject(applicantID, documentType, country string) models.Document {
+	now := timestamp.Now()
+	document_type, _ := models.ParseDocumentType(documentType)
+	return models.Document{
+		DocumentID:   ids.Document.New(),             // Generate a unique ID for the document
+		ApplicantID:  applicantID,                    // Set the Applicant ID
+		DocumentType: document_type,                  // Set the document type
+		Country:      country,                        // Set the country
+		FileURL:      localModels.PlaceholderFileURL, // Set placeholder URL then update after saving the file
+		FileSize:     0,
+		Status:       models.DocumentUploaded,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Deleted:      false,
+		DeletedAt:    nil,
+		DeletedBy:    nil,
+	}
+}
+
+// GetDocument returns one of an applicant's documents. It returns
+// apperr.ErrApplicantNotFound or ErrDocumentNotFound when either does not exist.
+func (s *DocumentServiceImpl) GetDocument(c *gin.Context, applicantID string, docID string, collection common.CollectionInterface) (models.Document, error) {
+	collectionName := s.CollectionName
+
+	scope := tenant.FromContext(c)
+	filter, cacheKey, err := GenerateFilterAndCacheKey(scope, applicantID, docID, collectionName)
 	if err != nil {
+		log.Printf("Error generating filter and cache key: %v", err)
 		return models.Document{}, err
 	}
 
-	// Extract the matched document
-	if len(result.Documents) > 0 {
-		return result.Documents[0], nil
+	if cached, found := s.Cache.get(cacheKey); found {
+		return cached, nil
+	}
+	var result models.Document
+	err = collection.FindOne(c.Request.Context(), filter).Decode(&result)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Document{}, fmt.Errorf("failed to fetch document: %w", err)
+	}
+	if err == nil && result.DocumentID != "" {
+		s.Cache.set(cacheKey, result)
+		return result, nil
 	}
 
-	return models.Document{}, nil
+	// Tell a missing applicant apart from a missing document
+	if _, err := s.findApplicant(c.Request.Context(), scope, applicantID); err != nil {
+		return models.Document{}, err
+	}
+	return models.Document{}, ErrDocumentNotFound
 }
 
+// UpdateDocument sets the status of one of an applicant's documents. The cached document
+// is only invalidated once the update has matched it.
 func (s *DocumentServiceImpl) UpdateDocument(c *gin.Context, applicantID string, docID string, status models.DocumentStatus) (models.Document, error) {
-	collectionName := constants.CollectionApplicants
+	collectionName := s.CollectionName
 	collection := common.GetCollection(collectionName)
-	fmt.Println("Using MongoDB collection:", collectionName)
 
-	filter, cacheKey, err := GenerateFilterAndCacheKey(applicantID, docID, collectionName)
+	filter, cacheKey, err := GenerateFilterAndCacheKey(tenant.FromContext(c), applicantID, docID, collectionName)
 	if err != nil {
 		log.Printf("Error generating filter and cache key: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating filter and cache key"})
 		return models.Document{}, err
 	}
-	// Use $set with a positional operator to update the specific document
 	update := bson.M{
 		"$set": bson.M{
-			"documents.$.status":     status,
-			"documents.$.updated_at": time.Now(),
+			"status":     status,
+			"updated_at": timestamp.Now(),
 		},
 	}
-	// Invalidate the cache after a successful update
-	err = common.InvalidateCache(c, collectionName, cacheKey, filter, update, nil)
-	if err != nil {
-		log.Printf("Error invalidating cache: %v : %s", err, cacheKey)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error invalidating cache"})
+
+	work := newUnitOfWork(c, collectionName, collection, s.Cache)
+	if err := work.Update("update_document_status", filter, update, cacheKey); err != nil {
 		return models.Document{}, err
 	}
+	work.Commit()
 
 	// Retrieve the updated document
 	result, err := s.GetDocument(c, applicantID, docID, collection)
 	if err != nil {
 		log.Printf("Error retrieving updated document: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve updated document"})
 		return models.Document{}, err
 	}
 	return result, err
@@ -250,28 +491,21 @@ func (s *DocumentServiceImpl) UpdateDocument(c *gin.Context, applicantID string,
 // Save the file to the local filesystem
 func (s *DocumentServiceImpl) DownloadDocument(c *gin.Context, docID string, applicantID string, collection common.CollectionInterface) (string, error) {
 
-	// Step 3: Get the file URL from MongoDB using documentID and clientID
-	var applicant struct {
-		Documents []models.Document `bson:"documents"`
+	// Step 3: Get the file URL from MongoDB using documentID and applicantID
+	var doc localModels.DocumentRecord
+	filter := documentFilter(tenant.FromContext(c), applicantID, docID).With("deleted", false)
+	err := tenant.Guard(collection).FindOne(c.Request.Context(), filter).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrDocumentNotFound
 	}
-
-	filter := bson.M{
-		"applicant_id": applicantID,
-	}
-
-	err := collection.FindOne(c.Request.Context(), filter).Decode(&applicant)
 	if err != nil {
-		return "", fmt.Errorf("failed to find document in database: %v", err)
+		return "", fmt.Errorf("failed to find document in database: %w", err)
 	}
-
-	var fileURL string
-	for _, doc := range applicant.Documents {
-		if doc.DocumentID == docID {
-			fileURL = doc.FileURL
-			break
-		}
+	if !doc.ColdStorage.Downloadable(timestamp.Now()) {
+		return "", ErrDocumentArchived
 	}
 
+	fileURL := doc.FileURL
 	if fileURL == "" {
 		return "", fmt.Errorf("document with ID %s not found for client %s", docID, applicantID)
 	}
@@ -333,12 +567,11 @@ func getObjectKeyFromURL(fileURL string) (string, error) {
 	return objectKey, nil
 }
 
-// GenerateFilterAndCacheKey generates the filter and cache key for a document
-func GenerateFilterAndCacheKey(applicantID, docID, collectionName string) (bson.M, string, error) {
-	filter := bson.M{
-		"applicant_id":          applicantID,
-		"deleted":               false,
-		"documents.document_id": docID, // Ensure the specific document exists in the array
+// GenerateFilterAndCacheKey generates the filter and cache key for one of a client's documents
+func GenerateFilterAndCacheKey(scope tenant.Filter, applicantID, docID, collectionName string) (bson.M, string, error) {
+	filter, err := documentFilter(scope, applicantID, docID).With("deleted", false).BSON()
+	if err != nil {
+		return nil, "", err
 	}
 	cacheKey, err := common.GenerateCacheKey(collectionName, filter)
 	if err != nil {
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// clientLoader serves client records from a map, failing when the map is nil
+type clientLoader map[string]localModels.Client
+
+func (l clientLoader) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	if l == nil {
+		return localModels.Client{}, fmt.Errorf("clients unavailable")
+	}
+	if client, ok := l[clientID]; ok {
+		return client, nil
+	}
+	return localModels.Client{ClientID: clientID}, nil
+}
+
+func TestDownloadPurpose(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "", want: PurposeReview},
+		{query: "  ", want: PurposeReview},
+		{query: " AML audit ", want: "AML audit"},
+		{query: "a very long purpose that will not fit on the mark", wantErr: true},
+		{query: "line\nbreak", wantErr: true},
+		{query: "café", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/download?purpose="+url.QueryEscape(tt.query), nil)
+			purpose, err := DownloadPurpose(c, PurposeReview)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidPurpose)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, purpose)
+		})
+	}
+}
+
+func TestDownloadWatermark(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	download := localModels.DocumentDownload{Viewer: "admin1", Purpose: "review"}
+
+	client := localModels.Client{ClientID: "client1", Name: "Acme Bank"}
+	assert.Nil(t, DownloadWatermark(client, download, at))
+
+	client.Settings.Watermark.Enabled = true
+	mark := DownloadWatermark(client, download, at)
+	assert.Equal(t, []string{"Acme Bank - review", "admin1 2026-10-16 07:30 UTC"}, mark.Lines)
+
+	client.Settings.Watermark.Label = "ACME"
+	assert.Equal(t, "ACME - review", DownloadWatermark(client, download, at).Lines[0])
+
+	client.Name, client.Settings.Watermark.Label = "", ""
+	assert.Equal(t, "client1 - review", DownloadWatermark(client, download, at).Lines[0])
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/imagequality"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// photographedTypes are the documents applicants take photos of, whose photos are checked
+// for quality. Selfies are not documents, so they are not checked for framing.
+var photographedTypes = map[models.DocumentType]bool{
+	models.DocumentPassport:      true,
+	models.DocumentDriverLicense: true,
+	models.DocumentNationalID:    true,
+	models.DocumentIDCard:        true,
+	models.DocumentSelfie:        true,
+}
+
+// applyImageQuality refuses photos of identity documents and selfies that are too small,
+// blurry, glared or cropped for a provider to read, naming what to fix in each check's
+// code. Images that cannot be decoded are left to the provider.
+func (s *DocumentServiceImpl) applyImageQuality(file io.ReadSeeker, mimeType string, record *localModels.DocumentRecord, result *localModels.UploadResult) error {
+	if !s.ImageQuality.Enabled || !photographedTypes[record.DocumentType] {
+		return nil
+	}
+	if mimeType != "image/png" && mimeType != "image/jpeg" {
+		return nil
+	}
+
+	content, err := readUpload(file)
+	if err != nil {
+		return err
+	}
+	img, err := imagequality.Decode(content)
+	if err != nil {
+		return nil
+	}
+
+	for _, finding := range imagequality.Assess(img, imagequality.FromSettings(s.ImageQuality)) {
+		if finding.Check == imagequality.CheckFraming && record.DocumentType == models.DocumentSelfie {
+			continue
+		}
+		check := localModels.UploadCheck{Name: finding.Check, Status: localModels.UploadCheckPassed}
+		if finding.Code != "" {
+			check.Status = localModels.UploadCheckFailed
+			check.Code = finding.Code
+			check.Detail = finding.Detail
+		}
+		result.Checks = append(result.Checks, check)
+	}
+	result.ProcessingStatus = localModels.OverallStatus(result.Checks)
+	result.DocumentRecord = *record
+	if result.ProcessingStatus == localModels.ProcessingRejected {
+		return checksFailed(*result)
+	}
+	return nil
+}
+
+// readUpload returns the content of a file already checked for size and rewinds it for
+// the S3 upload
+func readUpload(file io.ReadSeeker) ([]byte, error) {
+	if held, ok := file.(interface{ Bytes() []byte }); ok {
+		return held.Bytes(), nil
+	}
+	content, err := io.ReadAll(io.LimitReader(file, maxUploadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %v", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("unable to rewind file: %v", err)
+	}
+	return content, nil
+}
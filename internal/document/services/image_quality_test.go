@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plainPNG is a featureless photo, which is too blurry to read but has nothing cut off at its edges
+func plainPNG(t *testing.T, width, height int) *bytes.Reader {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+	img.SetGray(width/2, height/2, color.Gray{Y: 0})
+	var out bytes.Buffer
+	require.NoError(t, png.Encode(&out, img))
+	return bytes.NewReader(out.Bytes())
+}
+
+func TestApplyImageQuality(t *testing.T) {
+	enabled := &DocumentServiceImpl{ImageQuality: config.ImageQualitySettings{Enabled: true}}
+	tests := []struct {
+		name     string
+		service  *DocumentServiceImpl
+		docType  models.DocumentType
+		mimeType string
+		checks   []string
+	}{
+		{"Disabled", &DocumentServiceImpl{}, models.DocumentPassport, "image/png", nil},
+		{"Not photographed", enabled, models.DocumentUtilityBill, "image/png", nil},
+		{"Not an image", enabled, models.DocumentPassport, "application/pdf", nil},
+		{"Identity document", enabled, models.DocumentPassport, "image/png", []string{"resolution", "sharpness", "glare", "framing"}},
+		{"Selfie", enabled, models.DocumentSelfie, "image/png", []string{"resolution", "sharpness", "glare"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := plainPNG(t, 400, 300)
+			record := localModels.DocumentRecord{Document: models.Document{DocumentType: tt.docType}}
+			result := localModels.UploadResult{ProcessingStatus: localModels.ProcessingAccepted}
+
+			err := tt.service.applyImageQuality(file, tt.mimeType, &record, &result)
+
+			var names []string
+			for _, check := range result.Checks {
+				names = append(names, check.Name)
+			}
+			assert.Equal(t, tt.checks, names)
+			if tt.checks == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Equal(t, localModels.ProcessingRejected, result.ProcessingStatus)
+			assert.Equal(t, localModels.UploadCheck{
+				Name:   "resolution",
+				Status: localModels.UploadCheckFailed,
+				Detail: "image is 400x300 but its shorter side must be at least 600 pixels",
+				Code:   "low_resolution",
+			}, result.Checks[0])
+			assert.Equal(t, "blurry_image", result.Checks[1].Code)
+			// The file is rewound for the S3 upload
+			offset, _ := file.Seek(0, io.SeekCurrent)
+			assert.Zero(t, offset)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/preview"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_app_backend/internal/watermark"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+)
+
+// PurposePreview is stamped on previews that do not give a purpose
+const PurposePreview = "preview"
+
+// defaultPreviewFileBytes bounds the files previews are drawn from when no limit is configured
+const defaultPreviewFileBytes = 20 << 20
+
+// PreviewDocument returns a downscaled JPEG of a client's document, watermarked when the
+// client watermarks downloads or the request asks for it. The preview is not drawn when
+// the caller already has it: its ETag names the document's file, the preview size and
+// the mark's client label, purpose and viewer, but not the time in the mark, so a cached
+// preview keeps the time it was first drawn at.
+func (s *DocumentServiceImpl) PreviewDocument(c *gin.Context, clientID, applicantID, docID string, request localModels.PreviewRequest, collection common.CollectionInterface) (localModels.DocumentPreview, error) {
+	doc, err := findDocumentRecord(c, collection, tenant.Of(clientID), applicantID, docID)
+	if err != nil {
+		return localModels.DocumentPreview{}, err
+	}
+	if err := s.previewable(doc); err != nil {
+		return localModels.DocumentPreview{}, err
+	}
+
+	client := localModels.Client{ClientID: doc.ClientID}
+	if s.Clients != nil {
+		if client, err = s.Clients.Load(c.Request.Context(), doc.ClientID); err != nil {
+			return localModels.DocumentPreview{}, fmt.Errorf("failed to load client settings: %v", err)
+		}
+	}
+	if request.Watermark {
+		client.Settings.Watermark.Enabled = true
+	}
+	mark := DownloadWatermark(client, request.Download, time.Now())
+
+	result := localModels.DocumentPreview{
+		ETag:        previewETag(doc, s.Previews.MaxDimension, mark, request.Download.Viewer),
+		Watermarked: mark != nil,
+	}
+	if etagMatches(request.IfNoneMatch, result.ETag) {
+		return result, nil
+	}
+
+	result.Content, err = s.renderPreview(c.Request.Context(), doc, s.Previews.MaxDimension, mark)
+	if err != nil {
+		return localModels.DocumentPreview{}, err
+	}
+	return result, nil
+}
+
+// Thumbnail returns a JPEG of a document no larger than maxDimension along either side,
+// marked for the download as the client's downloads are
+func (s *DocumentServiceImpl) Thumbnail(ctx context.Context, doc localModels.DocumentRecord, maxDimension int, download localModels.DocumentDownload) ([]byte, error) {
+	if err := s.previewable(doc); err != nil {
+		return nil, err
+	}
+	mark, err := s.downloadWatermark(ctx, doc.ClientID, download)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client settings: %v", err)
+	}
+	return s.renderPreview(ctx, doc, maxDimension, mark)
+}
+
+// previewable reports why a document's file cannot be previewed, if it cannot
+func (s *DocumentServiceImpl) previewable(doc localModels.DocumentRecord) error {
+	if doc.FileURL == "" || doc.FileURL == localModels.PlaceholderFileURL {
+		return ErrUploadInProgress
+	}
+	if !doc.ColdStorage.Downloadable(timestamp.Now()) {
+		return ErrDocumentArchived
+	}
+	if maxBytes := s.previewFileBytes(); doc.FileSize > maxBytes {
+		return fmt.Errorf("%w: files over %d bytes have no preview", preview.ErrUnsupported, maxBytes)
+	}
+	return nil
+}
+
+func (s *DocumentServiceImpl) previewFileBytes() int64 {
+	if s.Previews.MaxFileBytes > 0 {
+		return s.Previews.MaxFileBytes
+	}
+	return defaultPreviewFileBytes
+}
+
+// renderPreview downloads a document's file and draws its preview
+func (s *DocumentServiceImpl) renderPreview(ctx context.Context, doc localModels.DocumentRecord, maxDimension int, mark *watermark.Mark) ([]byte, error) {
+	objectKey, err := getObjectKeyFromURL(doc.FileURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract object key from URL: %v", err)
+	}
+	output, err := s.Uploader.DownloadFile(ctx, objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file from S3: %v", err)
+	}
+	defer output.Body.Close()
+	maxBytes := s.previewFileBytes()
+	content, err := io.ReadAll(io.LimitReader(output.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file from S3: %v", err)
+	}
+	if int64(len(content)) > maxBytes {
+		return nil, fmt.Errorf("%w: files over %d bytes have no preview", preview.ErrUnsupported, maxBytes)
+	}
+	return preview.Render(content, mime.TypeByExtension(path.Ext(doc.FileURL)), maxDimension, mark)
+}
+
+// previewETag names a preview by what it is drawn from. It is weak, since previews drawn
+// from the same file at different times carry different times in their mark.
+func previewETag(doc localModels.DocumentRecord, maxDimension int, mark *watermark.Mark, viewer string) string {
+	h := sha256.New()
+	for _, part := range []string{doc.DocumentID, strconv.Itoa(doc.CurrentVersion()), doc.FileURL, strconv.Itoa(maxDimension)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if mark != nil {
+		h.Write([]byte(mark.Lines[0]))
+		h.Write([]byte{0})
+		h.Write([]byte(viewer))
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header names an ETag, comparing weakly
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/watermark"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewETag(t *testing.T) {
+	doc := localModels.DocumentRecord{Document: models.Document{DocumentID: "doc1", FileURL: "https://bucket.s3.amazonaws.com/doc1.png"}}
+	mark := &watermark.Mark{Lines: []string{"Acme - preview", "client1 2026-10-16 09:30 UTC"}}
+	later := &watermark.Mark{Lines: []string{"Acme - preview", "client1 2026-10-16 10:45 UTC"}}
+
+	etag := previewETag(doc, 800, mark, "client1")
+	assert.Regexp(t, `^W/"[0-9a-f]{32}"$`, etag)
+	// The time in the mark does not change the preview's name
+	assert.Equal(t, etag, previewETag(doc, 800, later, "client1"))
+	assert.NotEqual(t, etag, previewETag(doc, 800, nil, "client1"))
+	assert.NotEqual(t, etag, previewETag(doc, 400, mark, "client1"))
+	assert.NotEqual(t, etag, previewETag(doc, 800, mark, "client2"))
+
+	replaced := doc
+	replaced.FileURL = "https://bucket.s3.amazonaws.com/doc1_v2.png"
+	assert.NotEqual(t, etag, previewETag(replaced, 800, mark, "client1"))
+}
+
+func TestETagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`W/"abc"`, `W/"abc"`))
+	assert.True(t, etagMatches(`"xyz", "abc"`, `W/"abc"`))
+	assert.True(t, etagMatches(`*`, `W/"abc"`))
+	assert.False(t, etagMatches(``, `W/"abc"`))
+	assert.False(t, etagMatches(`W/"abd"`, `W/"abc"`))
+}
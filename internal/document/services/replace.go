@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongotx"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrDocumentNotFound is returned when the document does not exist on one of the client's applicants
+	ErrDocumentNotFound = apperr.NotFound("document_not_found", "document not found")
+	// ErrUploadInProgress is returned when a document's current file has not reached S3 yet
+	ErrUploadInProgress = apperr.Conflict("upload_in_progress", "document upload is still in progress")
+	// ErrReplaceConflict is returned when the document was replaced by another request at the same time
+	ErrReplaceConflict = apperr.Conflict("replace_conflict", "document was replaced concurrently")
+)
+
+// findDocumentRecord loads one of an applicant's documents. Staff, who may read any
+// client's documents, pass tenant.AllClients.
+func findDocumentRecord(c *gin.Context, collection common.CollectionInterface, scope tenant.Filter, applicantID, docID string) (localModels.DocumentRecord, error) {
+	filter := documentFilter(scope, applicantID, docID).With("deleted", false)
+
+	var doc localModels.DocumentRecord
+	err := tenant.Guard(collection).FindOne(c.Request.Context(), filter).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return localModels.DocumentRecord{}, ErrDocumentNotFound
+	}
+	if err != nil {
+		return localModels.DocumentRecord{}, fmt.Errorf("failed to look up document: %w", err)
+	}
+	return doc, nil
+}
+
+// replacedBy reports whether the document already holds the given replacement, which happens
+// when a retried write had in fact been applied by an earlier attempt
+func (s *DocumentServiceImpl) replacedBy(c *gin.Context, collection common.CollectionInterface, clientID, applicantID, docID string, version int, at time.Time) bool {
+	doc, err := findDocumentRecord(c, collection, tenant.Of(clientID), applicantID, docID)
+	if err != nil || doc.Version != version || len(doc.Versions) == 0 {
+		return false
+	}
+	// Mongo stores times with millisecond precision
+	return doc.Versions[len(doc.Versions)-1].ReplacedAt.Equal(at.Truncate(time.Millisecond))
+}
+
+// versionOf snapshots a document's current file before it is replaced
+func versionOf(doc localModels.DocumentRecord, replacedBy string, replacedAt time.Time) localModels.DocumentVersion {
+	uploadedAt := doc.CreatedAt
+	if n := len(doc.Versions); n > 0 {
+		uploadedAt = doc.Versions[n-1].ReplacedAt
+	}
+	return localModels.DocumentVersion{
+		Version:      doc.CurrentVersion(),
+		FileURL:      doc.FileURL,
+		FileSize:     doc.FileSize,
+		Status:       doc.Status,
+		CountryCheck: doc.CountryCheck,
+		Flags:        doc.Flags,
+		UploadedAt:   uploadedAt,
+		ReplacedAt:   replacedAt,
+		ReplacedBy:   replacedBy,
+		Archived:     doc.ColdStorage != nil,
+	}
+}
+
+// ReplaceDocument uploads a new file for an existing document. The current file is kept
+// in the document's version history, the status is reset to uploaded and the upload
+// checks run again on the new file.
+func (s *DocumentServiceImpl) ReplaceDocument(c *gin.Context, clientID, docID string, collection common.CollectionInterface) (localModels.UploadResult, error) {
+	r := c.Request
+	file, fileHeader, mimeType, ext, err := readDocumentFile(c)
+	if err != nil {
+		return localModels.UploadResult{}, err
+	}
+	defer file.Close()
+
+	applicantID := r.FormValue("applicant_id")
+	if applicantID == "" {
+		return localModels.UploadResult{}, requiredField("applicant_id")
+	}
+
+	current, err := findDocumentRecord(c, collection, tenant.Of(clientID), applicantID, docID)
+	if err != nil {
+		return localModels.UploadResult{}, err
+	}
+	applicant, err := s.findApplicant(c.Request.Context(), tenant.Of(clientID), applicantID)
+	if err != nil {
+		return localModels.UploadResult{}, err
+	}
+	if current.Upload != nil && (current.Upload.State == localModels.UploadPending || current.Upload.State == localModels.UploadQuarantined) {
+		return localModels.UploadResult{}, ErrUploadInProgress
+	}
+	end, err := s.Limits.begin(clientID)
+	if err != nil {
+		return localModels.UploadResult{}, err
+	}
+	defer end()
+	if err := s.checkApplicantLimits(r.Context(), collection, applicant, applicantID, 0, fileHeader.Size-current.FileSize); err != nil {
+		return localModels.UploadResult{}, err
+	}
+
+	// The country may be corrected on replacement, otherwise the claimed one still applies
+	country := r.FormValue("country")
+	if country == "" {
+		country = current.Country
+	}
+
+	now := timestamp.Now()
+	record := localModels.DocumentRecord{Document: current.Document, Version: current.CurrentVersion() + 1}
+	record.Country = country
+	record.FileSize = fileHeader.Size
+	record.Status = models.DocumentUploaded
+	record.UpdatedAt = now
+	record.ClientID = clientID
+
+	tracker := s.trackUpload(r.Context(), record)
+	result, err := s.checkUpload(c, file, fileHeader.Size, mimeType, country, applicant, &record)
+	if err != nil {
+		tracker.fail(r.Context(), result.ProcessingStatus, err)
+		return result, err
+	}
+
+	// Every version gets its own object so the replaced file stays available
+	fileName := docID + "_v" + strconv.Itoa(record.Version) + ext
+	s.stageUpload(&record, file, fileName, mimeType)
+	record.Upload.JobID = tracker.jobID()
+
+	// Only replace the version that was read, so two concurrent replacements cannot both win
+	versionMatch := interface{}(current.Version)
+	if current.Version == 0 {
+		versionMatch = bson.M{"$exists": false}
+	}
+	filter := documentFilter(tenant.Of(clientID), applicantID, docID).With("version", versionMatch)
+	update := bson.M{
+		"$set": bson.M{
+			"file_url":      record.FileURL,
+			"file_size":     record.FileSize,
+			"country":       record.Country,
+			"status":        record.Status,
+			"updated_at":    now,
+			"version":       record.Version,
+			"upload":        record.Upload,
+			"country_check": record.CountryCheck,
+			"flags":         record.Flags,
+			"vendor":        record.Vendor,
+		},
+		"$push": bson.M{"versions": versionOf(current, clientID, now)},
+		// The new file is in the bucket's default storage class, and answers any request to resubmit
+		"$unset": bson.M{"cold_storage": "", "resubmission": ""},
+	}
+	if err := mongoschema.ValidateUpdate(s.CollectionName, update); err != nil {
+		removeStaged(record.Upload.StagedPath)
+		tracker.fail(r.Context(), result.ProcessingStatus, err)
+		return localModels.UploadResult{}, err
+	}
+	// The new version and what it records on the applicant are saved together where
+	// transactions are available, before the file is stored
+	err = mongotx.Run(r.Context(), "replace_document", func(ctx context.Context) error {
+		var matched int64
+		err := mongoretry.Write(ctx, "replace_document", func(ctx context.Context) error {
+			updateResult, err := tenant.Guard(collection).UpdateOne(ctx, filter, update)
+			if err == nil {
+				matched = updateResult.MatchedCount
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("could not replace document: %w", err)
+		}
+		if matched == 0 && !s.replacedBy(c, collection, clientID, applicantID, docID, record.Version, now) {
+			return ErrReplaceConflict
+		}
+		return s.recordOutcome(ctx, applicantID, record)
+	})
+	if err != nil {
+		removeStaged(record.Upload.StagedPath)
+		tracker.fail(r.Context(), result.ProcessingStatus, err)
+		return localModels.UploadResult{}, err
+	}
+
+	s.finishUpload(c, collection, applicantID, &record, file, &result, tracker)
+	return result, nil
+}
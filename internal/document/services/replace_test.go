@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionOf(t *testing.T) {
+	created := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	firstReplaced := created.Add(24 * time.Hour)
+	now := firstReplaced.Add(24 * time.Hour)
+
+	original := localModels.DocumentRecord{
+		Document: models.Document{DocumentID: "doc1", FileURL: "https://bucket/doc1.pdf", FileSize: 100, CreatedAt: created},
+		Flags:    []localModels.DocumentFlag{{Code: "country_mismatch"}},
+	}
+	version := versionOf(original, "client1", firstReplaced)
+	assert.Equal(t, 1, version.Version)
+	assert.Equal(t, created, version.UploadedAt)
+	assert.Equal(t, "https://bucket/doc1.pdf", version.FileURL)
+	assert.Len(t, version.Flags, 1)
+
+	// A replaced document's current file was uploaded when the previous one was replaced
+	replaced := localModels.DocumentRecord{
+		Document: models.Document{DocumentID: "doc1", FileURL: "https://bucket/doc1_v2.pdf", CreatedAt: created},
+		Version:  2,
+		Versions: []localModels.DocumentVersion{version},
+	}
+	version = versionOf(replaced, "client1", now)
+	assert.Equal(t, 2, version.Version)
+	assert.Equal(t, firstReplaced, version.UploadedAt)
+	assert.Equal(t, now, version.ReplacedAt)
+	assert.Equal(t, "client1", version.ReplacedBy)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/quarantine"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// stageFile keeps a local copy of an upload so it can be retried if S3 is unavailable.
+// The file is rewound afterwards.
+func stageFile(dir, fileName string, file io.ReadSeeker) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	path := filepath.Join(dir, fileName)
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create staged file: %v", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, file); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write staged file: %v", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %v", err)
+	}
+	return path, nil
+}
+
+// removeStaged deletes a staged copy once it is no longer needed
+func removeStaged(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing staged file %s: %v", path, err)
+	}
+}
+
+// saveDocumentRecord inserts a document into the documents collection. The insert is
+// keyed on the document ID, so the write can be retried safely.
+func saveDocumentRecord(ctx context.Context, applicantID string, document localModels.DocumentRecord, collection common.CollectionInterface) error {
+	document.ApplicantID = applicantID
+	if err := mongoschema.ValidateInsert(localConstants.CollectionDocuments, document); err != nil {
+		return err
+	}
+	return mongoretry.InsertOnce(ctx, collection, "save_document", bson.M{"document_id": document.DocumentID}, document)
+}
+
+// documentFilter matches one document of an applicant, within the client's documents
+func documentFilter(scope tenant.Filter, applicantID, docID string) tenant.Filter {
+	return scope.With("applicant_id", applicantID).With("document_id", docID)
+}
+
+// markStored points a document at its S3 object once the upload succeeded
+func markStored(ctx context.Context, collection common.CollectionInterface, scope tenant.Filter, applicantID, docID, fileURL string) error {
+	now := timestamp.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"file_url":               fileURL,
+			"upload.state":           localModels.UploadStored,
+			"upload.last_attempt_at": now,
+			"updated_at":             now,
+		},
+		"$unset": bson.M{"upload.staged_path": "", "upload.last_error": "", "upload.quarantine_url": ""},
+	}
+	if err := mongoschema.ValidateUpdate(localConstants.CollectionDocuments, update); err != nil {
+		return err
+	}
+	return mongoretry.Write(ctx, "mark_document_stored", func(ctx context.Context) error {
+		_, err := tenant.Guard(collection).UpdateOne(ctx, documentFilter(scope, applicantID, docID), update)
+		return err
+	})
+}
+
+// markQuarantined records that a document's file is in quarantine, so the reconciler can
+// finish moving it if the move is interrupted. The file URL stays a placeholder until then.
+func markQuarantined(ctx context.Context, collection common.CollectionInterface, scope tenant.Filter, applicantID, docID, quarantineURL string) error {
+	now := timestamp.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"upload.state":           localModels.UploadQuarantined,
+			"upload.quarantine_url":  quarantineURL,
+			"upload.last_attempt_at": now,
+			"updated_at":             now,
+		},
+	}
+	if err := mongoschema.ValidateUpdate(localConstants.CollectionDocuments, update); err != nil {
+		return err
+	}
+	return mongoretry.Write(ctx, "mark_document_quarantined", func(ctx context.Context) error {
+		_, err := tenant.Guard(collection).UpdateOne(ctx, documentFilter(scope, applicantID, docID), update)
+		return err
+	})
+}
+
+// commitUpload points a document at the file uploaded to fileURL and returns the URL it
+// was stored under. A quarantined file is recorded as such, copied to its permanent key
+// and only removed from quarantine once the record points at the copy.
+func commitUpload(ctx context.Context, store *quarantine.Store, collection common.CollectionInterface, scope tenant.Filter, applicantID, docID, fileURL string) (string, error) {
+	if !store.Holds(fileURL) {
+		return fileURL, markStored(ctx, collection, scope, applicantID, docID, fileURL)
+	}
+	if err := markQuarantined(ctx, collection, scope, applicantID, docID, fileURL); err != nil {
+		return "", err
+	}
+	storedURL, err := store.Promote(ctx, fileURL)
+	if err != nil {
+		return "", err
+	}
+	if err := markStored(ctx, collection, scope, applicantID, docID, storedURL); err != nil {
+		return "", err
+	}
+	// A copy left behind expires with the bucket's quarantine rule
+	if err := store.Release(ctx, fileURL); err != nil {
+		log.Printf("Error removing quarantined copy of document %s: %v", docID, err)
+	}
+	return storedURL, nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/softdelete"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UndeleteDocument un-deletes one of a client's documents that was deleted within the
+// grace period, and returns it. Its applicant must not be deleted, so a document deleted
+// with its applicant comes back once the applicant is restored and then the document.
+// The document counts against the applicant's document limit again.
+func (s *DocumentServiceImpl) UndeleteDocument(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (models.Document, error) {
+	ctx := c.Request.Context()
+	applicant, err := s.findApplicant(ctx, tenant.Of(clientID), applicantID)
+	if err != nil {
+		return models.Document{}, err
+	}
+	if err := s.undelete(ctx, collection, applicant, applicantID, docID); err != nil {
+		return models.Document{}, err
+	}
+	return s.GetDocument(c, applicantID, docID, collection)
+}
+
+// undelete clears the deletion of an applicant's document if its grace period allows
+func (s *DocumentServiceImpl) undelete(ctx context.Context, collection common.CollectionInterface, applicant documentApplicant, applicantID, docID string) error {
+	filter := documentFilter(tenant.Of(applicant.ClientID), applicantID, docID)
+	var doc struct {
+		softdelete.State `bson:",inline"`
+		FileSize         int64 `bson:"file_size"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"deleted": 1, "deleted_at": 1, "file_size": 1})
+	err := tenant.Guard(collection).FindOne(ctx, filter, opts).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return ErrDocumentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up document: %w", err)
+	}
+	if err := doc.Check(s.RestoreGracePeriod, timestamp.Now()); err != nil {
+		return err
+	}
+	if err := s.checkApplicantLimits(ctx, collection, applicant, applicantID, 1, doc.FileSize); err != nil {
+		return err
+	}
+
+	update := softdelete.Restore(timestamp.Now())
+	if err := mongoschema.ValidateUpdate(localConstants.CollectionDocuments, update); err != nil {
+		return err
+	}
+	// Only the deletion that was checked is undone, so a second restore changes nothing
+	stillDeleted := filter.With("deleted", true).With("deleted_at", doc.DeletedAt)
+	var matched int64
+	err = mongoretry.Write(ctx, "undelete_document", func(ctx context.Context) error {
+		result, err := tenant.Guard(collection).UpdateOne(ctx, stillDeleted, update)
+		if err != nil {
+			return err
+		}
+		matched = result.MatchedCount
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return softdelete.ErrNotDeleted
+	}
+
+	// A read cached before the document was deleted must not outlive the restore
+	if _, cacheKey, err := GenerateFilterAndCacheKey(tenant.Of(applicant.ClientID), applicantID, docID, s.CollectionName); err == nil {
+		s.Cache.evict(cacheKey)
+	}
+	return nil
+}
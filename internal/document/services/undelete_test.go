@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/softdelete"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// deletedDocument holds one soft-deleted document of client1 and records the updates made to it
+type deletedDocument struct {
+	deletedAt time.Time
+	restored  bool
+	updates   []bson.M
+}
+
+func (d *deletedDocument) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return &mongo.InsertOneResult{}, nil
+}
+
+func (d *deletedDocument) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	if filter.(bson.M)["client_id"] != "client1" {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(bson.M{"deleted": !d.restored, "deleted_at": d.deletedAt, "file_size": 2048}, nil, nil)
+}
+
+func (d *deletedDocument) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	return mongo.NewCursorFromDocuments([]interface{}{bson.M{"file_size": 1024}}, nil, nil)
+}
+
+func (d *deletedDocument) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	f := filter.(bson.M)
+	if d.restored || f["deleted"] != true || !f["deleted_at"].(*time.Time).Equal(d.deletedAt) {
+		return &mongo.UpdateResult{}, nil
+	}
+	d.restored = true
+	d.updates = append(d.updates, update.(bson.M))
+	return &mongo.UpdateResult{MatchedCount: 1}, nil
+}
+
+var client1Applicant = documentApplicant{ClientID: "client1", VerificationLevel: "basic"}
+
+func TestUndeleteWithinGracePeriod(t *testing.T) {
+	svc := &DocumentServiceImpl{RestoreGracePeriod: 48 * time.Hour}
+	documents := &deletedDocument{deletedAt: timestamp.Now().Add(-24 * time.Hour)}
+
+	require.NoError(t, svc.undelete(context.Background(), documents, client1Applicant, "app1", "doc1"))
+	require.Len(t, documents.updates, 1)
+	assert.Equal(t, false, documents.updates[0]["$set"].(bson.M)["deleted"])
+	assert.Contains(t, documents.updates[0]["$unset"], "deleted_at")
+
+	// Once restored, restoring again is refused
+	assert.ErrorIs(t, svc.undelete(context.Background(), documents, client1Applicant, "app1", "doc1"), softdelete.ErrNotDeleted)
+}
+
+func TestUndeleteAfterGracePeriod(t *testing.T) {
+	svc := &DocumentServiceImpl{RestoreGracePeriod: 12 * time.Hour}
+	documents := &deletedDocument{deletedAt: timestamp.Now().Add(-24 * time.Hour)}
+
+	assert.ErrorIs(t, svc.undelete(context.Background(), documents, client1Applicant, "app1", "doc1"), softdelete.ErrGracePeriodOver)
+	assert.Empty(t, documents.updates)
+}
+
+func TestUndeleteRespectsDocumentLimit(t *testing.T) {
+	svc := &DocumentServiceImpl{Limits: NewUploadLimits(config.UploadLimitSettings{
+		Levels: map[string]config.ApplicantDocumentLimit{"basic": {MaxDocuments: 1}},
+	})}
+	documents := &deletedDocument{deletedAt: timestamp.Now().Add(-time.Hour)}
+
+	assert.ErrorIs(t, svc.undelete(context.Background(), documents, client1Applicant, "app1", "doc1"), ErrApplicantDocumentLimit)
+	assert.Empty(t, documents.updates)
+}
+
+func TestUndeleteOtherClientsDocument(t *testing.T) {
+	svc := &DocumentServiceImpl{}
+	documents := &deletedDocument{deletedAt: timestamp.Now()}
+
+	err := svc.undelete(context.Background(), documents, documentApplicant{ClientID: "client2"}, "app1", "doc1")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// unitOfWork applies writes to documents and invalidates the cached reads they affect
+// only once the writes are confirmed, so a write that matched nothing never evicts
+// a cache entry.
+type unitOfWork struct {
+	c              *gin.Context
+	collection     common.CollectionInterface
+	collectionName string
+	cache          *DocumentCache
+	confirmed      []string // Cache keys of the writes that matched a document
+}
+
+func newUnitOfWork(c *gin.Context, collectionName string, collection common.CollectionInterface, cache *DocumentCache) *unitOfWork {
+	return &unitOfWork{c: c, collection: collection, collectionName: collectionName, cache: cache}
+}
+
+// Update applies update to the single document matching filter. It returns
+// ErrDocumentNotFound if no document matched.
+func (u *unitOfWork) Update(op string, filter, update bson.M, cacheKey string) error {
+	if err := mongoschema.ValidateUpdate(u.collectionName, update); err != nil {
+		return err
+	}
+	var matched int64
+	err := mongoretry.Write(u.c.Request.Context(), op, func(ctx context.Context) error {
+		result, err := u.collection.UpdateOne(ctx, filter, update)
+		if err == nil {
+			matched = result.MatchedCount
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not update document: %w", err)
+	}
+	if matched == 0 {
+		return ErrDocumentNotFound
+	}
+	u.confirmed = append(u.confirmed, cacheKey)
+	return nil
+}
+
+// Commit drops the cache entries of every confirmed write, so the next read fetches the
+// updated document
+func (u *unitOfWork) Commit() {
+	for _, cacheKey := range u.confirmed {
+		u.cache.evict(cacheKey)
+	}
+	u.confirmed = nil
+}
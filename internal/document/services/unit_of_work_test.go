@@ -0,0 +1,43 @@
+package services
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mocks "github.com/rachel-lawrie/verus_backend_core/mocks"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestUnitOfWorkOnlyKeepsConfirmedWrites(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("PUT", "/documents/doc1", nil)
+
+	filter := bson.M{"applicant_id": "applicant1", "document_id": "doc1", "deleted": false}
+	update := bson.M{"$set": bson.M{"status": models.DocumentVerified}}
+
+	missing := new(mocks.MockCollection)
+	missing.On("UpdateOne", mock.Anything, filter, update, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	cache := NewDocumentCache(time.Minute, time.Minute)
+	cache.set("key1", models.Document{DocumentID: "doc1", Status: models.DocumentUploaded})
+	work := newUnitOfWork(c, "documents", missing, cache)
+	err := work.Update("update_document_status", filter, update, "key1")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	work.Commit()
+	_, found := cache.get("key1")
+	assert.True(t, found, "a write that matched nothing must not invalidate the cache")
+
+	matched := new(mocks.MockCollection)
+	matched.On("UpdateOne", mock.Anything, filter, update, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	work = newUnitOfWork(c, "documents", matched, cache)
+	assert.NoError(t, work.Update("update_document_status", filter, update, "key1"))
+	work.Commit()
+	_, found = cache.get("key1")
+	assert.False(t, found, "a confirmed write must evict the cached document")
+	matched.AssertNumberOfCalls(t, "UpdateOne", 1)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+	mocks "github.com/rachel-lawrie/verus_backend_core/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+// Sizes of the files the upload benchmarks run with, from a phone photo to near the limit
+var benchmarkSizes = []int{256 << 10, 2 << 20, 8 << 20}
+
+// pngFile returns size bytes that sniff as a PNG
+func pngFile(b testing.TB, size int) []byte {
+	content := make([]byte, size)
+	if _, err := rand.Read(content); err != nil {
+		b.Fatal(err)
+	}
+	copy(content, "\x89PNG\r\n\x1a\n")
+	return content
+}
+
+// uploadFormBody builds a v1 document upload form holding content
+func uploadFormBody(b testing.TB, content []byte) ([]byte, string) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("applicant_id", "applicant1")
+	writer.WriteField("document_type", "passport")
+	writer.WriteField("country", "GB")
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="document"; filename="passport.png"`)
+	header.Set("Content-Type", "image/png")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		b.Fatal(err)
+	}
+	part.Write(content)
+	writer.Close()
+	return body.Bytes(), writer.FormDataContentType()
+}
+
+// parsedUpload reads an upload form the way the handler does
+func parsedUpload(b testing.TB, body []byte, contentType string) (*gin.Context, multipart.File, *multipart.FileHeader) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/documents", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+	file, header, _, _, err := readDocumentFile(c)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return c, file, header
+}
+
+func BenchmarkReadDocumentFile(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	for _, size := range benchmarkSizes {
+		body, contentType := uploadFormBody(b, pngFile(b, size))
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, file, _ := parsedUpload(b, body, contentType)
+				file.Close()
+			}
+		})
+	}
+}
+
+func BenchmarkCheckDocumentFile(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	for _, size := range benchmarkSizes {
+		body, contentType := uploadFormBody(b, pngFile(b, size))
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			_, file, header := parsedUpload(b, body, contentType)
+			defer file.Close()
+
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				record := localModels.DocumentRecord{Document: createDocumentObject("applicant1", "passport", "GB")}
+				if _, err := checkDocumentFile(file, header.Size, "image/png", "GB", "", &record); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkKMS hands out a fixed data key without calling AWS
+type benchmarkKMS struct{}
+
+func (benchmarkKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	return bytes.Repeat([]byte{7}, 32), []byte("encrypted-key"), nil
+}
+
+func (benchmarkKMS) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return plaintext, nil
+}
+
+func (benchmarkKMS) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	return encrypted, nil
+}
+
+// sealingUploader stands in for S3. It reads and envelope encrypts the file as an
+// upload would, then discards it, so the benchmark covers this side of the put
+// without the network.
+type sealingUploader struct{}
+
+func (sealingUploader) UploadFile(ctx context.Context, file multipart.File, fileName string, mimeType string, kmsUploader interfaces.KMSUploader) (string, error) {
+	key, _, err := kmsUploader.GenerateDataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	gcm.Seal(nil, nonce, content, nil)
+	return "https://bucket.s3.amazonaws.com/" + fileName, nil
+}
+
+func (sealingUploader) DownloadFile(ctx context.Context, objectKey string) (*s3.GetObjectOutput, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func BenchmarkStoreUpload(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	service := &DocumentServiceImpl{Uploader: sealingUploader{}, KMSUploader: benchmarkKMS{}}
+	collection := new(mocks.MockCollection)
+	collection.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+
+	for _, size := range benchmarkSizes {
+		body, contentType := uploadFormBody(b, pngFile(b, size))
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			c, file, _ := parsedUpload(b, body, contentType)
+			defer file.Close()
+
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					b.Fatal(err)
+				}
+				record := localModels.DocumentRecord{Document: createDocumentObject("applicant1", "passport", "GB"), ClientID: "client1"}
+				service.stageUpload(&record, file, record.DocumentID+".png", "image/png")
+				if !service.storeUpload(c, collection, "applicant1", &record, file) {
+					b.Fatal("upload was left for reconciliation")
+				}
+			}
+		})
+	}
+}
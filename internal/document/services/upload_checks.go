@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+const maxUploadBytes = 10 << 20 // Largest file accepted by the upload endpoint
+
+// Standard antivirus test signature, used to verify the scan path end to end
+var eicarSignature = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+// Byte patterns that have no place in an identity document
+var suspiciousPatterns = map[string][]byte{
+	"embedded javascript": []byte("/JavaScript"),
+	"auto-run action":     []byte("/OpenAction"),
+	"launch action":       []byte("/Launch"),
+	"embedded file":       []byte("/EmbeddedFile"),
+}
+
+// runUploadChecks runs the synchronous checks on an uploaded file and rewinds it for the S3 upload
+func runUploadChecks(file io.ReadSeeker, size int64, declaredMIME string) ([]localModels.UploadCheck, error) {
+	checks := []localModels.UploadCheck{checkSize(size)}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return checks, fmt.Errorf("unable to read file: %v", err)
+	}
+	checks = append(checks, checkMIME(head[:n], declaredMIME))
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return checks, fmt.Errorf("unable to rewind file: %v", err)
+	}
+
+	// Scan a file held in memory in place, otherwise stream it through the scan a window at
+	// a time, so every byte is scanned before the file is stored
+	if held, ok := file.(interface{ Bytes() []byte }); ok {
+		checks = append(checks, quickScan(held.Bytes(), declaredMIME))
+		return checks, nil
+	}
+	check, _, err := scanStream(io.LimitReader(file, maxUploadBytes+1), declaredMIME)
+	if err != nil {
+		return checks, fmt.Errorf("unable to read file: %v", err)
+	}
+	checks = append(checks, check)
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return checks, fmt.Errorf("unable to rewind file: %v", err)
+	}
+	return checks, nil
+}
+
+// checkSize verifies the file is neither empty nor above the upload limit
+func checkSize(size int64) localModels.UploadCheck {
+	check := localModels.UploadCheck{Name: "size", Status: localModels.UploadCheckPassed}
+	switch {
+	case size <= 0:
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = "file is empty"
+	case size > maxUploadBytes:
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = fmt.Sprintf("file exceeds %d bytes", maxUploadBytes)
+	}
+	return check
+}
+
+// checkMIME sniffs the file content and compares it with the declared MIME type
+func checkMIME(head []byte, declaredMIME string) localModels.UploadCheck {
+	check := localModels.UploadCheck{Name: "mime_sniff", Status: localModels.UploadCheckPassed}
+	if len(head) == 0 {
+		check.Status = localModels.UploadCheckSkipped
+		check.Detail = "no content to sniff"
+		return check
+	}
+	detected := http.DetectContentType(head)
+	if detected != declaredMIME {
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = fmt.Sprintf("declared %s but content is %s", declaredMIME, detected)
+	}
+	return check
+}
+
+// quickScan looks for known malicious markers in the file content
+func quickScan(content []byte, declaredMIME string) localModels.UploadCheck {
+	check := localModels.UploadCheck{Name: "quick_scan", Status: localModels.UploadCheckPassed}
+	if bytes.Contains(content, eicarSignature) {
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = "file matches antivirus test signature"
+		return check
+	}
+	if declaredMIME != "application/pdf" {
+		return check
+	}
+	for reason, pattern := range suspiciousPatterns {
+		if bytes.Contains(content, pattern) {
+			check.Status = localModels.UploadCheckFailed
+			check.Detail = "pdf contains " + reason
+			return check
+		}
+	}
+	return check
+}
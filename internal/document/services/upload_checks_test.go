@@ -0,0 +1,64 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// large pads content to 6MB and ends it with tail, which the scan can only find by reading
+// the whole file
+func large(content, tail []byte) []byte {
+	out := make([]byte, 6<<20, 6<<20+len(tail))
+	copy(out, content)
+	return append(out, tail...)
+}
+
+func TestRunUploadChecks(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	tests := []struct {
+		name         string
+		content      []byte
+		size         int64
+		mimeType     string
+		wantStatus   localModels.ProcessingStatus
+		failingCheck string
+	}{
+		{"Valid PDF", pdf, int64(len(pdf)), "application/pdf", localModels.ProcessingAccepted, ""},
+		{"Valid PNG", png, int64(len(png)), "image/png", localModels.ProcessingAccepted, ""},
+		{"Declared PDF but PNG content", png, int64(len(png)), "application/pdf", localModels.ProcessingRejected, "mime_sniff"},
+		{"Empty file", []byte{}, 0, "application/pdf", localModels.ProcessingRejected, "size"},
+		{"Oversized file", pdf, maxUploadBytes + 1, "application/pdf", localModels.ProcessingRejected, "size"},
+		{"EICAR test file", append(append([]byte{}, pdf...), eicarSignature...), int64(len(pdf) + len(eicarSignature)), "application/pdf", localModels.ProcessingRejected, "quick_scan"},
+		{"PDF with JavaScript", append(append([]byte{}, pdf...), []byte("/JavaScript")...), int64(len(pdf) + 11), "application/pdf", localModels.ProcessingRejected, "quick_scan"},
+		{"Large file", large(pdf, nil), 6 << 20, "application/pdf", localModels.ProcessingAccepted, ""},
+		{"Large EICAR test file", large(pdf, eicarSignature), 6<<20 + int64(len(eicarSignature)), "application/pdf", localModels.ProcessingRejected, "quick_scan"},
+		{"Large PDF with launch action", large(pdf, []byte("/Launch")), 6<<20 + 7, "application/pdf", localModels.ProcessingRejected, "quick_scan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := bytes.NewReader(tt.content)
+
+			checks, err := runUploadChecks(file, tt.size, tt.mimeType)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, localModels.OverallStatus(checks))
+
+			for _, check := range checks {
+				if check.Name == tt.failingCheck {
+					assert.Equal(t, localModels.UploadCheckFailed, check.Status)
+				} else {
+					assert.NotEqual(t, localModels.UploadCheckFailed, check.Status, check.Name)
+				}
+			}
+
+			// The file must be rewound for the S3 upload
+			pos, _ := file.Seek(0, 1)
+			assert.Equal(t, int64(0), pos)
+		})
+	}
+}
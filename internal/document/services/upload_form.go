@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+)
+
+// MaxFormMemory is how much of an uploaded file is held in a pooled buffer, 2MB, before
+// the file is spooled to a temp file instead
+const MaxFormMemory = 2 << 20
+
+const (
+	documentField     = "document" // Form field holding the uploaded file
+	maxFormValueBytes = 1 << 20    // Total size of the form's text fields
+	maxPooledBuffer   = 8 << 20    // Larger buffers are left to the garbage collector rather than pooled
+	uploadFormKey     = "uploadForm"
+)
+
+var (
+	// ErrInvalidUploadForm is returned for an upload that is not a readable multipart form
+	ErrInvalidUploadForm = apperr.BadRequest("invalid_upload_form", "unable to parse form data")
+	// ErrUnknownMimeType is returned for an uploaded file that does not say what type it is
+	ErrUnknownMimeType = apperr.BadRequest("unknown_mime_type", "unable to determine MIME type")
+	// ErrUnsupportedMimeType is returned for an uploaded file of a type documents cannot be
+	ErrUnsupportedMimeType = apperr.BadRequest("unsupported_mime_type", "unsupported MIME type")
+)
+
+// requiredField returns the error for a form field the upload left out
+func requiredField(name string) error {
+	return apperr.Invalid(fmt.Errorf("%s is required", name), map[string]string{name: "required"})
+}
+
+var (
+	// formBuffers hold uploaded files and quick scan reads, so concurrent uploads reuse
+	// memory instead of growing a new buffer each
+	formBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	// copyBuffers are used to spool large files to disk
+	copyBuffers = sync.Pool{New: func() any { buf := make([]byte, 32<<10); return &buf }}
+)
+
+func getBuffer() *bytes.Buffer {
+	buf := formBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		formBuffers.Put(buf)
+	}
+}
+
+// uploadForm is an upload form read in one pass over the request body
+type uploadForm struct {
+	file   multipart.File
+	header *multipart.FileHeader
+}
+
+// ParseUploadForm reads a document upload form part by part, copying the file out of the
+// request once rather than buffering the whole form as ParseMultipartForm does. The text
+// fields are then available from the request's FormValue and PostForm as usual. The form
+// is kept on the context, so calling it again, e.g. from the controller and then the
+// service, does not read the body twice.
+func ParseUploadForm(c *gin.Context) error {
+	_, err := parseUploadForm(c)
+	return err
+}
+
+func parseUploadForm(c *gin.Context) (*uploadForm, error) {
+	if form, ok := c.Get(uploadFormKey); ok {
+		return form.(*uploadForm), nil
+	}
+	r := c.Request
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUploadForm, err)
+	}
+
+	form := &uploadForm{}
+	values := make(url.Values)
+	valueBytes := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			form.close()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUploadForm, err)
+		}
+		name := part.FormName()
+		switch {
+		case name == "":
+		case part.FileName() == "":
+			value, err := io.ReadAll(io.LimitReader(part, int64(maxFormValueBytes-valueBytes+1)))
+			if err != nil {
+				form.close()
+				return nil, fmt.Errorf("%w: %v", ErrInvalidUploadForm, err)
+			}
+			valueBytes += len(value)
+			if valueBytes > maxFormValueBytes {
+				form.close()
+				return nil, fmt.Errorf("%w: fields exceed %d bytes", ErrInvalidUploadForm, maxFormValueBytes)
+			}
+			values.Add(name, string(value))
+		case name == documentField && form.file == nil:
+			form.file, form.header, err = spoolFile(part)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read the file: %v", err)
+			}
+		}
+		// Anything left of the part, such as other files, is skipped by NextPart
+	}
+
+	// Fill in the request's form as ParseMultipartForm would, minus the files
+	r.MultipartForm = &multipart.Form{Value: values}
+	r.PostForm = values
+	r.Form = make(url.Values)
+	for name, value := range values {
+		r.Form[name] = append(r.Form[name], value...)
+	}
+	for name, value := range r.URL.Query() {
+		r.Form[name] = append(r.Form[name], value...)
+	}
+	c.Set(uploadFormKey, form)
+	return form, nil
+}
+
+// close releases the form's file, if one was read
+func (f *uploadForm) close() {
+	if f.file != nil {
+		f.file.Close()
+	}
+}
+
+// spoolFile copies an uploaded file out of the request into a pooled buffer or, once it
+// is larger than MaxFormMemory, a temp file. Only enough of an oversized file is kept for
+// the size check to reject it; the rest is counted and discarded.
+func spoolFile(part *multipart.Part) (multipart.File, *multipart.FileHeader, error) {
+	header := &multipart.FileHeader{Filename: part.FileName(), Header: part.Header}
+
+	buf := getBuffer()
+	n, err := buf.ReadFrom(io.LimitReader(part, MaxFormMemory+1))
+	if err != nil {
+		putBuffer(buf)
+		return nil, nil, err
+	}
+	if n <= MaxFormMemory {
+		header.Size = n
+		return &memoryFile{Reader: bytes.NewReader(buf.Bytes()), buffer: buf}, header, nil
+	}
+
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		putBuffer(buf)
+		return nil, nil, err
+	}
+	file := diskFile{File: tmp}
+	_, err = tmp.Write(buf.Bytes())
+	putBuffer(buf)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	copyBuf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(copyBuf)
+	// Hide the file's ReadFrom so the pooled buffer is used
+	kept, err := io.CopyBuffer(struct{ io.Writer }{tmp}, io.LimitReader(part, maxUploadBytes+1-n), *copyBuf)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	discarded, err := io.CopyBuffer(io.Discard, part, *copyBuf)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	header.Size = n + kept + discarded
+	return file, header, nil
+}
+
+// memoryFile is an uploaded file held in a pooled buffer, which Close returns to the pool
+type memoryFile struct {
+	*bytes.Reader
+	buffer *bytes.Buffer
+}
+
+// Bytes returns the whole file without copying it. It is only valid until Close.
+func (f *memoryFile) Bytes() []byte {
+	if f.buffer == nil {
+		return nil
+	}
+	return f.buffer.Bytes()
+}
+
+func (f *memoryFile) Close() error {
+	if f.buffer != nil {
+		f.Reader.Reset(nil)
+		putBuffer(f.buffer)
+		f.buffer = nil
+	}
+	return nil
+}
+
+// diskFile is an uploaded file spooled to a temp file, which Close removes
+type diskFile struct {
+	*os.File
+}
+
+func (f diskFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// readDocumentFile parses the multipart form and returns the uploaded document file with its type
+func readDocumentFile(c *gin.Context) (multipart.File, *multipart.FileHeader, string, string, error) {
+	form, err := parseUploadForm(c)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+	if form.file == nil {
+		return nil, nil, "", "", requiredField(documentField)
+	}
+	file, fileHeader := form.file, form.header
+
+	// Get MIME type of the uploaded file
+	mimeType := fileHeader.Header.Get("Content-Type")
+	if mimeType == "" {
+		file.Close()
+		return nil, nil, "", "", ErrUnknownMimeType
+	}
+
+	// Check for known MIME types and return an error if unsupported
+	if _, ok := mimeTypeToExtension[mimeType]; !ok {
+		file.Close()
+		return nil, nil, "", "", fmt.Errorf("%w: %s", ErrUnsupportedMimeType, mimeType)
+	}
+
+	// Determine the file extension based on MIME type
+	ext, err := GetFileExtension(mimeType)
+	if err != nil {
+		file.Close()
+		return nil, nil, "", "", err
+	}
+	return file, fileHeader, mimeType, ext, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadContext returns a context for a multipart request with the given fields and,
+// when content is not nil, a document part holding it
+func uploadContext(t *testing.T, target string, fields map[string]string, content []byte) *gin.Context {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	if content != nil {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="document"; filename="passport.png"`)
+		header.Set("Content-Type", "image/png")
+		part, err := writer.CreatePart(header)
+		require.NoError(t, err)
+		part.Write(content)
+	}
+	require.NoError(t, writer.Close())
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, target, &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c
+}
+
+func TestReadDocumentFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		wantDisk bool
+		wantKept int64 // Bytes of the file that can be read back
+	}{
+		{name: "Small file is held in memory", size: 1 << 10, wantKept: 1 << 10},
+		{name: "Large file is spooled to disk", size: MaxFormMemory + 1, wantDisk: true, wantKept: MaxFormMemory + 1},
+		{name: "Oversized file is counted but not kept", size: maxUploadBytes + 4096, wantDisk: true, wantKept: maxUploadBytes + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := bytes.Repeat([]byte("a"), tt.size)
+			c := uploadContext(t, "/documents?source=sdk", map[string]string{"applicant_id": "applicant1", "country": "GB"}, content)
+
+			file, header, mimeType, ext, err := readDocumentFile(c)
+			require.NoError(t, err)
+			assert.Equal(t, "image/png", mimeType)
+			assert.Equal(t, ".png", ext)
+			assert.Equal(t, "passport.png", header.Filename)
+			assert.Equal(t, int64(tt.size), header.Size)
+
+			// Fields are available as if ParseMultipartForm had run
+			assert.Equal(t, "applicant1", c.Request.FormValue("applicant_id"))
+			assert.Equal(t, "GB", c.PostForm("country"))
+			assert.Equal(t, "sdk", c.Request.FormValue("source"))
+
+			read, err := io.ReadAll(file)
+			require.NoError(t, err)
+			assert.Equal(t, content[:tt.wantKept], read)
+
+			disk, onDisk := file.(diskFile)
+			assert.Equal(t, tt.wantDisk, onDisk)
+			require.NoError(t, file.Close())
+			if onDisk {
+				_, err := os.Stat(disk.Name())
+				assert.True(t, os.IsNotExist(err), "temp file should be removed on close")
+			}
+		})
+	}
+}
+
+func TestParseUploadFormOnce(t *testing.T) {
+	c := uploadContext(t, "/documents", map[string]string{"document_type": "passport"}, []byte("\x89PNG\r\n\x1a\n"))
+
+	// The controller reads the form first and sets a field, which the service then sees
+	require.NoError(t, ParseUploadForm(c))
+	c.Request.Form.Set("applicant_id", "applicant1")
+
+	file, header, _, _, err := readDocumentFile(c)
+	require.NoError(t, err)
+	defer file.Close()
+	assert.Equal(t, int64(8), header.Size)
+	assert.Equal(t, "applicant1", c.Request.FormValue("applicant_id"))
+	assert.Equal(t, "passport", c.Request.FormValue("document_type"))
+}
+
+func TestReadDocumentFileErrors(t *testing.T) {
+	t.Run("Missing file", func(t *testing.T) {
+		c := uploadContext(t, "/documents", map[string]string{"applicant_id": "applicant1"}, nil)
+		_, _, _, _, err := readDocumentFile(c)
+		var invalid *apperr.ErrValidation
+		require.ErrorAs(t, err, &invalid)
+		assert.Equal(t, map[string]string{"document": "required"}, invalid.Fields)
+	})
+
+	t.Run("Fields too large", func(t *testing.T) {
+		c := uploadContext(t, "/documents", map[string]string{"mrz": strings.Repeat("<", maxFormValueBytes+1)}, []byte("x"))
+		_, _, _, _, err := readDocumentFile(c)
+		assert.ErrorIs(t, err, ErrInvalidUploadForm)
+		assert.Contains(t, err.Error(), "fields exceed")
+	})
+
+	t.Run("Not a multipart form", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/documents", strings.NewReader(`{}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		_, _, _, _, err := readDocumentFile(c)
+		assert.ErrorIs(t, err, ErrInvalidUploadForm)
+		assert.ErrorIs(t, err, apperr.ErrBadRequest)
+	})
+}
+
+func TestMemoryFileClose(t *testing.T) {
+	buf := getBuffer()
+	buf.WriteString("content")
+	file := &memoryFile{Reader: bytes.NewReader(buf.Bytes()), buffer: buf}
+	assert.Equal(t, []byte("content"), file.Bytes())
+
+	require.NoError(t, file.Close())
+	require.NoError(t, file.Close())
+	assert.Nil(t, file.Bytes())
+	n, err := file.Read(make([]byte, 8))
+	assert.Zero(t, n)
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+// ErrUploadJobNotFound is returned when the client has no upload with the given job ID, or its progress has expired
+var ErrUploadJobNotFound = apperr.NotFound("upload_job_not_found", "upload job not found")
+
+// uploadJobRetention is how long an upload's progress can be read after it last changed
+const uploadJobRetention = 24 * time.Hour
+
+// uploadJobKeyPrefix namespaces upload jobs among the keys in Redis
+const uploadJobKeyPrefix = "verus:upload_jobs:"
+
+// MongoUploadJobStore keeps upload progress in a collection shared by every replica,
+// whose TTL index forgets it a day after the upload last moved on. It serves deployments
+// without Redis.
+type MongoUploadJobStore struct {
+	CollectionName string
+}
+
+// NewMongoUploadJobStore creates an upload job store in the upload jobs collection
+func NewMongoUploadJobStore() *MongoUploadJobStore {
+	return &MongoUploadJobStore{CollectionName: localConstants.CollectionUploadJobs}
+}
+
+func (s *MongoUploadJobStore) Save(ctx context.Context, job localModels.UploadJob) error {
+	job.ExpiresAt = job.UpdatedAt.Add(uploadJobRetention)
+	_, err := common.GetCollection(s.CollectionName).ReplaceOne(ctx, bson.M{"job_id": job.JobID}, job, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save upload job: %w", err)
+	}
+	return nil
+}
+
+func (s *MongoUploadJobStore) Get(ctx context.Context, clientID, jobID string) (localModels.UploadJob, error) {
+	var job localModels.UploadJob
+	err := common.GetCollection(s.CollectionName).FindOne(ctx, bson.M{"job_id": jobID, "client_id": clientID}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return localModels.UploadJob{}, ErrUploadJobNotFound
+	}
+	if err != nil {
+		return localModels.UploadJob{}, fmt.Errorf("failed to look up upload job: %w", err)
+	}
+	return job, nil
+}
+
+// RedisUploadJobStore keeps upload progress in Redis, shared by every replica, which
+// forgets it a day after the upload last moved on. Progress changes several times per
+// upload, so this spares MongoDB a write at each stage.
+type RedisUploadJobStore struct {
+	Client *redis.Client
+}
+
+// NewRedisUploadJobStore creates an upload job store in the Redis server client connects to
+func NewRedisUploadJobStore(client *redis.Client) *RedisUploadJobStore {
+	return &RedisUploadJobStore{Client: client}
+}
+
+func (s *RedisUploadJobStore) Save(ctx context.Context, job localModels.UploadJob) error {
+	job.ExpiresAt = job.UpdatedAt.Add(uploadJobRetention)
+	// BSON keeps the client, which the job's JSON leaves out
+	encoded, err := bson.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload job: %w", err)
+	}
+	if err := s.Client.Set(ctx, uploadJobKeyPrefix+job.JobID, encoded, uploadJobRetention).Err(); err != nil {
+		return fmt.Errorf("failed to save upload job: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisUploadJobStore) Get(ctx context.Context, clientID, jobID string) (localModels.UploadJob, error) {
+	encoded, err := s.Client.Get(ctx, uploadJobKeyPrefix+jobID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return localModels.UploadJob{}, ErrUploadJobNotFound
+	}
+	if err != nil {
+		return localModels.UploadJob{}, fmt.Errorf("failed to look up upload job: %w", err)
+	}
+	var job localModels.UploadJob
+	if err := bson.Unmarshal(encoded, &job); err != nil {
+		return localModels.UploadJob{}, fmt.Errorf("failed to decode upload job: %w", err)
+	}
+	// Another client's job is as unknown as one nobody started
+	if job.ClientID != clientID {
+		return localModels.UploadJob{}, ErrUploadJobNotFound
+	}
+	return job, nil
+}
+
+// GetUploadJob returns the progress of one of the client's uploads
+func (s *DocumentServiceImpl) GetUploadJob(ctx context.Context, clientID, jobID string) (localModels.UploadJob, error) {
+	if s.Jobs == nil {
+		return localModels.UploadJob{}, ErrUploadJobNotFound
+	}
+	return s.Jobs.Get(ctx, clientID, jobID)
+}
+
+// uploadTracker records an upload's progress as it moves through the pipeline. Progress
+// is informational, so failing to record it is logged and does not fail the upload.
+// A tracker without a store records nothing.
+type uploadTracker struct {
+	store localInterfaces.UploadJobStore
+	job   localModels.UploadJob
+}
+
+// trackUpload starts tracking an upload of a document, at the checking stage
+func (s *DocumentServiceImpl) trackUpload(ctx context.Context, record localModels.DocumentRecord) *uploadTracker {
+	if s.Jobs == nil {
+		return &uploadTracker{}
+	}
+	now := timestamp.Now()
+	t := &uploadTracker{store: s.Jobs, job: localModels.UploadJob{
+		JobID:       ids.New(),
+		ClientID:    record.ClientID,
+		ApplicantID: record.ApplicantID,
+		DocumentID:  record.DocumentID,
+		Stage:       localModels.UploadJobChecking,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}}
+	t.save(ctx)
+	return t
+}
+
+// jobID is the ID clients read the upload's progress with, or empty when nothing is tracked
+func (t *uploadTracker) jobID() string {
+	if t.store == nil {
+		return ""
+	}
+	return t.job.JobID
+}
+
+// advance records that the upload reached a stage
+func (t *uploadTracker) advance(ctx context.Context, stage localModels.UploadJobStage, status localModels.ProcessingStatus) {
+	t.job.Stage, t.job.ProcessingStatus = stage, status
+	t.save(ctx)
+}
+
+// fail records that the upload stopped with an error
+func (t *uploadTracker) fail(ctx context.Context, status localModels.ProcessingStatus, err error) {
+	t.job.Stage, t.job.ProcessingStatus, t.job.Error = localModels.UploadJobFailed, status, err.Error()
+	t.save(ctx)
+}
+
+func (t *uploadTracker) save(ctx context.Context) {
+	if t.store == nil {
+		return
+	}
+	t.job.UpdatedAt = timestamp.Now()
+	if err := t.store.Save(ctx, t.job); err != nil {
+		zaplogger.GetLogger().Warn("Error recording upload progress", zap.Error(err), zap.String("jobID", t.job.JobID))
+	}
+}
+
+// completeUploadJob records the outcome of an upload the reconciler finished, if it was tracked
+func completeUploadJob(ctx context.Context, store localInterfaces.UploadJobStore, doc localModels.DocumentRecord, stage localModels.UploadJobStage, reason string) {
+	if store == nil || doc.Upload == nil || doc.Upload.JobID == "" {
+		return
+	}
+	job, err := store.Get(ctx, doc.ClientID, doc.Upload.JobID)
+	if err != nil {
+		// Progress of old uploads expires; there is nothing left to complete
+		return
+	}
+	t := &uploadTracker{store: store, job: job}
+	t.job.Stage, t.job.Error = stage, reason
+	t.save(ctx)
+}
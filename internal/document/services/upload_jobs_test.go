@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/redisclient"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryUploadJobStore keeps every saved version of each job, so tests can follow its stages
+type memoryUploadJobStore struct {
+	saved []localModels.UploadJob
+}
+
+func (m *memoryUploadJobStore) Save(ctx context.Context, job localModels.UploadJob) error {
+	m.saved = append(m.saved, job)
+	return nil
+}
+
+func (m *memoryUploadJobStore) Get(ctx context.Context, clientID, jobID string) (localModels.UploadJob, error) {
+	for i := len(m.saved) - 1; i >= 0; i-- {
+		if m.saved[i].JobID == jobID && m.saved[i].ClientID == clientID {
+			return m.saved[i], nil
+		}
+	}
+	return localModels.UploadJob{}, ErrUploadJobNotFound
+}
+
+func (m *memoryUploadJobStore) stages() []localModels.UploadJobStage {
+	var stages []localModels.UploadJobStage
+	for _, job := range m.saved {
+		stages = append(stages, job.Stage)
+	}
+	return stages
+}
+
+func trackedRecord() localModels.DocumentRecord {
+	return localModels.DocumentRecord{
+		Document: models.Document{DocumentID: "doc1", ApplicantID: "app1"},
+		ClientID: "client1",
+	}
+}
+
+func TestUploadTrackerStages(t *testing.T) {
+	store := &memoryUploadJobStore{}
+	s := &DocumentServiceImpl{Jobs: store}
+	ctx := context.Background()
+
+	tracker := s.trackUpload(ctx, trackedRecord())
+	require.NotEmpty(t, tracker.jobID())
+	tracker.advance(ctx, localModels.UploadJobStoring, localModels.ProcessingAccepted)
+	tracker.advance(ctx, localModels.UploadJobCompleted, localModels.ProcessingAccepted)
+
+	assert.Equal(t, []localModels.UploadJobStage{
+		localModels.UploadJobChecking, localModels.UploadJobStoring, localModels.UploadJobCompleted,
+	}, store.stages())
+
+	job, err := s.GetUploadJob(ctx, "client1", tracker.jobID())
+	require.NoError(t, err)
+	assert.Equal(t, "app1", job.ApplicantID)
+	assert.Equal(t, "doc1", job.DocumentID)
+	assert.Equal(t, localModels.ProcessingAccepted, job.ProcessingStatus)
+
+	// Another client cannot read the job
+	_, err = s.GetUploadJob(ctx, "client2", tracker.jobID())
+	assert.ErrorIs(t, err, ErrUploadJobNotFound)
+}
+
+func TestUploadTrackerFail(t *testing.T) {
+	store := &memoryUploadJobStore{}
+	s := &DocumentServiceImpl{Jobs: store}
+	ctx := context.Background()
+
+	tracker := s.trackUpload(ctx, trackedRecord())
+	tracker.fail(ctx, localModels.ProcessingRejected, errors.New("document failed upload checks"))
+
+	job, err := store.Get(ctx, "client1", tracker.jobID())
+	require.NoError(t, err)
+	assert.Equal(t, localModels.UploadJobFailed, job.Stage)
+	assert.Equal(t, localModels.ProcessingRejected, job.ProcessingStatus)
+	assert.Equal(t, "document failed upload checks", job.Error)
+}
+
+func TestUploadTrackerWithoutStore(t *testing.T) {
+	s := &DocumentServiceImpl{}
+	ctx := context.Background()
+
+	tracker := s.trackUpload(ctx, trackedRecord())
+	tracker.advance(ctx, localModels.UploadJobStoring, localModels.ProcessingAccepted)
+	assert.Empty(t, tracker.jobID(), "untracked uploads must not hand out a job ID")
+
+	_, err := s.GetUploadJob(ctx, "client1", "job1")
+	assert.ErrorIs(t, err, ErrUploadJobNotFound)
+}
+
+func TestCompleteUploadJob(t *testing.T) {
+	store := &memoryUploadJobStore{}
+	s := &DocumentServiceImpl{Jobs: store}
+	ctx := context.Background()
+
+	record := trackedRecord()
+	tracker := s.trackUpload(ctx, record)
+	tracker.advance(ctx, localModels.UploadJobStoring, localModels.ProcessingScanPending)
+	record.Upload = &localModels.StorageUpload{JobID: tracker.jobID()}
+
+	completeUploadJob(ctx, store, record, localModels.UploadJobFailed, "the staged copy of the file is missing")
+
+	job, err := store.Get(ctx, "client1", tracker.jobID())
+	require.NoError(t, err)
+	assert.Equal(t, localModels.UploadJobFailed, job.Stage)
+	assert.Equal(t, localModels.ProcessingScanPending, job.ProcessingStatus, "the checks' outcome is kept")
+	assert.Equal(t, "the staged copy of the file is missing", job.Error)
+
+	// Untracked uploads are left alone
+	saves := len(store.saved)
+	record.Upload.JobID = ""
+	completeUploadJob(ctx, store, record, localModels.UploadJobCompleted, "")
+	assert.Len(t, store.saved, saves)
+}
+
+func TestRedisUploadJobStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client, err := redisclient.New("redis://" + server.Addr())
+	require.NoError(t, err)
+	store := NewRedisUploadJobStore(client)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job := localModels.UploadJob{
+		JobID: "job1", ClientID: "client1", ApplicantID: "app1", DocumentID: "doc1",
+		Stage: localModels.UploadJobStoring, ProcessingStatus: localModels.ProcessingAccepted,
+		CreatedAt: now, UpdatedAt: now,
+	}
+	require.NoError(t, store.Save(ctx, job))
+
+	got, err := store.Get(ctx, "client1", "job1")
+	require.NoError(t, err)
+	job.ExpiresAt = now.Add(uploadJobRetention)
+	assert.Equal(t, job, got)
+
+	// Another client's job is not found, like one that does not exist
+	_, err = store.Get(ctx, "client2", "job1")
+	assert.ErrorIs(t, err, ErrUploadJobNotFound)
+	_, err = store.Get(ctx, "client1", "job2")
+	assert.ErrorIs(t, err, ErrUploadJobNotFound)
+
+	// Forgotten a day after the upload last moved on
+	server.FastForward(uploadJobRetention + time.Minute)
+	_, err = store.Get(ctx, "client1", "job1")
+	assert.ErrorIs(t, err, ErrUploadJobNotFound)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrTooManyUploads is returned when a client already has as many uploads in progress as it may
+	ErrTooManyUploads = apperr.QuotaExceeded("too_many_uploads", "too many uploads in progress")
+	// ErrApplicantDocumentLimit is returned for an upload that would give an applicant more documents than its level allows
+	ErrApplicantDocumentLimit = apperr.Conflict("applicant_document_limit", "applicant has reached its document limit")
+	// ErrApplicantStorageLimit is returned for an upload that would take an applicant's files over the size its level allows
+	ErrApplicantStorageLimit = apperr.Conflict("applicant_storage_limit", "applicant has reached its storage limit")
+)
+
+// UploadLimits bounds the documents each applicant may have, by verification level, and
+// the uploads each client may have in progress. Uploads in progress are counted per
+// instance, as with authguard.
+type UploadLimits struct {
+	settings config.UploadLimitSettings
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// NewUploadLimits creates the upload limits, or returns nil when nothing is limited
+func NewUploadLimits(settings config.UploadLimitSettings) *UploadLimits {
+	limited := settings.MaxConcurrentPerClient > 0 || settings.Default != (config.ApplicantDocumentLimit{})
+	for _, limit := range settings.Levels {
+		limited = limited || limit != (config.ApplicantDocumentLimit{})
+	}
+	if !limited {
+		return nil
+	}
+	return &UploadLimits{settings: settings, inFlight: make(map[string]int)}
+}
+
+// begin counts an upload of the client as in progress until the returned func is called,
+// or returns ErrTooManyUploads
+func (l *UploadLimits) begin(clientID string) (func(), error) {
+	if l == nil || l.settings.MaxConcurrentPerClient <= 0 {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[clientID] >= l.settings.MaxConcurrentPerClient {
+		return nil, fmt.Errorf("%w: the client may have %d at once; retry once one finishes", ErrTooManyUploads, l.settings.MaxConcurrentPerClient)
+	}
+	l.inFlight[clientID]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.inFlight[clientID]--; l.inFlight[clientID] <= 0 {
+				delete(l.inFlight, clientID)
+			}
+		})
+	}, nil
+}
+
+// forLevel returns the document limit of applicants at a verification level
+func (l *UploadLimits) forLevel(level string) config.ApplicantDocumentLimit {
+	for name, limit := range l.settings.Levels {
+		if strings.EqualFold(name, level) {
+			return limit
+		}
+	}
+	return l.settings.Default
+}
+
+// applicantUsage is what an applicant's current documents count against its limit
+type applicantUsage struct {
+	Documents int
+	Bytes     int64
+}
+
+// checkApplicantLimit returns an error if adding documents and bytes to an applicant's
+// usage would take it over its level's limit
+func checkApplicantLimit(limit config.ApplicantDocumentLimit, level string, usage applicantUsage, documents int, bytes int64) error {
+	if limit.MaxDocuments > 0 && documents > 0 && usage.Documents+documents > limit.MaxDocuments {
+		return fmt.Errorf("%w: applicants at level %s may have %d documents and this one has %d", ErrApplicantDocumentLimit, level, limit.MaxDocuments, usage.Documents)
+	}
+	if limit.MaxBytes > 0 && bytes > 0 && usage.Bytes+bytes > limit.MaxBytes {
+		return fmt.Errorf("%w: applicants at level %s may have %d bytes of documents and this one has %d, so a file of %d bytes does not fit", ErrApplicantStorageLimit, level, limit.MaxBytes, usage.Bytes, bytes)
+	}
+	return nil
+}
+
+// checkApplicantLimits returns an error if adding documents and bytes would take an
+// applicant over its level's limit. A replacement adds no document, and only the
+// difference in size.
+func (s *DocumentServiceImpl) checkApplicantLimits(ctx context.Context, collection common.CollectionInterface, applicant documentApplicant, applicantID string, documents int, bytes int64) error {
+	if s.Limits == nil {
+		return nil
+	}
+	limit := s.Limits.forLevel(applicant.VerificationLevel)
+	if limit == (config.ApplicantDocumentLimit{}) {
+		return nil
+	}
+	usage, err := currentUsage(ctx, collection, tenant.Of(applicant.ClientID), applicantID)
+	if err != nil {
+		return err
+	}
+	return checkApplicantLimit(limit, applicant.VerificationLevel, usage, documents, bytes)
+}
+
+// currentUsage counts an applicant's documents that are not deleted and adds up their current files
+func currentUsage(ctx context.Context, collection common.CollectionInterface, scope tenant.Filter, applicantID string) (applicantUsage, error) {
+	opts := options.Find().SetProjection(bson.M{"file_size": 1})
+	cursor, err := tenant.Guard(collection).Find(ctx, scope.With("applicant_id", applicantID).With("deleted", false), opts)
+	if err != nil {
+		return applicantUsage{}, fmt.Errorf("failed to count applicant documents: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var usage applicantUsage
+	for cursor.Next(ctx) {
+		var doc struct {
+			FileSize int64 `bson:"file_size"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return applicantUsage{}, fmt.Errorf("failed to count applicant documents: %w", err)
+		}
+		usage.Documents++
+		usage.Bytes += doc.FileSize
+	}
+	return usage, cursor.Err()
+}
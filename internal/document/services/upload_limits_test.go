@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUploadLimits(t *testing.T) {
+	assert.Nil(t, NewUploadLimits(config.UploadLimitSettings{}))
+	assert.Nil(t, NewUploadLimits(config.UploadLimitSettings{Levels: map[string]config.ApplicantDocumentLimit{"basic": {}}}))
+	assert.NotNil(t, NewUploadLimits(config.UploadLimitSettings{Levels: map[string]config.ApplicantDocumentLimit{"basic": {MaxDocuments: 3}}}))
+}
+
+func TestUploadsInProgressPerClient(t *testing.T) {
+	limits := NewUploadLimits(config.UploadLimitSettings{MaxConcurrentPerClient: 2})
+
+	first, err := limits.begin("client1")
+	require.NoError(t, err)
+	second, err := limits.begin("client1")
+	require.NoError(t, err)
+	_, err = limits.begin("client1")
+	assert.ErrorIs(t, err, ErrTooManyUploads)
+
+	// Other clients have their own allowance
+	other, err := limits.begin("client2")
+	require.NoError(t, err)
+	other()
+
+	// Ending an upload twice only frees one place
+	first()
+	first()
+	third, err := limits.begin("client1")
+	require.NoError(t, err)
+	_, err = limits.begin("client1")
+	assert.ErrorIs(t, err, ErrTooManyUploads)
+	second()
+	third()
+	assert.Empty(t, limits.inFlight)
+
+	// Without limits every upload begins
+	var none *UploadLimits
+	end, err := none.begin("client1")
+	require.NoError(t, err)
+	end()
+}
+
+func TestApplicantLimitByLevel(t *testing.T) {
+	limits := NewUploadLimits(config.UploadLimitSettings{
+		Default: config.ApplicantDocumentLimit{MaxDocuments: 10},
+		Levels:  map[string]config.ApplicantDocumentLimit{"basic-kyc-level": {MaxDocuments: 2, MaxBytes: 1000}},
+	})
+	basic := limits.forLevel("Basic-KYC-Level")
+	assert.Equal(t, config.ApplicantDocumentLimit{MaxDocuments: 2, MaxBytes: 1000}, basic)
+	assert.Equal(t, config.ApplicantDocumentLimit{MaxDocuments: 10}, limits.forLevel("enhanced"))
+
+	assert.NoError(t, checkApplicantLimit(basic, "basic-kyc-level", applicantUsage{Documents: 1, Bytes: 400}, 1, 600))
+	assert.ErrorIs(t, checkApplicantLimit(basic, "basic-kyc-level", applicantUsage{Documents: 2, Bytes: 400}, 1, 100), ErrApplicantDocumentLimit)
+	assert.ErrorIs(t, checkApplicantLimit(basic, "basic-kyc-level", applicantUsage{Documents: 1, Bytes: 400}, 1, 601), ErrApplicantStorageLimit)
+
+	// A replacement adds no document, and a smaller file always fits
+	assert.NoError(t, checkApplicantLimit(basic, "basic-kyc-level", applicantUsage{Documents: 2, Bytes: 900}, 0, 100))
+	assert.NoError(t, checkApplicantLimit(basic, "basic-kyc-level", applicantUsage{Documents: 2, Bytes: 1200}, 0, -300))
+}
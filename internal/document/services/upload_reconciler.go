@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/quarantine"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	zap "go.uber.org/zap"
+)
+
+const (
+	defaultReconcileGracePeriod = 2 * time.Minute
+	defaultMaxUploadAttempts    = 5
+)
+
+// UploadReconciler finds documents whose file never reached S3, retries the upload
+// from the staged copy, and gives up by marking the document failed and telling
+// the client to re-upload it
+type UploadReconciler struct {
+	Uploader       interfaces.Uploader
+	KMSUploader    interfaces.KMSUploader
+	Webhooks       localInterfaces.WebhookService
+	Jobs           localInterfaces.UploadJobStore   // Completes the progress of tracked uploads; nil tracks nothing
+	Applicants     localInterfaces.AnnotationReader // Echoes applicants' tags and metadata in webhook events; nil leaves them out
+	Quarantine     *quarantine.Store                // Holds retried files until their record is saved; nil stores them directly
+	CollectionName string
+	GracePeriod    time.Duration
+	MaxAttempts    int
+}
+
+// NewUploadReconciler creates a reconciler for the documents collection, applying defaults for unset limits
+func NewUploadReconciler(uploader interfaces.Uploader, kmsUploader interfaces.KMSUploader, webhooks localInterfaces.WebhookService, gracePeriod time.Duration, maxAttempts int) *UploadReconciler {
+	if gracePeriod <= 0 {
+		gracePeriod = defaultReconcileGracePeriod
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxUploadAttempts
+	}
+	return &UploadReconciler{
+		Uploader:       uploader,
+		KMSUploader:    kmsUploader,
+		Webhooks:       webhooks,
+		CollectionName: localConstants.CollectionDocuments,
+		GracePeriod:    gracePeriod,
+		MaxAttempts:    maxAttempts,
+	}
+}
+
+// pendingUpload matches documents still waiting for their file to reach S3. Direct uploads
+// waiting for their scan are left to it.
+func (r *UploadReconciler) pendingUpload(cutoff time.Time) bson.M {
+	return bson.M{
+		"file_url":     localModels.PlaceholderFileURL,
+		"deleted":      false,
+		"updated_at":   bson.M{"$lte": cutoff}, // Replacements restart the clock on an existing document
+		"upload.state": bson.M{"$nin": bson.A{localModels.UploadFailed, localModels.UploadScanning}},
+	}
+}
+
+// Reconcile makes one pass over documents with placeholder file URLs
+func (r *UploadReconciler) Reconcile(ctx context.Context) error {
+	collection := common.GetCollection(r.CollectionName)
+	cutoff := time.Now().Add(-r.GracePeriod)
+
+	cursor, err := collection.Find(ctx, r.pendingUpload(cutoff))
+	if err != nil {
+		return fmt.Errorf("failed to find pending uploads: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc localModels.DocumentRecord
+		if err := cursor.Decode(&doc); err != nil {
+			zaplogger.GetLogger().Error("Error decoding document with pending upload", zap.Error(err))
+			continue
+		}
+		r.reconcileDocument(ctx, collection, doc.ApplicantID, doc.ClientID, doc)
+	}
+	return cursor.Err()
+}
+
+// reconcileDocument retries one document's upload, or marks it failed if that is not possible.
+// A file that already reached quarantine only needs moving to its permanent key.
+func (r *UploadReconciler) reconcileDocument(ctx context.Context, collection common.CollectionInterface, applicantID, clientID string, doc localModels.DocumentRecord) {
+	logger := zaplogger.GetLogger().With(zap.String("applicantID", applicantID), zap.String("documentID", doc.DocumentID))
+
+	var fileURL string
+	var uploadErr error
+	if doc.Upload != nil && doc.Upload.State == localModels.UploadQuarantined && r.Quarantine.Holds(doc.Upload.QuarantineURL) {
+		fileURL = doc.Upload.QuarantineURL
+	} else {
+		if doc.Upload == nil || doc.Upload.StagedPath == "" {
+			r.markFailed(ctx, collection, applicantID, clientID, doc, "no staged copy of the file is available")
+			return
+		}
+		file, err := os.Open(doc.Upload.StagedPath)
+		if err != nil {
+			r.markFailed(ctx, collection, applicantID, clientID, doc, "the staged copy of the file is missing")
+			return
+		}
+		fileURL, uploadErr = r.Uploader.UploadFile(clientconfig.WithClientID(ctx, clientID), file, r.Quarantine.Key(doc.Upload.FileName), doc.Upload.MimeType, r.KMSUploader)
+		file.Close()
+	}
+
+	if uploadErr == nil {
+		var storeErr error
+		if _, storeErr = commitUpload(ctx, r.Quarantine, collection, tenant.Of(clientID), applicantID, doc.DocumentID, fileURL); storeErr == nil {
+			removeStaged(doc.Upload.StagedPath)
+			completeUploadJob(ctx, r.Jobs, doc, localModels.UploadJobCompleted, "")
+			logger.Info("Document upload recovered by reconciliation")
+			return
+		}
+		if !r.Quarantine.Holds(fileURL) {
+			// The object is in S3 under the same key, so the next pass simply uploads it again
+			logger.Error("Error saving file URL after retried upload", zap.Error(storeErr))
+			return
+		}
+		// A quarantined file that can't be moved counts as a failed attempt, so one that
+		// has expired from quarantine is eventually given up on
+		uploadErr = storeErr
+	}
+
+	attempts := doc.Upload.Attempts + 1
+	logger.Warn("Retry of document upload failed", zap.Error(uploadErr), zap.Int("attempts", attempts))
+	if attempts >= r.MaxAttempts {
+		r.markFailed(ctx, collection, applicantID, clientID, doc, fmt.Sprintf("storage upload failed after %d attempts", attempts))
+		return
+	}
+	update := bson.M{"$set": bson.M{
+		"upload.attempts":        attempts,
+		"upload.last_error":      uploadErr.Error(),
+		"upload.last_attempt_at": timestamp.Now(),
+	}}
+	if _, err := tenant.Guard(collection).UpdateOne(ctx, documentFilter(tenant.Of(clientID), applicantID, doc.DocumentID), update); err != nil {
+		logger.Error("Error recording upload attempt", zap.Error(err))
+	}
+}
+
+// markFailed gives up on a document's upload and asks the client to re-upload it
+func (r *UploadReconciler) markFailed(ctx context.Context, collection common.CollectionInterface, applicantID, clientID string, doc localModels.DocumentRecord, reason string) {
+	failUpload(ctx, collection, r.Jobs, r.Webhooks, r.Applicants, applicantID, clientID, doc, reason)
+}
+
+// failUpload marks a document's upload failed, completes its upload job and sends a
+// document.upload_failed webhook asking the client to re-upload it
+func failUpload(ctx context.Context, collection common.CollectionInterface, jobs localInterfaces.UploadJobStore, webhooks localInterfaces.WebhookService, applicants localInterfaces.AnnotationReader, applicantID, clientID string, doc localModels.DocumentRecord, reason string) {
+	logger := zaplogger.GetLogger().With(zap.String("applicantID", applicantID), zap.String("documentID", doc.DocumentID))
+
+	now := timestamp.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"upload.state":      localModels.UploadFailed,
+			"upload.last_error": reason,
+			"updated_at":        now,
+		},
+		"$unset": bson.M{"upload.staged_path": ""},
+	}
+	if _, err := tenant.Guard(collection).UpdateOne(ctx, documentFilter(tenant.Of(clientID), applicantID, doc.DocumentID), update); err != nil {
+		logger.Error("Error marking document upload failed", zap.Error(err))
+		return
+	}
+	if doc.Upload != nil {
+		removeStaged(doc.Upload.StagedPath)
+	}
+	logger.Warn("Document upload marked failed", zap.String("reason", reason))
+	completeUploadJob(ctx, jobs, doc, localModels.UploadJobFailed, reason)
+
+	if webhooks == nil {
+		return
+	}
+	data := localModels.DocumentUploadFailedData{
+		ApplicantID:  applicantID,
+		DocumentID:   doc.DocumentID,
+		DocumentType: doc.DocumentType.String(),
+		Reason:       reason,
+		Action:       "reupload",
+		Annotations:  applicantAnnotations(ctx, applicants, clientID, applicantID),
+	}
+	if err := webhooks.Emit(ctx, clientID, localModels.WebhookDocumentUploadFailed, data); err != nil {
+		logger.Error("Error emitting upload failed webhook", zap.Error(err))
+	}
+}
+
+// applicantAnnotations returns the tags and metadata of an applicant to echo in its webhook
+// events. The event is still worth sending without them, so failing to read them is only logged.
+func applicantAnnotations(ctx context.Context, applicants localInterfaces.AnnotationReader, clientID, applicantID string) *localModels.Annotations {
+	if applicants == nil {
+		return nil
+	}
+	annotations, err := applicants.Annotations(ctx, clientID, applicantID)
+	if err != nil {
+		zaplogger.GetLogger().Warn("Error reading applicant annotations", zap.Error(err), zap.String("applicantID", applicantID))
+	}
+	return annotations
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/quarantine"
+	mocks "github.com/rachel-lawrie/verus_backend_core/mocks"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStageFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "staging")
+	file := strings.NewReader("%PDF-1.4 test")
+
+	path, err := stageFile(dir, "doc1.pdf", file)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "doc1.pdf"), path)
+
+	staged, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4 test", string(staged))
+
+	// The original must be rewound so it can still be uploaded
+	rest := make([]byte, 4)
+	n, _ := file.Read(rest)
+	assert.Equal(t, "%PDF", string(rest[:n]))
+
+	removeStaged(path)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReconcileDocumentWithoutStagedCopy(t *testing.T) {
+	mockCollection := new(mocks.MockCollection)
+	mockWebhooks := new(localMocks.MockWebhookService)
+	reconciler := NewUploadReconciler(nil, nil, mockWebhooks, 0, 0)
+
+	doc := localModels.DocumentRecord{
+		Document: models.Document{
+			DocumentID:   "doc1",
+			ApplicantID:  "applicant1",
+			DocumentType: models.DocumentPassport,
+			FileURL:      localModels.PlaceholderFileURL,
+			CreatedAt:    time.Now().Add(-time.Hour),
+			UpdatedAt:    time.Now().Add(-time.Hour),
+		},
+		Upload: &localModels.StorageUpload{State: localModels.UploadPending},
+	}
+
+	mockCollection.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.AnythingOfType("[]*options.UpdateOptions")).Return(nil, nil)
+	mockWebhooks.On("Emit", mock.Anything, "client1", localModels.WebhookDocumentUploadFailed, mock.MatchedBy(func(data localModels.DocumentUploadFailedData) bool {
+		return data.DocumentID == "doc1" && data.ApplicantID == "applicant1" && data.Action == "reupload"
+	})).Return(nil)
+
+	reconciler.reconcileDocument(context.Background(), mockCollection, "applicant1", "client1", doc)
+
+	mockCollection.AssertExpectations(t)
+	mockWebhooks.AssertExpectations(t)
+}
+
+// quarantineBucket stands in for the S3 calls that move files out of quarantine
+type quarantineBucket struct {
+	copied  []string
+	removed []string
+}
+
+func (b *quarantineBucket) CopyObject(ctx context.Context, input *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	b.copied = append(b.copied, aws.ToString(input.Key))
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (b *quarantineBucket) DeleteObject(ctx context.Context, input *s3.DeleteObjectInput, opts ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	b.removed = append(b.removed, aws.ToString(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (b *quarantineBucket) GetBucketLifecycleConfiguration(ctx context.Context, input *s3.GetBucketLifecycleConfigurationInput, opts ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	return &s3.GetBucketLifecycleConfigurationOutput{}, nil
+}
+
+func (b *quarantineBucket) PutBucketLifecycleConfiguration(ctx context.Context, input *s3.PutBucketLifecycleConfigurationInput, opts ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+func (b *quarantineBucket) DeleteBucketLifecycle(ctx context.Context, input *s3.DeleteBucketLifecycleInput, opts ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+	return &s3.DeleteBucketLifecycleOutput{}, nil
+}
+
+// recordedUpdates returns a collection that accepts every update, and the $set of each
+func recordedUpdates() (*mocks.MockCollection, *[]bson.M) {
+	collection := new(mocks.MockCollection)
+	sets := []bson.M{}
+	collection.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sets = append(sets, args.Get(2).(bson.M)["$set"].(bson.M))
+	}).Return(nil, nil)
+	return collection, &sets
+}
+
+func TestStoreUploadMovesFilesOutOfQuarantine(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bucket := &quarantineBucket{}
+	service := &DocumentServiceImpl{
+		Uploader:    sealingUploader{},
+		KMSUploader: benchmarkKMS{},
+		Quarantine:  quarantine.New(config.QuarantineSettings{Enabled: true}, "bucket", bucket),
+	}
+	collection, sets := recordedUpdates()
+	body, contentType := uploadFormBody(t, pngFile(t, 1<<10))
+	c, file, _ := parsedUpload(t, body, contentType)
+	defer file.Close()
+
+	record := localModels.DocumentRecord{Document: createDocumentObject("applicant1", "passport", "GB"), ClientID: "client1"}
+	service.stageUpload(&record, file, record.DocumentID+".png", "image/png")
+	assert.True(t, service.storeUpload(c, collection, "applicant1", &record, file))
+
+	// The record says the file is in quarantine before it is moved, and only points at the moved copy
+	require.Len(t, *sets, 2)
+	assert.Equal(t, localModels.UploadQuarantined, (*sets)[0]["upload.state"])
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/quarantine/"+record.DocumentID+".png", (*sets)[0]["upload.quarantine_url"])
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/"+record.DocumentID+".png", (*sets)[1]["file_url"])
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/"+record.DocumentID+".png", record.FileURL)
+	assert.Equal(t, []string{record.DocumentID + ".png"}, bucket.copied)
+	assert.Equal(t, []string{"quarantine/" + record.DocumentID + ".png"}, bucket.removed)
+}
+
+func TestReconcileQuarantinedDocument(t *testing.T) {
+	bucket := &quarantineBucket{}
+	reconciler := NewUploadReconciler(nil, nil, nil, 0, 0)
+	reconciler.Quarantine = quarantine.New(config.QuarantineSettings{Enabled: true}, "bucket", bucket)
+	collection, sets := recordedUpdates()
+
+	// The staged copy is gone, but the file only needs moving
+	doc := localModels.DocumentRecord{
+		Document: models.Document{DocumentID: "doc1", ApplicantID: "applicant1", FileURL: localModels.PlaceholderFileURL},
+		Upload: &localModels.StorageUpload{
+			State:         localModels.UploadQuarantined,
+			FileName:      "doc1.png",
+			QuarantineURL: "https://bucket.s3.amazonaws.com/quarantine/doc1.png",
+		},
+	}
+	reconciler.reconcileDocument(context.Background(), collection, "applicant1", "client1", doc)
+
+	require.Len(t, *sets, 2)
+	assert.Equal(t, localModels.UploadStored, (*sets)[1]["upload.state"])
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/doc1.png", (*sets)[1]["file_url"])
+	assert.Equal(t, []string{"doc1.png"}, bucket.copied)
+	assert.Equal(t, []string{"quarantine/doc1.png"}, bucket.removed)
+}
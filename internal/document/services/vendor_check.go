@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongotx"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_app_backend/internal/vendor"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// vendorCheck selects the vendor that will verify a document. A client bound to a vendor
+// that cannot verify the document fails the upload now rather than at submission.
+func vendorCheck(selector vendor.Selector, clientID, level string, record *localModels.DocumentRecord) localModels.UploadCheck {
+	check := localModels.UploadCheck{Name: "vendor_support", Status: localModels.UploadCheckPassed}
+	selection, err := selector.Select(clientID, level, record.DocumentType, record.Country)
+	switch {
+	case errors.Is(err, vendor.ErrNoVendor):
+		check.Status = localModels.UploadCheckFlagged
+		check.Detail = "no verification vendor configured for client"
+	case err != nil:
+		check.Status = localModels.UploadCheckFailed
+		check.Detail = err.Error()
+	default:
+		record.Vendor, record.VendorRoute = selection.Name, selection.Route
+		check.Detail = selection.Name
+		if selection.Route == vendor.RouteFailover {
+			record.PreferredVendor = selection.Preferred
+			check.Detail = fmt.Sprintf("%s (%s is unavailable)", selection.Name, selection.Preferred)
+		}
+	}
+	return check
+}
+
+// applyVendorCheck adds the vendor check to an upload's results, using the client and
+// verification level of the applicant the document belongs to
+func (s *DocumentServiceImpl) applyVendorCheck(applicant documentApplicant, record *localModels.DocumentRecord, result *localModels.UploadResult) error {
+	if s.Vendors == nil {
+		return nil
+	}
+
+	result.Checks = append(result.Checks, vendorCheck(s.Vendors, applicant.ClientID, applicant.VerificationLevel, record))
+	result.ProcessingStatus = localModels.OverallStatus(result.Checks)
+	result.DocumentRecord = *record
+	if result.ProcessingStatus == localModels.ProcessingRejected {
+		return checksFailed(*result)
+	}
+	return nil
+}
+
+// recordProvider notes on the applicant the vendor chosen for its latest document.
+// Failing to is logged rather than failing the upload, as the document records it too,
+// unless in a transaction.
+func (s *DocumentServiceImpl) recordProvider(ctx context.Context, applicantID string, record localModels.DocumentRecord) error {
+	if s.Vendors == nil || record.Vendor == "" {
+		return nil
+	}
+	choice := localModels.ProviderChoice{
+		Name:       record.Vendor,
+		Route:      record.VendorRoute,
+		Preferred:  record.PreferredVendor,
+		DocumentID: record.DocumentID,
+		SelectedAt: timestamp.Now(),
+	}
+	err := mongoretry.Write(ctx, "record_verification_provider", func(ctx context.Context) error {
+		_, err := common.GetCollection(s.ApplicantCollectionName).UpdateOne(ctx,
+			bson.M{"applicant_id": applicantID}, bson.M{"$set": bson.M{"verification_provider": choice}})
+		return err
+	})
+	if err != nil {
+		if mongotx.InTransaction(ctx) {
+			return fmt.Errorf("failed to record verification provider: %w", err)
+		}
+		log.Printf("Error recording verification provider for applicant %s: %v", applicantID, err)
+	}
+	return nil
+}
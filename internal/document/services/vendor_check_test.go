@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/vendor"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSelector struct {
+	selection vendor.Selection
+	err       error
+}
+
+func (f fakeSelector) Select(clientID, level string, docType models.DocumentType, issuingCountry string) (vendor.Selection, error) {
+	return f.selection, f.err
+}
+
+func TestVendorCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		selector       fakeSelector
+		expectedStatus localModels.UploadCheckStatus
+		expectedVendor string
+		expectedRoute  string
+	}{
+		{"Vendor selected", fakeSelector{selection: vendor.Selection{Provider: vendor.Provider{Name: "sumsub"}, Preferred: "sumsub", Route: vendor.RoutePreferred}}, localModels.UploadCheckPassed, "sumsub", vendor.RoutePreferred},
+		{"Failed over", fakeSelector{selection: vendor.Selection{Provider: vendor.Provider{Name: "onfido"}, Preferred: "sumsub", Route: vendor.RouteFailover}}, localModels.UploadCheckPassed, "onfido", vendor.RouteFailover},
+		{"Vendor cannot verify document", fakeSelector{err: fmt.Errorf("%w: nope", vendor.ErrUnsupported)}, localModels.UploadCheckFailed, "", ""},
+		{"No vendor for client", fakeSelector{err: vendor.ErrNoVendor}, localModels.UploadCheckFlagged, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := localModels.DocumentRecord{Document: models.Document{DocumentType: models.DocumentPassport, Country: "DEU"}}
+			check := vendorCheck(tt.selector, "client1", "basic", &record)
+			assert.Equal(t, "vendor_support", check.Name)
+			assert.Equal(t, tt.expectedStatus, check.Status)
+			assert.Equal(t, tt.expectedVendor, record.Vendor)
+			assert.Equal(t, tt.expectedRoute, record.VendorRoute)
+			if tt.expectedRoute == vendor.RouteFailover {
+				assert.Equal(t, "sumsub", record.PreferredVendor)
+				assert.Equal(t, "onfido (sumsub is unavailable)", check.Detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+)
+
+var (
+	// ErrVersionNotFound is returned when a document has no version with the requested number
+	ErrVersionNotFound = apperr.NotFound("version_not_found", "document version not found")
+	// ErrVersionNotStored is returned when the file of a version never reached S3
+	ErrVersionNotStored = apperr.NotFound("version_not_stored", "document version has no stored file")
+)
+
+// GetDocumentVersions returns the versions a client's document has been replaced from, oldest first
+func (s *DocumentServiceImpl) GetDocumentVersions(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.DocumentHistory, error) {
+	doc, err := findDocumentRecord(c, collection, tenant.Of(clientID), applicantID, docID)
+	if err != nil {
+		return localModels.DocumentHistory{}, err
+	}
+	history := localModels.DocumentHistory{
+		DocumentID:     doc.DocumentID,
+		CurrentVersion: doc.CurrentVersion(),
+		Versions:       doc.Versions,
+	}
+	if history.Versions == nil {
+		history.Versions = []localModels.DocumentVersion{}
+	}
+	return history, nil
+}
+
+// OpenDocumentVersion returns the file of any version of a document, including the current one,
+// watermarked for the download if the document's client asks for it. It is meant for staff
+// and is not scoped to a client.
+func (s *DocumentServiceImpl) OpenDocumentVersion(c *gin.Context, applicantID, docID string, version int, download localModels.DocumentDownload, collection common.CollectionInterface) (localModels.DocumentVersion, io.ReadCloser, error) {
+	doc, err := findDocumentRecord(c, collection, tenant.AllClients(), applicantID, docID)
+	if err != nil {
+		return localModels.DocumentVersion{}, nil, err
+	}
+
+	var selected localModels.DocumentVersion
+	switch {
+	case version == doc.CurrentVersion():
+		selected = localModels.DocumentVersion{
+			Version:  version,
+			FileURL:  doc.FileURL,
+			FileSize: doc.FileSize,
+			Status:   doc.Status,
+			Archived: !doc.ColdStorage.Downloadable(timestamp.Now()),
+		}
+	default:
+		found := false
+		for _, v := range doc.Versions {
+			if v.Version == version {
+				selected, found = v, true
+				break
+			}
+		}
+		if !found {
+			return localModels.DocumentVersion{}, nil, ErrVersionNotFound
+		}
+	}
+	if selected.FileURL == "" || selected.FileURL == localModels.PlaceholderFileURL {
+		return selected, nil, ErrVersionNotStored
+	}
+	if selected.Archived {
+		return selected, nil, ErrDocumentArchived
+	}
+
+	objectKey, err := getObjectKeyFromURL(selected.FileURL)
+	if err != nil {
+		return selected, nil, fmt.Errorf("failed to extract object key from URL: %v", err)
+	}
+	mark, err := s.downloadWatermark(c.Request.Context(), doc.ClientID, download)
+	if err != nil {
+		return selected, nil, fmt.Errorf("failed to load client settings: %v", err)
+	}
+	output, err := s.Uploader.DownloadFile(c.Request.Context(), objectKey)
+	if err != nil {
+		return selected, nil, fmt.Errorf("failed to download file from S3: %v", err)
+	}
+	if mark == nil {
+		return selected, output.Body, nil
+	}
+
+	// Stamping needs the whole file, so it is read in rather than streamed
+	defer output.Body.Close()
+	content, err := io.ReadAll(output.Body)
+	if err != nil {
+		return selected, nil, fmt.Errorf("failed to download file from S3: %v", err)
+	}
+	content, _ = stampFile(doc.DocumentID, selected.FileURL, content, mark)
+	selected.FileSize = int64(len(content))
+	return selected, io.NopCloser(bytes.NewReader(content)), nil
+}
@@ -0,0 +1,144 @@
+// Package dto maps stored applicants and documents to the bodies handlers respond with.
+//
+// Storage models carry fields clients must never see, such as encrypted PII and the
+// client an applicant belongs to, so handlers respond with these types instead. Each
+// version of the API has its own types, mapped field by field: a field added to a
+// storage model is not returned until it is added here.
+package dto
+
+import (
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/models_sumsub"
+)
+
+// Applicant is an applicant as v2 clients see it
+type Applicant struct {
+	ApplicantID       string                       `json:"applicant_id"`
+	FirstName         string                       `json:"first_name"`
+	MiddleName        string                       `json:"middle_name"`
+	LastName          string                       `json:"last_name"`
+	Email             string                       `json:"email"`
+	Phone             string                       `json:"phone"`
+	VerificationLevel string                       `json:"verification_level"`
+	DeviceMetadata    *localModels.DeviceMetadata  `json:"device_metadata,omitempty"`
+	RiskSignals       []localModels.RiskSignal     `json:"risk_signals,omitempty"`
+	Consent           *localModels.Consent         `json:"consent,omitempty"`
+	Intake            *localModels.ApplicantIntake `json:"intake,omitempty"` // Set for applicants created from a document
+	CaptureChannel    localModels.SessionChannel   `json:"capture_channel,omitempty"`
+	Annotations       *localModels.Annotations     `json:"annotations,omitempty"` // The client's own tags and metadata
+	Documents         []Document                   `json:"documents"`
+	CreatedAt         time.Time                    `json:"created_at"`
+	UpdatedAt         time.Time                    `json:"updated_at"`
+}
+
+// ApplicantV1 is an applicant in the shape v1 has always returned it, less its
+// encrypted data and owning client
+type ApplicantV1 struct {
+	ApplicantID       string                      `json:"applicant_id"`
+	FirstName         string                      `json:"first_name"`
+	MiddleName        string                      `json:"middle_name"`
+	LastName          string                      `json:"last_name"`
+	Email             string                      `json:"email"`
+	Phone             string                      `json:"phone"`
+	CreatedAt         time.Time                   `json:"created_at"`
+	UpdatedAt         time.Time                   `json:"updated_at"`
+	Deleted           bool                        `json:"deleted"`
+	DeletedAt         *time.Time                  `json:"deleted_at"`
+	DeletedBy         *string                     `json:"deleted_by"`
+	Documents         []DocumentV1                `json:"documents"`
+	SumsubApplicant   models_sumsub.Applicant     `json:"sumsub_applicant"`
+	VerificationLevel string                      `json:"verification_level"`
+	DeviceMetadata    *localModels.DeviceMetadata `json:"device_metadata,omitempty"`
+	RiskSignals       []localModels.RiskSignal    `json:"risk_signals,omitempty"`
+	Consent           *localModels.Consent        `json:"consent,omitempty"`
+}
+
+// NewApplicant maps a stored applicant to its v2 response
+func NewApplicant(a localModels.ApplicantRecord) Applicant {
+	return Applicant{
+		ApplicantID:       a.ApplicantID,
+		FirstName:         a.FirstName,
+		MiddleName:        a.MiddleName,
+		LastName:          a.LastName,
+		Email:             a.Email,
+		Phone:             a.Phone,
+		VerificationLevel: a.VerificationLevel,
+		DeviceMetadata:    a.DeviceMetadata,
+		RiskSignals:       a.RiskSignals,
+		Consent:           a.Consent,
+		Intake:            a.Intake,
+		CaptureChannel:    a.CaptureChannel,
+		Annotations:       a.Annotations,
+		Documents:         newDocuments(a.Documents),
+		CreatedAt:         timestamp.UTC(a.CreatedAt),
+		UpdatedAt:         timestamp.UTC(a.UpdatedAt),
+	}
+}
+
+// NewApplicantV1 maps a stored applicant to its v1 response
+func NewApplicantV1(a localModels.ApplicantRecord) ApplicantV1 {
+	return ApplicantV1{
+		ApplicantID:       a.ApplicantID,
+		FirstName:         a.FirstName,
+		MiddleName:        a.MiddleName,
+		LastName:          a.LastName,
+		Email:             a.Email,
+		Phone:             a.Phone,
+		CreatedAt:         timestamp.UTC(a.CreatedAt),
+		UpdatedAt:         timestamp.UTC(a.UpdatedAt),
+		Deleted:           a.Deleted,
+		DeletedAt:         timestamp.UTCPtr(a.DeletedAt),
+		DeletedBy:         a.DeletedBy,
+		Documents:         newDocumentsV1(a.Documents),
+		SumsubApplicant:   a.SumsubApplicant,
+		VerificationLevel: a.VerificationLevel,
+		DeviceMetadata:    a.DeviceMetadata,
+		RiskSignals:       a.RiskSignals,
+		Consent:           a.Consent,
+	}
+}
+
+// ApplicantResponse maps an applicant to the response of the given API version
+func ApplicantResponse(version apiversion.Version, a localModels.ApplicantRecord) interface{} {
+	if version >= apiversion.V2 {
+		return NewApplicant(a)
+	}
+	return NewApplicantV1(a)
+}
+
+// ApplicantsResponse maps a list of applicants to the response of the given API version
+func ApplicantsResponse(version apiversion.Version, applicants []localModels.ApplicantRecord) interface{} {
+	if version >= apiversion.V2 {
+		mapped := make([]Applicant, len(applicants))
+		for i, a := range applicants {
+			mapped[i] = NewApplicant(a)
+		}
+		return mapped
+	}
+	mapped := make([]ApplicantV1, len(applicants))
+	for i, a := range applicants {
+		mapped[i] = NewApplicantV1(a)
+	}
+	return mapped
+}
+
+// SelectionResponse maps the documents included in a sparse fieldset of an applicant.
+// The selected fields themselves come from the projection, which only reads fields in
+// localModels.ApplicantFields, so they are passed through.
+func SelectionResponse(version apiversion.Version, applicant map[string]interface{}) map[string]interface{} {
+	documents, ok := applicant[localModels.IncludeDocuments].([]coreModels.Document)
+	if !ok {
+		return applicant
+	}
+	if version >= apiversion.V2 {
+		applicant[localModels.IncludeDocuments] = newDocuments(documents)
+	} else {
+		applicant[localModels.IncludeDocuments] = newDocumentsV1(documents)
+	}
+	return applicant
+}
@@ -0,0 +1,100 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func storedApplicant() localModels.ApplicantRecord {
+	created := time.Date(2025, 1, 15, 10, 30, 0, 0, time.FixedZone("CET", 3600))
+	return localModels.ApplicantRecord{
+		Applicant: coreModels.Applicant{
+			ApplicantID:       "applicant1",
+			FirstName:         "Ada",
+			LastName:          "Lovelace",
+			Email:             "ada@example.com",
+			ClientID:          "client1",
+			EncryptedData:     coreModels.EncryptedData{DOB: coreModels.EncryptedField{Ciphertext: []byte("ciphertext"), Nonce: []byte("nonce")}, EncryptedKey: []byte("key")},
+			CreatedAt:         created,
+			UpdatedAt:         created,
+			Documents:         []coreModels.Document{storedDocument().Document},
+			VerificationLevel: "basic",
+		},
+		Consent: &localModels.Consent{TextVersion: "v1", Channel: "web"},
+	}
+}
+
+func TestApplicantShape(t *testing.T) {
+	applicant := storedApplicant()
+	assert.Equal(t, []string{
+		"applicant_id", "consent", "created_at", "documents", "email", "first_name", "last_name", "middle_name",
+		"phone", "updated_at", "verification_level",
+	}, keys(t, ApplicantResponse(apiversion.V2, applicant)))
+	assert.Equal(t, []string{
+		"applicant_id", "consent", "created_at", "deleted", "deleted_at", "deleted_by", "documents", "email",
+		"first_name", "last_name", "middle_name", "phone", "sumsub_applicant", "updated_at", "verification_level",
+	}, keys(t, ApplicantResponse(apiversion.V1, applicant)))
+
+	v2 := NewApplicant(applicant)
+	assert.Equal(t, "2025-01-15T09:30:00Z", v2.CreatedAt.Format(time.RFC3339Nano))
+	require.Len(t, v2.Documents, 1)
+	assert.Equal(t, []string{
+		"applicant_id", "country", "created_at", "document_id", "document_type", "file_size", "status", "updated_at",
+	}, keys(t, v2.Documents[0]))
+
+	// An applicant read without its documents keeps documents null rather than empty
+	applicant.Documents = nil
+	body, err := json.Marshal(NewApplicant(applicant))
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"documents":null`)
+}
+
+func TestApplicantsResponse(t *testing.T) {
+	applicants := []localModels.ApplicantRecord{storedApplicant()}
+	assert.IsType(t, []Applicant{}, ApplicantsResponse(apiversion.V2, applicants))
+	assert.IsType(t, []ApplicantV1{}, ApplicantsResponse(apiversion.V1, applicants))
+
+	body, err := json.Marshal(ApplicantsResponse(apiversion.V2, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(body))
+}
+
+func TestSelectionResponse(t *testing.T) {
+	selected := map[string]interface{}{
+		"applicant_id": "applicant1",
+		"email":        "ada@example.com",
+		"documents":    []coreModels.Document{storedDocument().Document},
+	}
+	mapped := SelectionResponse(apiversion.V2, selected)
+	assert.Equal(t, "ada@example.com", mapped["email"])
+	documents, ok := mapped["documents"].([]Document)
+	require.True(t, ok)
+	assert.Equal(t, "doc1", documents[0].DocumentID)
+
+	// Selections without documents are returned as they are
+	assert.Equal(t, map[string]interface{}{"email": "ada@example.com"},
+		SelectionResponse(apiversion.V2, map[string]interface{}{"email": "ada@example.com"}))
+}
+
+func TestApplicantIntakeHidesExtractedDetails(t *testing.T) {
+	applicant := storedApplicant()
+	applicant.Intake = &localModels.ApplicantIntake{
+		State:      localModels.IntakeProvisional,
+		DocumentID: "doc1",
+		Extracted:  localModels.ExtractedIdentity{Source: "mrz", LastName: "pii:v1:sealed"},
+		DataKey:    []byte("key"),
+	}
+
+	body, err := json.Marshal(ApplicantResponse(apiversion.V2, applicant))
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"intake":{"state":"provisional","document_id":"doc1"}`)
+	assert.NotContains(t, string(body), "sealed")
+	assert.NotContains(t, keys(t, ApplicantResponse(apiversion.V1, applicant)), "intake")
+}
@@ -0,0 +1,157 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// Document is a document as v2 clients see it. Where the file is stored and whether
+// the record was deleted stay on the server.
+type Document struct {
+	DocumentID   string                     `json:"document_id"`
+	ApplicantID  string                     `json:"applicant_id"`
+	DocumentType coreModels.DocumentType    `json:"document_type"`
+	Country      string                     `json:"country"`
+	FileSize     int64                      `json:"file_size"`
+	Status       coreModels.DocumentStatus  `json:"status"`
+	Version      int                        `json:"version,omitempty"`
+	Flags        []localModels.DocumentFlag `json:"flags,omitempty"`
+	CountryCheck *localModels.CountryCheck  `json:"country_check,omitempty"`
+	CreatedAt    time.Time                  `json:"created_at"`
+	UpdatedAt    time.Time                  `json:"updated_at"`
+}
+
+// DocumentV1 is a document in the shape v1 has always returned it
+type DocumentV1 struct {
+	DocumentID   string                    `json:"document_id"`
+	ApplicantID  string                    `json:"applicant_id"`
+	DocumentType coreModels.DocumentType   `json:"document_type"`
+	Country      string                    `json:"country"`
+	FileURL      string                    `json:"file_url"`
+	FileSize     int64                     `json:"file_size"`
+	Status       coreModels.DocumentStatus `json:"status"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	UpdatedAt    time.Time                 `json:"updated_at"`
+	Deleted      bool                      `json:"deleted"`
+	DeletedAt    *time.Time                `json:"deleted_at"`
+	DeletedBy    *string                   `json:"deleted_by"`
+}
+
+// UploadResult is an uploaded document as v2 clients see it, with its check results
+type UploadResult struct {
+	Document
+	Checks           []localModels.UploadCheck    `json:"checks"`
+	ProcessingStatus localModels.ProcessingStatus `json:"processing_status"`
+	JobID            string                       `json:"job_id,omitempty"`
+}
+
+// UploadResultV1 is an uploaded document in the shape v1 has always returned it
+type UploadResultV1 struct {
+	DocumentV1
+	Flags            []localModels.DocumentFlag   `json:"flags,omitempty"`
+	CountryCheck     *localModels.CountryCheck    `json:"country_check,omitempty"`
+	Upload           *localModels.StorageUpload   `json:"upload,omitempty"`
+	Version          int                          `json:"version,omitempty"`
+	Vendor           string                       `json:"vendor,omitempty"`
+	Checks           []localModels.UploadCheck    `json:"checks"`
+	ProcessingStatus localModels.ProcessingStatus `json:"processing_status"`
+	JobID            string                       `json:"job_id,omitempty"`
+}
+
+// NewDocument maps a stored document to its v2 response
+func NewDocument(d coreModels.Document) Document {
+	return Document{
+		DocumentID:   d.DocumentID,
+		ApplicantID:  d.ApplicantID,
+		DocumentType: d.DocumentType,
+		Country:      d.Country,
+		FileSize:     d.FileSize,
+		Status:       d.Status,
+		CreatedAt:    timestamp.UTC(d.CreatedAt),
+		UpdatedAt:    timestamp.UTC(d.UpdatedAt),
+	}
+}
+
+// NewDocumentV1 maps a stored document to its v1 response
+func NewDocumentV1(d coreModels.Document) DocumentV1 {
+	return DocumentV1{
+		DocumentID:   d.DocumentID,
+		ApplicantID:  d.ApplicantID,
+		DocumentType: d.DocumentType,
+		Country:      d.Country,
+		FileURL:      d.FileURL,
+		FileSize:     d.FileSize,
+		Status:       d.Status,
+		CreatedAt:    timestamp.UTC(d.CreatedAt),
+		UpdatedAt:    timestamp.UTC(d.UpdatedAt),
+		Deleted:      d.Deleted,
+		DeletedAt:    timestamp.UTCPtr(d.DeletedAt),
+		DeletedBy:    d.DeletedBy,
+	}
+}
+
+// NewUploadResult maps the outcome of an upload to its v2 response
+func NewUploadResult(r localModels.UploadResult) UploadResult {
+	document := NewDocument(r.Document)
+	document.Version, document.Flags, document.CountryCheck = r.Version, r.Flags, r.CountryCheck
+	return UploadResult{Document: document, Checks: r.Checks, ProcessingStatus: r.ProcessingStatus, JobID: r.JobID}
+}
+
+// NewUploadResultV1 maps the outcome of an upload to its v1 response
+func NewUploadResultV1(r localModels.UploadResult) UploadResultV1 {
+	return UploadResultV1{
+		DocumentV1:       NewDocumentV1(r.Document),
+		Flags:            r.Flags,
+		CountryCheck:     r.CountryCheck,
+		Upload:           r.Upload,
+		Version:          r.Version,
+		Vendor:           r.Vendor,
+		Checks:           r.Checks,
+		ProcessingStatus: r.ProcessingStatus,
+		JobID:            r.JobID,
+	}
+}
+
+// DocumentResponse maps a document to the response of the given API version
+func DocumentResponse(version apiversion.Version, d coreModels.Document) interface{} {
+	if version >= apiversion.V2 {
+		return NewDocument(d)
+	}
+	return NewDocumentV1(d)
+}
+
+// UploadResponse maps the outcome of an upload to the response of the given API version
+func UploadResponse(version apiversion.Version, r localModels.UploadResult) interface{} {
+	if version >= apiversion.V2 {
+		return NewUploadResult(r)
+	}
+	return NewUploadResultV1(r)
+}
+
+// newDocuments maps an applicant's documents to v2 responses, keeping nil as nil
+func newDocuments(documents []coreModels.Document) []Document {
+	if documents == nil {
+		return nil
+	}
+	mapped := make([]Document, len(documents))
+	for i, d := range documents {
+		mapped[i] = NewDocument(d)
+	}
+	return mapped
+}
+
+// newDocumentsV1 maps an applicant's documents to v1 responses, keeping nil as nil
+func newDocumentsV1(documents []coreModels.Document) []DocumentV1 {
+	if documents == nil {
+		return nil
+	}
+	mapped := make([]DocumentV1, len(documents))
+	for i, d := range documents {
+		mapped[i] = NewDocumentV1(d)
+	}
+	return mapped
+}
@@ -0,0 +1,104 @@
+package dto
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keys returns the top-level keys of a value once marshalled to JSON, sorted
+func keys(t *testing.T, v interface{}) []string {
+	t.Helper()
+	body, err := json.Marshal(v)
+	require.NoError(t, err)
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &fields))
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func storedDocument() localModels.DocumentRecord {
+	deletedBy := "admin1"
+	deletedAt := time.Date(2025, 1, 16, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	return localModels.DocumentRecord{
+		Document: coreModels.Document{
+			DocumentID:   "doc1",
+			ApplicantID:  "applicant1",
+			DocumentType: coreModels.DocumentPassport,
+			Country:      "GB",
+			FileURL:      "https://bucket.s3.amazonaws.com/doc1.png",
+			FileSize:     48213,
+			Status:       coreModels.DocumentUploaded,
+			CreatedAt:    time.Date(2025, 1, 15, 10, 31, 0, 123456789, time.FixedZone("CET", 3600)),
+			UpdatedAt:    time.Date(2025, 1, 15, 10, 31, 0, 0, time.FixedZone("CET", 3600)),
+			Deleted:      true,
+			DeletedAt:    &deletedAt,
+			DeletedBy:    &deletedBy,
+		},
+		ClientID: "client1",
+		Flags:    []localModels.DocumentFlag{{Code: "low_resolution", Message: "image is small"}},
+		Upload:   &localModels.StorageUpload{State: localModels.UploadStored, StagedPath: "/tmp/doc1"},
+		Version:  2,
+		Vendor:   "sumsub",
+	}
+}
+
+func TestDocumentShape(t *testing.T) {
+	doc := storedDocument().Document
+	assert.Equal(t, []string{
+		"applicant_id", "country", "created_at", "document_id", "document_type", "file_size", "status", "updated_at",
+	}, keys(t, DocumentResponse(apiversion.V2, doc)))
+	assert.Equal(t, []string{
+		"applicant_id", "country", "created_at", "deleted", "deleted_at", "deleted_by", "document_id", "document_type",
+		"file_size", "file_url", "status", "updated_at",
+	}, keys(t, DocumentResponse(apiversion.V1, doc)))
+
+	body, err := json.Marshal(NewDocument(doc))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"document_id": "doc1",
+		"applicant_id": "applicant1",
+		"document_type": 0,
+		"country": "GB",
+		"file_size": 48213,
+		"status": 0,
+		"created_at": "2025-01-15T09:31:00.123Z",
+		"updated_at": "2025-01-15T09:31:00Z"
+	}`, string(body))
+}
+
+func TestUploadResultShape(t *testing.T) {
+	result := localModels.UploadResult{
+		DocumentRecord:   storedDocument(),
+		Checks:           []localModels.UploadCheck{{Name: "file_type", Status: localModels.UploadCheckPassed}},
+		ProcessingStatus: localModels.ProcessingAccepted,
+		JobID:            "job-1",
+	}
+	assert.Equal(t, []string{
+		"applicant_id", "checks", "country", "created_at", "document_id", "document_type", "file_size", "flags",
+		"job_id", "processing_status", "status", "updated_at", "version",
+	}, keys(t, UploadResponse(apiversion.V2, result)))
+	assert.Equal(t, []string{
+		"applicant_id", "checks", "country", "created_at", "deleted", "deleted_at", "deleted_by", "document_id",
+		"document_type", "file_size", "file_url", "flags", "job_id", "processing_status", "status", "updated_at", "upload",
+		"vendor", "version",
+	}, keys(t, UploadResponse(apiversion.V1, result)))
+
+	// v1 returns what the storage model always gave it, so nothing is lost by mapping
+	legacy, err := json.Marshal(result)
+	require.NoError(t, err)
+	mapped, err := json.Marshal(NewUploadResultV1(result))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(legacy), string(mapped))
+}
@@ -0,0 +1,135 @@
+// Package egress sends outbound calls, such as webhook deliveries and provider API calls,
+// through the proxy or NAT gateway in settings.egress, and publishes the addresses they
+// leave from so clients can allowlist them.
+//
+// Clients made with Client pick up the configuration whenever it changes, so services
+// created before Configure is called are proxied as well. S3 and KMS calls are made by
+// the AWS SDK, which follows HTTPS_PROXY and NO_PROXY.
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Path is where the egress IP ranges are published
+const Path = "/.well-known/egress-ips"
+
+// maxAge is how long clients may cache the published ranges
+const maxAge = time.Hour
+
+// ErrInvalidProxy is returned when the proxy URL cannot be used
+var ErrInvalidProxy = errors.New("egress proxy URL must be an absolute http, https or socks5 URL")
+
+var (
+	mu        sync.RWMutex
+	transport http.RoundTripper = http.DefaultTransport
+	ranges                      = []string{}
+)
+
+// Configure routes outbound calls through the configured proxy and sets the ranges
+// published at Path. It fails if the proxy URL or a range is invalid.
+func Configure(settings config.EgressSettings) error {
+	next, err := newTransport(settings)
+	if err != nil {
+		return err
+	}
+	published, err := normalize(settings.IPRanges)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	transport = next
+	ranges = published
+	return nil
+}
+
+// Client creates an HTTP client for outbound calls that gives up after timeout
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: roundTripper{}}
+}
+
+// IPRanges returns the ranges outbound calls leave from, in CIDR notation
+func IPRanges() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append(make([]string, 0, len(ranges)), ranges...)
+}
+
+// Handler answers with the ranges clients should allowlist to receive webhooks
+func Handler(c *gin.Context) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	c.JSON(http.StatusOK, gin.H{"ip_ranges": IPRanges()})
+}
+
+// roundTripper sends each request with the transport configured at the time
+type roundTripper struct{}
+
+func (roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	mu.RLock()
+	next := transport
+	mu.RUnlock()
+	return next.RoundTrip(req)
+}
+
+// newTransport creates the transport outbound calls are sent with. Without a proxy URL it
+// is the default transport, which follows the proxy environment variables.
+func newTransport(settings config.EgressSettings) (http.RoundTripper, error) {
+	if settings.ProxyURL == "" {
+		return http.DefaultTransport, nil
+	}
+	proxyURL, err := url.Parse(settings.ProxyURL)
+	if err != nil || proxyURL.Host == "" {
+		return nil, ErrInvalidProxy
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, ErrInvalidProxy
+	}
+
+	proxy := (&httpproxy.Config{
+		HTTPProxy:  settings.ProxyURL,
+		HTTPSProxy: settings.ProxyURL,
+		NoProxy:    settings.NoProxy,
+	}).ProxyFunc()
+	next := http.DefaultTransport.(*http.Transport).Clone()
+	next.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	return next, nil
+}
+
+// normalize checks each range, writing single addresses as /32 or /128 ranges
+func normalize(values []string) ([]string, error) {
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if ip := net.ParseIP(value); ip != nil {
+			if ip.To4() != nil {
+				normalized = append(normalized, ip.String()+"/32")
+			} else {
+				normalized = append(normalized, ip.String()+"/128")
+			}
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("egress IP range %q is not an address or CIDR range", value)
+		}
+		normalized = append(normalized, network.String())
+	}
+	return normalized, nil
+}
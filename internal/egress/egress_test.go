@@ -0,0 +1,80 @@
+package egress
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureValidates(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(config.EgressSettings{})) })
+
+	assert.ErrorIs(t, Configure(config.EgressSettings{ProxyURL: "ftp://proxy:21"}), ErrInvalidProxy)
+	assert.ErrorIs(t, Configure(config.EgressSettings{ProxyURL: "proxy:3128"}), ErrInvalidProxy)
+	assert.Error(t, Configure(config.EgressSettings{IPRanges: []string{"203.0.113.300"}}))
+
+	require.NoError(t, Configure(config.EgressSettings{IPRanges: []string{"203.0.113.7", " 198.51.100.0/24", "2001:db8::1", "192.0.2.9/24"}}))
+	assert.Equal(t, []string{"203.0.113.7/32", "198.51.100.0/24", "2001:db8::1/128", "192.0.2.0/24"}, IPRanges())
+}
+
+func TestClientUsesProxy(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(config.EgressSettings{})) })
+
+	// A plain HTTP proxy is sent the absolute URL of each request
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	// The client is created before the proxy is configured, as services are
+	client := Client(time.Second)
+	require.NoError(t, Configure(config.EgressSettings{ProxyURL: proxy.URL, NoProxy: "direct.example"}))
+
+	resp, err := client.Get("http://hooks.example/verus")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, []string{"http://hooks.example/verus"}, proxied)
+
+	// Hosts in NoProxy are called directly, so the proxy is not asked
+	_, err = client.Get("http://direct.example/verus")
+	assert.Error(t, err)
+	assert.Len(t, proxied, 1)
+}
+
+func TestHandler(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(config.EgressSettings{})) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(Path, Handler)
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, Path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without ranges the list is empty rather than null
+	w := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"ip_ranges": []}`, w.Body.String())
+
+	require.NoError(t, Configure(config.EgressSettings{IPRanges: []string{"203.0.113.7"}}))
+	w = get()
+	var body struct {
+		IPRanges []string `json:"ip_ranges"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, []string{"203.0.113.7/32"}, body.IPRanges)
+	assert.Equal(t, "public, max-age=3600", w.Header().Get("Cache-Control"))
+}
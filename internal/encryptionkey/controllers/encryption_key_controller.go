@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/encryptionkey/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// RegisterEncryptionKey is the handler function for registering a KMS key the client wants
+// its data encrypted under, such as one holding key material the client imported
+func RegisterEncryptionKey(c *gin.Context, service interfaces.EncryptionKeyService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var requestBody struct {
+		KeyARN string `json:"key_arn" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key_arn is required"})
+		return
+	}
+
+	key, err := service.RegisterKey(c.Request.Context(), clientID, requestBody.KeyARN)
+	switch {
+	case err == nil:
+		c.Header("Location", c.Request.URL.Path+"/"+key.EncryptionKeyID)
+		c.JSON(http.StatusCreated, key)
+	default:
+		respondError(c, err, "Could not register encryption key")
+	}
+}
+
+// ListEncryptionKeys is the handler function for the client's own KMS keys, newest first
+func ListEncryptionKeys(c *gin.Context, service interfaces.EncryptionKeyService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	keys, err := service.ListKeys(c.Request.Context(), clientID)
+	if err != nil {
+		respondError(c, err, "Could not list encryption keys")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// GetEncryptionKey is the handler function for one of the client's own KMS keys. Once the
+// key is activated, its migration reports how many applicants have been moved to it.
+func GetEncryptionKey(c *gin.Context, service interfaces.EncryptionKeyService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := service.GetKey(c.Request.Context(), clientID, c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Could not look up encryption key")
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// ValidateEncryptionKey is the handler function for checking the service can encrypt and
+// decrypt with one of the client's keys. A key that fails is answered with 422 and the
+// reason in validation_error.
+func ValidateEncryptionKey(c *gin.Context, service interfaces.EncryptionKeyService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := service.ValidateKey(c.Request.Context(), clientID, c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Could not validate encryption key")
+		return
+	}
+	if key.Status == localModels.EncryptionKeyInvalid {
+		c.JSON(http.StatusUnprocessableEntity, key)
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// ActivateEncryptionKey is the handler function for encrypting the client's new data under
+// a validated key and moving its existing applicants to it. The key is checked again first.
+func ActivateEncryptionKey(c *gin.Context, service interfaces.EncryptionKeyService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := service.ActivateKey(c.Request.Context(), clientID, c.Param("id"))
+	if errors.Is(err, services.ErrKeyNotValidated) && key.Status == localModels.EncryptionKeyInvalid {
+		c.JSON(http.StatusUnprocessableEntity, key)
+		return
+	}
+	if err != nil {
+		respondError(c, err, "Could not activate encryption key")
+		return
+	}
+	c.JSON(http.StatusAccepted, key)
+}
+
+// respondError answers with the status for one of the service's errors
+func respondError(c *gin.Context, err error, message string) {
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	default:
+		zaplogger.GetLogger().Error(message, zap.Error(err), zap.String("encryptionKeyID", c.Param("id")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
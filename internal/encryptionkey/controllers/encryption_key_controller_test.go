@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/encryptionkey/services"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const keyARN = "arn:aws:kms:us-east-1:444455556666:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+// setupKeyRouter registers the encryption key routes behind a fake client login
+func setupKeyRouter(mockService *localMocks.MockEncryptionKeyService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("client_id", "client1") })
+	router.POST("/encryption-keys", func(c *gin.Context) { RegisterEncryptionKey(c, mockService) })
+	router.GET("/encryption-keys", func(c *gin.Context) { ListEncryptionKeys(c, mockService) })
+	router.GET("/encryption-keys/:id", func(c *gin.Context) { GetEncryptionKey(c, mockService) })
+	router.POST("/encryption-keys/:id/validate", func(c *gin.Context) { ValidateEncryptionKey(c, mockService) })
+	router.POST("/encryption-keys/:id/activate", func(c *gin.Context) { ActivateEncryptionKey(c, mockService) })
+	return router
+}
+
+func TestRegisterEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        string
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{"Registers key", `{"key_arn": "` + keyARN + `"}`, nil, http.StatusCreated},
+		{"Not an ARN", `{"key_arn": "alias/acme"}`, services.ErrInvalidKeyARN, http.StatusBadRequest},
+		{"No ARN", `{}`, nil, http.StatusBadRequest},
+		{"Service failure", `{"key_arn": "` + keyARN + `"}`, errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockEncryptionKeyService)
+			mockService.On("RegisterKey", mock.Anything, "client1", mock.Anything).
+				Return(localModels.EncryptionKey{EncryptionKeyID: "key1", KeyARN: keyARN, Status: localModels.EncryptionKeyRegistered}, tt.serviceErr)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/encryption-keys", strings.NewReader(tt.requestBody))
+			setupKeyRouter(mockService).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusCreated {
+				assert.Equal(t, "/encryption-keys/key1", w.Header().Get("Location"))
+				assert.Contains(t, w.Body.String(), `"status":"registered"`)
+				assert.NotContains(t, w.Body.String(), "client1")
+			}
+		})
+	}
+}
+
+func TestValidateEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name               string
+		key                localModels.EncryptionKey
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{"Key passes", localModels.EncryptionKey{Status: localModels.EncryptionKeyValidated}, nil, http.StatusOK},
+		{"Key fails", localModels.EncryptionKey{Status: localModels.EncryptionKeyInvalid, ValidationError: "AccessDeniedException"}, nil, http.StatusUnprocessableEntity},
+		{"Already activated", localModels.EncryptionKey{}, services.ErrKeyInUse, http.StatusConflict},
+		{"Unknown key", localModels.EncryptionKey{}, services.ErrKeyNotFound, http.StatusNotFound},
+		{"No KMS", localModels.EncryptionKey{}, services.ErrKeysDisabled, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockEncryptionKeyService)
+			mockService.On("ValidateKey", mock.Anything, "client1", "key1").Return(tt.key, tt.serviceErr)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/encryption-keys/key1/validate", nil)
+			setupKeyRouter(mockService).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.key.ValidationError)
+		})
+	}
+}
+
+func TestActivateEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name               string
+		key                localModels.EncryptionKey
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{"Activates key", localModels.EncryptionKey{Status: localModels.EncryptionKeyMigrating, Migration: &localModels.KeyMigration{}}, nil, http.StatusAccepted},
+		{"Not validated", localModels.EncryptionKey{}, services.ErrKeyNotValidated, http.StatusConflict},
+		{"Fails its last check", localModels.EncryptionKey{Status: localModels.EncryptionKeyInvalid, ValidationError: "KMSInvalidStateException"}, services.ErrKeyNotValidated, http.StatusUnprocessableEntity},
+		{"Client not registered", localModels.EncryptionKey{}, services.ErrClientNotRegistered, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockEncryptionKeyService)
+			mockService.On("ActivateKey", mock.Anything, "client1", "key1").Return(tt.key, tt.serviceErr)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/encryption-keys/key1/activate", nil)
+			setupKeyRouter(mockService).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.key.ValidationError)
+		})
+	}
+}
+
+func TestGetEncryptionKey(t *testing.T) {
+	mockService := new(localMocks.MockEncryptionKeyService)
+	mockService.On("GetKey", mock.Anything, "client1", "key1").Return(localModels.EncryptionKey{
+		EncryptionKeyID: "key1",
+		Status:          localModels.EncryptionKeyMigrating,
+		Migration:       &localModels.KeyMigration{Total: 10, Migrated: 4},
+	}, nil)
+	mockService.On("GetKey", mock.Anything, "client1", "missing").Return(localModels.EncryptionKey{}, services.ErrKeyNotFound)
+	mockService.On("ListKeys", mock.Anything, "client1").Return([]localModels.EncryptionKey{}, nil)
+	router := setupKeyRouter(mockService)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/encryption-keys/key1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":10,"migrated":4`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/encryption-keys/missing", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/encryption-keys", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"keys": []}`, w.Body.String())
+}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/keyring"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	rekeyServices "github.com/rachel-lawrie/verus_app_backend/internal/rekey/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrKeyNotFound is returned when the client has no encryption key with the requested ID
+	ErrKeyNotFound = apperr.NotFound("encryption_key_not_found", "encryption key not found")
+	// ErrInvalidKeyARN is returned when a key is registered with something other than a KMS key ARN
+	ErrInvalidKeyARN = apperr.BadRequest("invalid_key_arn", "key_arn must be the ARN of a KMS key or alias")
+	// ErrKeyNotValidated is returned when a key is activated before it passed validation
+	ErrKeyNotValidated = apperr.Conflict("encryption_key_not_validated", "the key must pass validation before it is activated")
+	// ErrKeyInUse is returned when a key that was already activated is validated or activated again
+	ErrKeyInUse = apperr.Conflict("encryption_key_in_use", "the key has already been activated")
+	// ErrClientNotRegistered is returned when a client without a record activates a key
+	ErrClientNotRegistered = apperr.Conflict("client_not_registered", "the client must be registered by an admin before it can use its own key")
+	// ErrKeysDisabled is returned when no KMS is configured to check keys with
+	ErrKeysDisabled = apperr.Unavailable("encryption_keys_unavailable", "client-managed keys are not available")
+)
+
+// defaultBatchSize is how many applicants the migration reads at a time
+const defaultBatchSize = 100
+
+// KeyChecker shows the service can use a KMS key
+type KeyChecker interface {
+	Check(ctx context.Context, keyID string) error
+}
+
+// EncryptionKeyServiceImpl takes clients' own KMS keys into use. A client registers a key,
+// validates it with a test encrypt and decrypt, and activates it, after which new data is
+// encrypted under it and the encryption_key_migration job moves the client's existing
+// applicants to it with the rekey service.
+type EncryptionKeyServiceImpl struct {
+	CollectionName      string
+	ClientCollection    string
+	ApplicantCollection string
+	Checker             KeyChecker                   // Nil when KMS calls are not available
+	Rekey               localInterfaces.RekeyService // Moves applicants to the new key
+	Trigger             func(ctx context.Context)    // Starts the migration job; nil waits for its schedule
+	BatchSize           int64
+}
+
+var (
+	instance EncryptionKeyServiceImpl
+	once     sync.Once
+)
+
+func GetEncryptionKeyServiceImpl() EncryptionKeyServiceImpl {
+	once.Do(func() {
+		instance = EncryptionKeyServiceImpl{
+			CollectionName:      localConstants.CollectionEncryptionKeys,
+			ClientCollection:    localConstants.CollectionClients,
+			ApplicantCollection: constants.CollectionApplicants,
+		}
+	})
+	return instance
+}
+
+// RegisterKey records a KMS key the client wants its data encrypted under. The key is not
+// used until it is validated and activated.
+func (s *EncryptionKeyServiceImpl) RegisterKey(ctx context.Context, clientID, keyARN string) (localModels.EncryptionKey, error) {
+	keyARN = strings.TrimSpace(keyARN)
+	if !keyring.ValidKeyARN(keyARN) {
+		return localModels.EncryptionKey{}, ErrInvalidKeyARN
+	}
+	key := localModels.EncryptionKey{
+		EncryptionKeyID: ids.New(),
+		ClientID:        clientID,
+		KeyARN:          keyARN,
+		Status:          localModels.EncryptionKeyRegistered,
+		RegisteredAt:    timestamp.Now(),
+	}
+	if err := mongoretry.InsertOnce(ctx, common.GetCollection(s.CollectionName), "register_encryption_key", bson.M{"encryption_key_id": key.EncryptionKeyID}, key); err != nil {
+		return localModels.EncryptionKey{}, fmt.Errorf("failed to register key: %w", err)
+	}
+	return key, nil
+}
+
+// ListKeys returns the client's keys, newest first
+func (s *EncryptionKeyServiceImpl) ListKeys(ctx context.Context, clientID string) ([]localModels.EncryptionKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "registered_at", Value: -1}})
+	cursor, err := tenant.Guard(common.GetCollection(s.CollectionName)).Find(ctx, tenant.Of(clientID), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	keys := []localModels.EncryptionKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode keys: %w", err)
+	}
+	return keys, nil
+}
+
+// GetKey returns one of the client's keys, with the progress of its migration once activated
+func (s *EncryptionKeyServiceImpl) GetKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error) {
+	var key localModels.EncryptionKey
+	err := tenant.Guard(common.GetCollection(s.CollectionName)).
+		FindOne(ctx, tenant.Of(clientID).With("encryption_key_id", encryptionKeyID)).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return localModels.EncryptionKey{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return localModels.EncryptionKey{}, fmt.Errorf("failed to look up key: %w", err)
+	}
+	return key, nil
+}
+
+// ValidateKey makes a data key under the key and encrypts a probe with it, and decrypts
+// both. The key is marked validated or invalid; a key that fails is not an error, and its
+// status and validation_error say why.
+func (s *EncryptionKeyServiceImpl) ValidateKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error) {
+	if s.Checker == nil {
+		return localModels.EncryptionKey{}, ErrKeysDisabled
+	}
+	key, err := s.GetKey(ctx, clientID, encryptionKeyID)
+	if err != nil {
+		return localModels.EncryptionKey{}, err
+	}
+	if !validatable(key.Status) {
+		return localModels.EncryptionKey{}, ErrKeyInUse
+	}
+
+	now := timestamp.Now()
+	set := bson.M{"validated_at": now}
+	unset := bson.M{}
+	if err := s.Checker.Check(ctx, key.KeyARN); err != nil {
+		zaplogger.GetLogger().Warn("Client encryption key failed validation", zap.Error(err),
+			zap.String("clientID", clientID), zap.String("encryptionKeyID", encryptionKeyID))
+		key.Status, key.ValidationError = localModels.EncryptionKeyInvalid, err.Error()
+		set["validation_error"] = key.ValidationError
+	} else {
+		key.Status, key.ValidationError = localModels.EncryptionKeyValidated, ""
+		unset["validation_error"] = ""
+	}
+	key.ValidatedAt = &now
+	set["status"] = key.Status
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	filter := tenant.Of(clientID).With("encryption_key_id", encryptionKeyID).
+		With("status", bson.M{"$in": []localModels.EncryptionKeyStatus{localModels.EncryptionKeyRegistered, localModels.EncryptionKeyValidated, localModels.EncryptionKeyInvalid}})
+	var matched int64
+	err = mongoretry.Write(ctx, "validate_encryption_key", func(ctx context.Context) error {
+		result, err := tenant.Guard(common.GetCollection(s.CollectionName)).UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		matched = result.MatchedCount
+		return nil
+	})
+	if err != nil {
+		return localModels.EncryptionKey{}, fmt.Errorf("failed to record validation: %w", err)
+	}
+	if matched == 0 {
+		return localModels.EncryptionKey{}, ErrKeyInUse
+	}
+	return key, nil
+}
+
+// ActivateKey switches the client to a validated key. The key is checked once more, then
+// set in the client's settings, and any key it replaces is retired. New data is encrypted
+// under it within keyring.CacheTTL, after which the migration moves existing applicants.
+func (s *EncryptionKeyServiceImpl) ActivateKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error) {
+	key, err := s.GetKey(ctx, clientID, encryptionKeyID)
+	if err != nil {
+		return localModels.EncryptionKey{}, err
+	}
+	if key.Status != localModels.EncryptionKeyValidated {
+		if !validatable(key.Status) {
+			return localModels.EncryptionKey{}, ErrKeyInUse
+		}
+		return localModels.EncryptionKey{}, ErrKeyNotValidated
+	}
+	// The key may have been disabled or its grant revoked since it was validated
+	if key, err = s.ValidateKey(ctx, clientID, encryptionKeyID); err != nil {
+		return localModels.EncryptionKey{}, err
+	}
+	if key.Status != localModels.EncryptionKeyValidated {
+		return key, ErrKeyNotValidated
+	}
+
+	if err := s.setClientKey(ctx, clientID, key.KeyARN); err != nil {
+		return localModels.EncryptionKey{}, err
+	}
+	now := timestamp.Now()
+	cutover := now.Add(keyring.CacheTTL)
+	collection := common.GetCollection(s.CollectionName)
+	err = mongoretry.Write(ctx, "retire_encryption_keys", func(ctx context.Context) error {
+		_, err := collection.UpdateMany(ctx, bson.M{
+			"client_id":         clientID,
+			"encryption_key_id": bson.M{"$ne": encryptionKeyID},
+			"status":            bson.M{"$in": []localModels.EncryptionKeyStatus{localModels.EncryptionKeyMigrating, localModels.EncryptionKeyActive}},
+		}, bson.M{"$set": bson.M{"status": localModels.EncryptionKeyRetired, "retired_at": now}})
+		return err
+	})
+	if err != nil {
+		return localModels.EncryptionKey{}, fmt.Errorf("failed to retire earlier keys: %w", err)
+	}
+
+	key.Status, key.ActivatedAt, key.CutoverAt = localModels.EncryptionKeyMigrating, &now, &cutover
+	key.Migration = &localModels.KeyMigration{UpdatedAt: now}
+	err = mongoretry.Write(ctx, "activate_encryption_key", func(ctx context.Context) error {
+		_, err := tenant.Guard(collection).UpdateOne(ctx,
+			tenant.Of(clientID).With("encryption_key_id", encryptionKeyID).With("status", localModels.EncryptionKeyValidated),
+			bson.M{"$set": bson.M{"status": key.Status, "activated_at": now, "cutover_at": cutover, "migration": key.Migration}})
+		return err
+	})
+	if err != nil {
+		return localModels.EncryptionKey{}, fmt.Errorf("failed to activate key: %w", err)
+	}
+	zaplogger.GetLogger().Info("Client encryption key activated", zap.String("clientID", clientID),
+		zap.String("encryptionKeyID", encryptionKeyID), zap.Time("cutoverAt", cutover))
+
+	// Applicants rekeyed before every replica has the new key could be sealed under the old one
+	if s.Trigger != nil {
+		time.AfterFunc(time.Until(cutover), func() { s.Trigger(context.Background()) })
+	}
+	return key, nil
+}
+
+// setClientKey sets the key the client's new data keys are made under
+func (s *EncryptionKeyServiceImpl) setClientKey(ctx context.Context, clientID, keyARN string) error {
+	var matched int64
+	err := mongoretry.Write(ctx, "set_client_kms_key", func(ctx context.Context) error {
+		result, err := common.GetCollection(s.ClientCollection).UpdateOne(ctx, bson.M{"client_id": clientID},
+			bson.M{"$set": bson.M{"settings.kms_key_id": keyARN, "updated_at": timestamp.Now()}})
+		if err != nil {
+			return err
+		}
+		matched = result.MatchedCount
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set the client's key: %w", err)
+	}
+	if matched == 0 {
+		return ErrClientNotRegistered
+	}
+	return nil
+}
+
+// MigratePending moves the applicants of clients whose key passed its cutover to that key,
+// and marks each key active once none are left. Applicants that fail are tried again on
+// the next run. It is run by the encryption_key_migration job.
+func (s *EncryptionKeyServiceImpl) MigratePending(ctx context.Context) error {
+	if s.Rekey == nil {
+		return nil
+	}
+	cursor, err := common.GetCollection(s.CollectionName).Find(ctx, bson.M{
+		"status":     localModels.EncryptionKeyMigrating,
+		"cutover_at": bson.M{"$lte": timestamp.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list migrating keys: %w", err)
+	}
+	var keys []localModels.EncryptionKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return fmt.Errorf("failed to decode migrating keys: %w", err)
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := s.migrate(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("key %s: %w", key.EncryptionKeyID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// migrate rekeys the client's applicants created before the key's cutover that have not
+// been rekeyed since, then records the migration's progress
+func (s *EncryptionKeyServiceImpl) migrate(ctx context.Context, key localModels.EncryptionKey) error {
+	logger := zaplogger.GetLogger().With(zap.String("clientID", key.ClientID), zap.String("encryptionKeyID", key.EncryptionKeyID))
+	scope, pending := migrationFilters(key)
+
+	failed := []string{} // Left out of later batches in this run
+	var failures, filesSkipped int64
+	lastError := ""
+	var runErr error
+	for runErr == nil {
+		ids, err := s.applicantIDs(ctx, pending.With("applicant_id", bson.M{"$nin": failed}))
+		if err != nil {
+			runErr = err
+			break
+		}
+		if len(ids) == 0 {
+			break
+		}
+		for _, applicantID := range ids {
+			result, err := s.Rekey.Rekey(ctx, key.ClientID, applicantID)
+			switch {
+			case err == nil:
+				filesSkipped += int64(len(result.Skipped))
+			case errors.Is(err, apperr.ErrApplicantNotFound):
+				// Deleted since it was listed, so it no longer counts
+				failed = append(failed, applicantID)
+			case mongoretry.IsTransient(err), errors.Is(err, rekeyServices.ErrRekeyDisabled), ctx.Err() != nil:
+				runErr = err
+			default:
+				logger.Warn("Error migrating applicant to client encryption key", zap.Error(err), zap.String("applicantID", applicantID))
+				failed = append(failed, applicantID)
+				failures++
+				lastError = err.Error()
+			}
+			if runErr != nil {
+				break
+			}
+		}
+	}
+
+	// Progress is recorded even when the run stops early, so it reflects the rekeys made
+	total, err := s.count(ctx, scope)
+	if err != nil {
+		return errors.Join(runErr, err)
+	}
+	remaining, err := s.count(ctx, pending)
+	if err != nil {
+		return errors.Join(runErr, err)
+	}
+	now := timestamp.Now()
+	set := bson.M{
+		"migration.total":      total,
+		"migration.migrated":   total - remaining,
+		"migration.failed":     failures,
+		"migration.last_error": lastError,
+		"migration.updated_at": now,
+	}
+	if remaining == 0 && runErr == nil {
+		set["status"] = localModels.EncryptionKeyActive
+		set["migration.completed_at"] = now
+		logger.Info("Client encryption key migration completed", zap.Int64("applicants", total))
+	}
+	err = mongoretry.Write(ctx, "record_key_migration", func(ctx context.Context) error {
+		_, err := common.GetCollection(s.CollectionName).UpdateOne(ctx,
+			bson.M{"encryption_key_id": key.EncryptionKeyID, "status": localModels.EncryptionKeyMigrating},
+			bson.M{"$set": set, "$inc": bson.M{"migration.files_skipped": filesSkipped}})
+		return err
+	})
+	return errors.Join(runErr, err)
+}
+
+// migrationFilters returns filters of the client's applicants the key's migration covers,
+// and of those still to be moved to the key
+func migrationFilters(key localModels.EncryptionKey) (tenant.Filter, tenant.Filter) {
+	scope := tenant.Of(key.ClientID).With("deleted", false).With("created_at", bson.M{"$lt": *key.CutoverAt})
+	pending := scope.With("rekeyed_at", bson.M{"$not": bson.M{"$gte": *key.CutoverAt}})
+	return scope, pending
+}
+
+// applicantIDs returns the IDs of a batch of applicants matching a filter, oldest first
+func (s *EncryptionKeyServiceImpl) applicantIDs(ctx context.Context, f tenant.Filter) ([]string, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetProjection(bson.M{"applicant_id": 1}).
+		SetLimit(s.batchSize())
+	cursor, err := tenant.Guard(common.GetCollection(s.ApplicantCollection)).Find(ctx, f, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applicants: %w", err)
+	}
+	var applicants []struct {
+		ApplicantID string `bson:"applicant_id"`
+	}
+	if err := cursor.All(ctx, &applicants); err != nil {
+		return nil, fmt.Errorf("failed to decode applicants: %w", err)
+	}
+	ids := make([]string, 0, len(applicants))
+	for _, applicant := range applicants {
+		ids = append(ids, applicant.ApplicantID)
+	}
+	return ids, nil
+}
+
+// count counts the applicants matching a filter
+func (s *EncryptionKeyServiceImpl) count(ctx context.Context, f tenant.Filter) (int64, error) {
+	filter, err := f.BSON()
+	if err != nil {
+		return 0, err
+	}
+	n, err := common.GetCollection(s.ApplicantCollection).CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count applicants: %w", err)
+	}
+	return n, nil
+}
+
+func (s *EncryptionKeyServiceImpl) batchSize() int64 {
+	if s.BatchSize > 0 {
+		return s.BatchSize
+	}
+	return defaultBatchSize
+}
+
+// validatable reports whether a key in a status may be validated, that is whether it has
+// not been activated
+func validatable(status localModels.EncryptionKeyStatus) bool {
+	switch status {
+	case localModels.EncryptionKeyRegistered, localModels.EncryptionKeyValidated, localModels.EncryptionKeyInvalid:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMigrationFilters(t *testing.T) {
+	cutover := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	scope, pending := migrationFilters(localModels.EncryptionKey{ClientID: "client1", CutoverAt: &cutover})
+
+	scoped, err := scope.BSON()
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"client_id": "client1", "deleted": false, "created_at": bson.M{"$lt": cutover}}, scoped)
+
+	// Applicants never rekeyed have no rekeyed_at, which $not matches
+	left, err := pending.BSON()
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"$not": bson.M{"$gte": cutover}}, left["rekeyed_at"])
+	assert.Equal(t, bson.M{"$lt": cutover}, left["created_at"])
+}
+
+func TestRegisterKeyRequiresARN(t *testing.T) {
+	service := GetEncryptionKeyServiceImpl()
+	_, err := service.RegisterKey(context.Background(), "client1", "1234abcd-12ab-34cd-56ef-1234567890ab")
+	assert.ErrorIs(t, err, ErrInvalidKeyARN)
+}
+
+func TestValidateKeyWithoutKMS(t *testing.T) {
+	service := GetEncryptionKeyServiceImpl()
+	_, err := service.ValidateKey(context.Background(), "client1", "key1")
+	assert.ErrorIs(t, err, ErrKeysDisabled)
+}
+
+func TestValidatable(t *testing.T) {
+	assert.True(t, validatable(localModels.EncryptionKeyRegistered))
+	assert.True(t, validatable(localModels.EncryptionKeyInvalid))
+	assert.False(t, validatable(localModels.EncryptionKeyMigrating))
+	assert.False(t, validatable(localModels.EncryptionKeyRetired))
+}
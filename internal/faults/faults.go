@@ -0,0 +1,144 @@
+// Package faults injects failures into the sandbox, so clients can check how their
+// integration retries and backs off before they go live. Only clients that opt in with
+// settings.sandbox.inject_faults are affected: some of their requests are delayed, some
+// are answered with a server error without being handled, and some webhook deliveries
+// are dropped and retried as if their endpoint had failed.
+//
+// Injected errors carry the X-Verus-Injected-Fault header, so a client can tell them
+// from real ones in its logs.
+package faults
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// Environment is the only environment faults may be injected in
+const Environment = "sandbox"
+
+// Header marks a response whose error was injected, naming the kind of fault
+const Header = "X-Verus-Injected-Fault"
+
+// Code is the error code of an injected error response
+const Code = "injected_fault"
+
+// ErrNotSandbox is returned when fault injection is switched on outside the sandbox
+var ErrNotSandbox = errors.New("fault injection can only be enabled in the sandbox environment")
+
+// statuses are the errors a failed request is answered with, as a client sees them from
+// an overloaded or restarting service
+var statuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Injector decides which of an opted-in client's requests and deliveries fail
+type Injector struct {
+	settings config.FaultInjectionSettings
+	clients  clientconfig.Loader // Reads the opt-in of webhook deliveries, made outside a request
+	sleep    func(ctx context.Context, d time.Duration)
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New creates an Injector, or returns nil if fault injection is off. It fails if fault
+// injection is on in any environment but the sandbox.
+func New(settings config.FaultInjectionSettings, env string, clients clientconfig.Loader) (*Injector, error) {
+	if !settings.Enabled {
+		return nil, nil
+	}
+	if env != Environment {
+		return nil, ErrNotSandbox
+	}
+	return &Injector{
+		settings: settings,
+		clients:  clients,
+		sleep:    wait,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Middleware delays or fails requests of clients that opted in. It must follow
+// clientconfig.Middleware. A nil Injector passes every request through.
+func (i *Injector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if i == nil {
+			c.Next()
+			return
+		}
+		client, err := clientconfig.FromContext(c)
+		if err != nil || !client.Settings.Sandbox.InjectFaults {
+			c.Next()
+			return
+		}
+
+		if i.roll(i.settings.LatencyRate) && i.settings.MaxLatency > 0 {
+			d := time.Duration(i.int63n(int64(i.settings.MaxLatency)))
+			c.Header(Header+"-Latency", d.Round(time.Millisecond).String())
+			i.sleep(c.Request.Context(), d)
+		}
+		if i.roll(i.settings.ErrorRate) {
+			status := statuses[i.int63n(int64(len(statuses)))]
+			if status == http.StatusServiceUnavailable {
+				c.Header("Retry-After", "1")
+			}
+			c.Header(Header, "error")
+			zaplogger.GetLogger().Info("Injected fault", zap.String("clientID", client.ClientID), zap.Int("status", status))
+			c.AbortWithStatusJSON(status, gin.H{"error": "Injected fault for retry testing", "code": Code})
+			return
+		}
+		c.Next()
+	}
+}
+
+// DropDelivery reports whether a webhook delivery to the client is dropped. A nil
+// Injector drops nothing.
+func (i *Injector) DropDelivery(ctx context.Context, clientID string) bool {
+	if i == nil || i.clients == nil {
+		return false
+	}
+	client, err := i.clients.Load(ctx, clientID)
+	if err != nil || !client.Settings.Sandbox.InjectFaults {
+		return false
+	}
+	return i.roll(i.settings.WebhookDropRate)
+}
+
+// roll reports whether an event with the given rate happens this time
+func (i *Injector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Float64() < rate
+}
+
+func (i *Injector) int63n(n int64) int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Int63n(n)
+}
+
+// wait sleeps for d, returning early if the request is cancelled
+func wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
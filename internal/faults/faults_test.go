@@ -0,0 +1,103 @@
+package faults
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClients returns each client with the sandbox opt-in it is listed with
+type fakeClients map[string]bool
+
+func (f fakeClients) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	client := localModels.Client{ClientID: clientID}
+	client.Settings.Sandbox.InjectFaults = f[clientID]
+	return client, nil
+}
+
+var clients = fakeClients{"opted-in": true, "opted-out": false}
+
+func newInjector(t *testing.T, settings config.FaultInjectionSettings) (*Injector, *[]time.Duration) {
+	settings.Enabled = true
+	injector, err := New(settings, Environment, clients)
+	require.NoError(t, err)
+	var slept []time.Duration
+	injector.sleep = func(ctx context.Context, d time.Duration) { slept = append(slept, d) }
+	return injector, &slept
+}
+
+func serve(injector *Injector, clientID string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("client_id", clientID) })
+	router.Use(clientconfig.Middleware(clients))
+	router.Use(injector.Middleware())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNew(t *testing.T) {
+	injector, err := New(config.FaultInjectionSettings{}, "prod", clients)
+	assert.NoError(t, err)
+	assert.Nil(t, injector)
+
+	_, err = New(config.FaultInjectionSettings{Enabled: true}, "prod", clients)
+	assert.ErrorIs(t, err, ErrNotSandbox)
+
+	injector, err = New(config.FaultInjectionSettings{Enabled: true}, Environment, clients)
+	assert.NoError(t, err)
+	assert.NotNil(t, injector)
+}
+
+func TestMiddlewareFailsRequestsOfOptedInClients(t *testing.T) {
+	injector, _ := newInjector(t, config.FaultInjectionSettings{ErrorRate: 1})
+
+	w := serve(injector, "opted-in")
+	assert.Contains(t, statuses, w.Code)
+	assert.Equal(t, "error", w.Header().Get(Header))
+	assert.Contains(t, w.Body.String(), Code)
+
+	w = serve(injector, "opted-out")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(Header))
+}
+
+func TestMiddlewareDelaysRequests(t *testing.T) {
+	injector, slept := newInjector(t, config.FaultInjectionSettings{LatencyRate: 1, MaxLatency: time.Second})
+
+	w := serve(injector, "opted-in")
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, *slept, 1)
+	assert.Less(t, (*slept)[0], time.Second)
+
+	serve(injector, "opted-out")
+	assert.Len(t, *slept, 1)
+}
+
+func TestMiddlewareWithoutInjector(t *testing.T) {
+	var injector *Injector
+	assert.Equal(t, http.StatusOK, serve(injector, "opted-in").Code)
+	assert.False(t, injector.DropDelivery(context.Background(), "opted-in"))
+}
+
+func TestDropDelivery(t *testing.T) {
+	injector, _ := newInjector(t, config.FaultInjectionSettings{WebhookDropRate: 1})
+	assert.True(t, injector.DropDelivery(context.Background(), "opted-in"))
+	assert.False(t, injector.DropDelivery(context.Background(), "opted-out"))
+
+	injector, _ = newInjector(t, config.FaultInjectionSettings{})
+	assert.False(t, injector.DropDelivery(context.Background(), "opted-in"))
+}
@@ -0,0 +1,93 @@
+// Package geoip resolves IP addresses to the country they are registered in
+package geoip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/country"
+)
+
+// Locator resolves an IP address to an ISO 3166-1 alpha-3 country code
+type Locator interface {
+	Country(ip netip.Addr) (string, bool)
+}
+
+// RangeTable is a Locator backed by a list of network ranges, such as an
+// exported GeoLite2 country CSV reduced to "network,country" lines
+type RangeTable struct {
+	ranges []countryRange
+}
+
+type countryRange struct {
+	prefix  netip.Prefix
+	country string
+}
+
+// LoadCSV reads a range table from a file of "cidr,country" lines
+func LoadCSV(path string) (*RangeTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %v", err)
+	}
+	defer f.Close()
+	return ParseCSV(f)
+}
+
+// ParseCSV reads "cidr,country" lines. Blank lines, comments and a header line are skipped.
+func ParseCSV(r io.Reader) (*RangeTable, error) {
+	table := &RangeTable{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected cidr,country", line)
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(fields[0]))
+		if err != nil {
+			if line == 1 {
+				continue // Header
+			}
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		code, ok := country.Normalize(strings.TrimSpace(fields[1]))
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown country %q", line, fields[1])
+		}
+		table.ranges = append(table.ranges, countryRange{prefix: prefix.Masked(), country: code})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Country returns the country of the most specific range containing ip
+func (t *RangeTable) Country(ip netip.Addr) (string, bool) {
+	ip = ip.Unmap()
+	best := -1
+	code := ""
+	for _, r := range t.ranges {
+		if r.prefix.Bits() > best && r.prefix.Contains(ip) {
+			best = r.prefix.Bits()
+			code = r.country
+		}
+	}
+	return code, best >= 0
+}
+
+// IsPublic reports whether ip is routable on the internet, and so worth geolocating
+func IsPublic(ip netip.Addr) bool {
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast()
+}
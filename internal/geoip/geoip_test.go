@@ -0,0 +1,51 @@
+package geoip
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeTable(t *testing.T) {
+	table, err := ParseCSV(strings.NewReader(`network,country
+# Documentation ranges used for tests
+203.0.113.0/24,GB
+203.0.113.128/25,FR
+2001:db8::/32,DE
+`))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		ip       string
+		expected string
+		found    bool
+	}{
+		{"203.0.113.10", "GBR", true},
+		{"203.0.113.200", "FRA", true}, // Most specific range wins
+		{"::ffff:203.0.113.10", "GBR", true},
+		{"2001:db8::1", "DEU", true},
+		{"198.51.100.1", "", false},
+	}
+	for _, tt := range tests {
+		code, found := table.Country(netip.MustParseAddr(tt.ip))
+		assert.Equal(t, tt.expected, code, tt.ip)
+		assert.Equal(t, tt.found, found, tt.ip)
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("203.0.113.0/24,GB\nnot-a-network,FR\n"))
+	assert.Error(t, err)
+
+	_, err = ParseCSV(strings.NewReader("203.0.113.0/24,XX\n"))
+	assert.Error(t, err)
+}
+
+func TestIsPublic(t *testing.T) {
+	assert.True(t, IsPublic(netip.MustParseAddr("203.0.113.10")))
+	assert.False(t, IsPublic(netip.MustParseAddr("10.1.2.3")))
+	assert.False(t, IsPublic(netip.MustParseAddr("127.0.0.1")))
+	assert.False(t, IsPublic(netip.Addr{}))
+}
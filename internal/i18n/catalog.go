@@ -0,0 +1,79 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// catalog holds one language's translations. Messages are keyed by their English text;
+// a key with %s in it matches any message with text in those places, which is carried
+// over into the translation's %s in the same order. Labels are keyed by kind and code,
+// such as "document_type.passport".
+type catalog struct {
+	messages map[string]string
+	patterns []pattern
+	labels   map[string]string
+}
+
+// pattern matches messages with dynamic parts, such as limits and identifiers
+type pattern struct {
+	match       *regexp.Regexp
+	translation string
+}
+
+// catalogs are the languages with translations, keyed by lowercase language tag
+var catalogs = map[string]catalog{
+	"en": newCatalog(nil, englishLabels),
+	"es": newCatalog(spanishMessages, spanishLabels),
+}
+
+func newCatalog(messages, labels map[string]string) catalog {
+	cat := catalog{messages: map[string]string{}, labels: labels}
+	for msg, translation := range messages {
+		if !strings.Contains(msg, "%s") {
+			cat.messages[msg] = translation
+			continue
+		}
+		parts := strings.Split(msg, "%s")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		cat.patterns = append(cat.patterns, pattern{
+			match:       regexp.MustCompile("^" + strings.Join(parts, "(.+?)") + "$"),
+			translation: translation,
+		})
+	}
+	// Try the most specific patterns first, whatever order the map gave them in
+	sort.Slice(cat.patterns, func(i, j int) bool {
+		a, b := cat.patterns[i].match.String(), cat.patterns[j].match.String()
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return cat
+}
+
+// message returns the translation of a message, preferring an exact match to a pattern
+func (cat catalog) message(msg string) (string, bool) {
+	if translation, ok := cat.messages[msg]; ok {
+		return translation, true
+	}
+	for _, p := range cat.patterns {
+		args := p.match.FindStringSubmatch(msg)
+		if args == nil {
+			continue
+		}
+		parts := strings.Split(p.translation, "%s")
+		var out strings.Builder
+		for i, part := range parts {
+			out.WriteString(part)
+			if i+1 < len(parts) && i+1 < len(args) {
+				out.WriteString(args[i+1])
+			}
+		}
+		return out.String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,263 @@
+// Package i18n translates API error messages, and the labels of document types and
+// review reason codes, into the language a request asks for with Accept-Language.
+//
+// Messages are looked up by their English text, so handlers keep writing English and
+// Middleware translates error bodies on their way out. Each language in the request's
+// fallback chain is tried in turn, ending with English, which needs no catalog.
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultLanguage is the language messages are written in, and the end of every fallback chain
+const DefaultLanguage = "en"
+
+const (
+	contextKey    = "i18n_localizer"
+	translatedKey = "i18n_translated"
+	maxLanguages  = 10       // Languages read from one Accept-Language header
+	maxErrorBody  = 64 << 10 // Larger error bodies are passed through untranslated
+)
+
+// Negotiate returns the fallback chain for an Accept-Language header: the languages
+// asked for by preference, each followed by its base language, then DefaultLanguage.
+// Languages refused with q=0 and the "*" wildcard are left out.
+//
+//	Negotiate("es-MX, fr;q=0.8") // [es-mx es fr en]
+func Negotiate(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		if len(tags) == maxLanguages {
+			break
+		}
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if key, value, ok := strings.Cut(params, "="); ok && strings.TrimSpace(key) == "q" {
+			q, _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var chain []string
+	seen := map[string]bool{}
+	add := func(tag string) {
+		if !seen[tag] {
+			seen[tag] = true
+			chain = append(chain, tag)
+		}
+	}
+	for _, t := range tags {
+		add(t.tag)
+		if base, _, ok := strings.Cut(t.tag, "-"); ok {
+			add(base)
+		}
+	}
+	add(DefaultLanguage)
+	return chain
+}
+
+// Localizer translates into a request's fallback chain of languages
+type Localizer struct {
+	chain []string
+}
+
+// New returns a localizer for an Accept-Language header
+func New(acceptLanguage string) Localizer {
+	return Localizer{chain: Negotiate(acceptLanguage)}
+}
+
+// Language returns the first language in the chain that has a catalog
+func (l Localizer) Language() string {
+	for _, lang := range l.chain {
+		if _, ok := catalogs[lang]; ok {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// Message translates an English message, returning it unchanged when no language in
+// the chain before English has a translation for it
+func (l Localizer) Message(msg string) string {
+	for _, lang := range l.chain {
+		if lang == DefaultLanguage {
+			break
+		}
+		if cat, ok := catalogs[lang]; ok {
+			if translated, ok := cat.message(msg); ok {
+				return translated
+			}
+		}
+	}
+	return msg
+}
+
+// Label returns the label of a code of some kind, such as document_type or reason_code,
+// falling back to the code itself when no language in the chain has one
+func (l Localizer) Label(kind, code string) string {
+	for _, lang := range l.chain {
+		if cat, ok := catalogs[lang]; ok {
+			if label, ok := cat.labels[kind+"."+code]; ok {
+				return label
+			}
+		}
+	}
+	return code
+}
+
+// FromContext returns the request's localizer, or one for English outside Middleware
+func FromContext(c *gin.Context) Localizer {
+	if value, ok := c.Get(contextKey); ok {
+		if l, ok := value.(Localizer); ok {
+			return l
+		}
+	}
+	return New(c.GetHeader("Accept-Language"))
+}
+
+// Middleware translates the "error" message and the check details of JSON error
+// responses into the language the request asks for, marking them with Content-Language.
+// Since the body is rewritten, it must be mounted again after any middleware that signs
+// response bodies; only the innermost copy translates.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(contextKey)
+		l, ok := value.(Localizer)
+		if !ok {
+			l = New(c.GetHeader("Accept-Language"))
+			c.Set(contextKey, l)
+			c.Writer.Header().Add("Vary", "Accept-Language")
+		}
+		if l.Language() == DefaultLanguage {
+			c.Next()
+			return
+		}
+
+		w := &translatingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			w.finish(c, l)
+		}()
+		c.Next()
+	}
+}
+
+// translatingWriter holds back JSON error bodies so their messages can be translated
+type translatingWriter struct {
+	gin.ResponseWriter
+	decided   bool
+	buffering bool
+	body      bytes.Buffer
+}
+
+func (w *translatingWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		header := w.Header()
+		w.buffering = w.Status() >= http.StatusBadRequest && header.Get("Content-Encoding") == "" &&
+			strings.HasPrefix(header.Get("Content-Type"), "application/json")
+	}
+	if !w.buffering {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.body.Len()+len(b) > maxErrorBody {
+		w.buffering = false
+		if w.body.Len() > 0 {
+			if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+				return 0, err
+			}
+			w.body.Reset()
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+func (w *translatingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports whether the handler has written anything, including a body held back
+func (w *translatingWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush does nothing while an error body is held back
+func (w *translatingWriter) Flush() {
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish sends a held back body, translated unless a copy of Middleware nearer the
+// handler already did
+func (w *translatingWriter) finish(c *gin.Context, l Localizer) {
+	if !w.buffering {
+		return
+	}
+	body := w.body.Bytes()
+	if !c.GetBool(translatedKey) {
+		c.Set(translatedKey, true)
+		if translated, ok := translateBody(body, l); ok {
+			body = translated
+			w.Header().Set("Content-Language", l.Language())
+		}
+	}
+	w.ResponseWriter.Write(body)
+}
+
+// translateBody translates the messages of a JSON error body, reporting whether any changed
+func translateBody(body []byte, l Localizer) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, false
+	}
+
+	changed := false
+	translate := func(object map[string]interface{}, key string) {
+		if msg, ok := object[key].(string); ok {
+			if translated := l.Message(msg); translated != msg {
+				object[key] = translated
+				changed = true
+			}
+		}
+	}
+	translate(fields, "error")
+	if checks, ok := fields["checks"].([]interface{}); ok {
+		for _, check := range checks {
+			if object, ok := check.(map[string]interface{}); ok {
+				translate(object, "detail")
+			}
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+	translated, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return translated, true
+}
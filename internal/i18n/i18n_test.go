@@ -0,0 +1,166 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string][]string{
+		"":                           {"en"},
+		"es":                         {"es", "en"},
+		"es-MX, fr;q=0.8":            {"es-mx", "es", "fr", "en"},
+		"fr;q=0.5, es-AR;q=0.9, *":   {"es-ar", "es", "fr", "en"},
+		"de, es;q=0, en-GB;q=0.7":    {"de", "en-gb", "en"},
+		" ES ; q=1 , es-es ; q=0.9 ": {"es", "es-es", "en"},
+	}
+	for header, want := range tests {
+		assert.Equal(t, want, Negotiate(header), header)
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	spanish := New("es-MX,en;q=0.5")
+	assert.Equal(t, "es", spanish.Language())
+	assert.Equal(t, "solicitante no encontrado", spanish.Message("applicant not found"))
+	assert.Equal(t, "limit debe estar entre 1 y 100", spanish.Message("limit must be between 1 and 100"))
+	assert.Equal(t, "se declaró image/png pero el contenido es text/plain; charset=utf-8",
+		spanish.Message("declared image/png but content is text/plain; charset=utf-8"))
+	// A message without a translation falls back to English
+	assert.Equal(t, "Audit log failed verification", spanish.Message("Audit log failed verification"))
+	assert.Equal(t, "Pasaporte", spanish.Label("document_type", "passport"))
+	assert.Equal(t, "driving_licence", spanish.Label("document_type", "driving_licence"))
+
+	// Languages without a catalog fall through to the next one asked for
+	french := New("fr-FR, es;q=0.8")
+	assert.Equal(t, "es", french.Language())
+	assert.Equal(t, "documento no encontrado", french.Message("document not found"))
+
+	english := New("de")
+	assert.Equal(t, "en", english.Language())
+	assert.Equal(t, "applicant not found", english.Message("applicant not found"))
+	assert.Equal(t, "Document has expired", english.Label("reason_code", "document_expired"))
+}
+
+func TestCatalogsLabelEveryCode(t *testing.T) {
+	for lang, cat := range catalogs {
+		for _, decision := range []localModels.ReviewDecision{localModels.DecisionApprove, localModels.DecisionReject} {
+			for _, code := range localModels.ReasonCodes(decision) {
+				assert.Contains(t, cat.labels, "reason_code."+code, lang)
+			}
+		}
+	}
+}
+
+// bodyBuffer holds back a response and writes it at the end, as response signing does
+func bodyBuffer(c *gin.Context) {
+	w := &translatingWriter{ResponseWriter: c.Writer, decided: true, buffering: true}
+	c.Writer = w
+	c.Next()
+	c.Writer = w.ResponseWriter
+	c.Header("X-Buffered-Body", w.body.String())
+	c.Writer.Write(w.body.Bytes())
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "applicant not found", "code": "applicant_not_found"})
+	})
+	router.GET("/rejected", func(c *gin.Context) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "document failed upload checks",
+			"checks": []localModels.UploadCheck{{Name: "size", Status: localModels.UploadCheckFailed, Detail: "file is empty"}},
+		})
+	})
+	router.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"error": "applicant not found"})
+	})
+	router.GET("/signed", bodyBuffer, Middleware(), func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+	})
+
+	get := func(path, language string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Language", language)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/missing", "es")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "es", w.Header().Get("Content-Language"))
+	assert.Equal(t, []string{"Accept-Language"}, w.Header().Values("Vary"))
+	assert.JSONEq(t, `{"error":"solicitante no encontrado","code":"applicant_not_found"}`, w.Body.String())
+
+	w = get("/missing", "en-US")
+	assert.Empty(t, w.Header().Get("Content-Language"))
+	assert.JSONEq(t, `{"error":"applicant not found","code":"applicant_not_found"}`, w.Body.String())
+
+	w = get("/rejected", "es")
+	var body struct {
+		Error  string                    `json:"error"`
+		Checks []localModels.UploadCheck `json:"checks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "el documento no superó las comprobaciones de carga", body.Error)
+	assert.Equal(t, "el archivo está vacío", body.Checks[0].Detail)
+
+	// Only error responses are translated
+	w = get("/ok", "es")
+	assert.JSONEq(t, `{"error":"applicant not found"}`, w.Body.String())
+
+	// The copy nearest the handler translates, before the body is held back for signing
+	w = get("/signed", "es")
+	assert.JSONEq(t, `{"error":"documento no encontrado"}`, w.Header().Get("X-Buffered-Body"))
+	assert.Equal(t, w.Header().Get("X-Buffered-Body"), w.Body.String())
+}
+
+func TestMiddlewarePassesLargeBodiesThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	large := bytes.Repeat([]byte("x"), maxErrorBody)
+	router.GET("/large", func(c *gin.Context) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON", "detail": string(large)})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/large", nil)
+	req.Header.Set("Accept-Language", "es")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"Invalid JSON"`)
+}
+
+func TestListLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/labels", ListLabels)
+
+	req := httptest.NewRequest(http.MethodGet, "/labels", nil)
+	req.Header.Set("Accept-Language", "es-ES")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "es", w.Header().Get("Content-Language"))
+
+	var labels localModels.Labels
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &labels))
+	assert.Equal(t, "es", labels.Language)
+	assert.Len(t, labels.DocumentTypes, len(DocumentTypes))
+	assert.Contains(t, labels.ReasonCodes["reject"], localModels.Label{Code: "document_expired", Label: "El documento ha caducado"})
+	assert.Len(t, labels.ReasonCodes["approve"], len(localModels.ReasonCodes(localModels.DecisionApprove)))
+}
@@ -0,0 +1,38 @@
+package i18n
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// DocumentTypes are the document types labelled by ListLabels
+var DocumentTypes = []models.DocumentType{models.DocumentPassport, models.DocumentUtilityBill}
+
+// Labels returns the labels of document types and review reason codes in the localizer's language
+func (l Localizer) Labels() localModels.Labels {
+	labels := localModels.Labels{
+		Language:    l.Language(),
+		ReasonCodes: map[string][]localModels.Label{},
+	}
+	for _, documentType := range DocumentTypes {
+		code := documentType.String()
+		labels.DocumentTypes = append(labels.DocumentTypes, localModels.Label{Code: code, Label: l.Label("document_type", code)})
+	}
+	for _, decision := range []localModels.ReviewDecision{localModels.DecisionApprove, localModels.DecisionReject} {
+		for _, code := range localModels.ReasonCodes(decision) {
+			labels.ReasonCodes[string(decision)] = append(labels.ReasonCodes[string(decision)], localModels.Label{Code: code, Label: l.Label("reason_code", code)})
+		}
+	}
+	return labels
+}
+
+// ListLabels is the handler function for listing the labels of document types and review
+// reason codes in the language the request asks for
+func ListLabels(c *gin.Context) {
+	l := FromContext(c)
+	c.Header("Content-Language", l.Language())
+	c.JSON(http.StatusOK, l.Labels())
+}
@@ -0,0 +1,269 @@
+package i18n
+
+// englishLabels are the labels of codes in the language they are written in
+var englishLabels = map[string]string{
+	"document_type.passport":     "Passport",
+	"document_type.utility_bill": "Utility bill",
+
+	"reason_code.checks_passed":          "All checks passed",
+	"reason_code.manual_verification":    "Verified manually",
+	"reason_code.false_positive_cleared": "False positive cleared",
+	"reason_code.document_forged":        "Document is forged",
+	"reason_code.document_expired":       "Document has expired",
+	"reason_code.identity_mismatch":      "Identity does not match the document",
+	"reason_code.sanctions_match":        "Matches a sanctions list",
+	"reason_code.poor_quality":           "Document image is of poor quality",
+	"reason_code.fraud_suspected":        "Fraud suspected",
+	"reason_code.other":                  "Other",
+}
+
+var spanishLabels = map[string]string{
+	"document_type.passport":     "Pasaporte",
+	"document_type.utility_bill": "Factura de servicios",
+
+	"reason_code.checks_passed":          "Todas las comprobaciones superadas",
+	"reason_code.manual_verification":    "Verificado manualmente",
+	"reason_code.false_positive_cleared": "Falso positivo descartado",
+	"reason_code.document_forged":        "El documento es falso",
+	"reason_code.document_expired":       "El documento ha caducado",
+	"reason_code.identity_mismatch":      "La identidad no coincide con el documento",
+	"reason_code.sanctions_match":        "Coincide con una lista de sanciones",
+	"reason_code.poor_quality":           "La imagen del documento es de mala calidad",
+	"reason_code.fraud_suspected":        "Sospecha de fraude",
+	"reason_code.other":                  "Otro",
+}
+
+// spanishMessages covers the messages clients of the API see: authentication, request
+// validation, missing resources and upload check failures. Messages only shown to
+// admins stay in English.
+var spanishMessages = map[string]string{
+	// Authentication and request handling
+	"API key is missing":                                              "Falta la clave de API",
+	"Invalid or inactive API key":                                     "La clave de API no es válida o está inactiva",
+	"Invalid credentials":                                             "Credenciales no válidas",
+	"invalid credentials":                                             "credenciales no válidas",
+	"invalid token":                                                   "token no válido",
+	"token expired or revoked":                                        "token caducado o revocado",
+	"Token expired or revoked":                                        "Token caducado o revocado",
+	"Invalid or expired refresh token":                                "El token de actualización no es válido o ha caducado",
+	"Too many failed authentication attempts; try again later":        "Demasiados intentos de autenticación fallidos; inténtelo más tarde",
+	"Requests from this IP address are not allowed for this client":   "No se permiten solicitudes desde esta dirección IP para este cliente",
+	"Injected fault for retry testing":                                "Fallo inyectado para probar los reintentos",
+	"grant_type is required":                                          "grant_type es obligatorio",
+	"grant_type must be api_key, password or refresh_token":           "grant_type debe ser api_key, password o refresh_token",
+	"api_key is required":                                             "api_key es obligatorio",
+	"refresh_token is required":                                       "refresh_token es obligatorio",
+	"token is required":                                               "token es obligatorio",
+	"username and password are required":                              "El nombre de usuario y la contraseña son obligatorios",
+	"request must carry X-Timestamp, X-Nonce and X-Signature headers": "La solicitud debe incluir las cabeceras X-Timestamp, X-Nonce y X-Signature",
+	"request timestamp is outside the allowed window":                 "La marca de tiempo de la solicitud está fuera del margen permitido",
+	"request signature does not match":                                "La firma de la solicitud no coincide",
+	"request nonce has already been used":                             "El nonce de la solicitud ya se ha utilizado",
+	"Content-Encoding must be gzip":                                   "Content-Encoding debe ser gzip",
+	"Request body is not valid gzip":                                  "El cuerpo de la solicitud no es gzip válido",
+	"Could not read request body":                                     "No se pudo leer el cuerpo de la solicitud",
+	"Invalid JSON":                                                    "JSON no válido",
+	"Service temporarily unavailable, please retry":                   "Servicio no disponible temporalmente, vuelva a intentarlo",
+	"not found": "no encontrado",
+
+	// Applicants
+	"applicant not found":                                "solicitante no encontrado",
+	"Applicant not found":                                "Solicitante no encontrado",
+	"applicant_id is required":                           "applicant_id es obligatorio",
+	"Applicant ID is required":                           "El ID del solicitante es obligatorio",
+	"device.ip must be a valid IP address":               "device.ip debe ser una dirección IP válida",
+	"level is not enabled for this client":               "El nivel no está habilitado para este cliente",
+	"field cannot be changed: %s":                        "el campo no se puede modificar: %s",
+	"consent is required for this client":                "El consentimiento es obligatorio para este cliente",
+	"consent.text_version is required":                   "consent.text_version es obligatorio",
+	"consent.text_version must be at most %s characters": "consent.text_version debe tener como máximo %s caracteres",
+	"consent.given_at is required":                       "consent.given_at es obligatorio",
+	"consent.given_at is in the future":                  "consent.given_at está en el futuro",
+	"consent.channel must be one of %s":                  "consent.channel debe ser uno de %s",
+	"consent.ip must be a valid IP address":              "consent.ip debe ser una dirección IP válida",
+	"Could not create applicant":                         "No se pudo crear el solicitante",
+	"Could not retrieve applicants":                      "No se pudieron obtener los solicitantes",
+	"Could not retrieve applicant":                       "No se pudo obtener el solicitante",
+	"Could not fetch applicants":                         "No se pudieron obtener los solicitantes",
+	"Could not fetch applicant":                          "No se pudo obtener el solicitante",
+	"Could not update applicant":                         "No se pudo actualizar el solicitante",
+	"applicant is not known to Sumsub":                   "el solicitante no es conocido por Sumsub",
+	"applicant changed during sync, try again":           "el solicitante cambió durante la sincronización, vuelva a intentarlo",
+	"sumsub sync is not configured":                      "la sincronización con Sumsub no está configurada",
+	"sumsub is unavailable":                              "Sumsub no está disponible",
+	"Could not sync applicant":                           "No se pudo sincronizar el solicitante",
+	"unknown verification level: %s":                     "nivel de verificación desconocido: %s",
+
+	// Verification levels
+	"country must be an ISO 3166-1 country code": "country debe ser un código de país ISO 3166-1",
+	"currency must be an ISO 4217 currency code": "currency debe ser un código de moneda ISO 4217",
+	"Could not list verification levels":         "No se pudieron listar los niveles de verificación",
+
+	// Applicants created from documents
+	"level is required":                                         "level es obligatorio",
+	"consent must be a JSON object":                             "consent debe ser un objeto JSON",
+	"applicant details could not be read from the document: %s": "no se pudieron leer los datos del solicitante del documento: %s",
+	"applicant is not awaiting confirmation":                    "el solicitante no está pendiente de confirmación",
+	"first_name and last_name cannot be empty":                  "first_name y last_name no pueden estar vacíos",
+	"dob must be a date as YYYY-MM-DD":                          "dob debe ser una fecha con formato AAAA-MM-DD",
+	"Could not confirm applicant":                               "No se pudo confirmar el solicitante",
+
+	// Hosted verification sessions
+	"ttl_seconds must be a whole number of seconds":                   "ttl_seconds debe ser un número entero de segundos",
+	"invalid session lifetime: ttl_seconds must be between %s and %s": "duración de sesión no válida: ttl_seconds debe estar entre %s y %s",
+	"session not found":                               "sesión no encontrada",
+	"Could not create session":                        "No se pudo crear la sesión",
+	"Could not retrieve session":                      "No se pudo obtener la sesión",
+	"Session token is required":                       "El token de sesión es obligatorio",
+	"Invalid or expired session token":                "Token de sesión no válido o caducado",
+	"Could not verify session":                        "No se pudo verificar la sesión",
+	"Could not open session":                          "No se pudo abrir la sesión",
+	"session is completed or expired":                 "la sesión está completada o ha caducado",
+	"no documents have been uploaded in this session": "no se ha subido ningún documento en esta sesión",
+	"Could not complete session":                      "No se pudo completar la sesión",
+	"no hosted page is configured":                    "no hay ninguna página alojada configurada",
+	"size must be between %s and %s":                  "size debe estar entre %s y %s",
+	"Could not create QR code":                        "No se pudo crear el código QR",
+	"Expected a WebSocket upgrade":                    "Se esperaba una actualización a WebSocket",
+
+	// Documents
+	"document not found":                                    "documento no encontrado",
+	"document version not found":                            "versión del documento no encontrada",
+	"upload job not found":                                  "trabajo de carga no encontrado",
+	"document upload is still in progress":                  "la carga del documento sigue en curso",
+	"document was replaced concurrently":                    "el documento se reemplazó simultáneamente",
+	"document failed upload checks":                         "el documento no superó las comprobaciones de carga",
+	"document id parameter is required":                     "El parámetro de ID del documento es obligatorio",
+	"version must be a positive number":                     "version debe ser un número positivo",
+	"file is required":                                      "El archivo es obligatorio",
+	"Invalid status":                                        "Estado no válido",
+	"reason must be one of %s":                              "reason debe ser uno de %s",
+	"purpose must be at most %s printable ASCII characters": "purpose debe tener como máximo %s caracteres ASCII imprimibles",
+	"document cannot be previewed":                          "el documento no se puede previsualizar",
+	"document cannot be previewed: %s":                      "el documento no se puede previsualizar: %s",
+	"watermark must be true or false":                       "watermark debe ser true o false",
+	"report not found":                                      "informe no encontrado",
+	"report could not be generated":                         "no se pudo generar el informe",
+	"Could not generate report":                             "No se pudo generar el informe",
+	"too many uploads in progress: the client may have %s at once; retry once one finishes":                                                                  "demasiadas cargas en curso: el cliente puede tener %s a la vez; reintente cuando termine alguna",
+	"applicant has reached its document limit: applicants at level %s may have %s documents and this one has %s":                                             "el solicitante alcanzó su límite de documentos: los solicitantes de nivel %s pueden tener %s documentos y este tiene %s",
+	"applicant has reached its storage limit: applicants at level %s may have %s bytes of documents and this one has %s, so a file of %s bytes does not fit": "el solicitante alcanzó su límite de almacenamiento: los solicitantes de nivel %s pueden tener %s bytes de documentos y este tiene %s, así que no cabe un archivo de %s bytes",
+	"Could not create document":                           "No se pudo crear el documento",
+	"Could not retrieve documents":                        "No se pudieron obtener los documentos",
+	"Could not retrieve document":                         "No se pudo obtener el documento",
+	"Could not retrieve document versions":                "No se pudieron obtener las versiones del documento",
+	"Could not retrieve upload job":                       "No se pudo obtener el trabajo de carga",
+	"Could not retrieve document version":                 "No se pudo obtener la versión del documento",
+	"document is archived; restore it before downloading": "el documento está archivado; restáuralo antes de descargarlo",
+	"document is not archived":                            "el documento no está archivado",
+	"Could not restore document":                          "No se pudo restaurar el documento",
+	"Could not retrieve document restore":                 "No se pudo obtener la restauración del documento",
+	"direct uploads are not enabled":                      "las cargas directas no están habilitadas",
+	"invalid direct upload":                               "carga directa no válida",
+	"upload token is invalid or has expired":              "el token de carga no es válido o ha caducado",
+	"file has not been uploaded":                          "el archivo no se ha cargado",
+	"upload was already completed":                        "la carga ya se completó",
+	"Could not presign upload":                            "No se pudo firmar la carga",
+
+	// Upload check details
+	"file is empty":                                  "el archivo está vacío",
+	"file exceeds %s bytes":                          "el archivo supera los %s bytes",
+	"uploaded %s bytes but declared %s":              "se cargaron %s bytes pero se declararon %s",
+	"file does not match the declared checksum":      "el archivo no coincide con la suma de comprobación declarada",
+	"file exceeds the client limit of %s bytes":      "el archivo supera el límite del cliente de %s bytes",
+	"declared %s but content is %s":                  "se declaró %s pero el contenido es %s",
+	"file matches antivirus test signature":          "el archivo coincide con la firma de prueba del antivirus",
+	"pdf contains embedded javascript":               "el pdf contiene javascript incrustado",
+	"pdf contains auto-run action":                   "el pdf contiene una acción de ejecución automática",
+	"pdf contains launch action":                     "el pdf contiene una acción de lanzamiento",
+	"pdf contains embedded file":                     "el pdf contiene un archivo incrustado",
+	"document type %s is not enabled for the client": "el tipo de documento %s no está habilitado para el cliente",
+	"unrecognised country code: %s":                  "código de país no reconocido: %s",
+	"issuing country could not be read from MRZ":     "no se pudo leer el país emisor de la MRZ",
+	"MRZ issuing country is not a recognised code":   "el país emisor de la MRZ no es un código reconocido",
+	"claimed %s but document was issued by %s":       "se indicó %s pero el documento fue emitido por %s",
+	"no verification vendor configured for client":   "no hay ningún proveedor de verificación configurado para el cliente",
+	"verification vendor does not support document: %s cannot verify %s documents issued by %s": "el proveedor de verificación no admite el documento: %s no puede verificar documentos %s emitidos por %s",
+	"applicant has no consent record, so documents cannot be submitted for verification":        "el solicitante no tiene un registro de consentimiento, por lo que no se pueden enviar documentos para su verificación",
+	"image is %sx%s but its shorter side must be at least %s pixels":                            "la imagen mide %sx%s pero su lado más corto debe tener al menos %s píxeles",
+	"image is too blurry to read, with a sharpness of %s where at least %s is needed":           "la imagen está demasiado borrosa para leerla, con una nitidez de %s cuando se necesita al menos %s",
+	"glare covers %s% of the image":           "los reflejos cubren el %s% de la imagen",
+	"document runs off the edge of the image": "el documento se sale del borde de la imagen",
+
+	// Notes, webhooks, usage and stats
+	"body is required":                                           "body es obligatorio",
+	"body must be between 1 and %s characters":                   "body debe tener entre 1 y %s caracteres",
+	"limit must be between 1 and %s":                             "limit debe estar entre 1 y %s",
+	"cursor is invalid":                                          "el cursor no es válido",
+	"clients can only add shared notes":                          "los clientes solo pueden añadir notas compartidas",
+	"url is required":                                            "url es obligatorio",
+	"url must be an absolute http(s) URL":                        "url debe ser una URL http(s) absoluta",
+	"no webhook endpoint registered":                             "no hay ningún endpoint de webhook registrado",
+	"type is required":                                           "type es obligatorio",
+	"unknown webhook event type: type must be one of %s":         "tipo de evento de webhook desconocido: type debe ser uno de %s",
+	"from must be a month such as 2026-01":                       "from debe ser un mes como 2026-01",
+	"to must be a month such as 2026-01":                         "to debe ser un mes como 2026-01",
+	"from must not be after to":                                  "from no debe ser posterior a to",
+	"range must not exceed 24 months":                            "el intervalo no debe superar los 24 meses",
+	"cidrs is required; send an empty list to allow any address": "cidrs es obligatorio; envíe una lista vacía para permitir cualquier dirección",
+	"cidrs must include the address this request comes from: %s": "cidrs debe incluir la dirección de la que procede esta solicitud: %s",
+	"Could not load client settings":                             "No se pudo cargar la configuración del cliente",
+	"Could not save note":                                        "No se pudo guardar la nota",
+	"Could not retrieve notes":                                   "No se pudieron obtener las notas",
+	"Could not save webhook endpoint":                            "No se pudo guardar el endpoint de webhook",
+	"Could not retrieve webhook endpoint":                        "No se pudo obtener el endpoint de webhook",
+	"Could not send test webhook":                                "No se pudo enviar el webhook de prueba",
+	"Could not retrieve usage":                                   "No se pudo obtener el uso",
+	"Could not compute stats":                                    "No se pudieron calcular las estadísticas",
+
+	// Applicant tags and metadata
+	"invalid tags or metadata: tag %s must be up to %s letters, digits, '_', '.', ':' or '-', starting with a letter or digit": "etiquetas o metadatos no válidos: la etiqueta %s debe tener hasta %s letras, dígitos, '_', '.', ':' o '-', y empezar por una letra o un dígito",
+	"invalid tags or metadata: at most %s tags are allowed":                                                                    "etiquetas o metadatos no válidos: se permiten como máximo %s etiquetas",
+	"invalid tags or metadata: at most %s metadata keys are allowed":                                                           "etiquetas o metadatos no válidos: se permiten como máximo %s claves de metadatos",
+	"invalid tags or metadata: metadata key %s must be up to %s letters, digits or '_', starting with a letter":                "etiquetas o metadatos no válidos: la clave de metadatos %s debe tener hasta %s letras, dígitos o '_', y empezar por una letra",
+	"invalid tags or metadata: metadata value of %s must be at most %s bytes":                                                  "etiquetas o metadatos no válidos: el valor de metadatos de %s debe tener como máximo %s bytes",
+	"invalid tags or metadata: metadata must be at most %s bytes in total":                                                     "etiquetas o metadatos no válidos: los metadatos deben tener como máximo %s bytes en total",
+	"invalid tag filter: %s":                                 "filtro de etiqueta no válido: %s",
+	"invalid metadata filter: %s":                            "filtro de metadatos no válido: %s",
+	"annotations were changed by another request, try again": "otra solicitud ha cambiado las etiquetas y los metadatos; inténtelo de nuevo",
+	"Could not update annotations":                           "No se pudieron actualizar las etiquetas y los metadatos",
+
+	// Applicant patches
+	"Content-Type must be application/merge-patch+json or application/json-patch+json": "Content-Type debe ser application/merge-patch+json o application/json-patch+json",
+	"invalid applicant: %s required":                                      "solicitante no válido: %s es obligatorio",
+	"invalid applicant: dob must be a date such as 1990-01-31":            "solicitante no válido: dob debe ser una fecha como 1990-01-31",
+	"invalid applicant: address.Country must be an ISO 3166 country code": "solicitante no válido: address.Country debe ser un código de país ISO 3166",
+	"invalid applicant: level is not enabled for this client":             "solicitante no válido: el nivel no está habilitado para este cliente",
+	"operation %s (%s %s): patch test failed":                             "operación %s (%s %s): la prueba del parche ha fallado",
+	"invalid patch: the applicant must remain an object":                  "parche no válido: el solicitante debe seguir siendo un objeto",
+
+	// Schema validation
+	"write does not match the collection schema: %s": "la escritura no coincide con el esquema de la colección: %s",
+
+	// Compliance reports
+	"from and to are required":                                 "from y to son obligatorios",
+	"from must be a date or an RFC 3339 time":                  "from debe ser una fecha o una hora RFC 3339",
+	"to must be a date or an RFC 3339 time":                    "to debe ser una fecha o una hora RFC 3339",
+	"format must be csv or json":                               "format debe ser csv o json",
+	"invalid report period: from must be before to":            "periodo de informe no válido: from debe ser anterior a to",
+	"invalid report period: reports may cover at most %s days": "periodo de informe no válido: los informes pueden abarcar como máximo %s días",
+
+	// Re-encryption
+	"applicant was changed by another request, try again": "otra solicitud ha cambiado el solicitante; inténtelo de nuevo",
+	"re-encryption is not available":                      "el recifrado no está disponible",
+	"Could not rekey applicant":                           "No se pudo recifrar el solicitante",
+
+	// Client-managed keys
+	"key_arn is required":                                                     "key_arn es obligatorio",
+	"key_arn must be the ARN of a KMS key or alias":                           "key_arn debe ser el ARN de una clave o alias de KMS",
+	"encryption key not found":                                                "clave de cifrado no encontrada",
+	"the key must pass validation before it is activated":                     "la clave debe superar la validación antes de activarse",
+	"the key has already been activated":                                      "la clave ya se ha activado",
+	"the client must be registered by an admin before it can use its own key": "un administrador debe registrar el cliente antes de que pueda usar su propia clave",
+	"client-managed keys are not available":                                   "las claves gestionadas por el cliente no están disponibles",
+	"Could not register encryption key":                                       "No se pudo registrar la clave de cifrado",
+	"Could not list encryption keys":                                          "No se pudieron listar las claves de cifrado",
+	"Could not look up encryption key":                                        "No se pudo buscar la clave de cifrado",
+	"Could not validate encryption key":                                       "No se pudo validar la clave de cifrado",
+	"Could not activate encryption key":                                       "No se pudo activar la clave de cifrado",
+}
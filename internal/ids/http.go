@@ -0,0 +1,48 @@
+package ids
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pathKinds says what kind of ID a path parameter holds from the segment before it, as
+// in /applicants/:id or /documents/:docId
+var pathKinds = map[string]Kind{
+	"applicants":   Applicant,
+	"review-queue": Applicant,
+	"documents":    Document,
+	"clients":      Client,
+	"failures":     Webhook,
+}
+
+// Respond answers a request naming a record by an ID that cannot be one of its kind with
+// 400 and code invalid_id, and reports whether it did
+func Respond(c *gin.Context, kind Kind, id string) bool {
+	err := kind.Check(id)
+	if err == nil {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "invalid_id"})
+	return true
+}
+
+// Middleware checks the applicant, document, client and webhook event IDs in a request's
+// path before its handler looks them up, so an ID of the wrong type is refused with a
+// clear error rather than reported as not found
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		segments := strings.Split(c.FullPath(), "/")
+		for i := 1; i < len(segments); i++ {
+			if !strings.HasPrefix(segments[i], ":") {
+				continue
+			}
+			kind, ok := pathKinds[segments[i-1]]
+			if ok && Respond(c, kind, c.Param(segments[i][1:])) {
+				return
+			}
+		}
+		c.Next()
+	}
+}
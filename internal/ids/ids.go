@@ -0,0 +1,95 @@
+// Package ids mints the IDs of the records the API hands out. They are UUIDv7s: random
+// enough in their low bits that no client can guess another's, and ordered by creation
+// time so new records are written next to each other in indexes instead of all over them.
+//
+// Applicants, documents, clients and webhook events get prefixed IDs, such as
+// app_0192b3c4d5e67f808192a3b4c5d6e7f8, that say what they name. Records created before
+// prefixes keep their plain UUIDs, which are accepted wherever a prefixed ID is.
+package ids
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// New returns a new ID
+func New() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// Kind is a type of record with prefixed IDs
+type Kind struct {
+	Prefix string
+	Name   string // What the ID names, with its article, for error messages
+}
+
+var (
+	Applicant = Kind{Prefix: "app", Name: "an applicant"}
+	Document  = Kind{Prefix: "doc", Name: "a document"}
+	Client    = Kind{Prefix: "cli", Name: "a client"}
+	Webhook   = Kind{Prefix: "whk", Name: "a webhook event"}
+)
+
+var kinds = []Kind{Applicant, Document, Client, Webhook}
+
+var (
+	// ErrMalformed is returned for an ID that is neither a prefixed ID nor a UUID
+	ErrMalformed = errors.New("malformed ID")
+	// ErrWrongKind is returned for a prefixed ID of another type of record
+	ErrWrongKind = errors.New("ID names another type of record")
+)
+
+// New returns a new ID of the kind
+func (k Kind) New() string {
+	return k.Format(uuid.Must(uuid.NewV7()))
+}
+
+// Format returns the ID of the kind for a UUID
+func (k Kind) Format(id uuid.UUID) string {
+	return k.Prefix + "_" + hex.EncodeToString(id[:])
+}
+
+// Check returns nil if id can name a record of the kind: an ID with its prefix, or a
+// plain UUID from before prefixes
+func (k Kind) Check(id string) error {
+	if isUUID(id) {
+		return nil
+	}
+	prefix, rest, found := strings.Cut(id, "_")
+	if !found || !isHex(rest) {
+		return k.malformed()
+	}
+	if prefix == k.Prefix {
+		return nil
+	}
+	for _, other := range kinds {
+		if other.Prefix == prefix {
+			return fmt.Errorf("%w: %s is the ID of %s, not of %s", ErrWrongKind, id, other.Name, k.Name)
+		}
+	}
+	return k.malformed()
+}
+
+func (k Kind) malformed() error {
+	return fmt.Errorf("%w: the ID of %s must be %s_ followed by 32 hex digits, or a UUID", ErrMalformed, k.Name, k.Prefix)
+}
+
+func isUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	_, err := uuid.Parse(id)
+	return err == nil
+}
+
+func isHex(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
@@ -0,0 +1,92 @@
+package ids
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	first, second := New(), New()
+
+	id, err := uuid.Parse(first)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), id.Version())
+	assert.NotEqual(t, first, second)
+	// IDs minted later sort after earlier ones
+	assert.Less(t, first, second)
+}
+
+func TestKindNew(t *testing.T) {
+	id := Applicant.New()
+	assert.Regexp(t, `^app_[0-9a-f]{32}$`, id)
+	assert.NoError(t, Applicant.Check(id))
+	assert.Regexp(t, `^doc_[0-9a-f]{32}$`, Document.New())
+}
+
+func TestKindCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{name: "Prefixed ID of the kind", id: "app_0192b3c4d5e67f808192a3b4c5d6e7f8"},
+		{name: "UUID from before prefixes", id: "0b7c6f3e-5d1a-4f7e-9a63-2c1d8e4b5a90"},
+		{name: "ID of another kind", id: "doc_0192b3c4d5e67f808192a3b4c5d6e7f8", wantErr: ErrWrongKind},
+		{name: "Unknown prefix", id: "usr_0192b3c4d5e67f808192a3b4c5d6e7f8", wantErr: ErrMalformed},
+		{name: "Short", id: "app_0192", wantErr: ErrMalformed},
+		{name: "Upper case", id: "app_0192B3C4D5E67F808192A3B4C5D6E7F8", wantErr: ErrMalformed},
+		{name: "Not an ID", id: "nope", wantErr: ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Applicant.Check(tt.id)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+	assert.EqualError(t, Applicant.Check("doc_0192b3c4d5e67f808192a3b4c5d6e7f8"),
+		"ID names another type of record: doc_0192b3c4d5e67f808192a3b4c5d6e7f8 is the ID of a document, not of an applicant")
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/applicants/:id/documents/:docId", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/documents/jobs/:job_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	applicantID, documentID := Applicant.New(), Document.New()
+	tests := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+	}{
+		{name: "IDs of their kinds", path: "/applicants/" + applicantID + "/documents/" + documentID, expectedStatusCode: http.StatusOK},
+		{name: "UUIDs from before prefixes", path: "/applicants/0b7c6f3e-5d1a-4f7e-9a63-2c1d8e4b5a90/documents/5e0a4c1d-93b2-4a8f-b7d6-1f2e3d4c5b6a", expectedStatusCode: http.StatusOK},
+		{name: "Swapped IDs", path: "/applicants/" + documentID + "/documents/" + applicantID, expectedStatusCode: http.StatusBadRequest},
+		{name: "Unchecked parameter", path: "/documents/jobs/job1", expectedStatusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusBadRequest {
+				assert.Contains(t, w.Body.String(), `"code":"invalid_id"`)
+			}
+		})
+	}
+}
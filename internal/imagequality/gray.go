@@ -0,0 +1,55 @@
+package imagequality
+
+import (
+	"image"
+	"image/color"
+)
+
+// gray is the luminance of a photo, from 0 to 255, row by row
+type gray struct {
+	w, h int
+	pix  []float64
+}
+
+func (g gray) at(x, y int) float64 {
+	return g.pix[y*g.w+x]
+}
+
+// grayscale returns the luminance of img, averaging blocks of pixels so that neither side
+// is longer than maxSide
+func grayscale(img image.Image, maxSide int) gray {
+	bounds := img.Bounds()
+	scale := 1
+	for bounds.Dx()/scale > maxSide || bounds.Dy()/scale > maxSide {
+		scale++
+	}
+	g := gray{w: bounds.Dx() / scale, h: bounds.Dy() / scale}
+	g.pix = make([]float64, g.w*g.h)
+
+	luminance := luminanceOf(img)
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			var sum float64
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					sum += luminance(bounds.Min.X+x*scale+dx, bounds.Min.Y+y*scale+dy)
+				}
+			}
+			g.pix[y*g.w+x] = sum / float64(scale*scale)
+		}
+	}
+	return g
+}
+
+// luminanceOf reads the luminance of img's pixels, straight from the Y plane of JPEGs and
+// the pixels of grayscale images rather than through a conversion from colour
+func luminanceOf(img image.Image) func(x, y int) float64 {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) float64 { return float64(img.Y[img.YOffset(x, y)]) }
+	case *image.Gray:
+		return func(x, y int) float64 { return float64(img.Pix[img.PixOffset(x, y)]) }
+	default:
+		return func(x, y int) float64 { return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y) }
+	}
+}
@@ -0,0 +1,228 @@
+// Package imagequality spots photos of documents no provider could read: too small, out of
+// focus, washed out by glare, or with the document running off the edge of the frame. It
+// uses simple measures of the pixels so uploads can be refused while the applicant is
+// still holding the document, rather than after a round trip to the provider.
+//
+// Sharpness and glare are measured on a grayscale copy no larger than analysisSize along
+// either side, so thresholds hold for photos of any size.
+package imagequality
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Registers JPEG with image.Decode
+	_ "image/png"  // Registers PNG with image.Decode
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+)
+
+const (
+	// DefaultMinShortSide is the shortest side, in pixels, of a photo that is not too small
+	DefaultMinShortSide = 600
+	// DefaultMinSharpness is the lowest variance of the Laplacian of a photo in focus
+	DefaultMinSharpness = 50
+	// DefaultMaxGlare is the largest share of a photo that may be blown out to white
+	DefaultMaxGlare = 0.1
+
+	analysisSize = 1000       // Longest side of the grayscale copy that is measured
+	maxPixels    = 40_000_000 // Largest photo decoded, as for previews
+	clipped      = 250        // Luminance from which a pixel is blown out
+	edgeStep     = 48         // Luminance difference between neighbours that makes an edge
+	maxEdgeShare = 0.25       // Share of a border strip that may be edges before the document is cut off
+	borderShare  = 0.02       // Width of each border strip as a share of the side across it
+	minBorder    = 2          // Narrowest border strip, in pixels
+)
+
+// Codes name what is wrong with a photo, matching the guidance applicants get when a
+// provider asks for a document again
+const (
+	CodeLowResolution   = "low_resolution"
+	CodeBlurryImage     = "blurry_image"
+	CodeGlare           = "glare"
+	CodeCroppedDocument = "cropped_document"
+)
+
+// Checks are the names of the measures in the order they are taken
+const (
+	CheckResolution = "resolution"
+	CheckSharpness  = "sharpness"
+	CheckGlare      = "glare"
+	CheckFraming    = "framing"
+)
+
+// ErrUnreadable is returned for content that is not a PNG or JPEG small enough to decode
+var ErrUnreadable = errors.New("image cannot be assessed")
+
+// Thresholds are the limits a photo must be within
+type Thresholds struct {
+	MinShortSide int
+	MinSharpness float64
+	MaxGlare     float64
+}
+
+// FromSettings returns the thresholds of settings, using the defaults for zero values
+func FromSettings(settings config.ImageQualitySettings) Thresholds {
+	t := Thresholds{
+		MinShortSide: settings.MinShortSide,
+		MinSharpness: settings.MinSharpness,
+		MaxGlare:     settings.MaxGlare,
+	}
+	if t.MinShortSide <= 0 {
+		t.MinShortSide = DefaultMinShortSide
+	}
+	if t.MinSharpness <= 0 {
+		t.MinSharpness = DefaultMinSharpness
+	}
+	if t.MaxGlare <= 0 {
+		t.MaxGlare = DefaultMaxGlare
+	}
+	return t
+}
+
+// Finding is the outcome of one measure. Code is empty when the photo is within the limit.
+type Finding struct {
+	Check  string
+	Code   string
+	Detail string
+}
+
+// Decode reads a PNG or JPEG, refusing photos too large to hold in memory
+func Decode(content []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: image of %dx%d is too large", ErrUnreadable, config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	return img, nil
+}
+
+// Assess measures img against t, returning a finding for each of resolution, sharpness,
+// glare and framing
+func Assess(img image.Image, t Thresholds) []Finding {
+	bounds := img.Bounds()
+	g := grayscale(img, analysisSize)
+	return []Finding{
+		resolution(bounds.Dx(), bounds.Dy(), t.MinShortSide),
+		sharpness(g, t.MinSharpness),
+		glare(g, t.MaxGlare),
+		framing(g),
+	}
+}
+
+// resolution checks the shorter side of the photo is long enough for its text to be read
+func resolution(width, height, minShortSide int) Finding {
+	finding := Finding{Check: CheckResolution}
+	if min(width, height) < minShortSide {
+		finding.Code = CodeLowResolution
+		finding.Detail = fmt.Sprintf("image is %dx%d but its shorter side must be at least %d pixels", width, height, minShortSide)
+	}
+	return finding
+}
+
+// sharpness checks the photo is in focus by the variance of its Laplacian, which is
+// high where edges are crisp and falls as they blur
+func sharpness(g gray, minSharpness float64) Finding {
+	finding := Finding{Check: CheckSharpness}
+	if g.w < 3 || g.h < 3 {
+		return finding
+	}
+	var sum, sumSquares float64
+	n := 0
+	for y := 1; y < g.h-1; y++ {
+		for x := 1; x < g.w-1; x++ {
+			l := g.at(x-1, y) + g.at(x+1, y) + g.at(x, y-1) + g.at(x, y+1) - 4*g.at(x, y)
+			sum += l
+			sumSquares += l * l
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	variance := sumSquares/float64(n) - mean*mean
+	if variance < minSharpness {
+		finding.Code = CodeBlurryImage
+		finding.Detail = fmt.Sprintf("image is too blurry to read, with a sharpness of %.0f where at least %.0f is needed", variance, minSharpness)
+	}
+	return finding
+}
+
+// glare checks how much of the photo is blown out to white, as light reflected off a
+// laminated card or a screen is
+func glare(g gray, maxGlare float64) Finding {
+	finding := Finding{Check: CheckGlare}
+	if len(g.pix) == 0 {
+		return finding
+	}
+	blown := 0
+	for _, v := range g.pix {
+		if v >= clipped {
+			blown++
+		}
+	}
+	share := float64(blown) / float64(len(g.pix))
+	if share > maxGlare {
+		finding.Code = CodeGlare
+		finding.Detail = fmt.Sprintf("glare covers %.0f%% of the image", share*100)
+	}
+	return finding
+}
+
+// framing checks the whole document is in the photo. A document photographed whole is
+// surrounded by background, so the strips along the photo's edges are mostly plain; where
+// the document runs off an edge, its text and borders cross that strip.
+func framing(g gray) Finding {
+	finding := Finding{Check: CheckFraming}
+	across := max(minBorder, int(float64(g.h)*borderShare))
+	down := max(minBorder, int(float64(g.w)*borderShare))
+	if g.w <= 2*down || g.h <= 2*across {
+		return finding
+	}
+
+	strips := [][4]int{
+		{0, 0, g.w, across},         // Top
+		{0, g.h - across, g.w, g.h}, // Bottom
+		{0, 0, down, g.h},           // Left
+		{g.w - down, 0, g.w, g.h},   // Right
+	}
+	for _, strip := range strips {
+		if edgeShare(g, strip[0], strip[1], strip[2], strip[3]) > maxEdgeShare {
+			finding.Code = CodeCroppedDocument
+			finding.Detail = "document runs off the edge of the image"
+			break
+		}
+	}
+	return finding
+}
+
+// edgeShare is the share of pixels in the rectangle from x0,y0 to x1,y1 that differ
+// sharply from their right or lower neighbour
+func edgeShare(g gray, x0, y0, x1, y1 int) float64 {
+	edges, n := 0, 0
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			v := g.at(x, y)
+			if (x+1 < g.w && abs(g.at(x+1, y)-v) >= edgeStep) || (y+1 < g.h && abs(g.at(x, y+1)-v) >= edgeStep) {
+				edges++
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(edges) / float64(n)
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
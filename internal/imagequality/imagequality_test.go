@@ -0,0 +1,139 @@
+package imagequality
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// photo draws a card on a plain background, with a checkerboard of 4 pixel squares
+// standing in for its text
+func photo(width, height int, card image.Rectangle) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	text := card.Inset(30)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := image.Pt(x, y)
+			v := uint8(90)
+			switch {
+			case p.In(text):
+				if (x/4+y/4)%2 == 0 {
+					v = 20
+				} else {
+					v = 230
+				}
+			case p.In(card):
+				v = 200
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+// blur averages each pixel of img with those within radius of it
+func blur(img *image.Gray, radius int) *image.Gray {
+	bounds := img.Bounds()
+	out := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			sum, n := 0, 0
+			for dy := -radius; dy <= radius; dy += 2 {
+				for dx := -radius; dx <= radius; dx += 2 {
+					if p := image.Pt(x+dx, y+dy); p.In(bounds) {
+						sum += int(img.GrayAt(p.X, p.Y).Y)
+						n++
+					}
+				}
+			}
+			out.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+		}
+	}
+	return out
+}
+
+// codes maps the name of each measure to the code it failed with
+func codes(findings []Finding) map[string]string {
+	out := map[string]string{}
+	for _, f := range findings {
+		out[f.Check] = f.Code
+	}
+	return out
+}
+
+var (
+	thresholds = FromSettings(config.ImageQualitySettings{})
+	card       = image.Rect(200, 100, 1000, 700)
+)
+
+func TestAssessPassesAClearPhoto(t *testing.T) {
+	findings := Assess(photo(1200, 800, card), thresholds)
+	assert.Equal(t, map[string]string{CheckResolution: "", CheckSharpness: "", CheckGlare: "", CheckFraming: ""}, codes(findings))
+}
+
+func TestAssessRefusesUnusablePhotos(t *testing.T) {
+	tests := []struct {
+		name  string
+		img   image.Image
+		check string
+		code  string
+	}{
+		{"small", photo(560, 400, image.Rect(100, 50, 460, 350)), CheckResolution, CodeLowResolution},
+		{"blurry", blur(photo(1200, 800, card), 12), CheckSharpness, CodeBlurryImage},
+		{"cropped", photo(1200, 800, image.Rect(-200, 100, 1000, 700)), CheckFraming, CodeCroppedDocument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := codes(Assess(tt.img, thresholds))
+			assert.Equal(t, tt.code, found[tt.check])
+			for check, code := range found {
+				if check != tt.check {
+					assert.Empty(t, code, check)
+				}
+			}
+		})
+	}
+
+	glared := photo(1200, 800, card)
+	for y := 150; y < 500; y++ {
+		for x := 250; x < 800; x++ {
+			glared.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	findings := Assess(glared, thresholds)
+	assert.Equal(t, CodeGlare, codes(findings)[CheckGlare])
+	assert.Equal(t, "glare covers 20% of the image", findings[2].Detail)
+}
+
+func TestDecode(t *testing.T) {
+	colour := image.NewRGBA(image.Rect(0, 0, 1200, 800))
+	draw.Draw(colour, colour.Bounds(), photo(1200, 800, card), image.Point{}, draw.Src)
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, colour, &jpeg.Options{Quality: 90}))
+	img, err := Decode(encoded.Bytes())
+	require.NoError(t, err)
+	// JPEGs are measured from their Y plane
+	assert.IsType(t, &image.YCbCr{}, img)
+	assert.Equal(t, map[string]string{CheckResolution: "", CheckSharpness: "", CheckGlare: "", CheckFraming: ""}, codes(Assess(img, thresholds)))
+
+	encoded.Reset()
+	require.NoError(t, png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8000, 6000))))
+	_, err = Decode(encoded.Bytes())
+	assert.ErrorIs(t, err, ErrUnreadable)
+	_, err = Decode([]byte("%PDF-1.7"))
+	assert.ErrorIs(t, err, ErrUnreadable)
+}
+
+func TestFromSettings(t *testing.T) {
+	assert.Equal(t, Thresholds{MinShortSide: 600, MinSharpness: 50, MaxGlare: 0.1}, thresholds)
+	assert.Equal(t, Thresholds{MinShortSide: 1000, MinSharpness: 50, MaxGlare: 0.3},
+		FromSettings(config.ImageQualitySettings{MinShortSide: 1000, MaxGlare: 0.3}))
+}
@@ -0,0 +1,78 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// container is a dependency started with the docker CLI for the length of the suite
+type container struct {
+	id   string
+	addr string // host:port the container's service port is published on
+}
+
+// startContainer runs an image detached with the given service port published on a
+// random local port. The container is removed when it stops.
+func startContainer(ctx context.Context, image, port string, env []string, args ...string) (*container, error) {
+	run := []string{"run", "-d", "--rm", "-p", "127.0.0.1::" + port}
+	for _, e := range env {
+		run = append(run, "-e", e)
+	}
+	run = append(run, image)
+	run = append(run, args...)
+
+	id, err := docker(ctx, run...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", image, err)
+	}
+	c := &container{id: id}
+	addr, err := docker(ctx, "port", id, port+"/tcp")
+	if err != nil {
+		c.stop()
+		return nil, fmt.Errorf("failed to find the published port of %s: %w", image, err)
+	}
+	// One line per address family; the IPv4 binding is first
+	c.addr = strings.Fields(addr)[0]
+	return c, nil
+}
+
+// stop removes the container, ignoring failures since the suite is ending anyway
+func (c *container) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	docker(ctx, "stop", c.id)
+}
+
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// waitFor retries check until it succeeds or the timeout passes
+func waitFor(ctx context.Context, what string, timeout time.Duration, check func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s was not ready after %s: %w", what, timeout, err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,199 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// call sends a request to the running API and decodes a JSON response into out
+func call(t *testing.T, method, path string, headers map[string]string, body io.Reader, contentType string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, body)
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if out != nil && len(raw) > 0 {
+		require.NoError(t, json.Unmarshal(raw, out), string(raw))
+	}
+	return resp.StatusCode
+}
+
+func callJSON(t *testing.T, method, path string, headers map[string]string, body interface{}, out interface{}) int {
+	t.Helper()
+	encoded, err := json.Marshal(body)
+	require.NoError(t, err)
+	return call(t, method, path, headers, bytes.NewReader(encoded), "application/json", out)
+}
+
+// registerClient onboards a client through the admin API and returns its API key and ID
+func registerClient(t *testing.T) (string, string) {
+	t.Helper()
+	var registration struct {
+		Client struct {
+			ClientID string `json:"client_id"`
+		} `json:"client"`
+		APIKey string `json:"api_key"`
+	}
+	status := callJSON(t, http.MethodPost, "/api/v1/admin/clients", map[string]string{"X-Admin-Key": adminKey}, map[string]interface{}{
+		"name":                        "Integration Client",
+		"contact_email":               "ops@integration.test",
+		"allowed_verification_levels": []string{"basic"},
+	}, &registration)
+	require.Equal(t, http.StatusCreated, status)
+	require.NotEmpty(t, registration.APIKey)
+	return registration.APIKey, registration.Client.ClientID
+}
+
+// documentImage is a small PNG with enough detail to pass the upload checks
+func documentImage(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 640, 400))
+	for x := 0; x < 640; x++ {
+		for y := 0; y < 400; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// createApplicant creates an applicant for the client with the key and returns its ID.
+// Personal data is encrypted with a data key from the fake KMS.
+func createApplicant(t *testing.T, key map[string]string) string {
+	t.Helper()
+	var created struct {
+		ApplicantID string `json:"applicant_id"`
+	}
+	status := callJSON(t, http.MethodPost, "/api/v2/applicants", key, map[string]interface{}{
+		"first_name":  "Ada",
+		"middle_name": "King",
+		"last_name":   "Lovelace",
+		"email":       "ada@integration.test",
+		"phone":       "+441234567890",
+		"dob":         "1815-12-10",
+		"level":       "basic",
+		"address": map[string]string{
+			"line1":       "12 St James's Square",
+			"city":        "London",
+			"postal_code": "SW1Y 4JH",
+			"country":     "GBR",
+		},
+	}, &created)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, created.ApplicantID)
+	return created.ApplicantID
+}
+
+// uploadedDocument is the part of an upload response the tests use
+type uploadedDocument struct {
+	DocumentID       string `json:"document_id"`
+	JobID            string `json:"job_id"`
+	ProcessingStatus string `json:"processing_status"`
+}
+
+// passportForm is the upload form of the file as a passport, and its content type
+func passportForm(t *testing.T, file []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	require.NoError(t, writer.WriteField("document_type", models.DocumentPassport.String()))
+	require.NoError(t, writer.WriteField("country", "GBR"))
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="document"; filename="passport.png"`)
+	header.Set("Content-Type", "image/png")
+	part, err := writer.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write(file)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return &form, writer.FormDataContentType()
+}
+
+// uploadDocument uploads the file as the applicant's passport. The file goes to MinIO.
+func uploadDocument(t *testing.T, key map[string]string, applicantID string, file []byte) uploadedDocument {
+	t.Helper()
+	form, contentType := passportForm(t, file)
+	var uploaded uploadedDocument
+	status := call(t, http.MethodPost, "/api/v2/applicants/"+applicantID+"/documents", key, form, contentType, &uploaded)
+	require.Equal(t, http.StatusOK, status, "processing status %s", uploaded.ProcessingStatus)
+	require.NotEmpty(t, uploaded.DocumentID)
+	return uploaded
+}
+
+func TestApplicantDocumentLifecycle(t *testing.T) {
+	apiKey, _ := registerClient(t)
+	key := map[string]string{"X-API-Key": apiKey}
+	applicantID := createApplicant(t, key)
+
+	var applicant map[string]interface{}
+	status := call(t, http.MethodGet, "/api/v2/applicants/"+applicantID, key, nil, "", &applicant)
+	assert.Equal(t, http.StatusOK, status)
+
+	file := documentImage(t)
+	uploaded := uploadDocument(t, key, applicantID, file)
+	documentPath := "/api/v2/applicants/" + applicantID + "/documents/" + uploaded.DocumentID
+
+	// Update the document's status
+	var updated struct {
+		Status models.DocumentStatus `json:"status"`
+	}
+	status = callJSON(t, http.MethodPut, documentPath, key, map[string]string{"status": models.DocumentVerified.String()}, &updated)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, models.DocumentVerified, updated.Status)
+
+	var fetched struct {
+		DocumentID string                `json:"document_id"`
+		Status     models.DocumentStatus `json:"status"`
+	}
+	status = call(t, http.MethodGet, documentPath, key, nil, "", &fetched)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, models.DocumentVerified, fetched.Status)
+
+	// Download the file back out of MinIO
+	var saved struct {
+		FilePath string `json:"file_path"`
+	}
+	status = callJSON(t, http.MethodPost, "/api/v1/protected/downloads/"+uploaded.DocumentID, key, map[string]string{"applicant_id": applicantID}, &saved)
+	require.Equal(t, http.StatusOK, status)
+	defer os.Remove(saved.FilePath)
+	downloaded, err := os.ReadFile(saved.FilePath)
+	require.NoError(t, err)
+	assert.Equal(t, file, downloaded)
+}
+
+func TestRequestsWithoutCredentialsAreRefused(t *testing.T) {
+	status := call(t, http.MethodGet, "/api/v2/applicants", nil, nil, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status = call(t, http.MethodPost, "/api/v2/applicants", map[string]string{"X-API-Key": "vk_unknown"}, strings.NewReader("{}"), "application/json", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status = call(t, http.MethodGet, "/api/v1/admin/metrics", map[string]string{"X-Admin-Key": "ak_unknown"}, nil, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
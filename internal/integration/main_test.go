@@ -0,0 +1,150 @@
+//go:build integration
+
+// Package integration boots the real router against real dependencies and drives it
+// over HTTP, catching wiring mistakes that unit tests with mocked services miss.
+//
+// The suite needs docker and is excluded from ordinary builds:
+//
+//	go test -tags integration ./internal/integration/...
+//
+// MongoDB and MinIO are started with the docker CLI, unless INTEGRATION_MONGO_URI or
+// INTEGRATION_S3_ENDPOINT point at instances that are already running, e.g. CI service
+// containers. KMS is faked in process, since the KMS client cannot be pointed at a local
+// endpoint. The service keeps nonces and revocations in MongoDB while sessions.redisURL is
+// unset, so no Redis is needed.
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/app/controller"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoindex"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	startTimeout  = 90 * time.Second
+	databaseName  = "verus_integration"
+	bucketName    = "verus-integration"
+	minioUser     = "integration"
+	minioPassword = "integration-secret"
+	adminKey      = "ak_integration"
+)
+
+// server is the running API, shared by the tests in the suite
+var server *httptest.Server
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	ctx := context.Background()
+	var containers []*container
+	defer func() {
+		for _, c := range containers {
+			c.stop()
+		}
+	}()
+
+	mongoURI := os.Getenv("INTEGRATION_MONGO_URI")
+	if mongoURI == "" {
+		mongo, err := startContainer(ctx, "mongo:7", "27017", nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		containers = append(containers, mongo)
+		mongoURI = "mongodb://" + mongo.addr
+	}
+	s3Endpoint := os.Getenv("INTEGRATION_S3_ENDPOINT")
+	if s3Endpoint == "" {
+		minio, err := startContainer(ctx, "minio/minio", "9000",
+			[]string{"MINIO_ROOT_USER=" + minioUser, "MINIO_ROOT_PASSWORD=" + minioPassword}, "server", "/data")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		containers = append(containers, minio)
+		s3Endpoint = minio.addr
+	}
+
+	handler, err := boot(ctx, mongoURI, s3Endpoint)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	server = httptest.NewServer(handler)
+	defer server.Close()
+	return m.Run()
+}
+
+// boot connects to the dependencies, seeds an admin and builds the router as cmd/dev does
+func boot(ctx context.Context, mongoURI, s3Endpoint string) (*gin.Engine, error) {
+	cfg := models.Config{
+		Database: models.DatabaseConfig{UseAtlas: true, AtlasConnectionURI: mongoURI, Name: databaseName},
+		AWS:      models.AWSConfig{Region: "us-east-1", BucketName: bucketName},
+	}
+	err := waitFor(ctx, "MongoDB", startTimeout, func(ctx context.Context) error {
+		return common.ConnectDatabase(cfg.Database)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Start from an empty database when reusing an instance
+	if err := common.GetCollection(localConstants.CollectionAdminUsers).Database().Drop(ctx); err != nil {
+		return nil, err
+	}
+	if err := mongoindex.Ensure(ctx); err != nil {
+		return nil, err
+	}
+	if err := seedAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	uploader, err := newMinioUploader(ctx, s3Endpoint, minioUser, minioPassword, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	kms, err := newFakeKMS()
+	if err != nil {
+		return nil, err
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	// Background workers stay off so each test sees only the effects of its own requests
+	settings := config.Settings{}
+	settings.Tokens.SigningSecret = "integration-token-secret"
+	controller.New(controller.Params{
+		Router:       r,
+		Config:       &cfg,
+		Settings:     &settings,
+		Dependencies: controller.Dependencies{Uploader: uploader, KMSUploader: kms},
+	}).InitializeRoutes()
+	return r, nil
+}
+
+// seedAdmin creates the staff member whose key the tests use for admin routes
+func seedAdmin(ctx context.Context) error {
+	_, err := common.GetCollection(localConstants.CollectionAdminUsers).InsertOne(ctx, bson.M{
+		"admin_id":   "integration-admin",
+		"role":       "admin",
+		"key_hash":   utils.HashAPIKey(adminKey),
+		"revoked":    false,
+		"deleted_at": nil,
+	})
+	return err
+}
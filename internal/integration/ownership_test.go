@@ -0,0 +1,115 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// heldIDs are the IDs of one client's records, or IDs no client holds
+type heldIDs struct {
+	applicant, document, job, event string
+}
+
+// ownedRoute is a route that looks up a client's record by ID
+type ownedRoute struct {
+	method, path string
+	body         func(t *testing.T) (io.Reader, string)
+}
+
+func jsonBody(body string) func(t *testing.T) (io.Reader, string) {
+	return func(t *testing.T) (io.Reader, string) {
+		return strings.NewReader(body), "application/json"
+	}
+}
+
+func (r ownedRoute) url(held heldIDs) string {
+	return strings.NewReplacer(
+		"{applicant}", held.applicant,
+		"{document}", held.document,
+		"{job}", held.job,
+		"{event}", held.event,
+	).Replace("/api/v2" + r.path)
+}
+
+// TestOtherClientsRecordsAreNotFound checks that a client asking for another client's
+// applicant, document, upload job or webhook failure gets exactly the answer it gets for
+// an ID nobody holds, so it cannot tell which IDs are taken
+func TestOtherClientsRecordsAreNotFound(t *testing.T) {
+	ownerKey, ownerID := registerClient(t)
+	otherKey, _ := registerClient(t)
+	owner := map[string]string{"X-API-Key": ownerKey}
+	other := map[string]string{"X-API-Key": otherKey}
+
+	applicantID := createApplicant(t, owner)
+	file := documentImage(t)
+	uploaded := uploadDocument(t, owner, applicantID, file)
+	require.NotEmpty(t, uploaded.JobID)
+
+	// Failed deliveries are only recorded by the webhook workers, which are off in the suite
+	eventID := ids.Webhook.New()
+	_, err := common.GetCollection(localConstants.CollectionWebhookDeadLetters).InsertOne(context.Background(), localModels.WebhookDeadLetter{
+		EventID:   eventID,
+		ClientID:  ownerID,
+		Type:      "document.upload_failed",
+		Attempts:  5,
+		CreatedAt: time.Now().UTC(),
+		FailedAt:  time.Now().UTC(),
+	})
+	require.NoError(t, err)
+
+	owned := heldIDs{applicant: applicantID, document: uploaded.DocumentID, job: uploaded.JobID, event: eventID}
+	unused := heldIDs{applicant: ids.Applicant.New(), document: ids.Document.New(), job: ids.New(), event: ids.Webhook.New()}
+
+	routes := []ownedRoute{
+		{method: http.MethodGet, path: "/applicants/{applicant}"},
+		{method: http.MethodPut, path: "/applicants/{applicant}", body: jsonBody(`{"first_name":"Grace"}`)},
+		{method: http.MethodPatch, path: "/applicants/{applicant}", body: jsonBody(`{"first_name":"Grace"}`)},
+		{method: http.MethodPatch, path: "/applicants/{applicant}/annotations", body: jsonBody(`{"tags":["vip"]}`)},
+		{method: http.MethodPost, path: "/applicants/{applicant}/restore"},
+		{method: http.MethodPost, path: "/applicants/{applicant}/documents", body: func(t *testing.T) (io.Reader, string) {
+			return passportForm(t, file)
+		}},
+		{method: http.MethodGet, path: "/applicants/{applicant}/documents/{document}"},
+		{method: http.MethodPut, path: "/applicants/{applicant}/documents/{document}", body: jsonBody(`{"status":"verified"}`)},
+		{method: http.MethodGet, path: "/applicants/{applicant}/documents/{document}/versions"},
+		{method: http.MethodGet, path: "/applicants/{applicant}/documents/{document}/restore"},
+		{method: http.MethodPost, path: "/applicants/{applicant}/documents/{document}/undelete"},
+		{method: http.MethodGet, path: "/applicants/{applicant}/documents/{document}/preview?reason=verification"},
+		{method: http.MethodGet, path: "/documents/jobs/{job}"},
+		{method: http.MethodPost, path: "/webhooks/failures/{event}/redeliver"},
+	}
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			send := func(held heldIDs) (int, json.RawMessage) {
+				var body io.Reader
+				var contentType string
+				if route.body != nil {
+					body, contentType = route.body(t)
+				}
+				var raw json.RawMessage
+				status := call(t, route.method, route.url(held), other, body, contentType, &raw)
+				return status, raw
+			}
+
+			status, foreign := send(owned)
+			unusedStatus, missing := send(unused)
+			assert.Equal(t, http.StatusNotFound, status, string(foreign))
+			assert.Equal(t, unusedStatus, status)
+			assert.Equal(t, string(missing), string(foreign), "another client's record is answered byte for byte like a missing one")
+		})
+	}
+}
@@ -0,0 +1,129 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var adminHeaders = map[string]string{"X-Admin-Key": adminKey}
+
+// issueTokens sends a grant to the token endpoint and returns the status and the pair
+func issueTokens(t *testing.T, grant map[string]string) (int, localModels.TokenPair) {
+	t.Helper()
+	var pair localModels.TokenPair
+	status := callJSON(t, http.MethodPost, "/api/v2/auth/token", nil, grant, &pair)
+	return status, pair
+}
+
+func refresh(t *testing.T, refreshToken string) (int, localModels.TokenPair) {
+	t.Helper()
+	return issueTokens(t, map[string]string{"grant_type": localModels.GrantRefreshToken, "refresh_token": refreshToken})
+}
+
+// storedRefreshToken looks up what the service keeps of a refresh token
+func storedRefreshToken(t *testing.T, refreshToken string) localModels.RefreshToken {
+	t.Helper()
+	sum := sha256.Sum256([]byte(refreshToken))
+	var stored localModels.RefreshToken
+	err := common.GetCollection(localConstants.CollectionRefreshTokens).FindOne(context.Background(), bson.M{"token_hash": hex.EncodeToString(sum[:])}).Decode(&stored)
+	require.NoError(t, err)
+	return stored
+}
+
+// apiKeyID returns the ID of the API key a client was registered with
+func apiKeyID(t *testing.T, clientID string) string {
+	t.Helper()
+	var secret localModels.ClientSecret
+	err := common.GetCollection(localConstants.CollectionClientSecrets).FindOne(context.Background(), bson.M{"client_id": clientID}).Decode(&secret)
+	require.NoError(t, err)
+	return secret.KeyID
+}
+
+// createDashboardUser gives a client a dashboard user and returns the user's ID and username
+func createDashboardUser(t *testing.T, clientID, password string) (string, string) {
+	t.Helper()
+	username := "staff-" + time.Now().Format("150405.000000")
+	var user localModels.DashboardUser
+	status := callJSON(t, http.MethodPost, "/api/v1/admin/clients/"+clientID+"/dashboard-users", adminHeaders,
+		map[string]string{"username": username, "password": password}, &user)
+	require.Equal(t, http.StatusCreated, status)
+	return user.UserID, username
+}
+
+func TestRefreshKeepsTheSignInsExpiry(t *testing.T) {
+	apiKey, _ := registerClient(t)
+	status, pair := issueTokens(t, map[string]string{"grant_type": localModels.GrantAPIKey, "api_key": apiKey})
+	require.Equal(t, http.StatusOK, status)
+	signedIn := storedRefreshToken(t, pair.RefreshToken)
+
+	time.Sleep(10 * time.Millisecond)
+	status, refreshed := refresh(t, pair.RefreshToken)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, signedIn.ExpiresAt.Equal(storedRefreshToken(t, refreshed.RefreshToken).ExpiresAt), "refreshing does not extend the sign-in")
+}
+
+func TestRefreshStopsOnceTheAPIKeyIsRevoked(t *testing.T) {
+	ctx := context.Background()
+
+	// Revoked outside the service, e.g. by another tool, the key is checked on refresh
+	apiKey, clientID := registerClient(t)
+	status, pair := issueTokens(t, map[string]string{"grant_type": localModels.GrantAPIKey, "api_key": apiKey})
+	require.Equal(t, http.StatusOK, status)
+	_, err := common.GetCollection(localConstants.CollectionClientSecrets).UpdateOne(ctx, bson.M{"client_id": clientID}, bson.M{"$set": bson.M{"revoked": true}})
+	require.NoError(t, err)
+	status, _ = refresh(t, pair.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Revoked through the admin API, the key's sign-ins are revoked with it
+	apiKey, clientID = registerClient(t)
+	status, pair = issueTokens(t, map[string]string{"grant_type": localModels.GrantAPIKey, "api_key": apiKey})
+	require.Equal(t, http.StatusOK, status)
+	status = call(t, http.MethodDelete, "/api/v1/admin/clients/"+clientID+"/api-keys/"+apiKeyID(t, clientID), adminHeaders, nil, "", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotNil(t, storedRefreshToken(t, pair.RefreshToken).RevokedAt)
+	status, _ = refresh(t, pair.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = issueTokens(t, map[string]string{"grant_type": localModels.GrantAPIKey, "api_key": apiKey})
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestRefreshStopsOnceTheDashboardUserIsDisabled(t *testing.T) {
+	ctx := context.Background()
+	const password = "correct horse battery"
+	signIn := func(username string) localModels.TokenPair {
+		status, pair := issueTokens(t, map[string]string{"grant_type": localModels.GrantPassword, "username": username, "password": password})
+		require.Equal(t, http.StatusOK, status)
+		return pair
+	}
+	_, clientID := registerClient(t)
+
+	// Disabled outside the service, the user is checked on refresh
+	userID, username := createDashboardUser(t, clientID, password)
+	pair := signIn(username)
+	_, err := common.GetCollection(localConstants.CollectionDashboardUsers).UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{"$set": bson.M{"disabled": true}})
+	require.NoError(t, err)
+	status, _ := refresh(t, pair.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Disabled through the admin API, the user's sign-ins are revoked too
+	userID, username = createDashboardUser(t, clientID, password)
+	pair = signIn(username)
+	status = call(t, http.MethodPost, "/api/v1/admin/clients/"+clientID+"/dashboard-users/"+userID+"/disable", adminHeaders, nil, "", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotNil(t, storedRefreshToken(t, pair.RefreshToken).RevokedAt)
+	status, _ = refresh(t, pair.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
@@ -3,19 +3,24 @@ package interfaces
 import (
 	"context"
 
+	"io"
 	"mime/multipart"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
 	"github.com/rachel-lawrie/verus_backend_core/common"
 	models "github.com/rachel-lawrie/verus_backend_core/models"
 )
 
 // DocumentService defines the methods available for document operations
 type DocumentService interface {
-	// UploadDocument handles the upload of a document and returns metadata
-	UploadDocument(c *gin.Context, collection common.CollectionInterface) (models.Document, error)
+	// UploadDocument handles the upload of a document and returns metadata with the upload check results
+	UploadDocument(c *gin.Context, collection common.CollectionInterface) (localModels.UploadResult, error)
 
 	// GetDocumentByID retrieves a document by its ID
 	GetDocument(c *gin.Context, applicantID string, docID string, collection common.CollectionInterface) (models.Document, error)
@@ -24,21 +29,419 @@ type DocumentService interface {
 	UpdateDocument(c *gin.Context, applicantID string, docID string, status models.DocumentStatus) (models.Document, error)
 
 	DownloadDocument(c *gin.Context, docID string, applicantID string, collection common.CollectionInterface) (string, error)
+
+	// ReplaceDocument uploads a new version of a client's document and re-runs the upload checks
+	ReplaceDocument(c *gin.Context, clientID, docID string, collection common.CollectionInterface) (localModels.UploadResult, error)
+
+	// GetDocumentVersions lists the versions a client's document was replaced from
+	GetDocumentVersions(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.DocumentHistory, error)
+
+	// OpenDocumentVersion returns the file of any version of a document, watermarked for the download
+	// when its client asks for it; the caller must close it
+	OpenDocumentVersion(c *gin.Context, applicantID, docID string, version int, download localModels.DocumentDownload, collection common.CollectionInterface) (localModels.DocumentVersion, io.ReadCloser, error)
+
+	// PreviewDocument returns a downscaled JPEG of a client's document, or only its ETag when the caller has it
+	PreviewDocument(c *gin.Context, clientID, applicantID, docID string, request localModels.PreviewRequest, collection common.CollectionInterface) (localModels.DocumentPreview, error)
+
+	// PresignUpload returns a presigned URL to upload a file for a client's applicant straight to S3
+	PresignUpload(c *gin.Context, clientID string, request localModels.DirectUploadRequest) (localModels.DirectUpload, error)
+
+	// CompleteUpload checks a file uploaded through a presigned URL and registers its document
+	CompleteUpload(c *gin.Context, clientID, applicantID string, completion localModels.DirectUploadCompletion, collection common.CollectionInterface) (localModels.UploadResult, error)
+
+	// RestoreDocument starts restoring a client's archived document so its file can be downloaded again
+	RestoreDocument(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.ColdStorage, error)
+
+	// GetDocumentRestore returns the archival state of a client's archived document
+	GetDocumentRestore(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.ColdStorage, error)
+
+	// UndeleteDocument un-deletes a client's document deleted within the grace period and returns it
+	UndeleteDocument(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (models.Document, error)
+
+	// ListArchiveDocuments returns the documents of a client's applicant, oldest first
+	ListArchiveDocuments(c *gin.Context, clientID, applicantID string, collection common.CollectionInterface) ([]localModels.DocumentRecord, error)
+
+	// WriteDocumentArchive writes the files of documents to w as a ZIP with a manifest, and returns the manifest
+	WriteDocumentArchive(ctx context.Context, documents []localModels.DocumentRecord, download localModels.DocumentDownload, w io.Writer) ([]localModels.DocumentArchiveEntry, error)
+
+	// GetUploadJob returns the progress of one of the client's uploads
+	GetUploadJob(ctx context.Context, clientID, jobID string) (localModels.UploadJob, error)
+
+	// CreateApplicantFromDocument creates a provisional applicant from the details read from
+	// an uploaded identity document, and saves the document as its first
+	CreateApplicantFromDocument(c *gin.Context, level string, consent *localModels.Consent, collection common.CollectionInterface) (localModels.DocumentIntake, error)
+
+	// ConfirmApplicant confirms, and corrects, the details of an applicant created from a document
+	ConfirmApplicant(c *gin.Context, clientID, applicantID string, confirmation localModels.ApplicantConfirmation) (localModels.ApplicantRecord, error)
+}
+
+// UploadJobStore keeps the progress of uploads outside process memory, so any replica can report it
+type UploadJobStore interface {
+	// Save records an upload's progress, replacing what was recorded before
+	Save(ctx context.Context, job localModels.UploadJob) error
+
+	// Get returns the progress of one of the client's uploads
+	Get(ctx context.Context, clientID, jobID string) (localModels.UploadJob, error)
 }
 
 // ApplicantService defines the methods available for applicant operations
 type ApplicantService interface {
 	// UploadApplicant handles the upload of a applicant and returns metadata
-	CreateApplicant(c *gin.Context, applicant *models.Applicant) (models.Applicant, error)
+	CreateApplicant(c *gin.Context, applicant *localModels.ApplicantRecord, addressCountry string) (localModels.ApplicantRecord, error)
 
-	// GetAllApplicants retrieves all applicants
-	GetAllApplicants(c *gin.Context) ([]models.Applicant, error)
+	// GetAllApplicants retrieves the applicants with all of a filter's tags and metadata values
+	GetAllApplicants(c *gin.Context, filter localModels.ApplicantFilter) ([]localModels.ApplicantRecord, error)
 
 	// GetApplicantByID retrieves a applicant by its ID
-	GetApplicant(c *gin.Context, applicantID string) (models.Applicant, error)
+	GetApplicant(c *gin.Context, applicantID string) (localModels.ApplicantRecord, error)
 
 	// UpdateApplicant updates a applicant by its ID with new data
-	UpdateApplicant(c *gin.Context, applicantID string, updates map[string]interface{}) (models.Applicant, error)
+	UpdateApplicant(c *gin.Context, applicantID string, updates map[string]interface{}) (localModels.ApplicantRecord, error)
+
+	// PatchApplicant applies a JSON Merge Patch or JSON Patch to an applicant's editable fields
+	PatchApplicant(c *gin.Context, applicantID string, patch localModels.ApplicantPatch) (localModels.ApplicantRecord, error)
+
+	// SelectApplicants retrieves the applicants matching a filter with only the selected fields
+	SelectApplicants(c *gin.Context, filter localModels.ApplicantFilter, selection localModels.ApplicantSelection) ([]map[string]interface{}, error)
+
+	// SelectApplicant retrieves an applicant by its ID with only the selected fields
+	SelectApplicant(c *gin.Context, applicantID string, selection localModels.ApplicantSelection) (map[string]interface{}, error)
+
+	// UpdateAnnotations applies a patch to an applicant's tags and metadata and returns what it now has
+	UpdateAnnotations(c *gin.Context, applicantID string, patch localModels.AnnotationsPatch) (localModels.Annotations, error)
+
+	// RestoreApplicant un-deletes an applicant deleted within the grace period and returns it
+	RestoreApplicant(c *gin.Context, applicantID string) (localModels.ApplicantRecord, error)
+
+	// ListDeletedApplicants lists the scope's deleted applicants, most recently deleted first
+	ListDeletedApplicants(ctx context.Context, scope tenant.Filter, page pagination.Page) ([]localModels.DeletedApplicant, error)
+}
+
+// AnnotationReader reads the tags and metadata of applicants, to echo in their webhook events
+type AnnotationReader interface {
+	// Annotations returns the tags and metadata of a client's applicant, or nil when it has none
+	Annotations(ctx context.Context, clientID, applicantID string) (*localModels.Annotations, error)
+}
+
+// ReviewService defines the methods available for the admin review queue
+type ReviewService interface {
+	// GetReviewQueue lists applicants awaiting review across clients
+	GetReviewQueue(c *gin.Context, filter localModels.ReviewQueueFilter) ([]localModels.ReviewQueueItem, error)
+
+	// ClaimApplicant assigns an unassigned applicant to the calling reviewer
+	ClaimApplicant(c *gin.Context, applicantID, reviewerID string) (localModels.ReviewQueueItem, error)
+
+	// AssignApplicant assigns an applicant to a reviewer on behalf of an admin
+	AssignApplicant(c *gin.Context, applicantID, reviewerID, assignedBy string) (localModels.ReviewQueueItem, error)
+
+	// ReleaseApplicant returns a claimed applicant to the unassigned pool
+	ReleaseApplicant(c *gin.Context, applicantID, reviewerID string) (localModels.ReviewQueueItem, error)
+}
+
+// DecisionService defines the methods available for manual verification decisions
+type DecisionService interface {
+	// ProposeDecision records a reviewer's decision, applying it immediately unless dual control is required
+	ProposeDecision(c *gin.Context, applicantID, reviewerID string, decision localModels.ReviewDecision, reasonCode, comment string) (localModels.Decision, error)
+
+	// ConfirmDecision applies a pending decision on behalf of a second reviewer
+	ConfirmDecision(c *gin.Context, decisionID, reviewerID, comment string) (localModels.Decision, error)
+
+	// DeclineDecision discards a pending decision, leaving the applicant in the review queue
+	DeclineDecision(c *gin.Context, decisionID, reviewerID, comment string) (localModels.Decision, error)
+
+	// GetApplicantDecisions lists the decisions recorded for an applicant, newest first
+	GetApplicantDecisions(c *gin.Context, applicantID string) ([]localModels.Decision, error)
+}
+
+// RiskService defines the methods other subsystems use to raise risk signals on an applicant
+type RiskService interface {
+	// RecordSignal appends a risk signal to the applicant
+	RecordSignal(ctx context.Context, applicantID string, signal localModels.RiskSignal) error
+}
+
+// WebhookService defines the methods available for client webhooks
+type WebhookService interface {
+	// Emit queues an event for delivery to the client's webhook endpoint
+	Emit(ctx context.Context, clientID, eventType string, data interface{}) error
+
+	// GetEndpoint returns the client's registered webhook endpoint
+	GetEndpoint(ctx context.Context, clientID string) (localModels.WebhookEndpoint, error)
+
+	// SetEndpoint registers or changes the client's webhook URL
+	SetEndpoint(ctx context.Context, clientID, url string) (localModels.WebhookEndpoint, error)
+
+	// RotateSecret gives the client's endpoint a new signing secret, keeping the current one signing for the grace period
+	RotateSecret(ctx context.Context, clientID string, grace time.Duration) (localModels.WebhookEndpoint, error)
+
+	// VerifySignature checks a signature header against a payload with the client's active signing secrets
+	VerifySignature(ctx context.Context, clientID, header string, payload []byte) (localModels.WebhookVerification, error)
+
+	// ListDeadLetters lists a page of the client's webhook events that ran out of delivery
+	// attempts, most recent first, with one more than the page holds if another follows
+	ListDeadLetters(ctx context.Context, clientID string, page pagination.Page) ([]localModels.WebhookDeadLetter, error)
+
+	// Redeliver queues a dead letter's event for delivery again
+	Redeliver(ctx context.Context, clientID, eventID, redeliveredBy string) (localModels.WebhookDeadLetter, error)
+
+	// SendTestEvent sends a sample event of a type to the client's endpoint and reports how it answered
+	SendTestEvent(ctx context.Context, clientID, eventType string) (localModels.WebhookTestResult, error)
+}
+
+// ClientService defines the methods available for registering API clients and managing their settings
+type ClientService interface {
+	// RegisterClient creates a client and its first API key
+	RegisterClient(ctx context.Context, client localModels.Client, adminID string) (localModels.ClientRegistration, error)
+
+	// GetClient returns a client with its webhook URL
+	GetClient(ctx context.Context, clientID string) (localModels.Client, error)
+
+	// UpdateClient replaces a client's settings
+	UpdateClient(ctx context.Context, client localModels.Client) (localModels.Client, error)
+
+	// SetIPAllowlist replaces the networks the client's requests may come from
+	SetIPAllowlist(ctx context.Context, clientID string, cidrs []string, updatedBy string) (localModels.IPAllowlist, error)
+
+	// SetIPAllowlistBypass switches the emergency bypass of the client's IP allowlist on or off
+	SetIPAllowlistBypass(ctx context.Context, clientID string, bypass bool, adminID string) (localModels.IPAllowlist, error)
+}
+
+// UsageRecorder meters billable events for a client
+type UsageRecorder interface {
+	// Record meters a billable event. Recording the same event for the same subject again has no effect.
+	Record(ctx context.Context, clientID, eventType, subjectID string) error
+}
+
+// UsageService defines the methods available for client usage metering
+type UsageService interface {
+	UsageRecorder
+
+	// GetUsage counts a client's billable events for each month in a range of months
+	GetUsage(ctx context.Context, clientID, fromMonth, toMonth string) ([]localModels.MonthlyUsage, error)
+}
+
+// StatsService defines the methods available for a client's aggregate applicant statistics
+type StatsService interface {
+	// GetClientStats counts a client's applicants and documents without exposing any one applicant
+	GetClientStats(ctx context.Context, clientID string) (localModels.ApplicantStats, error)
+}
+
+// OperationsService defines the cross-client figures behind the internal operations dashboard
+type OperationsService interface {
+	// ClientVolumes counts each client's applicants and documents created since a time, busiest first
+	ClientVolumes(ctx context.Context, since time.Time) ([]localModels.ClientVolume, error)
+
+	// WebhookDeliveries counts each client's webhook events created since a time by delivery state
+	WebhookDeliveries(ctx context.Context, since time.Time) ([]localModels.WebhookDeliveryStats, error)
+
+	// QueueDepth counts the work currently waiting
+	QueueDepth(ctx context.Context) (localModels.QueueDepth, error)
+}
+
+// AttachmentService defines the methods available for supporting attachments on applicants.
+// An empty clientID means a reviewer, who can see internal attachments as well as shared ones.
+type AttachmentService interface {
+	// AddAttachment checks and stores a file and records it on one of the scope's applicants
+	AddAttachment(c *gin.Context, scope tenant.Filter, attachment localModels.Attachment, file multipart.File, header *multipart.FileHeader) (localModels.Attachment, error)
+
+	// ListAttachments lists the attachments on one of the scope's applicants visible to the caller
+	ListAttachments(c *gin.Context, applicantID string, scope tenant.Filter) ([]localModels.Attachment, error)
+
+	// OpenAttachment returns an attachment and its content; the caller must close the content
+	OpenAttachment(c *gin.Context, applicantID, attachmentID string, scope tenant.Filter) (localModels.Attachment, io.ReadCloser, error)
+
+	// DeleteAttachment soft deletes an attachment
+	DeleteAttachment(c *gin.Context, applicantID, attachmentID, deletedBy string) error
+}
+
+// NoteService defines the methods available for case notes on applicants. Reviewers
+// work across clients with tenant.AllClients and see internal notes as well as shared ones.
+type NoteService interface {
+	// AddNote records a note on one of the scope's applicants
+	AddNote(c *gin.Context, scope tenant.Filter, note localModels.Note) (localModels.Note, error)
+
+	// ListNotes lists a page of the notes on one of the scope's applicants visible to the
+	// caller, newest first, with one more than the page holds if another follows
+	ListNotes(c *gin.Context, applicantID string, scope tenant.Filter, page pagination.Page) ([]localModels.Note, error)
+}
+
+// AuditService defines the methods available for the hash-chained audit log of staff actions
+// and document downloads
+type AuditService interface {
+	// Record appends an event to the audit log and returns it with its sequence number and hashes
+	Record(ctx context.Context, event localModels.AuditEvent) (localModels.AuditEvent, error)
+
+	// Export returns the events in [from, to) with a proof that none were left out
+	Export(ctx context.Context, from, to time.Time) (localModels.AuditExport, error)
+
+	// DocumentAccessLog returns every recorded download of a document's files, oldest first
+	DocumentAccessLog(ctx context.Context, documentID string) (localModels.DocumentAccessLog, error)
+}
+
+// SigningService defines the methods available for the keys that sign API responses to a client
+type SigningService interface {
+	// RotateKey creates a signing key for the client, retiring its current keys after a grace period.
+	// The new key's secret is only ever returned here.
+	RotateKey(ctx context.Context, clientID, adminID string) (localModels.ResponseSigningKey, error)
+
+	// ListKeys lists the client's signing keys without their secrets, newest first
+	ListKeys(ctx context.Context, clientID string) ([]localModels.ResponseSigningKey, error)
+
+	// ActiveKeys returns the client's keys that have not retired, with their secrets, newest first
+	ActiveKeys(ctx context.Context, clientID string) ([]localModels.ResponseSigningKey, error)
+}
+
+// JobService defines the methods available for managing scheduled jobs from the admin API
+type JobService interface {
+	// ListJobs lists the registered jobs with their schedules and state
+	ListJobs(ctx context.Context) ([]localModels.JobStatus, error)
+
+	// ListRuns lists a job's most recent runs, newest first
+	ListRuns(ctx context.Context, name string, limit int64) ([]localModels.JobRun, error)
+
+	// Trigger starts a run of a job now, unless a replica is already running it
+	Trigger(ctx context.Context, name, triggeredBy string) (localModels.JobRun, error)
+
+	// Pause stops a job's scheduled runs until it is resumed
+	Pause(ctx context.Context, name, pausedBy string) (localModels.JobStatus, error)
+
+	// Resume restarts a paused job's schedule from now
+	Resume(ctx context.Context, name string) (localModels.JobStatus, error)
+}
+
+// BackupService defines the methods available for encrypted client snapshots
+type BackupService interface {
+	// Snapshot dumps a client's records to the backup bucket now
+	Snapshot(ctx context.Context, clientID, takenBy string) (localModels.Backup, error)
+
+	// ListBackups lists a client's snapshots, newest first
+	ListBackups(ctx context.Context, clientID string, limit int64) ([]localModels.Backup, error)
+}
+
+// ReportService defines the methods available for PDF verification reports of applicants
+type ReportService interface {
+	// RequestReport generates the report of a client's applicant, or queues it when the
+	// applicant has too many documents to generate it during the request
+	RequestReport(ctx context.Context, clientID, applicantID, purpose string) (localModels.ApplicantReport, error)
+
+	// GetReport returns a queued report of a client's applicant, with its PDF once it is ready
+	GetReport(ctx context.Context, clientID, applicantID, reportID string) (localModels.ApplicantReport, error)
+}
+
+// ComplianceReportService defines the methods available for clients' compliance reports
+type ComplianceReportService interface {
+	// RequestComplianceReport queues a report of the client's records from from up to to
+	RequestComplianceReport(ctx context.Context, clientID, format string, from, to time.Time) (localModels.ComplianceReport, error)
+
+	// GetComplianceReport returns a compliance report of the client, with its file once it is ready
+	GetComplianceReport(ctx context.Context, clientID, reportID string) (localModels.ComplianceReport, error)
+}
+
+// RekeyService defines the methods available for re-encrypting applicants' data
+type RekeyService interface {
+	// Rekey re-encrypts an applicant's personal data and its files' data keys under the current KMS key
+	Rekey(ctx context.Context, clientID, applicantID string) (localModels.Rekey, error)
+}
+
+// EncryptionKeyService defines the methods available for clients' own KMS keys
+type EncryptionKeyService interface {
+	// RegisterKey records a KMS key the client wants its data encrypted under
+	RegisterKey(ctx context.Context, clientID, keyARN string) (localModels.EncryptionKey, error)
+
+	// ListKeys returns the client's keys, newest first
+	ListKeys(ctx context.Context, clientID string) ([]localModels.EncryptionKey, error)
+
+	// GetKey returns one of the client's keys, with the progress of its migration once activated
+	GetKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error)
+
+	// ValidateKey checks the service can encrypt and decrypt with the key, marking it validated or invalid
+	ValidateKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error)
+
+	// ActivateKey encrypts the client's new data under a validated key and starts moving existing data to it
+	ActivateKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error)
+}
+
+// DocumentThumbnailer draws small images of documents for reports
+type DocumentThumbnailer interface {
+	// Thumbnail returns a JPEG of a document no larger than maxDimension, marked as the client's downloads are
+	Thumbnail(ctx context.Context, doc localModels.DocumentRecord, maxDimension int, download localModels.DocumentDownload) ([]byte, error)
+}
+
+// SumsubClient defines the Sumsub API calls that applicant syncs make
+type SumsubClient interface {
+	// Applicant reads the Sumsub applicant created for one of our applicants
+	Applicant(ctx context.Context, applicantID string) (localModels.SumsubApplicantData, error)
+
+	// Inspections reads Sumsub's verdict on each document set of a Sumsub applicant
+	Inspections(ctx context.Context, sumsubID string) ([]localModels.SumsubInspection, error)
+}
+
+// SumsubSyncService defines the methods available for pulling applicant state back from Sumsub
+type SumsubSyncService interface {
+	// SyncApplicant reconciles one of the client's applicants with Sumsub and records what changed
+	SyncApplicant(ctx context.Context, clientID, applicantID, trigger string) (localModels.SumsubSync, error)
+}
+
+// TokenService defines the methods available for issuing and checking session tokens
+type TokenService interface {
+	// IssueForAPIKey exchanges an API key for an access token and a refresh token
+	IssueForAPIKey(ctx context.Context, apiKey string) (localModels.TokenPair, error)
+
+	// IssueForPassword exchanges a dashboard user's credentials for an access token and a refresh token
+	IssueForPassword(ctx context.Context, username, password string) (localModels.TokenPair, error)
+
+	// Refresh exchanges a refresh token, which cannot be used again, for new tokens
+	Refresh(ctx context.Context, refreshToken string) (localModels.TokenPair, error)
+
+	// Revoke revokes an access token or a refresh token
+	Revoke(ctx context.Context, token string) error
+
+	// Verify returns the claims of a valid access token
+	Verify(ctx context.Context, accessToken string) (localModels.TokenClaims, error)
+
+	// CreateDashboardUser adds a dashboard user who can sign in with a password
+	CreateDashboardUser(ctx context.Context, user localModels.DashboardUser, password string) (localModels.DashboardUser, error)
+
+	// RevokeAPIKey revokes a client's API key and the refresh tokens signed in with it
+	RevokeAPIKey(ctx context.Context, clientID, keyID string) error
+
+	// DisableDashboardUser stops a dashboard user signing in and revokes the user's refresh tokens
+	DisableDashboardUser(ctx context.Context, clientID, userID string) error
+}
+
+// SessionService defines the methods available for hosted verification sessions
+type SessionService interface {
+	// CreateSession starts a session for one of the client's applicants and returns its token and link
+	CreateSession(ctx context.Context, clientID, applicantID string, ttl time.Duration) (localModels.SessionLink, error)
+
+	// GetSession returns one of the sessions of a client's applicant
+	GetSession(ctx context.Context, clientID, applicantID, sessionID string) (localModels.VerificationSession, error)
+
+	// Authenticate returns the session a token was issued for and the channel it was handed over by
+	Authenticate(ctx context.Context, token string) (localModels.VerificationSession, localModels.SessionChannel, error)
+
+	// HandoffCode returns a PNG QR code of a link that opens the session on the applicant's phone
+	HandoffCode(ctx context.Context, session localModels.VerificationSession, size int) ([]byte, error)
+
+	// OpenSession records that the applicant has loaded the hosted page
+	OpenSession(ctx context.Context, session localModels.VerificationSession) (localModels.VerificationSession, error)
+
+	// RecordSubmission adds a document uploaded through a session to it, and tells the pages watching the session
+	RecordSubmission(ctx context.Context, session localModels.VerificationSession, documentID string) error
+
+	// CompleteSession records that the applicant has finished uploading, on the given channel
+	CompleteSession(ctx context.Context, session localModels.VerificationSession, channel localModels.SessionChannel) (localModels.VerificationSession, error)
+
+	// WatchSession returns the events of a session's applicant from now on, until stop is called
+	WatchSession(session localModels.VerificationSession) (<-chan localModels.SessionEvent, func())
+}
+
+// SessionEventBus carries session events to the hosted pages watching them, across replicas
+type SessionEventBus interface {
+	// Publish sends an event to the pages watching its applicant
+	Publish(ctx context.Context, event localModels.SessionEvent) error
+
+	// Subscribe returns the events of a client's applicant from now on, until stop is called
+	Subscribe(clientID, applicantID string) (<-chan localModels.SessionEvent, func())
 }
 
 // Uploader defines the method that an uploader must implement
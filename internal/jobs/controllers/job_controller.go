@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRunLimit = 20
+	maxRunLimit     = 200
+)
+
+// ListJobs is the handler function for listing scheduled jobs with their schedules and state
+func ListJobs(c *gin.Context, service interfaces.JobService) {
+	jobs, err := service.ListJobs(c.Request.Context())
+	if err != nil {
+		zaplogger.GetLogger().Error("Error listing jobs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": jobs, "count": len(jobs)})
+}
+
+// ListJobRuns is the handler function for a job's run history, newest first
+func ListJobRuns(c *gin.Context, service interfaces.JobService) {
+	limit := int64(defaultRunLimit)
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.ParseInt(limitParam, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxRunLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxRunLimit)})
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := service.ListRuns(c.Request.Context(), c.Param("name"), limit)
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error("Error listing job runs", zap.Error(err), zap.String("job", c.Param("name")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve job runs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": runs, "count": len(runs)})
+}
+
+// TriggerJob is the handler function for running a job now. The run happens in the
+// background; the response is its record, to be followed in the job's run history.
+func TriggerJob(c *gin.Context, service interfaces.JobService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := service.Trigger(c.Request.Context(), c.Param("name"), adminID)
+	switch {
+	case apperr.Respond(c, err):
+	case err != nil:
+		zaplogger.GetLogger().Error("Error triggering job", zap.Error(err), zap.String("job", c.Param("name")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not trigger job"})
+	default:
+		c.JSON(http.StatusAccepted, run)
+	}
+}
+
+// PauseJob is the handler function for stopping a job's scheduled runs on every replica
+func PauseJob(c *gin.Context, service interfaces.JobService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := service.Pause(c.Request.Context(), c.Param("name"), adminID)
+	respondJob(c, job, err, "Could not pause job")
+}
+
+// ResumeJob is the handler function for restarting a paused job's schedule
+func ResumeJob(c *gin.Context, service interfaces.JobService) {
+	job, err := service.Resume(c.Request.Context(), c.Param("name"))
+	respondJob(c, job, err, "Could not resume job")
+}
+
+// respondJob writes a job's state after it was paused or resumed
+func respondJob(c *gin.Context, job localModels.JobStatus, err error, failure string) {
+	if apperr.Respond(c, err) {
+		return
+	}
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error(failure, zap.Error(err), zap.String("job", c.Param("name")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/jobs/services"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupJobRouter(mockService *localMocks.MockJobService, adminID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		if adminID != "" {
+			c.Set("admin_id", adminID)
+		}
+	})
+	router.GET("/jobs", func(c *gin.Context) {
+		ListJobs(c, mockService)
+	})
+	router.GET("/jobs/:name/runs", func(c *gin.Context) {
+		ListJobRuns(c, mockService)
+	})
+	router.POST("/jobs/:name/run", func(c *gin.Context) {
+		TriggerJob(c, mockService)
+	})
+	router.POST("/jobs/:name/pause", func(c *gin.Context) {
+		PauseJob(c, mockService)
+	})
+	router.POST("/jobs/:name/resume", func(c *gin.Context) {
+		ResumeJob(c, mockService)
+	})
+	return router
+}
+
+func TestListJobs(t *testing.T) {
+	mockService := new(localMocks.MockJobService)
+	mockService.On("ListJobs", mock.Anything).Return([]localModels.JobStatus{
+		{JobState: localModels.JobState{Name: "upload_reconciliation"}, Schedule: "* * * * *"},
+	}, nil)
+	router := setupJobRouter(mockService, "admin1")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/jobs", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"upload_reconciliation"`)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+}
+
+func TestListJobRuns(t *testing.T) {
+	tests := []struct {
+		name               string
+		query              string
+		limit              int64
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Default limit", limit: 20, expectedStatusCode: http.StatusOK},
+		{name: "Custom limit", query: "?limit=5", limit: 5, expectedStatusCode: http.StatusOK},
+		{name: "Limit too high", query: "?limit=500", expectedStatusCode: http.StatusBadRequest},
+		{name: "Unknown job", limit: 20, serviceErr: services.ErrJobNotFound, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockJobService)
+			mockService.On("ListRuns", mock.Anything, "usage_export", tt.limit).
+				Return([]localModels.JobRun{{RunID: "run1", Job: "usage_export"}}, tt.serviceErr)
+			router := setupJobRouter(mockService, "admin1")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/jobs/usage_export/runs"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"run_id":"run1"`)
+				mockService.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestTriggerJob(t *testing.T) {
+	tests := []struct {
+		name               string
+		adminID            string
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Run started", adminID: "admin1", expectedStatusCode: http.StatusAccepted},
+		{name: "No admin", expectedStatusCode: http.StatusUnauthorized},
+		{name: "Unknown job", adminID: "admin1", serviceErr: services.ErrJobNotFound, expectedStatusCode: http.StatusNotFound},
+		{name: "Already running", adminID: "admin1", serviceErr: services.ErrJobRunning, expectedStatusCode: http.StatusConflict},
+		{name: "Storage fails", adminID: "admin1", serviceErr: errors.New("boom"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockJobService)
+			mockService.On("Trigger", mock.Anything, "usage_export", "admin1").
+				Return(localModels.JobRun{RunID: "run1", Job: "usage_export", Trigger: localModels.JobTriggerManual}, tt.serviceErr)
+			router := setupJobRouter(mockService, tt.adminID)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/jobs/usage_export/run", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusAccepted {
+				assert.Contains(t, w.Body.String(), `"trigger":"manual"`)
+			}
+		})
+	}
+}
+
+func TestPauseAndResumeJob(t *testing.T) {
+	mockService := new(localMocks.MockJobService)
+	mockService.On("Pause", mock.Anything, "usage_export", "admin1").
+		Return(localModels.JobStatus{JobState: localModels.JobState{Name: "usage_export", Paused: true, PausedBy: "admin1"}}, nil)
+	mockService.On("Resume", mock.Anything, "usage_export").
+		Return(localModels.JobStatus{JobState: localModels.JobState{Name: "usage_export"}}, nil)
+	mockService.On("Resume", mock.Anything, "unknown").
+		Return(localModels.JobStatus{}, services.ErrJobNotFound)
+	router := setupJobRouter(mockService, "admin1")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/jobs/usage_export/pause", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"paused":true`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/jobs/usage_export/resume", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"paused":false`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/jobs/unknown/resume", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
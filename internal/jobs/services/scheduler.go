@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/cron"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+var (
+	// ErrJobNotFound is returned for a job name this service has not registered
+	ErrJobNotFound = apperr.NotFound("job_not_found", "job not found")
+	// ErrJobRunning is returned when a job is triggered while a replica is running it
+	ErrJobRunning = apperr.Conflict("job_running", "job is already running")
+)
+
+const (
+	defaultPollInterval = 15 * time.Second
+	defaultLockTTL      = 5 * time.Minute
+	defaultRunRetention = 30 * 24 * time.Hour
+)
+
+// Counters published under "jobs" on the expvar endpoint
+const (
+	statRuns     = "runs"      // Runs started on this instance
+	statFailures = "failures"  // Runs that returned an error
+	statLockLost = "lock_lost" // Runs cancelled because another replica took over the job's lock
+)
+
+var jobStats = expvar.NewMap("jobs")
+
+func init() {
+	for _, name := range []string{statRuns, statFailures, statLockLost} {
+		jobStats.Add(name, 0)
+	}
+}
+
+// job is a unit of work registered with the scheduler
+type job struct {
+	name     string
+	schedule *cron.Schedule // Nil for jobs that only run when triggered
+	run      func(ctx context.Context) error
+}
+
+// Scheduler runs jobs on cron schedules across replicas. Every replica registers the same
+// jobs and polls for due ones; a job's lock in the jobs collection elects the replica
+// that runs it, so each run happens once. The lock is renewed while the job runs, and a
+// replica that dies mid-run hands the job over once LockTTL passes.
+type Scheduler struct {
+	CollectionName    string
+	RunCollectionName string
+	// Instance identifies this replica in locks and run history
+	Instance     string
+	PollInterval time.Duration
+	LockTTL      time.Duration
+	// RunRetention is how long run history is kept
+	RunRetention time.Duration
+
+	schedules map[string]string
+	mu        sync.Mutex
+	jobs      map[string]*job
+	prepared  bool
+}
+
+// NewScheduler creates a scheduler for the jobs collection, applying defaults for unset settings
+func NewScheduler(settings config.JobSettings) *Scheduler {
+	pollInterval := settings.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	lockTTL := settings.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = defaultLockTTL
+	}
+	runRetention := settings.RunRetention
+	if runRetention <= 0 {
+		runRetention = defaultRunRetention
+	}
+	host, _ := os.Hostname()
+	return &Scheduler{
+		CollectionName:    localConstants.CollectionJobs,
+		RunCollectionName: localConstants.CollectionJobRuns,
+		Instance:          host + "-" + uuid.NewString()[:8],
+		PollInterval:      pollInterval,
+		LockTTL:           lockTTL,
+		RunRetention:      runRetention,
+		schedules:         settings.Schedules,
+		jobs:              make(map[string]*job),
+	}
+}
+
+// Register adds a job, to run on the schedule configured for its name. Jobs without a
+// schedule only run when triggered.
+func (s *Scheduler) Register(name string, run func(ctx context.Context) error) error {
+	j := &job{name: name, run: run}
+	if expr := s.schedules[name]; expr != "" {
+		schedule, err := cron.Parse(expr)
+		if err != nil {
+			return fmt.Errorf("invalid schedule for job %s: %w", name, err)
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return fmt.Errorf("schedule for job %s never runs", name)
+		}
+		j.schedule = &schedule
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %s is already registered", name)
+	}
+	s.jobs[name] = j
+	return nil
+}
+
+// registered returns the registered jobs sorted by name
+func (s *Scheduler) registered() []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].name < jobs[k].name })
+	return jobs
+}
+
+func (s *Scheduler) lookup(name string) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return j, nil
+}
+
+// lockFree matches a job's state when no replica holds its lock
+func lockFree(name string, now time.Time) bson.M {
+	return bson.M{
+		"name": name,
+		"$or":  bson.A{bson.M{"locked_until": nil}, bson.M{"locked_until": bson.M{"$lte": now}}},
+	}
+}
+
+// prepare records each job's next run. A schedule brought forward in config takes effect
+// straight away; one pushed back waits for the run already due. Jobs that lost their
+// schedule are no longer due.
+func (s *Scheduler) prepare(ctx context.Context, now time.Time) error {
+	collection := common.GetCollection(s.CollectionName)
+	for _, j := range s.registered() {
+		update := bson.M{"$setOnInsert": bson.M{"paused": false}}
+		if j.schedule != nil {
+			update["$min"] = bson.M{"next_run_at": j.schedule.Next(now)}
+		} else {
+			update["$unset"] = bson.M{"next_run_at": ""}
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"name": j.name}, update, options.Update().SetUpsert(true)); err != nil {
+			return fmt.Errorf("failed to record schedule of job %s: %w", j.name, err)
+		}
+	}
+
+	for name := range s.schedules {
+		if _, err := s.lookup(name); err != nil {
+			zaplogger.GetLogger().Warn("Schedule configured for a job that is not registered", zap.String("job", name))
+		}
+	}
+	return nil
+}
+
+// RunDue starts every scheduled job that is due and not paused or running elsewhere.
+// Jobs run in the background, so a long run does not hold up the others.
+func (s *Scheduler) RunDue(ctx context.Context) error {
+	now := timestamp.Now()
+	if !s.prepared {
+		if err := s.prepare(ctx, now); err != nil {
+			return err
+		}
+		s.prepared = true
+	}
+
+	var errs []error
+	for _, j := range s.registered() {
+		if j.schedule == nil {
+			continue
+		}
+		due := lockFree(j.name, now)
+		due["paused"] = bson.M{"$ne": true}
+		due["next_run_at"] = bson.M{"$lte": now}
+		claim := bson.M{"$set": bson.M{
+			"locked_by":    s.Instance,
+			"locked_until": now.Add(s.LockTTL),
+			"next_run_at":  j.schedule.Next(now),
+		}}
+		err := common.GetCollection(s.CollectionName).FindOneAndUpdate(ctx, due, claim).Err()
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to claim job %s: %w", j.name, err))
+			continue
+		}
+		s.launch(j, localModels.JobTriggerSchedule, "")
+	}
+	return errors.Join(errs...)
+}
+
+// Trigger runs a job now, whatever its schedule and even when it is paused
+func (s *Scheduler) Trigger(ctx context.Context, name, triggeredBy string) (localModels.JobRun, error) {
+	j, err := s.lookup(name)
+	if err != nil {
+		return localModels.JobRun{}, err
+	}
+	now := timestamp.Now()
+	claim := bson.M{
+		"$set":         bson.M{"locked_by": s.Instance, "locked_until": now.Add(s.LockTTL)},
+		"$setOnInsert": bson.M{"paused": false},
+	}
+	// The upsert only inserts for a job no replica has recorded yet; for a locked job it
+	// collides with the existing state on the unique name index
+	_, err = common.GetCollection(s.CollectionName).UpdateOne(ctx, lockFree(name, now), claim, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return localModels.JobRun{}, ErrJobRunning
+	}
+	if err != nil {
+		return localModels.JobRun{}, fmt.Errorf("failed to claim job %s: %w", name, err)
+	}
+	return s.launch(j, localModels.JobTriggerManual, triggeredBy), nil
+}
+
+// launch records a run of a job whose lock this instance holds and starts it
+func (s *Scheduler) launch(j *job, trigger, triggeredBy string) localModels.JobRun {
+	now := timestamp.Now()
+	run := localModels.JobRun{
+		RunID:       ids.New(),
+		Job:         j.name,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Instance:    s.Instance,
+		Status:      localModels.JobRunning,
+		StartedAt:   now,
+		ExpiresAt:   now.Add(s.RunRetention),
+	}
+	// History is kept for operators; failing to record it does not stop the run
+	if _, err := common.GetCollection(s.RunCollectionName).InsertOne(context.Background(), run); err != nil {
+		zaplogger.GetLogger().Warn("Error recording job run", zap.Error(err), zap.String("job", j.name))
+	}
+	jobStats.Add(statRuns, 1)
+	go s.execute(j, run)
+	return run
+}
+
+// execute runs a job while renewing its lock, then records the outcome and releases the lock.
+// The job's context is cancelled if another replica takes the lock over.
+func (s *Scheduler) execute(j *job, run localModels.JobRun) {
+	logger := zaplogger.GetLogger().With(zap.String("job", j.name), zap.String("runID", run.RunID))
+	logger.Info("Job started", zap.String("trigger", run.Trigger))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go s.renew(ctx, cancel, j.name, done)
+	err := j.run(ctx)
+	close(done)
+	cancel()
+
+	finished := timestamp.Now()
+	run.FinishedAt = &finished
+	run.DurationMS = finished.Sub(run.StartedAt).Milliseconds()
+	run.Status = localModels.JobSucceeded
+	if err != nil {
+		run.Status = localModels.JobFailed
+		run.Error = err.Error()
+		jobStats.Add(statFailures, 1)
+		logger.Error("Job failed", zap.Error(err), zap.Int64("durationMs", run.DurationMS))
+	} else {
+		logger.Info("Job finished", zap.Int64("durationMs", run.DurationMS))
+	}
+
+	record := bson.M{"$set": bson.M{
+		"status":      run.Status,
+		"error":       run.Error,
+		"finished_at": run.FinishedAt,
+		"duration_ms": run.DurationMS,
+	}}
+	if _, err := common.GetCollection(s.RunCollectionName).UpdateOne(context.Background(), bson.M{"run_id": run.RunID}, record); err != nil {
+		logger.Warn("Error recording job outcome", zap.Error(err))
+	}
+	release := bson.M{
+		"$set":   bson.M{"last_run_at": run.StartedAt, "last_status": run.Status},
+		"$unset": bson.M{"locked_by": "", "locked_until": ""},
+	}
+	if _, err := common.GetCollection(s.CollectionName).UpdateOne(context.Background(), bson.M{"name": j.name, "locked_by": s.Instance}, release); err != nil {
+		logger.Error("Error releasing job lock", zap.Error(err))
+	}
+}
+
+// renew extends this instance's lock on a job until done is closed, cancelling the run
+// if the lock has passed to another replica
+func (s *Scheduler) renew(ctx context.Context, cancel context.CancelFunc, name string, done <-chan struct{}) {
+	ticker := time.NewTicker(s.LockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		result, err := common.GetCollection(s.CollectionName).UpdateOne(ctx,
+			bson.M{"name": name, "locked_by": s.Instance},
+			bson.M{"$set": bson.M{"locked_until": timestamp.Now().Add(s.LockTTL)}},
+		)
+		if err != nil {
+			// The lock may still be held; try again on the next tick
+			zaplogger.GetLogger().Warn("Error renewing job lock", zap.Error(err), zap.String("job", name))
+			continue
+		}
+		if result.MatchedCount == 0 {
+			jobStats.Add(statLockLost, 1)
+			zaplogger.GetLogger().Error("Job lock taken over by another replica; cancelling run", zap.String("job", name))
+			cancel()
+			return
+		}
+	}
+}
+
+// ListJobs lists the registered jobs with their schedules and shared state
+func (s *Scheduler) ListJobs(ctx context.Context) ([]localModels.JobStatus, error) {
+	cursor, err := common.GetCollection(s.CollectionName).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jobs: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var states []localModels.JobState
+	if err := cursor.All(ctx, &states); err != nil {
+		return nil, fmt.Errorf("failed to decode jobs: %w", err)
+	}
+	byName := make(map[string]localModels.JobState, len(states))
+	for _, state := range states {
+		byName[state.Name] = state
+	}
+
+	now := time.Now()
+	jobs := s.registered()
+	statuses := make([]localModels.JobStatus, len(jobs))
+	for i, j := range jobs {
+		state, ok := byName[j.name]
+		if !ok {
+			state = localModels.JobState{Name: j.name}
+		}
+		statuses[i] = s.status(j, state, now)
+	}
+	return statuses, nil
+}
+
+// status combines a job's registration with its shared state
+func (s *Scheduler) status(j *job, state localModels.JobState, now time.Time) localModels.JobStatus {
+	status := localModels.JobStatus{
+		JobState: state,
+		Running:  state.LockedUntil != nil && state.LockedUntil.After(now),
+	}
+	if j.schedule != nil {
+		status.Schedule = j.schedule.String()
+	}
+	return status
+}
+
+// ListRuns lists a job's most recent runs, newest first
+func (s *Scheduler) ListRuns(ctx context.Context, name string, limit int64) ([]localModels.JobRun, error) {
+	if _, err := s.lookup(name); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(limit)
+	cursor, err := common.GetCollection(s.RunCollectionName).Find(ctx, bson.M{"job": name}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch job runs: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	runs := []localModels.JobRun{}
+	if err := cursor.All(ctx, &runs); err != nil {
+		return nil, fmt.Errorf("failed to decode job runs: %w", err)
+	}
+	return runs, nil
+}
+
+// Pause stops a job's scheduled runs on every replica. A run in progress carries on.
+func (s *Scheduler) Pause(ctx context.Context, name, pausedBy string) (localModels.JobStatus, error) {
+	j, err := s.lookup(name)
+	if err != nil {
+		return localModels.JobStatus{}, err
+	}
+	update := bson.M{"$set": bson.M{"paused": true, "paused_by": pausedBy, "paused_at": timestamp.Now()}}
+	return s.setPaused(ctx, j, update)
+}
+
+// Resume restarts a paused job's schedule from now, skipping the runs missed while paused
+func (s *Scheduler) Resume(ctx context.Context, name string) (localModels.JobStatus, error) {
+	j, err := s.lookup(name)
+	if err != nil {
+		return localModels.JobStatus{}, err
+	}
+	set := bson.M{"paused": false}
+	if j.schedule != nil {
+		set["next_run_at"] = j.schedule.Next(time.Now())
+	}
+	update := bson.M{"$set": set, "$unset": bson.M{"paused_by": "", "paused_at": ""}}
+	return s.setPaused(ctx, j, update)
+}
+
+func (s *Scheduler) setPaused(ctx context.Context, j *job, update bson.M) (localModels.JobStatus, error) {
+	var state localModels.JobState
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := mongoretry.Write(ctx, "pause_job", func(ctx context.Context) error {
+		return common.GetCollection(s.CollectionName).FindOneAndUpdate(ctx, bson.M{"name": j.name}, update, opts).Decode(&state)
+	})
+	if err != nil {
+		return localModels.JobStatus{}, err
+	}
+	return s.status(j, state, time.Now()), nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func noop(ctx context.Context) error { return nil }
+
+func TestNewSchedulerDefaults(t *testing.T) {
+	scheduler := NewScheduler(config.JobSettings{})
+
+	assert.Equal(t, defaultPollInterval, scheduler.PollInterval)
+	assert.Equal(t, defaultLockTTL, scheduler.LockTTL)
+	assert.Equal(t, defaultRunRetention, scheduler.RunRetention)
+	assert.NotEmpty(t, scheduler.Instance)
+	assert.NotEqual(t, scheduler.Instance, NewScheduler(config.JobSettings{}).Instance, "replicas on one host are told apart")
+}
+
+func TestRegister(t *testing.T) {
+	scheduler := NewScheduler(config.JobSettings{Schedules: map[string]string{
+		"hourly":  "@hourly",
+		"broken":  "every minute",
+		"never":   "0 0 31 2 *",
+		"ignored": "",
+	}})
+
+	assert.NoError(t, scheduler.Register("hourly", noop))
+	assert.NoError(t, scheduler.Register("manual", noop), "jobs need no schedule")
+	assert.NoError(t, scheduler.Register("ignored", noop))
+	assert.Error(t, scheduler.Register("broken", noop))
+	assert.Error(t, scheduler.Register("never", noop))
+	assert.Error(t, scheduler.Register("hourly", noop), "names are unique")
+
+	jobs := scheduler.registered()
+	if assert.Len(t, jobs, 3) {
+		assert.Equal(t, "hourly", jobs[0].name)
+		assert.Equal(t, "@hourly", jobs[0].schedule.String())
+		assert.Nil(t, jobs[1].schedule)
+		assert.Equal(t, "manual", jobs[2].name)
+	}
+
+	_, err := scheduler.lookup("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = scheduler.ListRuns(context.Background(), "missing", 10)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = scheduler.Trigger(context.Background(), "missing", "admin1")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestStatus(t *testing.T) {
+	scheduler := NewScheduler(config.JobSettings{Schedules: map[string]string{"nightly": "0 2 * * *"}})
+	assert.NoError(t, scheduler.Register("nightly", noop))
+	j, _ := scheduler.lookup("nightly")
+
+	now := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	held := now.Add(time.Minute)
+	expired := now.Add(-time.Minute)
+
+	running := scheduler.status(j, localModels.JobState{Name: "nightly", LockedBy: "host-1", LockedUntil: &held}, now)
+	assert.True(t, running.Running)
+	assert.Equal(t, "0 2 * * *", running.Schedule)
+
+	abandoned := scheduler.status(j, localModels.JobState{Name: "nightly", LockedBy: "host-1", LockedUntil: &expired}, now)
+	assert.False(t, abandoned.Running, "a lock that was not renewed no longer counts")
+
+	assert.False(t, scheduler.status(j, localModels.JobState{Name: "nightly"}, now).Running)
+}
@@ -0,0 +1,143 @@
+// Package jsonlimit bounds the JSON bodies of routes that take small documents, such as
+// applicant creation and updates. A body is read up to its size limit, after any gzip
+// inflation, and its structure is walked before the handler decodes it, so a compressed
+// bomb or a deeply nested or sprawling document is refused instead of being decoded.
+package jsonlimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultMaxBytes bounds a body when no size is configured
+	DefaultMaxBytes int64 = 64 << 10
+	// DefaultMaxDepth bounds how deeply objects and arrays may be nested
+	DefaultMaxDepth = 8
+	// DefaultMaxFields bounds the object members and array elements of a body
+	DefaultMaxFields = 500
+)
+
+var (
+	// ErrTooDeep is returned for a body nested deeper than the limit
+	ErrTooDeep = errors.New("request body is nested too deeply")
+	// ErrTooManyFields is returned for a body with more fields than the limit
+	ErrTooManyFields = errors.New("request body has too many fields")
+)
+
+// Limits bounds a JSON body. Zero values use the defaults.
+type Limits struct {
+	MaxBytes  int64
+	MaxDepth  int
+	MaxFields int
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultMaxBytes
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultMaxDepth
+	}
+	if l.MaxFields <= 0 {
+		l.MaxFields = DefaultMaxFields
+	}
+	return l
+}
+
+// Middleware refuses bodies larger than the limit with 413 and code payload_too_large,
+// and bodies nested too deeply or with too many fields with 400 and code
+// payload_too_deep or payload_too_many_fields. Bodies that are not valid JSON are passed
+// on for the handler to report.
+func Middleware(limits Limits) gin.HandlerFunc {
+	limits = limits.withDefaults()
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limits.MaxBytes {
+			abortTooLarge(c, limits.MaxBytes)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxBytes))
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			abortTooLarge(c, limits.MaxBytes)
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
+			return
+		}
+
+		switch err := Check(body, limits); {
+		case errors.Is(err, ErrTooDeep):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "payload_too_deep", "limit": limits.MaxDepth})
+			return
+		case errors.Is(err, ErrTooManyFields):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "payload_too_many_fields", "limit": limits.MaxFields})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+func abortTooLarge(c *gin.Context, maxBytes int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error": fmt.Sprintf("Request body is larger than %d bytes", maxBytes),
+		"code":  "payload_too_large",
+		"limit": maxBytes,
+	})
+}
+
+// Check walks a JSON document and returns ErrTooDeep or ErrTooManyFields if it exceeds
+// the limits. Every object member and array element counts as a field. It stops at the
+// first token that is not valid JSON and returns nil, leaving that to the decoder.
+func Check(body []byte, limits Limits) error {
+	limits = limits.withDefaults()
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Containers are tracked to tell an object's keys from its values
+	var containers []json.Delim
+	expectKey := false
+	fields := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			containers = containers[:len(containers)-1]
+			expectKey = len(containers) > 0 && containers[len(containers)-1] == '{'
+			continue
+		}
+		if expectKey {
+			// An object's key; its value follows
+			expectKey = false
+			continue
+		}
+		if len(containers) > 0 {
+			fields++
+			if fields > limits.MaxFields {
+				return ErrTooManyFields
+			}
+		}
+		if delim, ok := token.(json.Delim); ok {
+			containers = append(containers, delim)
+			if len(containers) > limits.MaxDepth {
+				return ErrTooDeep
+			}
+			expectKey = delim == '{'
+			continue
+		}
+		expectKey = len(containers) > 0 && containers[len(containers)-1] == '{'
+	}
+}
@@ -0,0 +1,93 @@
+package jsonlimit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/compression"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	limits := Limits{MaxDepth: 3, MaxFields: 6}
+
+	assert.NoError(t, Check([]byte(`{"first_name":"Ada","address":{"city":"London"},"tags":["a","b"]}`), limits))
+	assert.NoError(t, Check([]byte(`{"a":{"b":{"c":1}}}`), limits))
+	assert.ErrorIs(t, Check([]byte(`{"a":{"b":{"c":{"d":1}}}}`), limits), ErrTooDeep)
+	assert.ErrorIs(t, Check([]byte(`[[[[1]]]]`), limits), ErrTooDeep)
+	assert.ErrorIs(t, Check([]byte(`{"tags":["a","b","c","d","e","f"]}`), limits), ErrTooManyFields)
+	// Invalid JSON is left to the handler's decoder
+	assert.NoError(t, Check([]byte(`{"a":`), limits))
+}
+
+func setupRouter(limits Limits) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(compression.Requests(0))
+	router.POST("/applicants", Middleware(limits), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	return router
+}
+
+func TestMiddleware(t *testing.T) {
+	router := setupRouter(Limits{MaxBytes: 1024, MaxDepth: 3, MaxFields: 10})
+
+	tests := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{name: "Within the limits", body: `{"first_name":"Ada","address":{"city":"London"}}`, expectedStatusCode: http.StatusOK},
+		{name: "Too large", body: `{"first_name":"` + strings.Repeat("a", 2048) + `"}`, expectedStatusCode: http.StatusRequestEntityTooLarge, expectedCode: "payload_too_large"},
+		{name: "Too deep", body: `{"a":{"b":{"c":{"d":1}}}}`, expectedStatusCode: http.StatusBadRequest, expectedCode: "payload_too_deep"},
+		{name: "Too many fields", body: `{"tags":[1,2,3,4,5,6,7,8,9,10]}`, expectedStatusCode: http.StatusBadRequest, expectedCode: "payload_too_many_fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/applicants", strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tt.expectedCode+`"`)
+			} else {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddlewareBoundsInflatedBodies(t *testing.T) {
+	router := setupRouter(Limits{MaxBytes: 1024})
+
+	// A few hundred bytes that inflate to a megabyte
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(`{"first_name":"` + strings.Repeat("a", 1<<20) + `"}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/applicants", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"payload_too_large"`)
+}
@@ -0,0 +1,101 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+// TestMergePatch runs the examples of RFC 7386, appendix A
+func TestMergePatch(t *testing.T) {
+	tests := []struct{ target, patch, result string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		target := decode(t, tt.target)
+		result := MergePatch(target, decode(t, tt.patch))
+		assert.Equal(t, decode(t, tt.result), result, "%s merged with %s", tt.target, tt.patch)
+		assert.Equal(t, decode(t, tt.target), target, "the target is left unchanged")
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, result string
+	}{
+		{"Add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{"Add element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"Append element", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"qux"}]`, `{"foo":["bar","qux"]}`},
+		{"Remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"Remove element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"Replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"Replace element", `{"foo":["bar","baz"]}`, `[{"op":"replace","path":"/foo/0","value":"qux"}]`, `{"foo":["qux","baz"]}`},
+		{"Move", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"Copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`},
+		{"Test then replace", `{"a":{"b":"c"}}`, `[{"op":"test","path":"/a/b","value":"c"},{"op":"replace","path":"/a/b","value":"d"}]`, `{"a":{"b":"d"}}`},
+		{"Escaped tokens", `{"a/b":1,"m~n":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/m~0n"}]`, `{}`},
+		{"Null value", `{"a":1}`, `[{"op":"add","path":"/a","value":null}]`, `{"a":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var operations []Operation
+			require.NoError(t, json.Unmarshal([]byte(tt.patch), &operations))
+			doc := decode(t, tt.doc)
+			result, err := Apply(doc, operations)
+			require.NoError(t, err)
+			assert.Equal(t, decode(t, tt.result), result)
+			assert.Equal(t, decode(t, tt.doc), doc, "the document is left unchanged")
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name, patch string
+		err         error
+	}{
+		{"Test fails", `[{"op":"test","path":"/a","value":2}]`, ErrTestFailed},
+		{"Missing member", `[{"op":"remove","path":"/missing"}]`, ErrInvalidPatch},
+		{"Replace missing member", `[{"op":"replace","path":"/missing","value":1}]`, ErrInvalidPatch},
+		{"Index out of range", `[{"op":"add","path":"/list/3","value":1}]`, ErrInvalidPatch},
+		{"Leading zero index", `[{"op":"remove","path":"/list/01"}]`, ErrInvalidPatch},
+		{"No value", `[{"op":"add","path":"/b"}]`, ErrInvalidPatch},
+		{"Relative path", `[{"op":"remove","path":"a"}]`, ErrInvalidPatch},
+		{"Move into itself", `[{"op":"move","from":"/obj","path":"/obj/child"}]`, ErrInvalidPatch},
+		{"Unknown op", `[{"op":"merge","path":"/a"}]`, ErrInvalidPatch},
+		{"Later operation fails", `[{"op":"replace","path":"/a","value":2},{"op":"test","path":"/a","value":1}]`, ErrTestFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var operations []Operation
+			require.NoError(t, json.Unmarshal([]byte(tt.patch), &operations))
+			doc := decode(t, `{"a":1,"list":[1,2],"obj":{}}`)
+			_, err := Apply(doc, operations)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, decode(t, `{"a":1,"list":[1,2],"obj":{}}`), doc)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Package keyring makes data keys under the KMS key of the client the work is for. Clients
+// with a dedicated key in their settings get data keys made under it, so they can revoke
+// the service's access to their data; other clients use the shared key.
+//
+// The client is read from the context, which is tagged by clientconfig.Middleware for
+// requests and by clientconfig.WithClientID for background work. Decrypting needs no key
+// ID, as KMS reads the key from the encrypted data key.
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	coreInterfaces "github.com/rachel-lawrie/verus_backend_core/interfaces"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// CacheTTL is how long a client's key is reused before its settings are read again,
+// bounding how long a change of key takes to apply on every replica
+const CacheTTL = time.Minute
+
+// keyARN matches the ARN of a KMS key or alias
+var keyARN = regexp.MustCompile(`^arn:aws[a-z-]*:kms:[a-z0-9-]+:\d{12}:(key/[A-Za-z0-9-]+|alias/[A-Za-z0-9/_-]+)$`)
+
+// ValidKeyARN reports whether a client's key setting is the ARN of a KMS key or alias
+func ValidKeyARN(value string) bool {
+	return keyARN.MatchString(value)
+}
+
+// Keyring makes and unwraps data keys with KMS, choosing the key by client
+type Keyring struct {
+	Client       coreInterfaces.KMSClient
+	DefaultKeyID string // Shared key, for clients without their own and work done for no client
+	Clients      clientconfig.Loader
+	cache        *keyCache
+}
+
+// New creates a Keyring falling back to the shared key
+func New(client coreInterfaces.KMSClient, defaultKeyID string, clients clientconfig.Loader) *Keyring {
+	return &Keyring{
+		Client:       client,
+		DefaultKeyID: defaultKeyID,
+		Clients:      clients,
+		cache:        newKeyCache(CacheTTL),
+	}
+}
+
+// GenerateDataKey returns a new data key in plaintext and encrypted under the client's key
+func (k *Keyring) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	keyID, err := k.KeyID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := k.Client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   &keyID,
+		KeySpec: "AES_256",
+	})
+	if err != nil {
+		zaplogger.GetLogger().Error("failed to generate data key", zap.Error(err), zap.String("keyID", keyID))
+		return nil, nil, err
+	}
+	return result.Plaintext, result.CiphertextBlob, nil
+}
+
+// EncryptData encrypts data under the client's key
+func (k *Keyring) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	keyID, err := k.KeyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := k.Client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:     &keyID,
+		Plaintext: plaintext,
+	})
+	if err != nil {
+		zaplogger.GetLogger().Error("failed to encrypt data", zap.Error(err), zap.String("keyID", keyID))
+		return nil, err
+	}
+	return result.CiphertextBlob, nil
+}
+
+// DecryptData decrypts data encrypted under any key the service may use
+func (k *Keyring) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	result, err := k.Client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob: encrypted,
+	})
+	if err != nil {
+		zaplogger.GetLogger().Error("failed to decrypt data", zap.Error(err))
+		return nil, err
+	}
+	return result.Plaintext, nil
+}
+
+// ErrKeyMismatch is returned when a key gives back something other than what it was given
+var ErrKeyMismatch = errors.New("decrypting with the key did not return what was encrypted")
+
+// Check makes a data key under a key and encrypts a probe with it, and decrypts both,
+// to show the service can use the key before any data is encrypted under it
+func (k *Keyring) Check(ctx context.Context, keyID string) error {
+	generated, err := k.Client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{KeyId: &keyID, KeySpec: "AES_256"})
+	if err != nil {
+		return fmt.Errorf("generating a data key failed: %w", err)
+	}
+	if err := k.roundTrip(ctx, generated.CiphertextBlob, generated.Plaintext); err != nil {
+		return err
+	}
+	probe := []byte("verus key check")
+	encrypted, err := k.Client.Encrypt(ctx, &kms.EncryptInput{KeyId: &keyID, Plaintext: probe})
+	if err != nil {
+		return fmt.Errorf("encrypting failed: %w", err)
+	}
+	return k.roundTrip(ctx, encrypted.CiphertextBlob, probe)
+}
+
+// roundTrip decrypts ciphertext and compares it with the plaintext it was made from
+func (k *Keyring) roundTrip(ctx context.Context, ciphertext, plaintext []byte) error {
+	decrypted, err := k.Client.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: ciphertext})
+	if err != nil {
+		return fmt.Errorf("decrypting failed: %w", err)
+	}
+	if !bytes.Equal(decrypted.Plaintext, plaintext) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+// KeyID returns the key new data keys are made under for the context's client. It fails
+// rather than fall back to the shared key when the client's settings cannot be read, so a
+// client's data is never sealed under a key it did not choose.
+func (k *Keyring) KeyID(ctx context.Context) (string, error) {
+	clientID, ok := clientconfig.ClientID(ctx)
+	if !ok || k.Clients == nil {
+		return k.DefaultKeyID, nil
+	}
+	if keyID, ok := k.cache.get(clientID); ok {
+		return k.orDefault(keyID), nil
+	}
+	client, err := k.Clients.Load(ctx, clientID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the client's KMS key: %w", err)
+	}
+	k.cache.put(clientID, client.Settings.KMSKeyID)
+	return k.orDefault(client.Settings.KMSKeyID), nil
+}
+
+func (k *Keyring) orDefault(keyID string) string {
+	if keyID == "" {
+		return k.DefaultKeyID
+	}
+	return keyID
+}
+
+// keyCache keeps each client's key setting for a fixed time. It is shared by copies of the Keyring.
+type keyCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]cachedKey
+}
+
+type cachedKey struct {
+	keyID    string
+	loadedAt time.Time
+}
+
+func newKeyCache(ttl time.Duration) *keyCache {
+	return &keyCache{ttl: ttl, now: time.Now, entries: make(map[string]cachedKey)}
+}
+
+func (c *keyCache) get(clientID string) (string, bool) {
+	if c == nil || c.ttl <= 0 {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[clientID]
+	if !ok || c.now().Sub(entry.loadedAt) >= c.ttl {
+		delete(c.entries, clientID)
+		return "", false
+	}
+	return entry.keyID, true
+}
+
+func (c *keyCache) put(clientID, keyID string) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[clientID] = cachedKey{keyID: keyID, loadedAt: c.now()}
+}
@@ -0,0 +1,120 @@
+package keyring
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sharedKey = "arn:aws:kms:us-east-1:111122223333:key/shared"
+	acmeKey   = "arn:aws:kms:us-east-1:444455556666:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+)
+
+// recordingKMS records the keys it is asked to use. It "encrypts" by leaving data as it is.
+type recordingKMS struct {
+	keyIDs []string
+	garble bool // Decrypt to something else, as a key whose material was swapped would
+}
+
+func (r *recordingKMS) GenerateDataKey(ctx context.Context, input *kms.GenerateDataKeyInput, opts ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	r.keyIDs = append(r.keyIDs, aws.ToString(input.KeyId))
+	return &kms.GenerateDataKeyOutput{Plaintext: []byte("key"), CiphertextBlob: []byte("key")}, nil
+}
+
+func (r *recordingKMS) Encrypt(ctx context.Context, input *kms.EncryptInput, opts ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	r.keyIDs = append(r.keyIDs, aws.ToString(input.KeyId))
+	return &kms.EncryptOutput{CiphertextBlob: input.Plaintext}, nil
+}
+
+func (r *recordingKMS) Decrypt(ctx context.Context, input *kms.DecryptInput, opts ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	if r.garble {
+		return &kms.DecryptOutput{Plaintext: []byte("garbled")}, nil
+	}
+	return &kms.DecryptOutput{Plaintext: input.CiphertextBlob}, nil
+}
+
+// clientLoader serves client records by ID and counts how often it is asked
+type clientLoader struct {
+	keys  map[string]string
+	err   error
+	calls int
+}
+
+func (l *clientLoader) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	l.calls++
+	client := localModels.Client{ClientID: clientID, Settings: localModels.ClientSettings{KMSKeyID: l.keys[clientID]}}
+	return client, l.err
+}
+
+func TestKeyringUsesClientKey(t *testing.T) {
+	client := &recordingKMS{}
+	loader := &clientLoader{keys: map[string]string{"acme": acmeKey}}
+	keyring := New(client, sharedKey, loader)
+
+	_, _, err := keyring.GenerateDataKey(clientconfig.WithClientID(context.Background(), "acme"))
+	require.NoError(t, err)
+	_, err = keyring.EncryptData(clientconfig.WithClientID(context.Background(), "acme"), []byte("data"))
+	require.NoError(t, err)
+	_, _, err = keyring.GenerateDataKey(clientconfig.WithClientID(context.Background(), "other"))
+	require.NoError(t, err)
+	_, _, err = keyring.GenerateDataKey(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{acmeKey, acmeKey, sharedKey, sharedKey}, client.keyIDs)
+	assert.Equal(t, 2, loader.calls, "each client's settings are read once while cached")
+}
+
+func TestKeyringFailsClosed(t *testing.T) {
+	client := &recordingKMS{}
+	keyring := New(client, sharedKey, &clientLoader{err: errors.New("mongo down")})
+
+	_, _, err := keyring.GenerateDataKey(clientconfig.WithClientID(context.Background(), "acme"))
+	assert.Error(t, err)
+	assert.Empty(t, client.keyIDs, "no data key is made under the shared key instead")
+}
+
+func TestKeyringRereadsExpiredKey(t *testing.T) {
+	loader := &clientLoader{keys: map[string]string{"acme": acmeKey}}
+	keyring := New(&recordingKMS{}, sharedKey, loader)
+	now := time.Now()
+	keyring.cache.now = func() time.Time { return now }
+	ctx := clientconfig.WithClientID(context.Background(), "acme")
+
+	keyID, err := keyring.KeyID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, acmeKey, keyID)
+
+	loader.keys["acme"] = ""
+	now = now.Add(CacheTTL)
+	keyID, err = keyring.KeyID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, sharedKey, keyID, "removing the client's key falls back to the shared key")
+}
+
+func TestCheck(t *testing.T) {
+	client := &recordingKMS{}
+	keyring := New(client, sharedKey, nil)
+	require.NoError(t, keyring.Check(context.Background(), acmeKey))
+	assert.Equal(t, []string{acmeKey, acmeKey}, client.keyIDs)
+
+	client.garble = true
+	assert.ErrorIs(t, keyring.Check(context.Background(), acmeKey), ErrKeyMismatch)
+}
+
+func TestValidKeyARN(t *testing.T) {
+	assert.True(t, ValidKeyARN(acmeKey))
+	assert.True(t, ValidKeyARN("arn:aws:kms:eu-west-1:444455556666:key/mrk-1234abcd12ab34cd56ef1234567890ab"))
+	assert.True(t, ValidKeyARN("arn:aws-us-gov:kms:us-gov-west-1:444455556666:alias/acme"))
+	assert.False(t, ValidKeyARN("1234abcd-12ab-34cd-56ef-1234567890ab"), "bare key IDs resolve in the service's own account")
+	assert.False(t, ValidKeyARN("alias/acme"))
+	assert.False(t, ValidKeyARN("arn:aws:s3:::bucket"))
+}
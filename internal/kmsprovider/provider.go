@@ -0,0 +1,80 @@
+// Package kmsprovider builds the key service that makes and opens data keys, chosen by
+// settings.kms.provider. AWS KMS is the default; deployments that cannot reach it, such as
+// on-prem ones, can use the transit engine of a HashiCorp Vault instead. Either way callers
+// see an interfaces.KMSUploader and encrypt with the same envelope scheme.
+//
+// Data keys made by one provider can only be opened by it, so changing the provider of an
+// environment that already holds data needs every applicant rekeyed first.
+package kmsprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/keyring"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+const (
+	// ProviderAWS makes data keys with AWS KMS, under each client's own key where it has one
+	ProviderAWS = "aws"
+	// ProviderVault makes data keys with a Vault transit key shared by every client
+	ProviderVault = "vault"
+)
+
+// ErrUnknownProvider is returned when settings.kms.provider names no provider
+var ErrUnknownProvider = errors.New("kms.provider must be aws or vault")
+
+// Name returns the configured provider, defaulting to AWS KMS
+func Name(settings config.KMSSettings) (string, error) {
+	switch provider := strings.ToLower(strings.TrimSpace(settings.Provider)); provider {
+	case "", ProviderAWS:
+		return ProviderAWS, nil
+	case ProviderVault:
+		return ProviderVault, nil
+	default:
+		return "", fmt.Errorf("%w, not %q", ErrUnknownProvider, settings.Provider)
+	}
+}
+
+// New connects to the configured provider. With AWS KMS the result is a *keyring.Keyring,
+// which makes data keys under the KMS key in the settings of the client work is for; clients
+// may be nil to use the shared key for everything. Vault has no per-client keys.
+func New(cfg models.Config, settings config.KMSSettings, clients clientconfig.Loader) (interfaces.KMSUploader, error) {
+	provider, err := Name(settings)
+	if err != nil {
+		return nil, err
+	}
+	if provider == ProviderVault {
+		return NewTransit(settings.Vault)
+	}
+	shared, err := utils.NewKMSUploader(cfg.AWS.Region, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, cfg.AWS.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return keyring.New(shared.Client, cfg.AWS.KeyID, clients), nil
+}
+
+// Check makes a data key with the configured provider, which needs the key to exist and the
+// service's credentials to be allowed to use it
+func Check(ctx context.Context, cfg models.Config, settings config.KMSSettings) error {
+	provider, err := Name(settings)
+	if err != nil {
+		return err
+	}
+	if provider == ProviderAWS && cfg.AWS.KeyID == "" {
+		return errors.New("no KMS key configured")
+	}
+	uploader, err := New(cfg, settings, nil)
+	if err != nil {
+		return err
+	}
+	_, _, err = uploader.GenerateDataKey(ctx)
+	return err
+}
@@ -0,0 +1,19 @@
+package kmsprovider
+
+import (
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestName(t *testing.T) {
+	for provider, want := range map[string]string{"": ProviderAWS, "aws": ProviderAWS, " Vault ": ProviderVault} {
+		got, err := Name(config.KMSSettings{Provider: provider})
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := Name(config.KMSSettings{Provider: "gcp"})
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
@@ -0,0 +1,178 @@
+package kmsprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/egress"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultVaultMount   = "transit"
+	defaultVaultTimeout = 10 * time.Second
+	// vaultPrefix starts every ciphertext the transit engine returns, e.g. vault:v1:...
+	vaultPrefix = "vault:"
+)
+
+var (
+	// ErrVaultUnavailable is returned when Vault could not be reached or refused a call
+	ErrVaultUnavailable = errors.New("vault is unavailable")
+	// ErrNotVaultCiphertext is returned when asked to decrypt data the transit engine did not encrypt
+	ErrNotVaultCiphertext = errors.New("data was not encrypted by Vault")
+)
+
+// Transit makes and opens data keys with a key of Vault's transit engine. Encrypted data
+// keys are the transit ciphertexts, which name the key version they were made with, so
+// they can still be opened after the key is rotated.
+//
+// The token needs "update" on <mount>/datakey/plaintext/<key>, <mount>/encrypt/<key> and
+// <mount>/decrypt/<key>.
+type Transit struct {
+	Address    string
+	Token      string
+	Namespace  string
+	Mount      string
+	KeyName    string
+	HTTPClient *http.Client
+}
+
+// NewTransit creates a client for the configured transit key
+func NewTransit(settings config.VaultSettings) (*Transit, error) {
+	if settings.Address == "" {
+		return nil, errors.New("kms.vault.address is required")
+	}
+	if settings.KeyName == "" {
+		return nil, errors.New("kms.vault.keyName is required")
+	}
+	address, err := url.Parse(settings.Address)
+	if err != nil || (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+		return nil, fmt.Errorf("kms.vault.address must be an http or https URL, not %q", settings.Address)
+	}
+	token := settings.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		return nil, errors.New("a Vault token is required in kms.vault.token or VAULT_TOKEN")
+	}
+	mount := strings.Trim(settings.Mount, "/")
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	timeout := settings.Timeout
+	if timeout <= 0 {
+		timeout = defaultVaultTimeout
+	}
+	return &Transit{
+		Address:    strings.TrimSuffix(settings.Address, "/"),
+		Token:      token,
+		Namespace:  settings.Namespace,
+		Mount:      mount,
+		KeyName:    settings.KeyName,
+		HTTPClient: egress.Client(timeout),
+	}, nil
+}
+
+// GenerateDataKey returns a new 256-bit data key in plaintext and encrypted under the transit key
+func (t *Transit) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	var out struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := t.call(ctx, "datakey/plaintext", map[string]any{"bits": 256}, &out); err != nil {
+		zaplogger.GetLogger().Error("failed to generate data key", zap.Error(err), zap.String("vaultKey", t.KeyName))
+		return nil, nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(out.Plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: could not decode data key: %v", ErrVaultUnavailable, err)
+	}
+	return plaintext, []byte(out.Ciphertext), nil
+}
+
+// EncryptData encrypts data under the transit key
+func (t *Transit) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	var out struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := t.call(ctx, "encrypt", map[string]any{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}, &out); err != nil {
+		zaplogger.GetLogger().Error("failed to encrypt data", zap.Error(err), zap.String("vaultKey", t.KeyName))
+		return nil, err
+	}
+	return []byte(out.Ciphertext), nil
+}
+
+// DecryptData decrypts data encrypted under any version of the transit key
+func (t *Transit) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	if !bytes.HasPrefix(encrypted, []byte(vaultPrefix)) {
+		return nil, ErrNotVaultCiphertext
+	}
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := t.call(ctx, "decrypt", map[string]any{"ciphertext": string(encrypted)}, &out); err != nil {
+		zaplogger.GetLogger().Error("failed to decrypt data", zap.Error(err), zap.String("vaultKey", t.KeyName))
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(out.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode plaintext: %v", ErrVaultUnavailable, err)
+	}
+	return plaintext, nil
+}
+
+// call posts to an operation of the transit key and decodes the data of the response into out
+func (t *Transit) call(ctx context.Context, operation string, in map[string]any, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", t.Address, t.Mount, operation, url.PathEscape(t.KeyName))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid Vault URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", t.Token)
+	if t.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", t.Namespace)
+	}
+
+	resp, err := t.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVaultUnavailable, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: could not read response: %v", ErrVaultUnavailable, err)
+	}
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_ = json.Unmarshal(raw, &envelope)
+		if len(envelope.Errors) > 0 {
+			return fmt.Errorf("%w: responded with status %d: %s", ErrVaultUnavailable, resp.StatusCode, strings.Join(envelope.Errors, "; "))
+		}
+		return fmt.Errorf("%w: responded with status %d", ErrVaultUnavailable, resp.StatusCode)
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil || len(envelope.Data) == 0 {
+		return fmt.Errorf("%w: could not decode response", ErrVaultUnavailable)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
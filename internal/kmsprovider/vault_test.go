@@ -0,0 +1,95 @@
+package kmsprovider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransit answers transit calls for key "verus", "encrypting" by prefixing the plaintext
+func fakeTransit(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var in map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		var data map[string]any
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/verus":
+			key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+			data = map[string]any{"plaintext": key, "ciphertext": "vault:v1:" + key}
+		case "/v1/transit/encrypt/verus":
+			data = map[string]any{"ciphertext": "vault:v1:" + in["plaintext"].(string)}
+		case "/v1/transit/decrypt/verus":
+			data = map[string]any{"plaintext": strings.TrimPrefix(in["ciphertext"].(string), "vault:v1:")}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+}
+
+func TestTransit(t *testing.T) {
+	server := fakeTransit(t)
+	defer server.Close()
+	transit, err := NewTransit(config.VaultSettings{Address: server.URL, Token: "s.token", KeyName: "verus"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	plaintext, encrypted, err := transit.GenerateDataKey(ctx)
+	require.NoError(t, err)
+	assert.Len(t, plaintext, 32)
+	assert.True(t, strings.HasPrefix(string(encrypted), "vault:v1:"))
+	opened, err := transit.DecryptData(ctx, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+
+	sealed, err := transit.EncryptData(ctx, []byte("data key"))
+	require.NoError(t, err)
+	opened, err = transit.DecryptData(ctx, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data key"), opened)
+
+	// Data keys made by AWS KMS are never sent to Vault
+	_, err = transit.DecryptData(ctx, []byte{0x01, 0x02, 0x03})
+	assert.ErrorIs(t, err, ErrNotVaultCiphertext)
+}
+
+func TestTransitRefused(t *testing.T) {
+	server := fakeTransit(t)
+	defer server.Close()
+	transit, err := NewTransit(config.VaultSettings{Address: server.URL, Token: "s.wrong", KeyName: "verus"})
+	require.NoError(t, err)
+
+	_, _, err = transit.GenerateDataKey(context.Background())
+	assert.ErrorIs(t, err, ErrVaultUnavailable)
+	assert.Contains(t, err.Error(), "permission denied")
+}
+
+func TestNewTransitValidates(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "")
+	_, err := NewTransit(config.VaultSettings{Address: "vault:8200", Token: "s.token", KeyName: "verus"})
+	assert.Error(t, err)
+	_, err = NewTransit(config.VaultSettings{Address: "https://vault:8200", KeyName: "verus"})
+	assert.Error(t, err)
+
+	t.Setenv("VAULT_TOKEN", "s.env")
+	transit, err := NewTransit(config.VaultSettings{Address: "https://vault:8200/", KeyName: "verus"})
+	require.NoError(t, err)
+	assert.Equal(t, "s.env", transit.Token)
+	assert.Equal(t, "transit", transit.Mount)
+	assert.Equal(t, "https://vault:8200", transit.Address)
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/country"
+	"github.com/rachel-lawrie/verus_app_backend/internal/levels"
+)
+
+// ListLevels is the handler function for describing the verification levels the client may
+// create applicants at. The country query parameter narrows them to those serving
+// applicants from a country, and currency to those with a price in a currency.
+func ListLevels(c *gin.Context, catalog *levels.Catalog) {
+	var query levels.Query
+	if param := c.Query("country"); param != "" {
+		code, ok := country.Normalize(param)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "country must be an ISO 3166-1 country code"})
+			return
+		}
+		query.Country = code
+	}
+	if param := c.Query("currency"); param != "" {
+		query.Currency = strings.ToUpper(param)
+		if !levels.ValidCurrency(query.Currency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be an ISO 4217 currency code"})
+			return
+		}
+	}
+
+	client, err := clientconfig.FromContext(c)
+	if err != nil {
+		log.Printf("ListLevels: Error loading client settings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list verification levels"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"levels": catalog.For(client, query)})
+}
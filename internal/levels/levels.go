@@ -0,0 +1,273 @@
+// Package levels describes the verification levels applicants can be created at: the
+// documents and checks each requires, the countries it serves, how long a decision takes
+// and what it costs. Levels are declared in settings.levels; the countries a level serves
+// a client are narrowed to those of the vendor the client's vendor selection rule names
+// for it.
+//
+// Applicants are checked against the catalog set with SetCatalog, so they can only be
+// created at, or moved to, a level that exists.
+package levels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/country"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/vendor"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+const (
+	// defaultSLA is the decision target of levels without one when reports do not set it either
+	defaultSLA = 24 * time.Hour
+	// CheckSecondReview is listed among the checks of levels in decisions.dualControlLevels
+	CheckSecondReview = "second_review"
+)
+
+var (
+	// ErrUnknownLevel is returned for a level that is not in the catalog
+	ErrUnknownLevel = errors.New("unknown verification level")
+
+	currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+	amount       = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+)
+
+// ValidCurrency reports whether code is an ISO 4217 currency code in uppercase, such as EUR
+func ValidCurrency(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+// Price is the fee for each applicant verified at a level
+type Price struct {
+	Currency string `json:"currency"` // ISO 4217
+	Amount   string `json:"amount"`   // A decimal, such as "1.50"
+}
+
+// Level is a verification level as it is described to a client
+type Level struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description,omitempty"`
+	DocumentTypes []string `json:"document_types"`
+	Checks        []string `json:"checks"`
+	Countries     []string `json:"countries"` // ISO 3166-1 alpha-3; empty means every country
+	SLASeconds    int64    `json:"sla_seconds"`
+	Prices        []Price  `json:"prices"`
+}
+
+// serves reports whether the level serves applicants from a country in alpha-3
+func (l Level) serves(code string) bool {
+	if len(l.Countries) == 0 {
+		return true
+	}
+	for _, c := range l.Countries {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// VendorLookup tells which vendor a client uses for a level, such as vendor.Registry
+type VendorLookup interface {
+	Vendor(clientID, level string) (vendor.Provider, error)
+}
+
+// Catalog holds the configured levels
+type Catalog struct {
+	levels []Level // By name
+	// Vendors narrows the countries of each level to those of the client's vendor for it; nil leaves them as configured
+	Vendors VendorLookup
+}
+
+// New builds the catalog of settings.levels, rejecting document types, countries and
+// prices that are not valid
+func New(settings config.Settings) (*Catalog, error) {
+	sla := settings.Reports.Compliance.DecisionTarget
+	if sla <= 0 {
+		sla = defaultSLA
+	}
+	dualControl := map[string]bool{}
+	for _, name := range settings.Decisions.DualControlLevels {
+		dualControl[strings.ToLower(name)] = true
+	}
+
+	catalog := &Catalog{levels: make([]Level, 0, len(settings.Levels))}
+	for name, ls := range settings.Levels {
+		level := Level{
+			Name:          name,
+			Description:   ls.Description,
+			DocumentTypes: []string{},
+			Checks:        append([]string{}, ls.Checks...),
+			Countries:     []string{},
+			SLASeconds:    int64(sla.Seconds()),
+			Prices:        []Price{},
+		}
+		if ls.SLA > 0 {
+			level.SLASeconds = int64(ls.SLA.Seconds())
+		}
+		if dualControl[strings.ToLower(name)] {
+			level.Checks = append(level.Checks, CheckSecondReview)
+		}
+		for _, t := range ls.DocumentTypes {
+			docType, err := models.ParseDocumentType(t)
+			if err != nil {
+				return nil, fmt.Errorf("level %s: invalid document type %q", name, t)
+			}
+			level.DocumentTypes = append(level.DocumentTypes, docType.String())
+		}
+		for _, c := range ls.Countries {
+			code, ok := country.Normalize(c)
+			if !ok {
+				return nil, fmt.Errorf("level %s: invalid country %q", name, c)
+			}
+			level.Countries = append(level.Countries, code)
+		}
+		for currency, price := range ls.Prices {
+			// Settings keys arrive in lowercase
+			currency = strings.ToUpper(currency)
+			if !ValidCurrency(currency) {
+				return nil, fmt.Errorf("level %s: invalid currency %q", name, currency)
+			}
+			if !amount.MatchString(price) {
+				return nil, fmt.Errorf("level %s: price in %s must be a decimal such as 1.50, not %q", name, currency, price)
+			}
+			level.Prices = append(level.Prices, Price{Currency: currency, Amount: price})
+		}
+		sort.Slice(level.Prices, func(i, j int) bool { return level.Prices[i].Currency < level.Prices[j].Currency })
+		catalog.levels = append(catalog.levels, level)
+	}
+	sort.Slice(catalog.levels, func(i, j int) bool { return catalog.levels[i].Name < catalog.levels[j].Name })
+	return catalog, nil
+}
+
+// Query narrows the levels listed to a client
+type Query struct {
+	Country  string // Only levels serving applicants from this country, in alpha-3
+	Currency string // Only levels with a price in this currency, with that price alone
+}
+
+// For returns the levels the client may verify applicants at, with the countries of the
+// vendor it uses for each. Levels none of whose countries the vendor supports are left out.
+func (c *Catalog) For(client localModels.Client, query Query) []Level {
+	levels := []Level{}
+	for _, level := range c.levels {
+		if !clientconfig.AllowsLevel(client, level.Name) {
+			continue
+		}
+		level, ok := c.withVendor(client.ClientID, level)
+		if !ok || (query.Country != "" && !level.serves(query.Country)) {
+			continue
+		}
+		if query.Currency != "" {
+			var priced []Price
+			for _, price := range level.Prices {
+				if price.Currency == query.Currency {
+					priced = append(priced, price)
+				}
+			}
+			if len(priced) == 0 {
+				continue
+			}
+			level.Prices = priced
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// withVendor narrows a level's countries to those of the client's vendor for it, and
+// reports whether any are left
+func (c *Catalog) withVendor(clientID string, level Level) (Level, bool) {
+	if c.Vendors == nil {
+		return level, true
+	}
+	provider, err := c.Vendors.Vendor(clientID, level.Name)
+	if err != nil || len(provider.Countries) == 0 {
+		// A vendor for every country, or none at all, leaves the level's countries as configured
+		return level, true
+	}
+	if len(level.Countries) == 0 {
+		level.Countries = append([]string{}, provider.Countries...)
+		return level, true
+	}
+	var shared []string
+	for _, code := range level.Countries {
+		for _, supported := range provider.Countries {
+			if code == supported {
+				shared = append(shared, code)
+				break
+			}
+		}
+	}
+	level.Countries = shared
+	return level, len(shared) > 0
+}
+
+// Names returns the names of the levels in the catalog
+func (c *Catalog) Names() []string {
+	names := make([]string, len(c.levels))
+	for i, level := range c.levels {
+		names[i] = level.Name
+	}
+	return names
+}
+
+// Known reports whether a level is in the catalog, matching names without regard to case.
+// Any level is known to a catalog without levels.
+func (c *Catalog) Known(name string) bool {
+	_, ok := c.Canonical(name)
+	return ok
+}
+
+// Canonical returns the catalog's spelling of a level, matching names without regard to
+// case, and whether the level is in the catalog. A catalog without levels returns name as
+// it is given.
+func (c *Catalog) Canonical(name string) (string, bool) {
+	if len(c.levels) == 0 {
+		return name, true
+	}
+	for _, level := range c.levels {
+		if strings.EqualFold(level.Name, name) {
+			return level.Name, true
+		}
+	}
+	return "", false
+}
+
+var (
+	mu      sync.RWMutex
+	current = &Catalog{}
+)
+
+// SetCatalog makes catalog the one applicants' levels are checked against
+func SetCatalog(catalog *Catalog) {
+	mu.Lock()
+	current = catalog
+	mu.Unlock()
+}
+
+// Validate returns the catalog's spelling of level, which is what applicants store and
+// client settings are checked against, or an apperr.ErrValidation naming field unless
+// level is in the catalog set with SetCatalog. Any level is accepted as it is given until
+// a catalog with levels is set.
+func Validate(field, level string) (string, error) {
+	mu.RLock()
+	catalog := current
+	mu.RUnlock()
+	if name, ok := catalog.Canonical(level); ok {
+		return name, nil
+	}
+	return "", apperr.Invalid(
+		fmt.Errorf("%w: %q", ErrUnknownLevel, level),
+		map[string]string{field: "must be one of " + strings.Join(catalog.Names(), ", ")},
+	)
+}
@@ -0,0 +1,133 @@
+package levels
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/vendor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var settings = config.Settings{
+	Levels: map[string]config.LevelSettings{
+		"basic-kyc-level": {
+			Description:   "Identity document and selfie",
+			DocumentTypes: []string{"passport", "selfie"},
+			Checks:        []string{"document_authenticity", "face_match"},
+			Prices:        map[string]string{"eur": "1.50", "usd": "1.75"},
+		},
+		"enhanced-kyc-level": {
+			DocumentTypes: []string{"passport", "utility_bill"},
+			Checks:        []string{"document_authenticity", "proof_of_address"},
+			Countries:     []string{"DE", "FRA", "GB"},
+			SLA:           72 * time.Hour,
+			Prices:        map[string]string{"eur": "4.00"},
+		},
+	},
+	Decisions: config.DecisionSettings{DualControlLevels: []string{"enhanced-kyc-level"}},
+}
+
+// vendors uses one vendor for every client and level
+type vendors struct {
+	provider vendor.Provider
+	err      error
+}
+
+func (v vendors) Vendor(clientID, level string) (vendor.Provider, error) {
+	return v.provider, v.err
+}
+
+func TestNew(t *testing.T) {
+	catalog, err := New(settings)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"basic-kyc-level", "enhanced-kyc-level"}, catalog.Names())
+	assert.Equal(t, Level{
+		Name:          "basic-kyc-level",
+		Description:   "Identity document and selfie",
+		DocumentTypes: []string{"PASSPORT", "SELFIE"},
+		Checks:        []string{"document_authenticity", "face_match"},
+		Countries:     []string{},
+		SLASeconds:    24 * 60 * 60,
+		Prices:        []Price{{Currency: "EUR", Amount: "1.50"}, {Currency: "USD", Amount: "1.75"}},
+	}, catalog.levels[0])
+	enhanced := catalog.levels[1]
+	assert.Equal(t, []string{"document_authenticity", "proof_of_address", CheckSecondReview}, enhanced.Checks)
+	assert.Equal(t, []string{"DEU", "FRA", "GBR"}, enhanced.Countries)
+	assert.Equal(t, int64(72*60*60), enhanced.SLASeconds)
+
+	invalid := []config.LevelSettings{
+		{DocumentTypes: []string{"hologram"}},
+		{Countries: []string{"Atlantis"}},
+		{Prices: map[string]string{"euro": "1.50"}},
+		{Prices: map[string]string{"eur": "1,50"}},
+	}
+	for _, ls := range invalid {
+		_, err := New(config.Settings{Levels: map[string]config.LevelSettings{"basic": ls}})
+		assert.Error(t, err, "%+v", ls)
+	}
+}
+
+func TestFor(t *testing.T) {
+	catalog, err := New(settings)
+	require.NoError(t, err)
+	client := localModels.Client{ClientID: "client1"}
+
+	names := func(levels []Level) []string {
+		var out []string
+		for _, level := range levels {
+			out = append(out, level.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"basic-kyc-level", "enhanced-kyc-level"}, names(catalog.For(client, Query{})))
+	assert.Equal(t, []string{"basic-kyc-level"}, names(catalog.For(client, Query{Country: "USA"})))
+	assert.Equal(t, []string{"basic-kyc-level"}, names(catalog.For(client, Query{Currency: "USD"})))
+	assert.Equal(t, []Price{{Currency: "EUR", Amount: "4.00"}}, catalog.For(client, Query{Country: "DEU", Currency: "EUR"})[1].Prices)
+
+	// Clients only see the levels they were onboarded for
+	onboarded := localModels.Client{ClientID: "client1", AllowedVerificationLevels: []string{"enhanced-kyc-level"}}
+	assert.Equal(t, []string{"enhanced-kyc-level"}, names(catalog.For(onboarded, Query{})))
+
+	// Countries are narrowed to those of the client's vendor
+	catalog.Vendors = vendors{provider: vendor.Provider{Name: "sumsub", Countries: []string{"DEU", "USA"}}}
+	levels := catalog.For(client, Query{})
+	assert.Equal(t, []string{"DEU", "USA"}, levels[0].Countries)
+	assert.Equal(t, []string{"DEU"}, levels[1].Countries)
+	catalog.Vendors = vendors{provider: vendor.Provider{Name: "onfido", Countries: []string{"USA"}}}
+	assert.Equal(t, []string{"basic-kyc-level"}, names(catalog.For(client, Query{})))
+	catalog.Vendors = vendors{err: vendor.ErrNoVendor}
+	assert.Equal(t, []string{"DEU", "FRA", "GBR"}, catalog.For(client, Query{})[1].Countries)
+}
+
+func TestValidate(t *testing.T) {
+	t.Cleanup(func() { SetCatalog(&Catalog{}) })
+
+	// Any level is accepted as it is given until levels are configured
+	level, err := Validate("level", "Gold")
+	require.NoError(t, err)
+	assert.Equal(t, "Gold", level)
+
+	catalog, err := New(settings)
+	require.NoError(t, err)
+	SetCatalog(catalog)
+	level, err = Validate("level", "basic-kyc-level")
+	require.NoError(t, err)
+	assert.Equal(t, "basic-kyc-level", level)
+	// Levels are matched without regard to case and given back as the catalog spells them
+	level, err = Validate("level", "Basic-KYC-Level")
+	require.NoError(t, err)
+	assert.Equal(t, "basic-kyc-level", level)
+
+	_, err = Validate("verification_level", "gold")
+	assert.True(t, errors.Is(err, ErrUnknownLevel))
+	var invalid *apperr.ErrValidation
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, map[string]string{"verification_level": "must be one of basic-kyc-level, enhanced-kyc-level"}, invalid.Fields)
+	assert.Equal(t, `unknown verification level: "gold"`, err.Error())
+}
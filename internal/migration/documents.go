@@ -0,0 +1,88 @@
+// Package migration holds one-off data migrations. Each migration can be run
+// again safely, e.g. after it was interrupted or to pick up stragglers written
+// by instances that were still running the previous release.
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+// DocumentMoveResult counts what MoveDocuments did
+type DocumentMoveResult struct {
+	Applicants int // Applicants whose documents array was emptied
+	Documents  int // Documents copied into the documents collection
+	Skipped    int // Applicants left for a later run because their array changed meanwhile
+}
+
+// embeddedDocuments is an applicant record as stored before documents had their own collection
+type embeddedDocuments struct {
+	ApplicantID string                       `bson:"applicant_id"`
+	ClientID    string                       `bson:"client_id"`
+	Documents   []localModels.DocumentRecord `bson:"documents"`
+}
+
+// MoveDocuments copies the documents embedded in applicant records into the documents
+// collection and then removes the embedded array. Copies are keyed on document_id, so
+// documents moved by an earlier run are not duplicated. The array is only removed if it
+// still holds the documents that were copied; otherwise the applicant is left for the
+// next run.
+func MoveDocuments(ctx context.Context, applicants, documents common.CollectionInterface) (DocumentMoveResult, error) {
+	logger := zaplogger.GetLogger()
+	var result DocumentMoveResult
+
+	filter := bson.M{"documents.0": bson.M{"$exists": true}}
+	opts := options.Find().SetProjection(bson.M{"applicant_id": 1, "client_id": 1, "documents": 1})
+	cursor, err := applicants.Find(ctx, filter, opts)
+	if err != nil {
+		return result, fmt.Errorf("failed to find applicants with embedded documents: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var applicant embeddedDocuments
+		if err := cursor.Decode(&applicant); err != nil {
+			return result, fmt.Errorf("failed to decode applicant: %w", err)
+		}
+
+		for _, doc := range applicant.Documents {
+			doc.ApplicantID = applicant.ApplicantID
+			doc.ClientID = applicant.ClientID
+			if err := mongoretry.InsertOnce(ctx, documents, "migrate_document", bson.M{"document_id": doc.DocumentID}, doc); err != nil {
+				return result, fmt.Errorf("failed to copy document %s of applicant %s: %w", doc.DocumentID, applicant.ApplicantID, err)
+			}
+			result.Documents++
+		}
+
+		// A document pushed by an old instance since the read changes the array size
+		var matched int64
+		err := mongoretry.Write(ctx, "migrate_unset_documents", func(ctx context.Context) error {
+			updateResult, err := applicants.UpdateOne(ctx,
+				bson.M{"applicant_id": applicant.ApplicantID, "documents": bson.M{"$size": len(applicant.Documents)}},
+				bson.M{"$unset": bson.M{"documents": ""}},
+			)
+			if err == nil {
+				matched = updateResult.MatchedCount
+			}
+			return err
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to remove embedded documents of applicant %s: %w", applicant.ApplicantID, err)
+		}
+		if matched == 0 {
+			logger.Warn("Embedded documents changed during migration, leaving applicant for the next run", zap.String("applicantID", applicant.ApplicantID))
+			result.Skipped++
+			continue
+		}
+		result.Applicants++
+	}
+	return result, cursor.Err()
+}
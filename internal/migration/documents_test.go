@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	models "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeCollection serves Find from fixed documents and records updates
+type fakeCollection struct {
+	common.CollectionInterface
+	found     []interface{}
+	updates   []bson.M
+	filters   []bson.M
+	unmatched map[string]bool // Applicants whose unset matches nothing
+}
+
+func (f *fakeCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	return mongo.NewCursorFromDocuments(f.found, nil, nil)
+}
+
+func (f *fakeCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	f.filters = append(f.filters, filter.(bson.M))
+	f.updates = append(f.updates, update.(bson.M))
+	if id, ok := filter.(bson.M)["applicant_id"].(string); ok && f.unmatched[id] {
+		return &mongo.UpdateResult{}, nil
+	}
+	return &mongo.UpdateResult{MatchedCount: 1}, nil
+}
+
+func TestMoveDocuments(t *testing.T) {
+	applicants := &fakeCollection{
+		found: []interface{}{
+			bson.M{"applicant_id": "app1", "client_id": "client1", "documents": bson.A{
+				bson.M{"document_id": "doc1"},
+				bson.M{"document_id": "doc2"},
+			}},
+			bson.M{"applicant_id": "app2", "client_id": "client2", "documents": bson.A{
+				bson.M{"document_id": "doc3"},
+			}},
+		},
+		unmatched: map[string]bool{"app2": true},
+	}
+	documents := &fakeCollection{}
+
+	result, err := MoveDocuments(context.Background(), applicants, documents)
+	assert.NoError(t, err)
+	assert.Equal(t, DocumentMoveResult{Applicants: 1, Documents: 3, Skipped: 1}, result)
+
+	// Each document is copied once, keyed on its ID and carrying its applicant and client
+	assert.Len(t, documents.updates, 3)
+	assert.Equal(t, bson.M{"document_id": "doc1"}, documents.filters[0])
+	copied := documents.updates[0]["$setOnInsert"].(localModels.DocumentRecord)
+	assert.Equal(t, localModels.DocumentRecord{Document: models.Document{DocumentID: "doc1", ApplicantID: "app1"}, ClientID: "client1"}, copied)
+
+	// The array is only removed if it still holds what was copied
+	assert.Equal(t, bson.M{"applicant_id": "app1", "documents": bson.M{"$size": 2}}, applicants.filters[0])
+	assert.Equal(t, bson.M{"$unset": bson.M{"documents": ""}}, applicants.updates[0])
+}
@@ -0,0 +1,50 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ManyUpdater updates every record matching a filter, as a *mongo.Collection does
+type ManyUpdater interface {
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+}
+
+// statuses are the applicant statuses earlier releases stored as strings
+var statuses = []localModels.ApplicantStatus{
+	localModels.ApplicantPending, localModels.ApplicantInReview, localModels.ApplicantResubmissionRequired,
+	localModels.ApplicantApproved, localModels.ApplicantRejected,
+}
+
+// ApplicantStatuses rewrites applicant statuses stored as strings, such as "approved", as
+// the ints the core package stores in the same field, so filters on status match every
+// applicant. It returns the number of applicants rewritten.
+func ApplicantStatuses(ctx context.Context, applicants ManyUpdater) (int64, error) {
+	var rewritten int64
+	for _, status := range statuses {
+		core, err := status.Core()
+		if err != nil {
+			return rewritten, err
+		}
+		err = mongoretry.Write(ctx, "migrate_applicant_status", func(ctx context.Context) error {
+			result, err := applicants.UpdateMany(ctx,
+				bson.M{"status": string(status)},
+				bson.M{"$set": bson.M{"status": int32(core)}},
+			)
+			if err == nil {
+				rewritten += result.ModifiedCount
+			}
+			return err
+		})
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to rewrite %s applicant statuses: %w", status, err)
+		}
+	}
+	return rewritten, nil
+}
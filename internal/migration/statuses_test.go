@@ -0,0 +1,43 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// statusCollection records UpdateMany calls and modifies one applicant for each
+type statusCollection struct {
+	filters []bson.M
+	updates []bson.M
+}
+
+func (f *statusCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	f.filters = append(f.filters, filter.(bson.M))
+	f.updates = append(f.updates, update.(bson.M))
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func TestApplicantStatuses(t *testing.T) {
+	applicants := &statusCollection{}
+
+	rewritten, err := ApplicantStatuses(context.Background(), applicants)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), rewritten)
+	require.Len(t, applicants.filters, 5)
+
+	for i, filter := range applicants.filters {
+		if filter["status"] == string(localModels.ApplicantApproved) {
+			assert.Equal(t, bson.M{"$set": bson.M{"status": int32(2)}}, applicants.updates[i], "approved is core's verified")
+		}
+		if filter["status"] == string(localModels.ApplicantResubmissionRequired) {
+			assert.Equal(t, bson.M{"$set": bson.M{"status": int32(4)}}, applicants.updates[i])
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockApplicantService mocks the applicant service
+type MockApplicantService struct {
+	mock.Mock
+}
+
+func (m *MockApplicantService) CreateApplicant(c *gin.Context, applicant *localModels.ApplicantRecord, addressCountry string) (localModels.ApplicantRecord, error) {
+	args := m.Called(c, applicant, addressCountry)
+	return args.Get(0).(localModels.ApplicantRecord), args.Error(1)
+}
+
+func (m *MockApplicantService) GetAllApplicants(c *gin.Context, filter localModels.ApplicantFilter) ([]localModels.ApplicantRecord, error) {
+	args := m.Called(c, filter)
+	return args.Get(0).([]localModels.ApplicantRecord), args.Error(1)
+}
+
+func (m *MockApplicantService) GetApplicant(c *gin.Context, applicantID string) (localModels.ApplicantRecord, error) {
+	args := m.Called(c, applicantID)
+	return args.Get(0).(localModels.ApplicantRecord), args.Error(1)
+}
+
+func (m *MockApplicantService) UpdateApplicant(c *gin.Context, applicantID string, updates map[string]interface{}) (localModels.ApplicantRecord, error) {
+	args := m.Called(c, applicantID, updates)
+	return args.Get(0).(localModels.ApplicantRecord), args.Error(1)
+}
+
+func (m *MockApplicantService) SelectApplicants(c *gin.Context, filter localModels.ApplicantFilter, selection localModels.ApplicantSelection) ([]map[string]interface{}, error) {
+	args := m.Called(c, filter, selection)
+	return args.Get(0).([]map[string]interface{}), args.Error(1)
+}
+
+func (m *MockApplicantService) SelectApplicant(c *gin.Context, applicantID string, selection localModels.ApplicantSelection) (map[string]interface{}, error) {
+	args := m.Called(c, applicantID, selection)
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
+
+func (m *MockApplicantService) UpdateAnnotations(c *gin.Context, applicantID string, patch localModels.AnnotationsPatch) (localModels.Annotations, error) {
+	args := m.Called(c, applicantID, patch)
+	return args.Get(0).(localModels.Annotations), args.Error(1)
+}
+
+func (m *MockApplicantService) PatchApplicant(c *gin.Context, applicantID string, patch localModels.ApplicantPatch) (localModels.ApplicantRecord, error) {
+	args := m.Called(c, applicantID, patch)
+	return args.Get(0).(localModels.ApplicantRecord), args.Error(1)
+}
+
+func (m *MockApplicantService) RestoreApplicant(c *gin.Context, applicantID string) (localModels.ApplicantRecord, error) {
+	args := m.Called(c, applicantID)
+	return args.Get(0).(localModels.ApplicantRecord), args.Error(1)
+}
+
+func (m *MockApplicantService) ListDeletedApplicants(ctx context.Context, scope tenant.Filter, page pagination.Page) ([]localModels.DeletedApplicant, error) {
+	args := m.Called(ctx, scope, page)
+	return args.Get(0).([]localModels.DeletedApplicant), args.Error(1)
+}
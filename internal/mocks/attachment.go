@@ -0,0 +1,37 @@
+package mocks
+
+import (
+	"io"
+	"mime/multipart"
+
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAttachmentService mocks the applicant attachment service
+type MockAttachmentService struct {
+	mock.Mock
+}
+
+func (m *MockAttachmentService) AddAttachment(c *gin.Context, scope tenant.Filter, attachment localModels.Attachment, file multipart.File, header *multipart.FileHeader) (localModels.Attachment, error) {
+	args := m.Called(c, scope, attachment, file, header)
+	return args.Get(0).(localModels.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentService) ListAttachments(c *gin.Context, applicantID string, scope tenant.Filter) ([]localModels.Attachment, error) {
+	args := m.Called(c, applicantID, scope)
+	return args.Get(0).([]localModels.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentService) OpenAttachment(c *gin.Context, applicantID, attachmentID string, scope tenant.Filter) (localModels.Attachment, io.ReadCloser, error) {
+	args := m.Called(c, applicantID, attachmentID, scope)
+	body, _ := args.Get(1).(io.ReadCloser)
+	return args.Get(0).(localModels.Attachment), body, args.Error(2)
+}
+
+func (m *MockAttachmentService) DeleteAttachment(c *gin.Context, applicantID, attachmentID, deletedBy string) error {
+	args := m.Called(c, applicantID, attachmentID, deletedBy)
+	return args.Error(0)
+}
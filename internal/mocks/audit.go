@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAuditService mocks the audit log service
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) Record(ctx context.Context, event localModels.AuditEvent) (localModels.AuditEvent, error) {
+	args := m.Called(ctx, event)
+	return args.Get(0).(localModels.AuditEvent), args.Error(1)
+}
+
+func (m *MockAuditService) Export(ctx context.Context, from, to time.Time) (localModels.AuditExport, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).(localModels.AuditExport), args.Error(1)
+}
+
+func (m *MockAuditService) DocumentAccessLog(ctx context.Context, documentID string) (localModels.DocumentAccessLog, error) {
+	args := m.Called(ctx, documentID)
+	return args.Get(0).(localModels.DocumentAccessLog), args.Error(1)
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBackupService mocks the client snapshot service
+type MockBackupService struct {
+	mock.Mock
+}
+
+func (m *MockBackupService) Snapshot(ctx context.Context, clientID, takenBy string) (localModels.Backup, error) {
+	args := m.Called(ctx, clientID, takenBy)
+	return args.Get(0).(localModels.Backup), args.Error(1)
+}
+
+func (m *MockBackupService) ListBackups(ctx context.Context, clientID string, limit int64) ([]localModels.Backup, error) {
+	args := m.Called(ctx, clientID, limit)
+	return args.Get(0).([]localModels.Backup), args.Error(1)
+}
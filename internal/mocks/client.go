@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockClientService mocks the client management service
+type MockClientService struct {
+	mock.Mock
+}
+
+func (m *MockClientService) RegisterClient(ctx context.Context, client localModels.Client, adminID string) (localModels.ClientRegistration, error) {
+	args := m.Called(ctx, client, adminID)
+	return args.Get(0).(localModels.ClientRegistration), args.Error(1)
+}
+
+func (m *MockClientService) GetClient(ctx context.Context, clientID string) (localModels.Client, error) {
+	args := m.Called(ctx, clientID)
+	return args.Get(0).(localModels.Client), args.Error(1)
+}
+
+func (m *MockClientService) UpdateClient(ctx context.Context, client localModels.Client) (localModels.Client, error) {
+	args := m.Called(ctx, client)
+	return args.Get(0).(localModels.Client), args.Error(1)
+}
+
+func (m *MockClientService) SetIPAllowlist(ctx context.Context, clientID string, cidrs []string, updatedBy string) (localModels.IPAllowlist, error) {
+	args := m.Called(ctx, clientID, cidrs, updatedBy)
+	return args.Get(0).(localModels.IPAllowlist), args.Error(1)
+}
+
+func (m *MockClientService) SetIPAllowlistBypass(ctx context.Context, clientID string, bypass bool, adminID string) (localModels.IPAllowlist, error) {
+	args := m.Called(ctx, clientID, bypass, adminID)
+	return args.Get(0).(localModels.IPAllowlist), args.Error(1)
+}
@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDecisionService mocks the manual verification decision service
+type MockDecisionService struct {
+	mock.Mock
+}
+
+func (m *MockDecisionService) ProposeDecision(c *gin.Context, applicantID, reviewerID string, decision localModels.ReviewDecision, reasonCode, comment string) (localModels.Decision, error) {
+	args := m.Called(c, applicantID, reviewerID, decision, reasonCode, comment)
+	return args.Get(0).(localModels.Decision), args.Error(1)
+}
+
+func (m *MockDecisionService) ConfirmDecision(c *gin.Context, decisionID, reviewerID, comment string) (localModels.Decision, error) {
+	args := m.Called(c, decisionID, reviewerID, comment)
+	return args.Get(0).(localModels.Decision), args.Error(1)
+}
+
+func (m *MockDecisionService) DeclineDecision(c *gin.Context, decisionID, reviewerID, comment string) (localModels.Decision, error) {
+	args := m.Called(c, decisionID, reviewerID, comment)
+	return args.Get(0).(localModels.Decision), args.Error(1)
+}
+
+func (m *MockDecisionService) GetApplicantDecisions(c *gin.Context, applicantID string) ([]localModels.Decision, error) {
+	args := m.Called(c, applicantID)
+	return args.Get(0).([]localModels.Decision), args.Error(1)
+}
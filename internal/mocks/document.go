@@ -1,9 +1,12 @@
 package mocks
 
 import (
+	"context"
 	"fmt"
+	"io"
 
 	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
 	"github.com/rachel-lawrie/verus_backend_core/common"
 	"github.com/rachel-lawrie/verus_backend_core/interfaces"
 	"github.com/rachel-lawrie/verus_backend_core/models"
@@ -16,22 +19,27 @@ type MockDocumentService struct {
 	Uploader interfaces.Uploader
 }
 
-func (m *MockDocumentService) UploadDocument(c *gin.Context, collection common.CollectionInterface) (models.Document, error) {
+func (m *MockDocumentService) UploadDocument(c *gin.Context, collection common.CollectionInterface) (localModels.UploadResult, error) {
 	// Mock the behavior here
 	r := c.Request
 	// Parse the form data (including file)
 	err := r.ParseMultipartForm(10 << 20) // 10MB max file size
 	if err != nil {
-		return models.Document{}, fmt.Errorf("unable to parse form data: %v", err)
+		return localModels.UploadResult{}, fmt.Errorf("unable to parse form data: %v", err)
 	}
 	// Get the file from the request
 	applicant_id := r.FormValue("applicant_id")
 	document_type := r.FormValue("document_type")
 	documentType, _ := models.ParseDocumentType(document_type)
-	return models.Document{
-		ApplicantID:  applicant_id,
-		DocumentType: documentType,
-		Status:       models.DocumentUploaded,
+	return localModels.UploadResult{
+		DocumentRecord: localModels.DocumentRecord{
+			Document: models.Document{
+				ApplicantID:  applicant_id,
+				DocumentType: documentType,
+				Status:       models.DocumentUploaded,
+			},
+		},
+		ProcessingStatus: localModels.ProcessingAccepted,
 	}, nil
 }
 
@@ -50,3 +58,79 @@ func (m *MockDocumentService) DownloadDocument(c *gin.Context, docID string, app
 	args := m.Called(c, m.Uploader, collection)
 	return args.String(0), args.Error(1)
 }
+
+func (m *MockDocumentService) ReplaceDocument(c *gin.Context, clientID, docID string, collection common.CollectionInterface) (localModels.UploadResult, error) {
+	args := m.Called(c, clientID, docID, collection)
+	return args.Get(0).(localModels.UploadResult), args.Error(1)
+}
+
+func (m *MockDocumentService) GetDocumentVersions(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.DocumentHistory, error) {
+	args := m.Called(c, clientID, applicantID, docID, collection)
+	return args.Get(0).(localModels.DocumentHistory), args.Error(1)
+}
+
+func (m *MockDocumentService) OpenDocumentVersion(c *gin.Context, applicantID, docID string, version int, download localModels.DocumentDownload, collection common.CollectionInterface) (localModels.DocumentVersion, io.ReadCloser, error) {
+	args := m.Called(c, applicantID, docID, version, download, collection)
+	body, _ := args.Get(1).(io.ReadCloser)
+	return args.Get(0).(localModels.DocumentVersion), body, args.Error(2)
+}
+
+func (m *MockDocumentService) PreviewDocument(c *gin.Context, clientID, applicantID, docID string, request localModels.PreviewRequest, collection common.CollectionInterface) (localModels.DocumentPreview, error) {
+	args := m.Called(c, clientID, applicantID, docID, request, collection)
+	return args.Get(0).(localModels.DocumentPreview), args.Error(1)
+}
+
+func (m *MockDocumentService) PresignUpload(c *gin.Context, clientID string, request localModels.DirectUploadRequest) (localModels.DirectUpload, error) {
+	args := m.Called(c, clientID, request)
+	return args.Get(0).(localModels.DirectUpload), args.Error(1)
+}
+
+func (m *MockDocumentService) CompleteUpload(c *gin.Context, clientID, applicantID string, completion localModels.DirectUploadCompletion, collection common.CollectionInterface) (localModels.UploadResult, error) {
+	args := m.Called(c, clientID, applicantID, completion, collection)
+	return args.Get(0).(localModels.UploadResult), args.Error(1)
+}
+
+func (m *MockDocumentService) RestoreDocument(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.ColdStorage, error) {
+	args := m.Called(c, clientID, applicantID, docID, collection)
+	return args.Get(0).(localModels.ColdStorage), args.Error(1)
+}
+
+func (m *MockDocumentService) GetDocumentRestore(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (localModels.ColdStorage, error) {
+	args := m.Called(c, clientID, applicantID, docID, collection)
+	return args.Get(0).(localModels.ColdStorage), args.Error(1)
+}
+
+func (m *MockDocumentService) UndeleteDocument(c *gin.Context, clientID, applicantID, docID string, collection common.CollectionInterface) (models.Document, error) {
+	args := m.Called(c, clientID, applicantID, docID, collection)
+	return args.Get(0).(models.Document), args.Error(1)
+}
+
+func (m *MockDocumentService) ListArchiveDocuments(c *gin.Context, clientID, applicantID string, collection common.CollectionInterface) ([]localModels.DocumentRecord, error) {
+	args := m.Called(c, clientID, applicantID, collection)
+	documents, _ := args.Get(0).([]localModels.DocumentRecord)
+	return documents, args.Error(1)
+}
+
+func (m *MockDocumentService) WriteDocumentArchive(ctx context.Context, documents []localModels.DocumentRecord, download localModels.DocumentDownload, w io.Writer) ([]localModels.DocumentArchiveEntry, error) {
+	args := m.Called(ctx, documents, download, w)
+	if content, ok := args.Get(0).(string); ok {
+		io.WriteString(w, content)
+	}
+	entries, _ := args.Get(1).([]localModels.DocumentArchiveEntry)
+	return entries, args.Error(2)
+}
+
+func (m *MockDocumentService) GetUploadJob(ctx context.Context, clientID, jobID string) (localModels.UploadJob, error) {
+	args := m.Called(ctx, clientID, jobID)
+	return args.Get(0).(localModels.UploadJob), args.Error(1)
+}
+
+func (m *MockDocumentService) CreateApplicantFromDocument(c *gin.Context, level string, consent *localModels.Consent, collection common.CollectionInterface) (localModels.DocumentIntake, error) {
+	args := m.Called(c, level, consent, collection)
+	return args.Get(0).(localModels.DocumentIntake), args.Error(1)
+}
+
+func (m *MockDocumentService) ConfirmApplicant(c *gin.Context, clientID, applicantID string, confirmation localModels.ApplicantConfirmation) (localModels.ApplicantRecord, error) {
+	args := m.Called(c, clientID, applicantID, confirmation)
+	return args.Get(0).(localModels.ApplicantRecord), args.Error(1)
+}
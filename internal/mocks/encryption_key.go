@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockEncryptionKeyService mocks the service managing clients' own KMS keys
+type MockEncryptionKeyService struct {
+	mock.Mock
+}
+
+func (m *MockEncryptionKeyService) RegisterKey(ctx context.Context, clientID, keyARN string) (localModels.EncryptionKey, error) {
+	args := m.Called(ctx, clientID, keyARN)
+	return args.Get(0).(localModels.EncryptionKey), args.Error(1)
+}
+
+func (m *MockEncryptionKeyService) ListKeys(ctx context.Context, clientID string) ([]localModels.EncryptionKey, error) {
+	args := m.Called(ctx, clientID)
+	return args.Get(0).([]localModels.EncryptionKey), args.Error(1)
+}
+
+func (m *MockEncryptionKeyService) GetKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error) {
+	args := m.Called(ctx, clientID, encryptionKeyID)
+	return args.Get(0).(localModels.EncryptionKey), args.Error(1)
+}
+
+func (m *MockEncryptionKeyService) ValidateKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error) {
+	args := m.Called(ctx, clientID, encryptionKeyID)
+	return args.Get(0).(localModels.EncryptionKey), args.Error(1)
+}
+
+func (m *MockEncryptionKeyService) ActivateKey(ctx context.Context, clientID, encryptionKeyID string) (localModels.EncryptionKey, error) {
+	args := m.Called(ctx, clientID, encryptionKeyID)
+	return args.Get(0).(localModels.EncryptionKey), args.Error(1)
+}
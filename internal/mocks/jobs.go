@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockJobService mocks the scheduled jobs service
+type MockJobService struct {
+	mock.Mock
+}
+
+func (m *MockJobService) ListJobs(ctx context.Context) ([]localModels.JobStatus, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]localModels.JobStatus), args.Error(1)
+}
+
+func (m *MockJobService) ListRuns(ctx context.Context, name string, limit int64) ([]localModels.JobRun, error) {
+	args := m.Called(ctx, name, limit)
+	return args.Get(0).([]localModels.JobRun), args.Error(1)
+}
+
+func (m *MockJobService) Trigger(ctx context.Context, name, triggeredBy string) (localModels.JobRun, error) {
+	args := m.Called(ctx, name, triggeredBy)
+	return args.Get(0).(localModels.JobRun), args.Error(1)
+}
+
+func (m *MockJobService) Pause(ctx context.Context, name, pausedBy string) (localModels.JobStatus, error) {
+	args := m.Called(ctx, name, pausedBy)
+	return args.Get(0).(localModels.JobStatus), args.Error(1)
+}
+
+func (m *MockJobService) Resume(ctx context.Context, name string) (localModels.JobStatus, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(localModels.JobStatus), args.Error(1)
+}
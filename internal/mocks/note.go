@@ -0,0 +1,24 @@
+package mocks
+
+import (
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockNoteService mocks the applicant case note service
+type MockNoteService struct {
+	mock.Mock
+}
+
+func (m *MockNoteService) AddNote(c *gin.Context, scope tenant.Filter, note localModels.Note) (localModels.Note, error) {
+	args := m.Called(c, scope, note)
+	return args.Get(0).(localModels.Note), args.Error(1)
+}
+
+func (m *MockNoteService) ListNotes(c *gin.Context, applicantID string, scope tenant.Filter, page pagination.Page) ([]localModels.Note, error) {
+	args := m.Called(c, applicantID, scope, page)
+	return args.Get(0).([]localModels.Note), args.Error(1)
+}
@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOperationsService mocks the operations dashboard service
+type MockOperationsService struct {
+	mock.Mock
+}
+
+func (m *MockOperationsService) ClientVolumes(ctx context.Context, since time.Time) ([]localModels.ClientVolume, error) {
+	args := m.Called(ctx, since)
+	return args.Get(0).([]localModels.ClientVolume), args.Error(1)
+}
+
+func (m *MockOperationsService) WebhookDeliveries(ctx context.Context, since time.Time) ([]localModels.WebhookDeliveryStats, error) {
+	args := m.Called(ctx, since)
+	return args.Get(0).([]localModels.WebhookDeliveryStats), args.Error(1)
+}
+
+func (m *MockOperationsService) QueueDepth(ctx context.Context) (localModels.QueueDepth, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(localModels.QueueDepth), args.Error(1)
+}
@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRekeyService mocks the rekey service
+type MockRekeyService struct {
+	mock.Mock
+}
+
+func (m *MockRekeyService) Rekey(ctx context.Context, clientID, applicantID string) (localModels.Rekey, error) {
+	args := m.Called(ctx, clientID, applicantID)
+	return args.Get(0).(localModels.Rekey), args.Error(1)
+}
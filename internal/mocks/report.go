@@ -0,0 +1,39 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockReportService mocks the applicant report service
+type MockReportService struct {
+	mock.Mock
+}
+
+func (m *MockReportService) RequestReport(ctx context.Context, clientID, applicantID, purpose string) (localModels.ApplicantReport, error) {
+	args := m.Called(ctx, clientID, applicantID, purpose)
+	return args.Get(0).(localModels.ApplicantReport), args.Error(1)
+}
+
+func (m *MockReportService) GetReport(ctx context.Context, clientID, applicantID, reportID string) (localModels.ApplicantReport, error) {
+	args := m.Called(ctx, clientID, applicantID, reportID)
+	return args.Get(0).(localModels.ApplicantReport), args.Error(1)
+}
+
+// MockComplianceReportService mocks the compliance report service
+type MockComplianceReportService struct {
+	mock.Mock
+}
+
+func (m *MockComplianceReportService) RequestComplianceReport(ctx context.Context, clientID, format string, from, to time.Time) (localModels.ComplianceReport, error) {
+	args := m.Called(ctx, clientID, format, from, to)
+	return args.Get(0).(localModels.ComplianceReport), args.Error(1)
+}
+
+func (m *MockComplianceReportService) GetComplianceReport(ctx context.Context, clientID, reportID string) (localModels.ComplianceReport, error) {
+	args := m.Called(ctx, clientID, reportID)
+	return args.Get(0).(localModels.ComplianceReport), args.Error(1)
+}
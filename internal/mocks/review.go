@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockReviewService mocks the admin review queue service
+type MockReviewService struct {
+	mock.Mock
+}
+
+func (m *MockReviewService) GetReviewQueue(c *gin.Context, filter localModels.ReviewQueueFilter) ([]localModels.ReviewQueueItem, error) {
+	args := m.Called(c, filter)
+	return args.Get(0).([]localModels.ReviewQueueItem), args.Error(1)
+}
+
+func (m *MockReviewService) ClaimApplicant(c *gin.Context, applicantID, reviewerID string) (localModels.ReviewQueueItem, error) {
+	args := m.Called(c, applicantID, reviewerID)
+	return args.Get(0).(localModels.ReviewQueueItem), args.Error(1)
+}
+
+func (m *MockReviewService) AssignApplicant(c *gin.Context, applicantID, reviewerID, assignedBy string) (localModels.ReviewQueueItem, error) {
+	args := m.Called(c, applicantID, reviewerID, assignedBy)
+	return args.Get(0).(localModels.ReviewQueueItem), args.Error(1)
+}
+
+func (m *MockReviewService) ReleaseApplicant(c *gin.Context, applicantID, reviewerID string) (localModels.ReviewQueueItem, error) {
+	args := m.Called(c, applicantID, reviewerID)
+	return args.Get(0).(localModels.ReviewQueueItem), args.Error(1)
+}
@@ -0,0 +1,54 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSessionService mocks the hosted verification session service
+type MockSessionService struct {
+	mock.Mock
+}
+
+func (m *MockSessionService) CreateSession(ctx context.Context, clientID, applicantID string, ttl time.Duration) (localModels.SessionLink, error) {
+	args := m.Called(ctx, clientID, applicantID, ttl)
+	return args.Get(0).(localModels.SessionLink), args.Error(1)
+}
+
+func (m *MockSessionService) GetSession(ctx context.Context, clientID, applicantID, sessionID string) (localModels.VerificationSession, error) {
+	args := m.Called(ctx, clientID, applicantID, sessionID)
+	return args.Get(0).(localModels.VerificationSession), args.Error(1)
+}
+
+func (m *MockSessionService) Authenticate(ctx context.Context, token string) (localModels.VerificationSession, localModels.SessionChannel, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(localModels.VerificationSession), args.Get(1).(localModels.SessionChannel), args.Error(2)
+}
+
+func (m *MockSessionService) HandoffCode(ctx context.Context, session localModels.VerificationSession, size int) ([]byte, error) {
+	args := m.Called(ctx, session, size)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockSessionService) OpenSession(ctx context.Context, session localModels.VerificationSession) (localModels.VerificationSession, error) {
+	args := m.Called(ctx, session)
+	return args.Get(0).(localModels.VerificationSession), args.Error(1)
+}
+
+func (m *MockSessionService) RecordSubmission(ctx context.Context, session localModels.VerificationSession, documentID string) error {
+	args := m.Called(ctx, session, documentID)
+	return args.Error(0)
+}
+
+func (m *MockSessionService) CompleteSession(ctx context.Context, session localModels.VerificationSession, channel localModels.SessionChannel) (localModels.VerificationSession, error) {
+	args := m.Called(ctx, session, channel)
+	return args.Get(0).(localModels.VerificationSession), args.Error(1)
+}
+
+func (m *MockSessionService) WatchSession(session localModels.VerificationSession) (<-chan localModels.SessionEvent, func()) {
+	args := m.Called(session)
+	return args.Get(0).(<-chan localModels.SessionEvent), args.Get(1).(func())
+}
@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSigningService mocks the response signing key service
+type MockSigningService struct {
+	mock.Mock
+}
+
+func (m *MockSigningService) RotateKey(ctx context.Context, clientID, adminID string) (localModels.ResponseSigningKey, error) {
+	args := m.Called(ctx, clientID, adminID)
+	return args.Get(0).(localModels.ResponseSigningKey), args.Error(1)
+}
+
+func (m *MockSigningService) ListKeys(ctx context.Context, clientID string) ([]localModels.ResponseSigningKey, error) {
+	args := m.Called(ctx, clientID)
+	return args.Get(0).([]localModels.ResponseSigningKey), args.Error(1)
+}
+
+func (m *MockSigningService) ActiveKeys(ctx context.Context, clientID string) ([]localModels.ResponseSigningKey, error) {
+	args := m.Called(ctx, clientID)
+	return args.Get(0).([]localModels.ResponseSigningKey), args.Error(1)
+}
@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStatsService mocks the applicant statistics service
+type MockStatsService struct {
+	mock.Mock
+}
+
+func (m *MockStatsService) GetClientStats(ctx context.Context, clientID string) (localModels.ApplicantStats, error) {
+	args := m.Called(ctx, clientID)
+	return args.Get(0).(localModels.ApplicantStats), args.Error(1)
+}
@@ -0,0 +1,33 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSumsubSyncService mocks the Sumsub applicant sync service
+type MockSumsubSyncService struct {
+	mock.Mock
+}
+
+func (m *MockSumsubSyncService) SyncApplicant(ctx context.Context, clientID, applicantID, trigger string) (localModels.SumsubSync, error) {
+	args := m.Called(ctx, clientID, applicantID, trigger)
+	return args.Get(0).(localModels.SumsubSync), args.Error(1)
+}
+
+// MockSumsubClient mocks the Sumsub API
+type MockSumsubClient struct {
+	mock.Mock
+}
+
+func (m *MockSumsubClient) Applicant(ctx context.Context, applicantID string) (localModels.SumsubApplicantData, error) {
+	args := m.Called(ctx, applicantID)
+	return args.Get(0).(localModels.SumsubApplicantData), args.Error(1)
+}
+
+func (m *MockSumsubClient) Inspections(ctx context.Context, sumsubID string) ([]localModels.SumsubInspection, error) {
+	args := m.Called(ctx, sumsubID)
+	return args.Get(0).([]localModels.SumsubInspection), args.Error(1)
+}
@@ -0,0 +1,53 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTokenService mocks the session token service
+type MockTokenService struct {
+	mock.Mock
+}
+
+func (m *MockTokenService) IssueForAPIKey(ctx context.Context, apiKey string) (localModels.TokenPair, error) {
+	args := m.Called(ctx, apiKey)
+	return args.Get(0).(localModels.TokenPair), args.Error(1)
+}
+
+func (m *MockTokenService) IssueForPassword(ctx context.Context, username, password string) (localModels.TokenPair, error) {
+	args := m.Called(ctx, username, password)
+	return args.Get(0).(localModels.TokenPair), args.Error(1)
+}
+
+func (m *MockTokenService) Refresh(ctx context.Context, refreshToken string) (localModels.TokenPair, error) {
+	args := m.Called(ctx, refreshToken)
+	return args.Get(0).(localModels.TokenPair), args.Error(1)
+}
+
+func (m *MockTokenService) Revoke(ctx context.Context, token string) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockTokenService) Verify(ctx context.Context, accessToken string) (localModels.TokenClaims, error) {
+	args := m.Called(ctx, accessToken)
+	return args.Get(0).(localModels.TokenClaims), args.Error(1)
+}
+
+func (m *MockTokenService) CreateDashboardUser(ctx context.Context, user localModels.DashboardUser, password string) (localModels.DashboardUser, error) {
+	args := m.Called(ctx, user, password)
+	return args.Get(0).(localModels.DashboardUser), args.Error(1)
+}
+
+func (m *MockTokenService) RevokeAPIKey(ctx context.Context, clientID, keyID string) error {
+	args := m.Called(ctx, clientID, keyID)
+	return args.Error(0)
+}
+
+func (m *MockTokenService) DisableDashboardUser(ctx context.Context, clientID, userID string) error {
+	args := m.Called(ctx, clientID, userID)
+	return args.Error(0)
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"context"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockUsageService mocks the usage metering service
+type MockUsageService struct {
+	mock.Mock
+}
+
+func (m *MockUsageService) Record(ctx context.Context, clientID, eventType, subjectID string) error {
+	args := m.Called(ctx, clientID, eventType, subjectID)
+	return args.Error(0)
+}
+
+func (m *MockUsageService) GetUsage(ctx context.Context, clientID, fromMonth, toMonth string) ([]localModels.MonthlyUsage, error) {
+	args := m.Called(ctx, clientID, fromMonth, toMonth)
+	return args.Get(0).([]localModels.MonthlyUsage), args.Error(1)
+}
@@ -0,0 +1,55 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhookService mocks the client webhook service
+type MockWebhookService struct {
+	mock.Mock
+}
+
+func (m *MockWebhookService) Emit(ctx context.Context, clientID, eventType string, data interface{}) error {
+	args := m.Called(ctx, clientID, eventType, data)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) GetEndpoint(ctx context.Context, clientID string) (localModels.WebhookEndpoint, error) {
+	args := m.Called(ctx, clientID)
+	return args.Get(0).(localModels.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookService) SetEndpoint(ctx context.Context, clientID, url string) (localModels.WebhookEndpoint, error) {
+	args := m.Called(ctx, clientID, url)
+	return args.Get(0).(localModels.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookService) RotateSecret(ctx context.Context, clientID string, grace time.Duration) (localModels.WebhookEndpoint, error) {
+	args := m.Called(ctx, clientID, grace)
+	return args.Get(0).(localModels.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookService) VerifySignature(ctx context.Context, clientID, header string, payload []byte) (localModels.WebhookVerification, error) {
+	args := m.Called(ctx, clientID, header, payload)
+	return args.Get(0).(localModels.WebhookVerification), args.Error(1)
+}
+
+func (m *MockWebhookService) ListDeadLetters(ctx context.Context, clientID string, page pagination.Page) ([]localModels.WebhookDeadLetter, error) {
+	args := m.Called(ctx, clientID, page)
+	return args.Get(0).([]localModels.WebhookDeadLetter), args.Error(1)
+}
+
+func (m *MockWebhookService) Redeliver(ctx context.Context, clientID, eventID, redeliveredBy string) (localModels.WebhookDeadLetter, error) {
+	args := m.Called(ctx, clientID, eventID, redeliveredBy)
+	return args.Get(0).(localModels.WebhookDeadLetter), args.Error(1)
+}
+
+func (m *MockWebhookService) SendTestEvent(ctx context.Context, clientID, eventType string) (localModels.WebhookTestResult, error) {
+	args := m.Called(ctx, clientID, eventType)
+	return args.Get(0).(localModels.WebhookTestResult), args.Error(1)
+}
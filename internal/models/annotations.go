@@ -0,0 +1,156 @@
+package models
+
+import (
+	"fmt"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Bounds on the tags and metadata a client may attach to one applicant
+const (
+	MaxTags                = 20
+	MaxTagLength           = 64
+	MaxMetadataKeys        = 50
+	MaxMetadataKeyLength   = 40
+	MaxMetadataValueLength = 500
+	// MaxMetadataBytes bounds the keys and values of an applicant's metadata taken together
+	MaxMetadataBytes = 8 * 1024
+)
+
+var (
+	// Tags are matched exactly, so they are kept to characters that read the same everywhere
+	tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]*$`)
+	// Metadata keys are also query parameter names and stored field names, so take no dots or dollars
+	metadataKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+)
+
+// ErrInvalidAnnotations is returned for tags or metadata outside the bounds above
+var ErrInvalidAnnotations = apperr.BadRequest("invalid_annotations", "invalid tags or metadata")
+
+// Annotations are a client's own tags and key/value metadata on an applicant, for routing
+// and reporting on its side. They are kept under "annotations", apart from the fields we
+// verify, and echoed in the applicant's webhook events.
+type Annotations struct {
+	Tags     []string          `json:"tags" bson:"tags"`
+	Metadata map[string]string `json:"metadata" bson:"metadata"`
+}
+
+// NewAnnotations checks tags and metadata against the bounds above, dropping repeated tags.
+// It returns nil when there are neither.
+func NewAnnotations(tags []string, metadata map[string]string) (*Annotations, error) {
+	if len(tags) == 0 && len(metadata) == 0 {
+		return nil, nil
+	}
+	annotations := &Annotations{Tags: []string{}, Metadata: map[string]string{}}
+	for _, tag := range tags {
+		if len(tag) > MaxTagLength || !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("%w: tag %q must be up to %d letters, digits, '_', '.', ':' or '-', starting with a letter or digit", ErrInvalidAnnotations, tag, MaxTagLength)
+		}
+		if !slices.Contains(annotations.Tags, tag) {
+			annotations.Tags = append(annotations.Tags, tag)
+		}
+	}
+	if len(annotations.Tags) > MaxTags {
+		return nil, fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidAnnotations, MaxTags)
+	}
+
+	if len(metadata) > MaxMetadataKeys {
+		return nil, fmt.Errorf("%w: at most %d metadata keys are allowed", ErrInvalidAnnotations, MaxMetadataKeys)
+	}
+	size := 0
+	for _, key := range sortedKeys(metadata) {
+		value := metadata[key]
+		if len(key) > MaxMetadataKeyLength || !metadataKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%w: metadata key %q must be up to %d letters, digits or '_', starting with a letter", ErrInvalidAnnotations, key, MaxMetadataKeyLength)
+		}
+		if len(value) > MaxMetadataValueLength {
+			return nil, fmt.Errorf("%w: metadata value of %q must be at most %d bytes", ErrInvalidAnnotations, key, MaxMetadataValueLength)
+		}
+		size += len(key) + len(value)
+		annotations.Metadata[key] = value
+	}
+	if size > MaxMetadataBytes {
+		return nil, fmt.Errorf("%w: metadata must be at most %d bytes in total", ErrInvalidAnnotations, MaxMetadataBytes)
+	}
+	return annotations, nil
+}
+
+// AnnotationsPatch changes an applicant's annotations as a JSON merge patch: tags, when
+// given, replace the applicant's, and each metadata key is set, or removed when null
+type AnnotationsPatch struct {
+	Tags     *[]string          `json:"tags"`
+	Metadata map[string]*string `json:"metadata"`
+}
+
+// Apply returns the annotations with the patch applied, checked against the bounds above
+func (p AnnotationsPatch) Apply(current *Annotations) (*Annotations, error) {
+	var tags []string
+	metadata := map[string]string{}
+	if current != nil {
+		tags = current.Tags
+		for key, value := range current.Metadata {
+			metadata[key] = value
+		}
+	}
+	if p.Tags != nil {
+		tags = *p.Tags
+	}
+	for key, value := range p.Metadata {
+		if value == nil {
+			delete(metadata, key)
+			continue
+		}
+		metadata[key] = *value
+	}
+
+	annotations, err := NewAnnotations(tags, metadata)
+	if annotations == nil && err == nil {
+		// Kept empty rather than unset, so the response says what the applicant now has
+		annotations = &Annotations{Tags: []string{}, Metadata: map[string]string{}}
+	}
+	return annotations, err
+}
+
+// ApplicantFilter narrows applicant lists to those with all of some tags and metadata values
+type ApplicantFilter struct {
+	Tags     []string
+	Metadata map[string]string
+}
+
+// metadataParamPrefix starts the query parameters filtering on metadata, as in ?metadata.region=eu
+const metadataParamPrefix = "metadata."
+
+// ParseApplicantFilter reads repeated ?tag= parameters and ?metadata.<key>= parameters
+func ParseApplicantFilter(query url.Values) (ApplicantFilter, error) {
+	filter := ApplicantFilter{Metadata: map[string]string{}}
+	for _, tag := range query["tag"] {
+		if !tagPattern.MatchString(tag) {
+			return ApplicantFilter{}, fmt.Errorf("invalid tag filter: %q", tag)
+		}
+		filter.Tags = append(filter.Tags, tag)
+	}
+	for param, values := range query {
+		key, ok := strings.CutPrefix(param, metadataParamPrefix)
+		if !ok {
+			continue
+		}
+		if !metadataKeyPattern.MatchString(key) || len(values) != 1 {
+			return ApplicantFilter{}, fmt.Errorf("invalid metadata filter: %s", param)
+		}
+		filter.Metadata[key] = values[0]
+	}
+	return filter, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ApplicantStatus is the lifecycle state of an applicant's verification
+type ApplicantStatus string
+
+const (
+	ApplicantPending              ApplicantStatus = "pending"
+	ApplicantInReview             ApplicantStatus = "in_review"
+	ApplicantResubmissionRequired ApplicantStatus = "resubmission_required"
+	ApplicantApproved             ApplicantStatus = "approved"
+	ApplicantRejected             ApplicantStatus = "rejected"
+)
+
+// coreResubmissionRequired extends the statuses of the core package, which has no
+// resubmission state, with the next free value
+const coreResubmissionRequired coreModels.ApplicantStatus = coreModels.ApplicantStatusRejected + 1
+
+// coreStatuses are the values the statuses are stored as in the status field, which the
+// core package writes as an int. Approved is core's verified.
+var coreStatuses = map[ApplicantStatus]coreModels.ApplicantStatus{
+	ApplicantPending:              coreModels.ApplicantStatusPending,
+	ApplicantInReview:             coreModels.ApplicantStatusInReview,
+	ApplicantApproved:             coreModels.ApplicantStatusVerified,
+	ApplicantRejected:             coreModels.ApplicantStatusRejected,
+	ApplicantResubmissionRequired: coreResubmissionRequired,
+}
+
+// ParseApplicantStatus converts a string into an ApplicantStatus
+func ParseApplicantStatus(s string) (ApplicantStatus, error) {
+	if _, ok := coreStatuses[ApplicantStatus(s)]; ok {
+		return ApplicantStatus(s), nil
+	}
+	return "", fmt.Errorf("invalid applicant status: %s", s)
+}
+
+// Core returns the status as the core package stores it
+func (s ApplicantStatus) Core() (coreModels.ApplicantStatus, error) {
+	if status, ok := coreStatuses[s]; ok {
+		return status, nil
+	}
+	return 0, fmt.Errorf("invalid applicant status: %s", s)
+}
+
+// ApplicantStatusFromCore returns the status stored as a core status
+func ApplicantStatusFromCore(core coreModels.ApplicantStatus) (ApplicantStatus, error) {
+	for status, value := range coreStatuses {
+		if value == core {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("invalid applicant status: %d", core)
+}
+
+// MarshalBSONValue stores the status as the int the core package writes to the same
+// field, so filters and updates match applicants whichever package saved them
+func (s ApplicantStatus) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	core, err := s.Core()
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(int32(core))
+}
+
+// UnmarshalBSONValue reads a stored int status. Strings, which releases before statuses
+// were stored as ints wrote until migration.ApplicantStatuses converts them, are read as is.
+func (s *ApplicantStatus) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Null, bsontype.Undefined:
+		*s = ""
+		return nil
+	case bsontype.Int32, bsontype.Int64:
+		status, err := ApplicantStatusFromCore(coreModels.ApplicantStatus(value.AsInt64()))
+		if err != nil {
+			return err
+		}
+		*s = status
+		return nil
+	case bsontype.String:
+		status, err := ParseApplicantStatus(value.StringValue())
+		if err != nil {
+			return err
+		}
+		*s = status
+		return nil
+	}
+	return fmt.Errorf("cannot read applicant status from %s", t)
+}
+
+// Review holds the manual review state stored on the applicant record under "review"
+type Review struct {
+	AssignedTo *string    `json:"assigned_to" bson:"assigned_to"`
+	AssignedBy *string    `json:"assigned_by,omitempty" bson:"assigned_by,omitempty"`
+	ClaimedAt  *time.Time `json:"claimed_at" bson:"claimed_at"`
+	EnteredAt  *time.Time `json:"entered_at" bson:"entered_at"` // When the applicant entered its current review state
+	DecidedBy  *string    `json:"decided_by,omitempty" bson:"decided_by,omitempty"`
+	DecidedAt  *time.Time `json:"decided_at,omitempty" bson:"decided_at,omitempty"`
+	ReasonCode string     `json:"reason_code,omitempty" bson:"reason_code,omitempty"`
+	Comment    string     `json:"comment,omitempty" bson:"comment,omitempty"`
+	DecisionID string     `json:"decision_id,omitempty" bson:"decision_id,omitempty"` // Decision that set the current status
+}
+
+// ProviderChoice is the verification vendor chosen for the applicant's latest document,
+// stored on the applicant record under "verification_provider"
+type ProviderChoice struct {
+	Name       string    `json:"name" bson:"name"`
+	Route      string    `json:"route" bson:"route"`                             // preferred, or failover
+	Preferred  string    `json:"preferred,omitempty" bson:"preferred,omitempty"` // The vendor failed over from
+	DocumentID string    `json:"document_id" bson:"document_id"`
+	SelectedAt time.Time `json:"selected_at" bson:"selected_at"`
+}
+
+// ApplicantFields are the applicant fields clients may select with ?fields=. Encrypted
+// data is left out, since it is only of use to the service holding the key.
+var ApplicantFields = []string{
+	"applicant_id", "first_name", "middle_name", "last_name", "email", "phone",
+	"verification_level", "status", "review", "device_metadata", "risk_signals",
+	"consent", "annotations", "created_at", "updated_at",
+}
+
+// IncludeDocuments names the applicant's documents in ?include=
+const IncludeDocuments = "documents"
+
+// ApplicantSelection is a sparse fieldset of applicants and the related resources to include with them
+type ApplicantSelection struct {
+	Fields    []string // Always holds applicant_id, so results can be told apart
+	Documents bool
+}
+
+// ParseApplicantSelection reads comma separated ?fields= and ?include= values. Leaving
+// fields empty selects every field in ApplicantFields.
+func ParseApplicantSelection(fields, include string) (ApplicantSelection, error) {
+	selection := ApplicantSelection{Fields: []string{"applicant_id"}}
+	if strings.TrimSpace(fields) == "" {
+		selection.Fields = ApplicantFields
+	}
+	for _, field := range splitList(fields) {
+		if !slices.Contains(ApplicantFields, field) {
+			return ApplicantSelection{}, fmt.Errorf("unknown applicant field: %s", field)
+		}
+		if !slices.Contains(selection.Fields, field) {
+			selection.Fields = append(selection.Fields, field)
+		}
+	}
+	for _, related := range splitList(include) {
+		if related != IncludeDocuments {
+			return ApplicantSelection{}, fmt.Errorf("cannot include %s", related)
+		}
+		selection.Documents = true
+	}
+	return selection, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/country"
+	"github.com/rachel-lawrie/verus_app_backend/internal/jsonpatch"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// Content types of the patches PATCH /applicants/{id} accepts. Plain application/json is
+// read as a merge patch.
+const (
+	MergePatchContentType = "application/merge-patch+json" // RFC 7386
+	JSONPatchContentType  = "application/json-patch+json"  // RFC 6902
+)
+
+// ErrInvalidApplicant is returned when a patch would leave an applicant incomplete or malformed
+var ErrInvalidApplicant = errors.New("invalid applicant")
+
+// ApplicantPatch is a JSON Merge Patch or, when Operations is set, a JSON Patch to an
+// applicant's EditableApplicant
+type ApplicantPatch struct {
+	Merge      map[string]interface{}
+	Operations []jsonpatch.Operation
+}
+
+// EditableApplicant is the part of an applicant clients change with a patch. The date of
+// birth and address are stored encrypted and never read back, so they start out absent:
+// a patch that sets the address gives it whole.
+type EditableApplicant struct {
+	FirstName         string                 `json:"first_name"`
+	MiddleName        string                 `json:"middle_name"`
+	LastName          string                 `json:"last_name"`
+	Email             string                 `json:"email"`
+	Phone             string                 `json:"phone"`
+	VerificationLevel string                 `json:"verification_level"`
+	DOB               string                 `json:"dob,omitempty"`
+	Address           *coreModels.RawAddress `json:"address,omitempty"`
+}
+
+// Validate checks that the applicant would still have everything creating one requires
+func (a EditableApplicant) Validate() error {
+	var missing []string
+	for field, value := range map[string]string{
+		"first_name":         a.FirstName,
+		"last_name":          a.LastName,
+		"email":              a.Email,
+		"phone":              a.Phone,
+		"verification_level": a.VerificationLevel,
+	} {
+		if strings.TrimSpace(value) == "" {
+			missing = append(missing, field)
+		}
+	}
+	if a.Address != nil {
+		for field, value := range map[string]string{"Line1": a.Address.Line1, "City": a.Address.City, "Country": a.Address.Country} {
+			if strings.TrimSpace(value) == "" {
+				missing = append(missing, "address."+field)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		fields := make(map[string]string, len(missing))
+		for _, field := range missing {
+			fields[field] = "required"
+		}
+		return apperr.Invalid(fmt.Errorf("%w: %s required", ErrInvalidApplicant, strings.Join(missing, ", ")), fields)
+	}
+
+	if a.DOB != "" {
+		if _, err := time.Parse(time.DateOnly, a.DOB); err != nil {
+			return apperr.Invalid(fmt.Errorf("%w: dob must be a date such as 1990-01-31", ErrInvalidApplicant),
+				map[string]string{"dob": "must be a date such as 1990-01-31"})
+		}
+	}
+	if a.Address != nil {
+		if _, ok := country.Normalize(a.Address.Country); !ok {
+			return apperr.Invalid(fmt.Errorf("%w: address.Country must be an ISO 3166 country code", ErrInvalidApplicant),
+				map[string]string{"address.Country": "must be an ISO 3166 country code"})
+		}
+	}
+	return nil
+}
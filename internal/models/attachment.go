@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// AttachmentVisibility controls who can see a supporting attachment
+type AttachmentVisibility string
+
+const (
+	AttachmentInternal AttachmentVisibility = "internal" // Reviewers only
+	AttachmentShared   AttachmentVisibility = "shared"   // Reviewers and the client
+)
+
+// Attachment is a supporting file on an applicant, such as correspondence or a
+// screenshot. Attachments are kept apart from verification documents in their own
+// collection so they are never evaluated as KYC evidence or submitted to vendors.
+type Attachment struct {
+	AttachmentID string               `json:"attachment_id" bson:"attachment_id"`
+	ApplicantID  string               `json:"applicant_id" bson:"applicant_id"`
+	ClientID     string               `json:"client_id" bson:"client_id"`
+	FileName     string               `json:"file_name" bson:"file_name"` // Name as uploaded
+	MimeType     string               `json:"mime_type" bson:"mime_type"`
+	FileSize     int64                `json:"file_size" bson:"file_size"`
+	FileURL      string               `json:"-" bson:"file_url"`
+	Description  string               `json:"description,omitempty" bson:"description,omitempty"`
+	Visibility   AttachmentVisibility `json:"visibility" bson:"visibility"`
+	UploadedBy   string               `json:"uploaded_by" bson:"uploaded_by"`
+	UploaderType string               `json:"uploader_type" bson:"uploader_type"` // "admin" or "client"
+	CreatedAt    time.Time            `json:"created_at" bson:"created_at"`
+	Deleted      bool                 `json:"deleted" bson:"deleted"`
+	DeletedAt    *time.Time           `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy    *string              `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+}
+
+// Uploader types recorded on attachments
+const (
+	AttachmentUploaderAdmin  = "admin"
+	AttachmentUploaderClient = "client"
+)
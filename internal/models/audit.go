@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Reasons for downloading a document's files, one of which every download must give
+const (
+	AccessReasonVerification = "verification"
+	AccessReasonAudit        = "audit"
+	AccessReasonSupport      = "support"
+)
+
+// AccessReasons lists the reasons a download may give
+var AccessReasons = []string{AccessReasonVerification, AccessReasonAudit, AccessReasonSupport}
+
+// AuditEvent is one staff action, or one download of document files by a client, in
+// the audit log. Each event carries the hash of
+// the event before it, so the log forms a chain in which removing or editing an
+// event breaks every hash after it.
+type AuditEvent struct {
+	Seq       int64     `json:"seq" bson:"seq"` // Position in the chain, starting at 1 with no gaps
+	EventID   string    `json:"event_id" bson:"event_id"`
+	At        time.Time `json:"at" bson:"at"` // Never earlier than the event before it
+	ActorID   string    `json:"actor_id" bson:"actor_id"`
+	ActorRole string    `json:"actor_role" bson:"actor_role"` // Staff role, or client for client downloads
+	Method    string    `json:"method" bson:"method"`
+	Route     string    `json:"route" bson:"route"` // Route pattern, e.g. /api/v1/admin/applicants/:id/notes
+	Path      string    `json:"path" bson:"path"`   // Path as requested, including IDs
+	Status    int       `json:"status" bson:"status"`
+	IP        string    `json:"ip" bson:"ip"`
+	// DocumentIDs and Reason are set on downloads of document files: the documents handed
+	// out, and why the actor said they needed them
+	DocumentIDs []string `json:"document_ids,omitempty" bson:"document_ids,omitempty"`
+	Reason      string   `json:"reason,omitempty" bson:"reason,omitempty"`
+	// OnBehalfOf is the client a support admin acted as; Reason then holds the admin's justification
+	OnBehalfOf string `json:"on_behalf_of,omitempty" bson:"on_behalf_of,omitempty"`
+	PrevHash   string `json:"prev_hash" bson:"prev_hash"`
+	Hash       string `json:"hash" bson:"hash"`
+}
+
+// AuditProof lets an auditor check that an export holds every event in its time range.
+// The exported events must chain from StartHash to EndHash with consecutive sequence
+// numbers, and the boundary events must fall either side of the range.
+type AuditProof struct {
+	Algorithm string    `json:"algorithm"`
+	From      time.Time `json:"from"` // Inclusive
+	To        time.Time `json:"to"`   // Exclusive
+	Count     int       `json:"count"`
+	FirstSeq  int64     `json:"first_seq,omitempty"`
+	LastSeq   int64     `json:"last_seq,omitempty"`
+	// StartHash is the chain head when the range starts, i.e. the hash of Before
+	StartHash string `json:"start_hash"`
+	// EndHash is the chain head when the range ends, i.e. the hash of the last exported event
+	EndHash string `json:"end_hash"`
+	// Before is the last event before the range, nil when the range starts with the chain
+	Before *AuditEvent `json:"before,omitempty"`
+	// After is the first event after the range, nil when the range reaches the chain head
+	After *AuditEvent `json:"after,omitempty"`
+	// HeadSeq and HeadHash are the chain head when the export was made, for anchoring later exports
+	HeadSeq     int64     `json:"head_seq"`
+	HeadHash    string    `json:"head_hash"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// AuditExport is the audit log for a time range together with its completeness proof
+type AuditExport struct {
+	Events []AuditEvent `json:"events"`
+	Proof  AuditProof   `json:"proof"`
+}
+
+// DocumentAccessLog is every recorded download of a document's files, oldest first
+type DocumentAccessLog struct {
+	DocumentID string       `json:"document_id"`
+	Accesses   []AuditEvent `json:"accesses"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Backup records an encrypted snapshot of one client's data in the backup bucket
+type Backup struct {
+	BackupID    string         `json:"backup_id" bson:"backup_id"`
+	ClientID    string         `json:"client_id" bson:"client_id"`
+	TakenAt     time.Time      `json:"taken_at" bson:"taken_at"`
+	TakenBy     string         `json:"taken_by,omitempty" bson:"taken_by,omitempty"` // Admin who asked for the snapshot; empty for scheduled ones
+	ArchiveKey  string         `json:"archive_key" bson:"archive_key"`               // Encrypted dump of the client's records
+	ManifestKey string         `json:"manifest_key" bson:"manifest_key"`             // What the restore command is given
+	Collections map[string]int `json:"collections" bson:"collections"`               // Records dumped per collection
+	Objects     int            `json:"objects" bson:"objects"`                       // Files listed in the manifest
+}
+
+// BackupManifest is stored next to each snapshot's archive and holds everything needed to
+// restore it without the database: where the archive is, the KMS-encrypted key it is
+// sealed with, and the S3 objects of the client's files at the time of the snapshot.
+type BackupManifest struct {
+	BackupID      string         `json:"backup_id"`
+	ClientID      string         `json:"client_id"`
+	TakenAt       time.Time      `json:"taken_at"`
+	ArchiveKey    string         `json:"archive_key"`
+	ArchiveSHA256 string         `json:"archive_sha256"` // Of the encrypted archive, checked before decrypting
+	DataKey       []byte         `json:"data_key"`       // Encrypted by KMS
+	Collections   map[string]int `json:"collections"`
+	Objects       []BackupObject `json:"objects"`
+}
+
+// BackupObject is a file in the documents bucket that a snapshot's records point to
+type BackupObject struct {
+	Collection string `json:"collection"`
+	RecordID   string `json:"record_id"`
+	Key        string `json:"key"`
+}
+
+// BackupRestore counts what restoring a snapshot wrote, or would write on a dry run
+type BackupRestore struct {
+	BackupID    string         `json:"backup_id"`
+	ClientID    string         `json:"client_id"`
+	DryRun      bool           `json:"dry_run"`
+	Collections map[string]int `json:"collections"`
+}
@@ -0,0 +1,109 @@
+package models
+
+import "time"
+
+// Client is a customer of the API. Clients authenticate with API keys kept in the
+// client secrets collection; their webhook URL lives with their webhook endpoint.
+type Client struct {
+	ClientID                  string          `json:"client_id" bson:"client_id"`
+	Name                      string          `json:"name" bson:"name"`
+	ContactName               string          `json:"contact_name" bson:"contact_name"`
+	ContactEmail              string          `json:"contact_email" bson:"contact_email"`
+	AllowedVerificationLevels []string        `json:"allowed_verification_levels" bson:"allowed_verification_levels"`
+	Settings                  ClientSettings  `json:"settings" bson:"settings"`
+	Features                  map[string]bool `json:"features,omitempty" bson:"features,omitempty"` // Feature flags switched on or off for this client
+	IPAllowlist               IPAllowlist     `json:"ip_allowlist" bson:"ip_allowlist"`             // Networks the client's requests must come from
+	WebhookURL                string          `json:"webhook_url,omitempty" bson:"-"`               // Read from the client's webhook endpoint
+	WebhookSigning            *WebhookSigning `json:"webhook_signing,omitempty" bson:"-"`           // Read from the client's webhook endpoint
+	CreatedBy                 string          `json:"created_by" bson:"created_by"`                 // Admin who registered the client
+	CreatedAt                 time.Time       `json:"created_at" bson:"created_at"`
+	UpdatedAt                 time.Time       `json:"updated_at" bson:"updated_at"`
+}
+
+// ClientSettings adjusts the service's behaviour for one client. Zero values fall back to the service defaults.
+type ClientSettings struct {
+	// MaxUploadBytes lowers the largest document file the client may upload
+	MaxUploadBytes int64 `json:"max_upload_bytes,omitempty" bson:"max_upload_bytes,omitempty"`
+	// AllowedDocumentTypes restricts the document types the client may upload. Empty allows every type.
+	AllowedDocumentTypes []string                 `json:"allowed_document_types,omitempty" bson:"allowed_document_types,omitempty"`
+	Sandbox              SandboxSimulation        `json:"sandbox" bson:"sandbox"`
+	Webhooks             ClientWebhookRetryPolicy `json:"webhooks" bson:"webhooks"`
+	// SignResponses adds an HMAC signature of each API response body, made with the client's signing keys
+	SignResponses bool `json:"sign_responses,omitempty" bson:"sign_responses,omitempty"`
+	// RequireSignedRequests refuses requests made with the client's API key unless they carry a
+	// fresh timestamp, an unused nonce and a signature made with one of the client's signing keys
+	RequireSignedRequests bool `json:"require_signed_requests,omitempty" bson:"require_signed_requests,omitempty"`
+	// Watermark stamps the client's documents with who downloaded them, when and why
+	Watermark DownloadWatermark `json:"watermark" bson:"watermark"`
+	// RequireConsent refuses to create applicants without a consent record, and to submit
+	// documents for verification for applicants that have none
+	RequireConsent bool `json:"require_consent,omitempty" bson:"require_consent,omitempty"`
+	// KMSKeyID is the ARN of a KMS key dedicated to the client. Data keys for the client's
+	// personal data and files are made under it instead of the shared key, so revoking the
+	// key revokes access to the client's data. Empty uses the shared key.
+	KMSKeyID string `json:"kms_key_id,omitempty" bson:"kms_key_id,omitempty"`
+}
+
+// DownloadWatermark controls the visible mark stamped on a client's document files as
+// staff and the client download them
+type DownloadWatermark struct {
+	Enabled bool `json:"enabled" bson:"enabled"`
+	// Label names the client in the mark. Empty uses the client's name.
+	Label string `json:"label,omitempty" bson:"label,omitempty"`
+}
+
+// IPAllowlist restricts the addresses a client's requests may come from
+type IPAllowlist struct {
+	// CIDRs lists the networks requests may come from. Empty allows any address.
+	CIDRs     []string   `json:"cidrs" bson:"cidrs"`
+	UpdatedBy string     `json:"updated_by,omitempty" bson:"updated_by,omitempty"` // Admin ID, or the client's own ID when it set the list itself
+	UpdatedAt *time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	// Bypass lets requests from any address through while the list is kept, for an
+	// admin to use when a client has locked itself out
+	Bypass      bool       `json:"bypass" bson:"bypass"`
+	BypassSetBy string     `json:"bypass_set_by,omitempty" bson:"bypass_set_by,omitempty"`
+	BypassSetAt *time.Time `json:"bypass_set_at,omitempty" bson:"bypass_set_at,omitempty"`
+}
+
+// Outcomes a sandbox client can ask verifications to simulate
+const (
+	SandboxOutcomeApprove = "approve"
+	SandboxOutcomeReject  = "reject"
+	SandboxOutcomeReview  = "review"
+)
+
+// SandboxSimulation controls how the sandbox environment answers the client, so
+// integrations can exercise each path without real documents
+type SandboxSimulation struct {
+	// Outcome is the verification result to simulate. Empty runs verification as normal.
+	Outcome string `json:"outcome,omitempty" bson:"outcome,omitempty"`
+	// InjectFaults delays or fails some of the client's requests and drops some webhook
+	// deliveries, at the rates the sandbox is configured with
+	InjectFaults bool `json:"inject_faults,omitempty" bson:"inject_faults,omitempty"`
+}
+
+// ClientWebhookRetryPolicy overrides how webhook deliveries to the client are retried
+type ClientWebhookRetryPolicy struct {
+	// MaxAttempts is the number of delivery attempts before an event is given up on
+	MaxAttempts int `json:"max_attempts,omitempty" bson:"max_attempts,omitempty"`
+}
+
+// ClientSecret is an API key of a client. Only the hash of the key is stored.
+type ClientSecret struct {
+	KeyID            string     `bson:"key_id"`
+	ClientID         string     `bson:"client_id"`
+	ClientSecretHash string     `bson:"client_secret_hash"`
+	Revoked          bool       `bson:"revoked"`
+	CreatedBy        string     `bson:"created_by"`
+	CreatedAt        time.Time  `bson:"created_at"`
+	DeletedAt        *time.Time `bson:"deleted_at"`
+}
+
+// ClientRegistration is returned once when a client is registered. The API key and
+// webhook signing secret cannot be retrieved again.
+type ClientRegistration struct {
+	Client        Client `json:"client"`
+	KeyID         string `json:"key_id"`
+	APIKey        string `json:"api_key"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
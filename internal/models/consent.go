@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ConsentChannel is how the applicant was shown the consent text
+type ConsentChannel string
+
+const (
+	ConsentWeb      ConsentChannel = "web"
+	ConsentMobile   ConsentChannel = "mobile"
+	ConsentInPerson ConsentChannel = "in_person"
+	ConsentPhone    ConsentChannel = "phone"
+)
+
+// ConsentChannels lists the channels consent may be given through
+var ConsentChannels = []ConsentChannel{ConsentWeb, ConsentMobile, ConsentInPerson, ConsentPhone}
+
+// Consent is the applicant's agreement to be verified, as reported by the client when the
+// applicant was created. It is stored under "consent" and never changed afterwards.
+type Consent struct {
+	TextVersion string         `json:"text_version" bson:"text_version"` // Version of the consent text the applicant agreed to
+	GivenAt     time.Time      `json:"given_at" bson:"given_at"`
+	IP          string         `json:"ip" bson:"ip"` // Address the applicant agreed from
+	Channel     ConsentChannel `json:"channel" bson:"channel"`
+	RecordedAt  time.Time      `json:"recorded_at" bson:"recorded_at"` // Set by the service when the consent is stored
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// DecisionStatus is the state of a manual verification decision
+type DecisionStatus string
+
+const (
+	DecisionPendingConfirmation DecisionStatus = "pending_confirmation" // Waiting for a second reviewer
+	DecisionApplied             DecisionStatus = "applied"              // Applicant status has been changed
+	DecisionDeclined            DecisionStatus = "declined"             // Rejected by the second reviewer, applicant unchanged
+)
+
+// Actions recorded in a decision's audit trail
+const (
+	DecisionActionProposed  = "proposed"
+	DecisionActionConfirmed = "confirmed"
+	DecisionActionDeclined  = "declined"
+	DecisionActionApplied   = "applied"
+)
+
+// DecisionAuditEntry is one step in the life of a decision
+type DecisionAuditEntry struct {
+	Action  string    `json:"action" bson:"action"`
+	Actor   string    `json:"actor" bson:"actor"`
+	At      time.Time `json:"at" bson:"at"`
+	Comment string    `json:"comment,omitempty" bson:"comment,omitempty"`
+}
+
+// Decision is a reviewer's approve/reject decision on an applicant, stored in the decisions collection
+type Decision struct {
+	DecisionID          string               `json:"decision_id" bson:"decision_id"`
+	ApplicantID         string               `json:"applicant_id" bson:"applicant_id"`
+	ClientID            string               `json:"client_id" bson:"client_id"`
+	VerificationLevel   string               `json:"verification_level" bson:"verification_level"`
+	Decision            ReviewDecision       `json:"decision" bson:"decision"`
+	ReasonCode          string               `json:"reason_code" bson:"reason_code"`
+	Comment             string               `json:"comment,omitempty" bson:"comment,omitempty"`
+	Status              DecisionStatus       `json:"status" bson:"status"`
+	RequiresDualControl bool                 `json:"requires_dual_control" bson:"requires_dual_control"`
+	DualControlReason   string               `json:"dual_control_reason,omitempty" bson:"dual_control_reason,omitempty"`
+	ProposedBy          string               `json:"proposed_by" bson:"proposed_by"`
+	ProposedAt          time.Time            `json:"proposed_at" bson:"proposed_at"`
+	ConfirmedBy         *string              `json:"confirmed_by,omitempty" bson:"confirmed_by,omitempty"`
+	ConfirmedAt         *time.Time           `json:"confirmed_at,omitempty" bson:"confirmed_at,omitempty"`
+	AuditTrail          []DecisionAuditEntry `json:"audit_trail" bson:"audit_trail"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// DeletedApplicant is a soft-deleted applicant as listed for restoring, with how long it
+// can still be restored
+type DeletedApplicant struct {
+	ApplicantID       string    `json:"applicant_id" bson:"applicant_id"`
+	ClientID          string    `json:"client_id" bson:"client_id"`
+	FirstName         string    `json:"first_name" bson:"first_name"`
+	LastName          string    `json:"last_name" bson:"last_name"`
+	VerificationLevel string    `json:"verification_level" bson:"verification_level"`
+	CreatedAt         time.Time `json:"created_at" bson:"created_at"`
+	DeletedAt         time.Time `json:"deleted_at" bson:"deleted_at"`
+	DeletedBy         *string   `json:"deleted_by" bson:"deleted_by"`
+	// RestorableUntil is when the grace period ends; the applicant stays deleted after it
+	RestorableUntil  time.Time `json:"restorable_until" bson:"-"`
+	SecondsRemaining int64     `json:"seconds_remaining" bson:"-"` // Zero once it can no longer be restored
+	Restorable       bool      `json:"restorable" bson:"-"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// ApplicantRecord is an applicant as stored by this service: the shared applicant
+// model plus the fields only this service writes
+type ApplicantRecord struct {
+	coreModels.Applicant `bson:",inline"`
+	DeviceMetadata       *DeviceMetadata  `json:"device_metadata,omitempty" bson:"device_metadata,omitempty"`
+	RiskSignals          []RiskSignal     `json:"risk_signals,omitempty" bson:"risk_signals,omitempty"`
+	Consent              *Consent         `json:"consent,omitempty" bson:"consent,omitempty"`
+	VerificationProvider *ProviderChoice  `json:"verification_provider,omitempty" bson:"verification_provider,omitempty"`
+	Intake               *ApplicantIntake `json:"intake,omitempty" bson:"intake,omitempty"`
+	// CaptureChannel is how the applicant reached the hosted page when they completed a session there
+	CaptureChannel SessionChannel `json:"capture_channel,omitempty" bson:"capture_channel,omitempty"`
+	Annotations    *Annotations   `json:"annotations,omitempty" bson:"annotations,omitempty"`
+	// RekeyedAt is when the applicant's data was last re-encrypted under the current KMS key
+	RekeyedAt *time.Time `json:"rekeyed_at,omitempty" bson:"rekeyed_at,omitempty"`
+}
+
+// MarshalJSON gives the applicant's times, and those of its documents, in UTC whatever
+// zone they were set in
+func (a ApplicantRecord) MarshalJSON() ([]byte, error) {
+	type record ApplicantRecord
+	a.CreatedAt, a.UpdatedAt, a.DeletedAt = timestamp.UTC(a.CreatedAt), timestamp.UTC(a.UpdatedAt), timestamp.UTCPtr(a.DeletedAt)
+	if a.Documents != nil {
+		documents := make([]coreModels.Document, len(a.Documents))
+		for i, document := range a.Documents {
+			document.CreatedAt, document.UpdatedAt, document.DeletedAt = timestamp.UTC(document.CreatedAt), timestamp.UTC(document.UpdatedAt), timestamp.UTCPtr(document.DeletedAt)
+			documents[i] = document
+		}
+		a.Documents = documents
+	}
+	return json.Marshal(record(a))
+}
+
+// DeviceInfo describes the device and network an applicant was created from
+type DeviceInfo struct {
+	IP             string `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent      string `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	Fingerprint    string `json:"fingerprint,omitempty" bson:"fingerprint,omitempty"`
+	AcceptLanguage string `json:"accept_language,omitempty" bson:"accept_language,omitempty"`
+}
+
+// DeviceMetadata is captured when an applicant is created. Clients calling from their
+// own servers should submit the end user's details, since the captured request comes
+// from the client's infrastructure rather than the applicant's device.
+type DeviceMetadata struct {
+	Submitted  *DeviceInfo `json:"submitted,omitempty" bson:"submitted,omitempty"` // Reported by the client
+	Captured   DeviceInfo  `json:"captured" bson:"captured"`                       // Observed on the API request
+	IPCountry  *IPCountry  `json:"ip_country,omitempty" bson:"ip_country,omitempty"`
+	CapturedAt time.Time   `json:"captured_at" bson:"captured_at"`
+}
+
+// IPCountry compares the country the applicant's IP is registered in with their declared address
+type IPCountry struct {
+	IP             string             `json:"ip" bson:"ip"`
+	Source         string             `json:"source" bson:"source"` // "submitted" or "captured"
+	Country        string             `json:"country,omitempty" bson:"country,omitempty"`
+	AddressCountry string             `json:"address_country,omitempty" bson:"address_country,omitempty"`
+	Status         CountryCheckStatus `json:"status" bson:"status"`
+	Reason         *CheckReason       `json:"reason,omitempty" bson:"reason,omitempty"`
+}
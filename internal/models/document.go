@@ -0,0 +1,231 @@
+package models
+
+import (
+	"encoding/json"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// DocumentRecord is a document as stored by this service: the shared document
+// model plus the fields only this service writes. Documents live in their own
+// collection, keyed by document_id and looked up by applicant_id.
+type DocumentRecord struct {
+	coreModels.Document `bson:",inline"`
+	ClientID            string            `json:"-" bson:"client_id"` // Client of the applicant, for scoping lookups
+	Flags               []DocumentFlag    `json:"flags,omitempty" bson:"flags,omitempty"`
+	CountryCheck        *CountryCheck     `json:"country_check,omitempty" bson:"country_check,omitempty"`
+	Upload              *StorageUpload    `json:"upload,omitempty" bson:"upload,omitempty"`
+	Version             int               `json:"version,omitempty" bson:"version,omitempty"`                   // Unset on documents that were never replaced
+	Versions            []DocumentVersion `json:"-" bson:"versions,omitempty"`                                  // Files this document replaced, oldest first
+	Vendor              string            `json:"vendor,omitempty" bson:"vendor,omitempty"`                     // Verification vendor the document is submitted to
+	VendorRoute         string            `json:"vendor_route,omitempty" bson:"vendor_route,omitempty"`         // preferred, or failover when the preferred vendor was down
+	PreferredVendor     string            `json:"preferred_vendor,omitempty" bson:"preferred_vendor,omitempty"` // Set when the document failed over from it
+	ColdStorage         *ColdStorage      `json:"cold_storage,omitempty" bson:"cold_storage,omitempty"`         // Set once the file is moved to archival storage
+	Resubmission        *Resubmission     `json:"resubmission,omitempty" bson:"resubmission,omitempty"`         // Set while the provider waits for a better file
+}
+
+// utc returns a copy of the document with its times in UTC
+func (d DocumentRecord) utc() DocumentRecord {
+	d.CreatedAt, d.UpdatedAt, d.DeletedAt = timestamp.UTC(d.CreatedAt), timestamp.UTC(d.UpdatedAt), timestamp.UTCPtr(d.DeletedAt)
+	return d
+}
+
+// MarshalJSON gives the document's times in UTC whatever zone they were set in
+func (d DocumentRecord) MarshalJSON() ([]byte, error) {
+	type document DocumentRecord
+	return json.Marshal(document(d.utc()))
+}
+
+// CurrentVersion returns the version number of the document's current file
+func (d DocumentRecord) CurrentVersion() int {
+	if d.Version == 0 {
+		return 1
+	}
+	return d.Version
+}
+
+// DocumentVersion is a file that has since been replaced, kept with the outcome it had
+type DocumentVersion struct {
+	Version      int                       `json:"version" bson:"version"`
+	FileURL      string                    `json:"-" bson:"file_url"`
+	FileSize     int64                     `json:"file_size" bson:"file_size"`
+	Status       coreModels.DocumentStatus `json:"status" bson:"status"`
+	CountryCheck *CountryCheck             `json:"country_check,omitempty" bson:"country_check,omitempty"`
+	Flags        []DocumentFlag            `json:"flags,omitempty" bson:"flags,omitempty"`
+	UploadedAt   time.Time                 `json:"uploaded_at" bson:"uploaded_at"`
+	ReplacedAt   time.Time                 `json:"replaced_at" bson:"replaced_at"`
+	ReplacedBy   string                    `json:"replaced_by" bson:"replaced_by"`
+	Archived     bool                      `json:"archived,omitempty" bson:"archived,omitempty"` // The file was in archival storage when it was replaced and cannot be downloaded
+}
+
+// ContentType derives the version's MIME type from the extension its file was stored under
+func (v DocumentVersion) ContentType() string {
+	if mimeType := mime.TypeByExtension(path.Ext(v.FileURL)); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// DocumentHistory lists the files a document has been replaced from
+type DocumentHistory struct {
+	DocumentID     string            `json:"document_id"`
+	CurrentVersion int               `json:"current_version"`
+	Versions       []DocumentVersion `json:"versions"` // Oldest first, excluding the current file
+}
+
+// ColdStorageState is whether an archived document's file can be downloaded
+type ColdStorageState string
+
+const (
+	ColdStorageArchived  ColdStorageState = "archived"  // The file must be restored before it can be downloaded
+	ColdStorageRestoring ColdStorageState = "restoring" // A restore was asked for and is under way
+	ColdStorageRestored  ColdStorageState = "restored"  // A copy of the file can be downloaded until RestoredUntil
+)
+
+// ColdStorage records a document's file being moved to archival storage and restored from it
+type ColdStorage struct {
+	State              ColdStorageState `json:"state" bson:"state"`
+	StorageClass       string           `json:"storage_class" bson:"storage_class"`
+	ArchivedAt         time.Time        `json:"archived_at" bson:"archived_at"`
+	RestoreRequestedAt *time.Time       `json:"restore_requested_at,omitempty" bson:"restore_requested_at,omitempty"`
+	RestoredUntil      *time.Time       `json:"restored_until,omitempty" bson:"restored_until,omitempty"` // When the restored copy goes again
+}
+
+// Downloadable reports whether the file can be downloaded at a time. Files that were never
+// archived always can.
+func (c *ColdStorage) Downloadable(at time.Time) bool {
+	return c == nil || (c.State == ColdStorageRestored && c.RestoredUntil != nil && at.Before(*c.RestoredUntil))
+}
+
+// MarshalJSON gives the times in UTC whatever zone they were set in
+func (c ColdStorage) MarshalJSON() ([]byte, error) {
+	type coldStorage ColdStorage
+	c.ArchivedAt, c.RestoreRequestedAt, c.RestoredUntil = timestamp.UTC(c.ArchivedAt), timestamp.UTCPtr(c.RestoreRequestedAt), timestamp.UTCPtr(c.RestoredUntil)
+	return json.Marshal(coldStorage(c))
+}
+
+// PlaceholderFileURL is stored as the file URL until the file has reached S3
+const PlaceholderFileURL = "placeholder"
+
+// UploadState tracks whether a document's file has reached S3
+type UploadState string
+
+const (
+	UploadPending     UploadState = "pending"     // Record saved, file not yet in S3
+	UploadQuarantined UploadState = "quarantined" // File is in quarantine, not yet moved to its permanent key
+	UploadScanning    UploadState = "scanning"    // Directly uploaded file is in quarantine until its malware scan passes
+	UploadStored      UploadState = "stored"      // File is in S3 and FileURL points to it
+	UploadFailed      UploadState = "failed"      // Retries exhausted or no staged copy; the client must re-upload
+)
+
+// StorageUpload records the progress of moving a document's file into S3
+type StorageUpload struct {
+	State         UploadState `json:"state" bson:"state"`
+	FileName      string      `json:"-" bson:"file_name,omitempty"`
+	MimeType      string      `json:"-" bson:"mime_type,omitempty"`
+	StagedPath    string      `json:"-" bson:"staged_path,omitempty"`    // Local copy kept until the file is in S3
+	QuarantineURL string      `json:"-" bson:"quarantine_url,omitempty"` // Quarantined file still to be moved to its permanent key
+	Attempts      int         `json:"attempts" bson:"attempts"`
+	LastError     string      `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	LastAttemptAt *time.Time  `json:"last_attempt_at,omitempty" bson:"last_attempt_at,omitempty"`
+	JobID         string      `json:"-" bson:"job_id,omitempty"`          // Upload job whose progress the reconciler completes
+	Checksum      string      `json:"-" bson:"checksum_sha256,omitempty"` // Declared SHA-256 of a directly uploaded file, checked again by its scan
+}
+
+// DocumentFlag marks a document for reviewer attention without rejecting it
+type DocumentFlag struct {
+	Code     string    `json:"code" bson:"code"`
+	Message  string    `json:"message" bson:"message"`
+	RaisedAt time.Time `json:"raised_at" bson:"raised_at"`
+}
+
+// ResubmissionGuidance tells an end user what to fix in a document a provider asked them
+// to submit again. Each provider's reasons are mapped onto these.
+type ResubmissionGuidance string
+
+const (
+	GuidanceBlurryImage     ResubmissionGuidance = "blurry_image"     // The photo is out of focus or too poor to read
+	GuidanceCroppedDocument ResubmissionGuidance = "cropped_document" // Part of the document, or one of its pages, is missing
+	GuidanceExpiredDocument ResubmissionGuidance = "expired_document"
+	GuidanceNameMismatch    ResubmissionGuidance = "name_mismatch" // The name on the document is not the applicant's
+)
+
+// Resubmission records a provider asking for a document to be submitted again. It is
+// cleared when the document is replaced.
+type Resubmission struct {
+	Provider        string                 `json:"provider" bson:"provider"`
+	Guidance        []ResubmissionGuidance `json:"guidance" bson:"guidance"`            // Empty when none of the provider's reasons has a counterpart
+	ProviderReasons []string               `json:"-" bson:"provider_reasons,omitempty"` // The provider's own codes, for support
+	RequestedAt     time.Time              `json:"requested_at" bson:"requested_at"`
+}
+
+// MarshalJSON gives the time in UTC whatever zone it was set in
+func (r Resubmission) MarshalJSON() ([]byte, error) {
+	type resubmission Resubmission
+	r.RequestedAt = timestamp.UTC(r.RequestedAt)
+	return json.Marshal(resubmission(r))
+}
+
+// CountryCheckStatus is the outcome of comparing claimed and detected issuing countries
+type CountryCheckStatus string
+
+const (
+	CountryMatch        CountryCheckStatus = "match"
+	CountryMismatch     CountryCheckStatus = "mismatch"
+	CountryUndetermined CountryCheckStatus = "undetermined" // No readable source for the issuing country
+)
+
+// CountryCheck records the claimed issuing country against the one read from the document
+type CountryCheck struct {
+	Claimed  string             `json:"claimed" bson:"claimed"`
+	Detected string             `json:"detected,omitempty" bson:"detected,omitempty"`
+	Source   string             `json:"source,omitempty" bson:"source,omitempty"` // e.g. "mrz"
+	Status   CountryCheckStatus `json:"status" bson:"status"`
+	Reason   *CheckReason       `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+// CheckReason is a structured, machine readable explanation of a check outcome
+type CheckReason struct {
+	Code    string `json:"code" bson:"code"`
+	Message string `json:"message" bson:"message"`
+}
+
+// DocumentDownload is who a document's file is given to and why, stamped on the file
+// when its client watermarks downloads
+type DocumentDownload struct {
+	Viewer  string // ID of the admin or client downloading the file
+	Purpose string
+}
+
+// PreviewRequest asks for a preview of a document's file
+type PreviewRequest struct {
+	Download    DocumentDownload
+	Watermark   bool   // Stamp the preview even when the client does not watermark downloads
+	IfNoneMatch string // ETag of a preview the caller already has
+}
+
+// DocumentPreview is a downscaled JPEG of a document's file. Content is nil when the
+// caller already has the preview named by ETag.
+type DocumentPreview struct {
+	ETag        string
+	Content     []byte
+	Watermarked bool
+}
+
+// DocumentArchiveEntry describes one document in the manifest.json of a document archive
+type DocumentArchiveEntry struct {
+	DocumentID   string                    `json:"document_id"`
+	DocumentType coreModels.DocumentType   `json:"document_type"`
+	Country      string                    `json:"country"`
+	Status       coreModels.DocumentStatus `json:"status"`
+	Version      int                       `json:"version"`
+	FileSize     int64                     `json:"file_size"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	File         string                    `json:"file,omitempty"`    // Path of the file in the archive, unset when it is missing
+	Missing      string                    `json:"missing,omitempty"` // Why the file is not in the archive
+	Watermarked  bool                      `json:"watermarked,omitempty"`
+}
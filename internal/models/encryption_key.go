@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// EncryptionKeyStatus is how far a client's own KMS key has been taken into use
+type EncryptionKeyStatus string
+
+const (
+	EncryptionKeyRegistered EncryptionKeyStatus = "registered" // Waiting to be validated
+	EncryptionKeyValidated  EncryptionKeyStatus = "validated"  // The service can use it; ready to activate
+	EncryptionKeyInvalid    EncryptionKeyStatus = "invalid"    // Failed its last validation
+	EncryptionKeyMigrating  EncryptionKeyStatus = "migrating"  // New data is encrypted under it while existing applicants are moved to it
+	EncryptionKeyActive     EncryptionKeyStatus = "active"     // Every applicant is encrypted under it
+	EncryptionKeyRetired    EncryptionKeyStatus = "retired"    // Replaced by a later key
+)
+
+// EncryptionKey is a KMS key a client brings for its data to be encrypted under, such as
+// one holding key material the client imported. It is kept in the encryption_keys
+// collection from registration until it is replaced.
+type EncryptionKey struct {
+	EncryptionKeyID string              `json:"encryption_key_id" bson:"encryption_key_id"`
+	ClientID        string              `json:"-" bson:"client_id"`
+	KeyARN          string              `json:"key_arn" bson:"key_arn"`
+	Status          EncryptionKeyStatus `json:"status" bson:"status"`
+	RegisteredAt    time.Time           `json:"registered_at" bson:"registered_at"`
+	ValidatedAt     *time.Time          `json:"validated_at,omitempty" bson:"validated_at,omitempty"`
+	ValidationError string              `json:"validation_error,omitempty" bson:"validation_error,omitempty"`
+	ActivatedAt     *time.Time          `json:"activated_at,omitempty" bson:"activated_at,omitempty"`
+	// CutoverAt is when every replica makes new data keys under the key. Applicants created
+	// before it are moved to the key by the migration.
+	CutoverAt *time.Time    `json:"cutover_at,omitempty" bson:"cutover_at,omitempty"`
+	RetiredAt *time.Time    `json:"retired_at,omitempty" bson:"retired_at,omitempty"`
+	Migration *KeyMigration `json:"migration,omitempty" bson:"migration,omitempty"`
+}
+
+// KeyMigration is the progress of moving a client's existing applicants to its new key
+type KeyMigration struct {
+	Total    int64 `json:"total" bson:"total"`       // Applicants created before the cutover
+	Migrated int64 `json:"migrated" bson:"migrated"` // Of those, applicants encrypted under the key
+	// Failed counts applicants that could not be moved in the last run. They are tried again on the next.
+	Failed       int64      `json:"failed" bson:"failed"`
+	FilesSkipped int64      `json:"files_skipped" bson:"files_skipped"` // Files left under the old key, such as those in archival storage
+	LastError    string     `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at" bson:"updated_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
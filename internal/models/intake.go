@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+// IntakeState is how far an applicant created from a document has been confirmed by its client
+type IntakeState string
+
+const (
+	IntakeProvisional IntakeState = "provisional" // Details were read from the document and await the client
+	IntakeConfirmed   IntakeState = "confirmed"
+)
+
+// ApplicantIntake records that an applicant was created from one of its documents rather
+// than from details sent by the client, stored on the applicant record under "intake".
+// What was read from the document is kept sealed, so corrections can be told apart from
+// confirmations, and is only returned when the applicant is created.
+type ApplicantIntake struct {
+	State       IntakeState       `json:"state" bson:"state"`
+	DocumentID  string            `json:"document_id" bson:"document_id"` // The document the details were read from
+	Extracted   ExtractedIdentity `json:"-" bson:"extracted"`
+	Corrected   []string          `json:"corrected,omitempty" bson:"corrected,omitempty"` // Fields the client changed from what was read
+	ConfirmedAt *time.Time        `json:"confirmed_at,omitempty" bson:"confirmed_at,omitempty"`
+	DataKey     []byte            `json:"-" bson:"data_key" pii:"key"`
+}
+
+// ExtractedIdentity is what was read from an identity document
+type ExtractedIdentity struct {
+	Source         string `json:"source" bson:"source"` // mrz
+	FirstName      string `json:"first_name" bson:"first_name" pii:"encrypt"`
+	MiddleName     string `json:"middle_name" bson:"middle_name" pii:"encrypt"`
+	LastName       string `json:"last_name" bson:"last_name" pii:"encrypt"`
+	DOB            string `json:"dob" bson:"dob" pii:"encrypt"` // As YYYY-MM-DD
+	Nationality    string `json:"nationality" bson:"nationality"`
+	IssuingCountry string `json:"issuing_country" bson:"issuing_country"`
+}
+
+// DocumentIntake is an applicant created from an uploaded document, with the document
+type DocumentIntake struct {
+	ApplicantID string
+	Intake      ApplicantIntake
+	Extracted   ExtractedIdentity
+	Upload      UploadResult
+}
+
+// ApplicantConfirmation holds what a client confirms a provisional applicant with. Names
+// and date of birth replace those read from the document when given; the contact details
+// and address, which a document does not hold, are required.
+type ApplicantConfirmation struct {
+	FirstName  *string               `json:"first_name"`
+	MiddleName *string               `json:"middle_name"`
+	LastName   *string               `json:"last_name"`
+	DOB        *string               `json:"dob"`
+	Email      string                `json:"email" binding:"required"`
+	Phone      string                `json:"phone" binding:"required"`
+	Address    coreModels.RawAddress `json:"address" binding:"required"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// JobRunStatus is the outcome of a run of a scheduled job
+type JobRunStatus string
+
+const (
+	JobRunning   JobRunStatus = "running"
+	JobSucceeded JobRunStatus = "succeeded"
+	JobFailed    JobRunStatus = "failed"
+)
+
+// What started a job run
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual" // Started from the admin API
+)
+
+// JobState is what replicas share about a scheduled job: whether it is paused, when it
+// is next due, and which replica holds its lock while it runs
+type JobState struct {
+	Name        string       `json:"name" bson:"name"`
+	Paused      bool         `json:"paused" bson:"paused"`
+	PausedBy    string       `json:"paused_by,omitempty" bson:"paused_by,omitempty"`
+	PausedAt    *time.Time   `json:"paused_at,omitempty" bson:"paused_at,omitempty"`
+	NextRunAt   *time.Time   `json:"next_run_at,omitempty" bson:"next_run_at,omitempty"`
+	LockedBy    string       `json:"locked_by,omitempty" bson:"locked_by,omitempty"`       // Instance running the job
+	LockedUntil *time.Time   `json:"locked_until,omitempty" bson:"locked_until,omitempty"` // Renewed while the job runs; another replica may take the lock after it
+	LastRunAt   *time.Time   `json:"last_run_at,omitempty" bson:"last_run_at,omitempty"`
+	LastStatus  JobRunStatus `json:"last_status,omitempty" bson:"last_status,omitempty"`
+}
+
+// JobStatus is a scheduled job as the admin API lists it
+type JobStatus struct {
+	JobState
+	Schedule string `json:"schedule,omitempty"` // Empty for jobs that only run when triggered
+	Running  bool   `json:"running"`
+}
+
+// JobRun records one run of a scheduled job
+type JobRun struct {
+	RunID       string       `json:"run_id" bson:"run_id"`
+	Job         string       `json:"job" bson:"job"`
+	Trigger     string       `json:"trigger" bson:"trigger"`
+	TriggeredBy string       `json:"triggered_by,omitempty" bson:"triggered_by,omitempty"` // Admin who started a manual run
+	Instance    string       `json:"instance" bson:"instance"`
+	Status      JobRunStatus `json:"status" bson:"status"`
+	Error       string       `json:"error,omitempty" bson:"error,omitempty"`
+	StartedAt   time.Time    `json:"started_at" bson:"started_at"`
+	FinishedAt  *time.Time   `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+	DurationMS  int64        `json:"duration_ms,omitempty" bson:"duration_ms,omitempty"`
+	ExpiresAt   time.Time    `json:"-" bson:"expires_at"` // Removed from history by a TTL index
+}
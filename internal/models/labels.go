@@ -0,0 +1,15 @@
+package models
+
+// Label is the display text of a code, in the language of the request
+type Label struct {
+	Code  string `json:"code"`
+	Label string `json:"label"`
+}
+
+// Labels are the display texts of the codes the API returns, for clients showing them to
+// applicants or reviewers
+type Labels struct {
+	Language      string             `json:"language"`
+	DocumentTypes []Label            `json:"document_types"`
+	ReasonCodes   map[string][]Label `json:"reason_codes"` // Keyed by decision
+}
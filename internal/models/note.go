@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// NoteVisibility controls who can read a case note
+type NoteVisibility string
+
+const (
+	NoteInternal NoteVisibility = "internal" // Reviewers only
+	NoteShared   NoteVisibility = "shared"   // Reviewers and the client
+)
+
+// Author types recorded on notes
+const (
+	NoteAuthorAdmin  = "admin"
+	NoteAuthorClient = "client"
+)
+
+// Note is a timestamped comment on an applicant. Notes live in their own
+// collection rather than on the applicant so a long-running case does not
+// grow the applicant document without bound.
+type Note struct {
+	NoteID      string         `json:"note_id" bson:"note_id"`
+	ApplicantID string         `json:"applicant_id" bson:"applicant_id"`
+	ClientID    string         `json:"client_id" bson:"client_id"`
+	Body        string         `json:"body" bson:"body"`
+	Visibility  NoteVisibility `json:"visibility" bson:"visibility"`
+	AuthorID    string         `json:"author_id" bson:"author_id"`
+	AuthorType  string         `json:"author_type" bson:"author_type"` // "admin" or "client"
+	CreatedAt   time.Time      `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// ClientVolume counts the applicants and documents a client created in a time window
+type ClientVolume struct {
+	ClientID   string `json:"client_id" bson:"_id"`
+	Applicants int64  `json:"applicants"`
+	Documents  int64  `json:"documents"`
+}
+
+// WebhookDeliveryStats counts a client's webhook events created in a time window by delivery state
+type WebhookDeliveryStats struct {
+	ClientID  string `json:"client_id"`
+	Pending   int64  `json:"pending"`
+	Delivered int64  `json:"delivered"`
+	Failed    int64  `json:"failed"`
+	// FailureRate is the share of finished events whose delivery attempts were exhausted
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// QueueDepth counts the work waiting across all clients
+type QueueDepth struct {
+	ReviewQueue       int64 `json:"review_queue"`
+	ReviewUnassigned  int64 `json:"review_unassigned"`
+	WebhooksPending   int64 `json:"webhooks_pending"`
+	WebhooksDue       int64 `json:"webhooks_due"`        // Pending events whose next attempt time has passed
+	UploadsAwaitingS3 int64 `json:"uploads_awaiting_s3"` // Documents whose file has not reached S3 yet
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Rekey is the outcome of re-encrypting an applicant's personal data and the data keys of
+// its documents' files under the current KMS key
+type Rekey struct {
+	ApplicantID  string        `json:"applicant_id"`
+	RekeyedAt    time.Time     `json:"rekeyed_at"`
+	PII          bool          `json:"pii"`           // Whether the applicant had encrypted fields to re-encrypt
+	FilesRekeyed int           `json:"files_rekeyed"` // Files of documents and their earlier versions
+	Skipped      []SkippedFile `json:"skipped,omitempty"`
+}
+
+// SkippedFile is a document file whose data key was left as it was
+type SkippedFile struct {
+	DocumentID string `json:"document_id"`
+	Version    int    `json:"version"`
+	Reason     string `json:"reason"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ReportStatus is how far a verification report has been generated
+type ReportStatus string
+
+const (
+	ReportPending ReportStatus = "pending" // Waiting for the applicant_report job
+	ReportReady   ReportStatus = "ready"
+	ReportFailed  ReportStatus = "failed"
+)
+
+// ApplicantReport is a PDF summary of an applicant's verification, kept in the
+// applicant_reports collection while it is generated in the background and for a while after
+type ApplicantReport struct {
+	ReportID    string       `json:"report_id" bson:"report_id"`
+	ApplicantID string       `json:"applicant_id" bson:"applicant_id"`
+	ClientID    string       `json:"-" bson:"client_id"`
+	Status      ReportStatus `json:"status" bson:"status"`
+	Purpose     string       `json:"-" bson:"purpose"` // Stamped on the report's thumbnails when the client watermarks downloads
+	RequestedAt time.Time    `json:"requested_at" bson:"requested_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	Pages       int          `json:"pages,omitempty" bson:"pages,omitempty"`
+	Error       string       `json:"error,omitempty" bson:"error,omitempty"`
+	Content     []byte       `json:"-" bson:"content,omitempty"`      // The PDF, once ready
+	DocumentIDs []string     `json:"-" bson:"document_ids,omitempty"` // Documents the PDF shows, for the audit log
+	ExpiresAt   time.Time    `json:"expires_at" bson:"expires_at"`
+}
+
+// Formats compliance reports are written in
+const (
+	ReportFormatCSV  = "csv"
+	ReportFormatJSON = "json"
+)
+
+// ComplianceReport is a client's report to its regulator of the verifications performed,
+// their outcomes, how long they took and the erasures executed over a period. It is kept
+// in the compliance_reports collection while the compliance_report job generates it, and
+// for a while after.
+type ComplianceReport struct {
+	ReportID    string       `json:"report_id" bson:"report_id"`
+	ClientID    string       `json:"-" bson:"client_id"`
+	Format      string       `json:"format" bson:"format"`
+	From        time.Time    `json:"from" bson:"from"`
+	To          time.Time    `json:"to" bson:"to"` // Exclusive
+	Status      ReportStatus `json:"status" bson:"status"`
+	RequestedAt time.Time    `json:"requested_at" bson:"requested_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	Error       string       `json:"error,omitempty" bson:"error,omitempty"`
+	Content     []byte       `json:"-" bson:"content,omitempty"` // The CSV or JSON file, once ready
+	ExpiresAt   time.Time    `json:"expires_at" bson:"expires_at"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReviewDecision is the outcome a reviewer records on an applicant
+type ReviewDecision string
+
+const (
+	DecisionApprove ReviewDecision = "approve"
+	DecisionReject  ReviewDecision = "reject"
+)
+
+// Reason codes a reviewer must supply with each decision
+var reviewReasonCodes = map[ReviewDecision][]string{
+	DecisionApprove: {"checks_passed", "manual_verification", "false_positive_cleared"},
+	DecisionReject:  {"document_forged", "document_expired", "identity_mismatch", "sanctions_match", "poor_quality", "fraud_suspected", "other"},
+}
+
+// ReasonCodes returns the reason codes accepted for a decision
+func ReasonCodes(decision ReviewDecision) []string {
+	return reviewReasonCodes[decision]
+}
+
+// ValidateReasonCode checks that the reason code is allowed for the decision
+func ValidateReasonCode(decision ReviewDecision, code string) error {
+	for _, allowed := range reviewReasonCodes[decision] {
+		if allowed == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid reason code %q for decision %s", code, decision)
+}
+
+// Status returns the applicant status a decision moves the applicant to
+func (d ReviewDecision) Status() ApplicantStatus {
+	if d == DecisionApprove {
+		return ApplicantApproved
+	}
+	return ApplicantRejected
+}
+
+// Time reviewers have to act on an applicant in each queue state
+var reviewSLAs = map[ApplicantStatus]time.Duration{
+	ApplicantInReview:             24 * time.Hour,
+	ApplicantResubmissionRequired: 72 * time.Hour,
+}
+
+// ReviewQueueStatuses are the applicant statuses that place an applicant in the review queue
+var ReviewQueueStatuses = []ApplicantStatus{ApplicantInReview, ApplicantResubmissionRequired}
+
+// ReviewQueueItem is one applicant awaiting review, as shown to reviewers
+type ReviewQueueItem struct {
+	ApplicantID         string          `json:"applicant_id" bson:"applicant_id"`
+	ClientID            string          `json:"client_id" bson:"client_id"`
+	FirstName           string          `json:"first_name" bson:"first_name"`
+	LastName            string          `json:"last_name" bson:"last_name"`
+	VerificationLevel   string          `json:"verification_level" bson:"verification_level"`
+	Status              ApplicantStatus `json:"status" bson:"status"`
+	Review              Review          `json:"review" bson:"review"`
+	UpdatedAt           time.Time       `json:"updated_at" bson:"updated_at"`
+	SLADueAt            time.Time       `json:"sla_due_at" bson:"-"`
+	SLARemainingSeconds int64           `json:"sla_remaining_seconds" bson:"-"`
+	SLABreached         bool            `json:"sla_breached" bson:"-"`
+}
+
+// ApplySLA fills in the SLA timer fields relative to now. The timer starts when the
+// applicant entered its review state, falling back to its last update for older records.
+func (i *ReviewQueueItem) ApplySLA(now time.Time) {
+	start := i.UpdatedAt
+	if i.Review.EnteredAt != nil {
+		start = *i.Review.EnteredAt
+	}
+	i.SLADueAt = start.Add(reviewSLAs[i.Status])
+	remaining := i.SLADueAt.Sub(now)
+	i.SLARemainingSeconds = int64(remaining.Seconds())
+	i.SLABreached = remaining < 0
+}
+
+// ReviewQueueFilter narrows the review queue listing
+type ReviewQueueFilter struct {
+	Statuses   []ApplicantStatus
+	AssignedTo *string // nil for any assignee, empty string for unassigned only
+	ClientID   string
+	Limit      int64
+}
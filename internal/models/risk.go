@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RiskSeverity grades how strongly a signal should weigh in an applicant's risk assessment
+type RiskSeverity string
+
+const (
+	RiskLow    RiskSeverity = "low"
+	RiskMedium RiskSeverity = "medium"
+	RiskHigh   RiskSeverity = "high"
+)
+
+// RiskSignal is one piece of evidence recorded against an applicant under "risk_signals"
+type RiskSignal struct {
+	Code       string       `json:"code" bson:"code"`
+	Severity   RiskSeverity `json:"severity" bson:"severity"`
+	Source     string       `json:"source" bson:"source"` // Subsystem that raised the signal
+	DocumentID string       `json:"document_id,omitempty" bson:"document_id,omitempty"`
+	Detail     string       `json:"detail" bson:"detail"`
+	CreatedAt  time.Time    `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// SessionStatus is how far an applicant has got through a hosted verification session
+type SessionStatus string
+
+const (
+	SessionCreated   SessionStatus = "created"
+	SessionOpened    SessionStatus = "opened"    // The applicant has loaded the hosted page
+	SessionSubmitted SessionStatus = "submitted" // At least one document has been uploaded
+	SessionCompleted SessionStatus = "completed" // The applicant has finished; no more uploads are accepted
+	SessionExpired   SessionStatus = "expired"   // Never stored; reported once a session is past its expiry unfinished
+)
+
+// SessionChannel is how the applicant reached the hosted page
+type SessionChannel string
+
+const (
+	ChannelLink      SessionChannel = "link"       // The link the client handed to the applicant
+	ChannelQRHandoff SessionChannel = "qr_handoff" // The QR code shown on the hosted page, to carry on with a phone
+)
+
+// VerificationSession lets a client's end user upload documents for one applicant
+// directly to us, through a link the client hands them. The link's token is signed
+// rather than stored, so it cannot be listed or recovered once issued.
+type VerificationSession struct {
+	SessionID   string        `json:"session_id" bson:"session_id"`
+	ClientID    string        `json:"-" bson:"client_id"`
+	ApplicantID string        `json:"applicant_id" bson:"applicant_id"`
+	Status      SessionStatus `json:"status" bson:"status"`
+	DocumentIDs []string      `json:"document_ids" bson:"document_ids"` // Documents uploaded through the session
+	CreatedAt   time.Time     `json:"created_at" bson:"created_at"`
+	ExpiresAt   time.Time     `json:"expires_at" bson:"expires_at"`
+	OpenedAt    *time.Time    `json:"opened_at,omitempty" bson:"opened_at,omitempty"`
+	SubmittedAt *time.Time    `json:"submitted_at,omitempty" bson:"submitted_at,omitempty"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	// HandedOffAt is when the QR code to carry on with a phone was first shown
+	HandedOffAt  *time.Time     `json:"handed_off_at,omitempty" bson:"handed_off_at,omitempty"`
+	CompletedVia SessionChannel `json:"completed_via,omitempty" bson:"completed_via,omitempty"`
+}
+
+// StatusAt is the session's status at a time, which is expired once the session is past
+// its expiry without being completed
+func (s VerificationSession) StatusAt(now time.Time) SessionStatus {
+	if s.Status != SessionCompleted && !now.Before(s.ExpiresAt) {
+		return SessionExpired
+	}
+	return s.Status
+}
+
+// SessionLink is a newly created session with the token and URL that open it
+type SessionLink struct {
+	VerificationSession
+	Token string `json:"token"`
+	URL   string `json:"url,omitempty"` // Only set when a hosted page is configured
+}
+
+// SessionEventType is what a hosted session event reports
+type SessionEventType string
+
+const (
+	SessionDocumentReceived     SessionEventType = "document_received"     // A document was uploaded through the session
+	SessionVerificationProgress SessionEventType = "verification_progress" // An upload of the applicant's moved through the pipeline
+)
+
+// SessionEvent is pushed to hosted pages watching a session as its applicant's uploads
+// progress. Progress events are of the applicant's uploads, so carry no session ID.
+type SessionEvent struct {
+	Type             SessionEventType `json:"type"`
+	ClientID         string           `json:"-"`
+	ApplicantID      string           `json:"applicant_id"`
+	SessionID        string           `json:"session_id,omitempty"`
+	DocumentID       string           `json:"document_id"`
+	Stage            UploadJobStage   `json:"stage,omitempty"`
+	ProcessingStatus ProcessingStatus `json:"processing_status,omitempty"`
+	Error            string           `json:"error,omitempty"`
+	OccurredAt       time.Time        `json:"occurred_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ResponseSigningKey is an HMAC key that signs the API responses sent to a client. When a
+// key is rotated out it keeps signing alongside its replacement until it retires, so the
+// client can switch keys without rejecting responses.
+type ResponseSigningKey struct {
+	KeyID     string     `json:"key_id" bson:"key_id"`
+	ClientID  string     `json:"client_id" bson:"client_id"`
+	Secret    string     `json:"secret,omitempty" bson:"secret"` // Only returned when the key is created
+	CreatedBy string     `json:"created_by" bson:"created_by"`   // Admin who created the key
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	RetiresAt *time.Time `json:"retires_at,omitempty" bson:"retires_at,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ApplicantStats summarises a client's applicants without identifying any of them
+type ApplicantStats struct {
+	ApplicantsByStatus map[string]int64 `json:"applicants_by_status"`
+	// AverageTimeToVerificationSeconds is the mean time from creation to an approve or reject
+	// decision, over the VerifiedApplicants that have one
+	AverageTimeToVerificationSeconds float64          `json:"average_time_to_verification_seconds"`
+	VerifiedApplicants               int64            `json:"verified_applicants"`
+	RejectionReasons                 map[string]int64 `json:"rejection_reasons"`
+	DocumentTypes                    map[string]int64 `json:"document_types"`
+	GeneratedAt                      time.Time        `json:"generated_at"` // Stats may be cached for a few minutes
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Sumsub review statuses and answers, as its API gives them
+const (
+	SumsubReviewInit       = "init"
+	SumsubReviewPending    = "pending"
+	SumsubReviewPrechecked = "prechecked"
+	SumsubReviewQueued     = "queued"
+	SumsubReviewOnHold     = "onHold"
+	SumsubReviewCompleted  = "completed"
+
+	SumsubAnswerGreen = "GREEN"
+	SumsubAnswerRed   = "RED"
+
+	SumsubRejectFinal = "FINAL" // The applicant cannot try again
+	SumsubRejectRetry = "RETRY" // The applicant may resubmit
+)
+
+// What started a sync from Sumsub
+const (
+	SumsubSyncRequested = "request"  // POST /applicants/:id/sync
+	SumsubSyncScheduled = "schedule" // The sumsub_sync job
+)
+
+// How a sync resolved a difference between our record and Sumsub's
+const (
+	SyncKeptLocal = "kept_local" // Our record was left as it is
+)
+
+// SumsubReviewResult is Sumsub's verdict on an applicant or one of its documents
+type SumsubReviewResult struct {
+	ReviewAnswer     string   `json:"reviewAnswer"`
+	RejectLabels     []string `json:"rejectLabels,omitempty"`
+	ReviewRejectType string   `json:"reviewRejectType,omitempty"`
+}
+
+// SumsubReview is where an applicant's review at Sumsub has got to
+type SumsubReview struct {
+	ReviewStatus string             `json:"reviewStatus"`
+	ReviewResult SumsubReviewResult `json:"reviewResult"`
+}
+
+// SumsubInfo is the personal data Sumsub extracted from the applicant's documents
+type SumsubInfo struct {
+	FirstName string `json:"firstName,omitempty"`
+	LastName  string `json:"lastName,omitempty"`
+	DOB       string `json:"dob,omitempty"`
+	Country   string `json:"country,omitempty"`
+}
+
+// SumsubApplicantData is an applicant as Sumsub's API returns it
+type SumsubApplicantData struct {
+	ID     string       `json:"id"`
+	Review SumsubReview `json:"review"`
+	Info   SumsubInfo   `json:"info"`
+	// Raw is the whole response, stored as the applicant's sumsub_applicant
+	Raw json.RawMessage `json:"-"`
+}
+
+// SumsubInspection is Sumsub's verdict on one of the document sets it asked the applicant for
+type SumsubInspection struct {
+	DocSet       string   `json:"doc_set" bson:"doc_set"` // e.g. IDENTITY or SELFIE
+	DocType      string   `json:"doc_type,omitempty" bson:"doc_type,omitempty"`
+	Answer       string   `json:"answer,omitempty" bson:"answer,omitempty"` // Empty until Sumsub has reviewed it
+	RejectLabels []string `json:"reject_labels,omitempty" bson:"reject_labels,omitempty"`
+}
+
+// SumsubState is what the last sync read from Sumsub, stored on the applicant under "sumsub_sync"
+type SumsubState struct {
+	SumsubID     string    `json:"sumsub_id" bson:"sumsub_id"`
+	ReviewStatus string    `json:"review_status" bson:"review_status"`
+	ReviewAnswer string    `json:"review_answer,omitempty" bson:"review_answer,omitempty"`
+	SyncedAt     time.Time `json:"synced_at" bson:"synced_at"`
+}
+
+// SyncChange is a field a sync changed on our record
+type SyncChange struct {
+	Field string `json:"field" bson:"field"`
+	From  string `json:"from" bson:"from"`
+	To    string `json:"to" bson:"to"`
+}
+
+// SyncConflict is a field on which our record and Sumsub disagree and the sync did not follow Sumsub
+type SyncConflict struct {
+	Field      string `json:"field" bson:"field"`
+	Local      string `json:"local" bson:"local"`
+	Sumsub     string `json:"sumsub" bson:"sumsub"`
+	Resolution string `json:"resolution" bson:"resolution"`
+	Reason     string `json:"reason" bson:"reason"`
+}
+
+// SumsubSync records one sync of an applicant from Sumsub and what it changed
+type SumsubSync struct {
+	SyncID       string             `json:"sync_id" bson:"sync_id"`
+	ApplicantID  string             `json:"applicant_id" bson:"applicant_id"`
+	ClientID     string             `json:"-" bson:"client_id"`
+	Trigger      string             `json:"trigger" bson:"trigger"`
+	SumsubID     string             `json:"sumsub_id" bson:"sumsub_id"`
+	ReviewStatus string             `json:"review_status" bson:"review_status"`
+	ReviewAnswer string             `json:"review_answer,omitempty" bson:"review_answer,omitempty"`
+	Inspections  []SumsubInspection `json:"inspections" bson:"inspections"`
+	Changes      []SyncChange       `json:"changes" bson:"changes"`
+	Conflicts    []SyncConflict     `json:"conflicts" bson:"conflicts"`
+	SyncedAt     time.Time          `json:"synced_at" bson:"synced_at"`
+}
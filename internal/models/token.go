@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Grant types accepted by the token endpoint
+const (
+	GrantAPIKey       = "api_key"
+	GrantPassword     = "password"
+	GrantRefreshToken = "refresh_token"
+)
+
+// TokenPair is a short-lived access token and the refresh token that replaces it
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // Seconds until the access token expires
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenClaims are the claims carried by an access token
+type TokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"` // The client for API key grants, or the dashboard user
+	ClientID  string `json:"client_id"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// RefreshToken is a refresh token issued by the token endpoint. Only its hash is stored.
+// Each refresh token can be used once; using one again revokes its whole family.
+type RefreshToken struct {
+	TokenHash string     `bson:"token_hash"`
+	FamilyID  string     `bson:"family_id"` // Shared by the tokens descended from one sign-in
+	ClientID  string     `bson:"client_id"`
+	Subject   string     `bson:"subject"`
+	APIKeyID  string     `bson:"api_key_id,omitempty"` // The API key the family was signed in with, if any
+	CreatedAt time.Time  `bson:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at"` // The family's sign-in plus the refresh TTL
+	UsedAt    *time.Time `bson:"used_at"`
+	RevokedAt *time.Time `bson:"revoked_at"`
+}
+
+// DashboardUser is a member of a client's staff who signs in to the dashboard with a password
+type DashboardUser struct {
+	UserID       string    `json:"user_id" bson:"user_id"`
+	ClientID     string    `json:"client_id" bson:"client_id"`
+	Username     string    `json:"username" bson:"username"`
+	PasswordHash string    `json:"-" bson:"password_hash"`
+	Disabled     bool      `json:"disabled" bson:"disabled"`
+	CreatedBy    string    `json:"created_by" bson:"created_by"` // Admin who created the user
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+}
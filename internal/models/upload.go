@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UploadCheckStatus is the outcome of a single synchronous upload check
+type UploadCheckStatus string
+
+const (
+	UploadCheckPassed  UploadCheckStatus = "passed"
+	UploadCheckFailed  UploadCheckStatus = "failed"
+	UploadCheckSkipped UploadCheckStatus = "skipped"
+	UploadCheckFlagged UploadCheckStatus = "flagged" // Accepted, but marked for reviewer attention
+)
+
+// UploadCheck is the result of one check run while the upload request is in flight
+type UploadCheck struct {
+	Name   string            `json:"name" bson:"name"`
+	Status UploadCheckStatus `json:"status" bson:"status"`
+	Detail string            `json:"detail,omitempty" bson:"detail,omitempty"`
+	Code   string            `json:"code,omitempty" bson:"code,omitempty"` // What the applicant should fix, on failed image quality checks
+}
+
+// ProcessingStatus is the overall state of the upload pipeline once the synchronous checks ran
+type ProcessingStatus string
+
+const (
+	ProcessingAccepted       ProcessingStatus = "accepted"        // All synchronous checks passed
+	ProcessingScanPending    ProcessingStatus = "scan_pending"    // Some checks were deferred and will complete asynchronously
+	ProcessingRejected       ProcessingStatus = "rejected"        // At least one check failed
+	ProcessingStoragePending ProcessingStatus = "storage_pending" // Checks passed but the file is still being moved to storage
+)
+
+// UploadResult is the document metadata returned by an upload together with the check results
+type UploadResult struct {
+	DocumentRecord   `bson:",inline"`
+	Checks           []UploadCheck    `json:"checks"`
+	ProcessingStatus ProcessingStatus `json:"processing_status"`
+	JobID            string           `json:"job_id,omitempty"` // Progress of the upload, readable from any replica
+}
+
+// MarshalJSON writes the document's fields alongside the check results, which the
+// MarshalJSON promoted from DocumentRecord would leave out
+func (r UploadResult) MarshalJSON() ([]byte, error) {
+	type document DocumentRecord
+	return json.Marshal(struct {
+		document
+		Checks           []UploadCheck    `json:"checks"`
+		ProcessingStatus ProcessingStatus `json:"processing_status"`
+		JobID            string           `json:"job_id,omitempty"`
+	}{document(r.DocumentRecord.utc()), r.Checks, r.ProcessingStatus, r.JobID})
+}
+
+// OverallStatus derives the pipeline status from a set of check results
+func OverallStatus(checks []UploadCheck) ProcessingStatus {
+	status := ProcessingAccepted
+	for _, check := range checks {
+		switch check.Status {
+		case UploadCheckFailed:
+			return ProcessingRejected
+		case UploadCheckSkipped:
+			status = ProcessingScanPending
+		}
+	}
+	return status
+}
+
+// UploadJobStage is how far an upload has got through the pipeline
+type UploadJobStage string
+
+const (
+	UploadJobChecking  UploadJobStage = "checking"  // The file is being checked
+	UploadJobStoring   UploadJobStage = "storing"   // The file passed its checks and is on its way to storage
+	UploadJobCompleted UploadJobStage = "completed" // The file is stored; deferred checks may still be running
+	UploadJobFailed    UploadJobStage = "failed"    // The file was rejected or could not be stored
+)
+
+// UploadJob is the progress of one upload, kept outside process memory so any replica
+// behind the load balancer can report it
+type UploadJob struct {
+	JobID            string           `json:"job_id" bson:"job_id"`
+	ClientID         string           `json:"-" bson:"client_id"`
+	ApplicantID      string           `json:"applicant_id" bson:"applicant_id"`
+	DocumentID       string           `json:"document_id" bson:"document_id"`
+	Stage            UploadJobStage   `json:"stage" bson:"stage"`
+	ProcessingStatus ProcessingStatus `json:"processing_status,omitempty" bson:"processing_status,omitempty"`
+	Error            string           `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt        time.Time        `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at" bson:"updated_at"`
+	ExpiresAt        time.Time        `json:"-" bson:"expires_at"`
+}
+
+// DirectUploadRequest asks for a presigned URL to upload a file straight to S3
+type DirectUploadRequest struct {
+	ApplicantID    string `json:"applicant_id"`
+	DocumentType   string `json:"document_type" binding:"required"`
+	Country        string `json:"country" binding:"required"`
+	MimeType       string `json:"mime_type" binding:"required"`
+	FileSize       int64  `json:"file_size" binding:"required"`
+	ChecksumSHA256 string `json:"checksum_sha256" binding:"required"` // Base64 of the file's SHA-256 digest, which S3 checks the upload against
+}
+
+// DirectUpload is where and how to upload a file straight to S3. The upload is
+// registered by completing it with the upload token once the PUT has succeeded.
+type DirectUpload struct {
+	DocumentID  string            `json:"document_id"`
+	UploadToken string            `json:"upload_token"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"` // Must be sent with the upload exactly as given
+	ExpiresAt   time.Time         `json:"expires_at"`
+}
+
+// DirectUploadCompletion registers a file uploaded through a presigned URL
+type DirectUploadCompletion struct {
+	UploadToken string `json:"upload_token" binding:"required"`
+	MRZ         string `json:"mrz,omitempty"` // Cross-checked against the claimed country, as with form uploads
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Billable event types recorded per client
+const (
+	UsageApplicantCreated      = "applicant_created"
+	UsageDocumentProcessed     = "document_processed"
+	UsageVerificationCompleted = "verification_completed"
+	UsageScreeningRun          = "screening_run"
+)
+
+// UsageMonthFormat is the layout of the billing month of usage events
+const UsageMonthFormat = "2006-01"
+
+// UsageEvent is one billable event, stored in the metering collection
+type UsageEvent struct {
+	// EventKey identifies the work being billed, so the same work is never counted twice
+	EventKey   string     `json:"event_key" bson:"event_key"`
+	ClientID   string     `json:"client_id" bson:"client_id"`
+	Type       string     `json:"type" bson:"type"`
+	SubjectID  string     `json:"subject_id" bson:"subject_id"` // e.g. the applicant or document billed for
+	Month      string     `json:"month" bson:"month"`           // Billing month in UTC, e.g. "2026-10"
+	At         time.Time  `json:"at" bson:"at"`
+	ExportedAt *time.Time `json:"exported_at,omitempty" bson:"exported_at,omitempty"` // When the event was sent to the billing system
+}
+
+// MonthlyUsage counts a client's billable events in one month by type
+type MonthlyUsage struct {
+	Month  string           `json:"month"`
+	Counts map[string]int64 `json:"counts"`
+}
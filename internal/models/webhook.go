@@ -0,0 +1,195 @@
+package models
+
+import "time"
+
+// Webhook event types sent to clients
+const (
+	WebhookDocumentUploadFailed = "document.upload_failed"
+	WebhookDocumentRestored     = "document.restored"
+	WebhookSecurityAlert        = "security.alert"
+	WebhookReportCompleted      = "report.completed"
+
+	WebhookApplicantResubmissionRequired = "applicant.resubmission_required"
+)
+
+// WebhookEventStatus is the delivery state of a webhook event
+type WebhookEventStatus string
+
+const (
+	WebhookPending   WebhookEventStatus = "pending"
+	WebhookDelivered WebhookEventStatus = "delivered"
+	WebhookFailed    WebhookEventStatus = "failed" // Delivery attempts exhausted
+)
+
+// WebhookEndpoint is where a client receives webhook events. Each client has its own
+// signing secret. When the secret is rotated the previous one keeps signing deliveries
+// alongside it until it retires, so the client can switch secrets without rejecting events.
+type WebhookEndpoint struct {
+	ClientID                string          `json:"client_id" bson:"client_id"`
+	URL                     string          `json:"url" bson:"url"`
+	Secret                  string          `json:"secret,omitempty" bson:"secret"`
+	PreviousSecret          string          `json:"previous_secret,omitempty" bson:"previous_secret,omitempty"`
+	PreviousSecretRetiresAt *time.Time      `json:"previous_secret_retires_at,omitempty" bson:"previous_secret_retires_at,omitempty"`
+	SecretRotatedAt         *time.Time      `json:"secret_rotated_at,omitempty" bson:"secret_rotated_at,omitempty"`
+	Signing                 *WebhookSigning `json:"signing,omitempty" bson:"-"` // Set in place of the secrets when the endpoint is read
+	CreatedAt               time.Time       `json:"created_at" bson:"created_at"`
+	UpdatedAt               time.Time       `json:"updated_at" bson:"updated_at"`
+}
+
+// ActiveSecrets returns the secrets deliveries are signed with at a time, newest first
+func (e WebhookEndpoint) ActiveSecrets(at time.Time) []string {
+	secrets := []string{e.Secret}
+	if e.PreviousSecret != "" && e.PreviousSecretRetiresAt != nil && at.Before(*e.PreviousSecretRetiresAt) {
+		secrets = append(secrets, e.PreviousSecret)
+	}
+	return secrets
+}
+
+// SigningState describes the endpoint's signing secrets without revealing them
+func (e WebhookEndpoint) SigningState(at time.Time) WebhookSigning {
+	signing := WebhookSigning{SecretHint: SecretHint(e.Secret), RotatedAt: e.SecretRotatedAt}
+	if len(e.ActiveSecrets(at)) > 1 {
+		signing.PreviousSecretHint = SecretHint(e.PreviousSecret)
+		signing.PreviousSecretRetiresAt = e.PreviousSecretRetiresAt
+	}
+	return signing
+}
+
+// WebhookSigning is the state of a client's webhook signing secrets, as shown in its settings
+type WebhookSigning struct {
+	SecretHint              string     `json:"secret_hint"` // Enough of the secret to tell which one a client holds
+	RotatedAt               *time.Time `json:"rotated_at,omitempty"`
+	PreviousSecretHint      string     `json:"previous_secret_hint,omitempty"` // Set while the previous secret still signs
+	PreviousSecretRetiresAt *time.Time `json:"previous_secret_retires_at,omitempty"`
+}
+
+// secretHintLength covers a secret's "whsec_" prefix and its first few characters
+const secretHintLength = 12
+
+// SecretHint returns the start of a secret, enough to tell secrets apart but not to sign with
+func SecretHint(secret string) string {
+	if len(secret) <= secretHintLength {
+		return ""
+	}
+	return secret[:secretHintLength] + "..."
+}
+
+// WebhookVerification is the outcome of checking a webhook signature for a client
+type WebhookVerification struct {
+	Valid  bool   `json:"valid"`
+	Secret string `json:"secret,omitempty"` // "current" or "previous": the secret that made the signature
+	Reason string `json:"reason,omitempty"` // Why a signature is not valid
+}
+
+// WebhookEvent is an event waiting in the outbox to be delivered to a client
+type WebhookEvent struct {
+	EventID       string             `json:"event_id" bson:"event_id"`
+	ClientID      string             `json:"client_id" bson:"client_id"`
+	Type          string             `json:"type" bson:"type"`
+	Data          interface{}        `json:"data" bson:"data"`
+	Status        WebhookEventStatus `json:"status" bson:"status"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	NextAttemptAt time.Time          `json:"next_attempt_at" bson:"next_attempt_at"`
+	LastError     string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+	DeliveredAt   *time.Time         `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+	Redelivered   bool               `json:"redelivered,omitempty" bson:"redelivered,omitempty"` // Queued again from the dead-letter collection
+}
+
+// WebhookDeadLetter is an event whose delivery attempts ran out, kept until the client
+// has it redelivered. It shares its event's ID, which redelivery keeps, so receivers can
+// tell a redelivered event from a new one.
+type WebhookDeadLetter struct {
+	EventID       string      `json:"event_id" bson:"event_id"`
+	ClientID      string      `json:"client_id" bson:"client_id"`
+	Type          string      `json:"type" bson:"type"`
+	Data          interface{} `json:"data" bson:"data"`
+	Attempts      int         `json:"attempts" bson:"attempts"`
+	LastError     string      `json:"last_error" bson:"last_error"`
+	CreatedAt     time.Time   `json:"created_at" bson:"created_at"` // When the event was emitted
+	FailedAt      time.Time   `json:"failed_at" bson:"failed_at"`
+	Redeliveries  int         `json:"redeliveries" bson:"redeliveries"`
+	RedeliveredAt *time.Time  `json:"redelivered_at,omitempty" bson:"redelivered_at,omitempty"` // Set while a redelivery is in progress
+	RedeliveredBy string      `json:"redelivered_by,omitempty" bson:"redelivered_by,omitempty"`
+}
+
+// DocumentUploadFailedData is the payload of a document.upload_failed event
+type DocumentUploadFailedData struct {
+	ApplicantID  string `json:"applicant_id" bson:"applicant_id"`
+	DocumentID   string `json:"document_id" bson:"document_id"`
+	DocumentType string `json:"document_type" bson:"document_type"`
+	Reason       string `json:"reason" bson:"reason"`
+	Action       string `json:"action" bson:"action"` // What the client should do, e.g. "reupload"
+	// Annotations are the applicant's tags and metadata, for routing the event on the client's side
+	Annotations *Annotations `json:"annotations,omitempty" bson:"annotations,omitempty"`
+}
+
+// DocumentRestoredData is the payload of a document.restored event, sent once an archived
+// document's file can be downloaded again
+type DocumentRestoredData struct {
+	ApplicantID   string    `json:"applicant_id" bson:"applicant_id"`
+	DocumentID    string    `json:"document_id" bson:"document_id"`
+	DocumentType  string    `json:"document_type" bson:"document_type"`
+	RestoredUntil time.Time `json:"restored_until" bson:"restored_until"`
+	// Annotations are the applicant's tags and metadata, for routing the event on the client's side
+	Annotations *Annotations `json:"annotations,omitempty" bson:"annotations,omitempty"`
+}
+
+// ApplicantResubmissionData is the payload of an applicant.resubmission_required event,
+// sent when a provider asks the applicant to submit some of their documents again
+type ApplicantResubmissionData struct {
+	ApplicantID string                `json:"applicant_id" bson:"applicant_id"`
+	Provider    string                `json:"provider" bson:"provider"`
+	Documents   []ResubmittedDocument `json:"documents" bson:"documents"`
+	// Annotations are the applicant's tags and metadata, for routing the event on the client's side
+	Annotations *Annotations `json:"annotations,omitempty" bson:"annotations,omitempty"`
+}
+
+// ResubmittedDocument is a document the applicant must submit again and what to fix in it
+type ResubmittedDocument struct {
+	DocumentID   string                 `json:"document_id" bson:"document_id"`
+	DocumentType string                 `json:"document_type" bson:"document_type"`
+	Guidance     []ResubmissionGuidance `json:"guidance" bson:"guidance"`
+}
+
+// ComplianceReportData is the payload of a report.completed event, sent once a compliance
+// report is ready to download or has failed
+type ComplianceReportData struct {
+	ReportID string       `json:"report_id"`
+	Format   string       `json:"format"`
+	From     time.Time    `json:"from"`
+	To       time.Time    `json:"to"`
+	Status   ReportStatus `json:"status"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// Kinds of security alert sent with WebhookSecurityAlert
+const (
+	SecurityAlertNewCountry  = "api_key_new_country"
+	SecurityAlertRateAnomaly = "api_key_rate_anomaly"
+)
+
+// SecurityAlertData is the payload of a security alert about the use of one of the client's API keys
+type SecurityAlertData struct {
+	Kind              string    `json:"kind"`
+	KeyPrefix         string    `json:"key_prefix"` // Enough of the key to tell the client's keys apart
+	IP                string    `json:"ip"`
+	Country           string    `json:"country,omitempty"`
+	KnownCountries    []string  `json:"known_countries,omitempty"` // Countries the key was used from before
+	RequestsPerMinute int64     `json:"requests_per_minute,omitempty"`
+	BaselinePerMinute float64   `json:"baseline_per_minute,omitempty"` // Average over the previous hour
+	At                time.Time `json:"at"`
+}
+
+// WebhookTestResult is the outcome of sending a sample event to a client's webhook
+// endpoint. A failed delivery is reported here rather than as an error.
+type WebhookTestResult struct {
+	EventID         string `json:"event_id"`
+	Type            string `json:"type"`
+	URL             string `json:"url"`
+	Delivered       bool   `json:"delivered"`                  // The endpoint answered with a 2xx status
+	StatusCode      int    `json:"status_code,omitempty"`      // Unset when the endpoint could not be reached
+	LatencyMS       int64  `json:"latency_ms"`                 // Until the endpoint's response headers arrived
+	ResponseExcerpt string `json:"response_excerpt,omitempty"` // The start of the endpoint's response body
+	Error           string `json:"error,omitempty"`
+}
@@ -0,0 +1,116 @@
+// Package mongoclient connects to MongoDB with the pool, timeouts and read preference in
+// settings.mongo, and watches the connection: commands slower than a threshold are logged
+// with their collection and the shape of their filter, and the pool's use is published
+// under "mongo_pool" on the expvar endpoint so saturation shows before it hurts latency.
+package mongoclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongotx"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap"
+)
+
+// connectTimeout bounds the first connection, as common.ConnectDatabase does
+const connectTimeout = 10 * time.Second
+
+// Connect connects to the database like common.ConnectDatabase, which sets up the database
+// name and cache GetCollection relies on, then replaces the client it made from the URI
+// alone with one configured from settings. It also decides whether mongotx uses transactions.
+func Connect(cfg models.DatabaseConfig, settings config.MongoSettings) error {
+	opts, err := Options(cfg, settings)
+	if err != nil {
+		return err
+	}
+	if err := common.ConnectDatabase(cfg); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB with the configured options: %w", err)
+	}
+	previous := common.Client
+	common.Client = client
+	if previous != nil {
+		_ = previous.Disconnect(ctx)
+	}
+
+	transactions, err := mongotx.Configure(ctx, client, settings.Transactions)
+	if err != nil {
+		return err
+	}
+	zaplogger.GetLogger().Info("MongoDB connected", zap.Bool("transactions", transactions))
+	return nil
+}
+
+// Options returns the client options for the database and settings, with the slow query
+// and pool monitors attached
+func Options(cfg models.DatabaseConfig, settings config.MongoSettings) (*options.ClientOptions, error) {
+	opts := options.Client().ApplyURI(uri(cfg))
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid MongoDB connection string: %w", err)
+	}
+	if settings.Pool.MaxSize > 0 {
+		opts.SetMaxPoolSize(settings.Pool.MaxSize)
+	}
+	if settings.Pool.MinSize > 0 {
+		opts.SetMinPoolSize(settings.Pool.MinSize)
+	}
+	if settings.Pool.MaxConnecting > 0 {
+		opts.SetMaxConnecting(settings.Pool.MaxConnecting)
+	}
+	if settings.Pool.MaxIdleTime > 0 {
+		opts.SetMaxConnIdleTime(settings.Pool.MaxIdleTime)
+	}
+	if settings.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(settings.ConnectTimeout)
+	}
+	if settings.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(settings.ServerSelectionTimeout)
+	}
+	if mode := strings.TrimSpace(settings.ReadPreference); mode != "" {
+		parsed, err := readpref.ModeFromString(mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mongo.readPreference %q: %w", mode, err)
+		}
+		pref, err := readpref.New(parsed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mongo.readPreference %q: %w", mode, err)
+		}
+		opts.SetReadPreference(pref)
+	}
+
+	maxPoolSize := uint64(100) // The driver's default
+	if opts.MaxPoolSize != nil {
+		maxPoolSize = *opts.MaxPoolSize
+	}
+	pool.Set(statMaxSize, intVar(maxPoolSize))
+	opts.SetPoolMonitor(&event.PoolMonitor{Event: observePool})
+	if settings.SlowQueryThreshold > 0 {
+		opts.SetMonitor(NewSlowQueryMonitor(settings.SlowQueryThreshold))
+	}
+	return opts, nil
+}
+
+// uri is the connection string common.ConnectDatabase would use
+func uri(cfg models.DatabaseConfig) string {
+	if cfg.UseAtlas {
+		return cfg.AtlasConnectionURI
+	}
+	return fmt.Sprintf("mongodb://%s:%s@%s:%d/%s?authSource=admin&authMechanism=SCRAM-SHA-256",
+		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+}
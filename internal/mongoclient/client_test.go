@@ -0,0 +1,46 @@
+package mongoclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestOptions(t *testing.T) {
+	database := models.DatabaseConfig{UseAtlas: true, AtlasConnectionURI: "mongodb://mongo1.example.net:27017/?maxPoolSize=20"}
+	opts, err := Options(database, config.MongoSettings{
+		Pool:                   config.MongoPoolSettings{MaxSize: 200, MinSize: 10, MaxIdleTime: time.Minute},
+		ServerSelectionTimeout: 5 * time.Second,
+		ReadPreference:         "secondaryPreferred",
+		SlowQueryThreshold:     time.Second,
+	})
+	require.NoError(t, err)
+
+	// Settings override the connection string
+	assert.Equal(t, uint64(200), *opts.MaxPoolSize)
+	assert.Equal(t, uint64(10), *opts.MinPoolSize)
+	assert.Equal(t, time.Minute, *opts.MaxConnIdleTime)
+	assert.Equal(t, 5*time.Second, *opts.ServerSelectionTimeout)
+	assert.Equal(t, readpref.SecondaryPreferredMode, opts.ReadPreference.Mode())
+	assert.NotNil(t, opts.Monitor)
+	assert.NotNil(t, opts.PoolMonitor)
+	assert.Equal(t, "200", pool.Get(statMaxSize).String())
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	database := models.DatabaseConfig{UseAtlas: true, AtlasConnectionURI: "mongodb://mongo1.example.net:27017/?maxPoolSize=20"}
+	opts, err := Options(database, config.MongoSettings{})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(20), *opts.MaxPoolSize)
+	assert.Nil(t, opts.Monitor)
+	assert.Equal(t, "20", pool.Get(statMaxSize).String())
+
+	_, err = Options(database, config.MongoSettings{ReadPreference: "closest"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package mongoclient
+
+import (
+	"context"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/opsmetrics"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+	"go.uber.org/zap"
+)
+
+// Figures published under "mongo_pool" on the expvar endpoint, summed over every server
+const (
+	statMaxSize          = "max_size"          // Most connections each server's pool may open
+	statOpen             = "open"              // Connections open
+	statInUse            = "in_use"            // Connections checked out by an operation
+	statWaiting          = "waiting"           // Operations waiting for a connection
+	statCheckoutFailed   = "checkout_failed"   // Operations that never got a connection
+	statCheckoutTimeouts = "checkout_timeouts" // Of those, operations that waited too long
+	statCleared          = "cleared"           // Times a pool was cleared after a server error
+)
+
+// statSlow counts commands slower than the threshold, under "mongo_commands"
+const statSlow = "slow"
+
+var (
+	pool     = expvar.NewMap("mongo_pool")
+	commands = expvar.NewMap("mongo_commands")
+)
+
+func init() {
+	for _, name := range []string{statOpen, statInUse, statWaiting, statCheckoutFailed, statCheckoutTimeouts, statCleared} {
+		pool.Add(name, 0)
+	}
+	commands.Add(statSlow, 0)
+}
+
+func intVar(n uint64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(int64(n))
+	return v
+}
+
+// observePool keeps the pool figures up to date. The time operations wait for a connection
+// is also recorded as the mongo.checkout provider call.
+func observePool(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		pool.Add(statOpen, 1)
+	case event.ConnectionClosed:
+		pool.Add(statOpen, -1)
+	case event.GetStarted:
+		pool.Add(statWaiting, 1)
+	case event.GetSucceeded:
+		pool.Add(statWaiting, -1)
+		pool.Add(statInUse, 1)
+		opsmetrics.Providers.Record("mongo.checkout", e.Duration, opsmetrics.OK)
+	case event.GetFailed:
+		pool.Add(statWaiting, -1)
+		pool.Add(statCheckoutFailed, 1)
+		if e.Reason == event.ReasonTimedOut {
+			pool.Add(statCheckoutTimeouts, 1)
+		}
+		opsmetrics.Providers.Record("mongo.checkout", e.Duration, opsmetrics.Failure)
+	case event.ConnectionReturned:
+		pool.Add(statInUse, -1)
+	case event.PoolCleared:
+		pool.Add(statCleared, 1)
+	}
+}
+
+// slowQueries logs commands that take longer than a threshold. What each command ran
+// against is kept from when it starts until it finishes.
+type slowQueries struct {
+	threshold time.Duration
+	running   sync.Map // Request ID -> query
+}
+
+// query is what a command ran against, without any of the values it was given
+type query struct {
+	collection string
+	filter     string
+}
+
+// NewSlowQueryMonitor logs commands slower than threshold with their collection and the
+// shape of their filter, in which every value is replaced with "?"
+func NewSlowQueryMonitor(threshold time.Duration) *event.CommandMonitor {
+	m := &slowQueries{threshold: threshold}
+	return &event.CommandMonitor{
+		Started: m.started,
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			m.finished(e.CommandFinishedEvent, "")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			m.finished(e.CommandFinishedEvent, e.Failure)
+		},
+	}
+}
+
+func (m *slowQueries) started(_ context.Context, e *event.CommandStartedEvent) {
+	collection := collectionOf(e.CommandName, e.Command)
+	if collection == "" {
+		return // Commands such as hello and ping
+	}
+	m.running.Store(e.RequestID, query{collection: collection, filter: Sanitize(filterOf(e.CommandName, e.Command))})
+}
+
+func (m *slowQueries) finished(e event.CommandFinishedEvent, failure string) {
+	value, ok := m.running.LoadAndDelete(e.RequestID)
+	if !ok || e.Duration < m.threshold {
+		return
+	}
+	q := value.(query)
+	commands.Add(statSlow, 1)
+	fields := []zap.Field{
+		zap.String("command", e.CommandName),
+		zap.String("database", e.DatabaseName),
+		zap.String("collection", q.collection),
+		zap.String("filter", q.filter),
+		zap.Duration("duration", e.Duration),
+	}
+	if failure != "" {
+		fields = append(fields, zap.String("failure", failure))
+	}
+	zaplogger.GetLogger().Warn("Slow Mongo command", fields...)
+}
+
+// collectionOf returns the collection a command runs against, which most commands name as
+// their first element
+func collectionOf(name string, command bson.Raw) string {
+	if name == "getMore" {
+		collection, _ := command.Lookup("collection").StringValueOK()
+		return collection
+	}
+	elements, err := command.Elements()
+	if err != nil || len(elements) == 0 || elements[0].Key() != name {
+		return ""
+	}
+	collection, _ := elements[0].Value().StringValueOK()
+	return collection
+}
+
+// filterOf returns the part of a command that selects documents, if it has one
+func filterOf(name string, command bson.Raw) bson.RawValue {
+	switch name {
+	case "find":
+		return command.Lookup("filter")
+	case "count", "distinct", "findAndModify":
+		return command.Lookup("query")
+	case "aggregate":
+		return command.Lookup("pipeline")
+	case "update":
+		return command.Lookup("updates", "0", "q")
+	case "delete":
+		return command.Lookup("deletes", "0", "q")
+	}
+	return bson.RawValue{}
+}
+
+// Sanitize renders a filter or pipeline with its keys and operators but every value
+// replaced with "?", so it can be logged without the personal data it may match on
+func Sanitize(value bson.RawValue) string {
+	var b strings.Builder
+	sanitize(&b, value)
+	return b.String()
+}
+
+func sanitize(b *strings.Builder, value bson.RawValue) {
+	switch value.Type {
+	case bsontype.Type(0):
+		// No filter
+	case bsontype.EmbeddedDocument:
+		elements, _ := value.Document().Elements()
+		b.WriteString("{")
+		for i, element := range elements {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(element.Key())
+			b.WriteString(": ")
+			sanitize(b, element.Value())
+		}
+		b.WriteString("}")
+	case bsontype.Array:
+		values, _ := value.Array().Values()
+		if !containsDocuments(values) {
+			b.WriteString("?")
+			return
+		}
+		b.WriteString("[")
+		for i, item := range values {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			sanitize(b, item)
+		}
+		b.WriteString("]")
+	default:
+		b.WriteString("?")
+	}
+}
+
+// containsDocuments reports whether an array holds documents, such as the clauses of $or
+// or the stages of a pipeline, rather than values
+func containsDocuments(values []bson.RawValue) bool {
+	for _, value := range values {
+		if value.Type == bsontype.EmbeddedDocument {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package mongoclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func rawCommand(t *testing.T, command bson.D) bson.Raw {
+	raw, err := bson.Marshal(command)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestSanitize(t *testing.T) {
+	command := rawCommand(t, bson.D{
+		{Key: "find", Value: "applicants"},
+		{Key: "filter", Value: bson.D{
+			{Key: "client_id", Value: "client1"},
+			{Key: "email", Value: "ada@example.com"},
+			{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{"pending", "approved"}}}},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "deleted", Value: false}},
+				bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$gte", Value: time.Now()}}}},
+			}},
+		}},
+	})
+
+	assert.Equal(t, "applicants", collectionOf("find", command))
+	filter := Sanitize(filterOf("find", command))
+	assert.Equal(t, "{client_id: ?, email: ?, status: {$in: ?}, $or: [{deleted: ?}, {deleted_at: {$gte: ?}}]}", filter)
+	assert.NotContains(t, filter, "ada@example.com")
+}
+
+func TestSanitizeCommands(t *testing.T) {
+	update := rawCommand(t, bson.D{
+		{Key: "update", Value: "documents"},
+		{Key: "updates", Value: bson.A{bson.D{
+			{Key: "q", Value: bson.D{{Key: "document_id", Value: "doc1"}}},
+			{Key: "u", Value: bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "verified"}}}}},
+		}}},
+	})
+	assert.Equal(t, "documents", collectionOf("update", update))
+	assert.Equal(t, "{document_id: ?}", Sanitize(filterOf("update", update)))
+
+	aggregate := rawCommand(t, bson.D{
+		{Key: "aggregate", Value: "applicants"},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "client_id", Value: "client1"}}}},
+			bson.D{{Key: "$count", Value: "total"}},
+		}},
+	})
+	assert.Equal(t, "[{$match: {client_id: ?}}, {$count: ?}]", Sanitize(filterOf("aggregate", aggregate)))
+
+	getMore := rawCommand(t, bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "notes"}})
+	assert.Equal(t, "notes", collectionOf("getMore", getMore))
+	assert.Equal(t, "", Sanitize(filterOf("getMore", getMore)))
+
+	assert.Equal(t, "", collectionOf("hello", rawCommand(t, bson.D{{Key: "hello", Value: 1}})))
+}
+
+func TestSlowQueryMonitor(t *testing.T) {
+	monitor := NewSlowQueryMonitor(100 * time.Millisecond)
+	ctx := context.Background()
+	slow := commands.Get(statSlow).String()
+
+	find := rawCommand(t, bson.D{{Key: "find", Value: "applicants"}, {Key: "filter", Value: bson.D{{Key: "client_id", Value: "client1"}}}})
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: find, CommandName: "find", RequestID: 1})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1, Duration: time.Millisecond}})
+	assert.Equal(t, slow, commands.Get(statSlow).String())
+
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: find, CommandName: "find", RequestID: 2})
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 2, Duration: time.Second}, Failure: "timeout"})
+	assert.NotEqual(t, slow, commands.Get(statSlow).String())
+}
+
+func TestObservePool(t *testing.T) {
+	inUse := pool.Get(statInUse).String()
+	observePool(&event.PoolEvent{Type: event.GetStarted})
+	assert.Equal(t, "1", pool.Get(statWaiting).String())
+	observePool(&event.PoolEvent{Type: event.GetSucceeded, Duration: time.Millisecond})
+	assert.Equal(t, "0", pool.Get(statWaiting).String())
+	assert.NotEqual(t, inUse, pool.Get(statInUse).String())
+	observePool(&event.PoolEvent{Type: event.ConnectionReturned})
+	assert.Equal(t, inUse, pool.Get(statInUse).String())
+
+	observePool(&event.PoolEvent{Type: event.GetStarted})
+	observePool(&event.PoolEvent{Type: event.GetFailed, Reason: event.ReasonTimedOut})
+	assert.Equal(t, "0", pool.Get(statWaiting).String())
+	assert.Equal(t, "1", pool.Get(statCheckoutTimeouts).String())
+}
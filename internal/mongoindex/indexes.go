@@ -0,0 +1,256 @@
+// Package mongoindex creates the MongoDB indexes this service relies on. Ensure is
+// run at startup; creating an index that already exists is a no-op.
+package mongoindex
+
+import (
+	"context"
+	"fmt"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Index is an index on one of the service's collections
+type Index struct {
+	Collection string
+	Model      mongo.IndexModel
+}
+
+// Indexes lists every index the service creates
+var Indexes = []Index{
+	// Unique sequence numbers stop concurrent appends from forking the audit chain
+	{localConstants.CollectionAuditLog, mongo.IndexModel{
+		Keys:    bson.D{{Key: "seq", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionAuditLog, mongo.IndexModel{
+		Keys: bson.D{{Key: "at", Value: 1}},
+	}},
+	// Downloads of a document are listed for data protection reports
+	{localConstants.CollectionAuditLog, mongo.IndexModel{
+		Keys: bson.D{{Key: "document_ids", Value: 1}, {Key: "seq", Value: 1}},
+	}},
+
+	// A client's snapshots are listed newest first
+	{localConstants.CollectionBackups, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "taken_at", Value: -1}},
+	}},
+
+	{localConstants.CollectionClients, mongo.IndexModel{
+		Keys:    bson.D{{Key: "client_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+
+	// An applicant has at most one decision waiting for a second reviewer, so concurrent
+	// proposals cannot both be left pending
+	{localConstants.CollectionDecisions, mongo.IndexModel{
+		Keys: bson.D{{Key: "applicant_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"status": localModels.DecisionPendingConfirmation,
+		}),
+	}},
+
+	// Documents are looked up by ID within an applicant, listed per applicant, and
+	// scanned by the upload reconciler for files that never reached S3
+	{localConstants.CollectionDocuments, mongo.IndexModel{
+		Keys:    bson.D{{Key: "document_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionDocuments, mongo.IndexModel{
+		Keys: bson.D{{Key: "applicant_id", Value: 1}, {Key: "created_at", Value: 1}},
+	}},
+	{localConstants.CollectionDocuments, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "applicant_id", Value: 1}},
+	}},
+	{localConstants.CollectionDocuments, mongo.IndexModel{
+		Keys: bson.D{{Key: "file_url", Value: 1}, {Key: "updated_at", Value: 1}},
+	}},
+	// Recent documents and webhook events are counted per client for the operations dashboard
+	{localConstants.CollectionDocuments, mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: 1}},
+	}},
+	{localConstants.CollectionWebhookEvents, mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: 1}},
+	}},
+	// A client's recent webhook events are counted for its delivery failure rate
+	{localConstants.CollectionWebhookEvents, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "created_at", Value: 1}},
+	}},
+	// Dead letters are kept once per event and listed per client, most recent first
+	{localConstants.CollectionWebhookDeadLetters, mongo.IndexModel{
+		Keys:    bson.D{{Key: "event_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionWebhookDeadLetters, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "failed_at", Value: -1}},
+	}},
+
+	// Dashboard users sign in by username
+	{localConstants.CollectionDashboardUsers, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionDashboardUsers, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+
+	// Replicas share one state document per scheduled job, whose lock relies on the names
+	// being unique. Run history is listed per job and removed once expired.
+	{localConstants.CollectionJobs, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionJobRuns, mongo.IndexModel{
+		Keys: bson.D{{Key: "job", Value: 1}, {Key: "started_at", Value: -1}},
+	}},
+	{localConstants.CollectionJobRuns, mongo.IndexModel{
+		Keys:    bson.D{{Key: "run_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionJobRuns, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}},
+
+	// Refresh tokens are looked up by hash and revoked by family, or by the API key or
+	// dashboard user that signed in. They and revoked access token IDs are removed once
+	// expired.
+	{localConstants.CollectionRefreshTokens, mongo.IndexModel{
+		Keys:    bson.D{{Key: "token_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionRefreshTokens, mongo.IndexModel{
+		Keys: bson.D{{Key: "family_id", Value: 1}},
+	}},
+	{localConstants.CollectionRefreshTokens, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "subject", Value: 1}},
+	}},
+	{localConstants.CollectionRefreshTokens, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "api_key_id", Value: 1}},
+	}},
+	{localConstants.CollectionRefreshTokens, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}},
+	{localConstants.CollectionRevokedTokens, mongo.IndexModel{
+		Keys:    bson.D{{Key: "jti", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionRevokedTokens, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}},
+
+	// Signing keys are read on every request from clients whose responses are signed
+	{localConstants.CollectionSigningKeys, mongo.IndexModel{
+		Keys:    bson.D{{Key: "key_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionSigningKeys, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "created_at", Value: -1}},
+	}},
+
+	// A nonce can be used once per client, and is forgotten once the request it signed is too old to replay
+	{localConstants.CollectionRequestNonces, mongo.IndexModel{
+		Keys:    bson.D{{Key: "client_id", Value: 1}, {Key: "nonce", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionRequestNonces, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}},
+
+	// An applicant's syncs from Sumsub are read newest first
+	{localConstants.CollectionSumsubSyncs, mongo.IndexModel{
+		Keys: bson.D{{Key: "applicant_id", Value: 1}, {Key: "synced_at", Value: -1}},
+	}},
+
+	// Upload progress is read by job ID from any replica and forgotten a day after the upload
+	{localConstants.CollectionUploadJobs, mongo.IndexModel{
+		Keys:    bson.D{{Key: "job_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionUploadJobs, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}},
+
+	// Verification reports are fetched by ID, queued at most once per applicant, and
+	// forgotten once they expire
+	{localConstants.CollectionApplicantReports, mongo.IndexModel{
+		Keys:    bson.D{{Key: "report_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionApplicantReports, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "applicant_id", Value: 1}, {Key: "status", Value: 1}},
+	}},
+	{localConstants.CollectionApplicantReports, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "requested_at", Value: 1}},
+	}},
+	{localConstants.CollectionApplicantReports, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}},
+
+	// Compliance reports are fetched by ID, generated oldest first and forgotten once they expire
+	{localConstants.CollectionComplianceReports, mongo.IndexModel{
+		Keys:    bson.D{{Key: "report_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionComplianceReports, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "requested_at", Value: 1}},
+	}},
+	{localConstants.CollectionComplianceReports, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}},
+
+	// Clients' own KMS keys are fetched by ID, listed per client and migrated by status
+	{localConstants.CollectionEncryptionKeys, mongo.IndexModel{
+		Keys:    bson.D{{Key: "encryption_key_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionEncryptionKeys, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "registered_at", Value: -1}},
+	}},
+	{localConstants.CollectionEncryptionKeys, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}},
+	}},
+
+	// Each billable event is recorded once, counted per client and month, and
+	// scanned by the exporter until the billing system has it
+	{localConstants.CollectionUsageEvents, mongo.IndexModel{
+		Keys:    bson.D{{Key: "event_key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionUsageEvents, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "month", Value: 1}},
+	}},
+	{localConstants.CollectionUsageEvents, mongo.IndexModel{
+		Keys: bson.D{{Key: "exported_at", Value: 1}, {Key: "at", Value: 1}},
+	}},
+
+	// Hosted verification sessions are looked up by ID from their token, and listed per applicant
+	{localConstants.CollectionVerificationSessions, mongo.IndexModel{
+		Keys:    bson.D{{Key: "session_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{localConstants.CollectionVerificationSessions, mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "applicant_id", Value: 1}},
+	}},
+}
+
+// Ensure creates any missing indexes
+func Ensure(ctx context.Context) error {
+	for _, index := range Indexes {
+		if _, err := common.GetCollection(index.Collection).Indexes().CreateOne(ctx, index.Model); err != nil {
+			return fmt.Errorf("failed to create index on %s: %w", index.Collection, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package mongoretry
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Server error codes raised while a replica set has no writable primary
+var transientCodes = []int{
+	6,     // HostUnreachable
+	7,     // HostNotFound
+	89,    // NetworkTimeout
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	262,   // ExceededTimeLimit
+	9001,  // SocketException
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// IsTransient reports whether err is expected to clear up on its own, such as
+// a network error or the cluster electing a new primary
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var selection topology.ServerSelectionError
+	if errors.As(err, &selection) {
+		return true
+	}
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		if serverErr.HasErrorLabel("RetryableWriteError") || serverErr.HasErrorLabel("TransientTransactionError") {
+			return true
+		}
+		for _, code := range transientCodes {
+			if serverErr.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ErrRetryWritesDisabled is returned by CheckURI for connection strings that turn off retryable writes
+var ErrRetryWritesDisabled = errors.New("connection string disables retryable writes")
+
+// CheckURI rejects a connection string with retryWrites=false, which would
+// leave every write exposed to a primary election
+func CheckURI(uri string) error {
+	if uri == "" {
+		return nil
+	}
+	i := strings.Index(uri, "?")
+	if i < 0 {
+		return nil
+	}
+	query, err := url.ParseQuery(uri[i+1:])
+	if err != nil {
+		return nil // Left for the driver to report
+	}
+	for key, values := range query {
+		if strings.EqualFold(key, "retryWrites") && len(values) > 0 && strings.EqualFold(values[len(values)-1], "false") {
+			return ErrRetryWritesDisabled
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package mongoretry
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RespondUnavailable answers with 503 and a Retry-After header if err is an
+// unavailable database, and reports whether it did
+func RespondUnavailable(c *gin.Context, err error) bool {
+	if !errors.Is(err, ErrUnavailable) {
+		return false
+	}
+	seconds := int(math.Ceil(currentPolicy().RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable, please retry"})
+	return true
+}
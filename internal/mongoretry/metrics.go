@@ -0,0 +1,20 @@
+package mongoretry
+
+import "expvar"
+
+// Counters published under "mongo_writes" on the expvar endpoint
+const (
+	statTransient   = "transient_errors"  // Attempts that failed with a transient error
+	statPersistent  = "persistent_errors" // Writes that failed with an error retrying cannot fix
+	statRetries     = "retries"           // Attempts repeated after a transient error
+	statRecovered   = "recovered"         // Writes that succeeded after at least one retry
+	statUnavailable = "unavailable"       // Writes that gave up and were reported as 503
+)
+
+var stats = expvar.NewMap("mongo_writes")
+
+func init() {
+	for _, name := range []string{statTransient, statPersistent, statRetries, statRecovered, statUnavailable} {
+		stats.Add(name, 0)
+	}
+}
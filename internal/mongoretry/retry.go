@@ -0,0 +1,165 @@
+// Package mongoretry retries MongoDB writes through short outages such as a
+// primary election, and reports writes that still fail as a temporary
+// unavailability rather than an internal error.
+//
+// The driver already retries a failed write once when retryable writes are
+// enabled (the default, see CheckURI). Write adds a few more attempts with
+// backoff on top, bounded by the request deadline, which covers elections that
+// take longer than the driver's single retry.
+package mongoretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+// Policy bounds how writes are retried
+type Policy struct {
+	MaxAttempts int           // Attempts including the first
+	BaseDelay   time.Duration // Delay before the first retry, doubled for each one after
+	MaxDelay    time.Duration // Largest delay between attempts
+	RetryAfter  time.Duration // Sent to clients in Retry-After once retries are exhausted
+}
+
+// DefaultPolicy rides out a typical election of a few seconds
+var DefaultPolicy = Policy{
+	MaxAttempts: 4,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+	RetryAfter:  5 * time.Second,
+}
+
+var (
+	mu     sync.RWMutex
+	policy = DefaultPolicy
+)
+
+// SetPolicy replaces the retry policy. Unset fields keep their default.
+func SetPolicy(p Policy) {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultPolicy.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultPolicy.MaxDelay
+	}
+	if p.RetryAfter <= 0 {
+		p.RetryAfter = DefaultPolicy.RetryAfter
+	}
+	mu.Lock()
+	policy = p
+	mu.Unlock()
+}
+
+func currentPolicy() Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return policy
+}
+
+// ErrUnavailable is matched by errors.Is for writes that failed only because
+// the database was temporarily unreachable
+var ErrUnavailable = errors.New("database temporarily unavailable")
+
+// UnavailableError is returned by Write when a write kept failing with transient errors
+type UnavailableError struct {
+	Op       string
+	Attempts int
+	Err      error // Last error returned by the driver
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s: %v after %d attempts: %v", e.Op, ErrUnavailable, e.Attempts, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() []error {
+	return []error{ErrUnavailable, e.Err}
+}
+
+// backoff returns the delay before the given retry, starting at 1
+func backoff(p Policy, retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// fitsDeadline reports whether waiting for delay still leaves the context time for another attempt
+func fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > delay
+}
+
+// Write runs a write, retrying it while it fails with transient errors. fn must be
+// safe to repeat: a write that timed out may still have been applied. A write made in a
+// transaction is attempted once, as a transient error aborts the transaction, which is
+// retried as a whole by mongotx.Run.
+func Write(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	p := currentPolicy()
+	logger := zaplogger.GetLogger()
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 1 {
+				stats.Add(statRecovered, 1)
+				logger.Info("MongoDB write succeeded after retry", zap.String("op", op), zap.Int("attempts", attempt))
+			}
+			return nil
+		}
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		if !IsTransient(err) {
+			stats.Add(statPersistent, 1)
+			return err
+		}
+		stats.Add(statTransient, 1)
+
+		delay := backoff(p, attempt)
+		if attempt >= p.MaxAttempts || ctx.Err() != nil || !fitsDeadline(ctx, delay) {
+			stats.Add(statUnavailable, 1)
+			logger.Warn("MongoDB write gave up on transient errors", zap.String("op", op), zap.Int("attempts", attempt), zap.Error(err))
+			return &UnavailableError{Op: op, Attempts: attempt, Err: err}
+		}
+
+		stats.Add(statRetries, 1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			stats.Add(statUnavailable, 1)
+			return &UnavailableError{Op: op, Attempts: attempt, Err: err}
+		}
+	}
+}
+
+// InsertOnce inserts doc unless a document matching key already exists. Unlike
+// InsertOne it can be retried after an ambiguous failure without creating a duplicate.
+func InsertOnce(ctx context.Context, collection common.CollectionInterface, op string, key bson.M, doc interface{}) error {
+	opts := options.Update().SetUpsert(true)
+	return Write(ctx, op, func(ctx context.Context) error {
+		_, err := collection.UpdateOne(ctx, key, bson.M{"$setOnInsert": doc}, opts)
+		return err
+	})
+}
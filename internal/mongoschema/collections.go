@@ -0,0 +1,194 @@
+package mongoschema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Shorthands for the schemas below
+var (
+	str       = &Schema{Types: []Type{String}}
+	boolean   = &Schema{Types: []Type{Bool}}
+	date      = &Schema{Types: []Type{Date}}
+	integer   = &Schema{Types: []Type{Int, Long}}
+	object    = &Schema{Types: []Type{Object}}
+	array     = &Schema{Types: []Type{Array}}
+	maybeDate = &Schema{Types: []Type{Date, Null}}
+	maybeStr  = &Schema{Types: []Type{String, Null}}
+	maybeObj  = &Schema{Types: []Type{Object, Null}}
+	maybeArr  = &Schema{Types: []Type{Array, Null}}
+)
+
+func oneOf(values ...string) *Schema {
+	return &Schema{Types: []Type{String}, Enum: values}
+}
+
+// applicantStatus allows the ints applicant statuses are stored as
+func applicantStatus() *Schema {
+	schema := &Schema{Types: []Type{Int, Long}}
+	for _, status := range []localModels.ApplicantStatus{
+		localModels.ApplicantPending, localModels.ApplicantInReview, localModels.ApplicantResubmissionRequired,
+		localModels.ApplicantApproved, localModels.ApplicantRejected,
+	} {
+		core, err := status.Core()
+		if err != nil {
+			panic(err)
+		}
+		n := int64(core)
+		if schema.Minimum == nil || n < *schema.Minimum {
+			schema.Minimum = &n
+		}
+		if schema.Maximum == nil || n > *schema.Maximum {
+			schema.Maximum = &n
+		}
+	}
+	return schema
+}
+
+// Applicants is the schema of the applicants collection
+var Applicants = &Schema{
+	Types:    []Type{Object},
+	Required: []string{"applicant_id", "client_id", "created_at", "updated_at", "deleted"},
+	Properties: map[string]*Schema{
+		"applicant_id":       str,
+		"client_id":          str,
+		"first_name":         str,
+		"middle_name":        str,
+		"last_name":          str,
+		"email":              str,
+		"phone":              str,
+		"verification_level": str,
+		"created_at":         date,
+		"updated_at":         date,
+		"deleted":            boolean,
+		"deleted_at":         maybeDate,
+		"deleted_by":         maybeStr,
+		// Stored as the core package's int; the API names statuses as strings
+		"status": applicantStatus(),
+		"encrypted_data": {
+			Types: []Type{Object},
+			Properties: map[string]*Schema{
+				"dob":           object,
+				"address":       object,
+				"encrypted_key": {Types: []Type{BinData, Null}},
+			},
+		},
+		// Emptied and then removed by the document migration
+		"documents":       maybeArr,
+		"review":          object,
+		"device_metadata": object,
+		"risk_signals":    array,
+		"consent":         object,
+		"intake": {
+			Types:      []Type{Object},
+			Properties: map[string]*Schema{"state": oneOf(string(localModels.IntakeProvisional), string(localModels.IntakeConfirmed))},
+		},
+		"capture_channel": oneOf(string(localModels.ChannelLink), string(localModels.ChannelQRHandoff)),
+		"annotations": {
+			Types: []Type{Object},
+			Properties: map[string]*Schema{
+				"tags":     {Types: []Type{Array, Null}, Items: str},
+				"metadata": maybeObj,
+			},
+		},
+		"rekeyed_at": date,
+	},
+}
+
+// Documents is the schema of the documents collection
+var Documents = &Schema{
+	Types:    []Type{Object},
+	Required: []string{"document_id", "applicant_id", "created_at", "updated_at"},
+	Properties: map[string]*Schema{
+		"document_id":  str,
+		"applicant_id": str,
+		"client_id":    str,
+		"country":      str,
+		"file_url":     str,
+		"file_size":    integer,
+		// Document statuses are stored as numbers
+		"status":        integer,
+		"created_at":    date,
+		"updated_at":    date,
+		"deleted":       boolean,
+		"deleted_at":    maybeDate,
+		"deleted_by":    maybeStr,
+		"flags":         maybeArr,
+		"country_check": maybeObj,
+		"upload": {
+			Types: []Type{Object},
+			Properties: map[string]*Schema{
+				"state":           oneOf(string(localModels.UploadPending), string(localModels.UploadQuarantined), string(localModels.UploadScanning), string(localModels.UploadStored), string(localModels.UploadFailed)),
+				"last_attempt_at": date,
+			},
+		},
+		"version":  integer,
+		"versions": {Types: []Type{Array}, Items: object},
+		"vendor":   str,
+		"resubmission": {
+			Types: []Type{Object},
+			Properties: map[string]*Schema{
+				"guidance":     {Types: []Type{Array}, Items: oneOf(string(localModels.GuidanceBlurryImage), string(localModels.GuidanceCroppedDocument), string(localModels.GuidanceExpiredDocument), string(localModels.GuidanceNameMismatch))},
+				"requested_at": date,
+			},
+		},
+	},
+}
+
+// Schemas holds the schema of each validated collection by name
+var Schemas = map[string]*Schema{
+	constants.CollectionApplicants:     Applicants,
+	localConstants.CollectionDocuments: Documents,
+}
+
+// Validation settings of the installed validators. Moderate validation leaves updates to
+// documents stored before the validator alone, so records from older releases stay
+// writable until they are migrated.
+const (
+	validationLevel  = "moderate"
+	validationAction = "error"
+)
+
+// namespaceNotFound is the server's code for collMod on a collection that does not exist
+const namespaceNotFound = 26
+
+// Ensure installs each schema as its collection's validator, creating the collection if
+// it does not exist yet. Installing a validator again replaces it, so it is safe to run
+// on every deploy.
+func Ensure(ctx context.Context) error {
+	names := make([]string, 0, len(Schemas))
+	for name := range Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		validator := bson.M{"$jsonSchema": Schemas[name].JSONSchema()}
+		database := common.GetCollection(name).Database()
+		err := database.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: name},
+			{Key: "validator", Value: validator},
+			{Key: "validationLevel", Value: validationLevel},
+			{Key: "validationAction", Value: validationAction},
+		}).Err()
+		var commandErr mongo.CommandError
+		if errors.As(err, &commandErr) && commandErr.Code == namespaceNotFound {
+			opts := options.CreateCollection().SetValidator(validator).SetValidationLevel(validationLevel).SetValidationAction(validationAction)
+			err = database.CreateCollection(ctx, name, opts)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to install schema validator on %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+// Package mongoschema describes the shape of the applicants and documents collections.
+// The same description is installed in MongoDB as a $jsonSchema validator by Ensure and
+// checked in the service before each write, so a malformed write is turned away with a
+// message naming the field rather than stored, or rejected by the server as an opaque
+// "Document failed validation".
+//
+// Schemas only constrain the fields they list; other fields are allowed, so a release can
+// add fields before the schema knows them.
+package mongoschema
+
+import (
+	"fmt"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ErrInvalidWrite is returned for a write that does not match its collection's schema
+var ErrInvalidWrite = apperr.Unprocessable("invalid_write", "write does not match the collection schema")
+
+// Type is a BSON type as $jsonSchema's bsonType names it
+type Type string
+
+const (
+	String  Type = "string"
+	Bool    Type = "bool"
+	Date    Type = "date"
+	Int     Type = "int"
+	Long    Type = "long"
+	Double  Type = "double"
+	Object  Type = "object"
+	Array   Type = "array"
+	BinData Type = "binData"
+	Null    Type = "null"
+)
+
+// bsonTypes names the BSON types stored values can have
+var bsonTypes = map[bsontype.Type]Type{
+	bsontype.String:           String,
+	bsontype.Boolean:          Bool,
+	bsontype.DateTime:         Date,
+	bsontype.Int32:            Int,
+	bsontype.Int64:            Long,
+	bsontype.Double:           Double,
+	bsontype.EmbeddedDocument: Object,
+	bsontype.Array:            Array,
+	bsontype.Binary:           BinData,
+	bsontype.Null:             Null,
+	bsontype.ObjectID:         "objectId",
+	bsontype.Decimal128:       "decimal",
+	bsontype.Timestamp:        "timestamp",
+}
+
+// Schema constrains a value: its type, the strings it may be, the range of its numbers,
+// and for objects and arrays the fields and items it holds
+type Schema struct {
+	Types      []Type // Any type when empty
+	Enum       []string
+	Minimum    *int64 // Least int or long allowed, when set
+	Maximum    *int64 // Greatest int or long allowed, when set
+	Required   []string
+	Properties map[string]*Schema
+	Items      *Schema
+}
+
+// JSONSchema renders the schema as a MongoDB $jsonSchema
+func (s *Schema) JSONSchema() bson.M {
+	out := bson.M{}
+	switch len(s.Types) {
+	case 0:
+	case 1:
+		out["bsonType"] = string(s.Types[0])
+	default:
+		types := make(bson.A, len(s.Types))
+		for i, t := range s.Types {
+			types[i] = string(t)
+		}
+		out["bsonType"] = types
+	}
+	if len(s.Enum) > 0 {
+		enum := make(bson.A, len(s.Enum))
+		for i, value := range s.Enum {
+			enum[i] = value
+		}
+		out["enum"] = enum
+	}
+	if s.Minimum != nil {
+		out["minimum"] = *s.Minimum
+	}
+	if s.Maximum != nil {
+		out["maximum"] = *s.Maximum
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	if len(s.Properties) > 0 {
+		properties := bson.M{}
+		for name, property := range s.Properties {
+			properties[name] = property.JSONSchema()
+		}
+		out["properties"] = properties
+	}
+	if s.Items != nil {
+		out["items"] = s.Items.JSONSchema()
+	}
+	return out
+}
+
+// validate checks a stored value against the schema, naming path in errors
+func (s *Schema) validate(path string, value bson.RawValue) error {
+	t, ok := bsonTypes[value.Type]
+	if !ok {
+		t = Type(value.Type.String())
+	}
+	if len(s.Types) > 0 && !slices.Contains(s.Types, t) {
+		return fmt.Errorf("%w: %s must be %s, not %s", ErrInvalidWrite, path, joinTypes(s.Types), t)
+	}
+	if len(s.Enum) > 0 && t == String && !slices.Contains(s.Enum, value.StringValue()) {
+		return fmt.Errorf("%w: %s must be one of %s", ErrInvalidWrite, path, strings.Join(s.Enum, ", "))
+	}
+	if t == Int || t == Long {
+		n := value.AsInt64()
+		if (s.Minimum != nil && n < *s.Minimum) || (s.Maximum != nil && n > *s.Maximum) {
+			return fmt.Errorf("%w: %s must be between %s and %s, not %d", ErrInvalidWrite, path, bound(s.Minimum), bound(s.Maximum), n)
+		}
+	}
+
+	switch t {
+	case Object:
+		document := value.Document()
+		for _, field := range s.Required {
+			if _, err := document.LookupErr(field); err != nil {
+				return fmt.Errorf("%w: %s is required", ErrInvalidWrite, join(path, field))
+			}
+		}
+		elements, err := document.Elements()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidWrite, path, err)
+		}
+		for _, element := range elements {
+			if property, ok := s.Properties[element.Key()]; ok {
+				if err := property.validate(join(path, element.Key()), element.Value()); err != nil {
+					return err
+				}
+			}
+		}
+	case Array:
+		if s.Items == nil {
+			return nil
+		}
+		values, err := value.Array().Values()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidWrite, path, err)
+		}
+		for i, item := range values {
+			if err := s.Items.validate(fmt.Sprintf("%s.%d", path, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// at returns the schema of the field a dotted path leads to, or nil when the schema does
+// not constrain it. Numeric and positional segments step into array items.
+func (s *Schema) at(path string) *Schema {
+	current := s
+	for _, segment := range strings.Split(path, ".") {
+		if property, ok := current.Properties[segment]; ok {
+			current = property
+			continue
+		}
+		if current.Items != nil && isArrayIndex(segment) {
+			current = current.Items
+			continue
+		}
+		return nil
+	}
+	return current
+}
+
+func isArrayIndex(segment string) bool {
+	if segment == "$" || strings.HasPrefix(segment, "$[") {
+		return true
+	}
+	for _, r := range segment {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return segment != ""
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func bound(n *int64) string {
+	if n == nil {
+		return "any"
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
+func joinTypes(types []Type) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, " or ")
+}
@@ -0,0 +1,115 @@
+package mongoschema
+
+import (
+	"testing"
+	"time"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateInsert(t *testing.T) {
+	now := time.Now()
+
+	applicant := localModels.ApplicantRecord{
+		Applicant:   models.Applicant{ApplicantID: "app-1", ClientID: "client-1", FirstName: "Ada", CreatedAt: now, UpdatedAt: now},
+		Annotations: &localModels.Annotations{Tags: []string{"vip"}},
+	}
+	assert.NoError(t, ValidateInsert(constants.CollectionApplicants, applicant))
+
+	// Core writes statuses as ints, so a string status is malformed
+	err := ValidateInsert(constants.CollectionApplicants, bson.M{"applicant_id": "app-1", "client_id": "client-1", "created_at": now, "updated_at": now, "deleted": false, "status": "in_review"})
+	assert.ErrorIs(t, err, ErrInvalidWrite)
+	assert.ErrorContains(t, err, "status must be int or long, not string")
+	applicant.Status = 7
+	assert.ErrorContains(t, ValidateInsert(constants.CollectionApplicants, applicant), "status must be between 0 and 4, not 7")
+
+	document := localModels.DocumentRecord{
+		Document: models.Document{DocumentID: "doc-1", ApplicantID: "app-1", Status: 1, CreatedAt: now, UpdatedAt: now},
+		Upload:   &localModels.StorageUpload{State: localModels.UploadPending, LastAttemptAt: &now},
+	}
+	assert.NoError(t, ValidateInsert(localConstants.CollectionDocuments, document))
+
+	err = ValidateInsert(localConstants.CollectionDocuments, bson.M{"document_id": "doc-1", "applicant_id": "app-1", "created_at": now})
+	assert.ErrorIs(t, err, ErrInvalidWrite)
+	assert.ErrorContains(t, err, "updated_at is required")
+
+	assert.NoError(t, ValidateInsert("notes", bson.M{"status": "anything"}))
+}
+
+func TestValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		collection string
+		update     bson.M
+		message    string
+	}{
+		{
+			name:       "Document status as a string",
+			collection: localConstants.CollectionDocuments,
+			update:     bson.M{"$set": bson.M{"status": "approved"}},
+			message:    "status must be int or long, not string",
+		},
+		{
+			name:       "Applicant status as a string",
+			collection: constants.CollectionApplicants,
+			update:     bson.M{"$set": bson.M{"status": "approved"}},
+			message:    "status must be int or long, not string",
+		},
+		{
+			name:       "Nested field by dotted path",
+			collection: localConstants.CollectionDocuments,
+			update:     bson.M{"$set": bson.M{"upload.state": "lost"}},
+			message:    "upload.state must be one of",
+		},
+		{
+			name:       "Unsetting a required field",
+			collection: constants.CollectionApplicants,
+			update:     bson.M{"$unset": bson.M{"client_id": ""}},
+			message:    "client_id is required",
+		},
+		{
+			name:       "Pushing a malformed item",
+			collection: constants.CollectionApplicants,
+			update:     bson.M{"$push": bson.M{"annotations.tags": bson.M{"$each": bson.A{"vip", 3}}}},
+			message:    "annotations.tags must be string, not int",
+		},
+		{
+			name:       "Replacement without the required fields",
+			collection: constants.CollectionApplicants,
+			update:     bson.M{"first_name": "Ada"},
+			message:    "applicant_id is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpdate(tt.collection, tt.update)
+			assert.ErrorIs(t, err, ErrInvalidWrite)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+
+	valid := bson.M{
+		"$set":   bson.M{"status": localModels.ApplicantApproved, "review.decided_by": "reviewer", "updated_at": time.Now(), "custom": 1},
+		"$unset": bson.M{"deleted_at": ""},
+	}
+	assert.NoError(t, ValidateUpdate(constants.CollectionApplicants, valid))
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema := Documents.JSONSchema()
+
+	assert.Equal(t, "object", schema["bsonType"])
+	assert.Equal(t, Documents.Required, schema["required"])
+	properties := schema["properties"].(bson.M)
+	assert.Equal(t, bson.A{"int", "long"}, properties["status"].(bson.M)["bsonType"])
+	upload := properties["upload"].(bson.M)["properties"].(bson.M)
+	assert.Equal(t, bson.A{"pending", "quarantined", "scanning", "stored", "failed"}, upload["state"].(bson.M)["enum"])
+
+	status := Applicants.JSONSchema()["properties"].(bson.M)["status"].(bson.M)
+	assert.Equal(t, bson.M{"bsonType": bson.A{"int", "long"}, "minimum": int64(0), "maximum": int64(4)}, status)
+}
@@ -0,0 +1,94 @@
+package mongoschema
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ValidateInsert checks a document about to be inserted into a collection. Collections
+// without a schema accept anything.
+func ValidateInsert(collection string, document interface{}) error {
+	schema, ok := Schemas[collection]
+	if !ok {
+		return nil
+	}
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWrite, err)
+	}
+	return schema.validate("", bson.RawValue{Type: bsontype.EmbeddedDocument, Value: raw})
+}
+
+// ValidateUpdate checks the values an update document writes to a collection: fields set
+// by $set and $setOnInsert, items added by $push and $addToSet, and that $unset leaves
+// the required fields. An update without operators replaces the document, so is checked
+// as an insert.
+func ValidateUpdate(collection string, update interface{}) error {
+	schema, ok := Schemas[collection]
+	if !ok {
+		return nil
+	}
+	marshalled, err := bson.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWrite, err)
+	}
+	raw := bson.Raw(marshalled)
+	operators, err := raw.Elements()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWrite, err)
+	}
+	if len(operators) > 0 && !strings.HasPrefix(operators[0].Key(), "$") {
+		return schema.validate("", bson.RawValue{Type: bsontype.EmbeddedDocument, Value: raw})
+	}
+
+	for _, operator := range operators {
+		fields, ok := operator.Value().DocumentOK()
+		if !ok {
+			continue
+		}
+		elements, err := fields.Elements()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidWrite, err)
+		}
+		for _, element := range elements {
+			path, value := element.Key(), element.Value()
+			switch operator.Key() {
+			case "$set", "$setOnInsert":
+				if field := schema.at(path); field != nil {
+					if err := field.validate(path, value); err != nil {
+						return err
+					}
+				}
+			case "$push", "$addToSet":
+				field := schema.at(path)
+				if field == nil || field.Items == nil {
+					continue
+				}
+				// Several items are pushed as {"$each": [...]}
+				items := []bson.RawValue{value}
+				if modifiers, ok := value.DocumentOK(); ok {
+					if each, err := modifiers.LookupErr("$each"); err == nil {
+						if items, err = each.Array().Values(); err != nil {
+							return fmt.Errorf("%w: %v", ErrInvalidWrite, err)
+						}
+					}
+				}
+				for _, item := range items {
+					if err := field.Items.validate(path, item); err != nil {
+						return err
+					}
+				}
+			case "$unset":
+				for _, required := range schema.Required {
+					if path == required {
+						return fmt.Errorf("%w: %s is required and cannot be unset", ErrInvalidWrite, path)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package mongotx runs writes that belong together, such as creating an applicant and
+// metering it, in a MongoDB multi-document transaction so they are applied together or not
+// at all.
+//
+// Transactions need a replica set or a sharded cluster. Against a standalone server, such
+// as the dev container, the writes are made one after the other in the order given instead,
+// and one that fails leaves those before it in place.
+package mongotx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Modes of settings.mongo.transactions
+const (
+	ModeAuto     = "auto"     // Use transactions when the deployment supports them
+	ModeRequired = "required" // Refuse to start against a deployment without them
+	ModeOff      = "off"      // Always write one after the other
+)
+
+// ErrUnsupported is returned by Configure when transactions are required but the
+// deployment is a standalone server
+var ErrUnsupported = errors.New("MongoDB deployment does not support transactions: it is not a replica set or sharded cluster")
+
+var (
+	mu      sync.RWMutex
+	enabled bool
+)
+
+// Configure decides whether Run uses transactions, asking the deployment client is
+// connected to whether it supports them unless mode is off. It reports the decision.
+func Configure(ctx context.Context, client *mongo.Client, mode string) (bool, error) {
+	mode = strings.TrimSpace(mode)
+	var hello bson.M
+	var err error
+	if mode != ModeOff {
+		err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	}
+	use, err := decide(mode, hello, err)
+	if err != nil {
+		return false, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = use
+	return use, nil
+}
+
+// decide picks whether to use transactions from the mode and the deployment's answer to hello
+func decide(mode string, hello bson.M, helloErr error) (bool, error) {
+	switch mode {
+	case ModeOff:
+		return false, nil
+	case "", ModeAuto, ModeRequired:
+	default:
+		return false, fmt.Errorf("mongo.transactions must be auto, required or off, not %q", mode)
+	}
+	if helloErr != nil {
+		return false, fmt.Errorf("failed to ask MongoDB whether it supports transactions: %w", helloErr)
+	}
+	if supports(hello) {
+		return true, nil
+	}
+	if mode == ModeRequired {
+		return false, ErrUnsupported
+	}
+	return false, nil
+}
+
+// supports reports whether a deployment that answered hello so supports transactions: the
+// members of a replica set name their set, and mongos routers say they are one
+func supports(hello bson.M) bool {
+	setName, _ := hello["setName"].(string)
+	msg, _ := hello["msg"].(string)
+	return setName != "" || msg == "isdbgrid"
+}
+
+// Enabled reports whether Run uses transactions
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled
+}
+
+// InTransaction reports whether ctx is that of a transaction run by Run. Steps that are
+// only logged when they fail on their own should fail the transaction instead.
+func InTransaction(ctx context.Context) bool {
+	return mongo.SessionFromContext(ctx) != nil
+}
+
+// Run runs fn in a transaction when transactions are enabled, and otherwise calls it
+// directly. Every write fn makes must use the context it is given. fn is run again from the
+// start when the transaction is aborted by a transient error, such as a primary election,
+// so it must be safe to repeat; once that keeps happening Run fails as mongoretry.Write does,
+// with an error matching mongoretry.ErrUnavailable.
+func Run(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	if !Enabled() {
+		return fn(ctx)
+	}
+	session, err := common.Client.StartSession()
+	if err != nil {
+		return fmt.Errorf("%s: failed to start a session: %w", op, err)
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	attempts := 0
+	opts := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.Majority())
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		attempts++
+		return nil, fn(ctx)
+	}, opts)
+	if err != nil && mongoretry.IsTransient(err) {
+		return &mongoretry.UnavailableError{Op: op, Attempts: attempts, Err: err}
+	}
+	return err
+}
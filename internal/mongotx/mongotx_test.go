@@ -0,0 +1,57 @@
+package mongotx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDecide(t *testing.T) {
+	replicaSet := bson.M{"isWritablePrimary": true, "setName": "rs0"}
+	router := bson.M{"isWritablePrimary": true, "msg": "isdbgrid"}
+	standalone := bson.M{"isWritablePrimary": true}
+
+	for _, mode := range []string{"", ModeAuto, ModeRequired} {
+		use, err := decide(mode, replicaSet, nil)
+		require.NoError(t, err)
+		assert.True(t, use, mode)
+		use, err = decide(mode, router, nil)
+		require.NoError(t, err)
+		assert.True(t, use, mode)
+	}
+
+	use, err := decide(ModeAuto, standalone, nil)
+	require.NoError(t, err)
+	assert.False(t, use, "standalone servers write one after the other")
+	_, err = decide(ModeRequired, standalone, nil)
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	use, err = decide(ModeOff, nil, errors.New("not asked"))
+	require.NoError(t, err)
+	assert.False(t, use)
+
+	_, err = decide(ModeAuto, nil, errors.New("connection refused"))
+	assert.Error(t, err)
+	_, err = decide("always", replicaSet, nil)
+	assert.Error(t, err)
+}
+
+func TestRunWithoutTransactions(t *testing.T) {
+	require.False(t, Enabled())
+
+	var steps []string
+	err := Run(context.Background(), "test", func(ctx context.Context) error {
+		assert.False(t, InTransaction(ctx))
+		steps = append(steps, "applicant", "usage")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"applicant", "usage"}, steps)
+
+	failed := errors.New("write failed")
+	assert.ErrorIs(t, Run(context.Background(), "test", func(context.Context) error { return failed }), failed)
+}
@@ -0,0 +1,188 @@
+package mrz
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format is the ICAO 9303 layout of a machine readable zone
+type Format string
+
+const (
+	TD1 Format = "TD1" // ID cards: 3 lines of 30 characters
+	TD2 Format = "TD2" // Older ID cards and visas: 2 lines of 36 characters
+	TD3 Format = "TD3" // Passports: 2 lines of 44 characters
+)
+
+// Result holds the fields read from a machine readable zone
+type Result struct {
+	Format         Format     `json:"format" bson:"format"`
+	DocumentCode   string     `json:"document_code" bson:"document_code"`
+	IssuingCountry string     `json:"issuing_country" bson:"issuing_country"`
+	Nationality    string     `json:"nationality" bson:"nationality"`
+	DocumentNumber string     `json:"-" bson:"-"` // Never persisted or returned
+	Surname        string     `json:"surname" bson:"surname"`
+	GivenNames     string     `json:"given_names" bson:"given_names"`
+	BirthDate      *time.Time `json:"birth_date,omitempty" bson:"birth_date,omitempty"`
+	ExpiryDate     *time.Time `json:"expiry_date,omitempty" bson:"expiry_date,omitempty"`
+	Sex            string     `json:"sex" bson:"sex"`
+}
+
+// Parse reads a TD1, TD2 or TD3 machine readable zone and validates its check digits
+func Parse(text string) (Result, error) {
+	var lines []string
+	for _, line := range strings.Split(strings.ToUpper(text), "\n") {
+		line = strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	switch {
+	case len(lines) == 3 && allLength(lines, 30):
+		return parseTD1(lines)
+	case len(lines) == 2 && allLength(lines, 36):
+		return parseTD2(lines)
+	case len(lines) == 2 && allLength(lines, 44):
+		return parseTD3(lines)
+	}
+	return Result{}, fmt.Errorf("unrecognised MRZ layout")
+}
+
+func allLength(lines []string, n int) bool {
+	for _, line := range lines {
+		if len(line) != n {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTD1(lines []string) (Result, error) {
+	l1, l2, l3 := lines[0], lines[1], lines[2]
+	if err := verify("document number", l1[5:14], l1[14]); err != nil {
+		return Result{}, err
+	}
+	if err := verify("birth date", l2[0:6], l2[6]); err != nil {
+		return Result{}, err
+	}
+	if err := verify("expiry date", l2[8:14], l2[14]); err != nil {
+		return Result{}, err
+	}
+	if err := verify("composite", l1[5:30]+l2[0:7]+l2[8:15]+l2[18:29], l2[29]); err != nil {
+		return Result{}, err
+	}
+	surname, given := splitName(l3)
+	return Result{
+		Format:         TD1,
+		DocumentCode:   trimFiller(l1[0:2]),
+		IssuingCountry: trimFiller(l1[2:5]),
+		DocumentNumber: trimFiller(l1[5:14]),
+		BirthDate:      parseDate(l2[0:6], false),
+		Sex:            trimFiller(l2[7:8]),
+		ExpiryDate:     parseDate(l2[8:14], true),
+		Nationality:    trimFiller(l2[15:18]),
+		Surname:        surname,
+		GivenNames:     given,
+	}, nil
+}
+
+func parseTD2(lines []string) (Result, error) {
+	return parseTwoLine(lines, TD2, 36)
+}
+
+func parseTD3(lines []string) (Result, error) {
+	return parseTwoLine(lines, TD3, 44)
+}
+
+// parseTwoLine handles TD2 and TD3, which share the same field positions on the second line
+func parseTwoLine(lines []string, format Format, width int) (Result, error) {
+	l1, l2 := lines[0], lines[1]
+	if err := verify("document number", l2[0:9], l2[9]); err != nil {
+		return Result{}, err
+	}
+	if err := verify("birth date", l2[13:19], l2[19]); err != nil {
+		return Result{}, err
+	}
+	if err := verify("expiry date", l2[21:27], l2[27]); err != nil {
+		return Result{}, err
+	}
+	composite := l2[0:10] + l2[13:20] + l2[21:width-1]
+	if err := verify("composite", composite, l2[width-1]); err != nil {
+		return Result{}, err
+	}
+	surname, given := splitName(l1[5:])
+	return Result{
+		Format:         format,
+		DocumentCode:   trimFiller(l1[0:2]),
+		IssuingCountry: trimFiller(l1[2:5]),
+		DocumentNumber: trimFiller(l2[0:9]),
+		Nationality:    trimFiller(l2[10:13]),
+		BirthDate:      parseDate(l2[13:19], false),
+		Sex:            trimFiller(l2[20:21]),
+		ExpiryDate:     parseDate(l2[21:27], true),
+		Surname:        surname,
+		GivenNames:     given,
+	}, nil
+}
+
+// CheckDigit computes the ICAO 9303 check digit of a field
+func CheckDigit(field string) int {
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i, r := range field {
+		var v int
+		switch {
+		case r >= '0' && r <= '9':
+			v = int(r - '0')
+		case r >= 'A' && r <= 'Z':
+			v = int(r-'A') + 10
+		}
+		sum += v * weights[i%3]
+	}
+	return sum % 10
+}
+
+func verify(name, field string, digit byte) error {
+	// A filler check digit is allowed when the field itself is empty
+	if digit == '<' && strings.Trim(field, "<") == "" {
+		return nil
+	}
+	if digit < '0' || digit > '9' || CheckDigit(field) != int(digit-'0') {
+		return fmt.Errorf("MRZ %s check digit mismatch", name)
+	}
+	return nil
+}
+
+func trimFiller(s string) string {
+	return strings.Trim(s, "<")
+}
+
+func splitName(s string) (string, string) {
+	parts := strings.SplitN(strings.TrimRight(s, "<"), "<<", 2)
+	surname := strings.ReplaceAll(parts[0], "<", " ")
+	given := ""
+	if len(parts) == 2 {
+		given = strings.ReplaceAll(parts[1], "<", " ")
+	}
+	return strings.TrimSpace(surname), strings.TrimSpace(given)
+}
+
+// parseDate reads a YYMMDD date. Expiry dates are assumed to be in this century,
+// birth dates in the past.
+func parseDate(s string, expiry bool) *time.Time {
+	t, err := time.Parse("060102", s)
+	if err != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	// Go maps 69-99 to 19xx and 00-68 to 20xx; correct for the field's meaning
+	if expiry && t.Year() < 2000 {
+		t = t.AddDate(100, 0, 0)
+	}
+	if !expiry && t.After(now) {
+		t = t.AddDate(-100, 0, 0)
+	}
+	return &t
+}
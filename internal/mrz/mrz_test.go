@@ -0,0 +1,69 @@
+package mrz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		wantFormat  Format
+		wantIssuer  string
+		wantSurname string
+		wantGiven   string
+		expectErr   bool
+	}{
+		{
+			name:        "TD3 passport (ICAO specimen)",
+			text:        "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204159ZE184226B<<<<<10",
+			wantFormat:  TD3,
+			wantIssuer:  "UTO",
+			wantSurname: "ERIKSSON",
+			wantGiven:   "ANNA MARIA",
+		},
+		{
+			name:        "TD1 identity card (ICAO specimen)",
+			text:        "I<UTOD231458907<<<<<<<<<<<<<<<\n7408122F1204159UTO<<<<<<<<<<<6\nERIKSSON<<ANNA<MARIA<<<<<<<<<<",
+			wantFormat:  TD1,
+			wantIssuer:  "UTO",
+			wantSurname: "ERIKSSON",
+			wantGiven:   "ANNA MARIA",
+		},
+		{
+			name:      "Corrupted check digit",
+			text:      "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C37UTO7408122F1204159ZE184226B<<<<<10",
+			expectErr: true,
+		},
+		{
+			name:      "Not an MRZ",
+			text:      "hello world",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(tt.text)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFormat, result.Format)
+			assert.Equal(t, tt.wantIssuer, result.IssuingCountry)
+			assert.Equal(t, tt.wantSurname, result.Surname)
+			assert.Equal(t, tt.wantGiven, result.GivenNames)
+			assert.Equal(t, 1974, result.BirthDate.Year())
+			assert.Equal(t, 2012, result.ExpiryDate.Year())
+		})
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	assert.Equal(t, 6, CheckDigit("L898902C3"))
+	assert.Equal(t, 2, CheckDigit("740812"))
+	assert.Equal(t, 9, CheckDigit("120415"))
+}
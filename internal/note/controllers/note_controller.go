@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+const (
+	maxNoteLength    = 5000
+	defaultNoteLimit = 50
+	maxNoteLimit     = 200
+)
+
+type noteRequest struct {
+	Body       string `json:"body" binding:"required"`
+	Visibility string `json:"visibility"`
+}
+
+// addNote validates the request body and stores the note
+func addNote(c *gin.Context, service interfaces.NoteService, scope tenant.Filter, note localModels.Note, allowInternal bool) {
+	var requestBody noteRequest
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body is required"})
+		return
+	}
+	note.Body = strings.TrimSpace(requestBody.Body)
+	if note.Body == "" || len(note.Body) > maxNoteLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body must be between 1 and " + strconv.Itoa(maxNoteLength) + " characters"})
+		return
+	}
+
+	switch localModels.NoteVisibility(requestBody.Visibility) {
+	case "":
+	case localModels.NoteShared:
+		note.Visibility = localModels.NoteShared
+	case localModels.NoteInternal:
+		if !allowInternal {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "clients can only add shared notes"})
+			return
+		}
+		note.Visibility = localModels.NoteInternal
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be internal or shared"})
+		return
+	}
+
+	note.ApplicantID = c.Param("id")
+	result, err := service.AddNote(c, scope, note)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save note"})
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// listNotes lists a page of the notes visible to the caller. Admins' cursors are scoped
+// apart from clients'.
+func listNotes(c *gin.Context, service interfaces.NoteService, scope tenant.Filter) {
+	page, ok := pagination.Parse(c, pagination.Scope(scope.ClientID(), "notes", c.Param("id")), defaultNoteLimit, maxNoteLimit)
+	if !ok {
+		return
+	}
+
+	notes, err := service.ListNotes(c, c.Param("id"), scope, page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve notes"})
+		return
+	}
+	pagination.Respond(c, page, notes, func(note localModels.Note) pagination.Cursor {
+		return pagination.Cursor{At: note.CreatedAt, ID: note.NoteID}
+	})
+}
+
+// AdminAddNote is the handler function for a reviewer adding a note to an applicant.
+// Reviewer notes are internal unless explicitly shared.
+func AdminAddNote(c *gin.Context, service interfaces.NoteService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	addNote(c, service, tenant.AllClients(), localModels.Note{
+		Visibility: localModels.NoteInternal,
+		AuthorID:   adminID,
+		AuthorType: localModels.NoteAuthorAdmin,
+	}, true)
+}
+
+// AdminListNotes is the handler function for listing every note on an applicant
+func AdminListNotes(c *gin.Context, service interfaces.NoteService) {
+	listNotes(c, service, tenant.AllClients())
+}
+
+// AddNote is the handler function for a client adding a note to one of its applicants
+func AddNote(c *gin.Context, service interfaces.NoteService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	addNote(c, service, tenant.Of(clientID), localModels.Note{
+		ClientID:   clientID,
+		Visibility: localModels.NoteShared,
+		AuthorID:   clientID,
+		AuthorType: localModels.NoteAuthorClient,
+	}, false)
+}
+
+// ListNotes is the handler function for a client listing the shared notes on an applicant
+func ListNotes(c *gin.Context, service interfaces.NoteService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	listNotes(c, service, tenant.Of(clientID))
+}
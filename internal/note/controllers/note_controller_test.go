@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// setupNoteRouter registers the admin and client note routes behind fake logins
+func setupNoteRouter(mockService *localMocks.MockNoteService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	admin := router.Group("/admin", func(c *gin.Context) {
+		c.Set("admin_id", "reviewer1")
+		c.Set("admin_role", "reviewer")
+	})
+	admin.POST("/applicants/:id/notes", func(c *gin.Context) {
+		AdminAddNote(c, mockService)
+	})
+
+	client := router.Group("/protected", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	})
+	client.POST("/applicants/:id/notes", func(c *gin.Context) {
+		AddNote(c, mockService)
+	})
+	client.GET("/applicants/:id/notes", func(c *gin.Context) {
+		ListNotes(c, mockService)
+	})
+	return router
+}
+
+func TestAddNote(t *testing.T) {
+	tests := []struct {
+		name               string
+		path               string
+		requestBody        string
+		expectedScope      tenant.Filter
+		expectedNote       localModels.Note
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{
+			name:               "Reviewer note defaults to internal",
+			path:               "/admin/applicants/app1/notes",
+			requestBody:        `{"body": "Called the applicant"}`,
+			expectedScope:      tenant.AllClients(),
+			expectedNote:       localModels.Note{ApplicantID: "app1", Body: "Called the applicant", Visibility: localModels.NoteInternal, AuthorID: "reviewer1", AuthorType: localModels.NoteAuthorAdmin},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "Reviewer shares a note",
+			path:               "/admin/applicants/app1/notes",
+			requestBody:        `{"body": "Please resend page 2", "visibility": "shared"}`,
+			expectedScope:      tenant.AllClients(),
+			expectedNote:       localModels.Note{ApplicantID: "app1", Body: "Please resend page 2", Visibility: localModels.NoteShared, AuthorID: "reviewer1", AuthorType: localModels.NoteAuthorAdmin},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "Client note is shared",
+			path:               "/protected/applicants/app1/notes",
+			requestBody:        `{"body": "  Sent by post  "}`,
+			expectedScope:      tenant.Of("client1"),
+			expectedNote:       localModels.Note{ApplicantID: "app1", ClientID: "client1", Body: "Sent by post", Visibility: localModels.NoteShared, AuthorID: "client1", AuthorType: localModels.NoteAuthorClient},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "Client cannot add internal note",
+			path:               "/protected/applicants/app1/notes",
+			requestBody:        `{"body": "hidden", "visibility": "internal"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Blank body",
+			path:               "/admin/applicants/app1/notes",
+			requestBody:        `{"body": "   "}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Body too long",
+			path:               "/admin/applicants/app1/notes",
+			requestBody:        `{"body": "` + strings.Repeat("a", maxNoteLength+1) + `"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Applicant of another client",
+			path:               "/protected/applicants/app1/notes",
+			requestBody:        `{"body": "hello"}`,
+			expectedScope:      tenant.Of("client1"),
+			expectedNote:       localModels.Note{ApplicantID: "app1", ClientID: "client1", Body: "hello", Visibility: localModels.NoteShared, AuthorID: "client1", AuthorType: localModels.NoteAuthorClient},
+			serviceErr:         apperr.ErrApplicantNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockNoteService)
+			router := setupNoteRouter(mockService)
+			mockService.On("AddNote", mock.Anything, tt.expectedScope, tt.expectedNote).Return(tt.expectedNote, tt.serviceErr)
+
+			req, _ := http.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.requestBody))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusBadRequest {
+				mockService.AssertNotCalled(t, "AddNote", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				mockService.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestListNotesIsClientScoped(t *testing.T) {
+	mockService := new(localMocks.MockNoteService)
+	router := setupNoteRouter(mockService)
+	firstPage := mock.MatchedBy(func(page pagination.Page) bool { return page.Limit == 10 && page.After == nil })
+	mockService.On("ListNotes", mock.Anything, "app1", tenant.Of("client1"), firstPage).Return([]localModels.Note{{NoteID: "n1"}}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/protected/applicants/app1/notes?limit=10", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/protected/applicants/app1/notes?limit=0", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestListNotesPagesWithCursors(t *testing.T) {
+	mockService := new(localMocks.MockNoteService)
+	router := setupNoteRouter(mockService)
+	older := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	notes := []localModels.Note{{NoteID: "n3", CreatedAt: older.Add(2 * time.Minute)}, {NoteID: "n2", CreatedAt: older.Add(time.Minute)}, {NoteID: "n1", CreatedAt: older}}
+	mockService.On("ListNotes", mock.Anything, "app1", tenant.Of("client1"), mock.MatchedBy(func(page pagination.Page) bool { return page.After == nil })).Return(notes, nil)
+	afterSecond := mock.MatchedBy(func(page pagination.Page) bool {
+		return page.After != nil && page.After.ID == "n2" && page.After.At.Equal(older.Add(time.Minute))
+	})
+	mockService.On("ListNotes", mock.Anything, "app1", tenant.Of("client1"), afterSecond).Return(notes[2:], nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/protected/applicants/app1/notes?limit=2", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var page struct {
+		Items      []localModels.Note `json:"items"`
+		NextCursor string             `json:"next_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Items, 2)
+	require.NotEmpty(t, page.NextCursor)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/protected/applicants/app1/notes?limit=2&cursor="+page.NextCursor, nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.NotContains(t, w.Body.String(), "next_cursor")
+
+	// A cursor for another applicant's notes is refused
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/protected/applicants/app2/notes?cursor="+page.NextCursor, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), pagination.InvalidCursor)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/replicareads"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	zap "go.uber.org/zap"
+)
+
+// NoteServiceImpl stores case notes on applicants in their own collection
+type NoteServiceImpl struct {
+	CollectionName          string
+	ApplicantCollectionName string
+}
+
+var (
+	instance NoteServiceImpl
+	once     sync.Once
+)
+
+func GetNoteServiceImpl() NoteServiceImpl {
+	once.Do(func() {
+		instance = NoteServiceImpl{
+			CollectionName:          localConstants.CollectionNotes,
+			ApplicantCollectionName: constants.CollectionApplicants,
+		}
+	})
+	return instance
+}
+
+// AddNote records a note on one of the scope's applicants. Clients may only add notes to
+// their own applicants; reviewers add them to any with tenant.AllClients.
+func (s *NoteServiceImpl) AddNote(c *gin.Context, scope tenant.Filter, note localModels.Note) (localModels.Note, error) {
+	ctx := c.Request.Context()
+
+	applicantFilter := scope.With("applicant_id", note.ApplicantID).With("deleted", false)
+	var applicant struct {
+		ClientID string `bson:"client_id"`
+	}
+	err := tenant.Guard(common.GetCollection(s.ApplicantCollectionName)).FindOne(ctx, applicantFilter).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return localModels.Note{}, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return localModels.Note{}, fmt.Errorf("failed to look up applicant: %v", err)
+	}
+
+	note.NoteID = ids.New()
+	note.ClientID = applicant.ClientID
+	note.CreatedAt = timestamp.Now()
+
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	key := tenant.Of(note.ClientID).With("note_id", note.NoteID)
+	err = mongoretry.Write(ctx, "add_note", func(ctx context.Context) error {
+		return collection.InsertOnce(ctx, key, note)
+	})
+	if err != nil {
+		zaplogger.GetLogger().Error("Error inserting note into MongoDB", zap.Error(err), zap.String("applicantID", note.ApplicantID))
+		return localModels.Note{}, err
+	}
+	return note, nil
+}
+
+// ListNotes lists the notes on one of the scope's applicants visible to the caller, newest
+// first. Reviewers, who list across clients, also see internal notes.
+func (s *NoteServiceImpl) ListNotes(c *gin.Context, applicantID string, scope tenant.Filter, page pagination.Page) ([]localModels.Note, error) {
+	filter := scope.With("applicant_id", applicantID)
+	if scope.ClientID() != "" {
+		filter = filter.With("visibility", localModels.NoteShared)
+	}
+	for field, condition := range page.Filter(bson.M{}, "created_at", "note_id") {
+		filter = filter.With(field, condition)
+	}
+
+	collection := tenant.Guard(replicareads.Collection(c.Request.Context(), common.GetCollection(s.CollectionName)))
+	cursor, err := collection.Find(c.Request.Context(), filter, page.Options("created_at", "note_id"))
+	if err != nil {
+		zaplogger.GetLogger().Error("Error fetching notes from MongoDB", zap.Error(err), zap.String("applicantID", applicantID))
+		return nil, err
+	}
+	defer cursor.Close(c.Request.Context())
+
+	notes := []localModels.Note{}
+	if err := cursor.All(c.Request.Context(), &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/diagnostics"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// maxLogLevelDuration bounds how long a changed log level may last before it reverts
+const maxLogLevelDuration = 24 * time.Hour
+
+// logLevelResponse reports the runtime log level of this instance
+func logLevelResponse(c *gin.Context) {
+	response := gin.H{"level": diagnostics.Level().String()}
+	if revertsAt := diagnostics.RevertsAt(); !revertsAt.IsZero() {
+		response["reverts_at"] = revertsAt.UTC()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLogLevel is the handler function for the runtime log level of this instance
+func GetLogLevel(c *gin.Context) {
+	logLevelResponse(c)
+}
+
+// SetLogLevel is the handler function for changing the runtime log level of this instance.
+// An optional duration, e.g. "30m", puts the previous level back once it has passed.
+func SetLogLevel(c *gin.Context) {
+	var input struct {
+		Level    string `json:"level" binding:"required"`
+		Duration string `json:"duration"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var duration time.Duration
+	if input.Duration != "" {
+		parsed, err := time.ParseDuration(input.Duration)
+		if err != nil || parsed <= 0 || parsed > maxLogLevelDuration {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duration must be a positive duration of at most " + maxLogLevelDuration.String()})
+			return
+		}
+		duration = parsed
+	}
+
+	previous := diagnostics.Level()
+	if err := diagnostics.SetLevelFor(input.Level, duration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown log level " + input.Level})
+		return
+	}
+	zaplogger.GetLogger().Warn("Log level changed",
+		zap.Stringer("from", previous),
+		zap.Stringer("to", diagnostics.Level()),
+		zap.Duration("duration", duration),
+		zap.String("adminID", c.GetString("admin_id")),
+	)
+	logLevelResponse(c)
+}
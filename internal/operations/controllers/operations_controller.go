@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/opsmetrics"
+	"github.com/rachel-lawrie/verus_app_backend/internal/slo"
+	"github.com/rachel-lawrie/verus_app_backend/internal/vendor"
+)
+
+const (
+	defaultWindowHours = 24
+	maxWindowHours     = 30 * 24
+)
+
+// windowStart reads the hours query parameter, responding with 400 if it is invalid
+func windowStart(c *gin.Context) (time.Time, int, bool) {
+	hours := defaultWindowHours
+	if param := c.Query("hours"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 1 || parsed > maxWindowHours {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and " + strconv.Itoa(maxWindowHours)})
+			return time.Time{}, 0, false
+		}
+		hours = parsed
+	}
+	return time.Now().Add(-time.Duration(hours) * time.Hour), hours, true
+}
+
+// GetClientVolumes is the handler function for applicants and documents created per client.
+// The hours query parameter sets the window and defaults to the last 24 hours.
+func GetClientVolumes(c *gin.Context, service interfaces.OperationsService) {
+	since, hours, ok := windowStart(c)
+	if !ok {
+		return
+	}
+	volumes, err := service.ClientVolumes(c.Request.Context(), since)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not count client volumes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hours": hours, "clients": volumes})
+}
+
+// GetWebhookDeliveries is the handler function for webhook delivery outcomes and failure rates per client
+func GetWebhookDeliveries(c *gin.Context, service interfaces.OperationsService) {
+	since, hours, ok := windowStart(c)
+	if !ok {
+		return
+	}
+	deliveries, err := service.WebhookDeliveries(c.Request.Context(), since)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not count webhook deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hours": hours, "clients": deliveries})
+}
+
+// GetQueueDepth is the handler function for the work currently waiting
+func GetQueueDepth(c *gin.Context, service interfaces.OperationsService) {
+	depth, err := service.QueueDepth(c.Request.Context())
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not count queues"})
+		return
+	}
+	c.JSON(http.StatusOK, depth)
+}
+
+// GetProviderLatency is the handler function for the latency and error rate of calls to
+// external providers over the last hour, as seen by this instance
+func GetProviderLatency(c *gin.Context) {
+	providers, _ := opsmetrics.Providers.Summaries()
+	c.JSON(http.StatusOK, gin.H{"window": opsmetrics.Span.String(), "providers": providers})
+}
+
+// GetVendorHealth is the handler function for whether each verification vendor with a
+// health URL is taking new submissions, as seen by this instance
+func GetVendorHealth(c *gin.Context, monitor *vendor.Monitor) {
+	vendors := []vendor.Health{}
+	if monitor != nil {
+		vendors = monitor.Status()
+	}
+	c.JSON(http.StatusOK, gin.H{"vendors": vendors})
+}
+
+// GetErrorRates is the handler function for request error rates over the last hour, as seen by this instance
+func GetErrorRates(c *gin.Context) {
+	routes, total := opsmetrics.Requests.Summaries()
+	c.JSON(http.StatusOK, gin.H{"window": opsmetrics.Span.String(), "total": total, "routes": routes})
+}
+
+// GetSLOs is the handler function for the error budgets of the SLOs and the recent alerts
+// about them, as seen by this instance
+func GetSLOs(c *gin.Context, tracker *slo.Tracker) {
+	if tracker == nil {
+		c.JSON(http.StatusOK, gin.H{"objectives": []slo.Status{}, "alerts": []slo.Alert{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"objectives": tracker.Status(), "alerts": tracker.Alerts()})
+}
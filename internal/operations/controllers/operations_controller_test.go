@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/diagnostics"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupOperationsRouter(mockService *localMocks.MockOperationsService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/ops/volumes", func(c *gin.Context) {
+		GetClientVolumes(c, mockService)
+	})
+	router.GET("/ops/queues", func(c *gin.Context) {
+		GetQueueDepth(c, mockService)
+	})
+	router.GET("/ops/errors", GetErrorRates)
+	router.GET("/ops/slos", func(c *gin.Context) {
+		GetSLOs(c, nil)
+	})
+	router.GET("/ops/pprof/*profile", GetProfile)
+	router.GET("/ops/log-level", GetLogLevel)
+	router.PUT("/ops/log-level", SetLogLevel)
+	return router
+}
+
+func TestGetClientVolumes(t *testing.T) {
+	tests := []struct {
+		name               string
+		query              string
+		window             time.Duration
+		expectedStatusCode int
+	}{
+		{name: "Defaults to a day", query: "", window: 24 * time.Hour, expectedStatusCode: http.StatusOK},
+		{name: "Custom window", query: "?hours=2", window: 2 * time.Hour, expectedStatusCode: http.StatusOK},
+		{name: "Not a number", query: "?hours=day", expectedStatusCode: http.StatusBadRequest},
+		{name: "Too long", query: "?hours=721", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockOperationsService)
+			router := setupOperationsRouter(mockService)
+			near := mock.MatchedBy(func(since time.Time) bool {
+				return time.Since(since)-tt.window < time.Minute
+			})
+			mockService.On("ClientVolumes", mock.Anything, near).
+				Return([]localModels.ClientVolume{{ClientID: "client1", Applicants: 3}}, nil)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/ops/volumes"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"client_id":"client1"`)
+				mockService.AssertExpectations(t)
+			} else {
+				mockService.AssertNotCalled(t, "ClientVolumes", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGetQueueDepth(t *testing.T) {
+	mockService := new(localMocks.MockOperationsService)
+	router := setupOperationsRouter(mockService)
+	mockService.On("QueueDepth", mock.Anything).Return(localModels.QueueDepth{ReviewQueue: 7, WebhooksDue: 2}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/ops/queues", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"review_queue":7`)
+	assert.Contains(t, w.Body.String(), `"webhooks_due":2`)
+}
+
+func TestGetErrorRates(t *testing.T) {
+	router := setupOperationsRouter(new(localMocks.MockOperationsService))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/ops/errors", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"window":"1h0m0s"`)
+	assert.Contains(t, w.Body.String(), `"total"`)
+}
+
+func TestGetSLOs(t *testing.T) {
+	router := setupOperationsRouter(new(localMocks.MockOperationsService))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/ops/slos", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"objectives":[],"alerts":[]}`, w.Body.String())
+}
+
+func TestGetProfile(t *testing.T) {
+	router := setupOperationsRouter(new(localMocks.MockOperationsService))
+
+	tests := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{name: "Index", path: "/ops/pprof/", expectedStatusCode: http.StatusOK, expectedBody: "goroutine"},
+		{name: "Named profile", path: "/ops/pprof/heap?debug=1", expectedStatusCode: http.StatusOK, expectedBody: "heap profile"},
+		{name: "Command line", path: "/ops/pprof/cmdline", expectedStatusCode: http.StatusOK},
+		{name: "Unknown profile", path: "/ops/pprof/unknown", expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	defer diagnostics.SetLevel("info")
+	router := setupOperationsRouter(new(localMocks.MockOperationsService))
+
+	tests := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{name: "Change level", body: `{"level": "warn"}`, expectedStatusCode: http.StatusOK, expectedBody: `{"level":"warn"}`},
+		{name: "Change level for a while", body: `{"level": "debug", "duration": "30m"}`, expectedStatusCode: http.StatusOK, expectedBody: `"reverts_at"`},
+		{name: "Missing level", body: `{}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Unknown level", body: `{"level": "loud"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid duration", body: `{"level": "debug", "duration": "soon"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Duration too long", body: `{"level": "debug", "duration": "48h"}`, expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/ops/log-level", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/ops/log-level", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"level":"debug"`)
+	assert.Contains(t, w.Body.String(), `"reverts_at"`)
+}
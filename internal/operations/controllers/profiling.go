@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProfile is the handler function for the runtime profiles of this instance, as served
+// by net/http/pprof. The profile path parameter names the profile, e.g. /heap or
+// /profile?seconds=30; an empty name lists them. Importing net/http/pprof also registers
+// it on http.DefaultServeMux, which this service never serves, so the profiles are only
+// reachable through this handler and whatever guards its route.
+func GetProfile(c *gin.Context) {
+	switch name := strings.Trim(c.Param("profile"), "/"); name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OperationsServiceImpl computes cross-client figures for the operations dashboard
+type OperationsServiceImpl struct {
+	ApplicantCollectionName string
+	DocumentCollectionName  string
+	WebhookCollectionName   string
+}
+
+var (
+	instance OperationsServiceImpl
+	once     sync.Once
+)
+
+func GetOperationsServiceImpl() OperationsServiceImpl {
+	once.Do(func() {
+		instance = OperationsServiceImpl{
+			ApplicantCollectionName: constants.CollectionApplicants,
+			DocumentCollectionName:  localConstants.CollectionDocuments,
+			WebhookCollectionName:   localConstants.CollectionWebhookEvents,
+		}
+	})
+	return instance
+}
+
+// countRow is a grouping key and the number of records that have it
+type countRow struct {
+	ID    bson.M `bson:"_id"`
+	Count int64  `bson:"count"`
+}
+
+// countBy groups the records created since a time on the given fields
+func countBy(ctx context.Context, collectionName string, match bson.M, fields ...string) ([]countRow, error) {
+	group := bson.M{}
+	for _, field := range fields {
+		group[field] = "$" + field
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{"_id": group, "count": bson.M{"$sum": 1}}},
+	}
+
+	cursor, err := common.GetCollection(collectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate %s: %w", collectionName, err)
+	}
+	defer cursor.Close(ctx)
+	var rows []countRow
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode %s counts: %w", collectionName, err)
+	}
+	return rows, nil
+}
+
+// ClientVolumes counts each client's applicants and documents created since a time, busiest first
+func (s *OperationsServiceImpl) ClientVolumes(ctx context.Context, since time.Time) ([]localModels.ClientVolume, error) {
+	match := bson.M{"created_at": bson.M{"$gte": since}}
+	applicants, err := countBy(ctx, s.ApplicantCollectionName, match, "client_id")
+	if err != nil {
+		return nil, err
+	}
+	documents, err := countBy(ctx, s.DocumentCollectionName, match, "client_id")
+	if err != nil {
+		return nil, err
+	}
+	return clientVolumes(applicants, documents), nil
+}
+
+func clientVolumes(applicants, documents []countRow) []localModels.ClientVolume {
+	byClient := make(map[string]*localModels.ClientVolume)
+	volume := func(row countRow) *localModels.ClientVolume {
+		clientID, _ := row.ID["client_id"].(string)
+		if byClient[clientID] == nil {
+			byClient[clientID] = &localModels.ClientVolume{ClientID: clientID}
+		}
+		return byClient[clientID]
+	}
+	for _, row := range applicants {
+		volume(row).Applicants += row.Count
+	}
+	for _, row := range documents {
+		volume(row).Documents += row.Count
+	}
+
+	volumes := make([]localModels.ClientVolume, 0, len(byClient))
+	for _, v := range byClient {
+		volumes = append(volumes, *v)
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		if volumes[i].Applicants != volumes[j].Applicants {
+			return volumes[i].Applicants > volumes[j].Applicants
+		}
+		if volumes[i].Documents != volumes[j].Documents {
+			return volumes[i].Documents > volumes[j].Documents
+		}
+		return volumes[i].ClientID < volumes[j].ClientID
+	})
+	return volumes
+}
+
+// WebhookDeliveries counts each client's webhook events created since a time by delivery
+// state, highest failure rate first
+func (s *OperationsServiceImpl) WebhookDeliveries(ctx context.Context, since time.Time) ([]localModels.WebhookDeliveryStats, error) {
+	rows, err := countBy(ctx, s.WebhookCollectionName, bson.M{"created_at": bson.M{"$gte": since}}, "client_id", "status")
+	if err != nil {
+		return nil, err
+	}
+	return webhookDeliveries(rows), nil
+}
+
+func webhookDeliveries(rows []countRow) []localModels.WebhookDeliveryStats {
+	byClient := make(map[string]*localModels.WebhookDeliveryStats)
+	for _, row := range rows {
+		clientID, _ := row.ID["client_id"].(string)
+		stats := byClient[clientID]
+		if stats == nil {
+			stats = &localModels.WebhookDeliveryStats{ClientID: clientID}
+			byClient[clientID] = stats
+		}
+		status, _ := row.ID["status"].(string)
+		switch localModels.WebhookEventStatus(status) {
+		case localModels.WebhookPending:
+			stats.Pending += row.Count
+		case localModels.WebhookDelivered:
+			stats.Delivered += row.Count
+		case localModels.WebhookFailed:
+			stats.Failed += row.Count
+		}
+	}
+
+	deliveries := make([]localModels.WebhookDeliveryStats, 0, len(byClient))
+	for _, stats := range byClient {
+		if finished := stats.Delivered + stats.Failed; finished > 0 {
+			stats.FailureRate = float64(stats.Failed) / float64(finished)
+		}
+		deliveries = append(deliveries, *stats)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].FailureRate != deliveries[j].FailureRate {
+			return deliveries[i].FailureRate > deliveries[j].FailureRate
+		}
+		return deliveries[i].ClientID < deliveries[j].ClientID
+	})
+	return deliveries
+}
+
+// QueueDepth counts the applicants awaiting review, webhook events awaiting delivery
+// and documents awaiting upload to S3
+func (s *OperationsServiceImpl) QueueDepth(ctx context.Context) (localModels.QueueDepth, error) {
+	var depth localModels.QueueDepth
+	inReview := bson.M{"deleted": false, "status": bson.M{"$in": localModels.ReviewQueueStatuses}}
+	unassigned := bson.M{"deleted": false, "status": bson.M{"$in": localModels.ReviewQueueStatuses}, "review.assigned_to": nil}
+	pending := bson.M{"status": localModels.WebhookPending}
+	due := bson.M{"status": localModels.WebhookPending, "next_attempt_at": bson.M{"$lte": time.Now()}}
+	awaitingS3 := bson.M{"deleted": false, "file_url": localModels.PlaceholderFileURL, "upload.state": bson.M{"$ne": localModels.UploadFailed}}
+
+	counts := []struct {
+		collection string
+		filter     bson.M
+		into       *int64
+	}{
+		{s.ApplicantCollectionName, inReview, &depth.ReviewQueue},
+		{s.ApplicantCollectionName, unassigned, &depth.ReviewUnassigned},
+		{s.WebhookCollectionName, pending, &depth.WebhooksPending},
+		{s.WebhookCollectionName, due, &depth.WebhooksDue},
+		{s.DocumentCollectionName, awaitingS3, &depth.UploadsAwaitingS3},
+	}
+	for _, count := range counts {
+		n, err := common.GetCollection(count.collection).CountDocuments(ctx, count.filter)
+		if err != nil {
+			return localModels.QueueDepth{}, fmt.Errorf("failed to count %s: %w", count.collection, err)
+		}
+		*count.into = n
+	}
+	return depth, nil
+}
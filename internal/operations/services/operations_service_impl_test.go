@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestClientVolumes(t *testing.T) {
+	applicants := []countRow{
+		{ID: bson.M{"client_id": "small"}, Count: 2},
+		{ID: bson.M{"client_id": "big"}, Count: 9},
+	}
+	documents := []countRow{
+		{ID: bson.M{"client_id": "big"}, Count: 20},
+		{ID: bson.M{"client_id": "documents-only"}, Count: 4},
+	}
+
+	assert.Equal(t, []localModels.ClientVolume{
+		{ClientID: "big", Applicants: 9, Documents: 20},
+		{ClientID: "small", Applicants: 2},
+		{ClientID: "documents-only", Documents: 4},
+	}, clientVolumes(applicants, documents))
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	rows := []countRow{
+		{ID: bson.M{"client_id": "healthy", "status": "delivered"}, Count: 10},
+		{ID: bson.M{"client_id": "flaky", "status": "delivered"}, Count: 3},
+		{ID: bson.M{"client_id": "flaky", "status": "failed"}, Count: 1},
+		{ID: bson.M{"client_id": "flaky", "status": "pending"}, Count: 5},
+		{ID: bson.M{"client_id": "new", "status": "pending"}, Count: 1},
+	}
+
+	assert.Equal(t, []localModels.WebhookDeliveryStats{
+		{ClientID: "flaky", Pending: 5, Delivered: 3, Failed: 1, FailureRate: 0.25},
+		{ClientID: "healthy", Delivered: 10},
+		{ClientID: "new", Pending: 1},
+	}, webhookDeliveries(rows))
+}
@@ -0,0 +1,115 @@
+package opsmetrics
+
+import (
+	"context"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/accesslog"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+)
+
+var (
+	// Requests holds API requests by route, with 5xx responses as errors
+	Requests = NewWindow()
+	// Providers holds calls to external services by provider and operation
+	Providers = NewWindow()
+)
+
+// Middleware records the duration and outcome of each request under its route
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		outcome := OK
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			outcome = Failure
+		case status >= http.StatusBadRequest:
+			outcome = ClientError
+		}
+		Requests.Record(c.Request.Method+" "+route, time.Since(started), outcome)
+	}
+}
+
+// ObserveCall records a call to an external provider that started at started and returned err
+func ObserveCall(provider, operation string, started time.Time, err error) {
+	observe(provider, operation, time.Since(started), err)
+}
+
+// ObserveRequestCall records a call like ObserveCall and adds its time to the access log
+// entry of the request ctx belongs to
+func ObserveRequestCall(ctx context.Context, provider, operation string, started time.Time, err error) {
+	d := time.Since(started)
+	observe(provider, operation, d, err)
+	accesslog.ObserveUpstream(ctx, provider, d)
+}
+
+func observe(provider, operation string, d time.Duration, err error) {
+	outcome := OK
+	if err != nil {
+		outcome = Failure
+	}
+	Providers.Record(provider+"."+operation, d, outcome)
+}
+
+// Uploader times the calls made to S3
+func Uploader(inner interfaces.Uploader) interfaces.Uploader {
+	return &uploader{inner: inner}
+}
+
+type uploader struct {
+	inner interfaces.Uploader
+}
+
+func (u *uploader) UploadFile(ctx context.Context, file multipart.File, fileName string, mimeType string, kmsUploader interfaces.KMSUploader) (string, error) {
+	started := time.Now()
+	url, err := u.inner.UploadFile(ctx, file, fileName, mimeType, kmsUploader)
+	ObserveRequestCall(ctx, "s3", "upload_file", started, err)
+	return url, err
+}
+
+func (u *uploader) DownloadFile(ctx context.Context, objectKey string) (*s3.GetObjectOutput, error) {
+	started := time.Now()
+	out, err := u.inner.DownloadFile(ctx, objectKey)
+	ObserveRequestCall(ctx, "s3", "download_file", started, err)
+	return out, err
+}
+
+// KMSUploader times the calls made to KMS
+func KMSUploader(inner interfaces.KMSUploader) interfaces.KMSUploader {
+	return &kmsUploader{inner: inner}
+}
+
+type kmsUploader struct {
+	inner interfaces.KMSUploader
+}
+
+func (k *kmsUploader) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	started := time.Now()
+	plaintext, encrypted, err := k.inner.GenerateDataKey(ctx)
+	ObserveRequestCall(ctx, "kms", "generate_data_key", started, err)
+	return plaintext, encrypted, err
+}
+
+func (k *kmsUploader) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	started := time.Now()
+	out, err := k.inner.EncryptData(ctx, plaintext)
+	ObserveRequestCall(ctx, "kms", "encrypt", started, err)
+	return out, err
+}
+
+func (k *kmsUploader) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	started := time.Now()
+	out, err := k.inner.DecryptData(ctx, encrypted)
+	ObserveRequestCall(ctx, "kms", "decrypt", started, err)
+	return out, err
+}
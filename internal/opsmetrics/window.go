@@ -0,0 +1,192 @@
+// Package opsmetrics keeps the last hour of request outcomes and external call
+// latencies in memory for the operations dashboard. Figures cover this process
+// only, so a dashboard should sum them across instances.
+package opsmetrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Span is how far back a Window remembers, in one bucket per minute
+const Span = time.Hour
+
+const bucketCount = int(Span / time.Minute)
+
+// Latency histogram bounds used to estimate percentiles
+var latencyBounds = [...]time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, time.Second,
+	2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// Outcome classifies an observation
+type Outcome int
+
+const (
+	OK          Outcome = iota
+	ClientError         // The caller was at fault, e.g. a 4xx response
+	Failure             // This service or the provider failed
+)
+
+// Summary describes one series of observations over the last hour
+type Summary struct {
+	Name          string  `json:"name"`
+	Count         int64   `json:"count"`
+	Errors        int64   `json:"errors"`
+	ErrorRate     float64 `json:"error_rate"`
+	ClientErrors  int64   `json:"client_errors,omitempty"`
+	AverageMillis float64 `json:"average_ms"`
+	P95Millis     float64 `json:"p95_ms"` // Upper bound of the histogram bucket holding the 95th percentile
+	MaxMillis     float64 `json:"max_ms"`
+}
+
+// Window counts observations per name over the last hour
+type Window struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	buckets   [bucketCount]bucket
+	observers []Observer
+}
+
+// Observer is told of each observation a Window records
+type Observer func(name string, d time.Duration, outcome Outcome)
+
+type bucket struct {
+	minute int64 // Unix minute the bucket holds, so stale buckets can be recognised
+	series map[string]*series
+}
+
+type series struct {
+	count, errors, clientErrors int64
+	total, max                  time.Duration
+	histogram                   [len(latencyBounds) + 1]int64
+}
+
+// NewWindow creates an empty Window
+func NewWindow() *Window {
+	return &Window{now: time.Now}
+}
+
+// Subscribe passes every later observation to observer as well, e.g. to track an objective
+// over longer than the Window remembers
+func (w *Window) Subscribe(observer Observer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.observers = append(w.observers, observer)
+}
+
+// Record adds an observation that took d to the named series
+func (w *Window) Record(name string, d time.Duration, outcome Outcome) {
+	for _, observer := range w.add(name, d, outcome) {
+		observer(name, d, outcome)
+	}
+}
+
+// add counts an observation and returns the observers to tell of it
+func (w *Window) add(name string, d time.Duration, outcome Outcome) []Observer {
+	minute := w.now().Unix() / 60
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := &w.buckets[minute%int64(bucketCount)]
+	if b.minute != minute || b.series == nil {
+		b.minute = minute
+		b.series = make(map[string]*series)
+	}
+	s := b.series[name]
+	if s == nil {
+		s = &series{}
+		b.series[name] = s
+	}
+
+	s.count++
+	switch outcome {
+	case Failure:
+		s.errors++
+	case ClientError:
+		s.clientErrors++
+	}
+	s.total += d
+	if d > s.max {
+		s.max = d
+	}
+	s.histogram[sort.Search(len(latencyBounds), func(i int) bool { return d <= latencyBounds[i] })]++
+	return w.observers
+}
+
+// Summaries returns each series seen in the last hour, busiest first, and the total across them
+func (w *Window) Summaries() ([]Summary, Summary) {
+	oldest := w.now().Unix()/60 - int64(bucketCount) + 1
+
+	merged := make(map[string]*series)
+	total := &series{}
+	w.mu.Lock()
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.minute < oldest {
+			continue
+		}
+		for name, s := range b.series {
+			if merged[name] == nil {
+				merged[name] = &series{}
+			}
+			merged[name].add(s)
+			total.add(s)
+		}
+	}
+	w.mu.Unlock()
+
+	summaries := make([]Summary, 0, len(merged))
+	for name, s := range merged {
+		summaries = append(summaries, s.summary(name))
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries, total.summary("total")
+}
+
+func (s *series) add(other *series) {
+	s.count += other.count
+	s.errors += other.errors
+	s.clientErrors += other.clientErrors
+	s.total += other.total
+	if other.max > s.max {
+		s.max = other.max
+	}
+	for i, n := range other.histogram {
+		s.histogram[i] += n
+	}
+}
+
+func (s *series) summary(name string) Summary {
+	summary := Summary{Name: name, Count: s.count, Errors: s.errors, ClientErrors: s.clientErrors, MaxMillis: millis(s.max)}
+	if s.count == 0 {
+		return summary
+	}
+	summary.ErrorRate = float64(s.errors) / float64(s.count)
+	summary.AverageMillis = millis(s.total) / float64(s.count)
+
+	threshold := (s.count*95 + 99) / 100
+	var seen int64
+	for i, n := range s.histogram {
+		if seen += n; seen >= threshold {
+			if i < len(latencyBounds) {
+				summary.P95Millis = millis(latencyBounds[i])
+			} else {
+				summary.P95Millis = summary.MaxMillis
+			}
+			break
+		}
+	}
+	return summary
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,83 @@
+package opsmetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowSummaries(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	w := NewWindow()
+	w.now = func() time.Time { return now }
+
+	for i := 0; i < 19; i++ {
+		w.Record("s3.upload_file", 40*time.Millisecond, OK)
+	}
+	w.Record("s3.upload_file", 3*time.Second, Failure)
+	w.Record("kms.decrypt", 8*time.Millisecond, ClientError)
+
+	summaries, total := w.Summaries()
+
+	assert.Len(t, summaries, 2)
+	upload := summaries[0]
+	assert.Equal(t, "s3.upload_file", upload.Name)
+	assert.Equal(t, int64(20), upload.Count)
+	assert.Equal(t, int64(1), upload.Errors)
+	assert.Equal(t, 0.05, upload.ErrorRate)
+	assert.Equal(t, 50.0, upload.P95Millis, "19 of 20 calls fall within the 50ms bucket")
+	assert.Equal(t, 3000.0, upload.MaxMillis)
+	assert.InDelta(t, 188.0, upload.AverageMillis, 0.001)
+
+	assert.Equal(t, int64(1), summaries[1].ClientErrors)
+	assert.Zero(t, summaries[1].Errors)
+
+	assert.Equal(t, "total", total.Name)
+	assert.Equal(t, int64(21), total.Count)
+	assert.Equal(t, int64(1), total.Errors)
+}
+
+func TestWindowForgetsObservationsOlderThanTheSpan(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	w := NewWindow()
+	w.now = func() time.Time { return now }
+
+	w.Record("GET /api/v1/protected2/applicants", time.Millisecond, OK)
+	now = now.Add(Span - time.Minute)
+	w.Record("GET /api/v1/protected2/applicants", time.Millisecond, Failure)
+
+	_, total := w.Summaries()
+	assert.Equal(t, int64(2), total.Count)
+
+	now = now.Add(time.Minute)
+	_, total = w.Summaries()
+	assert.Equal(t, int64(1), total.Count, "the first observation is an hour old")
+
+	// Writing into a reused bucket starts it afresh
+	w.Record("GET /api/v1/protected2/applicants", time.Millisecond, OK)
+	_, total = w.Summaries()
+	assert.Equal(t, int64(2), total.Count)
+	assert.Equal(t, int64(1), total.Errors)
+}
+
+func TestP95BeyondTheLargestBound(t *testing.T) {
+	w := NewWindow()
+	w.Record("webhook.deliver", 30*time.Second, Failure)
+
+	summaries, _ := w.Summaries()
+	assert.Equal(t, 30000.0, summaries[0].P95Millis)
+}
+
+func TestWindowSubscribe(t *testing.T) {
+	w := NewWindow()
+	var seen []string
+	w.Subscribe(func(name string, d time.Duration, outcome Outcome) {
+		seen = append(seen, name)
+		w.Summaries() // Observers are called without the lock held
+	})
+
+	w.Record("webhook.deliver", time.Second, Failure)
+	w.Record("kms.decrypt", time.Millisecond, OK)
+	assert.Equal(t, []string{"webhook.deliver", "kms.decrypt"}, seen)
+}
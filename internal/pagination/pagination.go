@@ -0,0 +1,175 @@
+// Package pagination pages list endpoints with opaque cursor tokens rather than offsets.
+//
+// A cursor holds the sort key and ID of the last item of a page, and the next page
+// starts after it, so items inserted while a client pages through a list neither shift
+// later pages nor get repeated. Tokens are signed with a secret and bound to the list
+// they were issued for, including the client it belongs to, so a cursor cannot be edited
+// or replayed against another client's list.
+//
+// New list endpoints should page this way, newest first:
+//
+//	page, ok := pagination.Parse(c, pagination.Scope(clientID, "notes", applicantID), 50, 200)
+//	...
+//	pagination.Respond(c, page, items, func(n localModels.Note) pagination.Cursor { ... })
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InvalidCursor is the error code of a request with a cursor that was not issued for the list
+const InvalidCursor = "invalid_cursor"
+
+// ErrInvalidCursor is returned for a token that is malformed, was edited, or belongs to another list
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// Cursor is the position of the last item of a page
+type Cursor struct {
+	At time.Time `json:"t"`
+	ID string    `json:"i"`
+}
+
+// Page is the part of a list a request asks for
+type Page struct {
+	Limit int64
+	After *Cursor // Nil for the first page
+	scope string
+}
+
+var (
+	secretMu sync.Mutex
+	secret   []byte
+)
+
+// SetSecret sets the key cursors are signed with. Every instance serving a list must use
+// the same key, or cursors issued by one are refused by the others. Without one, each
+// instance signs with a random key of its own.
+func SetSecret(key []byte) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secret = key
+}
+
+func signingKey() []byte {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	return secret
+}
+
+// Scope names a list, e.g. Scope(clientID, "notes", applicantID). A cursor is only
+// accepted by the list it was issued for.
+func Scope(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+// Encode makes the token of a cursor for the list of scope
+func Encode(scope string, cursor Cursor) string {
+	payload, _ := json.Marshal(cursor)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(scope, encoded))
+}
+
+// Decode reads a token issued for the list of scope
+func Decode(scope, token string) (Cursor, error) {
+	var cursor Cursor
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return cursor, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(scope, encoded)) {
+		return cursor, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(payload, &cursor) != nil || cursor.ID == "" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+func sign(scope, encoded string) []byte {
+	mac := hmac.New(sha256.New, signingKey())
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// Parse reads the limit and cursor query parameters of a request for the list of scope,
+// answering 400 and returning false when either is invalid
+func Parse(c *gin.Context, scope string, defaultLimit, maxLimit int64) (Page, bool) {
+	page := Page{Limit: defaultLimit, scope: scope}
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.ParseInt(limitParam, 10, 64)
+		if err != nil || parsed <= 0 || parsed > maxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.FormatInt(maxLimit, 10)})
+			return page, false
+		}
+		page.Limit = parsed
+	}
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := Decode(scope, token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": InvalidCursor})
+			return page, false
+		}
+		page.After = &cursor
+	}
+	return page, true
+}
+
+// Filter narrows a query to the items after the page's cursor, for a list sorted newest
+// first by sortField with idField breaking ties
+func (p Page) Filter(filter bson.M, sortField, idField string) bson.M {
+	if p.After == nil {
+		return filter
+	}
+	after := bson.M{"$or": bson.A{
+		bson.M{sortField: bson.M{"$lt": p.After.At}},
+		bson.M{sortField: p.After.At, idField: bson.M{"$lt": p.After.ID}},
+	}}
+	if len(filter) == 0 {
+		return after
+	}
+	return bson.M{"$and": bson.A{filter, after}}
+}
+
+// Options sorts a list newest first and reads one item past the page, so Respond can
+// tell whether another page follows
+func (p Page) Options(sortField, idField string) *options.FindOptions {
+	return options.Find().
+		SetSort(bson.D{{Key: sortField, Value: -1}, {Key: idField, Value: -1}}).
+		SetLimit(p.Limit + 1)
+}
+
+// Respond writes a page of items with the cursor of the next page, if there is one
+func Respond[T any](c *gin.Context, page Page, items []T, cursor func(T) Cursor) {
+	body := gin.H{}
+	if page.Limit > 0 && int64(len(items)) > page.Limit {
+		items = items[:page.Limit]
+		body["next_cursor"] = Encode(page.scope, cursor(items[len(items)-1]))
+	}
+	body["items"] = items
+	body["count"] = len(items)
+	c.JSON(http.StatusOK, body)
+}
@@ -0,0 +1,87 @@
+package pagination
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	SetSecret([]byte("test-secret"))
+	cursor := Cursor{At: time.Date(2025, 1, 15, 9, 45, 0, 123000000, time.UTC), ID: "n1"}
+	scope := Scope("client1", "notes", "app1")
+
+	token := Encode(scope, cursor)
+	decoded, err := Decode(scope, token)
+	require.NoError(t, err)
+	assert.True(t, cursor.At.Equal(decoded.At))
+	assert.Equal(t, "n1", decoded.ID)
+
+	// Another client's list, or the same list's cursor edited, is refused
+	_, err = Decode(Scope("client2", "notes", "app1"), token)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	payload, signature, _ := strings.Cut(token, ".")
+	_, err = Decode(scope, payload+"x."+signature)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = Decode(scope, "not-a-cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	// Cursors signed with another secret are refused
+	SetSecret([]byte("rotated-secret"))
+	_, err = Decode(scope, token)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestFilter(t *testing.T) {
+	at := time.Date(2025, 1, 15, 9, 45, 0, 0, time.UTC)
+	filter := bson.M{"client_id": "client1"}
+	assert.Equal(t, filter, Page{Limit: 10}.Filter(filter, "created_at", "note_id"))
+
+	page := Page{Limit: 10, After: &Cursor{At: at, ID: "n1"}}
+	assert.Equal(t, bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+		bson.M{"created_at": bson.M{"$lt": at}},
+		bson.M{"created_at": at, "note_id": bson.M{"$lt": "n1"}},
+	}}}}, page.Filter(filter, "created_at", "note_id"))
+}
+
+func TestParseAndRespond(t *testing.T) {
+	SetSecret([]byte("test-secret"))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	items := []Cursor{{ID: "c"}, {ID: "b"}, {ID: "a"}}
+	router.GET("/", func(c *gin.Context) {
+		page, ok := Parse(c, Scope("client1", "items"), 2, 5)
+		if !ok {
+			return
+		}
+		Respond(c, page, items[:min(len(items), int(page.Limit)+1)], func(item Cursor) Cursor { return item })
+	})
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":2`)
+	assert.Contains(t, w.Body.String(), `"next_cursor":"`+Encode(Scope("client1", "items"), Cursor{ID: "b"})+`"`)
+
+	w = get("/?limit=5")
+	assert.Contains(t, w.Body.String(), `"count":3`)
+	assert.NotContains(t, w.Body.String(), "next_cursor")
+
+	assert.Equal(t, http.StatusBadRequest, get("/?limit=6").Code)
+	w = get("/?cursor=" + Encode(Scope("client2", "items"), Cursor{ID: "b"}))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), InvalidCursor)
+}
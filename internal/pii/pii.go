@@ -0,0 +1,299 @@
+// Package pii encrypts personal data in individual struct fields before they are
+// stored, using envelope encryption: each record gets a data key from KMS, the
+// tagged fields are sealed with it using AES-GCM, and only the KMS-encrypted copy
+// of the key is stored alongside them.
+//
+// Fields are marked with struct tags:
+//
+//	type Answer struct {
+//		Question string
+//		Value    string `pii:"encrypt"` // Sealed before storage
+//		DataKey  []byte `pii:"key"`     // Encrypted data key, managed by this package
+//	}
+//
+// Tagged fields must be strings. Nested structs, pointers to structs and slices of
+// structs are walked, and share the data key of the outermost record.
+package pii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+)
+
+const (
+	tagName    = "pii"
+	tagEncrypt = "encrypt"
+	tagKey     = "key"
+
+	// prefix marks sealed values, so values stored before a field was tagged are left readable
+	prefix = "pii:v1:"
+
+	contextKey = "pii_cipher"
+)
+
+var (
+	// ErrNoKeyField is returned for a record with encrypted fields but nowhere to keep the data key
+	ErrNoKeyField = errors.New("record has fields tagged for encryption but no pii:\"key\" field")
+	// ErrMissingKey is returned when a record holds sealed values but no data key
+	ErrMissingKey = errors.New("record has encrypted fields but no data key")
+)
+
+// Cipher seals and opens tagged fields. Data keys decrypted by KMS are remembered,
+// so a Cipher should live no longer than a request.
+type Cipher struct {
+	kms  interfaces.KMSUploader
+	mu   sync.Mutex
+	keys map[string][]byte // Plaintext data keys by their encrypted form
+}
+
+// NewCipher creates a Cipher that gets its data keys from kms
+func NewCipher(kms interfaces.KMSUploader) *Cipher {
+	return &Cipher{kms: kms, keys: make(map[string][]byte)}
+}
+
+// Middleware gives each request its own Cipher, available through FromContext
+func Middleware(kms interfaces.KMSUploader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextKey, NewCipher(kms))
+		c.Next()
+	}
+}
+
+// FromContext returns the request's Cipher, or a new one if Middleware did not run
+func FromContext(c *gin.Context, kms interfaces.KMSUploader) *Cipher {
+	if value, ok := c.Get(contextKey); ok {
+		return value.(*Cipher)
+	}
+	return NewCipher(kms)
+}
+
+// Encrypt seals the tagged fields of the struct v points to. A record that already has
+// a data key keeps it; otherwise a new one is generated. Values that are already
+// sealed are left alone, so a record read back and updated can be encrypted again.
+func (c *Cipher) Encrypt(ctx context.Context, v interface{}) error {
+	record, err := structOf(v)
+	if err != nil {
+		return err
+	}
+	keyField, err := findKeyField(record)
+	if err != nil {
+		return err
+	}
+	if !keyField.IsValid() {
+		return nil
+	}
+
+	var key []byte
+	if encryptedKey := keyField.Bytes(); len(encryptedKey) > 0 {
+		key, err = c.openKey(ctx, encryptedKey)
+	} else {
+		var encryptedKey []byte
+		key, encryptedKey, err = c.kms.GenerateDataKey(ctx)
+		if err == nil {
+			c.remember(encryptedKey, key)
+			keyField.SetBytes(encryptedKey)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get data key: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	return walk(record, func(field reflect.Value) error {
+		if field.String() == "" || strings.HasPrefix(field.String(), prefix) {
+			return nil
+		}
+		sealed, err := seal(aead, field.String())
+		if err != nil {
+			return err
+		}
+		field.SetString(sealed)
+		return nil
+	})
+}
+
+// Decrypt opens the sealed fields of the struct v points to. Values that were never
+// sealed are left as they are.
+func (c *Cipher) Decrypt(ctx context.Context, v interface{}) error {
+	record, err := structOf(v)
+	if err != nil {
+		return err
+	}
+	keyField, err := findKeyField(record)
+	if err != nil || !keyField.IsValid() {
+		return err
+	}
+
+	var aead cipher.AEAD
+	return walk(record, func(field reflect.Value) error {
+		if !strings.HasPrefix(field.String(), prefix) {
+			return nil
+		}
+		if aead == nil {
+			if len(keyField.Bytes()) == 0 {
+				return ErrMissingKey
+			}
+			key, err := c.openKey(ctx, keyField.Bytes())
+			if err != nil {
+				return fmt.Errorf("failed to decrypt data key: %w", err)
+			}
+			if aead, err = newAEAD(key); err != nil {
+				return err
+			}
+		}
+		plaintext, err := open(aead, field.String())
+		if err != nil {
+			return err
+		}
+		field.SetString(plaintext)
+		return nil
+	})
+}
+
+// openKey decrypts a data key with KMS, once per Cipher
+func (c *Cipher) openKey(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	c.mu.Lock()
+	key, ok := c.keys[string(encryptedKey)]
+	c.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	key, err := c.kms.DecryptData(ctx, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	c.remember(encryptedKey, key)
+	return key, nil
+}
+
+func (c *Cipher) remember(encryptedKey, key []byte) {
+	c.mu.Lock()
+	c.keys[string(encryptedKey)] = key
+	c.mu.Unlock()
+}
+
+// structOf returns the struct v points to
+func structOf(v interface{}) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("pii: expected a pointer to a struct, got %T", v)
+	}
+	return value.Elem(), nil
+}
+
+// findKeyField returns the record's data key field. The returned value is invalid if the
+// record has no fields to encrypt, in which case there is nothing to do.
+func findKeyField(record reflect.Value) (reflect.Value, error) {
+	var keyField reflect.Value
+	hasEncrypted := false
+	recordType := record.Type()
+	for i := 0; i < recordType.NumField(); i++ {
+		if recordType.Field(i).Tag.Get(tagName) == tagKey {
+			if recordType.Field(i).Type != reflect.TypeOf([]byte(nil)) {
+				return reflect.Value{}, fmt.Errorf("pii: key field %s must be []byte", recordType.Field(i).Name)
+			}
+			keyField = record.Field(i)
+		}
+	}
+	err := walk(record, func(reflect.Value) error {
+		hasEncrypted = true
+		return nil
+	})
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if !hasEncrypted {
+		return reflect.Value{}, nil
+	}
+	if !keyField.IsValid() {
+		return reflect.Value{}, ErrNoKeyField
+	}
+	return keyField, nil
+}
+
+// walk calls fn with every field tagged for encryption in value, descending into nested structs
+func walk(value reflect.Value, fn func(field reflect.Value) error) error {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		return walk(value.Elem(), fn)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := walk(value.Index(i), fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Tag.Get(tagName) == tagEncrypt {
+			if field.Type.Kind() != reflect.String {
+				return fmt.Errorf("pii: field %s tagged for encryption must be a string", field.Name)
+			}
+			if err := fn(value.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := walk(value.Field(i), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("pii: invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a value as prefix + base64(nonce || ciphertext)
+func seal(aead cipher.AEAD, plaintext string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("pii: failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func open(aead cipher.AEAD, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("pii: malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("pii: failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,117 @@
+package pii
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKMS hands out random data keys and "encrypts" them by prefixing
+type fakeKMS struct {
+	generated, decrypted int
+}
+
+func (k *fakeKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	k.generated++
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key, append([]byte("wrapped:"), key...), nil
+}
+
+func (k *fakeKMS) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return append([]byte("wrapped:"), plaintext...), nil
+}
+
+func (k *fakeKMS) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	k.decrypted++
+	if !strings.HasPrefix(string(encrypted), "wrapped:") {
+		return nil, errors.New("not a wrapped key")
+	}
+	return encrypted[len("wrapped:"):], nil
+}
+
+type answer struct {
+	Question string
+	Value    string `pii:"encrypt"`
+}
+
+type questionnaire struct {
+	ApplicantID string
+	TaxID       string `pii:"encrypt"`
+	Answers     []answer
+	Spouse      *answer
+	DataKey     []byte `pii:"key"`
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	kms := &fakeKMS{}
+	record := questionnaire{
+		ApplicantID: "app1",
+		TaxID:       "123-45-6789",
+		Answers:     []answer{{Question: "Occupation", Value: "Pilot"}},
+		Spouse:      &answer{Question: "Name", Value: "Sam"},
+	}
+
+	assert.NoError(t, NewCipher(kms).Encrypt(context.Background(), &record))
+	assert.Equal(t, "app1", record.ApplicantID, "untagged fields are stored as they are")
+	assert.Equal(t, "Occupation", record.Answers[0].Question)
+	for _, sealed := range []string{record.TaxID, record.Answers[0].Value, record.Spouse.Value} {
+		assert.True(t, strings.HasPrefix(sealed, prefix))
+	}
+	assert.NotEmpty(t, record.DataKey)
+
+	// A fresh cipher, as in a later request, needs KMS to open the data key once
+	kms.decrypted = 0
+	assert.NoError(t, NewCipher(kms).Decrypt(context.Background(), &record))
+	assert.Equal(t, "123-45-6789", record.TaxID)
+	assert.Equal(t, "Pilot", record.Answers[0].Value)
+	assert.Equal(t, "Sam", record.Spouse.Value)
+	assert.Equal(t, 1, kms.decrypted)
+}
+
+func TestEncryptKeepsDataKeyAndSealedValues(t *testing.T) {
+	kms := &fakeKMS{}
+	cipher := NewCipher(kms)
+	record := questionnaire{TaxID: "123-45-6789"}
+	assert.NoError(t, cipher.Encrypt(context.Background(), &record))
+	sealedTaxID, dataKey := record.TaxID, record.DataKey
+
+	// Updating one field and encrypting again reuses the key and leaves sealed values alone
+	record.Answers = []answer{{Question: "Occupation", Value: "Pilot"}}
+	assert.NoError(t, cipher.Encrypt(context.Background(), &record))
+	assert.Equal(t, 1, kms.generated)
+	assert.Equal(t, dataKey, record.DataKey)
+	assert.Equal(t, sealedTaxID, record.TaxID)
+	assert.True(t, strings.HasPrefix(record.Answers[0].Value, prefix))
+}
+
+func TestDecryptLeavesPlaintextValues(t *testing.T) {
+	// Stored before the field was tagged
+	record := questionnaire{TaxID: "123-45-6789"}
+	assert.NoError(t, NewCipher(&fakeKMS{}).Decrypt(context.Background(), &record))
+	assert.Equal(t, "123-45-6789", record.TaxID)
+
+	record.TaxID = prefix + "AAAA"
+	assert.ErrorIs(t, NewCipher(&fakeKMS{}).Decrypt(context.Background(), &record), ErrMissingKey)
+}
+
+func TestInvalidRecords(t *testing.T) {
+	cipher := NewCipher(&fakeKMS{})
+
+	noKey := struct {
+		TaxID string `pii:"encrypt"`
+	}{TaxID: "123"}
+	assert.ErrorIs(t, cipher.Encrypt(context.Background(), &noKey), ErrNoKeyField)
+
+	notString := struct {
+		Age     int    `pii:"encrypt"`
+		DataKey []byte `pii:"key"`
+	}{}
+	assert.Error(t, cipher.Encrypt(context.Background(), &notString))
+
+	assert.Error(t, cipher.Encrypt(context.Background(), questionnaire{}), "records must be passed by pointer")
+}
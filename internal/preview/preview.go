@@ -0,0 +1,167 @@
+// Package preview draws small JPEG previews of document files, so reviewer UIs can show a
+// document without being handed its original, and answers the cross-origin requests those
+// UIs make for them.
+//
+// Photos are resized as they are. PDFs are previewed from the image on their first page,
+// which is how scanners and phones write them; PDFs of text have no preview.
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // Registers PNG with image.Decode
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/watermark"
+)
+
+const (
+	// DefaultMaxDimension is the longest side of a preview, in pixels, when none is configured
+	DefaultMaxDimension = 800
+	// DefaultMaxAge is how long browsers may keep a preview when no time is configured
+	DefaultMaxAge = 5 * time.Minute
+	// ContentType is the type of every preview
+	ContentType = "image/jpeg"
+
+	maxPixels = 40_000_000 // Largest photo decoded, as for watermarking
+	quality   = 80
+)
+
+// ErrUnsupported is returned for files that have no preview, such as PDFs of text
+var ErrUnsupported = apperr.Unprocessable("preview_unavailable", "document cannot be previewed")
+
+// Render returns a JPEG of a PNG, JPEG or PDF file no larger than maxDimension along either
+// side, stamped with mark unless it is nil. Images are never enlarged.
+func Render(content []byte, mimeType string, maxDimension int, mark *watermark.Mark) ([]byte, error) {
+	if maxDimension <= 0 {
+		maxDimension = DefaultMaxDimension
+	}
+	src, err := decode(content, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := downscale(src, maxDimension)
+	if mark != nil {
+		watermark.DrawMark(dst, *mark)
+	}
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+// decode reads the image a preview is drawn from
+func decode(content []byte, mimeType string) (image.Image, error) {
+	switch mimeType {
+	case "application/pdf":
+		img, err := watermark.PageImage(content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+		return img, nil
+	case "image/png", "image/jpeg":
+		config, _, err := image.DecodeConfig(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+		if config.Width*config.Height > maxPixels {
+			return nil, fmt.Errorf("%w: image of %dx%d is too large", ErrUnsupported, config.Width, config.Height)
+		}
+		img, _, err := image.Decode(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+		return img, nil
+	default:
+		return nil, fmt.Errorf("%w: %s files", ErrUnsupported, mimeType)
+	}
+}
+
+// downscale shrinks an image to fit in a square of maxDimension, averaging the pixels each
+// preview pixel covers so text on the document stays legible
+func downscale(src image.Image, maxDimension int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, src, bounds.Min, draw.Src)
+
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDimension && height <= maxDimension {
+		return rgba
+	}
+	scaledWidth, scaledHeight := maxDimension, maxDimension
+	if width > height {
+		scaledHeight = max(1, height*maxDimension/width)
+	} else {
+		scaledWidth = max(1, width*maxDimension/height)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		y0, y1 := y*height/scaledHeight, max((y+1)*height/scaledHeight, y*height/scaledHeight+1)
+		for x := 0; x < scaledWidth; x++ {
+			x0, x1 := x*width/scaledWidth, max((x+1)*width/scaledWidth, x*width/scaledWidth+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for i := range sum {
+						sum[i] += int(row[sx*4+i])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			at := y*dst.Stride + x*4
+			for i := range sum {
+				dst.Pix[at+i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// CORS lets pages from origins read previews, answering their preflight requests. "*"
+// allows every origin. Responses to other origins carry no CORS headers, so browsers keep
+// them from the page, and their preflight requests are refused.
+func CORS(origins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		header.Set("X-Content-Type-Options", "nosniff")
+
+		origin := c.GetHeader("Origin")
+		if origin == "" || !(allowed["*"] || allowed[origin]) {
+			header.Set("Cross-Origin-Resource-Policy", "same-origin")
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Expose-Headers", "ETag, Content-Language, X-Verus-Response-Signature")
+		header.Set("Cross-Origin-Resource-Policy", "cross-origin")
+		if c.Request.Method == http.MethodOptions {
+			header.Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", "Authorization, X-API-Key, X-Timestamp, X-Nonce, X-Signature, Accept-Language, If-None-Match")
+			header.Set("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,122 @@
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/watermark"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 240
+	}
+	var out bytes.Buffer
+	require.NoError(t, png.Encode(&out, img))
+	return out.Bytes()
+}
+
+func decodeJPEG(t *testing.T, content []byte) image.Image {
+	img, err := jpeg.Decode(bytes.NewReader(content))
+	require.NoError(t, err)
+	return img
+}
+
+func TestRenderShrinksImages(t *testing.T) {
+	out, err := Render(testPNG(t, 1600, 400), "image/png", 800, nil)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 800, 200), decodeJPEG(t, out).Bounds())
+
+	out, err = Render(testPNG(t, 300, 900), "image/png", 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 266, DefaultMaxDimension), decodeJPEG(t, out).Bounds())
+
+	// Small images are not enlarged
+	out, err = Render(testPNG(t, 120, 80), "image/png", 800, nil)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 120, 80), decodeJPEG(t, out).Bounds())
+}
+
+func TestRenderStampsTheMark(t *testing.T) {
+	plain, err := Render(testPNG(t, 400, 400), "image/png", 200, nil)
+	require.NoError(t, err)
+	marked, err := Render(testPNG(t, 400, 400), "image/png", 200, &watermark.Mark{Lines: []string{"Acme - preview"}})
+	require.NoError(t, err)
+
+	darker := 0
+	a, b := decodeJPEG(t, plain), decodeJPEG(t, marked)
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			if color.GrayModel.Convert(b.At(x, y)).(color.Gray).Y+30 < color.GrayModel.Convert(a.At(x, y)).(color.Gray).Y {
+				darker++
+			}
+		}
+	}
+	assert.Greater(t, darker, 100)
+}
+
+func TestRenderUnsupported(t *testing.T) {
+	tests := map[string]struct {
+		content  []byte
+		mimeType string
+	}{
+		"Text PDF":       {[]byte("%PDF-1.4\nnot really a PDF"), "application/pdf"},
+		"Corrupt image":  {[]byte("\x89PNG\r\n\x1a\nbroken"), "image/png"},
+		"Other document": {[]byte("hello"), "text/plain"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Render(tc.content, tc.mimeType, 800, nil)
+			assert.True(t, errors.Is(err, ErrUnsupported))
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	cors := CORS([]string{"https://review.example.com/"})
+	router.OPTIONS("/preview", cors)
+	router.GET("/preview", cors, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	serve := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/preview", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodOptions, "https://review.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://review.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "X-API-Key")
+
+	w = serve(http.MethodGet, "https://review.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://review.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "cross-origin", w.Header().Get("Cross-Origin-Resource-Policy"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+
+	w = serve(http.MethodOptions, "https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = serve(http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "same-origin", w.Header().Get("Cross-Origin-Resource-Policy"))
+}
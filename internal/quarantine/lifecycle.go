@@ -0,0 +1,120 @@
+package quarantine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+)
+
+// RulePrefix starts the ID of every lifecycle rule the service manages. Rules with other
+// IDs were set up by hand and are kept as they are.
+const RulePrefix = "verus-"
+
+// Rule IDs of the managed rules
+const (
+	ExpireQuarantineRule = RulePrefix + "expire-quarantine"
+	ArchiveDocumentsRule = RulePrefix + "archive-documents"
+)
+
+const defaultQuarantineExpireDays = 7
+
+// Rules returns the lifecycle rules for the documents bucket: quarantined files expire, and
+// documents move to cheaper storage as they age. It fails on settings S3 would refuse or
+// that would archive quarantined files before they expire.
+func Rules(quarantine config.QuarantineSettings, lifecycle config.LifecycleSettings) ([]types.LifecycleRule, error) {
+	var rules []types.LifecycleRule
+
+	expireDays := lifecycle.QuarantineExpireDays
+	if expireDays <= 0 {
+		expireDays = defaultQuarantineExpireDays
+	}
+	if quarantine.Enabled {
+		rules = append(rules, types.LifecycleRule{
+			ID:                             aws.String(ExpireQuarantineRule),
+			Status:                         types.ExpirationStatusEnabled,
+			Filter:                         &types.LifecycleRuleFilter{Prefix: aws.String(Prefix(quarantine))},
+			Expiration:                     &types.LifecycleExpiration{Days: aws.Int32(expireDays)},
+			AbortIncompleteMultipartUpload: &types.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int32(1)},
+		})
+	}
+
+	if lifecycle.ArchiveAfterDays > 0 {
+		class := types.TransitionStorageClassGlacierIr
+		if lifecycle.ArchiveStorageClass != "" {
+			class = types.TransitionStorageClass(lifecycle.ArchiveStorageClass)
+		}
+		if !validStorageClass(class) {
+			return nil, fmt.Errorf("archiveStorageClass %q is not an S3 storage class documents can move to", class)
+		}
+		// A rule can't leave out a prefix, so the archive rule also covers quarantine
+		if quarantine.Enabled && lifecycle.ArchiveAfterDays <= expireDays {
+			return nil, fmt.Errorf("archiveAfterDays (%d) must be more than quarantineExpireDays (%d)", lifecycle.ArchiveAfterDays, expireDays)
+		}
+		rules = append(rules, types.LifecycleRule{
+			ID:          aws.String(ArchiveDocumentsRule),
+			Status:      types.ExpirationStatusEnabled,
+			Filter:      &types.LifecycleRuleFilter{Prefix: aws.String("")},
+			Transitions: []types.Transition{{Days: aws.Int32(lifecycle.ArchiveAfterDays), StorageClass: class}},
+		})
+	}
+	return rules, nil
+}
+
+func validStorageClass(class types.TransitionStorageClass) bool {
+	for _, known := range class.Values() {
+		if class == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyLifecycle replaces the managed rules of a bucket with rules, keeping any other rules
+// it has. Managed rules that are no longer wanted are removed.
+func ApplyLifecycle(ctx context.Context, client Client, bucket string, rules []types.LifecycleRule) error {
+	current, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(bucket)})
+	if err != nil && !noLifecycle(err) {
+		return fmt.Errorf("failed to read lifecycle rules of %s: %w", bucket, err)
+	}
+
+	var kept []types.LifecycleRule
+	if current != nil {
+		for _, rule := range current.Rules {
+			if !strings.HasPrefix(aws.ToString(rule.ID), RulePrefix) {
+				kept = append(kept, rule)
+			}
+		}
+	}
+	kept = append(kept, rules...)
+
+	// S3 refuses a configuration without rules, so an empty one is deleted instead
+	if len(kept) == 0 {
+		if current == nil || len(current.Rules) == 0 {
+			return nil
+		}
+		if _, err := client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{Bucket: aws.String(bucket)}); err != nil {
+			return fmt.Errorf("failed to remove lifecycle rules of %s: %w", bucket, err)
+		}
+		return nil
+	}
+	_, err = client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: kept},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write lifecycle rules of %s: %w", bucket, err)
+	}
+	return nil
+}
+
+// noLifecycle reports whether S3 answered that the bucket has no lifecycle configuration
+func noLifecycle(err error) bool {
+	var apiErr interface{ ErrorCode() string }
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration"
+}
@@ -0,0 +1,127 @@
+// Package quarantine keeps newly uploaded files under a restricted prefix of the documents
+// bucket until their document record has been saved, and then moves them to their
+// permanent key. A file that never gets that far is left in quarantine, where the
+// bucket's lifecycle rules expire it, so the permanent keys only ever hold files that a
+// committed record points at.
+//
+// The prefix is only as restricted as the bucket policy makes it: the API never records a
+// quarantined URL for a document, and the policy should deny reads under the prefix to
+// anything but the API's own role.
+package quarantine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+)
+
+// DefaultPrefix is where quarantined files are kept unless configured otherwise
+const DefaultPrefix = "quarantine/"
+
+// Client is the part of the S3 client quarantine and lifecycle management use
+type Client interface {
+	CopyObject(ctx context.Context, input *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, input *s3.DeleteObjectInput, opts ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	GetBucketLifecycleConfiguration(ctx context.Context, input *s3.GetBucketLifecycleConfigurationInput, opts ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error)
+	PutBucketLifecycleConfiguration(ctx context.Context, input *s3.PutBucketLifecycleConfigurationInput, opts ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error)
+	DeleteBucketLifecycle(ctx context.Context, input *s3.DeleteBucketLifecycleInput, opts ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error)
+}
+
+// Store moves files out of quarantine in the documents bucket
+type Store struct {
+	client Client
+	bucket string
+	prefix string
+}
+
+// New creates a Store for the documents bucket, or returns nil if quarantine is off
+func New(settings config.QuarantineSettings, bucket string, client Client) *Store {
+	if !settings.Enabled {
+		return nil
+	}
+	return &Store{client: client, bucket: bucket, prefix: Prefix(settings)}
+}
+
+// Prefix returns the configured prefix, or DefaultPrefix
+func Prefix(settings config.QuarantineSettings) string {
+	if settings.Prefix == "" {
+		return DefaultPrefix
+	}
+	return settings.Prefix
+}
+
+// Key returns the key a file is uploaded under. Without a quarantine it is the file's permanent key.
+func (s *Store) Key(fileName string) string {
+	if s == nil {
+		return fileName
+	}
+	return s.prefix + fileName
+}
+
+// Holds reports whether the file at fileURL is in quarantine
+func (s *Store) Holds(fileURL string) bool {
+	if s == nil {
+		return false
+	}
+	key, err := objectKey(fileURL)
+	return err == nil && strings.HasPrefix(key, s.prefix)
+}
+
+// Promote copies a quarantined file to its permanent key and returns its permanent URL.
+// The copy keeps the object's metadata, including the encrypted data key. Copying again
+// after an interrupted promotion is harmless.
+func (s *Store) Promote(ctx context.Context, fileURL string) (string, error) {
+	if !s.Holds(fileURL) {
+		return "", fmt.Errorf("file %s is not in quarantine", fileURL)
+	}
+	parsed, err := url.Parse(fileURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid file URL: %w", err)
+	}
+	key, _ := objectKey(fileURL)
+	permanent := strings.TrimPrefix(key, s.prefix)
+
+	source := &url.URL{Path: s.bucket + "/" + key}
+	_, err = s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(permanent),
+		CopySource: aws.String(source.EscapedPath()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to move %s out of quarantine: %w", key, err)
+	}
+	parsed.Path = "/" + permanent
+	parsed.RawPath = ""
+	return parsed.String(), nil
+}
+
+// Release removes a quarantined file once its permanent copy is recorded
+func (s *Store) Release(ctx context.Context, fileURL string) error {
+	if !s.Holds(fileURL) {
+		return nil
+	}
+	key, _ := objectKey(fileURL)
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from quarantine: %w", key, err)
+	}
+	return nil
+}
+
+// objectKey reads the object key from the URL the uploader returned for it
+func objectKey(fileURL string) (string, error) {
+	parsed, err := url.Parse(fileURL)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if key == "" {
+		return "", fmt.Errorf("no object key in %s", fileURL)
+	}
+	return key, nil
+}
@@ -0,0 +1,153 @@
+package quarantine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBucket records the calls made to it and holds one lifecycle configuration
+type fakeBucket struct {
+	copies    []*s3.CopyObjectInput
+	deletes   []string
+	rules     []types.LifecycleRule
+	hasRules  bool
+	deleted   bool
+	copyError error
+}
+
+func (b *fakeBucket) CopyObject(ctx context.Context, input *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	b.copies = append(b.copies, input)
+	return &s3.CopyObjectOutput{}, b.copyError
+}
+
+func (b *fakeBucket) DeleteObject(ctx context.Context, input *s3.DeleteObjectInput, opts ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	b.deletes = append(b.deletes, aws.ToString(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// noSuchLifecycle is the error S3 answers with for a bucket without lifecycle rules
+type noSuchLifecycle struct{}
+
+func (noSuchLifecycle) Error() string     { return "NoSuchLifecycleConfiguration" }
+func (noSuchLifecycle) ErrorCode() string { return "NoSuchLifecycleConfiguration" }
+
+func (b *fakeBucket) GetBucketLifecycleConfiguration(ctx context.Context, input *s3.GetBucketLifecycleConfigurationInput, opts ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	if !b.hasRules {
+		return nil, noSuchLifecycle{}
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: b.rules}, nil
+}
+
+func (b *fakeBucket) PutBucketLifecycleConfiguration(ctx context.Context, input *s3.PutBucketLifecycleConfigurationInput, opts ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	b.rules, b.hasRules = input.LifecycleConfiguration.Rules, true
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+func (b *fakeBucket) DeleteBucketLifecycle(ctx context.Context, input *s3.DeleteBucketLifecycleInput, opts ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+	b.rules, b.hasRules, b.deleted = nil, false, true
+	return &s3.DeleteBucketLifecycleOutput{}, nil
+}
+
+const quarantinedURL = "https://docs.s3.eu-west-1.amazonaws.com/quarantine/doc1.png"
+
+func TestStoreIsOffUnlessEnabled(t *testing.T) {
+	var store *Store = New(config.QuarantineSettings{}, "docs", &fakeBucket{})
+	assert.Nil(t, store)
+	assert.Equal(t, "doc1.png", store.Key("doc1.png"))
+	assert.False(t, store.Holds(quarantinedURL))
+	assert.NoError(t, store.Release(context.Background(), quarantinedURL))
+}
+
+func TestPromoteCopiesToThePermanentKey(t *testing.T) {
+	bucket := &fakeBucket{}
+	store := New(config.QuarantineSettings{Enabled: true}, "docs", bucket)
+	assert.Equal(t, "quarantine/doc1.png", store.Key("doc1.png"))
+	assert.True(t, store.Holds(quarantinedURL))
+	assert.False(t, store.Holds("https://docs.s3.eu-west-1.amazonaws.com/doc1.png"))
+
+	storedURL, err := store.Promote(context.Background(), quarantinedURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://docs.s3.eu-west-1.amazonaws.com/doc1.png", storedURL)
+	require.Len(t, bucket.copies, 1)
+	assert.Equal(t, "docs", aws.ToString(bucket.copies[0].Bucket))
+	assert.Equal(t, "doc1.png", aws.ToString(bucket.copies[0].Key))
+	assert.Equal(t, "docs/quarantine/doc1.png", aws.ToString(bucket.copies[0].CopySource))
+
+	require.NoError(t, store.Release(context.Background(), quarantinedURL))
+	assert.Equal(t, []string{"quarantine/doc1.png"}, bucket.deletes)
+
+	// Files that are already permanent are never moved or removed
+	_, err = store.Promote(context.Background(), storedURL)
+	assert.Error(t, err)
+	require.NoError(t, store.Release(context.Background(), storedURL))
+	assert.Len(t, bucket.deletes, 1)
+}
+
+func TestPromoteFailure(t *testing.T) {
+	bucket := &fakeBucket{copyError: errors.New("access denied")}
+	store := New(config.QuarantineSettings{Enabled: true, Prefix: "incoming/"}, "docs", bucket)
+
+	_, err := store.Promote(context.Background(), "https://docs.s3.eu-west-1.amazonaws.com/incoming/doc1.png")
+	assert.ErrorIs(t, err, bucket.copyError)
+}
+
+func TestRules(t *testing.T) {
+	quarantine := config.QuarantineSettings{Enabled: true}
+
+	rules, err := Rules(quarantine, config.LifecycleSettings{})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, ExpireQuarantineRule, aws.ToString(rules[0].ID))
+	assert.Equal(t, DefaultPrefix, aws.ToString(rules[0].Filter.Prefix))
+	assert.Equal(t, int32(7), aws.ToInt32(rules[0].Expiration.Days))
+
+	rules, err = Rules(quarantine, config.LifecycleSettings{QuarantineExpireDays: 3, ArchiveAfterDays: 365})
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, ArchiveDocumentsRule, aws.ToString(rules[1].ID))
+	assert.Equal(t, types.TransitionStorageClassGlacierIr, rules[1].Transitions[0].StorageClass)
+	assert.Equal(t, int32(365), aws.ToInt32(rules[1].Transitions[0].Days))
+
+	_, err = Rules(quarantine, config.LifecycleSettings{ArchiveAfterDays: 5})
+	assert.Error(t, err, "documents in quarantine would be archived before they expire")
+	_, err = Rules(quarantine, config.LifecycleSettings{ArchiveAfterDays: 90, ArchiveStorageClass: "TAPE"})
+	assert.Error(t, err)
+
+	rules, err = Rules(config.QuarantineSettings{}, config.LifecycleSettings{})
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestApplyLifecycleKeepsOtherRules(t *testing.T) {
+	manual := types.LifecycleRule{ID: aws.String("logs"), Status: types.ExpirationStatusEnabled, Filter: &types.LifecycleRuleFilter{Prefix: aws.String("logs/")}}
+	bucket := &fakeBucket{}
+	rules, err := Rules(config.QuarantineSettings{Enabled: true}, config.LifecycleSettings{ArchiveAfterDays: 365})
+	require.NoError(t, err)
+
+	// A bucket without any rules yet
+	require.NoError(t, ApplyLifecycle(context.Background(), bucket, "docs", rules))
+	assert.Len(t, bucket.rules, 2)
+
+	bucket.rules = append(bucket.rules, manual)
+	require.NoError(t, ApplyLifecycle(context.Background(), bucket, "docs", rules[:1]))
+	ids := []string{}
+	for _, rule := range bucket.rules {
+		ids = append(ids, aws.ToString(rule.ID))
+	}
+	assert.Equal(t, []string{"logs", ExpireQuarantineRule}, ids)
+
+	// Without managed rules only the manual one is left, and without that the configuration goes
+	require.NoError(t, ApplyLifecycle(context.Background(), bucket, "docs", nil))
+	assert.Equal(t, []types.LifecycleRule{manual}, bucket.rules)
+	bucket.rules = rules
+	require.NoError(t, ApplyLifecycle(context.Background(), bucket, "docs", nil))
+	assert.True(t, bucket.deleted)
+}
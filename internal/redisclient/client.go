@@ -0,0 +1,27 @@
+// Package redisclient connects to the Redis server replicas share state through, given as
+// a redis:// or, over TLS, rediss:// URL whose user info is the username and password to
+// authenticate with.
+package redisclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// timeout bounds dialling Redis and each command
+const timeout = 5 * time.Second
+
+// New returns a client of the server at rawURL. Connections are made as commands need them,
+// so an unreachable server is reported by the first command rather than here.
+func New(rawURL string) (*redis.Client, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	opts.DialTimeout = timeout
+	opts.ReadTimeout = timeout
+	opts.WriteTimeout = timeout
+	return redis.NewClient(opts), nil
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// RekeyApplicant is the handler function for re-encrypting an applicant's personal data and
+// the data keys of its documents' files under the current KMS key, such as after a key is
+// suspected to be compromised
+func RekeyApplicant(c *gin.Context, service interfaces.RekeyService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	applicantID := c.Param("id")
+	result, err := service.Rekey(c.Request.Context(), clientID, applicantID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, result)
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	default:
+		zaplogger.GetLogger().Error("Error rekeying applicant", zap.Error(err), zap.String("applicantID", applicantID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not rekey applicant"})
+	}
+}
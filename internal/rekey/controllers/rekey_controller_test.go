@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/rekey/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRekeyApplicant(t *testing.T) {
+	tests := []struct {
+		name               string
+		err                error
+		expectedStatusCode int
+	}{
+		{"Rekeyed", nil, http.StatusOK},
+		{"Unknown applicant", apperr.ErrApplicantNotFound, http.StatusNotFound},
+		{"Changed meanwhile", services.ErrApplicantChanged, http.StatusConflict},
+		{"No KMS", services.ErrRekeyDisabled, http.StatusServiceUnavailable},
+		{"Other error", errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockRekeyService)
+			mockService.On("Rekey", mock.Anything, "client1", "app1").
+				Return(localModels.Rekey{ApplicantID: "app1", PII: true, FilesRekeyed: 3}, tt.err)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) { c.Set("client_id", "client1") })
+			router.POST("/applicants/:id/rekey", func(c *gin.Context) { RekeyApplicant(c, mockService) })
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/applicants/app1/rekey", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.err == nil {
+				assert.Contains(t, w.Body.String(), `"files_rekeyed":3`)
+				assert.NotContains(t, w.Body.String(), "skipped")
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pii"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrApplicantChanged is returned when the applicant kept changing while it was re-encrypted
+	ErrApplicantChanged = apperr.Conflict("applicant_changed", "applicant was changed by another request, try again")
+	// ErrRekeyDisabled is returned when no KMS is configured to re-encrypt with
+	ErrRekeyDisabled = apperr.Unavailable("rekey_unavailable", "re-encryption is not available")
+)
+
+// metadataKey is the S3 metadata entry holding a file's encrypted data key
+const metadataKey = "encrypted-key"
+
+// rekeyAttempts bounds how often an applicant changed by other requests is read again
+const rekeyAttempts = 3
+
+// Reasons a file's data key is left as it was
+const (
+	reasonArchived = "the file is in archival storage; restore it and rekey again"
+	reasonNoKey    = "the file has no data key"
+	reasonFailed   = "the file's data key could not be re-encrypted"
+	reasonNoFiles  = "file storage is not available to rekey files"
+)
+
+// FileClient is the part of the S3 client that rewrites the metadata of files
+type FileClient interface {
+	HeadObject(ctx context.Context, input *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	CopyObject(ctx context.Context, input *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+}
+
+// RekeyServiceImpl re-encrypts an applicant's personal data, and the data keys of its
+// documents' files, under the current KMS key. Personal data is sealed again with a new
+// data key. Files keep their data key, as re-encrypting them would mean downloading and
+// uploading each one, but the key is encrypted again and the old copy is replaced.
+type RekeyServiceImpl struct {
+	ApplicantCollection string
+	DocumentCollection  string
+	KMSUploader         localInterfaces.KMSUploader
+	Files               FileClient // Rewrites the data keys of files; nil leaves files out
+	Bucket              string
+}
+
+var (
+	instance RekeyServiceImpl
+	once     sync.Once
+)
+
+func GetRekeyServiceImpl() RekeyServiceImpl {
+	once.Do(func() {
+		instance = RekeyServiceImpl{
+			ApplicantCollection: constants.CollectionApplicants,
+			DocumentCollection:  localConstants.CollectionDocuments,
+		}
+	})
+	return instance
+}
+
+// Rekey re-encrypts a client's applicant and the data keys of its documents' files. Files
+// that cannot be rewritten, such as those in archival storage, are listed as skipped.
+func (s *RekeyServiceImpl) Rekey(ctx context.Context, clientID, applicantID string) (localModels.Rekey, error) {
+	if s.KMSUploader == nil {
+		return localModels.Rekey{}, ErrRekeyDisabled
+	}
+	// New data keys are made under the client's own KMS key when it has one
+	ctx = clientconfig.WithClientID(ctx, clientID)
+	result := localModels.Rekey{ApplicantID: applicantID}
+	rekeyed, err := s.rekeyApplicant(ctx, clientID, applicantID)
+	if err != nil {
+		return localModels.Rekey{}, err
+	}
+	result.PII = rekeyed
+
+	documents, err := s.documents(ctx, clientID, applicantID)
+	if err != nil {
+		return localModels.Rekey{}, err
+	}
+	now := timestamp.Now()
+	for _, doc := range documents {
+		for _, file := range filesOf(doc) {
+			if file.url == "" || file.url == localModels.PlaceholderFileURL {
+				continue
+			}
+			if file.current && !doc.ColdStorage.Downloadable(now) {
+				result.Skipped = append(result.Skipped, localModels.SkippedFile{DocumentID: doc.DocumentID, Version: file.version, Reason: reasonArchived})
+				continue
+			}
+			if s.Files == nil {
+				result.Skipped = append(result.Skipped, localModels.SkippedFile{DocumentID: doc.DocumentID, Version: file.version, Reason: reasonNoFiles})
+				continue
+			}
+			reason, err := s.rewrapFile(ctx, file.url)
+			if err != nil {
+				zaplogger.GetLogger().Error("Error re-encrypting file data key", zap.Error(err),
+					zap.String("documentID", doc.DocumentID), zap.Int("version", file.version))
+				reason = reasonFailed
+			}
+			if reason != "" {
+				result.Skipped = append(result.Skipped, localModels.SkippedFile{DocumentID: doc.DocumentID, Version: file.version, Reason: reason})
+				continue
+			}
+			result.FilesRekeyed++
+		}
+	}
+	result.RekeyedAt = timestamp.Now()
+	zaplogger.GetLogger().Info("Applicant rekeyed", zap.String("clientID", clientID), zap.String("applicantID", applicantID),
+		zap.Bool("pii", result.PII), zap.Int("files", result.FilesRekeyed), zap.Int("skipped", len(result.Skipped)))
+	return result, nil
+}
+
+// rekeyApplicant seals the applicant's personal data again under a new data key, reporting
+// whether it had any. The write is made only if the applicant is unchanged since it was read.
+func (s *RekeyServiceImpl) rekeyApplicant(ctx context.Context, clientID, applicantID string) (bool, error) {
+	collection := tenant.Guard(common.GetCollection(s.ApplicantCollection))
+	filter := tenant.Of(clientID).With("applicant_id", applicantID).With("deleted", false)
+
+	for attempt := 0; attempt < rekeyAttempts; attempt++ {
+		var applicant localModels.ApplicantRecord
+		err := collection.FindOne(ctx, filter).Decode(&applicant)
+		if err == mongo.ErrNoDocuments {
+			return false, apperr.ErrApplicantNotFound
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to look up applicant: %w", err)
+		}
+
+		changes, err := s.reseal(ctx, applicant)
+		if err != nil {
+			return false, err
+		}
+		resealed := len(changes) > 0
+		now := timestamp.Now()
+		changes["rekeyed_at"] = now
+		changes["updated_at"] = now
+		update := bson.M{"$set": changes}
+		if err := mongoschema.ValidateUpdate(s.ApplicantCollection, update); err != nil {
+			return false, err
+		}
+		var matched int64
+		err = mongoretry.Write(ctx, "rekey_applicant", func(ctx context.Context) error {
+			result, err := collection.UpdateOne(ctx, filter.With("updated_at", applicant.UpdatedAt), update)
+			if err != nil {
+				return err
+			}
+			matched = result.MatchedCount
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		if matched == 1 {
+			return resealed, nil
+		}
+	}
+	return false, ErrApplicantChanged
+}
+
+// reseal returns the stored fields to set for an applicant's personal data sealed under
+// new data keys: its date of birth and address, and what was read from its document
+func (s *RekeyServiceImpl) reseal(ctx context.Context, applicant localModels.ApplicantRecord) (bson.M, error) {
+	changes := bson.M{}
+	if len(applicant.EncryptedData.EncryptedKey) > 0 {
+		encryptedData, err := s.resealData(ctx, applicant.EncryptedData)
+		if err != nil {
+			return nil, err
+		}
+		changes["encrypted_data"] = encryptedData
+	}
+
+	if intake := applicant.Intake; intake != nil && len(intake.DataKey) > 0 {
+		cipher := pii.NewCipher(s.KMSUploader)
+		if err := cipher.Decrypt(ctx, intake); err != nil {
+			return nil, fmt.Errorf("failed to decrypt intake: %w", err)
+		}
+		intake.DataKey = nil
+		if err := cipher.Encrypt(ctx, intake); err != nil {
+			return nil, fmt.Errorf("failed to encrypt intake: %w", err)
+		}
+		changes["intake.extracted"] = intake.Extracted
+		changes["intake.data_key"] = intake.DataKey
+	}
+	return changes, nil
+}
+
+// resealData decrypts an applicant's date of birth and address and encrypts them again
+// with a new data key. Fields that were never set are left empty.
+func (s *RekeyServiceImpl) resealData(ctx context.Context, data coreModels.EncryptedData) (coreModels.EncryptedData, error) {
+	oldKey, err := s.KMSUploader.DecryptData(ctx, data.EncryptedKey)
+	if err != nil {
+		return coreModels.EncryptedData{}, fmt.Errorf("failed to decrypt applicant data key: %w", err)
+	}
+	newKey, encryptedKey, err := s.KMSUploader.GenerateDataKey(ctx)
+	if err != nil {
+		return coreModels.EncryptedData{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	resealed := coreModels.EncryptedData{EncryptedKey: encryptedKey}
+	if len(data.DOB.Nonce) > 0 {
+		dob, err := utils.DecryptField(data.DOB, oldKey)
+		if err != nil {
+			return coreModels.EncryptedData{}, fmt.Errorf("failed to decrypt date of birth: %w", err)
+		}
+		if resealed.DOB, err = utils.EncryptField(dob, newKey); err != nil {
+			return coreModels.EncryptedData{}, fmt.Errorf("failed to encrypt date of birth: %w", err)
+		}
+	}
+	if len(data.Address.Line1.Nonce) > 0 {
+		address, err := utils.DecryptAddress(data.Address, oldKey)
+		if err != nil {
+			return coreModels.EncryptedData{}, fmt.Errorf("failed to decrypt address: %w", err)
+		}
+		if resealed.Address, err = utils.EncryptAddress(address, newKey); err != nil {
+			return coreModels.EncryptedData{}, fmt.Errorf("failed to encrypt address: %w", err)
+		}
+	}
+	return resealed, nil
+}
+
+// rewrapFile encrypts a file's data key again under the current KMS key, copying the file
+// onto itself with the new key in its metadata. The copy is only made if the file is
+// unchanged since its metadata was read. It returns why a file was skipped, if it was.
+func (s *RekeyServiceImpl) rewrapFile(ctx context.Context, fileURL string) (string, error) {
+	key, err := objectKey(fileURL)
+	if err != nil {
+		return "", err
+	}
+	head, err := s.Files.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+	if err != nil {
+		return "", fmt.Errorf("failed to read metadata of %s: %w", key, err)
+	}
+	encoded := head.Metadata[metadataKey]
+	if encoded == "" {
+		return reasonNoKey, nil
+	}
+	encryptedKey, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("malformed data key of %s: %w", key, err)
+	}
+	dataKey, err := s.KMSUploader.DecryptData(ctx, encryptedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data key of %s: %w", key, err)
+	}
+	rewrapped, err := s.KMSUploader.EncryptData(ctx, dataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt data key of %s: %w", key, err)
+	}
+
+	metadata := make(map[string]string, len(head.Metadata))
+	for name, value := range head.Metadata {
+		metadata[name] = value
+	}
+	metadata[metadataKey] = base64.StdEncoding.EncodeToString(rewrapped)
+	source := &url.URL{Path: s.Bucket + "/" + key}
+	_, err = s.Files.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(s.Bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(source.EscapedPath()),
+		CopySourceIfMatch: head.ETag,
+		ContentType:       head.ContentType,
+		StorageClass:      types.StorageClass(head.StorageClass),
+		Metadata:          metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to rewrite metadata of %s: %w", key, err)
+	}
+	return "", nil
+}
+
+// documents lists every document of a client's applicant, deleted ones included, as
+// their files are kept
+func (s *RekeyServiceImpl) documents(ctx context.Context, clientID, applicantID string) ([]localModels.DocumentRecord, error) {
+	cursor, err := tenant.Guard(common.GetCollection(s.DocumentCollection)).
+		Find(ctx, tenant.Of(clientID).With("applicant_id", applicantID),
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	documents := []localModels.DocumentRecord{}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, fmt.Errorf("failed to decode documents: %w", err)
+	}
+	return documents, nil
+}
+
+// file is one of a document's files: its current one, or one it replaced
+type file struct {
+	url     string
+	version int
+	current bool
+}
+
+// filesOf returns a document's current file and those it replaced, oldest first
+func filesOf(doc localModels.DocumentRecord) []file {
+	files := make([]file, 0, len(doc.Versions)+1)
+	for _, version := range doc.Versions {
+		files = append(files, file{url: version.FileURL, version: version.Version})
+	}
+	return append(files, file{url: doc.FileURL, version: doc.CurrentVersion(), current: true})
+}
+
+// objectKey returns the S3 key of a file from its URL
+func objectKey(fileURL string) (string, error) {
+	parsed, err := url.Parse(fileURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid file URL: %w", err)
+	}
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if key == "" {
+		return "", fmt.Errorf("file URL %q has no object key", fileURL)
+	}
+	return key, nil
+}
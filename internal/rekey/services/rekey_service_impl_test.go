@@ -0,0 +1,169 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pii"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKMS wraps data keys by prefixing them with the name of its key, and unwraps keys
+// wrapped by either its key or the old one
+type fakeKMS struct{}
+
+func (fakeKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	return key, append([]byte("new:"), key...), nil
+}
+
+func (fakeKMS) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return append([]byte("new:"), plaintext...), nil
+}
+
+func (fakeKMS) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	for _, prefix := range [][]byte{[]byte("old:"), []byte("new:")} {
+		if bytes.HasPrefix(encrypted, prefix) {
+			return encrypted[len(prefix):], nil
+		}
+	}
+	return nil, errors.New("unknown key")
+}
+
+// fakeFiles serves the metadata of one file and records copies of it
+type fakeFiles struct {
+	head   s3.HeadObjectOutput
+	copies []*s3.CopyObjectInput
+}
+
+func (f *fakeFiles) HeadObject(ctx context.Context, input *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return &f.head, nil
+}
+
+func (f *fakeFiles) CopyObject(ctx context.Context, input *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	f.copies = append(f.copies, input)
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func TestResealData(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	dob, err := utils.EncryptField("1990-01-31", oldKey)
+	require.NoError(t, err)
+	address := coreModels.RawAddress{Line1: "1 Main St", City: "Springfield", Country: "US"}
+	encryptedAddress, err := utils.EncryptAddress(address, oldKey)
+	require.NoError(t, err)
+	service := RekeyServiceImpl{KMSUploader: fakeKMS{}}
+
+	resealed, err := service.resealData(context.Background(), coreModels.EncryptedData{
+		DOB: dob, Address: encryptedAddress, EncryptedKey: append([]byte("old:"), oldKey...),
+	})
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(resealed.EncryptedKey, []byte("new:")))
+	newKey := resealed.EncryptedKey[len("new:"):]
+	assert.NotEqual(t, oldKey, newKey)
+
+	opened, err := utils.DecryptField(resealed.DOB, newKey)
+	require.NoError(t, err)
+	assert.Equal(t, "1990-01-31", opened)
+	openedAddress, err := utils.DecryptAddress(resealed.Address, newKey)
+	require.NoError(t, err)
+	assert.Equal(t, address, openedAddress)
+	_, err = utils.DecryptField(resealed.DOB, oldKey)
+	assert.Error(t, err)
+
+	// Applicants created from a document have no address until they are confirmed
+	resealed, err = service.resealData(context.Background(), coreModels.EncryptedData{DOB: dob, EncryptedKey: append([]byte("old:"), oldKey...)})
+	require.NoError(t, err)
+	assert.Empty(t, resealed.Address.Line1.Ciphertext)
+	assert.NotEmpty(t, resealed.DOB.Ciphertext)
+}
+
+func TestResealIntake(t *testing.T) {
+	intake := &localModels.ApplicantIntake{
+		State:     localModels.IntakeProvisional,
+		Extracted: localModels.ExtractedIdentity{Source: "mrz", FirstName: "Ana", LastName: "Silva", DOB: "1990-01-31"},
+	}
+	require.NoError(t, pii.NewCipher(fakeKMS{}).Encrypt(context.Background(), intake))
+	oldKey := intake.DataKey
+	service := RekeyServiceImpl{KMSUploader: fakeKMS{}}
+
+	changes, err := service.reseal(context.Background(), localModels.ApplicantRecord{Intake: intake})
+	require.NoError(t, err)
+	assert.NotContains(t, changes, "encrypted_data")
+	dataKey := changes["intake.data_key"].([]byte)
+	assert.NotEqual(t, oldKey, dataKey)
+
+	resealed := localModels.ApplicantIntake{Extracted: changes["intake.extracted"].(localModels.ExtractedIdentity), DataKey: dataKey}
+	require.NoError(t, pii.NewCipher(fakeKMS{}).Decrypt(context.Background(), &resealed))
+	assert.Equal(t, "Ana", resealed.Extracted.FirstName)
+	assert.Equal(t, "1990-01-31", resealed.Extracted.DOB)
+
+	// Applicants without encrypted fields have nothing to reseal
+	changes, err = service.reseal(context.Background(), localModels.ApplicantRecord{})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestRewrapFile(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{9}, 32)
+	files := &fakeFiles{head: s3.HeadObjectOutput{
+		ETag:         aws.String(`"etag1"`),
+		ContentType:  aws.String("image/jpeg"),
+		StorageClass: types.StorageClassStandardIa,
+		Metadata: map[string]string{
+			metadataKey: base64.StdEncoding.EncodeToString(append([]byte("old:"), dataKey...)),
+			"nonce":     "bm9uY2U=",
+		},
+	}}
+	service := RekeyServiceImpl{KMSUploader: fakeKMS{}, Files: files, Bucket: "documents"}
+
+	reason, err := service.rewrapFile(context.Background(), "https://documents.s3.amazonaws.com/client1/app1/passport.jpg")
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+	require.Len(t, files.copies, 1)
+	copied := files.copies[0]
+	assert.Equal(t, "client1/app1/passport.jpg", aws.ToString(copied.Key))
+	assert.Equal(t, "documents/client1/app1/passport.jpg", aws.ToString(copied.CopySource))
+	assert.Equal(t, `"etag1"`, aws.ToString(copied.CopySourceIfMatch))
+	assert.Equal(t, types.MetadataDirectiveReplace, copied.MetadataDirective)
+	assert.Equal(t, types.StorageClassStandardIa, copied.StorageClass)
+	assert.Equal(t, "image/jpeg", aws.ToString(copied.ContentType))
+	assert.Equal(t, "bm9uY2U=", copied.Metadata["nonce"])
+	rewrapped, err := base64.StdEncoding.DecodeString(copied.Metadata[metadataKey])
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte("new:"), dataKey...), rewrapped)
+
+	// Files stored without a data key are left alone
+	files.head.Metadata = map[string]string{}
+	reason, err = service.rewrapFile(context.Background(), "https://documents.s3.amazonaws.com/client1/app1/old.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, reasonNoKey, reason)
+	assert.Len(t, files.copies, 1)
+}
+
+func TestFilesOf(t *testing.T) {
+	doc := localModels.DocumentRecord{
+		Document: coreModels.Document{DocumentID: "doc1", FileURL: "https://b.s3.amazonaws.com/v3"},
+		Version:  3,
+		Versions: []localModels.DocumentVersion{{Version: 1, FileURL: "https://b.s3.amazonaws.com/v1"}, {Version: 2, FileURL: "https://b.s3.amazonaws.com/v2"}},
+	}
+	assert.Equal(t, []file{
+		{url: "https://b.s3.amazonaws.com/v1", version: 1},
+		{url: "https://b.s3.amazonaws.com/v2", version: 2},
+		{url: "https://b.s3.amazonaws.com/v3", version: 3, current: true},
+	}, filesOf(doc))
+}
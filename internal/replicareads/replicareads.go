@@ -0,0 +1,187 @@
+// Package replicareads sends the reads of chosen list endpoints, and of compliance exports,
+// to MongoDB secondaries with the read preference in settings.mongo.secondaryReads, so bulk
+// reads do not load the primary.
+//
+// Only reads made through Collection leave the primary, and only for requests to the
+// configured routes. Writes always go to the primary. So that a client reads what it has
+// just written, its reads stay on the primary for MaxStaleness after each of its writes
+// handled by this instance; a write handled by another instance can still be missed for
+// up to that long.
+package replicareads
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// MinStaleness is the least max staleness the driver accepts
+const MinStaleness = 90 * time.Second
+
+// DefaultMode is used when no mode is configured
+const DefaultMode = "secondaryPreferred"
+
+var (
+	mu        sync.RWMutex
+	pref      *readpref.ReadPref
+	staleness time.Duration
+	routes    = map[string]bool{}
+	exports   bool
+
+	writesMu sync.Mutex
+	writes   = map[string]time.Time{} // Client ID -> time of its last write on this instance
+)
+
+// contextKey marks a context whose reads may go to secondaries
+type contextKey struct{}
+
+// marker is what a marked context holds. Requests carry their gin context, as the client
+// is only known once the request has been authenticated, after this middleware has run.
+type marker struct {
+	c *gin.Context
+}
+
+// Configure sets which routes read from secondaries and the read preference they use. It
+// fails if the mode is not secondary or secondaryPreferred, or the staleness is below
+// MinStaleness.
+func Configure(settings config.SecondaryReadSettings) error {
+	mode := strings.TrimSpace(settings.Mode)
+	if mode == "" {
+		mode = DefaultMode
+	}
+	maxStaleness := settings.MaxStaleness
+	if maxStaleness == 0 {
+		maxStaleness = MinStaleness
+	}
+	if maxStaleness < MinStaleness {
+		return fmt.Errorf("mongo.secondaryReads.maxStaleness must be at least %s, not %s", MinStaleness, maxStaleness)
+	}
+
+	var next *readpref.ReadPref
+	var err error
+	switch mode {
+	case "secondary":
+		next, err = readpref.New(readpref.SecondaryMode, readpref.WithMaxStaleness(maxStaleness))
+	case "secondaryPreferred":
+		next, err = readpref.New(readpref.SecondaryPreferredMode, readpref.WithMaxStaleness(maxStaleness))
+	default:
+		return fmt.Errorf("mongo.secondaryReads.mode must be secondary or secondaryPreferred, not %q", settings.Mode)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid mongo.secondaryReads: %w", err)
+	}
+
+	configured := make(map[string]bool, len(settings.Routes))
+	for _, route := range settings.Routes {
+		if route = strings.Join(strings.Fields(route), " "); route != "" {
+			configured[route] = true
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	pref = next
+	staleness = maxStaleness
+	routes = configured
+	exports = settings.Exports
+	return nil
+}
+
+// Middleware marks the requests of configured routes so their reads may go to secondaries,
+// and notes when each client last wrote so its own reads stay on the primary
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enabled(c.Request.Method + " " + c.FullPath()) {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, marker{c: c}))
+		}
+
+		c.Next()
+
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+		if clientID := c.GetString("client_id"); clientID != "" {
+			wrote(clientID, time.Now())
+		}
+	}
+}
+
+// ForExport marks the context of a background export, such as a compliance report, so its
+// reads go to secondaries when exports are configured to
+func ForExport(ctx context.Context) context.Context {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !exports {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, marker{})
+}
+
+// Collection returns coll reading from secondaries when ctx was marked by Middleware or
+// ForExport and its client has not written recently, and coll itself otherwise
+func Collection(ctx context.Context, coll *mongo.Collection) *mongo.Collection {
+	m, ok := ctx.Value(contextKey{}).(marker)
+	if !ok {
+		return coll
+	}
+	mu.RLock()
+	current, window := pref, staleness
+	mu.RUnlock()
+	if current == nil {
+		return coll
+	}
+	if m.c != nil && wroteWithin(m.c.GetString("client_id"), window, time.Now()) {
+		return coll
+	}
+	clone, err := coll.Clone(options.Collection().SetReadPreference(current))
+	if err != nil {
+		return coll
+	}
+	return clone
+}
+
+// enabled reports whether a route reads from secondaries
+func enabled(route string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return pref != nil && routes[route]
+}
+
+// wrote notes that a client wrote at a time, and forgets writes that no longer matter
+func wrote(clientID string, at time.Time) {
+	mu.RLock()
+	configured, window := pref != nil, staleness
+	mu.RUnlock()
+	if !configured {
+		return
+	}
+
+	writesMu.Lock()
+	defer writesMu.Unlock()
+	writes[clientID] = at
+	for id, last := range writes {
+		if at.Sub(last) > window {
+			delete(writes, id)
+		}
+	}
+}
+
+// wroteWithin reports whether a client wrote within window of now
+func wroteWithin(clientID string, window time.Duration, now time.Time) bool {
+	if clientID == "" {
+		return false
+	}
+	writesMu.Lock()
+	defer writesMu.Unlock()
+	last, ok := writes[clientID]
+	return ok && now.Sub(last) <= window
+}
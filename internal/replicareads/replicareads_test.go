@@ -0,0 +1,99 @@
+package replicareads
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testCollection is a collection of a client that never connects
+func testCollection(t *testing.T) *mongo.Collection {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://mongo1.example.net:27017"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	return client.Database("test_db").Collection("applicants")
+}
+
+func reset(t *testing.T) {
+	t.Cleanup(func() {
+		mu.Lock()
+		pref, staleness, routes, exports = nil, 0, map[string]bool{}, false
+		mu.Unlock()
+		writesMu.Lock()
+		writes = map[string]time.Time{}
+		writesMu.Unlock()
+	})
+}
+
+func TestConfigureValidates(t *testing.T) {
+	reset(t)
+
+	assert.Error(t, Configure(config.SecondaryReadSettings{Mode: "nearest"}))
+	assert.Error(t, Configure(config.SecondaryReadSettings{MaxStaleness: time.Minute}))
+	require.NoError(t, Configure(config.SecondaryReadSettings{Routes: []string{" GET   /api/v2/applicants "}}))
+	assert.True(t, enabled("GET /api/v2/applicants"))
+	assert.False(t, enabled("GET /api/v2/applicants/:id"))
+	assert.Equal(t, MinStaleness, staleness)
+}
+
+func TestMiddleware(t *testing.T) {
+	reset(t)
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, Configure(config.SecondaryReadSettings{Routes: []string{"GET /applicants"}}))
+	coll := testCollection(t)
+
+	var secondary bool
+	r := gin.New()
+	r.Use(Middleware())
+	r.Use(func(c *gin.Context) { c.Set("client_id", "client1") }) // As authentication does
+	r.GET("/applicants", func(c *gin.Context) {
+		secondary = Collection(c.Request.Context(), coll) != coll
+		c.Status(http.StatusOK)
+	})
+	r.GET("/applicants/:id", func(c *gin.Context) {
+		secondary = Collection(c.Request.Context(), coll) != coll
+		c.Status(http.StatusOK)
+	})
+	r.POST("/applicants", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.PATCH("/applicants/:id", func(c *gin.Context) { c.Status(http.StatusUnprocessableEntity) })
+	serve := func(method, path string) {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+	}
+
+	serve(http.MethodGet, "/applicants")
+	assert.True(t, secondary, "configured routes read from secondaries")
+	serve(http.MethodGet, "/applicants/a1")
+	assert.False(t, secondary, "other routes read from the primary")
+
+	// Failed writes change nothing, so reads may still go to secondaries
+	serve(http.MethodPatch, "/applicants/a1")
+	serve(http.MethodGet, "/applicants")
+	assert.True(t, secondary)
+
+	// After a write the client reads its own writes from the primary
+	serve(http.MethodPost, "/applicants")
+	serve(http.MethodGet, "/applicants")
+	assert.False(t, secondary)
+	assert.False(t, wroteWithin("client1", MinStaleness, time.Now().Add(MinStaleness+time.Second)))
+}
+
+func TestForExport(t *testing.T) {
+	reset(t)
+	coll := testCollection(t)
+
+	require.NoError(t, Configure(config.SecondaryReadSettings{}))
+	assert.Same(t, coll, Collection(ForExport(context.Background()), coll))
+
+	require.NoError(t, Configure(config.SecondaryReadSettings{Mode: "secondary", Exports: true}))
+	assert.NotSame(t, coll, Collection(ForExport(context.Background()), coll))
+	assert.Same(t, coll, Collection(context.Background(), coll))
+}
@@ -0,0 +1,139 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// ComplianceContentTypes are the content types of compliance reports by format
+var ComplianceContentTypes = map[string]string{
+	localModels.ReportFormatCSV:  "text/csv; charset=utf-8",
+	localModels.ReportFormatJSON: "application/json",
+}
+
+// Compliance is what a client's compliance report shows of a period
+type Compliance struct {
+	ReportID      string        `json:"report_id"`
+	From          time.Time     `json:"from"`
+	To            time.Time     `json:"to"` // Exclusive
+	GeneratedAt   time.Time     `json:"generated_at"`
+	Verifications Verifications `json:"verifications"`
+	Outcomes      Outcomes      `json:"outcomes"`
+	SLA           SLA           `json:"sla"`
+	Erasures      Erasures      `json:"erasures"`
+}
+
+// Verifications counts the verification work started and finished in the period
+type Verifications struct {
+	ApplicantsCreated int64 `json:"applicants_created"`
+	DocumentsUploaded int64 `json:"documents_uploaded"`
+	DecisionsApplied  int64 `json:"decisions_applied"`
+}
+
+// Outcomes counts the decisions applied in the period by outcome and reason code
+type Outcomes struct {
+	Approved int64            `json:"approved"`
+	Rejected int64            `json:"rejected"`
+	Reasons  map[string]int64 `json:"reasons"`
+}
+
+// SLA describes how long the applicants decided in the period took, from creation to decision
+type SLA struct {
+	TargetSeconds  int64   `json:"target_seconds"`
+	Decided        int64   `json:"decided"`
+	WithinTarget   int64   `json:"within_target"`
+	AverageSeconds float64 `json:"average_seconds"`
+	MaxSeconds     float64 `json:"max_seconds"`
+}
+
+// Erasures lists the applicants and documents deleted in the period
+type Erasures struct {
+	Applicants int64     `json:"applicants"`
+	Documents  int64     `json:"documents"`
+	Records    []Erasure `json:"records"`
+	// Truncated is set when there were more erasures than a report lists; the counts stay complete
+	Truncated bool `json:"truncated"`
+}
+
+// Erasure is one applicant or document deleted in the period
+type Erasure struct {
+	Type     string    `json:"type" bson:"type"` // applicant or document
+	ID       string    `json:"id" bson:"id"`
+	ErasedAt time.Time `json:"erased_at" bson:"erased_at"`
+}
+
+// WriteCompliance writes a compliance report in a format. CSV reports have a row for
+// each figure, of its section, metric, subject and value, followed by a row for each
+// erasure.
+func WriteCompliance(format string, compliance Compliance) ([]byte, error) {
+	switch format {
+	case localModels.ReportFormatJSON:
+		if compliance.Outcomes.Reasons == nil {
+			compliance.Outcomes.Reasons = map[string]int64{}
+		}
+		if compliance.Erasures.Records == nil {
+			compliance.Erasures.Records = []Erasure{}
+		}
+		return json.MarshalIndent(compliance, "", "  ")
+	case localModels.ReportFormatCSV:
+		return complianceCSV(compliance)
+	}
+	return nil, fmt.Errorf("unknown report format %q", format)
+}
+
+func complianceCSV(compliance Compliance) ([]byte, error) {
+	count := func(n int64) string { return strconv.FormatInt(n, 10) }
+	seconds := func(s float64) string { return strconv.FormatFloat(s, 'f', 0, 64) }
+
+	rows := [][]string{
+		{"section", "metric", "subject", "value"},
+		{"report", "report_id", "", compliance.ReportID},
+		{"report", "from", "", formatRFC3339(compliance.From)},
+		{"report", "to", "", formatRFC3339(compliance.To)},
+		{"report", "generated_at", "", formatRFC3339(compliance.GeneratedAt)},
+		{"verifications", "applicants_created", "", count(compliance.Verifications.ApplicantsCreated)},
+		{"verifications", "documents_uploaded", "", count(compliance.Verifications.DocumentsUploaded)},
+		{"verifications", "decisions_applied", "", count(compliance.Verifications.DecisionsApplied)},
+		{"outcomes", "approved", "", count(compliance.Outcomes.Approved)},
+		{"outcomes", "rejected", "", count(compliance.Outcomes.Rejected)},
+	}
+	reasons := make([]string, 0, len(compliance.Outcomes.Reasons))
+	for reason := range compliance.Outcomes.Reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		rows = append(rows, []string{"outcomes", "reason", reason, count(compliance.Outcomes.Reasons[reason])})
+	}
+	rows = append(rows,
+		[]string{"sla", "target_seconds", "", count(compliance.SLA.TargetSeconds)},
+		[]string{"sla", "decided", "", count(compliance.SLA.Decided)},
+		[]string{"sla", "within_target", "", count(compliance.SLA.WithinTarget)},
+		[]string{"sla", "average_seconds", "", seconds(compliance.SLA.AverageSeconds)},
+		[]string{"sla", "max_seconds", "", seconds(compliance.SLA.MaxSeconds)},
+		[]string{"erasures", "applicants", "", count(compliance.Erasures.Applicants)},
+		[]string{"erasures", "documents", "", count(compliance.Erasures.Documents)},
+		[]string{"erasures", "truncated", "", strconv.FormatBool(compliance.Erasures.Truncated)},
+	)
+	for _, erasure := range compliance.Erasures.Records {
+		rows = append(rows, []string{"erasure", erasure.Type, erasure.ID, formatRFC3339(erasure.ErasedAt)})
+	}
+
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("failed to write report: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func formatRFC3339(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCompliance() Compliance {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return Compliance{
+		ReportID:      "report-1",
+		From:          from,
+		To:            from.AddDate(0, 3, 0),
+		GeneratedAt:   time.Date(2026, 4, 2, 8, 0, 0, 0, time.UTC),
+		Verifications: Verifications{ApplicantsCreated: 40, DocumentsUploaded: 75, DecisionsApplied: 31},
+		Outcomes:      Outcomes{Approved: 28, Rejected: 3, Reasons: map[string]int64{"checks_passed": 28, "document_expired": 2, "fraud_suspected": 1}},
+		SLA:           SLA{TargetSeconds: 86400, Decided: 31, WithinTarget: 29, AverageSeconds: 7200.4, MaxSeconds: 259200},
+		Erasures: Erasures{Applicants: 1, Documents: 1, Records: []Erasure{
+			{Type: "applicant", ID: "app-1", ErasedAt: from.Add(time.Hour)},
+			{Type: "document", ID: "doc-1", ErasedAt: from.Add(2 * time.Hour)},
+		}},
+	}
+}
+
+func TestWriteComplianceCSV(t *testing.T) {
+	content, err := WriteCompliance(localModels.ReportFormatCSV, testCompliance())
+	require.NoError(t, err)
+
+	rows, err := csv.NewReader(strings.NewReader(string(content))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"section", "metric", "subject", "value"}, rows[0])
+	assert.Contains(t, rows, []string{"report", "to", "", "2026-04-01T00:00:00Z"})
+	assert.Contains(t, rows, []string{"verifications", "decisions_applied", "", "31"})
+	assert.Contains(t, rows, []string{"sla", "average_seconds", "", "7200"})
+	assert.Contains(t, rows, []string{"erasures", "truncated", "", "false"})
+
+	// Reasons are listed in order, and erasures follow the figures
+	var reasons []string
+	for _, row := range rows {
+		if row[1] == "reason" {
+			reasons = append(reasons, row[2])
+		}
+	}
+	assert.Equal(t, []string{"checks_passed", "document_expired", "fraud_suspected"}, reasons)
+	assert.Equal(t, []string{"erasure", "applicant", "app-1", "2026-01-01T01:00:00Z"}, rows[len(rows)-2])
+	assert.Equal(t, []string{"erasure", "document", "doc-1", "2026-01-01T02:00:00Z"}, rows[len(rows)-1])
+}
+
+func TestWriteComplianceJSON(t *testing.T) {
+	content, err := WriteCompliance(localModels.ReportFormatJSON, testCompliance())
+	require.NoError(t, err)
+	var decoded Compliance
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, testCompliance(), decoded)
+
+	// Periods without decisions or erasures still list them, empty
+	content, err = WriteCompliance(localModels.ReportFormatJSON, Compliance{ReportID: "report-2"})
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"reasons": {}`)
+	assert.Contains(t, string(content), `"records": []`)
+
+	_, err = WriteCompliance("xml", testCompliance())
+	assert.Error(t, err)
+}
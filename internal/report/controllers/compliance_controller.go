@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+// dateLayout is the layout of a period's bounds given as whole days
+const dateLayout = "2006-01-02"
+
+// RequestComplianceReport is the handler function for queueing a compliance report of the
+// client's records over a period. The period's bounds are RFC 3339 times, or dates for
+// whole days, the to date included. The report is answered with 202 and a Location to
+// download it at once it is ready, and the client is sent a report.completed webhook.
+func RequestComplianceReport(c *gin.Context, service interfaces.ComplianceReportService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var requestBody struct {
+		Format string `json:"format"`
+		From   string `json:"from" binding:"required"`
+		To     string `json:"to" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required"})
+		return
+	}
+	if requestBody.Format == "" {
+		requestBody.Format = localModels.ReportFormatJSON
+	}
+	from, _, err := parseBound(requestBody.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date or an RFC 3339 time"})
+		return
+	}
+	to, wholeDay, err := parseBound(requestBody.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date or an RFC 3339 time"})
+		return
+	}
+	if wholeDay {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	result, err := service.RequestComplianceReport(c.Request.Context(), clientID, requestBody.Format, from, to)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		log.Printf("RequestComplianceReport: Error queueing report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate report"})
+		return
+	}
+	c.Header("Location", c.Request.URL.Path+"/"+result.ReportID)
+	c.Header("Retry-After", retryAfter)
+	c.JSON(http.StatusAccepted, result)
+}
+
+// GetComplianceReport is the handler function for a compliance report of the client. It
+// answers 202 while the report is generated, and the CSV or JSON file once it is ready.
+func GetComplianceReport(c *gin.Context, service interfaces.ComplianceReportService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := service.GetComplianceReport(c.Request.Context(), clientID, c.Param("id"))
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		log.Printf("GetComplianceReport: Error looking up report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate report"})
+		return
+	}
+	switch result.Status {
+	case localModels.ReportPending:
+		c.Header("Retry-After", retryAfter)
+		c.JSON(http.StatusAccepted, result)
+	case localModels.ReportFailed:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error, "code": "report_failed", "report_id": result.ReportID})
+	default:
+		c.Header("Cache-Control", "private, no-store")
+		c.Header("Content-Disposition", `attachment; filename="compliance-report-`+result.ReportID+`.`+result.Format+`"`)
+		c.Data(http.StatusOK, report.ComplianceContentTypes[result.Format], result.Content)
+	}
+}
+
+// parseBound reads a bound of a report's period, reporting whether it was a date
+func parseBound(value string) (time.Time, bool, error) {
+	if day, err := time.Parse(dateLayout, value); err == nil {
+		return day, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupComplianceRouter(mockService *localMocks.MockComplianceReportService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	})
+	router.POST("/reports", func(c *gin.Context) {
+		RequestComplianceReport(c, mockService)
+	})
+	router.GET("/reports/:id", func(c *gin.Context) {
+		GetComplianceReport(c, mockService)
+	})
+	return router
+}
+
+func TestRequestComplianceReport(t *testing.T) {
+	jan1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pending := localModels.ComplianceReport{ReportID: "report1", Format: "csv", Status: localModels.ReportPending}
+
+	tests := []struct {
+		name               string
+		body               string
+		format             string
+		from, to           time.Time
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Whole days", body: `{"format":"csv","from":"2026-01-01","to":"2026-03-31"}`, format: "csv", from: jan1, to: jan1.AddDate(0, 3, 0), expectedStatusCode: http.StatusAccepted},
+		{name: "Times", body: `{"format":"csv","from":"2026-01-01T00:00:00Z","to":"2026-01-01T12:00:00+02:00"}`, format: "csv", from: jan1, to: jan1.Add(10 * time.Hour), expectedStatusCode: http.StatusAccepted},
+		{name: "JSON by default", body: `{"from":"2026-01-01","to":"2026-01-01"}`, format: "json", from: jan1, to: jan1.AddDate(0, 0, 1), expectedStatusCode: http.StatusAccepted},
+		{name: "Missing period", body: `{"format":"csv"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Unreadable bound", body: `{"from":"01/01/2026","to":"2026-03-31"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Unknown format", body: `{"format":"xml","from":"2026-01-01","to":"2026-01-01"}`, format: "xml", from: jan1, to: jan1.AddDate(0, 0, 1), serviceErr: services.ErrInvalidFormat, expectedStatusCode: http.StatusBadRequest},
+		{name: "Period too long", body: `{"format":"csv","from":"2026-01-01","to":"2026-01-01"}`, format: "csv", from: jan1, to: jan1.AddDate(0, 0, 1), serviceErr: fmt.Errorf("%w: too long", services.ErrInvalidPeriod), expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockComplianceReportService)
+			mockService.On("RequestComplianceReport", mock.Anything, "client1", tt.format, mock.MatchedBy(tt.from.Equal), mock.MatchedBy(tt.to.Equal)).Return(pending, tt.serviceErr)
+			router := setupComplianceRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/reports", strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusAccepted {
+				assert.Equal(t, "/reports/report1", w.Header().Get("Location"))
+				assert.Contains(t, w.Body.String(), `"status":"pending"`)
+			} else if tt.format == "" {
+				mockService.AssertNotCalled(t, "RequestComplianceReport", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGetComplianceReport(t *testing.T) {
+	tests := []struct {
+		name               string
+		result             localModels.ComplianceReport
+		serviceErr         error
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{name: "Ready", result: localModels.ComplianceReport{ReportID: "report1", Format: "csv", Status: localModels.ReportReady, Content: []byte("section,metric,subject,value\n")}, expectedStatusCode: http.StatusOK},
+		{name: "Pending", result: localModels.ComplianceReport{ReportID: "report1", Status: localModels.ReportPending}, expectedStatusCode: http.StatusAccepted},
+		{name: "Failed", result: localModels.ComplianceReport{ReportID: "report1", Status: localModels.ReportFailed, Error: "report could not be generated"}, expectedStatusCode: http.StatusInternalServerError, expectedCode: "report_failed"},
+		{name: "Unknown", serviceErr: services.ErrReportNotFound, expectedStatusCode: http.StatusNotFound, expectedCode: "report_not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockComplianceReportService)
+			mockService.On("GetComplianceReport", mock.Anything, "client1", "report1").Return(tt.result, tt.serviceErr)
+			router := setupComplianceRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/reports/report1", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tt.expectedCode+`"`)
+			}
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Header().Get("Content-Disposition"), "compliance-report-report1.csv")
+				assert.Equal(t, "section,metric,subject,value\n", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	auditControllers "github.com/rachel-lawrie/verus_app_backend/internal/audit/controllers"
+	documentServices "github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report/services"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+// retryAfter is how long clients are asked to wait, in seconds, before asking for a queued report again
+const retryAfter = "5"
+
+// GetApplicantReport is the handler function for the PDF verification report of an
+// applicant. Reports show the applicant's documents, so like downloads they must give a
+// reason. Large reports are answered with 202 and a Location to ask for them at again,
+// with the report_id they were queued as, until they are ready.
+func GetApplicantReport(c *gin.Context, service interfaces.ReportService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	reason, err := documentServices.DownloadReason(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	purpose, err := documentServices.DownloadPurpose(c, services.PurposeReport)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	applicantID := c.Param("id")
+	var result localModels.ApplicantReport
+	if reportID := c.Query("report_id"); reportID != "" {
+		result, err = service.GetReport(c.Request.Context(), clientID, applicantID, reportID)
+	} else {
+		result, err = service.RequestReport(c.Request.Context(), clientID, applicantID, purpose)
+	}
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		log.Printf("GetApplicantReport: Error generating report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate report"})
+		return
+	}
+
+	switch result.Status {
+	case localModels.ReportPending:
+		query := url.Values{"report_id": {result.ReportID}, "reason": {reason}}
+		c.Header("Location", c.Request.URL.Path+"?"+query.Encode())
+		c.Header("Retry-After", retryAfter)
+		c.JSON(http.StatusAccepted, result)
+	case localModels.ReportFailed:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error, "code": "report_failed", "report_id": result.ReportID})
+	default:
+		auditControllers.NoteDocumentAccess(c, reason, result.DocumentIDs...)
+		c.Header("Cache-Control", "private, no-store")
+		c.Header("Content-Disposition", `attachment; filename="verification-report-`+result.ApplicantID+`.pdf"`)
+		c.Data(http.StatusOK, report.ContentType, result.Content)
+	}
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupReportRouter(mockService *localMocks.MockReportService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	})
+	router.GET("/applicants/:id/report.pdf", func(c *gin.Context) {
+		GetApplicantReport(c, mockService)
+	})
+	return router
+}
+
+func TestGetApplicantReport(t *testing.T) {
+	ready := localModels.ApplicantReport{ReportID: "report1", ApplicantID: "app1", Status: localModels.ReportReady, Content: []byte("%PDF-1.4"), DocumentIDs: []string{"doc1"}}
+	pending := localModels.ApplicantReport{ReportID: "report1", ApplicantID: "app1", Status: localModels.ReportPending}
+	failed := localModels.ApplicantReport{ReportID: "report1", ApplicantID: "app1", Status: localModels.ReportFailed, Error: "applicant not found"}
+
+	tests := []struct {
+		name               string
+		query              string
+		requested          localModels.ApplicantReport
+		fetched            localModels.ApplicantReport
+		serviceErr         error
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{name: "Generated during the request", query: "reason=audit", requested: ready, expectedStatusCode: http.StatusOK},
+		{name: "Queued", query: "reason=audit", requested: pending, expectedStatusCode: http.StatusAccepted},
+		{name: "Fetched once ready", query: "reason=audit&report_id=report1", fetched: ready, expectedStatusCode: http.StatusOK},
+		{name: "Still queued", query: "reason=audit&report_id=report1", fetched: pending, expectedStatusCode: http.StatusAccepted},
+		{name: "Failed", query: "reason=audit&report_id=report1", fetched: failed, expectedStatusCode: http.StatusInternalServerError, expectedCode: "report_failed"},
+		{name: "Missing reason", query: "", expectedStatusCode: http.StatusBadRequest},
+		{name: "Unknown applicant", query: "reason=audit", serviceErr: apperr.ErrApplicantNotFound, expectedStatusCode: http.StatusNotFound, expectedCode: "applicant_not_found"},
+		{name: "Unknown report", query: "reason=audit&report_id=report1", serviceErr: services.ErrReportNotFound, expectedStatusCode: http.StatusNotFound, expectedCode: "report_not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockReportService)
+			mockService.On("RequestReport", mock.Anything, "client1", "app1", services.PurposeReport).Return(tt.requested, tt.serviceErr)
+			mockService.On("GetReport", mock.Anything, "client1", "app1", "report1").Return(tt.fetched, tt.serviceErr)
+			router := setupReportRouter(mockService)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/applicants/app1/report.pdf?"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tt.expectedCode+`"`)
+			}
+			switch tt.expectedStatusCode {
+			case http.StatusOK:
+				assert.Equal(t, report.ContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, "%PDF-1.4", w.Body.String())
+				assert.Contains(t, w.Header().Get("Content-Disposition"), "verification-report-app1.pdf")
+			case http.StatusAccepted:
+				location, err := url.Parse(w.Header().Get("Location"))
+				assert.NoError(t, err)
+				assert.Equal(t, "/applicants/app1/report.pdf", location.Path)
+				assert.Equal(t, "report1", location.Query().Get("report_id"))
+				assert.Equal(t, "audit", location.Query().Get("reason"))
+				assert.Contains(t, w.Body.String(), `"status":"pending"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"time"
+)
+
+// A4 pages, measured in points
+const (
+	pageWidth   = 595.0
+	pageHeight  = 842.0
+	margin      = 50.0
+	footerSpace = 30.0 // Kept free above the bottom margin for the page footer
+	lineSpacing = 1.4  // Line height as a multiple of the font size
+)
+
+// Fonts are the standard Helvetica faces every PDF reader has, so none are embedded
+const (
+	regular = "F1"
+	bold    = "F2"
+)
+
+// helveticaWidths are the widths of the printable ASCII characters in Helvetica, in
+// thousandths of the font size, from its Adobe font metrics
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// winAnsi maps the punctuation people paste into names and comments to its WinAnsi code.
+// Latin-1 letters have the same codes in both.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+// pdfImage is a JPEG drawn on a page
+type pdfImage struct {
+	data          []byte
+	width, height int
+	colorSpace    string
+}
+
+// document lays out text and images on A4 pages, top to bottom, and writes them as a PDF
+type document struct {
+	pages  []*bytes.Buffer // Content stream of each page
+	images []pdfImage
+	shown  [][]int // Images drawn on each page
+	y      float64 // Baseline of the last line drawn on the current page
+}
+
+func (d *document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.shown = append(d.shown, nil)
+	d.y = pageHeight - margin
+}
+
+// space makes room for height points below the last line, starting a new page when the
+// current one is full
+func (d *document) space(height float64) {
+	if len(d.pages) == 0 || d.y-height < margin+footerSpace {
+		d.newPage()
+	}
+}
+
+// line draws a line of text with its baseline at y
+func (d *document) line(font string, size, x, y float64, s string) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %g Tf %.2f %.2f Td %s Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// gap leaves an empty band of height points, unless a page has just started
+func (d *document) gap(height float64) {
+	if len(d.pages) > 0 && d.y > margin && d.y < pageHeight-margin {
+		d.y -= height
+	}
+}
+
+// text draws s in lines wrapped to the page, indented by indent points
+func (d *document) text(font string, size, indent float64, s string) {
+	lineHeight := size * lineSpacing
+	for _, l := range wrap(s, size, pageWidth-2*margin-indent) {
+		d.space(lineHeight)
+		d.y -= lineHeight
+		d.line(font, size, margin+indent, d.y, l)
+	}
+}
+
+// field draws a bold label with its value wrapped beside it
+func (d *document) field(label, value string, size float64) {
+	const labelWidth = 130.0
+	lineHeight := size * lineSpacing
+	for i, l := range wrap(value, size, pageWidth-2*margin-labelWidth) {
+		d.space(lineHeight)
+		d.y -= lineHeight
+		if i == 0 {
+			d.line(bold, size, margin, d.y, label)
+		}
+		d.line(regular, size, margin+labelWidth, d.y, l)
+	}
+}
+
+// image draws a JPEG indented by indent points, scaled to fit a square of box points
+func (d *document) image(data []byte, box, indent float64) error {
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to read thumbnail: %v", err)
+	}
+	colorSpace := "DeviceRGB"
+	switch config.ColorModel {
+	case color.GrayModel:
+		colorSpace = "DeviceGray"
+	case color.CMYKModel:
+		return fmt.Errorf("failed to read thumbnail: CMYK images are not drawn")
+	}
+	width, height := float64(config.Width), float64(config.Height)
+	scale := box / max(width, height)
+	width, height = width*scale, height*scale
+
+	d.space(height + 6)
+	d.y -= height + 6
+	d.images = append(d.images, pdfImage{data: data, width: config.Width, height: config.Height, colorSpace: colorSpace})
+	index := len(d.images)
+	d.shown[len(d.shown)-1] = append(d.shown[len(d.shown)-1], index)
+	fmt.Fprintf(d.pages[len(d.pages)-1], "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, margin+indent, d.y, index)
+	return nil
+}
+
+// bytes writes the pages as a PDF with footer on each, followed by its page number
+func (d *document) bytes(title, footer string, created time.Time) []byte {
+	if len(d.pages) == 0 {
+		d.newPage()
+	}
+	for i, page := range d.pages {
+		fmt.Fprintf(page, "BT /%s 8 Tf %.2f %.2f Td %s Tj ET\n", regular, margin, margin, pdfString(fmt.Sprintf("%s - page %d of %d", footer, i+1, len(d.pages))))
+	}
+
+	// Objects 1 to 5 are fixed, followed by the images and then each page and its contents
+	firstImage := 6
+	firstPage := firstImage + len(d.images)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	objects := [][]byte{
+		[]byte("<< /Type /Catalog /Pages 2 0 R >>"),
+		[]byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))),
+		[]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"),
+		[]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"),
+		[]byte(fmt.Sprintf("<< /Title %s /Producer (Verus) /CreationDate (D:%s) >>", pdfString(title), created.UTC().Format("20060102150405Z"))),
+	}
+	for _, img := range d.images {
+		header := fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode", img.width, img.height, img.colorSpace)
+		objects = append(objects, stream(header, img.data))
+	}
+	for i, page := range d.pages {
+		var xobjects strings.Builder
+		for _, index := range d.shown[i] {
+			fmt.Fprintf(&xobjects, " /Im%d %d 0 R", index, firstImage+index-1)
+		}
+		objects = append(objects,
+			[]byte(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> /XObject <<%s >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, regular, bold, xobjects.String(), firstPage+2*i+1)),
+			stream("<<", page.Bytes()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// stream returns a stream object whose dictionary starts with header and is closed here
+func stream(header string, data []byte) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s /Length %d >>\nstream\n", header, len(data))
+	out.Write(data)
+	out.WriteString("\nendstream")
+	return out.Bytes()
+}
+
+// pdfString encodes s as a PDF string in WinAnsi. Characters it has no code for are
+// written as question marks.
+func pdfString(s string) string {
+	var out strings.Builder
+	out.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r < ' ':
+			out.WriteByte(' ')
+		case r <= '~':
+			out.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&out, "\\%03o", r)
+		case winAnsi[r] != 0:
+			fmt.Fprintf(&out, "\\%03o", winAnsi[r])
+		default:
+			out.WriteByte('?')
+		}
+	}
+	out.WriteByte(')')
+	return out.String()
+}
+
+// textWidth measures s in points when set in Helvetica at size
+func textWidth(s string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		if r >= ' ' && r <= '~' {
+			total += helveticaWidths[r-' ']
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// wrap breaks s into lines no wider than width, between words where it can. Bold text
+// runs a little wider than measured, so it is only used for short labels and headings.
+func wrap(s string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		current := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if current != "" {
+				candidate = current + " " + word
+			}
+			if textWidth(candidate, size) <= width {
+				current = candidate
+				continue
+			}
+			if current != "" {
+				lines = append(lines, current)
+			}
+			// Words longer than a line, such as IDs and URLs, are broken anywhere
+			current = ""
+			for _, r := range word {
+				if current != "" && textWidth(current+string(r), size) > width {
+					lines = append(lines, current)
+					current = ""
+				}
+				current += string(r)
+			}
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
@@ -0,0 +1,191 @@
+// Package report renders the verification report of an applicant as a PDF, for clients to
+// file with their compliance records: the applicant's details, each document with a
+// thumbnail, what the verification provider found, the risk signals raised by screening,
+// and the trail of review decisions.
+//
+// The PDF is written directly, with the standard Helvetica fonts and the thumbnails as
+// JPEG images, so reports need no renderer installed alongside the service.
+//
+// It also writes clients' compliance reports of a period as CSV or JSON, for filing with
+// their regulator.
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// ContentType is the type of every report
+const ContentType = "application/pdf"
+
+// ThumbnailSize is the longest side of a document's thumbnail, in pixels. Thumbnails are
+// drawn at up to half their size so they stay sharp when printed.
+const ThumbnailSize = 320
+
+const (
+	titleSize   = 18
+	headingSize = 13
+	bodySize    = 9.5
+	timeLayout  = "2006-01-02 15:04 UTC"
+)
+
+// Summary is what a report shows of an applicant
+type Summary struct {
+	Applicant   localModels.ApplicantRecord
+	Status      localModels.ApplicantStatus
+	Review      localModels.Review
+	Documents   []Document
+	Syncs       []localModels.SumsubSync // Newest first
+	Decisions   []localModels.Decision   // Oldest first
+	GeneratedAt time.Time
+}
+
+// Document is a document shown in a report, with its thumbnail when one could be drawn
+type Document struct {
+	Record    localModels.DocumentRecord
+	Thumbnail []byte // JPEG
+	Missing   string // Why the document has no thumbnail
+}
+
+// Render writes a summary as a PDF and returns it with its number of pages
+func Render(summary Summary) ([]byte, int, error) {
+	applicant := summary.Applicant
+	d := &document{}
+	d.text(bold, titleSize, 0, "Verification report")
+	d.text(regular, bodySize, 0, "Applicant "+applicant.ApplicantID+", generated "+formatTime(summary.GeneratedAt))
+
+	heading(d, "Applicant")
+	name := strings.Join(strings.Fields(strings.Join([]string{applicant.FirstName, applicant.MiddleName, applicant.LastName}, " ")), " ")
+	d.field("Name", orNone(name), bodySize)
+	d.field("Email", orNone(applicant.Email), bodySize)
+	d.field("Phone", orNone(applicant.Phone), bodySize)
+	d.field("Verification level", orNone(applicant.VerificationLevel), bodySize)
+	d.field("Status", orNone(string(summary.Status)), bodySize)
+	if summary.Review.DecidedAt != nil {
+		d.field("Decided", formatTime(*summary.Review.DecidedAt)+" by "+deref(summary.Review.DecidedBy)+reasonSuffix(summary.Review.ReasonCode), bodySize)
+	}
+	d.field("Created", formatTime(applicant.CreatedAt), bodySize)
+	if consent := applicant.Consent; consent != nil {
+		d.field("Consent", fmt.Sprintf("Text %s, given %s through %s", consent.TextVersion, formatTime(consent.GivenAt), consent.Channel), bodySize)
+	}
+	if provider := applicant.VerificationProvider; provider != nil {
+		d.field("Provider", provider.Name+" ("+provider.Route+"), chosen "+formatTime(provider.SelectedAt), bodySize)
+	}
+
+	heading(d, "Documents")
+	if len(summary.Documents) == 0 {
+		d.text(regular, bodySize, 0, "The applicant has no documents.")
+	}
+	for i, doc := range summary.Documents {
+		if i > 0 {
+			d.gap(8)
+		}
+		record := doc.Record
+		d.text(bold, bodySize+1, 0, fmt.Sprintf("%s, %s", record.DocumentType.String(), orNone(record.Country)))
+		d.field("Document ID", record.DocumentID, bodySize)
+		d.field("Status", record.Status.String(), bodySize)
+		d.field("Uploaded", formatTime(record.CreatedAt), bodySize)
+		if record.Vendor != "" {
+			d.field("Vendor", record.Vendor, bodySize)
+		}
+		if check := record.CountryCheck; check != nil {
+			d.field("Issuing country", fmt.Sprintf("%s: claimed %s, detected %s", check.Status, orNone(check.Claimed), orNone(check.Detected)), bodySize)
+		}
+		for _, flag := range record.Flags {
+			d.field("Flag", flag.Code+": "+flag.Message, bodySize)
+		}
+		if doc.Thumbnail == nil {
+			d.field("Image", "Not shown: "+orNone(doc.Missing), bodySize)
+			continue
+		}
+		if err := d.image(doc.Thumbnail, ThumbnailSize/2, 0); err != nil {
+			return nil, 0, fmt.Errorf("document %s: %w", record.DocumentID, err)
+		}
+	}
+
+	heading(d, "Provider results")
+	if len(summary.Syncs) == 0 {
+		d.text(regular, bodySize, 0, "No results have been received from a verification provider.")
+	}
+	for _, sync := range summary.Syncs {
+		d.text(bold, bodySize, 0, fmt.Sprintf("Sumsub review %s %s, read %s", sync.ReviewStatus, sync.ReviewAnswer, formatTime(sync.SyncedAt)))
+		for _, inspection := range sync.Inspections {
+			line := strings.TrimSpace(inspection.DocSet + " " + inspection.DocType + ": " + orNone(inspection.Answer))
+			if len(inspection.RejectLabels) > 0 {
+				line += " (" + strings.Join(inspection.RejectLabels, ", ") + ")"
+			}
+			d.text(regular, bodySize, 12, line)
+		}
+	}
+
+	heading(d, "Screening outcomes")
+	if len(applicant.RiskSignals) == 0 {
+		d.text(regular, bodySize, 0, "No risk signals were raised.")
+	}
+	for _, signal := range applicant.RiskSignals {
+		line := fmt.Sprintf("[%s] %s from %s, %s", signal.Severity, signal.Code, signal.Source, formatTime(signal.CreatedAt))
+		if signal.DocumentID != "" {
+			line += ", document " + signal.DocumentID
+		}
+		d.text(regular, bodySize, 0, line)
+		if signal.Detail != "" {
+			d.text(regular, bodySize, 12, signal.Detail)
+		}
+	}
+
+	heading(d, "Decision trail")
+	if len(summary.Decisions) == 0 {
+		d.text(regular, bodySize, 0, "No review decisions have been made.")
+	}
+	for _, decision := range summary.Decisions {
+		d.text(bold, bodySize, 0, fmt.Sprintf("%s%s, %s", decision.Decision, reasonSuffix(decision.ReasonCode), decision.Status))
+		for _, entry := range decision.AuditTrail {
+			line := fmt.Sprintf("%s %s by %s", formatTime(entry.At), entry.Action, entry.Actor)
+			if entry.Comment != "" {
+				line += ": " + entry.Comment
+			}
+			d.text(regular, bodySize, 12, line)
+		}
+	}
+
+	content := d.bytes("Verification report "+applicant.ApplicantID, "Verification report for applicant "+applicant.ApplicantID, summary.GeneratedAt)
+	return content, len(d.pages), nil
+}
+
+// heading starts a section, keeping it off the bottom of a page
+func heading(d *document, title string) {
+	d.gap(10)
+	d.space(headingSize*lineSpacing + 3*bodySize*lineSpacing)
+	d.text(bold, headingSize, 0, title)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "unknown"
+	}
+	return *s
+}
+
+func reasonSuffix(code string) string {
+	if code == "" {
+		return ""
+	}
+	return " (" + code + ")"
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/watermark"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testThumbnail(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 180
+	}
+	var out bytes.Buffer
+	require.NoError(t, jpeg.Encode(&out, img, nil))
+	return out.Bytes()
+}
+
+func testSummary() Summary {
+	at := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+	applicant := localModels.ApplicantRecord{
+		RiskSignals: []localModels.RiskSignal{{Code: "ip_country_mismatch", Severity: localModels.RiskMedium, Source: "device_check", Detail: "IP in FR, address in GB", CreatedAt: at}},
+	}
+	applicant.ApplicantID, applicant.FirstName, applicant.LastName = "app-1", "Zoë", "O'Brien (Jr)"
+	return Summary{
+		Applicant: applicant,
+		Status:    localModels.ApplicantApproved,
+		Syncs: []localModels.SumsubSync{{ReviewStatus: "completed", ReviewAnswer: "GREEN", SyncedAt: at,
+			Inspections: []localModels.SumsubInspection{{DocSet: "IDENTITY", DocType: "PASSPORT", Answer: "GREEN"}}}},
+		Decisions: []localModels.Decision{{Decision: localModels.DecisionApprove, ReasonCode: "documents_verified", Status: localModels.DecisionApplied,
+			AuditTrail: []localModels.DecisionAuditEntry{{Action: localModels.DecisionActionProposed, Actor: "reviewer-1", At: at, Comment: "All good"}}}},
+		GeneratedAt: at,
+	}
+}
+
+func TestRender(t *testing.T) {
+	summary := testSummary()
+	summary.Documents = []Document{
+		{Record: localModels.DocumentRecord{Document: coreModels.Document{DocumentID: "doc-1"}}, Thumbnail: testThumbnail(t, 240, 320)},
+		{Record: localModels.DocumentRecord{Document: coreModels.Document{DocumentID: "doc-2"}}, Missing: "the file is in archival storage"},
+	}
+
+	content, pages, err := Render(summary)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pages)
+	assert.True(t, bytes.HasPrefix(content, []byte("%PDF-1.4")))
+	for _, text := range []string{
+		"(Verification report)", `(Zo\353 O'Brien \(Jr\))`, "(approved)", "(Not shown: the file is in archival storage)",
+		"(IDENTITY PASSPORT: GREEN)", "([medium] ip_country_mismatch from device_check, 2026-03-02 09:30 UTC)",
+		"(2026-03-02 09:30 UTC proposed by reviewer-1: All good)", "(Verification report for applicant app-1 - page 1 of 1)",
+	} {
+		assert.Contains(t, string(content), text)
+	}
+
+	// The thumbnail is the image on the first page, as a PDF reader finds it
+	img, err := watermark.PageImage(content)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 240, 320), img.Bounds())
+}
+
+func TestRenderPaginates(t *testing.T) {
+	summary := testSummary()
+	thumbnail := testThumbnail(t, 320, 200)
+	for i := 0; i < 12; i++ {
+		summary.Documents = append(summary.Documents, Document{Thumbnail: thumbnail})
+	}
+
+	content, pages, err := Render(summary)
+	require.NoError(t, err)
+	assert.Greater(t, pages, 3)
+	assert.Contains(t, string(content), fmt.Sprintf("/Count %d", pages))
+	assert.Equal(t, 12, bytes.Count(content, []byte("/Subtype /Image")))
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap("the quick brown fox jumps over the lazy dog", 10, 100)
+	assert.Greater(t, len(lines), 1)
+	for _, line := range lines {
+		assert.LessOrEqual(t, textWidth(line, 10), 100.0)
+	}
+	assert.Equal(t, "the quick brown fox jumps over the lazy dog", strings.Join(lines, " "))
+
+	// Words longer than a line are broken
+	lines = wrap(strings.Repeat("x", 60), 10, 100)
+	assert.Equal(t, strings.Repeat("x", 60), strings.Join(lines, ""))
+	assert.Greater(t, len(lines), 1)
+
+	assert.Equal(t, []string{"a", "", "b"}, wrap("a\n\nb", 10, 100))
+}
+
+func TestPDFString(t *testing.T) {
+	assert.Equal(t, `(a\(b\)c\\)`, pdfString(`a(b)c\`))
+	assert.Equal(t, `(caf\351 \226 ?)`, pdfString("café – 日"))
+	assert.Equal(t, "(a b)", pdfString("a\tb"))
+}
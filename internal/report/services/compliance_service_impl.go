@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/replicareads"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidPeriod is returned for a compliance report whose period is empty or too long
+	ErrInvalidPeriod = apperr.BadRequest("invalid_report_period", "invalid report period")
+	// ErrInvalidFormat is returned for a compliance report in a format that is not written
+	ErrInvalidFormat = apperr.BadRequest("invalid_report_format", "format must be csv or json")
+)
+
+const (
+	defaultMaxRange            = 366 * 24 * time.Hour
+	defaultDecisionTarget      = 24 * time.Hour
+	defaultMaxErasures         = 50000
+	defaultComplianceRetention = 7 * 24 * time.Hour
+)
+
+// ComplianceServiceImpl generates the compliance reports clients file with their regulator.
+// Reports cover whole periods of a client's records, so they are always queued in the
+// compliance_reports collection for the compliance_report job, and the client is sent a
+// report.completed webhook once each is ready.
+type ComplianceServiceImpl struct {
+	CollectionName string
+	Settings       config.ComplianceReportSettings
+	Webhooks       localInterfaces.WebhookService // Sends report.completed; nil leaves clients to poll
+	Trigger        func(ctx context.Context)      // Starts the compliance_report job once a report is queued; nil waits for its schedule
+	BatchSize      int64
+}
+
+var (
+	complianceInstance ComplianceServiceImpl
+	complianceOnce     sync.Once
+)
+
+func GetComplianceServiceImpl() ComplianceServiceImpl {
+	complianceOnce.Do(func() {
+		complianceInstance = ComplianceServiceImpl{
+			CollectionName: localConstants.CollectionComplianceReports,
+		}
+	})
+	return complianceInstance
+}
+
+// RequestComplianceReport queues a report of the client's records from from up to to, in
+// the format given
+func (s *ComplianceServiceImpl) RequestComplianceReport(ctx context.Context, clientID, format string, from, to time.Time) (localModels.ComplianceReport, error) {
+	if _, ok := report.ComplianceContentTypes[format]; !ok {
+		return localModels.ComplianceReport{}, ErrInvalidFormat
+	}
+	if !from.Before(to) {
+		return localModels.ComplianceReport{}, fmt.Errorf("%w: from must be before to", ErrInvalidPeriod)
+	}
+	if maxRange := s.maxRange(); to.Sub(from) > maxRange {
+		return localModels.ComplianceReport{}, fmt.Errorf("%w: reports may cover at most %d days", ErrInvalidPeriod, int(maxRange.Hours()/24))
+	}
+
+	now := timestamp.Now()
+	requested := localModels.ComplianceReport{
+		ReportID:    ids.New(),
+		ClientID:    clientID,
+		Format:      format,
+		From:        from.UTC(),
+		To:          to.UTC(),
+		Status:      localModels.ReportPending,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(s.retention()),
+	}
+	if err := mongoretry.InsertOnce(ctx, common.GetCollection(s.CollectionName), "queue_compliance_report", bson.M{"report_id": requested.ReportID}, requested); err != nil {
+		return localModels.ComplianceReport{}, fmt.Errorf("failed to queue report: %w", err)
+	}
+	if s.Trigger != nil {
+		s.Trigger(context.WithoutCancel(ctx))
+	}
+	return requested, nil
+}
+
+// GetComplianceReport returns a compliance report of the client, with its file once it is ready
+func (s *ComplianceServiceImpl) GetComplianceReport(ctx context.Context, clientID, reportID string) (localModels.ComplianceReport, error) {
+	var queued localModels.ComplianceReport
+	err := tenant.Guard(common.GetCollection(s.CollectionName)).
+		FindOne(ctx, tenant.Of(clientID).With("report_id", reportID)).Decode(&queued)
+	if err == mongo.ErrNoDocuments {
+		return localModels.ComplianceReport{}, ErrReportNotFound
+	}
+	if err != nil {
+		return localModels.ComplianceReport{}, fmt.Errorf("failed to look up report: %w", err)
+	}
+	// The TTL monitor only runs once a minute
+	if queued.ExpiresAt.Before(timestamp.Now()) {
+		return localModels.ComplianceReport{}, ErrReportNotFound
+	}
+	return queued, nil
+}
+
+// GeneratePending generates queued compliance reports, oldest first, and tells each
+// client its report is done. It is run by the compliance_report job.
+func (s *ComplianceServiceImpl) GeneratePending(ctx context.Context) error {
+	collection := common.GetCollection(s.CollectionName)
+	opts := options.Find().SetSort(bson.D{{Key: "requested_at", Value: 1}}).SetLimit(s.batchSize())
+	cursor, err := collection.Find(ctx, bson.M{"status": localModels.ReportPending}, opts)
+	if err != nil {
+		return fmt.Errorf("failed to list queued reports: %w", err)
+	}
+	var queued []localModels.ComplianceReport
+	if err := cursor.All(ctx, &queued); err != nil {
+		return fmt.Errorf("failed to decode queued reports: %w", err)
+	}
+
+	var errs []error
+	for _, requested := range queued {
+		content, err := s.generate(ctx, requested)
+		// Reports that failed on the database are left queued for the next run
+		if mongoretry.IsTransient(err) {
+			errs = append(errs, fmt.Errorf("report %s: %w", requested.ReportID, err))
+			continue
+		}
+		now := timestamp.Now()
+		set := bson.M{"completed_at": now, "expires_at": now.Add(s.retention())}
+		if err != nil {
+			zaplogger.GetLogger().Error("Error generating compliance report", zap.Error(err), zap.String("reportID", requested.ReportID))
+			requested.Status, requested.Error = localModels.ReportFailed, "report could not be generated"
+			set["status"], set["error"] = requested.Status, requested.Error
+		} else {
+			requested.Status = localModels.ReportReady
+			set["status"], set["content"] = requested.Status, content
+		}
+		err = mongoretry.Write(ctx, "complete_compliance_report", func(ctx context.Context) error {
+			_, err := collection.UpdateOne(ctx, bson.M{"report_id": requested.ReportID, "status": localModels.ReportPending}, bson.M{"$set": set})
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("report %s: %w", requested.ReportID, err))
+			continue
+		}
+		s.notify(ctx, requested)
+	}
+	return errors.Join(errs...)
+}
+
+// notify sends the client a report.completed webhook for a report that is ready or failed
+func (s *ComplianceServiceImpl) notify(ctx context.Context, completed localModels.ComplianceReport) {
+	if s.Webhooks == nil {
+		return
+	}
+	data := localModels.ComplianceReportData{
+		ReportID: completed.ReportID,
+		Format:   completed.Format,
+		From:     completed.From,
+		To:       completed.To,
+		Status:   completed.Status,
+		Error:    completed.Error,
+	}
+	if err := s.Webhooks.Emit(ctx, completed.ClientID, localModels.WebhookReportCompleted, data); err != nil {
+		zaplogger.GetLogger().Error("Error emitting report completed webhook", zap.Error(err), zap.String("reportID", completed.ReportID))
+	}
+}
+
+// generate gathers the figures of a queued report and writes them in its format
+func (s *ComplianceServiceImpl) generate(ctx context.Context, requested localModels.ComplianceReport) ([]byte, error) {
+	ctx = replicareads.ForExport(ctx)
+	compliance := report.Compliance{
+		ReportID:    requested.ReportID,
+		From:        requested.From,
+		To:          requested.To,
+		GeneratedAt: timestamp.Now(),
+	}
+	period := bson.M{"$gte": requested.From, "$lt": requested.To}
+	scope := tenant.Of(requested.ClientID)
+
+	var err error
+	if compliance.Verifications.ApplicantsCreated, err = s.count(ctx, constants.CollectionApplicants, scope.With("created_at", period)); err != nil {
+		return nil, err
+	}
+	if compliance.Verifications.DocumentsUploaded, err = s.count(ctx, localConstants.CollectionDocuments, scope.With("created_at", period)); err != nil {
+		return nil, err
+	}
+	if compliance.Outcomes, err = s.outcomes(ctx, scope, period); err != nil {
+		return nil, err
+	}
+	compliance.Verifications.DecisionsApplied = compliance.Outcomes.Approved + compliance.Outcomes.Rejected
+	if compliance.SLA, err = s.sla(ctx, scope, period); err != nil {
+		return nil, err
+	}
+	if compliance.Erasures, err = s.erasures(ctx, scope, period); err != nil {
+		return nil, err
+	}
+	return report.WriteCompliance(requested.Format, compliance)
+}
+
+// count counts the records of a collection matching a filter
+func (s *ComplianceServiceImpl) count(ctx context.Context, collectionName string, f tenant.Filter) (int64, error) {
+	filter, err := f.BSON()
+	if err != nil {
+		return 0, err
+	}
+	n, err := replicareads.Collection(ctx, common.GetCollection(collectionName)).CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", collectionName, err)
+	}
+	return n, nil
+}
+
+// outcomeRow is the number of decisions applied with an outcome and reason code
+type outcomeRow struct {
+	ID struct {
+		Decision   localModels.ReviewDecision `bson:"decision"`
+		ReasonCode string                     `bson:"reason_code"`
+	} `bson:"_id"`
+	Count int64 `bson:"count"`
+}
+
+// outcomes counts the decisions applied to the client's applicants in the period
+func (s *ComplianceServiceImpl) outcomes(ctx context.Context, scope tenant.Filter, period bson.M) (report.Outcomes, error) {
+	match, err := scope.With("status", localModels.DecisionApplied).
+		With("audit_trail", bson.M{"$elemMatch": bson.M{"action": localModels.DecisionActionApplied, "at": period}}).BSON()
+	if err != nil {
+		return report.Outcomes{}, err
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{"_id": bson.M{"decision": "$decision", "reason_code": "$reason_code"}, "count": bson.M{"$sum": 1}}},
+	}
+	var rows []outcomeRow
+	if err := aggregate(ctx, localConstants.CollectionDecisions, pipeline, &rows); err != nil {
+		return report.Outcomes{}, err
+	}
+
+	outcomes := report.Outcomes{Reasons: make(map[string]int64, len(rows))}
+	for _, row := range rows {
+		switch row.ID.Decision {
+		case localModels.DecisionApprove:
+			outcomes.Approved += row.Count
+		case localModels.DecisionReject:
+			outcomes.Rejected += row.Count
+		}
+		reason := row.ID.ReasonCode
+		if reason == "" {
+			reason = "unknown"
+		}
+		outcomes.Reasons[reason] += row.Count
+	}
+	return outcomes, nil
+}
+
+// sla measures the time from creation to decision of the client's applicants decided in
+// the period against the decision target
+func (s *ComplianceServiceImpl) sla(ctx context.Context, scope tenant.Filter, period bson.M) (report.SLA, error) {
+	target := s.decisionTarget()
+	decided := []localModels.ApplicantStatus{localModels.ApplicantApproved, localModels.ApplicantRejected}
+	match, err := scope.With("status", bson.M{"$in": decided}).With("review.decided_at", period).BSON()
+	if err != nil {
+		return report.SLA{}, err
+	}
+	took := bson.M{"$subtract": []string{"$review.decided_at", "$created_at"}}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":        nil,
+			"count":      bson.M{"$sum": 1},
+			"within":     bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$lte": []interface{}{took, target.Milliseconds()}}, 1, 0}}},
+			"average_ms": bson.M{"$avg": took},
+			"max_ms":     bson.M{"$max": took},
+		}},
+	}
+	var rows []struct {
+		Count         int64   `bson:"count"`
+		Within        int64   `bson:"within"`
+		AverageMillis float64 `bson:"average_ms"`
+		MaxMillis     float64 `bson:"max_ms"`
+	}
+	if err := aggregate(ctx, constants.CollectionApplicants, pipeline, &rows); err != nil {
+		return report.SLA{}, err
+	}
+
+	sla := report.SLA{TargetSeconds: int64(target.Seconds())}
+	if len(rows) > 0 {
+		sla.Decided, sla.WithinTarget = rows[0].Count, rows[0].Within
+		sla.AverageSeconds, sla.MaxSeconds = rows[0].AverageMillis/1000, rows[0].MaxMillis/1000
+	}
+	return sla, nil
+}
+
+// erasures counts and lists the client's applicants and documents deleted in the period,
+// oldest first, listing up to MaxErasures of them
+func (s *ComplianceServiceImpl) erasures(ctx context.Context, scope tenant.Filter, period bson.M) (report.Erasures, error) {
+	deleted := scope.With("deleted", true).With("deleted_at", period)
+	var erasures report.Erasures
+	var err error
+	if erasures.Applicants, err = s.count(ctx, constants.CollectionApplicants, deleted); err != nil {
+		return report.Erasures{}, err
+	}
+	if erasures.Documents, err = s.count(ctx, localConstants.CollectionDocuments, deleted); err != nil {
+		return report.Erasures{}, err
+	}
+
+	match, err := deleted.BSON()
+	if err != nil {
+		return report.Erasures{}, err
+	}
+	limit := s.maxErasures()
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$project": bson.M{"_id": 0, "type": "applicant", "id": "$applicant_id", "erased_at": "$deleted_at"}},
+		{"$unionWith": bson.M{"coll": localConstants.CollectionDocuments, "pipeline": []bson.M{
+			{"$match": match},
+			{"$project": bson.M{"_id": 0, "type": "document", "id": "$document_id", "erased_at": "$deleted_at"}},
+		}}},
+		{"$sort": bson.D{{Key: "erased_at", Value: 1}, {Key: "id", Value: 1}}},
+		{"$limit": limit},
+	}
+	if err := aggregate(ctx, constants.CollectionApplicants, pipeline, &erasures.Records); err != nil {
+		return report.Erasures{}, err
+	}
+	erasures.Truncated = erasures.Applicants+erasures.Documents > limit
+	return erasures, nil
+}
+
+// aggregate runs a pipeline against a collection and decodes every result
+func aggregate(ctx context.Context, collectionName string, pipeline []bson.M, results interface{}) error {
+	cursor, err := replicareads.Collection(ctx, common.GetCollection(collectionName)).Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate %s: %w", collectionName, err)
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, results); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", collectionName, err)
+	}
+	return nil
+}
+
+func (s *ComplianceServiceImpl) maxRange() time.Duration {
+	if s.Settings.MaxRange > 0 {
+		return s.Settings.MaxRange
+	}
+	return defaultMaxRange
+}
+
+func (s *ComplianceServiceImpl) decisionTarget() time.Duration {
+	if s.Settings.DecisionTarget > 0 {
+		return s.Settings.DecisionTarget
+	}
+	return defaultDecisionTarget
+}
+
+func (s *ComplianceServiceImpl) maxErasures() int64 {
+	if s.Settings.MaxErasures > 0 {
+		return s.Settings.MaxErasures
+	}
+	return defaultMaxErasures
+}
+
+func (s *ComplianceServiceImpl) retention() time.Duration {
+	if s.Settings.Retention > 0 {
+		return s.Settings.Retention
+	}
+	return defaultComplianceRetention
+}
+
+func (s *ComplianceServiceImpl) batchSize() int64 {
+	if s.BatchSize > 0 {
+		return s.BatchSize
+	}
+	return defaultBatchSize
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestComplianceReportChecksPeriod(t *testing.T) {
+	service := ComplianceServiceImpl{Settings: config.ComplianceReportSettings{MaxRange: 31 * 24 * time.Hour}}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.RequestComplianceReport(context.Background(), "client1", "pdf", from, from.AddDate(0, 0, 1))
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+	_, err = service.RequestComplianceReport(context.Background(), "client1", localModels.ReportFormatCSV, from, from)
+	assert.ErrorIs(t, err, ErrInvalidPeriod)
+	_, err = service.RequestComplianceReport(context.Background(), "client1", localModels.ReportFormatCSV, from, from.AddDate(0, 2, 0))
+	assert.ErrorIs(t, err, ErrInvalidPeriod)
+	assert.ErrorContains(t, err, "at most 31 days")
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	documentServices "github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/preview"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrReportNotFound is returned for a report that was never queued for the applicant, or has expired
+	ErrReportNotFound = apperr.NotFound("report_not_found", "report not found")
+)
+
+// PurposeReport is stamped on the thumbnails of reports that do not give a purpose
+const PurposeReport = "report"
+
+const (
+	defaultInlineMaxDocuments = 10
+	defaultMaxThumbnails      = 50
+	defaultRetention          = 24 * time.Hour
+	defaultBatchSize          = 20
+)
+
+// ReportServiceImpl generates the PDF verification reports of applicants. Reports of
+// applicants with many documents take a while to draw, so they are queued in the
+// applicant_reports collection for the applicant_report job and fetched by their ID.
+type ReportServiceImpl struct {
+	CollectionName string
+	Settings       config.ReportSettings
+	Documents      localInterfaces.DocumentThumbnailer // Draws the documents' thumbnails; nil leaves them out
+	Trigger        func(ctx context.Context)           // Starts the applicant_report job once a report is queued; nil waits for its schedule
+}
+
+var (
+	instance ReportServiceImpl
+	once     sync.Once
+)
+
+func GetReportServiceImpl() ReportServiceImpl {
+	once.Do(func() {
+		instance = ReportServiceImpl{
+			CollectionName: localConstants.CollectionApplicantReports,
+		}
+	})
+	return instance
+}
+
+// applicantState is an applicant with the review state stored alongside its record
+type applicantState struct {
+	localModels.ApplicantRecord `bson:",inline"`
+	Status                      localModels.ApplicantStatus `bson:"status"`
+	Review                      localModels.Review          `bson:"review"`
+}
+
+// RequestReport returns the report of a client's applicant. The reports of applicants with
+// no more than InlineMaxDocuments documents are generated straight away and returned
+// ready; larger ones are queued and returned pending, or the one already queued for the
+// applicant is returned.
+func (s *ReportServiceImpl) RequestReport(ctx context.Context, clientID, applicantID, purpose string) (localModels.ApplicantReport, error) {
+	applicant, err := s.applicant(ctx, clientID, applicantID)
+	if err != nil {
+		return localModels.ApplicantReport{}, err
+	}
+	documents, err := s.documents(ctx, clientID, applicantID)
+	if err != nil {
+		return localModels.ApplicantReport{}, err
+	}
+
+	now := timestamp.Now()
+	requested := localModels.ApplicantReport{
+		ReportID:    ids.New(),
+		ApplicantID: applicantID,
+		ClientID:    clientID,
+		Status:      localModels.ReportPending,
+		Purpose:     purpose,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(s.retention()),
+	}
+	if len(documents) <= s.inlineMaxDocuments() {
+		if err := s.render(ctx, &requested, applicant, documents); err != nil {
+			return localModels.ApplicantReport{}, err
+		}
+		requested.Status, requested.CompletedAt = localModels.ReportReady, &now
+		return requested, nil
+	}
+
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	var queued localModels.ApplicantReport
+	err = collection.FindOne(ctx, tenant.Of(clientID).With("applicant_id", applicantID).With("status", localModels.ReportPending),
+		options.FindOne().SetProjection(bson.M{"content": 0})).Decode(&queued)
+	if err == nil {
+		return queued, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return localModels.ApplicantReport{}, fmt.Errorf("failed to look up queued reports: %w", err)
+	}
+	if err := mongoretry.InsertOnce(ctx, common.GetCollection(s.CollectionName), "queue_report", bson.M{"report_id": requested.ReportID}, requested); err != nil {
+		return localModels.ApplicantReport{}, fmt.Errorf("failed to queue report: %w", err)
+	}
+	if s.Trigger != nil {
+		s.Trigger(context.WithoutCancel(ctx))
+	}
+	return requested, nil
+}
+
+// GetReport returns a queued report of a client's applicant, with its PDF once it is ready
+func (s *ReportServiceImpl) GetReport(ctx context.Context, clientID, applicantID, reportID string) (localModels.ApplicantReport, error) {
+	var queued localModels.ApplicantReport
+	err := tenant.Guard(common.GetCollection(s.CollectionName)).
+		FindOne(ctx, tenant.Of(clientID).With("applicant_id", applicantID).With("report_id", reportID)).Decode(&queued)
+	if err == mongo.ErrNoDocuments {
+		return localModels.ApplicantReport{}, ErrReportNotFound
+	}
+	if err != nil {
+		return localModels.ApplicantReport{}, fmt.Errorf("failed to look up report: %w", err)
+	}
+	// The TTL monitor only runs once a minute
+	if queued.ExpiresAt.Before(timestamp.Now()) {
+		return localModels.ApplicantReport{}, ErrReportNotFound
+	}
+	return queued, nil
+}
+
+// GeneratePending generates queued reports, oldest first, recording those that cannot be
+// generated as failed. It is run by the applicant_report job.
+func (s *ReportServiceImpl) GeneratePending(ctx context.Context) error {
+	collection := common.GetCollection(s.CollectionName)
+	opts := options.Find().SetSort(bson.D{{Key: "requested_at", Value: 1}}).SetLimit(s.batchSize())
+	cursor, err := collection.Find(ctx, bson.M{"status": localModels.ReportPending}, opts)
+	if err != nil {
+		return fmt.Errorf("failed to list queued reports: %w", err)
+	}
+	var queued []localModels.ApplicantReport
+	if err := cursor.All(ctx, &queued); err != nil {
+		return fmt.Errorf("failed to decode queued reports: %w", err)
+	}
+
+	var errs []error
+	for _, requested := range queued {
+		err := s.generate(ctx, &requested)
+		// Reports that failed on the database are left queued for the next run
+		if mongoretry.IsTransient(err) {
+			errs = append(errs, fmt.Errorf("report %s: %w", requested.ReportID, err))
+			continue
+		}
+		now := timestamp.Now()
+		set := bson.M{"completed_at": now, "expires_at": now.Add(s.retention())}
+		switch {
+		case errors.Is(err, apperr.ErrApplicantNotFound):
+			set["status"], set["error"] = localModels.ReportFailed, err.Error()
+		case err != nil:
+			zaplogger.GetLogger().Error("Error generating applicant report", zap.Error(err),
+				zap.String("reportID", requested.ReportID), zap.String("applicantID", requested.ApplicantID))
+			set["status"], set["error"] = localModels.ReportFailed, "report could not be generated"
+		default:
+			set["status"], set["content"], set["pages"], set["document_ids"] = localModels.ReportReady, requested.Content, requested.Pages, requested.DocumentIDs
+		}
+		err = mongoretry.Write(ctx, "complete_report", func(ctx context.Context) error {
+			_, err := collection.UpdateOne(ctx, bson.M{"report_id": requested.ReportID, "status": localModels.ReportPending}, bson.M{"$set": set})
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("report %s: %w", requested.ReportID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// generate reads an applicant's records and renders the report queued for it
+func (s *ReportServiceImpl) generate(ctx context.Context, requested *localModels.ApplicantReport) error {
+	applicant, err := s.applicant(ctx, requested.ClientID, requested.ApplicantID)
+	if err != nil {
+		return err
+	}
+	documents, err := s.documents(ctx, requested.ClientID, requested.ApplicantID)
+	if err != nil {
+		return err
+	}
+	return s.render(ctx, requested, applicant, documents)
+}
+
+// render reads what the report shows beyond the applicant and its documents, and draws
+// it into the report
+func (s *ReportServiceImpl) render(ctx context.Context, requested *localModels.ApplicantReport, applicant applicantState, documents []localModels.DocumentRecord) error {
+	summary := report.Summary{
+		Applicant:   applicant.ApplicantRecord,
+		Status:      applicant.Status,
+		Review:      applicant.Review,
+		Documents:   s.thumbnails(ctx, documents, localModels.DocumentDownload{Viewer: requested.ClientID, Purpose: requested.Purpose}),
+		GeneratedAt: timestamp.Now(),
+	}
+	scope := tenant.Of(requested.ClientID).With("applicant_id", requested.ApplicantID)
+
+	cursor, err := tenant.Guard(common.GetCollection(localConstants.CollectionSumsubSyncs)).
+		Find(ctx, scope, options.Find().SetSort(bson.D{{Key: "synced_at", Value: -1}}))
+	if err != nil {
+		return fmt.Errorf("failed to list provider results: %w", err)
+	}
+	if err := cursor.All(ctx, &summary.Syncs); err != nil {
+		return fmt.Errorf("failed to decode provider results: %w", err)
+	}
+
+	cursor, err = tenant.Guard(common.GetCollection(localConstants.CollectionDecisions)).
+		Find(ctx, scope, options.Find().SetSort(bson.D{{Key: "proposed_at", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("failed to list decisions: %w", err)
+	}
+	if err := cursor.All(ctx, &summary.Decisions); err != nil {
+		return fmt.Errorf("failed to decode decisions: %w", err)
+	}
+	content, pages, err := report.Render(summary)
+	if err != nil {
+		return err
+	}
+	requested.Content, requested.Pages = content, pages
+	requested.DocumentIDs = make([]string, len(documents))
+	for i, doc := range documents {
+		requested.DocumentIDs[i] = doc.DocumentID
+	}
+	return nil
+}
+
+// thumbnails draws a thumbnail of each document, up to MaxThumbnails. Documents whose
+// thumbnail cannot be drawn are shown without one, saying why.
+func (s *ReportServiceImpl) thumbnails(ctx context.Context, documents []localModels.DocumentRecord, download localModels.DocumentDownload) []report.Document {
+	maxThumbnails := s.Settings.MaxThumbnails
+	if maxThumbnails <= 0 {
+		maxThumbnails = defaultMaxThumbnails
+	}
+	shown := make([]report.Document, len(documents))
+	for i, doc := range documents {
+		shown[i].Record = doc
+		switch {
+		case s.Documents == nil:
+			shown[i].Missing = "thumbnails are not available"
+		case i >= maxThumbnails:
+			shown[i].Missing = fmt.Sprintf("reports show the first %d documents' images", maxThumbnails)
+		default:
+			thumbnail, err := s.Documents.Thumbnail(ctx, doc, report.ThumbnailSize, download)
+			if err != nil {
+				shown[i].Missing = missingThumbnail(err)
+				if shown[i].Missing == "" {
+					zaplogger.GetLogger().Warn("Failed to draw document thumbnail for report", zap.Error(err), zap.String("documentID", doc.DocumentID))
+					shown[i].Missing = "the image could not be read"
+				}
+				continue
+			}
+			shown[i].Thumbnail = thumbnail
+		}
+	}
+	return shown
+}
+
+// missingThumbnail explains to the reader why a document has no thumbnail, or returns
+// empty for errors that are not the document's own
+func missingThumbnail(err error) string {
+	switch {
+	case errors.Is(err, documentServices.ErrUploadInProgress):
+		return "the upload has not finished"
+	case errors.Is(err, documentServices.ErrDocumentArchived):
+		return "the file is in archival storage"
+	case errors.Is(err, preview.ErrUnsupported):
+		return "the file has no image to show"
+	}
+	return ""
+}
+
+// applicant reads a client's applicant with its review state
+func (s *ReportServiceImpl) applicant(ctx context.Context, clientID, applicantID string) (applicantState, error) {
+	var applicant applicantState
+	err := tenant.Guard(common.GetCollection(constants.CollectionApplicants)).
+		FindOne(ctx, tenant.Of(clientID).With("applicant_id", applicantID).With("deleted", false)).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return applicantState{}, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return applicantState{}, fmt.Errorf("failed to look up applicant: %w", err)
+	}
+	return applicant, nil
+}
+
+// documents lists the documents of a client's applicant, oldest first
+func (s *ReportServiceImpl) documents(ctx context.Context, clientID, applicantID string) ([]localModels.DocumentRecord, error) {
+	cursor, err := tenant.Guard(common.GetCollection(localConstants.CollectionDocuments)).
+		Find(ctx, tenant.Of(clientID).With("applicant_id", applicantID).With("deleted", false),
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	documents := []localModels.DocumentRecord{}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, fmt.Errorf("failed to decode documents: %w", err)
+	}
+	return documents, nil
+}
+
+func (s *ReportServiceImpl) inlineMaxDocuments() int {
+	if s.Settings.InlineMaxDocuments > 0 {
+		return s.Settings.InlineMaxDocuments
+	}
+	return defaultInlineMaxDocuments
+}
+
+func (s *ReportServiceImpl) retention() time.Duration {
+	if s.Settings.Retention > 0 {
+		return s.Settings.Retention
+	}
+	return defaultRetention
+}
+
+func (s *ReportServiceImpl) batchSize() int64 {
+	if s.Settings.BatchSize > 0 {
+		return s.Settings.BatchSize
+	}
+	return defaultBatchSize
+}
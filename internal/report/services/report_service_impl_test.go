@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	documentServices "github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/preview"
+	"github.com/rachel-lawrie/verus_app_backend/internal/report"
+	coreModels "github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeThumbnailer draws a thumbnail of every document except those given an error
+type fakeThumbnailer struct {
+	errs      map[string]error
+	downloads []localModels.DocumentDownload
+}
+
+func (f *fakeThumbnailer) Thumbnail(ctx context.Context, doc localModels.DocumentRecord, maxDimension int, download localModels.DocumentDownload) ([]byte, error) {
+	f.downloads = append(f.downloads, download)
+	if err := f.errs[doc.DocumentID]; err != nil {
+		return nil, err
+	}
+	return []byte("jpeg of " + doc.DocumentID), nil
+}
+
+func TestThumbnails(t *testing.T) {
+	documents := make([]localModels.DocumentRecord, 5)
+	for i := range documents {
+		documents[i] = localModels.DocumentRecord{Document: coreModels.Document{DocumentID: fmt.Sprintf("doc-%d", i+1)}}
+	}
+	thumbnailer := &fakeThumbnailer{errs: map[string]error{
+		"doc-1": documentServices.ErrDocumentArchived,
+		"doc-2": fmt.Errorf("%w: text PDF", preview.ErrUnsupported),
+		"doc-3": errors.New("failed to download file from S3: timeout"),
+	}}
+	service := ReportServiceImpl{Documents: thumbnailer, Settings: config.ReportSettings{MaxThumbnails: 4}}
+	download := localModels.DocumentDownload{Viewer: "client1", Purpose: PurposeReport}
+
+	shown := service.thumbnails(context.Background(), documents, download)
+	assert.Equal(t, []report.Document{
+		{Record: documents[0], Missing: "the file is in archival storage"},
+		{Record: documents[1], Missing: "the file has no image to show"},
+		{Record: documents[2], Missing: "the image could not be read"},
+		{Record: documents[3], Thumbnail: []byte("jpeg of doc-4")},
+		{Record: documents[4], Missing: "reports show the first 4 documents' images"},
+	}, shown)
+	assert.Len(t, thumbnailer.downloads, 4)
+	assert.Equal(t, download, thumbnailer.downloads[0])
+
+	// Without a thumbnailer every document is listed without an image
+	service.Documents = nil
+	shown = service.thumbnails(context.Background(), documents[:1], download)
+	assert.Equal(t, "thumbnails are not available", shown[0].Missing)
+}
@@ -0,0 +1,33 @@
+// Package requestmeta captures details about the device and network a request came from
+package requestmeta
+
+import (
+	"github.com/gin-gonic/gin"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+const contextKey = "request_device"
+
+// FingerprintHeader lets clients forward a device fingerprint computed on their side
+const FingerprintHeader = "X-Device-Fingerprint"
+
+// Capture records the source IP, user agent and device fingerprint of each request
+func Capture() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextKey, localModels.DeviceInfo{
+			IP:             c.ClientIP(),
+			UserAgent:      c.Request.UserAgent(),
+			Fingerprint:    c.GetHeader(FingerprintHeader),
+			AcceptLanguage: c.GetHeader("Accept-Language"),
+		})
+		c.Next()
+	}
+}
+
+// FromContext returns the device details captured for the request
+func FromContext(c *gin.Context) localModels.DeviceInfo {
+	if info, ok := c.Get(contextKey); ok {
+		return info.(localModels.DeviceInfo)
+	}
+	return localModels.DeviceInfo{}
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+const (
+	defaultQueueLimit = 50
+	maxQueueLimit     = 200
+)
+
+// respondReviewError maps review service errors to HTTP responses
+func respondReviewError(c *gin.Context, err error) {
+	if !apperr.Respond(c, err) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update review queue"})
+	}
+}
+
+// GetReviewQueue is the handler function for listing applicants awaiting review
+func GetReviewQueue(c *gin.Context, service interfaces.ReviewService) {
+	filter := localModels.ReviewQueueFilter{
+		ClientID: c.Query("client_id"),
+		Limit:    defaultQueueLimit,
+	}
+
+	if statusParam := c.Query("status"); statusParam != "" {
+		for _, s := range strings.Split(statusParam, ",") {
+			status, err := localModels.ParseApplicantStatus(s)
+			if err != nil || (status != localModels.ApplicantInReview && status != localModels.ApplicantResubmissionRequired) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter: " + s})
+				return
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if assigned, ok := c.GetQuery("assigned_to"); ok {
+		switch assigned {
+		case "unassigned":
+			assigned = ""
+		case "me":
+			assigned = c.GetString("admin_id")
+		}
+		filter.AssignedTo = &assigned
+	}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		limit, err := strconv.ParseInt(limitParam, 10, 64)
+		if err != nil || limit <= 0 || limit > maxQueueLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxQueueLimit)})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	items, err := service.GetReviewQueue(c, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve review queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// ClaimApplicant is the handler function for a reviewer claiming an applicant
+func ClaimApplicant(c *gin.Context, service interfaces.ReviewService) {
+	reviewerID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := service.ClaimApplicant(c, c.Param("id"), reviewerID)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// ReleaseApplicant is the handler function for a reviewer giving up a claim
+func ReleaseApplicant(c *gin.Context, service interfaces.ReviewService) {
+	reviewerID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := service.ReleaseApplicant(c, c.Param("id"), reviewerID)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// AssignApplicant is the handler function for an admin assigning an applicant to a reviewer
+func AssignApplicant(c *gin.Context, service interfaces.ReviewService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestBody struct {
+		ReviewerID string `json:"reviewer_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reviewer_id is required"})
+		return
+	}
+
+	item, err := service.AssignApplicant(c, c.Param("id"), requestBody.ReviewerID, adminID)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/review/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupReviewRouter registers the review routes behind a fake admin login
+func setupReviewRouter(mockService *localMocks.MockReviewService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("admin_id", "reviewer1")
+		c.Set("admin_role", "reviewer")
+	})
+	router.GET("/review-queue", func(c *gin.Context) {
+		GetReviewQueue(c, mockService)
+	})
+	router.POST("/review-queue/:id/claim", func(c *gin.Context) {
+		ClaimApplicant(c, mockService)
+	})
+	return router
+}
+
+func TestGetReviewQueue(t *testing.T) {
+	tests := []struct {
+		name               string
+		query              string
+		expectedStatusCode int
+		expectCall         bool
+	}{
+		{"Default filter", "", http.StatusOK, true},
+		{"Unassigned only", "?assigned_to=unassigned", http.StatusOK, true},
+		{"Invalid status", "?status=approved", http.StatusBadRequest, false},
+		{"Invalid limit", "?limit=1000", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockReviewService)
+			router := setupReviewRouter(mockService)
+			mockService.On("GetReviewQueue", mock.Anything, mock.Anything).Return([]localModels.ReviewQueueItem{{ApplicantID: "app1"}}, nil)
+
+			req, _ := http.NewRequest(http.MethodGet, "/review-queue"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectCall {
+				mockService.AssertExpectations(t)
+			} else {
+				mockService.AssertNotCalled(t, "GetReviewQueue", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestClaimApplicant(t *testing.T) {
+	tests := []struct {
+		name               string
+		mockError          error
+		expectedStatusCode int
+	}{
+		{"Success", nil, http.StatusOK},
+		{"Claimed by someone else", services.ErrClaimConflict, http.StatusConflict},
+		{"Not in queue", services.ErrNotInQueue, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockReviewService)
+			router := setupReviewRouter(mockService)
+			mockService.On("ClaimApplicant", mock.Anything, "app1", "reviewer1").Return(localModels.ReviewQueueItem{ApplicantID: "app1"}, tt.mockError)
+
+			req, _ := http.NewRequest(http.MethodPost, "/review-queue/app1/claim", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
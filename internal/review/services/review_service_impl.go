@@ -0,0 +1,176 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/replicareads"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+var (
+	// ErrNotInQueue is returned when the applicant does not exist or is not awaiting review
+	ErrNotInQueue = apperr.NotFound("not_in_review_queue", "applicant not found in review queue")
+	// ErrClaimConflict is returned when the applicant is assigned to another reviewer
+	ErrClaimConflict = apperr.Conflict("claimed_by_another_reviewer", "applicant is assigned to another reviewer")
+)
+
+// ReviewServiceImpl implements the admin review queue on top of the applicants collection
+type ReviewServiceImpl struct {
+	CollectionName string
+}
+
+var (
+	instance ReviewServiceImpl
+	once     sync.Once
+)
+
+func GetReviewServiceImpl() ReviewServiceImpl {
+	once.Do(func() {
+		instance = ReviewServiceImpl{
+			CollectionName: constants.CollectionApplicants,
+		}
+	})
+	return instance
+}
+
+// queueFilter matches applicants of any client currently awaiting review, as reviewers
+// work the queue for every client
+func queueFilter(applicantID string) tenant.Filter {
+	return tenant.AllClients().
+		With("applicant_id", applicantID).
+		With("deleted", false).
+		With("status", bson.M{"$in": localModels.ReviewQueueStatuses})
+}
+
+// GetReviewQueue lists applicants awaiting review across all clients, oldest first
+func (s *ReviewServiceImpl) GetReviewQueue(c *gin.Context, f localModels.ReviewQueueFilter) ([]localModels.ReviewQueueItem, error) {
+	logger := zaplogger.GetLogger()
+	collection := tenant.Guard(replicareads.Collection(c.Request.Context(), common.GetCollection(s.CollectionName)))
+
+	statuses := f.Statuses
+	if len(statuses) == 0 {
+		statuses = localModels.ReviewQueueStatuses
+	}
+	scope := tenant.AllClients()
+	if f.ClientID != "" {
+		scope = tenant.Of(f.ClientID)
+	}
+	filter := scope.With("deleted", false).With("status", bson.M{"$in": statuses})
+	if f.AssignedTo != nil {
+		if *f.AssignedTo == "" {
+			filter = filter.With("review.assigned_to", nil)
+		} else {
+			filter = filter.With("review.assigned_to", *f.AssignedTo)
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "review.entered_at", Value: 1}, {Key: "updated_at", Value: 1}}).
+		SetLimit(f.Limit)
+
+	cursor, err := collection.Find(c.Request.Context(), filter, opts)
+	if err != nil {
+		logger.Error("Error fetching review queue from MongoDB", zap.Error(err))
+		return nil, err
+	}
+	defer cursor.Close(c.Request.Context())
+
+	now := timestamp.Now()
+	items := []localModels.ReviewQueueItem{}
+	for cursor.Next(c.Request.Context()) {
+		var item localModels.ReviewQueueItem
+		if err := cursor.Decode(&item); err != nil {
+			logger.Error("Error decoding review queue item", zap.Error(err))
+			return nil, err
+		}
+		item.ApplySLA(now)
+		items = append(items, item)
+	}
+	if err := cursor.Err(); err != nil {
+		logger.Error("Cursor error", zap.Error(err))
+		return nil, err
+	}
+	return items, nil
+}
+
+// ClaimApplicant assigns an unassigned applicant to the calling reviewer
+func (s *ReviewServiceImpl) ClaimApplicant(c *gin.Context, applicantID, reviewerID string) (localModels.ReviewQueueItem, error) {
+	filter := queueFilter(applicantID).With("$or", bson.A{
+		bson.M{"review.assigned_to": nil},
+		bson.M{"review.assigned_to": reviewerID},
+	})
+	now := timestamp.Now()
+	update := bson.M{"$set": bson.M{
+		"review.assigned_to": reviewerID,
+		"review.assigned_by": reviewerID,
+		"review.claimed_at":  now,
+		"updated_at":         now,
+	}}
+	return s.updateQueueItem(c, applicantID, filter, update)
+}
+
+// AssignApplicant assigns an applicant to a reviewer on behalf of an admin, overriding any claim
+func (s *ReviewServiceImpl) AssignApplicant(c *gin.Context, applicantID, reviewerID, assignedBy string) (localModels.ReviewQueueItem, error) {
+	now := timestamp.Now()
+	update := bson.M{"$set": bson.M{
+		"review.assigned_to": reviewerID,
+		"review.assigned_by": assignedBy,
+		"review.claimed_at":  now,
+		"updated_at":         now,
+	}}
+	return s.updateQueueItem(c, applicantID, queueFilter(applicantID), update)
+}
+
+// ReleaseApplicant returns a claimed applicant to the unassigned pool
+func (s *ReviewServiceImpl) ReleaseApplicant(c *gin.Context, applicantID, reviewerID string) (localModels.ReviewQueueItem, error) {
+	filter := queueFilter(applicantID).With("review.assigned_to", reviewerID)
+	update := bson.M{
+		"$set":   bson.M{"review.assigned_to": nil, "updated_at": timestamp.Now()},
+		"$unset": bson.M{"review.assigned_by": "", "review.claimed_at": ""},
+	}
+	return s.updateQueueItem(c, applicantID, filter, update)
+}
+
+// updateQueueItem applies an update to an applicant in the queue and returns the result
+func (s *ReviewServiceImpl) updateQueueItem(c *gin.Context, applicantID string, filter tenant.Filter, update bson.M) (localModels.ReviewQueueItem, error) {
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var item localModels.ReviewQueueItem
+	err := collection.FindOneAndUpdate(c.Request.Context(), filter, update, opts).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return item, s.explainMiss(c, applicantID)
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error("Error updating review queue item", zap.Error(err), zap.String("applicantID", applicantID))
+		return item, err
+	}
+	item.ApplySLA(time.Now())
+	return item, nil
+}
+
+// explainMiss works out why a queue update matched nothing
+func (s *ReviewServiceImpl) explainMiss(c *gin.Context, applicantID string) error {
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	err := collection.FindOne(c.Request.Context(), queueFilter(applicantID)).Err()
+	if err == mongo.ErrNoDocuments {
+		return ErrNotInQueue
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up applicant: %v", err)
+	}
+	return ErrClaimConflict
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	zap "go.uber.org/zap"
+)
+
+// RiskServiceImpl records risk signals raised by other subsystems on the applicant record
+type RiskServiceImpl struct {
+	CollectionName string
+}
+
+var (
+	instance RiskServiceImpl
+	once     sync.Once
+)
+
+func GetRiskServiceImpl() RiskServiceImpl {
+	once.Do(func() {
+		instance = RiskServiceImpl{
+			CollectionName: constants.CollectionApplicants,
+		}
+	})
+	return instance
+}
+
+// RecordSignal appends a risk signal to the applicant's risk_signals
+func (s *RiskServiceImpl) RecordSignal(ctx context.Context, applicantID string, signal localModels.RiskSignal) error {
+	logger := zaplogger.GetLogger()
+	collection := common.GetCollection(s.CollectionName)
+
+	if signal.CreatedAt.IsZero() {
+		signal.CreatedAt = timestamp.Now()
+	}
+
+	filter := bson.M{"applicant_id": applicantID, "deleted": false}
+	update := bson.M{
+		"$push": bson.M{"risk_signals": signal},
+		"$set":  bson.M{"updated_at": timestamp.Now()},
+	}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.Error("Error recording risk signal", zap.Error(err), zap.String("applicantID", applicantID))
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("applicant %s not found", applicantID)
+	}
+
+	logger.Info("Risk signal recorded",
+		zap.String("applicantID", applicantID),
+		zap.String("code", signal.Code),
+		zap.String("severity", string(signal.Severity)),
+		zap.String("source", signal.Source),
+	)
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package s3upload encrypts files and stores them in S3, in place of the core library's
+// S3Uploader. Objects are written in the same format, AES-GCM under a KMS data key with
+// the encrypted key and nonce in the object's metadata, so either can read the other's
+// files. Files are sealed into pooled buffers and sent as an S3 multipart upload once
+// they are larger than a part, so concurrent uploads neither grow new buffers nor copy
+// the ciphertext again to send it.
+//
+// GCM seals a file as a whole, and the format has one tag for the whole object, so the
+// plaintext is still held once per upload. Sealing while reading the request would need
+// a chunked format that every reader of the bucket understands.
+package s3upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+)
+
+const (
+	// PartSize is the size of each part of a multipart upload. Files that seal to no more
+	// than one part are sent with a single PutObject.
+	PartSize = 8 << 20
+	// maxPooledBuffer bounds the buffers kept for reuse; larger ones are left to the
+	// garbage collector
+	maxPooledBuffer = 32 << 20
+)
+
+// buffers hold the plaintext of files that are not in memory already, and every sealed file
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		buffers.Put(buf)
+	}
+}
+
+// Client is the part of the S3 API the uploader uses
+type Client interface {
+	PutObject(ctx context.Context, input *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput, opts ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, input *s3.UploadPartInput, opts ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, opts ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, opts ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	GetObject(ctx context.Context, input *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Uploader stores encrypted files in one bucket
+type Uploader struct {
+	client   Client
+	bucket   string
+	partSize int
+}
+
+var _ interfaces.Uploader = (*Uploader)(nil)
+
+// New creates an uploader for the bucket
+func New(client Client, bucket string) *Uploader {
+	return &Uploader{client: client, bucket: bucket, partSize: PartSize}
+}
+
+// UploadFile encrypts file under a new data key and stores it as fileName, returning the
+// object's URL
+func (u *Uploader) UploadFile(ctx context.Context, file multipart.File, fileName string, mimeType string, kmsUploader interfaces.KMSUploader) (string, error) {
+	plaintextKey, encryptedKey, err := kmsUploader.GenerateDataKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate data key: %v", err)
+	}
+	block, err := aes.NewCipher(plaintextKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create AES cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("failed to create AES-GCM: %v", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	plaintext, release, err := readAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	sealed := getBuffer()
+	defer putBuffer(sealed)
+	sealed.Grow(len(plaintext) + aead.Overhead())
+	ciphertext := aead.Seal(sealed.AvailableBuffer(), nonce, plaintext, nil)
+	release()
+
+	metadata := map[string]string{
+		"encrypted-key": base64.StdEncoding.EncodeToString(encryptedKey),
+		"nonce":         base64.StdEncoding.EncodeToString(nonce),
+	}
+	if len(ciphertext) <= u.partSize {
+		_, err = u.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(u.bucket),
+			Key:         aws.String(fileName),
+			Body:        bytes.NewReader(ciphertext),
+			ContentType: aws.String(mimeType),
+			ACL:         types.ObjectCannedACLPrivate,
+			Metadata:    metadata,
+		})
+	} else {
+		err = u.uploadParts(ctx, ciphertext, fileName, mimeType, metadata)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to upload encrypted file to S3: %v", err)
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", u.bucket, fileName), nil
+}
+
+// uploadParts sends the ciphertext as a multipart upload, each part read in place. The
+// upload is aborted if any part fails, so S3 keeps no orphaned parts.
+func (u *Uploader) uploadParts(ctx context.Context, ciphertext []byte, fileName, mimeType string, metadata map[string]string) error {
+	created, err := u.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(u.bucket),
+		Key:         aws.String(fileName),
+		ContentType: aws.String(mimeType),
+		ACL:         types.ObjectCannedACLPrivate,
+		Metadata:    metadata,
+	})
+	if err != nil {
+		return err
+	}
+	var parts []types.CompletedPart
+	for offset := 0; offset < len(ciphertext); offset += u.partSize {
+		end := min(offset+u.partSize, len(ciphertext))
+		number := aws.Int32(int32(len(parts) + 1))
+		part, err := u.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(u.bucket),
+			Key:           aws.String(fileName),
+			UploadId:      created.UploadId,
+			PartNumber:    number,
+			Body:          bytes.NewReader(ciphertext[offset:end]),
+			ContentLength: aws.Int64(int64(end - offset)),
+		})
+		if err != nil {
+			u.abort(fileName, created.UploadId)
+			return fmt.Errorf("part %d: %w", *number, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: part.ETag, PartNumber: number})
+	}
+	_, err = u.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(u.bucket),
+		Key:             aws.String(fileName),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		u.abort(fileName, created.UploadId)
+	}
+	return err
+}
+
+// abort drops an unfinished multipart upload, even if the request that started it was cancelled
+func (u *Uploader) abort(fileName string, uploadID *string) {
+	_, err := u.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(u.bucket),
+		Key:      aws.String(fileName),
+		UploadId: uploadID,
+	})
+	if err != nil {
+		log.Printf("Error aborting multipart upload of %s: %v", fileName, err)
+	}
+}
+
+// DownloadFile returns an object as stored, still encrypted
+func (u *Uploader) DownloadFile(ctx context.Context, objectKey string) (*s3.GetObjectOutput, error) {
+	output, err := u.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object from S3: %w", err)
+	}
+	return output, nil
+}
+
+// readAll returns the file's contents and a function that releases them. Uploads held in
+// memory are read in place; anything else is read into a pooled buffer.
+func readAll(file multipart.File) ([]byte, func(), error) {
+	if inMemory, ok := file.(interface{ Bytes() []byte }); ok {
+		if content := inMemory.Bytes(); content != nil {
+			return content, func() {}, nil
+		}
+	}
+	buf := getBuffer()
+	if stat, ok := file.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		if info, err := stat.Stat(); err == nil {
+			buf.Grow(int(info.Size()))
+		}
+	}
+	if _, err := io.Copy(buf, file); err != nil {
+		putBuffer(buf)
+		return nil, nil, err
+	}
+	return buf.Bytes(), func() { putBuffer(buf) }, nil
+}
@@ -0,0 +1,171 @@
+package s3upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKMS struct{}
+
+func (fakeKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	return bytes.Repeat([]byte{7}, 32), []byte("encrypted-key"), nil
+}
+
+func (fakeKMS) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return plaintext, nil
+}
+
+func (fakeKMS) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	return encrypted, nil
+}
+
+// fakeS3 keeps objects and multipart uploads in memory
+type fakeS3 struct {
+	objects  map[string][]byte
+	metadata map[string]map[string]string
+	parts    [][]byte
+	failPart int32
+	aborted  int
+	puts     int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, metadata: map[string]map[string]string{}}
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, input *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, _ := io.ReadAll(input.Body)
+	f.objects[*input.Key] = body
+	f.metadata[*input.Key] = input.Metadata
+	f.puts++
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) CreateMultipartUpload(ctx context.Context, input *s3.CreateMultipartUploadInput, opts ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.metadata[*input.Key] = input.Metadata
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload1")}, nil
+}
+
+func (f *fakeS3) UploadPart(ctx context.Context, input *s3.UploadPartInput, opts ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if *input.PartNumber == f.failPart {
+		return nil, errors.New("connection reset")
+	}
+	body, _ := io.ReadAll(input.Body)
+	f.parts = append(f.parts, body)
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag%d", *input.PartNumber))}, nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, opts ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	if len(input.MultipartUpload.Parts) != len(f.parts) {
+		return nil, errors.New("parts missing")
+	}
+	f.objects[*input.Key] = bytes.Join(f.parts, nil)
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(ctx context.Context, input *s3.AbortMultipartUploadInput, opts ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.aborted++
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, input *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(f.objects[*input.Key]))}, nil
+}
+
+// memoryFile is an upload held in memory, as the document services hand them over
+type memoryFile struct {
+	*bytes.Reader
+	content []byte
+}
+
+func (f memoryFile) Bytes() []byte { return f.content }
+func (f memoryFile) Close() error  { return nil }
+
+// open decrypts a stored object the way the core library's readers do
+func open(t *testing.T, store *fakeS3, key string) []byte {
+	nonce, err := base64.StdEncoding.DecodeString(store.metadata[key]["nonce"])
+	require.NoError(t, err)
+	block, err := aes.NewCipher(bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	plaintext, err := aead.Open(nil, nonce, store.objects[key], nil)
+	require.NoError(t, err)
+	return plaintext
+}
+
+func TestUploadFileSmallFile(t *testing.T) {
+	store := newFakeS3()
+	content := []byte("passport scan")
+	url, err := New(store, "documents").UploadFile(context.Background(), memoryFile{bytes.NewReader(content), content}, "doc1.png", "image/png", fakeKMS{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://documents.s3.amazonaws.com/doc1.png", url)
+	assert.Equal(t, 1, store.puts)
+	assert.Empty(t, store.parts)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("encrypted-key")), store.metadata["doc1.png"]["encrypted-key"])
+	assert.Equal(t, content, open(t, store, "doc1.png"))
+}
+
+func TestUploadFileInParts(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	file, err := os.CreateTemp(t.TempDir(), "upload")
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.Write(content)
+	require.NoError(t, err)
+	_, err = file.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	store := newFakeS3()
+	uploader := New(store, "documents")
+	uploader.partSize = 4096
+	_, err = uploader.UploadFile(context.Background(), file, "doc2.pdf", "application/pdf", fakeKMS{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, store.puts)
+	assert.Len(t, store.parts, 3)
+	assert.Equal(t, content, open(t, store, "doc2.pdf"))
+}
+
+func TestUploadFileAbortsFailedParts(t *testing.T) {
+	content := bytes.Repeat([]byte{1}, 10000)
+	store := newFakeS3()
+	store.failPart = 2
+	uploader := New(store, "documents")
+	uploader.partSize = 4096
+	_, err := uploader.UploadFile(context.Background(), memoryFile{bytes.NewReader(content), content}, "doc3.pdf", "application/pdf", fakeKMS{})
+	assert.ErrorContains(t, err, "part 2")
+	assert.Equal(t, 1, store.aborted)
+	assert.NotContains(t, store.objects, "doc3.pdf")
+}
+
+// discardS3 accepts every object without keeping it
+type discardS3 struct{ fakeS3 }
+
+func (discardS3) PutObject(ctx context.Context, input *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return &s3.PutObjectOutput{}, nil
+}
+
+func BenchmarkUploadFile(b *testing.B) {
+	content := bytes.Repeat([]byte{1}, 2<<20)
+	uploader := New(&discardS3{}, "documents")
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := uploader.UploadFile(context.Background(), memoryFile{bytes.NewReader(content), content}, "doc.png", "image/png", fakeKMS{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
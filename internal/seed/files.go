@@ -0,0 +1,101 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math/rand"
+	"strings"
+)
+
+// passportImage draws a passport data page: a photo, lines standing in for the printed
+// fields and machine readable zone, and a red band marking it as a sample. It holds no
+// text, so nothing on it can be mistaken for a real identity.
+func passportImage(r *rand.Rand, p person) []byte {
+	const width, height = 640, 420
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill := func(x0, y0, x1, y1 int, c color.Color) {
+		draw.Draw(img, image.Rect(x0, y0, x1, y1), &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+
+	// Pages of one applicant share a tint, so a demo shows them apart
+	tint := uint8(len(p.FirstName+p.LastName) * 9)
+	fill(0, 0, width, height, color.RGBA{225, 232 - tint/8, 220 + tint/10, 255})
+	fill(0, 0, width, 48, color.RGBA{40, 60, 110, 255})
+	fill(32, 80, 192, 290, color.RGBA{170, 170, 175, 255})
+	fill(82, 115, 142, 175, color.RGBA{120, 120, 128, 255})
+	fill(62, 185, 162, 290, color.RGBA{120, 120, 128, 255})
+
+	ink := color.RGBA{50, 50, 60, 255}
+	for line := 0; line < 6; line++ {
+		y := 88 + line*34
+		fill(220, y, 220+60+r.Intn(40), y+6, color.RGBA{140, 140, 150, 255})
+		fill(220, y+12, 220+120+r.Intn(260), y+22, ink)
+	}
+	for row := 0; row < 2; row++ {
+		y := 330 + row*34
+		for x := 32; x < width-32; x += 14 {
+			fill(x, y, x+10, y+20, ink)
+		}
+	}
+	fill(0, height/2-18, width, height/2+18, color.RGBA{200, 30, 40, 200})
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		panic(err) // Encoding an in-memory RGBA image cannot fail
+	}
+	return out.Bytes()
+}
+
+// utilityBill writes a one page PDF bill addressed to the person
+func utilityBill(p person) []byte {
+	lines := []string{
+		"SAMPLE DOCUMENT - NOT A REAL BILL",
+		"",
+		"Example Energy Ltd",
+		"Statement of account",
+		"",
+		p.FirstName + " " + p.LastName,
+		p.Address.Line1,
+		p.Address.City + " " + p.Address.PostalCode,
+		p.Address.Country,
+		"",
+		"Amount due: 42.00",
+	}
+	var text strings.Builder
+	text.WriteString("BT /F1 14 Tf 72 740 Td 18 TL\n")
+	for _, line := range lines {
+		fmt.Fprintf(&text, "(%s) Tj T*\n", pdfEscape(line))
+	}
+	text.WriteString("ET")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", text.Len(), text.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape escapes the characters with a meaning inside a PDF string
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s)
+}
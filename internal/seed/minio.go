@@ -0,0 +1,60 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// MinIOStore keeps files in a MinIO bucket, or any other S3-compatible server
+type MinIOStore struct {
+	client *s3.Client
+	bucket string
+	region string
+}
+
+// NewMinIOStore connects to the server at endpoint, creating the bucket if it does not exist
+func NewMinIOStore(ctx context.Context, endpoint, region, bucket, accessKey, secretKey string) (*MinIOStore, error) {
+	cfg, err := awsConfig.LoadDefaultConfig(ctx,
+		awsConfig.WithRegion(region),
+		awsConfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: accessKey, SecretAccessKey: secretKey}, nil
+		})),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not load S3 configuration: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = true // MinIO serves buckets by path rather than by host name
+	})
+
+	_, err = client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+	var owned *types.BucketAlreadyOwnedByYou
+	var exists *types.BucketAlreadyExists
+	if err != nil && !errors.As(err, &owned) && !errors.As(err, &exists) {
+		return nil, fmt.Errorf("could not create bucket %s: %w", bucket, err)
+	}
+	return &MinIOStore{client: client, bucket: bucket, region: region}, nil
+}
+
+// Put stores a file. The URL returned has the form the API records for uploads to S3, so
+// the object key is read from it the same way.
+func (m *MinIOStore) Put(ctx context.Context, key, contentType string, content []byte) (string, error) {
+	_, err := m.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(m.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", m.bucket, m.region, key), nil
+}
@@ -0,0 +1,87 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+)
+
+var (
+	firstNames = []string{"Alex", "Amara", "Ben", "Chen", "Dana", "Elif", "Farid", "Grace", "Hugo", "Ines", "Jonas", "Kemi", "Lena", "Mateo", "Nora", "Omar", "Priya", "Quinn", "Rosa", "Sami", "Tara", "Uma", "Viktor", "Wen", "Yara", "Zoe"}
+	lastNames  = []string{"Abara", "Berger", "Castillo", "Dubois", "Eriksen", "Fischer", "Garcia", "Haddad", "Ito", "Jensen", "Kowalski", "Larsen", "Moreau", "Novak", "Okafor", "Petrov", "Quint", "Rossi", "Santos", "Tanaka", "Usman", "Varga", "Weber", "Yilmaz", "Zhou"}
+	streets    = []string{"Sample Street", "Example Road", "Test Avenue", "Placeholder Lane", "Demo Way", "Fixture Close"}
+	levels     = []string{"basic", "standard", "enhanced"}
+)
+
+// Cities of seeded addresses with their ISO 3166 country codes
+var cities = []struct {
+	City, Country string
+}{
+	{"London", "GB"}, {"Manchester", "GB"}, {"New York", "US"}, {"Austin", "US"}, {"Paris", "FR"},
+	{"Lyon", "FR"}, {"Berlin", "DE"}, {"Madrid", "ES"}, {"Lisbon", "PT"}, {"Toronto", "CA"},
+}
+
+// statusShare is an applicant status and its share of seeded applicants
+type statusShare struct {
+	Status localModels.ApplicantStatus
+	Weight int
+}
+
+var statuses = []statusShare{
+	{localModels.ApplicantPending, 25},
+	{localModels.ApplicantInReview, 25},
+	{localModels.ApplicantResubmissionRequired, 10},
+	{localModels.ApplicantApproved, 30},
+	{localModels.ApplicantRejected, 10},
+}
+
+// person is a made-up identity
+type person struct {
+	FirstName, LastName string
+	Email, Phone        string
+	DOB                 string
+	Address             models.RawAddress
+}
+
+func newPerson(r *rand.Rand) person {
+	first, last := pick(r, firstNames), pick(r, lastNames)
+	city := cities[r.Intn(len(cities))]
+	dob := time.Date(1950, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, r.Intn(55*365))
+	return person{
+		FirstName: first,
+		LastName:  last,
+		Email:     fmt.Sprintf("%s.%s.%04d@example.com", strings.ToLower(first), strings.ToLower(last), r.Intn(10000)),
+		// 555-0100 to 555-0199 is reserved for fiction in every North American area code
+		Phone: fmt.Sprintf("+1%03d55501%02d", 201+r.Intn(780), r.Intn(100)),
+		DOB:   dob.Format(time.DateOnly),
+		Address: models.RawAddress{
+			Line1:      fmt.Sprintf("%d %s", 1+r.Intn(200), pick(r, streets)),
+			City:       city.City,
+			PostalCode: fmt.Sprintf("%05d", r.Intn(100000)),
+			Country:    city.Country,
+		},
+	}
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.Intn(len(values))]
+}
+
+func weighted(r *rand.Rand, choices []statusShare) localModels.ApplicantStatus {
+	total := 0
+	for _, choice := range choices {
+		total += choice.Weight
+	}
+	n := r.Intn(total)
+	for _, choice := range choices {
+		if n < choice.Weight {
+			return choice.Status
+		}
+		n -= choice.Weight
+	}
+	return choices[len(choices)-1].Status
+}
@@ -0,0 +1,227 @@
+// Package seed fills a development database with synthetic applicants and documents, so
+// development and demos never need production data. Every name, email, phone number and
+// address is made up: emails use example.com, phone numbers the 555-01xx range reserved
+// for fiction, and each file is marked as a sample.
+//
+// A seeded run is reproducible: the same random seed produces the same records and IDs,
+// and records already present are left as they are.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/interfaces"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tag marks seeded applicants among a client's annotations, so they can be found and removed
+const Tag = "seed"
+
+// KeyPrefix starts the object key of every seeded file
+const KeyPrefix = "seed/"
+
+// FileStore keeps the generated document files
+type FileStore interface {
+	// Put stores content under key and returns the file's URL, as recorded on the document
+	Put(ctx context.Context, key, contentType string, content []byte) (string, error)
+}
+
+// Seeder writes synthetic applicants and their documents
+type Seeder struct {
+	Applicants           common.CollectionInterface
+	Documents            common.CollectionInterface
+	ApplicantsCollection string
+	DocumentsCollection  string
+	Files                FileStore
+	KMSUploader          interfaces.KMSUploader // Encrypts each applicant's date of birth and address, as the API does
+	Rand                 *rand.Rand
+	Now                  time.Time
+}
+
+// Result counts what a run wrote
+type Result struct {
+	Applicants int
+	Documents  int
+	Statuses   map[localModels.ApplicantStatus]int
+}
+
+// applicantRecord is an applicant as stored, with the review state the review queue and
+// decisions keep on it
+type applicantRecord struct {
+	localModels.ApplicantRecord `bson:",inline"`
+	Status                      localModels.ApplicantStatus `bson:"status"`
+	Review                      *localModels.Review         `bson:"review,omitempty"`
+}
+
+// Run seeds count applicants for a client
+func (s *Seeder) Run(ctx context.Context, clientID string, count int) (Result, error) {
+	result := Result{Statuses: map[localModels.ApplicantStatus]int{}}
+	for i := 0; i < count; i++ {
+		person := newPerson(s.Rand)
+		applicant, err := s.applicant(ctx, clientID, person)
+		if err != nil {
+			return result, err
+		}
+		documents, err := s.documents(ctx, applicant, person)
+		if err != nil {
+			return result, err
+		}
+
+		if err := mongoschema.ValidateInsert(s.ApplicantsCollection, applicant); err != nil {
+			return result, err
+		}
+		key := bson.M{"applicant_id": applicant.ApplicantID}
+		if err := mongoretry.InsertOnce(ctx, s.Applicants, "seed_applicant", key, applicant); err != nil {
+			return result, fmt.Errorf("failed to insert applicant: %w", err)
+		}
+		for _, document := range documents {
+			if err := mongoschema.ValidateInsert(s.DocumentsCollection, document); err != nil {
+				return result, err
+			}
+			key := bson.M{"document_id": document.DocumentID}
+			if err := mongoretry.InsertOnce(ctx, s.Documents, "seed_document", key, document); err != nil {
+				return result, fmt.Errorf("failed to insert document: %w", err)
+			}
+		}
+		result.Applicants++
+		result.Documents += len(documents)
+		result.Statuses[applicant.Status]++
+	}
+	return result, nil
+}
+
+// applicant builds an applicant created up to 90 days ago, in a status weighted towards
+// those seen most often
+func (s *Seeder) applicant(ctx context.Context, clientID string, person person) (applicantRecord, error) {
+	plaintextKey, encryptedKey, err := s.KMSUploader.GenerateDataKey(clientconfig.WithClientID(ctx, clientID))
+	if err != nil {
+		return applicantRecord{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	encryptedDOB, err := utils.EncryptField(person.DOB, plaintextKey)
+	if err != nil {
+		return applicantRecord{}, fmt.Errorf("failed to encrypt date of birth: %w", err)
+	}
+	encryptedAddress, err := utils.EncryptAddress(person.Address, plaintextKey)
+	if err != nil {
+		return applicantRecord{}, fmt.Errorf("failed to encrypt address: %w", err)
+	}
+
+	createdAt := s.Now.Add(-time.Duration(s.Rand.Int63n(int64(90 * 24 * time.Hour)))).Truncate(time.Second)
+	updatedAt := createdAt.Add(time.Duration(s.Rand.Int63n(int64(s.Now.Sub(createdAt)) + 1))).Truncate(time.Second)
+	applicant := applicantRecord{
+		ApplicantRecord: localModels.ApplicantRecord{
+			Applicant: models.Applicant{
+				ApplicantID:       s.id(ids.Applicant),
+				ClientID:          clientID,
+				FirstName:         person.FirstName,
+				LastName:          person.LastName,
+				Email:             person.Email,
+				Phone:             person.Phone,
+				VerificationLevel: pick(s.Rand, levels),
+				EncryptedData:     models.EncryptedData{DOB: encryptedDOB, Address: encryptedAddress, EncryptedKey: encryptedKey},
+				CreatedAt:         createdAt,
+				UpdatedAt:         updatedAt,
+			},
+			Annotations: &localModels.Annotations{Tags: []string{Tag}, Metadata: map[string]string{"country": person.Address.Country}},
+		},
+		Status: weighted(s.Rand, statuses),
+	}
+
+	switch applicant.Status {
+	case localModels.ApplicantInReview, localModels.ApplicantResubmissionRequired:
+		applicant.Review = &localModels.Review{EnteredAt: &updatedAt}
+	case localModels.ApplicantApproved, localModels.ApplicantRejected:
+		reviewer := "seed-reviewer"
+		decision := localModels.DecisionApprove
+		if applicant.Status == localModels.ApplicantRejected {
+			decision = localModels.DecisionReject
+		}
+		applicant.Review = &localModels.Review{
+			DecidedBy:  &reviewer,
+			DecidedAt:  &updatedAt,
+			ReasonCode: pick(s.Rand, localModels.ReasonCodes(decision)),
+		}
+	}
+	return applicant, nil
+}
+
+// documents builds an applicant's passport scan and, for some, a proof of address,
+// storing a generated file for each
+func (s *Seeder) documents(ctx context.Context, applicant applicantRecord, person person) ([]localModels.DocumentRecord, error) {
+	types := []models.DocumentType{models.DocumentPassport}
+	if s.Rand.Intn(2) == 0 {
+		types = append(types, models.DocumentUtilityBill)
+	}
+	status := models.DocumentUploaded
+	if applicant.Status == localModels.ApplicantApproved {
+		status = models.DocumentVerified
+	}
+
+	var documents []localModels.DocumentRecord
+	for _, documentType := range types {
+		documentID := s.id(ids.Document)
+		var content []byte
+		var contentType, extension string
+		if documentType == models.DocumentPassport {
+			content, contentType, extension = passportImage(s.Rand, person), "image/png", ".png"
+		} else {
+			content, contentType, extension = utilityBill(person), "application/pdf", ".pdf"
+		}
+		key := KeyPrefix + applicant.ClientID + "/" + applicant.ApplicantID + "/" + documentID + extension
+		fileURL, err := s.Files.Put(ctx, key, contentType, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", key, err)
+		}
+
+		uploadedAt := applicant.CreatedAt
+		documents = append(documents, localModels.DocumentRecord{
+			Document: models.Document{
+				DocumentID:   documentID,
+				ApplicantID:  applicant.ApplicantID,
+				DocumentType: documentType,
+				Country:      person.Address.Country,
+				FileURL:      fileURL,
+				FileSize:     int64(len(content)),
+				Status:       status,
+				CreatedAt:    uploadedAt,
+				UpdatedAt:    applicant.UpdatedAt,
+			},
+			ClientID: applicant.ClientID,
+			Upload:   &localModels.StorageUpload{State: localModels.UploadStored, Attempts: 1, LastAttemptAt: &uploadedAt},
+		})
+	}
+	return documents, nil
+}
+
+// id makes an ID of the kind from the run's random source, so a repeated run makes the same IDs
+func (s *Seeder) id(kind ids.Kind) string {
+	id, err := uuid.NewRandomFromReader(s.Rand)
+	if err != nil {
+		return kind.New()
+	}
+	return kind.Format(id)
+}
+
+// ApplicantFilter matches the applicants seeded for a client
+func ApplicantFilter(clientID string) bson.M {
+	return bson.M{"client_id": clientID, "annotations.tags": Tag}
+}
+
+// DocumentFilter matches the documents seeded for a client, by the key of their file
+func DocumentFilter(clientID string) bson.M {
+	return bson.M{"client_id": clientID, "file_url": primitive.Regex{Pattern: "/" + regexp.QuoteMeta(KeyPrefix+clientID+"/")}}
+}
@@ -0,0 +1,132 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeCollection records the documents inserted through it
+type fakeCollection struct {
+	common.CollectionInterface
+	inserted []bson.M
+}
+
+func (f *fakeCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	raw, err := bson.Marshal(update.(bson.M)["$setOnInsert"])
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	f.inserted = append(f.inserted, doc)
+	return &mongo.UpdateResult{UpsertedCount: 1}, nil
+}
+
+// fakeStore keeps files in memory
+type fakeStore struct {
+	files map[string][]byte
+}
+
+func (f *fakeStore) Put(ctx context.Context, key, contentType string, content []byte) (string, error) {
+	f.files[key] = content
+	return "https://bucket.s3.us-east-1.amazonaws.com/" + key, nil
+}
+
+type fakeKMS struct{}
+
+func (fakeKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	return key, append([]byte("wrapped:"), key...), nil
+}
+
+func (fakeKMS) EncryptData(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return append([]byte("wrapped:"), plaintext...), nil
+}
+
+func (fakeKMS) DecryptData(ctx context.Context, encrypted []byte) ([]byte, error) {
+	return bytes.TrimPrefix(encrypted, []byte("wrapped:")), nil
+}
+
+func newSeeder(seed int64) (*Seeder, *fakeCollection, *fakeCollection, *fakeStore) {
+	applicants, documents := &fakeCollection{}, &fakeCollection{}
+	files := &fakeStore{files: map[string][]byte{}}
+	return &Seeder{
+		Applicants:           applicants,
+		Documents:            documents,
+		ApplicantsCollection: "applicants",
+		DocumentsCollection:  "documents",
+		Files:                files,
+		KMSUploader:          fakeKMS{},
+		Rand:                 rand.New(rand.NewSource(seed)),
+		Now:                  time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC),
+	}, applicants, documents, files
+}
+
+func TestRun(t *testing.T) {
+	seeder, applicants, documents, files := newSeeder(1)
+	result, err := seeder.Run(context.Background(), "client1", 40)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 40, result.Applicants)
+	assert.Len(t, applicants.inserted, 40)
+	assert.Len(t, documents.inserted, result.Documents)
+	assert.Len(t, files.files, result.Documents)
+	assert.Greater(t, len(result.Statuses), 1, "applicants should be spread over statuses")
+
+	for _, applicant := range applicants.inserted {
+		assert.Equal(t, "client1", applicant["client_id"])
+		assert.True(t, strings.HasSuffix(applicant["email"].(string), "@example.com"))
+		assert.Contains(t, applicant["phone"], "55501")
+		assert.Equal(t, bson.A{Tag}, applicant["annotations"].(bson.M)["tags"])
+		status, err := localModels.ApplicantStatusFromCore(models.ApplicantStatus(applicant["status"].(int32)))
+		assert.NoError(t, err, "statuses are stored as core's ints")
+		if status == localModels.ApplicantApproved || status == localModels.ApplicantRejected {
+			assert.NotEmpty(t, applicant["review"].(bson.M)["reason_code"])
+		}
+	}
+	for key, content := range files.files {
+		assert.True(t, strings.HasPrefix(key, "seed/client1/"))
+		switch {
+		case strings.HasSuffix(key, ".png"):
+			_, err := png.Decode(bytes.NewReader(content))
+			assert.NoError(t, err, key)
+		case strings.HasSuffix(key, ".pdf"):
+			assert.True(t, bytes.HasPrefix(content, []byte("%PDF-")), key)
+			assert.True(t, bytes.HasSuffix(content, []byte("%%EOF\n")), key)
+			assert.Contains(t, string(content), "SAMPLE DOCUMENT")
+		default:
+			t.Errorf("unexpected file %s", key)
+		}
+	}
+}
+
+func TestRunIsReproducible(t *testing.T) {
+	ids := func(seed int64) []interface{} {
+		seeder, applicants, _, _ := newSeeder(seed)
+		_, err := seeder.Run(context.Background(), "client1", 5)
+		assert.NoError(t, err)
+		var ids []interface{}
+		for _, applicant := range applicants.inserted {
+			ids = append(ids, applicant["applicant_id"])
+		}
+		return ids
+	}
+
+	assert.Equal(t, ids(7), ids(7))
+	assert.NotEqual(t, ids(7), ids(8))
+}
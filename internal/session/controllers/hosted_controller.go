@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apiversion"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	documentControllers "github.com/rachel-lawrie/verus_app_backend/internal/document/controllers"
+	documentServices "github.com/rachel-lawrie/verus_app_backend/internal/document/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/dto"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/session/services"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// sessionKey and channelKey are the context keys of the session a hosted request was
+	// authenticated with, and the channel its token was handed over by
+	sessionKey = "verification_session"
+	channelKey = "verification_session_channel"
+
+	// SessionProtocol is the WebSocket subprotocol a hosted page offers alongside its
+	// session token, as browsers cannot set headers on WebSocket connections
+	SessionProtocol = "verus.session"
+
+	defaultCodeSize = 256
+
+	// eventWriteTimeout is how long an event may take to reach a watching page before the
+	// connection is given up on
+	eventWriteTimeout = 10 * time.Second
+)
+
+// Authenticate returns middleware that authenticates the hosted page's requests with the
+// session token sent as a Bearer token, acting as the session's client for its applicant
+func Authenticate(service interfaces.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok && c.IsWebsocket() {
+			// Browsers cannot set headers on WebSocket connections, which offer the token as a
+			// subprotocol instead. Query strings are kept out of logs, but also end up in
+			// proxies' and browsers' histories.
+			token, ok = protocolToken(c.GetHeader("Sec-WebSocket-Protocol"))
+		}
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session token is required"})
+			return
+		}
+
+		session, channel, err := service.Authenticate(c.Request.Context(), token)
+		switch {
+		case errors.Is(err, services.ErrInvalidToken):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session token"})
+			return
+		case err != nil:
+			zaplogger.GetLogger().Error("Error authenticating verification session", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not verify session"})
+			return
+		}
+
+		c.Set("client_id", session.ClientID)
+		c.Set(sessionKey, session)
+		c.Set(channelKey, channel)
+		c.Next()
+	}
+}
+
+// protocolToken finds the session token among the subprotocols a WebSocket connection
+// offers, which must include SessionProtocol
+func protocolToken(header string) (string, bool) {
+	var token string
+	offered := false
+	for _, protocol := range strings.Split(header, ",") {
+		switch protocol = strings.TrimSpace(protocol); {
+		case protocol == SessionProtocol:
+			offered = true
+		case token == "":
+			token = protocol
+		}
+	}
+	return token, offered
+}
+
+// sessionFromContext returns the session set by Authenticate
+func sessionFromContext(c *gin.Context) localModels.VerificationSession {
+	session, _ := c.Get(sessionKey)
+	return session.(localModels.VerificationSession)
+}
+
+// channelFromContext returns the channel set by Authenticate
+func channelFromContext(c *gin.Context) localModels.SessionChannel {
+	channel, _ := c.Get(channelKey)
+	return channel.(localModels.SessionChannel)
+}
+
+// GetHostedSession is the handler function for the hosted page loading its session,
+// which marks the session opened
+func GetHostedSession(c *gin.Context, service interfaces.SessionService) {
+	session, err := service.OpenSession(c.Request.Context(), sessionFromContext(c))
+	switch {
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		zaplogger.GetLogger().Error("Error opening verification session", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not open session"})
+	default:
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// UploadHostedDocument is the handler function for the applicant uploading a document
+// through a session. The form is that of a document upload, without the applicant, which
+// is always the session's.
+func UploadHostedDocument(c *gin.Context, service interfaces.SessionService, documents interfaces.DocumentService) {
+	session := sessionFromContext(c)
+	if session.StatusAt(timestamp.Now()) == localModels.SessionCompleted {
+		apperr.Respond(c, services.ErrSessionClosed)
+		return
+	}
+	if err := documentServices.ParseUploadForm(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Request.Form.Set("applicant_id", session.ApplicantID)
+
+	result, err := documents.UploadDocument(c, documentControllers.DocumentCollection())
+	switch {
+	case mongoretry.RespondUnavailable(c, err), documentControllers.RespondUploadLimit(c, err):
+		return
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The document is stored either way, so a failure here only loses it from the session's progress
+	if err := service.RecordSubmission(c.Request.Context(), session, result.DocumentID); err != nil {
+		zaplogger.GetLogger().Error("Error recording session submission", zap.Error(err), zap.String("sessionID", session.SessionID), zap.String("documentID", result.DocumentID))
+	}
+
+	status := http.StatusOK
+	if result.ProcessingStatus == localModels.ProcessingScanPending || result.ProcessingStatus == localModels.ProcessingStoragePending {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, dto.UploadResponse(apiversion.FromContext(c), result))
+}
+
+// CompleteHostedSession is the handler function for the applicant finishing a session,
+// after which it accepts no more documents
+func CompleteHostedSession(c *gin.Context, service interfaces.SessionService) {
+	session, err := service.CompleteSession(c.Request.Context(), sessionFromContext(c), channelFromContext(c))
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		zaplogger.GetLogger().Error("Error completing verification session", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not complete session"})
+	default:
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// GetHandoffCode is the handler function for the hosted page showing a QR code that opens
+// the session on the applicant's phone, sized with ?size= in pixels
+func GetHandoffCode(c *gin.Context, service interfaces.SessionService) {
+	size := defaultCodeSize
+	if sizeParam := c.Query("size"); sizeParam != "" {
+		parsed, err := strconv.Atoi(sizeParam)
+		if err != nil || parsed < services.MinCodeSize || parsed > services.MaxCodeSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between " + strconv.Itoa(services.MinCodeSize) + " and " + strconv.Itoa(services.MaxCodeSize)})
+			return
+		}
+		size = parsed
+	}
+
+	png, err := service.HandoffCode(c.Request.Context(), sessionFromContext(c), size)
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		zaplogger.GetLogger().Error("Error creating handoff QR code", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create QR code"})
+	default:
+		// The code holds a token for the session, so it must not be kept by caches
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "image/png", png)
+	}
+}
+
+// WatchHostedSession is the handler function for the hosted page following the session's
+// progress over a WebSocket, which is sent each event as a JSON message until the session
+// expires or the page disconnects
+func WatchHostedSession(c *gin.Context, service interfaces.SessionService) {
+	if !c.IsWebsocket() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected a WebSocket upgrade"})
+		return
+	}
+	session := sessionFromContext(c)
+	server := websocket.Server{
+		// The session token authenticates the connection, so pages on any origin may open it.
+		// Browsers only accept the connection if one of the subprotocols they offered is chosen.
+		Handshake: func(config *websocket.Config, _ *http.Request) error {
+			config.Protocol = []string{SessionProtocol}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			streamSessionEvents(ws, service, session)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// streamSessionEvents writes a session's events to a WebSocket until the session expires
+// or the connection closes
+func streamSessionEvents(ws *websocket.Conn, service interfaces.SessionService, session localModels.VerificationSession) {
+	events, stop := service.WatchSession(session)
+	defer stop()
+
+	// The page sends nothing, so a failed read is the connection closing
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	expiry := time.NewTimer(session.ExpiresAt.Sub(timestamp.Now()))
+	defer expiry.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			ws.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				zaplogger.GetLogger().Info("Stopped streaming session events", zap.Error(err), zap.String("sessionID", session.SessionID))
+				return
+			}
+		case <-expiry.C:
+			return
+		case <-closed:
+			return
+		}
+	}
+}
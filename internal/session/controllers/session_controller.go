@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+type sessionRequest struct {
+	TTLSeconds int64 `json:"ttl_seconds"`
+}
+
+// CreateSession is the handler function for starting a hosted verification session for
+// an applicant. The response holds the token and link the client hands to the applicant,
+// which cannot be retrieved again.
+func CreateSession(c *gin.Context, service interfaces.SessionService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request sessionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl_seconds must be a whole number of seconds"})
+			return
+		}
+	}
+	if request.TTLSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ttl_seconds must be a whole number of seconds"})
+		return
+	}
+
+	link, err := service.CreateSession(c.Request.Context(), clientID, c.Param("id"), time.Duration(request.TTLSeconds)*time.Second)
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		zaplogger.GetLogger().Error("Error creating verification session", zap.Error(err), zap.String("applicantID", c.Param("id")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create session"})
+	default:
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusCreated, link)
+	}
+}
+
+// GetSession is the handler function for following the progress of an applicant's session
+func GetSession(c *gin.Context, service interfaces.SessionService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := service.GetSession(c.Request.Context(), clientID, c.Param("id"), c.Param("sessionId"))
+	switch {
+	case apperr.Respond(c, err):
+	case err != nil:
+		zaplogger.GetLogger().Error("Error fetching verification session", zap.Error(err), zap.String("sessionID", c.Param("sessionId")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve session"})
+	default:
+		c.JSON(http.StatusOK, session)
+	}
+}
@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/session/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// setupSessionRouter registers the client session routes behind a fake login, and the
+// hosted routes behind the session token middleware
+func setupSessionRouter(mockService *localMocks.MockSessionService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	client := router.Group("/v2", func(c *gin.Context) {
+		c.Set("client_id", "client1")
+	})
+	client.POST("/applicants/:id/sessions", func(c *gin.Context) {
+		CreateSession(c, mockService)
+	})
+	client.GET("/applicants/:id/sessions/:sessionId", func(c *gin.Context) {
+		GetSession(c, mockService)
+	})
+
+	hosted := router.Group("/hosted/session", Authenticate(mockService))
+	hosted.GET("", func(c *gin.Context) {
+		GetHostedSession(c, mockService)
+	})
+	hosted.POST("/documents", func(c *gin.Context) {
+		UploadHostedDocument(c, mockService, &localMocks.MockDocumentService{})
+	})
+	hosted.POST("/complete", func(c *gin.Context) {
+		CompleteHostedSession(c, mockService)
+	})
+	hosted.GET("/handoff.png", func(c *gin.Context) {
+		GetHandoffCode(c, mockService)
+	})
+	hosted.GET("/events", func(c *gin.Context) {
+		WatchHostedSession(c, mockService)
+	})
+	return router
+}
+
+func TestCreateSession(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        string
+		expectedTTL        time.Duration
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Default lifetime", expectedStatusCode: http.StatusCreated},
+		{name: "Lifetime given", requestBody: `{"ttl_seconds": 600}`, expectedTTL: 10 * time.Minute, expectedStatusCode: http.StatusCreated},
+		{name: "Negative lifetime", requestBody: `{"ttl_seconds": -1}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Lifetime not a number", requestBody: `{"ttl_seconds": "1h"}`, expectedStatusCode: http.StatusBadRequest},
+		{
+			name:               "Lifetime too long",
+			requestBody:        `{"ttl_seconds": 99999999}`,
+			expectedTTL:        99999999 * time.Second,
+			serviceErr:         fmt.Errorf("%w: ttl_seconds must be between 60 and 604800", services.ErrInvalidTTL),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{name: "Unknown applicant", serviceErr: apperr.ErrApplicantNotFound, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockSessionService)
+			router := setupSessionRouter(mockService)
+
+			link := localModels.SessionLink{
+				VerificationSession: localModels.VerificationSession{SessionID: "session1", ClientID: "client1", ApplicantID: "app1", Status: localModels.SessionCreated},
+				Token:               "vs_session1.1700000000.abc",
+			}
+			mockService.On("CreateSession", mock.Anything, "client1", "app1", tt.expectedTTL).Return(link, tt.serviceErr)
+
+			req, _ := http.NewRequest(http.MethodPost, "/v2/applicants/app1/sessions", strings.NewReader(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code, w.Body.String())
+			if tt.expectedStatusCode == http.StatusCreated {
+				var body map[string]interface{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, "vs_session1.1700000000.abc", body["token"])
+				assert.NotContains(t, body, "client_id")
+				assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestHostedSessionRequiresToken(t *testing.T) {
+	mockService := new(localMocks.MockSessionService)
+	router := setupSessionRouter(mockService)
+	mockService.On("Authenticate", mock.Anything, "vs_bad").Return(localModels.VerificationSession{}, localModels.SessionChannel(""), services.ErrInvalidToken)
+
+	for _, header := range []string{"", "Bearer vs_bad"} {
+		req, _ := http.NewRequest(http.MethodGet, "/hosted/session", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, header)
+	}
+	mockService.AssertNotCalled(t, "OpenSession", mock.Anything, mock.Anything)
+}
+
+func TestHostedSession(t *testing.T) {
+	session := localModels.VerificationSession{
+		SessionID:   "session1",
+		ClientID:    "client1",
+		ApplicantID: "app1",
+		Status:      localModels.SessionCompleted,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	t.Run("Completed session takes no more documents", func(t *testing.T) {
+		mockService := new(localMocks.MockSessionService)
+		router := setupSessionRouter(mockService)
+		mockService.On("Authenticate", mock.Anything, "vs_good").Return(session, localModels.ChannelLink, nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/hosted/session/documents", nil)
+		req.Header.Set("Authorization", "Bearer vs_good")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockService.AssertNotCalled(t, "RecordSubmission", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Completing without documents", func(t *testing.T) {
+		opened := session
+		opened.Status = localModels.SessionOpened
+		mockService := new(localMocks.MockSessionService)
+		router := setupSessionRouter(mockService)
+		mockService.On("Authenticate", mock.Anything, "vs_good").Return(opened, localModels.ChannelLink, nil)
+		mockService.On("CompleteSession", mock.Anything, opened, localModels.ChannelLink).Return(localModels.VerificationSession{}, services.ErrNothingSubmitted)
+
+		req, _ := http.NewRequest(http.MethodPost, "/hosted/session/complete", nil)
+		req.Header.Set("Authorization", "Bearer vs_good")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Completing on the phone the session was handed off to", func(t *testing.T) {
+		submitted := session
+		submitted.Status = localModels.SessionSubmitted
+		completed := session
+		completed.CompletedVia = localModels.ChannelQRHandoff
+		mockService := new(localMocks.MockSessionService)
+		router := setupSessionRouter(mockService)
+		mockService.On("Authenticate", mock.Anything, "vs_phone").Return(submitted, localModels.ChannelQRHandoff, nil)
+		mockService.On("CompleteSession", mock.Anything, submitted, localModels.ChannelQRHandoff).Return(completed, nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/hosted/session/complete", nil)
+		req.Header.Set("Authorization", "Bearer vs_phone")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"completed_via":"qr_handoff"`)
+	})
+}
+
+func TestGetHandoffCode(t *testing.T) {
+	session := localModels.VerificationSession{SessionID: "session1", ClientID: "client1", ApplicantID: "app1", Status: localModels.SessionOpened}
+	tests := []struct {
+		name               string
+		query              string
+		expectedSize       int
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Default size", expectedSize: 256, expectedStatusCode: http.StatusOK},
+		{name: "Size given", query: "?size=512", expectedSize: 512, expectedStatusCode: http.StatusOK},
+		{name: "Size too small", query: "?size=16", expectedStatusCode: http.StatusBadRequest},
+		{name: "Size not a number", query: "?size=big", expectedStatusCode: http.StatusBadRequest},
+		{name: "No hosted page", expectedSize: 256, serviceErr: services.ErrNoHostedPage, expectedStatusCode: http.StatusNotFound},
+		{name: "Completed session", expectedSize: 256, serviceErr: services.ErrSessionClosed, expectedStatusCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockSessionService)
+			router := setupSessionRouter(mockService)
+			mockService.On("Authenticate", mock.Anything, "vs_good").Return(session, localModels.ChannelLink, nil)
+			mockService.On("HandoffCode", mock.Anything, session, tt.expectedSize).Return([]byte("\x89PNG"), tt.serviceErr)
+
+			req, _ := http.NewRequest(http.MethodGet, "/hosted/session/handoff.png"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer vs_good")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code, w.Body.String())
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+				assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestWatchHostedSession(t *testing.T) {
+	session := localModels.VerificationSession{SessionID: "session1", ClientID: "client1", ApplicantID: "app1", Status: localModels.SessionOpened, ExpiresAt: time.Now().Add(time.Hour)}
+
+	t.Run("Without a WebSocket upgrade", func(t *testing.T) {
+		mockService := new(localMocks.MockSessionService)
+		router := setupSessionRouter(mockService)
+		mockService.On("Authenticate", mock.Anything, "vs_good").Return(session, localModels.ChannelLink, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/hosted/session/events", nil)
+		req.Header.Set("Authorization", "Bearer vs_good")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "WatchSession", mock.Anything)
+	})
+
+	t.Run("The token is not read from the query string", func(t *testing.T) {
+		mockService := new(localMocks.MockSessionService)
+		server := httptest.NewServer(setupSessionRouter(mockService))
+		defer server.Close()
+
+		_, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/hosted/session/events?session=vs_good", "", server.URL)
+		assert.Error(t, err)
+		mockService.AssertNotCalled(t, "Authenticate", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Events are sent as they happen", func(t *testing.T) {
+		mockService := new(localMocks.MockSessionService)
+		server := httptest.NewServer(setupSessionRouter(mockService))
+		defer server.Close()
+
+		events := make(chan localModels.SessionEvent, 1)
+		stopped := make(chan struct{})
+		mockService.On("Authenticate", mock.Anything, "vs_good").Return(session, localModels.ChannelLink, nil)
+		mockService.On("WatchSession", session).Return((<-chan localModels.SessionEvent)(events), func() { close(stopped) })
+		events <- localModels.SessionEvent{Type: localModels.SessionDocumentReceived, ClientID: "client1", ApplicantID: "app1", SessionID: "session1", DocumentID: "doc1"}
+
+		// Browsers cannot set headers on WebSockets, so the token is offered as a subprotocol
+		config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/hosted/session/events", server.URL)
+		require.NoError(t, err)
+		config.Protocol = []string{SessionProtocol, "vs_good"}
+		ws, err := websocket.DialConfig(config)
+		require.NoError(t, err)
+		assert.Equal(t, []string{SessionProtocol}, ws.Config().Protocol)
+
+		var event map[string]interface{}
+		require.NoError(t, websocket.JSON.Receive(ws, &event))
+		assert.Equal(t, "document_received", event["type"])
+		assert.Equal(t, "doc1", event["document_id"])
+		assert.NotContains(t, event, "client_id")
+
+		ws.Close()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch was not stopped when the page disconnected")
+		}
+	})
+}
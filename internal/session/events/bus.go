@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"github.com/redis/go-redis/v9"
+	zap "go.uber.org/zap"
+)
+
+const (
+	// DefaultChannel is the Redis channel events are published on when none is configured
+	DefaultChannel = "verus:session_events"
+	// ReconnectInterval is how long a replica waits to subscribe again after losing Redis
+	ReconnectInterval = 5 * time.Second
+)
+
+// message is an event as published to Redis, with the client it is scoped to, which the
+// event's JSON leaves out
+type message struct {
+	ClientID string                   `json:"client_id"`
+	Event    localModels.SessionEvent `json:"event"`
+}
+
+// Bus carries session events to the hosted pages watching them. Without Redis events
+// only reach pages connected to the replica they were published on; with it every
+// replica publishes to one channel and hands what it receives to its own pages.
+type Bus struct {
+	hub     *hub
+	redis   *redis.Client
+	channel string
+}
+
+// NewBus creates a bus for a single replica
+func NewBus() *Bus {
+	return &Bus{hub: newHub()}
+}
+
+// NewRedisBus creates a bus that fans events out across replicas through a Redis channel,
+// or DefaultChannel when empty. Events are only received while Listen runs.
+func NewRedisBus(client *redis.Client, channel string) *Bus {
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	return &Bus{hub: newHub(), redis: client, channel: channel}
+}
+
+// Publish sends an event to the pages watching its applicant, on whichever replica
+func (b *Bus) Publish(ctx context.Context, event localModels.SessionEvent) error {
+	if b.redis == nil {
+		b.hub.dispatch(event)
+		return nil
+	}
+	payload, err := json.Marshal(message{ClientID: event.ClientID, Event: event})
+	if err != nil {
+		return fmt.Errorf("failed to encode session event: %w", err)
+	}
+	if err := b.redis.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish to Redis: %w", err)
+	}
+	return nil
+}
+
+// Subscribe returns the events of a client's applicant published from now on, until stop
+// is called
+func (b *Bus) Subscribe(clientID, applicantID string) (<-chan localModels.SessionEvent, func()) {
+	return b.hub.subscribe(clientID, applicantID)
+}
+
+// Listen receives the events published by every replica until ctx is cancelled or the
+// connection to Redis is lost, when it returns an error to be run again
+func (b *Bus) Listen(ctx context.Context) error {
+	if b.redis == nil {
+		return errors.New("session events are not shared through Redis")
+	}
+	subscription := b.redis.Subscribe(ctx, b.channel)
+	defer subscription.Close()
+
+	// Reads wait for a message regardless of ctx, so closing the subscription ends them
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			subscription.Close()
+		case <-done:
+		}
+	}()
+
+	if _, err := subscription.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("failed to subscribe to Redis: %w", err)
+	}
+	for {
+		received, err := subscription.ReceiveMessage(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("lost Redis subscription: %w", err)
+		}
+		var m message
+		if err := json.Unmarshal([]byte(received.Payload), &m); err != nil {
+			zaplogger.GetLogger().Warn("Ignoring malformed session event", zap.Error(err))
+			continue
+		}
+		m.Event.ClientID = m.ClientID
+		b.hub.dispatch(m.Event)
+	}
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/redisclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBus(t *testing.T) {
+	bus := NewBus()
+	events, stop := bus.Subscribe("client1", "app1")
+	other, stopOther := bus.Subscribe("client2", "app1")
+	defer stopOther()
+
+	event := localModels.SessionEvent{Type: localModels.SessionDocumentReceived, ClientID: "client1", ApplicantID: "app1", DocumentID: "doc1"}
+	require.NoError(t, bus.Publish(context.Background(), event))
+	assert.Equal(t, event, <-events)
+	// Applicants are scoped to their client
+	assert.Len(t, other, 0)
+
+	stop()
+	_, open := <-events
+	assert.False(t, open)
+	assert.NoError(t, bus.Publish(context.Background(), event), "publishing with nobody watching")
+}
+
+func TestSlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewBus()
+	events, stop := bus.Subscribe("client1", "app1")
+	defer stop()
+
+	for i := 0; i < subscriberBuffer+5; i++ {
+		require.NoError(t, bus.Publish(context.Background(), localModels.SessionEvent{ClientID: "client1", ApplicantID: "app1"}))
+	}
+	assert.Len(t, events, subscriberBuffer)
+}
+
+func TestRedisBus(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	client, err := redisclient.New("redis://:secret@" + server.Addr())
+	require.NoError(t, err)
+
+	// Two replicas, with a page connected to the second
+	publisher := NewRedisBus(client, "")
+	receiver := NewRedisBus(client, "")
+	events, stop := receiver.Subscribe("client1", "app1")
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	listened := make(chan error)
+	go func() { listened <- receiver.Listen(ctx) }()
+	require.Eventually(t, func() bool { return server.PubSubNumSub(DefaultChannel)[DefaultChannel] > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	event := localModels.SessionEvent{
+		Type: localModels.SessionVerificationProgress, ClientID: "client1", ApplicantID: "app1", DocumentID: "doc1",
+		Stage: localModels.UploadJobStoring, OccurredAt: time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC),
+	}
+	require.NoError(t, publisher.Publish(context.Background(), event))
+	select {
+	case received := <-events:
+		assert.Equal(t, event, received, "the client survives the trip through Redis")
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not received through Redis")
+	}
+
+	cancel()
+	assert.NoError(t, <-listened)
+}
+
+func TestRedisBusWrongPassword(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	client, err := redisclient.New("redis://:wrong@" + server.Addr())
+	require.NoError(t, err)
+	bus := NewRedisBus(client, "")
+
+	err = bus.Publish(context.Background(), localModels.SessionEvent{})
+	assert.ErrorContains(t, err, "WRONGPASS")
+	assert.Error(t, bus.Listen(context.Background()))
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"sync"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	zap "go.uber.org/zap"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind by before
+// further events to it are dropped
+const subscriberBuffer = 16
+
+// hub hands events to the subscribers connected to this replica, by applicant
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan localModels.SessionEvent]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subscribers: make(map[string]map[chan localModels.SessionEvent]struct{})}
+}
+
+// subscriberKey scopes an applicant's subscribers to its client
+func subscriberKey(clientID, applicantID string) string {
+	return clientID + "/" + applicantID
+}
+
+// subscribe returns the events of a client's applicant, until stop is called
+func (h *hub) subscribe(clientID, applicantID string) (<-chan localModels.SessionEvent, func()) {
+	key := subscriberKey(clientID, applicantID)
+	ch := make(chan localModels.SessionEvent, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[key] == nil {
+		h.subscribers[key] = make(map[chan localModels.SessionEvent]struct{})
+	}
+	h.subscribers[key][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subscribers[key], ch)
+			if len(h.subscribers[key]) == 0 {
+				delete(h.subscribers, key)
+			}
+			close(ch)
+		})
+	}
+	return ch, stop
+}
+
+// dispatch hands an event to the subscribers of its applicant, without waiting on any of them
+func (h *hub) dispatch(event localModels.SessionEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[subscriberKey(event.ClientID, event.ApplicantID)] {
+		select {
+		case ch <- event:
+		default:
+			zaplogger.GetLogger().Warn("Dropping session event for a slow subscriber", zap.String("applicantID", event.ApplicantID), zap.String("type", string(event.Type)))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	zap "go.uber.org/zap"
+)
+
+// ProgressStore records upload progress in a store and publishes each step to the hosted
+// pages watching the upload's applicant. Publishing is best effort: the progress stays
+// readable from the store when it fails.
+type ProgressStore struct {
+	localInterfaces.UploadJobStore
+	Bus localInterfaces.SessionEventBus
+}
+
+// NewProgressStore publishes the progress saved to store on bus
+func NewProgressStore(store localInterfaces.UploadJobStore, bus localInterfaces.SessionEventBus) *ProgressStore {
+	return &ProgressStore{UploadJobStore: store, Bus: bus}
+}
+
+func (s *ProgressStore) Save(ctx context.Context, job localModels.UploadJob) error {
+	if err := s.UploadJobStore.Save(ctx, job); err != nil {
+		return err
+	}
+	event := localModels.SessionEvent{
+		Type:             localModels.SessionVerificationProgress,
+		ClientID:         job.ClientID,
+		ApplicantID:      job.ApplicantID,
+		DocumentID:       job.DocumentID,
+		Stage:            job.Stage,
+		ProcessingStatus: job.ProcessingStatus,
+		Error:            job.Error,
+		OccurredAt:       job.UpdatedAt,
+	}
+	if err := s.Bus.Publish(ctx, event); err != nil {
+		zaplogger.GetLogger().Warn("Error publishing upload progress", zap.Error(err), zap.String("jobID", job.JobID))
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/session/events"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+const (
+	DefaultTTL    = time.Hour
+	DefaultMaxTTL = 7 * 24 * time.Hour
+
+	// MinTTL is the shortest session a client may ask for
+	MinTTL = time.Minute
+
+	// MinCodeSize and MaxCodeSize bound the width in pixels of handoff QR codes
+	MinCodeSize = 128
+	MaxCodeSize = 1024
+)
+
+var (
+	// ErrSessionNotFound is returned when the session does not exist or belongs to another applicant
+	ErrSessionNotFound = apperr.NotFound("session_not_found", "session not found")
+	// ErrInvalidTTL is returned when a client asks for a session shorter than MinTTL or longer than MaxTTL
+	ErrInvalidTTL = apperr.BadRequest("invalid_session_ttl", "invalid session lifetime")
+	// ErrInvalidToken is returned for session tokens that were not issued here or have expired
+	ErrInvalidToken = errors.New("invalid or expired session token")
+	// ErrSessionClosed is returned when a completed or expired session is used
+	ErrSessionClosed = apperr.Conflict("session_closed", "session is completed or expired")
+	// ErrNothingSubmitted is returned when a session is completed before any document is uploaded
+	ErrNothingSubmitted = apperr.Conflict("nothing_submitted", "no documents have been uploaded in this session")
+	// ErrNoHostedPage is returned for a handoff QR code when no hosted page is configured for it to open
+	ErrNoHostedPage = apperr.NotFound("no_hosted_page", "no hosted page is configured")
+)
+
+// SessionServiceImpl issues hosted verification sessions and tracks their progress
+type SessionServiceImpl struct {
+	CollectionName          string
+	ApplicantCollectionName string
+	SigningSecret           []byte
+	HostedURL               string // Page that session links open; links are not built when empty
+	TTL                     time.Duration
+	MaxTTL                  time.Duration
+	Events                  localInterfaces.SessionEventBus // Carries progress to the hosted pages watching sessions
+}
+
+var (
+	instance SessionServiceImpl
+	once     sync.Once
+)
+
+func GetSessionServiceImpl() SessionServiceImpl {
+	once.Do(func() {
+		instance = SessionServiceImpl{
+			CollectionName:          localConstants.CollectionVerificationSessions,
+			ApplicantCollectionName: constants.CollectionApplicants,
+			TTL:                     DefaultTTL,
+			MaxTTL:                  DefaultMaxTTL,
+			Events:                  events.NewBus(),
+		}
+	})
+	return instance
+}
+
+// CreateSession starts a session for one of the client's applicants, lasting ttl or the
+// default when zero, and returns it with the token and link to hand to the applicant
+func (s *SessionServiceImpl) CreateSession(ctx context.Context, clientID, applicantID string, ttl time.Duration) (localModels.SessionLink, error) {
+	if ttl == 0 {
+		ttl = s.TTL
+	}
+	if ttl < MinTTL || ttl > s.MaxTTL {
+		return localModels.SessionLink{}, fmt.Errorf("%w: ttl_seconds must be between %d and %d", ErrInvalidTTL, int64(MinTTL/time.Second), int64(s.MaxTTL/time.Second))
+	}
+
+	filter := tenant.Of(clientID).With("applicant_id", applicantID).With("deleted", false)
+	err := tenant.Guard(common.GetCollection(s.ApplicantCollectionName)).FindOne(ctx, filter).Err()
+	if err == mongo.ErrNoDocuments {
+		return localModels.SessionLink{}, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return localModels.SessionLink{}, fmt.Errorf("failed to look up applicant: %w", err)
+	}
+
+	now := timestamp.Now()
+	session := localModels.VerificationSession{
+		SessionID:   ids.New(),
+		ClientID:    clientID,
+		ApplicantID: applicantID,
+		Status:      localModels.SessionCreated,
+		DocumentIDs: []string{},
+		CreatedAt:   now,
+		// Tokens carry whole seconds, so the session ends when its token does
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+	}
+	collection := tenant.Guard(common.GetCollection(s.CollectionName))
+	key := tenant.Of(clientID).With("session_id", session.SessionID)
+	err = mongoretry.Write(ctx, "create_session", func(ctx context.Context) error {
+		return collection.InsertOnce(ctx, key, session)
+	})
+	if err != nil {
+		return localModels.SessionLink{}, err
+	}
+
+	token := signToken(s.SigningSecret, session.SessionID, localModels.ChannelLink, session.ExpiresAt)
+	return localModels.SessionLink{VerificationSession: session, Token: token, URL: s.link(token)}, nil
+}
+
+// GetSession returns one of the sessions of a client's applicant
+func (s *SessionServiceImpl) GetSession(ctx context.Context, clientID, applicantID, sessionID string) (localModels.VerificationSession, error) {
+	return s.findSession(ctx, tenant.Of(clientID).With("session_id", sessionID).With("applicant_id", applicantID))
+}
+
+// Authenticate returns the session a token was issued for and the channel the token was
+// handed over by. Tokens stop working when their session expires, but still open a
+// completed session so its status can be shown.
+func (s *SessionServiceImpl) Authenticate(ctx context.Context, token string) (localModels.VerificationSession, localModels.SessionChannel, error) {
+	if len(s.SigningSecret) == 0 {
+		return localModels.VerificationSession{}, "", ErrInvalidToken
+	}
+	sessionID, channel, err := parseToken(s.SigningSecret, token, timestamp.Now())
+	if err != nil {
+		return localModels.VerificationSession{}, "", err
+	}
+	// The token was signed for this session, which may be any client's
+	session, err := s.findSession(ctx, tenant.AllClients().With("session_id", sessionID))
+	if errors.Is(err, ErrSessionNotFound) {
+		return localModels.VerificationSession{}, "", ErrInvalidToken
+	}
+	return session, channel, err
+}
+
+// HandoffCode returns a PNG QR code, size pixels wide, of a link that opens the session
+// on another device, so an applicant who started on a computer can carry on with their
+// phone's camera. Uploads and completion through the link are told apart from the client's.
+func (s *SessionServiceImpl) HandoffCode(ctx context.Context, session localModels.VerificationSession, size int) ([]byte, error) {
+	if s.HostedURL == "" {
+		return nil, ErrNoHostedPage
+	}
+	now := timestamp.Now()
+	if status := session.StatusAt(now); status == localModels.SessionCompleted || status == localModels.SessionExpired {
+		return nil, ErrSessionClosed
+	}
+
+	token := signToken(s.SigningSecret, session.SessionID, localModels.ChannelQRHandoff, session.ExpiresAt)
+	png, err := qrcode.Encode(s.link(token), qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	filter := tenant.Of(session.ClientID).With("session_id", session.SessionID)
+	update := bson.M{"$min": bson.M{"handed_off_at": now}}
+	err = mongoretry.Write(ctx, "record_session_handoff", func(ctx context.Context) error {
+		_, err := tenant.Guard(common.GetCollection(s.CollectionName)).UpdateOne(ctx, filter, update)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return png, nil
+}
+
+// OpenSession records that the applicant has loaded the hosted page, the first time they do
+func (s *SessionServiceImpl) OpenSession(ctx context.Context, session localModels.VerificationSession) (localModels.VerificationSession, error) {
+	if session.Status != localModels.SessionCreated {
+		return session, nil
+	}
+	now := timestamp.Now()
+	filter := tenant.Of(session.ClientID).With("session_id", session.SessionID).With("status", localModels.SessionCreated)
+	update := bson.M{"$set": bson.M{"status": localModels.SessionOpened, "opened_at": now}}
+	err := mongoretry.Write(ctx, "open_session", func(ctx context.Context) error {
+		_, err := tenant.Guard(common.GetCollection(s.CollectionName)).UpdateOne(ctx, filter, update)
+		return err
+	})
+	if err != nil {
+		return localModels.VerificationSession{}, err
+	}
+	session.Status = localModels.SessionOpened
+	session.OpenedAt = &now
+	return session, nil
+}
+
+// RecordSubmission adds a document uploaded through a session to it, and tells the pages
+// watching the session it was received. The upload is already stored, so failing to tell
+// them is only logged.
+func (s *SessionServiceImpl) RecordSubmission(ctx context.Context, session localModels.VerificationSession, documentID string) error {
+	now := timestamp.Now()
+	filter := tenant.Of(session.ClientID).
+		With("session_id", session.SessionID).
+		With("status", bson.M{"$ne": localModels.SessionCompleted})
+	update := bson.M{
+		"$set":      bson.M{"status": localModels.SessionSubmitted},
+		"$min":      bson.M{"submitted_at": now},
+		"$addToSet": bson.M{"document_ids": documentID},
+	}
+	err := mongoretry.Write(ctx, "record_session_submission", func(ctx context.Context) error {
+		_, err := tenant.Guard(common.GetCollection(s.CollectionName)).UpdateOne(ctx, filter, update)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	event := localModels.SessionEvent{
+		Type:        localModels.SessionDocumentReceived,
+		ClientID:    session.ClientID,
+		ApplicantID: session.ApplicantID,
+		SessionID:   session.SessionID,
+		DocumentID:  documentID,
+		OccurredAt:  now,
+	}
+	if err := s.Events.Publish(ctx, event); err != nil {
+		zaplogger.GetLogger().Warn("Error publishing session event", zap.Error(err), zap.String("sessionID", session.SessionID))
+	}
+	return nil
+}
+
+// CompleteSession records that the applicant has finished uploading, and the channel they
+// finished on, which is also kept on the applicant. No more documents are accepted through
+// the session afterwards.
+func (s *SessionServiceImpl) CompleteSession(ctx context.Context, session localModels.VerificationSession, channel localModels.SessionChannel) (localModels.VerificationSession, error) {
+	now := timestamp.Now()
+	switch session.StatusAt(now) {
+	case localModels.SessionCompleted, localModels.SessionExpired:
+		return localModels.VerificationSession{}, ErrSessionClosed
+	case localModels.SessionCreated, localModels.SessionOpened:
+		return localModels.VerificationSession{}, ErrNothingSubmitted
+	}
+
+	filter := tenant.Of(session.ClientID).
+		With("session_id", session.SessionID).
+		With("status", localModels.SessionSubmitted).
+		With("expires_at", bson.M{"$gt": now})
+	update := bson.M{"$set": bson.M{"status": localModels.SessionCompleted, "completed_at": now, "completed_via": channel}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var completed localModels.VerificationSession
+	err := mongoretry.Write(ctx, "complete_session", func(ctx context.Context) error {
+		return tenant.Guard(common.GetCollection(s.CollectionName)).FindOneAndUpdate(ctx, filter, update, opts).Decode(&completed)
+	})
+	if err == mongo.ErrNoDocuments {
+		return localModels.VerificationSession{}, ErrSessionClosed
+	}
+	if err != nil {
+		return localModels.VerificationSession{}, err
+	}
+
+	applicantFilter := tenant.Of(completed.ClientID).With("applicant_id", completed.ApplicantID)
+	applicantUpdate := bson.M{"$set": bson.M{"capture_channel": channel}}
+	err = mongoretry.Write(ctx, "record_capture_channel", func(ctx context.Context) error {
+		_, err := tenant.Guard(common.GetCollection(s.ApplicantCollectionName)).UpdateOne(ctx, applicantFilter, applicantUpdate)
+		return err
+	})
+	if err != nil {
+		return localModels.VerificationSession{}, err
+	}
+	return completed, nil
+}
+
+// WatchSession returns the events of a session's applicant from now on, leaving out those
+// of its other sessions, until stop is called
+func (s *SessionServiceImpl) WatchSession(session localModels.VerificationSession) (<-chan localModels.SessionEvent, func()) {
+	applicantEvents, unsubscribe := s.Events.Subscribe(session.ClientID, session.ApplicantID)
+	sessionEvents := make(chan localModels.SessionEvent)
+	done := make(chan struct{})
+	go func() {
+		defer close(sessionEvents)
+		for event := range applicantEvents {
+			if event.SessionID != "" && event.SessionID != session.SessionID {
+				continue
+			}
+			select {
+			case sessionEvents <- event:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+	return sessionEvents, stop
+}
+
+// findSession returns the session matching a filter, with its status as of now
+func (s *SessionServiceImpl) findSession(ctx context.Context, filter tenant.Filter) (localModels.VerificationSession, error) {
+	var session localModels.VerificationSession
+	err := tenant.Guard(common.GetCollection(s.CollectionName)).FindOne(ctx, filter).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return localModels.VerificationSession{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return localModels.VerificationSession{}, fmt.Errorf("failed to look up session: %w", err)
+	}
+	session.Status = session.StatusAt(timestamp.Now())
+	return session, nil
+}
+
+// link returns the hosted page's URL opened with a token, or nothing without a hosted page
+func (s *SessionServiceImpl) link(token string) string {
+	if s.HostedURL == "" {
+		return ""
+	}
+	u, err := url.Parse(s.HostedURL)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	query.Set("session", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
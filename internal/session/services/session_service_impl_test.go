@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/session/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseToken(t *testing.T) {
+	now := time.Now()
+	token := signToken([]byte("secret"), "session1", localModels.ChannelLink, now.Add(time.Hour))
+	assert.True(t, strings.HasPrefix(token, "vs_session1."))
+
+	sessionID, channel, err := parseToken([]byte("secret"), token, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "session1", sessionID)
+	assert.Equal(t, localModels.ChannelLink, channel)
+
+	_, _, err = parseToken([]byte("other secret"), token, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// The expiry is signed, so it cannot be pushed back
+	parts := strings.Split(token, ".")
+	extended := parts[0] + "." + "9999999999" + "." + parts[2]
+	_, _, err = parseToken([]byte("secret"), extended, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, _, err = parseToken([]byte("secret"), token, now.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	for _, malformed := range []string{"", "vs_", "session1." + parts[1] + "." + parts[2], "vs_.123.abc", "vs_session1.123.not-hex"} {
+		_, _, err = parseToken([]byte("secret"), malformed, now)
+		assert.ErrorIs(t, err, ErrInvalidToken, malformed)
+	}
+}
+
+func TestParseHandoffToken(t *testing.T) {
+	now := time.Now()
+	token := signToken([]byte("secret"), "session1", localModels.ChannelQRHandoff, now.Add(time.Hour))
+
+	sessionID, channel, err := parseToken([]byte("secret"), token, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "session1", sessionID)
+	assert.Equal(t, localModels.ChannelQRHandoff, channel)
+
+	// The channel is signed, so a handoff token cannot pass as the client's link or the other way round
+	parts := strings.Split(token, ".")
+	asLink := parts[0] + "." + parts[1] + "." + parts[3]
+	_, _, err = parseToken([]byte("secret"), asLink, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestHandoffCodeRefused(t *testing.T) {
+	open := localModels.VerificationSession{SessionID: "session1", Status: localModels.SessionOpened, ExpiresAt: time.Now().Add(time.Hour)}
+
+	service := SessionServiceImpl{SigningSecret: []byte("secret")}
+	_, err := service.HandoffCode(context.Background(), open, 256)
+	assert.ErrorIs(t, err, ErrNoHostedPage)
+
+	service.HostedURL = "https://verify.example.com/start"
+	completed := open
+	completed.Status = localModels.SessionCompleted
+	_, err = service.HandoffCode(context.Background(), completed, 256)
+	assert.ErrorIs(t, err, ErrSessionClosed)
+
+	expired := open
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	_, err = service.HandoffCode(context.Background(), expired, 256)
+	assert.ErrorIs(t, err, ErrSessionClosed)
+}
+
+func TestSessionStatusAt(t *testing.T) {
+	expiresAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status   localModels.SessionStatus
+		at       time.Time
+		expected localModels.SessionStatus
+	}{
+		{localModels.SessionOpened, expiresAt.Add(-time.Second), localModels.SessionOpened},
+		{localModels.SessionOpened, expiresAt, localModels.SessionExpired},
+		{localModels.SessionSubmitted, expiresAt.Add(time.Hour), localModels.SessionExpired},
+		{localModels.SessionCompleted, expiresAt.Add(time.Hour), localModels.SessionCompleted},
+	}
+	for _, tt := range tests {
+		session := localModels.VerificationSession{Status: tt.status, ExpiresAt: expiresAt}
+		assert.Equal(t, tt.expected, session.StatusAt(tt.at), "%s at %s", tt.status, tt.at)
+	}
+}
+
+func TestSessionLink(t *testing.T) {
+	service := SessionServiceImpl{}
+	assert.Empty(t, service.link("vs_token"))
+
+	service.HostedURL = "https://verify.example.com/start?lang=en"
+	assert.Equal(t, "https://verify.example.com/start?lang=en&session=vs_token", service.link("vs_token"))
+}
+
+func TestWatchSession(t *testing.T) {
+	bus := events.NewBus()
+	service := SessionServiceImpl{Events: bus}
+	session := localModels.VerificationSession{SessionID: "session1", ClientID: "client1", ApplicantID: "app1"}
+
+	watched, stop := service.WatchSession(session)
+	ctx := context.Background()
+	// The applicant's other sessions are left out, but not progress of the applicant's uploads
+	assert.NoError(t, bus.Publish(ctx, localModels.SessionEvent{Type: localModels.SessionDocumentReceived, ClientID: "client1", ApplicantID: "app1", SessionID: "session2", DocumentID: "doc0"}))
+	assert.NoError(t, bus.Publish(ctx, localModels.SessionEvent{Type: localModels.SessionDocumentReceived, ClientID: "client1", ApplicantID: "app1", SessionID: "session1", DocumentID: "doc1"}))
+	assert.NoError(t, bus.Publish(ctx, localModels.SessionEvent{Type: localModels.SessionVerificationProgress, ClientID: "client1", ApplicantID: "app1", DocumentID: "doc1", Stage: localModels.UploadJobCompleted}))
+
+	received := <-watched
+	assert.Equal(t, "doc1", received.DocumentID)
+	assert.Equal(t, localModels.SessionDocumentReceived, received.Type)
+	progress := <-watched
+	assert.Equal(t, localModels.UploadJobCompleted, progress.Stage)
+
+	stop()
+	stop()
+	_, open := <-watched
+	assert.False(t, open)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// tokenPrefix marks session tokens, so they are not mistaken for API keys or JWTs
+const tokenPrefix = "vs_"
+
+// signToken returns a token for a session that is valid until expiresAt. The token
+// carries the session ID, expiry and, unless it is the client's link, the channel it
+// was issued for, signed so none can be changed.
+func signToken(secret []byte, sessionID string, channel localModels.SessionChannel, expiresAt time.Time) string {
+	unsigned := sessionID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	if channel != localModels.ChannelLink {
+		unsigned += "." + string(channel)
+	}
+	return tokenPrefix + unsigned + "." + hex.EncodeToString(hmacSHA256(secret, unsigned))
+}
+
+// parseToken checks a token's signature and expiry and returns its session ID and channel
+func parseToken(secret []byte, token string, now time.Time) (string, localModels.SessionChannel, error) {
+	parts := strings.Split(strings.TrimPrefix(token, tokenPrefix), ".")
+	if !strings.HasPrefix(token, tokenPrefix) || len(parts) < 3 || len(parts) > 4 || parts[0] == "" {
+		return "", "", ErrInvalidToken
+	}
+	unsigned, signed := parts[:len(parts)-1], parts[len(parts)-1]
+	signature, err := hex.DecodeString(signed)
+	if err != nil || !hmac.Equal(signature, hmacSHA256(secret, strings.Join(unsigned, "."))) {
+		return "", "", ErrInvalidToken
+	}
+	expiresAt, err := strconv.ParseInt(unsigned[1], 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return "", "", ErrInvalidToken
+	}
+	channel := localModels.ChannelLink
+	if len(unsigned) == 3 {
+		channel = localModels.SessionChannel(unsigned[2])
+	}
+	return unsigned[0], channel, nil
+}
+
+func hmacSHA256(secret []byte, s string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
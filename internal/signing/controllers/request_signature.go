@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/signing/services"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// Error codes of refused signed requests
+var requestSignatureCodes = map[error]string{
+	services.ErrUnsignedRequest:         "signature_required",
+	services.ErrStaleRequest:            "stale_timestamp",
+	services.ErrInvalidRequestSignature: "invalid_signature",
+	services.ErrReplayedRequest:         "replayed_request",
+}
+
+// VerifyRequests refuses requests made with an API key by clients whose settings require
+// signed requests, unless the request carries a timestamp within maxSkew of the server's
+// clock, a nonce not used before and a signature made with one of the client's active
+// signing keys. Requests authenticated with a JWT are not signed. It must run after
+// authentication and clientconfig.Middleware.
+//
+// The body is read into memory to check the signature and handed on to the handler, so
+// bodies larger than maxBody are refused with 413 and code payload_too_large. Files too
+// large for that are uploaded through presigned URLs instead.
+func VerifyRequests(service interfaces.SigningService, nonces services.NonceStore, maxSkew time.Duration, maxBody int64) gin.HandlerFunc {
+	if maxSkew <= 0 {
+		maxSkew = services.DefaultMaxClockSkew
+	}
+	if maxBody <= 0 {
+		maxBody = services.DefaultMaxSignedBody
+	}
+	return func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") == "" {
+			c.Next()
+			return
+		}
+		client, err := clientconfig.FromContext(c)
+		if err != nil {
+			zaplogger.GetLogger().Error("Error loading client settings for request signing", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not load client settings"})
+			return
+		}
+		if !client.Settings.RequireSignedRequests {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			if c.Request.ContentLength > maxBody {
+				abortTooLarge(c, maxBody)
+				return
+			}
+			body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBody))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				abortTooLarge(c, maxBody)
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		keys, err := service.ActiveKeys(c.Request.Context(), client.ClientID)
+		if err != nil {
+			zaplogger.GetLogger().Error("Error loading signing keys", zap.Error(err), zap.String("clientID", client.ClientID))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not load signing keys"})
+			return
+		}
+
+		err = services.VerifyRequest(c.Request.Context(), keys, nonces, client.ClientID, services.SignedRequest{
+			Timestamp:  c.GetHeader(services.TimestampHeader),
+			Nonce:      c.GetHeader(services.NonceHeader),
+			Signature:  c.GetHeader(services.RequestSignatureHeader),
+			Method:     c.Request.Method,
+			RequestURI: c.Request.URL.RequestURI(),
+			Body:       body,
+		}, maxSkew, time.Now())
+		for known, code := range requestSignatureCodes {
+			if errors.Is(err, known) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": code})
+				return
+			}
+		}
+		if err != nil {
+			zaplogger.GetLogger().Error("Error checking request nonce", zap.Error(err), zap.String("clientID", client.ClientID))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not check request signature"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func abortTooLarge(c *gin.Context, maxBody int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error": fmt.Sprintf("Signed request body is larger than %d bytes", maxBody),
+		"code":  "payload_too_large",
+		"limit": maxBody,
+	})
+}
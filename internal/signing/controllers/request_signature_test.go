@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/signing/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// memoryNonces keeps claimed nonces in memory
+type memoryNonces map[string]bool
+
+func (m memoryNonces) Claim(ctx context.Context, clientID, nonce string, until time.Time) (bool, error) {
+	if m[clientID+"/"+nonce] {
+		return false, nil
+	}
+	m[clientID+"/"+nonce] = true
+	return true, nil
+}
+
+func setupVerifiedRouter(mockService *localMocks.MockSigningService, requireSigned bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(func(c *gin.Context) { c.Set("client_id", "client1") })
+	router.Use(clientconfig.Middleware(fixedLoader{localModels.ClientSettings{RequireSignedRequests: requireSigned}}))
+	router.Use(VerifyRequests(mockService, memoryNonces{}, time.Minute, 1<<10))
+	router.POST("/applicants", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusCreated, string(body))
+	})
+	return router
+}
+
+func TestVerifyRequests(t *testing.T) {
+	mockService := new(localMocks.MockSigningService)
+	mockService.On("ActiveKeys", mock.Anything, "client1").Return([]localModels.ResponseSigningKey{
+		{KeyID: "new", Secret: "rssec_new"},
+		{KeyID: "old", Secret: "rssec_old"},
+	}, nil)
+	router := setupVerifiedRouter(mockService, true)
+
+	body := `{"first_name":"Ada"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	send := func(timestamp, nonce, signature string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/applicants?level=basic", strings.NewReader(body))
+		req.Header.Set("X-API-Key", "vk_test")
+		req.Header.Set(services.TimestampHeader, timestamp)
+		req.Header.Set(services.NonceHeader, nonce)
+		req.Header.Set(services.RequestSignatureHeader, signature)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	sign := func(secret, timestamp, nonce string) string {
+		return services.SignRequest(secret, timestamp, nonce, http.MethodPost, "/applicants?level=basic", []byte(body))
+	}
+
+	w := send(now, "n1", sign("rssec_old", now, "n1"))
+	assert.Equal(t, http.StatusCreated, w.Code, "a key in its rotation grace period still verifies")
+	assert.Equal(t, body, w.Body.String(), "the handler reads the body after it was checked")
+
+	w = send(now, "n1", sign("rssec_new", now, "n1"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "replayed_request")
+
+	w = send(stale, "n2", sign("rssec_new", stale, "n2"))
+	assert.Contains(t, w.Body.String(), "stale_timestamp")
+
+	w = send(now, "n3", sign("rssec_unknown", now, "n3"))
+	assert.Contains(t, w.Body.String(), "invalid_signature")
+
+	// A forged request does not use up the nonce
+	w = send(now, "n3", sign("rssec_new", now, "n3"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = send("", "", "")
+	assert.Contains(t, w.Body.String(), "signature_required")
+}
+
+func TestVerifyRequestsSkipsUnsignedClients(t *testing.T) {
+	mockService := new(localMocks.MockSigningService)
+	router := setupVerifiedRouter(mockService, false)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/applicants", strings.NewReader("{}"))
+	req.Header.Set("X-API-Key", "vk_test")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockService.AssertNotCalled(t, "ActiveKeys", mock.Anything, mock.Anything)
+}
+
+func TestVerifyRequestsBoundsTheBody(t *testing.T) {
+	mockService := new(localMocks.MockSigningService)
+	router := setupVerifiedRouter(mockService, true)
+
+	// Refused whether or not the client declares the length
+	for _, declared := range []bool{true, false} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/applicants", strings.NewReader(strings.Repeat("a", 2<<10)))
+		if !declared {
+			req.ContentLength = -1
+		}
+		req.Header.Set("X-API-Key", "vk_test")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), "payload_too_large")
+	}
+	mockService.AssertNotCalled(t, "ActiveKeys", mock.Anything, mock.Anything)
+}
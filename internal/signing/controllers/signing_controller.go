@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/signing/services"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// SignResponses signs the response body for clients whose settings ask for it, with each
+// of the client's active keys. It must run after authentication and clientconfig.Middleware.
+//
+// A signed response is held in memory until the handler finishes, and is never gzipped so
+// the signature covers the bytes the client reads. Requests from a client that wants
+// signed responses fail rather than go out unsigned.
+func SignResponses(service interfaces.SigningService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, err := clientconfig.FromContext(c)
+		if err != nil {
+			zaplogger.GetLogger().Error("Error loading client settings for response signing", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not load client settings"})
+			return
+		}
+		if !client.Settings.SignResponses {
+			c.Next()
+			return
+		}
+		keys, err := service.ActiveKeys(c.Request.Context(), client.ClientID)
+		if err != nil {
+			zaplogger.GetLogger().Error("Error loading response signing keys", zap.Error(err), zap.String("clientID", client.ClientID))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not load response signing keys"})
+			return
+		}
+		if len(keys) == 0 {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Response signing is enabled but no signing key has been issued"})
+			return
+		}
+
+		c.Request.Header.Del("Accept-Encoding")
+		w := &signingWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		at := time.Now()
+		for _, key := range keys {
+			c.Writer.Header().Add(services.SignatureHeader, services.Sign(key, at, w.body.Bytes()))
+		}
+		c.Writer.WriteHeader(w.status)
+		if w.body.Len() > 0 {
+			c.Writer.Write(w.body.Bytes())
+		} else {
+			c.Writer.WriteHeaderNow()
+		}
+	}
+}
+
+// signingWriter holds back a response until it can be signed
+type signingWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *signingWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *signingWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *signingWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.body.Write(b)
+}
+
+func (w *signingWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *signingWriter) Status() int {
+	return w.status
+}
+
+func (w *signingWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *signingWriter) Written() bool {
+	return w.written
+}
+
+// Flush does nothing, as nothing can be sent before the whole body is signed
+func (w *signingWriter) Flush() {}
+
+// RotateSigningKey is the handler function for issuing a client a new response signing
+// key. The client's previous keys keep signing until the rotation grace period ends. The
+// response holds the key's secret, which cannot be retrieved again.
+func RotateSigningKey(c *gin.Context, service interfaces.SigningService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := service.RotateKey(c.Request.Context(), c.Param("clientId"), adminID)
+	if apperr.Respond(c, err) || mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error("Error rotating response signing key", zap.Error(err), zap.String("clientID", c.Param("clientId")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not rotate signing key"})
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListSigningKeys is the handler function for listing a client's response signing keys
+func ListSigningKeys(c *gin.Context, service interfaces.SigningService) {
+	keys, err := service.ListKeys(c.Request.Context(), c.Param("clientId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve signing keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
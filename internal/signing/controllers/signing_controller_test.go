@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/compression"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/signing/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fixedLoader returns the same settings for every client
+type fixedLoader struct {
+	settings localModels.ClientSettings
+}
+
+func (l fixedLoader) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	return localModels.Client{ClientID: clientID, Settings: l.settings}, nil
+}
+
+func setupSignedRouter(mockService *localMocks.MockSigningService, signResponses bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(func(c *gin.Context) { c.Set("client_id", "client1") })
+	router.Use(clientconfig.Middleware(fixedLoader{localModels.ClientSettings{SignResponses: signResponses}}))
+	router.Use(SignResponses(mockService))
+	router.GET("/applicants", compression.Responses(1), func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"applicant_id": "app1"})
+	})
+	return router
+}
+
+func TestSignResponses(t *testing.T) {
+	keys := []localModels.ResponseSigningKey{
+		{KeyID: "new", Secret: "rssec_new"},
+		{KeyID: "old", Secret: "rssec_old"},
+	}
+
+	tests := []struct {
+		name               string
+		signResponses      bool
+		keys               []localModels.ResponseSigningKey
+		keysErr            error
+		expectedStatusCode int
+		expectedSignatures int
+	}{
+		{name: "Signing off", expectedStatusCode: http.StatusCreated},
+		{name: "Signed with every active key", signResponses: true, keys: keys, expectedStatusCode: http.StatusCreated, expectedSignatures: 2},
+		{name: "No key issued", signResponses: true, keys: []localModels.ResponseSigningKey{}, expectedStatusCode: http.StatusInternalServerError},
+		{name: "Keys unavailable", signResponses: true, keys: []localModels.ResponseSigningKey{}, keysErr: errors.New("boom"), expectedStatusCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockSigningService)
+			mockService.On("ActiveKeys", mock.Anything, "client1").Return(tt.keys, tt.keysErr)
+			router := setupSignedRouter(mockService, tt.signResponses)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/applicants", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			signatures := w.Header().Values(services.SignatureHeader)
+			assert.Len(t, signatures, tt.expectedSignatures)
+			if tt.expectedSignatures == 0 {
+				return
+			}
+
+			// Signed responses are sent as they were signed, uncompressed
+			assert.Empty(t, w.Header().Get("Content-Encoding"))
+			assert.JSONEq(t, `{"applicant_id":"app1"}`, w.Body.String())
+			for i, signature := range signatures {
+				timestamp := strings.TrimPrefix(strings.Split(signature, ",")[0], "t=")
+				assert.Contains(t, signature, ",kid="+keys[i].KeyID+",")
+				at := parseUnix(t, timestamp)
+				assert.Equal(t, services.Sign(keys[i], at, w.Body.Bytes()), signature)
+			}
+		})
+	}
+}
+
+func TestRotateSigningKey(t *testing.T) {
+	tests := []struct {
+		name               string
+		adminID            string
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Key issued", adminID: "admin1", expectedStatusCode: http.StatusCreated},
+		{name: "No admin", expectedStatusCode: http.StatusUnauthorized},
+		{name: "Unknown client", adminID: "admin1", serviceErr: services.ErrClientNotFound, expectedStatusCode: http.StatusNotFound},
+		{name: "Storage fails", adminID: "admin1", serviceErr: errors.New("boom"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockSigningService)
+			mockService.On("RotateKey", mock.Anything, "client1", "admin1").
+				Return(localModels.ResponseSigningKey{KeyID: "key1", ClientID: "client1", Secret: "rssec_1"}, tt.serviceErr)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.Default()
+			router.POST("/clients/:clientId/signing-keys", func(c *gin.Context) {
+				if tt.adminID != "" {
+					c.Set("admin_id", tt.adminID)
+				}
+				RotateSigningKey(c, mockService)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/clients/client1/signing-keys", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusCreated {
+				assert.Contains(t, w.Body.String(), `"secret":"rssec_1"`)
+			}
+		})
+	}
+}
+
+func parseUnix(t *testing.T, timestamp string) time.Time {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	assert.NoError(t, err)
+	return time.Unix(seconds, 0)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Headers of a signed request
+const (
+	TimestampHeader        = "X-Timestamp" // Unix seconds
+	NonceHeader            = "X-Nonce"
+	RequestSignatureHeader = "X-Signature" // Hex HMAC-SHA256, see SignRequest
+)
+
+// DefaultMaxClockSkew is how far a signed request's timestamp may be from the server's clock
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// DefaultMaxSignedBody bounds the body of a signed request, which is held in memory while
+// its signature is checked
+const DefaultMaxSignedBody int64 = 20 << 20
+
+// maxNonceLength bounds the nonces stored for replay protection
+const maxNonceLength = 128
+
+var (
+	// ErrUnsignedRequest is returned when a request lacks a timestamp, nonce or signature
+	ErrUnsignedRequest = errors.New("request must carry X-Timestamp, X-Nonce and X-Signature headers")
+	// ErrStaleRequest is returned when a request's timestamp is too far from the server's clock
+	ErrStaleRequest = errors.New("request timestamp is outside the allowed window")
+	// ErrInvalidRequestSignature is returned when no active signing key produces the request's signature
+	ErrInvalidRequestSignature = errors.New("request signature does not match")
+	// ErrReplayedRequest is returned when a request's nonce has been used before
+	ErrReplayedRequest = errors.New("request nonce has already been used")
+)
+
+// NonceStore remembers the nonces of signed requests so each is accepted once
+type NonceStore interface {
+	// Claim records a client's nonce until the given time, reporting false if it was already recorded
+	Claim(ctx context.Context, clientID, nonce string, until time.Time) (bool, error)
+}
+
+// nonceKeyPrefix namespaces claimed nonces among the keys in Redis
+const nonceKeyPrefix = "verus:request_nonces:"
+
+// MongoNonceStore keeps nonces in a collection whose unique index rejects a second use
+// and whose TTL index removes them once they can no longer be replayed. It serves
+// deployments without Redis.
+type MongoNonceStore struct {
+	CollectionName string
+}
+
+// NewMongoNonceStore creates a nonce store in the request nonces collection
+func NewMongoNonceStore() *MongoNonceStore {
+	return &MongoNonceStore{CollectionName: localConstants.CollectionRequestNonces}
+}
+
+func (s *MongoNonceStore) Claim(ctx context.Context, clientID, nonce string, until time.Time) (bool, error) {
+	_, err := common.GetCollection(s.CollectionName).InsertOne(ctx, bson.M{
+		"client_id":  clientID,
+		"nonce":      nonce,
+		"expires_at": until,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record request nonce: %w", err)
+	}
+	return true, nil
+}
+
+// RedisNonceStore claims nonces with SETNX in Redis, which forgets each once it can no
+// longer be replayed
+type RedisNonceStore struct {
+	Client *redis.Client
+}
+
+// NewRedisNonceStore creates a nonce store in the Redis server client connects to
+func NewRedisNonceStore(client *redis.Client) *RedisNonceStore {
+	return &RedisNonceStore{Client: client}
+}
+
+func (s *RedisNonceStore) Claim(ctx context.Context, clientID, nonce string, until time.Time) (bool, error) {
+	// A key without an expiry would be kept forever, so one already due is kept briefly
+	ttl := max(until.Sub(timestamp.Now()), time.Second)
+	claimed, err := s.Client.SetNX(ctx, nonceKeyPrefix+clientID+":"+nonce, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to record request nonce: %w", err)
+	}
+	return claimed, nil
+}
+
+// SignRequest computes the signature a client sends in X-Signature: a hex HMAC-SHA256,
+// keyed with one of the client's signing secrets, of
+// "<timestamp>.<nonce>.<METHOD>.<request URI>.<body>"
+func SignRequest(secret, timestamp, nonce, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + nonce + "." + method + "." + requestURI + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedRequest is what a request carries for its signature to be checked
+type SignedRequest struct {
+	Timestamp  string
+	Nonce      string
+	Signature  string
+	Method     string
+	RequestURI string
+	Body       []byte
+}
+
+// VerifyRequest checks a signed request against the client's active signing keys, then
+// claims its nonce. The nonce is only claimed for a valid signature, so a forger cannot
+// use up a client's nonces.
+func VerifyRequest(ctx context.Context, keys []localModels.ResponseSigningKey, nonces NonceStore, clientID string, req SignedRequest, maxSkew time.Duration, now time.Time) error {
+	if req.Timestamp == "" || req.Nonce == "" || req.Signature == "" || len(req.Nonce) > maxNonceLength {
+		return ErrUnsignedRequest
+	}
+	seconds, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return ErrUnsignedRequest
+	}
+	at := time.Unix(seconds, 0)
+	if at.Before(now.Add(-maxSkew)) || at.After(now.Add(maxSkew)) {
+		return ErrStaleRequest
+	}
+
+	signature, err := hex.DecodeString(req.Signature)
+	if err != nil {
+		return ErrInvalidRequestSignature
+	}
+	valid := false
+	for _, key := range keys {
+		expected, _ := hex.DecodeString(SignRequest(key.Secret, req.Timestamp, req.Nonce, req.Method, req.RequestURI, req.Body))
+		if hmac.Equal(signature, expected) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidRequestSignature
+	}
+
+	// Past this time the timestamp is stale, so the nonce no longer needs remembering
+	claimed, err := nonces.Claim(ctx, clientID, req.Nonce, at.Add(maxSkew))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrReplayedRequest
+	}
+	return nil
+}
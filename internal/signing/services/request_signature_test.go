@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/rachel-lawrie/verus_app_backend/internal/redisclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisNonceStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client, err := redisclient.New("redis://" + server.Addr())
+	require.NoError(t, err)
+	store := NewRedisNonceStore(client)
+	ctx := context.Background()
+	until := time.Now().Add(time.Minute)
+
+	claimed, err := store.Claim(ctx, "client1", "nonce1", until)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.Claim(ctx, "client1", "nonce1", until)
+	require.NoError(t, err)
+	assert.False(t, claimed, "a nonce is accepted once")
+
+	// Nonces are the client's own
+	claimed, err = store.Claim(ctx, "client2", "nonce1", until)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Forgotten once the request could no longer be replayed
+	assert.LessOrEqual(t, server.TTL(nonceKeyPrefix+"client1:nonce1"), time.Minute)
+	server.FastForward(2 * time.Minute)
+	assert.False(t, server.Exists(nonceKeyPrefix+"client1:nonce1"))
+
+	server.Close()
+	_, err = store.Claim(ctx, "client1", "nonce2", until)
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// SignatureHeader carries a signature of the response body. It is repeated once per
+	// active key while a rotation is in progress.
+	SignatureHeader = "X-Verus-Response-Signature"
+
+	// DefaultRotationGrace is how long a rotated-out key keeps signing next to its replacement
+	DefaultRotationGrace = 7 * 24 * time.Hour
+)
+
+// ErrClientNotFound is returned when no client has the given ID
+var ErrClientNotFound = apperr.NotFound("client_not_found", "client not found")
+
+// SigningServiceImpl manages the keys that sign API responses for clients that ask for it
+type SigningServiceImpl struct {
+	CollectionName       string
+	ClientCollectionName string
+	RotationGrace        time.Duration
+}
+
+var (
+	instance SigningServiceImpl
+	once     sync.Once
+)
+
+func GetSigningServiceImpl() SigningServiceImpl {
+	once.Do(func() {
+		instance = SigningServiceImpl{
+			CollectionName:       localConstants.CollectionSigningKeys,
+			ClientCollectionName: localConstants.CollectionClients,
+			RotationGrace:        DefaultRotationGrace,
+		}
+	})
+	return instance
+}
+
+// RotateKey creates a signing key for the client and retires its other keys once the
+// rotation grace period has passed
+func (s *SigningServiceImpl) RotateKey(ctx context.Context, clientID, adminID string) (localModels.ResponseSigningKey, error) {
+	n, err := common.GetCollection(s.ClientCollectionName).CountDocuments(ctx, bson.M{"client_id": clientID})
+	if err != nil {
+		return localModels.ResponseSigningKey{}, fmt.Errorf("failed to look up client: %w", err)
+	}
+	if n == 0 {
+		return localModels.ResponseSigningKey{}, ErrClientNotFound
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return localModels.ResponseSigningKey{}, err
+	}
+	now := timestamp.Now()
+	key := localModels.ResponseSigningKey{
+		KeyID:     ids.New(),
+		ClientID:  clientID,
+		Secret:    secret,
+		CreatedBy: adminID,
+		CreatedAt: now,
+	}
+
+	// The new key is stored before the old ones are retired, so a failure in between
+	// leaves the client with two keys rather than none
+	collection := common.GetCollection(s.CollectionName)
+	if err := mongoretry.InsertOnce(ctx, collection, "rotate_signing_key", bson.M{"key_id": key.KeyID}, key); err != nil {
+		return localModels.ResponseSigningKey{}, err
+	}
+	retire := bson.M{"client_id": clientID, "key_id": bson.M{"$ne": key.KeyID}, "retires_at": nil}
+	if _, err := collection.UpdateMany(ctx, retire, bson.M{"$set": bson.M{"retires_at": now.Add(s.RotationGrace)}}); err != nil {
+		return localModels.ResponseSigningKey{}, fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+	return key, nil
+}
+
+// ListKeys lists the client's signing keys without their secrets, newest first
+func (s *SigningServiceImpl) ListKeys(ctx context.Context, clientID string) ([]localModels.ResponseSigningKey, error) {
+	keys, err := s.findKeys(ctx, bson.M{"client_id": clientID})
+	for i := range keys {
+		keys[i].Secret = ""
+	}
+	return keys, err
+}
+
+// ActiveKeys returns the client's keys that have not retired, newest first
+func (s *SigningServiceImpl) ActiveKeys(ctx context.Context, clientID string) ([]localModels.ResponseSigningKey, error) {
+	return s.findKeys(ctx, bson.M{
+		"client_id": clientID,
+		"$or":       []bson.M{{"retires_at": nil}, {"retires_at": bson.M{"$gt": time.Now()}}},
+	})
+}
+
+func (s *SigningServiceImpl) findKeys(ctx context.Context, filter bson.M) ([]localModels.ResponseSigningKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := common.GetCollection(s.CollectionName).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	keys := []localModels.ResponseSigningKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Sign computes one value of the signature header: the timestamp, the key ID and an
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the key's secret
+func Sign(key localModels.ResponseSigningKey, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(key.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",kid=" + key.KeyID + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newSecret generates a random response signing secret
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate signing secret: %v", err)
+	}
+	return "rssec_" + hex.EncodeToString(b), nil
+}
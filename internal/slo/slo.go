@@ -0,0 +1,291 @@
+// Package slo tracks service level objectives from the request and provider figures of
+// opsmetrics and raises alerts when an objective's error budget is burning too fast.
+//
+// Burn rate is the error rate divided by the error budget, 1 - target: at a burn rate of
+// 1 the budget lasts exactly as long as the period it is meant for. Each rule compares a
+// long and a short window and fires only while both burn faster than its threshold, so
+// an alert starts soon after a problem does and stops soon after it is fixed. Like
+// opsmetrics, figures cover this instance only.
+package slo
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/opsmetrics"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// span is how far back events are kept, in one bucket per minute
+const span = 6 * time.Hour
+
+const bucketCount = int(span / time.Minute)
+
+// maxAlerts is how many recent alert events are kept for the admin endpoint
+const maxAlerts = 100
+
+// Windows over which each objective is reported
+var windows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, span}
+
+// Rule fires while the burn rate over both its windows is above Threshold
+type Rule struct {
+	Name      string
+	Severity  string
+	Long      time.Duration
+	Short     time.Duration
+	Threshold float64
+}
+
+// Rules are the multiwindow burn rate alerts of the SRE workbook. A fast burn spends 2%
+// of a 30 day budget in an hour and a slow burn 5% in six hours.
+var Rules = []Rule{
+	{Name: "fast_burn", Severity: "page", Long: time.Hour, Short: 5 * time.Minute, Threshold: 14.4},
+	{Name: "slow_burn", Severity: "ticket", Long: span, Short: 30 * time.Minute, Threshold: 6},
+}
+
+// alertStats counts the alerts raised, by objective
+var alertStats = expvar.NewMap("slo_alerts")
+
+// Alert is a rule of an objective starting or stopping to fire
+type Alert struct {
+	Objective     string    `json:"objective"`
+	Rule          string    `json:"rule"`
+	Severity      string    `json:"severity"`
+	State         string    `json:"state"` // "firing" or "resolved"
+	BurnRate      float64   `json:"burn_rate"`
+	ShortBurnRate float64   `json:"short_burn_rate"`
+	Threshold     float64   `json:"threshold"`
+	At            time.Time `json:"at"`
+}
+
+// Status is how an objective is doing
+type Status struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Target      float64        `json:"target"`
+	LatencyMS   int64          `json:"latency_ms,omitempty"`
+	Windows     []WindowStatus `json:"windows"`
+	// BudgetRemaining is the share of the error budget of the longest window left, below
+	// zero once it is spent
+	BudgetRemaining float64  `json:"budget_remaining"`
+	Firing          []string `json:"firing"`
+}
+
+// WindowStatus is an objective's events over one window
+type WindowStatus struct {
+	Window    string  `json:"window"`
+	Events    int64   `json:"events"`
+	Bad       int64   `json:"bad"`
+	ErrorRate float64 `json:"error_rate"`
+	BurnRate  float64 `json:"burn_rate"`
+}
+
+// Tracker counts good and bad events of each objective
+type Tracker struct {
+	Interval  time.Duration // How often Check should be run
+	MinEvents int64
+
+	now        func() time.Time
+	objectives []*objective
+	bySeries   map[string][]*objective
+
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+type objective struct {
+	config.ObjectiveSettings
+	buckets [bucketCount]bucket
+	firing  map[string]bool // Rule name -> firing
+}
+
+type bucket struct {
+	minute    int64 // Unix minute the bucket holds, so stale buckets can be recognised
+	good, bad int64
+}
+
+// NewTracker creates a tracker of the configured objectives, or returns nil if SLO
+// tracking is off
+func NewTracker(settings config.SLOSettings) (*Tracker, error) {
+	if settings.CheckInterval <= 0 || len(settings.Objectives) == 0 {
+		return nil, nil
+	}
+	t := &Tracker{
+		Interval:  settings.CheckInterval,
+		MinEvents: int64(settings.MinEvents),
+		now:       time.Now,
+		bySeries:  make(map[string][]*objective),
+	}
+	names := make(map[string]bool)
+	for _, o := range settings.Objectives {
+		switch {
+		case o.Name == "":
+			return nil, fmt.Errorf("an SLO has no name")
+		case names[o.Name]:
+			return nil, fmt.Errorf("SLO %q is declared twice", o.Name)
+		case len(o.Series) == 0:
+			return nil, fmt.Errorf("SLO %q has no series", o.Name)
+		case o.Target <= 0 || o.Target >= 1:
+			return nil, fmt.Errorf("SLO %q target must be between 0 and 1", o.Name)
+		}
+		names[o.Name] = true
+		tracked := &objective{ObjectiveSettings: o, firing: make(map[string]bool)}
+		t.objectives = append(t.objectives, tracked)
+		for _, series := range o.Series {
+			t.bySeries[series] = append(t.bySeries[series], tracked)
+		}
+	}
+	return t, nil
+}
+
+// Observe counts an event of the named series towards the objectives that include it.
+// It has the signature of an opsmetrics.Observer.
+func (t *Tracker) Observe(name string, d time.Duration, outcome opsmetrics.Outcome) {
+	objectives := t.bySeries[name]
+	if len(objectives) == 0 || outcome == opsmetrics.ClientError {
+		return
+	}
+	minute := t.now().Unix() / 60
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, o := range objectives {
+		b := &o.buckets[minute%int64(bucketCount)]
+		if b.minute != minute {
+			*b = bucket{minute: minute}
+		}
+		if outcome == opsmetrics.Failure || (o.Latency > 0 && d > o.Latency) {
+			b.bad++
+		} else {
+			b.good++
+		}
+	}
+}
+
+// Check evaluates every rule of every objective, logging an alert when one starts firing
+// and again when it stops
+func (t *Tracker) Check(ctx context.Context) error {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, o := range t.objectives {
+		for _, rule := range Rules {
+			long, longEvents := o.burnRate(now, rule.Long)
+			short, _ := o.burnRate(now, rule.Short)
+			firing := longEvents >= t.MinEvents && long > rule.Threshold && short > rule.Threshold
+			if firing == o.firing[rule.Name] {
+				continue
+			}
+			o.firing[rule.Name] = firing
+
+			alert := Alert{
+				Objective:     o.Name,
+				Rule:          rule.Name,
+				Severity:      rule.Severity,
+				State:         "resolved",
+				BurnRate:      long,
+				ShortBurnRate: short,
+				Threshold:     rule.Threshold,
+				At:            now.UTC(),
+			}
+			fields := []zap.Field{
+				zap.Bool("alert", firing),
+				zap.String("objective", o.Name),
+				zap.String("rule", rule.Name),
+				zap.String("severity", rule.Severity),
+				zap.Float64("burnRate", long),
+				zap.Float64("shortBurnRate", short),
+				zap.Float64("threshold", rule.Threshold),
+				zap.Float64("target", o.Target),
+			}
+			if firing {
+				alert.State = "firing"
+				alertStats.Add(o.Name, 1)
+				zaplogger.GetLogger().Error("SLO error budget burning too fast", fields...)
+			} else {
+				zaplogger.GetLogger().Info("SLO burn rate back under threshold", fields...)
+			}
+			t.alerts = append(t.alerts, alert)
+			if len(t.alerts) > maxAlerts {
+				t.alerts = t.alerts[len(t.alerts)-maxAlerts:]
+			}
+		}
+	}
+	return nil
+}
+
+// Status reports each objective over every window
+func (t *Tracker) Status() []Status {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	statuses := make([]Status, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		status := Status{
+			Name:        o.Name,
+			Description: o.Description,
+			Target:      o.Target,
+			LatencyMS:   o.Latency.Milliseconds(),
+			Firing:      []string{},
+		}
+		for _, window := range windows {
+			good, bad := o.count(now, window)
+			ws := WindowStatus{Window: window.String(), Events: good + bad, Bad: bad}
+			if ws.Events > 0 {
+				ws.ErrorRate = float64(bad) / float64(ws.Events)
+				ws.BurnRate = ws.ErrorRate / (1 - o.Target)
+			}
+			status.Windows = append(status.Windows, ws)
+		}
+		status.BudgetRemaining = 1 - status.Windows[len(status.Windows)-1].BurnRate
+		for _, rule := range Rules {
+			if o.firing[rule.Name] {
+				status.Firing = append(status.Firing, rule.Name)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Alerts returns the recent alert events, most recent first
+func (t *Tracker) Alerts() []Alert {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	alerts := make([]Alert, len(t.alerts))
+	for i, alert := range t.alerts {
+		alerts[len(t.alerts)-1-i] = alert
+	}
+	return alerts
+}
+
+// burnRate returns the burn rate over the window ending at now, and the number of events
+// it is based on
+func (o *objective) burnRate(now time.Time, window time.Duration) (float64, int64) {
+	good, bad := o.count(now, window)
+	if good+bad == 0 {
+		return 0, 0
+	}
+	return float64(bad) / float64(good+bad) / (1 - o.Target), good + bad
+}
+
+// count adds up the events of the window ending at now
+func (o *objective) count(now time.Time, window time.Duration) (good, bad int64) {
+	current := now.Unix() / 60
+	oldest := current - int64(window/time.Minute) + 1
+	for i := range o.buckets {
+		b := &o.buckets[i]
+		if b.minute >= oldest && b.minute <= current {
+			good += b.good
+			bad += b.bad
+		}
+	}
+	return good, bad
+}
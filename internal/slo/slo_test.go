@@ -0,0 +1,126 @@
+package slo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/opsmetrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTracker(t *testing.T, now *time.Time) *Tracker {
+	tracker, err := NewTracker(config.SLOSettings{
+		CheckInterval: time.Minute,
+		MinEvents:     20,
+		Objectives: []config.ObjectiveSettings{
+			{Name: "upload_latency", Series: []string{"POST /api/v2/applicants/:id/documents"}, Target: 0.99, Latency: 3 * time.Second},
+			{Name: "webhook_delivery", Series: []string{"webhook.deliver"}, Target: 0.995},
+		},
+	})
+	require.NoError(t, err)
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func TestNewTracker(t *testing.T) {
+	tracker, err := NewTracker(config.SLOSettings{})
+	assert.NoError(t, err)
+	assert.Nil(t, tracker, "tracking is off without a check interval")
+
+	tests := []struct {
+		name      string
+		objective config.ObjectiveSettings
+	}{
+		{name: "No name", objective: config.ObjectiveSettings{Series: []string{"a"}, Target: 0.99}},
+		{name: "No series", objective: config.ObjectiveSettings{Name: "a", Target: 0.99}},
+		{name: "Target of 1", objective: config.ObjectiveSettings{Name: "a", Series: []string{"a"}, Target: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTracker(config.SLOSettings{CheckInterval: time.Minute, Objectives: []config.ObjectiveSettings{tt.objective}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestObserve(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tracker := newTracker(t, &now)
+
+	upload := "POST /api/v2/applicants/:id/documents"
+	tracker.Observe(upload, time.Second, opsmetrics.OK)
+	tracker.Observe(upload, 4*time.Second, opsmetrics.OK)
+	tracker.Observe(upload, time.Second, opsmetrics.Failure)
+	tracker.Observe(upload, time.Second, opsmetrics.ClientError)
+	tracker.Observe("GET /api/v2/applicants/:id", time.Second, opsmetrics.Failure)
+
+	statuses := tracker.Status()
+	require.Len(t, statuses, 2)
+	latency := statuses[0]
+	assert.Equal(t, int64(3000), latency.LatencyMS)
+	window := latency.Windows[0]
+	assert.Equal(t, "5m0s", window.Window)
+	assert.Equal(t, int64(3), window.Events, "client errors are not counted")
+	assert.Equal(t, int64(2), window.Bad, "the slow upload and the failure are bad")
+	assert.InDelta(t, 66.667, window.BurnRate, 0.001)
+	assert.Zero(t, statuses[1].Windows[0].Events)
+
+	// Events leave the short windows as time passes
+	now = now.Add(10 * time.Minute)
+	statuses = tracker.Status()
+	assert.Zero(t, statuses[0].Windows[0].Events)
+	assert.Equal(t, int64(3), statuses[0].Windows[3].Events)
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tracker := newTracker(t, &now)
+
+	// 10% of deliveries failing burns a 0.5% budget 20 times too fast
+	for i := 0; i < 100; i++ {
+		outcome := opsmetrics.OK
+		if i%10 == 0 {
+			outcome = opsmetrics.Failure
+		}
+		tracker.Observe("webhook.deliver", 100*time.Millisecond, outcome)
+	}
+	require.NoError(t, tracker.Check(context.Background()))
+
+	alerts := tracker.Alerts()
+	require.Len(t, alerts, 2, "both rules fire, as six hours have seen only these events")
+	for _, alert := range alerts {
+		assert.Equal(t, "webhook_delivery", alert.Objective)
+		assert.Equal(t, "firing", alert.State)
+		assert.InDelta(t, 20.0, alert.BurnRate, 0.001)
+	}
+	assert.ElementsMatch(t, []string{"fast_burn", "slow_burn"}, tracker.Status()[1].Firing)
+	assert.InDelta(t, -19.0, tracker.Status()[1].BudgetRemaining, 0.001)
+
+	// Checking again raises nothing new
+	require.NoError(t, tracker.Check(context.Background()))
+	assert.Len(t, tracker.Alerts(), 2)
+
+	// Once the failures are over five minutes old, the fast burn resolves
+	now = now.Add(6 * time.Minute)
+	for i := 0; i < 100; i++ {
+		tracker.Observe("webhook.deliver", 100*time.Millisecond, opsmetrics.OK)
+	}
+	require.NoError(t, tracker.Check(context.Background()))
+	alerts = tracker.Alerts()
+	require.Len(t, alerts, 3)
+	assert.Equal(t, "fast_burn", alerts[0].Rule)
+	assert.Equal(t, "resolved", alerts[0].State)
+	assert.Equal(t, []string{"slow_burn"}, tracker.Status()[1].Firing)
+}
+
+func TestCheckMinEvents(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tracker := newTracker(t, &now)
+
+	tracker.Observe("webhook.deliver", time.Second, opsmetrics.Failure)
+	require.NoError(t, tracker.Check(context.Background()))
+	assert.Empty(t, tracker.Alerts())
+}
@@ -0,0 +1,63 @@
+// Package softdelete decides whether a soft-deleted applicant or document can still be
+// restored. Records are deleted by setting deleted, deleted_at and deleted_by rather than
+// removed, and a client may restore one for a grace period after its deleted_at.
+package softdelete
+
+import (
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DefaultGracePeriod is how long deleted records can be restored when no period is configured
+const DefaultGracePeriod = 30 * 24 * time.Hour
+
+var (
+	// ErrNotDeleted is returned when a record that is not deleted is restored
+	ErrNotDeleted = apperr.Conflict("not_deleted", "record is not deleted")
+	// ErrGracePeriodOver is returned when a record is restored after its grace period has passed
+	ErrGracePeriodOver = apperr.Gone("restore_period_over", "the grace period for restoring the record has passed")
+)
+
+// State is the part of a record that says whether, and when, it was deleted
+type State struct {
+	Deleted   bool       `bson:"deleted"`
+	DeletedAt *time.Time `bson:"deleted_at"`
+}
+
+// Projection reads only a record's State
+var Projection = bson.M{"deleted": 1, "deleted_at": 1}
+
+// GracePeriod returns the configured grace period, or DefaultGracePeriod when it is not positive
+func GracePeriod(configured time.Duration) time.Duration {
+	if configured <= 0 {
+		return DefaultGracePeriod
+	}
+	return configured
+}
+
+// RestorableUntil returns when a record deleted at deletedAt can no longer be restored
+func RestorableUntil(deletedAt time.Time, gracePeriod time.Duration) time.Time {
+	return deletedAt.Add(GracePeriod(gracePeriod))
+}
+
+// Check returns nil if a record in this state can be restored at now. Records deleted
+// without a deleted_at cannot be placed in their grace period, so they are not restored.
+func (s State) Check(gracePeriod time.Duration, now time.Time) error {
+	if !s.Deleted {
+		return ErrNotDeleted
+	}
+	if s.DeletedAt == nil || !now.Before(RestorableUntil(*s.DeletedAt, gracePeriod)) {
+		return ErrGracePeriodOver
+	}
+	return nil
+}
+
+// Restore returns the update that un-deletes a record at now
+func Restore(now time.Time) bson.M {
+	return bson.M{
+		"$set":   bson.M{"deleted": false, "updated_at": now},
+		"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+	}
+}
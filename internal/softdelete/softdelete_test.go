@@ -0,0 +1,29 @@
+package softdelete
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoschema"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 10, 24, 12, 0, 0, 0, time.UTC)
+	deletedAt := func(ago time.Duration) *time.Time {
+		at := now.Add(-ago)
+		return &at
+	}
+
+	assert.ErrorIs(t, State{}.Check(0, now), ErrNotDeleted)
+	assert.NoError(t, State{Deleted: true, DeletedAt: deletedAt(29 * 24 * time.Hour)}.Check(0, now))
+	assert.ErrorIs(t, State{Deleted: true, DeletedAt: deletedAt(30 * 24 * time.Hour)}.Check(0, now), ErrGracePeriodOver)
+	assert.ErrorIs(t, State{Deleted: true, DeletedAt: deletedAt(2 * time.Hour)}.Check(time.Hour, now), ErrGracePeriodOver)
+	// Without deleted_at the grace period cannot be known
+	assert.ErrorIs(t, State{Deleted: true}.Check(0, now), ErrGracePeriodOver)
+}
+
+func TestRestoreMatchesSchema(t *testing.T) {
+	assert.NoError(t, mongoschema.ValidateUpdate(constants.CollectionApplicants, Restore(time.Now())))
+}
@@ -0,0 +1,121 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rachel-lawrie/verus_app_backend/internal/awsreplay"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/kmsprovider"
+	"github.com/rachel-lawrie/verus_app_backend/internal/redisclient"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Checks returns the dependency checks for a server that has connected to the
+// database. S3 and KMS are left out when AWS calls are replayed from a cassette, and
+// Redis when replicas do not share one.
+func Checks(cfg models.Config, settings config.Settings) []Check {
+	checks := []Check{MongoCheck()}
+	if awsreplay.Mode(settings.AWSReplay.Mode) != awsreplay.ModeReplay {
+		checks = append(checks, S3BucketCheck(cfg), KMSKeyCheck(cfg, settings.KMS))
+	}
+	if settings.Sessions.RedisURL != "" {
+		checks = append(checks, RedisCheck(settings.Sessions.RedisURL))
+	}
+	return checks
+}
+
+// PolicyFrom converts the startup settings into a Policy
+func PolicyFrom(settings config.StartupSettings) Policy {
+	return Policy{Attempts: settings.Attempts, RetryDelay: settings.RetryDelay, Timeout: settings.Timeout}
+}
+
+// MongoCheck pings the primary of the connected database
+func MongoCheck() Check {
+	return Check{
+		Name: "mongodb",
+		Hint: "check the database host or Atlas connection URI, the credentials, and that the cluster has a primary",
+		Run: func(ctx context.Context) error {
+			collection := common.GetCollection(constants.CollectionApplicants)
+			if collection == nil {
+				return errors.New("database is not connected")
+			}
+			return collection.Database().Client().Ping(ctx, readpref.Primary())
+		},
+	}
+}
+
+// RedisCheck pings the Redis server at rawURL, which replicas share session events, token
+// revocations, request nonces and upload progress through
+func RedisCheck(rawURL string) Check {
+	return Check{
+		Name: "redis",
+		Hint: "check the host, port and password in sessions.redisURL, and that it uses rediss:// if the server requires TLS",
+		Run: func(ctx context.Context) error {
+			client, err := redisclient.New(rawURL)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			return client.Ping(ctx).Err()
+		},
+	}
+}
+
+// S3BucketCheck confirms the document bucket exists and the credentials can reach it
+func S3BucketCheck(cfg models.Config) Check {
+	return Check{
+		Name: "s3",
+		Hint: fmt.Sprintf("check that bucket %q exists in region %s and the credentials allow s3:ListBucket on it", cfg.AWS.BucketName, cfg.AWS.Region),
+		Run: func(ctx context.Context) error {
+			if cfg.AWS.BucketName == "" {
+				return errors.New("no bucket name configured")
+			}
+			client, err := NewS3Client(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(cfg.AWS.BucketName)})
+			return err
+		},
+	}
+}
+
+// KMSKeyCheck generates a data key, which needs the key to be enabled and usable by the credentials
+func KMSKeyCheck(cfg models.Config, settings config.KMSSettings) Check {
+	hint := fmt.Sprintf("check that key %q is enabled in region %s and the credentials allow kms:GenerateDataKey and kms:Decrypt", cfg.AWS.KeyID, cfg.AWS.Region)
+	if provider, _ := kmsprovider.Name(settings); provider == kmsprovider.ProviderVault {
+		hint = fmt.Sprintf("check that Vault at %s is unsealed, transit key %q exists and the token may update its datakey, encrypt and decrypt paths", settings.Vault.Address, settings.Vault.KeyName)
+	}
+	return Check{
+		Name: "kms",
+		Hint: hint,
+		Run: func(ctx context.Context) error {
+			return kmsprovider.Check(ctx, cfg, settings)
+		},
+	}
+}
+
+// NewS3Client connects to S3 in the configured region, with the configured keys if there are any
+func NewS3Client(ctx context.Context, cfg models.Config) (*s3.Client, error) {
+	opts := []func(*awsConfig.LoadOptions) error{awsConfig.WithRegion(cfg.AWS.Region)}
+	if cfg.AWS.AccessKeyID != "" {
+		opts = append(opts, awsConfig.WithCredentialsProvider(aws.CredentialsProviderFunc(
+			func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{AccessKeyID: cfg.AWS.AccessKeyID, SecretAccessKey: cfg.AWS.SecretAccessKey}, nil
+			},
+		)))
+	}
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not load AWS configuration: %w", err)
+	}
+	return s3.NewFromConfig(awsCfg), nil
+}
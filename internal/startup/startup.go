@@ -0,0 +1,142 @@
+// Package startup checks that the services the API depends on are reachable and
+// usable before the server starts listening, so a misconfigured deployment fails
+// at boot with a diagnosis instead of on its first request.
+//
+// Cached reads are held in process by the shared core module, so there is no
+// external cache such as Redis to check.
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// Check verifies one dependency
+type Check struct {
+	Name string
+	// Hint is logged with a failure to point at the likely cause
+	Hint string
+	Run  func(ctx context.Context) error
+}
+
+// Policy bounds how long a dependency is given to become ready
+type Policy struct {
+	// Attempts is the number of times each check is run before it is given up on
+	Attempts int
+	// RetryDelay is the delay before the first retry, doubled for each one after
+	RetryDelay time.Duration
+	// Timeout bounds each attempt
+	Timeout time.Duration
+}
+
+// Failure describes a dependency that did not become ready
+type Failure struct {
+	Name     string
+	Hint     string
+	Attempts int
+	Err      error // Error from the last attempt
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s: %v after %d attempts", f.Name, f.Err, f.Attempts)
+}
+
+func (f Failure) Unwrap() error {
+	return f.Err
+}
+
+// Verify runs every check concurrently, retrying each one within the policy, and
+// logs the outcome for each dependency. It returns the failures joined together,
+// or nil when every dependency is ready.
+func Verify(ctx context.Context, checks []Check, p Policy) error {
+	logger := zaplogger.GetLogger()
+	if p.Attempts < 1 {
+		p.Attempts = 1
+	}
+
+	failures := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			started := time.Now()
+			attempts, err := run(ctx, check, p)
+			if err != nil {
+				failure := Failure{Name: check.Name, Hint: check.Hint, Attempts: attempts, Err: err}
+				logger.Error("Dependency unavailable",
+					zap.String("dependency", check.Name),
+					zap.Int("attempts", attempts),
+					zap.Error(err),
+					zap.String("hint", check.Hint),
+				)
+				failures[i] = failure
+				return
+			}
+			logger.Info("Dependency ready",
+				zap.String("dependency", check.Name),
+				zap.Int("attempts", attempts),
+				zap.Duration("took", time.Since(started)),
+			)
+		}(i, check)
+	}
+	wg.Wait()
+
+	return errors.Join(failures...)
+}
+
+// run runs a check until it succeeds or its attempts are used up, returning the number of attempts made
+func run(ctx context.Context, check Check, p Policy) (int, error) {
+	logger := zaplogger.GetLogger()
+	delay := p.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := attemptOnce(ctx, check, p.Timeout)
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= p.Attempts || ctx.Err() != nil {
+			return attempt, err
+		}
+		logger.Warn("Dependency check failed, retrying",
+			zap.String("dependency", check.Name),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func attemptOnce(ctx context.Context, check Check, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return check.Run(ctx)
+}
+
+// Names lists the dependencies that failed in an error returned by Verify
+func Names(err error) []string {
+	var names []string
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			var failure Failure
+			if errors.As(e, &failure) {
+				names = append(names, failure.Name)
+			}
+		}
+	}
+	return names
+}
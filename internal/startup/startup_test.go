@@ -0,0 +1,110 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/rachel-lawrie/verus_app_backend/internal/awsreplay"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = Policy{Attempts: 3, RetryDelay: time.Millisecond, Timeout: time.Second}
+
+// flaky fails the given number of times before succeeding
+func flaky(failures int) (*int, func(ctx context.Context) error) {
+	calls := 0
+	return &calls, func(ctx context.Context) error {
+		calls++
+		if calls <= failures {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+}
+
+func TestVerifyRetriesUntilReady(t *testing.T) {
+	calls, run := flaky(2)
+
+	err := Verify(context.Background(), []Check{{Name: "mongodb", Run: run}}, testPolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestVerifyReportsEveryFailedDependency(t *testing.T) {
+	mongoCalls, mongo := flaky(0)
+	s3Calls, s3 := flaky(10)
+	kmsCalls, kms := flaky(10)
+
+	err := Verify(context.Background(), []Check{
+		{Name: "mongodb", Run: mongo},
+		{Name: "s3", Hint: "check the bucket", Run: s3},
+		{Name: "kms", Run: kms},
+	}, testPolicy)
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"s3", "kms"}, Names(err))
+	assert.Equal(t, 1, *mongoCalls)
+	assert.Equal(t, 3, *s3Calls, "attempts are bounded by the policy")
+	assert.Equal(t, 3, *kmsCalls)
+
+	var failure Failure
+	assert.True(t, errors.As(err, &failure))
+	assert.Equal(t, Failure{Name: "s3", Hint: "check the bucket", Attempts: 3, Err: errors.New("connection refused")}, failure)
+}
+
+func TestVerifyBoundsEachAttempt(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	started := time.Now()
+	err := Verify(context.Background(), []Check{{Name: "s3", Run: hang}}, Policy{Attempts: 2, RetryDelay: time.Millisecond, Timeout: 10 * time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func TestVerifyStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls, run := flaky(10)
+
+	err := Verify(ctx, []Check{{Name: "kms", Run: run}}, Policy{Attempts: 5, RetryDelay: time.Hour})
+	assert.Error(t, err)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestRedisCheck(t *testing.T) {
+	server := miniredis.RunT(t)
+	check := RedisCheck("redis://" + server.Addr())
+	assert.NoError(t, Verify(context.Background(), []Check{check}, testPolicy))
+
+	server.Close()
+	err := Verify(context.Background(), []Check{check}, testPolicy)
+	assert.Equal(t, []string{"redis"}, Names(err))
+	var failure Failure
+	require.True(t, errors.As(err, &failure))
+	assert.Equal(t, 3, failure.Attempts, "attempts are bounded by the policy")
+}
+
+func TestChecksIncludeRedisWhenConfigured(t *testing.T) {
+	names := func(checks []Check) []string {
+		var names []string
+		for _, check := range checks {
+			names = append(names, check.Name)
+		}
+		return names
+	}
+	var settings config.Settings
+	settings.AWSReplay.Mode = string(awsreplay.ModeReplay)
+	assert.Equal(t, []string{"mongodb"}, names(Checks(models.Config{}, settings)))
+
+	settings.Sessions.RedisURL = "redis://redis:6379"
+	assert.Equal(t, []string{"mongodb", "redis"}, names(Checks(models.Config{}, settings)))
+}
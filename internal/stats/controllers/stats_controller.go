@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// GetStats is the handler function for the authenticated client's aggregate applicant statistics
+func GetStats(c *gin.Context, service interfaces.StatsService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := service.GetClientStats(c.Request.Context(), clientID)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error computing applicant stats", zap.Error(err), zap.String("clientID", clientID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not compute stats"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupStatsRouter(mockService *localMocks.MockStatsService, clientID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/stats", func(c *gin.Context) {
+		if clientID != "" {
+			c.Set("client_id", clientID)
+		}
+		GetStats(c, mockService)
+	})
+	return router
+}
+
+func TestGetStats(t *testing.T) {
+	stats := localModels.ApplicantStats{
+		ApplicantsByStatus: map[string]int64{"approved": 2},
+		RejectionReasons:   map[string]int64{},
+		DocumentTypes:      map[string]int64{},
+	}
+
+	tests := []struct {
+		name               string
+		clientID           string
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Stats for the client", clientID: "client1", expectedStatusCode: http.StatusOK},
+		{name: "No client", expectedStatusCode: http.StatusUnauthorized},
+		{name: "Aggregation fails", clientID: "client1", serviceErr: errors.New("boom"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockStatsService)
+			mockService.On("GetClientStats", mock.Anything, "client1").Return(stats, tt.serviceErr)
+			router := setupStatsRouter(mockService, tt.clientID)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/stats", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"approved":2`)
+			}
+			if tt.clientID == "" {
+				mockService.AssertNotCalled(t, "GetClientStats", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
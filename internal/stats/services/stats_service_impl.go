@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/accesslog"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultCacheTTL is how long a client's stats are reused before being computed again
+const defaultCacheTTL = 5 * time.Minute
+
+// StatsServiceImpl computes aggregate applicant statistics per client, caching them
+// briefly since the pipelines scan all of a client's applicants and documents
+type StatsServiceImpl struct {
+	ApplicantCollectionName string
+	DocumentCollectionName  string
+	cache                   *statsCache
+}
+
+var (
+	instance StatsServiceImpl
+	once     sync.Once
+)
+
+func GetStatsServiceImpl() StatsServiceImpl {
+	once.Do(func() {
+		instance = StatsServiceImpl{
+			ApplicantCollectionName: constants.CollectionApplicants,
+			DocumentCollectionName:  localConstants.CollectionDocuments,
+			cache:                   newStatsCache(defaultCacheTTL),
+		}
+	})
+	return instance
+}
+
+// GetClientStats returns the client's stats, computing them if none are cached
+func (s *StatsServiceImpl) GetClientStats(ctx context.Context, clientID string) (localModels.ApplicantStats, error) {
+	if stats, ok := s.cache.get(clientID); ok {
+		accesslog.NoteCache(ctx, true)
+		return stats, nil
+	}
+	accesslog.NoteCache(ctx, false)
+
+	applicants, err := s.applicantFacets(ctx, clientID)
+	if err != nil {
+		return localModels.ApplicantStats{}, err
+	}
+	documents, err := s.documentTypes(ctx, clientID)
+	if err != nil {
+		return localModels.ApplicantStats{}, err
+	}
+
+	stats := buildStats(applicants, documents)
+	stats.GeneratedAt = timestamp.Now()
+	s.cache.put(clientID, stats)
+	return stats, nil
+}
+
+// countRow is a value and the number of records that have it
+type countRow struct {
+	Value interface{} `bson:"_id"`
+	Count int64       `bson:"count"`
+}
+
+// applicantRows holds the results of the applicant pipeline's facets
+type applicantRows struct {
+	ByStatus     []countRow `bson:"by_status"`
+	Rejections   []countRow `bson:"rejections"`
+	Verification []struct {
+		AverageMillis float64 `bson:"average_ms"`
+		Count         int64   `bson:"count"`
+	} `bson:"verification"`
+}
+
+// applicantFacets counts the client's applicants by status and rejection reason, and
+// averages the time decided applicants took to verify, in a single pass
+func (s *StatsServiceImpl) applicantFacets(ctx context.Context, clientID string) (applicantRows, error) {
+	decided := []localModels.ApplicantStatus{localModels.ApplicantApproved, localModels.ApplicantRejected}
+	pipeline := bson.A{
+		bson.M{"$facet": bson.M{
+			"by_status": []bson.M{
+				{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+			},
+			"rejections": []bson.M{
+				{"$match": bson.M{"status": localModels.ApplicantRejected}},
+				{"$group": bson.M{"_id": "$review.reason_code", "count": bson.M{"$sum": 1}}},
+			},
+			"verification": []bson.M{
+				{"$match": bson.M{"status": bson.M{"$in": decided}, "review.decided_at": bson.M{"$type": "date"}}},
+				{"$group": bson.M{
+					"_id":        nil,
+					"average_ms": bson.M{"$avg": bson.M{"$subtract": []string{"$review.decided_at", "$created_at"}}},
+					"count":      bson.M{"$sum": 1},
+				}},
+			},
+		}},
+	}
+
+	var rows []applicantRows
+	applicants := tenant.Guard(common.GetCollection(s.ApplicantCollectionName))
+	cursor, err := applicants.Aggregate(ctx, tenant.Of(clientID).With("deleted", false), pipeline)
+	if err != nil {
+		return applicantRows{}, fmt.Errorf("failed to aggregate applicant stats: %w", err)
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &rows); err != nil {
+		return applicantRows{}, fmt.Errorf("failed to decode applicant stats: %w", err)
+	}
+	if len(rows) == 0 {
+		return applicantRows{}, nil
+	}
+	return rows[0], nil
+}
+
+// documentTypeRow is a document type and the number of the client's documents of that type
+type documentTypeRow struct {
+	Type  models.DocumentType `bson:"_id"`
+	Count int64               `bson:"count"`
+}
+
+// documentTypes counts the client's documents by type
+func (s *StatsServiceImpl) documentTypes(ctx context.Context, clientID string) ([]documentTypeRow, error) {
+	pipeline := bson.A{
+		bson.M{"$group": bson.M{"_id": "$document_type", "count": bson.M{"$sum": 1}}},
+	}
+
+	var rows []documentTypeRow
+	documents := tenant.Guard(common.GetCollection(s.DocumentCollectionName))
+	cursor, err := documents.Aggregate(ctx, tenant.Of(clientID).With("deleted", false), pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate document stats: %w", err)
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode document stats: %w", err)
+	}
+	return rows, nil
+}
+
+// buildStats folds the pipeline results into stats. Applicants without a status or
+// rejections without a reason code are counted under "unknown".
+func buildStats(applicants applicantRows, documents []documentTypeRow) localModels.ApplicantStats {
+	stats := localModels.ApplicantStats{
+		ApplicantsByStatus: countsByStatus(applicants.ByStatus),
+		RejectionReasons:   countsByValue(applicants.Rejections),
+		DocumentTypes:      make(map[string]int64, len(documents)),
+	}
+	if len(applicants.Verification) > 0 {
+		verification := applicants.Verification[0]
+		stats.VerifiedApplicants = verification.Count
+		stats.AverageTimeToVerificationSeconds = verification.AverageMillis / 1000
+	}
+	for _, row := range documents {
+		stats.DocumentTypes[row.Type.String()] += row.Count
+	}
+	return stats
+}
+
+// countsByStatus names the statuses applicants are grouped by, which are stored as ints,
+// or as strings by releases before them
+func countsByStatus(rows []countRow) map[string]int64 {
+	named := make([]countRow, len(rows))
+	for i, row := range rows {
+		named[i] = row
+		var core int64
+		switch value := row.Value.(type) {
+		case int32:
+			core = int64(value)
+		case int64:
+			core = value
+		default:
+			continue
+		}
+		if status, err := localModels.ApplicantStatusFromCore(models.ApplicantStatus(core)); err == nil {
+			named[i].Value = string(status)
+		}
+	}
+	return countsByValue(named)
+}
+
+func countsByValue(rows []countRow) map[string]int64 {
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		key, ok := row.Value.(string)
+		if !ok || key == "" {
+			key = "unknown"
+		}
+		counts[key] += row.Count
+	}
+	return counts
+}
+
+// statsCache keeps each client's stats for a fixed time. It is shared by copies of the service.
+type statsCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]localModels.ApplicantStats
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{ttl: ttl, now: time.Now, entries: make(map[string]localModels.ApplicantStats)}
+}
+
+func (c *statsCache) get(clientID string) (localModels.ApplicantStats, bool) {
+	if c == nil || c.ttl <= 0 {
+		return localModels.ApplicantStats{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats, ok := c.entries[clientID]
+	if !ok || c.now().Sub(stats.GeneratedAt) >= c.ttl {
+		delete(c.entries, clientID)
+		return localModels.ApplicantStats{}, false
+	}
+	return stats, true
+}
+
+func (c *statsCache) put(clientID string, stats localModels.ApplicantStats) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[clientID] = stats
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildStats(t *testing.T) {
+	applicants := applicantRows{
+		ByStatus: []countRow{
+			{Value: int32(2), Count: 4},
+			{Value: string(localModels.ApplicantApproved), Count: 1},
+			{Value: int64(3), Count: 2},
+			{Value: nil, Count: 1},
+		},
+		Rejections: []countRow{
+			{Value: "document_forged", Count: 1},
+			{Value: "", Count: 1},
+		},
+	}
+	applicants.Verification = append(applicants.Verification, struct {
+		AverageMillis float64 `bson:"average_ms"`
+		Count         int64   `bson:"count"`
+	}{AverageMillis: 90000, Count: 7})
+
+	stats := buildStats(applicants, nil)
+
+	assert.Equal(t, map[string]int64{"approved": 5, "rejected": 2, "unknown": 1}, stats.ApplicantsByStatus)
+	assert.Equal(t, map[string]int64{"document_forged": 1, "unknown": 1}, stats.RejectionReasons)
+	assert.Equal(t, int64(7), stats.VerifiedApplicants)
+	assert.Equal(t, 90.0, stats.AverageTimeToVerificationSeconds)
+	assert.Empty(t, stats.DocumentTypes)
+}
+
+func TestBuildStatsWithoutApplicants(t *testing.T) {
+	stats := buildStats(applicantRows{}, nil)
+
+	assert.NotNil(t, stats.ApplicantsByStatus)
+	assert.NotNil(t, stats.RejectionReasons)
+	assert.Zero(t, stats.VerifiedApplicants)
+	assert.Zero(t, stats.AverageTimeToVerificationSeconds)
+}
+
+func TestStatsCache(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	cache := newStatsCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.get("client1")
+	assert.False(t, ok)
+
+	cache.put("client1", localModels.ApplicantStats{VerifiedApplicants: 3, GeneratedAt: now})
+	stats, ok := cache.get("client1")
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), stats.VerifiedApplicants)
+
+	_, ok = cache.get("client2")
+	assert.False(t, ok, "stats are kept per client")
+
+	now = now.Add(time.Minute)
+	_, ok = cache.get("client1")
+	assert.False(t, ok, "stats expire after the TTL")
+}
+
+func TestStatsCacheDisabled(t *testing.T) {
+	cache := newStatsCache(0)
+	cache.put("client1", localModels.ApplicantStats{GeneratedAt: time.Now()})
+
+	_, ok := cache.get("client1")
+	assert.False(t, ok)
+}
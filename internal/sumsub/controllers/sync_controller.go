@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// SyncApplicant is the handler function for pulling an applicant's latest state from Sumsub
+func SyncApplicant(c *gin.Context, service interfaces.SumsubSyncService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	applicantID := c.Param("id")
+	sync, err := service.SyncApplicant(c.Request.Context(), clientID, applicantID, localModels.SumsubSyncRequested)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, sync)
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	default:
+		zaplogger.GetLogger().Error("Error syncing applicant from Sumsub", zap.Error(err), zap.String("applicantID", applicantID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not sync applicant"})
+	}
+}
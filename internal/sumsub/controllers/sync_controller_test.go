@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/sumsub/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSyncApplicant(t *testing.T) {
+	tests := []struct {
+		name               string
+		err                error
+		expectedStatusCode int
+	}{
+		{"Synced", nil, http.StatusOK},
+		{"Unknown applicant", apperr.ErrApplicantNotFound, http.StatusNotFound},
+		{"Not sent to Sumsub", services.ErrSumsubApplicantNotFound, http.StatusNotFound},
+		{"Changed meanwhile", services.ErrSyncConflict, http.StatusConflict},
+		{"Sync disabled", services.ErrSyncDisabled, http.StatusServiceUnavailable},
+		{"Sumsub down", fmt.Errorf("%w: responded with status 500", services.ErrSumsubUnavailable), http.StatusBadGateway},
+		{"Other error", errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockSumsubSyncService)
+			mockService.On("SyncApplicant", mock.Anything, "client1", "app1", localModels.SumsubSyncRequested).
+				Return(localModels.SumsubSync{SyncID: "sync1", ApplicantID: "app1"}, tt.err)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) { c.Set("client_id", "client1") })
+			router.POST("/applicants/:id/sync", func(c *gin.Context) { SyncApplicant(c, mockService) })
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/applicants/app1/sync", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.err == nil {
+				assert.Contains(t, w.Body.String(), `"sync_id":"sync1"`)
+				assert.NotContains(t, w.Body.String(), "client_id")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	"github.com/rachel-lawrie/verus_app_backend/internal/egress"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+const defaultSumsubBaseURL = "https://api.sumsub.com"
+
+var (
+	// ErrSumsubApplicantNotFound is returned when Sumsub has no applicant for one of ours
+	ErrSumsubApplicantNotFound = apperr.NotFound("sumsub_applicant_not_found", "applicant is not known to Sumsub")
+	// ErrSumsubUnavailable is returned when Sumsub could not be reached or refused a call
+	ErrSumsubUnavailable = apperr.ProviderUnavailable("sumsub_unavailable", "sumsub is unavailable")
+)
+
+// SumsubClient calls Sumsub's API, signing each call with the app's secret key
+type SumsubClient struct {
+	BaseURL    string
+	AppToken   string
+	SecretKey  string
+	HTTPClient *http.Client
+}
+
+// NewSumsubClient creates a client for the configured Sumsub app
+func NewSumsubClient(settings config.SumsubSettings) *SumsubClient {
+	baseURL := settings.BaseURL
+	if baseURL == "" {
+		baseURL = defaultSumsubBaseURL
+	}
+	return &SumsubClient{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		AppToken:   settings.AppToken,
+		SecretKey:  settings.SecretKey,
+		HTTPClient: egress.Client(10 * time.Second),
+	}
+}
+
+// Applicant reads the Sumsub applicant created for one of our applicants, which Sumsub
+// knows by our applicant ID as its external user ID
+func (s *SumsubClient) Applicant(ctx context.Context, applicantID string) (localModels.SumsubApplicantData, error) {
+	body, err := s.get(ctx, "/resources/applicants/-;externalUserId="+url.PathEscape(applicantID)+"/one")
+	if err != nil {
+		return localModels.SumsubApplicantData{}, err
+	}
+	var applicant localModels.SumsubApplicantData
+	if err := json.Unmarshal(body, &applicant); err != nil {
+		return localModels.SumsubApplicantData{}, fmt.Errorf("%w: could not decode applicant: %v", ErrSumsubUnavailable, err)
+	}
+	applicant.Raw = body
+	return applicant, nil
+}
+
+// Inspections reads Sumsub's verdict on each document set of a Sumsub applicant, ordered
+// by document set. Sets the applicant has not submitted yet are left out.
+func (s *SumsubClient) Inspections(ctx context.Context, sumsubID string) ([]localModels.SumsubInspection, error) {
+	body, err := s.get(ctx, "/resources/applicants/"+url.PathEscape(sumsubID)+"/requiredIdDocsStatus")
+	if err != nil {
+		return nil, err
+	}
+	var sets map[string]*struct {
+		IDDocType    string                          `json:"idDocType"`
+		ReviewResult *localModels.SumsubReviewResult `json:"reviewResult"`
+	}
+	if err := json.Unmarshal(body, &sets); err != nil {
+		return nil, fmt.Errorf("%w: could not decode inspections: %v", ErrSumsubUnavailable, err)
+	}
+
+	inspections := []localModels.SumsubInspection{}
+	for name, set := range sets {
+		if set == nil {
+			continue
+		}
+		inspection := localModels.SumsubInspection{DocSet: name, DocType: set.IDDocType}
+		if set.ReviewResult != nil {
+			inspection.Answer, inspection.RejectLabels = set.ReviewResult.ReviewAnswer, set.ReviewResult.RejectLabels
+		}
+		inspections = append(inspections, inspection)
+	}
+	sort.Slice(inspections, func(i, j int) bool { return inspections[i].DocSet < inspections[j].DocSet })
+	return inspections, nil
+}
+
+// get makes a signed GET request to Sumsub and returns the response body
+func (s *SumsubClient) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.BaseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sumsub URL: %w", err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-App-Token", s.AppToken)
+	req.Header.Set("X-App-Access-Ts", ts)
+	req.Header.Set("X-App-Access-Sig", signSumsubRequest(s.SecretKey, ts, http.MethodGet, req.URL.RequestURI(), nil))
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSumsubUnavailable, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read response: %v", ErrSumsubUnavailable, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrSumsubApplicantNotFound
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, fmt.Errorf("%w: responded with status %d", ErrSumsubUnavailable, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// signSumsubRequest computes X-App-Access-Sig: the hex HMAC-SHA256, keyed with the app's
+// secret key, of the timestamp, method, path with query, and body of a request
+func signSumsubRequest(secretKey, ts, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(ts + method + requestURI))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
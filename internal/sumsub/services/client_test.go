@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSumsubClientSignsAndDecodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig := signSumsubRequest("secret", r.Header.Get("X-App-Access-Ts"), r.Method, r.URL.RequestURI(), nil)
+		if r.Header.Get("X-App-Token") != "token" || r.Header.Get("X-App-Access-Sig") != sig {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/resources/applicants/-;externalUserId=app1/one":
+			w.Write([]byte(`{"id":"sumsub1","review":{"reviewStatus":"completed","reviewResult":{"reviewAnswer":"RED","reviewRejectType":"RETRY"}},"info":{"firstName":"Ana"}}`))
+		case "/resources/applicants/sumsub1/requiredIdDocsStatus":
+			w.Write([]byte(`{"SELFIE":{"idDocType":"SELFIE","reviewResult":{"reviewAnswer":"GREEN"}},"IDENTITY":{"idDocType":"PASSPORT","reviewResult":{"reviewAnswer":"RED","rejectLabels":["BAD_PHOTO"]}},"PROOF_OF_RESIDENCE":null}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &SumsubClient{BaseURL: server.URL, AppToken: "token", SecretKey: "secret", HTTPClient: server.Client()}
+
+	applicant, err := client.Applicant(context.Background(), "app1")
+	require.NoError(t, err)
+	assert.Equal(t, "sumsub1", applicant.ID)
+	assert.Equal(t, "RETRY", applicant.Review.ReviewResult.ReviewRejectType)
+	assert.Equal(t, "Ana", applicant.Info.FirstName)
+	assert.Contains(t, string(applicant.Raw), `"id":"sumsub1"`)
+
+	inspections, err := client.Inspections(context.Background(), "sumsub1")
+	require.NoError(t, err)
+	require.Len(t, inspections, 2)
+	assert.Equal(t, "IDENTITY", inspections[0].DocSet)
+	assert.Equal(t, []string{"BAD_PHOTO"}, inspections[0].RejectLabels)
+	assert.Equal(t, "SELFIE", inspections[1].DocSet)
+
+	_, err = client.Applicant(context.Background(), "app2")
+	assert.ErrorIs(t, err, ErrSumsubApplicantNotFound)
+
+	client.SecretKey = "wrong"
+	_, err = client.Applicant(context.Background(), "app1")
+	assert.ErrorIs(t, err, ErrSumsubUnavailable)
+}
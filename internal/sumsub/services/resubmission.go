@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/tenant"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	zap "go.uber.org/zap"
+)
+
+// sumsubProvider names Sumsub on the resubmissions it asks for
+const sumsubProvider = "sumsub"
+
+// sumsubGuidance maps Sumsub's reject labels onto resubmission guidance. Labels without a
+// counterpart, such as FORGERY or SELFIE_MISMATCH, are for a reviewer rather than
+// something the applicant can fix with a better photo.
+var sumsubGuidance = map[string]localModels.ResubmissionGuidance{
+	"UNSATISFACTORY_PHOTOS":      localModels.GuidanceBlurryImage,
+	"LOW_QUALITY":                localModels.GuidanceBlurryImage,
+	"DOCUMENT_PAGE_MISSING":      localModels.GuidanceCroppedDocument,
+	"INCOMPLETE_DOCUMENT":        localModels.GuidanceCroppedDocument,
+	"EXPIRATION_DATE":            localModels.GuidanceExpiredDocument,
+	"PROBLEMATIC_APPLICANT_DATA": localModels.GuidanceNameMismatch,
+	"REQUESTED_DATA_MISMATCH":    localModels.GuidanceNameMismatch,
+}
+
+// sumsubDocSets are the document types of ours each of Sumsub's document sets is made of
+var sumsubDocSets = map[string][]models.DocumentType{
+	"IDENTITY":           {models.DocumentPassport, models.DocumentDriverLicense, models.DocumentNationalID, models.DocumentIDCard},
+	"SELFIE":             {models.DocumentSelfie, models.DocumentVideoSelfie},
+	"PROOF_OF_RESIDENCE": {models.DocumentUtilityBill, models.DocumentBankStatement, models.DocumentProofOfAddress},
+}
+
+// sumsubDocTypes maps the document types Sumsub names in a set onto ours
+var sumsubDocTypes = map[string]models.DocumentType{
+	"PASSPORT":       models.DocumentPassport,
+	"DRIVERS":        models.DocumentDriverLicense,
+	"ID_CARD":        models.DocumentIDCard,
+	"SELFIE":         models.DocumentSelfie,
+	"VIDEO_SELFIE":   models.DocumentVideoSelfie,
+	"UTILITY_BILL":   models.DocumentUtilityBill,
+	"BANK_STATEMENT": models.DocumentBankStatement,
+}
+
+// guidance maps reject labels onto guidance, each given once in the order it first comes up
+func guidance(labels []string) []localModels.ResubmissionGuidance {
+	found := []localModels.ResubmissionGuidance{}
+	seen := map[localModels.ResubmissionGuidance]bool{}
+	for _, label := range labels {
+		if g, ok := sumsubGuidance[label]; ok && !seen[g] {
+			seen[g] = true
+			found = append(found, g)
+		}
+	}
+	return found
+}
+
+// resubmission is one of the applicant's documents Sumsub asked for again
+type resubmission struct {
+	document localModels.DocumentRecord
+	labels   []string
+}
+
+// resubmissions matches the document sets Sumsub rejected to the applicant's documents.
+// A set is matched to the documents of the type Sumsub names for it, or of any type the
+// set is made of when it names none we know.
+func resubmissions(inspections []localModels.SumsubInspection, documents []localModels.DocumentRecord) []resubmission {
+	var found []resubmission
+	for _, inspection := range inspections {
+		if inspection.Answer != localModels.SumsubAnswerRed {
+			continue
+		}
+		types := sumsubDocSets[inspection.DocSet]
+		if docType, ok := sumsubDocTypes[inspection.DocType]; ok {
+			types = []models.DocumentType{docType}
+		}
+		for _, document := range documents {
+			for _, docType := range types {
+				if document.DocumentType == docType {
+					found = append(found, resubmission{document: document, labels: inspection.RejectLabels})
+					break
+				}
+			}
+		}
+	}
+	return found
+}
+
+// requestResubmission records on each document Sumsub asked for again what the applicant
+// should fix, and tells the client in an applicant.resubmission_required webhook. The
+// applicant's status has already changed, so failures are logged rather than returned.
+func (s *SumsubSyncServiceImpl) requestResubmission(ctx context.Context, applicant syncApplicant, inspections []localModels.SumsubInspection, now time.Time) {
+	logger := zaplogger.GetLogger().With(zap.String("applicantID", applicant.ApplicantID))
+
+	documents, err := s.applicantDocuments(ctx, applicant)
+	if err != nil {
+		logger.Error("Error reading documents to resubmit", zap.Error(err))
+		return
+	}
+	data := localModels.ApplicantResubmissionData{
+		ApplicantID: applicant.ApplicantID,
+		Provider:    sumsubProvider,
+		Documents:   []localModels.ResubmittedDocument{},
+	}
+	collection := tenant.Guard(common.GetCollection(s.DocumentCollectionName))
+	for _, r := range resubmissions(inspections, documents) {
+		request := localModels.Resubmission{
+			Provider:        sumsubProvider,
+			Guidance:        guidance(r.labels),
+			ProviderReasons: r.labels,
+			RequestedAt:     now,
+		}
+		filter := tenant.Of(applicant.ClientID).With("applicant_id", applicant.ApplicantID).With("document_id", r.document.DocumentID)
+		err := mongoretry.Write(ctx, "request document resubmission", func(ctx context.Context) error {
+			_, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"resubmission": request}})
+			return err
+		})
+		if err != nil {
+			logger.Error("Error recording document resubmission", zap.Error(err), zap.String("documentID", r.document.DocumentID))
+		}
+		data.Documents = append(data.Documents, localModels.ResubmittedDocument{
+			DocumentID:   r.document.DocumentID,
+			DocumentType: r.document.DocumentType.String(),
+			Guidance:     request.Guidance,
+		})
+	}
+
+	if s.Webhooks == nil {
+		return
+	}
+	if s.Applicants != nil {
+		data.Annotations, err = s.Applicants.Annotations(ctx, applicant.ClientID, applicant.ApplicantID)
+		if err != nil {
+			logger.Warn("Error reading applicant annotations", zap.Error(err))
+		}
+	}
+	if err := s.Webhooks.Emit(ctx, applicant.ClientID, localModels.WebhookApplicantResubmissionRequired, data); err != nil {
+		logger.Error("Error emitting resubmission required webhook", zap.Error(err))
+	}
+}
+
+// applicantDocuments reads the documents of an applicant that are not deleted
+func (s *SumsubSyncServiceImpl) applicantDocuments(ctx context.Context, applicant syncApplicant) ([]localModels.DocumentRecord, error) {
+	filter := tenant.Of(applicant.ClientID).With("applicant_id", applicant.ApplicantID).With("deleted", false)
+	cursor, err := tenant.Guard(common.GetCollection(s.DocumentCollectionName)).Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find documents: %w", err)
+	}
+	var documents []localModels.DocumentRecord
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, fmt.Errorf("failed to read documents: %w", err)
+	}
+	return documents, nil
+}
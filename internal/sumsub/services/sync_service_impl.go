@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localInterfaces "github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/constants"
+	"github.com/rachel-lawrie/verus_backend_core/models_sumsub"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+const (
+	defaultSyncStaleAfter = time.Hour
+	defaultSyncBatchSize  = 100
+)
+
+var (
+	// ErrSyncDisabled is returned when no Sumsub app is configured
+	ErrSyncDisabled = apperr.Unavailable("sumsub_sync_disabled", "sumsub sync is not configured")
+	// ErrSyncConflict is returned when the applicant changed while it was being synced
+	ErrSyncConflict = apperr.Conflict("sumsub_sync_conflict", "applicant changed during sync, try again")
+)
+
+// SumsubSyncServiceImpl pulls applicants' review state back from Sumsub and reconciles it
+// into our records. Sumsub's view is stored whole as the applicant's sumsub_applicant; our
+// own fields only follow Sumsub where that cannot undo a reviewer's decision or data the
+// client gave us, and each sync records what it changed and where the two disagree.
+type SumsubSyncServiceImpl struct {
+	Client                  localInterfaces.SumsubClient // Sumsub's API; nil disables syncing
+	CollectionName          string
+	ApplicantCollectionName string
+	DocumentCollectionName  string
+	Webhooks                localInterfaces.WebhookService   // Told when Sumsub asks for documents again; nil sends no webhooks
+	Applicants              localInterfaces.AnnotationReader // Annotations echoed in webhooks; nil leaves them out
+	StaleAfter              time.Duration                    // The sync job leaves applicants synced more recently than this
+	BatchSize               int                              // Applicants synced per run of the sync job
+}
+
+var (
+	instance SumsubSyncServiceImpl
+	once     sync.Once
+)
+
+func GetSumsubSyncServiceImpl() SumsubSyncServiceImpl {
+	once.Do(func() {
+		instance = SumsubSyncServiceImpl{
+			CollectionName:          localConstants.CollectionSumsubSyncs,
+			ApplicantCollectionName: constants.CollectionApplicants,
+			DocumentCollectionName:  localConstants.CollectionDocuments,
+			StaleAfter:              defaultSyncStaleAfter,
+			BatchSize:               defaultSyncBatchSize,
+		}
+	})
+	return instance
+}
+
+// syncApplicant is the part of the applicant record a sync reads
+type syncApplicant struct {
+	ApplicantID string                      `bson:"applicant_id"`
+	ClientID    string                      `bson:"client_id"`
+	FirstName   string                      `bson:"first_name"`
+	LastName    string                      `bson:"last_name"`
+	Status      localModels.ApplicantStatus `bson:"status"`
+	Review      struct {
+		DecidedBy *string `bson:"decided_by"`
+	} `bson:"review"`
+	Sumsub *localModels.SumsubState `bson:"sumsub_sync"`
+}
+
+// SyncApplicant reconciles one of the client's applicants with Sumsub. An empty clientID
+// syncs the applicant whichever client it belongs to, as the sync job does.
+func (s *SumsubSyncServiceImpl) SyncApplicant(ctx context.Context, clientID, applicantID, trigger string) (localModels.SumsubSync, error) {
+	if s.Client == nil {
+		return localModels.SumsubSync{}, ErrSyncDisabled
+	}
+	applicants := common.GetCollection(s.ApplicantCollectionName)
+
+	filter := bson.M{"applicant_id": applicantID, "deleted": false}
+	if clientID != "" {
+		filter["client_id"] = clientID
+	}
+	var applicant syncApplicant
+	opts := options.FindOne().SetProjection(bson.M{
+		"applicant_id": 1, "client_id": 1, "first_name": 1, "last_name": 1, "status": 1, "review.decided_by": 1, "sumsub_sync": 1,
+	})
+	err := applicants.FindOne(ctx, filter, opts).Decode(&applicant)
+	if err == mongo.ErrNoDocuments {
+		return localModels.SumsubSync{}, apperr.ErrApplicantNotFound
+	}
+	if err != nil {
+		return localModels.SumsubSync{}, fmt.Errorf("failed to look up applicant: %w", err)
+	}
+
+	data, err := s.Client.Applicant(ctx, applicantID)
+	if err != nil {
+		return localModels.SumsubSync{}, err
+	}
+	inspections, err := s.Client.Inspections(ctx, data.ID)
+	if err != nil {
+		return localModels.SumsubSync{}, err
+	}
+
+	now := timestamp.Now()
+	record := localModels.SumsubSync{
+		SyncID:       ids.New(),
+		ApplicantID:  applicantID,
+		ClientID:     applicant.ClientID,
+		Trigger:      trigger,
+		SumsubID:     data.ID,
+		ReviewStatus: data.Review.ReviewStatus,
+		ReviewAnswer: data.Review.ReviewResult.ReviewAnswer,
+		Inspections:  inspections,
+		SyncedAt:     now,
+	}
+	set := reconcile(applicant, data, &record)
+	if err := s.apply(ctx, applicants, applicant, data, set, now); err != nil {
+		return localModels.SumsubSync{}, err
+	}
+	if set["status"] == localModels.ApplicantResubmissionRequired {
+		s.requestResubmission(ctx, applicant, inspections, now)
+	}
+
+	// The applicant is already synced, so a lost record is logged rather than failing the sync
+	err = mongoretry.Write(ctx, "record sumsub sync", func(ctx context.Context) error {
+		_, err := common.GetCollection(s.CollectionName).InsertOne(ctx, record)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		zaplogger.GetLogger().Error("Error recording Sumsub sync", zap.Error(err), zap.String("applicantID", applicantID))
+	}
+	return record, nil
+}
+
+// apply writes a sync to the applicant. The update only matches while the applicant has
+// the status the sync was worked out from, so a decision made meanwhile is not undone.
+func (s *SumsubSyncServiceImpl) apply(ctx context.Context, applicants common.CollectionInterface, applicant syncApplicant, data localModels.SumsubApplicantData, set bson.M, now time.Time) error {
+	var sumsubApplicant models_sumsub.Applicant
+	if err := json.Unmarshal(data.Raw, &sumsubApplicant); err != nil {
+		return fmt.Errorf("%w: could not decode applicant: %v", ErrSumsubUnavailable, err)
+	}
+	set["sumsub_applicant"] = sumsubApplicant
+	set["sumsub_sync"] = localModels.SumsubState{
+		SumsubID:     data.ID,
+		ReviewStatus: data.Review.ReviewStatus,
+		ReviewAnswer: data.Review.ReviewResult.ReviewAnswer,
+		SyncedAt:     now,
+	}
+	set["updated_at"] = now
+
+	filter := bson.M{"applicant_id": applicant.ApplicantID, "client_id": applicant.ClientID, "deleted": false, "status": applicant.Status}
+	var matched int64
+	err := mongoretry.Write(ctx, "sync applicant from sumsub", func(ctx context.Context) error {
+		result, err := applicants.UpdateOne(ctx, filter, bson.M{"$set": set})
+		if err != nil {
+			return err
+		}
+		matched = result.MatchedCount
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return ErrSyncConflict
+	}
+	return nil
+}
+
+// reconcile works out which of our fields follow Sumsub, noting each change and
+// disagreement on the sync record, and returns the fields to set
+func reconcile(applicant syncApplicant, data localModels.SumsubApplicantData, record *localModels.SumsubSync) bson.M {
+	set := bson.M{}
+	record.Changes, record.Conflicts = []localModels.SyncChange{}, []localModels.SyncConflict{}
+
+	var previous localModels.SumsubState
+	if applicant.Sumsub != nil {
+		previous = *applicant.Sumsub
+	}
+	if previous.ReviewStatus != data.Review.ReviewStatus {
+		record.Changes = append(record.Changes, localModels.SyncChange{Field: "sumsub_sync.review_status", From: previous.ReviewStatus, To: data.Review.ReviewStatus})
+	}
+	if previous.ReviewAnswer != data.Review.ReviewResult.ReviewAnswer {
+		record.Changes = append(record.Changes, localModels.SyncChange{Field: "sumsub_sync.review_answer", From: previous.ReviewAnswer, To: data.Review.ReviewResult.ReviewAnswer})
+	}
+
+	if status, ok := sumsubStatus(data.Review); ok && status != applicant.Status {
+		switch {
+		case applicant.Review.DecidedBy != nil:
+			record.Conflicts = append(record.Conflicts, localModels.SyncConflict{
+				Field: "status", Local: string(applicant.Status), Sumsub: string(status),
+				Resolution: localModels.SyncKeptLocal, Reason: "a reviewer decided the applicant",
+			})
+		case applicant.Status == localModels.ApplicantApproved || applicant.Status == localModels.ApplicantRejected:
+			record.Conflicts = append(record.Conflicts, localModels.SyncConflict{
+				Field: "status", Local: string(applicant.Status), Sumsub: string(status),
+				Resolution: localModels.SyncKeptLocal, Reason: "the applicant's status is final",
+			})
+		default:
+			record.Changes = append(record.Changes, localModels.SyncChange{Field: "status", From: string(applicant.Status), To: string(status)})
+			set["status"] = status
+			set["review.entered_at"] = record.SyncedAt
+		}
+	}
+
+	// Names the client gave us stay as they are; a different reading from the documents is for a reviewer to look at
+	for _, field := range []struct{ name, local, sumsub string }{
+		{"first_name", applicant.FirstName, data.Info.FirstName},
+		{"last_name", applicant.LastName, data.Info.LastName},
+	} {
+		if field.sumsub != "" && !strings.EqualFold(strings.TrimSpace(field.local), strings.TrimSpace(field.sumsub)) {
+			record.Conflicts = append(record.Conflicts, localModels.SyncConflict{
+				Field: field.name, Local: field.local, Sumsub: field.sumsub,
+				Resolution: localModels.SyncKeptLocal, Reason: "extracted from the applicant's documents",
+			})
+		}
+	}
+	return set
+}
+
+// sumsubStatus maps a Sumsub review onto our applicant status. ok is false for review
+// states with no counterpart, which leave our status as it is.
+func sumsubStatus(review localModels.SumsubReview) (status localModels.ApplicantStatus, ok bool) {
+	switch review.ReviewStatus {
+	case localModels.SumsubReviewInit:
+		return localModels.ApplicantPending, true
+	case localModels.SumsubReviewPending, localModels.SumsubReviewPrechecked, localModels.SumsubReviewQueued, localModels.SumsubReviewOnHold:
+		return localModels.ApplicantInReview, true
+	case localModels.SumsubReviewCompleted:
+		switch review.ReviewResult.ReviewAnswer {
+		case localModels.SumsubAnswerGreen:
+			return localModels.ApplicantApproved, true
+		case localModels.SumsubAnswerRed:
+			if review.ReviewResult.ReviewRejectType == localModels.SumsubRejectRetry {
+				return localModels.ApplicantResubmissionRequired, true
+			}
+			return localModels.ApplicantRejected, true
+		}
+	}
+	return "", false
+}
+
+// SyncStale syncs the applicants still awaiting a decision that have not been synced
+// for StaleAfter, least recently synced first. It is run as the sumsub_sync job.
+func (s *SumsubSyncServiceImpl) SyncStale(ctx context.Context) error {
+	if s.Client == nil {
+		return ErrSyncDisabled
+	}
+	applicants := common.GetCollection(s.ApplicantCollectionName)
+	cutoff := timestamp.Now().Add(-s.StaleAfter)
+
+	filter := bson.M{
+		"deleted": false,
+		"status":  bson.M{"$in": []localModels.ApplicantStatus{localModels.ApplicantPending, localModels.ApplicantInReview, localModels.ApplicantResubmissionRequired}},
+		"$or": bson.A{
+			bson.M{"sumsub_sync.synced_at": bson.M{"$exists": false}},
+			bson.M{"sumsub_sync.synced_at": bson.M{"$lte": cutoff}},
+		},
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"applicant_id": 1}).
+		SetSort(bson.D{{Key: "sumsub_sync.synced_at", Value: 1}}).
+		SetLimit(int64(s.BatchSize))
+	cursor, err := applicants.Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("failed to find applicants to sync: %w", err)
+	}
+	var stale []struct {
+		ApplicantID string `bson:"applicant_id"`
+	}
+	if err := cursor.All(ctx, &stale); err != nil {
+		return fmt.Errorf("failed to read applicants to sync: %w", err)
+	}
+
+	logger := zaplogger.GetLogger()
+	synced := 0
+	for _, applicant := range stale {
+		_, err := s.SyncApplicant(ctx, "", applicant.ApplicantID, localModels.SumsubSyncScheduled)
+		switch {
+		case err == nil:
+			synced++
+		case errors.Is(err, ErrSumsubApplicantNotFound):
+			// Not sent to Sumsub yet; look again once it is stale
+			s.markChecked(ctx, applicants, applicant.ApplicantID)
+		case errors.Is(err, ErrSumsubUnavailable):
+			// The rest would fail the same way
+			return err
+		default:
+			logger.Error("Error syncing applicant from Sumsub", zap.Error(err), zap.String("applicantID", applicant.ApplicantID))
+		}
+	}
+	logger.Info("Applicants synced from Sumsub", zap.Int("synced", synced), zap.Int("stale", len(stale)))
+	return nil
+}
+
+// markChecked notes that an applicant Sumsub does not know was looked for, so the sync
+// job moves on to other applicants
+func (s *SumsubSyncServiceImpl) markChecked(ctx context.Context, applicants common.CollectionInterface, applicantID string) {
+	update := bson.M{"$set": bson.M{"sumsub_sync.synced_at": timestamp.Now()}}
+	if _, err := applicants.UpdateOne(ctx, bson.M{"applicant_id": applicantID}, update); err != nil {
+		zaplogger.GetLogger().Error("Error marking applicant checked against Sumsub", zap.Error(err), zap.String("applicantID", applicantID))
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_backend_core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	reviewer := "admin1"
+	sumsub := func(status, answer, rejectType string) localModels.SumsubApplicantData {
+		data := localModels.SumsubApplicantData{ID: "sumsub1", Info: localModels.SumsubInfo{FirstName: "Ana", LastName: "García"}}
+		data.Review.ReviewStatus = status
+		data.Review.ReviewResult = localModels.SumsubReviewResult{ReviewAnswer: answer, ReviewRejectType: rejectType}
+		return data
+	}
+	tests := []struct {
+		name          string
+		status        localModels.ApplicantStatus
+		decidedBy     *string
+		lastName      string
+		data          localModels.SumsubApplicantData
+		wantStatus    localModels.ApplicantStatus // Empty when the status is left alone
+		wantConflicts []string
+	}{
+		{"Submitted", localModels.ApplicantPending, nil, "García", sumsub("pending", "", ""), localModels.ApplicantInReview, nil},
+		{"Approved", localModels.ApplicantInReview, nil, "García", sumsub("completed", "GREEN", ""), localModels.ApplicantApproved, nil},
+		{"Asked to resubmit", localModels.ApplicantInReview, nil, "García", sumsub("completed", "RED", "RETRY"), localModels.ApplicantResubmissionRequired, nil},
+		{"Rejected", localModels.ApplicantInReview, nil, "García", sumsub("completed", "RED", "FINAL"), localModels.ApplicantRejected, nil},
+		{"Already in step", localModels.ApplicantInReview, nil, "García", sumsub("queued", "", ""), "", nil},
+		{"Reviewer decided", localModels.ApplicantResubmissionRequired, &reviewer, "García", sumsub("completed", "GREEN", ""), "", []string{"status"}},
+		{"Final status", localModels.ApplicantRejected, nil, "García", sumsub("completed", "GREEN", ""), "", []string{"status"}},
+		{"Same name in other case", localModels.ApplicantInReview, nil, " garcía", sumsub("queued", "", ""), "", nil},
+		{"Different name", localModels.ApplicantInReview, nil, "Garcia Lopez", sumsub("queued", "", ""), "", []string{"last_name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applicant := syncApplicant{ApplicantID: "app1", FirstName: "Ana", LastName: tt.lastName, Status: tt.status}
+			applicant.Review.DecidedBy = tt.decidedBy
+			record := localModels.SumsubSync{SyncedAt: time.Now()}
+
+			set := reconcile(applicant, tt.data, &record)
+
+			if tt.wantStatus == "" {
+				assert.NotContains(t, set, "status")
+			} else {
+				assert.Equal(t, tt.wantStatus, set["status"])
+				assert.Contains(t, record.Changes, localModels.SyncChange{Field: "status", From: string(tt.status), To: string(tt.wantStatus)})
+			}
+			var conflicts []string
+			for _, conflict := range record.Conflicts {
+				assert.Equal(t, localModels.SyncKeptLocal, conflict.Resolution)
+				conflicts = append(conflicts, conflict.Field)
+			}
+			assert.Equal(t, tt.wantConflicts, conflicts)
+			assert.NotContains(t, set, "last_name")
+		})
+	}
+}
+
+func TestReconcileRecordsReviewChanges(t *testing.T) {
+	applicant := syncApplicant{Status: localModels.ApplicantInReview, Sumsub: &localModels.SumsubState{ReviewStatus: "pending"}}
+	data := localModels.SumsubApplicantData{}
+	data.Review.ReviewStatus = "onHold"
+	var record localModels.SumsubSync
+
+	reconcile(applicant, data, &record)
+
+	assert.Equal(t, []localModels.SyncChange{{Field: "sumsub_sync.review_status", From: "pending", To: "onHold"}}, record.Changes)
+	assert.Empty(t, record.Conflicts)
+}
+
+func TestSyncWithoutSumsub(t *testing.T) {
+	service := SumsubSyncServiceImpl{}
+
+	_, err := service.SyncApplicant(context.Background(), "client1", "app1", localModels.SumsubSyncRequested)
+	assert.ErrorIs(t, err, ErrSyncDisabled)
+	assert.ErrorIs(t, service.SyncStale(context.Background()), ErrSyncDisabled)
+}
+
+func TestGuidance(t *testing.T) {
+	assert.Equal(t, []localModels.ResubmissionGuidance{localModels.GuidanceBlurryImage, localModels.GuidanceExpiredDocument},
+		guidance([]string{"UNSATISFACTORY_PHOTOS", "FORGERY", "EXPIRATION_DATE", "LOW_QUALITY"}))
+	assert.Empty(t, guidance([]string{"SELFIE_MISMATCH"}))
+}
+
+func TestResubmissions(t *testing.T) {
+	document := func(id string, docType models.DocumentType) localModels.DocumentRecord {
+		var record localModels.DocumentRecord
+		record.DocumentID, record.DocumentType = id, docType
+		return record
+	}
+	passport, licence := document("doc_passport", models.DocumentPassport), document("doc_licence", models.DocumentDriverLicense)
+	selfie, bill := document("doc_selfie", models.DocumentSelfie), document("doc_bill", models.DocumentUtilityBill)
+	inspections := []localModels.SumsubInspection{
+		{DocSet: "IDENTITY", DocType: "PASSPORT", Answer: "RED", RejectLabels: []string{"DOCUMENT_PAGE_MISSING"}},
+		{DocSet: "PROOF_OF_RESIDENCE", Answer: "GREEN"},
+		{DocSet: "SELFIE", Answer: "RED", RejectLabels: []string{"UNSATISFACTORY_PHOTOS"}},
+	}
+
+	found := resubmissions(inspections, []localModels.DocumentRecord{passport, licence, selfie, bill})
+
+	assert.Equal(t, []resubmission{
+		{document: passport, labels: []string{"DOCUMENT_PAGE_MISSING"}},
+		{document: selfie, labels: []string{"UNSATISFACTORY_PHOTOS"}},
+	}, found)
+}
@@ -0,0 +1,244 @@
+// Package tenant keeps queries to the data of the client making the request. Filters are
+// built with Of or FromContext, which carry the client's ID, and run through Guard, which
+// refuses any filter without one. A query that forgets to pass the client then gets
+// ErrUnscoped instead of another client's records.
+//
+// Staff routes and background jobs that work across clients say so with AllClients. New
+// records are inserted through Guard too, which refuses one that names another client.
+//
+//	filter := tenant.FromContext(c).With("applicant_id", applicantID)
+//	err := tenant.Guard(collection).FindOne(ctx, filter).Decode(&doc)
+package tenant
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Field is the field every client-owned record keeps its client's ID in
+const Field = "client_id"
+
+// ErrUnscoped is returned for a query that names no client and was not marked as
+// working across clients, and for a record inserted without the filter's client
+var ErrUnscoped = errors.New("query is not scoped to a client")
+
+// ErrUnsupported is returned when the guarded collection cannot run an operation, as for
+// test doubles that only implement common.CollectionInterface
+var ErrUnsupported = errors.New("collection does not support the operation")
+
+// Filter is a query filter scoped to one client, or explicitly to every client
+type Filter struct {
+	clientID   string
+	all        bool
+	conditions bson.M
+}
+
+// Of returns a filter matching the client's records. An empty clientID makes a filter
+// that Guard refuses.
+func Of(clientID string) Filter {
+	return Filter{clientID: clientID}
+}
+
+// FromContext returns a filter matching the records of the client authenticated for
+// the request
+func FromContext(c *gin.Context) Filter {
+	return Of(c.GetString("client_id"))
+}
+
+// AllClients returns a filter matching every client's records, for staff and
+// background jobs
+func AllClients() Filter {
+	return Filter{all: true}
+}
+
+// With returns a copy of the filter that also requires field to equal value, or to
+// match it when value is an operator document. The client ID cannot be overridden.
+func (f Filter) With(field string, value interface{}) Filter {
+	conditions := make(bson.M, len(f.conditions)+1)
+	for k, v := range f.conditions {
+		conditions[k] = v
+	}
+	conditions[field] = value
+	f.conditions = conditions
+	return f
+}
+
+// ClientID returns the client the filter is scoped to, empty for AllClients
+func (f Filter) ClientID() string {
+	return f.clientID
+}
+
+// BSON returns the filter as a query document, or ErrUnscoped if it names no client
+func (f Filter) BSON() (bson.M, error) {
+	if f.clientID == "" && !f.all {
+		return nil, ErrUnscoped
+	}
+	filter := make(bson.M, len(f.conditions)+1)
+	for k, v := range f.conditions {
+		filter[k] = v
+	}
+	if !f.all {
+		filter[Field] = f.clientID
+	}
+	return filter, nil
+}
+
+// Collection runs scoped filters against a collection
+type Collection struct {
+	collection common.CollectionInterface
+}
+
+// Guard wraps a collection so it can only be queried with scoped filters
+func Guard(collection common.CollectionInterface) Collection {
+	return Collection{collection: collection}
+}
+
+// FindOne finds a record matching the filter. An unscoped filter decodes to ErrUnscoped.
+func (g Collection) FindOne(ctx context.Context, f Filter, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	filter, err := f.BSON()
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
+	}
+	return g.collection.FindOne(ctx, filter, opts...)
+}
+
+// Find finds the records matching the filter
+func (g Collection) Find(ctx context.Context, f Filter, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	filter, err := f.BSON()
+	if err != nil {
+		return nil, err
+	}
+	return g.collection.Find(ctx, filter, opts...)
+}
+
+// UpdateOne updates a record matching the filter
+func (g Collection) UpdateOne(ctx context.Context, f Filter, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	filter, err := f.BSON()
+	if err != nil {
+		return nil, err
+	}
+	return g.collection.UpdateOne(ctx, filter, update, opts...)
+}
+
+// FindOneAndUpdate updates a record matching the filter and returns it. An unscoped filter
+// decodes to ErrUnscoped.
+func (g Collection) FindOneAndUpdate(ctx context.Context, f Filter, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	filter, err := f.BSON()
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
+	}
+	collection, ok := g.collection.(interface {
+		FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
+	})
+	if !ok {
+		return mongo.NewSingleResultFromDocument(bson.D{}, ErrUnsupported, nil)
+	}
+	return collection.FindOneAndUpdate(ctx, filter, update, opts...)
+}
+
+// UpdateMany updates every record matching the filter
+func (g Collection) UpdateMany(ctx context.Context, f Filter, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	filter, err := f.BSON()
+	if err != nil {
+		return nil, err
+	}
+	collection, ok := g.collection.(interface {
+		UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	})
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return collection.UpdateMany(ctx, filter, update, opts...)
+}
+
+// CountDocuments counts the records matching the filter
+func (g Collection) CountDocuments(ctx context.Context, f Filter, opts ...*options.CountOptions) (int64, error) {
+	filter, err := f.BSON()
+	if err != nil {
+		return 0, err
+	}
+	collection, ok := g.collection.(interface {
+		CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
+	})
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	return collection.CountDocuments(ctx, filter, opts...)
+}
+
+// Aggregate runs a pipeline over the records matching the filter, which is matched first
+func (g Collection) Aggregate(ctx context.Context, f Filter, pipeline bson.A, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	filter, err := f.BSON()
+	if err != nil {
+		return nil, err
+	}
+	collection, ok := g.collection.(interface {
+		Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
+	})
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return collection.Aggregate(ctx, append(bson.A{bson.M{"$match": filter}}, pipeline...), opts...)
+}
+
+// DeleteOne deletes a record matching the filter
+func (g Collection) DeleteOne(ctx context.Context, f Filter, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	filter, err := f.BSON()
+	if err != nil {
+		return nil, err
+	}
+	collection, ok := g.collection.(interface {
+		DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	})
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return collection.DeleteOne(ctx, filter, opts...)
+}
+
+// InsertOne inserts a record of the filter's client. A record whose client_id is not the
+// filter's client, or that has none, is refused with ErrUnscoped.
+func (g Collection) InsertOne(ctx context.Context, f Filter, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	if err := f.owns(document); err != nil {
+		return nil, err
+	}
+	return g.collection.InsertOne(ctx, document, opts...)
+}
+
+// InsertOnce inserts a record of the filter's client unless one matching the filter exists
+// already. Unlike InsertOne it can be retried after an ambiguous failure without creating a
+// duplicate, so callers run it with mongoretry.Write.
+func (g Collection) InsertOnce(ctx context.Context, f Filter, document interface{}) error {
+	if err := f.owns(document); err != nil {
+		return err
+	}
+	filter, err := f.BSON()
+	if err != nil {
+		return err
+	}
+	_, err = g.collection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": document}, options.Update().SetUpsert(true))
+	return err
+}
+
+// owns checks a record belongs to the filter's client. Records inserted under AllClients
+// must still name a client.
+func (f Filter) owns(document interface{}) error {
+	if _, err := f.BSON(); err != nil {
+		return err
+	}
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+	clientID, _ := bson.Raw(raw).Lookup(Field).StringValueOK()
+	if clientID == "" || (!f.all && clientID != f.clientID) {
+		return ErrUnscoped
+	}
+	return nil
+}
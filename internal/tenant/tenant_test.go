@@ -0,0 +1,131 @@
+package tenant
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recordingCollection keeps the filters it is queried with
+type recordingCollection struct {
+	filters []interface{}
+}
+
+func (r *recordingCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return &mongo.InsertOneResult{}, nil
+}
+
+func (r *recordingCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	r.filters = append(r.filters, filter)
+	return mongo.NewSingleResultFromDocument(bson.D{{Key: "client_id", Value: "client1"}}, nil, nil)
+}
+
+func (r *recordingCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	r.filters = append(r.filters, filter)
+	return mongo.NewCursorFromDocuments(nil, nil, nil)
+}
+
+func (r *recordingCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	r.filters = append(r.filters, filter)
+	return &mongo.UpdateResult{MatchedCount: 1}, nil
+}
+
+func TestFilterBSON(t *testing.T) {
+	filter, err := Of("client1").With("applicant_id", "app1").BSON()
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"client_id": "client1", "applicant_id": "app1"}, filter)
+
+	// The client cannot be widened by a condition on its field
+	filter, err = Of("client1").With(Field, bson.M{"$exists": true}).BSON()
+	require.NoError(t, err)
+	assert.Equal(t, "client1", filter[Field])
+
+	filter, err = AllClients().With("applicant_id", "app1").BSON()
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"applicant_id": "app1"}, filter)
+
+	_, err = Of("").With("applicant_id", "app1").BSON()
+	assert.ErrorIs(t, err, ErrUnscoped)
+	_, err = Filter{}.BSON()
+	assert.ErrorIs(t, err, ErrUnscoped)
+}
+
+func TestWithDoesNotShareConditions(t *testing.T) {
+	base := Of("client1").With("deleted", false)
+	first, _ := base.With("applicant_id", "app1").BSON()
+	second, _ := base.With("applicant_id", "app2").BSON()
+	assert.Equal(t, "app1", first["applicant_id"])
+	assert.Equal(t, "app2", second["applicant_id"])
+}
+
+func TestFromContext(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, err := FromContext(c).BSON()
+	assert.ErrorIs(t, err, ErrUnscoped)
+
+	c.Set("client_id", "client1")
+	assert.Equal(t, "client1", FromContext(c).ClientID())
+}
+
+func TestGuardRefusesUnscopedQueries(t *testing.T) {
+	ctx := context.Background()
+	collection := &recordingCollection{}
+	guarded := Guard(collection)
+	unscoped := Of("").With("applicant_id", "app1")
+
+	var doc bson.M
+	assert.ErrorIs(t, guarded.FindOne(ctx, unscoped).Decode(&doc), ErrUnscoped)
+	_, err := guarded.Find(ctx, unscoped)
+	assert.ErrorIs(t, err, ErrUnscoped)
+	_, err = guarded.UpdateOne(ctx, unscoped, bson.M{"$set": bson.M{"status": "verified"}})
+	assert.ErrorIs(t, err, ErrUnscoped)
+	assert.Empty(t, collection.filters, "no unscoped query reached the collection")
+
+	require.NoError(t, guarded.FindOne(ctx, Of("client1").With("applicant_id", "app1")).Decode(&doc))
+	_, err = guarded.Find(ctx, AllClients())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{bson.M{"client_id": "client1", "applicant_id": "app1"}, bson.M{}}, collection.filters)
+}
+
+func TestGuardInsertsOnlyTheFilterClientsRecords(t *testing.T) {
+	ctx := context.Background()
+	collection := &recordingCollection{}
+	guarded := Guard(collection)
+
+	_, err := guarded.InsertOne(ctx, Of("client1"), bson.M{"client_id": "client2", "note_id": "n1"})
+	assert.ErrorIs(t, err, ErrUnscoped)
+	_, err = guarded.InsertOne(ctx, Of("client1"), bson.M{"note_id": "n1"})
+	assert.ErrorIs(t, err, ErrUnscoped)
+	_, err = guarded.InsertOne(ctx, AllClients(), bson.M{"note_id": "n1"})
+	assert.ErrorIs(t, err, ErrUnscoped, "records inserted across clients still name one")
+	assert.ErrorIs(t, guarded.InsertOnce(ctx, Of(""), bson.M{"client_id": "", "note_id": "n1"}), ErrUnscoped)
+	assert.Empty(t, collection.filters)
+
+	_, err = guarded.InsertOne(ctx, AllClients(), bson.M{"client_id": "client2", "note_id": "n1"})
+	require.NoError(t, err)
+	require.NoError(t, guarded.InsertOnce(ctx, Of("client1").With("note_id", "n1"), bson.M{"client_id": "client1", "note_id": "n1"}))
+	assert.Equal(t, []interface{}{bson.M{"client_id": "client1", "note_id": "n1"}}, collection.filters)
+}
+
+func TestGuardRefusesOperationsTheCollectionLacks(t *testing.T) {
+	ctx := context.Background()
+	guarded := Guard(&recordingCollection{})
+
+	_, err := guarded.UpdateMany(ctx, Of("client1"), bson.M{"$set": bson.M{"deleted": true}})
+	assert.ErrorIs(t, err, ErrUnsupported)
+	_, err = guarded.CountDocuments(ctx, Of("client1"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+	var doc bson.M
+	assert.ErrorIs(t, guarded.FindOneAndUpdate(ctx, Of("client1"), bson.M{}).Decode(&doc), ErrUnsupported)
+
+	// Unscoped filters are refused before the operation is looked for
+	_, err = guarded.CountDocuments(ctx, Of(""))
+	assert.ErrorIs(t, err, ErrUnscoped)
+}
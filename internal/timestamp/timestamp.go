@@ -0,0 +1,81 @@
+// Package timestamp keeps the service's times in one form: in UTC, to the millisecond
+// MongoDB stores, and given out by the API as RFC 3339. A time set with Now reads back
+// from the database equal to the one the API returned when it was set.
+//
+// Times from elsewhere, such as request bodies and query parameters, go through UTC or
+// Parse before they are stored or compared with stored times.
+package timestamp
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Precision is the smallest unit of time stored, matching BSON dates
+const Precision = time.Millisecond
+
+// Now returns the current time in UTC, to Precision
+func Now() time.Time {
+	return UTC(time.Now())
+}
+
+// UTC converts a time to UTC, to Precision. The zero time stays zero.
+func UTC(t time.Time) time.Time {
+	return t.UTC().Truncate(Precision)
+}
+
+// UTCPtr converts an optional time to UTC, to Precision
+func UTCPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	converted := UTC(*t)
+	return &converted
+}
+
+// Parse reads an RFC 3339 time with any offset, returning it in UTC
+func Parse(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return UTC(t), nil
+}
+
+// Format writes a time as RFC 3339 in UTC
+func Format(t time.Time) string {
+	return UTC(t).Format(time.RFC3339Nano)
+}
+
+// FromBSON converts the BSON dates in a document decoded without a struct, which
+// encoding/json would write in the server's local zone, to UTC times. Nested documents
+// and arrays are converted in place.
+func FromBSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.DateTime:
+		return UTC(v.Time())
+	case map[string]interface{}:
+		for key, nested := range v {
+			v[key] = FromBSON(nested)
+		}
+	case bson.M:
+		for key, nested := range v {
+			v[key] = FromBSON(nested)
+		}
+	case bson.D:
+		for i := range v {
+			v[i].Value = FromBSON(v[i].Value)
+		}
+	case bson.A:
+		for i := range v {
+			v[i] = FromBSON(v[i])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = FromBSON(v[i])
+		}
+	}
+	return value
+}
@@ -0,0 +1,58 @@
+package timestamp
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUTC(t *testing.T) {
+	zone := time.FixedZone("CEST", 2*60*60)
+	local := time.Date(2026, 10, 16, 11, 30, 0, 123456789, zone)
+
+	converted := UTC(local)
+	assert.Equal(t, time.UTC, converted.Location())
+	assert.True(t, converted.Equal(time.Date(2026, 10, 16, 9, 30, 0, 123000000, time.UTC)))
+	assert.True(t, UTC(time.Time{}).IsZero())
+	assert.Nil(t, UTCPtr(nil))
+	assert.Equal(t, converted, *UTCPtr(&local))
+
+	now := Now()
+	assert.Equal(t, time.UTC, now.Location())
+	assert.Zero(t, now.Nanosecond()%int(Precision))
+
+	encoded, err := json.Marshal(converted)
+	require.NoError(t, err)
+	assert.Equal(t, `"2026-10-16T09:30:00.123Z"`, string(encoded))
+	assert.Equal(t, "2026-10-16T09:30:00.123Z", Format(local))
+}
+
+func TestParse(t *testing.T) {
+	parsed, err := Parse("2026-10-16T11:30:00+02:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC), parsed)
+
+	_, err = Parse("2026-10-16 11:30")
+	assert.Error(t, err)
+}
+
+func TestFromBSON(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	doc := map[string]interface{}{
+		"created_at": primitive.NewDateTimeFromTime(at),
+		"review":     bson.D{{Key: "claimed_at", Value: primitive.NewDateTimeFromTime(at)}},
+		"signals":    bson.A{bson.M{"created_at": primitive.NewDateTimeFromTime(at)}},
+		"name":       "Ada",
+	}
+	FromBSON(doc)
+
+	assert.Equal(t, at, doc["created_at"])
+	assert.Equal(t, at, doc["review"].(bson.D)[0].Value)
+	assert.Equal(t, at, doc["signals"].(bson.A)[0].(bson.M)["created_at"])
+	assert.Equal(t, "Ada", doc["name"])
+}
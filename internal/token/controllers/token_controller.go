@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/auth/middleware"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/token/services"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.uber.org/zap"
+)
+
+// minPasswordLength is the shortest password a dashboard user may be given
+const minPasswordLength = 12
+
+type tokenRequest struct {
+	GrantType    string `json:"grant_type" binding:"required"`
+	APIKey       string `json:"api_key"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// IssueToken is the handler function for exchanging an API key, a dashboard user's
+// credentials or a refresh token for a short-lived access token and a new refresh token
+func IssueToken(c *gin.Context, service interfaces.TokenService) {
+	var request tokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grant_type is required"})
+		return
+	}
+
+	var (
+		pair localModels.TokenPair
+		err  error
+	)
+	switch request.GrantType {
+	case localModels.GrantAPIKey:
+		if request.APIKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "api_key is required"})
+			return
+		}
+		pair, err = service.IssueForAPIKey(c.Request.Context(), request.APIKey)
+	case localModels.GrantPassword:
+		if request.Username == "" || request.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username and password are required"})
+			return
+		}
+		pair, err = service.IssueForPassword(c.Request.Context(), request.Username, request.Password)
+	case localModels.GrantRefreshToken:
+		if request.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+			return
+		}
+		pair, err = service.Refresh(c.Request.Context(), request.RefreshToken)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grant_type must be api_key, password or refresh_token"})
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	case errors.Is(err, services.ErrInvalidToken), errors.Is(err, services.ErrTokenExpired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	case mongoretry.RespondUnavailable(c, err):
+		return
+	case err != nil:
+		zaplogger.GetLogger().Error("Error issuing token", zap.Error(err), zap.String("grantType", request.GrantType))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not issue token"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, pair)
+}
+
+// RevokeToken is the handler function for revoking an access token or refresh token, e.g. when
+// a dashboard user signs out. Revoking an unknown token succeeds, so tokens cannot be probed.
+func RevokeToken(c *gin.Context, service interfaces.TokenService) {
+	var request struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+	if err := service.Revoke(c.Request.Context(), request.Token); err != nil {
+		zaplogger.GetLogger().Error("Error revoking token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
+
+// Authenticate accepts access tokens issued by the token endpoint and hands every other
+// request to fallback, such as the shared CombinedAuthMiddleware
+func Authenticate(service interfaces.TokenService, fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			fallback(c)
+			return
+		}
+
+		claims, err := service.Verify(c.Request.Context(), token)
+		switch {
+		case errors.Is(err, services.ErrInvalidToken):
+			// Not one of ours
+			fallback(c)
+			return
+		case errors.Is(err, services.ErrTokenExpired):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired or revoked"})
+			return
+		case err != nil:
+			zaplogger.GetLogger().Error("Error verifying access token", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Could not verify token"})
+			return
+		}
+
+		c.Set("client_id", claims.ClientID)
+		c.Set("token_subject", claims.Subject)
+		c.Next()
+	}
+}
+
+// CreateDashboardUser is the handler function for giving a client's staff member a
+// username and password to sign in to the dashboard with
+func CreateDashboardUser(c *gin.Context, service interfaces.TokenService) {
+	adminID, err := middleware.GetAdminIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var request struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username and password are required"})
+		return
+	}
+	if len(request.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 12 characters"})
+		return
+	}
+
+	user := localModels.DashboardUser{ClientID: c.Param("clientId"), Username: request.Username, CreatedBy: adminID}
+	user, err = service.CreateDashboardUser(c.Request.Context(), user, request.Password)
+	if apperr.Respond(c, err) || mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create dashboard user"})
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// RevokeAPIKey is the handler function for revoking one of a client's API keys, which
+// also ends the sign-ins made with it
+func RevokeAPIKey(c *gin.Context, service interfaces.TokenService) {
+	err := service.RevokeAPIKey(c.Request.Context(), c.Param("clientId"), c.Param("keyId"))
+	if apperr.Respond(c, err) || mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error("Error revoking API key", zap.Error(err), zap.String("clientID", c.Param("clientId")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// DisableDashboardUser is the handler function for stopping a client's dashboard user
+// from signing in, which also ends the user's sign-ins
+func DisableDashboardUser(c *gin.Context, service interfaces.TokenService) {
+	err := service.DisableDashboardUser(c.Request.Context(), c.Param("clientId"), c.Param("userId"))
+	if apperr.Respond(c, err) || mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error("Error disabling dashboard user", zap.Error(err), zap.String("clientID", c.Param("clientId")))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not disable dashboard user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dashboard user disabled"})
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/token/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIssueToken(t *testing.T) {
+	pair := localModels.TokenPair{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "rt_1"}
+
+	tests := []struct {
+		name               string
+		body               string
+		method             string
+		args               []interface{}
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "API key", body: `{"grant_type":"api_key","api_key":"key1"}`, method: "IssueForAPIKey", args: []interface{}{"key1"}, expectedStatusCode: http.StatusOK},
+		{name: "Password", body: `{"grant_type":"password","username":"ada","password":"pw"}`, method: "IssueForPassword", args: []interface{}{"ada", "pw"}, expectedStatusCode: http.StatusOK},
+		{name: "Refresh", body: `{"grant_type":"refresh_token","refresh_token":"rt_0"}`, method: "Refresh", args: []interface{}{"rt_0"}, expectedStatusCode: http.StatusOK},
+		{name: "Wrong password", body: `{"grant_type":"password","username":"ada","password":"pw"}`, method: "IssueForPassword", args: []interface{}{"ada", "pw"}, serviceErr: services.ErrInvalidCredentials, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Reused refresh token", body: `{"grant_type":"refresh_token","refresh_token":"rt_0"}`, method: "Refresh", args: []interface{}{"rt_0"}, serviceErr: services.ErrTokenExpired, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Storage fails", body: `{"grant_type":"api_key","api_key":"key1"}`, method: "IssueForAPIKey", args: []interface{}{"key1"}, serviceErr: errors.New("boom"), expectedStatusCode: http.StatusInternalServerError},
+		{name: "Missing credential", body: `{"grant_type":"api_key"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Unknown grant", body: `{"grant_type":"client_credentials"}`, expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockTokenService)
+			if tt.method != "" {
+				mockService.On(tt.method, append([]interface{}{mock.Anything}, tt.args...)...).Return(pair, tt.serviceErr)
+			}
+			gin.SetMode(gin.TestMode)
+			router := gin.Default()
+			router.POST("/auth/token", func(c *gin.Context) {
+				IssueToken(c, mockService)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/auth/token", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+				assert.Contains(t, w.Body.String(), `"refresh_token":"rt_1"`)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name               string
+		authorization      string
+		verifyErr          error
+		expectedStatusCode int
+		expectedClient     string
+	}{
+		{name: "Issued token", authorization: "Bearer ours", expectedStatusCode: http.StatusOK, expectedClient: "client1"},
+		{name: "Revoked token", authorization: "Bearer ours", verifyErr: services.ErrTokenExpired, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Token from elsewhere falls back", authorization: "Bearer theirs", verifyErr: services.ErrInvalidToken, expectedStatusCode: http.StatusOK, expectedClient: "fallback"},
+		{name: "API key falls back", expectedStatusCode: http.StatusOK, expectedClient: "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockTokenService)
+			mockService.On("Verify", mock.Anything, mock.Anything).Return(localModels.TokenClaims{ClientID: "client1", Subject: "user1"}, tt.verifyErr)
+			fallback := func(c *gin.Context) {
+				c.Set("client_id", "fallback")
+				c.Next()
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.Default()
+			router.GET("/applicants", Authenticate(mockService, fallback), func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString("client_id"))
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/applicants", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedClient != "" {
+				assert.Equal(t, tt.expectedClient, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestEndSignIns(t *testing.T) {
+	tests := []struct {
+		name               string
+		method             string
+		path               string
+		serviceMethod      string
+		id                 string
+		serviceErr         error
+		expectedStatusCode int
+	}{
+		{name: "Revoke API key", method: http.MethodDelete, path: "/clients/client1/api-keys/key1", serviceMethod: "RevokeAPIKey", id: "key1", expectedStatusCode: http.StatusOK},
+		{name: "Unknown API key", method: http.MethodDelete, path: "/clients/client1/api-keys/key1", serviceMethod: "RevokeAPIKey", id: "key1", serviceErr: services.ErrAPIKeyNotFound, expectedStatusCode: http.StatusNotFound},
+		{name: "Disable dashboard user", method: http.MethodPost, path: "/clients/client1/dashboard-users/user1/disable", serviceMethod: "DisableDashboardUser", id: "user1", expectedStatusCode: http.StatusOK},
+		{name: "Unknown dashboard user", method: http.MethodPost, path: "/clients/client1/dashboard-users/user1/disable", serviceMethod: "DisableDashboardUser", id: "user1", serviceErr: services.ErrDashboardUserNotFound, expectedStatusCode: http.StatusNotFound},
+		{name: "Storage fails", method: http.MethodPost, path: "/clients/client1/dashboard-users/user1/disable", serviceMethod: "DisableDashboardUser", id: "user1", serviceErr: errors.New("boom"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockTokenService)
+			mockService.On(tt.serviceMethod, mock.Anything, "client1", tt.id).Return(tt.serviceErr)
+			gin.SetMode(gin.TestMode)
+			router := gin.Default()
+			router.DELETE("/clients/:clientId/api-keys/:keyId", func(c *gin.Context) {
+				RevokeAPIKey(c, mockService)
+			})
+			router.POST("/clients/:clientId/dashboard-users/:userId/disable", func(c *gin.Context) {
+				DisableDashboardUser(c, mockService)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
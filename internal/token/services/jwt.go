@@ -0,0 +1,56 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// jwtHeader is the encoded header of every token issued here; only HS256 is used
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// signJWT encodes the claims as an HS256 JWT
+func signJWT(secret []byte, claims localModels.TokenClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token claims: %w", err)
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(hmacSHA256(secret, unsigned)), nil
+}
+
+// parseJWT checks an HS256 JWT's signature and returns its claims. Expiry is left to the caller.
+func parseJWT(secret []byte, token string) (localModels.TokenClaims, error) {
+	var claims localModels.TokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if raw, err := base64.RawURLEncoding.DecodeString(parts[0]); err != nil || json.Unmarshal(raw, &header) != nil || header.Alg != "HS256" {
+		return claims, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, hmacSHA256(secret, parts[0]+"."+parts[1])) {
+		return claims, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return claims, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func hmacSHA256(secret []byte, s string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
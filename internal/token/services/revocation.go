@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RevocationList records revoked access tokens until they would have expired anyway.
+// It is read on every request made with an access token, so it should be fast.
+type RevocationList interface {
+	Revoke(ctx context.Context, tokenID string, until time.Time) error
+	Revoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+// revokedKeyPrefix namespaces revoked token IDs among the keys in Redis
+const revokedKeyPrefix = "verus:revoked_tokens:"
+
+// MongoRevocationList keeps revoked token IDs in a collection whose TTL index removes
+// them once the tokens have expired. It serves deployments without Redis.
+type MongoRevocationList struct {
+	CollectionName string
+}
+
+// NewMongoRevocationList creates a revocation list in the revoked tokens collection
+func NewMongoRevocationList() *MongoRevocationList {
+	return &MongoRevocationList{CollectionName: localConstants.CollectionRevokedTokens}
+}
+
+func (l *MongoRevocationList) Revoke(ctx context.Context, tokenID string, until time.Time) error {
+	opts := options.Update().SetUpsert(true)
+	update := bson.M{"$setOnInsert": bson.M{"jti": tokenID, "expires_at": until, "revoked_at": timestamp.Now()}}
+	if _, err := common.GetCollection(l.CollectionName).UpdateOne(ctx, bson.M{"jti": tokenID}, update, opts); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (l *MongoRevocationList) Revoked(ctx context.Context, tokenID string) (bool, error) {
+	n, err := common.GetCollection(l.CollectionName).CountDocuments(ctx, bson.M{"jti": tokenID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RedisRevocationList keeps revoked token IDs in Redis, which forgets each once its token
+// has expired. Every replica checks the same server, so a token revoked on one is refused
+// by all of them.
+type RedisRevocationList struct {
+	Client *redis.Client
+}
+
+// NewRedisRevocationList creates a revocation list in the Redis server client connects to
+func NewRedisRevocationList(client *redis.Client) *RedisRevocationList {
+	return &RedisRevocationList{Client: client}
+}
+
+func (l *RedisRevocationList) Revoke(ctx context.Context, tokenID string, until time.Time) error {
+	ttl := until.Sub(timestamp.Now())
+	if ttl <= 0 {
+		// The token has expired, so there is nothing left to refuse
+		return nil
+	}
+	if err := l.Client.Set(ctx, revokedKeyPrefix+tokenID, timestamp.Now().Unix(), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (l *RedisRevocationList) Revoked(ctx context.Context, tokenID string) (bool, error) {
+	n, err := l.Client.Exists(ctx, revokedKeyPrefix+tokenID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/rachel-lawrie/verus_app_backend/internal/redisclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisRevocationList(t *testing.T) {
+	server := miniredis.RunT(t)
+	client, err := redisclient.New("redis://" + server.Addr())
+	require.NoError(t, err)
+	list := NewRedisRevocationList(client)
+	ctx := context.Background()
+
+	require.NoError(t, list.Revoke(ctx, "token1", time.Now().Add(time.Minute)))
+	revoked, err := list.Revoked(ctx, "token1")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = list.Revoked(ctx, "token2")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// Forgotten once the token would have expired anyway
+	server.FastForward(2 * time.Minute)
+	revoked, err = list.Revoked(ctx, "token1")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, list.Revoke(ctx, "expired", time.Now().Add(-time.Minute)))
+	assert.False(t, server.Exists(revokedKeyPrefix+"expired"))
+
+	server.Close()
+	_, err = list.Revoked(ctx, "token1")
+	assert.Error(t, err)
+}
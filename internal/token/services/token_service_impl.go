@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Issuer identifies access tokens issued by this service
+	Issuer = "verus_app_backend"
+
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidCredentials is returned when an API key or username and password do not match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidToken is returned for tokens that were not issued by this service or are malformed
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned for tokens past their expiry or revoked
+	ErrTokenExpired = errors.New("token expired or revoked")
+	// ErrUsernameTaken is returned when a dashboard user already has the username
+	ErrUsernameTaken = apperr.Conflict("username_taken", "username is taken")
+	// ErrAPIKeyNotFound is returned when the client has no API key with the requested ID
+	ErrAPIKeyNotFound = apperr.NotFound("api_key_not_found", "API key not found")
+	// ErrDashboardUserNotFound is returned when the client has no dashboard user with the requested ID
+	ErrDashboardUserNotFound = apperr.NotFound("dashboard_user_not_found", "dashboard user not found")
+)
+
+// dummyHash is compared against when a username is unknown, so the response takes
+// as long as for a known user with the wrong password
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// TokenServiceImpl issues short-lived JWT access tokens and the refresh tokens that renew them
+type TokenServiceImpl struct {
+	SecretCollectionName  string
+	UserCollectionName    string
+	RefreshCollectionName string
+	SigningSecret         []byte
+	AccessTTL             time.Duration
+	RefreshTTL            time.Duration
+	Revocations           RevocationList
+}
+
+var (
+	instance TokenServiceImpl
+	once     sync.Once
+)
+
+func GetTokenServiceImpl() TokenServiceImpl {
+	once.Do(func() {
+		instance = TokenServiceImpl{
+			SecretCollectionName:  localConstants.CollectionClientSecrets,
+			UserCollectionName:    localConstants.CollectionDashboardUsers,
+			RefreshCollectionName: localConstants.CollectionRefreshTokens,
+			AccessTTL:             DefaultAccessTTL,
+			RefreshTTL:            DefaultRefreshTTL,
+			Revocations:           NewMongoRevocationList(),
+		}
+	})
+	return instance
+}
+
+// IssueForAPIKey exchanges an active API key for tokens acting as its client
+func (s *TokenServiceImpl) IssueForAPIKey(ctx context.Context, apiKey string) (localModels.TokenPair, error) {
+	var secret struct {
+		KeyID    string `bson:"key_id"`
+		ClientID string `bson:"client_id"`
+	}
+	filter := bson.M{"client_secret_hash": utils.HashAPIKey(apiKey), "revoked": false, "deleted_at": nil}
+	err := common.GetCollection(s.SecretCollectionName).FindOne(ctx, filter).Decode(&secret)
+	if err == mongo.ErrNoDocuments {
+		return localModels.TokenPair{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return localModels.TokenPair{}, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	return s.issue(ctx, localModels.RefreshToken{
+		FamilyID:  ids.New(),
+		ClientID:  secret.ClientID,
+		Subject:   secret.ClientID,
+		APIKeyID:  secret.KeyID,
+		ExpiresAt: timestamp.Now().Add(s.RefreshTTL),
+	})
+}
+
+// IssueForPassword exchanges a dashboard user's username and password for tokens acting as the user's client
+func (s *TokenServiceImpl) IssueForPassword(ctx context.Context, username, password string) (localModels.TokenPair, error) {
+	var user localModels.DashboardUser
+	err := common.GetCollection(s.UserCollectionName).FindOne(ctx, bson.M{"username": normalizeUsername(username)}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return localModels.TokenPair{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return localModels.TokenPair{}, fmt.Errorf("failed to look up dashboard user: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || user.Disabled {
+		return localModels.TokenPair{}, ErrInvalidCredentials
+	}
+	return s.issue(ctx, localModels.RefreshToken{
+		FamilyID:  ids.New(),
+		ClientID:  user.ClientID,
+		Subject:   user.UserID,
+		ExpiresAt: timestamp.Now().Add(s.RefreshTTL),
+	})
+}
+
+// Refresh exchanges a refresh token for new tokens. Each refresh token works once;
+// presenting a used one again revokes every token descended from the same sign-in,
+// since either the client or an attacker holds a stolen copy. Tokens are only renewed
+// while the API key or dashboard user that signed in may still do so, and never past
+// RefreshTTL from the sign-in.
+func (s *TokenServiceImpl) Refresh(ctx context.Context, refreshToken string) (localModels.TokenPair, error) {
+	now := timestamp.Now()
+	hash := hashToken(refreshToken)
+	collection := common.GetCollection(s.RefreshCollectionName)
+
+	var token localModels.RefreshToken
+	filter := bson.M{"token_hash": hash, "used_at": nil, "revoked_at": nil, "expires_at": bson.M{"$gt": now}}
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"used_at": now}}).Decode(&token)
+	if err == nil {
+		active, err := s.signInActive(ctx, token)
+		if err != nil {
+			return localModels.TokenPair{}, err
+		}
+		if !active {
+			if err := s.revokeFamily(ctx, token.FamilyID); err != nil {
+				return localModels.TokenPair{}, err
+			}
+			return localModels.TokenPair{}, ErrTokenExpired
+		}
+		// The new token keeps the family's expiry, so refreshing cannot extend a sign-in
+		return s.issue(ctx, token)
+	}
+	if err != mongo.ErrNoDocuments {
+		return localModels.TokenPair{}, fmt.Errorf("failed to look up refresh token: %w", err)
+	}
+
+	err = collection.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return localModels.TokenPair{}, ErrInvalidToken
+	}
+	if err != nil {
+		return localModels.TokenPair{}, fmt.Errorf("failed to look up refresh token: %w", err)
+	}
+	if token.UsedAt != nil && token.RevokedAt == nil {
+		if err := s.revokeFamily(ctx, token.FamilyID); err != nil {
+			return localModels.TokenPair{}, err
+		}
+	}
+	return localModels.TokenPair{}, ErrTokenExpired
+}
+
+// Revoke revokes an access token, or a refresh token together with every token from the same sign-in.
+// Unknown tokens are ignored.
+func (s *TokenServiceImpl) Revoke(ctx context.Context, token string) error {
+	if claims, err := parseJWT(s.SigningSecret, token); err == nil {
+		return s.Revocations.Revoke(ctx, claims.ID, time.Unix(claims.ExpiresAt, 0))
+	}
+
+	var refresh localModels.RefreshToken
+	err := common.GetCollection(s.RefreshCollectionName).FindOne(ctx, bson.M{"token_hash": hashToken(token)}).Decode(&refresh)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up refresh token: %w", err)
+	}
+	return s.revokeFamily(ctx, refresh.FamilyID)
+}
+
+// Verify returns the claims of a valid access token. Tokens this service did not sign
+// give ErrInvalidToken; tokens it signed that have expired or been revoked give ErrTokenExpired.
+func (s *TokenServiceImpl) Verify(ctx context.Context, accessToken string) (localModels.TokenClaims, error) {
+	claims, err := parseJWT(s.SigningSecret, accessToken)
+	if err != nil || claims.Issuer != Issuer {
+		return localModels.TokenClaims{}, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return localModels.TokenClaims{}, ErrTokenExpired
+	}
+	revoked, err := s.Revocations.Revoked(ctx, claims.ID)
+	if err != nil {
+		return localModels.TokenClaims{}, err
+	}
+	if revoked {
+		return localModels.TokenClaims{}, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+// CreateDashboardUser adds a dashboard user to a client, storing only a hash of the password
+func (s *TokenServiceImpl) CreateDashboardUser(ctx context.Context, user localModels.DashboardUser, password string) (localModels.DashboardUser, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return localModels.DashboardUser{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.UserID = ids.New()
+	user.Username = normalizeUsername(user.Username)
+	user.PasswordHash = string(hash)
+	user.CreatedAt = timestamp.Now()
+
+	err = mongoretry.InsertOnce(ctx, common.GetCollection(s.UserCollectionName), "create_dashboard_user", bson.M{"user_id": user.UserID}, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return localModels.DashboardUser{}, ErrUsernameTaken
+	}
+	if err != nil {
+		return localModels.DashboardUser{}, err
+	}
+	return user, nil
+}
+
+// RevokeAPIKey revokes one of a client's API keys and every refresh token signed in with
+// it. Access tokens already issued from them stay valid until they expire.
+func (s *TokenServiceImpl) RevokeAPIKey(ctx context.Context, clientID, keyID string) error {
+	filter := bson.M{"client_id": clientID, "key_id": keyID, "deleted_at": nil}
+	result, err := common.GetCollection(s.SecretCollectionName).UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return s.revokeFamilies(ctx, bson.M{"client_id": clientID, "api_key_id": keyID})
+}
+
+// DisableDashboardUser stops a client's dashboard user from signing in and revokes every
+// refresh token the user holds. Access tokens already issued stay valid until they expire.
+func (s *TokenServiceImpl) DisableDashboardUser(ctx context.Context, clientID, userID string) error {
+	filter := bson.M{"client_id": clientID, "user_id": userID}
+	result, err := common.GetCollection(s.UserCollectionName).UpdateOne(ctx, filter, bson.M{"$set": bson.M{"disabled": true}})
+	if err != nil {
+		return fmt.Errorf("failed to disable dashboard user: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrDashboardUserNotFound
+	}
+	return s.revokeFamilies(ctx, bson.M{"client_id": clientID, "subject": userID})
+}
+
+// signInActive reports whether the API key or dashboard user a token's family was signed
+// in with may still sign in. Families signed in with an API key before the key was
+// recorded on them name the client as subject, match no user and are not renewed.
+func (s *TokenServiceImpl) signInActive(ctx context.Context, token localModels.RefreshToken) (bool, error) {
+	collection := s.UserCollectionName
+	filter := bson.M{"client_id": token.ClientID, "user_id": token.Subject, "disabled": false}
+	if token.APIKeyID != "" {
+		collection = s.SecretCollectionName
+		filter = bson.M{"client_id": token.ClientID, "key_id": token.APIKeyID, "revoked": false, "deleted_at": nil}
+	}
+	err := common.GetCollection(collection).FindOne(ctx, filter).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up sign-in credentials: %w", err)
+	}
+	return true, nil
+}
+
+// issue creates an access token, and a refresh token in family's family with its client,
+// subject, API key and expiry
+func (s *TokenServiceImpl) issue(ctx context.Context, family localModels.RefreshToken) (localModels.TokenPair, error) {
+	now := timestamp.Now()
+	accessToken, err := signJWT(s.SigningSecret, localModels.TokenClaims{
+		Issuer:    Issuer,
+		Subject:   family.Subject,
+		ClientID:  family.ClientID,
+		ID:        ids.New(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.AccessTTL).Unix(),
+	})
+	if err != nil {
+		return localModels.TokenPair{}, err
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return localModels.TokenPair{}, err
+	}
+	stored := localModels.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  family.FamilyID,
+		ClientID:  family.ClientID,
+		Subject:   family.Subject,
+		APIKeyID:  family.APIKeyID,
+		CreatedAt: now,
+		ExpiresAt: family.ExpiresAt,
+	}
+	collection := common.GetCollection(s.RefreshCollectionName)
+	if err := mongoretry.InsertOnce(ctx, collection, "issue_refresh_token", bson.M{"token_hash": stored.TokenHash}, stored); err != nil {
+		return localModels.TokenPair{}, err
+	}
+
+	return localModels.TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.AccessTTL / time.Second),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// revokeFamily revokes every refresh token descended from one sign-in. Access tokens
+// already issued from them stay valid until they expire, which AccessTTL keeps short.
+func (s *TokenServiceImpl) revokeFamily(ctx context.Context, familyID string) error {
+	return s.revokeFamilies(ctx, bson.M{"family_id": familyID})
+}
+
+// revokeFamilies revokes the refresh tokens filter matches that are not revoked yet
+func (s *TokenServiceImpl) revokeFamilies(ctx context.Context, filter bson.M) error {
+	filter["revoked_at"] = nil
+	_, err := common.GetCollection(s.RefreshCollectionName).UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": timestamp.Now()}}, options.Update())
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRefreshToken generates a random refresh token
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	return "rt_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryRevocations is a RevocationList held in memory
+type memoryRevocations map[string]time.Time
+
+func (m memoryRevocations) Revoke(ctx context.Context, tokenID string, until time.Time) error {
+	m[tokenID] = until
+	return nil
+}
+
+func (m memoryRevocations) Revoked(ctx context.Context, tokenID string) (bool, error) {
+	_, ok := m[tokenID]
+	return ok, nil
+}
+
+func signedToken(t *testing.T, secret string, expiresAt time.Time) (string, localModels.TokenClaims) {
+	claims := localModels.TokenClaims{
+		Issuer:    Issuer,
+		Subject:   "user1",
+		ClientID:  "client1",
+		ID:        "token1",
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+	token, err := signJWT([]byte(secret), claims)
+	assert.NoError(t, err)
+	return token, claims
+}
+
+func TestParseJWT(t *testing.T) {
+	token, claims := signedToken(t, "secret", time.Now().Add(time.Minute))
+
+	parsed, err := parseJWT([]byte("secret"), token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims, parsed)
+
+	_, err = parseJWT([]byte("other secret"), token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + strings.TrimRight(parts[1], "=") + "x." + parts[2]
+	_, err = parseJWT([]byte("secret"), tampered)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Unsigned tokens are never accepted
+	unsigned := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." + parts[1] + "."
+	_, err = parseJWT([]byte("secret"), unsigned)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = parseJWT([]byte("secret"), "not a token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerify(t *testing.T) {
+	revocations := memoryRevocations{}
+	service := TokenServiceImpl{SigningSecret: []byte("secret"), Revocations: revocations}
+	ctx := context.Background()
+
+	valid, _ := signedToken(t, "secret", time.Now().Add(time.Minute))
+	claims, err := service.Verify(ctx, valid)
+	assert.NoError(t, err)
+	assert.Equal(t, "client1", claims.ClientID)
+
+	expired, _ := signedToken(t, "secret", time.Now().Add(-time.Second))
+	_, err = service.Verify(ctx, expired)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	foreign, _ := signedToken(t, "someone else's secret", time.Now().Add(time.Minute))
+	_, err = service.Verify(ctx, foreign)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Revoking an access token only touches the revocation list
+	assert.NoError(t, service.Revoke(ctx, valid))
+	_, err = service.Verify(ctx, valid)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
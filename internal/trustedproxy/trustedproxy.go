@@ -0,0 +1,23 @@
+// Package trustedproxy decides which proxies the router believes about the address a
+// request comes from. gin.Default trusts X-Forwarded-For from every caller, which would
+// let anyone pass a client's IP allowlist, dodge IP bans or write any address into audit
+// entries by sending the header.
+package trustedproxy
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+)
+
+// Configure makes the router take X-Forwarded-For only from the trusted proxies and read
+// the platform's header, if one is set. With no trusted proxies, c.ClientIP is the address
+// of the connection.
+func Configure(r *gin.Engine, settings config.ProxySettings) error {
+	if err := r.SetTrustedProxies(settings.Trusted); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	r.TrustedPlatform = settings.Platform
+	return nil
+}
@@ -0,0 +1,74 @@
+package trustedproxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	"github.com/rachel-lawrie/verus_app_backend/internal/config"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type allowlistLoader struct{}
+
+func (allowlistLoader) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	return localModels.Client{ClientID: clientID, IPAllowlist: localModels.IPAllowlist{CIDRs: []string{"203.0.113.0/24"}}}, nil
+}
+
+func newRouter(t *testing.T, settings config.ProxySettings) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	require.NoError(t, Configure(r, settings))
+	r.GET("/", func(c *gin.Context) { c.Set("client_id", "client1") }, clientconfig.Middleware(allowlistLoader{}), clientconfig.RequireAllowedIP(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	return r
+}
+
+func get(r *gin.Engine, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSpoofedForwardedForIsIgnored(t *testing.T) {
+	spoofed := http.Header{"X-Forwarded-For": {"203.0.113.7"}, "X-Real-Ip": {"203.0.113.7"}}
+
+	// Without trusted proxies the allowlist sees the address of the connection
+	w := get(newRouter(t, config.ProxySettings{}), "198.51.100.1:443", spoofed)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), clientconfig.IPNotAllowed)
+
+	// Nor is the header believed from a caller that is not one of the trusted proxies
+	r := newRouter(t, config.ProxySettings{Trusted: []string{"10.0.0.0/8"}})
+	w = get(r, "198.51.100.1:443", spoofed)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// From a trusted proxy the forwarded address is the client's
+	w = get(r, "10.0.0.5:443", http.Header{"X-Forwarded-For": {"203.0.113.7"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "203.0.113.7", w.Body.String())
+	w = get(r, "10.0.0.5:443", http.Header{"X-Forwarded-For": {"198.51.100.1"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestPlatformHeader(t *testing.T) {
+	r := newRouter(t, config.ProxySettings{Platform: gin.PlatformCloudflare})
+	w := get(r, "198.51.100.1:443", http.Header{"Cf-Connecting-Ip": {"203.0.113.9"}, "X-Forwarded-For": {"198.51.100.2"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "203.0.113.9", w.Body.String())
+}
+
+func TestConfigureRejectsInvalidProxies(t *testing.T) {
+	assert.Error(t, Configure(gin.New(), config.ProxySettings{Trusted: []string{"load-balancer"}}))
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// maxUsageMonths bounds the range of a single usage request
+const maxUsageMonths = 24
+
+// GetClientUsage is the handler function for a client's billable usage per month. The
+// from and to query parameters are months such as 2026-01 and default to the current month.
+func GetClientUsage(c *gin.Context, service interfaces.UsageService) {
+	current := time.Now().UTC().Format(localModels.UsageMonthFormat)
+	from, err := time.Parse(localModels.UsageMonthFormat, c.DefaultQuery("from", current))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a month such as 2026-01"})
+		return
+	}
+	to, err := time.Parse(localModels.UsageMonthFormat, c.DefaultQuery("to", current))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a month such as 2026-01"})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	if from.AddDate(0, maxUsageMonths, 0).Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range must not exceed 24 months"})
+		return
+	}
+
+	clientID := c.Param("clientId")
+	usage, err := service.GetUsage(c.Request.Context(), clientID,
+		from.Format(localModels.UsageMonthFormat), to.Format(localModels.UsageMonthFormat))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"client_id": clientID, "months": usage})
+}
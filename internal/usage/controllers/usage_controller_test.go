@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	localMocks "github.com/rachel-lawrie/verus_app_backend/internal/mocks"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupUsageRouter(mockService *localMocks.MockUsageService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/admin/clients/:clientId/usage", func(c *gin.Context) {
+		GetClientUsage(c, mockService)
+	})
+	return router
+}
+
+func TestGetClientUsage(t *testing.T) {
+	current := time.Now().UTC().Format(localModels.UsageMonthFormat)
+	usage := []localModels.MonthlyUsage{{Month: "2026-09", Counts: map[string]int64{localModels.UsageApplicantCreated: 4}}}
+
+	tests := []struct {
+		name               string
+		query              string
+		from, to           string
+		expectedStatusCode int
+	}{
+		{name: "Range of months", query: "?from=2026-01&to=2026-09", from: "2026-01", to: "2026-09", expectedStatusCode: http.StatusOK},
+		{name: "Defaults to current month", query: "", from: current, to: current, expectedStatusCode: http.StatusOK},
+		{name: "Invalid month", query: "?from=2026-13", expectedStatusCode: http.StatusBadRequest},
+		{name: "Reversed range", query: "?from=2026-09&to=2026-01", expectedStatusCode: http.StatusBadRequest},
+		{name: "Range too long", query: "?from=2020-01&to=2026-01", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(localMocks.MockUsageService)
+			router := setupUsageRouter(mockService)
+			mockService.On("GetUsage", mock.Anything, "client1", tt.from, tt.to).Return(usage, nil)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/admin/clients/client1/usage"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"applicant_created":4`)
+				mockService.AssertExpectations(t)
+			} else {
+				mockService.AssertNotCalled(t, "GetUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/egress"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+const exportBatchSize = 500
+
+// UsageServiceImpl meters billable events per client and month, and optionally
+// forwards them to an external billing system
+type UsageServiceImpl struct {
+	CollectionName string
+	// BillingURL receives batches of usage events as JSON. Nothing is exported when empty.
+	BillingURL string
+	HTTPClient *http.Client
+}
+
+var (
+	instance UsageServiceImpl
+	once     sync.Once
+)
+
+func GetUsageServiceImpl() UsageServiceImpl {
+	once.Do(func() {
+		instance = UsageServiceImpl{
+			CollectionName: localConstants.CollectionUsageEvents,
+			HTTPClient:     egress.Client(10 * time.Second),
+		}
+	})
+	return instance
+}
+
+// eventKey identifies the work an event bills for
+func eventKey(eventType, subjectID string) string {
+	return eventType + ":" + subjectID
+}
+
+// Record meters a billable event. Recording the same event type for the same subject
+// again has no effect, so callers can safely retry.
+func (s *UsageServiceImpl) Record(ctx context.Context, clientID, eventType, subjectID string) error {
+	now := timestamp.Now()
+	event := localModels.UsageEvent{
+		EventKey:  eventKey(eventType, subjectID),
+		ClientID:  clientID,
+		Type:      eventType,
+		SubjectID: subjectID,
+		Month:     now.Format(localModels.UsageMonthFormat),
+		At:        now,
+	}
+	collection := common.GetCollection(s.CollectionName)
+	if err := mongoretry.InsertOnce(ctx, collection, "record_usage", bson.M{"event_key": event.EventKey}, event); err != nil {
+		return fmt.Errorf("failed to record %s usage: %w", eventType, err)
+	}
+	return nil
+}
+
+// GetUsage counts a client's billable events for each month from one month to another, inclusive
+func (s *UsageServiceImpl) GetUsage(ctx context.Context, clientID, fromMonth, toMonth string) ([]localModels.MonthlyUsage, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"client_id": clientID, "month": bson.M{"$gte": fromMonth, "$lte": toMonth}}},
+		{"$group": bson.M{"_id": bson.M{"month": "$month", "type": "$type"}, "count": bson.M{"$sum": 1}}},
+	}
+	cursor, err := common.GetCollection(s.CollectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []usageRow
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode usage: %w", err)
+	}
+	return monthlyUsage(rows), nil
+}
+
+// usageRow is one month and event type counted by GetUsage
+type usageRow struct {
+	ID struct {
+		Month string `bson:"month"`
+		Type  string `bson:"type"`
+	} `bson:"_id"`
+	Count int64 `bson:"count"`
+}
+
+// monthlyUsage folds counted rows into one entry per month, oldest first
+func monthlyUsage(rows []usageRow) []localModels.MonthlyUsage {
+	byMonth := make(map[string]map[string]int64)
+	for _, row := range rows {
+		if byMonth[row.ID.Month] == nil {
+			byMonth[row.ID.Month] = make(map[string]int64)
+		}
+		byMonth[row.ID.Month][row.ID.Type] += row.Count
+	}
+
+	usage := make([]localModels.MonthlyUsage, 0, len(byMonth))
+	for month, counts := range byMonth {
+		usage = append(usage, localModels.MonthlyUsage{Month: month, Counts: counts})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Month < usage[j].Month })
+	return usage
+}
+
+// ExportPending sends events not yet exported to the billing system. An event may be
+// sent twice if marking it exported fails, so the billing system should deduplicate
+// on event_key.
+func (s *UsageServiceImpl) ExportPending(ctx context.Context) error {
+	if s.BillingURL == "" {
+		return nil
+	}
+	collection := common.GetCollection(s.CollectionName)
+
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: 1}}).SetLimit(exportBatchSize)
+	cursor, err := collection.Find(ctx, bson.M{"exported_at": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch usage to export: %w", err)
+	}
+	var events []localModels.UsageEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return fmt.Errorf("failed to decode usage to export: %w", err)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := s.send(ctx, events); err != nil {
+		return err
+	}
+
+	keys := make([]string, len(events))
+	for i, event := range events {
+		keys[i] = event.EventKey
+	}
+	_, err = collection.UpdateMany(ctx, bson.M{"event_key": bson.M{"$in": keys}}, bson.M{"$set": bson.M{"exported_at": timestamp.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to mark usage exported: %w", err)
+	}
+	zaplogger.GetLogger().Info("Usage exported to billing", zap.Int("events", len(events)))
+	return nil
+}
+
+// send posts a batch of events to the billing system
+func (s *UsageServiceImpl) send(ctx context.Context, events []localModels.UsageEvent) error {
+	body, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return fmt.Errorf("failed to encode usage: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.BillingURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid billing URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send usage to billing: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("billing system responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonthlyUsage(t *testing.T) {
+	row := func(month, eventType string, count int64) usageRow {
+		var r usageRow
+		r.ID.Month, r.ID.Type, r.Count = month, eventType, count
+		return r
+	}
+
+	usage := monthlyUsage([]usageRow{
+		row("2026-10", localModels.UsageApplicantCreated, 3),
+		row("2026-09", localModels.UsageDocumentProcessed, 7),
+		row("2026-10", localModels.UsageVerificationCompleted, 1),
+	})
+
+	assert.Equal(t, []localModels.MonthlyUsage{
+		{Month: "2026-09", Counts: map[string]int64{localModels.UsageDocumentProcessed: 7}},
+		{Month: "2026-10", Counts: map[string]int64{localModels.UsageApplicantCreated: 3, localModels.UsageVerificationCompleted: 1}},
+	}, usage)
+	assert.Empty(t, monthlyUsage(nil))
+}
+
+func TestEventKeyIdentifiesTheWork(t *testing.T) {
+	assert.Equal(t, "applicant_created:app1", eventKey(localModels.UsageApplicantCreated, "app1"))
+	assert.NotEqual(t, eventKey(localModels.UsageApplicantCreated, "x"), eventKey(localModels.UsageDocumentProcessed, "x"))
+}
+
+func TestSend(t *testing.T) {
+	var received struct {
+		Events []localModels.UsageEvent `json:"events"`
+	}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	service := UsageServiceImpl{BillingURL: server.URL, HTTPClient: server.Client()}
+	events := []localModels.UsageEvent{{EventKey: "applicant_created:app1", ClientID: "client1", Type: localModels.UsageApplicantCreated}}
+
+	assert.NoError(t, service.send(context.Background(), events))
+	assert.Equal(t, "applicant_created:app1", received.Events[0].EventKey)
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, service.send(context.Background(), events))
+}
@@ -0,0 +1,74 @@
+package watermark
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// maxPixels bounds the images that are decoded for stamping, as each is held as RGBA
+// while it is drawn on. Phone photos of documents are well under it.
+const maxPixels = 40_000_000
+
+// markColor is a dark red the mark is blended in at, visible on light and dark documents
+var markColor = color.NRGBA{R: 160, G: 0, B: 0, A: uint8(opacity*255 + 0.5)}
+
+// stampImage draws the mark across a PNG or JPEG, keeping its format
+func stampImage(content []byte, mark Mark) ([]byte, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %v", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: image of %dx%d is too large", ErrUnsupported, config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %v", err)
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+	DrawMark(dst, mark)
+
+	var out bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&out, dst)
+	case "jpeg":
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: 90})
+	default:
+		return nil, fmt.Errorf("%w: %s images", ErrUnsupported, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+// DrawMark tiles the mark across an image in place, as files are stamped
+func DrawMark(dst *image.RGBA, mark Mark) {
+	bounds := dst.Bounds()
+	runs, width, height := mark.layout()
+	// Cells are at least a pixel, so the text stays whole on small images
+	p := place(float64(bounds.Dx()), float64(bounds.Dy()), width, height, 1)
+	ink := image.NewUniform(markColor)
+	for _, origin := range p.origins {
+		for _, r := range runs {
+			// Round both edges so neighbouring cells meet without gaps or overlaps
+			x0 := origin[0] + float64(r.x)*p.scale
+			y0 := origin[1] + float64(r.y)*p.scale
+			rect := image.Rect(
+				int(math.Round(x0)), int(math.Round(y0)),
+				int(math.Round(x0+float64(r.length)*p.scale)), int(math.Round(y0+p.scale)),
+			).Add(bounds.Min)
+			draw.Draw(dst, rect.Intersect(bounds), ink, image.Point{}, draw.Over)
+		}
+	}
+}
@@ -0,0 +1,1043 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// PDFs are stamped with an incremental update: the original file is kept byte for byte
+// and the changed pages are appended after it, each given a resource for the mark's
+// transparency and a content stream drawing the mark over the page. Only as much of the
+// file is parsed as that needs: the cross-reference sections, the page tree and the
+// page objects. Encrypted files are not supported, nor are streams compressed with
+// anything but Flate, which is what the cross-reference and object streams of PDFs
+// from scanners and phones use.
+
+const (
+	markGState      = "VerusWatermark" // Name of the mark's graphics state in each page's resources
+	maxNesting      = 64               // Deepest nesting of arrays, dictionaries and page tree nodes followed
+	maxDecodedBytes = 64 << 20         // Largest decompressed cross-reference or object stream
+)
+
+var errMalformed = errors.New("malformed PDF")
+
+type (
+	pdfName   string         // A name without its slash, with any escapes as written
+	pdfNumber string         // A number as written
+	pdfRaw    []byte         // A string, boolean or null as written
+	pdfArray  []any          // An array of values
+	pdfDict   map[string]any // A dictionary keyed by name
+	pdfRef    struct{ num, gen int }
+	pdfStream struct {
+		dict pdfDict
+		data []byte // Still encoded
+	}
+)
+
+// xrefEntry locates an object, either at an offset in the file or in an object stream
+type xrefEntry struct {
+	offset   int
+	inStream bool
+	stream   int
+	index    int
+}
+
+// pdfFile is a PDF opened for stamping. Objects are parsed as they are needed.
+type pdfFile struct {
+	data       []byte
+	startxref  int
+	xrefStream bool // The newest cross-reference section is a stream rather than a table
+	trailer    pdfDict
+	xref       map[int]xrefEntry
+	objects    map[int]any
+	decoded    map[int][]byte // Object streams, decompressed
+	resolving  map[int]bool
+}
+
+// pdfPage is a page with the attributes it inherits from the page tree
+type pdfPage struct {
+	ref       pdfRef
+	dict      pdfDict
+	resources any
+	mediaBox  any
+}
+
+// stampPDF appends an update to a PDF drawing the mark over every page
+func stampPDF(content []byte, mark Mark) ([]byte, error) {
+	f, err := openPDF(content)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := f.pages()
+	if err != nil {
+		return nil, err
+	}
+	size, err := f.integer(f.trailer["Size"])
+	if err != nil {
+		return nil, err
+	}
+
+	update := &pdfUpdate{next: size}
+	gstate := update.add(pdfDict{"Type": pdfName("ExtGState"), "ca": pdfNumber(strconv.FormatFloat(opacity, 'f', -1, 64))})
+	// The page's own content is wrapped in q and Q, so whatever state it leaves behind
+	// does not change how the mark is drawn
+	save := update.addStream([]byte("q\n"), false)
+	stamps := map[[4]float64]pdfRef{}
+	for _, page := range pages {
+		box, err := f.box(page.mediaBox)
+		if err != nil {
+			return nil, err
+		}
+		stamp, ok := stamps[box]
+		if !ok {
+			stamp = update.addStream(markContent(mark, box), true)
+			stamps[box] = stamp
+		}
+
+		resources, err := f.withGState(page.resources, gstate)
+		if err != nil {
+			return nil, err
+		}
+		contents := pdfArray{save}
+		switch old := page.dict["Contents"].(type) {
+		case pdfArray:
+			contents = append(contents, old...)
+		case pdfRef:
+			// Contents may be a stream, or an array of streams stored as its own object
+			resolved, err := f.resolve(old)
+			if err != nil {
+				return nil, err
+			}
+			if array, ok := resolved.(pdfArray); ok {
+				contents = append(contents, array...)
+			} else {
+				contents = append(contents, old)
+			}
+		}
+		contents = append(contents, stamp)
+
+		dict := make(pdfDict, len(page.dict)+2)
+		for key, value := range page.dict {
+			dict[key] = value
+		}
+		dict["Resources"] = resources
+		dict["Contents"] = contents
+		update.replace(page.ref, dict)
+	}
+	return f.write(update), nil
+}
+
+// markContent draws copies of the mark across a page's media box
+func markContent(mark Mark, box [4]float64) []byte {
+	runs, width, height := mark.layout()
+	p := place(box[2]-box[0], box[3]-box[1], width, height, 0)
+
+	var buf bytes.Buffer
+	buf.WriteString("Q\nq\n/" + markGState + " gs\n")
+	fmt.Fprintf(&buf, "%s 0 0 rg\n", formatFloat(float64(markColor.R)/255))
+	for _, origin := range p.origins {
+		for _, r := range runs {
+			x := box[0] + origin[0] + float64(r.x)*p.scale
+			// Pages measure up from the bottom, the layout down from the top
+			y := box[3] - origin[1] - float64(r.y+1)*p.scale
+			fmt.Fprintf(&buf, "%s %s %s %s re\n", formatFloat(x), formatFloat(y), formatFloat(float64(r.length)*p.scale), formatFloat(p.scale))
+		}
+	}
+	buf.WriteString("f\nQ\n")
+	return buf.Bytes()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// openPDF reads a PDF's cross-reference sections, newest first
+func openPDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w: no PDF header", errMalformed)
+	}
+	at := bytes.LastIndex(data, []byte("startxref"))
+	if at < 0 {
+		return nil, fmt.Errorf("%w: no startxref", errMalformed)
+	}
+	l := &pdfLexer{data: data, pos: at + len("startxref")}
+	l.skipSpace()
+	startxref, err := strconv.Atoi(l.token())
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad startxref", errMalformed)
+	}
+
+	f := &pdfFile{
+		data:      data,
+		startxref: startxref,
+		xref:      make(map[int]xrefEntry),
+		objects:   make(map[int]any),
+		decoded:   make(map[int][]byte),
+		resolving: make(map[int]bool),
+	}
+	seen := make(map[int]bool)
+	for offset := startxref; !seen[offset]; {
+		seen[offset] = true
+		trailer, isStream, err := f.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if f.trailer == nil {
+			f.trailer, f.xrefStream = trailer, isStream
+		}
+		// Hybrid files list objects in object streams in a separate cross-reference stream
+		if stm, ok := trailer["XRefStm"]; ok {
+			if stmOffset, err := f.integer(stm); err == nil && !seen[stmOffset] {
+				seen[stmOffset] = true
+				if _, _, err := f.readXref(stmOffset); err != nil {
+					return nil, err
+				}
+			}
+		}
+		prev, ok := trailer["Prev"]
+		if !ok {
+			break
+		}
+		if offset, err = f.integer(prev); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := f.trailer["Encrypt"]; ok {
+		return nil, fmt.Errorf("%w: encrypted PDF", ErrUnsupported)
+	}
+	if _, ok := f.trailer["Root"].(pdfRef); !ok {
+		return nil, fmt.Errorf("%w: no document catalog", errMalformed)
+	}
+	return f, nil
+}
+
+// readXref reads a cross-reference table or stream, keeping entries for objects not
+// already found in a newer section, and returns its trailer
+func (f *pdfFile) readXref(offset int) (pdfDict, bool, error) {
+	if offset < 0 || offset >= len(f.data) {
+		return nil, false, fmt.Errorf("%w: cross-reference offset out of range", errMalformed)
+	}
+	l := &pdfLexer{data: f.data, pos: offset}
+	l.skipSpace()
+	if l.hasPrefix("xref") {
+		l.pos += len("xref")
+		trailer, err := f.readXrefTable(l)
+		return trailer, false, err
+	}
+
+	_, value, err := f.parseObject(offset)
+	if err != nil {
+		return nil, false, err
+	}
+	stream, ok := value.(pdfStream)
+	if !ok || stream.dict["Type"] != pdfName("XRef") {
+		return nil, false, fmt.Errorf("%w: no cross-reference section at %d", errMalformed, offset)
+	}
+	return stream.dict, true, f.readXrefStream(stream)
+}
+
+func (f *pdfFile) readXrefTable(l *pdfLexer) (pdfDict, error) {
+	for {
+		l.skipSpace()
+		if l.hasPrefix("trailer") {
+			l.pos += len("trailer")
+			value, err := l.value(0)
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := value.(pdfDict)
+			if !ok {
+				return nil, fmt.Errorf("%w: bad trailer", errMalformed)
+			}
+			return trailer, nil
+		}
+
+		start, err1 := strconv.Atoi(l.token())
+		l.skipSpace()
+		count, err2 := strconv.Atoi(l.token())
+		if err1 != nil || err2 != nil || start < 0 || count < 0 {
+			return nil, fmt.Errorf("%w: bad cross-reference table", errMalformed)
+		}
+		for i := 0; i < count; i++ {
+			l.skipSpace()
+			offset, err1 := strconv.Atoi(l.token())
+			l.skipSpace()
+			_, err2 := strconv.Atoi(l.token())
+			l.skipSpace()
+			kind := l.token()
+			if err1 != nil || err2 != nil || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("%w: bad cross-reference entry", errMalformed)
+			}
+			if kind == "n" {
+				f.setEntry(start+i, xrefEntry{offset: offset})
+			}
+		}
+	}
+}
+
+func (f *pdfFile) readXrefStream(stream pdfStream) error {
+	data, err := f.decode(stream)
+	if err != nil {
+		return err
+	}
+	widths, ok := stream.dict["W"].(pdfArray)
+	if !ok || len(widths) != 3 {
+		return fmt.Errorf("%w: bad cross-reference stream widths", errMalformed)
+	}
+	var w [3]int
+	for i := range w {
+		if w[i], err = f.integer(widths[i]); err != nil || w[i] < 0 || w[i] > 8 {
+			return fmt.Errorf("%w: bad cross-reference stream widths", errMalformed)
+		}
+	}
+	index, ok := stream.dict["Index"].(pdfArray)
+	if !ok {
+		index = pdfArray{pdfNumber("0"), stream.dict["Size"]}
+	}
+
+	rowLen := w[0] + w[1] + w[2]
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, err1 := f.integer(index[i])
+		count, err2 := f.integer(index[i+1])
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("%w: bad cross-reference stream index", errMalformed)
+		}
+		for j := 0; j < count; j++ {
+			if pos+rowLen > len(data) {
+				return fmt.Errorf("%w: short cross-reference stream", errMalformed)
+			}
+			var fields [3]int
+			for k := range fields {
+				for _, b := range data[pos : pos+w[k]] {
+					fields[k] = fields[k]<<8 | int(b)
+				}
+				pos += w[k]
+			}
+			kind := fields[0]
+			if w[0] == 0 {
+				kind = 1
+			}
+			switch kind {
+			case 1:
+				f.setEntry(start+j, xrefEntry{offset: fields[1]})
+			case 2:
+				f.setEntry(start+j, xrefEntry{inStream: true, stream: fields[1], index: fields[2]})
+			}
+		}
+	}
+	return nil
+}
+
+func (f *pdfFile) setEntry(num int, entry xrefEntry) {
+	if _, ok := f.xref[num]; !ok {
+		f.xref[num] = entry
+	}
+}
+
+// parseObject parses the indirect object at an offset
+func (f *pdfFile) parseObject(offset int) (pdfRef, any, error) {
+	l := &pdfLexer{data: f.data, pos: offset}
+	l.skipSpace()
+	num, err1 := strconv.Atoi(l.token())
+	l.skipSpace()
+	gen, err2 := strconv.Atoi(l.token())
+	l.skipSpace()
+	if err1 != nil || err2 != nil || l.token() != "obj" {
+		return pdfRef{}, nil, fmt.Errorf("%w: no object at %d", errMalformed, offset)
+	}
+	value, err := l.value(0)
+	if err != nil {
+		return pdfRef{}, nil, err
+	}
+
+	dict, ok := value.(pdfDict)
+	l.skipSpace()
+	if !ok || !l.hasPrefix("stream") {
+		return pdfRef{num, gen}, value, nil
+	}
+	l.pos += len("stream")
+	// The keyword ends with CRLF or LF, though some writers use a lone CR
+	if l.hasPrefix("\r\n") {
+		l.pos += 2
+	} else if l.hasPrefix("\n") || l.hasPrefix("\r") {
+		l.pos++
+	}
+	length, err := f.integer(dict["Length"])
+	if err != nil || length < 0 || l.pos+length > len(f.data) {
+		return pdfRef{}, nil, fmt.Errorf("%w: bad stream length in object %d", errMalformed, num)
+	}
+	return pdfRef{num, gen}, pdfStream{dict: dict, data: f.data[l.pos : l.pos+length]}, nil
+}
+
+// object returns an object by number. Objects missing from the file are null.
+func (f *pdfFile) object(num int) (any, error) {
+	if value, ok := f.objects[num]; ok {
+		return value, nil
+	}
+	entry, ok := f.xref[num]
+	if !ok {
+		return nil, nil
+	}
+	if f.resolving[num] {
+		return nil, fmt.Errorf("%w: object %d refers to itself", errMalformed, num)
+	}
+	f.resolving[num] = true
+	defer delete(f.resolving, num)
+
+	var value any
+	var err error
+	if entry.inStream {
+		value, err = f.objectFromStream(entry.stream, entry.index)
+	} else {
+		_, value, err = f.parseObject(entry.offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.objects[num] = value
+	return value, nil
+}
+
+// objectFromStream returns the object at an index of an object stream
+func (f *pdfFile) objectFromStream(streamNum, index int) (any, error) {
+	value, err := f.object(streamNum)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := value.(pdfStream)
+	if !ok {
+		return nil, fmt.Errorf("%w: object %d is not an object stream", errMalformed, streamNum)
+	}
+	data, ok := f.decoded[streamNum]
+	if !ok {
+		if data, err = f.decode(stream); err != nil {
+			return nil, err
+		}
+		f.decoded[streamNum] = data
+	}
+	first, err := f.integer(stream.dict["First"])
+	if err != nil {
+		return nil, err
+	}
+
+	// The stream starts with pairs of object number and offset from First
+	l := &pdfLexer{data: data}
+	offset := -1
+	for i := 0; i <= index; i++ {
+		l.skipSpace()
+		_, err1 := strconv.Atoi(l.token())
+		l.skipSpace()
+		at, err2 := strconv.Atoi(l.token())
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%w: bad object stream %d", errMalformed, streamNum)
+		}
+		offset = at
+	}
+	if first+offset < 0 || first+offset >= len(data) {
+		return nil, fmt.Errorf("%w: bad object stream %d", errMalformed, streamNum)
+	}
+	l.pos = first + offset
+	return l.value(0)
+}
+
+// resolve follows a reference to the object it names
+func (f *pdfFile) resolve(value any) (any, error) {
+	if ref, ok := value.(pdfRef); ok {
+		return f.object(ref.num)
+	}
+	return value, nil
+}
+
+func (f *pdfFile) resolveDict(value any) (pdfDict, error) {
+	resolved, err := f.resolve(value)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := resolved.(pdfDict)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected a dictionary", errMalformed)
+	}
+	return dict, nil
+}
+
+func (f *pdfFile) integer(value any) (int, error) {
+	resolved, err := f.resolve(value)
+	if err != nil {
+		return 0, err
+	}
+	number, ok := resolved.(pdfNumber)
+	if !ok {
+		return 0, fmt.Errorf("%w: expected a number", errMalformed)
+	}
+	n, err := strconv.Atoi(string(number))
+	if err != nil {
+		return 0, fmt.Errorf("%w: expected an integer", errMalformed)
+	}
+	return n, nil
+}
+
+// box reads a page's media box, defaulting to US Letter as readers do
+func (f *pdfFile) box(value any) ([4]float64, error) {
+	box := [4]float64{0, 0, 612, 792}
+	if value == nil {
+		return box, nil
+	}
+	resolved, err := f.resolve(value)
+	if err != nil {
+		return box, err
+	}
+	array, ok := resolved.(pdfArray)
+	if !ok || len(array) != 4 {
+		return box, fmt.Errorf("%w: bad media box", errMalformed)
+	}
+	for i, v := range array {
+		resolved, err := f.resolve(v)
+		if err != nil {
+			return box, err
+		}
+		number, ok := resolved.(pdfNumber)
+		if !ok {
+			return box, fmt.Errorf("%w: bad media box", errMalformed)
+		}
+		if box[i], err = strconv.ParseFloat(string(number), 64); err != nil {
+			return box, fmt.Errorf("%w: bad media box", errMalformed)
+		}
+	}
+	// Corners may be given in either order
+	if box[0] > box[2] {
+		box[0], box[2] = box[2], box[0]
+	}
+	if box[1] > box[3] {
+		box[1], box[3] = box[3], box[1]
+	}
+	return box, nil
+}
+
+// pages walks the page tree, in page order
+func (f *pdfFile) pages() ([]pdfPage, error) {
+	catalog, err := f.resolveDict(f.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []pdfPage
+	visited := make(map[pdfRef]bool)
+	var walk func(node any, resources, mediaBox any, depth int) error
+	walk = func(node any, resources, mediaBox any, depth int) error {
+		ref, ok := node.(pdfRef)
+		if !ok || visited[ref] || depth > maxNesting {
+			return fmt.Errorf("%w: bad page tree", errMalformed)
+		}
+		visited[ref] = true
+		dict, err := f.resolveDict(ref)
+		if err != nil {
+			return err
+		}
+		if value, ok := dict["Resources"]; ok {
+			resources = value
+		}
+		if value, ok := dict["MediaBox"]; ok {
+			mediaBox = value
+		}
+
+		// Some writers leave out Type, so a node with kids is taken to be a branch
+		if _, branch := dict["Kids"]; dict["Type"] != pdfName("Pages") && !branch {
+			pages = append(pages, pdfPage{ref: ref, dict: dict, resources: resources, mediaBox: mediaBox})
+			return nil
+		}
+		kids, err := f.resolve(dict["Kids"])
+		if err != nil {
+			return err
+		}
+		array, ok := kids.(pdfArray)
+		if !ok {
+			return fmt.Errorf("%w: bad page tree", errMalformed)
+		}
+		for _, kid := range array {
+			if err := walk(kid, resources, mediaBox, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(catalog["Pages"], nil, nil, 0); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w: no pages", errMalformed)
+	}
+	return pages, nil
+}
+
+// withGState returns a copy of a page's resources that also names the mark's graphics state
+func (f *pdfFile) withGState(resources any, gstate pdfRef) (pdfDict, error) {
+	merged := pdfDict{}
+	if resources != nil {
+		dict, err := f.resolveDict(resources)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range dict {
+			merged[key] = value
+		}
+	}
+	states := pdfDict{}
+	if existing, ok := merged["ExtGState"]; ok {
+		dict, err := f.resolveDict(existing)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range dict {
+			states[key] = value
+		}
+	}
+	states[markGState] = gstate
+	merged["ExtGState"] = states
+	return merged, nil
+}
+
+// decode decompresses a stream's data
+func (f *pdfFile) decode(stream pdfStream) ([]byte, error) {
+	filter, err := f.resolve(stream.dict["Filter"])
+	if err != nil {
+		return nil, err
+	}
+	params, err := f.resolve(stream.dict["DecodeParms"])
+	if err != nil {
+		return nil, err
+	}
+	if array, ok := filter.(pdfArray); ok && len(array) == 1 {
+		filter = array[0]
+		if list, ok := params.(pdfArray); ok && len(list) == 1 {
+			params = list[0]
+		}
+	}
+	switch filter {
+	case nil:
+		return stream.data, nil
+	case pdfName("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("%w: stream filter %v", ErrUnsupported, filter)
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(stream.data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad compressed stream", errMalformed)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, maxDecodedBytes+1))
+	if err != nil || len(data) > maxDecodedBytes {
+		return nil, fmt.Errorf("%w: bad compressed stream", errMalformed)
+	}
+
+	dict, _ := params.(pdfDict)
+	if dict == nil || dict["Predictor"] == nil {
+		return data, nil
+	}
+	predictor, err := f.integer(dict["Predictor"])
+	if err != nil {
+		return nil, err
+	}
+	columns := 1
+	if dict["Columns"] != nil {
+		if columns, err = f.integer(dict["Columns"]); err != nil || columns < 1 {
+			return nil, fmt.Errorf("%w: bad predictor columns", errMalformed)
+		}
+	}
+	switch {
+	case predictor == 1:
+		return data, nil
+	case predictor >= 10:
+		return unpredictPNG(data, columns)
+	default:
+		return nil, fmt.Errorf("%w: predictor %d", ErrUnsupported, predictor)
+	}
+}
+
+// unpredictPNG reverses PNG row filters, as used on cross-reference streams of one byte
+// per column
+func unpredictPNG(data []byte, columns int) ([]byte, error) {
+	rowLen := columns + 1
+	if len(data)%rowLen != 0 {
+		return nil, fmt.Errorf("%w: bad predicted stream", errMalformed)
+	}
+	out := make([]byte, 0, len(data)/rowLen*columns)
+	prev := make([]byte, columns)
+	for i := 0; i < len(data); i += rowLen {
+		filter := data[i]
+		row := append([]byte(nil), data[i+1:i+rowLen]...)
+		for j := range row {
+			var left, upLeft byte
+			if j > 0 {
+				left, upLeft = row[j-1], prev[j-1]
+			}
+			up := prev[j]
+			switch filter {
+			case 0:
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += up
+			case 3:
+				row[j] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[j] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: bad predicted stream", errMalformed)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// pdfUpdate is the set of objects an incremental update adds or replaces
+type pdfUpdate struct {
+	next    int // Number of the next new object
+	refs    []pdfRef
+	objects [][]byte
+}
+
+func (u *pdfUpdate) add(value any) pdfRef {
+	ref := pdfRef{num: u.next}
+	u.next++
+	u.replace(ref, value)
+	return ref
+}
+
+// addStream adds a stream object, compressing its data if asked
+func (u *pdfUpdate) addStream(data []byte, compress bool) pdfRef {
+	dict := pdfDict{}
+	if compress {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+		data = buf.Bytes()
+		dict["Filter"] = pdfName("FlateDecode")
+	}
+	dict["Length"] = pdfNumber(strconv.Itoa(len(data)))
+	var body bytes.Buffer
+	writeValue(&body, dict)
+	body.WriteString("\nstream\n")
+	body.Write(data)
+	body.WriteString("\nendstream")
+
+	ref := pdfRef{num: u.next}
+	u.next++
+	u.refs = append(u.refs, ref)
+	u.objects = append(u.objects, body.Bytes())
+	return ref
+}
+
+func (u *pdfUpdate) replace(ref pdfRef, value any) {
+	var body bytes.Buffer
+	writeValue(&body, value)
+	u.refs = append(u.refs, ref)
+	u.objects = append(u.objects, body.Bytes())
+}
+
+// write returns the file with the update appended, indexed the same way as the file's
+// newest cross-reference section
+func (f *pdfFile) write(u *pdfUpdate) []byte {
+	var out bytes.Buffer
+	out.Write(f.data)
+	if !bytes.HasSuffix(f.data, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	offsets := make(map[pdfRef]int, len(u.refs)+1)
+	writeObject := func(ref pdfRef, body []byte) {
+		offsets[ref] = out.Len()
+		fmt.Fprintf(&out, "%d %d obj\n", ref.num, ref.gen)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+	for i, ref := range u.refs {
+		writeObject(ref, u.objects[i])
+	}
+
+	trailer := pdfDict{
+		"Size": pdfNumber(strconv.Itoa(u.next)),
+		"Root": f.trailer["Root"],
+		"Prev": pdfNumber(strconv.Itoa(f.startxref)),
+	}
+	for _, key := range []string{"Info", "ID"} {
+		if value, ok := f.trailer[key]; ok {
+			trailer[key] = value
+		}
+	}
+
+	refs := append([]pdfRef(nil), u.refs...)
+	var xrefOffset int
+	if f.xrefStream {
+		// The cross-reference stream lists itself, so it takes the last object number
+		self := pdfRef{num: u.next}
+		refs = append(refs, self)
+		sort.Slice(refs, func(i, j int) bool { return refs[i].num < refs[j].num })
+		xrefOffset = out.Len()
+		offsets[self] = xrefOffset
+
+		var rows bytes.Buffer
+		for _, ref := range refs {
+			offset := offsets[ref]
+			rows.Write([]byte{1, byte(offset >> 24), byte(offset >> 16), byte(offset >> 8), byte(offset), byte(ref.gen >> 8), byte(ref.gen)})
+		}
+		trailer["Type"] = pdfName("XRef")
+		trailer["Size"] = pdfNumber(strconv.Itoa(u.next + 1))
+		trailer["W"] = pdfArray{pdfNumber("1"), pdfNumber("4"), pdfNumber("2")}
+		trailer["Index"] = xrefIndex(refs)
+		trailer["Length"] = pdfNumber(strconv.Itoa(rows.Len()))
+		var body bytes.Buffer
+		writeValue(&body, trailer)
+		body.WriteString("\nstream\n")
+		body.Write(rows.Bytes())
+		body.WriteString("\nendstream")
+		writeObject(self, body.Bytes())
+	} else {
+		sort.Slice(refs, func(i, j int) bool { return refs[i].num < refs[j].num })
+		xrefOffset = out.Len()
+		out.WriteString("xref\n")
+		for i := 0; i < len(refs); {
+			j := i + 1
+			for j < len(refs) && refs[j].num == refs[j-1].num+1 {
+				j++
+			}
+			fmt.Fprintf(&out, "%d %d\n", refs[i].num, j-i)
+			for _, ref := range refs[i:j] {
+				fmt.Fprintf(&out, "%010d %05d n\r\n", offsets[ref], ref.gen)
+			}
+			i = j
+		}
+		out.WriteString("trailer\n")
+		writeValue(&out, trailer)
+		out.WriteString("\n")
+	}
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+	return out.Bytes()
+}
+
+// xrefIndex groups sorted object numbers into runs of first number and count
+func xrefIndex(refs []pdfRef) pdfArray {
+	index := pdfArray{}
+	for i := 0; i < len(refs); {
+		j := i + 1
+		for j < len(refs) && refs[j].num == refs[j-1].num+1 {
+			j++
+		}
+		index = append(index, pdfNumber(strconv.Itoa(refs[i].num)), pdfNumber(strconv.Itoa(j-i)))
+		i = j
+	}
+	return index
+}
+
+// writeValue writes a value in PDF syntax. Dictionary keys are sorted so output is stable.
+func writeValue(buf *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case pdfName:
+		buf.WriteString("/" + string(v))
+	case pdfNumber:
+		buf.WriteString(string(v))
+	case pdfRaw:
+		buf.Write(v)
+	case pdfRef:
+		fmt.Fprintf(buf, "%d %d R", v.num, v.gen)
+	case pdfArray:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeValue(buf, item)
+		}
+		buf.WriteByte(']')
+	case pdfDict:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, key := range keys {
+			buf.WriteString("/" + key + " ")
+			writeValue(buf, v[key])
+		}
+		buf.WriteString(">>")
+	}
+}
+
+// pdfLexer reads PDF syntax from a position in a file
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+func isDelimiter(b byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), b) >= 0
+}
+
+func (l *pdfLexer) hasPrefix(s string) bool {
+	return bytes.HasPrefix(l.data[l.pos:], []byte(s))
+}
+
+// skipSpace skips whitespace and comments
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch b := l.data[l.pos]; {
+		case isSpace(b):
+			l.pos++
+		case b == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token reads a run of regular characters, such as a number or keyword
+func (l *pdfLexer) token() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// value parses the value at the lexer's position
+func (l *pdfLexer) value(depth int) (any, error) {
+	if depth > maxNesting {
+		return nil, fmt.Errorf("%w: nested too deeply", errMalformed)
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, fmt.Errorf("%w: unexpected end of file", errMalformed)
+	}
+
+	switch {
+	case l.hasPrefix("/"):
+		l.pos++
+		return pdfName(l.token()), nil
+	case l.hasPrefix("<<"):
+		l.pos += 2
+		dict := pdfDict{}
+		for {
+			l.skipSpace()
+			if l.hasPrefix(">>") {
+				l.pos += 2
+				return dict, nil
+			}
+			key, err := l.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, fmt.Errorf("%w: dictionary key is not a name", errMalformed)
+			}
+			value, err := l.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[string(name)] = value
+		}
+	case l.hasPrefix("<"):
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: unterminated string", errMalformed)
+		}
+		raw := pdfRaw(l.data[l.pos : l.pos+end+1])
+		l.pos += end + 1
+		return raw, nil
+	case l.hasPrefix("("):
+		start, open := l.pos, 0
+		for ; l.pos < len(l.data); l.pos++ {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				open++
+			case ')':
+				if open--; open == 0 {
+					l.pos++
+					return pdfRaw(l.data[start:l.pos]), nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%w: unterminated string", errMalformed)
+	case l.hasPrefix("["):
+		l.pos++
+		array := pdfArray{}
+		for {
+			l.skipSpace()
+			if l.hasPrefix("]") {
+				l.pos++
+				return array, nil
+			}
+			value, err := l.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+	}
+
+	token := l.token()
+	switch token {
+	case "":
+		return nil, fmt.Errorf("%w: unexpected %q", errMalformed, l.data[l.pos])
+	case "null":
+		return nil, nil
+	case "true", "false":
+		return pdfRaw(token), nil
+	}
+	if num, err := strconv.Atoi(token); err == nil && num >= 0 {
+		// Two integers followed by R are a reference
+		save := l.pos
+		l.skipSpace()
+		genToken := l.token()
+		l.skipSpace()
+		if gen, err := strconv.Atoi(genToken); err == nil && l.token() == "R" {
+			return pdfRef{num: num, gen: gen}, nil
+		}
+		l.pos = save
+	}
+	if _, err := strconv.ParseFloat(token, 64); err != nil {
+		return nil, fmt.Errorf("%w: unexpected %q", errMalformed, token)
+	}
+	return pdfNumber(token), nil
+}
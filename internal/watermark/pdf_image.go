@@ -0,0 +1,127 @@
+package watermark
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+)
+
+// maxImageBytes bounds the decoded samples of a page image, as for images being stamped
+const maxImageBytes = maxPixels * 3
+
+// PageImage returns the largest image drawn on the first page of a PDF. PDFs from scanners
+// and phones hold each page as a single JPEG or Flate compressed image, which is what this
+// reads; pages of text and vector drawings have no image to return and give ErrUnsupported,
+// as do images in colour spaces other than RGB and grey.
+func PageImage(content []byte) (image.Image, error) {
+	f, err := openPDF(content)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := f.pages()
+	if err != nil {
+		return nil, err
+	}
+	if pages[0].resources == nil {
+		return nil, fmt.Errorf("%w: first page has no image", ErrUnsupported)
+	}
+	resources, err := f.resolveDict(pages[0].resources)
+	if err != nil {
+		return nil, err
+	}
+	if resources["XObject"] == nil {
+		return nil, fmt.Errorf("%w: first page has no image", ErrUnsupported)
+	}
+	objects, err := f.resolveDict(resources["XObject"])
+	if err != nil {
+		return nil, err
+	}
+
+	var largest pdfStream
+	largestArea := 0
+	for _, value := range objects {
+		resolved, err := f.resolve(value)
+		if err != nil {
+			return nil, err
+		}
+		stream, ok := resolved.(pdfStream)
+		if !ok || stream.dict["Subtype"] != pdfName("Image") {
+			continue
+		}
+		width, err1 := f.integer(stream.dict["Width"])
+		height, err2 := f.integer(stream.dict["Height"])
+		if err1 != nil || err2 != nil || width < 1 || height < 1 {
+			return nil, fmt.Errorf("%w: bad image size", errMalformed)
+		}
+		if width*height > largestArea {
+			largest, largestArea = stream, width*height
+		}
+	}
+	if largestArea == 0 {
+		return nil, fmt.Errorf("%w: first page has no image", ErrUnsupported)
+	}
+	if largestArea > maxPixels {
+		return nil, fmt.Errorf("%w: page image is too large", ErrUnsupported)
+	}
+	return f.decodeImage(largest)
+}
+
+// decodeImage decodes an image XObject of 8 bit RGB or grey samples, or a JPEG
+func (f *pdfFile) decodeImage(stream pdfStream) (image.Image, error) {
+	filter, err := f.resolve(stream.dict["Filter"])
+	if err != nil {
+		return nil, err
+	}
+	if array, ok := filter.(pdfArray); ok && len(array) == 1 {
+		filter = array[0]
+	}
+	if filter == pdfName("DCTDecode") {
+		img, err := jpeg.Decode(bytes.NewReader(stream.data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad JPEG image", errMalformed)
+		}
+		return img, nil
+	}
+
+	width, _ := f.integer(stream.dict["Width"])
+	height, _ := f.integer(stream.dict["Height"])
+	bits, err := f.integer(stream.dict["BitsPerComponent"])
+	if err != nil || bits != 8 {
+		return nil, fmt.Errorf("%w: image of %v bits per component", ErrUnsupported, stream.dict["BitsPerComponent"])
+	}
+	space, err := f.resolve(stream.dict["ColorSpace"])
+	if err != nil {
+		return nil, err
+	}
+	var components int
+	switch space {
+	case pdfName("DeviceRGB"):
+		components = 3
+	case pdfName("DeviceGray"):
+		components = 1
+	default:
+		return nil, fmt.Errorf("%w: image colour space %v", ErrUnsupported, space)
+	}
+	// Predictors are only undone a byte at a time, which suits grey images but not RGB
+	if params, _ := f.resolve(stream.dict["DecodeParms"]); params != nil && components > 1 {
+		return nil, fmt.Errorf("%w: predicted RGB image", ErrUnsupported)
+	}
+
+	samples, err := f.decode(stream)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) < width*height*components || len(samples) > maxImageBytes {
+		return nil, fmt.Errorf("%w: image data does not match its size", errMalformed)
+	}
+	bounds := image.Rect(0, 0, width, height)
+	if components == 1 {
+		return &image.Gray{Pix: samples[:width*height], Stride: width, Rect: bounds}, nil
+	}
+	img := image.NewRGBA(bounds)
+	for i := 0; i < width*height; i++ {
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = samples[i*3], samples[i*3+1], samples[i*3+2], 0xff
+	}
+	return img, nil
+}
@@ -0,0 +1,81 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scannedContent = "q 595 0 0 842 0 0 cm /Im1 Do Q"
+
+// scannedPDF writes a one page document showing a single image, as scanners do
+func scannedPDF(imageDict string, data []byte) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /XObject << /Im1 4 0 R >> >> /Contents 5 0 R >>",
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image %s /Length %d >>\nstream\n%s\nendstream", imageDict, len(data), data),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(scannedContent), scannedContent),
+	}
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+func TestPageImageReadsJPEG(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for i := range src.Pix {
+		src.Pix[i] = 200
+	}
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, src, nil))
+
+	img, err := PageImage(scannedPDF("/Width 40 /Height 20 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode", encoded.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+}
+
+func TestPageImageReadsFlateSamples(t *testing.T) {
+	rgb := bytes.Repeat([]byte{255, 0, 0}, 6)
+	img, err := PageImage(scannedPDF("/Width 3 /Height 2 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", deflate(rgb)))
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, img.At(2, 1))
+
+	grey := []byte{0, 64, 128, 255}
+	img, err = PageImage(scannedPDF("/Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8", grey))
+	require.NoError(t, err)
+	assert.Equal(t, color.Gray{Y: 128}, img.At(0, 1))
+}
+
+func TestPageImageUnsupported(t *testing.T) {
+	// The test document's pages are text
+	_, err := PageImage(testPDF(false))
+	assert.True(t, errors.Is(err, ErrUnsupported))
+
+	_, err = PageImage(scannedPDF("/Width 2 /Height 2 /ColorSpace /DeviceCMYK /BitsPerComponent 8", make([]byte, 16)))
+	assert.True(t, errors.Is(err, ErrUnsupported))
+
+	_, err = PageImage(scannedPDF("/Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 1", []byte{0, 0}))
+	assert.True(t, errors.Is(err, ErrUnsupported))
+
+	_, err = PageImage(scannedPDF("/Width 20 /Height 20 /ColorSpace /DeviceGray /BitsPerComponent 8", []byte{0, 0}))
+	assert.True(t, errors.Is(err, errMalformed))
+}
@@ -0,0 +1,199 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testObjects is a two page document. The first page inherits its media box and
+// resources; the second has its own, with a graphics state of its own.
+var testObjects = map[int]string{
+	1: "<< /Type /Catalog /Pages 2 0 R >>",
+	2: "<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 595 842] /Resources << /Font << /F1 6 0 R >> >> >>",
+	3: "<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+	4: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 842 595] /Contents [5 0 R] /Resources 7 0 R /Annots [] >>",
+	6: "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	7: "<< /Font << /F1 6 0 R >> /ExtGState << /GS1 8 0 R >> >>",
+	8: "<< /Type /ExtGState /CA 1 /Name (Tricky \\) (name)) >>",
+}
+
+const testContent = "BT /F1 12 Tf 72 720 Td (Hello) Tj ET"
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// testPDF writes the test document, indexed by a cross-reference table or, when
+// compressed, the way phone scanners write PDFs: the objects packed into an object
+// stream and indexed by a predicted cross-reference stream
+func testPDF(compressed bool) []byte {
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := map[int]int{}
+	write := func(num int, body string) {
+		offsets[num] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+	write(5, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(testContent), testContent))
+	packed := []int{1, 2, 3, 4, 6, 7, 8}
+
+	if !compressed {
+		for _, num := range packed {
+			write(num, testObjects[num])
+		}
+		xref := out.Len()
+		out.WriteString("xref\n0 9\n0000000000 65535 f\r\n")
+		for num := 1; num <= 8; num++ {
+			fmt.Fprintf(&out, "%010d 00000 n\r\n", offsets[num])
+		}
+		fmt.Fprintf(&out, "trailer\n<< /Size 9 /Root 1 0 R /ID [<abc> <abc>] >>\nstartxref\n%d\n%%%%EOF\n", xref)
+		return out.Bytes()
+	}
+
+	var header, body strings.Builder
+	for _, num := range packed {
+		fmt.Fprintf(&header, "%d %d ", num, body.Len())
+		body.WriteString(testObjects[num] + "\n")
+	}
+	data := deflate([]byte(header.String() + body.String()))
+	write(9, fmt.Sprintf("<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+		len(packed), header.Len(), len(data), data))
+
+	// Rows of type, offset or object stream, and index, each Up-predicted against the last
+	rows := make([][4]byte, 11)
+	for i, num := range packed {
+		rows[num] = [4]byte{2, 0, 9, byte(i)}
+	}
+	offsets[10] = out.Len()
+	for _, num := range []int{5, 9, 10} {
+		rows[num] = [4]byte{1, byte(offsets[num] >> 8), byte(offsets[num]), 0}
+	}
+	var predicted []byte
+	var prev [4]byte
+	for _, row := range rows {
+		predicted = append(predicted, 2)
+		for i := range row {
+			predicted = append(predicted, row[i]-prev[i])
+		}
+		prev = row
+	}
+	data = deflate(predicted)
+	write(10, fmt.Sprintf("<< /Type /XRef /Size 11 /Root 1 0 R /W [1 2 1] /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 4 >> /Length %d >>\nstream\n%s\nendstream",
+		len(data), data))
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", offsets[10])
+	return out.Bytes()
+}
+
+func TestStampPDF(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compressed=%v", compressed), func(t *testing.T) {
+			original := testPDF(compressed)
+			stamped, err := Stamp(original, "application/pdf", testMark)
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(stamped, original), "the original revision is kept as it was")
+
+			f, err := openPDF(stamped)
+			require.NoError(t, err)
+			assert.Equal(t, compressed, f.xrefStream)
+			pages, err := f.pages()
+			require.NoError(t, err)
+			require.Len(t, pages, 2)
+
+			var stamps []pdfRef
+			for _, page := range pages {
+				contents, ok := page.dict["Contents"].(pdfArray)
+				require.True(t, ok)
+				require.Len(t, contents, 3)
+				assert.Equal(t, pdfRef{num: 5}, contents[1], "the page's own content stays in the middle")
+
+				save, err := f.resolve(contents[0])
+				require.NoError(t, err)
+				assert.Equal(t, "q\n", string(save.(pdfStream).data))
+
+				stamp, err := f.resolve(contents[2])
+				require.NoError(t, err)
+				data, err := f.decode(stamp.(pdfStream))
+				require.NoError(t, err)
+				assert.True(t, strings.HasPrefix(string(data), "Q\nq\n/"+markGState+" gs\n"))
+				assert.Contains(t, string(data), " re\n")
+				stamps = append(stamps, contents[2].(pdfRef))
+
+				states, err := f.resolveDict(page.resources)
+				require.NoError(t, err)
+				gstates := states["ExtGState"].(pdfDict)
+				gstate, err := f.resolveDict(gstates[markGState])
+				require.NoError(t, err)
+				assert.Equal(t, pdfNumber("0.3"), gstate["ca"])
+				assert.Equal(t, pdfDict{"F1": pdfRef{num: 6}}, states["Font"])
+			}
+			// Pages of different sizes get their own copy of the mark
+			assert.NotEqual(t, stamps[0], stamps[1])
+
+			second := pages[1]
+			states, err := f.resolveDict(second.resources)
+			require.NoError(t, err)
+			assert.Equal(t, pdfRef{num: 8}, states["ExtGState"].(pdfDict)["GS1"])
+			assert.Equal(t, pdfArray{}, second.dict["Annots"])
+			assert.Equal(t, pdfRef{num: 2}, second.dict["Parent"])
+			box, err := f.box(second.mediaBox)
+			require.NoError(t, err)
+			assert.Equal(t, [4]float64{0, 0, 842, 595}, box)
+
+			gstate, err := f.resolveDict(pdfRef{num: 8})
+			require.NoError(t, err)
+			assert.Equal(t, pdfRaw(`(Tricky \) (name))`), gstate["Name"])
+
+			// A stamped file can be stamped again, chaining a second update
+			again, err := Stamp(stamped, "application/pdf", testMark)
+			require.NoError(t, err)
+			f, err = openPDF(again)
+			require.NoError(t, err)
+			pages, err = f.pages()
+			require.NoError(t, err)
+			assert.Len(t, pages[0].dict["Contents"], 5)
+		})
+	}
+}
+
+func TestStampPDFErrors(t *testing.T) {
+	encrypted := bytes.Replace(testPDF(false), []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt 9 0 R"), 1)
+	_, err := Stamp(encrypted, "application/pdf", testMark)
+	assert.True(t, errors.Is(err, ErrUnsupported))
+
+	tests := map[string][]byte{
+		"Not a PDF":         []byte("hello"),
+		"Truncated":         testPDF(false)[:200],
+		"Bad xref offset":   bytes.Replace(testPDF(false), []byte("startxref\n"), []byte("startxref\n9"), 1),
+		"Missing catalog":   bytes.Replace(testPDF(false), []byte("/Root 1 0 R"), []byte("/Root null  "), 1),
+		"Looping page tree": bytes.Replace(testPDF(false), []byte("/Kids [3 0 R 4 0 R]"), []byte("/Kids [2 0 R 4 0 R]"), 1),
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Stamp(content, "application/pdf", testMark)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, errMalformed))
+		})
+	}
+}
+
+func TestUnpredictPNG(t *testing.T) {
+	// Sub, Up, Average and Paeth rows over two columns
+	data := []byte{0, 10, 20, 1, 5, 5, 2, 1, 1, 3, 4, 4, 4, 1, 1}
+	out, err := unpredictPNG(data, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{10, 20, 5, 10, 6, 11, 7, 13, 8, 14}, out)
+
+	_, err = unpredictPNG(data[:4], 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,196 @@
+// Package watermark stamps visible text across PDF and image files as they are
+// downloaded, so a leaked copy of an identity document shows who it was given to.
+//
+// Text is drawn from a built-in 5x7 bitmap font, so no font files are needed and the
+// same marks are drawn on images as pixels and on PDF pages as filled rectangles.
+// Lowercase letters are drawn as capitals and characters outside printable ASCII as '?'.
+//
+// Previews use the same pieces: PageImage reads the page image of a scanned PDF, and
+// DrawMark stamps an image that is already decoded.
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrUnsupported is returned for files that are valid but cannot be stamped, such as
+// encrypted PDFs
+var ErrUnsupported = errors.New("file cannot be watermarked")
+
+// Mark is the text stamped across a file, one entry per line
+type Mark struct {
+	Lines []string
+}
+
+// Stamp returns a copy of a PDF, PNG or JPEG file with the mark tiled across every page
+// or across the image
+func Stamp(content []byte, mimeType string, mark Mark) ([]byte, error) {
+	if len(mark.Lines) == 0 {
+		return nil, fmt.Errorf("watermark has no text")
+	}
+	switch mimeType {
+	case "application/pdf":
+		return stampPDF(content, mark)
+	case "image/png", "image/jpeg":
+		return stampImage(content, mark)
+	default:
+		return nil, fmt.Errorf("%w: %s files", ErrUnsupported, mimeType)
+	}
+}
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+	advance     = glyphWidth + 1  // Columns from one character to the next
+	lineHeight  = glyphHeight + 3 // Rows from one line to the next
+	maxLineLen  = 60              // Longer lines are cut, so the text stays legible once scaled to the page
+	opacity     = 0.3
+)
+
+// run is a horizontal stretch of set cells in a mark, in cell units from its top left
+type run struct {
+	x, y, length int
+}
+
+// layout returns the runs making up a mark's text and its size in cells
+func (m Mark) layout() (runs []run, width, height int) {
+	for line, text := range m.Lines {
+		chars := []rune(text)
+		if len(chars) > maxLineLen {
+			chars = chars[:maxLineLen]
+		}
+		if w := len(chars)*advance - 1; w > width {
+			width = w
+		}
+		for row := 0; row < glyphHeight; row++ {
+			y := line*lineHeight + row
+			start, length := 0, 0
+			for i, r := range chars {
+				bits := glyph(r)[row]
+				for col := 0; col < glyphWidth; col++ {
+					x := i*advance + col
+					if bits&(1<<(glyphWidth-1-col)) != 0 {
+						if length > 0 && start+length == x {
+							length++
+							continue
+						}
+						if length > 0 {
+							runs = append(runs, run{x: start, y: y, length: length})
+						}
+						start, length = x, 1
+					}
+				}
+			}
+			if length > 0 {
+				runs = append(runs, run{x: start, y: y, length: length})
+			}
+		}
+	}
+	height = len(m.Lines)*lineHeight - (lineHeight - glyphHeight)
+	return runs, width, height
+}
+
+// placement is where copies of a mark go on a page or image of the given size: the
+// scale of one cell, and the top left corner of each copy
+type placement struct {
+	scale   float64
+	origins [][2]float64
+}
+
+// place tiles a mark of width by height cells across an area, sized so one copy spans
+// about half the area's width but with cells no smaller than minScale, in staggered rows
+func place(areaWidth, areaHeight float64, width, height int, minScale float64) placement {
+	p := placement{scale: math.Max(areaWidth*0.5/float64(width), minScale)}
+	blockWidth, blockHeight := float64(width)*p.scale, float64(height)*p.scale
+	stepX, stepY := blockWidth*1.5, blockHeight*4
+	for row, y := 0, blockHeight; y < areaHeight; row, y = row+1, y+stepY {
+		x := areaWidth*0.05 - float64(row%2)*stepX/2
+		for ; x < areaWidth; x += stepX {
+			if x+blockWidth > 0 {
+				p.origins = append(p.origins, [2]float64{x, y})
+			}
+		}
+	}
+	return p
+}
+
+// glyph returns the rows of a character, the low five bits of each set where it is drawn
+func glyph(r rune) [glyphHeight]uint8 {
+	if r >= 'a' && r <= 'z' {
+		r -= 'a' - 'A'
+	}
+	if r < ' ' || r > '_' {
+		r = '?'
+	}
+	return glyphs[r-' ']
+}
+
+// glyphs covers ' ' to '_', which takes in digits, capitals and common punctuation
+var glyphs = [64][glyphHeight]uint8{
+	{0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b00000}, // ' '
+	{0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00000, 0b00100}, // !
+	{0b01010, 0b01010, 0b01010, 0b00000, 0b00000, 0b00000, 0b00000}, // "
+	{0b01010, 0b01010, 0b11111, 0b01010, 0b11111, 0b01010, 0b01010}, // #
+	{0b00100, 0b01111, 0b10100, 0b01110, 0b00101, 0b11110, 0b00100}, // $
+	{0b11000, 0b11001, 0b00010, 0b00100, 0b01000, 0b10011, 0b00011}, // %
+	{0b01100, 0b10010, 0b10100, 0b01000, 0b10101, 0b10010, 0b01101}, // &
+	{0b00100, 0b00100, 0b01000, 0b00000, 0b00000, 0b00000, 0b00000}, // '
+	{0b00010, 0b00100, 0b01000, 0b01000, 0b01000, 0b00100, 0b00010}, // (
+	{0b01000, 0b00100, 0b00010, 0b00010, 0b00010, 0b00100, 0b01000}, // )
+	{0b00000, 0b00100, 0b10101, 0b01110, 0b10101, 0b00100, 0b00000}, // *
+	{0b00000, 0b00100, 0b00100, 0b11111, 0b00100, 0b00100, 0b00000}, // +
+	{0b00000, 0b00000, 0b00000, 0b00000, 0b01100, 0b00100, 0b01000}, // ,
+	{0b00000, 0b00000, 0b00000, 0b11111, 0b00000, 0b00000, 0b00000}, // -
+	{0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b01100, 0b01100}, // .
+	{0b00000, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b00000}, // /
+	{0b01110, 0b10001, 0b10011, 0b10101, 0b11001, 0b10001, 0b01110}, // 0
+	{0b00100, 0b01100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110}, // 1
+	{0b01110, 0b10001, 0b00001, 0b00010, 0b00100, 0b01000, 0b11111}, // 2
+	{0b11111, 0b00010, 0b00100, 0b00010, 0b00001, 0b10001, 0b01110}, // 3
+	{0b00010, 0b00110, 0b01010, 0b10010, 0b11111, 0b00010, 0b00010}, // 4
+	{0b11111, 0b10000, 0b11110, 0b00001, 0b00001, 0b10001, 0b01110}, // 5
+	{0b00110, 0b01000, 0b10000, 0b11110, 0b10001, 0b10001, 0b01110}, // 6
+	{0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b01000, 0b01000}, // 7
+	{0b01110, 0b10001, 0b10001, 0b01110, 0b10001, 0b10001, 0b01110}, // 8
+	{0b01110, 0b10001, 0b10001, 0b01111, 0b00001, 0b00010, 0b01100}, // 9
+	{0b00000, 0b01100, 0b01100, 0b00000, 0b01100, 0b01100, 0b00000}, // :
+	{0b00000, 0b01100, 0b01100, 0b00000, 0b01100, 0b00100, 0b01000}, // ;
+	{0b00010, 0b00100, 0b01000, 0b10000, 0b01000, 0b00100, 0b00010}, // <
+	{0b00000, 0b00000, 0b11111, 0b00000, 0b11111, 0b00000, 0b00000}, // =
+	{0b01000, 0b00100, 0b00010, 0b00001, 0b00010, 0b00100, 0b01000}, // >
+	{0b01110, 0b10001, 0b00001, 0b00010, 0b00100, 0b00000, 0b00100}, // ?
+	{0b01110, 0b10001, 0b00001, 0b01101, 0b10101, 0b10101, 0b01110}, // @
+	{0b01110, 0b10001, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001}, // A
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10001, 0b10001, 0b11110}, // B
+	{0b01110, 0b10001, 0b10000, 0b10000, 0b10000, 0b10001, 0b01110}, // C
+	{0b11100, 0b10010, 0b10001, 0b10001, 0b10001, 0b10010, 0b11100}, // D
+	{0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b11111}, // E
+	{0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b10000}, // F
+	{0b01110, 0b10001, 0b10000, 0b10111, 0b10001, 0b10001, 0b01111}, // G
+	{0b10001, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001}, // H
+	{0b01110, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110}, // I
+	{0b00111, 0b00010, 0b00010, 0b00010, 0b00010, 0b10010, 0b01100}, // J
+	{0b10001, 0b10010, 0b10100, 0b11000, 0b10100, 0b10010, 0b10001}, // K
+	{0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b11111}, // L
+	{0b10001, 0b11011, 0b10101, 0b10101, 0b10001, 0b10001, 0b10001}, // M
+	{0b10001, 0b10001, 0b11001, 0b10101, 0b10011, 0b10001, 0b10001}, // N
+	{0b01110, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110}, // O
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10000, 0b10000, 0b10000}, // P
+	{0b01110, 0b10001, 0b10001, 0b10001, 0b10101, 0b10010, 0b01101}, // Q
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10100, 0b10010, 0b10001}, // R
+	{0b01111, 0b10000, 0b10000, 0b01110, 0b00001, 0b00001, 0b11110}, // S
+	{0b11111, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100}, // T
+	{0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110}, // U
+	{0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01010, 0b00100}, // V
+	{0b10001, 0b10001, 0b10001, 0b10101, 0b10101, 0b10101, 0b01010}, // W
+	{0b10001, 0b10001, 0b01010, 0b00100, 0b01010, 0b10001, 0b10001}, // X
+	{0b10001, 0b10001, 0b10001, 0b01010, 0b00100, 0b00100, 0b00100}, // Y
+	{0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b11111}, // Z
+	{0b01110, 0b01000, 0b01000, 0b01000, 0b01000, 0b01000, 0b01110}, // [
+	{0b00000, 0b10000, 0b01000, 0b00100, 0b00010, 0b00001, 0b00000}, // \
+	{0b01110, 0b00010, 0b00010, 0b00010, 0b00010, 0b00010, 0b01110}, // ]
+	{0b00100, 0b01010, 0b10001, 0b00000, 0b00000, 0b00000, 0b00000}, // ^
+	{0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b11111}, // _
+}
@@ -0,0 +1,103 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMark = Mark{Lines: []string{"Acme Bank - KYC review", "admin1 2026-10-16 09:30 UTC"}}
+
+func TestLayout(t *testing.T) {
+	runs, width, height := Mark{Lines: []string{"AB"}}.layout()
+	assert.Equal(t, 2*advance-1, width)
+	assert.Equal(t, glyphHeight, height)
+	// The top row of A is .###. and of B ####.
+	assert.Equal(t, []run{{x: 1, y: 0, length: 3}, {x: 6, y: 0, length: 4}}, runs[:2])
+	// Runs end at the gap between characters, even where both are set either side of it
+	assert.Contains(t, runs, run{x: 0, y: 4, length: 5})
+
+	lower, _, _ := Mark{Lines: []string{"ab"}}.layout()
+	assert.Equal(t, runs, lower)
+
+	_, width, height = Mark{Lines: []string{strings.Repeat("x", 100), "y"}}.layout()
+	assert.Equal(t, maxLineLen*advance-1, width)
+	assert.Equal(t, lineHeight+glyphHeight, height)
+
+	assert.Equal(t, glyph('?'), glyph('é'))
+}
+
+func TestPlace(t *testing.T) {
+	p := place(1000, 1000, 100, 20, 0)
+	assert.Equal(t, 5.0, p.scale)
+	require.NotEmpty(t, p.origins)
+	for _, origin := range p.origins {
+		assert.Less(t, origin[0], 1000.0)
+		assert.Greater(t, origin[0]+500, 0.0)
+		assert.Less(t, origin[1], 1000.0)
+	}
+
+	assert.Equal(t, 1.0, place(100, 100, 400, 20, 1).scale)
+}
+
+// testImage encodes a plain white image
+func testImage(t *testing.T, format string) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		require.NoError(t, png.Encode(&buf, img))
+	case "jpeg":
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	}
+	return buf.Bytes()
+}
+
+func TestStampImage(t *testing.T) {
+	for _, format := range []string{"png", "jpeg"} {
+		t.Run(format, func(t *testing.T) {
+			stamped, err := Stamp(testImage(t, format), "image/"+format, testMark)
+			require.NoError(t, err)
+
+			img, gotFormat, err := image.Decode(bytes.NewReader(stamped))
+			require.NoError(t, err)
+			assert.Equal(t, format, gotFormat)
+			assert.Equal(t, image.Rect(0, 0, 800, 600), img.Bounds())
+
+			// The mark is drawn in red at partial opacity, leaving the page showing through
+			tinted := 0
+			for y := 0; y < 600; y++ {
+				for x := 0; x < 800; x++ {
+					c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+					if c.R > c.G+40 {
+						tinted++
+						assert.Greater(t, c.G, uint8(100))
+					}
+				}
+			}
+			assert.Greater(t, tinted, 5000)
+		})
+	}
+}
+
+func TestStampErrors(t *testing.T) {
+	_, err := Stamp(testImage(t, "png"), "image/png", Mark{})
+	assert.Error(t, err)
+
+	_, err = Stamp([]byte("GIF89a"), "image/gif", testMark)
+	assert.True(t, errors.Is(err, ErrUnsupported))
+
+	_, err = Stamp([]byte("not an image"), "image/png", testMark)
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrUnsupported))
+}
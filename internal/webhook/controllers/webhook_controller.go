@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/interfaces"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/webhook/services"
+	"github.com/rachel-lawrie/verus_backend_core/utils"
+)
+
+const (
+	defaultFailureLimit = 50
+	maxFailureLimit     = 200
+)
+
+// GetWebhookEndpoint is the handler function for reading the client's webhook endpoint
+func GetWebhookEndpoint(c *gin.Context, service interfaces.WebhookService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	endpoint, err := service.GetEndpoint(c.Request.Context(), clientID)
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve webhook endpoint"})
+		return
+	}
+	c.JSON(http.StatusOK, endpoint)
+}
+
+// SetWebhookEndpoint is the handler function for registering the client's webhook endpoint
+func SetWebhookEndpoint(c *gin.Context, service interfaces.WebhookService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestBody struct {
+		URL string `json:"url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+		return
+	}
+	if !services.ValidEndpointURL(requestBody.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http(s) URL"})
+		return
+	}
+
+	endpoint, err := service.SetEndpoint(c.Request.Context(), clientID, requestBody.URL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save webhook endpoint"})
+		return
+	}
+	c.JSON(http.StatusOK, endpoint)
+}
+
+// RotateWebhookSecret is the handler function for giving the client's webhook endpoint a
+// new signing secret. The current secret keeps signing deliveries next to the new one for
+// grace_period_hours, 24 unless given, so the client's receiver can switch over. The
+// response holds the new secret, which cannot be retrieved again.
+func RotateWebhookSecret(c *gin.Context, service interfaces.WebhookService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestBody struct {
+		GracePeriodHours *int `json:"grace_period_hours"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+			return
+		}
+	}
+	grace := services.DefaultRotationGrace
+	if requestBody.GracePeriodHours != nil {
+		grace = time.Duration(*requestBody.GracePeriodHours) * time.Hour
+		if grace < 0 || grace > services.MaxRotationGrace {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("grace_period_hours must be between 0 and %d", int(services.MaxRotationGrace.Hours()))})
+			return
+		}
+	}
+
+	endpoint, err := service.RotateSecret(c.Request.Context(), clientID, grace)
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not rotate webhook secret"})
+		return
+	}
+	c.JSON(http.StatusOK, endpoint)
+}
+
+// VerifyWebhookSignature is the handler function for checking a webhook delivery the
+// client received: the raw body as payload and the X-Verus-Signature header as signature.
+// It answers whether the signature is valid as the client's receiver should decide it.
+func VerifyWebhookSignature(c *gin.Context, service interfaces.WebhookService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestBody struct {
+		Payload   string `json:"payload" binding:"required"`
+		Signature string `json:"signature" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload and signature are required"})
+		return
+	}
+
+	result, err := service.VerifySignature(c.Request.Context(), clientID, requestBody.Signature, []byte(requestBody.Payload))
+	if apperr.Respond(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify webhook signature"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListWebhookFailures is the handler function for listing the client's webhook events
+// that ran out of delivery attempts, most recent first
+func ListWebhookFailures(c *gin.Context, service interfaces.WebhookService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, ok := pagination.Parse(c, pagination.Scope(clientID, "webhook_failures"), defaultFailureLimit, maxFailureLimit)
+	if !ok {
+		return
+	}
+
+	failures, err := service.ListDeadLetters(c.Request.Context(), clientID, page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve webhook failures"})
+		return
+	}
+	pagination.Respond(c, page, failures, func(failure localModels.WebhookDeadLetter) pagination.Cursor {
+		return pagination.Cursor{At: failure.FailedAt, ID: failure.EventID}
+	})
+}
+
+// RedeliverWebhook is the handler function for queueing a failed webhook event for
+// delivery again. The event keeps its ID, so receivers can tell it was sent before.
+func RedeliverWebhook(c *gin.Context, service interfaces.WebhookService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	failure, err := service.Redeliver(c.Request.Context(), clientID, c.Param("id"), clientID)
+	if mongoretry.RespondUnavailable(c, err) {
+		return
+	}
+	switch {
+	case apperr.Respond(c, err):
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not redeliver webhook event"})
+		return
+	}
+	c.JSON(http.StatusAccepted, failure)
+}
+
+// TestWebhook is the handler function for sending a sample event of the type in the body
+// to the client's webhook endpoint, so a receiver can be tried without real applicants.
+// It answers 200 with the endpoint's response whether or not the delivery succeeded.
+func TestWebhook(c *gin.Context, service interfaces.WebhookService) {
+	clientID, err := utils.GetClientIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestBody struct {
+		Type string `json:"type" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type is required"})
+		return
+	}
+
+	result, err := service.SendTestEvent(c.Request.Context(), clientID, requestBody.Type)
+	switch {
+	case apperr.Respond(c, err):
+	case mongoretry.RespondUnavailable(c, err):
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send test webhook"})
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/pagination"
+	"github.com/rachel-lawrie/verus_app_backend/internal/replicareads"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+var (
+	// ErrDeadLetterNotFound is returned when a client has no dead letter with the given event ID
+	ErrDeadLetterNotFound = apperr.NotFound("webhook_failure_not_found", "webhook failure not found")
+	// ErrRedeliveryInProgress is returned when a dead letter's event is already queued again
+	ErrRedeliveryInProgress = apperr.Conflict("redelivery_in_progress", "webhook event is already being redelivered")
+)
+
+// Counters published under "webhook_failures" on the expvar endpoint
+const (
+	statDeadLetters  = "dead_letters"        // Events given up on and moved to the dead-letter collection
+	statRedeliveries = "redeliveries"        // Dead letters queued for delivery again
+	statRateAlerts   = "failure_rate_alerts" // Clients found failing more than the alert rate
+)
+
+var failureStats = expvar.NewMap("webhook_failures")
+
+func init() {
+	for _, name := range []string{statDeadLetters, statRedeliveries, statRateAlerts} {
+		failureStats.Add(name, 0)
+	}
+}
+
+// FailureAlertPolicy raises an alert when too many of a client's recent events are given up on
+type FailureAlertPolicy struct {
+	Rate      float64       // Share of events in Window that may fail; alerts are off when zero
+	Window    time.Duration // How far back events are counted, and how long an alert is held before repeating
+	MinEvents int64         // Fewer events in the window never raise an alert
+}
+
+// failureAlerts remembers when each client was last alerted on, so a failing client
+// raises one alert per window rather than one per delivery run
+type failureAlerts struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newFailureAlerts() *failureAlerts {
+	return &failureAlerts{last: make(map[string]time.Time)}
+}
+
+// due reports whether an alert for the client may be raised now, recording it if so
+func (a *failureAlerts) due(clientID string, now time.Time, window time.Duration) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if last, ok := a.last[clientID]; ok && now.Sub(last) < window {
+		return false
+	}
+	a.last[clientID] = now
+	return true
+}
+
+// exceeds reports whether failed out of total events breaks the policy
+func (p FailureAlertPolicy) exceeds(failed, total int64) bool {
+	if p.Rate <= 0 || total == 0 || total < p.MinEvents {
+		return false
+	}
+	return float64(failed)/float64(total) > p.Rate
+}
+
+// deadLetter keeps an event whose attempts ran out. An event that failed again after
+// being redelivered replaces its earlier dead letter.
+func (s *WebhookServiceImpl) deadLetter(ctx context.Context, event localModels.WebhookEvent, attempts int, deliveryErr error) error {
+	update := bson.M{
+		"$set": bson.M{
+			"client_id":  event.ClientID,
+			"type":       event.Type,
+			"data":       event.Data,
+			"attempts":   attempts,
+			"last_error": deliveryErr.Error(),
+			"created_at": event.CreatedAt,
+			"failed_at":  timestamp.Now(),
+		},
+		"$unset":       bson.M{"redelivered_at": "", "redelivered_by": ""},
+		"$setOnInsert": bson.M{"redeliveries": 0},
+	}
+	err := mongoretry.Write(ctx, "dead_letter_webhook", func(ctx context.Context) error {
+		_, err := common.GetCollection(s.DeadLetterCollectionName).UpdateOne(ctx, bson.M{"event_id": event.EventID}, update, options.Update().SetUpsert(true))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store dead letter: %w", err)
+	}
+	failureStats.Add(statDeadLetters, 1)
+	return nil
+}
+
+// checkFailureRate raises an alert when too many of the client's recent events were given up on
+func (s *WebhookServiceImpl) checkFailureRate(ctx context.Context, clientID string) {
+	if s.FailureAlert.Rate <= 0 || s.alerts == nil {
+		return
+	}
+	now := time.Now()
+	recent := bson.M{"client_id": clientID, "created_at": bson.M{"$gte": now.Add(-s.FailureAlert.Window)}}
+	collection := common.GetCollection(s.CollectionName)
+	total, err := collection.CountDocuments(ctx, recent)
+	if err != nil {
+		zaplogger.GetLogger().Warn("Error counting webhook events for failure rate", zap.Error(err), zap.String("clientID", clientID))
+		return
+	}
+	recent["status"] = localModels.WebhookFailed
+	failed, err := collection.CountDocuments(ctx, recent)
+	if err != nil {
+		zaplogger.GetLogger().Warn("Error counting failed webhook events for failure rate", zap.Error(err), zap.String("clientID", clientID))
+		return
+	}
+	if !s.FailureAlert.exceeds(failed, total) || !s.alerts.due(clientID, now, s.FailureAlert.Window) {
+		return
+	}
+	failureStats.Add(statRateAlerts, 1)
+	zaplogger.GetLogger().Error("Webhook failure rate above alert threshold",
+		zap.Bool("alert", true),
+		zap.String("clientID", clientID),
+		zap.Int64("failed", failed),
+		zap.Int64("events", total),
+		zap.Float64("threshold", s.FailureAlert.Rate),
+		zap.Duration("window", s.FailureAlert.Window),
+	)
+}
+
+// ListDeadLetters lists the client's webhook events that ran out of delivery attempts, most recent first
+func (s *WebhookServiceImpl) ListDeadLetters(ctx context.Context, clientID string, page pagination.Page) ([]localModels.WebhookDeadLetter, error) {
+	filter := page.Filter(bson.M{"client_id": clientID}, "failed_at", "event_id")
+	cursor, err := replicareads.Collection(ctx, common.GetCollection(s.DeadLetterCollectionName)).Find(ctx, filter, page.Options("failed_at", "event_id"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook dead letters: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	deadLetters := []localModels.WebhookDeadLetter{}
+	if err := cursor.All(ctx, &deadLetters); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook dead letters: %w", err)
+	}
+	return deadLetters, nil
+}
+
+// Redeliver queues a dead letter's event for delivery again, with its attempts reset.
+// The dead letter is kept, marked as redelivered, until the event is delivered, when it
+// is removed, or fails again.
+func (s *WebhookServiceImpl) Redeliver(ctx context.Context, clientID, eventID, redeliveredBy string) (localModels.WebhookDeadLetter, error) {
+	now := timestamp.Now()
+	claim := bson.M{"event_id": eventID, "client_id": clientID, "redelivered_at": nil}
+	update := bson.M{
+		"$set": bson.M{"redelivered_at": now, "redelivered_by": redeliveredBy},
+		"$inc": bson.M{"redeliveries": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var deadLetter localModels.WebhookDeadLetter
+	deadLetters := common.GetCollection(s.DeadLetterCollectionName)
+	err := deadLetters.FindOneAndUpdate(ctx, claim, update, opts).Decode(&deadLetter)
+	if err == mongo.ErrNoDocuments {
+		n, countErr := deadLetters.CountDocuments(ctx, bson.M{"event_id": eventID, "client_id": clientID})
+		if countErr != nil {
+			return deadLetter, fmt.Errorf("failed to look up webhook dead letter: %w", countErr)
+		}
+		if n == 0 {
+			return deadLetter, ErrDeadLetterNotFound
+		}
+		return deadLetter, ErrRedeliveryInProgress
+	}
+	if err != nil {
+		return deadLetter, fmt.Errorf("failed to claim webhook dead letter: %w", err)
+	}
+
+	requeue := bson.M{
+		"$set": bson.M{
+			"status":          localModels.WebhookPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"redelivered":     true,
+		},
+		"$unset": bson.M{"last_error": ""},
+	}
+	err = mongoretry.Write(ctx, "redeliver_webhook", func(ctx context.Context) error {
+		_, err := common.GetCollection(s.CollectionName).UpdateOne(ctx, bson.M{"event_id": eventID, "client_id": clientID}, requeue)
+		return err
+	})
+	if err != nil {
+		// Release the claim so the client can try again
+		release := bson.M{"$unset": bson.M{"redelivered_at": "", "redelivered_by": ""}, "$inc": bson.M{"redeliveries": -1}}
+		if _, releaseErr := deadLetters.UpdateOne(ctx, bson.M{"event_id": eventID}, release); releaseErr != nil {
+			zaplogger.GetLogger().Error("Error releasing webhook dead letter", zap.Error(releaseErr), zap.String("eventID", eventID))
+		}
+		return localModels.WebhookDeadLetter{}, err
+	}
+	failureStats.Add(statRedeliveries, 1)
+	return deadLetter, nil
+}
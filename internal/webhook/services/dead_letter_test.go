@@ -0,0 +1,28 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureAlertPolicyExceeds(t *testing.T) {
+	policy := FailureAlertPolicy{Rate: 0.5, Window: time.Hour, MinEvents: 10}
+
+	assert.False(t, policy.exceeds(0, 0))
+	assert.False(t, policy.exceeds(9, 9), "too few events to alert on")
+	assert.False(t, policy.exceeds(5, 10), "a rate equal to the threshold does not alert")
+	assert.True(t, policy.exceeds(6, 10))
+	assert.False(t, FailureAlertPolicy{}.exceeds(10, 10), "alerts are off with no rate")
+}
+
+func TestFailureAlertsDue(t *testing.T) {
+	alerts := newFailureAlerts()
+	now := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+
+	assert.True(t, alerts.due("client1", now, time.Hour))
+	assert.False(t, alerts.due("client1", now.Add(30*time.Minute), time.Hour), "held within the window")
+	assert.True(t, alerts.due("client2", now.Add(30*time.Minute), time.Hour), "held per client")
+	assert.True(t, alerts.due("client1", now.Add(time.Hour), time.Hour))
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+)
+
+// responseExcerptBytes bounds how much of the endpoint's response a test delivery returns
+const responseExcerptBytes = 1024
+
+// ErrUnknownEventType is returned when a test event is asked for a type that is never sent
+var ErrUnknownEventType = apperr.BadRequest("unknown_event_type", "unknown webhook event type")
+
+// sampleEvents holds a realistic payload for each event type, naming no real applicant
+var sampleEvents = map[string]func(now time.Time) interface{}{
+	localModels.WebhookDocumentUploadFailed: func(now time.Time) interface{} {
+		return localModels.DocumentUploadFailedData{
+			ApplicantID:  "00000000-0000-0000-0000-000000000001",
+			DocumentID:   "00000000-0000-0000-0000-000000000002",
+			DocumentType: "passport",
+			Reason:       "file could not be stored",
+			Action:       "reupload",
+			Annotations: &localModels.Annotations{
+				Tags:     []string{"sample"},
+				Metadata: map[string]string{"customer_ref": "sample-0001"},
+			},
+		}
+	},
+	localModels.WebhookDocumentRestored: func(now time.Time) interface{} {
+		return localModels.DocumentRestoredData{
+			ApplicantID:   "00000000-0000-0000-0000-000000000001",
+			DocumentID:    "00000000-0000-0000-0000-000000000002",
+			DocumentType:  "passport",
+			RestoredUntil: now.UTC().AddDate(0, 0, 7).Truncate(24 * time.Hour),
+			Annotations: &localModels.Annotations{
+				Tags:     []string{"sample"},
+				Metadata: map[string]string{"customer_ref": "sample-0001"},
+			},
+		}
+	},
+	localModels.WebhookApplicantResubmissionRequired: func(now time.Time) interface{} {
+		return localModels.ApplicantResubmissionData{
+			ApplicantID: "00000000-0000-0000-0000-000000000001",
+			Provider:    "sumsub",
+			Documents: []localModels.ResubmittedDocument{{
+				DocumentID:   "00000000-0000-0000-0000-000000000002",
+				DocumentType: "passport",
+				Guidance:     []localModels.ResubmissionGuidance{localModels.GuidanceBlurryImage, localModels.GuidanceCroppedDocument},
+			}},
+			Annotations: &localModels.Annotations{
+				Tags:     []string{"sample"},
+				Metadata: map[string]string{"customer_ref": "sample-0001"},
+			},
+		}
+	},
+	localModels.WebhookReportCompleted: func(now time.Time) interface{} {
+		to := now.UTC().Truncate(24 * time.Hour)
+		return localModels.ComplianceReportData{
+			ReportID: "00000000-0000-0000-0000-000000000003",
+			Format:   localModels.ReportFormatCSV,
+			From:     to.AddDate(0, -1, 0),
+			To:       to,
+			Status:   localModels.ReportReady,
+		}
+	},
+	localModels.WebhookSecurityAlert: func(now time.Time) interface{} {
+		return localModels.SecurityAlertData{
+			Kind:           localModels.SecurityAlertNewCountry,
+			KeyPrefix:      "vk_test_0000",
+			IP:             "203.0.113.7",
+			Country:        "FRA",
+			KnownCountries: []string{"GBR"},
+			At:             now.UTC(),
+		}
+	},
+}
+
+// TestEventTypes lists the event types a sample can be sent for
+func TestEventTypes() []string {
+	types := make([]string, 0, len(sampleEvents))
+	for eventType := range sampleEvents {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// SendTestEvent sends a sample event of a type to the client's webhook endpoint straight
+// away, signed as real deliveries are, and reports how the endpoint answered. The event is
+// marked "test": true and is not queued, so it is neither retried nor dead-lettered.
+func (s *WebhookServiceImpl) SendTestEvent(ctx context.Context, clientID, eventType string) (localModels.WebhookTestResult, error) {
+	sample, ok := sampleEvents[eventType]
+	if !ok {
+		return localModels.WebhookTestResult{}, fmt.Errorf("%w: type must be one of %s", ErrUnknownEventType, strings.Join(TestEventTypes(), ", "))
+	}
+	endpoint, err := s.findEndpoint(ctx, clientID)
+	if err != nil {
+		return localModels.WebhookTestResult{}, err
+	}
+	return s.sendTestEvent(ctx, endpoint, eventType, sample)
+}
+
+// sendTestEvent posts a sample event to an endpoint and records how it answered
+func (s *WebhookServiceImpl) sendTestEvent(ctx context.Context, endpoint localModels.WebhookEndpoint, eventType string, sample func(now time.Time) interface{}) (localModels.WebhookTestResult, error) {
+	now := time.Now()
+	result := localModels.WebhookTestResult{EventID: "test_" + ids.New(), Type: eventType, URL: endpoint.URL}
+	body, err := json.Marshal(map[string]interface{}{
+		"event_id":   result.EventID,
+		"type":       eventType,
+		"created_at": now.UTC(),
+		"data":       sample(now),
+		"test":       true,
+	})
+	if err != nil {
+		return localModels.WebhookTestResult{}, fmt.Errorf("failed to encode test event: %v", err)
+	}
+
+	resp, err := s.post(ctx, endpoint, body)
+	result.LatencyMS = time.Since(now).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.Delivered = resp.StatusCode >= 200 && resp.StatusCode < 300
+	excerpt, err := io.ReadAll(io.LimitReader(resp.Body, responseExcerptBytes))
+	if err != nil {
+		result.Error = fmt.Sprintf("could not read response: %v", err)
+	}
+	result.ResponseExcerpt = strings.ToValidUTF8(string(excerpt), string(utf8.RuneError))
+	if !result.Delivered && result.Error == "" {
+		result.Error = fmt.Sprintf("webhook endpoint responded with status %d", resp.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendTestEvent(t *testing.T) {
+	var received map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if _, err := Verify(r.Header.Get(SignatureHeader), body, time.Now(), "whsec_test"); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &received)
+		w.WriteHeader(status)
+		w.Write([]byte(strings.Repeat("x", 2*responseExcerptBytes)))
+	}))
+	defer server.Close()
+
+	service := WebhookServiceImpl{HTTPClient: server.Client()}
+	endpoint := localModels.WebhookEndpoint{URL: server.URL, Secret: "whsec_test"}
+
+	result, err := service.sendTestEvent(context.Background(), endpoint, localModels.WebhookSecurityAlert, sampleEvents[localModels.WebhookSecurityAlert])
+	require.NoError(t, err)
+	assert.True(t, result.Delivered)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Len(t, result.ResponseExcerpt, responseExcerptBytes)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, true, received["test"])
+	assert.Equal(t, result.EventID, received["event_id"])
+	assert.Equal(t, localModels.SecurityAlertNewCountry, received["data"].(map[string]interface{})["kind"])
+
+	status = http.StatusBadRequest
+	result, err = service.sendTestEvent(context.Background(), endpoint, localModels.WebhookSecurityAlert, sampleEvents[localModels.WebhookSecurityAlert])
+	require.NoError(t, err)
+	assert.False(t, result.Delivered)
+	assert.Equal(t, "webhook endpoint responded with status 400", result.Error)
+
+	endpoint.URL = "http://127.0.0.1:1"
+	result, err = service.sendTestEvent(context.Background(), endpoint, localModels.WebhookSecurityAlert, sampleEvents[localModels.WebhookSecurityAlert])
+	require.NoError(t, err)
+	assert.False(t, result.Delivered)
+	assert.Zero(t, result.StatusCode)
+	assert.NotEmpty(t, result.Error)
+}
+
+func TestSendTestEventOfUnknownType(t *testing.T) {
+	service := WebhookServiceImpl{}
+	_, err := service.SendTestEvent(context.Background(), "client1", "applicant.created")
+	assert.True(t, errors.Is(err, ErrUnknownEventType))
+	assert.Contains(t, err.Error(), "document.restored, document.upload_failed, report.completed, security.alert")
+}
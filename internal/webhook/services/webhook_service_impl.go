@@ -0,0 +1,456 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rachel-lawrie/verus_app_backend/internal/apperr"
+	"github.com/rachel-lawrie/verus_app_backend/internal/clientconfig"
+	localConstants "github.com/rachel-lawrie/verus_app_backend/internal/constants"
+	"github.com/rachel-lawrie/verus_app_backend/internal/egress"
+	"github.com/rachel-lawrie/verus_app_backend/internal/ids"
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/rachel-lawrie/verus_app_backend/internal/mongoretry"
+	"github.com/rachel-lawrie/verus_app_backend/internal/opsmetrics"
+	"github.com/rachel-lawrie/verus_app_backend/internal/timestamp"
+	"github.com/rachel-lawrie/verus_backend_core/common"
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	zap "go.uber.org/zap"
+)
+
+const (
+	// SignatureHeader carries the HMAC signature clients use to verify a delivery. While a
+	// secret rotation is in progress it holds a v1 signature for each active secret.
+	SignatureHeader = "X-Verus-Signature"
+	// TimestampHeader carries the Unix time the delivery was signed at, which is also the
+	// t= value of the signature
+	TimestampHeader = "X-Verus-Timestamp"
+
+	// DefaultRotationGrace is how long a rotated-out secret keeps signing next to its replacement
+	DefaultRotationGrace = 24 * time.Hour
+	// MaxRotationGrace bounds the grace period a client may ask for
+	MaxRotationGrace = 7 * 24 * time.Hour
+	// SignatureTolerance is how far a signature's timestamp may be from the clock of the
+	// one checking it, which receivers should also hold deliveries to
+	SignatureTolerance = 5 * time.Minute
+
+	defaultMaxAttempts = 8
+	deliveryBatchSize  = 100
+	maxBackoff         = 6 * time.Hour
+)
+
+// ErrNoEndpoint is returned when a client has not registered a webhook endpoint
+var ErrNoEndpoint = apperr.NotFound("webhook_endpoint_not_found", "no webhook endpoint registered")
+
+// ErrInjectedDrop is recorded for a delivery dropped by sandbox fault injection
+var ErrInjectedDrop = errors.New("delivery dropped by sandbox fault injection")
+
+// WebhookFaults decides which deliveries to drop, so they are retried as failed ones are
+type WebhookFaults interface {
+	DropDelivery(ctx context.Context, clientID string) bool
+}
+
+// Reasons a webhook signature is not valid
+var (
+	ErrMalformedSignature = errors.New("signature must be of the form t=<timestamp>,v1=<signature>")
+	ErrStaleSignature     = errors.New("signature timestamp is outside the allowed window")
+	ErrSignatureMismatch  = errors.New("signature does not match the payload with any active secret")
+)
+
+// WebhookServiceImpl queues client webhook events in an outbox collection and delivers them
+type WebhookServiceImpl struct {
+	CollectionName           string
+	EndpointCollectionName   string
+	DeadLetterCollectionName string // Events whose delivery attempts ran out
+	HTTPClient               *http.Client
+	MaxAttempts              int
+	ClientConfig             clientconfig.Loader // Per-client retry policies; MaxAttempts applies to every client when nil
+	FailureAlert             FailureAlertPolicy
+	Faults                   WebhookFaults // Drops some deliveries of sandbox clients testing their retries; none when nil
+	alerts                   *failureAlerts
+}
+
+var (
+	instance WebhookServiceImpl
+	once     sync.Once
+)
+
+func GetWebhookServiceImpl() WebhookServiceImpl {
+	once.Do(func() {
+		instance = WebhookServiceImpl{
+			CollectionName:           localConstants.CollectionWebhookEvents,
+			EndpointCollectionName:   localConstants.CollectionWebhookEndpoints,
+			DeadLetterCollectionName: localConstants.CollectionWebhookDeadLetters,
+			HTTPClient:               egress.Client(10 * time.Second),
+			MaxAttempts:              defaultMaxAttempts,
+			alerts:                   newFailureAlerts(),
+		}
+	})
+	return instance
+}
+
+// Emit queues an event for delivery to the client's webhook endpoint
+func (s *WebhookServiceImpl) Emit(ctx context.Context, clientID, eventType string, data interface{}) error {
+	now := timestamp.Now()
+	event := localModels.WebhookEvent{
+		EventID:       ids.Webhook.New(),
+		ClientID:      clientID,
+		Type:          eventType,
+		Data:          data,
+		Status:        localModels.WebhookPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	collection := common.GetCollection(s.CollectionName)
+	if err := mongoretry.InsertOnce(ctx, collection, "emit_webhook", bson.M{"event_id": event.EventID}, event); err != nil {
+		zaplogger.GetLogger().Error("Error queueing webhook event", zap.Error(err), zap.String("clientID", clientID), zap.String("type", eventType))
+		return err
+	}
+	return nil
+}
+
+// DeliverPending sends events that are due for delivery and reschedules the ones that fail
+func (s *WebhookServiceImpl) DeliverPending(ctx context.Context) error {
+	logger := zaplogger.GetLogger()
+	collection := common.GetCollection(s.CollectionName)
+
+	filter := bson.M{
+		"status":          localModels.WebhookPending,
+		"next_attempt_at": bson.M{"$lte": time.Now()},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetLimit(deliveryBatchSize)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pending webhook events: %v", err)
+	}
+	var events []localModels.WebhookEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return fmt.Errorf("failed to decode pending webhook events: %v", err)
+	}
+
+	policies := make(map[string]int)
+	failing := make(map[string]bool)
+	for _, event := range events {
+		deliveryErr := s.deliver(ctx, event)
+		failed, err := s.recordAttempt(ctx, event, deliveryErr, s.maxAttempts(ctx, event.ClientID, policies))
+		if err != nil {
+			logger.Error("Error recording webhook delivery attempt", zap.Error(err), zap.String("eventID", event.EventID))
+		}
+		if failed {
+			failing[event.ClientID] = true
+		}
+	}
+	for clientID := range failing {
+		s.checkFailureRate(ctx, clientID)
+	}
+	return nil
+}
+
+// deliver posts a single event to the client's endpoint
+func (s *WebhookServiceImpl) deliver(ctx context.Context, event localModels.WebhookEvent) error {
+	var endpoint localModels.WebhookEndpoint
+	err := common.GetCollection(s.EndpointCollectionName).FindOne(ctx, bson.M{"client_id": event.ClientID}).Decode(&endpoint)
+	if err == mongo.ErrNoDocuments {
+		return ErrNoEndpoint
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up webhook endpoint: %v", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"event_id":   event.EventID,
+		"type":       event.Type,
+		"created_at": event.CreatedAt,
+		"data":       event.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %v", err)
+	}
+
+	if s.Faults != nil && s.Faults.DropDelivery(ctx, event.ClientID) {
+		return ErrInjectedDrop
+	}
+	started := time.Now()
+	resp, err := s.post(ctx, endpoint, body)
+	if err != nil {
+		opsmetrics.ObserveCall("webhook", "deliver", started, err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("webhook endpoint responded with status %d", resp.StatusCode)
+	}
+	opsmetrics.ObserveCall("webhook", "deliver", started, err)
+	return err
+}
+
+// post signs a delivery body with the endpoint's active secrets and posts it to the endpoint
+func (s *WebhookServiceImpl) post(ctx context.Context, endpoint localModels.WebhookEndpoint, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook endpoint: %v", err)
+	}
+	at := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(at.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(at, body, endpoint.ActiveSecrets(at)...))
+	return s.HTTPClient.Do(req)
+}
+
+// maxAttempts returns the number of delivery attempts for the client's events, remembering
+// each client's policy for the rest of the delivery run
+func (s *WebhookServiceImpl) maxAttempts(ctx context.Context, clientID string, policies map[string]int) int {
+	if s.ClientConfig == nil {
+		return s.MaxAttempts
+	}
+	if attempts, ok := policies[clientID]; ok {
+		return attempts
+	}
+	attempts := s.MaxAttempts
+	client, err := s.ClientConfig.Load(ctx, clientID)
+	if err != nil {
+		zaplogger.GetLogger().Warn("Using default webhook retry policy", zap.Error(err), zap.String("clientID", clientID))
+	} else if client.Settings.Webhooks.MaxAttempts > 0 {
+		attempts = client.Settings.Webhooks.MaxAttempts
+	}
+	policies[clientID] = attempts
+	return attempts
+}
+
+// recordAttempt marks an event delivered, or schedules its next attempt with exponential
+// backoff. failed reports whether the event ran out of attempts, in which case it is also
+// kept as a dead letter.
+func (s *WebhookServiceImpl) recordAttempt(ctx context.Context, event localModels.WebhookEvent, deliveryErr error, maxAttempts int) (failed bool, err error) {
+	collection := common.GetCollection(s.CollectionName)
+	now := timestamp.Now()
+	attempts := event.Attempts + 1
+
+	set := bson.M{"attempts": attempts}
+	if deliveryErr == nil {
+		set["status"] = localModels.WebhookDelivered
+		set["delivered_at"] = now
+	} else {
+		set["last_error"] = deliveryErr.Error()
+		if attempts >= maxAttempts {
+			set["status"] = localModels.WebhookFailed
+			failed = true
+		} else {
+			set["next_attempt_at"] = now.Add(backoff(attempts))
+		}
+		zaplogger.GetLogger().Warn("Webhook delivery failed",
+			zap.Error(deliveryErr),
+			zap.String("eventID", event.EventID),
+			zap.String("clientID", event.ClientID),
+			zap.Int("attempts", attempts),
+		)
+	}
+
+	if _, err := collection.UpdateOne(ctx, bson.M{"event_id": event.EventID}, bson.M{"$set": set}); err != nil {
+		return failed, err
+	}
+	if deliveryErr == nil {
+		// A redelivered event no longer needs its dead letter
+		if event.Redelivered {
+			_, err = common.GetCollection(s.DeadLetterCollectionName).DeleteOne(ctx, bson.M{"event_id": event.EventID})
+		}
+		return false, err
+	}
+	if failed {
+		return true, s.deadLetter(ctx, event, attempts, deliveryErr)
+	}
+	return false, nil
+}
+
+// backoff returns the delay before the next delivery attempt
+func backoff(attempts int) time.Duration {
+	delay := 30 * time.Second << (attempts - 1)
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// Sign computes the value of the signature header: the timestamp and, for each secret,
+// an HMAC-SHA256 of "<timestamp>.<body>" keyed with that secret
+func Sign(at time.Time, body []byte, secrets ...string) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	header := "t=" + timestamp
+	for _, secret := range secrets {
+		header += ",v1=" + hex.EncodeToString(signature(secret, timestamp, body))
+	}
+	return header
+}
+
+func signature(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Verify checks a signature header against a delivery body, returning the index of the
+// first of secrets that made one of its signatures. The timestamp must be within
+// SignatureTolerance of now.
+func Verify(header string, body []byte, now time.Time, secrets ...string) (int, error) {
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return -1, ErrMalformedSignature
+	}
+	if at := time.Unix(seconds, 0); at.Before(now.Add(-SignatureTolerance)) || at.After(now.Add(SignatureTolerance)) {
+		return -1, ErrStaleSignature
+	}
+	for i, secret := range secrets {
+		expected := signature(secret, timestamp, body)
+		for _, sig := range signatures {
+			if hmac.Equal(sig, expected) {
+				return i, nil
+			}
+		}
+	}
+	return -1, ErrSignatureMismatch
+}
+
+// GetEndpoint returns the client's webhook endpoint with a description of its signing
+// secrets in place of the secrets themselves
+func (s *WebhookServiceImpl) GetEndpoint(ctx context.Context, clientID string) (localModels.WebhookEndpoint, error) {
+	endpoint, err := s.findEndpoint(ctx, clientID)
+	if err != nil {
+		return localModels.WebhookEndpoint{}, err
+	}
+	signing := endpoint.SigningState(time.Now())
+	endpoint.Signing = &signing
+	endpoint.Secret, endpoint.PreviousSecret = "", ""
+	return endpoint, nil
+}
+
+func (s *WebhookServiceImpl) findEndpoint(ctx context.Context, clientID string) (localModels.WebhookEndpoint, error) {
+	var endpoint localModels.WebhookEndpoint
+	err := common.GetCollection(s.EndpointCollectionName).FindOne(ctx, bson.M{"client_id": clientID}).Decode(&endpoint)
+	if err == mongo.ErrNoDocuments {
+		return endpoint, ErrNoEndpoint
+	}
+	return endpoint, err
+}
+
+// RotateSecret gives the client's endpoint a new signing secret. The current secret keeps
+// signing next to it for the grace period, replacing any previous secret still in its own.
+// The endpoint is returned with the new secret, which cannot be retrieved again.
+func (s *WebhookServiceImpl) RotateSecret(ctx context.Context, clientID string, grace time.Duration) (localModels.WebhookEndpoint, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return localModels.WebhookEndpoint{}, err
+	}
+	now := timestamp.Now()
+	// The update reads the secret being replaced, so it is applied in one step. It is not
+	// retried, since a second rotation would push the client's current secret out.
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"previous_secret":            "$secret",
+		"previous_secret_retires_at": now.Add(grace),
+		"secret":                     secret,
+		"secret_rotated_at":          now,
+		"updated_at":                 now,
+	}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var endpoint localModels.WebhookEndpoint
+	err = common.GetCollection(s.EndpointCollectionName).FindOneAndUpdate(ctx, bson.M{"client_id": clientID}, update, opts).Decode(&endpoint)
+	if err == mongo.ErrNoDocuments {
+		return endpoint, ErrNoEndpoint
+	}
+	if err != nil {
+		zaplogger.GetLogger().Error("Error rotating webhook secret", zap.Error(err), zap.String("clientID", clientID))
+		return endpoint, err
+	}
+	endpoint.PreviousSecret = ""
+	return endpoint, nil
+}
+
+// VerifySignature checks a signature header against a payload with the client's active
+// signing secrets, as a client's receiver should
+func (s *WebhookServiceImpl) VerifySignature(ctx context.Context, clientID, header string, payload []byte) (localModels.WebhookVerification, error) {
+	endpoint, err := s.findEndpoint(ctx, clientID)
+	if err != nil {
+		return localModels.WebhookVerification{}, err
+	}
+	return verification(endpoint, header, payload, time.Now()), nil
+}
+
+// verification describes the outcome of checking a signature against an endpoint's secrets
+func verification(endpoint localModels.WebhookEndpoint, header string, payload []byte, now time.Time) localModels.WebhookVerification {
+	i, err := Verify(header, payload, now, endpoint.ActiveSecrets(now)...)
+	switch {
+	case err != nil:
+		return localModels.WebhookVerification{Reason: err.Error()}
+	case i == 0:
+		return localModels.WebhookVerification{Valid: true, Secret: "current"}
+	default:
+		return localModels.WebhookVerification{Valid: true, Secret: "previous"}
+	}
+}
+
+// SetEndpoint registers or changes the client's webhook URL. The signing secret is
+// generated when the endpoint is first registered and returned with the endpoint.
+func (s *WebhookServiceImpl) SetEndpoint(ctx context.Context, clientID, url string) (localModels.WebhookEndpoint, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return localModels.WebhookEndpoint{}, err
+	}
+	now := timestamp.Now()
+	update := bson.M{
+		"$set":         bson.M{"url": url, "updated_at": now},
+		"$setOnInsert": bson.M{"client_id": clientID, "secret": secret, "created_at": now},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var endpoint localModels.WebhookEndpoint
+	err = common.GetCollection(s.EndpointCollectionName).FindOneAndUpdate(ctx, bson.M{"client_id": clientID}, update, opts).Decode(&endpoint)
+	if err != nil {
+		zaplogger.GetLogger().Error("Error saving webhook endpoint", zap.Error(err), zap.String("clientID", clientID))
+		return endpoint, err
+	}
+	return endpoint, nil
+}
+
+// ValidEndpointURL reports whether raw is an absolute http(s) URL that can receive webhooks
+func ValidEndpointURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != ""
+}
+
+// newSecret generates a random webhook signing secret
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	localModels "github.com/rachel-lawrie/verus_app_backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"document.upload_failed"}`)
+
+	signature := Sign(at, body, "whsec_test")
+
+	assert.Equal(t, "t=1700000000,v1=", signature[:16])
+	assert.Len(t, signature, 16+64)
+	assert.Equal(t, signature, Sign(at, body, "whsec_test"), "signature must be deterministic")
+	assert.NotEqual(t, signature, Sign(at, body, "whsec_other"))
+	assert.NotEqual(t, signature, Sign(at.Add(time.Second), body, "whsec_test"))
+
+	// During a rotation each active secret signs, newest first
+	rotating := Sign(at, body, "whsec_test", "whsec_old")
+	assert.Equal(t, signature, rotating[:len(signature)])
+	assert.Equal(t, Sign(at, body, "whsec_old")[len("t=1700000000"):], rotating[len(signature):])
+}
+
+func TestVerify(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"security.alert"}`)
+	header := Sign(at, body, "whsec_new", "whsec_old")
+
+	i, err := Verify(header, body, at, "whsec_new", "whsec_old")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, i)
+
+	// A receiver still holding the old secret accepts the delivery
+	i, err = Verify(header, body, at.Add(time.Minute), "whsec_old")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, i)
+
+	i, err = Verify(Sign(at, body, "whsec_old"), body, at, "whsec_new", "whsec_old")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, i)
+
+	_, err = Verify(header, []byte(`{"type":"tampered"}`), at, "whsec_new")
+	assert.ErrorIs(t, err, ErrSignatureMismatch)
+	_, err = Verify(header, body, at.Add(SignatureTolerance+time.Second), "whsec_new")
+	assert.ErrorIs(t, err, ErrStaleSignature)
+	for _, malformed := range []string{"", "v1=abcd", "t=soon,v1=abcd", "t=1700000000", "t=1700000000,v1=zz"} {
+		_, err = Verify(malformed, body, at, "whsec_new")
+		assert.ErrorIs(t, err, ErrMalformedSignature, malformed)
+	}
+}
+
+func TestVerification(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	retires := now.Add(time.Hour)
+	endpoint := localModels.WebhookEndpoint{Secret: "whsec_new", PreviousSecret: "whsec_old", PreviousSecretRetiresAt: &retires}
+	body := []byte(`{}`)
+
+	assert.Equal(t, localModels.WebhookVerification{Valid: true, Secret: "current"}, verification(endpoint, Sign(now, body, "whsec_new"), body, now))
+	assert.Equal(t, localModels.WebhookVerification{Valid: true, Secret: "previous"}, verification(endpoint, Sign(now, body, "whsec_old"), body, now))
+
+	// Once the previous secret retires its signatures are refused
+	later := retires.Add(time.Second)
+	assert.Equal(t, localModels.WebhookVerification{Reason: ErrSignatureMismatch.Error()}, verification(endpoint, Sign(later, body, "whsec_old"), body, later))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoff(1))
+	assert.Equal(t, 60*time.Second, backoff(2))
+	assert.Equal(t, 8*time.Minute, backoff(5))
+	assert.Equal(t, maxBackoff, backoff(20))
+	assert.Equal(t, maxBackoff, backoff(80))
+}
+
+// policyLoader serves per-client retry policies and counts lookups
+type policyLoader struct {
+	attempts map[string]int
+	calls    int
+}
+
+func (l *policyLoader) Load(ctx context.Context, clientID string) (localModels.Client, error) {
+	l.calls++
+	if clientID == "broken" {
+		return localModels.Client{}, errors.New("lookup failed")
+	}
+	client := localModels.Client{ClientID: clientID}
+	client.Settings.Webhooks.MaxAttempts = l.attempts[clientID]
+	return client, nil
+}
+
+func TestMaxAttempts(t *testing.T) {
+	loader := &policyLoader{attempts: map[string]int{"patient": 20}}
+	service := WebhookServiceImpl{MaxAttempts: 8, ClientConfig: loader}
+	policies := make(map[string]int)
+
+	assert.Equal(t, 20, service.maxAttempts(context.Background(), "patient", policies))
+	assert.Equal(t, 20, service.maxAttempts(context.Background(), "patient", policies))
+	assert.Equal(t, 8, service.maxAttempts(context.Background(), "default", policies))
+	assert.Equal(t, 8, service.maxAttempts(context.Background(), "broken", policies))
+	assert.Equal(t, 3, loader.calls, "each client is looked up once per delivery run")
+
+	assert.Equal(t, 8, (&WebhookServiceImpl{MaxAttempts: 8}).maxAttempts(context.Background(), "patient", policies))
+}
+
+func TestEndpointSigningState(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	retires := now.Add(time.Hour)
+	endpoint := localModels.WebhookEndpoint{
+		Secret:                  "whsec_0123456789abcdef",
+		PreviousSecret:          "whsec_fedcba9876543210",
+		PreviousSecretRetiresAt: &retires,
+		SecretRotatedAt:         &now,
+	}
+
+	assert.Equal(t, []string{endpoint.Secret, endpoint.PreviousSecret}, endpoint.ActiveSecrets(now))
+	assert.Equal(t, localModels.WebhookSigning{
+		SecretHint:              "whsec_012345...",
+		RotatedAt:               &now,
+		PreviousSecretHint:      "whsec_fedcba...",
+		PreviousSecretRetiresAt: &retires,
+	}, endpoint.SigningState(now))
+
+	assert.Equal(t, []string{endpoint.Secret}, endpoint.ActiveSecrets(retires))
+	assert.Equal(t, localModels.WebhookSigning{SecretHint: "whsec_012345...", RotatedAt: &now}, endpoint.SigningState(retires))
+}
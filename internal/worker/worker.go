@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/rachel-lawrie/verus_backend_core/zaplogger"
+	zap "go.uber.org/zap"
+)
+
+// Every runs job straight away and then once per interval until ctx is cancelled.
+// Errors are logged and do not stop the loop.
+func Every(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	logger := zaplogger.GetLogger().With(zap.String("worker", name))
+	logger.Info("Worker started", zap.Duration("interval", interval))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := job(ctx); err != nil {
+			logger.Error("Worker run failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("Worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}